@@ -8,7 +8,7 @@ import (
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/handlers"
 	"github.com/net2share/dnstm/internal/router"
-	"github.com/net2share/go-corelib/osdetect"
+	"github.com/net2share/dnstm/internal/system"
 	"github.com/spf13/cobra"
 )
 
@@ -85,7 +85,7 @@ func BuildCobraCommand(action *actions.Action) *cobra.Command {
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		// Check root requirement
 		if action.RequiresRoot {
-			if err := osdetect.RequireRoot(); err != nil {
+			if err := system.RequireRoot(); err != nil {
 				return err
 			}
 		}
@@ -98,12 +98,16 @@ func BuildCobraCommand(action *actions.Action) *cobra.Command {
 		}
 
 		// Build context
+		output := handlers.NewTUIOutput()
+		output.Quiet, _ = cmd.Flags().GetBool("quiet")
+		assumeYes, _ := cmd.Flags().GetBool("yes")
 		ctx := &actions.Context{
 			Ctx:           context.Background(),
 			Args:          args,
 			Values:        make(map[string]interface{}),
-			Output:        handlers.NewTUIOutput(),
+			Output:        output,
 			IsInteractive: false,
+			AssumeYes:     assumeYes,
 		}
 
 		// Load config if needed
@@ -116,9 +120,6 @@ func BuildCobraCommand(action *actions.Action) *cobra.Command {
 		if action.Args != nil && action.Args.Name == "tag" {
 			tagVal, _ := cmd.Flags().GetString("tag")
 			ctx.Values["tag"] = tagVal
-			if action.Args.Required && tagVal == "" {
-				return fmt.Errorf("--tag/-t is required\n\nUsage: %s", cmd.UseLine())
-			}
 		}
 
 		// Collect values from flags
@@ -145,16 +146,25 @@ func BuildCobraCommand(action *actions.Action) *cobra.Command {
 			ctx.Values[action.Confirm.ForceFlag] = force
 		}
 
+		// Require the tag unless its alternative input was given
+		if action.Args != nil && action.Args.Name == "tag" && action.Args.Required && ctx.GetString("tag") == "" {
+			if action.Args.OrInput == "" {
+				return actions.UsageError("--tag/-t is required", "\nUsage: "+cmd.UseLine())
+			}
+			if ctx.GetString(action.Args.OrInput) == "" {
+				return actions.UsageError(fmt.Sprintf("--tag/-t or --%s is required", action.Args.OrInput), "\nUsage: "+cmd.UseLine())
+			}
+		}
+
 		// Require non-tag arguments in CLI mode
 		if action.Args != nil && action.Args.Name != "tag" && action.Args.Required && len(args) == 0 {
-			return fmt.Errorf("%s is required\n\nUsage: %s", action.Args.Name, cmd.UseLine())
+			return actions.UsageError(fmt.Sprintf("%s is required", action.Args.Name), "\nUsage: "+cmd.UseLine())
 		}
 
 		// Handle confirmation — require --force in CLI mode
-		if action.Confirm != nil {
-			force := ctx.GetBool(action.Confirm.ForceFlag)
-			if !force {
-				return fmt.Errorf("%s\n\nUse --force to confirm", action.Confirm.Message)
+		if action.Confirm != nil && !(action.Confirm.DryRunFlag != "" && ctx.GetBool(action.Confirm.DryRunFlag)) {
+			if !ctx.Confirmed(action.Confirm.ForceFlag) {
+				return actions.UsageError(action.Confirm.Message, "\nUse --force or --yes to confirm")
 			}
 		}
 
@@ -176,19 +186,23 @@ func BuildAllCommands() []*cobra.Command {
 	// Build top-level commands
 	for _, action := range actions.TopLevel() {
 		cmd := BuildCobraCommand(action)
-
-		// Add child commands
-		for _, child := range actions.GetChildren(action.ID) {
-			childCmd := BuildCobraCommand(child)
-			cmd.AddCommand(childCmd)
-		}
-
+		addChildCommands(cmd, action.ID)
 		commands = append(commands, cmd)
 	}
 
 	return commands
 }
 
+// addChildCommands adds the commands of an action's children, including
+// those of nested submenus.
+func addChildCommands(cmd *cobra.Command, parentID string) {
+	for _, child := range actions.GetChildren(parentID) {
+		childCmd := BuildCobraCommand(child)
+		addChildCommands(childCmd, child.ID)
+		cmd.AddCommand(childCmd)
+	}
+}
+
 // RegisterActionsWithRoot adds all action-based commands to a root command.
 func RegisterActionsWithRoot(root *cobra.Command) {
 	for _, cmd := range BuildAllCommands() {
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var agentRunCmd = &cobra.Command{
+	Use:    "agent-run",
+	Short:  "Keep the control channel to the controller open (started by the dnstm-agent service)",
+	Hidden: true,
+	RunE:   runAgent,
+}
+
+func init() {
+	rootCmd.AddCommand(agentRunCmd)
+}
+
+func runAgent(cmd *cobra.Command, args []string) error {
+	a, err := agent.New()
+	if err != nil {
+		return err
+	}
+	a.Logf = log.Printf
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = agent.ErrRevoked
+	if !a.State.Revoked {
+		log.Printf("agent %s: connecting to %s", a.State.Name, a.State.Controller)
+		err = a.Serve(ctx)
+	}
+	if errors.Is(err, agent.ErrRevoked) {
+		// Restart=always would bring the service back
+		log.Printf("agent %s: revoked by the controller; stopping", a.State.Name)
+		service.DisableService(agent.ServiceName)
+		service.StopService(agent.ServiceName)
+		return nil
+	}
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("agent: %w", err)
+	}
+	return nil
+}
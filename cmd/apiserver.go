@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/api"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var apiServerCmd = &cobra.Command{
+	Use:    "api-server",
+	Short:  "Run the API server (started by the dnstm-api service)",
+	Hidden: true,
+	RunE:   runAPIServer,
+}
+
+func init() {
+	rootCmd.AddCommand(apiServerCmd)
+}
+
+func runAPIServer(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.API.IsEnabled() {
+		return fmt.Errorf("the API server is not enabled; run 'dnstm api enable'")
+	}
+	return api.ListenAndServe(cfg)
+}
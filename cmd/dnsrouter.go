@@ -3,13 +3,18 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/dnsrouter"
 	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/privacy"
 	"github.com/spf13/cobra"
 )
 
@@ -28,6 +33,7 @@ var dnsrouterServeCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(dnsrouterCmd)
 	dnsrouterCmd.AddCommand(dnsrouterServeCmd)
+	dnsrouterServeCmd.Flags().String("pprof", "", "Serve pprof profiles on this address for debugging (e.g. 127.0.0.1:6060)")
 }
 
 func runDNSRouterServe(cmd *cobra.Command, args []string) error {
@@ -36,21 +42,44 @@ func runDNSRouterServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Derive routes from enabled tunnels
+	// Derive routes from enabled DNS tunnels; an enabled canary shares the
+	// route of its stable tunnel, and a paused tunnel keeps its route to
+	// answer with an error
 	var routes []dnsrouter.Route
 	for _, t := range cfg.Tunnels {
-		if t.IsEnabled() {
-			routes = append(routes, dnsrouter.Route{
-				Domain:  t.Domain,
-				Backend: fmt.Sprintf("127.0.0.1:%d", t.Port),
-			})
+		if !t.IsEnabled() || !t.Transport.IsDNS() {
+			continue
 		}
+		if stable := cfg.CanaryOf(t.Tag); stable != nil && stable.IsEnabled() {
+			continue
+		}
+		if t.IsPaused() {
+			reply := dnsrouter.RcodeServFail
+			if t.Paused == config.PauseRefused {
+				reply = dnsrouter.RcodeRefused
+			}
+			routes = append(routes, dnsrouter.Route{Domain: t.Domain, Reply: reply})
+			log.Printf("Tunnel %s is paused, answering %s with %s", t.Tag, t.Domain, strings.ToUpper(t.Paused))
+			continue
+		}
+		route := dnsrouter.Route{
+			Domain:  t.Domain,
+			Backend: fmt.Sprintf("127.0.0.1:%d", t.Port),
+		}
+		if t.Canary != nil && !cfg.Route.Stateless {
+			if canary := cfg.GetTunnelByTag(t.Canary.Tunnel); canary != nil && canary.IsEnabled() && !canary.IsPaused() {
+				route.Canary = fmt.Sprintf("127.0.0.1:%d", canary.Port)
+				route.CanaryPercent = t.Canary.Percent
+				log.Printf("Sending %d%% of %s to canary %s", t.Canary.Percent, t.Domain, canary.Tag)
+			}
+		}
+		routes = append(routes, route)
 	}
 
 	// Derive default backend
 	defaultBackend := ""
 	if cfg.Route.Default != "" {
-		if t := cfg.GetTunnelByTag(cfg.Route.Default); t != nil {
+		if t := cfg.GetTunnelByTag(cfg.Route.Default); t != nil && t.Transport.IsDNS() {
 			defaultBackend = fmt.Sprintf("127.0.0.1:%d", t.Port)
 		}
 	}
@@ -58,6 +87,30 @@ func runDNSRouterServe(cmd *cobra.Command, args []string) error {
 	// Resolve listen address (0.0.0.0 → external IP)
 	listenAddr := network.ResolveListenAddress(cfg.Listen.Address)
 
+	// A broken zone file only disables the decoy answers
+	zone := dnsrouter.NewZone()
+	if cfg.Route.Decoy != nil {
+		decoy, err := dnsrouter.LoadZone(cfg.Route.Decoy.ZoneFile)
+		if err != nil {
+			log.Printf("[warning] decoy zone disabled: %v", err)
+		} else {
+			zone = decoy
+			log.Printf("Serving decoy zone for %s", strings.Join(decoy.Domains(), ", "))
+		}
+	}
+	for _, r := range cfg.Route.Records {
+		rec, err := dnsrouter.NewRecord(r.Name, r.Type, r.Value, uint32(r.TTL))
+		if err != nil {
+			log.Printf("[warning] skipping record %s %s: %v", r.Name, r.Type, err)
+			continue
+		}
+		zone.Add(rec)
+	}
+
+	if cfg.Route.Stateless {
+		log.Printf("Stateless routing: backends are picked by query name alone")
+	}
+
 	// Create forwarder using factory
 	forwarder, err := dnsrouter.NewForwarder(
 		dnsrouter.ForwarderTypeNative,
@@ -65,6 +118,11 @@ func runDNSRouterServe(cmd *cobra.Command, args []string) error {
 			ListenAddr:     listenAddr,
 			Routes:         routes,
 			DefaultBackend: defaultBackend,
+			Zone:           withACME(zone),
+			StatsFile:      dnsrouter.ResolverStatsFile,
+			Workers:        cfg.Listen.Workers,
+			Stateless:      cfg.Route.Stateless,
+			AnonymizeIP:    privacy.New(cfg.Privacy).IP,
 		},
 	)
 	if err != nil {
@@ -76,13 +134,54 @@ func runDNSRouterServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to start forwarder: %w", err)
 	}
 
-	// Wait for signal
+	if addr, _ := cmd.Flags().GetString("pprof"); addr != "" {
+		go servePprof(addr)
+	}
+
+	// Wait for signal, rereading ACME challenges on SIGHUP
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	log.Printf("DNS router running. Press Ctrl+C to stop.")
-	<-sigCh
+	for sig := range sigCh {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		forwarder.SetZone(withACME(zone))
+	}
 
 	log.Printf("Shutting down...")
 	return forwarder.Stop()
 }
+
+// servePprof serves the runtime profiles under /debug/pprof/ on addr.
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	log.Printf("Serving pprof on http://%s/debug/pprof/", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("[warning] pprof server stopped: %v", err)
+	}
+}
+
+// withACME returns the zone with the pending ACME challenge records added,
+// or nil when there is nothing to answer locally.
+func withACME(base *dnsrouter.Zone) *dnsrouter.Zone {
+	challenges, err := dnsrouter.LoadACMEChallenges(time.Now())
+	if err != nil {
+		log.Printf("[warning] ACME challenges not loaded: %v", err)
+	}
+	if len(challenges) > 0 {
+		log.Printf("Serving %d ACME challenge record(s)", len(challenges))
+	}
+	zone := dnsrouter.WithACME(base, challenges)
+	if zone.IsEmpty() {
+		return nil
+	}
+	return zone
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/sessionlog"
+	"github.com/spf13/cobra"
+)
+
+var healthCmd = &cobra.Command{
+	Use:    "health",
+	Short:  "Built-in health endpoint commands",
+	Hidden: true,
+}
+
+var healthServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the health endpoint and the tunnel fronts",
+	RunE:  runHealthServe,
+}
+
+func init() {
+	rootCmd.AddCommand(healthCmd)
+	healthCmd.AddCommand(healthServeCmd)
+}
+
+func runHealthServe(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Log.Sessions == nil {
+		return health.Serve(cfg, nil)
+	}
+
+	recorder, err := sessionlog.Open(cfg)
+	if err != nil {
+		return err
+	}
+	// Session events still queued are written before the service stops
+	defer recorder.Close()
+	errs := make(chan error, 1)
+	go func() { errs <- health.Serve(cfg, recorder) }()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		return err
+	case <-sigs:
+		return nil
+	}
+}
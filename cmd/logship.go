@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/logship"
+	"github.com/spf13/cobra"
+)
+
+var logshipCmd = &cobra.Command{
+	Use:    "logship",
+	Short:  "Log shipping commands",
+	Hidden: true,
+}
+
+var logshipServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Send the journal of the dnstm services to the log.ship target",
+	RunE:  runLogshipServe,
+}
+
+func init() {
+	rootCmd.AddCommand(logshipCmd)
+	logshipCmd.AddCommand(logshipServeCmd)
+}
+
+func runLogshipServe(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Log.Ship == nil {
+		return fmt.Errorf("log.ship is not set")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return logship.Run(ctx, cfg)
+}
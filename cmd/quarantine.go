@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/net2share/dnstm/internal/quarantine"
+	"github.com/spf13/cobra"
+)
+
+var quarantineHookCmd = &cobra.Command{
+	Use:    "quarantine-hook UNIT",
+	Short:  "Quarantine a crash-looping tunnel (run by systemd OnFailure)",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE:   runQuarantineHook,
+}
+
+func init() {
+	rootCmd.AddCommand(quarantineHookCmd)
+}
+
+func runQuarantineHook(cmd *cobra.Command, args []string) error {
+	r, err := quarantine.HandleFailure(args[0])
+	if err != nil {
+		return fmt.Errorf("quarantine: %w", err)
+	}
+	if r == nil {
+		return nil
+	}
+
+	msg := fmt.Sprintf("dnstm: tunnel '%s' is crash-looping (%s restarts) and has been quarantined. "+
+		"Check 'journalctl -u %s', then run 'dnstm tunnel unquarantine -t %s'.", r.Tag, r.Restarts, args[0], r.Tag)
+
+	// "<2>" logs at critical priority in the journal
+	fmt.Fprintln(os.Stderr, "<2>"+msg)
+	exec.Command("wall", msg).Run()
+	return nil
+}
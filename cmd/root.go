@@ -2,6 +2,8 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -9,10 +11,14 @@ import (
 	// Import handlers to register them with actions
 	_ "github.com/net2share/dnstm/internal/handlers"
 
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/i18n"
 	"github.com/net2share/dnstm/internal/menu"
+	"github.com/net2share/dnstm/internal/prompt"
+	"github.com/net2share/dnstm/internal/remote"
+	"github.com/net2share/dnstm/internal/system"
 	"github.com/net2share/dnstm/internal/transport"
 	"github.com/net2share/dnstm/internal/version"
-	"github.com/net2share/go-corelib/osdetect"
 	"github.com/spf13/cobra"
 )
 
@@ -20,7 +26,11 @@ import (
 func requireInstalled() error {
 	if !transport.IsInstalled() {
 		missing := transport.GetMissingBinaries()
-		return fmt.Errorf("transport binaries not installed. Missing: %s\nRun 'dnstm install' first", strings.Join(missing, ", "))
+		return &actions.ActionError{
+			Message: i18n.Tf("transport binaries not installed. Missing: %v", strings.Join(missing, ", ")),
+			Hint:    "Run 'dnstm install' first",
+			Err:     actions.ErrNotInstalled,
+		}
 	}
 	return nil
 }
@@ -30,9 +40,13 @@ var rootCmd = &cobra.Command{
 	Short: "DNS Tunnel Manager",
 	Long:  "DNS Tunnel Manager - https://github.com/net2share/dnstm",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := osdetect.RequireRoot(); err != nil {
+		if err := system.RequireRoot(); err != nil {
 			return err
 		}
+		if prompt.IsHeadless() {
+			return actions.UsageError("the interactive menu needs a terminal and cannot be used with --yes or --non-interactive",
+				"Run a command instead, e.g. 'dnstm tunnel list' (see 'dnstm --help')")
+		}
 		menu.InitTUI()
 		return menu.RunInteractive()
 	},
@@ -41,15 +55,121 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.Version = version.Version
 
+	// Handled in Execute before cobra parses arguments; registered for help output
+	rootCmd.PersistentFlags().StringP("host", "H", "", "Run the command on a remote server over SSH (user@host, or $"+remote.HostEnv+")")
+	// Applied in Execute before commands run so errors and menus are translated
+	rootCmd.PersistentFlags().String("lang", "", "Language for the interactive menu and common errors: "+strings.Join(i18n.Languages, "|")+" (default: $"+i18n.LangEnv+" or $LANG)")
+
+	rootCmd.PersistentFlags().Bool("plain", false, "Use numbered prompts without colors instead of the full-screen menus (or set $"+prompt.PlainEnv+")")
+	// Both are applied in Execute; --yes is also read by each command
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "Answer confirmations with yes and fail instead of prompting, for automation")
+	rootCmd.PersistentFlags().Bool("non-interactive", false, "Fail instead of prompting when a value is missing")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Print only warnings, errors and requested output, e.g. for cron jobs")
+
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return actions.UsageError(err.Error(), "")
+	})
+
 	// Register all action-based commands
 	RegisterActionsWithRoot(rootCmd)
 }
 
 // Execute runs the root command.
+// With --host set, the command is forwarded to the remote server over SSH.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	host, args, err := remote.ExtractHost(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
+	if host != "" {
+		code, err := remote.Run(host, args)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(code)
+	}
+
+	if err := setLanguage(args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	// Without a terminal nothing may wait for input, e.g. under cron or ssh
+	terminal := prompt.IsTerminal()
+	prompt.SetPlain(hasFlag(args, "--plain") || prompt.DetectPlain() || !terminal)
+	prompt.SetHeadless(!terminal || hasFlag(args, "--yes") || hasFlag(args, "-y") || hasFlag(args, "--non-interactive"))
+
+	jsonErrors := wantsJSON(args)
+	rootCmd.SilenceErrors = jsonErrors
+	rootCmd.SilenceUsage = jsonErrors
+	if err := rootCmd.Execute(); err != nil {
+		code := actions.ExitCodeOf(err)
+		if jsonErrors {
+			printJSONError(err, code)
+		}
+		os.Exit(int(code))
+	}
+}
+
+// wantsJSON reports whether the command was asked for JSON output, in which
+// case a failure is printed as JSON too.
+func wantsJSON(args []string) bool {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return false
+		case arg == "--format=json", arg == "--format" && i+1 < len(args) && args[i+1] == "json":
+			return true
+		}
+	}
+	return false
+}
+
+// printJSONError prints err to stdout as
+// {"error": {"code": 2, "name": "config_invalid", "message": "..."}}.
+func printJSONError(err error, code actions.ExitCode) {
+	out := struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Name    string `json:"name"`
+			Message string `json:"message"`
+			Hint    string `json:"hint,omitempty"`
+		} `json:"error"`
+	}{}
+	out.Error.Code = int(code)
+	out.Error.Name = code.String()
+	out.Error.Message = err.Error()
+	var actionErr *actions.ActionError
+	if errors.As(err, &actionErr) && actionErr.Hint != "" {
+		out.Error.Message = i18n.T(actionErr.Message)
+		out.Error.Hint = strings.TrimSpace(i18n.T(actionErr.Hint))
+	}
+	data, _ := json.Marshal(out)
+	fmt.Println(string(data))
+}
+
+// setLanguage selects the message language from --lang, falling back to the
+// locale environment.
+func setLanguage(args []string) error {
+	if lang := i18n.FromArgs(args); lang != "" {
+		return i18n.SetLanguage(lang)
+	}
+	return i18n.SetLanguage(i18n.Detect())
+}
+
+// hasFlag reports whether the boolean flag name is set in args.
+// Arguments after "--" are not inspected.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case name, name + "=true":
+			return true
+		}
+	}
+	return false
 }
 
 // SetVersionInfo sets version information for the CLI.
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var serviceHostCmd = &cobra.Command{
+	Use:    service.HostCommand + " NAME",
+	Short:  "Run a dnstm service (started by the service manager)",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return service.RunHost(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serviceHostCmd)
+}
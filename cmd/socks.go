@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/proxy/gosocks"
+	"github.com/spf13/cobra"
+)
+
+var socksCmd = &cobra.Command{
+	Use:    "socks",
+	Short:  "Built-in SOCKS5 proxy commands",
+	Hidden: true,
+}
+
+var socksServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the built-in SOCKS5 server",
+	RunE:  runSocksServe,
+}
+
+var socksApplyEgressCmd = &cobra.Command{
+	Use:   "apply-egress",
+	Short: "Sync nftables egress rules for the SOCKS proxy",
+	RunE:  runSocksApplyEgress,
+}
+
+func init() {
+	rootCmd.AddCommand(socksCmd)
+	socksCmd.AddCommand(socksServeCmd)
+	socksCmd.AddCommand(socksApplyEgressCmd)
+}
+
+func runSocksApplyEgress(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	return proxy.ApplySocksEgress(cfg)
+}
+
+func runSocksServe(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	port := cfg.Proxy.Port
+	if port == 0 {
+		port = 1080
+	}
+
+	serverCfg := gosocks.Config{
+		ListenAddr:    fmt.Sprintf("%s:%d", proxy.MicrosocksBindAddr, port),
+		MaxConnsPerIP: cfg.Proxy.MaxConnsPerIP,
+	}
+	if socksBackend := cfg.GetBackendByTag("socks"); socksBackend != nil {
+		if socksBackend.HasSocksAuth() {
+			serverCfg.User = socksBackend.Socks.User
+			serverCfg.Password = socksBackend.Socks.Password
+		}
+		rules, err := socksBackend.EgressRules()
+		if err != nil {
+			return fmt.Errorf("invalid egress rules: %w", err)
+		}
+		serverCfg.Rules = rules
+		if o := socksBackend.Outbound; o != nil {
+			serverCfg.BindInterface = o.Interface
+			if o.SourceIP != "" {
+				serverCfg.BindAddr = net.ParseIP(o.SourceIP)
+			}
+		}
+		if k := socksBackend.Keepalive; k != nil {
+			serverCfg.KeepAlive = net.KeepAliveConfig{
+				Enable:   true,
+				Idle:     k.IdleDuration(),
+				Interval: k.IntervalDuration(),
+				Count:    k.Count,
+			}
+			serverCfg.IdleTimeout = k.IdleTimeoutDuration()
+		}
+	}
+
+	server := gosocks.New(serverCfg)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	// Wait for signal, saving the counters for status and metrics meanwhile
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	failed := false
+
+	for {
+		select {
+		case err := <-errCh:
+			return err
+		case <-sigCh:
+			log.Printf("Shutting down...")
+			err := server.Close()
+			saveSocksStats(server)
+			return err
+		case <-ticker.C:
+			if err := saveSocksStats(server); err != nil && !failed {
+				log.Printf("[warning] SOCKS stats not saved: %v", err)
+				failed = true
+			}
+		}
+	}
+}
+
+// saveSocksStats writes the current counters of server for
+// "dnstm backend status" and "dnstm stats collect".
+func saveSocksStats(server *gosocks.Server) error {
+	return proxy.SaveSocksStats(&proxy.SocksStats{Stats: server.Stats(), Updated: time.Now()})
+}
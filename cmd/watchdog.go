@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/net2share/dnstm/internal/watchdog"
+	"github.com/spf13/cobra"
+)
+
+var watchdogCmd = &cobra.Command{
+	Use:    "watchdog --addr HOST:PORT --domain DOMAIN [--timeout 30s] -- COMMAND [ARGS...]",
+	Short:  "Run a transport under the systemd watchdog",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	RunE:   runWatchdog,
+}
+
+func init() {
+	rootCmd.AddCommand(watchdogCmd)
+	watchdogCmd.Flags().String("addr", "", "Address the transport listens on")
+	watchdogCmd.Flags().String("domain", "", "Tunnel domain used for probe queries")
+	watchdogCmd.Flags().Duration("timeout", 30*time.Second, "systemd WatchdogSec")
+	watchdogCmd.MarkFlagRequired("addr")
+	watchdogCmd.MarkFlagRequired("domain")
+}
+
+func runWatchdog(cmd *cobra.Command, args []string) error {
+	addr, _ := cmd.Flags().GetString("addr")
+	domain, _ := cmd.Flags().GetString("domain")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	code, err := watchdog.Run(watchdog.Config{
+		Addr:    addr,
+		Domain:  domain,
+		Timeout: timeout,
+		Command: args,
+	})
+	if err != nil {
+		return fmt.Errorf("watchdog: %w", err)
+	}
+	os.Exit(code)
+	return nil
+}
@@ -79,6 +79,9 @@ type ConfirmConfig struct {
 	DefaultNo bool
 	// ForceFlag is the flag name to skip confirmation (e.g., "force").
 	ForceFlag string
+	// DryRunFlag is a bool input that makes the action only report what it
+	// would do; no confirmation is needed when it is set.
+	DryRunFlag string
 }
 
 // ArgsSpec defines the positional arguments for an action.
@@ -91,6 +94,9 @@ type ArgsSpec struct {
 	Required bool
 	// PickerFunc provides interactive selection when arg is not provided.
 	PickerFunc func(ctx *Context) (string, error)
+	// OrInput names an input that can be given instead of a required
+	// argument, e.g. "selector" to act on several tunnels.
+	OrInput string
 }
 
 // Handler is the function signature for action handlers.
@@ -144,6 +150,14 @@ type Context struct {
 	Output OutputWriter
 	// IsInteractive indicates if running in interactive mode.
 	IsInteractive bool
+	// AssumeYes answers confirmations with yes (--yes).
+	AssumeYes bool
+}
+
+// Confirmed reports whether a confirmation was given, either with the
+// force flag of the action or with --yes.
+func (c *Context) Confirmed(forceFlag string) bool {
+	return c.AssumeYes || c.GetBool(forceFlag)
 }
 
 // GetString returns a string value from the context.
@@ -0,0 +1,177 @@
+package actions
+
+import (
+	"os"
+	"strings"
+)
+
+func init() {
+	// Register agent parent action (submenu)
+	Register(&Action{
+		ID:        ActionAgent,
+		Use:       "agent",
+		Short:     "Manage fleet agents",
+		Long:      "Manage a fleet of dnstm servers. A controller (see 'dnstm api agents')\nissues enrollment tokens; other servers enroll with it as agents and keep\na control channel open over mutual TLS, through which the controller runs\ndnstm commands on them.",
+		MenuLabel: "Fleet Agents",
+		IsSubmenu: true,
+	})
+
+	// Register agent.enroll action
+	Register(&Action{
+		ID:                ActionAgentEnroll,
+		Parent:            ActionAgent,
+		Use:               "enroll",
+		Short:             "Enroll this server with a controller",
+		Long:              "Generate this server's agent key, have the controller sign its certificate\nusing a one-time token, and start the dnstm-agent service that keeps the\ncontrol channel open.",
+		MenuLabel:         "Enroll",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "controller",
+				Label:       "Controller",
+				Type:        InputTypeText,
+				Required:    true,
+				Placeholder: "https://203.0.113.10:8443",
+				Description: "Address of the controller's agent listener",
+			},
+			{
+				Name:        "token",
+				Label:       "Token",
+				Type:        InputTypeText,
+				Required:    true,
+				Description: "Enrollment token from 'dnstm agent token' on the controller",
+			},
+			{
+				Name:        "name",
+				Label:       "Agent name",
+				Type:        InputTypeText,
+				Description: "Name of this server in the fleet (defaults to the host name)",
+				DefaultFunc: func(ctx *Context) string {
+					host, _ := os.Hostname()
+					host, _, _ = strings.Cut(strings.ToLower(host), ".")
+					return host
+				},
+			},
+		},
+	})
+
+	// Register agent.status action
+	Register(&Action{
+		ID:                ActionAgentStatus,
+		Parent:            ActionAgent,
+		Use:               "status",
+		Short:             "Show this server's enrollment",
+		Long:              "Show the controller this server is enrolled with and whether the agent runs",
+		MenuLabel:         "Status",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register agent.leave action
+	Register(&Action{
+		ID:                ActionAgentLeave,
+		Parent:            ActionAgent,
+		Use:               "leave",
+		Short:             "Stop being an agent",
+		Long:              "Stop the dnstm-agent service and delete the agent key and certificate.\nRevoke the agent on the controller as well.",
+		MenuLabel:         "Leave",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Confirm: &ConfirmConfig{
+			Message:     "Leave the fleet?",
+			Description: "The controller can no longer run commands on this server.",
+			DefaultNo:   true,
+			ForceFlag:   "force",
+		},
+	})
+
+	// Register agent.token action
+	Register(&Action{
+		ID:                ActionAgentToken,
+		Parent:            ActionAgent,
+		Use:               "token",
+		Short:             "Create an enrollment token",
+		Long:              "Create a one-time token that enrolls one agent with this controller. The\ntoken carries the hash of the controller's CA, so agents can verify the\ncontroller before sending anything.",
+		MenuLabel:         "Create Token",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "ttl",
+				Label:       "Valid for",
+				Type:        InputTypeText,
+				Default:     "24h",
+				Description: "How long the token can be used, e.g. 1h or 7d",
+			},
+		},
+	})
+
+	// Register agent.list action
+	Register(&Action{
+		ID:                ActionAgentList,
+		Parent:            ActionAgent,
+		Use:               "list",
+		Short:             "List enrolled agents",
+		Long:              "List the agents enrolled with this controller and when they were last seen",
+		MenuLabel:         "List",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register agent.revoke action
+	Register(&Action{
+		ID:                ActionAgentRevoke,
+		Parent:            ActionAgent,
+		Use:               "revoke <name>",
+		Short:             "Revoke an agent",
+		Long:              "Revoke an agent's certificate. The controller refuses it from then on, and\nthe agent stops its service when it next connects. The name can be\nenrolled again with a new token.",
+		MenuLabel:         "Revoke",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "name",
+			Description: "Agent name",
+			Required:    true,
+		},
+		Confirm: &ConfirmConfig{
+			Message:   "Revoke this agent?",
+			DefaultNo: true,
+			ForceFlag: "force",
+		},
+	})
+
+	// Register agent.exec action
+	Register(&Action{
+		ID:                ActionAgentExec,
+		Parent:            ActionAgent,
+		Use:               "exec <name> -- <command>...",
+		Short:             "Run a dnstm command on an agent",
+		Long:              "Queue a dnstm command for an agent and print its output once it ran.\nExample: dnstm agent exec vps2 -- tunnel list",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "name",
+			Description: "Agent name",
+			Required:    true,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "timeout",
+				Label:       "Timeout",
+				Type:        InputTypeText,
+				Default:     "2m",
+				Description: "How long to wait for the result; the command stays queued after",
+			},
+		},
+		ShowInMenu: func(ctx *Context) bool {
+			// Takes a command line, CLI only
+			return false
+		},
+	})
+}
+
+// SetAgentHandler sets the handler for an agent action.
+func SetAgentHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
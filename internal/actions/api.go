@@ -0,0 +1,219 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	// Register api parent action (submenu)
+	Register(&Action{
+		ID:                ActionAPI,
+		Use:               "api",
+		Short:             "Manage the API server",
+		Long:              "Manage the API server: an HTTP server dnstm runs as the dnstm-api service.\nIt serves the public status page and the provisioning webhook.",
+		MenuLabel:         "API Server",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register api.enable action
+	Register(&Action{
+		ID:                ActionAPIEnable,
+		Parent:            ActionAPI,
+		Use:               "enable",
+		Short:             "Start the API server",
+		Long:              "Install and start the API server on the given address, opening its port\nin the firewall",
+		MenuLabel:         "Enable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "listen",
+				Label:       "Listen address",
+				Type:        InputTypeText,
+				Description: "Address and port to listen on (host:port)",
+				DefaultFunc: func(ctx *Context) string {
+					if cfg, err := config.Load(); err == nil && cfg.API.Listen != "" {
+						return cfg.API.Listen
+					}
+					return config.DefaultAPIListen
+				},
+			},
+		},
+	})
+
+	// Register api.disable action
+	Register(&Action{
+		ID:                ActionAPIDisable,
+		Parent:            ActionAPI,
+		Use:               "disable",
+		Short:             "Stop the API server",
+		Long:              "Stop and remove the API server service",
+		MenuLabel:         "Disable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register api.status action
+	Register(&Action{
+		ID:                ActionAPIStatus,
+		Parent:            ActionAPI,
+		Use:               "status",
+		Short:             "Show API server status",
+		Long:              "Show whether the API server runs, its address and the routes it serves",
+		MenuLabel:         "Status",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register api.status-page action
+	Register(&Action{
+		ID:                ActionAPIStatusPage,
+		Parent:            ActionAPI,
+		Use:               "status-page",
+		Short:             "Turn the public status page on or off",
+		Long:              "Serve an unauthenticated page at /status (and /status.json) that shows only\nwhether each tunnel domain is up, for sharing with users. Tags, ports,\nkeys and backends are never shown, and the rescue tunnel is not listed.",
+		MenuLabel:         "Status Page",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable status page",
+				Type:        InputTypeBool,
+				Description: "Stop serving the status page",
+			},
+		},
+	})
+
+	// Register api.webhook action
+	Register(&Action{
+		ID:                ActionAPIWebhook,
+		Parent:            ActionAPI,
+		Use:               "webhook",
+		Short:             "Turn the provisioning webhook on or off",
+		Long:              "Accept HMAC-signed requests at /v1/provision that create tunnels, e.g. from a\nbilling panel. Requests are queued and provisioned within a minute; the\nresult, with the tunnel's share URL, is posted to the request's callback URL.",
+		MenuLabel:         "Webhook",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable webhook",
+				Type:        InputTypeBool,
+				Description: "Stop accepting provisioning requests",
+			},
+			{
+				Name:        "secret",
+				Label:       "Shared secret",
+				Type:        InputTypeText,
+				Description: fmt.Sprintf("HMAC key shared with the caller (at least %d characters); generated when empty", config.MinWebhookSecretLength),
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+			{
+				Name:        "backend",
+				Label:       "Default backend",
+				Type:        InputTypeText,
+				Description: "Backend of tunnels whose request names none",
+				DefaultFunc: func(ctx *Context) string {
+					if cfg, err := config.Load(); err == nil && cfg.API.Webhook != nil {
+						return cfg.API.Webhook.GetBackend()
+					}
+					return config.DefaultWebhookBackend
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+		},
+	})
+
+	// Register api.agents action
+	Register(&Action{
+		ID:                ActionAPIAgents,
+		Parent:            ActionAPI,
+		Use:               "agents",
+		Short:             "Turn fleet agent enrollment on or off",
+		Long:              "Make this server a controller: other dnstm servers enroll with it using\n'dnstm agent enroll' and take commands over mutual TLS on their own port.\nCreate enrollment tokens with 'dnstm agent token'.",
+		MenuLabel:         "Agents",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable agents",
+				Type:        InputTypeBool,
+				Description: "Stop accepting agents",
+			},
+			{
+				Name:        "listen",
+				Label:       "Agent listen address",
+				Type:        InputTypeText,
+				Description: "Address and port agents connect to over TLS (host:port)",
+				DefaultFunc: func(ctx *Context) string {
+					if cfg, err := config.Load(); err == nil && cfg.API.Agents != nil {
+						return cfg.API.Agents.Listen
+					}
+					return config.DefaultAgentsListen
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+		},
+	})
+
+	// Register api.jobs action
+	Register(&Action{
+		ID:                ActionAPIJobs,
+		Parent:            ActionAPI,
+		Use:               "jobs",
+		Short:             "List provisioning jobs",
+		Long:              "List the requests received through the provisioning webhook and their outcome",
+		MenuLabel:         "Jobs",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register api.provision action (invoked by the provisioning timer)
+	Register(&Action{
+		ID:                ActionAPIProvision,
+		Parent:            ActionAPI,
+		Use:               "provision",
+		Short:             "Provision queued webhook requests",
+		Hidden:            true,
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register api.spec action
+	Register(&Action{
+		ID:     ActionAPISpec,
+		Parent: ActionAPI,
+		Use:    "spec",
+		Short:  "Print the OpenAPI document of the API",
+		Long:   "Print the OpenAPI 3 document describing every route of the API server,\nto generate clients from. It is also shipped as docs/openapi.json.",
+		Inputs: []InputField{
+			{
+				Name:        "file",
+				Label:       "Output file",
+				ShortFlag:   'o',
+				Type:        InputTypeText,
+				Description: "Optional output file path (stdout if not specified)",
+			},
+		},
+		ShowInMenu: func(ctx *Context) bool {
+			// Prints a document for tools, CLI only
+			return false
+		},
+	})
+}
+
+// SetAPIHandler sets the handler for an API server action.
+func SetAPIHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
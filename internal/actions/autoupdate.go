@@ -0,0 +1,83 @@
+package actions
+
+func init() {
+	// Register auto-update parent action (submenu)
+	Register(&Action{
+		ID:        ActionAutoUpdate,
+		Use:       "auto-update",
+		Short:     "Manage unattended upgrades",
+		Long:      "Schedule dnstm and transport binary upgrades inside a daily maintenance window.\n\nAfter an upgrade the services that were running are checked. If any of them\nfails to come back, the previous binaries are restored and the services restarted.",
+		MenuLabel: "Auto-Update",
+		IsSubmenu: true,
+	})
+
+	// Register auto-update.enable action
+	Register(&Action{
+		ID:                ActionAutoUpdateEnable,
+		Parent:            ActionAutoUpdate,
+		Use:               "enable",
+		Short:             "Enable unattended upgrades",
+		Long:              "Install a systemd timer that runs upgrades once a day inside the maintenance window",
+		MenuLabel:         "Enable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "window",
+				Label:       "Maintenance Window",
+				ShortFlag:   'w',
+				Type:        InputTypeText,
+				Default:     "03:00-05:00",
+				Description: "Daily window in local time (HH:MM-HH:MM)",
+			},
+		},
+	})
+
+	// Register auto-update.disable action
+	Register(&Action{
+		ID:           ActionAutoUpdateDisable,
+		Parent:       ActionAutoUpdate,
+		Use:          "disable",
+		Short:        "Disable unattended upgrades",
+		Long:         "Stop and remove the unattended upgrade timer",
+		MenuLabel:    "Disable",
+		RequiresRoot: true,
+	})
+
+	// Register auto-update.status action
+	Register(&Action{
+		ID:           ActionAutoUpdateStatus,
+		Parent:       ActionAutoUpdate,
+		Use:          "status",
+		Short:        "Show unattended upgrade status",
+		Long:         "Show whether unattended upgrades are enabled, the window, and the last run",
+		MenuLabel:    "Status",
+		RequiresRoot: true,
+	})
+
+	// Register auto-update.run action (invoked by the timer)
+	Register(&Action{
+		ID:                ActionAutoUpdateRun,
+		Parent:            ActionAutoUpdate,
+		Use:               "run",
+		Short:             "Run an unattended upgrade now",
+		Long:              "Upgrade dnstm and transport binaries without prompts, rolling back if services fail afterwards",
+		Hidden:            true,
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "window",
+				Label:       "Maintenance Window",
+				ShortFlag:   'w',
+				Type:        InputTypeText,
+				Description: "Skip the upgrade when run outside this window (HH:MM-HH:MM)",
+			},
+		},
+	})
+}
+
+// SetAutoUpdateHandler sets the handler for an auto-update action.
+func SetAutoUpdateHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
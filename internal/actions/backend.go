@@ -2,6 +2,8 @@ package actions
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/router"
@@ -103,7 +105,17 @@ func init() {
 				Required:    true,
 				Description: "Backend address (host:port)",
 				ShowIf: func(ctx *Context) bool {
-					return ctx.GetString("type") == string(config.BackendCustom)
+					t := ctx.GetString("type")
+					return t == string(config.BackendCustom) || t == string(config.BackendPortForward)
+				},
+			},
+			{
+				Name:        "client-port",
+				Label:       "Client Port",
+				Type:        InputTypeNumber,
+				Description: "Local port clients listen on (defaults to the service port)",
+				ShowIf: func(ctx *Context) bool {
+					return ctx.GetString("type") == string(config.BackendPortForward)
 				},
 			},
 			{
@@ -176,6 +188,329 @@ func init() {
 		},
 	})
 
+	// Register backend.reconfigure action
+	Register(&Action{
+		ID:                ActionBackendReconfigure,
+		Parent:            ActionBackend,
+		Use:               "reconfigure",
+		Short:             "Reconfigure a backend",
+		Long:              "Change a backend's address or credentials and rebuild the tunnels that use it",
+		MenuLabel:         "Reconfigure",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Backend tag",
+			Required:    true,
+			PickerFunc:  ReconfigurableBackendPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "address",
+				Label:       "Address",
+				ShortFlag:   'a',
+				Type:        InputTypeText,
+				Description: "New backend address (host:port)",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil {
+						return b.Address
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					b := selectedBackend(ctx)
+					return b == nil || b.Type == config.BackendSSH || b.Type == config.BackendCustom || b.Type == config.BackendPortForward
+				},
+			},
+			{
+				Name:        "client-port",
+				Label:       "Client Port",
+				Type:        InputTypeNumber,
+				Description: "New local port clients listen on",
+				ShowIf: func(ctx *Context) bool {
+					b := selectedBackend(ctx)
+					return b == nil || b.Type == config.BackendPortForward
+				},
+			},
+			{
+				Name:        "password",
+				Label:       "Password",
+				ShortFlag:   'p',
+				Type:        InputTypePassword,
+				Description: "New Shadowsocks password (unchanged if empty)",
+				ShowIf: func(ctx *Context) bool {
+					b := selectedBackend(ctx)
+					return b == nil || b.Type == config.BackendShadowsocks
+				},
+			},
+			{
+				Name:        "method",
+				Label:       "Encryption Method",
+				ShortFlag:   'm',
+				Type:        InputTypeSelect,
+				Options:     EncryptionMethodOptions(),
+				Description: "New Shadowsocks encryption method",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil && b.Shadowsocks != nil {
+						return b.Shadowsocks.Method
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					b := selectedBackend(ctx)
+					return b == nil || b.Type == config.BackendShadowsocks
+				},
+			},
+		},
+	})
+
+	// Register backend.egress action
+	Register(&Action{
+		ID:                ActionBackendEgress,
+		Parent:            ActionBackend,
+		Use:               "egress",
+		Short:             "Configure SOCKS egress rules",
+		Long:              "Restrict the destinations the SOCKS proxy may connect to.\n\nRules have the form \"<allow|deny> <cidr|ip> [port|port-port]\" and are\nseparated by semicolons. The first matching rule wins; unmatched traffic is allowed.",
+		MenuLabel:         "Egress Rules",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Backend tag",
+			Required:    true,
+			PickerFunc:  SocksBackendPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "clear",
+				Label:       "Clear egress rules",
+				Type:        InputTypeBool,
+				Description: "Remove all egress rules",
+			},
+			{
+				Name:        "rules",
+				Label:       "Rules",
+				ShortFlag:   'r',
+				Type:        InputTypeText,
+				Description: "Semicolon-separated rules, e.g. \"deny 10.0.0.0/8; deny 0.0.0.0/0 25\"",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil && b.Egress != nil {
+						return strings.Join(b.Egress.Rules, "; ")
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear")
+				},
+			},
+		},
+	})
+
+	// Register backend.outbound action
+	Register(&Action{
+		ID:                ActionBackendOutbound,
+		Parent:            ActionBackend,
+		Use:               "outbound",
+		Short:             "Configure outbound interface",
+		Long:              "Bind a backend's outgoing connections to a network interface or source IP.\n\nSupported for the SOCKS proxy and Shadowsocks backends. Binding the SOCKS\nproxy to an interface requires the built-in engine.",
+		MenuLabel:         "Outbound Interface",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Backend tag",
+			Required:    true,
+			PickerFunc:  OutboundBackendPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "clear",
+				Label:       "Clear outbound binding",
+				Type:        InputTypeBool,
+				Description: "Use the system default route again",
+			},
+			{
+				Name:        "interface",
+				Label:       "Interface",
+				ShortFlag:   'i',
+				Type:        InputTypeText,
+				Description: "Network interface for outgoing connections (e.g. eth1)",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil && b.Outbound != nil {
+						return b.Outbound.Interface
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear")
+				},
+			},
+			{
+				Name:        "source-ip",
+				Label:       "Source IP",
+				Type:        InputTypeText,
+				Description: "Local address for outgoing connections",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil && b.Outbound != nil {
+						return b.Outbound.SourceIP
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear")
+				},
+			},
+		},
+	})
+
+	// Register backend.keepalive action
+	Register(&Action{
+		ID:                ActionBackendKeepalive,
+		Parent:            ActionBackend,
+		Use:               "keepalive",
+		Short:             "Configure keepalive and idle timeouts",
+		Long:              "Tune keepalive probes and idle timeouts of a backend's client connections,\nso long-idle mobile clients are not dropped silently.\n\nFor the SOCKS proxy (built-in engine) TCP keepalive is set on client and\noutgoing connections, and connections idle past --idle-timeout are closed.\nFor SSH backends sshd sends keepalives through the tunnel every --idle,\nset in a drop-in under /etc/ssh/sshd_config.d.",
+		MenuLabel:         "Keepalive",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Backend tag",
+			Required:    true,
+			PickerFunc:  KeepaliveBackendPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "clear",
+				Label:       "Clear keepalive settings",
+				Type:        InputTypeBool,
+				Description: "Use the system defaults again",
+			},
+			{
+				Name:        "idle",
+				Label:       "Idle time before the first probe (e.g. 60s)",
+				Type:        InputTypeText,
+				Description: "Idle time before the first keepalive probe",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil && b.Keepalive != nil {
+						return b.Keepalive.Idle
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear")
+				},
+			},
+			{
+				Name:        "interval",
+				Label:       "Time between probes (e.g. 15s)",
+				Type:        InputTypeText,
+				Description: "Time between keepalive probes",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil && b.Keepalive != nil {
+						return b.Keepalive.Interval
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear") && selectedBackendIs(ctx, config.BackendSOCKS)
+				},
+			},
+			{
+				Name:        "count",
+				Label:       "Unanswered probes before dropping",
+				Type:        InputTypeNumber,
+				Description: "Unanswered probes before the connection is dropped",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil && b.Keepalive != nil && b.Keepalive.Count > 0 {
+						return strconv.Itoa(b.Keepalive.Count)
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear")
+				},
+			},
+			{
+				Name:        "idle-timeout",
+				Label:       "Close connections idle this long (e.g. 2h)",
+				Type:        InputTypeText,
+				Description: "Close connections that moved no data for this long (empty = never)",
+				DefaultFunc: func(ctx *Context) string {
+					if b := selectedBackend(ctx); b != nil && b.Keepalive != nil {
+						return b.Keepalive.IdleTimeout
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear") && selectedBackendIs(ctx, config.BackendSOCKS)
+				},
+			},
+		},
+	})
+
+	// Register backend.plugin action
+	Register(&Action{
+		ID:                ActionBackendPlugin,
+		Parent:            ActionBackend,
+		Use:               "plugin",
+		Short:             "Manage extra Shadowsocks plugin listeners",
+		Long:              "Let the tunnel of a Shadowsocks backend also listen on a TCP port with\nanother SIP003 plugin in front, such as v2ray-plugin or obfs-server, next to\nslipstream. Without flags the listeners are listed.",
+		MenuLabel:         "Plugins",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Backend tag",
+			Required:    true,
+			PickerFunc:  ShadowsocksBackendPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "clear",
+				Label:       "Remove all listeners",
+				Type:        InputTypeBool,
+				Description: "Remove all plugin listeners",
+			},
+			{
+				Name:        "remove",
+				Label:       "Remove port",
+				Type:        InputTypeNumber,
+				Description: "Remove the listener on this port",
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear")
+				},
+			},
+			{
+				Name:        "plugin",
+				Label:       "Plugin",
+				Type:        InputTypeText,
+				Description: "SIP003 server plugin, e.g. v2ray-plugin or obfs-server",
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear") && ctx.GetInt("remove") == 0
+				},
+			},
+			{
+				Name:        "port",
+				Label:       "Port",
+				Type:        InputTypeNumber,
+				Description: "Public TCP port of the listener",
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear") && ctx.GetInt("remove") == 0
+				},
+			},
+			{
+				Name:        "opts",
+				Label:       "Plugin options",
+				Type:        InputTypeText,
+				Description: "SIP003 plugin options, e.g. server;tls;host=example.com",
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("clear") && ctx.GetInt("remove") == 0
+				},
+			},
+		},
+	})
+
 	// Register backend.remove action
 	Register(&Action{
 		ID:                ActionBackendRemove,
@@ -255,6 +590,79 @@ func SocksBackendPicker(ctx *Context) (string, error) {
 	return "", nil
 }
 
+// ShadowsocksBackendPicker provides interactive selection of Shadowsocks backends.
+func ShadowsocksBackendPicker(ctx *Context) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+
+	var options []SelectOption
+	for _, b := range cfg.Backends {
+		if b.Type != config.BackendShadowsocks {
+			continue
+		}
+		options = append(options, SelectOption{
+			Label: fmt.Sprintf("%s (%s)", b.Tag, config.GetBackendTypeDisplayName(b.Type)),
+			Value: b.Tag,
+		})
+	}
+
+	if len(options) == 0 {
+		return "", fmt.Errorf("no Shadowsocks backends configured")
+	}
+
+	ctx.Set("_picker_options", options)
+	return "", nil
+}
+
+// ReconfigurableBackendPicker provides interactive selection of backends that can be reconfigured.
+// The built-in SOCKS backend is excluded; its settings are managed via 'backend auth'.
+func ReconfigurableBackendPicker(ctx *Context) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+
+	var options []SelectOption
+	for _, b := range cfg.Backends {
+		if b.Type == config.BackendSOCKS {
+			continue
+		}
+		typeName := config.GetBackendTypeDisplayName(b.Type)
+		options = append(options, SelectOption{
+			Label: fmt.Sprintf("%s (%s)", b.Tag, typeName),
+			Value: b.Tag,
+		})
+	}
+
+	if len(options) == 0 {
+		return "", fmt.Errorf("no reconfigurable backends configured")
+	}
+
+	ctx.Set("_picker_options", options)
+	return "", nil
+}
+
+// selectedBackend returns the backend referenced by the tag in the context, if any.
+func selectedBackend(ctx *Context) *config.BackendConfig {
+	tag := ctx.GetString("tag")
+	if tag == "" {
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil
+	}
+	return cfg.GetBackendByTag(tag)
+}
+
+// selectedBackendIs reports whether the selected backend has the given type.
+func selectedBackendIs(ctx *Context, t config.BackendType) bool {
+	b := selectedBackend(ctx)
+	return b != nil && b.Type == t
+}
+
 // BackendTypeOptions returns the available backend type options for adding new backends.
 // Note: SOCKS and SSH are built-in backends and cannot be added manually.
 func BackendTypeOptions() []SelectOption {
@@ -269,6 +677,11 @@ func BackendTypeOptions() []SelectOption {
 			Value:       string(config.BackendCustom),
 			Description: "Custom TCP service",
 		},
+		{
+			Label:       "Port Forward",
+			Value:       string(config.BackendPortForward),
+			Description: "Publish a local service (web panel, game server) through the tunnel",
+		},
 	}
 }
 
@@ -276,3 +689,58 @@ func BackendTypeOptions() []SelectOption {
 func SetBackendHandler(actionID string, handler Handler) {
 	SetHandler(actionID, handler)
 }
+
+// OutboundBackendPicker provides interactive selection of backends that
+// support outbound interface binding.
+func OutboundBackendPicker(ctx *Context) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+
+	var options []SelectOption
+	for _, b := range cfg.Backends {
+		if b.Type != config.BackendSOCKS && b.Type != config.BackendShadowsocks {
+			continue
+		}
+		typeName := config.GetBackendTypeDisplayName(b.Type)
+		options = append(options, SelectOption{
+			Label: fmt.Sprintf("%s (%s)", b.Tag, typeName),
+			Value: b.Tag,
+		})
+	}
+
+	if len(options) == 0 {
+		return "", fmt.Errorf("no SOCKS or Shadowsocks backends configured")
+	}
+
+	ctx.Set("_picker_options", options)
+	return "", nil
+}
+
+// KeepaliveBackendPicker lists the backends keepalive settings apply to.
+func KeepaliveBackendPicker(ctx *Context) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+
+	var options []SelectOption
+	for _, b := range cfg.Backends {
+		if b.Type != config.BackendSOCKS && b.Type != config.BackendSSH {
+			continue
+		}
+		typeName := config.GetBackendTypeDisplayName(b.Type)
+		options = append(options, SelectOption{
+			Label: fmt.Sprintf("%s (%s)", b.Tag, typeName),
+			Value: b.Tag,
+		})
+	}
+
+	if len(options) == 0 {
+		return "", fmt.Errorf("no SOCKS or SSH backends configured")
+	}
+
+	ctx.Set("_picker_options", options)
+	return "", nil
+}
@@ -0,0 +1,58 @@
+package actions
+
+func init() {
+	// Register bench action
+	Register(&Action{
+		ID:                ActionBench,
+		Use:               "bench",
+		Short:             "Benchmark latency and throughput through a tunnel",
+		Long:              "Run a standard benchmark through a tunnel and record the result.\n\nThe transport's client is started on this server and pointed at the tunnel,\ndirectly or through --resolver. New connections time the first response\nfrom the backend (SOCKS greeting or SSH banner), and a SOCKS backend also\ncarries a download and an upload to a local sink.\n\nResults are kept per tunnel with the transport, MTU, resolver and note, so\nruns can be compared with --history.",
+		MenuLabel:         "Benchmark",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "resolver",
+				Label:       "Resolver",
+				Type:        InputTypeText,
+				Description: "Resolver to send queries through (default: the tunnel server directly)",
+			},
+			{
+				Name:        "rounds",
+				Label:       "Latency probes",
+				Type:        InputTypeNumber,
+				Default:     "10",
+				Description: "Number of new connections to time",
+			},
+			{
+				Name:        "size",
+				Label:       "Transfer size (KiB)",
+				Type:        InputTypeNumber,
+				Default:     "512",
+				Description: "KiB to download and upload, 0 to skip",
+			},
+			{
+				Name:        "note",
+				Label:       "Note",
+				Type:        InputTypeText,
+				Description: "Label stored with the result, e.g. the client network",
+			},
+			{
+				Name:        "history",
+				Label:       "Show recorded results",
+				Type:        InputTypeBool,
+				Description: "Show recorded results instead of running (all tunnels without -t)",
+			},
+		},
+	})
+}
+
+// SetBenchHandler sets the handler for the bench action.
+func SetBenchHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,87 @@
+package actions
+
+func init() {
+	// Register bootstrap parent action (submenu)
+	Register(&Action{
+		ID:        ActionBootstrap,
+		Use:       "bootstrap",
+		Short:     "Generate unattended provisioning scripts",
+		Long:      "Generate cloud-init user-data or shell scripts that provision a tunnel server on first boot",
+		MenuLabel: "Bootstrap",
+		IsSubmenu: true,
+	})
+
+	// Register bootstrap.generate action
+	Register(&Action{
+		ID:        ActionBootstrapGenerate,
+		Parent:    ActionBootstrap,
+		Use:       "generate",
+		Short:     "Generate a bootstrap script",
+		Long:      "Generate a cloud-init user-data document or shell script that installs dnstm,\napplies a config manifest or install preset, and prints the client configs to\nthe console.\n\nPaste the output into the user-data field of a new server. Client configs\nappear in the provider's serial console log and in /var/log/dnstm-bootstrap.log.\n\nExamples:\n  dnstm bootstrap generate --manifest config.json -o user-data.yaml\n  dnstm bootstrap generate --preset ssh-basic -d example.com --format script",
+		MenuLabel: "Generate",
+		Inputs: []InputField{
+			{
+				Name:        "format",
+				Label:       "Format",
+				Type:        InputTypeSelect,
+				Options:     BootstrapFormatOptions(),
+				Default:     "cloud-init",
+				Description: "Output format: cloud-init or script",
+			},
+			{
+				Name:        "manifest",
+				Label:       "Manifest",
+				ShortFlag:   'c',
+				Type:        InputTypeText,
+				Description: "config.json to apply with 'dnstm config load'",
+			},
+			{
+				Name:        "preset",
+				Label:       "Preset",
+				Type:        InputTypeSelect,
+				Options:     PresetOptions(),
+				Description: "Install preset to apply instead of a manifest",
+			},
+			{
+				Name:        "domain",
+				Label:       "Base Domain",
+				ShortFlag:   'd',
+				Type:        InputTypeText,
+				Description: "Base domain for preset tunnels",
+			},
+			{
+				Name:        "mode",
+				Label:       "Operating Mode",
+				ShortFlag:   'm',
+				Type:        InputTypeSelect,
+				Options:     OperatingModeOptions(),
+				Description: "Operating mode passed to install",
+			},
+			{
+				Name:        "socks-engine",
+				Label:       "SOCKS Engine",
+				Type:        InputTypeSelect,
+				Options:     SocksEngineOptions(),
+				Description: "SOCKS5 proxy implementation passed to install",
+			},
+			{
+				Name:        "version",
+				Label:       "Version",
+				Type:        InputTypeText,
+				Description: "dnstm release to install (default: this build, or latest)",
+			},
+			{
+				Name:        "output",
+				Label:       "Output file",
+				ShortFlag:   'o',
+				Type:        InputTypeText,
+				Description: "Write to a file instead of stdout",
+			},
+		},
+	})
+}
+
+// SetBootstrapHandler sets the handler for a bootstrap action.
+func SetBootstrapHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,43 @@
+package actions
+
+func init() {
+	// Register certs parent action (submenu)
+	Register(&Action{
+		ID:                ActionCerts,
+		Use:               "certs",
+		Short:             "Inspect tunnel certificates and keys",
+		Long:              "Inspect the certificate fingerprints and public keys clients are given.",
+		MenuLabel:         "Certificates",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register certs.history action
+	Register(&Action{
+		ID:                ActionCertsHistory,
+		Parent:            ActionCerts,
+		Use:               "history <domain>",
+		Short:             "Show the fingerprints and keys a domain served",
+		Long:              "Show the certificate fingerprints and public keys a domain served, with when\neach was first and last handed out. dnstm records them whenever it writes or\nshares a client config.\n\nWhen a client reports a fingerprint mismatch, --check tells whether the\nfingerprint it sees is the current one, one from before a rotation, or one\ndnstm never issued for the domain.\n\nExamples:\n  dnstm certs history t.example.com\n  dnstm certs history t.example.com --check AB:CD:...",
+		MenuLabel:         "History",
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "domain",
+			Description: "Tunnel domain",
+			Required:    true,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "check",
+				Label:       "Fingerprint or public key to look up",
+				Type:        InputTypeText,
+				Description: "Fingerprint or public key a client reports",
+			},
+		},
+	})
+}
+
+// SetCertsHandler sets the handler for a certs action.
+func SetCertsHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
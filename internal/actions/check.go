@@ -0,0 +1,47 @@
+package actions
+
+func init() {
+	// Register check parent action (submenu)
+	Register(&Action{
+		ID:        ActionCheck,
+		Use:       "check",
+		Short:     "Check tunnel reachability",
+		Long:      "Check how the tunnels are reached from the outside.",
+		MenuLabel: "Checks",
+		IsSubmenu: true,
+	})
+
+	// Register check.resolvers action
+	Register(&Action{
+		ID:                ActionCheckResolvers,
+		Parent:            ActionCheck,
+		Use:               "resolvers",
+		Short:             "Probe tunnel domains through public resolvers",
+		Long:              "Send a test query for each DNS tunnel domain through a list of public\nresolvers and report which of them reach the tunnel, and how fast, to help\npick the resolver clients should use.\n\nQueries use a random name under the domain, so resolver caches do not hide\na broken path. Resolvers that only serve clients in their own network show\nas refused or timeout from the server and must be checked from a client.",
+		MenuLabel:         "Resolvers",
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Only probe this tunnel",
+		},
+		Inputs: []InputField{
+			{
+				Name:        "resolvers",
+				Label:       "Resolvers",
+				Type:        InputTypeText,
+				Description: "Comma-separated resolver IPs, with optional port (default: built-in list)",
+			},
+			{
+				Name:        "timeout",
+				Label:       "Timeout",
+				Type:        InputTypeNumber,
+				Description: "Seconds to wait for each resolver (default 5)",
+			},
+		},
+	})
+}
+
+// SetCheckHandler sets the handler for a check action.
+func SetCheckHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -6,7 +6,7 @@ func init() {
 		ID:                ActionConfig,
 		Use:               "config",
 		Short:             "Manage configuration",
-		Long:              "Load, export, and validate configuration files",
+		Long:              "Load, export, edit, and validate configuration files, or get and set single settings",
 		MenuLabel:         "Config",
 		IsSubmenu:         true,
 		RequiresInstalled: true,
@@ -50,6 +50,60 @@ func init() {
 		},
 	})
 
+	// Register config.edit action
+	Register(&Action{
+		ID:                ActionConfigEdit,
+		Parent:            ActionConfig,
+		Use:               "edit",
+		Short:             "Edit the configuration in place",
+		Long:              "Open the configuration in $VISUAL or $EDITOR (vi if neither is set). On save it\nis validated like a loaded file, and reopened while it is invalid. The changes\nto tunnels, backends and other sections are listed and, once confirmed,\ndeployed like 'dnstm config load'. When the deploy fails, the previous\nconfiguration is deployed again and the edited file kept.",
+		MenuLabel:         "Edit",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register config.get action
+	Register(&Action{
+		ID:                ActionConfigGet,
+		Parent:            ActionConfig,
+		Use:               "get <path>",
+		Short:             "Print a setting",
+		Long:              "Print the setting at a path of JSON field names, such as route.default or\ntunnels[tag=main].dnstt.mtu. List entries are selected by index or by a field\nvalue. Text is printed as is, objects and lists as JSON; an unset setting\nprints nothing.",
+		MenuLabel:         "Get",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "path",
+			Description: "Setting path, e.g. tunnels[tag=main].dnstt.mtu",
+			Required:    true,
+		},
+		ShowInMenu: func(ctx *Context) bool {
+			// Takes a path, for scripts; CLI only
+			return false
+		},
+	})
+
+	// Register config.set action
+	Register(&Action{
+		ID:                ActionConfigSet,
+		Parent:            ActionConfig,
+		Use:               "set <path> <value>",
+		Short:             "Change a setting",
+		Long:              "Change the setting at a path, as accepted by 'dnstm config get'. The value is\nJSON, or text for text settings; null clears a setting. The changed config is\nvalidated, saved, and the services using the setting are rebuilt or\nrestarted. Tunnels and backends are added and removed with their own\ncommands, and the routing mode and active tunnel are changed with\n'dnstm router mode' and 'dnstm router switch'.",
+		MenuLabel:         "Set",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "path",
+			Description: "Setting path, e.g. tunnels[tag=main].dnstt.mtu",
+			Required:    true,
+		},
+		ShowInMenu: func(ctx *Context) bool {
+			// Takes a path and a value, for scripts; CLI only
+			return false
+		},
+	})
+
 	// Register config.validate action
 	Register(&Action{
 		ID:                ActionConfigValidate,
@@ -66,6 +120,16 @@ func init() {
 			Required:    true,
 		},
 	})
+
+	// Register config.policy action
+	Register(&Action{
+		ID:        ActionConfigPolicy,
+		Parent:    ActionConfig,
+		Use:       "policy",
+		Short:     "Show the admin policy",
+		Long:      "Show the admin policy in /etc/dnstm/policy.json: the transports and backend\ntypes tunnels may use, and how many tunnels there may be. Adding tunnels,\nbackends and loading configs is refused when it breaks the policy.",
+		MenuLabel: "Policy",
+	})
 }
 
 // SetConfigHandler sets the handler for a config action.
@@ -0,0 +1,63 @@
+package actions
+
+func init() {
+	// Register dns parent action (submenu)
+	Register(&Action{
+		ID:                ActionDNS,
+		Use:               "dns",
+		Short:             "DNS setup helpers",
+		Long:              "Help set up the DNS records that point tunnel domains at this server",
+		MenuLabel:         "DNS",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register dns.records action
+	Register(&Action{
+		ID:                ActionDNSRecords,
+		Parent:            ActionDNS,
+		Use:               "records",
+		Short:             "Print the delegation records for a tunnel",
+		Long:              "Print the records to create in the parent zone so a tunnel domain is\ndelegated to this server: an address record for the name server host and\nan NS record for the tunnel domain, optionally a TXT record on the name\nserver host.\n\nFormats:\n  bind        Zone file lines\n  cloudflare  Cloudflare API calls (curl)\n  route53     AWS CLI change batch",
+		MenuLabel:         "Records",
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:    "format",
+				Label:   "Format",
+				Type:    InputTypeSelect,
+				Options: RecordFormatOptions(),
+				Default: "bind",
+			},
+			{
+				Name:        "ns-host",
+				Label:       "Name server host",
+				Type:        InputTypeText,
+				Description: "Host the NS record points to (default: ns.<parent zone>)",
+			},
+			{
+				Name:        "ip",
+				Label:       "Server address",
+				Type:        InputTypeText,
+				Description: "Address of the name server host (default: the tunnel's bind_host or the external IP)",
+			},
+			{
+				Name:        "txt",
+				Label:       "TXT record",
+				Type:        InputTypeText,
+				Description: "Optional TXT record on the name server host, e.g. an owner note",
+			},
+		},
+	})
+}
+
+// SetDNSHandler sets the handler for a dns action.
+func SetDNSHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -3,6 +3,8 @@ package actions
 import (
 	"errors"
 	"fmt"
+
+	"github.com/net2share/dnstm/internal/i18n"
 )
 
 // Common errors for action handling.
@@ -55,14 +57,17 @@ type ActionError struct {
 	Hint string
 	// Err is the underlying error, if any.
 	Err error
+	// Code is the exit status, or ExitOK to derive it from Err.
+	Code ExitCode
 }
 
 // Error implements the error interface.
+// Message and hint are shown in the selected language when translated.
 func (e *ActionError) Error() string {
 	if e.Hint != "" {
-		return fmt.Sprintf("%s\n%s", e.Message, e.Hint)
+		return fmt.Sprintf("%s\n%s", i18n.T(e.Message), i18n.T(e.Hint))
 	}
-	return e.Message
+	return i18n.T(e.Message)
 }
 
 // Unwrap returns the underlying error.
@@ -78,6 +83,17 @@ func NewActionError(message, hint string) *ActionError {
 	}
 }
 
+// WithCode sets the exit status of the error.
+func (e *ActionError) WithCode(code ExitCode) *ActionError {
+	e.Code = code
+	return e
+}
+
+// UsageError creates an error for missing or invalid arguments.
+func UsageError(message, hint string) *ActionError {
+	return NewActionError(message, hint).WithCode(ExitUsage)
+}
+
 // WrapError wraps an error with a message and hint.
 func WrapError(err error, message, hint string) *ActionError {
 	return &ActionError{
@@ -90,7 +106,7 @@ func WrapError(err error, message, hint string) *ActionError {
 // TunnelNotFoundError creates a tunnel not found error.
 func TunnelNotFoundError(tag string) *ActionError {
 	return &ActionError{
-		Message: fmt.Sprintf("tunnel '%s' not found", tag),
+		Message: i18n.Tf("tunnel '%s' not found", tag),
 		Hint:    "Use 'dnstm tunnel list' to see available tunnels",
 		Err:     ErrTunnelNotFound,
 	}
@@ -99,7 +115,7 @@ func TunnelNotFoundError(tag string) *ActionError {
 // TunnelExistsError creates a tunnel already exists error.
 func TunnelExistsError(tag string) *ActionError {
 	return &ActionError{
-		Message: fmt.Sprintf("tunnel '%s' already exists", tag),
+		Message: i18n.Tf("tunnel '%s' already exists", tag),
 		Hint:    "Choose a different tag or remove the existing tunnel",
 		Err:     ErrTunnelExists,
 	}
@@ -108,7 +124,7 @@ func TunnelExistsError(tag string) *ActionError {
 // BackendNotFoundError creates a backend not found error.
 func BackendNotFoundError(tag string) *ActionError {
 	return &ActionError{
-		Message: fmt.Sprintf("backend '%s' not found", tag),
+		Message: i18n.Tf("backend '%s' not found", tag),
 		Hint:    "Use 'dnstm backend list' to see available backends",
 		Err:     ErrBackendNotFound,
 	}
@@ -117,7 +133,7 @@ func BackendNotFoundError(tag string) *ActionError {
 // BackendExistsError creates a backend already exists error.
 func BackendExistsError(tag string) *ActionError {
 	return &ActionError{
-		Message: fmt.Sprintf("backend '%s' already exists", tag),
+		Message: i18n.Tf("backend '%s' already exists", tag),
 		Hint:    "Choose a different tag or remove the existing backend",
 		Err:     ErrBackendExists,
 	}
@@ -126,7 +142,7 @@ func BackendExistsError(tag string) *ActionError {
 // BackendInUseError creates a backend in use error.
 func BackendInUseError(tag string, tunnels []string) *ActionError {
 	return &ActionError{
-		Message: fmt.Sprintf("backend '%s' is in use by tunnels: %v", tag, tunnels),
+		Message: i18n.Tf("backend '%s' is in use by tunnels: %v", tag, tunnels),
 		Hint:    "Remove the tunnels first",
 		Err:     ErrBackendInUse,
 	}
@@ -144,7 +160,7 @@ func NotInitializedError() *ActionError {
 // NotInstalledError creates a transport binaries not installed error.
 func NotInstalledError(missing []string) *ActionError {
 	return &ActionError{
-		Message: fmt.Sprintf("transport binaries not installed. Missing: %v", missing),
+		Message: i18n.Tf("transport binaries not installed. Missing: %v", missing),
 		Hint:    "Run 'dnstm install' first",
 		Err:     ErrNotInstalled,
 	}
@@ -0,0 +1,90 @@
+package actions
+
+import (
+	"errors"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/prompt"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/go-corelib/osdetect"
+)
+
+// ExitCode is the exit status of a failed command. The values are stable
+// so scripts can branch on them; new codes are only ever added.
+type ExitCode int
+
+const (
+	ExitOK             ExitCode = 0
+	ExitFailure        ExitCode = 1  // any error without a more specific code
+	ExitConfigInvalid  ExitCode = 2  // the config cannot be parsed or does not validate
+	ExitNotInstalled   ExitCode = 3  // dnstm or its transports are not installed
+	ExitServiceFailure ExitCode = 4  // a service failed to start, stop or become ready
+	ExitDNSCheckFailed ExitCode = 5  // a tunnel domain is not reachable over DNS
+	ExitNotFound       ExitCode = 6  // no tunnel, backend or other object of that name
+	ExitConflict       ExitCode = 7  // the object exists already or is in use
+	ExitForbidden      ExitCode = 8  // refused by the admin policy, a limit, or for lack of root
+	ExitUsage          ExitCode = 9  // missing or invalid arguments, or a prompt without a terminal
+	ExitCancelled      ExitCode = 10 // the user declined a confirmation
+)
+
+var exitCodeNames = map[ExitCode]string{
+	ExitOK:             "ok",
+	ExitFailure:        "failure",
+	ExitConfigInvalid:  "config_invalid",
+	ExitNotInstalled:   "not_installed",
+	ExitServiceFailure: "service_failure",
+	ExitDNSCheckFailed: "dns_check_failed",
+	ExitNotFound:       "not_found",
+	ExitConflict:       "conflict",
+	ExitForbidden:      "forbidden",
+	ExitUsage:          "usage",
+	ExitCancelled:      "cancelled",
+}
+
+// String returns the name of the code used in JSON output.
+func (c ExitCode) String() string {
+	if name, ok := exitCodeNames[c]; ok {
+		return name
+	}
+	return "failure"
+}
+
+// ExitCodes returns all exit codes in order.
+func ExitCodes() []ExitCode {
+	codes := make([]ExitCode, 0, len(exitCodeNames))
+	for c := ExitOK; int(c) < len(exitCodeNames); c++ {
+		codes = append(codes, c)
+	}
+	return codes
+}
+
+// ExitCodeOf returns the exit status for err: the code of an ActionError
+// that sets one, else the code of the first known error it wraps.
+func ExitCodeOf(err error) ExitCode {
+	if err == nil {
+		return ExitOK
+	}
+	var actionErr *ActionError
+	if errors.As(err, &actionErr) && actionErr.Code != ExitOK {
+		return actionErr.Code
+	}
+	switch {
+	case errors.Is(err, ErrCancelled):
+		return ExitCancelled
+	case errors.Is(err, prompt.ErrNoTerminal):
+		return ExitUsage
+	case errors.Is(err, config.ErrInvalid):
+		return ExitConfigInvalid
+	case errors.Is(err, ErrNotInstalled), errors.Is(err, ErrNotInitialized):
+		return ExitNotInstalled
+	case errors.Is(err, service.ErrFailed):
+		return ExitServiceFailure
+	case errors.Is(err, ErrTunnelNotFound), errors.Is(err, ErrBackendNotFound):
+		return ExitNotFound
+	case errors.Is(err, ErrTunnelExists), errors.Is(err, ErrBackendExists), errors.Is(err, ErrBackendInUse):
+		return ExitConflict
+	case errors.Is(err, config.ErrPolicy), errors.Is(err, config.ErrLimit), errors.Is(err, osdetect.ErrNotRoot):
+		return ExitForbidden
+	}
+	return ExitFailure
+}
@@ -0,0 +1,52 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+func TestExitCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ExitCode
+	}{
+		{"nil", nil, ExitOK},
+		{"plain", errors.New("boom"), ExitFailure},
+		{"cancelled", ErrCancelled, ExitCancelled},
+		{"tunnel not found", TunnelNotFoundError("main"), ExitNotFound},
+		{"backend in use", BackendInUseError("socks", []string{"main"}), ExitConflict},
+		{"not installed", NotInstalledError([]string{"dnstt-server"}), ExitNotInstalled},
+		{"wrapped config", fmt.Errorf("load: %w", config.ErrInvalid), ExitConfigInvalid},
+		{"service", service.MarkFailed(errors.New("unit failed")), ExitServiceFailure},
+		{"policy", fmt.Errorf("%w: transport not allowed", config.ErrPolicy), ExitForbidden},
+		{"usage", UsageError("tag required", ""), ExitUsage},
+		{"explicit code wins", WrapError(ErrTunnelNotFound, "x", "").WithCode(ExitDNSCheckFailed), ExitDNSCheckFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCodeOf(tt.err); got != tt.want {
+				t.Errorf("ExitCodeOf() = %d (%s), want %d (%s)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	codes := ExitCodes()
+	if len(codes) != len(exitCodeNames) {
+		t.Fatalf("ExitCodes() has %d codes, want %d", len(codes), len(exitCodeNames))
+	}
+	for i, c := range codes {
+		if int(c) != i {
+			t.Errorf("codes[%d] = %d", i, c)
+		}
+		if c.String() == "" {
+			t.Errorf("code %d has no name", c)
+		}
+	}
+}
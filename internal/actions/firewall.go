@@ -0,0 +1,92 @@
+package actions
+
+func init() {
+	// Register firewall parent action (submenu)
+	Register(&Action{
+		ID:        ActionFirewall,
+		Use:       "firewall",
+		Short:     "Confirm firewall changes and manage the conntrack bypass",
+		Long:      "Firewall changes dnstm makes from an SSH session are rolled back unless\nconfirmed in time, so a mistake cannot lock the operator out.\n\nThe conntrack bypass exempts tunnel DNS traffic from connection tracking.",
+		MenuLabel: "Firewall",
+		IsSubmenu: true,
+	})
+
+	// Register firewall.status action
+	Register(&Action{
+		ID:           ActionFirewallStatus,
+		Parent:       ActionFirewall,
+		Use:          "status",
+		Short:        "Show pending firewall changes",
+		Long:         "Show whether firewall changes await confirmation and when they are rolled back",
+		MenuLabel:    "Status",
+		RequiresRoot: true,
+	})
+
+	// Register firewall.confirm action
+	Register(&Action{
+		ID:           ActionFirewallConfirm,
+		Parent:       ActionFirewall,
+		Use:          "confirm",
+		Short:        "Keep the firewall changes",
+		Long:         "Keep the pending firewall changes and cancel the scheduled rollback.\n\nRun it from a new SSH session to make sure you can still log in.",
+		MenuLabel:    "Confirm",
+		RequiresRoot: true,
+	})
+
+	// Register firewall.rollback action
+	Register(&Action{
+		ID:           ActionFirewallRollback,
+		Parent:       ActionFirewall,
+		Use:          "rollback",
+		Short:        "Undo the firewall changes",
+		Long:         "Restore the firewall rules saved before the pending changes",
+		MenuLabel:    "Rollback",
+		RequiresRoot: true,
+		Confirm: &ConfirmConfig{
+			Message:   "Restore the previous firewall rules?",
+			DefaultNo: true,
+			ForceFlag: "force",
+		},
+	})
+
+	// Register firewall.notrack action (submenu)
+	Register(&Action{
+		ID:                ActionFirewallNotrack,
+		Parent:            ActionFirewall,
+		Use:               "notrack",
+		Short:             "Manage the conntrack bypass of DNS traffic",
+		Long:              "Exempt tunnel DNS traffic from connection tracking with raw-table NOTRACK\nrules, so a busy tunnel cannot fill the conntrack table and get new\nconnections of the server dropped",
+		MenuLabel:         "Conntrack Bypass",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register firewall.notrack.enable action
+	Register(&Action{
+		ID:                ActionFirewallNotrackEnable,
+		Parent:            ActionFirewallNotrack,
+		Use:               "enable",
+		Short:             "Stop tracking tunnel DNS traffic",
+		Long:              "Add raw-table NOTRACK rules for UDP queries arriving on port 53 and the\nanswers leaving it. In multi mode the traffic between the DNS router and\nthe tunnels on loopback, in the tunnel port range, is exempted as well.\nThe rules follow mode switches and are removed on uninstall.\n\nThe server's own DNS lookups stay tracked. Rules matching conntrack state\nsee untracked packets as UNTRACKED rather than NEW or ESTABLISHED.",
+		MenuLabel:         "Enable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register firewall.notrack.disable action
+	Register(&Action{
+		ID:                ActionFirewallNotrackDisable,
+		Parent:            ActionFirewallNotrack,
+		Use:               "disable",
+		Short:             "Track tunnel DNS traffic again",
+		Long:              "Remove the NOTRACK rules of dnstm",
+		MenuLabel:         "Disable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+}
+
+// SetFirewallHandler sets the handler for a firewall action.
+func SetFirewallHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
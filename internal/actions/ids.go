@@ -3,13 +3,18 @@ package actions
 // Action IDs for type-safe references throughout the codebase.
 const (
 	// Backend actions
-	ActionBackend          = "backend"
-	ActionBackendList      = "backend.list"
-	ActionBackendAvailable = "backend.available"
-	ActionBackendAdd       = "backend.add"
-	ActionBackendRemove    = "backend.remove"
-	ActionBackendStatus    = "backend.status"
-	ActionBackendAuth      = "backend.auth"
+	ActionBackend            = "backend"
+	ActionBackendList        = "backend.list"
+	ActionBackendAvailable   = "backend.available"
+	ActionBackendAdd         = "backend.add"
+	ActionBackendRemove      = "backend.remove"
+	ActionBackendStatus      = "backend.status"
+	ActionBackendAuth        = "backend.auth"
+	ActionBackendReconfigure = "backend.reconfigure"
+	ActionBackendEgress      = "backend.egress"
+	ActionBackendOutbound    = "backend.outbound"
+	ActionBackendKeepalive   = "backend.keepalive"
+	ActionBackendPlugin      = "backend.plugin"
 
 	// Tunnel actions
 	ActionTunnel            = "tunnel"
@@ -22,26 +27,209 @@ const (
 	ActionTunnelStatus      = "tunnel.status"
 	ActionTunnelLogs  = "tunnel.logs"
 	ActionTunnelShare = "tunnel.share"
+	ActionTunnelExports = "tunnel.exports"
+	ActionTunnelWatchdog = "tunnel.watchdog"
+	ActionTunnelHealth = "tunnel.health"
+	ActionTunnelSessions = "tunnel.sessions"
+	ActionTunnelUpgrade = "tunnel.upgrade"
+	ActionTunnelCanary = "tunnel.canary"
+	ActionTunnelSchedule = "tunnel.schedule"
+	ActionTunnelApplySchedule = "tunnel.apply-schedule"
+	ActionTunnelUnquarantine = "tunnel.unquarantine"
+	ActionTunnelPause = "tunnel.pause"
+	ActionTunnelResume = "tunnel.resume"
+	ActionTunnelLabel = "tunnel.label"
+	ActionTunnelDescribe = "tunnel.describe"
+	ActionTunnelExpiry = "tunnel.expiry"
+	ActionTunnelApplyExpiry = "tunnel.apply-expiry"
+	ActionTunnelLogAlerts = "tunnel.log-alerts"
+	ActionTunnelScanLogs = "tunnel.scan-logs"
+	ActionTunnelUsers = "tunnel.users"
+	ActionTunnelUsersList = "tunnel.users.list"
+	ActionTunnelUsersAdd = "tunnel.users.add"
+	ActionTunnelUsersRemove = "tunnel.users.remove"
+	ActionTunnelUsersShare = "tunnel.users.share"
 
 	// Router actions
-	ActionRouter        = "router"
-	ActionRouterStatus  = "router.status"
-	ActionRouterStart   = "router.start"
-	ActionRouterStop    = "router.stop"
-	ActionRouterRestart = "router.restart"
-	ActionRouterLogs    = "router.logs"
-	ActionRouterMode    = "router.mode"
-	ActionRouterSwitch  = "router.switch"
+	ActionRouter          = "router"
+	ActionRouterStatus    = "router.status"
+	ActionRouterStart     = "router.start"
+	ActionRouterStop      = "router.stop"
+	ActionRouterRestart   = "router.restart"
+	ActionRouterLogs      = "router.logs"
+	ActionRouterMode      = "router.mode"
+	ActionRouterSwitch    = "router.switch"
+	ActionRouterReset     = "router.reset"
+	ActionRouterDecoy     = "router.decoy"
+	ActionRouterACMETXT   = "router.acme-txt"
+	ActionRouterStateless = "router.stateless"
+	ActionRouterWatch     = "router.watch"
 
 	// Config actions
 	ActionConfig         = "config"
 	ActionConfigLoad     = "config.load"
 	ActionConfigExport   = "config.export"
 	ActionConfigValidate = "config.validate"
+	ActionConfigPolicy   = "config.policy"
+	ActionConfigEdit     = "config.edit"
+	ActionConfigGet      = "config.get"
+	ActionConfigSet      = "config.set"
+
+	// Bootstrap actions
+	ActionBootstrap         = "bootstrap"
+	ActionBootstrapGenerate = "bootstrap.generate"
+
+	// Auto-update actions
+	ActionAutoUpdate        = "auto-update"
+	ActionAutoUpdateEnable  = "auto-update.enable"
+	ActionAutoUpdateDisable = "auto-update.disable"
+	ActionAutoUpdateStatus  = "auto-update.status"
+	ActionAutoUpdateRun     = "auto-update.run"
+
+	// Ports actions
+	ActionPorts     = "ports"
+	ActionPortsList = "ports.list"
+
+	// Report actions
+	ActionReport        = "report"
+	ActionReportUsage   = "report.usage"
+	ActionReportEnable  = "report.enable"
+	ActionReportDisable = "report.disable"
+	ActionReportQuota   = "report.quota"
+	ActionReportReset   = "report.reset"
+	ActionReportCollect = "report.collect"
+
+	// Zone actions
+	ActionZone       = "zone"
+	ActionZoneList   = "zone.list"
+	ActionZoneAdd    = "zone.add"
+	ActionZoneRemove = "zone.remove"
+
+	// DNS actions
+	ActionDNS        = "dns"
+	ActionDNSRecords = "dns.records"
+
+	// Check actions
+	ActionCheck          = "check"
+	ActionCheckResolvers = "check.resolvers"
+
+	// Bench actions
+	ActionBench = "bench"
+
+	// Logs actions
+	ActionLogs         = "logs"
+	ActionLogsShip     = "logs.ship"
+	ActionLogsSessions = "logs.sessions"
+
+	// Stats actions
+	ActionStats        = "stats"
+	ActionStatsGraph   = "stats.graph"
+	ActionStatsEnable  = "stats.enable"
+	ActionStatsDisable = "stats.disable"
+	ActionStatsCollect = "stats.collect"
+
+	// Privacy actions
+	ActionPrivacy        = "privacy"
+	ActionPrivacyEnable  = "privacy.enable"
+	ActionPrivacyDisable = "privacy.disable"
+
+	// Snapshot actions
+	ActionSnapshot         = "snapshot"
+	ActionSnapshotCreate   = "snapshot.create"
+	ActionSnapshotList     = "snapshot.list"
+	ActionSnapshotRollback = "snapshot.rollback"
+	ActionSnapshotRemove   = "snapshot.remove"
+
+	// Firewall actions
+	ActionFirewall               = "firewall"
+	ActionFirewallStatus         = "firewall.status"
+	ActionFirewallConfirm        = "firewall.confirm"
+	ActionFirewallRollback       = "firewall.rollback"
+	ActionFirewallNotrack        = "firewall.notrack"
+	ActionFirewallNotrackEnable  = "firewall.notrack.enable"
+	ActionFirewallNotrackDisable = "firewall.notrack.disable"
+
+	// Security actions
+	ActionSecurity                = "security"
+	ActionSecurityFail2ban        = "security.fail2ban"
+	ActionSecurityFail2banEnable  = "security.fail2ban.enable"
+	ActionSecurityFail2banDisable = "security.fail2ban.disable"
+	ActionSecurityFail2banStatus  = "security.fail2ban.status"
+
+	// Tune actions
+	ActionTune       = "tune"
+	ActionTuneApply  = "tune.apply"
+	ActionTuneRevert = "tune.revert"
+	ActionTuneStatus = "tune.status"
+
+	// Rescue actions
+	ActionRescue        = "rescue"
+	ActionRescueEnable  = "rescue.enable"
+	ActionRescueDisable = "rescue.disable"
+	ActionRescueStatus  = "rescue.status"
+
+	// API server actions
+	ActionAPI           = "api"
+	ActionAPIEnable     = "api.enable"
+	ActionAPIDisable    = "api.disable"
+	ActionAPIStatus     = "api.status"
+	ActionAPIStatusPage = "api.status-page"
+	ActionAPIWebhook    = "api.webhook"
+	ActionAPIJobs       = "api.jobs"
+	ActionAPIProvision  = "api.provision"
+	ActionAPISpec       = "api.spec"
+	ActionAPIAgents     = "api.agents"
+
+	// Agent actions
+	ActionAgent       = "agent"
+	ActionAgentEnroll = "agent.enroll"
+	ActionAgentStatus = "agent.status"
+	ActionAgentLeave  = "agent.leave"
+	ActionAgentToken  = "agent.token"
+	ActionAgentList   = "agent.list"
+	ActionAgentRevoke = "agent.revoke"
+	ActionAgentExec   = "agent.exec"
+
+	// Replicate actions
+	ActionReplicate       = "replicate"
+	ActionReplicateExport = "replicate.export"
+	ActionReplicateImport = "replicate.import"
+
+	// Import actions
+	ActionImport = "import"
+
+	// Orphans actions
+	ActionOrphans       = "orphans"
+	ActionOrphansScan   = "orphans.scan"
+	ActionOrphansAdopt  = "orphans.adopt"
+	ActionOrphansRemove = "orphans.remove"
+
+	// Verify actions
+	ActionVerify = "verify"
+
+	// Templates actions
+	ActionTemplates       = "templates"
+	ActionTemplatesList   = "templates.list"
+	ActionTemplatesShow   = "templates.show"
+	ActionTemplatesExport = "templates.export"
+
+	// Secret actions
+	ActionSecret    = "secret"
+	ActionSecretGen = "secret.gen"
+
+	// Certs actions
+	ActionCerts        = "certs"
+	ActionCertsHistory = "certs.history"
+
+	// Keys actions
+	ActionKeys       = "keys"
+	ActionKeysExport = "keys.export"
+	ActionKeysImport = "keys.import"
 
 	// System actions
 	ActionInstall   = "install"
 	ActionUninstall = "uninstall"
 	ActionSSHUsers  = "ssh-users"
 	ActionUpdate    = "update"
+	ActionPackage   = "package"
 )
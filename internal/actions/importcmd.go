@@ -0,0 +1,55 @@
+package actions
+
+func init() {
+	// Register import action
+	Register(&Action{
+		ID:                ActionImport,
+		Use:               "import <path>",
+		Short:             "Take over a tunnel server set up without dnstm",
+		Long:              "Read a DNS tunnel server set up by hand or by another tool and add it as a\ndnstm tunnel, keeping its domain, key or certificate so clients keep working.\n\n<path> is the systemd unit running the server (a file, or a unit name such as\n'dnstt'), or an ssserver JSON config with the slipstream-server plugin:\n  dnstt       dnstt-server with -privkey or -privkey-file\n  slipstream  slipstream-server with --cert and --key\n  ssserver    Shadowsocks over Slipstream (ssserver -c config.json)\n\nThe server's upstream becomes the backend with the same address, or a new\ncustom backend. Use --disable-old to stop and disable the old unit; it is\nstarted again if the import fails.",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "path",
+			Description: "systemd unit (file or name) or ssserver JSON config",
+			Required:    true,
+		},
+		Inputs: []InputField{
+			{
+				Name:  "from",
+				Label: "Source",
+				Type:  InputTypeSelect,
+				Options: []SelectOption{
+					{Label: "dnstt-server", Value: "dnstt"},
+					{Label: "slipstream-server", Value: "slipstream"},
+					{Label: "ssserver with slipstream plugin", Value: "ssserver"},
+				},
+				Description: "Kind of server: dnstt, slipstream or ssserver (default: detected)",
+			},
+			{
+				Name:        "tag",
+				Label:       "Tag",
+				ShortFlag:   't',
+				Type:        InputTypeText,
+				Description: "Tag of the new tunnel (default: the unit name)",
+			},
+			{
+				Name:        "disable-old",
+				Label:       "Disable old unit",
+				Type:        InputTypeBool,
+				Description: "Stop and disable the old systemd unit",
+			},
+			{
+				Name:        "dry-run",
+				Label:       "Dry run",
+				Type:        InputTypeBool,
+				Description: "Show what would be imported without changing anything",
+			},
+		},
+	})
+}
+
+// SetImportHandler sets the handler for the import action.
+func SetImportHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
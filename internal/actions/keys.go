@@ -0,0 +1,92 @@
+package actions
+
+func init() {
+	// Register keys parent action (submenu)
+	Register(&Action{
+		ID:        ActionKeys,
+		Use:       "keys",
+		Short:     "Escrow tunnel keys and certificates",
+		Long:      "Export the Curve25519 keys and TLS keypairs of tunnels for safekeeping off\nthe server, and restore them on a replacement so tunnels keep their public\nkeys and fingerprints and existing client configs keep working.",
+		MenuLabel: "Key Escrow",
+		IsSubmenu: true,
+	})
+
+	// Register keys.export action
+	Register(&Action{
+		ID:                ActionKeysExport,
+		Parent:            ActionKeys,
+		Use:               "export",
+		Short:             "Export tunnel keys and certificates",
+		Long:              "Write the private keys and TLS keypairs of tunnels to an archive. Unlike\n'dnstm snapshot' and 'dnstm replicate', the archive holds no configuration,\nonly what cannot be recreated.\n\nWith --encrypt the archive is sealed with a passphrase (scrypt and\nXChaCha20-Poly1305); the passphrase is asked for unless --passphrase is given.\nWithout it the archive holds the private keys in the clear.\n\nExamples:\n  dnstm keys export --encrypt -o /root/dnstm-keys.enc\n  dnstm keys export -t slip1 -o slip1-keys.tar.gz",
+		MenuLabel:         "Export",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag (default: all tunnels)",
+		},
+		Inputs: []InputField{
+			{
+				Name:        "file",
+				Label:       "Output file",
+				ShortFlag:   'o',
+				Type:        InputTypeText,
+				Description: "Archive path (default: dnstm-keys.enc, or dnstm-keys.tar.gz unencrypted)",
+			},
+			{
+				Name:  "encrypt",
+				Label: "Encrypt the archive with a passphrase",
+				Type:  InputTypeBool,
+			},
+			{
+				Name:        "passphrase",
+				Label:       "Passphrase",
+				Type:        InputTypePassword,
+				Description: "Passphrase to encrypt with (implies --encrypt)",
+			},
+		},
+	})
+
+	// Register keys.import action
+	Register(&Action{
+		ID:                ActionKeysImport,
+		Parent:            ActionKeys,
+		Use:               "import <file>",
+		Short:             "Restore tunnel keys and certificates",
+		Long:              "Restore the keys and certificates of an archive written by 'dnstm keys export'\ninto /etc/dnstm/tunnels/<tag>/.\n\nConfigured tunnels with the same tags switch to the restored keys and are\nrebuilt. For the others the keys are staged: adding a tunnel with the same\ntag, transport and domain picks them up.\n\nExamples:\n  dnstm keys import /root/dnstm-keys.enc\n  dnstm keys import dnstm-keys.tar.gz --tag slip1",
+		MenuLabel:         "Import",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "file",
+			Description: "Path to the archive written by 'dnstm keys export'",
+			Required:    true,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "tag",
+				Label:       "Only restore this tunnel",
+				ShortFlag:   't',
+				Type:        InputTypeText,
+				Description: "Tunnel tag (default: all tunnels in the archive)",
+			},
+			{
+				Name:        "passphrase",
+				Label:       "Passphrase",
+				Type:        InputTypePassword,
+				Description: "Passphrase of an encrypted archive",
+			},
+		},
+		Confirm: &ConfirmConfig{
+			Message:     "Restore the keys and certificates of the archive?",
+			Description: "Keys and certificates of tunnels with the same tags will be replaced.",
+			DefaultNo:   true,
+			ForceFlag:   "force",
+		},
+	})
+}
+
+// SetKeysHandler sets the handler for a keys action.
+func SetKeysHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
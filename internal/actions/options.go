@@ -2,6 +2,8 @@ package actions
 
 import (
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/delegation"
+	"github.com/net2share/dnstm/internal/presets"
 )
 
 // EncryptionMethodOptions returns the available Shadowsocks encryption methods.
@@ -23,6 +25,16 @@ func EncryptionMethodOptions() []SelectOption {
 			Value:       "aes-128-gcm",
 			Description: "Lighter encryption",
 		},
+		{
+			Label:       "2022-BLAKE3-AES-256-GCM",
+			Value:       config.ShadowsocksMethod2022AES256,
+			Description: "Shadowsocks 2022; allows several users per tunnel",
+		},
+		{
+			Label:       "2022-BLAKE3-AES-128-GCM",
+			Value:       config.ShadowsocksMethod2022AES128,
+			Description: "Shadowsocks 2022 with lighter encryption; allows several users per tunnel",
+		},
 	}
 }
 
@@ -42,6 +54,195 @@ func OperatingModeOptions() []SelectOption {
 	}
 }
 
+// ProfileOptions returns the available server profiles.
+func ProfileOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "Tiny",
+			Value:       config.ProfileTiny,
+			Description: "256-512 MB servers: one DNS router socket, two tunnels, MTU 1200",
+		},
+		{
+			Label:       "Standard",
+			Value:       config.ProfileStandard,
+			Description: "Default settings",
+		},
+		{
+			Label:       "Performance",
+			Value:       config.ProfilePerformance,
+			Description: "4 GB and 4 CPUs or more: Slipstream recommended",
+		},
+	}
+}
+
+// ACMETXTOperationOptions returns the operations on ACME challenge records.
+func ACMETXTOperationOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "Set",
+			Value:       "set",
+			Description: "Publish a challenge value",
+		},
+		{
+			Label:       "Clear",
+			Value:       "clear",
+			Description: "Remove challenge values",
+		},
+		{
+			Label:       "List",
+			Value:       "list",
+			Description: "Show published challenge values",
+		},
+	}
+}
+
+// PauseReplyOptions returns the answers the DNS router gives for the domain
+// of a paused tunnel.
+func PauseReplyOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "SERVFAIL",
+			Value:       config.PauseServfail,
+			Description: "Resolvers retry and keep the delegation (default)",
+		},
+		{
+			Label:       "REFUSED",
+			Value:       config.PauseRefused,
+			Description: "Resolvers give up on the server sooner",
+		},
+	}
+}
+
+// CryptoRetentionOptions returns the choices for tunnel key material on uninstall.
+func CryptoRetentionOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "Keep",
+			Value:       "keep",
+			Description: "Reinstalled tunnels keep their fingerprints and public keys",
+		},
+		{
+			Label:       "Remove",
+			Value:       "remove",
+			Description: "Clients must be updated after reinstalling",
+		},
+	}
+}
+
+// UninstallScopeOptions returns what an uninstall can remove.
+func UninstallScopeOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "Everything",
+			Value:       "all",
+			Description: "Remove all dnstm components",
+		},
+		{
+			Label:       "Keep microsocks",
+			Value:       "keep-microsocks",
+			Description: "Leave the microsocks SOCKS5 proxy running",
+		},
+		{
+			Label:       "Tunnels only",
+			Value:       "tunnels",
+			Description: "Remove tunnels and their files; keep the rest installed",
+		},
+	}
+}
+
+// SocksEngineOptions returns the available SOCKS proxy engine options.
+func SocksEngineOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "microsocks",
+			Value:       config.ProxyEngineMicrosocks,
+			Description: "Downloaded microsocks binary (default)",
+		},
+		{
+			Label:       "Built-in",
+			Value:       config.ProxyEngineBuiltin,
+			Description: "SOCKS5 server built into dnstm, supports per-IP limits",
+		},
+	}
+}
+
+// PackageFormatOptions returns the available package formats.
+func PackageFormatOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "deb",
+			Value:       "deb",
+			Description: "Debian/Ubuntu package (needs dpkg-deb)",
+			Recommended: true,
+		},
+		{
+			Label:       "rpm",
+			Value:       "rpm",
+			Description: "RHEL/Fedora package (needs rpmbuild)",
+		},
+	}
+}
+
+// ExpiryActionOptions returns what happens to a tunnel when it expires.
+func ExpiryActionOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "Stop",
+			Value:       "stop",
+			Description: "Stop and disable the tunnel; extend its expiry to start it again",
+			Recommended: true,
+		},
+		{
+			Label:       "Remove",
+			Value:       "remove",
+			Description: "Stop it, then remove it with its keys once the grace period has passed",
+		},
+	}
+}
+
+// BootstrapFormatOptions returns the available bootstrap script formats.
+func BootstrapFormatOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "cloud-init",
+			Value:       "cloud-init",
+			Description: "#cloud-config user-data",
+			Recommended: true,
+		},
+		{
+			Label:       "Shell script",
+			Value:       "script",
+			Description: "Plain bash script for other provisioning tools",
+		},
+	}
+}
+
+// PresetOptions returns the available install presets.
+func PresetOptions() []SelectOption {
+	list, err := presets.List()
+	if err != nil {
+		return nil
+	}
+	options := make([]SelectOption, 0, len(list))
+	for _, p := range list {
+		options = append(options, SelectOption{
+			Label:       p.Name,
+			Value:       p.Name,
+			Description: p.Description,
+		})
+	}
+	return options
+}
+
+// ZoneRecordTypeOptions returns the record types a static record may have.
+func ZoneRecordTypeOptions() []SelectOption {
+	var options []SelectOption
+	for _, t := range config.ZoneRecordTypes {
+		options = append(options, SelectOption{Label: t, Value: t})
+	}
+	return options
+}
+
 // GetTransportTypeByValue returns the transport type for a value.
 func GetTransportTypeByValue(value string) config.TransportType {
 	return config.TransportType(value)
@@ -53,5 +254,73 @@ func ValidTransportTypes() []string {
 		string(config.TransportSlipstream),
 		string(config.TransportDNSTT),
 		string(config.TransportVayDNS),
+		string(config.TransportChisel),
+	}
+}
+
+// RecordFormatOptions returns the output formats for delegation records.
+func RecordFormatOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "BIND zone file",
+			Value:       delegation.FormatBind,
+			Description: "Zone file lines, also accepted by most providers' import",
+			Recommended: true,
+		},
+		{
+			Label:       "Cloudflare",
+			Value:       delegation.FormatCloudflare,
+			Description: "Cloudflare API calls (curl)",
+		},
+		{
+			Label:       "Route 53",
+			Value:       delegation.FormatRoute53,
+			Description: "AWS CLI change batch",
+		},
+	}
+}
+
+// SecretFormatOptions returns the formats of generated secrets.
+func SecretFormatOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "Base64",
+			Value:       config.SecretFormatBase64,
+			Description: "Standard base64, may contain '+', '/' and '='",
+		},
+		{
+			Label:       "Base64 URL-safe",
+			Value:       config.SecretFormatBase64URL,
+			Description: "Base64 with '-' and '_', without padding",
+			Recommended: true,
+		},
+		{
+			Label:       "Hex",
+			Value:       config.SecretFormatHex,
+			Description: "Hexadecimal digits",
+		},
+		{
+			Label:       "Characters",
+			Value:       config.SecretFormatChars,
+			Description: "Characters drawn from a charset, letters and digits by default",
+		},
+	}
+}
+
+// SessionsOverLimitOptions returns what a tunnel does with sessions over its
+// limit.
+func SessionsOverLimitOptions() []SelectOption {
+	return []SelectOption{
+		{
+			Label:       "Reject",
+			Value:       config.SessionsReject,
+			Description: "Close new streams at once",
+			Recommended: true,
+		},
+		{
+			Label:       "Queue",
+			Value:       config.SessionsQueue,
+			Description: "Hold new streams until a session ends or the queue timeout passes",
+		},
 	}
 }
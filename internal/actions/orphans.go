@@ -0,0 +1,68 @@
+package actions
+
+func init() {
+	// Register orphans parent action (submenu)
+	Register(&Action{
+		ID:        ActionOrphans,
+		Use:       "orphans",
+		Short:     "Find services and directories of tunnels missing from the config",
+		Long:      "Find tunnel services, schedule timers and tunnel directories left behind for\ntags no tunnel in the config has, e.g. after a crash while adding a tunnel or\na hand-edited config, and adopt them as tunnels again or remove them.",
+		MenuLabel: "Orphans",
+		IsSubmenu: true,
+	})
+
+	// Register orphans.scan action
+	Register(&Action{
+		ID:                ActionOrphansScan,
+		Parent:            ActionOrphans,
+		Use:               "scan",
+		Short:             "List orphaned services and directories",
+		Long:              "List the tunnel services, schedule timers and tunnel directories that belong\nto no tunnel in the config, and whether each can be adopted.",
+		MenuLabel:         "Scan",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register orphans.adopt action
+	Register(&Action{
+		ID:                ActionOrphansAdopt,
+		Parent:            ActionOrphans,
+		Use:               "adopt",
+		Short:             "Add orphaned tunnel services to the config",
+		Long:              "Read an orphaned tunnel service and add it to the config as a tunnel again,\nkeeping its domain, key or certificate and backend. Without a tag, every\nadoptable orphan is adopted.\n\nOnly dnstt and slipstream services can be adopted. A tunnel directory without\na service keeps its key or certificate: 'dnstm tunnel add --tag <tag>' reuses it.",
+		MenuLabel:         "Adopt",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tag of the orphan (default: all)",
+		},
+	})
+
+	// Register orphans.remove action
+	Register(&Action{
+		ID:                ActionOrphansRemove,
+		Parent:            ActionOrphans,
+		Use:               "remove",
+		Short:             "Remove orphaned services and directories",
+		Long:              "Stop and delete orphaned tunnel services and schedule timers, and delete\norphaned tunnel directories with their keys and certificates. Without a tag,\nevery orphan is removed.",
+		MenuLabel:         "Remove",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tag of the orphan (default: all)",
+		},
+		Confirm: &ConfirmConfig{
+			Message:     "Remove orphans?",
+			Description: "Their keys and certificates are deleted; clients of these tunnels stop working.",
+			DefaultNo:   true,
+			ForceFlag:   "force",
+		},
+	})
+}
+
+// SetOrphansHandler sets the handler for an orphans action.
+func SetOrphansHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,30 @@
+package actions
+
+func init() {
+	// Register ports parent action (submenu)
+	Register(&Action{
+		ID:        ActionPorts,
+		Use:       "ports",
+		Short:     "Inspect tunnel port assignments",
+		Long:      "Inspect tunnel port assignments and the port allocation policy",
+		MenuLabel: "Ports",
+		IsSubmenu: true,
+	})
+
+	// Register ports.list action
+	Register(&Action{
+		ID:                ActionPortsList,
+		Parent:            ActionPorts,
+		Use:               "list",
+		Short:             "List port assignments",
+		Long:              "List the ports assigned to tunnels and the SOCKS proxy, and report conflicts:\nports used twice, outside the allocation range, excluded, or held by\nanother process on this host.",
+		MenuLabel:         "List",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+}
+
+// SetPortsHandler sets the handler for a ports action.
+func SetPortsHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,57 @@
+package actions
+
+import "github.com/net2share/dnstm/internal/config"
+
+func init() {
+	// Register privacy parent action (submenu)
+	Register(&Action{
+		ID:        ActionPrivacy,
+		Use:       "privacy",
+		Short:     "Anonymize client IP addresses",
+		Long:      "Anonymize the client IP addresses dnstm records, for operators who must\nnot keep identifying data about tunnel users: the client of session\nevents, the resolver addresses the DNS router counts and logs, and the\naddresses in shipped logs. Metrics hold no addresses.",
+		MenuLabel: "Privacy",
+		IsSubmenu: true,
+	})
+
+	// Register privacy.enable action
+	Register(&Action{
+		ID:                ActionPrivacyEnable,
+		Parent:            ActionPrivacy,
+		Use:               "enable",
+		Short:             "Truncate or hash client IP addresses",
+		Long:              "Anonymize client addresses from now on. 'truncate' keeps the /24 of IPv4\nand the /48 of IPv6 addresses; 'hash' replaces them with a keyed hash, so\nthe records of one address can still be told apart. The DNS router, the\nhealth service and the log shipping service are restarted to apply it.\n\nThe local journal, which fail2ban reads, still holds what the transports\nand sshd log, and records written before are not rewritten.\n\nExamples:\n  dnstm privacy enable\n  dnstm privacy enable --ip hash",
+		MenuLabel:         "Enable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:  "ip",
+				Label: "How addresses are anonymized: truncate or hash",
+				Type:  InputTypeSelect,
+				Options: []SelectOption{
+					{Label: "Truncate", Value: config.PrivacyTruncate, Description: "Keep the /24 (IPv4) or /48 (IPv6)", Recommended: true},
+					{Label: "Hash", Value: config.PrivacyHash, Description: "Replace with a keyed hash"},
+				},
+				Default:     config.PrivacyTruncate,
+				Description: "truncate keeps the network, hash replaces the address with a keyed hash",
+			},
+		},
+	})
+
+	// Register privacy.disable action
+	Register(&Action{
+		ID:                ActionPrivacyDisable,
+		Parent:            ActionPrivacy,
+		Use:               "disable",
+		Short:             "Record client IP addresses as seen",
+		Long:              "Stop anonymizing client addresses. The salt of the hash is dropped, so\nenabling hashing again gives different hashes.",
+		MenuLabel:         "Disable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+}
+
+// SetPrivacyHandler sets the handler for a privacy action.
+func SetPrivacyHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,63 @@
+package actions
+
+func init() {
+	// Register replicate parent action (submenu)
+	Register(&Action{
+		ID:        ActionReplicate,
+		Use:       "replicate",
+		Short:     "Serve the same tunnels from several servers",
+		Long:      "Package this server's tunnels with their keys and certificates so other\nservers can serve the same domains for DNS round robin or anycast, with\nidentical client configs",
+		MenuLabel: "Replicate",
+		IsSubmenu: true,
+	})
+
+	// Register replicate.export action
+	Register(&Action{
+		ID:                ActionReplicateExport,
+		Parent:            ActionReplicate,
+		Use:               "export",
+		Short:             "Export a replica bundle",
+		Long:              "Write the configuration and each tunnel's keys and certificates to a bundle.\n\nSettings bound to this host (listen address, tunnel bind_host, backend\noutbound binding) are left out. The bundle holds private keys: copy it\nover a secure channel and delete it afterwards.",
+		MenuLabel:         "Export",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "file",
+				Label:       "Output file",
+				ShortFlag:   'o',
+				Type:        InputTypeText,
+				Default:     "dnstm-replica.tar.gz",
+				Description: "Bundle path",
+			},
+		},
+	})
+
+	// Register replicate.import action
+	Register(&Action{
+		ID:                ActionReplicateImport,
+		Parent:            ActionReplicate,
+		Use:               "import <file>",
+		Short:             "Import a replica bundle",
+		Long:              "Replace this server's tunnels with the ones in a replica bundle, keeping\ntheir keys and certificates, and start the router.\n\nA snapshot is taken first, so 'dnstm snapshot rollback latest' undoes the import.",
+		MenuLabel:         "Import",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "file",
+			Description: "Path to the bundle written by 'dnstm replicate export'",
+			Required:    true,
+		},
+		Confirm: &ConfirmConfig{
+			Message:     "Replace this server's configuration with the bundle?",
+			Description: "Existing tunnels, keys and certificates will be removed.",
+			DefaultNo:   true,
+			ForceFlag:   "force",
+		},
+	})
+}
+
+// SetReplicateHandler sets the handler for a replicate action.
+func SetReplicateHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,144 @@
+package actions
+
+func init() {
+	// Register report parent action (submenu)
+	Register(&Action{
+		ID:        ActionReport,
+		Use:       "report",
+		Short:     "Usage reports",
+		Long:      "Per-user traffic accounting for SSH tunnel users and SOCKS credentials,\nand per-tunnel totals.\n\nCounters are collected every 5 minutes into a ledger per calendar month,\nwhich can be exported as CSV or JSON for billing. Monthly quotas cut an\naccount off once its traffic reaches the limit.",
+		MenuLabel: "Reports",
+		IsSubmenu: true,
+	})
+
+	// Register report.usage action
+	Register(&Action{
+		ID:                ActionReportUsage,
+		Parent:            ActionReport,
+		Use:               "usage",
+		Short:             "Show per-user usage",
+		Long:              "Show the traffic of each SSH tunnel user and SOCKS credential for a month",
+		MenuLabel:         "Usage",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "month",
+				Label:       "Month",
+				ShortFlag:   'm',
+				Type:        InputTypeText,
+				Description: "Month to report (YYYY-MM, default: current month)",
+			},
+			{
+				Name:        "format",
+				Label:       "Format",
+				Type:        InputTypeSelect,
+				Default:     "table",
+				Description: "Output format",
+				Options: []SelectOption{
+					{Label: "Table", Value: "table"},
+					{Label: "CSV", Value: "csv"},
+					{Label: "JSON", Value: "json"},
+				},
+				ShowIf: func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:        "output",
+				Label:       "Output File",
+				ShortFlag:   'o',
+				Type:        InputTypeText,
+				Description: "Write the report to a file instead of stdout",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+		},
+	})
+
+	// Register report.enable action
+	Register(&Action{
+		ID:                ActionReportEnable,
+		Parent:            ActionReport,
+		Use:               "enable",
+		Short:             "Enable usage accounting",
+		Long:              "Install the nftables counters and a systemd timer that collects them every 5 minutes",
+		MenuLabel:         "Enable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register report.disable action
+	Register(&Action{
+		ID:           ActionReportDisable,
+		Parent:       ActionReport,
+		Use:          "disable",
+		Short:        "Disable usage accounting",
+		Long:         "Collect the counters a last time, then remove them and the collector timer.\nRecorded ledgers are kept.",
+		MenuLabel:    "Disable",
+		RequiresRoot: true,
+	})
+
+	// Register report.quota action
+	Register(&Action{
+		ID:                ActionReportQuota,
+		Parent:            ActionReport,
+		Use:               "quota <account>",
+		Short:             "Set a monthly traffic quota",
+		Long:              "Set a monthly traffic quota for ssh:<user>, socks:<user> or tunnel:<tag>.\n\nWhen the account's traffic this month reaches the limit, SSH users are\nexpired and their sessions ended, SOCKS passwords are replaced, and tunnels\nare stopped. Accounts are restored at the start of the next month or with\n'dnstm report reset'.",
+		MenuLabel:         "Quota",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "account",
+			Description: "Account (ssh:<user>, socks:<user> or tunnel:<tag>)",
+			Required:    true,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "limit",
+				Label:       "Monthly Limit",
+				ShortFlag:   'l',
+				Type:        InputTypeText,
+				Description: "Traffic allowed per month (e.g. 500M, 50G)",
+				ShowIf:      func(ctx *Context) bool { return !ctx.GetBool("disable") },
+			},
+			{
+				Name:        "disable",
+				Label:       "Disable",
+				Type:        InputTypeBool,
+				Description: "Remove the quota",
+			},
+		},
+	})
+
+	// Register report.reset action
+	Register(&Action{
+		ID:                ActionReportReset,
+		Parent:            ActionReport,
+		Use:               "reset <account>",
+		Short:             "Reset a quota and restore the account",
+		Long:              "Restore an account that was cut off. Traffic recorded so far this month\nno longer counts against its quota.",
+		MenuLabel:         "Reset Quota",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "account",
+			Description: "Account (ssh:<user>, socks:<user> or tunnel:<tag>)",
+			Required:    true,
+		},
+	})
+
+	// Register report.collect action (invoked by the timer)
+	Register(&Action{
+		ID:           ActionReportCollect,
+		Parent:       ActionReport,
+		Use:          "collect",
+		Short:        "Collect usage counters now",
+		Long:         "Add the current counter values to this month's ledger, enforce quotas, and reload the counters for new users",
+		Hidden:       true,
+		RequiresRoot: true,
+	})
+}
+
+// SetReportHandler sets the handler for a report action.
+func SetReportHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,94 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	// Register rescue parent action (submenu)
+	Register(&Action{
+		ID:                ActionRescue,
+		Use:               "rescue",
+		Short:             "Manage the emergency access tunnel",
+		Long:              "Manage the rescue tunnel: a DNSTT tunnel to the local SSH daemon on a\nreserved domain, so the server stays reachable over DNS when a firewall\nmistake locks out SSH",
+		MenuLabel:         "Rescue",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register rescue.enable action
+	Register(&Action{
+		ID:                ActionRescueEnable,
+		Parent:            ActionRescue,
+		Use:               "enable",
+		Short:             "Create the rescue tunnel",
+		Long:              "Create the rescue tunnel on a domain of its own and start it.\n\nThe tunnel runs under the watchdog and is never quarantined, so systemd\nkeeps restarting it; 'dnstm router start' recreates its service if the\nunit goes missing. In single mode it needs a second public IP (--bind-host),\nsince the active tunnel holds port 53 on the main one.",
+		MenuLabel:         "Enable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "domain",
+				Label:       "Domain",
+				ShortFlag:   'd',
+				Type:        InputTypeText,
+				Required:    true,
+				Description: "Reserved domain delegated to this server, used only for rescue access",
+			},
+			{
+				Name:        "bind-host",
+				Label:       "Bind address",
+				Type:        InputTypeText,
+				Description: "Single mode: second public IP to serve the rescue tunnel on",
+				ShowIf: func(ctx *Context) bool {
+					cfg, err := config.Load()
+					return err == nil && cfg.IsSingleMode()
+				},
+			},
+			{
+				Name:        "timeout",
+				Label:       "Watchdog timeout",
+				Type:        InputTypeText,
+				Default:     config.DefaultWatchdogTimeout.String(),
+				Description: fmt.Sprintf("Restart the tunnel after this long without an answer (min %s)", config.MinWatchdogTimeout),
+			},
+		},
+	})
+
+	// Register rescue.disable action
+	Register(&Action{
+		ID:                ActionRescueDisable,
+		Parent:            ActionRescue,
+		Use:               "disable",
+		Short:             "Remove the rescue tunnel",
+		Long:              "Stop and remove the rescue tunnel with its keys",
+		MenuLabel:         "Disable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Confirm: &ConfirmConfig{
+			Message:     "Remove the rescue tunnel?",
+			Description: "Emergency access over DNS will no longer be available.",
+			DefaultNo:   true,
+			ForceFlag:   "force",
+		},
+	})
+
+	// Register rescue.status action
+	Register(&Action{
+		ID:                ActionRescueStatus,
+		Parent:            ActionRescue,
+		Use:               "status",
+		Short:             "Show rescue tunnel status",
+		Long:              "Show the rescue tunnel's domain, state and public key",
+		MenuLabel:         "Status",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+}
+
+// SetRescueHandler sets the handler for a rescue action.
+func SetRescueHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
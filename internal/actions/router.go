@@ -1,5 +1,7 @@
 package actions
 
+import "github.com/net2share/dnstm/internal/config"
+
 func init() {
 	// Register router parent action (submenu)
 	Register(&Action{
@@ -34,6 +36,9 @@ func init() {
 		MenuLabel:         "Start/Restart",
 		RequiresRoot:      true,
 		RequiresInstalled: true,
+		Inputs: []InputField{
+			port53ForceInput(),
+		},
 	})
 
 	// Register router.stop action
@@ -54,7 +59,7 @@ func init() {
 		Parent:            ActionRouter,
 		Use:               "restart",
 		Short:             "Restart the router",
-		Long:              "Restart all tunnels based on current mode.",
+		Long:              "Restart all tunnels based on current mode. In multi mode the DNS router\nkeeps its port 53 sockets across the restart, so no query is refused.",
 		MenuLabel:         "Restart",
 		RequiresRoot:      true,
 		RequiresInstalled: true,
@@ -81,6 +86,22 @@ func init() {
 		},
 	})
 
+	// Register router.watch action
+	Register(&Action{
+		ID:                ActionRouterWatch,
+		Parent:            ActionRouter,
+		Use:               "watch",
+		Short:             "Follow service state changes",
+		Long:              "Print state changes of the DNS router, tunnel and proxy services as they happen, until interrupted.\n\nChanges are pushed by systemd over D-Bus; without a system bus, states are polled every 2 seconds.",
+		MenuLabel:         "Watch",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		ShowInMenu: func(ctx *Context) bool {
+			// Runs until interrupted, CLI only
+			return false
+		},
+	})
+
 	// Register router.mode action
 	Register(&Action{
 		ID:                ActionRouterMode,
@@ -100,6 +121,8 @@ func init() {
 				Options:         OperatingModeOptions(),
 				InteractiveOnly: true,
 			},
+			ignoreLimitsInput(),
+			port53ForceInput(),
 		},
 	})
 
@@ -119,11 +142,148 @@ func init() {
 			Required:    false,
 			PickerFunc:  TunnelPicker,
 		},
+		Inputs: []InputField{
+			port53ForceInput(),
+		},
 		ShowInMenu: func(ctx *Context) bool {
 			// Only show in single mode
 			return ctx.Config != nil && ctx.Config.IsSingleMode()
 		},
 	})
+
+	// Register router.reset action
+	Register(&Action{
+		ID:                ActionRouterReset,
+		Parent:            ActionRouter,
+		Use:               "reset",
+		Short:             "Remove all tunnels and reset routing",
+		Long:              "Remove every tunnel, its service and files, stop the DNS router, clear the\nport 53 NAT redirects, and reset routing to single mode. Backends are kept.\n\nA snapshot is taken first. Use --dry-run to list what would be removed, and\n--keep-certs/--keep-keys to preserve key material already distributed to clients.",
+		MenuLabel:         "Reset",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "dry-run",
+				Label:       "Dry run",
+				Type:        InputTypeBool,
+				Description: "Show what would be removed without changing anything",
+			},
+			{
+				Name:        "keep-certs",
+				Label:       "Keep certificates",
+				Type:        InputTypeBool,
+				Description: "Preserve Slipstream certificates (cert.pem, key.pem)",
+			},
+			{
+				Name:        "keep-keys",
+				Label:       "Keep keys",
+				Type:        InputTypeBool,
+				Description: "Preserve DNSTT/VayDNS key pairs (server.key, server.pub)",
+			},
+		},
+		Confirm: &ConfirmConfig{
+			Message:     "Remove all tunnels and reset routing?",
+			Description: "Every tunnel is removed with its service and files; clients of these tunnels stop working. A dry run lists them first.",
+			DefaultNo:   true,
+			ForceFlag:   "force",
+			DryRunFlag:  "dry-run",
+		},
+	})
+
+	// Register router.decoy action
+	Register(&Action{
+		ID:                ActionRouterDecoy,
+		Parent:            ActionRouter,
+		Use:               "decoy",
+		Short:             "Answer non-tunnel queries from a zone file",
+		Long:              "Make the DNS router answer queries for names in a zone file (the tunnel\ndomain apex, www and so on) like an ordinary authoritative server, instead\nof leaving them unanswered. Tunnel traffic is forwarded as before.\n\nWithout an existing zone file, a starter zone with SOA, NS and A records for\nevery DNS tunnel domain is written. Only the DNS router in multi mode serves it.",
+		MenuLabel:         "Decoy Zone",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable decoy",
+				Type:        InputTypeBool,
+				Description: "Stop answering from the zone file",
+			},
+			{
+				Name:        "zone",
+				Label:       "Zone file",
+				Type:        InputTypeText,
+				Description: "Zone file in master file format",
+				DefaultFunc: func(ctx *Context) string {
+					if ctx.Config != nil && ctx.Config.Route.Decoy != nil {
+						return ctx.Config.Route.Decoy.ZoneFile
+					}
+					return config.DefaultDecoyZone
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+		},
+	})
+
+	// Register router.stateless action
+	Register(&Action{
+		ID:                ActionRouterStateless,
+		Parent:            ActionRouter,
+		Use:               "stateless",
+		Short:             "Route by query name alone, for ECMP or anycast",
+		Long:              "Make the DNS router pick the backend of each query from its name alone, so\nseveral servers sharing an address behind BGP ECMP or anycast route every\nname to the same tunnel, whichever of them the network hands a packet to.\nGive each server the same tunnels, domains and keys.\n\nCanary routing splits clients by network and is refused while stateless;\npause canaries with 'dnstm tunnel canary --percent 0' first. Only the DNS\nrouter in multi mode routes this way.",
+		MenuLabel:         "Stateless Routing",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable stateless routing",
+				Type:        InputTypeBool,
+				Description: "Route canaries by client network again",
+			},
+		},
+	})
+
+	// Register router.acme-txt action
+	Register(&Action{
+		ID:                ActionRouterACMETXT,
+		Parent:            ActionRouter,
+		Use:               "acme-txt [set|clear|list]",
+		Short:             "Publish ACME DNS-01 challenge records",
+		Long:              "Publish TXT records for ACME DNS-01 challenges through the running DNS\nrouter, so certificates for a tunnel domain can be issued without another\nDNS provider. Values are served at _acme-challenge.<domain> with a short\nTTL until cleared, or for at most 24 hours.\n\nSuitable as certbot --manual-auth-hook and --manual-cleanup-hook. Without\narguments, lists the published values. Only the DNS router in multi mode\nserves them.",
+		MenuLabel:         "ACME Challenges",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:            "operation",
+				Label:           "Operation",
+				Type:            InputTypeSelect,
+				Required:        true,
+				Options:         ACMETXTOperationOptions(),
+				InteractiveOnly: true,
+			},
+			{
+				Name:        "domain",
+				Label:       "Domain",
+				Type:        InputTypeText,
+				Description: "Domain being validated, e.g. t.example.com",
+				ShowIf: func(ctx *Context) bool {
+					return ctx.GetString("operation") != "list"
+				},
+			},
+			{
+				Name:        "value",
+				Label:       "Value",
+				Type:        InputTypeText,
+				Description: "Challenge value (clear removes all values if empty)",
+				ShowIf: func(ctx *Context) bool {
+					return ctx.GetString("operation") != "list"
+				},
+			},
+		},
+	})
 }
 
 // SetRouterHandler sets the handler for a router action.
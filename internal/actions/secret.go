@@ -0,0 +1,49 @@
+package actions
+
+func init() {
+	// Register secret parent action (submenu)
+	Register(&Action{
+		ID:        ActionSecret,
+		Use:       "secret",
+		Short:     "Generate passwords and secrets",
+		Long:      "Generate random passwords and secrets. Backend passwords dnstm generates\nitself follow the secrets section of the config.",
+		MenuLabel: "Secrets",
+		IsSubmenu: true,
+	})
+
+	// Register secret.gen action
+	Register(&Action{
+		ID:        ActionSecretGen,
+		Parent:    ActionSecret,
+		Use:       "gen",
+		Short:     "Generate a random secret",
+		Long:      "Print a random secret. Without options it follows the secrets section of the\nconfig, as backend passwords do; the options override it.\n\nLength counts random bytes for base64, base64url and hex, and characters for\nchars.\n\nExamples:\n  dnstm secret gen\n  dnstm secret gen --format base64url\n  dnstm secret gen --format chars --length 24 --charset abcdefghjkmnpqrstuvwxyz23456789",
+		MenuLabel: "Generate",
+		Inputs: []InputField{
+			{
+				Name:        "format",
+				Label:       "Format",
+				Type:        InputTypeSelect,
+				Options:     SecretFormatOptions(),
+				Description: "base64, base64url, hex or chars (default: from the config, or base64)",
+			},
+			{
+				Name:        "length",
+				Label:       "Length",
+				Type:        InputTypeNumber,
+				Description: "Random bytes, or characters with chars (default: from the config, or 32)",
+			},
+			{
+				Name:        "charset",
+				Label:       "Charset",
+				Type:        InputTypeText,
+				Description: "Characters of the chars format (default: letters and digits)",
+			},
+		},
+	})
+}
+
+// SetSecretHandler sets the handler for a secret action.
+func SetSecretHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,67 @@
+package actions
+
+func init() {
+	// Register security parent action (submenu)
+	Register(&Action{
+		ID:                ActionSecurity,
+		Use:               "security",
+		Short:             "Harden the services dnstm exposes",
+		Long:              "Manage protection of the services dnstm exposes",
+		MenuLabel:         "Security",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register security.fail2ban action (submenu)
+	Register(&Action{
+		ID:                ActionSecurityFail2ban,
+		Parent:            ActionSecurity,
+		Use:               "fail2ban",
+		Short:             "Manage fail2ban jails",
+		Long:              "Manage the fail2ban jails dnstm generates in /etc/fail2ban/jail.d/dnstm.conf",
+		MenuLabel:         "fail2ban",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register security.fail2ban.enable action
+	Register(&Action{
+		ID:                ActionSecurityFail2banEnable,
+		Parent:            ActionSecurityFail2ban,
+		Use:               "enable",
+		Short:             "Generate the fail2ban jails",
+		Long:              "Generate the dnstm fail2ban jails and reload fail2ban.\n\nThe dnstm-sshd jail bans addresses that keep failing to log in to sshd on\nthe SSH backend ports. Loopback and the server's own IPs are never banned,\nsince tunnel users reach the backends through the tunnel servers on\nloopback. Run it again after changing SSH backends.",
+		MenuLabel:         "Enable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register security.fail2ban.disable action
+	Register(&Action{
+		ID:                ActionSecurityFail2banDisable,
+		Parent:            ActionSecurityFail2ban,
+		Use:               "disable",
+		Short:             "Remove the fail2ban jails",
+		Long:              "Remove the dnstm fail2ban jails and lift their bans",
+		MenuLabel:         "Disable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register security.fail2ban.status action
+	Register(&Action{
+		ID:                ActionSecurityFail2banStatus,
+		Parent:            ActionSecurityFail2ban,
+		Use:               "status",
+		Short:             "Show fail2ban jail status",
+		Long:              "Show failures and bans of the dnstm fail2ban jails",
+		MenuLabel:         "Status",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+}
+
+// SetSecurityHandler sets the handler for a security action.
+func SetSecurityHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,125 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/snapshot"
+)
+
+func init() {
+	// Register snapshot parent action (submenu)
+	Register(&Action{
+		ID:        ActionSnapshot,
+		Use:       "snapshot",
+		Short:     "Manage state snapshots",
+		Long:      "Capture and restore the full dnstm state (config, certificates, keys, services, NAT rules)",
+		MenuLabel: "Snapshots",
+		IsSubmenu: true,
+	})
+
+	// Register snapshot.create action
+	Register(&Action{
+		ID:                ActionSnapshotCreate,
+		Parent:            ActionSnapshot,
+		Use:               "create",
+		Short:             "Create a snapshot",
+		Long:              "Capture the current configuration, unit files, and firewall rules",
+		MenuLabel:         "Create",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "note",
+				Label:       "Note",
+				ShortFlag:   'n',
+				Type:        InputTypeText,
+				Description: "Optional note stored with the snapshot",
+			},
+		},
+	})
+
+	// Register snapshot.list action
+	Register(&Action{
+		ID:           ActionSnapshotList,
+		Parent:       ActionSnapshot,
+		Use:          "list",
+		Short:        "List snapshots",
+		Long:         "List all stored snapshots",
+		MenuLabel:    "List",
+		RequiresRoot: true,
+	})
+
+	// Register snapshot.rollback action
+	Register(&Action{
+		ID:           ActionSnapshotRollback,
+		Parent:       ActionSnapshot,
+		Use:          "rollback <id>",
+		Short:        "Restore a snapshot",
+		Long:         "Restore the configuration, services, and NAT rules captured in a snapshot.\n\nUse \"latest\" to restore the most recent snapshot. Services created after\nthe snapshot are stopped and removed.",
+		MenuLabel:    "Rollback",
+		RequiresRoot: true,
+		Args: &ArgsSpec{
+			Name:        "id",
+			Description: "Snapshot ID (or \"latest\")",
+			Required:    true,
+			PickerFunc:  SnapshotPicker,
+		},
+		Confirm: &ConfirmConfig{
+			Message:     "Restore snapshot?",
+			Description: "The current configuration and services will be replaced.",
+			DefaultNo:   true,
+			ForceFlag:   "force",
+		},
+	})
+
+	// Register snapshot.remove action
+	Register(&Action{
+		ID:           ActionSnapshotRemove,
+		Parent:       ActionSnapshot,
+		Use:          "remove <id>",
+		Short:        "Remove a snapshot",
+		Long:         "Delete a stored snapshot",
+		MenuLabel:    "Remove",
+		RequiresRoot: true,
+		Args: &ArgsSpec{
+			Name:        "id",
+			Description: "Snapshot ID",
+			Required:    true,
+			PickerFunc:  SnapshotPicker,
+		},
+		Confirm: &ConfirmConfig{
+			Message:   "Remove snapshot?",
+			DefaultNo: true,
+			ForceFlag: "force",
+		},
+	})
+}
+
+// SnapshotPicker provides interactive snapshot selection.
+func SnapshotPicker(ctx *Context) (string, error) {
+	manifests, err := snapshot.List()
+	if err != nil {
+		return "", err
+	}
+	if len(manifests) == 0 {
+		return "", fmt.Errorf("no snapshots found")
+	}
+
+	var options []SelectOption
+	for i := len(manifests) - 1; i >= 0; i-- {
+		m := manifests[i]
+		label := m.ID
+		if m.Reason != "" {
+			label = fmt.Sprintf("%s (%s)", m.ID, m.Reason)
+		}
+		options = append(options, SelectOption{Label: label, Value: m.ID})
+	}
+
+	ctx.Set("_picker_options", options)
+	return "", nil
+}
+
+// SetSnapshotHandler sets the handler for a snapshot action.
+func SetSnapshotHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
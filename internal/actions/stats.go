@@ -0,0 +1,107 @@
+package actions
+
+func init() {
+	// Register stats parent action (submenu)
+	Register(&Action{
+		ID:        ActionStats,
+		Use:       "stats",
+		Short:     "Recorded metrics",
+		Long:      "Metrics recorded on this server, without an external monitoring system.\n\nEvery minute the metrics timer records whether each tunnel and the DNS\nrouter are up, the queries the router received and forwarded to each\ntunnel, and, with usage accounting on, the traffic of each tunnel. Samples\nare kept for the retention period (30 days by default).",
+		MenuLabel: "Stats",
+		IsSubmenu: true,
+	})
+
+	// Register stats.graph action
+	Register(&Action{
+		ID:                ActionStatsGraph,
+		Parent:            ActionStats,
+		Use:               "graph",
+		Short:             "Show recorded metrics as sparklines",
+		Long:              "Draw a sparkline per series over a time range. Queries and traffic are\nshown as rates, up/down as the share of time a service was running.\n\nExamples:\n  dnstm stats graph\n  dnstm stats graph -t slip1 --since 7d\n  dnstm stats graph --series queries --since 1h --width 60",
+		MenuLabel:         "Graph",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Only show the series of this tunnel",
+		},
+		Inputs: []InputField{
+			{
+				Name:        "since",
+				Label:       "Time range, e.g. 1h or 7d",
+				Type:        InputTypeText,
+				Default:     "24h",
+				Description: "How far back to show, e.g. 1h, 24h or 7d",
+			},
+			{
+				Name:        "series",
+				Label:       "Series or kinds to show (up, queries, bytes)",
+				Type:        InputTypeText,
+				Description: "Comma-separated series or kinds to show (up, queries, bytes)",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:        "width",
+				Label:       "Characters per sparkline",
+				Type:        InputTypeNumber,
+				Default:     "60",
+				Description: "Characters per sparkline",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+		},
+	})
+
+	// Register stats.enable action
+	Register(&Action{
+		ID:                ActionStatsEnable,
+		Parent:            ActionStats,
+		Use:               "enable",
+		Short:             "Record metrics every minute",
+		Long:              "Install the metrics timer, which records a sample every minute, and set\nhow long samples are kept.",
+		MenuLabel:         "Enable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "retention",
+				Label:       "How long samples are kept (default: 30d)",
+				Type:        InputTypeText,
+				Description: "How long samples are kept, e.g. 7d or 90d (default: 30d)",
+				DefaultFunc: func(ctx *Context) string {
+					if ctx.Config != nil && ctx.Config.Metrics != nil {
+						return ctx.Config.Metrics.Retention
+					}
+					return ""
+				},
+			},
+		},
+	})
+
+	// Register stats.disable action
+	Register(&Action{
+		ID:                ActionStatsDisable,
+		Parent:            ActionStats,
+		Use:               "disable",
+		Short:             "Stop recording metrics",
+		Long:              "Remove the metrics timer. Recorded samples are kept.",
+		MenuLabel:         "Disable",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register stats.collect action (invoked by the timer)
+	Register(&Action{
+		ID:           ActionStatsCollect,
+		Parent:       ActionStats,
+		Use:          "collect",
+		Short:        "Record a metrics sample now",
+		Long:         "Record the current value of every series and delete samples past the retention",
+		Hidden:       true,
+		RequiresRoot: true,
+	})
+}
+
+// SetStatsHandler sets the handler for a stats action.
+func SetStatsHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
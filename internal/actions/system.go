@@ -5,15 +5,54 @@ func init() {
 	Register(&Action{
 		ID:           ActionUninstall,
 		Use:          "uninstall",
-		Short:        "Completely uninstall dnstm",
-		Long:         "Remove all dnstm components from the system.\n\nThis will:\n  - Stop and remove all instance services\n  - Stop and remove DNS router service\n  - Stop and remove microsocks service\n  - Remove all configuration in /etc/dnstm\n  - Remove dnstm user\n  - Remove transport binaries (dnstt-server, slipstream-server, ssserver, microsocks)\n  - Remove firewall rules\n\nNote: The dnstm binary itself is kept for easy reinstallation.",
+		Short:        "Uninstall dnstm",
+		Long:         "Remove all dnstm components from the system.\n\nThis will:\n  - Stop and remove all instance services\n  - Stop and remove DNS router service\n  - Stop and remove microsocks service\n  - Remove all configuration in /etc/dnstm\n  - Remove dnstm user\n  - Remove transport binaries (dnstt-server, slipstream-server, ssserver, microsocks)\n  - Remove firewall rules\n\nUse --keep-crypto to keep tunnel certificates and keys in /etc/dnstm/tunnels,\nso reinstalled tunnels with the same tags keep their fingerprints and public keys.\n\nTo remove dnstm selectively:\n  --keep-microsocks  Leave the microsocks service and binary in place\n  --only-tunnels     Remove only the tunnels, their services and files\n\nUse --dry-run to list what would be removed without changing anything.\n\nNote: The dnstm binary itself is kept for easy reinstallation.",
 		MenuLabel:    "Uninstall",
 		RequiresRoot: true,
+		Inputs: []InputField{
+			{
+				Name:        "keep-crypto",
+				Type:        InputTypeBool,
+				Description: "Keep tunnel certificates and keys",
+			},
+			{
+				Name:        "keep-microsocks",
+				Type:        InputTypeBool,
+				Description: "Keep the microsocks SOCKS5 proxy",
+			},
+			{
+				Name:        "only-tunnels",
+				Type:        InputTypeBool,
+				Description: "Remove only the tunnels",
+			},
+			{
+				Name:        "dry-run",
+				Type:        InputTypeBool,
+				Description: "Show what would be removed without changing anything",
+			},
+			{
+				Name:            "scope",
+				Label:           "Remove",
+				Type:            InputTypeSelect,
+				Options:         UninstallScopeOptions(),
+				Default:         "all",
+				InteractiveOnly: true,
+			},
+			{
+				Name:            "crypto",
+				Label:           "Certificates and Keys",
+				Type:            InputTypeSelect,
+				Options:         CryptoRetentionOptions(),
+				Default:         "keep",
+				InteractiveOnly: true,
+			},
+		},
 		Confirm: &ConfirmConfig{
-			Message:     "Are you sure you want to uninstall everything?",
-			Description: "This will remove all dnstm components from your system.",
+			Message:     "Are you sure you want to uninstall dnstm?",
+			Description: "This will remove the selected dnstm components from your system.",
 			DefaultNo:   true,
 			ForceFlag:   "force",
+			DryRunFlag:  "dry-run",
 		},
 	})
 
@@ -22,13 +61,13 @@ func init() {
 		ID:           ActionInstall,
 		Use:          "install",
 		Short:        "Install transport binaries and configure system",
-		Long:         "Install all transport binaries and configure the system for DNS tunneling.\n\nThis will:\n  - Create dnstm system user\n  - Initialize router configuration and directories\n  - Set operating mode (defaults to single)\n  - Create DNS router service\n  - Download and install transport binaries\n  - Configure firewall rules (port 53 UDP/TCP)\n\nOptionally use --mode to set the operating mode:\n  single  Single-tunnel mode (default) - one tunnel at a time\n  multi   Multi-tunnel mode - multiple tunnels with DNS router",
+		Long:         "Install all transport binaries and configure the system for DNS tunneling.\n\nThis will:\n  - Create dnstm system user\n  - Initialize router configuration and directories\n  - Set operating mode (defaults to single)\n  - Create DNS router service\n  - Download and install transport binaries\n  - Configure firewall rules (port 53 UDP/TCP)\n\nInstall can be re-run safely: it only installs or repairs what is missing\nand ends with a summary of the changes. The existing mode and SOCKS engine\nare kept; a different --mode or --socks-engine is rejected unless --force\nis given.\n\nOptionally use --mode to set the operating mode:\n  single  Single-tunnel mode (default) - one tunnel at a time\n  multi   Multi-tunnel mode - multiple tunnels with DNS router\n\nThe profile tunes defaults to the size of the server; without --profile it is\ndetected from memory and CPUs on the first install:\n  tiny         256-512 MB: one DNS router socket, at most two tunnels,\n               MTU 1200 for new tunnels, DNSTT recommended\n  standard     Default settings\n  performance  4 GB and 4 CPUs or more: Slipstream recommended\nA re-run keeps the profile unless --profile is given.\n\nUse --fail2ban to also generate fail2ban jails (see 'dnstm security fail2ban').\n\nUse --tune to also apply the recommended kernel settings (see 'dnstm tune').\n\nUse --socks-engine builtin to run the SOCKS5 proxy inside dnstm instead of downloading microsocks.\n\nUse --preset with --domain to also create a preconfigured set of backends and\ntunnels in the same run. Tunnel domains are subdomains of --domain:\n  ssh-basic          DNSTT tunnel to SSH (t.<domain>)\n  socks-basic        Slipstream tunnel to SOCKS5 (s.<domain>)\n  multi-shadowsocks  Multi mode: Shadowsocks over Slipstream (s.<domain>),\n                     DNSTT to SOCKS5 (d.<domain>), VayDNS to SSH (v.<domain>)",
 		MenuLabel:    "Install",
 		RequiresRoot: true,
 		Inputs: []InputField{
 			{
 				Name:  "force",
-				Label: "Force reinstall if already installed",
+				Label: "Apply --mode and --socks-engine to an existing install",
 				Type:  InputTypeBool,
 			},
 			{
@@ -37,11 +76,53 @@ func init() {
 				ShortFlag: 'm',
 				Type:      InputTypeSelect,
 				Options:   OperatingModeOptions(),
-				Default:   "single",
+				// No default so that re-running install keeps the existing mode.
 				// Skip mode selection in interactive mode - defaults to single,
 				// user will be prompted to switch to multi when adding second tunnel
 				ShowIf: func(ctx *Context) bool { return !ctx.IsInteractive },
 			},
+			{
+				Name:        "socks-engine",
+				Label:       "SOCKS Engine",
+				Type:        InputTypeSelect,
+				Options:     SocksEngineOptions(),
+				Description: "SOCKS5 proxy implementation (microsocks or builtin)",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:        "preset",
+				Label:       "Preset",
+				Type:        InputTypeSelect,
+				Options:     PresetOptions(),
+				Description: "Create a preconfigured set of tunnels after installing",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:        "domain",
+				Label:       "Base Domain",
+				ShortFlag:   'd',
+				Type:        InputTypeText,
+				Description: "Base domain for preset tunnels (e.g., example.com)",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:        "profile",
+				Label:       "Server profile: tiny, standard or performance (detected when not given)",
+				Type:        InputTypeSelect,
+				Options:     ProfileOptions(),
+				Description: "Defaults tuned to the size of the server",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:  "fail2ban",
+				Label: "Generate fail2ban jails for sshd (requires fail2ban)",
+				Type:  InputTypeBool,
+			},
+			{
+				Name:  "tune",
+				Label: "Apply the recommended kernel settings (see 'dnstm tune')",
+				Type:  InputTypeBool,
+			},
 		},
 	})
 
@@ -89,6 +170,49 @@ func init() {
 			},
 		},
 	})
+
+	// Register package action
+	Register(&Action{
+		ID:    ActionPackage,
+		Use:   "package",
+		Short: "Build a .deb or .rpm package of dnstm",
+		Long:  "Build a .deb or .rpm package that installs dnstm to /usr/local/bin/dnstm.\n\nThe package scripts:\n  - On install, print how to set up dnstm\n  - On upgrade, re-run 'dnstm install' to refresh services of a configured install\n  - On removal, run 'dnstm uninstall --keep-crypto'\n  - On purge (deb), delete /etc/dnstm\n\nOnce dnstm is installed from a package, 'dnstm update' leaves dnstm itself to\nthe package manager and 'dnstm uninstall' asks to remove the package instead.\n\nBuilding needs dpkg-deb for deb and rpmbuild for rpm.\n\nExamples:\n  dnstm package --format deb --version 0.6.0\n  dnstm package --format rpm --arch arm64 --binary ./dnstm-linux-arm64 -o dist",
+		Inputs: []InputField{
+			{
+				Name:        "format",
+				Label:       "Format",
+				Type:        InputTypeSelect,
+				Options:     PackageFormatOptions(),
+				Default:     "deb",
+				Description: "Package format: deb or rpm",
+			},
+			{
+				Name:        "version",
+				Label:       "Version",
+				Type:        InputTypeText,
+				Description: "Package version (default: this build's version)",
+			},
+			{
+				Name:        "arch",
+				Label:       "Architecture",
+				Type:        InputTypeText,
+				Description: "Go architecture of the binary: amd64, arm64, arm or 386 (default: this build's)",
+			},
+			{
+				Name:        "binary",
+				Label:       "Binary",
+				Type:        InputTypeText,
+				Description: "dnstm binary to package (default: the running binary)",
+			},
+			{
+				Name:        "output",
+				Label:       "Output directory",
+				ShortFlag:   'o',
+				Type:        InputTypeText,
+				Description: "Directory to write the package to (default: current directory)",
+			},
+		},
+	})
 }
 
 // SetSystemHandler sets the handler for a system action.
@@ -0,0 +1,74 @@
+package actions
+
+func init() {
+	// Register templates parent action (submenu)
+	Register(&Action{
+		ID:        ActionTemplates,
+		Use:       "templates",
+		Short:     "Inspect and customize the service templates",
+		Long:      "The systemd units and server command lines dnstm generates are rendered from\ntemplates built into dnstm. A file of the same name in /etc/dnstm/templates\nreplaces a built-in template.",
+		MenuLabel: "Templates",
+		IsSubmenu: true,
+	})
+
+	// Register templates.list action
+	Register(&Action{
+		ID:        ActionTemplatesList,
+		Parent:    ActionTemplates,
+		Use:       "list",
+		Short:     "List templates",
+		Long:      "List the templates, whether a file in /etc/dnstm/templates replaces the\nbuilt-in one, and whether it parses.",
+		MenuLabel: "List",
+	})
+
+	// Register templates.show action
+	Register(&Action{
+		ID:        ActionTemplatesShow,
+		Parent:    ActionTemplates,
+		Use:       "show <name>",
+		Short:     "Print a template",
+		Long:      "Print the template in effect, or with --default the built-in one.",
+		MenuLabel: "Show",
+		Args: &ArgsSpec{
+			Name:        "name",
+			Description: "Template name, e.g. unit.service.tmpl",
+			Required:    true,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "default",
+				Label:       "Default",
+				Type:        InputTypeBool,
+				Description: "Print the built-in template",
+			},
+		},
+	})
+
+	// Register templates.export action
+	Register(&Action{
+		ID:           ActionTemplatesExport,
+		Parent:       ActionTemplates,
+		Use:          "export [name]",
+		Short:        "Write the built-in templates to /etc/dnstm/templates",
+		Long:         "Write built-in templates to /etc/dnstm/templates to customize them. Without a\nname, every template is written. Existing files are kept unless --force is\ngiven.\n\nEdited templates apply to services generated afterwards; run\n'dnstm verify --fix' to regenerate the installed ones.",
+		MenuLabel:    "Export",
+		RequiresRoot: true,
+		Args: &ArgsSpec{
+			Name:        "name",
+			Description: "Template name (default: all)",
+		},
+		Inputs: []InputField{
+			{
+				Name:        "force",
+				Label:       "Force",
+				Type:        InputTypeBool,
+				Description: "Replace existing files",
+			},
+		},
+	})
+}
+
+// SetTemplatesHandler sets the handler for a templates action.
+func SetTemplatesHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,54 @@
+package actions
+
+func init() {
+	// Register tune parent action (submenu)
+	Register(&Action{
+		ID:                ActionTune,
+		Use:               "tune",
+		Short:             "Tune kernel settings for DNS tunneling",
+		Long:              "Apply the kernel settings recommended for DNS tunneling servers: UDP\nbuffer sizes, the connection tracking table, the packet and TCP\nconnection backlogs, and route_localnet for port 53 redirects to loopback.",
+		MenuLabel:         "Kernel Tuning",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register tune.apply action
+	Register(&Action{
+		ID:                ActionTuneApply,
+		Parent:            ActionTune,
+		Use:               "apply",
+		Short:             "Apply the recommended kernel settings",
+		Long:              "Raise the kernel settings that are below the recommended values and\npersist them in /etc/sysctl.d/99-dnstm.conf. Settings that are already\nhigher are left alone. Buffers and the conntrack table are sized for the\nserver profile. The values replaced are recorded, so 'dnstm tune revert'\nand uninstall put them back.",
+		MenuLabel:         "Apply",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register tune.revert action
+	Register(&Action{
+		ID:                ActionTuneRevert,
+		Parent:            ActionTune,
+		Use:               "revert",
+		Short:             "Put back the kernel settings tuning replaced",
+		Long:              "Remove /etc/sysctl.d/99-dnstm.conf and put back the values the tuning\nreplaced, unless they were changed since. route_localnet stays enabled\nwhile dnstm is installed, since port 53 redirects restored from older\ninstalls need it.",
+		MenuLabel:         "Revert",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+	})
+
+	// Register tune.status action
+	Register(&Action{
+		ID:                ActionTuneStatus,
+		Parent:            ActionTune,
+		Use:               "status",
+		Short:             "Compare kernel settings with the recommendations",
+		Long:              "Show the current value of each recommended kernel setting, the\nrecommended value, and the value tuning replaced",
+		MenuLabel:         "Status",
+		RequiresInstalled: true,
+	})
+}
+
+// SetTuneHandler sets the handler for a tune action.
+func SetTuneHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -2,6 +2,8 @@ package actions
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/router"
@@ -30,6 +32,15 @@ func init() {
 		MenuLabel:         "List",
 		RequiresRoot:      true,
 		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "all",
+				Label:       "Include the rescue tunnel",
+				Type:        InputTypeBool,
+				Description: "Also list the rescue tunnel",
+			},
+			selectorInput("List only the tunnels whose labels match"),
+		},
 	})
 
 	// Register tunnel.status action
@@ -92,6 +103,11 @@ func init() {
 			Description: "Tunnel tag",
 			Required:    true,
 			PickerFunc:  TunnelPicker,
+			OrInput:     "selector",
+		},
+		Inputs: []InputField{
+			selectorInput("Start every tunnel whose labels match, instead of one tag"),
+			port53ForceInput(),
 		},
 	})
 
@@ -110,6 +126,10 @@ func init() {
 			Description: "Tunnel tag",
 			Required:    true,
 			PickerFunc:  TunnelPicker,
+			OrInput:     "selector",
+		},
+		Inputs: []InputField{
+			selectorInput("Stop every tunnel whose labels match, instead of one tag"),
 		},
 	})
 
@@ -128,6 +148,10 @@ func init() {
 			Description: "Tunnel tag",
 			Required:    true,
 			PickerFunc:  TunnelPicker,
+			OrInput:     "selector",
+		},
+		Inputs: []InputField{
+			selectorInput("Restart every tunnel whose labels match, instead of one tag"),
 		},
 	})
 
@@ -160,7 +184,7 @@ func init() {
 		Parent:            ActionTunnel,
 		Use:               "share",
 		Short:             "Generate a shareable client config URL",
-		Long:              "Generate a dnst:// URL containing all client-needed connection info",
+		Long:              "Generate a dnst:// URL containing all client-needed connection info.\n\nThe URL can also be rendered as a QR code so mobile clients can scan it\ninstead of copying long keys. Slipstream tunnels with a Shadowsocks backend\ncan be shared as an ss:// link for Shadowsocks clients instead.",
 		MenuLabel:         "Share",
 		RequiresRoot:      true,
 		RequiresInstalled: true,
@@ -198,6 +222,600 @@ func init() {
 				Type:        InputTypeBool,
 				Description: "Skip embedding certificate for Slipstream tunnels",
 			},
+			{
+				Name:        "qr",
+				Label:       "QR Code",
+				Type:        InputTypeBool,
+				Description: "Print a QR code of the URL to the terminal",
+			},
+			{
+				Name:        "ss",
+				Label:       "Shadowsocks Link",
+				Type:        InputTypeBool,
+				Description: "Share an ss:// link instead of the dnst:// URL",
+				ShowIf:      tunnelHasShadowsocksBackend,
+			},
+			{
+				Name:        "qr-png",
+				Label:       "QR Code PNG",
+				Type:        InputTypeText,
+				Description: "Write a PNG QR code of the URL to this path",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:        "ss-user",
+				Label:       "Shadowsocks User",
+				Type:        InputTypeText,
+				Description: "User of a multi-user Shadowsocks tunnel to share",
+				ShowIf:      tunnelHasShadowsocksUsers,
+			},
+		},
+	})
+
+	// Register tunnel.exports action
+	Register(&Action{
+		ID:                ActionTunnelExports,
+		Parent:            ActionTunnel,
+		Use:               "exports",
+		Short:             "Regenerate the client bundles",
+		Long:              "Regenerate the client configs kept under /etc/dnstm/exports and show which\nchanged since they were last written. dnstm does this by itself after changes\nto domains, passwords, methods, certificates or keys; without --tag every\ntunnel's bundle is regenerated.",
+		MenuLabel:         "Client Bundles",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag (default: all tunnels)",
+		},
+	})
+
+	// Register tunnel.watchdog action
+	Register(&Action{
+		ID:                ActionTunnelWatchdog,
+		Parent:            ActionTunnel,
+		Use:               "watchdog",
+		Short:             "Configure the health watchdog",
+		Long:              "Restart a tunnel automatically when it stops answering DNS queries.\n\nThe tunnel is probed with a DNS query under its domain every third of the\ntimeout. If no answer arrives within the timeout, systemd restarts the service.",
+		MenuLabel:         "Watchdog",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable watchdog",
+				Type:        InputTypeBool,
+				Description: "Turn the watchdog off",
+			},
+			{
+				Name:        "timeout",
+				Label:       "Timeout",
+				Type:        InputTypeText,
+				Description: "Restart after this long without an answer (e.g. 30s), or 'off'",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Watchdog != nil && t.Watchdog.Timeout != "" {
+						return t.Watchdog.Timeout
+					}
+					return "30s"
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+		},
+	})
+
+	// Register tunnel.health action
+	Register(&Action{
+		ID:                ActionTunnelHealth,
+		Parent:            ActionTunnel,
+		Use:               "health",
+		Short:             "Route the built-in health endpoint",
+		Long:              "Let client apps probe a tunnel end to end without depending on its backend.\n\nA client opens a stream through the tunnel and sends \"DNSTM-HEALTH\\n\"; dnstm\nanswers \"DNSTM-OK <tag> <unix time>\\n\" even while SSH or the SOCKS proxy is down.\nFor DNSTT, VayDNS and Slipstream a loopback front answers probes and passes\nall other streams to the backend. Chisel and Shadowsocks clients connect to\n127.0.0.1:7 instead, which also echoes what it receives.\n\nExamples:\n  dnstm tunnel health -t dnstt1\n  dnstm tunnel health -t dnstt1 --probe\n  dnstm tunnel health -t dnstt1 --disable",
+		MenuLabel:         "Health Endpoint",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Stop routing the health endpoint",
+				Type:        InputTypeBool,
+				Description: "Remove the health endpoint from the tunnel",
+			},
+			{
+				Name:        "probe",
+				Label:       "Probe the endpoint on this server",
+				Type:        InputTypeBool,
+				Description: "Send a probe to the tunnel's endpoint locally and show the answer",
+			},
+		},
+	})
+
+	// Register tunnel.sessions action
+	Register(&Action{
+		ID:                ActionTunnelSessions,
+		Parent:            ActionTunnel,
+		Use:               "sessions",
+		Short:             "Limit concurrent sessions",
+		Long:              "Cap the concurrent sessions of a tunnel so one popular domain cannot\noverwhelm a small server. A session is a stream the tunnel opens to its\nbackend, e.g. one SSH login or one SOCKS connection.\n\nThe limit is enforced by a loopback front before the backend, run by the\nhealth service, so it is available for DNSTT, VayDNS and Slipstream (not\nwith Shadowsocks). Streams over the limit are closed, or with --over-limit\nqueue held until a session ends. Counters show in 'dnstm tunnel status'.\n\nExamples:\n  dnstm tunnel sessions -t dnstt1 --max 50\n  dnstm tunnel sessions -t dnstt1 --max 50 --over-limit queue --queue-timeout 1m\n  dnstm tunnel sessions -t dnstt1 --disable",
+		MenuLabel:         "Session Limit",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Remove the session limit",
+				Type:        InputTypeBool,
+				Description: "Let the tunnel open any number of sessions",
+			},
+			{
+				Name:        "max",
+				Label:       "Maximum concurrent sessions",
+				Type:        InputTypeNumber,
+				Description: "Streams the tunnel may have open to its backend at once",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Sessions != nil {
+						return strconv.Itoa(t.Sessions.Max)
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+			{
+				Name:        "over-limit",
+				Label:       "Over-limit behavior (reject, queue)",
+				Type:        InputTypeSelect,
+				Options:     SessionsOverLimitOptions(),
+				Description: "reject, or queue until a session ends",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Sessions != nil && t.Sessions.OverLimit != "" {
+						return t.Sessions.OverLimit
+					}
+					return config.SessionsReject
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+			{
+				Name:        "queue-timeout",
+				Label:       "How long a queued stream waits (e.g. 30s)",
+				Type:        InputTypeText,
+				Description: "How long a queued stream waits for a free session",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Sessions != nil {
+						return t.Sessions.QueueTimeout
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable") && ctx.GetString("over-limit") == config.SessionsQueue
+				},
+			},
+		},
+	})
+
+	// Register tunnel.upgrade action
+	Register(&Action{
+		ID:                ActionTunnelUpgrade,
+		Parent:            ActionTunnel,
+		Use:               "upgrade",
+		Short:             "Upgrade a tunnel's transport after testing the new version",
+		Long:              "Upgrade the transport binary of a tunnel without trusting an untested build.\n\nThe new version is downloaded next to the installed one and started as a\nsecond instance of the tunnel on a free loopback port, with the tunnel's keys\nand backend. Only if it answers a DNS query for the tunnel's domain (or, for\nChisel, accepts a connection) is the installed binary replaced and the\ntunnel restarted; the candidate is then removed. If the restarted tunnel\nfails the same self-test, the previous binary is put back.\n\nWithout --version the version pinned by this dnstm release is installed.\nOther tunnels of the same transport pick up the new binary when they restart.\n\nExamples:\n  dnstm tunnel upgrade -t slip1\n  dnstm tunnel upgrade -t vay1 --version v0.2.8",
+		MenuLabel:         "Upgrade Transport",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "version",
+				Label:       "Version to install (default: pinned)",
+				Type:        InputTypeText,
+				Description: "Release of the transport binary, e.g. v0.2.8",
+			},
+			{
+				Name:        "reinstall",
+				Label:       "Upgrade even if the version is installed",
+				Type:        InputTypeBool,
+				Description: "Test and install the version even when the manifest says it is current",
+			},
+		},
+	})
+
+	// Register tunnel.canary action
+	Register(&Action{
+		ID:                ActionTunnelCanary,
+		Parent:            ActionTunnel,
+		Use:               "canary",
+		Short:             "Send a percentage of clients to a canary tunnel",
+		Long:              "Send a share of a domain's clients to a canary tunnel, e.g. one with other\ntransport settings or another backend, before moving everyone. Create the\ncanary with 'dnstm tunnel add --canary-of <tag>'; it serves the same domain\nwith the same transport and keys, so clients need no new settings.\n\nThe DNS router picks the canary by hashing the resolver's network (the /24,\nor /48 for IPv6), so a resolver stays on one tunnel between queries. Canary\nrouting needs multi mode. Set --percent 0 to pause it; remove the stable\ntunnel to promote the canary, or the canary to roll back.\n\nExamples:\n  dnstm tunnel canary -t slip1 --percent 10\n  dnstm tunnel canary -t slip1 --percent 0",
+		MenuLabel:         "Canary Routing",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "percent",
+				Label:       "Percentage of clients for the canary (0-100)",
+				Type:        InputTypeNumber,
+				Description: "Share of resolver networks the DNS router sends to the canary",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Canary != nil {
+						return strconv.Itoa(t.Canary.Percent)
+					}
+					return ""
+				},
+			},
+		},
+	})
+
+	// Register tunnel.schedule action
+	Register(&Action{
+		ID:                ActionTunnelSchedule,
+		Parent:            ActionTunnel,
+		Use:               "schedule",
+		Short:             "Configure active hours and blackout windows",
+		Long:              "Limit when a tunnel runs. Windows are HH:MM-HH:MM in local time and may\nwrap past midnight (e.g. 18:00-02:00).\n\nWith active windows the tunnel only runs inside them; blackout windows stop\nit even then. A systemd timer starts and stops the tunnel at each boundary.",
+		MenuLabel:         "Schedule",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable schedule",
+				Type:        InputTypeBool,
+				Description: "Remove the schedule so the tunnel runs at all times",
+			},
+			{
+				Name:        "active",
+				Label:       "Active hours",
+				Type:        InputTypeText,
+				Description: "Comma-separated windows the tunnel runs in (e.g. 18:00-02:00)",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Schedule != nil {
+						return strings.Join(t.Schedule.Active, ",")
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+			{
+				Name:        "blackout",
+				Label:       "Blackout windows",
+				Type:        InputTypeText,
+				Description: "Comma-separated windows the tunnel is stopped in (e.g. 03:00-04:00)",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Schedule != nil {
+						return strings.Join(t.Schedule.Blackout, ",")
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+		},
+	})
+
+	// Register tunnel.apply-schedule action (invoked by the schedule timer)
+	Register(&Action{
+		ID:           ActionTunnelApplySchedule,
+		Parent:       ActionTunnel,
+		Use:          "apply-schedule",
+		Short:        "Start or stop a tunnel according to its schedule",
+		Hidden:       true,
+		RequiresRoot: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+		},
+	})
+
+	// Register tunnel.expiry action
+	Register(&Action{
+		ID:                ActionTunnelExpiry,
+		Parent:            ActionTunnel,
+		Use:               "expiry",
+		Short:             "Set when a tunnel expires",
+		Long:              "Stop a tunnel at a given time, and optionally remove it after a grace period,\ne.g. for trial accounts. A timer checks every 5 minutes.",
+		MenuLabel:         "Expiry",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable expiry",
+				Type:        InputTypeBool,
+				Description: "Remove the expiry so the tunnel runs indefinitely",
+			},
+			{
+				Name:        "at",
+				Label:       "Expires at",
+				Type:        InputTypeText,
+				Description: "A date (2026-12-31, end of day), an RFC 3339 time, or a duration from now (30d, 12h)",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Expiry != nil {
+						return t.Expiry.At
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+			{
+				Name:        "on-expiry",
+				Label:       "On expiry",
+				Type:        InputTypeSelect,
+				Options:     ExpiryActionOptions(),
+				Default:     "stop",
+				Description: "stop, or remove after the grace period",
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+			{
+				Name:        "grace",
+				Label:       "Grace period",
+				Type:        InputTypeText,
+				Description: "How long after expiry a tunnel is removed (e.g. 7d or 72h)",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil && t.Expiry != nil {
+						return t.Expiry.Grace
+					}
+					return ""
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable") && ctx.GetString("on-expiry") == "remove"
+				},
+			},
+		},
+	})
+
+	// Register tunnel.apply-expiry action (invoked by the expiry timer)
+	Register(&Action{
+		ID:           ActionTunnelApplyExpiry,
+		Parent:       ActionTunnel,
+		Use:          "apply-expiry",
+		Short:        "Stop and remove expired tunnels",
+		Hidden:       true,
+		RequiresRoot: true,
+	})
+
+	// Register tunnel.log-alerts action
+	Register(&Action{
+		ID:                ActionTunnelLogAlerts,
+		Parent:            ActionTunnel,
+		Use:               "log-alerts",
+		Short:             "Alert on errors in tunnel logs",
+		Long:              "Scan the logs of running tunnels every 5 minutes for bind failures, TLS errors\nand repeated connection resets. A tunnel whose log matches a pattern threshold\ntimes in one scan is marked degraded and the operator is alerted.\n\nPatterns per transport can be set under log.alerts.patterns in the config.",
+		MenuLabel:         "Log Alerts",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "disable",
+				Label:       "Disable log alerts",
+				Type:        InputTypeBool,
+				Description: "Stop scanning tunnel logs",
+			},
+			{
+				Name:        "threshold",
+				Label:       "Threshold",
+				Type:        InputTypeNumber,
+				Description: "Matching lines per scan that mark a tunnel degraded (default 5)",
+				DefaultFunc: func(ctx *Context) string {
+					if ctx.Config != nil && ctx.Config.Log.Alerts != nil {
+						return strconv.Itoa(ctx.Config.Log.Alerts.GetThreshold())
+					}
+					return strconv.Itoa(config.DefaultLogAlertThreshold)
+				},
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.GetBool("disable")
+				},
+			},
+		},
+	})
+
+	// Register tunnel.scan-logs action (invoked by the log scan timer)
+	Register(&Action{
+		ID:           ActionTunnelScanLogs,
+		Parent:       ActionTunnel,
+		Use:          "scan-logs",
+		Short:        "Scan tunnel logs for errors",
+		Hidden:       true,
+		RequiresRoot: true,
+	})
+
+	// Register tunnel.unquarantine action
+	Register(&Action{
+		ID:                ActionTunnelUnquarantine,
+		Parent:            ActionTunnel,
+		Use:               "unquarantine",
+		Short:             "Lift a crash-loop quarantine",
+		Long:              "Clear the quarantine of a tunnel that systemd stopped restarting after a\ncrash loop, and start it again.",
+		MenuLabel:         "Unquarantine",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			port53ForceInput(),
+		},
+	})
+
+	// Register tunnel.pause action
+	Register(&Action{
+		ID:                ActionTunnelPause,
+		Parent:            ActionTunnel,
+		Use:               "pause",
+		Short:             "Stop a tunnel but keep its route",
+		Long:              "Stop a tunnel for maintenance while the DNS router keeps its route and\nanswers queries for its domain with SERVFAIL or REFUSED, so resolvers\nretry instead of treating the server as gone. 'dnstm tunnel resume' starts\nit again; 'dnstm tunnel stop' withdraws the route as well. Multi mode only.",
+		MenuLabel:         "Pause",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "reply",
+				Label:       "Reply while paused",
+				Type:        InputTypeSelect,
+				Options:     PauseReplyOptions(),
+				Default:     config.PauseServfail,
+				Description: "Answer for the tunnel's domain: servfail or refused",
+			},
+		},
+	})
+
+	// Register tunnel.resume action
+	Register(&Action{
+		ID:                ActionTunnelResume,
+		Parent:            ActionTunnel,
+		Use:               "resume",
+		Short:             "Start a paused tunnel",
+		Long:              "Start a paused tunnel and route its domain to it again",
+		MenuLabel:         "Resume",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+	})
+
+	// Register tunnel.label action
+	Register(&Action{
+		ID:                ActionTunnelLabel,
+		Parent:            ActionTunnel,
+		Use:               "label",
+		Short:             "Set or remove tunnel labels",
+		Long:              "Set or remove the labels that selectors match, e.g. region=eu or customer=acme",
+		MenuLabel:         "Labels",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "set",
+				Label:       "Labels to set",
+				Type:        InputTypeText,
+				Description: "Comma-separated key=value pairs, e.g. region=eu,customer=acme",
+			},
+			{
+				Name:        "remove",
+				Label:       "Labels to remove",
+				Type:        InputTypeText,
+				Description: "Comma-separated keys",
+			},
+		},
+	})
+
+	// Register tunnel.describe action
+	Register(&Action{
+		ID:                ActionTunnelDescribe,
+		Parent:            ActionTunnel,
+		Use:               "describe",
+		Short:             "Document what a tunnel is for",
+		Long:              "Set a tunnel's one-line description and free-form notes, shown by 'dnstm tunnel status'",
+		MenuLabel:         "Describe",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "description",
+				Label:       "Description",
+				Type:        InputTypeText,
+				Description: "One line, e.g. who uses the tunnel",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil {
+						return t.Description
+					}
+					return ""
+				},
+			},
+			{
+				Name:        "notes",
+				Label:       "Notes",
+				Type:        InputTypeText,
+				Description: "Free-form notes; \\n starts a new line",
+				DefaultFunc: func(ctx *Context) string {
+					if t := selectedTunnel(ctx); t != nil {
+						return t.Notes
+					}
+					return ""
+				},
+			},
+			{
+				Name:        "clear",
+				Label:       "Clear description and notes",
+				Type:        InputTypeBool,
+				Description: "Remove the description and notes",
+			},
 		},
 	})
 
@@ -222,11 +840,11 @@ func init() {
 			},
 			{
 				Name:        "transport",
-				Label:       "Transport (vaydns, dnstt, slipstream)",
+				Label:       "Transport (vaydns, dnstt, slipstream, chisel)",
 				Type:        InputTypeSelect,
 				Required:    true,
 				Options:     TransportOptions(),
-				Description: "Transport protocol (vaydns, dnstt, slipstream)",
+				Description: "Transport protocol (vaydns, dnstt, slipstream, or chisel as an HTTPS fallback)",
 				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
 			},
 			{
@@ -271,26 +889,61 @@ func init() {
 				Label:       "Port",
 				ShortFlag:   'p',
 				Type:        InputTypeNumber,
-				Description: "Internal port for multi mode (ignored in single mode)",
+				Description: "Internal port for multi mode (ignored in single mode); public listen port for chisel",
 				DefaultFunc: func(ctx *Context) string {
+					if config.TransportType(ctx.GetString("transport")) == config.TransportChisel {
+						return fmt.Sprintf("%d", config.DefaultChiselPort)
+					}
 					cfg, err := config.Load()
 					if err != nil {
 						return fmt.Sprintf("%d", config.DefaultPortStart)
 					}
-					port := cfg.AllocateNextPort()
-					if port == 0 {
-						return fmt.Sprintf("%d", config.DefaultPortStart)
+					port, err := cfg.AllocateNextPort()
+					if err != nil {
+						// tunnel add reports the full range itself
+						return ""
 					}
 					return fmt.Sprintf("%d", port)
 				},
 				ShowIf: func(ctx *Context) bool { return !ctx.IsInteractive },
 			},
 			{
-				Name:    "mtu",
-				Label:   "MTU",
-				Type:    InputTypeNumber,
-				Default: "1232",
-				ShowIf:  func(ctx *Context) bool { return !ctx.IsInteractive },
+				Name:        "bind-host",
+				Label:       "Bind address",
+				Type:        InputTypeText,
+				Description: "Single mode: serve port 53 on this second public IP next to the active tunnel",
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.IsInteractive && config.TransportType(ctx.GetString("transport")).IsDNS()
+				},
+			},
+			{
+				Name:        "canary-of",
+				Label:       "Canary of tunnel",
+				Type:        InputTypeText,
+				Description: "Multi mode: serve the domain and keys of this tunnel, with its transport, and take a share of its clients (see 'dnstm tunnel canary')",
+				ShowIf: func(ctx *Context) bool {
+					return !ctx.IsInteractive && config.TransportType(ctx.GetString("transport")).IsDNS()
+				},
+			},
+			{
+				Name:        "description",
+				Label:       "Description",
+				Type:        InputTypeText,
+				Description: "One line describing what the tunnel is for",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:        "labels",
+				Label:       "Labels",
+				Type:        InputTypeText,
+				Description: "Labels for selectors, e.g. region=eu,customer=acme",
+				ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+			},
+			{
+				Name:   "mtu",
+				Label:  "MTU (default 1232, 1200 with the tiny profile)",
+				Type:   InputTypeNumber,
+				ShowIf: func(ctx *Context) bool { return !ctx.IsInteractive },
 			},
 			{
 				Name:        "dnstt-compat",
@@ -382,11 +1035,50 @@ func init() {
 					return !ctx.IsInteractive && config.TransportType(ctx.GetString("transport")) == config.TransportVayDNS
 				},
 			},
+			ignoreLimitsInput(),
+			port53ForceInput(),
 		},
 	})
 
 }
 
+// selectorInput is the --selector/-l flag of commands that act on the
+// tunnels matching a label selector.
+func selectorInput(description string) InputField {
+	return InputField{
+		Name:        "selector",
+		Label:       "Label selector",
+		ShortFlag:   'l',
+		Type:        InputTypeText,
+		Description: description + ", e.g. region=eu,customer!=acme",
+		ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+	}
+}
+
+// ignoreLimitsInput is the --ignore-limits flag of commands checked against
+// the limits section of the config. The menu asks instead.
+func ignoreLimitsInput() InputField {
+	return InputField{
+		Name:        "ignore-limits",
+		Label:       "Ignore limits",
+		Type:        InputTypeBool,
+		Description: "Go on even if the change exceeds the limits in the config",
+		ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+	}
+}
+
+// port53ForceInput is the --force flag of commands that bind port 53: they go
+// on when another DNS server holds it. The menu asks instead.
+func port53ForceInput() InputField {
+	return InputField{
+		Name:        "force",
+		Label:       "Force",
+		Type:        InputTypeBool,
+		Description: "Go on even if another DNS server holds port 53",
+		ShowIf:      func(ctx *Context) bool { return !ctx.IsInteractive },
+	}
+}
+
 // TunnelPicker provides interactive tunnel selection.
 func TunnelPicker(ctx *Context) (string, error) {
 	cfg, err := config.Load()
@@ -399,6 +1091,7 @@ func TunnelPicker(ctx *Context) (string, error) {
 	}
 
 	var options []SelectOption
+	router.PrefetchStates(cfg.Tunnels)
 	for _, t := range cfg.Tunnels {
 		status := SymbolStopped
 		if router.NewTunnel(&t).IsActive() {
@@ -429,6 +1122,11 @@ func TransportOptions() []SelectOption {
 			Value:       string(config.TransportDNSTT),
 			Description: "Classic DNS tunnel (dnstt-server)",
 		},
+		{
+			Label:       "Chisel",
+			Value:       string(config.TransportChisel),
+			Description: "Websocket over HTTPS fallback for when DNS is throttled",
+		},
 	}
 }
 
@@ -444,8 +1142,8 @@ func BackendOptions(ctx *Context) []SelectOption {
 
 	for _, b := range cfg.Backends {
 		// Check compatibility
-		if transport == config.TransportDNSTT && b.Type == config.BackendShadowsocks {
-			continue // DNSTT doesn't support shadowsocks
+		if (transport == config.TransportDNSTT || transport == config.TransportChisel) && b.Type == config.BackendShadowsocks {
+			continue // DNSTT and Chisel don't support shadowsocks
 		}
 
 		typeName := config.GetBackendTypeDisplayName(b.Type)
@@ -479,6 +1177,33 @@ func NoTunnelsError() error {
 	return fmt.Errorf("no tunnels configured")
 }
 
+// tunnelHasShadowsocksUsers reports whether the selected tunnel has
+// Shadowsocks users.
+func tunnelHasShadowsocksUsers(ctx *Context) bool {
+	tag := ctx.GetString("tag")
+	if tag == "" || ctx.Config == nil {
+		return false
+	}
+	tunnel := ctx.Config.GetTunnelByTag(tag)
+	return tunnel != nil && tunnel.Slipstream != nil && len(tunnel.Slipstream.Users) > 0
+}
+
+// tunnelHasShadowsocksBackend reports whether the selected tunnel is a
+// Slipstream tunnel with a Shadowsocks backend, which can be shared as an
+// ss:// link.
+func tunnelHasShadowsocksBackend(ctx *Context) bool {
+	tag := ctx.GetString("tag")
+	if tag == "" || ctx.Config == nil {
+		return false
+	}
+	tunnel := ctx.Config.GetTunnelByTag(tag)
+	if tunnel == nil || tunnel.Transport != config.TransportSlipstream {
+		return false
+	}
+	backend := ctx.Config.GetBackendByTag(tunnel.Backend)
+	return backend != nil && backend.Type == config.BackendShadowsocks
+}
+
 // tunnelHasSSHBackend checks if the selected tunnel uses an SSH backend.
 func tunnelHasSSHBackend(ctx *Context) bool {
 	tag := ctx.GetString("tag")
@@ -495,3 +1220,16 @@ func tunnelHasSSHBackend(ctx *Context) bool {
 	}
 	return backend.Type == config.BackendSSH
 }
+
+// selectedTunnel returns the tunnel named by the "tag" argument, if any.
+func selectedTunnel(ctx *Context) *config.TunnelConfig {
+	tag := ctx.GetString("tag")
+	if tag == "" {
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil
+	}
+	return cfg.GetTunnelByTag(tag)
+}
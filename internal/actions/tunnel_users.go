@@ -0,0 +1,134 @@
+package actions
+
+func init() {
+	// Register tunnel.users action (submenu)
+	Register(&Action{
+		ID:                ActionTunnelUsers,
+		Parent:            ActionTunnel,
+		Use:               "users",
+		Short:             "Manage users of a Shadowsocks tunnel",
+		Long:              "Give each user of a Slipstream tunnel with a Shadowsocks backend a key of\ntheir own, so users can be added and removed without changing the others'\nconfigs. The backend must use a Shadowsocks 2022 AES method.",
+		MenuLabel:         "Users",
+		IsSubmenu:         true,
+		RequiresInstalled: true,
+	})
+
+	// Register tunnel.users.list action
+	Register(&Action{
+		ID:                ActionTunnelUsersList,
+		Parent:            ActionTunnelUsers,
+		Use:               "list",
+		Short:             "List the users of a tunnel",
+		MenuLabel:         "List",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+	})
+
+	// Register tunnel.users.add action
+	Register(&Action{
+		ID:                ActionTunnelUsersAdd,
+		Parent:            ActionTunnelUsers,
+		Use:               "add",
+		Short:             "Add a user to a tunnel",
+		Long:              "Add a user with a new key to a tunnel and restart it. The tunnel's other\nusers keep their configs.",
+		MenuLabel:         "Add",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "name",
+				Label:       "User name",
+				ShortFlag:   'n',
+				Type:        InputTypeText,
+				Required:    true,
+				Description: "Name of the user",
+			},
+			{
+				Name:        "password",
+				Label:       "Key",
+				Type:        InputTypePassword,
+				Description: "Base64 key of the user (default: generated)",
+			},
+		},
+	})
+
+	// Register tunnel.users.remove action
+	Register(&Action{
+		ID:                ActionTunnelUsersRemove,
+		Parent:            ActionTunnelUsers,
+		Use:               "remove",
+		Short:             "Remove a user from a tunnel",
+		Long:              "Remove a user from a tunnel and restart it. The user's config stops working;\nthe others keep working.",
+		MenuLabel:         "Remove",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "name",
+				Label:       "User name",
+				ShortFlag:   'n',
+				Type:        InputTypeText,
+				Required:    true,
+				Description: "Name of the user",
+			},
+		},
+		Confirm: &ConfirmConfig{
+			Message:     "Remove user?",
+			Description: "Clients with this user's config stop working.",
+			DefaultNo:   true,
+			ForceFlag:   "force",
+		},
+	})
+
+	// Register tunnel.users.share action
+	Register(&Action{
+		ID:                ActionTunnelUsersShare,
+		Parent:            ActionTunnelUsers,
+		Use:               "share",
+		Short:             "Print a user's ss:// link",
+		Long:              "Print the SIP002 ss:// link of a user, for Shadowsocks clients that run\nslipstream-client as their plugin. Use 'dnstm tunnel share --ss-user' for a\ndnst:// URL with the certificate.",
+		MenuLabel:         "Share",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Args: &ArgsSpec{
+			Name:        "tag",
+			Description: "Tunnel tag",
+			Required:    true,
+			PickerFunc:  TunnelPicker,
+		},
+		Inputs: []InputField{
+			{
+				Name:        "name",
+				Label:       "User name",
+				ShortFlag:   'n',
+				Type:        InputTypeText,
+				Required:    true,
+				Description: "Name of the user",
+			},
+			{
+				Name:        "qr",
+				Label:       "QR Code",
+				Type:        InputTypeBool,
+				Description: "Print a QR code of the link to the terminal",
+			},
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package actions
+
+func init() {
+	// Register verify action
+	Register(&Action{
+		ID:                ActionVerify,
+		Use:               "verify",
+		Short:             "Check generated files against the config",
+		Long:              "Build the DNS router and tunnel services, and the Shadowsocks and Chisel\nconfig files, from the config and compare them with the installed files.\nReports files that are missing or were edited by hand, and tunnel files,\nkeys and certificates not owned by the dnstm user or with a private key\nreadable by others.\n\nUse --fix to regenerate the drifted files, restarting the services that\nrun, and to repair owners and modes. A missing key or certificate is not\ngenerated again, since clients would need new configs.",
+		MenuLabel:         "Verify",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "fix",
+				Label:       "Fix",
+				Type:        InputTypeBool,
+				Description: "Regenerate drifted files and repair owners and modes",
+			},
+		},
+	})
+}
+
+// SetVerifyHandler sets the handler for the verify action.
+func SetVerifyHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,97 @@
+package actions
+
+func init() {
+	// Register zone parent action (submenu)
+	Register(&Action{
+		ID:        ActionZone,
+		Use:       "zone",
+		Short:     "Manage static DNS records",
+		Long:      "Manage static DNS records the DNS router answers next to the tunnels,\nso the server can also host records such as MX or ACME DNS-01 TXT.",
+		MenuLabel: "DNS Records",
+		IsSubmenu: true,
+	})
+
+	// Register zone.list action
+	Register(&Action{
+		ID:                ActionZoneList,
+		Parent:            ActionZone,
+		Use:               "list",
+		Short:             "List static records",
+		MenuLabel:         "List",
+		RequiresInstalled: true,
+	})
+
+	// Register zone.add action
+	Register(&Action{
+		ID:                ActionZoneAdd,
+		Parent:            ActionZone,
+		Use:               "add",
+		Short:             "Add a static record",
+		Long:              "Add a static record and restart the DNS router to serve it.\n\nNames are fully qualified. MX values are '<preference> <host>'.\nRecords are only served by the DNS router, which runs in multi mode.",
+		MenuLabel:         "Add",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "name",
+				Label:       "Name",
+				Type:        InputTypeText,
+				Required:    true,
+				Description: "Fully qualified record name (e.g. mail.example.com)",
+			},
+			{
+				Name:        "type",
+				Label:       "Type",
+				Type:        InputTypeSelect,
+				Required:    true,
+				Options:     ZoneRecordTypeOptions(),
+				Description: "Record type",
+			},
+			{
+				Name:        "value",
+				Label:       "Value",
+				Type:        InputTypeText,
+				Required:    true,
+				Description: "Address, host, '<preference> <host>' for MX, or text for TXT",
+			},
+			{
+				Name:        "ttl",
+				Label:       "TTL",
+				Type:        InputTypeNumber,
+				Description: "Time to live in seconds (default 3600)",
+			},
+		},
+	})
+
+	// Register zone.remove action
+	Register(&Action{
+		ID:                ActionZoneRemove,
+		Parent:            ActionZone,
+		Use:               "remove",
+		Short:             "Remove static records",
+		Long:              "Remove the static records with a name, optionally only those of one type.",
+		MenuLabel:         "Remove",
+		RequiresRoot:      true,
+		RequiresInstalled: true,
+		Inputs: []InputField{
+			{
+				Name:        "name",
+				Label:       "Name",
+				Type:        InputTypeText,
+				Required:    true,
+				Description: "Fully qualified record name",
+			},
+			{
+				Name:        "type",
+				Label:       "Type",
+				Type:        InputTypeText,
+				Description: "Only remove records of this type",
+			},
+		},
+	})
+}
+
+// SetZoneHandler sets the handler for a zone action.
+func SetZoneHandler(actionID string, handler Handler) {
+	SetHandler(actionID, handler)
+}
@@ -0,0 +1,342 @@
+// Package agent enrolls dnstm servers as agents of a controller, another
+// dnstm server whose API server manages the fleet. Agents authenticate
+// with a certificate the controller's CA signs at enrollment (mutual TLS)
+// and keep a control channel open, over which the controller hands them
+// dnstm commands to run. The controller side of the state is kept by
+// Registry.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// Dir holds the agent's key, certificate, the controller's CA and the
+// enrollment state.
+var Dir = filepath.Join(paths.ConfigDir, "agent")
+
+// Wire types of the agent endpoints of the controller.
+type (
+	// EnrollRequest registers an agent with a token and asks the
+	// controller to sign its certificate request.
+	EnrollRequest struct {
+		Token string `json:"token"`
+		Name  string `json:"name"`
+		CSR   string `json:"csr"` // PEM certificate request
+	}
+
+	// EnrollResponse carries the signed certificate and the controller's CA.
+	EnrollResponse struct {
+		Certificate string `json:"certificate"` // PEM
+		CA          string `json:"ca"`          // PEM
+	}
+
+	// CommandResult is what an agent reports after running a command.
+	CommandResult struct {
+		ExitCode int    `json:"exit_code"`
+		Output   string `json:"output"`
+	}
+)
+
+// maxOutput bounds the command output an agent reports.
+const maxOutput = 256 << 10
+
+// State records which controller the agent enrolled with.
+type State struct {
+	Controller string    `json:"controller"` // https://host:port
+	Name       string    `json:"name"`
+	CAHash     string    `json:"ca_hash"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+	Revoked    bool      `json:"revoked,omitempty"`
+}
+
+// LoadState reads the enrollment state. It returns an error wrapping
+// os.ErrNotExist when this server is not enrolled.
+func LoadState() (*State, error) {
+	var s State
+	if err := readJSON(filepath.Join(Dir, "state.json"), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func saveState(s *State) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(Dir, "state.json"), data, 0600)
+}
+
+// IsEnrolled reports whether this server enrolled with a controller.
+func IsEnrolled() bool {
+	_, err := os.Stat(filepath.Join(Dir, "state.json"))
+	return err == nil
+}
+
+// Leave deletes the agent's key, certificate and state.
+func Leave() error {
+	return os.RemoveAll(Dir)
+}
+
+// NormalizeController turns a controller address into its base URL.
+// https:// is added when the scheme is missing.
+func NormalizeController(controller string) (string, error) {
+	controller = strings.TrimRight(strings.TrimSpace(controller), "/")
+	if !strings.Contains(controller, "://") {
+		controller = "https://" + controller
+	}
+	u, err := url.Parse(controller)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.Path != "" {
+		return "", fmt.Errorf("controller must be https://host:port")
+	}
+	return controller, nil
+}
+
+// Enroll generates the agent's key, has the controller sign its
+// certificate using a one-time token and saves both with the controller's
+// CA. The token carries the hash of the CA, so the controller is
+// authenticated before the agent sends anything.
+func Enroll(ctx context.Context, controller, token, name string) (*State, error) {
+	controller, err := NormalizeController(controller)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	_, _, caHash, err := ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, csrPEM, err := newKeyAndCSR(name)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			// Verified against the CA hash instead of the system roots
+			InsecureSkipVerify: true,
+			VerifyConnection:   verifyServer(caHash, nil),
+		}},
+	}
+	body, _ := json.Marshal(EnrollRequest{Token: token, Name: name, CSR: string(csrPEM)})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controller+"/v1/agents/enroll", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach controller: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("controller refused enrollment: %s", responseError(resp))
+	}
+	var enrolled EnrollResponse
+	if err := json.NewDecoder(resp.Body).Decode(&enrolled); err != nil {
+		return nil, fmt.Errorf("invalid enrollment response: %w", err)
+	}
+
+	ca, err := parseCert([]byte(enrolled.CA))
+	if err != nil || certHash(ca.Raw) != caHash {
+		return nil, fmt.Errorf("controller returned a different CA than the token names")
+	}
+	if _, err := tls.X509KeyPair([]byte(enrolled.Certificate), keyPEM); err != nil {
+		return nil, fmt.Errorf("controller returned a certificate for another key: %w", err)
+	}
+
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return nil, err
+	}
+	for file, data := range map[string][]byte{
+		"key.pem":  keyPEM,
+		"cert.pem": []byte(enrolled.Certificate),
+		"ca.pem":   []byte(enrolled.CA),
+	} {
+		if err := os.WriteFile(filepath.Join(Dir, file), data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+	}
+	state := &State{Controller: controller, Name: name, CAHash: caHash, EnrolledAt: time.Now().UTC()}
+	if err := saveState(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Agent keeps the control channel to the controller open and runs the
+// commands it receives.
+type Agent struct {
+	State  *State
+	Client *http.Client
+	// Run runs a dnstm command and returns its exit code and output.
+	Run  func(ctx context.Context, args []string) (int, string)
+	Logf func(format string, args ...any)
+}
+
+// New returns an agent using the saved key, certificate and CA.
+func New() (*Agent, error) {
+	state, err := LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("not enrolled: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(filepath.Join(Dir, "cert.pem"), filepath.Join(Dir, "key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agent certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(filepath.Join(Dir, "ca.pem"))
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		// Longer than the controller holds a poll open
+		Timeout: 2 * time.Minute,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			Certificates:       []tls.Certificate{cert},
+			InsecureSkipVerify: true,
+			VerifyConnection:   verifyServer(state.CAHash, caPEM),
+		}},
+	}
+	return &Agent{State: state, Client: client, Run: runDnstm, Logf: func(string, ...any) {}}, nil
+}
+
+// Serve polls the controller for commands until ctx is done or the agent
+// is revoked. Connection errors are retried with backoff, so the channel
+// survives controller restarts and network outages.
+func (a *Agent) Serve(ctx context.Context) error {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		cmd, err := a.poll(ctx)
+		switch {
+		case errors.Is(err, ErrRevoked):
+			a.State.Revoked = true
+			saveState(a.State)
+			return err
+		case err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			a.Logf("control channel: %v; retrying in %s", err, backoff)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+		if cmd == nil {
+			continue
+		}
+
+		a.Logf("running command %s: dnstm %s", cmd.ID, strings.Join(cmd.Args, " "))
+		code, output := a.Run(ctx, cmd.Args)
+		if err := a.report(ctx, cmd.ID, CommandResult{ExitCode: code, Output: output}); err != nil {
+			a.Logf("command %s: failed to report result: %v", cmd.ID, err)
+		}
+	}
+	return ctx.Err()
+}
+
+// poll waits for the next command. It returns nil when the controller had
+// none before its poll timeout.
+func (a *Agent) poll(ctx context.Context) (*Command, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.State.Controller+"/v1/agents/channel", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var cmd Command
+		if err := json.NewDecoder(resp.Body).Decode(&cmd); err != nil {
+			return nil, fmt.Errorf("invalid command: %w", err)
+		}
+		return &cmd, nil
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusForbidden:
+		return nil, ErrRevoked
+	default:
+		return nil, fmt.Errorf("controller: %s", responseError(resp))
+	}
+}
+
+func (a *Agent) report(ctx context.Context, id string, result CommandResult) error {
+	body, _ := json.Marshal(result)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.State.Controller+"/v1/agents/commands/"+url.PathEscape(id), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("controller: %s", responseError(resp))
+	}
+	return nil
+}
+
+// runDnstm runs this dnstm binary with args.
+func runDnstm(ctx context.Context, args []string) (int, string) {
+	self, err := os.Executable()
+	if err != nil {
+		self = paths.Bin("dnstm")
+	}
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, self, args...)
+	cmd.Stdout, cmd.Stderr = &out, &out
+	err = cmd.Run()
+	output := out.String()
+	if len(output) > maxOutput {
+		output = output[len(output)-maxOutput:]
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode(), output
+	case err != nil:
+		return -1, output + err.Error()
+	}
+	return 0, output
+}
+
+// responseError returns the error message of a failed response.
+func responseError(resp *http.Response) string {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		return body.Error
+	}
+	if msg := strings.TrimSpace(string(data)); msg != "" {
+		return resp.Status + ": " + msg
+	}
+	return resp.Status
+}
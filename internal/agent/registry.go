@@ -0,0 +1,353 @@
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ControllerDir holds the controller's CA, enrollment tokens, enrolled
+// agents, revocations and queued commands. The API server, running as the
+// dnstm user, owns it.
+const ControllerDir = "/var/lib/dnstm/api/agents"
+
+// Command states.
+const (
+	CommandQueued = "queued"
+	CommandSent   = "sent"
+	CommandDone   = "done"
+)
+
+// DefaultTokenTTL is how long an enrollment token is valid unless set.
+const DefaultTokenTTL = 24 * time.Hour
+
+// namePattern matches agent names. Names are file names and certificate
+// common names, so they are restricted like host names.
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ErrRevoked is returned for agents whose certificate was revoked.
+var ErrRevoked = errors.New("agent certificate revoked")
+
+// ValidateName checks an agent name.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("agent name must be 1-63 lowercase letters, digits and dashes, starting with a letter or digit")
+	}
+	return nil
+}
+
+// Node is an enrolled agent as the controller records it.
+type Node struct {
+	Name       string    `json:"name"`
+	Serial     string    `json:"serial"` // of the agent's certificate
+	Address    string    `json:"address,omitempty"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+	LastSeen   time.Time `json:"last_seen,omitempty"`
+}
+
+// Token is a single-use enrollment token. Only the hash of its secret is
+// stored.
+type Token struct {
+	ID         string    `json:"id"`
+	SecretHash string    `json:"secret_hash"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Revocation marks an agent certificate as revoked.
+type Revocation struct {
+	Name      string    `json:"name"`
+	Serial    string    `json:"serial"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// Command is a dnstm command queued for an agent and its result.
+type Command struct {
+	ID       string    `json:"id"`
+	Args     []string  `json:"args"`
+	Status   string    `json:"status"`
+	QueuedAt time.Time `json:"queued_at"`
+	SentAt   time.Time `json:"sent_at,omitempty"`
+	DoneAt   time.Time `json:"done_at,omitempty"`
+	ExitCode int       `json:"exit_code"`
+	Output   string    `json:"output,omitempty"`
+}
+
+// Registry reads and writes the controller state in a directory. Each
+// record is its own file, so root commands and the API server never
+// overwrite each other's changes.
+type Registry struct {
+	Dir string
+	// Chown, when set, is applied to files and directories the registry
+	// creates, so state written by root stays usable by the API server.
+	Chown func(path string) error
+}
+
+// CreateToken stores a new enrollment token and returns it in the form
+// agents enroll with: id.secret.ca-hash.
+func (r Registry) CreateToken(caHash string, ttl time.Duration, now time.Time) (string, *Token, error) {
+	id, secret := randomHex(4), randomHex(16)
+	tok := &Token{ID: id, SecretHash: hashSecret(secret), CreatedAt: now.UTC(), ExpiresAt: now.Add(ttl).UTC()}
+	if err := r.write(filepath.Join("tokens", id+".json"), tok); err != nil {
+		return "", nil, err
+	}
+	return id + "." + secret + "." + caHash, tok, nil
+}
+
+// ParseToken splits an enrollment token into its parts.
+func ParseToken(token string) (id, secret, caHash string, err error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 || !isHex(parts[0], 8) || !isHex(parts[1], 32) || !isHex(parts[2], 64) {
+		return "", "", "", fmt.Errorf("malformed enrollment token")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// RedeemToken checks a token and deletes it, so it enrolls one agent only.
+func (r Registry) RedeemToken(token string, now time.Time) error {
+	id, secret, _, err := ParseToken(token)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(r.Dir, "tokens", id+".json")
+	var tok Token
+	if err := readJSON(path, &tok); err != nil {
+		return fmt.Errorf("unknown enrollment token")
+	}
+	if subtle.ConstantTimeCompare([]byte(tok.SecretHash), []byte(hashSecret(secret))) != 1 {
+		return fmt.Errorf("unknown enrollment token")
+	}
+	// Whoever removes the file redeems it
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("unknown enrollment token")
+	}
+	if now.After(tok.ExpiresAt) {
+		return fmt.Errorf("enrollment token expired at %s", tok.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Enroll records an agent with the serial of its new certificate. A name
+// may be reused once the agent holding it was revoked.
+func (r Registry) Enroll(name, serial, address string, now time.Time) error {
+	if existing, err := r.Node(name); err == nil && !r.IsRevoked(existing.Serial) {
+		return fmt.Errorf("agent '%s' is already enrolled; revoke it first", name)
+	}
+	return r.SaveNode(&Node{Name: name, Serial: serial, Address: address, EnrolledAt: now.UTC(), LastSeen: now.UTC()})
+}
+
+// Node reads an enrolled agent.
+func (r Registry) Node(name string) (*Node, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	var n Node
+	if err := readJSON(filepath.Join(r.Dir, "nodes", name+".json"), &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// SaveNode writes an agent record back.
+func (r Registry) SaveNode(n *Node) error {
+	return r.write(filepath.Join("nodes", n.Name+".json"), n)
+}
+
+// Nodes returns all enrolled agents by name, including revoked ones.
+func (r Registry) Nodes() ([]*Node, error) {
+	entries, err := os.ReadDir(filepath.Join(r.Dir, "nodes"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*Node
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		n, err := r.Node(name)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+// Revoke revokes the certificate of an agent. The controller rejects it
+// from then on and the agent stops itself when it is told.
+func (r Registry) Revoke(name string, now time.Time) error {
+	n, err := r.Node(name)
+	if err != nil {
+		return fmt.Errorf("agent '%s' is not enrolled", name)
+	}
+	if r.IsRevoked(n.Serial) {
+		return fmt.Errorf("agent '%s' is already revoked", name)
+	}
+	return r.write(filepath.Join("revoked", n.Serial+".json"), &Revocation{Name: name, Serial: n.Serial, RevokedAt: now.UTC()})
+}
+
+// IsRevoked reports whether the certificate with serial was revoked.
+func (r Registry) IsRevoked(serial string) bool {
+	if !isHex(serial, -1) {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(r.Dir, "revoked", serial+".json"))
+	return err == nil
+}
+
+// QueueCommand queues a dnstm command for an agent.
+func (r Registry) QueueCommand(name string, args []string, now time.Time) (*Command, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	cmd := &Command{ID: now.UTC().Format("20060102T150405") + "-" + randomHex(4), Args: args, Status: CommandQueued, QueuedAt: now.UTC()}
+	if err := r.saveCommand(name, cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// NextCommand returns the oldest queued command of an agent and marks it
+// sent, or nil when none is queued.
+func (r Registry) NextCommand(name string, now time.Time) (*Command, error) {
+	entries, err := os.ReadDir(filepath.Join(r.Dir, "commands", name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// IDs start with the queue time, so directory order is queue order
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		cmd, err := r.Command(name, id)
+		if err != nil {
+			return nil, err
+		}
+		if cmd.Status != CommandQueued {
+			continue
+		}
+		cmd.Status, cmd.SentAt = CommandSent, now.UTC()
+		if err := r.saveCommand(name, cmd); err != nil {
+			return nil, err
+		}
+		return cmd, nil
+	}
+	return nil, nil
+}
+
+// CompleteCommand records the result of a command an agent ran.
+func (r Registry) CompleteCommand(name, id string, exitCode int, output string, now time.Time) error {
+	cmd, err := r.Command(name, id)
+	if err != nil {
+		return err
+	}
+	cmd.Status, cmd.ExitCode, cmd.Output, cmd.DoneAt = CommandDone, exitCode, output, now.UTC()
+	return r.saveCommand(name, cmd)
+}
+
+// Command reads a queued or finished command.
+func (r Registry) Command(name, id string) (*Command, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid command id")
+	}
+	var cmd Command
+	if err := readJSON(filepath.Join(r.Dir, "commands", name, id+".json"), &cmd); err != nil {
+		return nil, err
+	}
+	return &cmd, nil
+}
+
+func (r Registry) saveCommand(name string, cmd *Command) error {
+	return r.write(filepath.Join("commands", name, cmd.ID+".json"), cmd)
+}
+
+// write stores v as JSON at rel below the registry, replacing the file
+// atomically and creating missing directories.
+func (r Registry) write(rel string, v any) error {
+	path := filepath.Join(r.Dir, rel)
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		if r.Chown != nil {
+			for d := dir; d != filepath.Dir(r.Dir) && d != "."; d = filepath.Dir(d) {
+				if err := r.Chown(d); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if r.Chown != nil {
+		if err := r.Chown(tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, path)
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// isHex reports whether s is lowercase hex of length n, or of any length
+// when n is negative.
+func isHex(s string, n int) bool {
+	if s == "" || (n >= 0 && len(s) != n) {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
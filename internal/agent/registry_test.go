@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"vps2":                  true,
+		"eu-1":                  true,
+		"":                      false,
+		"-a":                    false,
+		"VPS":                   false,
+		"a.b":                   false,
+		"../x":                  false,
+		strings.Repeat("a", 64): false,
+	} {
+		if err := ValidateName(name); (err == nil) != valid {
+			t.Errorf("ValidateName(%q) error = %v, want valid %v", name, err, valid)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	r := Registry{Dir: t.TempDir()}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	caHash := strings.Repeat("ab", 32)
+
+	token, _, err := r.CreateToken(caHash, time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, hash, err := ParseToken(token); err != nil || hash != caHash {
+		t.Errorf("ParseToken() = %q, %v; want CA hash %q", hash, err, caHash)
+	}
+	id, _, _, _ := ParseToken(token)
+	forged := id + "." + strings.Repeat("0", 32) + "." + caHash
+	if err := r.RedeemToken(forged, now); err == nil {
+		t.Error("RedeemToken() accepted a wrong secret")
+	}
+	if err := r.RedeemToken(token, now); err != nil {
+		t.Fatalf("RedeemToken() error = %v", err)
+	}
+	if err := r.RedeemToken(token, now); err == nil {
+		t.Error("RedeemToken() accepted a token twice")
+	}
+
+	expired, _, _ := r.CreateToken(caHash, time.Hour, now)
+	if err := r.RedeemToken(expired, now.Add(2*time.Hour)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("RedeemToken() of an expired token error = %v", err)
+	}
+
+	if _, _, _, err := ParseToken("abc"); err == nil {
+		t.Error("ParseToken() accepted a malformed token")
+	}
+}
+
+func TestEnrollRevoke(t *testing.T) {
+	r := Registry{Dir: t.TempDir()}
+	now := time.Now()
+
+	if err := r.Enroll("vps2", "a1", "192.0.2.1", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Enroll("vps2", "b2", "192.0.2.1", now); err == nil {
+		t.Error("Enroll() replaced an enrolled agent")
+	}
+	if err := r.Revoke("vps2", now); err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsRevoked("a1") {
+		t.Error("IsRevoked() = false after Revoke()")
+	}
+	if err := r.Revoke("vps2", now); err == nil {
+		t.Error("Revoke() twice succeeded")
+	}
+	if err := r.Enroll("vps2", "b2", "192.0.2.1", now); err != nil {
+		t.Errorf("Enroll() after revocation error = %v", err)
+	}
+	if r.IsRevoked("b2") {
+		t.Error("new certificate is revoked")
+	}
+	if err := r.Revoke("nobody", now); err == nil {
+		t.Error("Revoke() of an unknown agent succeeded")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	r := Registry{Dir: t.TempDir()}
+	now := time.Now()
+
+	if cmd, err := r.NextCommand("vps2", now); cmd != nil || err != nil {
+		t.Fatalf("NextCommand() on an empty queue = %v, %v", cmd, err)
+	}
+	first, _ := r.QueueCommand("vps2", []string{"tunnel", "list"}, now)
+	second, _ := r.QueueCommand("vps2", []string{"router", "status"}, now.Add(time.Second))
+
+	for _, want := range []*Command{first, second} {
+		cmd, err := r.NextCommand("vps2", now)
+		if err != nil || cmd == nil || cmd.ID != want.ID || cmd.Status != CommandSent {
+			t.Fatalf("NextCommand() = %+v, %v; want %s sent", cmd, err, want.ID)
+		}
+	}
+	if cmd, _ := r.NextCommand("vps2", now); cmd != nil {
+		t.Errorf("NextCommand() returned %s again", cmd.ID)
+	}
+
+	if err := r.CompleteCommand("vps2", first.ID, 0, "ok", now); err != nil {
+		t.Fatal(err)
+	}
+	if cmd, _ := r.Command("vps2", first.ID); cmd.Status != CommandDone || cmd.Output != "ok" {
+		t.Errorf("Command() = %+v, want done with output", cmd)
+	}
+	if err := r.CompleteCommand("vps2", "nope", 0, "", now); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("CompleteCommand() of an unknown command error = %v", err)
+	}
+	if _, err := r.Command("vps2", "../../nodes/vps2"); err == nil {
+		t.Error("Command() accepted a path")
+	}
+}
@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// ServiceName is the service that keeps the control channel open.
+var ServiceName = paths.SystemService("agent")
+
+// InstallService writes and starts the agent service. It runs as root, as
+// the commands the controller sends change tunnels and services.
+func InstallService() error {
+	if err := service.CreateGenericService(&service.ServiceConfig{
+		Name:         ServiceName,
+		Description:  "dnstm fleet agent",
+		ExecStart:    cmdline.Join(paths.Bin("dnstm"), "agent-run"),
+		RestartLimit: -1,
+	}); err != nil {
+		return err
+	}
+	if service.IsServiceActive(ServiceName) {
+		return service.RestartService(ServiceName)
+	}
+	if err := service.EnableService(ServiceName); err != nil {
+		return err
+	}
+	return service.StartService(ServiceName)
+}
+
+// IsActive reports whether the agent service is running.
+func IsActive() bool {
+	return service.IsServiceActive(ServiceName)
+}
+
+// IsInstalled reports whether the agent service unit exists.
+func IsInstalled() bool {
+	return service.IsServiceInstalled(ServiceName)
+}
+
+// RemoveService stops the agent service and deletes its unit.
+func RemoveService() error {
+	if !IsInstalled() {
+		return nil
+	}
+	service.StopService(ServiceName)
+	service.DisableService(ServiceName)
+	return service.RemoveService(ServiceName)
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// pollWait is how long the control channel is held open when no command
+// is queued; agents poll again right after.
+const pollWait = 25 * time.Second
+
+// AgentHandler returns the routes served on the agent listener.
+func (s *Server) AgentHandler(cfg *config.Config) http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range Routes {
+		if rt.Agent && rt.enabled(cfg) {
+			mux.HandleFunc(rt.Method+" "+rt.Path, rt.handler(s, cfg))
+		}
+	}
+	return mux
+}
+
+// AgentTLSConfig returns the TLS config of the agent listener: a server
+// certificate from the agent CA, and client certificates verified against
+// it when given. Enrollment is the one route that works without one.
+func AgentTLSConfig(ca *agent.CA, listen string, now time.Time) (*tls.Config, error) {
+	host, _, _ := net.SplitHostPort(listen)
+	cert, err := ca.ServerCertificate(host, now)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func (s *Server) handleAgentEnroll(w http.ResponseWriter, r *http.Request) {
+	var req agent.EnrollRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if err := agent.ValidateName(req.Name); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if s.ca == nil {
+		writeError(w, http.StatusServiceUnavailable, "agent CA unavailable")
+		return
+	}
+	// The CSR is checked before the token is spent, and signed only once
+	// the token is redeemed
+	if err := agent.CheckCSR([]byte(req.CSR)); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := s.now()
+	if err := s.agents.RedeemToken(req.Token, now); err != nil {
+		log.Printf("agent %s: enrollment refused: %v", req.Name, err)
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	certPEM, serial, err := s.ca.SignAgent([]byte(req.CSR), req.Name, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := s.agents.Enroll(req.Name, serial, remoteHost(r), now); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	log.Printf("agent %s: enrolled from %s", req.Name, remoteHost(r))
+	writeJSON(w, http.StatusOK, agent.EnrollResponse{Certificate: string(certPEM), CA: string(s.ca.PEM)})
+}
+
+// authAgent returns the enrolled agent presenting a client certificate,
+// writing the error response itself when there is none or it was revoked.
+func (s *Server) authAgent(w http.ResponseWriter, r *http.Request) (*agent.Node, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		writeError(w, http.StatusUnauthorized, "agent certificate required")
+		return nil, false
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	serial := agent.Serial(leaf)
+	if s.agents.IsRevoked(serial) {
+		writeError(w, http.StatusForbidden, agent.ErrRevoked.Error())
+		return nil, false
+	}
+	node, err := s.agents.Node(leaf.Subject.CommonName)
+	if err != nil || node.Serial != serial {
+		writeError(w, http.StatusUnauthorized, "unknown agent")
+		return nil, false
+	}
+	return node, true
+}
+
+func (s *Server) handleAgentChannel(w http.ResponseWriter, r *http.Request) {
+	node, ok := s.authAgent(w, r)
+	if !ok {
+		return
+	}
+	node.LastSeen, node.Address = s.now().UTC(), remoteHost(r)
+	if err := s.agents.SaveNode(node); err != nil {
+		log.Printf("agent %s: %v", node.Name, err)
+	}
+
+	deadline := time.NewTimer(s.pollWait)
+	defer deadline.Stop()
+	tick := time.NewTicker(s.pollInterval)
+	defer tick.Stop()
+	for {
+		cmd, err := s.agents.NextCommand(node.Name, s.now())
+		if err != nil {
+			log.Printf("agent %s: %v", node.Name, err)
+			writeError(w, http.StatusInternalServerError, "failed to read commands")
+			return
+		}
+		if cmd != nil {
+			writeJSON(w, http.StatusOK, cmd)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *Server) handleAgentResult(w http.ResponseWriter, r *http.Request) {
+	node, ok := s.authAgent(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 2*maxAgentOutput))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "result too large")
+		return
+	}
+	var result agent.CommandResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.agents.CompleteCommand(node.Name, id, result.ExitCode, result.Output, s.now()); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, http.StatusNotFound, "unknown command")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxAgentOutput bounds the command output accepted from an agent.
+const maxAgentOutput = 256 << 10
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// agentController starts the agent listener of a controller on a test
+// TLS server.
+func agentController(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	cfg := testConfig()
+	cfg.API.Agents = &config.AgentsConfig{Listen: "127.0.0.1:8443"}
+	s := New(func() (*config.Config, error) { return cfg, nil })
+	dir := t.TempDir()
+	s.agents = agent.Registry{Dir: dir}
+	ca, err := agent.LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ca = ca
+	s.pollWait, s.pollInterval = 200*time.Millisecond, 10*time.Millisecond
+
+	srv := httptest.NewUnstartedServer(s.AgentHandler(cfg))
+	if srv.TLS, err = AgentTLSConfig(ca, "127.0.0.1:0", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func TestAgentLifecycle(t *testing.T) {
+	s, srv := agentController(t)
+	oldDir := agent.Dir
+	agent.Dir = t.TempDir()
+	t.Cleanup(func() { agent.Dir = oldDir })
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	token, _, err := s.agents.CreateToken(s.ca.Hash(), time.Hour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agent.Enroll(ctx, srv.URL, token, "vps2"); err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+	if _, err := agent.Enroll(ctx, srv.URL, token, "vps3"); err == nil {
+		t.Error("second Enroll() with the same token succeeded")
+	}
+
+	// Without a client certificate the channel is closed
+	insecure := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := insecure.Get(srv.URL + "/v1/agents/channel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("channel without certificate: status = %d, want 401", resp.StatusCode)
+	}
+
+	a, err := agent.New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	a.Run = func(_ context.Context, args []string) (int, string) {
+		return 3, strings.Join(args, " ")
+	}
+	served := make(chan error, 1)
+	go func() { served <- a.Serve(ctx) }()
+
+	cmd, err := s.agents.QueueCommand("vps2", []string{"tunnel", "list"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cmd.Status != agent.CommandDone {
+		if ctx.Err() != nil {
+			t.Fatalf("command not done, status %s", cmd.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if cmd, err = s.agents.Command("vps2", cmd.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cmd.ExitCode != 3 || cmd.Output != "tunnel list" {
+		t.Errorf("result = %d %q, want 3 %q", cmd.ExitCode, cmd.Output, "tunnel list")
+	}
+	if node, _ := s.agents.Node("vps2"); node.Address != "127.0.0.1" {
+		t.Errorf("node address = %q, want 127.0.0.1", node.Address)
+	}
+
+	if err := s.agents.Revoke("vps2", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; !errors.Is(err, agent.ErrRevoked) {
+		t.Fatalf("Serve() error = %v, want ErrRevoked", err)
+	}
+	if state, err := agent.LoadState(); err != nil || !state.Revoked {
+		t.Errorf("state after revocation = %+v, %v; want revoked", state, err)
+	}
+}
+
+func TestAgentEnroll_WrongCA(t *testing.T) {
+	s, srv := agentController(t)
+	oldDir := agent.Dir
+	agent.Dir = t.TempDir()
+	t.Cleanup(func() { agent.Dir = oldDir })
+
+	token, _, err := s.agents.CreateToken(strings.Repeat("0", 64), time.Hour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agent.Enroll(context.Background(), srv.URL, token, "vps2"); err == nil {
+		t.Fatal("Enroll() trusted a controller with another CA")
+	}
+	if nodes, _ := s.agents.Nodes(); len(nodes) != 0 {
+		t.Errorf("controller enrolled %d agents", len(nodes))
+	}
+}
+
+func TestAgentEnroll_BadTokenGetsNoCertificate(t *testing.T) {
+	s, srv := agentController(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "vps2"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(agent.EnrollRequest{
+		Token: strings.Repeat("a", 8) + "." + strings.Repeat("b", 32) + "." + s.ca.Hash(),
+		Name:  "vps2",
+		CSR:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})),
+	})
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Post(srv.URL+"/v1/agents/enroll", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out agent.EnrollResponse
+	json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if out.Certificate != "" {
+		t.Error("an unknown token got a signed certificate")
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JobsDir holds the provisioning jobs, one JSON file per job. The API server
+// queues jobs here and the provisioning timer, running as root, works them off.
+const JobsDir = "/var/lib/dnstm/api/jobs"
+
+// Job states.
+const (
+	JobQueued = "queued"
+	JobDone   = "done"
+	JobFailed = "failed"
+)
+
+// MaxCallbackAttempts is how often a callback is tried before giving up.
+const MaxCallbackAttempts = 5
+
+// Job is a provisioning request and its outcome.
+type Job struct {
+	Request   ProvisionRequest `json:"request"`
+	Status    string           `json:"status"`
+	Tag       string           `json:"tag,omitempty"` // chosen before the tunnel is created, so a retry finds it
+	Error     string           `json:"error,omitempty"`
+	Result    *ProvisionResult `json:"result,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+
+	// Callback delivery
+	Delivered bool   `json:"delivered,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// NeedsCallback reports whether a finished job still has a callback to send.
+func (j *Job) NeedsCallback() bool {
+	return j.Status != JobQueued && j.Request.CallbackURL != "" && !j.Delivered && j.Attempts < MaxCallbackAttempts
+}
+
+// JobStore reads and writes jobs in a directory.
+type JobStore struct {
+	Dir string
+	// Chown, when set, is applied to files Save writes, so jobs updated by
+	// root stay readable by the API server.
+	Chown func(path string) error
+}
+
+// path returns the file of a job. IDs are validated before they get here.
+func (s JobStore) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+// Enqueue stores a new queued job for req. If a job with the same ID exists
+// it is returned instead and created is false, so a retried webhook does
+// not provision twice.
+func (s JobStore) Enqueue(req ProvisionRequest, now time.Time) (job *Job, created bool, err error) {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return nil, false, err
+	}
+	job = &Job{Request: req, Status: JobQueued, CreatedAt: now.UTC(), UpdatedAt: now.UTC()}
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return nil, false, err
+	}
+	f, err := os.OpenFile(s.path(req.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		existing, err := s.Load(req.ID)
+		return existing, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	return job, true, f.Close()
+}
+
+// Load reads a job. It returns an error wrapping os.ErrNotExist for
+// unknown IDs.
+func (s JobStore) Load(id string) (*Job, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+// Save writes a job back, replacing the file atomically.
+func (s JobStore) Save(job *Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path(job.Request.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if s.Chown != nil {
+		if err := s.Chown(tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, s.path(job.Request.ID))
+}
+
+// List returns all jobs, oldest first.
+func (s JobStore) List() ([]*Job, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		job, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs, nil
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpecVersion is the version of the API contract in the OpenAPI document.
+// Bump it when a route or body changes; incompatible changes get new /vN
+// paths instead.
+const SpecVersion = "1.1.0"
+
+// pathParamPattern matches the {name} segments of a ServeMux pattern.
+var pathParamPattern = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// Spec returns the OpenAPI 3 document of Routes.
+func Spec() map[string]any {
+	g := &schemaGen{schemas: map[string]any{}, types: map[string]reflect.Type{}}
+	paths := map[string]any{}
+	for _, rt := range Routes {
+		item, ok := paths[rt.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = g.operation(rt)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "dnstm API",
+			"version":     SpecVersion,
+			"description": "API server of dnstm, run as the dnstm-api service. Routes are served only while enabled in the api section of the config.",
+		},
+		"tags": []any{
+			map[string]any{"name": "status", "description": "Public status page, unauthenticated"},
+			map[string]any{"name": "provisioning", "description": "Provisioning webhook, signed with the shared secret"},
+			map[string]any{"name": "agents", "description": "Fleet agents of a controller, over TLS on api.agents.listen"},
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"timestamp": map[string]any{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        TimestampHeader,
+					"description": "Unix time of the request; requests more than 5 minutes off are rejected",
+				},
+				"signature": map[string]any{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        SignatureHeader,
+					"description": `"sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>", keyed with api.webhook.secret; requests without a body sign "<timestamp>.<method> <path>"`,
+				},
+			},
+		},
+	}
+}
+
+// SpecJSON returns the OpenAPI document as indented JSON.
+func SpecJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(Spec(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// operation returns the OpenAPI operation of a route.
+func (g *schemaGen) operation(rt Route) map[string]any {
+	description := rt.Description
+	if rt.Mutual {
+		// OpenAPI 3.0 has no mutualTLS security scheme
+		description += " Needs the agent's client certificate (mutual TLS)."
+	}
+	op := map[string]any{
+		"operationId": operationID(rt),
+		"tags":        []any{rt.Tag},
+		"summary":     rt.Summary,
+		"description": description,
+	}
+
+	var params []any
+	for _, m := range pathParamPattern.FindAllStringSubmatch(rt.Path, -1) {
+		params = append(params, map[string]any{
+			"name":        m[1],
+			"in":          "path",
+			"required":    true,
+			"description": rt.Params[m[1]],
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+	if params != nil {
+		op["parameters"] = params
+	}
+
+	if rt.Request != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  g.jsonContent(rt.Request),
+		}
+	}
+
+	responses := map[string]any{}
+	for _, resp := range rt.Responses {
+		r := map[string]any{"description": resp.Description}
+		switch {
+		case resp.Body != nil:
+			r["content"] = g.jsonContent(resp.Body)
+		case resp.ContentType != "":
+			r["content"] = map[string]any{resp.ContentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+		}
+		responses[strconv.Itoa(resp.Code)] = r
+	}
+	op["responses"] = responses
+
+	if rt.Signed {
+		op["security"] = []any{map[string]any{"timestamp": []any{}, "signature": []any{}}}
+	}
+
+	if rt.Callback != nil {
+		op["callbacks"] = map[string]any{
+			"result": map[string]any{
+				"{$request.body#/callback_url}": map[string]any{
+					"post": map[string]any{
+						"description": "Posted once the job is done or failed, signed like requests. Retried up to " + strconv.Itoa(MaxCallbackAttempts) + " times until a 2xx response.",
+						"requestBody": map[string]any{"required": true, "content": g.jsonContent(rt.Callback)},
+						"responses":   map[string]any{"2XX": map[string]any{"description": "Received"}},
+					},
+				},
+			},
+		}
+	}
+	return op
+}
+
+// operationID derives an identifier from the method and path, e.g.
+// getV1ProvisionId for GET /v1/provision/{id}.
+func operationID(rt Route) string {
+	id := strings.ToLower(rt.Method)
+	for _, word := range strings.FieldsFunc(rt.Path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		id += strings.ToUpper(word[:1]) + word[1:]
+	}
+	return id
+}
+
+func (g *schemaGen) jsonContent(v any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(v))}}
+}
+
+// schemaGen derives JSON schemas from Go types through their json tags,
+// collecting structs as named components.
+type schemaGen struct {
+	schemas map[string]any
+	types   map[string]reflect.Type
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.ref(t)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	default:
+		return map[string]any{}
+	}
+}
+
+// ref registers a struct as a component and returns a reference to it.
+// Structs of the same name from different packages are told apart by
+// prefixing the package name.
+func (g *schemaGen) ref(t reflect.Type) map[string]any {
+	name := t.Name()
+	if other, ok := g.types[name]; ok && other != t {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	ref := map[string]any{"$ref": "#/components/schemas/" + name}
+	if _, ok := g.types[name]; ok {
+		return ref
+	}
+	g.types[name] = t
+
+	props := map[string]any{}
+	var required []any
+	g.fields(t, props, &required)
+	obj := map[string]any{"type": "object", "properties": props}
+	if required != nil {
+		obj["required"] = required
+	}
+	g.schemas[name] = obj
+	return ref
+}
+
+// fields adds the JSON fields of a struct, flattening embedded structs.
+// Fields without omitempty are required.
+func (g *schemaGen) fields(t reflect.Type, props map[string]any, required *[]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// TestSpecMatchesDocs keeps the shipped docs/openapi.json in sync with the
+// routes. Regenerate it with: go run . api spec -o docs/openapi.json
+func TestSpecMatchesDocs(t *testing.T) {
+	want, err := SpecJSON()
+	if err != nil {
+		t.Fatalf("SpecJSON: %v", err)
+	}
+	got, err := os.ReadFile("../../docs/openapi.json")
+	if err != nil {
+		t.Fatalf("read docs/openapi.json: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("docs/openapi.json is out of date; run: go run . api spec -o docs/openapi.json")
+	}
+}
+
+func TestSpec(t *testing.T) {
+	data, err := SpecJSON()
+	if err != nil {
+		t.Fatalf("SpecJSON: %v", err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string           `json:"operationId"`
+			Security    []map[string]any `json:"security"`
+			Parameters  []map[string]any `json:"parameters"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Required []string `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	op := doc.Paths["/v1/provision/{id}"]["get"]
+	if op.OperationID != "getV1ProvisionId" || len(op.Security) != 1 || len(op.Parameters) != 1 {
+		t.Errorf("GET /v1/provision/{id} = %+v", op)
+	}
+	if op := doc.Paths["/status.json"]["get"]; op.Security != nil {
+		t.Errorf("status is unauthenticated, got security %v", op.Security)
+	}
+
+	// Fields without omitempty are required
+	req := doc.Components.Schemas["ProvisionRequest"].Required
+	if len(req) != 3 || req[0] != "id" || req[1] != "domain" || req[2] != "transport" {
+		t.Errorf("ProvisionRequest required = %v", req)
+	}
+	if _, ok := doc.Components.Schemas["ClientConfig"]; !ok {
+		t.Error("nested ClientConfig schema missing")
+	}
+}
+
+// TestRoutesServed checks that every documented route is served when its
+// feature is enabled.
+func TestRoutesServed(t *testing.T) {
+	cfg := testConfig()
+	cfg.API.Webhook = &config.WebhookConfig{Secret: testSecret}
+	cfg.API.Agents = &config.AgentsConfig{Listen: "127.0.0.1:8443"}
+	s := New(func() (*config.Config, error) { return cfg, nil })
+	s.jobs = JobStore{Dir: t.TempDir()}
+	s.agents = agent.Registry{Dir: t.TempDir()}
+	h, agents := s.Handler(cfg), s.AgentHandler(cfg)
+
+	for _, rt := range Routes {
+		path := pathParamPattern.ReplaceAllString(rt.Path, "x")
+		rec := httptest.NewRecorder()
+		if rt.Agent {
+			agents.ServeHTTP(rec, httptest.NewRequest(rt.Method, path, nil))
+		} else {
+			h.ServeHTTP(rec, httptest.NewRequest(rt.Method, path, nil))
+		}
+		if rec.Code == http.StatusNotFound || rec.Code == http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: not served (%d)", rt.Method, rt.Path, rec.Code)
+		}
+	}
+}
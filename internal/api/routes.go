@@ -0,0 +1,173 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// Route is an endpoint of the API server. The routes serve requests and
+// also describe them, so the OpenAPI document cannot drift from the
+// handlers.
+type Route struct {
+	Method      string
+	Path        string // ServeMux pattern, e.g. /v1/provision/{id}
+	Tag         string // OpenAPI tag grouping related routes
+	Summary     string
+	Description string
+	Params      map[string]string // path parameter descriptions
+	Signed      bool              // needs the webhook signature headers
+	Agent       bool              // served on the agent listener (api.agents.listen)
+	Mutual      bool              // needs an agent certificate
+	Request     any               // JSON request body, nil for none
+	Callback    any               // body posted to the request's callback_url
+	Responses   []Response
+
+	enabled func(cfg *config.Config) bool
+	handler func(s *Server, cfg *config.Config) http.HandlerFunc
+}
+
+// Response is a documented response of a route.
+type Response struct {
+	Code        int
+	Description string
+	Body        any    // JSON body, nil for none
+	ContentType string // for non-JSON bodies
+}
+
+// ErrorResponse is the body of failed JSON requests.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+var (
+	errBadSignature = Response{http.StatusUnauthorized, "Missing, stale or invalid signature", ErrorResponse{}, ""}
+	errUnavailable  = Response{http.StatusServiceUnavailable, "The state could not be read", nil, "text/plain"}
+)
+
+func statusPageEnabled(cfg *config.Config) bool { return cfg.API.StatusPage }
+func webhookEnabled(cfg *config.Config) bool    { return cfg.API.Webhook != nil }
+func agentsEnabled(cfg *config.Config) bool     { return cfg.API.Agents != nil }
+
+// Routes lists every endpoint of the API server. Handler and AgentHandler
+// serve the ones enabled in the config.
+var Routes = []Route{
+	{
+		Method:      http.MethodGet,
+		Path:        "/status",
+		Tag:         "status",
+		Summary:     "Public status page",
+		Description: "HTML page listing each tunnel domain and whether it is up. Served while api.status_page is set.",
+		Responses: []Response{
+			{http.StatusOK, "The status page", nil, "text/html"},
+			errUnavailable,
+		},
+		enabled: statusPageEnabled,
+		handler: func(s *Server, _ *config.Config) http.HandlerFunc { return s.handleStatusPage },
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/status.json",
+		Tag:         "status",
+		Summary:     "Public status",
+		Description: "The data of the status page. Served while api.status_page is set.",
+		Responses: []Response{
+			{http.StatusOK, "Tunnel domains and whether they are up", Status{}, ""},
+			errUnavailable,
+		},
+		enabled: statusPageEnabled,
+		handler: func(s *Server, _ *config.Config) http.HandlerFunc { return s.handleStatusJSON },
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/v1/provision",
+		Tag:         "provisioning",
+		Summary:     "Queue a tunnel",
+		Description: "Queues a tunnel for a domain. It is created within a minute and the result posted to callback_url. Sending an id again returns the existing job. Served while api.webhook is set.",
+		Signed:      true,
+		Request:     ProvisionRequest{},
+		Callback:    Callback{},
+		Responses: []Response{
+			{http.StatusOK, "A job with this id exists", Callback{}, ""},
+			{http.StatusAccepted, "The job was queued", Callback{}, ""},
+			{http.StatusBadRequest, "The request is invalid", ErrorResponse{}, ""},
+			errBadSignature,
+			{http.StatusForbidden, "The admin policy forbids the tunnel", ErrorResponse{}, ""},
+			{http.StatusRequestEntityTooLarge, "The body is over 64 KiB", ErrorResponse{}, ""},
+		},
+		enabled: webhookEnabled,
+		handler: func(s *Server, cfg *config.Config) http.HandlerFunc { return s.handleProvision(cfg.API.Webhook.Secret) },
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/v1/provision/{id}",
+		Tag:         "provisioning",
+		Summary:     "Get a provisioning job",
+		Description: "Returns the state of a job, the same body its callback receives. Served while api.webhook is set.",
+		Params:      map[string]string{"id": "The id given when the job was queued"},
+		Signed:      true,
+		Responses: []Response{
+			{http.StatusOK, "The job", Callback{}, ""},
+			errBadSignature,
+			{http.StatusNotFound, "No job has this id", ErrorResponse{}, ""},
+		},
+		enabled: webhookEnabled,
+		handler: func(s *Server, cfg *config.Config) http.HandlerFunc {
+			return s.handleProvisionStatus(cfg.API.Webhook.Secret)
+		},
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/v1/agents/enroll",
+		Tag:         "agents",
+		Summary:     "Enroll an agent",
+		Description: "Signs the agent's certificate request with the controller CA, spending a one-time token from dnstm agent token. Served over TLS on api.agents.listen.",
+		Agent:       true,
+		Request:     agent.EnrollRequest{},
+		Responses: []Response{
+			{http.StatusOK, "The agent certificate and the CA", agent.EnrollResponse{}, ""},
+			{http.StatusBadRequest, "Invalid name or certificate request", ErrorResponse{}, ""},
+			{http.StatusUnauthorized, "Unknown, used or expired token", ErrorResponse{}, ""},
+			{http.StatusConflict, "An agent of this name is enrolled", ErrorResponse{}, ""},
+		},
+		enabled: agentsEnabled,
+		handler: func(s *Server, _ *config.Config) http.HandlerFunc { return s.handleAgentEnroll },
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/v1/agents/channel",
+		Tag:         "agents",
+		Summary:     "Wait for a command",
+		Description: "Control channel of an agent. Returns the next queued command, or 204 when none is queued within 25 seconds; agents poll again right away. Served over TLS on api.agents.listen.",
+		Agent:       true,
+		Mutual:      true,
+		Responses: []Response{
+			{http.StatusOK, "A dnstm command to run", agent.Command{}, ""},
+			{http.StatusNoContent, "No command was queued", nil, ""},
+			{http.StatusUnauthorized, "No or unknown agent certificate", ErrorResponse{}, ""},
+			{http.StatusForbidden, "The agent certificate was revoked", ErrorResponse{}, ""},
+		},
+		enabled: agentsEnabled,
+		handler: func(s *Server, _ *config.Config) http.HandlerFunc { return s.handleAgentChannel },
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/v1/agents/commands/{id}",
+		Tag:         "agents",
+		Summary:     "Report a command result",
+		Description: "Records the exit code and output of a command the agent ran. Served over TLS on api.agents.listen.",
+		Params:      map[string]string{"id": "The id of the command"},
+		Agent:       true,
+		Mutual:      true,
+		Request:     agent.CommandResult{},
+		Responses: []Response{
+			{http.StatusNoContent, "Recorded", nil, ""},
+			{http.StatusUnauthorized, "No or unknown agent certificate", ErrorResponse{}, ""},
+			{http.StatusForbidden, "The agent certificate was revoked", ErrorResponse{}, ""},
+			{http.StatusNotFound, "No command has this id", ErrorResponse{}, ""},
+		},
+		enabled: agentsEnabled,
+		handler: func(s *Server, _ *config.Config) http.HandlerFunc { return s.handleAgentResult },
+	},
+}
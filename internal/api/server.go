@@ -0,0 +1,181 @@
+// Package api implements the dnstm API server, an HTTP server run as the
+// dnstm-api service. It serves the public status page, the provisioning
+// webhook and, on a TLS listener of its own, the agents of a fleet.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// statusTTL is how long a collected status is served before the tunnels are
+// checked again, so a busy status page does not hammer the service manager.
+const statusTTL = 15 * time.Second
+
+// Server serves the API. It rereads the config when it refreshes the
+// status, so tunnels added or removed show up without a restart.
+type Server struct {
+	load   func() (*config.Config, error)
+	policy func() (*config.Policy, error)
+	isUp   func(*config.Config) func(*config.TunnelConfig) bool
+	now    func() time.Time
+	jobs   JobStore
+
+	agents       agent.Registry
+	ca           *agent.CA
+	pollWait     time.Duration
+	pollInterval time.Duration
+
+	mu     sync.Mutex
+	status *Status
+}
+
+// New returns a server that reads the config with load.
+func New(load func() (*config.Config, error)) *Server {
+	return &Server{
+		load:         load,
+		policy:       config.LoadPolicy,
+		isUp:         tunnelUp,
+		now:          time.Now,
+		jobs:         JobStore{Dir: JobsDir},
+		agents:       agent.Registry{Dir: agent.ControllerDir},
+		pollWait:     pollWait,
+		pollInterval: time.Second,
+	}
+}
+
+// Handler returns the routes enabled in cfg. Unauthenticated routes only
+// expose what the status page shows; webhook routes need a signature.
+func (s *Server) Handler(cfg *config.Config) http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range Routes {
+		if !rt.Agent && rt.enabled(cfg) {
+			mux.HandleFunc(rt.Method+" "+rt.Path, rt.handler(s, cfg))
+		}
+	}
+	return mux
+}
+
+// ListenAndServe runs the API server on the configured addresses until
+// one of them fails.
+func ListenAndServe(cfg *config.Config) error {
+	s := New(config.Load)
+	srv := &http.Server{
+		Addr:              cfg.API.Listen,
+		Handler:           s.Handler(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	errc := make(chan error, 2)
+	go func() {
+		log.Printf("API server listening on %s", cfg.API.Listen)
+		errc <- srv.ListenAndServe()
+	}()
+
+	if a := cfg.API.Agents; a != nil {
+		ca, err := agent.LoadOrCreateCA(agent.ControllerDir)
+		if err != nil {
+			return fmt.Errorf("failed to load agent CA: %w", err)
+		}
+		s.ca = ca
+		tlsConfig, err := AgentTLSConfig(ca, a.Listen, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to issue controller certificate: %w", err)
+		}
+		agentSrv := &http.Server{
+			Addr:              a.Listen,
+			Handler:           s.AgentHandler(cfg),
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			// Room for a held control channel poll
+			WriteTimeout: pollWait + 30*time.Second,
+			IdleTimeout:  2 * time.Minute,
+		}
+		go func() {
+			log.Printf("Agent listener on %s (CA sha256 %s)", a.Listen, ca.Hash())
+			errc <- agentSrv.ListenAndServeTLS("", "")
+		}()
+	}
+	return <-errc
+}
+
+// currentStatus returns the cached status, refreshing it once it is older
+// than statusTTL.
+func (s *Server) currentStatus() (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.status != nil && now.Sub(s.status.CheckedAt) < statusTTL {
+		return *s.status, nil
+	}
+	cfg, err := s.load()
+	if err != nil {
+		return Status{}, err
+	}
+	status := CollectStatus(cfg, s.isUp(cfg), now)
+	s.status = &status
+	return status, nil
+}
+
+func (s *Server) handleStatusJSON(w http.ResponseWriter, r *http.Request) {
+	status, err := s.currentStatus()
+	if err != nil {
+		log.Printf("status: %v", err)
+		http.Error(w, "status unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=15")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (s *Server) handleStatusPage(w http.ResponseWriter, r *http.Request) {
+	status, err := s.currentStatus()
+	if err != nil {
+		log.Printf("status: %v", err)
+		http.Error(w, "status unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "max-age=15")
+	if err := statusPage.Execute(w, status); err != nil {
+		log.Printf("status page: %v", err)
+	}
+}
+
+var statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>Service status</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: .5em; border-bottom: 1px solid #ddd; }
+.up { color: #1a7f37; } .down { color: #cf222e; }
+small { color: #666; }
+</style>
+</head>
+<body>
+<h1>Service status</h1>
+{{if .Instances}}<table>
+{{range .Instances}}<tr><td>{{.Domain}}</td><td class="{{if .Up}}up">&#9679; Up{{else}}down">&#9679; Down{{end}}</td></tr>
+{{end}}</table>{{else}}<p>No services are listed.</p>{{end}}
+<p><small>Checked {{.CheckedAt.Format "2006-01-02 15:04:05 UTC"}}</small></p>
+</body>
+</html>
+`))
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func testConfig() *config.Config {
+	off := false
+	cfg := config.Default()
+	cfg.Route.Mode = "multi"
+	cfg.API = config.APIConfig{Listen: config.DefaultAPIListen, StatusPage: true}
+	cfg.Tunnels = []config.TunnelConfig{
+		{Tag: "a", Transport: config.TransportDNSTT, Domain: "a.example.com", Port: 5310},
+		{Tag: "b", Transport: config.TransportSlipstream, Domain: "b.example.com", Port: 5311},
+		{Tag: "off", Transport: config.TransportDNSTT, Domain: "off.example.com", Port: 5312, Enabled: &off},
+		{Tag: config.RescueTag, Transport: config.TransportDNSTT, Domain: "r.example.com", Port: 5313, Rescue: true},
+	}
+	return cfg
+}
+
+func TestCollectStatus(t *testing.T) {
+	cfg := testConfig()
+	isUp := func(t *config.TunnelConfig) bool { return t.Tag == "a" }
+
+	status := CollectStatus(cfg, isUp, time.Now())
+	want := []InstanceStatus{{Domain: "a.example.com", Up: true}, {Domain: "b.example.com", Up: false}}
+	if len(status.Instances) != len(want) {
+		t.Fatalf("instances = %+v, want %+v", status.Instances, want)
+	}
+	for i := range want {
+		if status.Instances[i] != want[i] {
+			t.Errorf("instance %d = %+v, want %+v", i, status.Instances[i], want[i])
+		}
+	}
+
+	// Single mode only lists the active tunnel
+	cfg.Route.Mode = "single"
+	cfg.Route.Active = "b"
+	status = CollectStatus(cfg, isUp, time.Now())
+	if len(status.Instances) != 1 || status.Instances[0].Domain != "b.example.com" {
+		t.Errorf("single mode instances = %+v", status.Instances)
+	}
+}
+
+func TestStatusEndpoints(t *testing.T) {
+	cfg := testConfig()
+	loads := 0
+	s := New(func() (*config.Config, error) {
+		loads++
+		return cfg, nil
+	})
+	s.isUp = func(*config.Config) func(*config.TunnelConfig) bool {
+		return func(t *config.TunnelConfig) bool { return t.Tag == "a" }
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	h := s.Handler(cfg)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status.json", nil))
+	var status Status
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(status.Instances) != 2 || !status.Instances[0].Up {
+		t.Errorf("status = %+v", status)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	page := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(page, "a.example.com") || !strings.Contains(page, "Down") {
+		t.Errorf("status page = %d %s", rec.Code, page)
+	}
+	for _, secret := range []string{"r.example.com", "5310", "dnstt"} {
+		if strings.Contains(page, secret) {
+			t.Errorf("status page shows %q", secret)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("config loaded %d times, want 1 (cached)", loads)
+	}
+
+	now = now.Add(statusTTL)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/status.json", nil))
+	if loads != 2 {
+		t.Errorf("config loaded %d times after the TTL, want 2", loads)
+	}
+}
+
+func TestStatusPageDisabled(t *testing.T) {
+	cfg := testConfig()
+	cfg.API.StatusPage = false
+	rec := httptest.NewRecorder()
+	New(func() (*config.Config, error) { return cfg, nil }).Handler(cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("disabled status page: code = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+// ServiceName is the service the API server runs as.
+var ServiceName = paths.SystemService("api")
+
+// ProvisionTimerName is the timer that runs "dnstm api provision" to work
+// off queued provisioning jobs.
+var ProvisionTimerName = paths.SystemService("provision")
+
+// serviceConfig returns the unit of the API server. It runs as the dnstm
+// user, only reads the config and writes nothing but the job queue and the
+// agent registry.
+func serviceConfig(cfg *config.Config) *service.ServiceConfig {
+	return &service.ServiceConfig{
+		Name:             ServiceName,
+		Description:      "DNSTM API Server",
+		User:             system.DnstmUser,
+		Group:            system.DnstmUser,
+		ExecStart:        cmdline.Join(paths.Bin("dnstm"), "api-server"),
+		ReadOnlyPaths:    []string{paths.ConfigDir},
+		ReadWritePaths:   []string{JobsDir, agent.ControllerDir},
+		BindToPrivileged: cfg.API.Port() < 1024,
+	}
+}
+
+// CreateService writes the unit of the API server and creates the job
+// queue and agent registry it writes to, with the agent CA when agents
+// are enabled.
+func CreateService(cfg *config.Config) error {
+	// The directories must exist for ReadWritePaths
+	for _, dir := range []string{JobsDir, agent.ControllerDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		if err := system.ChownToDnstm(dir); err != nil {
+			return fmt.Errorf("failed to set ownership of %s: %w", dir, err)
+		}
+	}
+	if cfg.API.Agents != nil {
+		if _, err := agent.LoadOrCreateCA(agent.ControllerDir); err != nil {
+			return fmt.Errorf("failed to create agent CA: %w", err)
+		}
+		for _, file := range []string{"ca.pem", "ca-key.pem"} {
+			if err := system.ChownToDnstm(filepath.Join(agent.ControllerDir, file)); err != nil {
+				return fmt.Errorf("failed to set ownership of %s: %w", file, err)
+			}
+		}
+	}
+	return service.CreateGenericService(serviceConfig(cfg))
+}
+
+// SyncProvisionTimer installs the provisioning timer while the webhook is
+// enabled and removes it otherwise.
+func SyncProvisionTimer(cfg *config.Config) error {
+	if cfg.API.IsEnabled() && cfg.API.Webhook != nil {
+		return service.CreateTimer(&service.TimerConfig{
+			Name:        ProvisionTimerName,
+			Description: "dnstm webhook provisioning",
+			ExecStart:   cmdline.Join(paths.Bin("dnstm"), "api", "provision"),
+			OnCalendar:  "*:0/1",
+			OnBoot:      time.Minute,
+		})
+	}
+	if service.IsTimerInstalled(ProvisionTimerName) {
+		return service.RemoveTimer(ProvisionTimerName)
+	}
+	return nil
+}
+
+// Start starts the API server, or restarts it to pick up config changes.
+func Start() error {
+	if service.IsServiceActive(ServiceName) {
+		return service.RestartService(ServiceName)
+	}
+	if err := service.EnableService(ServiceName); err != nil {
+		return err
+	}
+	return service.StartService(ServiceName)
+}
+
+// IsActive reports whether the API server is running.
+func IsActive() bool {
+	return service.IsServiceActive(ServiceName)
+}
+
+// IsInstalled reports whether the API server unit exists.
+func IsInstalled() bool {
+	return service.IsServiceInstalled(ServiceName)
+}
+
+// Remove stops the API server and deletes its unit and the provisioning
+// timer. Queued jobs are kept.
+func Remove() error {
+	if service.IsTimerInstalled(ProvisionTimerName) {
+		service.RemoveTimer(ProvisionTimerName)
+	}
+	if !IsInstalled() {
+		return nil
+	}
+	service.StopService(ServiceName)
+	service.DisableService(ServiceName)
+	return service.RemoveService(ServiceName)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// InstanceStatus is the public state of one tunnel: its domain and whether
+// it is up. Nothing else about the tunnel is shown.
+type InstanceStatus struct {
+	Domain string `json:"domain"`
+	Up     bool   `json:"up"`
+}
+
+// Status is what the public status page shows.
+type Status struct {
+	Instances []InstanceStatus `json:"instances"`
+	CheckedAt time.Time        `json:"checked_at"`
+}
+
+// CollectStatus returns the state of the tunnels users connect to: enabled
+// tunnels that are in service in the current mode. The rescue tunnel is
+// never listed. isUp reports whether a tunnel is serving.
+func CollectStatus(cfg *config.Config, isUp func(*config.TunnelConfig) bool, now time.Time) Status {
+	status := Status{Instances: []InstanceStatus{}, CheckedAt: now.UTC().Truncate(time.Second)}
+	for i := range cfg.Tunnels {
+		t := &cfg.Tunnels[i]
+		if t.Rescue || !t.IsEnabled() || t.Domain == "" {
+			continue
+		}
+		if cfg.IsSingleMode() && !t.RunsAlongsideActive() && cfg.Route.Active != t.Tag {
+			continue
+		}
+		status.Instances = append(status.Instances, InstanceStatus{Domain: t.Domain, Up: isUp(t)})
+	}
+	return status
+}
+
+// tunnelUp reports whether a tunnel's service is running and, for DNS
+// tunnels in multi mode, the DNS router that forwards to it as well.
+func tunnelUp(cfg *config.Config) func(*config.TunnelConfig) bool {
+	routerUp := !cfg.IsMultiMode() || service.IsServiceActive(dnsrouter.ServiceName)
+	return func(t *config.TunnelConfig) bool {
+		if t.Transport.IsDNS() && !routerUp {
+			return false
+		}
+		return router.NewTunnel(t).IsActive()
+	}
+}
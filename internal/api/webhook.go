@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/clientcfg"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// Webhook headers. The signature is "sha256=" and the hex HMAC-SHA256 of
+// the timestamp, a dot and the signed content, keyed with the webhook
+// secret. See SignedContent.
+const (
+	SignatureHeader = "X-Dnstm-Signature"
+	TimestampHeader = "X-Dnstm-Timestamp"
+)
+
+// maxClockSkew is how far a request timestamp may be from the server's
+// clock; older requests are rejected so captured ones cannot be replayed.
+const maxClockSkew = 5 * time.Minute
+
+// maxWebhookBody bounds the size of a webhook request.
+const maxWebhookBody = 64 << 10
+
+// jobIDPattern matches the IDs callers give their requests, e.g. an order
+// number. IDs name files, so they are restricted.
+var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ProvisionRequest asks for a tunnel on a domain.
+type ProvisionRequest struct {
+	ID          string `json:"id"`                     // caller's idempotency key
+	Domain      string `json:"domain"`                 // delegated to this server
+	Transport   string `json:"transport"`              // slipstream, dnstt, vaydns or chisel
+	Backend     string `json:"backend,omitempty"`      // defaults to api.webhook.backend
+	Tag         string `json:"tag,omitempty"`          // generated when empty
+	CallbackURL string `json:"callback_url,omitempty"` // receives the result
+}
+
+// ProvisionResult is the connection bundle of a provisioned tunnel.
+type ProvisionResult struct {
+	Tag       string                  `json:"tag"`
+	Domain    string                  `json:"domain"`
+	Transport string                  `json:"transport"`
+	URL       string                  `json:"url"` // dnst:// share URL
+	Client    *clientcfg.ClientConfig `json:"client"`
+}
+
+// Callback is what the callback URL receives once a job finishes.
+type Callback struct {
+	ID     string           `json:"id"`
+	Status string           `json:"status"`
+	Error  string           `json:"error,omitempty"`
+	Result *ProvisionResult `json:"result,omitempty"`
+}
+
+// Validate checks a request before it is queued.
+func (r *ProvisionRequest) Validate() error {
+	if !jobIDPattern.MatchString(r.ID) {
+		return fmt.Errorf("id must be 1-64 letters, digits and ._- starting with a letter or digit")
+	}
+	if r.Domain == "" {
+		return fmt.Errorf("domain is required")
+	}
+	if !config.IsKnownTransport(config.TransportType(r.Transport)) {
+		return fmt.Errorf("unknown transport '%s'", r.Transport)
+	}
+	if r.CallbackURL != "" {
+		u, err := url.Parse(r.CallbackURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("callback_url must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// SignedContent returns what the signature of a request covers besides
+// the timestamp: its body, or for a request without one, such as a job
+// query, its method and path, so the signature names the job it reads.
+func SignedContent(method, path string, body []byte) []byte {
+	if len(body) > 0 {
+		return body
+	}
+	return []byte(method + " " + path)
+}
+
+// Sign returns the signature header value of a message.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a request.
+func Verify(secret string, h http.Header, body []byte, now time.Time) error {
+	ts := h.Get(TimestampHeader)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid %s", TimestampHeader)
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return fmt.Errorf("timestamp is more than %s off", maxClockSkew)
+	}
+	if !hmac.Equal([]byte(h.Get(SignatureHeader)), []byte(Sign(secret, ts, body))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// verified reads and authenticates a webhook request, writing the error
+// response itself when it fails.
+func (s *Server) verified(w http.ResponseWriter, r *http.Request, secret string) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "request too large")
+		return nil, false
+	}
+	if err := Verify(secret, r.Header, SignedContent(r.Method, r.URL.Path, body), s.now()); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+	return body, true
+}
+
+func (s *Server) handleProvision(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := s.verified(w, r, secret)
+		if !ok {
+			return
+		}
+		var req ProvisionRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		if err := req.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// Resending a known id is answered with its job whatever the policy
+		if _, err := s.jobs.Load(req.ID); errors.Is(err, os.ErrNotExist) {
+			if err := s.checkPolicy(&req); errors.Is(err, config.ErrPolicy) {
+				writeError(w, http.StatusForbidden, err.Error())
+				return
+			} else if err != nil {
+				log.Printf("provision %s: %v", req.ID, err)
+				writeError(w, http.StatusInternalServerError, "failed to check the admin policy")
+				return
+			}
+		}
+
+		job, created, err := s.jobs.Enqueue(req, s.now())
+		if err != nil {
+			log.Printf("provision %s: %v", req.ID, err)
+			writeError(w, http.StatusInternalServerError, "failed to queue the request")
+			return
+		}
+		code := http.StatusAccepted
+		if !created {
+			code = http.StatusOK
+		} else {
+			log.Printf("provision %s: queued %s tunnel for %s", req.ID, req.Transport, req.Domain)
+		}
+		writeJSON(w, code, jobCallback(job))
+	}
+}
+
+// checkPolicy checks a request against the admin policy, so forbidden
+// tunnels are refused up front rather than failing when provisioned.
+func (s *Server) checkPolicy(req *ProvisionRequest) error {
+	policy, err := s.policy()
+	if err != nil {
+		return err
+	}
+	cfg, err := s.load()
+	if err != nil {
+		return err
+	}
+	backend := req.Backend
+	if backend == "" && cfg.API.Webhook != nil {
+		backend = cfg.API.Webhook.GetBackend()
+	}
+	return policy.CheckTunnel(cfg, &config.TunnelConfig{Tag: req.Tag, Transport: config.TransportType(req.Transport), Backend: backend})
+}
+
+func (s *Server) handleProvisionStatus(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.verified(w, r, secret); !ok {
+			return
+		}
+		id := r.PathValue("id")
+		if !jobIDPattern.MatchString(id) {
+			writeError(w, http.StatusNotFound, "unknown id")
+			return
+		}
+		job, err := s.jobs.Load(id)
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, http.StatusNotFound, "unknown id")
+			return
+		}
+		if err != nil {
+			log.Printf("provision %s: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to read the job")
+			return
+		}
+		writeJSON(w, http.StatusOK, jobCallback(job))
+	}
+}
+
+// jobCallback returns the public state of a job.
+func jobCallback(job *Job) Callback {
+	return Callback{ID: job.Request.ID, Status: job.Status, Error: job.Error, Result: job.Result}
+}
+
+// SendCallback posts the state of a finished job to its callback URL,
+// signed like incoming requests.
+func SendCallback(ctx context.Context, client *http.Client, secret string, job *Job) error {
+	body, err := json.Marshal(jobCallback(job))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.Request.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, Sign(secret, ts, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("callback returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, ErrorResponse{Error: msg})
+}
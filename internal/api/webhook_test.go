@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+const testSecret = "0123456789abcdef"
+
+func webhookServer(t *testing.T) (*Server, http.Handler, time.Time) {
+	t.Helper()
+	cfg := testConfig()
+	cfg.API.Webhook = &config.WebhookConfig{Secret: testSecret}
+	s := New(func() (*config.Config, error) { return cfg, nil })
+	s.jobs = JobStore{Dir: t.TempDir()}
+	s.policy = func() (*config.Policy, error) { return &config.Policy{}, nil }
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, s.Handler(cfg), now
+}
+
+func signedRequest(method, target, body string, ts time.Time, secret string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	r.Header.Set(TimestampHeader, stamp)
+	r.Header.Set(SignatureHeader, Sign(secret, stamp, SignedContent(method, r.URL.Path, []byte(body))))
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1767268800, 0)
+	body := []byte(`{"id":"1"}`)
+	header := func(ts time.Time, sig string) http.Header {
+		h := http.Header{}
+		h.Set(TimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+		h.Set(SignatureHeader, sig)
+		return h
+	}
+	stamp := strconv.FormatInt(now.Unix(), 10)
+
+	tests := []struct {
+		name    string
+		h       http.Header
+		wantErr string
+	}{
+		{"valid", header(now, Sign(testSecret, stamp, body)), ""},
+		{"wrong secret", header(now, Sign("another-secret-key", stamp, body)), "invalid signature"},
+		{"stale", header(now.Add(-10*time.Minute), Sign(testSecret, strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10), body)), "off"},
+		{"no timestamp", http.Header{}, "missing or invalid"},
+	}
+	for _, tt := range tests {
+		err := Verify(testSecret, tt.h, body, now)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProvisionRequest_Validate(t *testing.T) {
+	tests := []struct {
+		req     ProvisionRequest
+		wantErr string
+	}{
+		{ProvisionRequest{ID: "order-1", Domain: "t.example.com", Transport: "dnstt"}, ""},
+		{ProvisionRequest{ID: "../x", Domain: "t.example.com", Transport: "dnstt"}, "id must be"},
+		{ProvisionRequest{ID: "1", Transport: "dnstt"}, "domain is required"},
+		{ProvisionRequest{ID: "1", Domain: "t.example.com", Transport: "wireguard"}, "unknown transport"},
+		{ProvisionRequest{ID: "1", Domain: "t.example.com", Transport: "dnstt", CallbackURL: "ftp://x"}, "callback_url"},
+	}
+	for _, tt := range tests {
+		err := tt.req.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error %v", tt.req, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%+v: error = %v, want %q", tt.req, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProvisionEndpoint(t *testing.T) {
+	s, h, now := webhookServer(t)
+	body := `{"id":"order-1","domain":"t.example.com","transport":"dnstt"}`
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(http.MethodPost, "/v1/provision", body, now, "another-secret-key"))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature: code = %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(http.MethodPost, "/v1/provision", body, now, testSecret))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("code = %d, want 202: %s", rec.Code, rec.Body)
+	}
+	var cb Callback
+	if err := json.NewDecoder(rec.Body).Decode(&cb); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if cb.ID != "order-1" || cb.Status != JobQueued {
+		t.Errorf("response = %+v", cb)
+	}
+
+	// A retried request returns the existing job instead of queueing another
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(http.MethodPost, "/v1/provision", body, now, testSecret))
+	if rec.Code != http.StatusOK {
+		t.Errorf("retry: code = %d, want 200", rec.Code)
+	}
+	if jobs, _ := s.jobs.List(); len(jobs) != 1 {
+		t.Errorf("jobs = %d, want 1", len(jobs))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(http.MethodGet, "/v1/provision/order-1", "", now, testSecret))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"queued"`) {
+		t.Errorf("status: %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(http.MethodGet, "/v1/provision/order-2", "", now, testSecret))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown id: code = %d, want 404", rec.Code)
+	}
+
+	// A status signature does not carry over to another job
+	r := signedRequest(http.MethodGet, "/v1/provision/order-2", "", now, testSecret)
+	r.URL.Path = "/v1/provision/order-1"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("replayed status query: code = %d, want 401", rec.Code)
+	}
+}
+
+func TestProvisionEndpoint_Policy(t *testing.T) {
+	s, h, now := webhookServer(t)
+	s.policy = func() (*config.Policy, error) {
+		return &config.Policy{Transports: []config.TransportType{config.TransportSlipstream}, MaxTunnels: 4}, nil
+	}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"id":"order-1","domain":"t.example.com","transport":"dnstt"}`, http.StatusForbidden},
+		{`{"id":"order-2","domain":"t.example.com","transport":"slipstream"}`, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, signedRequest(http.MethodPost, "/v1/provision", tt.body, now, testSecret))
+		if rec.Code != tt.want {
+			t.Errorf("%s: code = %d, want %d: %s", tt.body, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	// Three tunnels besides the rescue tunnel exist, so the limit is reached
+	s.policy = func() (*config.Policy, error) { return &config.Policy{MaxTunnels: 3}, nil }
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(http.MethodPost, "/v1/provision", `{"id":"order-3","domain":"t.example.com","transport":"dnstt"}`, now, testSecret))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("over limit: code = %d, want 403", rec.Code)
+	}
+	// A known id is still answered
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(http.MethodPost, "/v1/provision", tests[1].body, now, testSecret))
+	if rec.Code != http.StatusOK {
+		t.Errorf("known id over limit: code = %d, want 200", rec.Code)
+	}
+}
+
+func TestSendCallback(t *testing.T) {
+	var got Callback
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(testSecret, r.Header, body, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	job := &Job{
+		Request: ProvisionRequest{ID: "order-1", CallbackURL: srv.URL},
+		Status:  JobDone,
+		Result:  &ProvisionResult{Tag: "t1", URL: "dnst://x"},
+	}
+	if !job.NeedsCallback() {
+		t.Fatal("finished job should need a callback")
+	}
+	if err := SendCallback(context.Background(), srv.Client(), testSecret, job); err != nil {
+		t.Fatalf("SendCallback: %v", err)
+	}
+	if got.ID != "order-1" || got.Result == nil || got.Result.URL != "dnst://x" {
+		t.Errorf("callback = %+v", got)
+	}
+
+	if err := SendCallback(context.Background(), srv.Client(), "another-secret-key", job); err == nil {
+		t.Error("callback with the wrong secret should fail")
+	}
+}
@@ -0,0 +1,118 @@
+// Package bench measures latency and throughput through a tunnel and keeps
+// the results, so transports, MTU values and resolvers can be compared.
+//
+// A benchmark starts the transport's client binary on the server itself,
+// pointed at the tunnel either directly or through a recursive resolver,
+// and talks to the backend through it: new connections time the first
+// response (the SOCKS greeting or SSH banner), and a SOCKS backend carries
+// bulk transfers to a local sink in both directions. Each run is appended
+// to a history file per tunnel under Dir.
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dir holds one history file per tunnel.
+var Dir = "/var/lib/dnstm/bench"
+
+// Result is the outcome of one benchmark run.
+type Result struct {
+	Time      time.Time `json:"time"`
+	Tag       string    `json:"tag"`
+	Transport string    `json:"transport"`
+	MTU       int       `json:"mtu,omitempty"`
+	Resolver  string    `json:"resolver"`
+	Note      string    `json:"note,omitempty"`
+
+	// Latency of new connections through the tunnel
+	Probes int     `json:"probes"`
+	Lost   int     `json:"lost"`
+	RTTMin float64 `json:"rtt_min_ms,omitempty"`
+	RTTAvg float64 `json:"rtt_avg_ms,omitempty"`
+	RTTMax float64 `json:"rtt_max_ms,omitempty"`
+
+	// Bulk transfer, zero when the backend cannot carry one
+	Bytes    int64   `json:"bytes,omitempty"`
+	DownRate float64 `json:"down_bps,omitempty"` // bytes per second
+	UpRate   float64 `json:"up_bps,omitempty"`
+}
+
+func historyPath(tag string) string {
+	return filepath.Join(Dir, tag+".jsonl")
+}
+
+// Append adds a result to the history of its tunnel.
+func Append(r *Result) error {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create bench directory: %w", err)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(historyPath(r.Tag), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open bench history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write bench history: %w", err)
+	}
+	return nil
+}
+
+// History returns the recorded results of a tunnel, or of all tunnels when
+// tag is empty, oldest first.
+func History(tag string) ([]Result, error) {
+	files := []string{historyPath(tag)}
+	if tag == "" {
+		files, _ = filepath.Glob(filepath.Join(Dir, "*.jsonl"))
+	}
+
+	var results []Result
+	for _, path := range files {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bench history: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var r Result
+			if err := json.Unmarshal([]byte(line), &r); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			results = append(results, r)
+		}
+		f.Close()
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Time.Before(results[j].Time) })
+	return results, nil
+}
+
+// FormatRate formats a rate in bytes per second.
+func FormatRate(bps float64) string {
+	switch {
+	case bps <= 0:
+		return "-"
+	case bps >= 1<<20:
+		return fmt.Sprintf("%.1f MiB/s", bps/(1<<20))
+	default:
+		return fmt.Sprintf("%.1f KiB/s", bps/(1<<10))
+	}
+}
@@ -0,0 +1,152 @@
+package bench
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/clientcfg"
+)
+
+func TestAppendHistory(t *testing.T) {
+	orig := Dir
+	Dir = t.TempDir()
+	defer func() { Dir = orig }()
+
+	now := time.Now().UTC()
+	for i, r := range []Result{
+		{Time: now, Tag: "dnstt1", Transport: "dnstt", RTTAvg: 120},
+		{Time: now.Add(-time.Hour), Tag: "slip1", Transport: "slipstream", RTTAvg: 80},
+		{Time: now.Add(time.Hour), Tag: "dnstt1", Transport: "dnstt", MTU: 900, RTTAvg: 150},
+	} {
+		if err := Append(&r); err != nil {
+			t.Fatalf("Append(%d) error = %v", i, err)
+		}
+	}
+
+	got, err := History("dnstt1")
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(got) != 2 || got[1].MTU != 900 {
+		t.Errorf("History(dnstt1) = %+v", got)
+	}
+
+	all, err := History("")
+	if err != nil {
+		t.Fatalf("History(\"\") error = %v", err)
+	}
+	if len(all) != 3 || all[0].Tag != "slip1" {
+		t.Errorf("History(\"\") not sorted by time: %+v", all)
+	}
+
+	if got, err := History("missing"); err != nil || len(got) != 0 {
+		t.Errorf("History(missing) = %v, %v", got, err)
+	}
+}
+
+func TestClientCommand(t *testing.T) {
+	cc := &clientcfg.ClientConfig{}
+	cc.Transport.Type = "dnstt"
+	cc.Transport.Domain = "t.example.com"
+	cc.Transport.PubKey = "abcd"
+
+	bin, args, err := ClientCommand(cc, "8.8.8.8:53", 7000, "")
+	if err != nil {
+		t.Fatalf("ClientCommand() error = %v", err)
+	}
+	if bin != "dnstt-client" || strings.Join(args, " ") != "-udp 8.8.8.8:53 -pubkey abcd t.example.com 127.0.0.1:7000" {
+		t.Errorf("ClientCommand(dnstt) = %s %v", bin, args)
+	}
+
+	cc.Transport.Type = "slipstream"
+	_, args, _ = ClientCommand(cc, "127.0.0.1:5310", 7000, "/tmp/cert.pem")
+	if strings.Join(args, " ") != "--tcp-listen-port 7000 --resolver 127.0.0.1:5310 --domain t.example.com --cert /tmp/cert.pem" {
+		t.Errorf("ClientCommand(slipstream) = %v", args)
+	}
+
+	cc.Transport.Type = "chisel"
+	if _, _, err := ClientCommand(cc, "", 7000, ""); err == nil {
+		t.Error("ClientCommand(chisel) accepted")
+	}
+}
+
+// fakeSocks is a SOCKS5 proxy without authentication that connects to
+// IPv4 targets, standing in for a tunnel client in front of a SOCKS backend.
+func fakeSocks(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				greeting := make([]byte, 3)
+				if _, err := io.ReadFull(conn, greeting); err != nil {
+					return
+				}
+				conn.Write([]byte{0x05, 0x00})
+				req := make([]byte, 10)
+				if _, err := io.ReadFull(conn, req); err != nil {
+					return
+				}
+				target := net.JoinHostPort(net.IP(req[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(req[8:10]))))
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					conn.Write([]byte{0x05, 0x05, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+				conn.Write([]byte{0x05, 0x00, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestMeasure_SOCKS(t *testing.T) {
+	addr := fakeSocks(t)
+	result := &Result{}
+	warnings, err := Measure(addr, clientcfg.BackendConfig{Type: "socks"}, Options{Rounds: 3, Size: 64 << 10, Timeout: 2 * time.Second}, result)
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %v", warnings)
+	}
+	if result.Probes != 3 || result.Lost != 0 || result.RTTAvg < result.RTTMin || result.RTTMax < result.RTTAvg {
+		t.Errorf("latency = %+v", result)
+	}
+	if result.Bytes != 64<<10 || result.DownRate <= 0 || result.UpRate <= 0 {
+		t.Errorf("transfer = %+v", result)
+	}
+}
+
+func TestMeasure_NoAnswer(t *testing.T) {
+	// A listener that never answers stands in for a broken tunnel
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+
+	result := &Result{}
+	_, err = Measure(ln.Addr().String(), clientcfg.BackendConfig{Type: "ssh"}, Options{Rounds: 2, Timeout: 100 * time.Millisecond}, result)
+	if err == nil || result.Lost != 2 {
+		t.Errorf("Measure() = %v, lost %d, want failure", err, result.Lost)
+	}
+}
@@ -0,0 +1,113 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/clientcfg"
+)
+
+// ClientCommand returns the client binary and arguments that open the
+// tunnel described by cc through resolver and listen on 127.0.0.1:port.
+// certPath is the Slipstream certificate, or empty.
+func ClientCommand(cc *clientcfg.ClientConfig, resolver string, port int, certPath string) (binary.BinaryType, []string, error) {
+	listen := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	t := cc.Transport
+
+	switch t.Type {
+	case "dnstt":
+		return binary.BinaryDNSTTClient, []string{"-udp", resolver, "-pubkey", t.PubKey, t.Domain, listen}, nil
+
+	case "vaydns":
+		args := []string{"-udp", resolver, "-pubkey", t.PubKey}
+		if t.DnsttCompat {
+			args = append(args, "-dnstt-compat")
+		}
+		if t.ClientIDSize > 0 {
+			args = append(args, "-clientid-size", strconv.Itoa(t.ClientIDSize))
+		}
+		if t.RecordType != "" {
+			args = append(args, "-record-type", t.RecordType)
+		}
+		return binary.BinaryVayDNSClient, append(args, t.Domain, listen), nil
+
+	case "slipstream":
+		args := []string{"--tcp-listen-port", strconv.Itoa(port), "--resolver", resolver, "--domain", t.Domain}
+		if certPath != "" {
+			args = append(args, "--cert", certPath)
+		}
+		return binary.BinarySlipstreamClient, args, nil
+	}
+	return "", nil, fmt.Errorf("transport %s cannot be benchmarked", t.Type)
+}
+
+// FreePort returns a TCP port that is free on the loopback interface.
+func FreePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Client is a tunnel client started for a benchmark.
+type Client struct {
+	cmd    *exec.Cmd
+	addr   string
+	output bytes.Buffer
+	done   chan struct{}
+}
+
+// StartClient starts the client binary at path and waits up to timeout
+// for it to accept connections on 127.0.0.1:port.
+func StartClient(path string, args []string, port int, timeout time.Duration) (*Client, error) {
+	c := &Client{
+		cmd:  exec.Command(path, args...),
+		addr: net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		done: make(chan struct{}),
+	}
+	c.cmd.Stdout = &c.output
+	c.cmd.Stderr = &c.output
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", filepath.Base(path), err)
+	}
+	go func() {
+		c.cmd.Wait()
+		close(c.done)
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-c.done:
+			return nil, fmt.Errorf("%s exited: %s", filepath.Base(path), strings.TrimSpace(c.output.String()))
+		default:
+		}
+		if conn, err := net.DialTimeout("tcp", c.addr, time.Second); err == nil {
+			conn.Close()
+			return c, nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	c.Stop()
+	return nil, fmt.Errorf("%s did not listen on %s within %s", filepath.Base(path), c.addr, timeout)
+}
+
+// Addr returns the address the client accepts connections on.
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+// Stop stops the client.
+func (c *Client) Stop() {
+	c.cmd.Process.Kill()
+	<-c.done
+}
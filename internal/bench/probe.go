@@ -0,0 +1,266 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/net2share/dnstm/internal/clientcfg"
+)
+
+// Probe times one new connection through the tunnel at addr: the SOCKS
+// greeting for a SOCKS backend, the server banner for SSH.
+func Probe(addr, backend string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	start := time.Now()
+	switch backend {
+	case "socks":
+		if _, err := conn.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+			return 0, err
+		}
+		reply := make([]byte, 2)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return 0, err
+		}
+	case "ssh":
+		if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("%s backends do not answer a new connection", backend)
+	}
+	return time.Since(start), nil
+}
+
+// Sink serves bulk transfers for benchmarks on the loopback interface. A
+// connection sends 'D' or 'U' and a 64-bit length; for 'D' the sink sends
+// that many bytes, for 'U' it reads them and replies with one byte.
+type Sink struct {
+	ln net.Listener
+}
+
+// StartSink starts a sink on a free loopback port.
+func StartSink() (*Sink, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{ln: ln}
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address of the sink.
+func (s *Sink) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops the sink.
+func (s *Sink) Close() error {
+	return s.ln.Close()
+}
+
+func (s *Sink) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var req [9]byte
+			if _, err := io.ReadFull(conn, req[:]); err != nil {
+				return
+			}
+			n := int64(binary.BigEndian.Uint64(req[1:]))
+			switch req[0] {
+			case 'D':
+				io.CopyN(conn, zeroReader{}, n)
+			case 'U':
+				if _, err := io.CopyN(io.Discard, conn, n); err == nil {
+					conn.Write([]byte{1})
+				}
+			}
+		}()
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Transfer moves size bytes between the sink and this end through the SOCKS
+// proxy at proxyAddr, downloading when down is set, and returns the rate
+// in bytes per second.
+func Transfer(proxyAddr, user, password, sinkAddr string, size int64, down bool, timeout time.Duration) (float64, error) {
+	conn, err := net.DialTimeout("tcp", proxyAddr, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := socksConnect(conn, user, password, sinkAddr); err != nil {
+		return 0, err
+	}
+
+	req := make([]byte, 9)
+	req[0] = 'U'
+	if down {
+		req[0] = 'D'
+	}
+	binary.BigEndian.PutUint64(req[1:], uint64(size))
+
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	if down {
+		if _, err := io.CopyN(io.Discard, conn, size); err != nil {
+			return 0, err
+		}
+	} else {
+		if _, err := io.CopyN(conn, zeroReader{}, size); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+			return 0, err
+		}
+	}
+	return float64(size) / time.Since(start).Seconds(), nil
+}
+
+// socksConnect performs a SOCKS5 handshake, with username and password
+// authentication when user is set, and connects to target.
+func socksConnect(conn net.Conn, user, password, target string) error {
+	method := byte(0x00)
+	if user != "" {
+		method = 0x02
+	}
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != method {
+		return errors.New("SOCKS proxy rejected the authentication method")
+	}
+	if method == 0x02 {
+		auth := append([]byte{0x01, byte(len(user))}, user...)
+		auth = append(append(auth, byte(len(password))), password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return errors.New("SOCKS authentication failed")
+		}
+	}
+
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	port, _ := strconv.Atoi(portStr)
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return fmt.Errorf("target %s is not an IPv4 address", target)
+	}
+	req := append([]byte{0x05, 0x01, 0x00, 0x01}, ip...)
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	resp := make([]byte, 10)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[1] != 0x00 {
+		return fmt.Errorf("SOCKS connect to %s failed with code %d", target, resp[1])
+	}
+	return nil
+}
+
+// Options configure the measurements of a run.
+type Options struct {
+	Rounds  int           // latency probes
+	Size    int64         // bytes per transfer direction, 0 to skip transfers
+	Timeout time.Duration // per probe; transfers get ten times as long
+}
+
+// Measure probes the tunnel client at addr and, for a SOCKS backend,
+// transfers Size bytes each way to a local sink, filling in result.
+// Transfer failures are returned as warnings; it fails only when no probe
+// got an answer.
+func Measure(addr string, backend clientcfg.BackendConfig, opts Options, result *Result) ([]string, error) {
+	var total time.Duration
+	for i := 0; i < opts.Rounds; i++ {
+		result.Probes++
+		rtt, err := Probe(addr, backend.Type, opts.Timeout)
+		if err != nil {
+			result.Lost++
+			continue
+		}
+		ms := float64(rtt.Microseconds()) / 1000
+		if result.RTTMin == 0 || ms < result.RTTMin {
+			result.RTTMin = ms
+		}
+		if ms > result.RTTMax {
+			result.RTTMax = ms
+		}
+		total += rtt
+	}
+	if result.Lost == result.Probes {
+		return nil, fmt.Errorf("no answer through the tunnel in %d probes", result.Probes)
+	}
+	result.RTTAvg = float64((total / time.Duration(result.Probes-result.Lost)).Microseconds()) / 1000
+
+	if opts.Size == 0 {
+		return nil, nil
+	}
+	if backend.Type != "socks" {
+		return []string{fmt.Sprintf("bulk transfer skipped: needs a SOCKS backend, not %s", backend.Type)}, nil
+	}
+
+	sink, err := StartSink()
+	if err != nil {
+		return []string{fmt.Sprintf("bulk transfer skipped: %v", err)}, nil
+	}
+	defer sink.Close()
+
+	var warnings []string
+	for _, down := range []bool{true, false} {
+		rate, err := Transfer(addr, backend.User, backend.Password, sink.Addr(), opts.Size, down, 10*opts.Timeout)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("bulk transfer failed: %v", err))
+			break
+		}
+		if down {
+			result.DownRate = rate
+		} else {
+			result.UpRate = rate
+		}
+	}
+	if result.DownRate > 0 {
+		result.Bytes = opts.Size
+	}
+	return warnings, nil
+}
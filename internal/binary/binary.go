@@ -2,6 +2,8 @@
 package binary
 
 import (
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/net2share/dnstm/internal/log"
+	"github.com/net2share/dnstm/internal/paths"
 	"github.com/net2share/go-corelib/binman"
 )
 
@@ -24,6 +27,7 @@ const (
 	BinaryMicrosocks       BinaryType = "microsocks"
 	BinarySSHTunUser       BinaryType = "sshtun-user"
 	BinaryVayDNSServer     BinaryType = "vaydns-server"
+	BinaryChiselServer     BinaryType = "chisel"
 
 	// Client binaries (used in testing)
 	BinaryDNSTTClient      BinaryType = "dnstt-client"
@@ -39,6 +43,7 @@ type BinaryDef struct {
 	URLPattern    string              // Download URL pattern with {version}, {os}, {arch} placeholders
 	PinnedVersion string              // Expected version for this dnstm release
 	Archive       bool                // If true, URL points to a tar.xz archive
+	Gzip          bool                // If true, URL points to a gzip-compressed binary
 	ArchiveDir    string              // Directory inside archive where binary is located
 	Platforms     map[string][]string // Supported os -> []arch
 	SkipUpdate    bool                // If true, skip in update process
@@ -48,13 +53,13 @@ type BinaryDef struct {
 	archMappings map[string]binman.ArchMapping
 }
 
-// Static arch mappings for shadowsocks-rust.
-var shadowsocksArchMappings = map[string]binman.ArchMapping{
-	"ssarch": {
-		"linux/amd64":  "x86_64-unknown-linux-gnu",
-		"linux/arm64":  "aarch64-unknown-linux-gnu",
-		"darwin/amd64": "x86_64-apple-darwin",
-		"darwin/arm64": "aarch64-apple-darwin",
+// Static arch mappings for chisel, which names ARMv7 builds "armv7".
+var chiselArchMappings = map[string]binman.ArchMapping{
+	"chiselarch": {
+		"linux/amd64": "amd64",
+		"linux/arm64": "arm64",
+		"linux/arm":   "armv7",
+		"linux/386":   "386",
 	},
 }
 
@@ -91,7 +96,7 @@ var DefaultBinaries = map[BinaryType]BinaryDef{
 		PinnedVersion: "v1.24.0",
 		Archive:       true,
 		Platforms: map[string][]string{
-			"linux":  {"amd64", "arm64"},
+			"linux":  {"amd64", "arm64", "arm", "386"},
 			"darwin": {"amd64", "arm64"},
 		},
 	},
@@ -126,6 +131,17 @@ var DefaultBinaries = map[BinaryType]BinaryDef{
 			"windows": {"amd64"},
 		},
 	},
+	BinaryChiselServer: {
+		Type:          BinaryChiselServer,
+		EnvVar:        "DNSTM_CHISEL_PATH",
+		URLPattern:    "https://github.com/jpillora/chisel/releases/download/v{version}/chisel_{version}_{os}_{chiselarch}.gz",
+		ChecksumURL:   "https://github.com/jpillora/chisel/releases/download/v{version}/chisel_{version}_checksums.txt",
+		PinnedVersion: "1.10.1",
+		Gzip:          true,
+		Platforms: map[string][]string{
+			"linux": {"amd64", "arm64", "arm", "386"},
+		},
+	},
 
 	// Client binaries - pinned versions for testing only
 	BinaryDNSTTClient: {
@@ -158,7 +174,7 @@ var DefaultBinaries = map[BinaryType]BinaryDef{
 		PinnedVersion: "v1.23.0",
 		Archive:       true,
 		Platforms: map[string][]string{
-			"linux":  {"amd64", "arm64"},
+			"linux":  {"amd64", "arm64", "arm", "386"},
 			"darwin": {"amd64", "arm64"},
 		},
 	},
@@ -177,19 +193,46 @@ var DefaultBinaries = map[BinaryType]BinaryDef{
 }
 
 func init() {
-	// Populate arch mappings for shadowsocks binaries (static).
+	// Populate arch mappings for shadowsocks binaries (runtime libc detection).
 	for _, bt := range []BinaryType{BinarySSServer, BinarySSLocal} {
 		def := DefaultBinaries[bt]
-		def.archMappings = shadowsocksArchMappings
+		def.archMappings = computeShadowsocksArchMappings(detectLibc())
 		DefaultBinaries[bt] = def
 	}
 
+	chiselDef := DefaultBinaries[BinaryChiselServer]
+	chiselDef.archMappings = chiselArchMappings
+	DefaultBinaries[BinaryChiselServer] = chiselDef
+
 	// Populate arch mappings for microsocks (runtime libc detection).
 	msDef := DefaultBinaries[BinaryMicrosocks]
 	msDef.archMappings = computeMicrosocksArchMappings()
 	DefaultBinaries[BinaryMicrosocks] = msDef
 }
 
+// computeShadowsocksArchMappings returns the shadowsocks-rust builds for
+// libc. Only musl builds exist for 386; on musl systems every Linux
+// architecture uses them.
+func computeShadowsocksArchMappings(libc string) map[string]binman.ArchMapping {
+	m := binman.ArchMapping{
+		"linux/amd64":  "x86_64-unknown-linux-gnu",
+		"linux/arm64":  "aarch64-unknown-linux-gnu",
+		"linux/arm":    "armv7-unknown-linux-gnueabihf",
+		"linux/386":    "i686-unknown-linux-musl",
+		"darwin/amd64": "x86_64-apple-darwin",
+		"darwin/arm64": "aarch64-apple-darwin",
+	}
+	if libc == "musl" {
+		m["linux/amd64"] = "x86_64-unknown-linux-musl"
+		m["linux/arm64"] = "aarch64-unknown-linux-musl"
+		m["linux/arm"] = "armv7-unknown-linux-musleabihf"
+	}
+
+	return map[string]binman.ArchMapping{
+		"ssarch": m,
+	}
+}
+
 // computeMicrosocksArchMappings detects libc at runtime and returns the appropriate mappings.
 func computeMicrosocksArchMappings() map[string]binman.ArchMapping {
 	libc := detectLibc()
@@ -225,6 +268,52 @@ func detectLibc() string {
 	return "glibc"
 }
 
+// UnsupportedError reports a binary with no release build for the running
+// platform.
+type UnsupportedError struct {
+	Binary BinaryType
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("binary %s not supported on %s/%s", e.Binary, runtime.GOOS, runtime.GOARCH)
+}
+
+// IsUnsupported reports whether err, or an error it wraps, is an
+// UnsupportedError.
+func IsUnsupported(err error) bool {
+	var u *UnsupportedError
+	return errors.As(err, &u)
+}
+
+// IsSupported reports whether a binary has a release build for the running
+// platform.
+func IsSupported(binType BinaryType) bool {
+	def, ok := DefaultBinaries[binType]
+	return ok && binman.NewManager("").IsPlatformSupported(toBinmanDef(def))
+}
+
+// envOverride returns the binary set in a definition's environment
+// variable, if it exists. It lets platforms without release downloads,
+// such as the BSDs, use locally built binaries.
+func envOverride(def BinaryDef) (string, bool) {
+	path := os.Getenv(def.EnvVar)
+	if path == "" {
+		return "", false
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// ExeName returns the file name of an executable, adding ".exe" on Windows.
+func ExeName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
 // toBinmanDef converts a local BinaryDef to a binman.BinaryDef.
 func toBinmanDef(def BinaryDef) binman.BinaryDef {
 	archiveType := ""
@@ -232,7 +321,7 @@ func toBinmanDef(def BinaryDef) binman.BinaryDef {
 		archiveType = "tar.xz"
 	}
 	return binman.BinaryDef{
-		Name:          string(def.Type),
+		Name:          ExeName(string(def.Type)),
 		EnvOverride:   def.EnvVar,
 		URLPattern:    def.URLPattern,
 		PinnedVersion: def.PinnedVersion,
@@ -244,12 +333,12 @@ func toBinmanDef(def BinaryDef) binman.BinaryDef {
 	}
 }
 
-const (
-	// DefaultInstallDir is the default directory for production binaries.
-	DefaultInstallDir = "/usr/local/bin"
-	// DefaultTestBinDir is the default directory for test binaries.
-	DefaultTestBinDir = "tests/.testbin"
-)
+// DefaultInstallDir is the directory for production binaries
+// (/usr/local/bin unless overridden; see package paths).
+var DefaultInstallDir = paths.BinDir
+
+// DefaultTestBinDir is the default directory for test binaries.
+const DefaultTestBinDir = "tests/.testbin"
 
 // Manager handles binary resolution and downloading.
 type Manager struct {
@@ -266,7 +355,7 @@ func NewManager(binDir string) *Manager {
 }
 
 // NewDefaultManager creates a binary manager that auto-detects the environment.
-// In test mode, uses tests/.testbin. In production, uses /usr/local/bin.
+// In test mode, uses tests/.testbin. In production, uses DefaultInstallDir.
 func NewDefaultManager() *Manager {
 	if isTestEnvironment() {
 		return NewManager(getTestBinDir())
@@ -306,9 +395,12 @@ func (m *Manager) GetPath(binType BinaryType) (string, error) {
 		return "", fmt.Errorf("unknown binary type: %s", binType)
 	}
 
+	if path, ok := envOverride(def); ok {
+		return path, nil
+	}
 	bd := toBinmanDef(def)
 	if !m.bm.IsPlatformSupported(bd) {
-		return "", fmt.Errorf("binary %s not supported on %s/%s", binType, runtime.GOOS, runtime.GOARCH)
+		return "", &UnsupportedError{Binary: binType}
 	}
 
 	return m.bm.ResolvePath(bd)
@@ -321,15 +413,23 @@ func (m *Manager) EnsureInstalled(binType BinaryType) (string, error) {
 		return "", fmt.Errorf("unknown binary type: %s", binType)
 	}
 
+	if path, ok := envOverride(def); ok {
+		return path, nil
+	}
 	bd := toBinmanDef(def)
 	if !m.bm.IsPlatformSupported(bd) {
-		return "", fmt.Errorf("binary %s not supported on %s/%s", binType, runtime.GOOS, runtime.GOARCH)
+		return "", &UnsupportedError{Binary: binType}
 	}
 
 	path, err := m.bm.EnsureInstalled(bd, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to install %s: %w", binType, err)
 	}
+	if def.Gzip {
+		if err := gunzipInPlace(path); err != nil {
+			return "", fmt.Errorf("failed to decompress %s: %w", binType, err)
+		}
+	}
 
 	log.Debug("binary %s: available at %s", binType, path)
 	return path, nil
@@ -344,10 +444,55 @@ func (m *Manager) DownloadVersion(binType BinaryType, version string) error {
 
 	bd := toBinmanDef(def)
 	if !m.bm.IsPlatformSupported(bd) {
-		return fmt.Errorf("binary %s not supported on %s/%s", binType, runtime.GOOS, runtime.GOARCH)
+		return &UnsupportedError{Binary: binType}
+	}
+
+	if err := m.bm.Download(bd, version, nil); err != nil {
+		return err
+	}
+	if def.Gzip {
+		return gunzipInPlace(filepath.Join(m.binDir, ExeName(string(binType))))
 	}
+	return nil
+}
+
+// gunzipInPlace replaces a gzip-compressed binary with its contents.
+// Binaries that are already decompressed are left alone.
+func gunzipInPlace(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	return m.bm.Download(bd, version, nil)
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(f, magic); err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	tmp := path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, zr); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
 }
 
 // EnsureDir creates the binary directory if it doesn't exist.
@@ -370,7 +515,7 @@ func GetDef(binType BinaryType) (BinaryDef, bool) {
 func ServerBinaries() []BinaryDef {
 	serverTypes := []BinaryType{
 		BinaryDNSTTServer, BinarySlipstreamServer, BinarySSServer,
-		BinaryMicrosocks, BinarySSHTunUser, BinaryVayDNSServer,
+		BinaryMicrosocks, BinarySSHTunUser, BinaryVayDNSServer, BinaryChiselServer,
 	}
 	var defs []BinaryDef
 	for _, bt := range serverTypes {
@@ -387,11 +532,7 @@ func (m *Manager) CopyToDir(srcPath string, binType BinaryType) (string, error)
 		return "", err
 	}
 
-	destName := string(binType)
-	if runtime.GOOS == "windows" {
-		destName += ".exe"
-	}
-	destPath := filepath.Join(m.binDir, destName)
+	destPath := filepath.Join(m.binDir, ExeName(string(binType)))
 
 	src, err := os.Open(srcPath)
 	if err != nil {
@@ -1,6 +1,9 @@
 package binary
 
 import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
 	"os"
 	"runtime"
 	"testing"
@@ -64,10 +67,16 @@ func TestArchMappings_Shadowsocks(t *testing.T) {
 	if !ok {
 		t.Fatal("SSServer should have ssarch mapping")
 	}
+	if got, want := ssarch["linux/amd64"], computeShadowsocksArchMappings(detectLibc())["ssarch"]["linux/amd64"]; got != want {
+		t.Errorf("ssarch[linux/amd64] = %s, want %s for the detected libc", got, want)
+	}
 
+	ssarch = computeShadowsocksArchMappings("glibc")["ssarch"]
 	expected := map[string]string{
 		"linux/amd64":  "x86_64-unknown-linux-gnu",
 		"linux/arm64":  "aarch64-unknown-linux-gnu",
+		"linux/arm":    "armv7-unknown-linux-gnueabihf",
+		"linux/386":    "i686-unknown-linux-musl",
 		"darwin/amd64": "x86_64-apple-darwin",
 		"darwin/arm64": "aarch64-apple-darwin",
 	}
@@ -79,6 +88,54 @@ func TestArchMappings_Shadowsocks(t *testing.T) {
 	}
 }
 
+func TestComputeShadowsocksArchMappings_Musl(t *testing.T) {
+	ssarch := computeShadowsocksArchMappings("musl")["ssarch"]
+
+	expected := map[string]string{
+		"linux/amd64":  "x86_64-unknown-linux-musl",
+		"linux/arm64":  "aarch64-unknown-linux-musl",
+		"linux/arm":    "armv7-unknown-linux-musleabihf",
+		"linux/386":    "i686-unknown-linux-musl",
+		"darwin/arm64": "aarch64-apple-darwin",
+	}
+
+	for platform, want := range expected {
+		if got := ssarch[platform]; got != want {
+			t.Errorf("ssarch[%s] = %s, want %s", platform, got, want)
+		}
+	}
+}
+
+func TestArchMappings_Chisel(t *testing.T) {
+	def := DefaultBinaries[BinaryChiselServer]
+	if got := def.archMappings["chiselarch"]["linux/arm"]; got != "armv7" {
+		t.Errorf("chiselarch[linux/arm] = %q, want armv7", got)
+	}
+	for _, arch := range []string{"amd64", "arm64", "arm", "386"} {
+		if _, ok := def.archMappings["chiselarch"]["linux/"+arch]; !ok {
+			t.Errorf("chisel supports linux/%s but has no chiselarch mapping", arch)
+		}
+	}
+}
+
+func TestUnsupportedError(t *testing.T) {
+	mgr := NewManager(t.TempDir())
+	def := DefaultBinaries[BinarySlipstreamServer]
+	DefaultBinaries[BinarySlipstreamServer] = BinaryDef{Type: BinarySlipstreamServer, Platforms: map[string][]string{"plan9": nil}}
+	defer func() { DefaultBinaries[BinarySlipstreamServer] = def }()
+
+	if IsSupported(BinarySlipstreamServer) {
+		t.Error("IsSupported() = true for a binary without a build for this platform")
+	}
+	_, err := mgr.GetPath(BinarySlipstreamServer)
+	if !IsUnsupported(fmt.Errorf("install: %w", err)) {
+		t.Errorf("GetPath() error = %v, want UnsupportedError", err)
+	}
+	if _, err := mgr.EnsureInstalled(BinarySlipstreamServer); !IsUnsupported(err) {
+		t.Errorf("EnsureInstalled() error = %v, want UnsupportedError", err)
+	}
+}
+
 func TestArchMappings_Microsocks(t *testing.T) {
 	def := DefaultBinaries[BinaryMicrosocks]
 	if def.archMappings == nil {
@@ -98,8 +155,8 @@ func TestArchMappings_Microsocks(t *testing.T) {
 
 func TestServerBinaries(t *testing.T) {
 	defs := ServerBinaries()
-	if len(defs) != 6 {
-		t.Errorf("ServerBinaries() returned %d, want 6", len(defs))
+	if len(defs) != 7 {
+		t.Errorf("ServerBinaries() returned %d, want 7", len(defs))
 	}
 
 	// Check VayDNS is included
@@ -115,6 +172,29 @@ func TestServerBinaries(t *testing.T) {
 	}
 }
 
+func TestGunzipInPlace(t *testing.T) {
+	path := t.TempDir() + "/chisel"
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("binary contents"))
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Second call must leave the decompressed binary alone
+	for i := 0; i < 2; i++ {
+		if err := gunzipInPlace(path); err != nil {
+			t.Fatalf("gunzipInPlace failed: %v", err)
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != "binary contents" {
+			t.Fatalf("call %d: got %q", i+1, data)
+		}
+	}
+}
+
 func TestChecksumURLs(t *testing.T) {
 	// Verify all server binaries except sshtun-user have checksum URLs
 	for _, def := range ServerBinaries() {
@@ -0,0 +1,183 @@
+// Package bootstrap generates unattended provisioning scripts.
+//
+// The output is either a shell script or a cloud-init user-data document
+// wrapping that script. On first boot it downloads dnstm, runs the install,
+// applies a config manifest or preset, and prints the client configs to the
+// console so they can be read from the provider's serial log.
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Output formats.
+const (
+	FormatCloudInit = "cloud-init"
+	FormatScript    = "script"
+)
+
+// ManifestPath is where the bootstrap script writes the embedded manifest.
+const ManifestPath = "/root/dnstm-manifest.json"
+
+// LogPath is where the bootstrap script logs its output.
+const LogPath = "/var/log/dnstm-bootstrap.log"
+
+// manifestDelimiter terminates the manifest heredoc.
+const manifestDelimiter = "DNSTM_MANIFEST_EOF"
+
+// Tunnel is a tunnel whose client config is printed after provisioning.
+type Tunnel struct {
+	Tag string
+	// SSH tunnels need client credentials to share, so only their status is printed.
+	SSH bool
+}
+
+// Options configures a bootstrap script.
+type Options struct {
+	Format string
+	// Version is the dnstm release tag to install; empty installs the latest release.
+	Version string
+	// Manifest is a config.json applied with "dnstm config load".
+	Manifest []byte
+	// Preset and Domain are passed to "dnstm install".
+	Preset string
+	Domain string
+	// Mode and SocksEngine are passed to "dnstm install" when set.
+	Mode        string
+	SocksEngine string
+	// Tunnels lists the tunnels to print client configs for.
+	Tunnels []Tunnel
+}
+
+// safeArg matches values that can be placed in the script without quoting.
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Generate renders the bootstrap script in the requested format.
+func Generate(opts Options) (string, error) {
+	if opts.Format == "" {
+		opts.Format = FormatCloudInit
+	}
+	if opts.Format != FormatCloudInit && opts.Format != FormatScript {
+		return "", fmt.Errorf("invalid format '%s' (must be '%s' or '%s')", opts.Format, FormatCloudInit, FormatScript)
+	}
+	if len(opts.Manifest) > 0 && opts.Preset != "" {
+		return "", fmt.Errorf("manifest and preset cannot be combined")
+	}
+	if bytes.Contains(opts.Manifest, []byte(manifestDelimiter)) {
+		return "", fmt.Errorf("manifest must not contain '%s'", manifestDelimiter)
+	}
+
+	named := map[string]string{
+		"version":      opts.Version,
+		"preset":       opts.Preset,
+		"domain":       opts.Domain,
+		"mode":         opts.Mode,
+		"socks-engine": opts.SocksEngine,
+	}
+	for name, value := range named {
+		if value != "" && !safeArg.MatchString(value) {
+			return "", fmt.Errorf("invalid %s '%s'", name, value)
+		}
+	}
+	for _, t := range opts.Tunnels {
+		if !safeArg.MatchString(t.Tag) {
+			return "", fmt.Errorf("invalid tunnel tag '%s'", t.Tag)
+		}
+	}
+
+	var b strings.Builder
+	if err := scriptTemplate.Execute(&b, templateData{
+		Options:   opts,
+		Manifest:  strings.TrimRight(string(opts.Manifest), "\n"),
+		Delimiter: manifestDelimiter,
+		Path:      ManifestPath,
+		Log:       LogPath,
+	}); err != nil {
+		return "", fmt.Errorf("failed to render script: %w", err)
+	}
+	script := b.String()
+
+	if opts.Format == FormatScript {
+		return script, nil
+	}
+	return cloudConfig(script), nil
+}
+
+// cloudConfig wraps a script in cloud-init user-data that writes and runs it.
+func cloudConfig(script string) string {
+	var b strings.Builder
+	b.WriteString("#cloud-config\n")
+	b.WriteString("write_files:\n")
+	b.WriteString("  - path: /root/dnstm-bootstrap.sh\n")
+	b.WriteString("    permissions: '0700'\n")
+	b.WriteString("    content: |\n")
+	for _, line := range strings.Split(strings.TrimRight(script, "\n"), "\n") {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString("      " + line + "\n")
+	}
+	b.WriteString("runcmd:\n")
+	b.WriteString("  - [bash, /root/dnstm-bootstrap.sh]\n")
+	return b.String()
+}
+
+type templateData struct {
+	Options
+	Manifest  string
+	Delimiter string
+	Path      string
+	Log       string
+}
+
+var scriptTemplate = template.Must(template.New("bootstrap").Parse(`#!/bin/bash
+# dnstm unattended bootstrap
+set -euo pipefail
+
+exec > >(tee -a {{.Log}} /dev/console) 2>&1
+
+REPO="net2share/dnstm"
+VERSION="{{.Version}}"
+
+case "$(uname -m)" in
+    x86_64) ARCH="amd64" ;;
+    aarch64|arm64) ARCH="arm64" ;;
+    armv7l|armv7) ARCH="armv7" ;;
+    i386|i686) ARCH="386" ;;
+    *) echo "dnstm bootstrap: unsupported architecture $(uname -m)"; exit 1 ;;
+esac
+
+if [ -z "$VERSION" ]; then
+    VERSION=$(curl -fsSL "https://api.github.com/repos/${REPO}/releases/latest" | grep '"tag_name"' | sed -E 's/.*"([^"]+)".*/\1/')
+fi
+
+echo "dnstm bootstrap: installing ${VERSION} (linux/${ARCH})"
+curl -fsSL "https://github.com/${REPO}/releases/download/${VERSION}/dnstm-linux-${ARCH}" -o /usr/local/bin/dnstm
+chmod +x /usr/local/bin/dnstm
+
+/usr/local/bin/dnstm install --force{{if .Mode}} --mode {{.Mode}}{{end}}{{if .SocksEngine}} --socks-engine {{.SocksEngine}}{{end}}{{if .Preset}} --preset {{.Preset}} --domain {{.Domain}}{{end}}
+{{- if .Manifest}}
+
+cat > {{.Path}} <<'{{.Delimiter}}'
+{{.Manifest}}
+{{.Delimiter}}
+chmod 600 {{.Path}}
+/usr/local/bin/dnstm config load {{.Path}}
+{{- end}}
+
+echo
+echo "===== dnstm client configs ====="
+{{- range .Tunnels}}
+{{- if .SSH}}
+/usr/local/bin/dnstm tunnel status -t {{.Tag}} || true
+{{- else}}
+/usr/local/bin/dnstm tunnel share -t {{.Tag}} || true
+{{- end}}
+{{- end}}
+echo "===== end dnstm client configs ====="
+`))
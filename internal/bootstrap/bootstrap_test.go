@@ -0,0 +1,93 @@
+package bootstrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate_ScriptWithManifest(t *testing.T) {
+	manifest := []byte("{\n  \"tunnels\": []\n}\n")
+	out, err := Generate(Options{
+		Format:   FormatScript,
+		Version:  "v1.2.3",
+		Manifest: manifest,
+		Tunnels: []Tunnel{
+			{Tag: "slip-socks"},
+			{Tag: "dnstt-ssh", SSH: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+
+	for _, want := range []string{
+		"#!/bin/bash\n",
+		`VERSION="v1.2.3"`,
+		"/usr/local/bin/dnstm install --force\n",
+		"cat > " + ManifestPath + " <<'" + manifestDelimiter + "'\n{\n  \"tunnels\": []\n}\n" + manifestDelimiter + "\n",
+		"/usr/local/bin/dnstm config load " + ManifestPath,
+		"/usr/local/bin/dnstm tunnel share -t slip-socks || true",
+		"/usr/local/bin/dnstm tunnel status -t dnstt-ssh || true",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("script missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestGenerate_Preset(t *testing.T) {
+	out, err := Generate(Options{
+		Format:      FormatScript,
+		Preset:      "ssh-basic",
+		Domain:      "example.com",
+		SocksEngine: "builtin",
+	})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	want := "/usr/local/bin/dnstm install --force --socks-engine builtin --preset ssh-basic --domain example.com\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("script missing %q\n%s", want, out)
+	}
+	if strings.Contains(out, "config load") {
+		t.Error("script should not load a manifest")
+	}
+}
+
+func TestGenerate_CloudInit(t *testing.T) {
+	out, err := Generate(Options{Tunnels: []Tunnel{{Tag: "t1"}}})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if !strings.HasPrefix(out, "#cloud-config\n") {
+		t.Errorf("missing cloud-config header:\n%s", out)
+	}
+	if !strings.Contains(out, "      #!/bin/bash\n") {
+		t.Errorf("script not indented under content:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "runcmd:\n  - [bash, /root/dnstm-bootstrap.sh]\n") {
+		t.Errorf("missing runcmd:\n%s", out)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"invalid format", Options{Format: "yaml"}},
+		{"manifest and preset", Options{Manifest: []byte("{}"), Preset: "ssh-basic", Domain: "example.com"}},
+		{"unsafe domain", Options{Preset: "ssh-basic", Domain: "example.com; rm -rf /"}},
+		{"unsafe version", Options{Version: "$(id)"}},
+		{"unsafe tag", Options{Tunnels: []Tunnel{{Tag: "a b"}}}},
+		{"delimiter in manifest", Options{Manifest: []byte(manifestDelimiter)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Generate(tt.opts); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
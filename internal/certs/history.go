@@ -0,0 +1,126 @@
+package certs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// HistoryFile records the certificate fingerprints and public keys that
+// clients were given, per domain. When a client reports a mismatch, it
+// tells whether the server rotated or the client sees something dnstm
+// never issued.
+var HistoryFile = filepath.Join(paths.ConfigDir, "key-history.json")
+
+// Kinds of history entries.
+const (
+	KindCert   = "cert"   // SHA-256 fingerprint of a TLS certificate (Slipstream, Chisel)
+	KindPubKey = "pubkey" // Curve25519 public key (DNSTT, VayDNS)
+)
+
+// HistoryEntry is a fingerprint or public key a domain served.
+type HistoryEntry struct {
+	Domain    string    `json:"domain"`
+	Tunnel    string    `json:"tunnel"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// NormalizeKeyValue returns a fingerprint or public key in the form the
+// history stores: lower-case hex without separators, so values copied from
+// FormatFingerprint output compare equal.
+func NormalizeKeyValue(v string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", " ", "", "-", "").Replace(strings.TrimSpace(v)))
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// LoadHistory returns every recorded entry, oldest first. A missing file
+// yields none.
+func LoadHistory() ([]HistoryEntry, error) {
+	data, err := os.ReadFile(HistoryFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key history: %w", err)
+	}
+	var entries []HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", HistoryFile, err)
+	}
+	return entries, nil
+}
+
+// History returns the entries of a domain, oldest first.
+func History(domain string) ([]HistoryEntry, error) {
+	all, err := LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+	domain = normalizeDomain(domain)
+	var entries []HistoryEntry
+	for _, e := range all {
+		if e.Domain == domain {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// Record notes that a domain serves a fingerprint or public key at now. The
+// latest entry of the domain and kind is extended when the value is the
+// same; otherwise a rotation happened and a new entry starts.
+func Record(domain, tunnel, kind, value string, now time.Time) error {
+	entries, err := LoadHistory()
+	if err != nil {
+		return err
+	}
+	domain, value = normalizeDomain(domain), NormalizeKeyValue(value)
+	now = now.UTC().Truncate(time.Second)
+
+	latest := -1
+	for i, e := range entries {
+		if e.Domain == domain && e.Kind == kind {
+			latest = i
+		}
+	}
+	if latest >= 0 && entries[latest].Value == value {
+		entries[latest].LastSeen = now
+		entries[latest].Tunnel = tunnel
+	} else {
+		entries = append(entries, HistoryEntry{
+			Domain:    domain,
+			Tunnel:    tunnel,
+			Kind:      kind,
+			Value:     value,
+			FirstSeen: now,
+			LastSeen:  now,
+		})
+	}
+	return saveHistory(entries)
+}
+
+func saveHistory(entries []HistoryEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(HistoryFile), 0755); err != nil {
+		return fmt.Errorf("failed to create key history directory: %w", err)
+	}
+	tmp := HistoryFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write key history: %w", err)
+	}
+	return os.Rename(tmp, HistoryFile)
+}
@@ -0,0 +1,74 @@
+package certs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	HistoryFile = filepath.Join(t.TempDir(), "key-history.json")
+
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 12, 0, 0, 0, time.UTC) }
+
+	// Missing file yields no history
+	entries, err := History("t.example.com")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no entries, got %d", len(entries))
+	}
+
+	if err := Record("T.Example.com.", "slip1", KindCert, "AB:CD:EF", day(1)); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	// Same value extends the entry
+	if err := Record("t.example.com", "slip1", KindCert, "abcdef", day(3)); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	// Rotation starts a new entry
+	if err := Record("t.example.com", "slip1", KindCert, "123456", day(5)); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	// Other domains are kept apart
+	if err := Record("d.example.com", "dnstt1", KindPubKey, "abcdef", day(2)); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	entries, err = History("t.example.com")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Value != "abcdef" || !entries[0].FirstSeen.Equal(day(1)) || !entries[0].LastSeen.Equal(day(3)) {
+		t.Errorf("first entry = %+v", entries[0])
+	}
+	if entries[1].Value != "123456" || !entries[1].FirstSeen.Equal(day(5)) {
+		t.Errorf("second entry = %+v", entries[1])
+	}
+
+	all, err := LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("expected 3 entries in total, got %d", len(all))
+	}
+}
+
+func TestNormalizeKeyValue(t *testing.T) {
+	tests := map[string]string{
+		"AB:CD:EF":   "abcdef",
+		" ab cd ef ": "abcdef",
+		"ab-cd-ef":   "abcdef",
+		"abcdef":     "abcdef",
+	}
+	for in, want := range tests {
+		if got := NormalizeKeyValue(in); got != want {
+			t.Errorf("NormalizeKeyValue(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
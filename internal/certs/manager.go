@@ -166,6 +166,11 @@ func ReadCertificateFingerprint(certPath string) (string, error) {
 		return "", err
 	}
 
+	return CertificateFingerprint(certPEM)
+}
+
+// CertificateFingerprint returns the SHA256 fingerprint of a PEM certificate.
+func CertificateFingerprint(certPEM []byte) (string, error) {
 	block, _ := pem.Decode(certPEM)
 	if block == nil {
 		return "", fmt.Errorf("failed to decode PEM block")
@@ -0,0 +1,160 @@
+package clientcfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// ExportsDir holds a client bundle per tunnel. dnstm rewrites a bundle
+// whenever a change alters what clients connect with, so the current
+// configs are at hand to redistribute.
+var ExportsDir = filepath.Join(paths.ConfigDir, "exports")
+
+// BundleDir returns the bundle directory of a tunnel.
+func BundleDir(tag string) string {
+	return filepath.Join(ExportsDir, tag)
+}
+
+// BundleChange is a client config of a bundle that is new or changed.
+type BundleChange struct {
+	Name   string   // "client", or the user of a multi-user tunnel
+	Path   string   // file with the dnst:// URL
+	New    bool     // the bundle had no such config before
+	Fields []string // changed fields, like "backend.password"
+}
+
+// WriteBundle regenerates the bundle of a tunnel: a .json file with the
+// client config and a .url file with its dnst:// URL, one pair per user for
+// multi-user Shadowsocks tunnels. SSH credentials are not stored in the
+// config, so SSH bundles leave them out. It returns the configs that are new
+// or changed.
+func WriteBundle(tunnel *config.TunnelConfig, backend *config.BackendConfig) ([]BundleChange, error) {
+	configs := make(map[string]*ClientConfig)
+	if tunnel.Slipstream != nil && len(tunnel.Slipstream.Users) > 0 {
+		for _, u := range tunnel.Slipstream.Users {
+			c, err := Generate(tunnel, backend, GenerateOptions{ShadowsocksUser: u.Name})
+			if err != nil {
+				return nil, err
+			}
+			c.Tag = tunnel.Tag + "-" + u.Name
+			configs[u.Name] = c
+		}
+	} else {
+		c, err := Generate(tunnel, backend, GenerateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		configs["client"] = c
+	}
+
+	dir := BundleDir(tunnel.Tag)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []BundleChange
+	for _, name := range names {
+		c := configs[name]
+		jsonPath := filepath.Join(dir, name+".json")
+		urlPath := filepath.Join(dir, name+".url")
+
+		old := readBundleConfig(jsonPath)
+		data, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal client config: %w", err)
+		}
+		url, err := Encode(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(jsonPath, append(data, '\n'), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write client config: %w", err)
+		}
+		if err := os.WriteFile(urlPath, []byte(url+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write client config: %w", err)
+		}
+
+		if old == nil {
+			changes = append(changes, BundleChange{Name: name, Path: urlPath, New: true})
+		} else if fields := Diff(old, c); len(fields) > 0 {
+			changes = append(changes, BundleChange{Name: name, Path: urlPath, Fields: fields})
+		}
+	}
+
+	// Drop the configs no longer generated, e.g. of removed users
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		name := strings.TrimSuffix(strings.TrimSuffix(e.Name(), ".json"), ".url")
+		if _, ok := configs[name]; !ok && name != e.Name() {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return changes, nil
+}
+
+// RemoveBundle removes the bundle of a tunnel.
+func RemoveBundle(tag string) error {
+	if err := os.RemoveAll(BundleDir(tag)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove client bundle: %w", err)
+	}
+	return nil
+}
+
+// Diff returns the fields that differ between two client configs, named by
+// their JSON paths, e.g. "transport.domain". Values are left out since most
+// are secrets.
+func Diff(old, cur *ClientConfig) []string {
+	var fields []string
+	diffStruct(reflect.ValueOf(*old), reflect.ValueOf(*cur), "", &fields)
+	sort.Strings(fields)
+	return fields
+}
+
+func diffStruct(a, b reflect.Value, prefix string, fields *[]string) {
+	for i := 0; i < a.NumField(); i++ {
+		name := strings.Split(a.Type().Field(i).Tag.Get("json"), ",")[0]
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fa, fb := a.Field(i), b.Field(i)
+		if fa.Kind() == reflect.Struct {
+			diffStruct(fa, fb, name, fields)
+		} else if fa.Interface() != fb.Interface() {
+			*fields = append(*fields, name)
+		}
+	}
+}
+
+// readBundleConfig reads a stored client config, or returns nil when there
+// is none to compare with.
+func readBundleConfig(path string) *ClientConfig {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var c ClientConfig
+	if json.Unmarshal(data, &c) != nil {
+		return nil
+	}
+	return &c
+}
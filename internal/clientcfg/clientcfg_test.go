@@ -2,8 +2,12 @@ package clientcfg
 
 import (
 	"encoding/base64"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
 )
 
 const fakeCertPEM = "-----BEGIN CERTIFICATE-----\nfake\n-----END CERTIFICATE-----\n"
@@ -287,3 +291,228 @@ func TestEncode_NilConfig(t *testing.T) {
 		t.Fatal("expected error for nil config")
 	}
 }
+
+func TestRoundTrip_PortForward(t *testing.T) {
+	original := &ClientConfig{
+		Version: 1,
+		Tag:     "panel",
+		Transport: TransportConfig{
+			Type:   "dnstt",
+			Domain: "p.example.com",
+			PubKey: "c6a85f970db1ad8afb1a1a910a4a2b276e68fa6c2e11c1ad1883e1c8f5c3a1b2",
+		},
+		Backend: BackendConfig{
+			Type: "portforward",
+			Port: 8080,
+		},
+	}
+
+	url, err := Encode(original)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := Decode(url)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if decoded.Backend.Type != "portforward" {
+		t.Errorf("backend.type: got %q, want %q", decoded.Backend.Type, "portforward")
+	}
+	if decoded.Backend.Port != original.Backend.Port {
+		t.Errorf("backend.port: got %d, want %d", decoded.Backend.Port, original.Backend.Port)
+	}
+}
+
+func TestQR_ShareURL(t *testing.T) {
+	url, err := Encode(&ClientConfig{
+		Version: 1,
+		Tag:     "main",
+		Transport: TransportConfig{
+			Type:   "slipstream",
+			Domain: "t.example.com",
+			Cert:   strings.Repeat("A", 1200),
+		},
+		Backend: BackendConfig{Type: "socks"},
+	})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	text, err := QRTerminal(url)
+	if err != nil {
+		t.Fatalf("QRTerminal: %v", err)
+	}
+	if !strings.Contains(text, "█") {
+		t.Error("terminal QR code contains no blocks")
+	}
+
+	png, err := QRPNG(url)
+	if err != nil {
+		t.Fatalf("QRPNG: %v", err)
+	}
+	if !strings.HasPrefix(string(png), "\x89PNG") {
+		t.Error("QRPNG did not return a PNG image")
+	}
+}
+
+func TestQR_TooLong(t *testing.T) {
+	if _, err := QRTerminal(strings.Repeat("x", 5000)); err == nil {
+		t.Fatal("expected error for payload beyond QR capacity")
+	}
+}
+
+func multiUserTunnel() (*config.TunnelConfig, *config.BackendConfig) {
+	tunnel := &config.TunnelConfig{
+		Tag:       "slip-ss",
+		Transport: config.TransportSlipstream,
+		Backend:   "ss",
+		Domain:    "s.example.com",
+		Slipstream: &config.SlipstreamConfig{
+			Users: []config.ShadowsocksUser{{Name: "alice", Password: "dXNlcmtleQ=="}},
+		},
+	}
+	backend := &config.BackendConfig{
+		Tag:  "ss",
+		Type: config.BackendShadowsocks,
+		Shadowsocks: &config.ShadowsocksConfig{
+			Method:   config.ShadowsocksMethod2022AES128,
+			Password: "c2VydmVya2V5",
+		},
+	}
+	return tunnel, backend
+}
+
+func TestGenerate_ShadowsocksUser(t *testing.T) {
+	tunnel, backend := multiUserTunnel()
+
+	cfg, err := Generate(tunnel, backend, GenerateOptions{NoCert: true, ShadowsocksUser: "alice"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if want := "c2VydmVya2V5:dXNlcmtleQ=="; cfg.Backend.Password != want {
+		t.Errorf("password: got %q, want %q", cfg.Backend.Password, want)
+	}
+
+	if _, err := Generate(tunnel, backend, GenerateOptions{NoCert: true}); err == nil {
+		t.Error("expected an error without a user")
+	}
+	if _, err := Generate(tunnel, backend, GenerateOptions{NoCert: true, ShadowsocksUser: "bob"}); err == nil {
+		t.Error("expected an error for an unknown user")
+	}
+
+	tunnel.Slipstream.Users = nil
+	if _, err := Generate(tunnel, backend, GenerateOptions{NoCert: true, ShadowsocksUser: "alice"}); err == nil {
+		t.Error("expected an error for a tunnel without users")
+	}
+}
+
+func TestShadowsocksURL(t *testing.T) {
+	cfg := &ClientConfig{
+		Version:   1,
+		Tag:       "slip-ss",
+		Transport: TransportConfig{Type: "slipstream", Domain: "s.example.com"},
+		Backend:   BackendConfig{Type: "shadowsocks", Method: "aes-256-gcm", Password: "secret"},
+	}
+
+	url, err := ShadowsocksURL(cfg)
+	if err != nil {
+		t.Fatalf("ShadowsocksURL: %v", err)
+	}
+	userInfo := base64.RawURLEncoding.EncodeToString([]byte("aes-256-gcm:secret"))
+	want := "ss://" + userInfo + "@s.example.com:53/?plugin=slipstream-client%3Bdomain%3Ds.example.com#slip-ss"
+	if url != want {
+		t.Errorf("got %q, want %q", url, want)
+	}
+
+	cfg.Backend.Method = config.ShadowsocksMethod2022AES128
+	cfg.Backend.Password = "a+b/c=:d+e/f="
+	url, err = ShadowsocksURL(cfg)
+	if err != nil {
+		t.Fatalf("ShadowsocksURL: %v", err)
+	}
+	if !strings.HasPrefix(url, "ss://2022-blake3-aes-128-gcm:a+b%2Fc=%3Ad+e%2Ff=@") {
+		t.Errorf("2022 user info not percent-encoded: %s", url)
+	}
+
+	cfg.Backend.Type = "socks"
+	if _, err := ShadowsocksURL(cfg); err == nil {
+		t.Error("expected an error for a SOCKS backend")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &ClientConfig{Version: 1, Tag: "t",
+		Transport: TransportConfig{Type: "slipstream", Domain: "a.example.com", Cert: fakeCertPEM},
+		Backend:   BackendConfig{Type: "shadowsocks", Method: "aes-256-gcm", Password: "one"}}
+	cur := *old
+	if fields := Diff(old, &cur); len(fields) != 0 {
+		t.Errorf("identical configs differ in %v", fields)
+	}
+
+	cur.Transport.Domain = "b.example.com"
+	cur.Backend.Password = "two"
+	got := strings.Join(Diff(old, &cur), ",")
+	if got != "backend.password,transport.domain" {
+		t.Errorf("Diff() = %s", got)
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	defer func(d string) { ExportsDir = d }(ExportsDir)
+	ExportsDir = filepath.Join(dir, "exports")
+
+	certPath := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(certPath, []byte(fakeCertPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tunnel, backend := multiUserTunnel()
+	tunnel.Slipstream.Cert = certPath
+	users := tunnel.Slipstream.Users
+	tunnel.Slipstream.Users = nil
+
+	changes, err := WriteBundle(tunnel, backend)
+	if err != nil {
+		t.Fatalf("WriteBundle: %v", err)
+	}
+	if len(changes) != 1 || !changes[0].New || changes[0].Name != "client" {
+		t.Fatalf("first write: got %+v, want a new client config", changes)
+	}
+	url, err := os.ReadFile(changes[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(strings.TrimSpace(string(url)))
+	if err != nil || decoded.Transport.Cert != fakeCertPEM {
+		t.Errorf("stored URL does not decode to the config: %v", err)
+	}
+
+	if changes, _ := WriteBundle(tunnel, backend); len(changes) != 0 {
+		t.Errorf("unchanged tunnel: got %+v", changes)
+	}
+
+	backend.Shadowsocks.Password = "bmV3a2V5"
+	changes, _ = WriteBundle(tunnel, backend)
+	if len(changes) != 1 || strings.Join(changes[0].Fields, ",") != "backend.password" {
+		t.Errorf("new password: got %+v", changes)
+	}
+
+	// With users the bundle has one config per user instead
+	tunnel.Slipstream.Users = users
+	changes, _ = WriteBundle(tunnel, backend)
+	if len(changes) != 1 || changes[0].Name != "alice" || !changes[0].New {
+		t.Errorf("users: got %+v", changes)
+	}
+	if _, err := os.Stat(filepath.Join(BundleDir(tunnel.Tag), "client.url")); !os.IsNotExist(err) {
+		t.Error("client config of a multi-user tunnel was kept")
+	}
+
+	if err := RemoveBundle(tunnel.Tag); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(BundleDir(tunnel.Tag)); !os.IsNotExist(err) {
+		t.Error("bundle not removed")
+	}
+}
@@ -18,6 +18,9 @@ type GenerateOptions struct {
 
 	// Slipstream options
 	NoCert bool // skip embedding certificate
+
+	// ShadowsocksUser names the user of a multi-user Shadowsocks tunnel
+	ShadowsocksUser string
 }
 
 // Generate builds a ClientConfig from server-side tunnel and backend config.
@@ -47,6 +50,21 @@ func Generate(tunnel *config.TunnelConfig, backend *config.BackendConfig, opts G
 			cfg.Transport.Cert = string(certPEM)
 		}
 
+	case config.TransportChisel:
+		if tunnel.Chisel == nil {
+			return nil, fmt.Errorf("chisel config is missing")
+		}
+		if !opts.NoCert {
+			certPEM, err := os.ReadFile(tunnel.Chisel.Cert)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read certificate: %w", err)
+			}
+			cfg.Transport.Cert = string(certPEM)
+		}
+		cfg.Transport.Auth = tunnel.Chisel.Auth
+		cfg.Transport.Port = tunnel.Port
+		cfg.Transport.Remote = backend.TargetAddress()
+
 	case config.TransportDNSTT:
 		pubKeyPath := filepath.Join(tunnelDir, "server.pub")
 		pubKey, err := keys.ReadPublicKey(pubKeyPath)
@@ -99,7 +117,36 @@ func Generate(tunnel *config.TunnelConfig, backend *config.BackendConfig, opts G
 		}
 		cfg.Backend.Method = backend.Shadowsocks.Method
 		cfg.Backend.Password = backend.Shadowsocks.Password
+		password, err := shadowsocksUserPassword(tunnel, backend, opts.ShadowsocksUser)
+		if err != nil {
+			return nil, err
+		}
+		if password != "" {
+			cfg.Backend.Password = password
+		}
+
+	case config.BackendPortForward:
+		cfg.Backend.Port = backend.GetClientPort()
 	}
 
 	return cfg, nil
 }
+
+// shadowsocksUserPassword returns the password of a user of a multi-user
+// Shadowsocks tunnel, or "" when the tunnel has no users.
+func shadowsocksUserPassword(tunnel *config.TunnelConfig, backend *config.BackendConfig, name string) (string, error) {
+	if tunnel.Slipstream == nil || len(tunnel.Slipstream.Users) == 0 {
+		if name != "" {
+			return "", fmt.Errorf("tunnel '%s' has no users", tunnel.Tag)
+		}
+		return "", nil
+	}
+	if name == "" {
+		return "", fmt.Errorf("tunnel '%s' has several users; name one of them", tunnel.Tag)
+	}
+	user := tunnel.Slipstream.GetShadowsocksUser(name)
+	if user == nil {
+		return "", fmt.Errorf("tunnel '%s' has no user '%s'", tunnel.Tag, name)
+	}
+	return config.ShadowsocksUserPassword(backend.Shadowsocks.Password, user.Password), nil
+}
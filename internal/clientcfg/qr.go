@@ -0,0 +1,48 @@
+package clientcfg
+
+import (
+	"fmt"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QRPNGSize is the edge length in pixels of generated PNG QR codes.
+const QRPNGSize = 512
+
+// newQRCode builds a QR code for a share URL. Medium error correction is
+// preferred for easier scanning; URLs with an embedded certificate may only
+// fit at the low level.
+func newQRCode(url string) (*qrcode.QRCode, error) {
+	q, err := qrcode.New(url, qrcode.Medium)
+	if err == nil {
+		return q, nil
+	}
+	q, err = qrcode.New(url, qrcode.Low)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	return q, nil
+}
+
+// QRTerminal renders a share URL as a QR code made of Unicode half blocks,
+// suitable for scanning straight from a terminal.
+func QRTerminal(url string) (string, error) {
+	q, err := newQRCode(url)
+	if err != nil {
+		return "", err
+	}
+	return q.ToSmallString(false), nil
+}
+
+// QRPNG renders a share URL as a PNG QR code.
+func QRPNG(url string) ([]byte, error) {
+	q, err := newQRCode(url)
+	if err != nil {
+		return nil, err
+	}
+	png, err := q.PNG(QRPNGSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code PNG: %w", err)
+	}
+	return png, nil
+}
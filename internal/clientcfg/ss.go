@@ -0,0 +1,39 @@
+package clientcfg
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// ShadowsocksURL returns the SIP002 ss:// link of a Slipstream tunnel with
+// a Shadowsocks backend, for Shadowsocks clients that run slipstream-client
+// as their SIP003 plugin. The link has no certificate; the plugin is told
+// the domain only.
+func ShadowsocksURL(cfg *ClientConfig) (string, error) {
+	if cfg == nil {
+		return "", fmt.Errorf("config is nil")
+	}
+	if cfg.Transport.Type != string(config.TransportSlipstream) || cfg.Backend.Type != string(config.BackendShadowsocks) {
+		return "", fmt.Errorf("ss:// links are only available for Slipstream tunnels with a Shadowsocks backend")
+	}
+
+	method := cfg.Backend.Method
+	if method == "" {
+		method = "aes-256-gcm"
+	}
+	// SIP002: 2022 methods put the user info in percent-encoding, the
+	// others in base64
+	var userInfo string
+	if config.ShadowsocksKeySize(method) > 0 {
+		userInfo = url.UserPassword(method, cfg.Backend.Password).String()
+	} else {
+		userInfo = base64.RawURLEncoding.EncodeToString([]byte(method + ":" + cfg.Backend.Password))
+	}
+
+	query := url.Values{"plugin": {"slipstream-client;domain=" + cfg.Transport.Domain}}
+	return fmt.Sprintf("ss://%s@%s:53/?%s#%s",
+		userInfo, cfg.Transport.Domain, query.Encode(), url.PathEscape(cfg.Tag)), nil
+}
@@ -10,7 +10,7 @@ type ClientConfig struct {
 
 // TransportConfig describes the DNS transport layer.
 type TransportConfig struct {
-	Type   string `json:"type"`             // "slipstream", "dnstt", or "vaydns"
+	Type   string `json:"type"`             // "slipstream", "dnstt", "vaydns", or "chisel"
 	Domain string `json:"domain"`           // NS domain
 	Cert   string `json:"cert,omitempty"`   // PEM string (slipstream)
 	PubKey string `json:"pubkey,omitempty"` // 64-char hex (dnstt, vaydns)
@@ -21,13 +21,19 @@ type TransportConfig struct {
 	IdleTimeout  string `json:"idle_timeout,omitempty"`    // server -idle-timeout
 	KeepAlive    string `json:"keepalive,omitempty"`       // server -keepalive
 	RecordType   string `json:"record_type,omitempty"`     // server -record-type (default txt)
+
+	// Chisel-specific fields (Cert holds the server certificate)
+	Auth   string `json:"auth,omitempty"`   // user:pass
+	Port   int    `json:"port,omitempty"`   // listen port on the server
+	Remote string `json:"remote,omitempty"` // backend address clients forward to
 }
 
 // BackendConfig describes the backend service behind the tunnel.
 type BackendConfig struct {
-	Type     string `json:"type"`               // "socks", "ssh", "shadowsocks"
+	Type     string `json:"type"`               // "socks", "ssh", "shadowsocks", "portforward"
 	User     string `json:"user,omitempty"`     // ssh
 	Password string `json:"password,omitempty"` // ssh, shadowsocks
 	Key      string `json:"key,omitempty"`      // ssh (private key PEM)
 	Method   string `json:"method,omitempty"`   // shadowsocks
+	Port     int    `json:"port,omitempty"`     // portforward (local listen port)
 }
@@ -0,0 +1,107 @@
+// Package cmdline splits and quotes the command lines of dnstm services.
+//
+// A service command line is split the way systemd splits ExecStart, so the
+// same line works in a systemd unit and in the service host used without
+// systemd. Quote is the inverse of Split.
+package cmdline
+
+import (
+	"runtime"
+	"strings"
+)
+
+// backslashEscapes is false on Windows, where backslashes separate path
+// elements.
+var backslashEscapes = runtime.GOOS != "windows"
+
+// Split splits a command line the way systemd splits ExecStart: on
+// whitespace, with single or double quotes grouping words and a backslash
+// escaping the next character outside single quotes.
+func Split(line string) []string {
+	var (
+		args    []string
+		cur     strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'' && backslashEscapes:
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// Quote quotes an argument so Split returns it as one word. Arguments of
+// only letters, digits and punctuation common in paths, addresses and
+// flags are returned as they are.
+//
+// Without backslash escapes, i.e. on Windows, an argument with a double
+// quote is put in single quotes; one with both kinds of quotes cannot be
+// quoted and is put in double quotes regardless.
+func Quote(arg string) string {
+	if isPlain(arg) {
+		return arg
+	}
+	if backslashEscapes {
+		arg = strings.ReplaceAll(arg, `\`, `\\`)
+		return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+	}
+	if strings.Contains(arg, `"`) && !strings.Contains(arg, "'") {
+		return "'" + arg + "'"
+	}
+	return `"` + arg + `"`
+}
+
+// Join quotes arguments and joins them into a command line.
+func Join(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = Quote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// isPlain reports whether an argument needs no quotes. Shell and systemd
+// metacharacters such as ';' are quoted too, although Split does not treat
+// them specially.
+func isPlain(arg string) bool {
+	if arg == "" {
+		return false
+	}
+	for _, r := range arg {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_./:=,@+%[]", r):
+		case r == '\\' && !backslashEscapes:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package cmdline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"/usr/local/bin/dnstt-server -udp :5310 t.example.com", []string{"/usr/local/bin/dnstt-server", "-udp", ":5310", "t.example.com"}},
+		{`  a  "b c"  'd "e"' `, []string{"a", "b c", `d "e"`}},
+		{`C:\\dnstm\\dnstm.exe x\ y ""`, []string{`C:\dnstm\dnstm.exe`, "x y", ""}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := Split(tt.line)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplit_WindowsPaths(t *testing.T) {
+	backslashEscapes = false
+	defer func() { backslashEscapes = true }()
+
+	got := Split(`"C:\Program Files\dnstm\dnstt-server.exe" -privkey-file C:\ProgramData\dnstm\server.key`)
+	want := []string{`C:\Program Files\dnstm\dnstt-server.exe`, "-privkey-file", `C:\ProgramData\dnstm\server.key`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Split() = %q, want %q", got, want)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		arg, want string
+	}{
+		{"/usr/local/bin/dnstt-server", "/usr/local/bin/dnstt-server"},
+		{"[2001:db8::1]:5310", "[2001:db8::1]:5310"},
+		{"t.example.com", "t.example.com"},
+		{"", `""`},
+		{"/opt/my keys/server.key", `"/opt/my keys/server.key"`},
+		{`p@ss "w\ord"; $x`, `"p@ss \"w\\ord\"; $x"`},
+		{"it's", `"it's"`},
+	}
+	for _, tt := range tests {
+		got := Quote(tt.arg)
+		if got != tt.want {
+			t.Errorf("Quote(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+		if split := Split(got); len(split) != 1 || split[0] != tt.arg {
+			t.Errorf("Split(Quote(%q)) = %q", tt.arg, split)
+		}
+	}
+
+	line := Join("/usr/bin/ssserver", "-c", "/etc/my dnstm/config.json")
+	if want := `/usr/bin/ssserver -c "/etc/my dnstm/config.json"`; line != want {
+		t.Errorf("Join() = %q, want %q", line, want)
+	}
+}
+
+func TestQuote_Windows(t *testing.T) {
+	backslashEscapes = false
+	defer func() { backslashEscapes = true }()
+
+	for arg, want := range map[string]string{
+		`C:\ProgramData\dnstm\server.key`:         `C:\ProgramData\dnstm\server.key`,
+		`C:\Program Files\dnstm\dnstt-server.exe`: `"C:\Program Files\dnstm\dnstt-server.exe"`,
+		`say "hi"`: `'say "hi"'`,
+	} {
+		got := Quote(arg)
+		if got != want {
+			t.Errorf("Quote(%q) = %q, want %q", arg, got, want)
+		}
+		if split := Split(got); len(split) != 1 || split[0] != arg {
+			t.Errorf("Split(Quote(%q)) = %q", arg, split)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// APIConfig configures the API server, an HTTP server dnstm runs as its own
+// service. It is off while Listen is empty.
+type APIConfig struct {
+	Listen     string         `json:"listen,omitempty"`      // host:port
+	StatusPage bool           `json:"status_page,omitempty"` // serve the public status page at /status
+	Webhook    *WebhookConfig `json:"webhook,omitempty"`
+	Agents     *AgentsConfig  `json:"agents,omitempty"`
+}
+
+// AgentsConfig makes this server a controller that other dnstm servers
+// enroll with as agents. Agents connect over mutual TLS on their own port.
+type AgentsConfig struct {
+	Listen string `json:"listen"` // host:port of the TLS listener
+}
+
+// DefaultAgentsListen is the address agents connect to unless set.
+const DefaultAgentsListen = "0.0.0.0:8443"
+
+// Port returns the port agents connect to, or 0 if the address has none.
+func (a *AgentsConfig) Port() int {
+	return listenPort(a.Listen)
+}
+
+// WebhookConfig enables the provisioning webhook: signed requests from a
+// billing panel or other system that create tunnels.
+type WebhookConfig struct {
+	Secret  string `json:"secret"`            // shared HMAC-SHA256 key
+	Backend string `json:"backend,omitempty"` // backend of tunnels whose request names none
+}
+
+// MinWebhookSecretLength is the shortest accepted webhook secret.
+const MinWebhookSecretLength = 16
+
+// DefaultWebhookBackend is the backend of provisioned tunnels unless set.
+const DefaultWebhookBackend = "socks"
+
+// GetBackend returns the default backend of provisioned tunnels.
+func (w *WebhookConfig) GetBackend() string {
+	if w == nil || w.Backend == "" {
+		return DefaultWebhookBackend
+	}
+	return w.Backend
+}
+
+// DefaultAPIListen is the address the API server listens on unless set.
+const DefaultAPIListen = "0.0.0.0:8080"
+
+// IsEnabled reports whether the API server is configured to run.
+func (a APIConfig) IsEnabled() bool {
+	return a.Listen != ""
+}
+
+// Port returns the port of the listen address, or 0 if it has none.
+func (a APIConfig) Port() int {
+	return listenPort(a.Listen)
+}
+
+func listenPort(listen string) int {
+	_, p, err := net.SplitHostPort(listen)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(p)
+	return port
+}
+
+// validateAPI checks the API server addresses and that their ports are not
+// ones a tunnel or the SOCKS proxy already uses.
+func (c *Config) validateAPI() error {
+	if !c.API.IsEnabled() {
+		if c.API.StatusPage || c.API.Webhook != nil || c.API.Agents != nil {
+			return fmt.Errorf("api.status_page, api.webhook and api.agents require api.listen")
+		}
+		return nil
+	}
+	if w := c.API.Webhook; w != nil {
+		if len(w.Secret) < MinWebhookSecretLength {
+			return fmt.Errorf("api.webhook.secret must be at least %d characters", MinWebhookSecretLength)
+		}
+		if b := c.GetBackendByTag(w.GetBackend()); b == nil {
+			return fmt.Errorf("api.webhook.backend '%s' does not exist", w.GetBackend())
+		}
+	}
+	if err := c.validateAPIListen("api.listen", c.API.Listen, DefaultAPIListen); err != nil {
+		return err
+	}
+	if a := c.API.Agents; a != nil {
+		if err := c.validateAPIListen("api.agents.listen", a.Listen, DefaultAgentsListen); err != nil {
+			return err
+		}
+		if a.Port() == c.API.Port() {
+			return fmt.Errorf("api.agents.listen must use another port than api.listen")
+		}
+	}
+	return nil
+}
+
+// validateAPIListen checks one listen address of the API server.
+func (c *Config) validateAPIListen(field, listen, example string) error {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return fmt.Errorf("invalid %s '%s': use host:port, e.g. %s", field, listen, example)
+	}
+	if host != "" && net.ParseIP(host) == nil {
+		return fmt.Errorf("invalid %s '%s': the host must be an IP address", field, listen)
+	}
+	port := listenPort(listen)
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid %s '%s': port must be between 1 and 65535", field, listen)
+	}
+	if port == 53 {
+		return fmt.Errorf("%s cannot use port 53", field)
+	}
+	for _, t := range c.Tunnels {
+		if t.Port == port {
+			return fmt.Errorf("%s port %d is used by tunnel '%s'", field, port, t.Tag)
+		}
+	}
+	proxyPort := c.Proxy.Port
+	if proxyPort == 0 {
+		proxyPort = 1080
+	}
+	if proxyPort == port {
+		return fmt.Errorf("%s port %d is used by the SOCKS proxy", field, port)
+	}
+	return nil
+}
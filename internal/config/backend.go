@@ -1,6 +1,14 @@
 package config
 
-import "os"
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/net2share/dnstm/internal/egress"
+	"github.com/net2share/dnstm/internal/paths"
+)
 
 // BackendType defines the type of backend.
 type BackendType string
@@ -10,6 +18,7 @@ const (
 	BackendSSH         BackendType = "ssh"
 	BackendShadowsocks BackendType = "shadowsocks"
 	BackendCustom      BackendType = "custom"
+	BackendPortForward BackendType = "portforward"
 )
 
 // BackendConfig configures a backend service.
@@ -19,6 +28,66 @@ type BackendConfig struct {
 	Address     string             `json:"address,omitempty"`
 	Shadowsocks *ShadowsocksConfig `json:"shadowsocks,omitempty"`
 	Socks       *SocksConfig       `json:"socks,omitempty"`
+	PortForward *PortForwardConfig `json:"portforward,omitempty"`
+	Egress      *EgressConfig      `json:"egress,omitempty"`
+	Outbound    *OutboundConfig    `json:"outbound,omitempty"`
+	Keepalive   *KeepaliveConfig   `json:"keepalive,omitempty"`
+}
+
+// KeepaliveConfig keeps long-idle client connections of a backend from being
+// dropped silently. For SOCKS backends Idle, Interval and Count tune TCP
+// keepalive probes and IdleTimeout closes connections that moved no data for
+// that long. For SSH backends Idle and Count set sshd's ClientAliveInterval
+// and ClientAliveCountMax, whose probes travel through the tunnel. Durations use Go syntax, e.g. "45s" or "2h".
+type KeepaliveConfig struct {
+	Idle        string `json:"idle,omitempty"`
+	Interval    string `json:"interval,omitempty"`
+	Count       int    `json:"count,omitempty"`
+	IdleTimeout string `json:"idle_timeout,omitempty"` // "" = never
+}
+
+// IdleDuration returns the idle time before the first probe, 0 if unset.
+func (k *KeepaliveConfig) IdleDuration() time.Duration {
+	return parseKeepaliveDuration(k.Idle)
+}
+
+// IntervalDuration returns the time between probes, 0 if unset.
+func (k *KeepaliveConfig) IntervalDuration() time.Duration {
+	return parseKeepaliveDuration(k.Interval)
+}
+
+// IdleTimeoutDuration returns how long a connection may stay idle, 0 if
+// unlimited.
+func (k *KeepaliveConfig) IdleTimeoutDuration() time.Duration {
+	return parseKeepaliveDuration(k.IdleTimeout)
+}
+
+func parseKeepaliveDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// OutboundConfig selects where a backend's outgoing connections leave the host.
+// Interface binds sockets to a device (SO_BINDTODEVICE); SourceIP sets the
+// local address, which on multi-homed hosts selects the egress IP.
+type OutboundConfig struct {
+	Interface string `json:"interface,omitempty"`
+	SourceIP  string `json:"source_ip,omitempty"`
+}
+
+// EgressConfig restricts which destinations a proxy backend may connect to.
+// Rules use the form "<allow|deny> <cidr|ip> [port|port-port]" and are
+// evaluated in order; the first match wins and unmatched traffic is allowed.
+type EgressConfig struct {
+	Rules []string `json:"rules"`
+}
+
+// PortForwardConfig holds settings for a published local service.
+type PortForwardConfig struct {
+	ClientPort int `json:"client_port,omitempty"`
 }
 
 // SocksConfig holds SOCKS5 authentication configuration.
@@ -29,8 +98,9 @@ type SocksConfig struct {
 
 // ShadowsocksConfig holds Shadowsocks-specific configuration.
 type ShadowsocksConfig struct {
-	Method   string `json:"method,omitempty"`
-	Password string `json:"password"`
+	Method   string              `json:"method,omitempty"`
+	Password string              `json:"password"`
+	Plugins  []ShadowsocksPlugin `json:"plugins,omitempty"` // extra listeners next to slipstream
 }
 
 // HasSocksAuth returns true if SOCKS5 authentication is configured.
@@ -38,6 +108,43 @@ func (b *BackendConfig) HasSocksAuth() bool {
 	return b.Socks != nil && b.Socks.User != "" && b.Socks.Password != ""
 }
 
+// EgressRules returns the parsed egress rules of the backend, if any.
+func (b *BackendConfig) EgressRules() ([]egress.Rule, error) {
+	if b.Egress == nil {
+		return nil, nil
+	}
+	return egress.ParseRules(b.Egress.Rules)
+}
+
+// TargetAddress returns the address tunnels forward to, applying the
+// default for managed backends without an explicit address.
+func (b *BackendConfig) TargetAddress() string {
+	if b.Address != "" {
+		return b.Address
+	}
+	switch b.Type {
+	case BackendSOCKS:
+		return "127.0.0.1:1080"
+	case BackendSSH:
+		return "127.0.0.1:22"
+	}
+	return ""
+}
+
+// GetClientPort returns the local port clients should listen on for a port forward.
+// Defaults to the port of the forwarded service.
+func (b *BackendConfig) GetClientPort() int {
+	if b.PortForward != nil && b.PortForward.ClientPort > 0 {
+		return b.PortForward.ClientPort
+	}
+	if _, portStr, err := net.SplitHostPort(b.Address); err == nil {
+		if port, err := strconv.Atoi(portStr); err == nil {
+			return port
+		}
+	}
+	return 0
+}
+
 // IsManaged returns true if dnstm manages this backend type.
 func (b *BackendConfig) IsManaged() bool {
 	switch b.Type {
@@ -78,7 +185,7 @@ var BackendTypeRegistry = map[BackendType]BackendTypeInfo{
 		Name:        "SOCKS5",
 		Description: "Built-in SOCKS5 proxy (microsocks)",
 		Category:    CategoryBuiltIn,
-		Binary:      "/usr/local/bin/microsocks",
+		Binary:      paths.Bin("microsocks"),
 	},
 	BackendSSH: {
 		Type:        BackendSSH,
@@ -91,7 +198,7 @@ var BackendTypeRegistry = map[BackendType]BackendTypeInfo{
 		Name:        "Shadowsocks",
 		Description: "Shadowsocks proxy (SIP003)",
 		Category:    CategoryBuiltIn,
-		Binary:      "/usr/local/bin/ssserver",
+		Binary:      paths.Bin("ssserver"),
 	},
 	BackendCustom: {
 		Type:        BackendCustom,
@@ -99,6 +206,12 @@ var BackendTypeRegistry = map[BackendType]BackendTypeInfo{
 		Description: "Custom TCP service",
 		Category:    CategoryCustom,
 	},
+	BackendPortForward: {
+		Type:        BackendPortForward,
+		Name:        "Port Forward",
+		Description: "Publish a local service through the tunnel",
+		Category:    CategoryCustom,
+	},
 }
 
 // IsInstalled returns true if the backend type's binary is available.
@@ -128,6 +241,7 @@ func GetBackendTypes() []BackendType {
 		BackendSSH,
 		BackendShadowsocks,
 		BackendCustom,
+		BackendPortForward,
 	}
 }
 
@@ -7,27 +7,81 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/net2share/dnstm/internal/paths"
 )
 
-const (
-	ConfigDir  = "/etc/dnstm"
-	ConfigFile = "config.json"
-	TunnelsDir = "/etc/dnstm/tunnels"
+const ConfigFile = "config.json"
+
+// Directories can be moved with environment variables; see package paths.
+var (
+	ConfigDir  = paths.ConfigDir
+	TunnelsDir = paths.TunnelsDir
+
+	// DefaultDecoyZone is where "dnstm router decoy" writes a starter zone.
+	DefaultDecoyZone = filepath.Join(ConfigDir, "decoy.zone")
 )
 
+// ErrInvalid is wrapped by errors for configs that cannot be parsed or do
+// not validate.
+var ErrInvalid = errors.New("invalid configuration")
+
+// invalidError marks err as an invalid config without changing its message.
+type invalidError struct{ err error }
+
+func (e invalidError) Error() string   { return e.err.Error() }
+func (e invalidError) Unwrap() []error { return []error{e.err, ErrInvalid} }
+
+func invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return invalidError{err}
+}
+
 // Config is the main dnstm configuration.
 type Config struct {
 	Log      LogConfig       `json:"log,omitempty"`
 	Listen   ListenConfig    `json:"listen,omitempty"`
 	Proxy    ProxyConfig     `json:"proxy,omitempty"`
+	Ports    PortsConfig     `json:"ports,omitempty"`
 	Backends []BackendConfig `json:"backends,omitempty"`
 	Tunnels  []TunnelConfig  `json:"tunnels,omitempty"`
 	Route    RouteConfig     `json:"route,omitempty"`
+	Quotas   []QuotaConfig   `json:"quotas,omitempty"`
+	Services ServicesConfig  `json:"services,omitempty"`
+	Metrics  *MetricsConfig  `json:"metrics,omitempty"` // nil = metrics are not recorded
+	Privacy  *PrivacyConfig  `json:"privacy,omitempty"` // nil = client addresses are recorded as seen
+	Firewall FirewallConfig  `json:"firewall,omitempty"`
+	API      APIConfig       `json:"api,omitempty"`
+	Limits   LimitsConfig    `json:"limits,omitempty"`
+	Profile  string          `json:"profile,omitempty"` // tiny, standard or performance; empty = standard
+	Secrets  SecretsConfig   `json:"secrets,omitempty"`
 }
 
-// ProxyConfig configures the built-in SOCKS proxy (microsocks).
+// ProxyConfig configures the built-in SOCKS proxy.
 type ProxyConfig struct {
-	Port int `json:"port,omitempty"`
+	Port          int    `json:"port,omitempty"`
+	Engine        string `json:"engine,omitempty"`
+	MaxConnsPerIP int    `json:"max_conns_per_ip,omitempty"`
+}
+
+// SOCKS proxy engines.
+const (
+	ProxyEngineMicrosocks = "microsocks"
+	ProxyEngineBuiltin    = "builtin"
+)
+
+// IsBuiltinEngine returns true if the SOCKS proxy runs in-process instead of microsocks.
+func (p ProxyConfig) IsBuiltinEngine() bool {
+	return p.Engine == ProxyEngineBuiltin
+}
+
+// PortsConfig configures how tunnel ports are allocated.
+type PortsConfig struct {
+	Start   int   `json:"start,omitempty"`
+	End     int   `json:"end,omitempty"`
+	Exclude []int `json:"exclude,omitempty"`
 }
 
 // LogConfig configures logging behavior.
@@ -35,38 +89,87 @@ type LogConfig struct {
 	Level     string `json:"level,omitempty"`
 	Output    string `json:"output,omitempty"`
 	Timestamp *bool  `json:"timestamp,omitempty"`
+
+	Alerts   *LogAlertsConfig  `json:"alerts,omitempty"`   // nil = tunnel logs are not scanned
+	Ship     *LogShipConfig    `json:"ship,omitempty"`     // nil = logs stay in the local journal
+	Sessions *SessionLogConfig `json:"sessions,omitempty"` // nil = sessions are not recorded
 }
 
 // ListenConfig configures the DNS listener.
 type ListenConfig struct {
 	Address string `json:"address,omitempty"`
+	Workers int    `json:"workers,omitempty"` // DNS router sockets sharing the port, 0 for one per CPU
+}
+
+// ServicesConfig configures how dnstm manages its systemd services.
+type ServicesConfig struct {
+	ReadyTimeout int `json:"ready_timeout,omitempty"` // seconds to wait for a started service to listen
+}
+
+// FirewallConfig configures the lockout protection applied when dnstm
+// changes the firewall from an SSH session, and the conntrack bypass of
+// DNS traffic.
+type FirewallConfig struct {
+	ConfirmTimeout int  `json:"confirm_timeout,omitempty"` // minutes before unconfirmed changes are rolled back, -1 to disable
+	NoTrack        bool `json:"notrack,omitempty"`         // exempt tunnel DNS traffic from connection tracking
 }
 
 // RouteConfig configures routing mode and active tunnel.
 type RouteConfig struct {
-	Mode    string `json:"mode,omitempty"`
-	Active  string `json:"active,omitempty"`
-	Default string `json:"default,omitempty"`
+	Mode    string       `json:"mode,omitempty"`
+	Active  string       `json:"active,omitempty"`
+	Default string       `json:"default,omitempty"`
+	Decoy   *DecoyConfig `json:"decoy,omitempty"`
+	Records []ZoneRecord `json:"records,omitempty"`
+
+	// Stateless makes the DNS router pick backends by query name alone, so
+	// several routers behind ECMP or anycast agree on every name. Canaries
+	// cannot be routed this way.
+	Stateless bool `json:"stateless,omitempty"`
+}
+
+// DecoyConfig makes the DNS router answer queries for names in a zone file
+// itself, so the tunnel domains look like an ordinary authoritative zone.
+type DecoyConfig struct {
+	ZoneFile string `json:"zone_file"`
 }
 
-// Load reads the configuration from disk.
+// ZoneRecord is a static DNS record the DNS router answers next to the
+// tunnels, e.g. MX for mail or TXT for ACME DNS-01 challenges.
+type ZoneRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	TTL   int    `json:"ttl,omitempty"`
+}
+
+// ZoneRecordTypes are the record types a ZoneRecord may have.
+var ZoneRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "TXT"}
+
+// Load reads the configuration from the config store.
 func Load() (*Config, error) {
-	return LoadFromPath(filepath.Join(ConfigDir, ConfigFile))
+	s, err := currentStore()
+	if err != nil {
+		return nil, err
+	}
+	return loadStore(s)
 }
 
 // LoadFromPath reads the configuration from a specific path.
+// A shared lock is taken when possible so the read does not overlap a locked
+// read-modify-write; readers without permission to create the lock file fall
+// back to an unlocked read, which is still safe because writes are atomic.
 func LoadFromPath(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	return loadStore(&fileStore{path: path})
+}
+
+// loadStore reads and parses the configuration held by s.
+func loadStore(s Store) (*Config, error) {
+	data, err := s.Load()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
-
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
-	}
-
-	return &cfg, nil
+	return parseConfig(data)
 }
 
 // LoadOrDefault reads the configuration from disk, or returns a default config if not found.
@@ -81,28 +184,36 @@ func LoadOrDefault() (*Config, error) {
 	return cfg, nil
 }
 
-// Save writes the configuration to disk.
+// Save writes the configuration to the config store.
 func (c *Config) Save() error {
-	return c.SaveToPath(filepath.Join(ConfigDir, ConfigFile))
+	s, err := currentStore()
+	if err != nil {
+		return err
+	}
+	return c.saveStore(s)
 }
 
 // SaveToPath writes the configuration to a specific path.
+// The write is serialized with other writers and replaces the file atomically.
 func (c *Config) SaveToPath(path string) error {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
-	}
+	return c.saveStore(&fileStore{path: path})
+}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+func (c *Config) saveStore(s Store) error {
+	data, err := marshalConfig(c)
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return err
 	}
+	return s.Save(data)
+}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+// marshalConfig encodes c the way it is stored.
+func marshalConfig(c *Config) ([]byte, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
 	}
-
-	return nil
+	return data, nil
 }
 
 // Default returns a default configuration.
@@ -152,6 +263,26 @@ func (c *Config) GetTunnelByTag(tag string) *TunnelConfig {
 	return nil
 }
 
+// GetRescueTunnel returns the rescue tunnel, or nil when none is configured.
+func (c *Config) GetRescueTunnel() *TunnelConfig {
+	for i := range c.Tunnels {
+		if c.Tunnels[i].Rescue {
+			return &c.Tunnels[i]
+		}
+	}
+	return nil
+}
+
+// GetTunnelByPort returns the tunnel assigned to a port.
+func (c *Config) GetTunnelByPort(port int) *TunnelConfig {
+	for i := range c.Tunnels {
+		if c.Tunnels[i].Port == port {
+			return &c.Tunnels[i]
+		}
+	}
+	return nil
+}
+
 // GetActiveTunnel returns the active tunnel tag in single mode.
 func (c *Config) GetActiveTunnel() string {
 	if c.IsSingleMode() {
@@ -193,13 +324,37 @@ func (c *Config) GetTunnelsUsingBackend(backendTag string) []*TunnelConfig {
 	return tunnels
 }
 
-// ConfigExists checks if the config file exists.
+// PublicPorts returns the TCP ports a tunnel listens on besides DNS, which
+// the firewall must allow: the port of a non-DNS transport and the plugin
+// listeners of its Shadowsocks backend.
+func (c *Config) PublicPorts(t *TunnelConfig) []int {
+	var ports []int
+	if !t.Transport.IsDNS() {
+		ports = append(ports, t.Port)
+	}
+	if b := c.GetBackendByTag(t.Backend); b != nil && b.Type == BackendShadowsocks && b.Shadowsocks != nil {
+		for _, p := range b.Shadowsocks.Plugins {
+			ports = append(ports, p.Port)
+		}
+	}
+	return ports
+}
+
+// ConfigExists checks if a configuration has been saved.
 func ConfigExists() bool {
-	_, err := os.Stat(filepath.Join(ConfigDir, ConfigFile))
+	s, err := currentStore()
+	if err != nil {
+		return false
+	}
+	_, err = s.Load()
 	return err == nil
 }
 
-// GetConfigPath returns the path to the config file.
+// GetConfigPath returns where the configuration is kept: the path to the
+// config file, or the key in a shared config store.
 func GetConfigPath() string {
-	return filepath.Join(ConfigDir, ConfigFile)
+	if s, err := currentStore(); err == nil {
+		return s.String()
+	}
+	return paths.Store
 }
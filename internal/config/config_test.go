@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -225,3 +227,96 @@ func TestConfig_SetActiveTunnel(t *testing.T) {
 		t.Errorf("SetActiveTunnel('') failed: %v", err)
 	}
 }
+
+func TestConfig_SaveIsAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	cfg := Default()
+	if err := cfg.SaveToPath(configPath); err != nil {
+		t.Fatalf("SaveToPath failed: %v", err)
+	}
+	cfg.Listen.Address = "127.0.0.1:5353"
+	if err := cfg.SaveToPath(configPath); err != nil {
+		t.Fatalf("SaveToPath failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, e := range entries {
+		if e.Name() != "config.json" && e.Name() != "config.json"+lockSuffix {
+			t.Errorf("unexpected leftover file %q", e.Name())
+		}
+	}
+
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0644 {
+		t.Errorf("config mode = %o, want 644", perm)
+	}
+}
+
+func TestConfig_UpdatePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if err := Default().SaveToPath(configPath); err != nil {
+		t.Fatalf("SaveToPath failed: %v", err)
+	}
+
+	// Concurrent updates must not lose each other's changes.
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := UpdatePath(configPath, func(c *Config) error {
+				c.Proxy.Port++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("UpdatePath failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded, err := LoadFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromPath failed: %v", err)
+	}
+	if loaded.Proxy.Port != workers {
+		t.Errorf("Proxy.Port = %d, want %d", loaded.Proxy.Port, workers)
+	}
+}
+
+func TestConfig_UpdatePathError(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if err := Default().SaveToPath(configPath); err != nil {
+		t.Fatalf("SaveToPath failed: %v", err)
+	}
+
+	wantErr := errors.New("abort")
+	err := UpdatePath(configPath, func(c *Config) error {
+		c.Listen.Address = "127.0.0.1:9999"
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("UpdatePath error = %v, want %v", err, wantErr)
+	}
+
+	loaded, err := LoadFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromPath failed: %v", err)
+	}
+	if loaded.Listen.Address != "0.0.0.0:53" {
+		t.Errorf("Listen.Address = %q, config should be unchanged", loaded.Listen.Address)
+	}
+}
@@ -1,19 +1,32 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"time"
 )
 
+// ErrPortRangeFull is returned when every port of the configured tunnel
+// port range is taken.
+var ErrPortRangeFull = errors.New("no free port left in the tunnel port range")
+
 const (
 	// DefaultPortStart is the start of the port range for tunnel allocation.
 	DefaultPortStart = 5310
 	// DefaultPortEnd is the end of the port range for tunnel allocation.
 	DefaultPortEnd = 5399
+	// DefaultReadyTimeout is how long a started service may take to listen.
+	DefaultReadyTimeout = 15 * time.Second
+
+	// DefaultFirewallConfirmTimeout is how long firewall changes made over
+	// SSH wait for 'dnstm firewall confirm' before they are rolled back.
+	DefaultFirewallConfirmTimeout = 5 * time.Minute
 )
 
-// ApplyDefaults fills in missing optional values with defaults.
-func (c *Config) ApplyDefaults() {
+// ApplyDefaults fills in missing optional values with defaults. It fails
+// only when a tunnel without a port cannot be given one.
+func (c *Config) ApplyDefaults() error {
 	// Log defaults
 	if c.Log.Level == "" {
 		c.Log.Level = "info"
@@ -38,10 +51,17 @@ func (c *Config) ApplyDefaults() {
 	for i := range c.Tunnels {
 		t := &c.Tunnels[i]
 
-		// Auto-allocate port if not set
+		// Auto-allocate port if not set; non-DNS transports have a fixed default
+		if t.Port == 0 && t.Transport == TransportChisel {
+			t.Port = DefaultChiselPort
+		}
 		if t.Port == 0 {
-			t.Port = allocatePort(usedPorts)
-			usedPorts[t.Port] = true
+			port, err := c.allocatePort(usedPorts)
+			if err != nil {
+				return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+			}
+			t.Port = port
+			usedPorts[port] = true
 		}
 
 		// Enabled defaults to true
@@ -56,7 +76,7 @@ func (c *Config) ApplyDefaults() {
 				t.DNSTT = &DNSTTConfig{}
 			}
 			if t.DNSTT.MTU == 0 {
-				t.DNSTT.MTU = 1232
+				t.DNSTT.MTU = c.DefaultMTU()
 			}
 		}
 		if t.Transport == TransportVayDNS {
@@ -64,7 +84,7 @@ func (c *Config) ApplyDefaults() {
 				t.VayDNS = &VayDNSConfig{}
 			}
 			if t.VayDNS.MTU == 0 {
-				t.VayDNS.MTU = 1232
+				t.VayDNS.MTU = c.DefaultMTU()
 			}
 			if !t.VayDNS.DnsttCompat && t.VayDNS.ClientIDSize == 0 {
 				t.VayDNS.ClientIDSize = 2
@@ -102,10 +122,10 @@ func (c *Config) ApplyDefaults() {
 		}
 	}
 
-	// Route active/default defaults to first enabled tunnel
+	// Route active/default defaults to first enabled DNS tunnel
 	if c.Route.Active == "" || c.Route.Default == "" {
 		for _, t := range c.Tunnels {
-			if t.IsEnabled() {
+			if t.IsEnabled() && t.Transport.IsDNS() {
 				if c.Route.Active == "" {
 					c.Route.Active = t.Tag
 				}
@@ -116,6 +136,7 @@ func (c *Config) ApplyDefaults() {
 			}
 		}
 	}
+	return nil
 }
 
 // getUsedPorts returns a map of all ports currently in use by tunnels.
@@ -125,25 +146,71 @@ func (c *Config) getUsedPorts() map[int]bool {
 		if t.Port != 0 {
 			ports[t.Port] = true
 		}
+		if port := t.FrontPort(); port != 0 {
+			ports[port] = true
+		}
 	}
 	return ports
 }
 
+// Range returns the tunnel port range, applying defaults for unset bounds.
+func (p PortsConfig) Range() (int, int) {
+	start, end := p.Start, p.End
+	if start == 0 {
+		start = DefaultPortStart
+	}
+	if end == 0 {
+		end = start + DefaultPortEnd - DefaultPortStart
+	}
+	return start, end
+}
+
+// IsExcluded returns true if the port must not be allocated.
+func (p PortsConfig) IsExcluded(port int) bool {
+	for _, e := range p.Exclude {
+		if e == port {
+			return true
+		}
+	}
+	return false
+}
+
+// GetReadyTimeout returns how long to wait for a started service to become
+// active and listen, applying the default when unset.
+func (s ServicesConfig) GetReadyTimeout() time.Duration {
+	if s.ReadyTimeout == 0 {
+		return DefaultReadyTimeout
+	}
+	return time.Duration(s.ReadyTimeout) * time.Second
+}
+
+// GetConfirmTimeout returns how long firewall changes wait for confirmation,
+// applying the default when unset. Zero means rollback is disabled.
+func (f FirewallConfig) GetConfirmTimeout() time.Duration {
+	switch {
+	case f.ConfirmTimeout == 0:
+		return DefaultFirewallConfirmTimeout
+	case f.ConfirmTimeout < 0:
+		return 0
+	}
+	return time.Duration(f.ConfirmTimeout) * time.Minute
+}
+
 // allocatePort finds the next available port in the tunnel port range.
 // It checks both the config (usedPorts) and system (TCP/UDP binding).
-func allocatePort(usedPorts map[int]bool) int {
-	for port := DefaultPortStart; port <= DefaultPortEnd; port++ {
-		if !usedPorts[port] && IsPortFree(port) {
-			return port
-		}
+// Without a configured end, ports above the range are used once it is full.
+func (c *Config) allocatePort(usedPorts map[int]bool) (int, error) {
+	start, end := c.Ports.Range()
+	last := end
+	if c.Ports.End == 0 {
+		last = 65534
 	}
-	// Fallback to ports above the range
-	for port := DefaultPortEnd + 1; port < 65535; port++ {
-		if !usedPorts[port] && IsPortFree(port) {
-			return port
+	for port := start; port <= last; port++ {
+		if !usedPorts[port] && !c.Ports.IsExcluded(port) && IsPortFree(port) {
+			return port, nil
 		}
 	}
-	return 0 // Should not happen
+	return 0, fmt.Errorf("%w (%d-%d)", ErrPortRangeFull, start, last)
 }
 
 // IsPortFree checks if a port is free on the system (both TCP and UDP on 127.0.0.1).
@@ -165,8 +232,17 @@ func IsPortFree(port int) bool {
 }
 
 // AllocateNextPort allocates the next available port for a new tunnel.
-func (c *Config) AllocateNextPort() int {
-	return allocatePort(c.getUsedPorts())
+// It returns an error wrapping ErrPortRangeFull when none is left.
+func (c *Config) AllocateNextPort() (int, error) {
+	return c.allocatePort(c.getUsedPorts())
+}
+
+// AllocateFrontPort returns a free port for the front of t, which need not
+// be in c.Tunnels yet.
+func (c *Config) AllocateFrontPort(t *TunnelConfig) (int, error) {
+	used := c.getUsedPorts()
+	used[t.Port] = true
+	return c.allocatePort(used)
 }
 
 // EnsureBuiltinBackends ensures the default socks and ssh backends exist.
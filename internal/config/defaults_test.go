@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+	"net"
 	"testing"
 )
 
@@ -167,7 +169,10 @@ func TestAllocateNextPort(t *testing.T) {
 		},
 	}
 
-	port := cfg.AllocateNextPort()
+	port, err := cfg.AllocateNextPort()
+	if err != nil {
+		t.Fatalf("AllocateNextPort() error = %v", err)
+	}
 	if port != 5312 {
 		t.Errorf("AllocateNextPort() = %d, want 5312", port)
 	}
@@ -180,7 +185,10 @@ func TestAllocateNextPort_Empty(t *testing.T) {
 
 	cfg := &Config{}
 
-	port := cfg.AllocateNextPort()
+	port, err := cfg.AllocateNextPort()
+	if err != nil {
+		t.Fatalf("AllocateNextPort() error = %v", err)
+	}
 	if port != DefaultPortStart {
 		t.Errorf("AllocateNextPort() = %d, want %d", port, DefaultPortStart)
 	}
@@ -198,12 +206,65 @@ func TestAllocateNextPort_Gap(t *testing.T) {
 		},
 	}
 
-	port := cfg.AllocateNextPort()
+	port, err := cfg.AllocateNextPort()
+	if err != nil {
+		t.Fatalf("AllocateNextPort() error = %v", err)
+	}
 	if port != 5311 {
 		t.Errorf("AllocateNextPort() = %d, want 5311 (fill gap)", port)
 	}
 }
 
+func TestAllocateNextPort_Policy(t *testing.T) {
+	if !IsPortFree(6002) {
+		t.Skip("port 6002 is in use on this system")
+	}
+
+	cfg := &Config{
+		Ports: PortsConfig{Start: 6000, End: 6002, Exclude: []int{6001}},
+		Tunnels: []TunnelConfig{
+			{Tag: "tunnel-a", Port: 6000},
+		},
+	}
+
+	if port, _ := cfg.AllocateNextPort(); port != 6002 {
+		t.Errorf("AllocateNextPort() = %d, want 6002 (skip excluded)", port)
+	}
+
+	cfg.Tunnels = append(cfg.Tunnels, TunnelConfig{Tag: "tunnel-b", Port: 6002})
+	if port, err := cfg.AllocateNextPort(); !errors.Is(err, ErrPortRangeFull) {
+		t.Errorf("AllocateNextPort() = %d, %v, want ErrPortRangeFull when the configured range is full", port, err)
+	}
+}
+
+func TestAllocateNextPort_SkipsBoundPort(t *testing.T) {
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	busy := ln.LocalAddr().(*net.UDPAddr).Port
+	if busy >= 65534 || !IsPortFree(busy+1) {
+		t.Skip("no free neighbouring port")
+	}
+
+	cfg := &Config{Ports: PortsConfig{Start: busy, End: busy + 1}}
+	if port, _ := cfg.AllocateNextPort(); port != busy+1 {
+		t.Errorf("AllocateNextPort() = %d, want %d (port %d is bound)", port, busy+1, busy)
+	}
+}
+
+func TestPortsConfig_Range(t *testing.T) {
+	start, end := PortsConfig{}.Range()
+	if start != DefaultPortStart || end != DefaultPortEnd {
+		t.Errorf("Range() = %d-%d, want %d-%d", start, end, DefaultPortStart, DefaultPortEnd)
+	}
+	start, end = PortsConfig{Start: 7000}.Range()
+	if start != 7000 || end != 7000+DefaultPortEnd-DefaultPortStart {
+		t.Errorf("Range() = %d-%d", start, end)
+	}
+}
+
 func TestEnsureBuiltinBackends(t *testing.T) {
 	cfg := &Config{
 		Proxy: ProxyConfig{Port: 1080},
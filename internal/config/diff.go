@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change kinds reported by Diff.
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "changed"
+)
+
+// Change is one difference between two configs: a tunnel or backend added,
+// removed or changed, or a top-level section such as route or firewall that
+// changed.
+type Change struct {
+	Kind    string   // ChangeAdded, ChangeRemoved or ChangeModified
+	Section string   // "tunnel", "backend" or the JSON name of a section
+	Tag     string   // the tunnel or backend, empty for a section
+	Fields  []string // the JSON names of the changed fields, if any
+}
+
+func (c Change) String() string {
+	s := c.Section
+	if c.Tag != "" {
+		s += " " + c.Tag
+	}
+	s += " " + c.Kind
+	if len(c.Fields) > 0 {
+		s += ": " + strings.Join(c.Fields, ", ")
+	}
+	return s
+}
+
+// Diff lists what changes from old to new: tunnels and backends by tag in
+// the order of the configs, then the other sections in alphabetical order.
+func Diff(old, new *Config) ([]Change, error) {
+	tunnels, err := diffTagged("tunnel", byTag(old.Tunnels, tunnelTag), byTag(new.Tunnels, tunnelTag))
+	if err != nil {
+		return nil, err
+	}
+	backends, err := diffTagged("backend", byTag(old.Backends, backendTag), byTag(new.Backends, backendTag))
+	if err != nil {
+		return nil, err
+	}
+	changes := append(tunnels, backends...)
+
+	oldSections, err := jsonFields(old)
+	if err != nil {
+		return nil, err
+	}
+	newSections, err := jsonFields(new)
+	if err != nil {
+		return nil, err
+	}
+	delete(oldSections, "tunnels")
+	delete(oldSections, "backends")
+	delete(newSections, "tunnels")
+	delete(newSections, "backends")
+	for _, name := range changedKeys(oldSections, newSections) {
+		c := Change{Kind: ChangeModified, Section: name}
+		// Sections that are objects on both sides list their changed fields
+		oldFields, errOld := rawFields(oldSections[name])
+		newFields, errNew := rawFields(newSections[name])
+		if errOld == nil && errNew == nil {
+			c.Fields = changedKeys(oldFields, newFields)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// tagged holds the tunnels or backends of a config by tag, in order.
+type tagged struct {
+	tags  []string
+	items map[string]any
+}
+
+func tunnelTag(t *TunnelConfig) string   { return t.Tag }
+func backendTag(b *BackendConfig) string { return b.Tag }
+
+func byTag[T any](items []T, tag func(*T) string) tagged {
+	t := tagged{items: make(map[string]any)}
+	for i := range items {
+		name := tag(&items[i])
+		t.tags = append(t.tags, name)
+		t.items[name] = &items[i]
+	}
+	return t
+}
+
+// diffTagged compares the tunnels or backends of two configs by tag.
+func diffTagged(section string, old, new tagged) ([]Change, error) {
+	var changes []Change
+	for _, tag := range old.tags {
+		n, ok := new.items[tag]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Section: section, Tag: tag})
+			continue
+		}
+		oldFields, err := jsonFields(old.items[tag])
+		if err != nil {
+			return nil, err
+		}
+		newFields, err := jsonFields(n)
+		if err != nil {
+			return nil, err
+		}
+		if fields := changedKeys(oldFields, newFields); len(fields) > 0 {
+			changes = append(changes, Change{Kind: ChangeModified, Section: section, Tag: tag, Fields: fields})
+		}
+	}
+	for _, tag := range new.tags {
+		if _, ok := old.items[tag]; !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Section: section, Tag: tag})
+		}
+	}
+	return changes, nil
+}
+
+// jsonFields returns the JSON encoding of each field of v.
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare configs: %w", err)
+	}
+	return rawFields(data)
+}
+
+func rawFields(data json.RawMessage) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if len(data) == 0 {
+		return fields, nil
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// changedKeys returns the sorted keys whose values differ between a and b,
+// including those only one of them has.
+func changedKeys(a, b map[string]json.RawMessage) []string {
+	var keys []string
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !bytes.Equal(v, w) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := Default()
+	old.Backends = []BackendConfig{
+		{Tag: "socks", Type: BackendSOCKS, Address: "127.0.0.1:1080"},
+		{Tag: "web", Type: BackendCustom, Address: "127.0.0.1:8080"},
+	}
+	old.Tunnels = []TunnelConfig{
+		{Tag: "slip1", Transport: TransportSlipstream, Backend: "socks", Domain: "t1.example.com", Port: 5310},
+		{Tag: "dnstt1", Transport: TransportDNSTT, Backend: "socks", Domain: "t2.example.com", Port: 5311},
+	}
+
+	new := Default()
+	new.Backends = old.Backends[:1]
+	new.Tunnels = []TunnelConfig{
+		{Tag: "slip1", Transport: TransportSlipstream, Backend: "socks", Domain: "t3.example.com", Port: 5320},
+		{Tag: "slip2", Transport: TransportSlipstream, Backend: "socks", Domain: "t4.example.com", Port: 5312},
+	}
+	new.Route.Mode = "multi"
+	new.Privacy = &PrivacyConfig{IP: PrivacyTruncate}
+
+	changes, err := Diff(old, new)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"tunnel slip1 changed: domain, port",
+		"tunnel dnstt1 removed",
+		"tunnel slip2 added",
+		"backend web removed",
+		"privacy changed: ip",
+		"route changed: mode",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() =\n%q\nwant\n%q", got, want)
+	}
+
+	if changes, _ := Diff(old, old); len(changes) != 0 {
+		t.Errorf("Diff() of a config with itself = %v", changes)
+	}
+}
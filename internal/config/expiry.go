@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiryConfig ends a tunnel's life, e.g. for trial accounts or time-boxed
+// access. Once At has passed the tunnel is stopped and disabled; with Remove
+// it is also removed after Grace, which leaves time to extend it.
+type ExpiryConfig struct {
+	At     string `json:"at"`              // RFC 3339 time, or a date meaning the end of that day
+	Grace  string `json:"grace,omitempty"` // e.g. "72h" or "7d"; removal waits this long
+	Remove bool   `json:"remove,omitempty"`
+}
+
+// Parse returns the expiry time and the grace period before removal.
+func (e *ExpiryConfig) Parse() (time.Time, time.Duration, error) {
+	at, err := ParseExpiryTime(e.At)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	var grace time.Duration
+	if e.Grace != "" {
+		if grace, err = ParseDays(e.Grace); err != nil || grace < 0 {
+			return time.Time{}, 0, fmt.Errorf("invalid expiry grace '%s': use a duration like 72h or 7d", e.Grace)
+		}
+	}
+	return at, grace, nil
+}
+
+// Expired reports whether the expiry time has passed. Tunnels without an
+// expiry, or with an invalid one, never expire.
+func (e *ExpiryConfig) Expired(now time.Time) bool {
+	if e == nil {
+		return false
+	}
+	at, _, err := e.Parse()
+	return err == nil && !now.Before(at)
+}
+
+// Removable reports whether the tunnel is due for removal: it is set to be
+// removed and its grace period after expiry has passed.
+func (e *ExpiryConfig) Removable(now time.Time) bool {
+	if e == nil || !e.Remove {
+		return false
+	}
+	at, grace, err := e.Parse()
+	return err == nil && !now.Before(at.Add(grace))
+}
+
+// ParseExpiryTime parses an RFC 3339 time, or a YYYY-MM-DD date meaning the
+// end of that day in local time.
+func ParseExpiryTime(s string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, s); err == nil {
+		return at, nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry time '%s': use a date like 2026-12-31 or a time like 2026-12-31T18:00:00Z", s)
+}
+
+// ParseDays parses a duration, also accepting whole days such as "30d".
+func ParseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExpiryTime(t *testing.T) {
+	got, err := ParseExpiryTime("2026-12-31T18:00:00Z")
+	if err != nil || !got.Equal(time.Date(2026, 12, 31, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339: got %v, %v", got, err)
+	}
+	got, err = ParseExpiryTime("2026-12-31")
+	if err != nil || !got.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("date: got %v, %v, want end of day", got, err)
+	}
+	if _, err := ParseExpiryTime("next week"); err == nil {
+		t.Error("ParseExpiryTime accepted an invalid time")
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"72h", 72 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"xd", 0, true},
+		{"1.5d", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDays(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDays(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestExpiredAndRemovable(t *testing.T) {
+	at := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	e := &ExpiryConfig{At: at.Format(time.RFC3339), Grace: "7d", Remove: true}
+
+	if e.Expired(at.Add(-time.Second)) {
+		t.Error("expired before its time")
+	}
+	if !e.Expired(at) {
+		t.Error("not expired at its time")
+	}
+	if e.Removable(at.Add(6 * 24 * time.Hour)) {
+		t.Error("removable inside the grace period")
+	}
+	if !e.Removable(at.Add(7 * 24 * time.Hour)) {
+		t.Error("not removable after the grace period")
+	}
+
+	e.Remove = false
+	if e.Removable(at.Add(30 * 24 * time.Hour)) {
+		t.Error("removable without remove set")
+	}
+
+	var none *ExpiryConfig
+	if none.Expired(at) || none.Removable(at) {
+		t.Error("nil expiry expired")
+	}
+}
+
+func TestValidate_Expiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		rescue  bool
+		expiry  ExpiryConfig
+		wantErr string
+	}{
+		{"valid", false, ExpiryConfig{At: "2026-12-31", Grace: "7d", Remove: true}, ""},
+		{"bad time", false, ExpiryConfig{At: "tomorrow"}, "invalid expiry time"},
+		{"bad grace", false, ExpiryConfig{At: "2026-12-31", Grace: "a week"}, "invalid expiry grace"},
+		{"rescue", true, ExpiryConfig{At: "2026-12-31"}, "rescue tunnel cannot expire"},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Backends = []BackendConfig{{Tag: "socks", Type: BackendSOCKS, Address: "127.0.0.1:1080"}}
+		expiry := tt.expiry
+		cfg.Tunnels = []TunnelConfig{{
+			Tag: "a", Transport: TransportDNSTT, Backend: "socks", Domain: "t.example.com", Port: 5310,
+			Rescue: tt.rescue, Expiry: &expiry,
+		}}
+		err := cfg.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Validate() = %v", tt.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Validate() = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import "fmt"
+
+// Footprint is the expected resource use of a running tunnel.
+type Footprint struct {
+	Memory uint64  // resident memory in bytes
+	CPU    float64 // CPU cores used under sustained load
+}
+
+// transportFootprints are rough figures for one tunnel server carrying a
+// handful of busy clients, measured on small x86 servers. They are meant
+// for warnings, not for capacity planning.
+var transportFootprints = map[TransportType]Footprint{
+	TransportSlipstream: {Memory: 48 << 20, CPU: 0.35}, // QUIC with congestion control per client
+	TransportDNSTT:      {Memory: 32 << 20, CPU: 0.25},
+	TransportVayDNS:     {Memory: 40 << 20, CPU: 0.25}, // larger queues than dnstt
+	TransportChisel:     {Memory: 24 << 20, CPU: 0.10}, // no DNS encoding
+}
+
+// defaultFootprint is assumed for transports without figures.
+var defaultFootprint = Footprint{Memory: 48 << 20, CPU: 0.35}
+
+// EstimateFootprint estimates the resource use of a tunnel of transport t.
+func EstimateFootprint(t TransportType) Footprint {
+	if f, ok := transportFootprints[t]; ok {
+		return f
+	}
+	return defaultFootprint
+}
+
+// SystemLoad is what a server has to spare.
+type SystemLoad struct {
+	CPUs         int
+	Load1        float64 // one minute load average
+	MemAvailable uint64  // bytes
+}
+
+// memoryHeadroom is kept free for the system, page cache and the SOCKS
+// proxy, which grows with the number of connections.
+const memoryHeadroom = 64 << 20
+
+// Oversubscribed returns the reasons why running something with footprint
+// f would likely oversubscribe a server under load, or nil if it fits.
+func (f Footprint) Oversubscribed(load SystemLoad) []string {
+	var reasons []string
+	if f.Memory+memoryHeadroom > load.MemAvailable {
+		reasons = append(reasons, fmt.Sprintf("only %d MB of memory available, about %d MB needed",
+			load.MemAvailable>>20, (f.Memory+memoryHeadroom)>>20))
+	}
+	if load.CPUs > 0 && load.Load1+f.CPU > float64(load.CPUs) {
+		reasons = append(reasons, fmt.Sprintf("load average %.2f on %d CPUs leaves no room for another %.2f",
+			load.Load1, load.CPUs, f.CPU))
+	}
+	return reasons
+}
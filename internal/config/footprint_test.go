@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestEstimateFootprint(t *testing.T) {
+	for _, transport := range GetTransportTypes() {
+		f := EstimateFootprint(transport)
+		if f.Memory == 0 || f.CPU <= 0 {
+			t.Errorf("%s: empty footprint %+v", transport, f)
+		}
+	}
+	if f := EstimateFootprint("custom"); f != defaultFootprint {
+		t.Errorf("unknown transport: got %+v, want the default", f)
+	}
+}
+
+func TestFootprintOversubscribed(t *testing.T) {
+	f := Footprint{Memory: 32 << 20, CPU: 0.25}
+	tests := []struct {
+		name string
+		load SystemLoad
+		want int
+	}{
+		{"idle", SystemLoad{CPUs: 2, Load1: 0.1, MemAvailable: 1 << 30}, 0},
+		{"low memory", SystemLoad{CPUs: 2, Load1: 0.1, MemAvailable: 80 << 20}, 1},
+		{"busy cpu", SystemLoad{CPUs: 1, Load1: 0.9, MemAvailable: 1 << 30}, 1},
+		{"both", SystemLoad{CPUs: 1, Load1: 1.5, MemAvailable: 16 << 20}, 2},
+		{"cpus unknown", SystemLoad{Load1: 4, MemAvailable: 1 << 30}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Oversubscribed(tt.load); len(got) != tt.want {
+				t.Errorf("got %q, want %d reasons", got, tt.want)
+			}
+		})
+	}
+}
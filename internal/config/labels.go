@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxLabelLength bounds label keys and values.
+const maxLabelLength = 63
+
+// labelPattern matches label keys and non-empty values: letters, digits and
+// "-_./", starting and ending with a letter or digit.
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+// ValidateLabel checks a label key and value, e.g. region=eu.
+func ValidateLabel(key, value string) error {
+	if len(key) > maxLabelLength || !labelPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: use up to %d letters, digits and -_./", key, maxLabelLength)
+	}
+	if value != "" && (len(value) > maxLabelLength || !labelPattern.MatchString(value)) {
+		return fmt.Errorf("invalid value %q for label %s: use up to %d letters, digits and -_./", value, key, maxLabelLength)
+	}
+	return nil
+}
+
+// ParseLabels parses comma-separated key=value pairs, e.g.
+// "region=eu,customer=acme".
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range splitList(s) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid label %q: expected key=value", pair)
+		}
+		if err := ValidateLabel(key, value); err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// FormatLabels returns labels as sorted, comma-separated key=value pairs.
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Selector matches tunnels by their labels. All of its requirements must
+// hold; an empty selector matches every tunnel.
+type Selector []labelRequirement
+
+// labelRequirement is one comma-separated term of a selector.
+type labelRequirement struct {
+	key    string
+	value  string
+	negate bool // != for a value, ! for existence
+	exists bool // only the key is tested
+}
+
+// ParseSelector parses a label selector such as "region=eu,customer!=acme".
+// Terms are key=value (or key==value), key!=value, key (has the label) and
+// !key (does not have it).
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, term := range splitList(s) {
+		var req labelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			req.key, req.value, _ = strings.Cut(term, "!=")
+			req.negate = true
+		case strings.Contains(term, "="):
+			req.key, req.value, _ = strings.Cut(term, "=")
+			req.value = strings.TrimPrefix(req.value, "=")
+		case strings.HasPrefix(term, "!"):
+			req.key = strings.TrimSpace(term[1:])
+			req.negate, req.exists = true, true
+		default:
+			req.key = term
+			req.exists = true
+		}
+		req.key, req.value = strings.TrimSpace(req.key), strings.TrimSpace(req.value)
+		if err := ValidateLabel(req.key, req.value); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// Matches reports whether labels satisfy every requirement of the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.key]
+		var match bool
+		if req.exists {
+			match = ok
+		} else {
+			match = ok && value == req.value
+		}
+		if match == req.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectTunnels returns the tunnels whose labels match sel, in config order.
+func (c *Config) SelectTunnels(sel Selector) []*TunnelConfig {
+	var tunnels []*TunnelConfig
+	for i := range c.Tunnels {
+		if sel.Matches(c.Tunnels[i].Labels) {
+			tunnels = append(tunnels, &c.Tunnels[i])
+		}
+	}
+	return tunnels
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
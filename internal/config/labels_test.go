@@ -0,0 +1,85 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels("region=eu, customer=acme,tier=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"region": "eu", "customer": "acme", "tier": ""}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("ParseLabels() = %v, want %v", labels, want)
+	}
+	if got := FormatLabels(labels); got != "customer=acme,region=eu,tier=" {
+		t.Errorf("FormatLabels() = %q", got)
+	}
+
+	for _, bad := range []string{"region", "-x=1", "region=eu west", "k=" + strings.Repeat("v", 64)} {
+		if _, err := ParseLabels(bad); err == nil {
+			t.Errorf("ParseLabels(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestSelector(t *testing.T) {
+	eu := map[string]string{"region": "eu", "customer": "acme"}
+	us := map[string]string{"region": "us"}
+
+	tests := []struct {
+		selector string
+		eu, us   bool
+		none     bool
+	}{
+		{"", true, true, true},
+		{"region=eu", true, false, false},
+		{"region==eu", true, false, false},
+		{"region!=eu", false, true, true},
+		{"customer", true, false, false},
+		{"!customer", false, true, true},
+		{"region=eu,customer=acme", true, false, false},
+		{"region=eu,customer!=acme", false, false, false},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Errorf("ParseSelector(%q) error = %v", tt.selector, err)
+			continue
+		}
+		if got := sel.Matches(eu); got != tt.eu {
+			t.Errorf("%q matches eu = %v, want %v", tt.selector, got, tt.eu)
+		}
+		if got := sel.Matches(us); got != tt.us {
+			t.Errorf("%q matches us = %v, want %v", tt.selector, got, tt.us)
+		}
+		if got := sel.Matches(nil); got != tt.none {
+			t.Errorf("%q matches unlabeled = %v, want %v", tt.selector, got, tt.none)
+		}
+	}
+
+	for _, bad := range []string{"region=eu west", "!", "=eu"} {
+		if _, err := ParseSelector(bad); err == nil {
+			t.Errorf("ParseSelector(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestSelectTunnels(t *testing.T) {
+	cfg := &Config{Tunnels: []TunnelConfig{
+		{Tag: "a", Labels: map[string]string{"customer": "acme"}},
+		{Tag: "b"},
+		{Tag: "c", Labels: map[string]string{"customer": "acme"}},
+	}}
+	sel, _ := ParseSelector("customer=acme")
+	var tags []string
+	for _, tun := range cfg.SelectTunnels(sel) {
+		tags = append(tags, tun.Tag)
+	}
+	if !reflect.DeepEqual(tags, []string{"a", "c"}) {
+		t.Errorf("SelectTunnels() = %v, want [a c]", tags)
+	}
+}
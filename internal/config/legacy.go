@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// legacyConfig represents the old router config format, where each entry in
+// the transports map bundled its transport settings with its upstream target.
+type legacyConfig struct {
+	Mode       string                           `json:"mode,omitempty"`
+	Active     string                           `json:"active,omitempty"`
+	Listen     string                           `json:"listen,omitempty"`
+	Transports map[string]legacyTransportConfig `json:"transports"`
+}
+
+// legacyTransportConfig represents a single entry of the legacy transports map.
+type legacyTransportConfig struct {
+	Type        string             `json:"type"`
+	Domain      string             `json:"domain"`
+	Port        int                `json:"port,omitempty"`
+	Enabled     *bool              `json:"enabled,omitempty"`
+	Target      *legacyTarget      `json:"target,omitempty"`
+	Shadowsocks *ShadowsocksConfig `json:"shadowsocks,omitempty"`
+	Slipstream  *SlipstreamConfig  `json:"slipstream,omitempty"`
+	DNSTT       *DNSTTConfig       `json:"dnstt,omitempty"`
+}
+
+// legacyTarget is the upstream address a legacy transport forwarded to.
+type legacyTarget struct {
+	Address string `json:"address"`
+}
+
+// Legacy transport type names.
+const (
+	legacyTypeSlipstreamShadowsocks = "slipstream-shadowsocks"
+	legacyTypeSlipstreamSocks       = "slipstream-socks"
+	legacyTypeSlipstreamSSH         = "slipstream-ssh"
+	legacyTypeDNSTTSocks            = "dnstt-socks"
+	legacyTypeDNSTTSSH              = "dnstt-ssh"
+)
+
+// isLegacyConfig reports whether data uses the legacy transports map format.
+func isLegacyConfig(data []byte) bool {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return false
+	}
+	_, hasTransports := raw["transports"]
+	_, hasTunnels := raw["tunnels"]
+	return hasTransports && !hasTunnels
+}
+
+// parseLegacyConfig converts a legacy transports map config into the unified
+// Backends/Tunnels model. Targets shared by several transports become a single
+// backend referenced by each tunnel.
+func parseLegacyConfig(data []byte) (*Config, error) {
+	var old legacyConfig
+	if err := json.Unmarshal(data, &old); err != nil {
+		return nil, invalid(fmt.Errorf("failed to parse legacy config: %w", err))
+	}
+
+	cfg := Default()
+	if old.Listen != "" {
+		cfg.Listen.Address = old.Listen
+	}
+	if old.Mode != "" {
+		cfg.Route.Mode = old.Mode
+	}
+
+	// Iterate in a stable order so generated backend tags are deterministic
+	tags := make([]string, 0, len(old.Transports))
+	for tag := range old.Transports {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	backendByAddr := make(map[string]string)
+	for _, tag := range tags {
+		t := old.Transports[tag]
+
+		tunnel := TunnelConfig{
+			Tag:     tag,
+			Enabled: t.Enabled,
+			Domain:  t.Domain,
+			Port:    t.Port,
+		}
+
+		switch t.Type {
+		case legacyTypeSlipstreamShadowsocks:
+			tunnel.Transport = TransportSlipstream
+			tunnel.Slipstream = t.Slipstream
+			if t.Shadowsocks == nil {
+				return nil, fmt.Errorf("legacy transport '%s': shadowsocks settings are required", tag)
+			}
+			backendTag := tag + "-ss"
+			cfg.Backends = append(cfg.Backends, BackendConfig{
+				Tag:         backendTag,
+				Type:        BackendShadowsocks,
+				Shadowsocks: t.Shadowsocks,
+			})
+			tunnel.Backend = backendTag
+		case legacyTypeSlipstreamSocks, legacyTypeSlipstreamSSH, legacyTypeDNSTTSocks, legacyTypeDNSTTSSH:
+			if t.Type == legacyTypeDNSTTSocks || t.Type == legacyTypeDNSTTSSH {
+				tunnel.Transport = TransportDNSTT
+				tunnel.DNSTT = t.DNSTT
+			} else {
+				tunnel.Transport = TransportSlipstream
+				tunnel.Slipstream = t.Slipstream
+			}
+			if t.Target == nil || t.Target.Address == "" {
+				return nil, fmt.Errorf("legacy transport '%s': target address is required", tag)
+			}
+			backendTag, ok := backendByAddr[t.Target.Address]
+			if !ok {
+				backendTag = tag + "-target"
+				cfg.Backends = append(cfg.Backends, BackendConfig{
+					Tag:     backendTag,
+					Type:    BackendCustom,
+					Address: t.Target.Address,
+				})
+				backendByAddr[t.Target.Address] = backendTag
+			}
+			tunnel.Backend = backendTag
+		default:
+			return nil, fmt.Errorf("legacy transport '%s': unknown type '%s'", tag, t.Type)
+		}
+
+		cfg.Tunnels = append(cfg.Tunnels, tunnel)
+	}
+
+	if old.Active != "" {
+		if _, ok := old.Transports[old.Active]; ok {
+			if cfg.IsSingleMode() {
+				cfg.Route.Active = old.Active
+			} else {
+				cfg.Route.Default = old.Active
+			}
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLegacyConfig_Load(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	legacy := `{
+  "mode": "multi",
+  "active": "ss1",
+  "listen": "0.0.0.0:53",
+  "transports": {
+    "ss1": {
+      "type": "slipstream-shadowsocks",
+      "domain": "ss.example.com",
+      "port": 5310,
+      "shadowsocks": {"password": "secret", "method": "aes-256-gcm"}
+    },
+    "dn1": {
+      "type": "dnstt-ssh",
+      "domain": "t.example.com",
+      "port": 5311,
+      "target": {"address": "127.0.0.1:22"},
+      "dnstt": {"mtu": 1200}
+    },
+    "dn2": {
+      "type": "slipstream-ssh",
+      "domain": "s.example.com",
+      "port": 5312,
+      "target": {"address": "127.0.0.1:22"}
+    }
+  }
+}`
+	if err := os.WriteFile(configPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	cfg, err := LoadFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromPath failed: %v", err)
+	}
+
+	if !cfg.IsMultiMode() {
+		t.Errorf("Route.Mode = %q, want multi", cfg.Route.Mode)
+	}
+	if cfg.Route.Default != "ss1" {
+		t.Errorf("Route.Default = %q, want ss1", cfg.Route.Default)
+	}
+	if len(cfg.Tunnels) != 3 {
+		t.Fatalf("len(Tunnels) = %d, want 3", len(cfg.Tunnels))
+	}
+	// Shared target collapses into one backend plus the shadowsocks backend
+	if len(cfg.Backends) != 2 {
+		t.Fatalf("len(Backends) = %d, want 2", len(cfg.Backends))
+	}
+
+	dn1 := cfg.GetTunnelByTag("dn1")
+	dn2 := cfg.GetTunnelByTag("dn2")
+	if dn1 == nil || dn2 == nil {
+		t.Fatal("expected dn1 and dn2 tunnels")
+	}
+	if dn1.Transport != TransportDNSTT || dn1.GetMTU() != 1200 {
+		t.Errorf("dn1 = %+v, want dnstt with MTU 1200", dn1)
+	}
+	if dn1.Backend != dn2.Backend {
+		t.Errorf("dn1 backend %q != dn2 backend %q, want shared backend", dn1.Backend, dn2.Backend)
+	}
+
+	ss1 := cfg.GetTunnelByTag("ss1")
+	if ss1 == nil {
+		t.Fatal("expected ss1 tunnel")
+	}
+	backend := cfg.GetBackendByTag(ss1.Backend)
+	if backend == nil || backend.Type != BackendShadowsocks || backend.Shadowsocks.Password != "secret" {
+		t.Errorf("ss1 backend = %+v, want shadowsocks backend", backend)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("converted config should validate: %v", err)
+	}
+}
+
+func TestLegacyConfig_RewrittenOnSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	legacy := `{"transports": {"t1": {"type": "dnstt-socks", "domain": "t.example.com", "target": {"address": "127.0.0.1:1080"}}}}`
+	if err := os.WriteFile(configPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	if err := UpdatePath(configPath, func(c *Config) error { return nil }); err != nil {
+		t.Fatalf("UpdatePath failed: %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if isLegacyConfig(data) {
+		t.Error("config should be saved in the current format")
+	}
+}
+
+func TestLegacyConfig_UnknownType(t *testing.T) {
+	_, err := parseLegacyConfig([]byte(`{"transports": {"t1": {"type": "bogus", "domain": "t.example.com"}}}`))
+	if err == nil {
+		t.Error("expected error for unknown legacy transport type")
+	}
+}
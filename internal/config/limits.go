@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMultiModeMinMemory is the memory below which multi mode is
+// refused: the DNS router and one process per tunnel do not fit next to
+// the system on smaller servers.
+const DefaultMultiModeMinMemory = 512 << 20
+
+// ErrLimit is wrapped by errors for changes that exceed a limit. Commands
+// that check limits can be told to ignore them.
+var ErrLimit = errors.New("limit exceeded")
+
+// LimitsConfig keeps operators from overcommitting small servers. Unlike
+// the admin policy, the limits are part of the config and may be ignored
+// for a single command with --ignore-limits.
+type LimitsConfig struct {
+	MaxTunnels           int    `json:"max_tunnels,omitempty"`             // 0 = unlimited
+	MaxTunnelsPerBackend int    `json:"max_tunnels_per_backend,omitempty"` // 0 = unlimited
+	MultiModeMinMemory   string `json:"multi_mode_min_memory,omitempty"`   // e.g. "512M" (default), "0" to allow any
+}
+
+// GetMultiModeMinMemory returns the memory multi mode needs in bytes, 0
+// when it is not limited.
+func (l LimitsConfig) GetMultiModeMinMemory() uint64 {
+	switch strings.TrimSpace(l.MultiModeMinMemory) {
+	case "":
+		return DefaultMultiModeMinMemory
+	case "0":
+		return 0
+	}
+	size, err := ParseByteSize(l.MultiModeMinMemory)
+	if err != nil {
+		return DefaultMultiModeMinMemory
+	}
+	return size
+}
+
+func (c *Config) validateLimits() error {
+	if c.Limits.MaxTunnels < 0 {
+		return fmt.Errorf("limits.max_tunnels must not be negative")
+	}
+	if c.Limits.MaxTunnelsPerBackend < 0 {
+		return fmt.Errorf("limits.max_tunnels_per_backend must not be negative")
+	}
+	if m := strings.TrimSpace(c.Limits.MultiModeMinMemory); m != "" && m != "0" {
+		if _, err := ParseByteSize(m); err != nil {
+			return fmt.Errorf("limits.multi_mode_min_memory: %w", err)
+		}
+	}
+	return nil
+}
+
+// CheckTunnelLimits checks that t may be added to the tunnels of c. The
+// rescue tunnel is not counted.
+func (c *Config) CheckTunnelLimits(t *TunnelConfig) error {
+	if t.Rescue {
+		return nil
+	}
+	total, sameBackend := 1, 1
+	for _, other := range c.Tunnels {
+		if other.Rescue || other.Tag == t.Tag {
+			continue
+		}
+		total++
+		if other.Backend == t.Backend {
+			sameBackend++
+		}
+	}
+	if limit := c.Limits.MaxTunnels; limit > 0 && total > limit {
+		return fmt.Errorf("%w: this server is limited to %d tunnels (limits.max_tunnels)", ErrLimit, limit)
+	}
+	if limit := c.Limits.MaxTunnelsPerBackend; limit > 0 && t.Backend != "" && sameBackend > limit {
+		return fmt.Errorf("%w: backend '%s' is limited to %d tunnels (limits.max_tunnels_per_backend)", ErrLimit, t.Backend, limit)
+	}
+	return nil
+}
+
+// CheckMultiModeMemory checks that a server with memTotal bytes of memory
+// may run in multi mode.
+func (c *Config) CheckMultiModeMemory(memTotal uint64) error {
+	if need := c.Limits.GetMultiModeMinMemory(); need > 0 && memTotal < need {
+		return fmt.Errorf("%w: multi mode needs %d MB of memory, this server has %d MB (limits.multi_mode_min_memory)",
+			ErrLimit, need>>20, memTotal>>20)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckTunnelLimits(t *testing.T) {
+	cfg := Default()
+	cfg.Tunnels = []TunnelConfig{
+		{Tag: "a", Transport: TransportDNSTT, Backend: "socks"},
+		{Tag: "b", Transport: TransportSlipstream, Backend: "ss"},
+		{Tag: RescueTag, Transport: TransportDNSTT, Backend: "ssh", Rescue: true},
+	}
+
+	tests := []struct {
+		name    string
+		limits  LimitsConfig
+		tunnel  TunnelConfig
+		wantErr string
+	}{
+		{"unlimited", LimitsConfig{}, TunnelConfig{Tag: "c", Backend: "socks"}, ""},
+		{"under max", LimitsConfig{MaxTunnels: 3}, TunnelConfig{Tag: "c", Backend: "socks"}, ""},
+		{"over max", LimitsConfig{MaxTunnels: 2}, TunnelConfig{Tag: "c", Backend: "socks"}, "limited to 2 tunnels"},
+		{"replacing", LimitsConfig{MaxTunnels: 2}, TunnelConfig{Tag: "a", Backend: "socks"}, ""},
+		{"rescue", LimitsConfig{MaxTunnels: 2}, TunnelConfig{Tag: RescueTag, Rescue: true}, ""},
+		{"per backend", LimitsConfig{MaxTunnelsPerBackend: 1}, TunnelConfig{Tag: "c", Backend: "socks"}, "backend 'socks' is limited to 1"},
+		{"other backend", LimitsConfig{MaxTunnelsPerBackend: 1}, TunnelConfig{Tag: "c", Backend: "custom"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Limits = tt.limits
+			err := cfg.CheckTunnelLimits(&tt.tunnel)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("CheckTunnelLimits() error = %v", err)
+			}
+			if tt.wantErr != "" && (!errors.Is(err, ErrLimit) || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("CheckTunnelLimits() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckMultiModeMemory(t *testing.T) {
+	cfg := Default()
+	if err := cfg.CheckMultiModeMemory(256 << 20); !errors.Is(err, ErrLimit) {
+		t.Errorf("256 MB with the default limit: error = %v, want ErrLimit", err)
+	}
+	if err := cfg.CheckMultiModeMemory(1 << 30); err != nil {
+		t.Errorf("1 GB: error = %v", err)
+	}
+	cfg.Limits.MultiModeMinMemory = "0"
+	if err := cfg.CheckMultiModeMemory(256 << 20); err != nil {
+		t.Errorf("limit off: error = %v", err)
+	}
+	cfg.Limits.MultiModeMinMemory = "2G"
+	if err := cfg.CheckMultiModeMemory(1 << 30); err == nil || !strings.Contains(err.Error(), "needs 2048 MB") {
+		t.Errorf("2G limit: error = %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockSuffix is appended to the config path to form the advisory lock file.
+const lockSuffix = ".lock"
+
+// fileLock is an advisory lock held on a sidecar lock file.
+type fileLock struct {
+	f *os.File
+}
+
+// acquireLock takes an advisory lock for the config file at path.
+// Exclusive locks are used by writers, shared locks by readers.
+func acquireLock(path string, exclusive bool) (*fileLock, error) {
+	lockPath := path + lockSuffix
+
+	flag := os.O_RDONLY | os.O_CREATE
+	if exclusive {
+		flag = os.O_RDWR | os.O_CREATE
+	}
+
+	f, err := os.OpenFile(lockPath, flag, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config lock: %w", err)
+	}
+
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock config: %w", err)
+	}
+
+	return &fileLock{f: f}, nil
+}
+
+// release drops the lock and closes the lock file.
+func (l *fileLock) release() {
+	if l == nil || l.f == nil {
+		return
+	}
+	unlockFile(l.f)
+	l.f.Close()
+	l.f = nil
+}
+
+// parseConfig decodes stored config data.
+// Configs in the legacy transports map format are converted on the fly and
+// are written back in the current format on the next save.
+func parseConfig(data []byte) (*Config, error) {
+	if isLegacyConfig(data) {
+		return parseLegacyConfig(data)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, invalid(fmt.Errorf("failed to parse config: %w", err))
+	}
+
+	return &cfg, nil
+}
+
+// writeFileAtomic replaces the file at path without taking a lock.
+// The data is written to a temporary file in the same directory and renamed
+// into place so readers never observe a partially written config.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to set config permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace config: %w", err)
+	}
+
+	return nil
+}
+
+// Update performs a locked read-modify-write of the system configuration.
+func Update(fn func(*Config) error) error {
+	s, err := currentStore()
+	if err != nil {
+		return err
+	}
+	return updateStore(s, fn)
+}
+
+// UpdatePath performs a locked read-modify-write of the configuration at path.
+// The exclusive lock is held from load until save, so concurrent invocations
+// cannot interleave and lose each other's changes. If fn returns an error the
+// file is left untouched.
+func UpdatePath(path string, fn func(*Config) error) error {
+	return updateStore(&fileStore{path: path}, fn)
+}
+
+// updateStore runs fn on the config held by s and saves the result.
+func updateStore(s Store, fn func(*Config) error) error {
+	return s.Update(func(data []byte) ([]byte, error) {
+		if data == nil {
+			return nil, fmt.Errorf("failed to read config: %s: %w", s, os.ErrNotExist)
+		}
+		cfg, err := parseConfig(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(cfg); err != nil {
+			return nil, err
+		}
+		return marshalConfig(cfg)
+	})
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultLogAlertThreshold is how many matching lines within one scan
+// mark a tunnel degraded.
+const DefaultLogAlertThreshold = 5
+
+// Log patterns shared by all transports.
+const (
+	patternBind   = `(?i)address already in use|bind: permission denied|cannot assign requested address`
+	patternResets = `(?i)connection reset by peer|broken pipe`
+	patternTLS    = `(?i)tls: |handshake (error|failed|timeout)|bad certificate|certificate (has expired|verify failed)`
+)
+
+// defaultLogPatterns are the error patterns scanned for per transport
+// unless log.alerts.patterns sets its own.
+var defaultLogPatterns = map[TransportType][]string{
+	TransportSlipstream: {patternBind, patternTLS, patternResets},
+	TransportDNSTT:      {patternBind, `(?i)handshake`, patternResets},
+	TransportVayDNS:     {patternBind, `(?i)handshake`, patternResets},
+	TransportChisel:     {patternBind, patternTLS, patternResets},
+}
+
+// LogAlertsConfig turns on scanning the tunnels' logs for errors. A tunnel
+// whose log matches a pattern threshold times within one scan is marked
+// degraded and the operator is alerted.
+type LogAlertsConfig struct {
+	Threshold int                 `json:"threshold,omitempty"` // default 5
+	Patterns  map[string][]string `json:"patterns,omitempty"`  // per transport, replacing the defaults
+}
+
+// GetThreshold returns the matches per scan that degrade a tunnel.
+func (a *LogAlertsConfig) GetThreshold() int {
+	if a.Threshold > 0 {
+		return a.Threshold
+	}
+	return DefaultLogAlertThreshold
+}
+
+// GetPatterns returns the regular expressions scanned for in the logs of
+// tunnels of transport t.
+func (a *LogAlertsConfig) GetPatterns(t TransportType) []string {
+	if patterns, ok := a.Patterns[string(t)]; ok {
+		return patterns
+	}
+	if patterns, ok := defaultLogPatterns[t]; ok {
+		return patterns
+	}
+	return []string{patternBind, patternResets}
+}
+
+func (c *Config) validateLogAlerts() error {
+	a := c.Log.Alerts
+	if a == nil {
+		return nil
+	}
+	if a.Threshold < 0 {
+		return fmt.Errorf("log.alerts.threshold must not be negative")
+	}
+	for transport, patterns := range a.Patterns {
+		if !IsKnownTransport(TransportType(transport)) {
+			return fmt.Errorf("log.alerts.patterns: unknown transport '%s'", transport)
+		}
+		for _, p := range patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return fmt.Errorf("log.alerts.patterns.%s: %w", transport, err)
+			}
+		}
+	}
+	return nil
+}
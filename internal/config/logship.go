@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Log shipping targets, chosen by the scheme of log.ship.url.
+const (
+	LogShipSyslog = "syslog" // udp://, tcp:// or tls://host[:port]
+	LogShipLoki   = "loki"   // http:// or https://host[:port][/path]
+)
+
+// LogShipConfig forwards the journal entries of dnstm's services, tunnels
+// included, to a remote syslog server or Loki. Each entry carries the
+// fields of the service it came from, such as the tunnel tag and transport.
+type LogShipConfig struct {
+	URL    string            `json:"url"`
+	Labels map[string]string `json:"labels,omitempty"` // added to every entry, e.g. server=eu-1
+}
+
+// Target returns LogShipSyslog or LogShipLoki, or "" for an unknown scheme.
+func (s *LogShipConfig) Target() string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+		return LogShipSyslog
+	case "http", "https":
+		return LogShipLoki
+	}
+	return ""
+}
+
+func (c *Config) validateLogShip() error {
+	s := c.Log.Ship
+	if s == nil {
+		return nil
+	}
+	if s.URL == "" {
+		return fmt.Errorf("log.ship.url is required")
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("log.ship.url: %w", err)
+	}
+	if s.Target() == "" {
+		return fmt.Errorf("log.ship.url: unknown scheme '%s' (use udp, tcp or tls for syslog, http or https for Loki)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("log.ship.url: host is required")
+	}
+	for key, value := range s.Labels {
+		if err := ValidateLabel(key, value); err != nil {
+			return fmt.Errorf("log.ship.labels: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"os/user"
+	"time"
+)
+
+// TunnelMeta records when and by whom a tunnel was created and last changed
+// through dnstm. Editing config.json by hand does not update it.
+type TunnelMeta struct {
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	ModifiedAt time.Time `json:"modified_at"`
+	ModifiedBy string    `json:"modified_by,omitempty"`
+}
+
+// MarkCreated records that the tunnel is being created now.
+func (t *TunnelConfig) MarkCreated() {
+	now, actor := time.Now().UTC().Truncate(time.Second), Actor()
+	t.Meta = &TunnelMeta{CreatedAt: now, CreatedBy: actor, ModifiedAt: now, ModifiedBy: actor}
+}
+
+// MarkModified records that the tunnel's configuration is being changed now.
+// Tunnels created before metadata was kept only get the modification.
+func (t *TunnelConfig) MarkModified() {
+	if t.Meta == nil {
+		t.Meta = &TunnelMeta{}
+	}
+	t.Meta.ModifiedAt = time.Now().UTC().Truncate(time.Second)
+	t.Meta.ModifiedBy = Actor()
+}
+
+// InheritMeta carries the description, notes and metadata of the tunnels in
+// old over to the tunnels with the same tag in c that have none, so loading
+// an edited or exported config keeps them. Tunnels new to c are marked
+// created.
+func (c *Config) InheritMeta(old *Config) {
+	for i := range c.Tunnels {
+		t := &c.Tunnels[i]
+		var prev *TunnelConfig
+		if old != nil {
+			prev = old.GetTunnelByTag(t.Tag)
+		}
+		if prev == nil {
+			if t.Meta == nil {
+				t.MarkCreated()
+			}
+			continue
+		}
+		if t.Description == "" {
+			t.Description = prev.Description
+		}
+		if t.Notes == "" {
+			t.Notes = prev.Notes
+		}
+		if t.Meta == nil && prev.Meta != nil {
+			meta := *prev.Meta
+			t.Meta = &meta
+		}
+	}
+}
+
+// Actor names who is running dnstm, as user@host: the user who invoked sudo
+// when there is one, so changes are not all attributed to root.
+func Actor() string {
+	name := os.Getenv("SUDO_USER")
+	if name == "" {
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+	}
+	if host, err := os.Hostname(); err == nil {
+		return name + "@" + host
+	}
+	return name
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkCreatedAndModified(t *testing.T) {
+	t.Setenv("SUDO_USER", "alice")
+
+	tun := &TunnelConfig{Tag: "a"}
+	tun.MarkModified()
+	if !tun.Meta.CreatedAt.IsZero() || tun.Meta.ModifiedAt.IsZero() {
+		t.Errorf("MarkModified on a tunnel without metadata: %+v", tun.Meta)
+	}
+
+	tun.MarkCreated()
+	m := tun.Meta
+	if m.CreatedAt.IsZero() || !m.CreatedAt.Equal(m.ModifiedAt) {
+		t.Errorf("MarkCreated: %+v", m)
+	}
+	if !strings.HasPrefix(m.CreatedBy, "alice") || m.ModifiedBy != m.CreatedBy {
+		t.Errorf("actor = %q/%q, want alice@host", m.CreatedBy, m.ModifiedBy)
+	}
+}
+
+func TestInheritMeta(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	old := &Config{Tunnels: []TunnelConfig{{
+		Tag:         "a",
+		Description: "customer acme",
+		Notes:       "line 1\nline 2",
+		Meta:        &TunnelMeta{CreatedAt: created, CreatedBy: "bob@h"},
+	}}}
+	cfg := &Config{Tunnels: []TunnelConfig{
+		{Tag: "a"},
+		{Tag: "b", Description: "new"},
+		{Tag: "c", Meta: &TunnelMeta{CreatedAt: created}},
+	}}
+	cfg.InheritMeta(old)
+
+	a := cfg.GetTunnelByTag("a")
+	if a.Description != "customer acme" || a.Notes != "line 1\nline 2" || a.Meta == nil || !a.Meta.CreatedAt.Equal(created) {
+		t.Errorf("a = %+v, want old description, notes and metadata", a)
+	}
+	if a.Meta == old.Tunnels[0].Meta {
+		t.Error("metadata should be copied, not shared")
+	}
+	if b := cfg.GetTunnelByTag("b"); b.Description != "new" || b.Meta == nil || b.Meta.CreatedAt.IsZero() {
+		t.Errorf("b = %+v, want marked created", b)
+	}
+	if c := cfg.GetTunnelByTag("c"); !c.Meta.CreatedAt.Equal(created) {
+		t.Errorf("c = %+v, want its own metadata kept", c.Meta)
+	}
+
+	// Without an old config, existing values are left alone
+	cfg.InheritMeta(nil)
+	if cfg.GetTunnelByTag("a").Description != "customer acme" {
+		t.Error("InheritMeta(nil) dropped a description")
+	}
+}
+
+func TestValidate_Description(t *testing.T) {
+	cfg := Default()
+	cfg.Backends = []BackendConfig{{Tag: "socks", Type: BackendSOCKS, Address: "127.0.0.1:1080"}}
+	cfg.Tunnels = []TunnelConfig{{
+		Tag: "a", Transport: TransportDNSTT, Backend: "socks", Domain: "t.example.com", Port: 5310,
+		Description: "two\nlines",
+	}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "single line") {
+		t.Errorf("Validate() = %v, want single line error", err)
+	}
+}
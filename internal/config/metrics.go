@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultMetricsRetention is how long recorded metrics are kept unless
+// metrics.retention says otherwise.
+const DefaultMetricsRetention = 30 * 24 * time.Hour
+
+// MetricsConfig turns on recording metrics (tunnels up, router queries,
+// tunnel traffic) every minute in the local time-series store, for
+// 'dnstm stats graph'.
+type MetricsConfig struct {
+	Retention string `json:"retention,omitempty"` // e.g. "30d", default 30 days
+}
+
+// GetRetention returns how long samples are kept.
+func (m *MetricsConfig) GetRetention() time.Duration {
+	if d, err := ParseDays(m.Retention); err == nil && d > 0 {
+		return d
+	}
+	return DefaultMetricsRetention
+}
+
+func (c *Config) validateMetrics() error {
+	m := c.Metrics
+	if m == nil || m.Retention == "" {
+		return nil
+	}
+	d, err := ParseDays(m.Retention)
+	if err != nil {
+		return fmt.Errorf("metrics.retention: %w", err)
+	}
+	if d < time.Hour {
+		return fmt.Errorf("metrics.retention must be at least 1h")
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A config path addresses one setting by the JSON names of its fields,
+// separated by dots. Entries of a list are selected by index or by a field
+// value, so "route.default", "tunnels[tag=main].dnstt.mtu" and
+// "backends[0].address" are paths.
+
+// pathStep is one field of a path, with the list selectors following it.
+type pathStep struct {
+	field     string
+	selectors []pathSelector
+}
+
+// pathSelector picks a list entry: by index when key is empty, else the
+// entry whose key field is value.
+type pathSelector struct {
+	index int
+	key   string
+	value string
+}
+
+func (s pathSelector) String() string {
+	if s.key == "" {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return fmt.Sprintf("[%s=%s]", s.key, s.value)
+}
+
+// parsePath splits a path into its steps.
+func parsePath(path string) ([]pathStep, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	var steps []pathStep
+	for _, part := range splitPath(path) {
+		name, rest := part, ""
+		if i := strings.Index(part, "["); i >= 0 {
+			name, rest = part[:i], part[i:]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid path %q: empty field name", path)
+		}
+		step := pathStep{field: name}
+		for rest != "" {
+			end := strings.Index(rest, "]")
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed [", path)
+			}
+			sel, err := parseSelector(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", path, err)
+			}
+			step.selectors = append(step.selectors, sel)
+			rest = rest[end+1:]
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// splitPath splits path at the dots outside selectors, which may hold
+// domains.
+func splitPath(path string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range path {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				parts = append(parts, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, path[start:])
+}
+
+func parseSelector(s string) (pathSelector, error) {
+	if key, value, ok := strings.Cut(s, "="); ok {
+		if key == "" || value == "" {
+			return pathSelector{}, fmt.Errorf("selector [%s] needs a field and a value", s)
+		}
+		return pathSelector{key: key, value: value}, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		return pathSelector{}, fmt.Errorf("selector [%s] is neither an index nor field=value", s)
+	}
+	return pathSelector{index: i}, nil
+}
+
+// GetPath returns the JSON encoding of the setting at path, or nil when it
+// is not set.
+func (c *Config) GetPath(path string) (json.RawMessage, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pathType(steps); err != nil {
+		return nil, err
+	}
+	tree, err := configTree(c)
+	if err != nil {
+		return nil, err
+	}
+
+	node := tree
+	for _, step := range steps {
+		obj, _ := node.(map[string]any)
+		if node = obj[step.field]; node == nil {
+			return nil, nil
+		}
+		for _, sel := range step.selectors {
+			if node, _, err = selectEntry(node, sel); err != nil {
+				return nil, fmt.Errorf("%s%s: %w", step.field, sel, err)
+			}
+		}
+	}
+	return json.Marshal(node)
+}
+
+// SetPath returns a copy of c with the setting at path set to value. A value
+// that is not JSON is taken as a string, as is any value of a text setting;
+// null clears the setting. Missing objects on the way are created, but list
+// entries must exist.
+func (c *Config) SetPath(path, value string) (*Config, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := pathType(steps)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseValue(typ, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", path, err)
+	}
+	tree, err := configTree(c)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := tree.(map[string]any)
+	for i, step := range steps {
+		last := i == len(steps)-1
+		if len(step.selectors) == 0 {
+			if last {
+				parent[step.field] = v
+				break
+			}
+			cur := parent[step.field]
+			next, ok := cur.(map[string]any)
+			if !ok {
+				if cur != nil {
+					return nil, fmt.Errorf("%s is not an object", step.field)
+				}
+				next = make(map[string]any)
+				parent[step.field] = next
+			}
+			parent = next
+			continue
+		}
+
+		node := parent[step.field]
+		for j, sel := range step.selectors {
+			entry, setEntry, err := selectEntry(node, sel)
+			if err != nil {
+				return nil, fmt.Errorf("%s%s: %w", step.field, sel, err)
+			}
+			if last && j == len(step.selectors)-1 {
+				if v == nil {
+					return nil, fmt.Errorf("%s: list entries cannot be cleared", path)
+				}
+				setEntry(v)
+			}
+			node = entry
+		}
+		if !last {
+			next, ok := node.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an object", step.field)
+			}
+			parent = next
+		}
+	}
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var updated Config
+	if err := json.Unmarshal(data, &updated); err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", path, err)
+	}
+	return &updated, nil
+}
+
+// pathType checks that steps name settings of Config and returns the type
+// of the last one.
+func pathType(steps []pathStep) (reflect.Type, error) {
+	typ := reflect.TypeOf(Config{})
+	var err error
+	for _, step := range steps {
+		if typ, err = fieldType(typ, step.field); err != nil {
+			return nil, err
+		}
+		for _, sel := range step.selectors {
+			for typ.Kind() == reflect.Pointer {
+				typ = typ.Elem()
+			}
+			if typ.Kind() != reflect.Slice {
+				return nil, fmt.Errorf("%s%s: %s is not a list", step.field, sel, step.field)
+			}
+			typ = typ.Elem()
+		}
+	}
+	return typ, nil
+}
+
+// configTree returns c as decoded JSON, keeping numbers as written.
+func configTree(c *Config) (any, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return tree, nil
+}
+
+// selectEntry returns the entry of list node picked by sel, and a function
+// replacing it.
+func selectEntry(node any, sel pathSelector) (any, func(any), error) {
+	list, ok := node.([]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("no such entry")
+	}
+	for i, entry := range list {
+		if sel.key == "" {
+			if i != sel.index {
+				continue
+			}
+		} else {
+			obj, _ := entry.(map[string]any)
+			if v, ok := obj[sel.key]; !ok || fmt.Sprint(v) != sel.value {
+				continue
+			}
+		}
+		return entry, func(v any) { list[i] = v }, nil
+	}
+	return nil, nil, fmt.Errorf("no such entry")
+}
+
+// fieldType returns the type of the field of struct typ with JSON name name.
+func fieldType(typ reflect.Type, name string) (reflect.Type, error) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Map {
+		return typ.Elem(), nil
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unknown setting %q", name)
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" && f.Anonymous {
+			if t, err := fieldType(f.Type, name); err == nil {
+				return t, nil
+			}
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if tag == name {
+			return f.Type, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown setting %q", name)
+}
+
+// parseValue decodes value for a setting of type typ.
+func parseValue(typ reflect.Type, value string) (any, error) {
+	if value == "null" {
+		return nil, nil
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		if typ.Kind() == reflect.String {
+			return value, nil
+		}
+		return nil, fmt.Errorf("%q is not valid JSON", value)
+	}
+	if _, ok := v.(string); !ok && typ.Kind() == reflect.String {
+		return value, nil
+	}
+	return v, nil
+}
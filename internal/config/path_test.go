@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func pathConfig() *Config {
+	cfg := Default()
+	cfg.Backends = []BackendConfig{{Tag: "socks", Type: BackendSOCKS, Address: "127.0.0.1:1080"}}
+	cfg.Tunnels = []TunnelConfig{
+		{Tag: "main", Transport: TransportDNSTT, Backend: "socks", Domain: "t.example.com", Port: 5310, DNSTT: &DNSTTConfig{MTU: 1232}},
+		{Tag: "slip1", Transport: TransportSlipstream, Backend: "socks", Domain: "s.example.com", Port: 5311},
+	}
+	cfg.Route.Default = "main"
+	return cfg
+}
+
+func TestGetPath(t *testing.T) {
+	cfg := pathConfig()
+	tests := map[string]string{
+		"route.default":                     `"main"`,
+		"tunnels[tag=main].dnstt.mtu":       `1232`,
+		"tunnels[1].port":                   `5311`,
+		"tunnels[domain=s.example.com].tag": `"slip1"`,
+		"backends[tag=socks].address":       `"127.0.0.1:1080"`,
+		"metrics":                           ``,
+		"tunnels[tag=slip1].slipstream":     ``,
+	}
+	for path, want := range tests {
+		got, err := cfg.GetPath(path)
+		if err != nil {
+			t.Errorf("GetPath(%q) error = %v", path, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("GetPath(%q) = %s, want %s", path, got, want)
+		}
+	}
+
+	for _, path := range []string{"route.nope", "tunnels.tag", "tunnels[tag=none].port", "route[0]", "tunnels[", ""} {
+		if _, err := cfg.GetPath(path); err == nil {
+			t.Errorf("GetPath(%q) succeeded", path)
+		}
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	cfg := pathConfig()
+
+	updated, err := cfg.SetPath("tunnels[tag=main].dnstt.mtu", "1400")
+	if err != nil {
+		t.Fatalf("SetPath() error = %v", err)
+	}
+	if updated.Tunnels[0].DNSTT.MTU != 1400 || cfg.Tunnels[0].DNSTT.MTU != 1232 {
+		t.Errorf("MTU = %d, original %d", updated.Tunnels[0].DNSTT.MTU, cfg.Tunnels[0].DNSTT.MTU)
+	}
+
+	// Text settings take any value, quoted or not
+	if updated, err = cfg.SetPath("route.default", "slip1"); err != nil || updated.Route.Default != "slip1" {
+		t.Errorf("SetPath(route.default) = %v, %v", updated.Route.Default, err)
+	}
+	if updated, err = cfg.SetPath("tunnels[tag=slip1].domain", `"1234"`); err != nil || updated.Tunnels[1].Domain != "1234" {
+		t.Errorf("SetPath(domain) = %v, %v", updated.Tunnels[1].Domain, err)
+	}
+
+	// Missing objects are created, null clears
+	if updated, err = cfg.SetPath("metrics.retention", "7d"); err != nil || updated.Metrics == nil {
+		t.Fatalf("SetPath(metrics.retention) error = %v", err)
+	}
+	if updated, err = updated.SetPath("metrics", "null"); err != nil || updated.Metrics != nil {
+		t.Errorf("SetPath(metrics, null) = %v, %v", updated.Metrics, err)
+	}
+
+	for path, value := range map[string]string{
+		"tunnels[tag=main].dnstt.mtu": "big",
+		"tunnels[tag=main].port":      `"5310"`,
+		"tunnels[tag=none].port":      "5312",
+		"tunnels[tag=main]":           "null",
+		"route.nope":                  "1",
+	} {
+		if _, err := cfg.SetPath(path, value); err == nil {
+			t.Errorf("SetPath(%q, %q) succeeded", path, value)
+		} else if strings.Contains(err.Error(), "failed to") {
+			t.Errorf("SetPath(%q, %q) error = %v", path, value, err)
+		}
+	}
+}
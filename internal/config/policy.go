@@ -0,0 +1,167 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PolicyFile is the admin policy. It lives next to the config but is not
+// part of it, so loading or replicating a config cannot change it.
+var PolicyFile = filepath.Join(ConfigDir, "policy.json")
+
+// ErrPolicy is wrapped by errors for tunnels the admin policy forbids.
+var ErrPolicy = errors.New("forbidden by policy")
+
+// Policy restricts what operators may deploy on a server. Empty fields
+// allow everything. The rescue tunnel is exempt: it is the server's
+// emergency access, not a deployment.
+type Policy struct {
+	Transports []TransportType `json:"transports,omitempty"`  // allowed transports
+	Backends   []BackendType   `json:"backends,omitempty"`    // allowed backend types of tunnels
+	MaxTunnels int             `json:"max_tunnels,omitempty"` // 0 = unlimited
+}
+
+// LoadPolicy reads the admin policy. A missing file is an empty policy; a
+// broken one is an error, so a typo never lifts the restrictions.
+func LoadPolicy() (*Policy, error) {
+	data, err := os.ReadFile(PolicyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", PolicyFile, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", PolicyFile, err)
+	}
+	return &p, nil
+}
+
+// Validate checks the policy names known transports and backend types.
+func (p *Policy) Validate() error {
+	for _, t := range p.Transports {
+		if !IsKnownTransport(t) {
+			return fmt.Errorf("transports: unknown transport '%s'", t)
+		}
+	}
+	for _, b := range p.Backends {
+		if GetBackendTypeInfo(b) == nil {
+			return fmt.Errorf("backends: unknown backend type '%s'", b)
+		}
+	}
+	if p.MaxTunnels < 0 {
+		return fmt.Errorf("max_tunnels must not be negative")
+	}
+	return nil
+}
+
+// IsEmpty reports whether the policy restricts nothing.
+func (p *Policy) IsEmpty() bool {
+	return len(p.Transports) == 0 && len(p.Backends) == 0 && p.MaxTunnels == 0
+}
+
+// AllowsTransport reports whether tunnels of transport t may be added.
+func (p *Policy) AllowsTransport(t TransportType) bool {
+	if len(p.Transports) == 0 {
+		return true
+	}
+	for _, allowed := range p.Transports {
+		if allowed == t {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsBackend reports whether tunnels may use backends of type b.
+func (p *Policy) AllowsBackend(b BackendType) bool {
+	if len(p.Backends) == 0 {
+		return true
+	}
+	for _, allowed := range p.Backends {
+		if allowed == b {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTunnel checks that t may be added to cfg, or replace the tunnel of
+// the same tag in it.
+func (p *Policy) CheckTunnel(cfg *Config, t *TunnelConfig) error {
+	if t.Rescue {
+		return nil
+	}
+	if !p.AllowsTransport(t.Transport) {
+		return fmt.Errorf("%w: %s tunnels are not allowed (allowed: %s)", ErrPolicy,
+			GetTransportTypeDisplayName(t.Transport), joinTypes(p.Transports))
+	}
+	if b := cfg.GetBackendByTag(t.Backend); b != nil && !p.AllowsBackend(b.Type) {
+		return fmt.Errorf("%w: %s backends are not allowed (allowed: %s)", ErrPolicy,
+			GetBackendTypeDisplayName(b.Type), joinTypes(p.Backends))
+	}
+	return p.checkRoom(cfg, t.Tag)
+}
+
+// CheckRoom checks that another tunnel may be added to cfg.
+func (p *Policy) CheckRoom(cfg *Config) error {
+	return p.checkRoom(cfg, "")
+}
+
+// checkRoom checks the tunnel limit, not counting the tunnel tagged
+// replacing, which is about to be replaced.
+func (p *Policy) checkRoom(cfg *Config, replacing string) error {
+	if p.MaxTunnels == 0 {
+		return nil
+	}
+	count := 1
+	for _, other := range cfg.Tunnels {
+		if !other.Rescue && (replacing == "" || other.Tag != replacing) {
+			count++
+		}
+	}
+	if count > p.MaxTunnels {
+		return fmt.Errorf("%w: at most %d tunnels are allowed", ErrPolicy, p.MaxTunnels)
+	}
+	return nil
+}
+
+// Check checks every tunnel of cfg against the policy.
+func (p *Policy) Check(cfg *Config) error {
+	count := 0
+	for i := range cfg.Tunnels {
+		t := &cfg.Tunnels[i]
+		if t.Rescue {
+			continue
+		}
+		count++
+		if !p.AllowsTransport(t.Transport) {
+			return fmt.Errorf("tunnel '%s': %w: %s tunnels are not allowed (allowed: %s)", t.Tag, ErrPolicy,
+				GetTransportTypeDisplayName(t.Transport), joinTypes(p.Transports))
+		}
+		if b := cfg.GetBackendByTag(t.Backend); b != nil && !p.AllowsBackend(b.Type) {
+			return fmt.Errorf("tunnel '%s': %w: %s backends are not allowed (allowed: %s)", t.Tag, ErrPolicy,
+				GetBackendTypeDisplayName(b.Type), joinTypes(p.Backends))
+		}
+	}
+	if p.MaxTunnels > 0 && count > p.MaxTunnels {
+		return fmt.Errorf("%w: %d tunnels configured, at most %d are allowed", ErrPolicy, count, p.MaxTunnels)
+	}
+	return nil
+}
+
+func joinTypes[T ~string](types []T) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, ", ")
+}
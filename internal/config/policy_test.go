@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func policyConfig() *Config {
+	cfg := Default()
+	cfg.EnsureBuiltinBackends()
+	cfg.Backends = append(cfg.Backends, BackendConfig{Tag: "ss", Type: BackendShadowsocks})
+	cfg.Tunnels = []TunnelConfig{
+		{Tag: "a", Transport: TransportSlipstream, Backend: "ss", Domain: "a.example.com"},
+		{Tag: "b", Transport: TransportDNSTT, Backend: "socks", Domain: "b.example.com"},
+		{Tag: RescueTag, Transport: TransportDNSTT, Backend: "ssh", Domain: "r.example.com", Rescue: true},
+	}
+	return cfg
+}
+
+func TestLoadPolicy(t *testing.T) {
+	old := PolicyFile
+	PolicyFile = filepath.Join(t.TempDir(), "policy.json")
+	t.Cleanup(func() { PolicyFile = old })
+
+	p, err := LoadPolicy()
+	if err != nil || !p.IsEmpty() {
+		t.Fatalf("LoadPolicy() without a file = %+v, %v; want empty policy", p, err)
+	}
+
+	tests := []struct {
+		data    string
+		wantErr string
+	}{
+		{`{"transports":["slipstream"],"backends":["shadowsocks"],"max_tunnels":2}`, ""},
+		{`{"transports":["dnstt2"]}`, "unknown transport"},
+		{`{"backends":["vpn"]}`, "unknown backend type"},
+		{`{"max_tunnels":-1}`, "negative"},
+		{`{"transports":`, "invalid policy"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(PolicyFile, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadPolicy()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("LoadPolicy(%s) error = %v", tt.data, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("LoadPolicy(%s) error = %v, want %q", tt.data, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPolicy_CheckTunnel(t *testing.T) {
+	cfg := policyConfig()
+	p := &Policy{
+		Transports: []TransportType{TransportSlipstream, TransportDNSTT},
+		Backends:   []BackendType{BackendShadowsocks, BackendSOCKS},
+		MaxTunnels: 3,
+	}
+
+	tests := []struct {
+		name    string
+		tunnel  TunnelConfig
+		wantErr string
+	}{
+		{"allowed", TunnelConfig{Tag: "c", Transport: TransportSlipstream, Backend: "ss"}, ""},
+		{"transport", TunnelConfig{Tag: "c", Transport: TransportVayDNS, Backend: "socks"}, "VayDNS tunnels are not allowed"},
+		{"backend", TunnelConfig{Tag: "c", Transport: TransportDNSTT, Backend: "ssh"}, "SSH backends are not allowed"},
+		{"rescue exempt", TunnelConfig{Tag: RescueTag, Transport: TransportVayDNS, Backend: "ssh", Rescue: true}, ""},
+		{"replacing", TunnelConfig{Tag: "b", Transport: TransportDNSTT, Backend: "socks"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.CheckTunnel(cfg, &tt.tunnel)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("CheckTunnel() error = %v", err)
+			}
+			if tt.wantErr != "" && (!errors.Is(err, ErrPolicy) || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("CheckTunnel() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	p.MaxTunnels = 2
+	if err := p.CheckRoom(cfg); !errors.Is(err, ErrPolicy) {
+		t.Errorf("CheckRoom() at the limit error = %v, want ErrPolicy", err)
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	cfg := policyConfig()
+	if err := (&Policy{}).Check(cfg); err != nil {
+		t.Errorf("empty policy: %v", err)
+	}
+	if err := (&Policy{MaxTunnels: 2}).Check(cfg); err != nil {
+		t.Errorf("rescue tunnel counted: %v", err)
+	}
+	if err := (&Policy{MaxTunnels: 1}).Check(cfg); !errors.Is(err, ErrPolicy) {
+		t.Errorf("over limit: error = %v", err)
+	}
+	err := (&Policy{Transports: []TransportType{TransportSlipstream}}).Check(cfg)
+	if err == nil || !strings.Contains(err.Error(), "tunnel 'b'") {
+		t.Errorf("forbidden transport: error = %v", err)
+	}
+}
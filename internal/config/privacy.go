@@ -0,0 +1,48 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// Ways of anonymizing client IP addresses.
+const (
+	PrivacyTruncate = "truncate" // keep the /24 of IPv4 and the /48 of IPv6
+	PrivacyHash     = "hash"     // replace with a keyed hash
+)
+
+// PrivacyConfig anonymizes the client IP addresses dnstm records: session
+// events, the resolver counters of the DNS router and shipped logs.
+type PrivacyConfig struct {
+	IP   string `json:"ip"`             // "truncate" or "hash"
+	Salt string `json:"salt,omitempty"` // hex key of the hash; the same salt gives the same hashes
+}
+
+// GenerateSalt returns a random key for hashing addresses.
+func GenerateSalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (c *Config) validatePrivacy() error {
+	p := c.Privacy
+	if p == nil {
+		return nil
+	}
+	switch p.IP {
+	case PrivacyTruncate:
+	case PrivacyHash:
+		// A short key lets the whole IPv4 space be hashed and compared
+		key, err := hex.DecodeString(p.Salt)
+		if err != nil || len(key) < 16 {
+			return fmt.Errorf("privacy.salt must be at least 32 hex characters with ip hash")
+		}
+	default:
+		return fmt.Errorf("privacy.ip must be %s or %s", PrivacyTruncate, PrivacyHash)
+	}
+	return nil
+}
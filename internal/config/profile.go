@@ -0,0 +1,100 @@
+package config
+
+import "fmt"
+
+// Server profiles tune the defaults to the size of the server.
+const (
+	ProfileTiny        = "tiny"
+	ProfileStandard    = "standard"
+	ProfilePerformance = "performance"
+)
+
+// DefaultMTU is the DNS packet MTU of DNSTT and VayDNS tunnels without a
+// profile that lowers it.
+const DefaultMTU = 1232
+
+// Profile holds the defaults a server profile applies.
+type Profile struct {
+	Name string
+	// Workers is the number of DNS router sockets, 0 for one per CPU.
+	Workers int
+	// MaxTunnels limits the tunnels, 0 for unlimited.
+	MaxTunnels int
+	// MTU is the default MTU of new DNSTT and VayDNS tunnels.
+	MTU int
+	// Transport is recommended for new tunnels, empty for none.
+	Transport TransportType
+}
+
+// profiles are the known profiles. Tiny is for 256-512 MB servers, where
+// every process and socket buffer counts: one DNS router socket, two
+// tunnels, DNSTT for its small footprint, and a smaller MTU so answers stay
+// clear of fragmentation on the cheap networks such servers sit on.
+var profiles = map[string]Profile{
+	ProfileTiny:        {Name: ProfileTiny, Workers: 1, MaxTunnels: 2, MTU: 1200, Transport: TransportDNSTT},
+	ProfileStandard:    {Name: ProfileStandard, MTU: DefaultMTU},
+	ProfilePerformance: {Name: ProfilePerformance, MTU: DefaultMTU, Transport: TransportSlipstream},
+}
+
+// Memory below which DetectProfile picks tiny, and from which (with
+// enough CPUs) performance.
+const (
+	tinyMaxMemory        = 768 << 20
+	performanceMinMemory = 4 << 30
+	performanceMinCPUs   = 4
+)
+
+// GetProfile returns the profile called name.
+func GetProfile(name string) (Profile, error) {
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q (must be %s, %s or %s)", name, ProfileTiny, ProfileStandard, ProfilePerformance)
+	}
+	return p, nil
+}
+
+// DetectProfile picks the profile for a server with memory bytes of memory
+// and cpus CPUs. Unknown memory gives standard.
+func DetectProfile(memory uint64, cpus int) Profile {
+	switch {
+	case memory == 0:
+		return profiles[ProfileStandard]
+	case memory < tinyMaxMemory:
+		return profiles[ProfileTiny]
+	case memory >= performanceMinMemory && cpus >= performanceMinCPUs:
+		return profiles[ProfilePerformance]
+	}
+	return profiles[ProfileStandard]
+}
+
+// ApplyProfile records p as the profile of c and sets the DNS router
+// workers and tunnel limit it implies.
+func (c *Config) ApplyProfile(p Profile) {
+	c.Profile = p.Name
+	c.Listen.Workers = p.Workers
+	c.Limits.MaxTunnels = p.MaxTunnels
+}
+
+// GetProfile returns the profile of c, standard when it has none.
+func (c *Config) GetProfile() Profile {
+	if p, ok := profiles[c.Profile]; ok {
+		return p
+	}
+	return profiles[ProfileStandard]
+}
+
+// DefaultMTU returns the MTU for new DNSTT and VayDNS tunnels.
+func (c *Config) DefaultMTU() int {
+	if mtu := c.GetProfile().MTU; mtu > 0 {
+		return mtu
+	}
+	return DefaultMTU
+}
+
+func (c *Config) validateProfile() error {
+	if c.Profile == "" {
+		return nil
+	}
+	_, err := GetProfile(c.Profile)
+	return err
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectProfile(t *testing.T) {
+	tests := []struct {
+		memory uint64
+		cpus   int
+		want   string
+	}{
+		{0, 1, ProfileStandard},
+		{512 << 20, 1, ProfileTiny},
+		{767 << 20, 8, ProfileTiny},
+		{1 << 30, 1, ProfileStandard},
+		{8 << 30, 2, ProfileStandard},
+		{4 << 30, 4, ProfilePerformance},
+	}
+	for _, tt := range tests {
+		if got := DetectProfile(tt.memory, tt.cpus).Name; got != tt.want {
+			t.Errorf("DetectProfile(%d MB, %d) = %s, want %s", tt.memory>>20, tt.cpus, got, tt.want)
+		}
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	cfg := Default()
+	if got := cfg.DefaultMTU(); got != DefaultMTU {
+		t.Errorf("DefaultMTU() without a profile = %d, want %d", got, DefaultMTU)
+	}
+
+	tiny, err := GetProfile(ProfileTiny)
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	cfg.ApplyProfile(tiny)
+	if cfg.Profile != ProfileTiny || cfg.Listen.Workers != 1 || cfg.Limits.MaxTunnels != 2 {
+		t.Errorf("ApplyProfile(tiny) = profile %q, %d workers, %d tunnels", cfg.Profile, cfg.Listen.Workers, cfg.Limits.MaxTunnels)
+	}
+	if got := cfg.DefaultMTU(); got != 1200 {
+		t.Errorf("DefaultMTU() with tiny = %d, want 1200", got)
+	}
+
+	standard, _ := GetProfile(ProfileStandard)
+	cfg.ApplyProfile(standard)
+	if cfg.Listen.Workers != 0 || cfg.Limits.MaxTunnels != 0 {
+		t.Errorf("ApplyProfile(standard) kept %d workers, %d tunnels", cfg.Listen.Workers, cfg.Limits.MaxTunnels)
+	}
+}
+
+func TestValidate_Profile(t *testing.T) {
+	cfg := &Config{Profile: ProfilePerformance}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	cfg.Profile = "huge"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+		t.Errorf("Validate() with profile huge error = %v, want unknown profile", err)
+	}
+	if _, err := GetProfile("huge"); err == nil {
+		t.Error("GetProfile(huge) succeeded")
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Quota account kinds. They match the usage accounting kinds.
+const (
+	QuotaSSH    = "ssh"
+	QuotaSocks  = "socks"
+	QuotaTunnel = "tunnel"
+)
+
+// QuotaConfig is a monthly traffic quota. When the account's traffic in the
+// current month reaches Limit, it is cut off until the next month or a
+// manual reset.
+type QuotaConfig struct {
+	Account string `json:"account"` // "ssh:<user>", "socks:<user>" or "tunnel:<tag>"
+	Limit   string `json:"limit"`   // e.g. "500M", "50G"
+}
+
+var quotaNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ParseQuotaAccount splits a quota account into its kind and name.
+func ParseQuotaAccount(account string) (string, string, error) {
+	kind, name, ok := strings.Cut(account, ":")
+	if !ok || !quotaNameRegex.MatchString(name) {
+		return "", "", fmt.Errorf("invalid account '%s' (expected ssh:<user>, socks:<user> or tunnel:<tag>)", account)
+	}
+	switch kind {
+	case QuotaSSH, QuotaSocks, QuotaTunnel:
+		return kind, name, nil
+	}
+	return "", "", fmt.Errorf("invalid account kind '%s' (expected ssh, socks or tunnel)", kind)
+}
+
+// ParseByteSize parses a size such as "512M", "50G" or "1TB". Units are
+// binary (1K = 1024 bytes); a plain number is a byte count.
+func ParseByteSize(s string) (uint64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+
+	multiplier := uint64(1)
+	if n := len(str); n > 0 {
+		if idx := strings.IndexByte("KMGT", str[n-1]); idx >= 0 {
+			multiplier = uint64(1) << (10 * (idx + 1))
+			str = str[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size '%s' (expected e.g. 500M or 50G)", s)
+	}
+	return uint64(value * float64(multiplier)), nil
+}
+
+// GetQuota returns the quota for an account, or nil if it has none.
+func (c *Config) GetQuota(account string) *QuotaConfig {
+	for i := range c.Quotas {
+		if c.Quotas[i].Account == account {
+			return &c.Quotas[i]
+		}
+	}
+	return nil
+}
+
+// RemoveQuota deletes the quota for an account, if any.
+func (c *Config) RemoveQuota(account string) {
+	quotas := c.Quotas[:0]
+	for _, q := range c.Quotas {
+		if q.Account != account {
+			quotas = append(quotas, q)
+		}
+	}
+	c.Quotas = quotas
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{in: "1024", want: 1024},
+		{in: "500M", want: 500 << 20},
+		{in: "50G", want: 50 << 30},
+		{in: "50GB", want: 50 << 30},
+		{in: "50GiB", want: 50 << 30},
+		{in: "1.5t", want: 3 << 39},
+		{in: "", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-5G", wantErr: true},
+		{in: "lots", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseByteSize(%q): expected error", tt.in)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidate_Quotas(t *testing.T) {
+	base := func(quotas ...QuotaConfig) *Config {
+		return &Config{
+			Backends: []BackendConfig{{Tag: "socks", Type: BackendSOCKS, Address: "127.0.0.1:1080"}},
+			Tunnels: []TunnelConfig{
+				{Tag: "main", Transport: TransportDNSTT, Backend: "socks", Domain: "t.example.com", Port: 5310},
+			},
+			Quotas: quotas,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{name: "valid", cfg: base(QuotaConfig{Account: "ssh:alice", Limit: "50G"}, QuotaConfig{Account: "tunnel:main", Limit: "1T"})},
+		{name: "bad kind", cfg: base(QuotaConfig{Account: "vpn:alice", Limit: "50G"}), wantErr: "invalid account kind"},
+		{name: "missing name", cfg: base(QuotaConfig{Account: "ssh:", Limit: "50G"}), wantErr: "invalid account"},
+		{name: "bad limit", cfg: base(QuotaConfig{Account: "ssh:alice", Limit: "plenty"}), wantErr: "invalid size"},
+		{name: "duplicate", cfg: base(QuotaConfig{Account: "ssh:alice", Limit: "1G"}, QuotaConfig{Account: "ssh:alice", Limit: "2G"}), wantErr: "duplicate quota"},
+		{name: "unknown tunnel", cfg: base(QuotaConfig{Account: "tunnel:gone", Limit: "1G"}), wantErr: "tunnel 'gone' does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoveQuota(t *testing.T) {
+	cfg := &Config{Quotas: []QuotaConfig{{Account: "ssh:alice", Limit: "1G"}, {Account: "tunnel:main", Limit: "1G"}}}
+	cfg.RemoveQuota("tunnel:main")
+	if len(cfg.Quotas) != 1 || cfg.GetQuota("ssh:alice") == nil {
+		t.Errorf("unexpected quotas after removal: %+v", cfg.Quotas)
+	}
+}
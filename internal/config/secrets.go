@@ -0,0 +1,133 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Secret formats.
+const (
+	SecretFormatBase64    = "base64"    // standard base64, may contain '+', '/' and '='
+	SecretFormatBase64URL = "base64url" // URL-safe base64 without padding
+	SecretFormatHex       = "hex"
+	SecretFormatChars     = "chars" // characters drawn from a charset
+)
+
+// Secret defaults.
+const (
+	DefaultSecretLength  = 32
+	DefaultSecretCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	minSecretLength      = 16
+	maxSecretLength      = 1024
+)
+
+// SecretsConfig sets how dnstm generates the passwords of Shadowsocks and
+// SOCKS backends. Some clients reject the '+' and '/' of the default
+// base64 passwords; base64url, hex or chars avoid them.
+type SecretsConfig struct {
+	Format  string `json:"format,omitempty"`  // base64 (default), base64url, hex or chars
+	Length  int    `json:"length,omitempty"`  // random bytes, or characters with chars; default 32
+	Charset string `json:"charset,omitempty"` // characters of the chars format; default letters and digits
+}
+
+// GetFormat returns the secret format, base64 when unset.
+func (s SecretsConfig) GetFormat() string {
+	if s.Format == "" {
+		return SecretFormatBase64
+	}
+	return s.Format
+}
+
+// GetLength returns the secret length, DefaultSecretLength when unset.
+func (s SecretsConfig) GetLength() int {
+	if s.Length == 0 {
+		return DefaultSecretLength
+	}
+	return s.Length
+}
+
+// GetCharset returns the characters of the chars format.
+func (s SecretsConfig) GetCharset() string {
+	if s.Charset == "" {
+		return DefaultSecretCharset
+	}
+	return s.Charset
+}
+
+// Validate checks the secret policy.
+func (s SecretsConfig) Validate() error {
+	switch s.GetFormat() {
+	case SecretFormatBase64, SecretFormatBase64URL, SecretFormatHex, SecretFormatChars:
+	default:
+		return fmt.Errorf("invalid format '%s', must be one of: base64, base64url, hex, chars", s.Format)
+	}
+	if n := s.GetLength(); n < minSecretLength || n > maxSecretLength {
+		return fmt.Errorf("length must be between %d and %d", minSecretLength, maxSecretLength)
+	}
+	if s.Charset == "" {
+		return nil
+	}
+	if s.GetFormat() != SecretFormatChars {
+		return fmt.Errorf("charset requires the chars format")
+	}
+	seen := make(map[rune]bool)
+	for _, r := range s.Charset {
+		if r <= ' ' || r > '~' {
+			return fmt.Errorf("charset must only contain printable ASCII characters other than space")
+		}
+		if seen[r] {
+			return fmt.Errorf("charset repeats '%c'", r)
+		}
+		seen[r] = true
+	}
+	if len(seen) < 2 {
+		return fmt.Errorf("charset must have at least 2 characters")
+	}
+	return nil
+}
+
+// Generate returns a random secret following the policy.
+func (s SecretsConfig) Generate() (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+	n := s.GetLength()
+
+	if s.GetFormat() == SecretFormatChars {
+		charset := s.GetCharset()
+		max := big.NewInt(int64(len(charset)))
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			idx, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", fmt.Errorf("failed to generate secret: %w", err)
+			}
+			b.WriteByte(charset[idx.Int64()])
+		}
+		return b.String(), nil
+	}
+
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	switch s.GetFormat() {
+	case SecretFormatBase64URL:
+		return base64.RawURLEncoding.EncodeToString(raw), nil
+	case SecretFormatHex:
+		return hex.EncodeToString(raw), nil
+	default:
+		return base64.StdEncoding.EncodeToString(raw), nil
+	}
+}
+
+func (c *Config) validateSecrets() error {
+	if err := c.Secrets.Validate(); err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	return nil
+}
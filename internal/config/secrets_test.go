@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSecretsConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets SecretsConfig
+		wantErr string
+	}{
+		{"default", SecretsConfig{}, ""},
+		{"hex", SecretsConfig{Format: SecretFormatHex, Length: 16}, ""},
+		{"chars", SecretsConfig{Format: SecretFormatChars, Charset: "abc123"}, ""},
+		{"unknown format", SecretsConfig{Format: "base32"}, "invalid format"},
+		{"short", SecretsConfig{Length: 8}, "length must be between"},
+		{"long", SecretsConfig{Length: 2048}, "length must be between"},
+		{"charset without chars", SecretsConfig{Charset: "abc"}, "requires the chars format"},
+		{"charset with space", SecretsConfig{Format: SecretFormatChars, Charset: "a b"}, "printable"},
+		{"charset repeats", SecretsConfig{Format: SecretFormatChars, Charset: "abca"}, "repeats 'a'"},
+		{"charset of one", SecretsConfig{Format: SecretFormatChars, Charset: "a"}, "at least 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.secrets.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecretsConfig_Generate(t *testing.T) {
+	s, err := SecretsConfig{}.Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if raw, err := base64.StdEncoding.DecodeString(s); err != nil || len(raw) != DefaultSecretLength {
+		t.Errorf("default secret %q is not %d bytes of base64", s, DefaultSecretLength)
+	}
+
+	s, _ = SecretsConfig{Format: SecretFormatBase64URL, Length: 48}.Generate()
+	if strings.ContainsAny(s, "+/=") {
+		t.Errorf("base64url secret %q has '+', '/' or '='", s)
+	}
+	if raw, err := base64.RawURLEncoding.DecodeString(s); err != nil || len(raw) != 48 {
+		t.Errorf("base64url secret %q is not 48 bytes", s)
+	}
+
+	s, _ = SecretsConfig{Format: SecretFormatHex, Length: 20}.Generate()
+	if raw, err := hex.DecodeString(s); err != nil || len(raw) != 20 {
+		t.Errorf("hex secret %q is not 20 bytes", s)
+	}
+
+	s, _ = SecretsConfig{Format: SecretFormatChars, Length: 64, Charset: "xy"}.Generate()
+	if len(s) != 64 || strings.Trim(s, "xy") != "" {
+		t.Errorf("chars secret %q", s)
+	}
+
+	if _, err := (SecretsConfig{Format: "base32"}).Generate(); err == nil {
+		t.Error("Generate() with an invalid policy succeeded")
+	}
+}
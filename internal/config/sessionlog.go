@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+)
+
+// Session log sinks, chosen by the scheme of log.sessions.sink.
+const (
+	SessionSinkFile    = "file"    // file:///path
+	SessionSinkWebhook = "webhook" // http:// or https://host[:port][/path]
+	SessionSinkSyslog  = "syslog"  // udp://, tcp:// or tls://host[:port]
+)
+
+// SessionLogConfig records the start and end of every session the fronts of
+// the tunnels pass to their backends, as JSON lines, for operators that must
+// keep connection records.
+type SessionLogConfig struct {
+	Sink string `json:"sink"`
+}
+
+// TunnelLogConfig logs the sessions of a tunnel to log.sessions. Port is the
+// loopback port of the front; it equals health.port or sessions.port when
+// the tunnel has one.
+type TunnelLogConfig struct {
+	Port int `json:"port"`
+}
+
+// SinkType returns SessionSinkFile, SessionSinkWebhook or SessionSinkSyslog,
+// or "" for an unknown scheme.
+func (s *SessionLogConfig) SinkType() string {
+	u, err := url.Parse(s.Sink)
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "file":
+		return SessionSinkFile
+	case "http", "https":
+		return SessionSinkWebhook
+	case "udp", "tcp", "tls":
+		return SessionSinkSyslog
+	}
+	return ""
+}
+
+// FilePath returns the path of a file sink, or "" for other sinks.
+func (s *SessionLogConfig) FilePath() string {
+	if s.SinkType() != SessionSinkFile {
+		return ""
+	}
+	u, _ := url.Parse(s.Sink)
+	return u.Path
+}
+
+func (c *Config) validateSessionLog() error {
+	s := c.Log.Sessions
+	if s == nil {
+		return nil
+	}
+	if s.Sink == "" {
+		return fmt.Errorf("log.sessions.sink is required")
+	}
+	u, err := url.Parse(s.Sink)
+	if err != nil {
+		return fmt.Errorf("log.sessions.sink: %w", err)
+	}
+	switch s.SinkType() {
+	case "":
+		return fmt.Errorf("log.sessions.sink: unknown scheme '%s' (use file, http, https, udp, tcp or tls)", u.Scheme)
+	case SessionSinkFile:
+		if u.Host != "" || !filepath.IsAbs(u.Path) || u.Path != filepath.Clean(u.Path) {
+			return fmt.Errorf("log.sessions.sink: a file sink needs an absolute path, e.g. file:///var/log/dnstm/sessions.jsonl")
+		}
+	default:
+		if u.Hostname() == "" {
+			return fmt.Errorf("log.sessions.sink: host is required")
+		}
+	}
+	return nil
+}
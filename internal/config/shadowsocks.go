@@ -0,0 +1,189 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Shadowsocks 2022 methods. Their passwords are base64 keys of a fixed
+// size, and a server using them can have several users.
+const (
+	ShadowsocksMethod2022AES128 = "2022-blake3-aes-128-gcm"
+	ShadowsocksMethod2022AES256 = "2022-blake3-aes-256-gcm"
+)
+
+// ShadowsocksUser is one user of a multi-user Shadowsocks tunnel. Each user
+// has a key of its own, so users can be added and removed without touching
+// the others.
+type ShadowsocksUser struct {
+	Name     string `json:"name"`
+	Password string `json:"password"` // base64 key of the method's key size
+}
+
+// SIP003 server plugins commonly run next to slipstream.
+const (
+	ShadowsocksPluginV2Ray = "v2ray-plugin"
+	ShadowsocksPluginObfs  = "obfs-server" // simple-obfs
+)
+
+// ShadowsocksPlugin is an extra listener of a Shadowsocks backend. The
+// tunnel's ssserver listens on the port with the plugin in front, next to
+// the slipstream listener, so clients that cannot use DNS can reach the same
+// server through another plugin.
+type ShadowsocksPlugin struct {
+	Plugin  string `json:"plugin"`            // plugin binary, a name in PATH or an absolute path
+	Options string `json:"options,omitempty"` // SIP003 plugin options, e.g. "server;tls;host=example.com"
+	Port    int    `json:"port"`              // public TCP port
+}
+
+var shadowsocksUserNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.@-]{0,63}$`)
+
+// ShadowsocksKeySize returns the key size in bytes of a 2022 method, and 0
+// for the other methods, whose passwords are free-form.
+func ShadowsocksKeySize(method string) int {
+	switch method {
+	case ShadowsocksMethod2022AES128:
+		return 16
+	case ShadowsocksMethod2022AES256:
+		return 32
+	}
+	return 0
+}
+
+// ShadowsocksSupportsUsers reports whether a server using the method can
+// have several users.
+func ShadowsocksSupportsUsers(method string) bool {
+	return ShadowsocksKeySize(method) > 0
+}
+
+// GenerateShadowsocksPassword returns a random password for the method:
+// a key of the right size for 2022 methods, otherwise a secret following
+// the policy.
+func GenerateShadowsocksPassword(method string, policy SecretsConfig) (string, error) {
+	if size := ShadowsocksKeySize(method); size > 0 {
+		policy = SecretsConfig{Format: SecretFormatBase64, Length: size}
+	}
+	return policy.Generate()
+}
+
+// ShadowsocksUserPassword returns the password a user's client connects
+// with: the server's identity key followed by the user's key.
+func ShadowsocksUserPassword(serverKey, userKey string) string {
+	return serverKey + ":" + userKey
+}
+
+// ValidateShadowsocksUserName checks the name of a Shadowsocks user.
+func ValidateShadowsocksUserName(name string) error {
+	if !shadowsocksUserNameRe.MatchString(name) {
+		return fmt.Errorf("invalid user name '%s': use up to 64 letters, digits, '_', '.', '@' or '-'", name)
+	}
+	return nil
+}
+
+// ValidateShadowsocksPassword checks that a password suits the method:
+// 2022 methods need a base64 key of their key size.
+func ValidateShadowsocksPassword(method, password string) error {
+	size := ShadowsocksKeySize(method)
+	if size == 0 {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(password)
+	if err != nil || len(key) != size {
+		return fmt.Errorf("%s needs a password of %d random bytes in base64, e.g. from 'dnstm secret gen --length %d'", method, size, size)
+	}
+	return nil
+}
+
+// validateShadowsocksUsers checks the users of a tunnel against its backend.
+func validateShadowsocksUsers(users []ShadowsocksUser, backend *BackendConfig) error {
+	if len(users) == 0 {
+		return nil
+	}
+	if backend.Type != BackendShadowsocks || backend.Shadowsocks == nil {
+		return fmt.Errorf("need a shadowsocks backend")
+	}
+	method := backend.Shadowsocks.Method
+	if !ShadowsocksSupportsUsers(method) {
+		return fmt.Errorf("need backend '%s' to use %s or %s", backend.Tag, ShadowsocksMethod2022AES128, ShadowsocksMethod2022AES256)
+	}
+	seen := make(map[string]bool)
+	for _, u := range users {
+		if err := ValidateShadowsocksUserName(u.Name); err != nil {
+			return err
+		}
+		if seen[u.Name] {
+			return fmt.Errorf("duplicate user '%s'", u.Name)
+		}
+		seen[u.Name] = true
+		if err := ValidateShadowsocksPassword(method, u.Password); err != nil {
+			return fmt.Errorf("user '%s': %w", u.Name, err)
+		}
+	}
+	return nil
+}
+
+var shadowsocksPluginNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._+-]*$`)
+
+// ValidateShadowsocksPlugin checks a plugin listener on its own; ports used
+// elsewhere are checked with the whole config.
+func ValidateShadowsocksPlugin(p ShadowsocksPlugin) error {
+	if p.Plugin == "" {
+		return fmt.Errorf("plugin is required")
+	}
+	if !shadowsocksPluginNameRe.MatchString(p.Plugin) && (!filepath.IsAbs(p.Plugin) || strings.ContainsAny(p.Plugin, " \t\n")) {
+		return fmt.Errorf("invalid plugin '%s': use a binary name or an absolute path", p.Plugin)
+	}
+	if strings.ContainsAny(p.Options, "\n\r") {
+		return fmt.Errorf("plugin options must be on one line")
+	}
+	if p.Port < 1 || p.Port > 65535 || p.Port == 53 {
+		return fmt.Errorf("plugin port must be between 1 and 65535 and not 53")
+	}
+	return nil
+}
+
+// validateShadowsocksPlugins checks the plugin listeners of the Shadowsocks
+// backends. Each listener is a public port of the one tunnel using its
+// backend, so the ports must be unique and the backend used only once.
+func (c *Config) validateShadowsocksPlugins() error {
+	used := make(map[int]string)
+	for _, t := range c.Tunnels {
+		if !t.Transport.IsDNS() && t.Port != 0 {
+			used[t.Port] = fmt.Sprintf("tunnel '%s'", t.Tag)
+		}
+	}
+	for _, b := range c.Backends {
+		if b.Type != BackendShadowsocks || b.Shadowsocks == nil || len(b.Shadowsocks.Plugins) == 0 {
+			continue
+		}
+		if n := len(c.GetTunnelsUsingBackend(b.Tag)); n > 1 {
+			return fmt.Errorf("backend '%s': a backend with plugins can only be used by one tunnel, not %d", b.Tag, n)
+		}
+		for _, p := range b.Shadowsocks.Plugins {
+			if err := ValidateShadowsocksPlugin(p); err != nil {
+				return fmt.Errorf("backend '%s': shadowsocks.plugins: %w", b.Tag, err)
+			}
+			if existing, ok := used[p.Port]; ok {
+				return fmt.Errorf("backend '%s': plugin port %d already used by %s", b.Tag, p.Port, existing)
+			}
+			used[p.Port] = fmt.Sprintf("backend '%s'", b.Tag)
+		}
+	}
+	return nil
+}
+
+// GetShadowsocksUser returns the user with the given name, or nil.
+func (s *SlipstreamConfig) GetShadowsocksUser(name string) *ShadowsocksUser {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Users {
+		if s.Users[i].Name == name {
+			return &s.Users[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestGenerateShadowsocksPassword(t *testing.T) {
+	for method, size := range map[string]int{
+		ShadowsocksMethod2022AES128: 16,
+		ShadowsocksMethod2022AES256: 32,
+	} {
+		// The policy must not change the key size of 2022 methods
+		pw, err := GenerateShadowsocksPassword(method, SecretsConfig{Format: SecretFormatHex, Length: 64})
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		key, err := base64.StdEncoding.DecodeString(pw)
+		if err != nil || len(key) != size {
+			t.Errorf("%s: got %q, want %d bytes in base64", method, pw, size)
+		}
+		if err := ValidateShadowsocksPassword(method, pw); err != nil {
+			t.Errorf("%s: generated password rejected: %v", method, err)
+		}
+	}
+
+	pw, err := GenerateShadowsocksPassword("aes-256-gcm", SecretsConfig{Format: SecretFormatHex, Length: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pw) != 32 {
+		t.Errorf("aes-256-gcm: got %q, want the policy's 16 bytes in hex", pw)
+	}
+}
+
+func TestValidateShadowsocksPassword(t *testing.T) {
+	key16 := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	key32 := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		method, password string
+		ok               bool
+	}{
+		{"aes-256-gcm", "anything", true},
+		{ShadowsocksMethod2022AES128, key16, true},
+		{ShadowsocksMethod2022AES128, key32, false},
+		{ShadowsocksMethod2022AES256, key32, true},
+		{ShadowsocksMethod2022AES256, "not base64!", false},
+	}
+	for _, tt := range tests {
+		err := ValidateShadowsocksPassword(tt.method, tt.password)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s %q: err = %v, want ok=%v", tt.method, tt.password, err, tt.ok)
+		}
+	}
+}
+
+func TestValidateShadowsocksUsers(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	backend := &BackendConfig{
+		Tag:         "ss",
+		Type:        BackendShadowsocks,
+		Shadowsocks: &ShadowsocksConfig{Method: ShadowsocksMethod2022AES256, Password: key},
+	}
+
+	if err := validateShadowsocksUsers([]ShadowsocksUser{{"alice", key}, {"bob@home", key}}, backend); err != nil {
+		t.Errorf("valid users rejected: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		users []ShadowsocksUser
+		want  string
+	}{
+		{"bad name", []ShadowsocksUser{{"-alice", key}}, "invalid user name"},
+		{"duplicate", []ShadowsocksUser{{"alice", key}, {"alice", key}}, "duplicate user"},
+		{"bad key", []ShadowsocksUser{{"alice", "short"}}, "user 'alice'"},
+	}
+	for _, tt := range tests {
+		err := validateShadowsocksUsers(tt.users, backend)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	backend.Shadowsocks.Method = "aes-256-gcm"
+	if err := validateShadowsocksUsers([]ShadowsocksUser{{"alice", key}}, backend); err == nil {
+		t.Error("expected an error for a method without users")
+	}
+}
+
+func TestValidateShadowsocksPlugins(t *testing.T) {
+	newConfig := func(plugins ...ShadowsocksPlugin) *Config {
+		return &Config{
+			Backends: []BackendConfig{{
+				Tag:         "ss",
+				Type:        BackendShadowsocks,
+				Shadowsocks: &ShadowsocksConfig{Password: "secret", Plugins: plugins},
+			}},
+			Tunnels: []TunnelConfig{
+				{Tag: "slip", Transport: TransportSlipstream, Backend: "ss", Domain: "s.example.com", Port: 5310},
+				{Tag: "web", Transport: TransportChisel, Backend: "socks", Domain: "w.example.com", Port: 8443},
+			},
+		}
+	}
+
+	if err := newConfig(ShadowsocksPlugin{Plugin: "v2ray-plugin", Options: "server", Port: 443}).validateShadowsocksPlugins(); err != nil {
+		t.Errorf("valid plugin rejected: %v", err)
+	}
+	if err := newConfig(ShadowsocksPlugin{Plugin: "/opt/obfs/obfs-server", Port: 8388}).validateShadowsocksPlugins(); err != nil {
+		t.Errorf("absolute plugin path rejected: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		plugins []ShadowsocksPlugin
+		want    string
+	}{
+		{"no plugin", []ShadowsocksPlugin{{Port: 443}}, "plugin is required"},
+		{"bad name", []ShadowsocksPlugin{{Plugin: "v2ray plugin", Port: 443}}, "invalid plugin"},
+		{"port 53", []ShadowsocksPlugin{{Plugin: "v2ray-plugin", Port: 53}}, "plugin port"},
+		{"tunnel port", []ShadowsocksPlugin{{Plugin: "v2ray-plugin", Port: 8443}}, "already used by tunnel 'web'"},
+		{"duplicate port", []ShadowsocksPlugin{{Plugin: "v2ray-plugin", Port: 443}, {Plugin: "obfs-server", Port: 443}}, "already used by backend 'ss'"},
+	}
+	for _, tt := range tests {
+		err := newConfig(tt.plugins...).validateShadowsocksPlugins()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	c := newConfig(ShadowsocksPlugin{Plugin: "v2ray-plugin", Port: 443})
+	c.Tunnels = append(c.Tunnels, TunnelConfig{Tag: "slip2", Transport: TransportSlipstream, Backend: "ss", Domain: "s2.example.com", Port: 5311})
+	if err := c.validateShadowsocksPlugins(); err == nil || !strings.Contains(err.Error(), "one tunnel") {
+		t.Errorf("backend shared by two tunnels: err = %v", err)
+	}
+}
+
+func TestPublicPorts(t *testing.T) {
+	c := &Config{Backends: []BackendConfig{{
+		Tag:         "ss",
+		Type:        BackendShadowsocks,
+		Shadowsocks: &ShadowsocksConfig{Plugins: []ShadowsocksPlugin{{Plugin: "v2ray-plugin", Port: 443}}},
+	}}}
+
+	if got := c.PublicPorts(&TunnelConfig{Transport: TransportSlipstream, Backend: "ss", Port: 5310}); len(got) != 1 || got[0] != 443 {
+		t.Errorf("slipstream tunnel: got %v, want [443]", got)
+	}
+	if got := c.PublicPorts(&TunnelConfig{Transport: TransportChisel, Backend: "socks", Port: 8443}); len(got) != 1 || got[0] != 8443 {
+		t.Errorf("chisel tunnel: got %v, want [8443]", got)
+	}
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// Store persists the encoded configuration. The file store keeps it in
+// config.json; the etcd and Consul stores keep it in a shared key so nodes
+// of a cluster see the same tunnels, and a failed node's tunnels can be
+// recreated on another one.
+type Store interface {
+	// Load returns the stored data, or an error wrapping os.ErrNotExist
+	// when nothing has been saved yet.
+	Load() ([]byte, error)
+	// Save replaces the stored data.
+	Save(data []byte) error
+	// Update replaces the stored data with fn's result, without losing a
+	// concurrent change in between. fn gets nil when nothing is stored;
+	// when it fails the data is left untouched.
+	Update(fn func([]byte) ([]byte, error)) error
+	// String describes where the data is kept, for messages.
+	String() string
+}
+
+// ErrConflict is returned by a shared store when another node changed the
+// data during an update more often than it retries.
+var ErrConflict = errors.New("config changed concurrently, try again")
+
+// updateRetries bounds the compare-and-swap attempts of the shared stores.
+const updateRetries = 10
+
+var (
+	storeOnce sync.Once
+	store     Store
+	storeErr  error
+)
+
+// currentStore returns the store selected by DNSTM_STORE.
+func currentStore() (Store, error) {
+	storeOnce.Do(func() {
+		store, storeErr = OpenStore(paths.Store)
+	})
+	return store, storeErr
+}
+
+// OpenStore returns the store for a DNSTM_STORE value: empty for config.json
+// in ConfigDir, or a URL such as etcd://host:2379/dnstm/config or
+// consul+https://host:8501/dnstm/config. The URL path is the key; user
+// information in it authenticates to etcd, and a token query parameter to
+// Consul.
+func OpenStore(location string) (Store, error) {
+	if location == "" {
+		return &fileStore{path: filepath.Join(ConfigDir, ConfigFile)}, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config store %q: %w", location, err)
+	}
+	kind, scheme, _ := strings.Cut(u.Scheme, "+")
+	if scheme == "" {
+		scheme = "http"
+	}
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("invalid config store %q: unsupported scheme %s", location, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid config store %q: missing host", location)
+	}
+	key := strings.Trim(u.Path, "/")
+	if key == "" {
+		key = "dnstm/" + ConfigFile
+	}
+	endpoint := scheme + "://" + u.Host
+
+	switch kind {
+	case "etcd":
+		s := &etcdStore{endpoint: endpoint, key: key}
+		if u.User != nil {
+			s.user = u.User.Username()
+			s.password, _ = u.User.Password()
+		}
+		return s, nil
+	case "consul":
+		return &consulStore{endpoint: endpoint, key: key, token: u.Query().Get("token")}, nil
+	}
+	return nil, fmt.Errorf("invalid config store %q: expected etcd or consul", location)
+}
+
+// fileStore keeps the configuration in a file, serializing writers with an
+// advisory lock and replacing the file atomically.
+type fileStore struct {
+	path string
+}
+
+// Load reads the file. A shared lock is taken when possible so the read
+// does not overlap a locked read-modify-write; readers without permission to
+// create the lock file fall back to an unlocked read, which is still safe
+// because writes are atomic.
+func (s *fileStore) Load() ([]byte, error) {
+	if _, err := os.Stat(s.path); err != nil {
+		return nil, err
+	}
+	if lock, err := acquireLock(s.path, false); err == nil {
+		defer lock.release()
+	}
+	return os.ReadFile(s.path)
+}
+
+func (s *fileStore) Save(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	lock, err := acquireLock(s.path, true)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	return writeFileAtomic(s.path, data)
+}
+
+// Update holds the exclusive lock from read until write, so concurrent
+// invocations cannot interleave and lose each other's changes.
+func (s *fileStore) Update(fn func([]byte) ([]byte, error)) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	lock, err := acquireLock(s.path, true)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	data, err = fn(data)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data)
+}
+
+func (s *fileStore) String() string {
+	return s.path
+}
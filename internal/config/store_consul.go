@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// consulStore keeps the configuration in a Consul KV key.
+type consulStore struct {
+	endpoint string
+	key      string
+	token    string
+}
+
+// get returns the value and its modify index; a missing key has index 0,
+// which a check-and-set write treats as "create only".
+func (s *consulStore) get() ([]byte, uint64, error) {
+	var entries []struct {
+		Value       string
+		ModifyIndex uint64
+	}
+	status, data, err := s.do(http.MethodGet, "", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if status == http.StatusNotFound {
+		return nil, 0, nil
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse Consul response: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, 0, nil
+	}
+	value, err := base64.StdEncoding.DecodeString(entries[0].Value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode config from Consul: %w", err)
+	}
+	return value, entries[0].ModifyIndex, nil
+}
+
+func (s *consulStore) Load() ([]byte, error) {
+	data, index, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	if index == 0 {
+		return nil, fmt.Errorf("no config in %s: %w", s, os.ErrNotExist)
+	}
+	return data, nil
+}
+
+func (s *consulStore) Save(data []byte) error {
+	_, _, err := s.do(http.MethodPut, "", data)
+	return err
+}
+
+// Update writes with check-and-set on the modify index read, retrying when
+// another node got there first.
+func (s *consulStore) Update(fn func([]byte) ([]byte, error)) error {
+	for range updateRetries {
+		data, index, err := s.get()
+		if err != nil {
+			return err
+		}
+		if data, err = fn(data); err != nil {
+			return err
+		}
+
+		_, resp, err := s.do(http.MethodPut, "cas="+strconv.FormatUint(index, 10), data)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(resp)) == "true" {
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+func (s *consulStore) String() string {
+	return s.endpoint + "/" + s.key
+}
+
+// do sends a KV request and returns the status and body; statuses other
+// than 200 and 404 are errors.
+func (s *consulStore) do(method, query string, body []byte) (int, []byte, error) {
+	url := s.endpoint + "/v1/kv/" + s.key
+	if query != "" {
+		url += "?" + query
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+
+	resp, err := storeClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reach Consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read Consul response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return 0, nil, fmt.Errorf("consul: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return resp.StatusCode, data, nil
+}
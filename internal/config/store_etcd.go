@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// storeClient is used by the shared stores; a slow cluster must not hang a
+// command or a service start.
+var storeClient = &http.Client{Timeout: 15 * time.Second}
+
+// etcdStore keeps the configuration in an etcd v3 key, using the JSON
+// gateway every etcd server serves next to gRPC.
+type etcdStore struct {
+	endpoint string
+	key      string
+	user     string
+	password string
+}
+
+// etcdKV is a key-value pair in gateway responses. Keys and values are
+// base64 encoded and revisions are decimal strings.
+type etcdKV struct {
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+// get returns the value and its revision; a missing key has revision "0".
+func (s *etcdStore) get() ([]byte, string, error) {
+	var resp struct {
+		Kvs []etcdKV `json:"kvs"`
+	}
+	if err := s.call("/v3/kv/range", map[string]any{"key": s.key64()}, &resp); err != nil {
+		return nil, "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, "0", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Kvs[0].Value)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode config from etcd: %w", err)
+	}
+	return data, resp.Kvs[0].ModRevision, nil
+}
+
+func (s *etcdStore) Load() ([]byte, error) {
+	data, rev, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	if rev == "0" {
+		return nil, fmt.Errorf("no config in %s: %w", s, os.ErrNotExist)
+	}
+	return data, nil
+}
+
+func (s *etcdStore) Save(data []byte) error {
+	return s.call("/v3/kv/put", s.put(data), nil)
+}
+
+// Update writes in a transaction that only succeeds if the key's revision
+// is still the one read, retrying when another node got there first.
+func (s *etcdStore) Update(fn func([]byte) ([]byte, error)) error {
+	for range updateRetries {
+		data, rev, err := s.get()
+		if err != nil {
+			return err
+		}
+		if data, err = fn(data); err != nil {
+			return err
+		}
+
+		var resp struct {
+			Succeeded bool `json:"succeeded"`
+		}
+		txn := map[string]any{
+			"compare": []map[string]any{{
+				"key": s.key64(), "target": "MOD", "result": "EQUAL", "mod_revision": rev,
+			}},
+			"success": []map[string]any{{"request_put": s.put(data)}},
+		}
+		if err := s.call("/v3/kv/txn", txn, &resp); err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+func (s *etcdStore) String() string {
+	return s.endpoint + "/" + s.key
+}
+
+func (s *etcdStore) key64() string {
+	return base64.StdEncoding.EncodeToString([]byte(s.key))
+}
+
+func (s *etcdStore) put(data []byte) map[string]any {
+	return map[string]any{"key": s.key64(), "value": base64.StdEncoding.EncodeToString(data)}
+}
+
+// call posts a gateway request, authenticating first when the store has
+// credentials, and decodes the response into out when it is not nil.
+func (s *etcdStore) call(path string, in, out any) error {
+	var token string
+	if s.user != "" {
+		var auth struct {
+			Token string `json:"token"`
+		}
+		creds := map[string]string{"name": s.user, "password": s.password}
+		if err := etcdPost(s.endpoint+"/v3/auth/authenticate", "", creds, &auth); err != nil {
+			return err
+		}
+		token = auth.Token
+	}
+	return etcdPost(s.endpoint+path, token, in, out)
+}
+
+func etcdPost(url, token string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := storeClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach etcd: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read etcd response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return fmt.Errorf("etcd: %s", e.Message)
+		}
+		return fmt.Errorf("etcd: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse etcd response: %w", err)
+	}
+	return nil
+}
@@ -1,5 +1,14 @@
 package config
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/schedule"
+)
+
 // TransportType defines the type of transport.
 type TransportType string
 
@@ -7,25 +16,62 @@ const (
 	TransportSlipstream TransportType = "slipstream"
 	TransportDNSTT      TransportType = "dnstt"
 	TransportVayDNS     TransportType = "vaydns"
+
+	// TransportChisel is a websocket fallback for when DNS tunneling is
+	// throttled. It listens on its own TCP port instead of answering DNS.
+	TransportChisel TransportType = "chisel"
 )
 
+// DefaultChiselPort is the port chisel tunnels listen on unless set.
+const DefaultChiselPort = 443
+
+// IsDNS reports whether the transport tunnels over DNS. Non-DNS transports
+// bind their own port in both modes and take no part in DNS routing.
+func (t TransportType) IsDNS() bool {
+	return t != TransportChisel
+}
+
 // TunnelConfig configures a DNS tunnel.
 type TunnelConfig struct {
 	Tag        string            `json:"tag"`
 	Enabled    *bool             `json:"enabled,omitempty"`
+	Paused     string            `json:"paused,omitempty"` // multi mode: stopped, the DNS router answers its domain with this error
 	Transport  TransportType     `json:"transport"`
 	Backend    string            `json:"backend"`
 	Domain     string            `json:"domain"`
 	Port       int               `json:"port,omitempty"`
+	BindHost   string            `json:"bind_host,omitempty"` // single mode: bind port 53 on this address next to the active tunnel
 	Slipstream *SlipstreamConfig `json:"slipstream,omitempty"`
 	DNSTT      *DNSTTConfig      `json:"dnstt,omitempty"`
 	VayDNS     *VayDNSConfig     `json:"vaydns,omitempty"`
+	Chisel     *ChiselConfig     `json:"chisel,omitempty"`
+	Watchdog   *WatchdogConfig   `json:"watchdog,omitempty"`
+	Health     *HealthConfig     `json:"health,omitempty"`
+	Sessions   *SessionsConfig   `json:"sessions,omitempty"`
+	SessionLog *TunnelLogConfig  `json:"session_log,omitempty"`
+	Canary     *CanaryConfig     `json:"canary,omitempty"`
+	CrashLoop  *CrashLoopConfig  `json:"crash_loop,omitempty"`
+	Unit       *UnitConfig       `json:"unit,omitempty"`
+	Schedule   *ScheduleConfig   `json:"schedule,omitempty"`
+	Expiry     *ExpiryConfig     `json:"expiry,omitempty"`
+	Rescue     bool              `json:"rescue,omitempty"` // emergency DNSTT->SSH access, see RescueTag
+	Labels     map[string]string `json:"labels,omitempty"` // e.g. region=eu, for selectors
+
+	// Documentation for the people running the server
+	Description string      `json:"description,omitempty"` // one line, shown in status
+	Notes       string      `json:"notes,omitempty"`
+	Meta        *TunnelMeta `json:"meta,omitempty"`
 }
 
+// RescueTag is the reserved tag of the rescue tunnel: a DNSTT tunnel to the
+// local SSH daemon that stays reachable over DNS when SSH is firewalled off.
+const RescueTag = "rescue"
+
 // SlipstreamConfig holds Slipstream-specific configuration.
 type SlipstreamConfig struct {
-	Cert string `json:"cert,omitempty"`
-	Key  string `json:"key,omitempty"`
+	Cert  string            `json:"cert,omitempty"`
+	Key   string            `json:"key,omitempty"`
+	Users []ShadowsocksUser `json:"users,omitempty"` // users of a Shadowsocks backend with a 2022 method
 }
 
 // DNSTTConfig holds DNSTT-specific configuration.
@@ -50,6 +96,249 @@ type VayDNSConfig struct {
 	RecordType     string `json:"record_type,omitempty"`
 }
 
+// ChiselConfig holds chisel-specific configuration.
+type ChiselConfig struct {
+	Auth string `json:"auth,omitempty"` // "user:pass" clients authenticate with
+	Cert string `json:"cert,omitempty"` // TLS certificate; self-signed unless replaced
+	Key  string `json:"key,omitempty"`
+}
+
+// WatchdogConfig enables the systemd watchdog for a tunnel. The service is
+// wrapped so systemd restarts it when it stops answering DNS queries.
+type WatchdogConfig struct {
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// DefaultWatchdogTimeout is used when no watchdog timeout is configured.
+const DefaultWatchdogTimeout = 30 * time.Second
+
+// MinWatchdogTimeout is the shortest accepted watchdog timeout.
+const MinWatchdogTimeout = 5 * time.Second
+
+// TimeoutDuration returns the watchdog timeout, applying the default when empty.
+func (w *WatchdogConfig) TimeoutDuration() (time.Duration, error) {
+	if w == nil || w.Timeout == "" {
+		return DefaultWatchdogTimeout, nil
+	}
+	d, err := time.ParseDuration(w.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid watchdog timeout '%s': %w", w.Timeout, err)
+	}
+	if d < MinWatchdogTimeout {
+		return 0, fmt.Errorf("watchdog timeout must be at least %s", MinWatchdogTimeout)
+	}
+	return d, nil
+}
+
+// HealthConfig routes the built-in health endpoint through a tunnel, so
+// client apps can probe it end to end. Port is the loopback port of the
+// front that answers probes and passes other streams to the backend; it is
+// 0 for transports where the client picks the destination.
+type HealthConfig struct {
+	Port int `json:"port,omitempty"`
+}
+
+// SessionsConfig caps the concurrent sessions of a tunnel, counted as the
+// streams its front passes to the backend. Streams over the limit are
+// refused, or with OverLimit "queue" held until a session ends or
+// QueueTimeout passes. Port is the loopback port of the front; it equals
+// health.port when the tunnel also routes the health endpoint.
+type SessionsConfig struct {
+	Max          int    `json:"max"`
+	OverLimit    string `json:"over_limit,omitempty"`    // "reject" (default) or "queue"
+	QueueTimeout string `json:"queue_timeout,omitempty"` // e.g. "30s" (default)
+	Port         int    `json:"port,omitempty"`
+}
+
+// Over-limit behaviors of SessionsConfig.
+const (
+	SessionsReject = "reject"
+	SessionsQueue  = "queue"
+)
+
+// DefaultSessionQueueTimeout is how long a queued stream waits by default.
+const DefaultSessionQueueTimeout = 30 * time.Second
+
+// Queues reports whether streams over the limit wait for a free session.
+func (s *SessionsConfig) Queues() bool {
+	return s.OverLimit == SessionsQueue
+}
+
+// QueueTimeoutDuration returns how long a queued stream waits.
+func (s *SessionsConfig) QueueTimeoutDuration() time.Duration {
+	if s.QueueTimeout == "" {
+		return DefaultSessionQueueTimeout
+	}
+	d, err := time.ParseDuration(s.QueueTimeout)
+	if err != nil || d <= 0 {
+		return DefaultSessionQueueTimeout
+	}
+	return d
+}
+
+// CanaryConfig sends part of the clients of a tunnel's domain to another
+// tunnel serving the same domain, to roll out a new transport or binary
+// gradually. The DNS router picks the canary for Percent of the client
+// networks by hashing the resolver address, so the queries of one client
+// keep reaching the same instance. Multi mode only.
+type CanaryConfig struct {
+	Tunnel  string `json:"tunnel"`
+	Percent int    `json:"percent"` // 0 sends nothing to the canary
+}
+
+// CanaryOf returns the tunnel that sends part of its traffic to the tunnel
+// tag, or nil.
+func (c *Config) CanaryOf(tag string) *TunnelConfig {
+	for i := range c.Tunnels {
+		if cn := c.Tunnels[i].Canary; cn != nil && cn.Tunnel == tag {
+			return &c.Tunnels[i]
+		}
+	}
+	return nil
+}
+
+// FrontPort returns the loopback port of the tunnel's front, or 0 if it has
+// none.
+func (t *TunnelConfig) FrontPort() int {
+	if t.Health != nil && t.Health.Port != 0 {
+		return t.Health.Port
+	}
+	if t.Sessions != nil {
+		return t.Sessions.Port
+	}
+	if t.SessionLog != nil {
+		return t.SessionLog.Port
+	}
+	return 0
+}
+
+// HasFront reports whether a tunnel with this transport and backend can put
+// a front before its backend, i.e. forwards to a fixed target. The others
+// reach the health endpoint by connecting to it.
+func HasFront(transport TransportType, backend BackendType) bool {
+	switch transport {
+	case TransportDNSTT, TransportVayDNS:
+		return true
+	case TransportSlipstream:
+		return backend != BackendShadowsocks
+	}
+	return false
+}
+
+// CrashLoopConfig overrides the crash-loop limits for a tunnel. A tunnel that
+// restarts more than MaxRestarts times within Interval is quarantined.
+type CrashLoopConfig struct {
+	MaxRestarts int    `json:"max_restarts,omitempty"`
+	Interval    string `json:"interval,omitempty"`
+}
+
+const (
+	// DefaultCrashLoopRestarts is the number of restarts allowed per interval.
+	DefaultCrashLoopRestarts = 10
+	// DefaultCrashLoopInterval is the window restarts are counted in.
+	DefaultCrashLoopInterval = 10 * time.Minute
+)
+
+// Limits returns the restart limit and interval, applying defaults for unset fields.
+func (c *CrashLoopConfig) Limits() (int, time.Duration, error) {
+	restarts, interval := DefaultCrashLoopRestarts, DefaultCrashLoopInterval
+	if c == nil {
+		return restarts, interval, nil
+	}
+	if c.MaxRestarts < 0 {
+		return 0, 0, fmt.Errorf("crash_loop max_restarts must not be negative")
+	}
+	if c.MaxRestarts > 0 {
+		restarts = c.MaxRestarts
+	}
+	if c.Interval != "" {
+		d, err := time.ParseDuration(c.Interval)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid crash_loop interval '%s': %w", c.Interval, err)
+		}
+		if d < time.Minute {
+			return 0, 0, fmt.Errorf("crash_loop interval must be at least 1m")
+		}
+		interval = d
+	}
+	return restarts, interval, nil
+}
+
+// UnitConfig adds settings to a tunnel's service on top of the generated
+// unit. On systemd they are written to a drop-in, override.conf, so they are
+// kept whenever dnstm writes the unit again.
+type UnitConfig struct {
+	Environment  []string `json:"environment,omitempty"`    // NAME=value pairs
+	ExecStartPre []string `json:"exec_start_pre,omitempty"` // commands run before the server, as the dnstm user
+	After        []string `json:"after,omitempty"`          // units the tunnel starts after
+}
+
+// envNameRegex matches environment variable names.
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate checks the unit settings.
+func (u *UnitConfig) Validate() error {
+	if u == nil {
+		return nil
+	}
+	for _, e := range u.Environment {
+		name, _, ok := strings.Cut(e, "=")
+		if !ok || !envNameRegex.MatchString(name) {
+			return fmt.Errorf("unit environment '%s' must be NAME=value", e)
+		}
+	}
+	for _, values := range [][]string{u.Environment, u.ExecStartPre, u.After} {
+		for _, v := range values {
+			if strings.TrimSpace(v) == "" || strings.ContainsAny(v, "\r\n") {
+				return fmt.Errorf("unit settings must be non-empty single lines")
+			}
+		}
+	}
+	for _, unit := range u.After {
+		if strings.ContainsAny(unit, " \t") {
+			return fmt.Errorf("unit after '%s' must be a single unit name", unit)
+		}
+	}
+	return nil
+}
+
+// ScheduleConfig limits when a tunnel runs. Windows are "HH:MM-HH:MM" in
+// local time and may wrap past midnight. Without active windows the tunnel
+// runs at all times outside its blackout windows.
+type ScheduleConfig struct {
+	Active   []string `json:"active,omitempty"`
+	Blackout []string `json:"blackout,omitempty"`
+}
+
+// Parse returns the parsed schedule.
+func (s *ScheduleConfig) Parse() (schedule.Schedule, error) {
+	if s == nil {
+		return schedule.Schedule{}, nil
+	}
+	if len(s.Active) == 0 && len(s.Blackout) == 0 {
+		return schedule.Schedule{}, fmt.Errorf("schedule needs at least one active or blackout window")
+	}
+	active, err := schedule.ParseWindows(s.Active)
+	if err != nil {
+		return schedule.Schedule{}, fmt.Errorf("schedule: %w", err)
+	}
+	blackout, err := schedule.ParseWindows(s.Blackout)
+	if err != nil {
+		return schedule.Schedule{}, fmt.Errorf("schedule: %w", err)
+	}
+	return schedule.Schedule{Active: active, Blackout: blackout}, nil
+}
+
+// InSchedule reports whether the tunnel's schedule lets it run at the given
+// time. Tunnels without a schedule always may.
+func (t *TunnelConfig) InSchedule(at time.Time) bool {
+	s, err := t.Schedule.Parse()
+	if err != nil {
+		return true
+	}
+	return s.Allows(at)
+}
+
 // ValidVayDNSRecordTypes returns the valid record types for VayDNS.
 var ValidVayDNSRecordTypes = []string{"txt", "cname", "a", "aaaa", "mx", "ns", "srv"}
 
@@ -100,6 +389,26 @@ func (t *TunnelConfig) IsEnabled() bool {
 	return t.Enabled == nil || *t.Enabled
 }
 
+// Replies of the DNS router for the domain of a paused tunnel.
+const (
+	PauseServfail = "servfail"
+	PauseRefused  = "refused"
+)
+
+// IsPaused reports whether the tunnel is paused: its service is stopped,
+// but the DNS router keeps its route and answers queries for its domain
+// with an error, so resolvers retry instead of treating it as gone.
+func (t *TunnelConfig) IsPaused() bool {
+	return t.Paused != ""
+}
+
+// RunsAlongsideActive reports whether the tunnel runs next to the active
+// tunnel in single mode: non-DNS transports on their own port, and DNS
+// tunnels that bind port 53 on their own bind_host address.
+func (t *TunnelConfig) RunsAlongsideActive() bool {
+	return !t.Transport.IsDNS() || t.BindHost != ""
+}
+
 // GetMTU returns the MTU for DNSTT/VayDNS tunnels, with a default of 1232.
 func (t *TunnelConfig) GetMTU() int {
 	if t.DNSTT != nil && t.DNSTT.MTU > 0 {
@@ -126,13 +435,39 @@ func (t *TunnelConfig) IsVayDNS() bool {
 	return t.Transport == TransportVayDNS
 }
 
+// extraTransports holds transport types added by providers outside the built-in set.
+var extraTransports []TransportType
+var extraTransportNames = map[TransportType]string{}
+
+// RegisterTransportType makes a provider-defined transport type valid in
+// tunnel configs. Registering a built-in type is a no-op.
+func RegisterTransportType(t TransportType, displayName string) {
+	if IsKnownTransport(t) {
+		return
+	}
+	extraTransports = append(extraTransports, t)
+	extraTransportNames[t] = displayName
+}
+
+// IsKnownTransport returns true for built-in and registered transport types.
+func IsKnownTransport(t TransportType) bool {
+	for _, known := range GetTransportTypes() {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
 // GetTransportTypes returns all available transport types.
 func GetTransportTypes() []TransportType {
-	return []TransportType{
+	types := []TransportType{
 		TransportSlipstream,
 		TransportDNSTT,
 		TransportVayDNS,
+		TransportChisel,
 	}
+	return append(types, extraTransports...)
 }
 
 // GetTransportTypeDisplayName returns a human-readable name for a transport type.
@@ -144,7 +479,12 @@ func GetTransportTypeDisplayName(t TransportType) string {
 		return "DNSTT"
 	case TransportVayDNS:
 		return "VayDNS"
+	case TransportChisel:
+		return "Chisel"
 	default:
+		if name, ok := extraTransportNames[t]; ok {
+			return name
+		}
 		return string(t)
 	}
 }
@@ -2,14 +2,22 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var tagRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
 
-// Validate checks the configuration for errors.
+// Validate checks the configuration for errors. They wrap ErrInvalid.
 func (c *Config) Validate() error {
+	return invalid(c.validate())
+}
+
+func (c *Config) validate() error {
 	if err := c.validateTagUniqueness(); err != nil {
 		return err
 	}
@@ -26,6 +34,78 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	if err := c.validateBindHosts(); err != nil {
+		return err
+	}
+
+	if err := c.validateRescue(); err != nil {
+		return err
+	}
+
+	if err := c.validateProxy(); err != nil {
+		return err
+	}
+
+	if err := c.validateListen(); err != nil {
+		return err
+	}
+
+	if err := c.validatePorts(); err != nil {
+		return err
+	}
+
+	if err := c.validateShadowsocksPlugins(); err != nil {
+		return err
+	}
+
+	if err := c.validateQuotas(); err != nil {
+		return err
+	}
+
+	if err := c.validateAPI(); err != nil {
+		return err
+	}
+
+	if err := c.validateLimits(); err != nil {
+		return err
+	}
+
+	if err := c.validateProfile(); err != nil {
+		return err
+	}
+
+	if err := c.validateSecrets(); err != nil {
+		return err
+	}
+
+	if err := c.validateLogAlerts(); err != nil {
+		return err
+	}
+
+	if err := c.validateLogShip(); err != nil {
+		return err
+	}
+
+	if err := c.validateSessionLog(); err != nil {
+		return err
+	}
+
+	if err := c.validateMetrics(); err != nil {
+		return err
+	}
+
+	if err := c.validatePrivacy(); err != nil {
+		return err
+	}
+
+	if c.Services.ReadyTimeout < 0 || c.Services.ReadyTimeout > 600 {
+		return fmt.Errorf("services.ready_timeout must be between 0 and 600 seconds")
+	}
+
+	if c.Firewall.ConfirmTimeout < -1 || c.Firewall.ConfirmTimeout > 60 {
+		return fmt.Errorf("firewall.confirm_timeout must be between -1 and 60 minutes")
+	}
+
 	return nil
 }
 
@@ -81,6 +161,13 @@ func (c *Config) validateBackends() error {
 					return fmt.Errorf("backend '%s': socks auth requires both user and password", b.Tag)
 				}
 			}
+		case BackendPortForward:
+			if b.Address == "" {
+				return fmt.Errorf("backend '%s': address is required for type %s", b.Tag, b.Type)
+			}
+			if b.PortForward != nil && (b.PortForward.ClientPort < 0 || b.PortForward.ClientPort > 65535) {
+				return fmt.Errorf("backend '%s': portforward.client_port must be between 1 and 65535", b.Tag)
+			}
 		case BackendShadowsocks:
 			if b.Shadowsocks == nil {
 				return fmt.Errorf("backend '%s': shadowsocks config is required for type %s", b.Tag, b.Type)
@@ -91,9 +178,176 @@ func (c *Config) validateBackends() error {
 			if err := validateShadowsocksMethod(b.Shadowsocks.Method); err != nil {
 				return fmt.Errorf("backend '%s': %w", b.Tag, err)
 			}
+			if err := ValidateShadowsocksPassword(b.Shadowsocks.Method, b.Shadowsocks.Password); err != nil {
+				return fmt.Errorf("backend '%s': %w", b.Tag, err)
+			}
 		default:
 			return fmt.Errorf("backend '%s': unknown type %s", b.Tag, b.Type)
 		}
+
+		if b.Egress != nil {
+			if b.Type != BackendSOCKS {
+				return fmt.Errorf("backend '%s': egress rules are only supported for socks backends", b.Tag)
+			}
+			if _, err := b.EgressRules(); err != nil {
+				return fmt.Errorf("backend '%s': egress: %w", b.Tag, err)
+			}
+		}
+
+		if b.Outbound != nil {
+			if err := c.validateOutbound(&b); err != nil {
+				return fmt.Errorf("backend '%s': %w", b.Tag, err)
+			}
+		}
+
+		if b.Keepalive != nil {
+			if err := c.validateKeepalive(&b); err != nil {
+				return fmt.Errorf("backend '%s': %w", b.Tag, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateOutbound validates a backend's outbound interface and source IP.
+func (c *Config) validateOutbound(b *BackendConfig) error {
+	if b.Type != BackendSOCKS && b.Type != BackendShadowsocks {
+		return fmt.Errorf("outbound binding is only supported for socks and shadowsocks backends")
+	}
+
+	o := b.Outbound
+	if o.Interface == "" && o.SourceIP == "" {
+		return fmt.Errorf("outbound requires interface or source_ip")
+	}
+	if o.SourceIP != "" && net.ParseIP(o.SourceIP) == nil {
+		return fmt.Errorf("outbound.source_ip '%s' is not a valid IP address", o.SourceIP)
+	}
+	if o.Interface != "" {
+		if len(o.Interface) > 15 || strings.ContainsAny(o.Interface, "/ \t") {
+			return fmt.Errorf("outbound.interface '%s' is not a valid interface name", o.Interface)
+		}
+		if b.Type == BackendSOCKS && !c.Proxy.IsBuiltinEngine() {
+			return fmt.Errorf("outbound.interface requires proxy.engine \"%s\"", ProxyEngineBuiltin)
+		}
+	}
+
+	return nil
+}
+
+// validateSessions validates the session limit of a tunnel.
+func validateSessions(t *TunnelConfig, backend *BackendConfig) error {
+	s := t.Sessions
+	if !HasFront(t.Transport, backend.Type) {
+		return fmt.Errorf("session limits are not supported with %s to %s", t.Transport, backend.Type)
+	}
+	if s.Max < 1 {
+		return fmt.Errorf("sessions.max must be at least 1")
+	}
+	switch s.OverLimit {
+	case "", SessionsReject, SessionsQueue:
+	default:
+		return fmt.Errorf("sessions.over_limit must be '%s' or '%s'", SessionsReject, SessionsQueue)
+	}
+	if s.QueueTimeout != "" {
+		if !s.Queues() {
+			return fmt.Errorf("sessions.queue_timeout requires over_limit '%s'", SessionsQueue)
+		}
+		if d, err := time.ParseDuration(s.QueueTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("sessions.queue_timeout '%s' is not a valid duration", s.QueueTimeout)
+		}
+	}
+	if s.Port < 1024 || s.Port > 65535 {
+		return fmt.Errorf("sessions.port must be between 1024 and 65535")
+	}
+	if t.Health != nil && t.Health.Port != s.Port {
+		return fmt.Errorf("sessions.port must equal health.port; both are the tunnel's front")
+	}
+	return nil
+}
+
+// validateCanary validates the canary of a tunnel.
+func (c *Config) validateCanary(t *TunnelConfig) error {
+	cn := t.Canary
+	if cn.Percent < 0 || cn.Percent > 100 {
+		return fmt.Errorf("canary.percent must be between 0 and 100")
+	}
+	if cn.Tunnel == t.Tag {
+		return fmt.Errorf("canary.tunnel cannot be the tunnel itself")
+	}
+	canary := c.GetTunnelByTag(cn.Tunnel)
+	if canary == nil {
+		return fmt.Errorf("canary tunnel '%s' not found", cn.Tunnel)
+	}
+	if !t.Transport.IsDNS() || !canary.Transport.IsDNS() {
+		return fmt.Errorf("canaries are only supported between DNS tunnels")
+	}
+	// Clients keep their settings, so they must speak the canary's protocol
+	if canary.Transport != t.Transport {
+		return fmt.Errorf("canary tunnel '%s' uses %s, not %s", canary.Tag, canary.Transport, t.Transport)
+	}
+	if canary.Domain != t.Domain {
+		return fmt.Errorf("canary tunnel '%s' serves %s, not %s", canary.Tag, canary.Domain, t.Domain)
+	}
+	if canary.Canary != nil {
+		return fmt.Errorf("canary tunnel '%s' has a canary of its own", canary.Tag)
+	}
+	if other := c.CanaryOf(cn.Tunnel); other.Tag != t.Tag {
+		return fmt.Errorf("tunnel '%s' is already the canary of %s", cn.Tunnel, other.Tag)
+	}
+	if t.BindHost != "" || canary.BindHost != "" {
+		return fmt.Errorf("canaries are routed by the DNS router and cannot use bind_host")
+	}
+	return nil
+}
+
+// isCanaryPair reports whether one of two tunnels is the canary of the other.
+func (c *Config) isCanaryPair(a, b string) bool {
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		if t := c.GetTunnelByTag(pair[0]); t != nil && t.Canary != nil && t.Canary.Tunnel == pair[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// validateKeepalive validates a backend's keepalive settings.
+func (c *Config) validateKeepalive(b *BackendConfig) error {
+	if b.Type != BackendSOCKS && b.Type != BackendSSH {
+		return fmt.Errorf("keepalive is only supported for socks and ssh backends")
+	}
+	if b.Type == BackendSOCKS && !c.Proxy.IsBuiltinEngine() {
+		return fmt.Errorf("keepalive requires proxy.engine \"%s\"", ProxyEngineBuiltin)
+	}
+
+	k := b.Keepalive
+	durations := []struct {
+		name, value string
+	}{
+		{"idle", k.Idle},
+		{"interval", k.Interval},
+		{"idle_timeout", k.IdleTimeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("keepalive.%s '%s' is not a valid duration", d.name, d.value)
+		}
+		if v < time.Second || v%time.Second != 0 {
+			return fmt.Errorf("keepalive.%s must be a whole number of seconds", d.name)
+		}
+	}
+	if k.Count < 0 || k.Count > 127 {
+		return fmt.Errorf("keepalive.count must be between 0 and 127")
+	}
+	if k.IdleTimeout != "" && b.Type != BackendSOCKS {
+		return fmt.Errorf("keepalive.idle_timeout is only supported for socks backends")
+	}
+	if k.Interval != "" && b.Type == BackendSSH {
+		return fmt.Errorf("keepalive.interval is not supported for ssh backends; sshd probes every keepalive.idle")
 	}
 
 	return nil
@@ -109,7 +363,7 @@ func (c *Config) validateTunnels() error {
 			return fmt.Errorf("tunnel '%s': transport is required", t.Tag)
 		}
 
-		if t.Transport != TransportSlipstream && t.Transport != TransportDNSTT && t.Transport != TransportVayDNS {
+		if !IsKnownTransport(t.Transport) {
 			return fmt.Errorf("tunnel '%s': unknown transport %s", t.Tag, t.Transport)
 		}
 
@@ -132,9 +386,18 @@ func (c *Config) validateTunnels() error {
 			return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
 		}
 
+		if t.Slipstream != nil {
+			if err := validateShadowsocksUsers(t.Slipstream.Users, backend); err != nil {
+				return fmt.Errorf("tunnel '%s': slipstream.users: %w", t.Tag, err)
+			}
+		}
+
 		// Check port uniqueness (if port is set)
 		if t.Port != 0 {
-			if t.Port < 1024 || t.Port > 65535 {
+			if !t.Transport.IsDNS() && (t.Port < 1 || t.Port > 65535) {
+				return fmt.Errorf("tunnel '%s': port must be between 1 and 65535", t.Tag)
+			}
+			if t.Transport.IsDNS() && (t.Port < 1024 || t.Port > 65535) {
 				return fmt.Errorf("tunnel '%s': port must be between 1024 and 65535", t.Tag)
 			}
 			if existing, ok := usedPorts[t.Port]; ok {
@@ -145,8 +408,9 @@ func (c *Config) validateTunnels() error {
 
 		// Check domain uniqueness (only in multi mode — single mode allows duplicates
 		// since only one tunnel is active at a time)
-		if c.IsMultiMode() {
-			if existing, ok := usedDomains[t.Domain]; ok {
+		if c.IsMultiMode() && t.Transport.IsDNS() {
+			// A canary serves the domain of the tunnel it takes traffic from
+			if existing, ok := usedDomains[t.Domain]; ok && !c.isCanaryPair(t.Tag, existing) {
 				return fmt.Errorf("tunnel '%s': domain '%s' already used by %s", t.Tag, t.Domain, existing)
 			}
 			usedDomains[t.Domain] = t.Tag
@@ -159,6 +423,10 @@ func (c *Config) validateTunnels() error {
 			}
 		}
 
+		if !t.Transport.IsDNS() && t.Watchdog != nil {
+			return fmt.Errorf("tunnel '%s': watchdog is only supported for DNS transports", t.Tag)
+		}
+
 		// Validate VayDNS-specific config
 		if t.Transport == TransportVayDNS && t.VayDNS != nil {
 			if t.VayDNS.MTU != 0 && (t.VayDNS.MTU < 512 || t.VayDNS.MTU > 1400) {
@@ -218,6 +486,84 @@ func (c *Config) validateTunnels() error {
 				}
 			}
 		}
+
+		if t.Health != nil {
+			front := HasFront(t.Transport, backend.Type)
+			if front && (t.Health.Port < 1024 || t.Health.Port > 65535) {
+				return fmt.Errorf("tunnel '%s': health.port must be between 1024 and 65535", t.Tag)
+			}
+			if !front && t.Health.Port != 0 {
+				return fmt.Errorf("tunnel '%s': health.port is not used with %s; clients connect to the health endpoint directly", t.Tag, t.Transport)
+			}
+		}
+
+		if t.Sessions != nil {
+			if err := validateSessions(&t, backend); err != nil {
+				return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+			}
+		}
+
+		if t.Canary != nil {
+			if err := c.validateCanary(&t); err != nil {
+				return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+			}
+		}
+
+		if t.IsPaused() {
+			if t.Paused != PauseServfail && t.Paused != PauseRefused {
+				return fmt.Errorf("tunnel '%s': paused must be '%s' or '%s'", t.Tag, PauseServfail, PauseRefused)
+			}
+			if !t.Transport.IsDNS() {
+				return fmt.Errorf("tunnel '%s': only DNS tunnels can be paused", t.Tag)
+			}
+			if c.IsSingleMode() {
+				return fmt.Errorf("tunnel '%s': paused tunnels are answered by the DNS router and need multi mode", t.Tag)
+			}
+		}
+
+		if port := t.FrontPort(); port != 0 {
+			if existing, ok := usedPorts[port]; ok {
+				return fmt.Errorf("tunnel '%s': front port %d already used by %s", t.Tag, port, existing)
+			}
+			usedPorts[port] = t.Tag
+		}
+
+		if t.Watchdog != nil {
+			if _, err := t.Watchdog.TimeoutDuration(); err != nil {
+				return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+			}
+		}
+
+		if _, _, err := t.CrashLoop.Limits(); err != nil {
+			return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+		}
+
+		if err := t.Unit.Validate(); err != nil {
+			return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+		}
+
+		if _, err := t.Schedule.Parse(); err != nil {
+			return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+		}
+
+		if t.Expiry != nil {
+			if t.Rescue {
+				return fmt.Errorf("tunnel '%s': the rescue tunnel cannot expire", t.Tag)
+			}
+			if _, _, err := t.Expiry.Parse(); err != nil {
+				return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+			}
+		}
+
+		if strings.ContainsAny(t.Description, "\r\n") {
+			return fmt.Errorf("tunnel '%s': description must be a single line; use notes for more", t.Tag)
+		}
+
+		for key, value := range t.Labels {
+			if err := ValidateLabel(key, value); err != nil {
+				return fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+			}
+		}
 	}
 
 	return nil
@@ -232,18 +578,228 @@ func (c *Config) validateRoute() error {
 
 	// In single mode, validate active tunnel exists
 	if c.IsSingleMode() && c.Route.Active != "" {
-		if c.GetTunnelByTag(c.Route.Active) == nil {
+		t := c.GetTunnelByTag(c.Route.Active)
+		if t == nil {
 			return fmt.Errorf("route.active: tunnel '%s' does not exist", c.Route.Active)
 		}
+		if !t.Transport.IsDNS() {
+			return fmt.Errorf("route.active: tunnel '%s' is not a DNS tunnel", c.Route.Active)
+		}
 	}
 
 	// Validate default route exists
 	if c.Route.Default != "" {
-		if c.GetTunnelByTag(c.Route.Default) == nil {
+		t := c.GetTunnelByTag(c.Route.Default)
+		if t == nil {
 			return fmt.Errorf("route.default: tunnel '%s' does not exist", c.Route.Default)
 		}
+		if !t.Transport.IsDNS() {
+			return fmt.Errorf("route.default: tunnel '%s' is not a DNS tunnel", c.Route.Default)
+		}
+	}
+
+	// Canaries split clients by network, which a stateless router ignores
+	if c.Route.Stateless {
+		for _, t := range c.Tunnels {
+			if t.Canary != nil && t.Canary.Percent > 0 {
+				return fmt.Errorf("route.stateless: tunnel '%s' sends %d%% of its clients to canary '%s'; set its percent to 0 first", t.Tag, t.Canary.Percent, t.Canary.Tunnel)
+			}
+		}
+	}
+
+	if c.Route.Decoy != nil && !filepath.IsAbs(c.Route.Decoy.ZoneFile) {
+		return fmt.Errorf("route.decoy.zone_file must be an absolute path")
+	}
+
+	for i, r := range c.Route.Records {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("route.records[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// validate checks a static record's name, type and value.
+func (r ZoneRecord) validate() error {
+	name := strings.TrimSuffix(r.Name, ".")
+	if name == "" || strings.ContainsAny(name, " \t") || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid name '%s'", r.Name)
+	}
+	typ := strings.ToUpper(r.Type)
+	known := false
+	for _, t := range ZoneRecordTypes {
+		if t == typ {
+			known = true
+		}
 	}
+	if !known {
+		return fmt.Errorf("type must be one of %s", strings.Join(ZoneRecordTypes, ", "))
+	}
+	if r.Value == "" {
+		return fmt.Errorf("%s record for '%s' needs a value", typ, r.Name)
+	}
+	if r.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+
+	switch typ {
+	case "A", "AAAA":
+		ip := net.ParseIP(r.Value)
+		if ip == nil || (typ == "A") != (ip.To4() != nil) {
+			return fmt.Errorf("invalid %s address '%s'", typ, r.Value)
+		}
+	case "MX":
+		fields := strings.Fields(r.Value)
+		if len(fields) != 2 {
+			return fmt.Errorf("MX value must be '<preference> <host>'")
+		}
+		if _, err := strconv.ParseUint(fields[0], 10, 16); err != nil {
+			return fmt.Errorf("invalid MX preference '%s'", fields[0])
+		}
+	case "CNAME", "NS":
+		if len(strings.Fields(r.Value)) != 1 {
+			return fmt.Errorf("%s value must be a host name", typ)
+		}
+	case "TXT":
+		if len(r.Value) > 4000 {
+			return fmt.Errorf("TXT value is too long")
+		}
+	}
+	return nil
+}
+
+// validateProxy validates the SOCKS proxy configuration.
+func (c *Config) validateProxy() error {
+	switch c.Proxy.Engine {
+	case "", ProxyEngineMicrosocks, ProxyEngineBuiltin:
+	default:
+		return fmt.Errorf("proxy.engine must be '%s' or '%s'", ProxyEngineMicrosocks, ProxyEngineBuiltin)
+	}
+	if c.Proxy.MaxConnsPerIP < 0 {
+		return fmt.Errorf("proxy.max_conns_per_ip must not be negative")
+	}
+	if c.Proxy.MaxConnsPerIP > 0 && !c.Proxy.IsBuiltinEngine() {
+		return fmt.Errorf("proxy.max_conns_per_ip requires proxy.engine '%s'", ProxyEngineBuiltin)
+	}
+	return nil
+}
+
+// validateListen validates the DNS listener settings.
+func (c *Config) validateListen() error {
+	if c.Listen.Workers < 0 || c.Listen.Workers > 256 {
+		return fmt.Errorf("listen.workers must be between 0 and 256")
+	}
+	return nil
+}
 
+// validateBindHosts checks the addresses DNS tunnels bind in single mode.
+// Each must be a distinct unicast IP; whether it is assigned to this host is
+// checked when the tunnel is added.
+func (c *Config) validateBindHosts() error {
+	used := make(map[string]string)
+	for _, t := range c.Tunnels {
+		if t.BindHost == "" {
+			continue
+		}
+		if !t.Transport.IsDNS() {
+			return fmt.Errorf("tunnel '%s': bind_host is only used by DNS transports", t.Tag)
+		}
+		if t.Tag == c.Route.Active {
+			return fmt.Errorf("tunnel '%s': the active tunnel binds the external IP and cannot have a bind_host", t.Tag)
+		}
+		if c.IsMultiMode() {
+			return fmt.Errorf("tunnel '%s': bind_host is only used in single mode; the DNS router serves every tunnel on listen.address", t.Tag)
+		}
+		ip := net.ParseIP(t.BindHost)
+		if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsMulticast() {
+			return fmt.Errorf("tunnel '%s': bind_host must be a unicast IP address, got '%s'", t.Tag, t.BindHost)
+		}
+		key := ip.String()
+		if existing, ok := used[key]; ok {
+			return fmt.Errorf("tunnel '%s': bind_host %s already used by %s", t.Tag, key, existing)
+		}
+		used[key] = t.Tag
+	}
+	return nil
+}
+
+// validateRescue checks the rescue tunnel: at most one, a DNSTT tunnel to an
+// SSH backend under the reserved tag, never the active or default route.
+func (c *Config) validateRescue() error {
+	var rescue *TunnelConfig
+	for i := range c.Tunnels {
+		t := &c.Tunnels[i]
+		if !t.Rescue {
+			continue
+		}
+		if rescue != nil {
+			return fmt.Errorf("only one rescue tunnel is allowed")
+		}
+		rescue = t
+	}
+	if rescue == nil {
+		return nil
+	}
+
+	if rescue.Tag != RescueTag {
+		return fmt.Errorf("tunnel '%s': the rescue tunnel must be tagged '%s'", rescue.Tag, RescueTag)
+	}
+	if rescue.Transport != TransportDNSTT {
+		return fmt.Errorf("tunnel '%s': the rescue tunnel must use the dnstt transport", rescue.Tag)
+	}
+	if b := c.GetBackendByTag(rescue.Backend); b == nil || b.Type != BackendSSH {
+		return fmt.Errorf("tunnel '%s': the rescue tunnel must use an ssh backend", rescue.Tag)
+	}
+	if c.Route.Active == rescue.Tag || c.Route.Default == rescue.Tag {
+		return fmt.Errorf("tunnel '%s': the rescue tunnel cannot be route.active or route.default", rescue.Tag)
+	}
+	return nil
+}
+
+// validatePorts validates the port allocation policy.
+func (c *Config) validatePorts() error {
+	if c.Ports.Start != 0 && (c.Ports.Start < 1024 || c.Ports.Start > 65535) {
+		return fmt.Errorf("ports.start must be between 1024 and 65535")
+	}
+	if c.Ports.End != 0 && (c.Ports.End < 1024 || c.Ports.End > 65535) {
+		return fmt.Errorf("ports.end must be between 1024 and 65535")
+	}
+	if start, end := c.Ports.Range(); start > end {
+		return fmt.Errorf("ports.start (%d) must not be greater than ports.end (%d)", start, end)
+	}
+	for _, p := range c.Ports.Exclude {
+		if p < 1 || p > 65535 {
+			return fmt.Errorf("ports.exclude: invalid port %d", p)
+		}
+	}
+	for _, t := range c.Tunnels {
+		if t.Port != 0 && t.Transport.IsDNS() && c.Ports.IsExcluded(t.Port) {
+			return fmt.Errorf("tunnel '%s': port %d is excluded by ports.exclude", t.Tag, t.Port)
+		}
+	}
+	return nil
+}
+
+// validateQuotas validates the traffic quotas.
+func (c *Config) validateQuotas() error {
+	seen := make(map[string]bool)
+	for i, q := range c.Quotas {
+		kind, name, err := ParseQuotaAccount(q.Account)
+		if err != nil {
+			return fmt.Errorf("quotas[%d]: %w", i, err)
+		}
+		if _, err := ParseByteSize(q.Limit); err != nil {
+			return fmt.Errorf("quota '%s': %w", q.Account, err)
+		}
+		if seen[q.Account] {
+			return fmt.Errorf("duplicate quota for '%s'", q.Account)
+		}
+		seen[q.Account] = true
+		if kind == QuotaTunnel && c.GetTunnelByTag(name) == nil {
+			return fmt.Errorf("quota '%s': tunnel '%s' does not exist", q.Account, name)
+		}
+	}
 	return nil
 }
 
@@ -257,6 +813,9 @@ func validateTransportBackendCompatibility(transport TransportType, backend Back
 	if transport == TransportVayDNS && backend == BackendShadowsocks {
 		return fmt.Errorf("vaydns transport does not support shadowsocks backend (no SIP003 plugin support)")
 	}
+	if transport == TransportChisel && backend == BackendShadowsocks {
+		return fmt.Errorf("chisel transport does not support shadowsocks backend")
+	}
 	return nil
 }
 
@@ -269,13 +828,16 @@ func validateShadowsocksMethod(method string) error {
 		"aes-256-gcm",
 		"aes-128-gcm",
 		"chacha20-ietf-poly1305",
+		ShadowsocksMethod2022AES128,
+		ShadowsocksMethod2022AES256,
 	}
 	for _, m := range validMethods {
 		if method == m {
 			return nil
 		}
 	}
-	return fmt.Errorf("invalid shadowsocks method '%s', must be one of: aes-256-gcm, aes-128-gcm, chacha20-ietf-poly1305", method)
+	return fmt.Errorf("invalid shadowsocks method '%s', must be one of: aes-256-gcm, aes-128-gcm, chacha20-ietf-poly1305, %s, %s",
+		method, ShadowsocksMethod2022AES128, ShadowsocksMethod2022AES256)
 }
 
 // GetSupportedShadowsocksMethods returns the list of supported shadowsocks methods.
@@ -284,5 +846,7 @@ func GetSupportedShadowsocksMethods() []string {
 		"aes-256-gcm",
 		"aes-128-gcm",
 		"chacha20-ietf-poly1305",
+		ShadowsocksMethod2022AES128,
+		ShadowsocksMethod2022AES256,
 	}
 }
@@ -0,0 +1,185 @@
+// Package delegation renders the parent-zone records that delegate a tunnel
+// domain to the server, in the formats DNS providers accept.
+package delegation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// Output formats.
+const (
+	FormatBind       = "bind"
+	FormatCloudflare = "cloudflare"
+	FormatRoute53    = "route53"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatBind, FormatCloudflare, FormatRoute53}
+
+// DefaultTTL is the TTL of the generated records.
+const DefaultTTL = 3600
+
+// Plan describes the delegation of one tunnel domain.
+type Plan struct {
+	Domain  string // Tunnel domain, e.g. t.example.com
+	Parent  string // Zone the records go in, e.g. example.com
+	NSHost  string // Name server host, e.g. ns.example.com
+	IP      string // Server address the NS host resolves to
+	Records []config.ZoneRecord
+}
+
+// NewPlan returns the records delegating domain to ip. nsHost defaults to
+// "ns" under the parent zone; a TXT record on the NS host is added when txt
+// is set.
+func NewPlan(domain, nsHost, ip, txt string) (*Plan, error) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	labels := strings.Split(domain, ".")
+	if len(labels) < 3 {
+		return nil, fmt.Errorf("%s is a registered domain, not a subdomain; set its name servers at the registrar instead", domain)
+	}
+	parent := strings.Join(labels[1:], ".")
+
+	if nsHost == "" {
+		nsHost = "ns." + parent
+	}
+	nsHost = strings.TrimSuffix(strings.ToLower(nsHost), ".")
+	if nsHost == domain || strings.HasSuffix(nsHost, "."+domain) {
+		return nil, fmt.Errorf("name server %s lies inside the delegated domain %s; use a host in %s", nsHost, domain, parent)
+	}
+
+	p := &Plan{Domain: domain, Parent: parent, NSHost: nsHost, IP: ip}
+	addrType := "A"
+	if strings.Contains(ip, ":") {
+		addrType = "AAAA"
+	}
+	p.Records = []config.ZoneRecord{
+		{Name: nsHost, Type: addrType, Value: ip, TTL: DefaultTTL},
+		{Name: domain, Type: "NS", Value: nsHost, TTL: DefaultTTL},
+	}
+	if txt != "" {
+		p.Records = append(p.Records, config.ZoneRecord{Name: nsHost, Type: "TXT", Value: txt, TTL: DefaultTTL})
+	}
+	return p, nil
+}
+
+// Render formats the plan's records.
+func (p *Plan) Render(format string) (string, error) {
+	switch format {
+	case FormatBind, "":
+		return p.renderBind(), nil
+	case FormatCloudflare:
+		return p.renderCloudflare(), nil
+	case FormatRoute53:
+		return p.renderRoute53()
+	}
+	return "", fmt.Errorf("unknown format '%s' (expected %s)", format, strings.Join(Formats, ", "))
+}
+
+// renderBind renders zone file lines for the parent zone.
+func (p *Plan) renderBind() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "; Delegate %s to %s (add to the %s zone)\n", p.Domain, p.IP, p.Parent)
+	for _, r := range p.Records {
+		value := r.Value
+		switch r.Type {
+		case "NS":
+			value += "."
+		case "TXT":
+			value = quoteTXT(value)
+		}
+		fmt.Fprintf(&b, "%s.\t%d\tIN\t%s\t%s\n", r.Name, r.TTL, r.Type, value)
+	}
+	return b.String()
+}
+
+// renderCloudflare renders Cloudflare API calls creating the records.
+// The NS host must not be proxied, or resolvers reach Cloudflare instead
+// of the server.
+func (p *Plan) renderCloudflare() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Delegate %s to %s in the Cloudflare zone %s.\n", p.Domain, p.IP, p.Parent)
+	b.WriteString("# Set CF_ZONE_ID and CF_API_TOKEN (Zone.DNS edit) first.\n")
+	b.WriteString("# In the dashboard: keep the proxy status of the address record \"DNS only\".\n")
+	for _, r := range p.Records {
+		body := map[string]any{
+			"type":    r.Type,
+			"name":    r.Name,
+			"content": r.Value,
+			"ttl":     r.TTL,
+		}
+		if r.Type == "A" || r.Type == "AAAA" {
+			body["proxied"] = false
+		}
+		data, _ := json.Marshal(body)
+		b.WriteString("curl -sS -X POST \"https://api.cloudflare.com/client/v4/zones/$CF_ZONE_ID/dns_records\" \\\n")
+		b.WriteString("  -H \"Authorization: Bearer $CF_API_TOKEN\" -H \"Content-Type: application/json\" \\\n")
+		fmt.Fprintf(&b, "  --data %s\n", shellQuote(string(data)))
+	}
+	return b.String()
+}
+
+// renderRoute53 renders an AWS CLI call upserting the records.
+func (p *Plan) renderRoute53() (string, error) {
+	type resourceRecord struct {
+		Value string `json:"Value"`
+	}
+	type recordSet struct {
+		Name            string           `json:"Name"`
+		Type            string           `json:"Type"`
+		TTL             int              `json:"TTL"`
+		ResourceRecords []resourceRecord `json:"ResourceRecords"`
+	}
+	type change struct {
+		Action            string    `json:"Action"`
+		ResourceRecordSet recordSet `json:"ResourceRecordSet"`
+	}
+	batch := struct {
+		Comment string   `json:"Comment"`
+		Changes []change `json:"Changes"`
+	}{Comment: "dnstm: delegate " + p.Domain}
+
+	for _, r := range p.Records {
+		value := r.Value
+		switch r.Type {
+		case "NS":
+			value += "."
+		case "TXT":
+			value = quoteTXT(value)
+		}
+		batch.Changes = append(batch.Changes, change{
+			Action: "UPSERT",
+			ResourceRecordSet: recordSet{
+				Name:            r.Name + ".",
+				Type:            r.Type,
+				TTL:             r.TTL,
+				ResourceRecords: []resourceRecord{{Value: value}},
+			},
+		})
+	}
+	data, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Delegate %s to %s in the Route 53 hosted zone %s.\n", p.Domain, p.IP, p.Parent)
+	b.WriteString("# Set ZONE_ID to the hosted zone ID first.\n")
+	b.WriteString("aws route53 change-resource-record-sets --hosted-zone-id \"$ZONE_ID\" --change-batch ")
+	b.WriteString(shellQuote(string(data)))
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+// quoteTXT quotes a TXT value for zone files and Route 53.
+func quoteTXT(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// shellQuote wraps s in single quotes for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
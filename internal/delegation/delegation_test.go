@@ -0,0 +1,97 @@
+package delegation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewPlan(t *testing.T) {
+	p, err := NewPlan("T.Example.com.", "", "203.0.113.10", "")
+	if err != nil {
+		t.Fatalf("NewPlan() failed: %v", err)
+	}
+	if p.Parent != "example.com" || p.NSHost != "ns.example.com" {
+		t.Errorf("Parent = %q, NSHost = %q", p.Parent, p.NSHost)
+	}
+	if len(p.Records) != 2 || p.Records[0].Type != "A" || p.Records[1].Type != "NS" || p.Records[1].Value != "ns.example.com" {
+		t.Errorf("Records = %+v", p.Records)
+	}
+
+	p, err = NewPlan("t.example.com", "dns.example.net", "2001:db8::1", "owner=ops")
+	if err != nil {
+		t.Fatalf("NewPlan() failed: %v", err)
+	}
+	if p.Records[0].Type != "AAAA" || p.Records[0].Name != "dns.example.net" || len(p.Records) != 3 || p.Records[2].Type != "TXT" {
+		t.Errorf("Records = %+v", p.Records)
+	}
+
+	for _, tc := range []struct{ domain, nsHost, wantErr string }{
+		{"example.com", "", "registered domain"},
+		{"t.example.com", "ns.t.example.com", "inside the delegated domain"},
+	} {
+		if _, err := NewPlan(tc.domain, tc.nsHost, "203.0.113.10", ""); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("NewPlan(%q, %q) error = %v, want %q", tc.domain, tc.nsHost, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRender_Bind(t *testing.T) {
+	p, _ := NewPlan("t.example.com", "", "203.0.113.10", `say "hi"`)
+	out, err := p.Render(FormatBind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"ns.example.com.\t3600\tIN\tA\t203.0.113.10\n",
+		"t.example.com.\t3600\tIN\tNS\tns.example.com.\n",
+		"ns.example.com.\t3600\tIN\tTXT\t\"say \\\"hi\\\"\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("bind output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRender_Cloudflare(t *testing.T) {
+	p, _ := NewPlan("t.example.com", "", "203.0.113.10", "")
+	out, err := p.Render(FormatCloudflare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "curl ") != 2 {
+		t.Errorf("want one API call per record:\n%s", out)
+	}
+	if !strings.Contains(out, `"proxied":false`) {
+		t.Errorf("address record is not marked unproxied:\n%s", out)
+	}
+}
+
+func TestRender_Route53(t *testing.T) {
+	p, _ := NewPlan("t.example.com", "", "203.0.113.10", "")
+	out, err := p.Render(FormatRoute53)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := strings.Index(out, "'"), strings.LastIndex(out, "'")
+	var batch struct {
+		Changes []struct {
+			Action            string
+			ResourceRecordSet struct {
+				Name, Type      string
+				ResourceRecords []struct{ Value string }
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(out[start+1:end]), &batch); err != nil {
+		t.Fatalf("change batch is not valid JSON: %v\n%s", err, out)
+	}
+	if len(batch.Changes) != 2 || batch.Changes[1].ResourceRecordSet.Name != "t.example.com." ||
+		batch.Changes[1].ResourceRecordSet.ResourceRecords[0].Value != "ns.example.com." {
+		t.Errorf("unexpected change batch: %+v", batch)
+	}
+
+	if _, err := p.Render("godaddy"); err == nil {
+		t.Error("Render() accepted an unknown format")
+	}
+}
@@ -0,0 +1,179 @@
+package dnsrouter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// ACMEFile holds the TXT values published for pending ACME DNS-01
+// challenges. It sits in the config directory, which the router service
+// can read, and is reread when the router receives SIGHUP.
+var ACMEFile = filepath.Join(paths.ConfigDir, "acme-txt.json")
+
+const (
+	// ACMETTL is the TTL of challenge records, kept short so a retried
+	// challenge is not answered from a resolver cache.
+	ACMETTL = 60
+
+	// ACMEExpiry is how long a challenge value is served when it is never
+	// cleared, e.g. because a cleanup hook did not run.
+	ACMEExpiry = 24 * time.Hour
+)
+
+// ACMEChallenge is a TXT value published for a DNS-01 challenge.
+type ACMEChallenge struct {
+	Name    string    `json:"name"`
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// ACMEChallengeName returns the name the CA queries to validate domain.
+// Wildcards are validated at their base domain.
+func ACMEChallengeName(domain string) string {
+	name := strings.TrimPrefix(canonicalName(strings.TrimSpace(domain)), "*.")
+	if strings.HasPrefix(name, "_acme-challenge.") {
+		return name
+	}
+	return "_acme-challenge." + name
+}
+
+// LoadACMEChallenges returns the challenges that have not expired at now.
+// A missing file yields none.
+func LoadACMEChallenges(now time.Time) ([]ACMEChallenge, error) {
+	data, err := os.ReadFile(ACMEFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACME challenges: %w", err)
+	}
+	var all []ACMEChallenge
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ACMEFile, err)
+	}
+	var challenges []ACMEChallenge
+	for _, c := range all {
+		if c.Expires.After(now) {
+			challenges = append(challenges, c)
+		}
+	}
+	return challenges, nil
+}
+
+// SaveACMEChallenges writes the challenges, removing the file when there
+// are none left.
+func SaveACMEChallenges(challenges []ACMEChallenge) error {
+	if len(challenges) == 0 {
+		if err := os.Remove(ACMEFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove ACME challenges: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(challenges, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ACMEFile), 0755); err != nil {
+		return fmt.Errorf("failed to create ACME directory: %w", err)
+	}
+	tmp := ACMEFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write ACME challenges: %w", err)
+	}
+	return os.Rename(tmp, ACMEFile)
+}
+
+// WithACME returns a copy of base with a TXT record for every challenge.
+// base may be nil and is not modified.
+func WithACME(base *Zone, challenges []ACMEChallenge) *Zone {
+	z := NewZone()
+	if base != nil {
+		for name, records := range base.nodes {
+			z.nodes[name] = append([]Record(nil), records...)
+		}
+		z.apexes = append(z.apexes, base.apexes...)
+	}
+	for _, c := range challenges {
+		rec, err := NewRecord(c.Name, "TXT", c.Value, ACMETTL)
+		if err != nil {
+			continue
+		}
+		z.Add(rec)
+	}
+	return z
+}
+
+// LookupTXT queries the DNS server at addr for the TXT records of name and
+// returns their values, with multi-string records joined.
+func LookupTXT(addr, name string, timeout time.Duration) ([]string, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	query := []byte{0x5a, 0xc3, 0x00, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}
+	query = append(query, encodeName(canonicalName(name))...)
+	query = binary.BigEndian.AppendUint16(query, TypeTXT)
+	query = binary.BigEndian.AppendUint16(query, classIN)
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, 4096)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+	resp = resp[:n]
+	if n < dnsHeaderSize || resp[0] != query[0] || resp[1] != query[1] {
+		return nil, fmt.Errorf("unexpected response from %s", addr)
+	}
+	if rcode := resp[3] & 0x0f; rcode != 0 {
+		return nil, fmt.Errorf("query for %s failed with rcode %d", name, rcode)
+	}
+
+	_, off, err := parseName(resp, dnsHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	off += 4
+	var values []string
+	for i := 0; i < int(binary.BigEndian.Uint16(resp[6:8])); i++ {
+		if _, off, err = parseName(resp, off); err != nil {
+			return nil, err
+		}
+		if off+10 > len(resp) {
+			return nil, ErrPacketTooShort
+		}
+		typ := binary.BigEndian.Uint16(resp[off : off+2])
+		rdlen := int(binary.BigEndian.Uint16(resp[off+8 : off+10]))
+		off += 10
+		if off+rdlen > len(resp) {
+			return nil, ErrPacketTooShort
+		}
+		if typ == TypeTXT {
+			var value strings.Builder
+			for rdata := resp[off : off+rdlen]; len(rdata) > 0; {
+				l := int(rdata[0])
+				if 1+l > len(rdata) {
+					return nil, ErrPacketTooShort
+				}
+				value.Write(rdata[1 : 1+l])
+				rdata = rdata[1+l:]
+			}
+			values = append(values, value.String())
+		}
+		off += rdlen
+	}
+	return values, nil
+}
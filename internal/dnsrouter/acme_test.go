@@ -0,0 +1,101 @@
+package dnsrouter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestACMEChallengeName(t *testing.T) {
+	tests := map[string]string{
+		"t.example.com":                 "_acme-challenge.t.example.com",
+		"*.T.Example.com.":              "_acme-challenge.t.example.com",
+		"_acme-challenge.t.example.com": "_acme-challenge.t.example.com",
+		" www.t.example.com ":           "_acme-challenge.www.t.example.com",
+	}
+	for domain, want := range tests {
+		if got := ACMEChallengeName(domain); got != want {
+			t.Errorf("ACMEChallengeName(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
+
+func TestACMEChallenges_SaveLoad(t *testing.T) {
+	orig := ACMEFile
+	ACMEFile = filepath.Join(t.TempDir(), "acme-txt.json")
+	defer func() { ACMEFile = orig }()
+
+	now := time.Now()
+	if got, err := LoadACMEChallenges(now); err != nil || len(got) != 0 {
+		t.Fatalf("LoadACMEChallenges() without file = %v, %v", got, err)
+	}
+
+	err := SaveACMEChallenges([]ACMEChallenge{
+		{Name: "_acme-challenge.t.example.com", Value: "live", Expires: now.Add(time.Hour)},
+		{Name: "_acme-challenge.t.example.com", Value: "stale", Expires: now.Add(-time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("SaveACMEChallenges() error = %v", err)
+	}
+	got, err := LoadACMEChallenges(now)
+	if err != nil {
+		t.Fatalf("LoadACMEChallenges() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Value != "live" {
+		t.Errorf("LoadACMEChallenges() = %+v, want only the unexpired value", got)
+	}
+
+	if err := SaveACMEChallenges(nil); err != nil {
+		t.Fatalf("SaveACMEChallenges(nil) error = %v", err)
+	}
+	if _, err := os.Stat(ACMEFile); !os.IsNotExist(err) {
+		t.Errorf("file not removed when no challenges are left: %v", err)
+	}
+}
+
+func TestWithACME(t *testing.T) {
+	base, err := ParseZone(strings.NewReader(testZone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := WithACME(base, []ACMEChallenge{{Name: "_acme-challenge.t.example.com", Value: "token"}})
+
+	if _, ok := base.nodes["_acme-challenge.t.example.com"]; ok {
+		t.Error("WithACME() modified the base zone")
+	}
+	rec := z.nodes["_acme-challenge.t.example.com"]
+	if len(rec) != 1 || rec[0].TTL != ACMETTL || string(rec[0].RData[1:]) != "token" {
+		t.Errorf("challenge record = %+v", rec)
+	}
+	if got := z.Domains(); len(got) != 1 || got[0] != "t.example.com" {
+		t.Errorf("Domains() = %v, want the base apex", got)
+	}
+	if !WithACME(nil, nil).IsEmpty() {
+		t.Error("WithACME(nil, nil) is not empty")
+	}
+}
+
+func TestLookupTXT_ServedByRouter(t *testing.T) {
+	r := NewRouter("127.0.0.1:0", nil, "")
+	if err := r.Start(); err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer r.Stop()
+	addr := r.conn.LocalAddr().String()
+	name := "_acme-challenge.t.example.com"
+
+	// Swapping the zone while running publishes the value
+	r.SetZone(WithACME(nil, []ACMEChallenge{
+		{Name: name, Value: "first"},
+		{Name: name, Value: strings.Repeat("x", 300)},
+	}))
+	values, err := LookupTXT(addr, name, time.Second)
+	if err != nil {
+		t.Fatalf("LookupTXT() error = %v", err)
+	}
+	if len(values) != 2 || values[0] != "first" || len(values[1]) != 300 {
+		t.Errorf("LookupTXT() = %q", values)
+	}
+}
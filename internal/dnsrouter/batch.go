@@ -0,0 +1,69 @@
+package dnsrouter
+
+import (
+	"net"
+	"net/netip"
+)
+
+// batchSize is the number of datagrams read or written per system call.
+const batchSize = 64
+
+// message is one datagram of a batch.
+type message struct {
+	buf  []byte         // packet storage; reads fill it, writes send buf[:n]
+	n    int            // packet length
+	addr netip.AddrPort // peer, unset on connected sockets
+}
+
+// batchConn moves datagrams in batches over a UDP socket. On Linux it uses
+// recvmmsg and sendmmsg; elsewhere each call moves one datagram. A
+// batchConn is not safe for concurrent use, but several can share a socket.
+type batchConn interface {
+	// ReadBatch blocks until at least one datagram arrives and returns how
+	// many of msgs were filled.
+	ReadBatch(msgs []message) (int, error)
+
+	// WriteBatch sends msgs, skipping any that fail, and returns the first
+	// error.
+	WriteBatch(msgs []message) error
+}
+
+// newMessages returns a batch of messages with MaxPacketSize buffers.
+func newMessages(n int) []message {
+	msgs := make([]message, n)
+	for i := range msgs {
+		msgs[i].buf = make([]byte, MaxPacketSize)
+	}
+	return msgs
+}
+
+// udpBatchConn is the portable batchConn that moves one datagram per call.
+type udpBatchConn struct {
+	conn *net.UDPConn
+}
+
+func (c *udpBatchConn) ReadBatch(msgs []message) (int, error) {
+	n, addr, err := c.conn.ReadFromUDPAddrPort(msgs[0].buf)
+	if err != nil {
+		return 0, err
+	}
+	msgs[0].n = n
+	msgs[0].addr = addr
+	return 1, nil
+}
+
+func (c *udpBatchConn) WriteBatch(msgs []message) error {
+	var firstErr error
+	for i := range msgs {
+		var err error
+		if msgs[i].addr.IsValid() {
+			_, err = c.conn.WriteToUDPAddrPort(msgs[i].buf[:msgs[i].n], msgs[i].addr)
+		} else {
+			_, err = c.conn.Write(msgs[i].buf[:msgs[i].n])
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,169 @@
+package dnsrouter
+
+import (
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmsghdr is struct mmsghdr; Go pads it the same way C does.
+type mmsghdr struct {
+	hdr unix.Msghdr
+	len uint32
+}
+
+// mmsgConn is the batchConn that uses recvmmsg and sendmmsg, waiting for
+// the socket through the runtime poller.
+type mmsgConn struct {
+	rc    syscall.RawConn
+	hdrs  []mmsghdr
+	iovs  []unix.Iovec
+	names []unix.RawSockaddrInet6 // large enough for IPv4 too
+}
+
+// newBatchConn returns a batchConn over conn.
+func newBatchConn(conn *net.UDPConn) batchConn {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return &udpBatchConn{conn: conn}
+	}
+	return &mmsgConn{
+		rc:    rc,
+		hdrs:  make([]mmsghdr, batchSize),
+		iovs:  make([]unix.Iovec, batchSize),
+		names: make([]unix.RawSockaddrInet6, batchSize),
+	}
+}
+
+// prepare points the headers at msgs: with room for the sender address
+// for reads, with the peer address, if any, for writes.
+func (c *mmsgConn) prepare(msgs []message, write bool) {
+	for i := range msgs {
+		c.iovs[i].Base = &msgs[i].buf[0]
+		h := &c.hdrs[i].hdr
+		*h = unix.Msghdr{Iov: &c.iovs[i]}
+		h.SetIovlen(1)
+		switch {
+		case !write:
+			c.iovs[i].SetLen(len(msgs[i].buf))
+			h.Name = (*byte)(unsafe.Pointer(&c.names[i]))
+			h.Namelen = unix.SizeofSockaddrInet6
+		case msgs[i].addr.IsValid():
+			c.iovs[i].SetLen(msgs[i].n)
+			h.Name = (*byte)(unsafe.Pointer(&c.names[i]))
+			h.Namelen = putSockaddr(&c.names[i], msgs[i].addr)
+		default:
+			c.iovs[i].SetLen(msgs[i].n)
+		}
+	}
+}
+
+// mmsg runs recvmmsg or sendmmsg on the prepared headers, waiting until the
+// socket is ready.
+func (c *mmsgConn) mmsg(trap uintptr, count int) (int, error) {
+	var n uintptr
+	var errno syscall.Errno
+	call := func(fd uintptr) bool {
+		for {
+			n, _, errno = unix.Syscall6(trap, fd, uintptr(unsafe.Pointer(&c.hdrs[0])), uintptr(count), unix.MSG_DONTWAIT, 0, 0)
+			if errno != unix.EINTR {
+				return errno != unix.EAGAIN
+			}
+		}
+	}
+
+	var err error
+	if trap == unix.SYS_RECVMMSG {
+		err = c.rc.Read(call)
+	} else {
+		err = c.rc.Write(call)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		name := "sendmmsg"
+		if trap == unix.SYS_RECVMMSG {
+			name = "recvmmsg"
+		}
+		return 0, os.NewSyscallError(name, errno)
+	}
+	return int(n), nil
+}
+
+func (c *mmsgConn) ReadBatch(msgs []message) (int, error) {
+	msgs = msgs[:min(len(msgs), len(c.hdrs))]
+	c.prepare(msgs, false)
+	n, err := c.mmsg(unix.SYS_RECVMMSG, len(msgs))
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < n; i++ {
+		msgs[i].n = int(c.hdrs[i].len)
+		msgs[i].addr = sockaddrAddrPort(&c.names[i])
+	}
+	return n, nil
+}
+
+func (c *mmsgConn) WriteBatch(msgs []message) error {
+	var firstErr error
+	for len(msgs) > 0 {
+		chunk := msgs[:min(len(msgs), len(c.hdrs))]
+		c.prepare(chunk, true)
+		n, err := c.mmsg(unix.SYS_SENDMMSG, len(chunk))
+		switch {
+		case err != nil:
+			// The first datagram failed; skip it and carry on
+			if firstErr == nil {
+				firstErr = err
+			}
+			n = 1
+		case n == 0:
+			return io.ErrShortWrite
+		}
+		msgs = msgs[n:]
+	}
+	return firstErr
+}
+
+// sockaddrAddrPort converts a sockaddr_in or sockaddr_in6.
+func sockaddrAddrPort(sa *unix.RawSockaddrInet6) netip.AddrPort {
+	switch sa.Family {
+	case unix.AF_INET:
+		sa4 := (*unix.RawSockaddrInet4)(unsafe.Pointer(sa))
+		return netip.AddrPortFrom(netip.AddrFrom4(sa4.Addr), portFromNetwork(sa4.Port))
+	case unix.AF_INET6:
+		return netip.AddrPortFrom(netip.AddrFrom16(sa.Addr), portFromNetwork(sa.Port))
+	}
+	return netip.AddrPort{}
+}
+
+// putSockaddr stores ap as a sockaddr_in or sockaddr_in6 and returns its size.
+func putSockaddr(sa *unix.RawSockaddrInet6, ap netip.AddrPort) uint32 {
+	if ap.Addr().Is4() {
+		sa4 := (*unix.RawSockaddrInet4)(unsafe.Pointer(sa))
+		*sa4 = unix.RawSockaddrInet4{Family: unix.AF_INET, Port: portToNetwork(ap.Port()), Addr: ap.Addr().As4()}
+		return unix.SizeofSockaddrInet4
+	}
+	*sa = unix.RawSockaddrInet6{Family: unix.AF_INET6, Port: portToNetwork(ap.Port()), Addr: ap.Addr().As16()}
+	return unix.SizeofSockaddrInet6
+}
+
+// portFromNetwork reads a port stored in network byte order.
+func portFromNetwork(port uint16) uint16 {
+	b := (*[2]byte)(unsafe.Pointer(&port))
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+// portToNetwork returns port in network byte order.
+func portToNetwork(port uint16) uint16 {
+	var out uint16
+	b := (*[2]byte)(unsafe.Pointer(&out))
+	b[0], b[1] = byte(port>>8), byte(port)
+	return out
+}
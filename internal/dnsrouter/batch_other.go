@@ -0,0 +1,10 @@
+//go:build !linux
+
+package dnsrouter
+
+import "net"
+
+// newBatchConn returns a batchConn over conn.
+func newBatchConn(conn *net.UDPConn) batchConn {
+	return &udpBatchConn{conn: conn}
+}
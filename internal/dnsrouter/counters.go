@@ -0,0 +1,51 @@
+package dnsrouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CountersFile holds the latest query counters of the router.
+var CountersFile = filepath.Join(StateDir, "counters.json")
+
+// Counters are the query counters of a running router. They start at zero
+// each time the router starts.
+type Counters struct {
+	Queries  uint64            `json:"queries"`
+	Errors   uint64            `json:"errors"`
+	Backends map[string]uint64 `json:"backends,omitempty"` // queries forwarded per backend address
+	Updated  time.Time         `json:"updated"`
+}
+
+// SaveCounters writes c to CountersFile.
+func SaveCounters(c *Counters) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := CountersFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, CountersFile)
+}
+
+// LoadCounters reads the counters last written by the router. A missing
+// file yields nil.
+func LoadCounters() (*Counters, error) {
+	data, err := os.ReadFile(CountersFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read router counters: %w", err)
+	}
+	var c Counters
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", CountersFile, err)
+	}
+	return &c, nil
+}
@@ -2,9 +2,12 @@ package dnsrouter
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"log"
 	"net"
+	"net/netip"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,54 +20,86 @@ const (
 	// DefaultTimeout is the default upstream query timeout
 	DefaultTimeout = 5 * time.Second
 
-)
+	// DrainTimeout bounds how long Stop waits for the responses to
+	// forwarded queries
+	DrainTimeout = 2 * time.Second
 
-// Buffer pools to reduce allocations
-var (
-	packetPool = sync.Pool{
-		New: func() interface{} {
-			buf := make([]byte, MaxPacketSize)
-			return &buf
-		},
-	}
 )
 
 // Route defines a domain suffix to backend mapping.
 type Route struct {
 	Domain  string // Domain suffix to match (e.g., "example.com")
 	Backend string // Backend address (e.g., "127.0.0.1:5310")
+
+	// Canary takes CanaryPercent of the client networks from Backend
+	Canary        string
+	CanaryPercent int
+
+	// Reply, if set, is the response code queries are answered with
+	// instead of being forwarded, while the tunnel is paused
+	Reply uint8
 }
 
-// pendingQuery represents a query waiting for a response
+// pendingQuery is a query forwarded to a backend, waiting for its response.
 type pendingQuery struct {
-	responseCh chan []byte
-	deadline   time.Time
+	client   netip.AddrPort
+	id       uint16 // transaction ID chosen by the client
+	payload  int    // EDNS0 payload size advertised by the client
+	deadline int64  // Unix nanoseconds
 }
 
-// backendConn manages a persistent connection to a backend
+// backendConn forwards queries to one backend over a connected socket.
+// Each query is sent under the next transaction ID in turn, so IDs picked
+// by different clients never collide, and the slot of that ID holds what
+// is needed to send the response back. Once the IDs wrap around, slots
+// whose query is still waiting are skipped and expired ones reused; a
+// response arriving after its deadline is dropped.
 type backendConn struct {
-	addr    *net.UDPAddr
+	addr    string
 	conn    *net.UDPConn
-	mu      sync.Mutex
-	pending map[uint16]*pendingQuery // keyed by DNS transaction ID
+	nextID  atomic.Uint32
+	queries atomic.Uint64 // forwarded since the connection was opened
+	pending [1 << 16]atomic.Pointer[pendingQuery]
 	ctx     context.Context
 	cancel  context.CancelFunc
 	wg      sync.WaitGroup
-	timeout time.Duration
 }
 
 // Router is a minimal DNS router that forwards raw packets.
+//
+// By default one worker per CPU reads queries in batches, on Linux each
+// from its own socket on the listen port so the kernel spreads clients
+// across them. A worker answers the queries the zone covers and queues the
+// rest for their backends, then writes each queue with one system call.
+// One reader per backend sends the responses back to the clients the same
+// way. Routing takes no lock: the route table is immutable, each worker
+// keeps its own reference to the backend connections, and pending queries
+// live in per-backend slots indexed by transaction ID.
 type Router struct {
 	listenAddr     string
 	routes         []Route
+	table          *routeTable
+	stateless      bool
 	defaultBackend string
 	timeout        time.Duration
+	zone           atomic.Pointer[Zone]
+	statsFile      string
+
+	// Response size counters per resolver
+	resolvers *resolverTracker
 
-	conn   *net.UDPConn
+	workers int
+
+	conns  []*net.UDPConn // sockets sharing the listen port
+	conn   *net.UDPConn   // the first, which also carries backend responses
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
+	// Workers reading queries, stopped before the backends
+	serving  sync.WaitGroup
+	stopping atomic.Bool
+
 	// Backend connection pool
 	backends   map[string]*backendConn
 	backendsMu sync.RWMutex
@@ -79,9 +114,11 @@ func NewRouter(listenAddr string, routes []Route, defaultBackend string) *Router
 	return &Router{
 		listenAddr:     listenAddr,
 		routes:         routes,
+		table:          newRouteTable(routes, false),
 		defaultBackend: defaultBackend,
 		timeout:        DefaultTimeout,
 		backends:       make(map[string]*backendConn),
+		resolvers:      newResolverTracker(),
 	}
 }
 
@@ -90,35 +127,103 @@ func (r *Router) SetTimeout(timeout time.Duration) {
 	r.timeout = timeout
 }
 
+// SetWorkers sets the number of workers, 0 for one per CPU. On Linux each
+// worker reads its own socket on the listen port (SO_REUSEPORT).
+func (r *Router) SetWorkers(n int) {
+	r.workers = n
+}
+
+// SetStateless makes the backend of a query depend on its name alone, so
+// that routers sharing an address behind ECMP or anycast route each name
+// the same way whichever of them the network hands a packet to. Canaries,
+// which split clients by network, are not routed. It must be called before
+// Start.
+func (r *Router) SetStateless(stateless bool) {
+	r.stateless = stateless
+	r.table = newRouteTable(r.routes, stateless)
+}
+
+// SetZone sets the records the router answers itself instead of routing.
+// It can be called while the router is running.
+func (r *Router) SetZone(zone *Zone) {
+	r.zone.Store(zone)
+}
+
+// SetResolverStatsFile makes the router write the per-resolver counters
+// to path every minute while running.
+func (r *Router) SetResolverStatsFile(path string) {
+	r.statsFile = path
+}
+
 // Start starts the DNS router.
 func (r *Router) Start() error {
-	addr, err := net.ResolveUDPAddr("udp", r.listenAddr)
-	if err != nil {
-		return fmt.Errorf("failed to resolve address: %w", err)
+	workers := r.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
 	}
 
-	conn, err := net.ListenUDP("udp", addr)
-	if err != nil {
-		return fmt.Errorf("failed to listen: %w", err)
+	// Sockets a previous process left in the systemd store are taken over,
+	// and every one of them needs a worker
+	conns := inheritListeners(r.listenAddr)
+	inherited := len(conns)
+	if inherited < workers {
+		addr := r.listenAddr
+		if inherited > 0 {
+			addr = conns[0].LocalAddr().String()
+		}
+		more, err := listenUDP(addr, workers-inherited)
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+		conns = append(conns, more...)
+	}
+	workers = max(workers, len(conns))
+	if err := storeListeners(conns); err != nil {
+		log.Printf("[warning] listen sockets not kept for restarts: %v", err)
 	}
 
-	r.conn = conn
+	r.conns = conns
+	r.conn = conns[0]
 	r.ctx, r.cancel = context.WithCancel(context.Background())
 
-	r.wg.Add(1)
-	go r.serve()
+	for i := 0; i < workers; i++ {
+		r.serving.Add(1)
+		go r.serve(conns[i%len(conns)])
+	}
+
+	if r.statsFile != "" {
+		r.wg.Add(1)
+		go r.writeStats()
+	}
 
-	log.Printf("[dnsrouter] Listening on %s (with connection pooling)", r.listenAddr)
+	if inherited > 0 {
+		log.Printf("[dnsrouter] Took over %d sockets from the previous process", inherited)
+	}
+	log.Printf("[dnsrouter] Listening on %s (%d workers, %d sockets)", r.listenAddr, workers, len(conns))
 	return nil
 }
 
-// Stop stops the DNS router.
+// Stop stops the DNS router. Workers stop reading first; the responses to
+// queries already forwarded are still sent back for up to DrainTimeout.
+// Queries that arrive meanwhile stay in the socket buffers, where the
+// next process finds them when the sockets are kept for a restart.
 func (r *Router) Stop() error {
-	if r.cancel != nil {
-		r.cancel()
+	if r.cancel == nil {
+		return nil
 	}
-	if r.conn != nil {
-		r.conn.Close()
+	r.stopping.Store(true)
+	for _, conn := range r.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	r.serving.Wait()
+	r.drain(DrainTimeout)
+
+	r.cancel()
+	for _, conn := range r.conns {
+		conn.Close()
 	}
 
 	// Close all backend connections
@@ -134,97 +239,196 @@ func (r *Router) Stop() error {
 	return nil
 }
 
-// serve handles incoming DNS queries.
-func (r *Router) serve() {
-	defer r.wg.Done()
+// drain waits until no forwarded query is waiting for its response, or for
+// at most timeout.
+func (r *Router) drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		waiting := 0
+		r.backendsMu.RLock()
+		for _, bc := range r.backends {
+			waiting += bc.inFlight()
+		}
+		r.backendsMu.RUnlock()
+		if waiting == 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
 
-	buf := make([]byte, MaxPacketSize)
+// writeStats saves the resolver counters and the query counters every
+// minute and on stop.
+func (r *Router) writeStats() {
+	defer r.wg.Done()
 
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	failed := false
 	for {
 		select {
 		case <-r.ctx.Done():
+			SaveResolverStats(r.resolvers.snapshot())
+			SaveCounters(r.Counters())
 			return
-		default:
+		case <-ticker.C:
+		}
+		err := SaveResolverStats(r.resolvers.snapshot())
+		if err == nil {
+			err = SaveCounters(r.Counters())
+		}
+		if err != nil && !failed {
+			log.Printf("[warning] router stats not saved: %v", err)
+			failed = true
 		}
+	}
+}
+
+// worker is the state of one serve loop: its own batchConns over the
+// shared sockets and the packets queued while handling a batch.
+type worker struct {
+	clients  batchConn
+	answers  []message
+	backends map[string]*backendConn
+	forwards map[*backendConn]*backendQueue
+	name     []byte
+}
+
+// backendQueue holds the queries a worker forwards to one backend.
+type backendQueue struct {
+	conn batchConn
+	msgs []message
+}
 
-		// Set read deadline so we can check for context cancellation
-		r.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+// serve reads queries from conn in batches and answers or forwards each
+// of them.
+func (r *Router) serve(conn *net.UDPConn) {
+	defer r.serving.Done()
 
-		n, clientAddr, err := r.conn.ReadFromUDP(buf)
+	w := &worker{
+		clients:  newBatchConn(conn),
+		backends: make(map[string]*backendConn),
+		forwards: make(map[*backendConn]*backendQueue),
+		name:     make([]byte, 0, 256),
+	}
+	in := newBatchConn(conn)
+	msgs := newMessages(batchSize)
+
+	for {
+		n, err := in.ReadBatch(msgs)
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				continue
-			}
-			if r.ctx.Err() != nil {
+			if r.stopping.Load() {
 				return
 			}
 			log.Printf("[dnsrouter] Read error: %v", err)
 			continue
 		}
 
-		// Get a buffer from pool and copy packet
-		packetBuf := packetPool.Get().(*[]byte)
-		packet := (*packetBuf)[:n]
-		copy(packet, buf[:n])
-
-		// Handle the query in a goroutine
-		go r.handleQuery(packet, packetBuf, clientAddr)
+		for i := 0; i < n; i++ {
+			r.handleQuery(w, &msgs[i])
+		}
+		r.flush(w)
 	}
 }
 
-// handleQuery processes a single DNS query.
-func (r *Router) handleQuery(packet []byte, packetBuf *[]byte, clientAddr *net.UDPAddr) {
-	// Return buffer to pool when done
-	defer packetPool.Put(packetBuf)
-
+// handleQuery answers a query from the zone or queues it for its backend.
+// Queued queries point into the read buffer, so they must be written
+// before the next batch is read.
+func (r *Router) handleQuery(w *worker, m *message) {
 	r.queriesTotal.Add(1)
+	packet := m.buf[:m.n]
 
 	// Extract query name for routing
-	queryName, err := ExtractQueryName(packet)
+	queryName, err := appendQueryName(w.name[:0], packet)
+	w.name = queryName[:0]
 	if err != nil {
 		log.Printf("[dnsrouter] Failed to extract query name: %v", err)
 		r.errorsTotal.Add(1)
 		return
 	}
 
+	// Names with records in the zone are answered here
+	if zone := r.zone.Load(); zone != nil {
+		if response, err := zone.Answer(packet); err == nil {
+			w.answers = append(w.answers, message{buf: response, n: len(response), addr: m.addr})
+			return
+		}
+	}
+
 	// Find matching backend
-	backend := r.findBackend(queryName)
+	backend, reply := r.findBackend(queryName, m.addr.Addr())
+	if reply != 0 {
+		if response, err := ErrorResponse(packet, reply); err == nil {
+			w.answers = append(w.answers, message{buf: response, n: len(response), addr: m.addr})
+		}
+		return
+	}
 	if backend == "" {
 		log.Printf("[dnsrouter] No backend for query: %s", queryName)
 		r.errorsTotal.Add(1)
 		return
 	}
 
-	// Forward to backend and get response
-	response, err := r.forwardQuery(packet, backend)
-	if err != nil {
-		log.Printf("[dnsrouter] Forward error for %s -> %s: %v", queryName, backend, err)
+	bc := w.backends[backend]
+	if bc == nil {
+		if bc, err = r.getBackendConn(backend); err != nil {
+			log.Printf("[dnsrouter] Forward error for %s -> %s: %v", queryName, backend, err)
+			r.errorsTotal.Add(1)
+			return
+		}
+		w.backends[backend] = bc
+	}
+	ok, expired := bc.register(packet, m.addr, r.timeout)
+	if expired {
+		// The query that last used this ID was never answered
+		r.errorsTotal.Add(1)
+	}
+	if !ok {
+		// Every ID tried is still waiting for its response
 		r.errorsTotal.Add(1)
 		return
 	}
+	bc.queries.Add(1)
 
-	// Send response back to client
-	_, err = r.conn.WriteToUDP(response, clientAddr)
-	if err != nil {
-		log.Printf("[dnsrouter] Write error: %v", err)
-		r.errorsTotal.Add(1)
+	q := w.forwards[bc]
+	if q == nil {
+		q = &backendQueue{conn: newBatchConn(bc.conn)}
+		w.forwards[bc] = q
 	}
+	q.msgs = append(q.msgs, message{buf: m.buf, n: m.n})
 }
 
-// findBackend finds the backend for a query name.
-// Returns empty string if no route matches (request will be dropped).
-// Note: defaultBackend is kept for display/state preservation only, not for routing.
-func (r *Router) findBackend(queryName string) string {
-	// Check routes in order (first match wins)
-	for _, route := range r.routes {
-		if MatchDomainSuffix(queryName, route.Domain) {
-			return route.Backend
+// flush writes the answers and forwarded queries queued by a worker.
+func (r *Router) flush(w *worker) {
+	if len(w.answers) > 0 {
+		if err := w.clients.WriteBatch(w.answers); err != nil {
+			log.Printf("[dnsrouter] Write error: %v", err)
+			r.errorsTotal.Add(1)
 		}
+		clear(w.answers)
+		w.answers = w.answers[:0]
 	}
+	for bc, q := range w.forwards {
+		if len(q.msgs) == 0 {
+			continue
+		}
+		if err := q.conn.WriteBatch(q.msgs); err != nil {
+			log.Printf("[dnsrouter] Forward error for %s: failed to send query: %v", bc.addr, err)
+			r.errorsTotal.Add(1)
+		}
+		q.msgs = q.msgs[:0]
+	}
+}
 
-	// No match - drop the request
+// findBackend finds the backend for a lowercase query name, or the
+// response code to answer with when its route is paused.
+// Returns empty string if no route matches (request will be dropped).
+// In stateless mode the table has no canaries, so client is not consulted.
+// Note: defaultBackend is kept for display/state preservation only, not for routing.
+func (r *Router) findBackend(queryName []byte, client netip.Addr) (string, uint8) {
+	// The longest matching domain wins; no match drops the request
 	// (defaultBackend is only used for display and mode-switching state preservation)
-	return ""
+	return r.table.lookup(queryName, client)
 }
 
 // getBackendConn gets or creates a persistent connection to a backend.
@@ -260,17 +464,15 @@ func (r *Router) getBackendConn(backend string) (*backendConn, error) {
 
 	ctx, cancel := context.WithCancel(r.ctx)
 	bc = &backendConn{
-		addr:    addr,
-		conn:    conn,
-		pending: make(map[uint16]*pendingQuery),
-		ctx:     ctx,
-		cancel:  cancel,
-		timeout: r.timeout,
+		addr:   backend,
+		conn:   conn,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 
 	// Start response reader goroutine
 	bc.wg.Add(1)
-	go bc.readResponses()
+	go r.readResponses(bc)
 
 	r.backends[backend] = bc
 	log.Printf("[dnsrouter] Created connection pool for backend %s", backend)
@@ -278,114 +480,52 @@ func (r *Router) getBackendConn(backend string) (*backendConn, error) {
 	return bc, nil
 }
 
-// forwardQuery forwards a raw DNS packet to a backend and returns the response.
-func (r *Router) forwardQuery(packet []byte, backend string) ([]byte, error) {
-	bc, err := r.getBackendConn(backend)
-	if err != nil {
-		return nil, err
-	}
-
-	return bc.query(packet, r.timeout)
-}
-
-// query sends a DNS query and waits for the response
-func (bc *backendConn) query(packet []byte, timeout time.Duration) ([]byte, error) {
-	if len(packet) < 2 {
-		return nil, fmt.Errorf("packet too short")
-	}
-
-	// Extract transaction ID (first 2 bytes)
-	txid := uint16(packet[0])<<8 | uint16(packet[1])
-
-	// Create response channel
-	responseCh := make(chan []byte, 1)
+// maxIDProbes bounds how many transaction IDs register tries for a query.
+// Finding them all in use means the backend is far behind, and the query
+// is dropped.
+const maxIDProbes = 64
+
+// register records a query from client and rewrites its transaction ID to
+// the one it is forwarded under, skipping IDs whose query is still waiting
+// for its response. It reports false when no free ID was found, leaving
+// the packet as it was, and whether the slot it took held a query that was
+// never answered.
+func (bc *backendConn) register(packet []byte, client netip.AddrPort, timeout time.Duration) (ok, expired bool) {
+	now := time.Now()
 	pq := &pendingQuery{
-		responseCh: responseCh,
-		deadline:   time.Now().Add(timeout),
-	}
-
-	// Register pending query
-	bc.mu.Lock()
-	if _, exists := bc.pending[txid]; exists {
-		bc.mu.Unlock()
-		// Transaction ID collision - very rare, fall back to simple approach
-		return bc.querySimple(packet, timeout)
-	}
-	bc.pending[txid] = pq
-	bc.mu.Unlock()
-
-	// Ensure cleanup
-	defer func() {
-		bc.mu.Lock()
-		delete(bc.pending, txid)
-		bc.mu.Unlock()
-	}()
-
-	// Send query
-	_, err := bc.conn.Write(packet)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send query: %w", err)
+		client:   client,
+		id:       binary.BigEndian.Uint16(packet),
+		payload:  ednsPayloadSize(packet),
+		deadline: now.Add(timeout).UnixNano(),
 	}
-
-	// Wait for response
-	select {
-	case response := <-responseCh:
-		return response, nil
-	case <-time.After(timeout):
-		return nil, fmt.Errorf("timeout waiting for response")
-	case <-bc.ctx.Done():
-		return nil, fmt.Errorf("backend connection closed")
-	}
-}
-
-// querySimple is a fallback for transaction ID collisions
-func (bc *backendConn) querySimple(packet []byte, timeout time.Duration) ([]byte, error) {
-	// Create a temporary connection for this query
-	conn, err := net.DialUDP("udp", nil, bc.addr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp connection: %w", err)
-	}
-	defer conn.Close()
-
-	conn.SetDeadline(time.Now().Add(timeout))
-
-	_, err = conn.Write(packet)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send query: %w", err)
-	}
-
-	buf := make([]byte, MaxPacketSize)
-	n, err := conn.Read(buf)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	for i := 0; i < maxIDProbes; i++ {
+		id := uint16(bc.nextID.Add(1))
+		old := bc.pending[id].Load()
+		if old != nil && old.deadline >= now.UnixNano() {
+			continue
+		}
+		if !bc.pending[id].CompareAndSwap(old, pq) {
+			continue
+		}
+		binary.BigEndian.PutUint16(packet, id)
+		return true, old != nil
 	}
-
-	return buf[:n], nil
+	return false, false
 }
 
-// readResponses reads responses from the backend and dispatches them
-func (bc *backendConn) readResponses() {
+// readResponses reads responses from a backend and sends each back to the
+// client that asked, under the client's transaction ID.
+func (r *Router) readResponses(bc *backendConn) {
 	defer bc.wg.Done()
 
-	buf := make([]byte, MaxPacketSize)
+	in := newBatchConn(bc.conn)
+	out := newBatchConn(r.conn)
+	msgs := newMessages(batchSize)
+	replies := make([]message, 0, batchSize)
 
 	for {
-		select {
-		case <-bc.ctx.Done():
-			return
-		default:
-		}
-
-		// Set read deadline for periodic context checks
-		bc.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
-
-		n, err := bc.conn.Read(buf)
+		n, err := in.ReadBatch(msgs)
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				// Cleanup expired pending queries
-				bc.cleanupExpired()
-				continue
-			}
 			if bc.ctx.Err() != nil {
 				return
 			}
@@ -393,45 +533,38 @@ func (bc *backendConn) readResponses() {
 			continue
 		}
 
-		if n < 2 {
-			continue
-		}
-
-		// Extract transaction ID
-		txid := uint16(buf[0])<<8 | uint16(buf[1])
-
-		// Find and dispatch to pending query
-		bc.mu.Lock()
-		pq, exists := bc.pending[txid]
-		if exists {
-			delete(bc.pending, txid)
-		}
-		bc.mu.Unlock()
-
-		if exists {
-			// Make a copy of the response
-			response := make([]byte, n)
-			copy(response, buf[:n])
-
-			// Non-blocking send (query might have timed out)
-			select {
-			case pq.responseCh <- response:
-			default:
+		now := time.Now()
+		replies = replies[:0]
+		for i := 0; i < n; i++ {
+			response := msgs[i].buf[:msgs[i].n]
+			if len(response) < dnsHeaderSize {
+				continue
+			}
+			pq := bc.pending[binary.BigEndian.Uint16(response)].Swap(nil)
+			if pq == nil || now.UnixNano() > pq.deadline {
+				continue
 			}
+			binary.BigEndian.PutUint16(response, pq.id)
+			r.resolvers.record(pq.client.Addr().Unmap().String(), pq.payload, response, now)
+			replies = append(replies, message{buf: msgs[i].buf, n: msgs[i].n, addr: pq.client})
+		}
+		if err := out.WriteBatch(replies); err != nil {
+			log.Printf("[dnsrouter] Write error: %v", err)
+			r.errorsTotal.Add(1)
 		}
 	}
 }
 
-// cleanupExpired removes expired pending queries
-func (bc *backendConn) cleanupExpired() {
-	now := time.Now()
-	bc.mu.Lock()
-	for txid, pq := range bc.pending {
-		if now.After(pq.deadline) {
-			delete(bc.pending, txid)
+// inFlight counts the queries still waiting for a response.
+func (bc *backendConn) inFlight() int {
+	now := time.Now().UnixNano()
+	n := 0
+	for i := range bc.pending {
+		if pq := bc.pending[i].Load(); pq != nil && pq.deadline >= now {
+			n++
 		}
 	}
-	bc.mu.Unlock()
+	return n
 }
 
 // close closes the backend connection
@@ -446,6 +579,25 @@ func (r *Router) Stats() (queries, errors uint64) {
 	return r.queriesTotal.Load(), r.errorsTotal.Load()
 }
 
+// Counters returns the query counters of the router, including the queries
+// forwarded to each backend.
+func (r *Router) Counters() *Counters {
+	c := &Counters{
+		Queries: r.queriesTotal.Load(),
+		Errors:  r.errorsTotal.Load(),
+		Updated: time.Now(),
+	}
+	r.backendsMu.RLock()
+	for addr, bc := range r.backends {
+		if c.Backends == nil {
+			c.Backends = make(map[string]uint64, len(r.backends))
+		}
+		c.Backends[addr] = bc.queries.Load()
+	}
+	r.backendsMu.RUnlock()
+	return c
+}
+
 // GetRoutes returns the configured routes.
 func (r *Router) GetRoutes() []Route {
 	return r.routes
@@ -456,6 +608,12 @@ func (r *Router) GetDefaultBackend() string {
 	return r.defaultBackend
 }
 
+// ResolverStats returns the response size counters per resolver, most
+// clamped first.
+func (r *Router) ResolverStats() []ResolverStat {
+	return r.resolvers.snapshot()
+}
+
 // BackendStats returns statistics about backend connections
 func (r *Router) BackendStats() map[string]int {
 	r.backendsMu.RLock()
@@ -463,9 +621,7 @@ func (r *Router) BackendStats() map[string]int {
 
 	stats := make(map[string]int)
 	for backend, bc := range r.backends {
-		bc.mu.Lock()
-		stats[backend] = len(bc.pending)
-		bc.mu.Unlock()
+		stats[backend] = bc.inFlight()
 	}
 	return stats
 }
@@ -0,0 +1,222 @@
+package dnsrouter
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// startEchoBackend answers every query with the query itself, flagged as
+// a response.
+func startEchoBackend(t *testing.T) string {
+	return startSlowEchoBackend(t, 0)
+}
+
+// startSlowEchoBackend is startEchoBackend answering after delay.
+func startSlowEchoBackend(t *testing.T, delay time.Duration) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, MaxPacketSize)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			buf[2] |= 0x80
+			time.Sleep(delay)
+			conn.WriteToUDP(buf[:n], addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestRouter_Forwards(t *testing.T) {
+	backend := startEchoBackend(t)
+	r := NewRouter("127.0.0.1:0", []Route{{Domain: "t.example.com", Backend: backend}}, "")
+	r.SetWorkers(4)
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer r.Stop()
+
+	// Two clients using the same transaction ID get their own responses
+	query := buildTestQuery("abc.t.example.com", TypeTXT)
+	var clients []*net.UDPConn
+	for i := 0; i < 2; i++ {
+		c, err := net.DialUDP("udp", nil, r.conn.LocalAddr().(*net.UDPAddr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		clients = append(clients, c)
+		if _, err := c.Write(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, c := range clients {
+		c.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, MaxPacketSize)
+		n, err := c.Read(buf)
+		if err != nil {
+			t.Fatalf("client %d: no response: %v", i, err)
+		}
+		if n != len(query) || binary.BigEndian.Uint16(buf) != 0xabcd || buf[2]&0x80 == 0 {
+			t.Errorf("client %d: response % x, want the echoed query under ID abcd", i, buf[:n])
+		}
+	}
+
+	// Queries without a route are dropped
+	clients[0].Write(buildTestQuery("abc.other.org", TypeTXT))
+	clients[0].SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := clients[0].Read(make([]byte, MaxPacketSize)); err == nil {
+		t.Error("unrouted query got a response")
+	}
+	if queries, errors := r.Stats(); queries != 3 || errors != 1 {
+		t.Errorf("Stats() = %d, %d, want 3, 1", queries, errors)
+	}
+	if c := r.Counters(); c.Backends[backend] != 2 {
+		t.Errorf("Counters().Backends[%s] = %d, want 2", backend, c.Backends[backend])
+	}
+}
+
+func TestBackendConn_RegisterSkipsLiveIDs(t *testing.T) {
+	bc := &backendConn{}
+	client := netip.MustParseAddrPort("192.0.2.1:5300")
+	query := buildTestQuery("abc.t.example.com", TypeTXT)
+
+	// Every ID is taken by a query still waiting for its response
+	for i := 0; i < 1<<16; i++ {
+		if ok, _ := bc.register(query, client, time.Minute); !ok {
+			t.Fatalf("register() failed after %d queries", i)
+		}
+	}
+	packet := buildTestQuery("abc.t.example.com", TypeTXT)
+	if ok, _ := bc.register(packet, client, time.Minute); ok {
+		t.Fatal("register() took the slot of a live query")
+	}
+	if id := binary.BigEndian.Uint16(packet); id != 0xabcd {
+		t.Errorf("dropped query was rewritten to ID %04x", id)
+	}
+
+	// An expired slot is reused
+	next := uint16(bc.nextID.Load() + 10)
+	bc.pending[next].Load().deadline = 0
+	ok, expired := bc.register(packet, client, time.Minute)
+	if !ok || !expired {
+		t.Fatalf("register() = %v, %v, want the expired slot", ok, expired)
+	}
+	if id := binary.BigEndian.Uint16(packet); id != next {
+		t.Errorf("query forwarded under ID %04x, want %04x", id, next)
+	}
+}
+
+func TestRouter_StopDrains(t *testing.T) {
+	backend := startSlowEchoBackend(t, 300*time.Millisecond)
+	r := NewRouter("127.0.0.1:0", []Route{{Domain: "t.example.com", Backend: backend}}, "")
+	r.SetWorkers(1)
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	c, err := net.DialUDP("udp", nil, r.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write(buildTestQuery("abc.t.example.com", TypeTXT)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The query is forwarded before the router is told to stop
+	time.Sleep(100 * time.Millisecond)
+	stopped := make(chan struct{})
+	go func() {
+		r.Stop()
+		close(stopped)
+	}()
+
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.Read(make([]byte, MaxPacketSize)); err != nil {
+		t.Errorf("response to a forwarded query lost on stop: %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(DrainTimeout + time.Second):
+		t.Error("Stop() did not return after the drain")
+	}
+}
+
+func TestListenUDP(t *testing.T) {
+	conns, err := listenUDP("127.0.0.1:0", 3)
+	if err != nil {
+		t.Fatalf("listenUDP() error = %v", err)
+	}
+	for _, c := range conns {
+		defer c.Close()
+		if c.LocalAddr().String() != conns[0].LocalAddr().String() {
+			t.Errorf("socket on %v, want all on %v", c.LocalAddr(), conns[0].LocalAddr())
+		}
+	}
+	if runtime.GOOS == "linux" && len(conns) != 3 {
+		t.Errorf("listenUDP() opened %d sockets, want 3", len(conns))
+	}
+}
+
+func TestBatchConn(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, err := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	out := newMessages(3)
+	for i := range out {
+		out[i].n = copy(out[i].buf, []byte{byte(i), 'x'})
+	}
+	if err := newBatchConn(client).WriteBatch(out); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+
+	in := newBatchConn(server)
+	msgs := newMessages(batchSize)
+	var got []message
+	server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(got) < 3 {
+		n, err := in.ReadBatch(msgs)
+		if err != nil {
+			t.Fatalf("ReadBatch() error = %v", err)
+		}
+		for _, m := range msgs[:n] {
+			got = append(got, message{buf: append([]byte(nil), m.buf[:m.n]...), n: m.n, addr: m.addr})
+		}
+	}
+	for i, m := range got {
+		if m.n != 2 || m.buf[0] != byte(i) || m.addr.String() != client.LocalAddr().String() {
+			t.Errorf("message %d = % x from %v", i, m.buf[:m.n], m.addr)
+		}
+	}
+
+	// Replies addressed to the client arrive
+	got[0].buf[1] = 'y'
+	if err := in.WriteBatch(got[:1]); err != nil {
+		t.Fatalf("WriteBatch() to address error = %v", err)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 16)
+	if n, err := client.Read(buf); err != nil || string(buf[:n]) != "\x00y" {
+		t.Errorf("reply = % x, %v", buf[:n], err)
+	}
+}
@@ -12,6 +12,7 @@
 //	│  Stats() (queries, errors uint64)                           │
 //	│  GetRoutes() []Route                                        │
 //	│  GetDefaultBackend() string                                 │
+//	│  SetZone(zone *Zone)                                        │
 //	└─────────────────────────────────────────────────────────────┘
 //	                              ▲
 //	              ┌───────────────┼───────────────┐
@@ -71,6 +72,9 @@ type DNSForwarder interface {
 
 	// GetDefaultBackend returns the default backend address.
 	GetDefaultBackend() string
+
+	// SetZone replaces the records answered locally while running.
+	SetZone(zone *Zone)
 }
 
 // ForwarderConfig contains configuration for creating a DNS forwarder.
@@ -78,6 +82,13 @@ type ForwarderConfig struct {
 	ListenAddr     string
 	Routes         []Route
 	DefaultBackend string
+	Zone           *Zone  // Optional records answered instead of routed
+	StatsFile      string // Optional file for per-resolver response counters
+	Workers        int    // Listen sockets and workers, 0 for one per CPU
+	Stateless      bool   // Route by query name alone, for routers behind ECMP
+
+	// AnonymizeIP, if set, hides resolver addresses in the counters and logs
+	AnonymizeIP func(string) string
 }
 
 // ForwarderType identifies the DNS forwarder implementation.
@@ -100,15 +111,27 @@ const (
 func NewForwarder(ftype ForwarderType, cfg ForwarderConfig) (DNSForwarder, error) {
 	switch ftype {
 	case ForwarderTypeNative:
-		return NewRouter(cfg.ListenAddr, cfg.Routes, cfg.DefaultBackend), nil
+		return newNativeRouter(cfg), nil
 	// Future implementations:
 	// case ForwarderTypeCoreDNS:
 	//     return NewCoreDNSForwarder(cfg)
 	// case ForwarderTypeEBPF:
 	//     return NewEBPFForwarder(cfg)
 	default:
-		return NewRouter(cfg.ListenAddr, cfg.Routes, cfg.DefaultBackend), nil
+		return newNativeRouter(cfg), nil
+	}
+}
+
+func newNativeRouter(cfg ForwarderConfig) *Router {
+	r := NewRouter(cfg.ListenAddr, cfg.Routes, cfg.DefaultBackend)
+	r.SetZone(cfg.Zone)
+	r.SetResolverStatsFile(cfg.StatsFile)
+	r.SetWorkers(cfg.Workers)
+	r.SetStateless(cfg.Stateless)
+	if cfg.AnonymizeIP != nil {
+		r.resolvers.anonymize = cfg.AnonymizeIP
 	}
+	return r
 }
 
 // Ensure Router implements DNSForwarder
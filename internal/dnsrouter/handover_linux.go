@@ -0,0 +1,134 @@
+package dnsrouter
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenFDName names the listen sockets in the systemd file descriptor
+// store.
+const listenFDName = "dnsrouter-listen"
+
+// listenFDsStart is the first descriptor systemd passes ($LISTEN_FDS).
+const listenFDsStart = 3
+
+// inheritListeners returns the listen sockets on addr that systemd passed
+// back from its file descriptor store, as a previous process of the
+// service left them there. Sockets on another address are dropped from
+// the store and closed, so that addr can be bound.
+func inheritListeners(addr string) []*net.UDPConn {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	want, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil
+	}
+	var conns, stale []*net.UDPConn
+	for i := 0; i < count; i++ {
+		fd := listenFDsStart + i
+		if i >= len(names) || names[i] != listenFDName {
+			continue
+		}
+		unix.CloseOnExec(fd)
+		f := os.NewFile(uintptr(fd), listenFDName)
+		pc, err := net.FilePacketConn(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		conn, ok := pc.(*net.UDPConn)
+		if !ok {
+			pc.Close()
+			continue
+		}
+		got := conn.LocalAddr().(*net.UDPAddr)
+		if got.Port == want.Port && got.IP.Equal(want.IP) {
+			conns = append(conns, conn)
+		} else {
+			stale = append(stale, conn)
+		}
+	}
+	if len(stale) > 0 {
+		// The store holds its own copies; they must go before the address
+		// is free. storeListeners puts back the ones kept.
+		notifyFDStore("FDSTOREREMOVE=1\nFDNAME="+listenFDName, nil)
+		for _, c := range stale {
+			c.Close()
+		}
+	}
+	return conns
+}
+
+// storeListeners hands copies of the listen sockets to the systemd file
+// descriptor store, replacing those stored before. systemd keeps them
+// open while the service restarts and passes them to the next process,
+// so queries arriving in between wait in the socket buffers instead of
+// being refused. Without $NOTIFY_SOCKET it does nothing.
+func storeListeners(conns []*net.UDPConn) error {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return nil
+	}
+	// The descriptors stay valid while the sockets are open. File() would
+	// do too, but its Fd() makes the shared socket blocking.
+	fds := make([]int, 0, len(conns))
+	for _, c := range conns {
+		rc, err := c.SyscallConn()
+		if err != nil {
+			return err
+		}
+		if err := rc.Control(func(fd uintptr) { fds = append(fds, int(fd)) }); err != nil {
+			return err
+		}
+	}
+
+	if err := notifyFDStore("FDSTOREREMOVE=1\nFDNAME="+listenFDName, nil); err != nil {
+		return err
+	}
+	// The kernel passes at most SCM_MAX_FD (253) descriptors per message
+	for len(fds) > 0 {
+		chunk := fds[:min(len(fds), 250)]
+		fds = fds[len(chunk):]
+		if err := notifyFDStore("FDSTORE=1\nFDNAME="+listenFDName, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyFDStore sends an sd_notify message to $NOTIFY_SOCKET with fds
+// attached.
+func notifyFDStore(state string, fds []int) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	sock, err := unix.Socket(unix.AF_UNIX, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to reach systemd: %w", err)
+	}
+	defer unix.Close(sock)
+	var oob []byte
+	if len(fds) > 0 {
+		oob = unix.UnixRights(fds...)
+	}
+	// A leading "@" names the abstract namespace, as systemd writes it
+	if err := unix.Sendmsg(sock, []byte(state), oob, &unix.SockaddrUnix{Name: addr}, 0); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
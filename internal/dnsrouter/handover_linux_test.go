@@ -0,0 +1,71 @@
+package dnsrouter
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestRouter_StoresListeners(t *testing.T) {
+	notify, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(t.TempDir(), "notify"), Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notify.Close()
+	t.Setenv("NOTIFY_SOCKET", notify.LocalAddr().String())
+
+	r := NewRouter("127.0.0.1:0", nil, "")
+	r.SetWorkers(2)
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	addr := r.conn.LocalAddr().(*net.UDPAddr)
+
+	// The old sockets are dropped from the store before the new are added
+	var states []string
+	var stored []*os.File
+	for len(states) < 2 {
+		notify.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf, oob := make([]byte, 256), make([]byte, unix.CmsgSpace(4*8))
+		n, oobn, _, _, err := notify.ReadMsgUnix(buf, oob)
+		if err != nil {
+			t.Fatalf("no notification: %v", err)
+		}
+		states = append(states, string(buf[:n]))
+		msgs, _ := unix.ParseSocketControlMessage(oob[:oobn])
+		for _, m := range msgs {
+			fds, _ := unix.ParseUnixRights(&m)
+			for _, fd := range fds {
+				stored = append(stored, os.NewFile(uintptr(fd), "stored"))
+			}
+		}
+	}
+	if !strings.HasPrefix(states[0], "FDSTOREREMOVE=1\n") || !strings.HasPrefix(states[1], "FDSTORE=1\n") {
+		t.Errorf("notifications = %q", states)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("%d sockets stored, want one per worker", len(stored))
+	}
+
+	// What the store holds keeps the port open after the router stops
+	r.Stop()
+	pc, err := net.FilePacketConn(stored[0])
+	for _, f := range stored {
+		f.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if got := pc.LocalAddr().(*net.UDPAddr); got.Port != addr.Port {
+		t.Fatalf("stored socket on %s, router on %s", got, addr)
+	}
+	if _, err := net.ListenUDP("udp", addr); err == nil {
+		t.Error("port was freed while the store holds its sockets")
+	}
+}
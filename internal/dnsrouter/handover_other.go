@@ -0,0 +1,16 @@
+//go:build !linux
+
+package dnsrouter
+
+import "net"
+
+// inheritListeners returns nil; the systemd file descriptor store is Linux
+// only.
+func inheritListeners(addr string) []*net.UDPConn {
+	return nil
+}
+
+// storeListeners does nothing; restarts bind the listen port again.
+func storeListeners(conns []*net.UDPConn) error {
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package dnsrouter
+
+import "net"
+
+// listenUDP opens one socket on addr; without SO_REUSEPORT load balancing
+// all workers share it.
+func listenUDP(addr string, n int) ([]*net.UDPConn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	return []*net.UDPConn{conn}, nil
+}
@@ -0,0 +1,227 @@
+package dnsrouter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StateDir is where the router keeps state it writes while running.
+const StateDir = "/var/lib/dnstm/dnsrouter"
+
+// ResolverStatsFile holds the latest per-resolver response size counters.
+var ResolverStatsFile = filepath.Join(StateDir, "resolvers.json")
+
+const (
+	// typeOPT is the EDNS0 pseudo-record type.
+	typeOPT = 41
+
+	// classicPayloadSize is the UDP response limit without EDNS0.
+	classicPayloadSize = 512
+
+	// A resolver is reported once this share of at least alertMinResponses
+	// responses was truncated or larger than it accepts, and again at most
+	// every alertInterval.
+	alertRatio        = 0.1
+	alertMinResponses = 100
+	alertInterval     = time.Hour
+
+	// maxTrackedResolvers bounds the table; the least recently seen
+	// resolver is dropped to make room.
+	maxTrackedResolvers = 4096
+)
+
+// ResolverStat counts the responses sent to one resolver that it is likely
+// to truncate or drop. Tunnel throughput through a resolver that clamps
+// response sizes collapses unless the tunnel MTU fits its payload size.
+type ResolverStat struct {
+	Addr      string    `json:"addr"`
+	Responses uint64    `json:"responses"`
+	Truncated uint64    `json:"truncated"` // TC set by the tunnel server
+	Oversize  uint64    `json:"oversize"`  // larger than the advertised payload size
+	Payload   int       `json:"payload"`   // last advertised EDNS0 payload size, 0 without EDNS0
+	LastSeen  time.Time `json:"last_seen"`
+
+	lastAlert time.Time
+}
+
+// Clamped returns the number of responses the resolver truncated or is
+// expected to drop.
+func (s *ResolverStat) Clamped() uint64 {
+	return s.Truncated + s.Oversize
+}
+
+// ClampRatio returns the share of responses that were clamped.
+func (s *ResolverStat) ClampRatio() float64 {
+	if s.Responses == 0 {
+		return 0
+	}
+	return float64(s.Clamped()) / float64(s.Responses)
+}
+
+// IsClamping reports whether the resolver crossed the alert threshold.
+func (s *ResolverStat) IsClamping() bool {
+	return s.Responses >= alertMinResponses && s.ClampRatio() >= alertRatio
+}
+
+// resolverTracker keeps a ResolverStat per resolver address.
+type resolverTracker struct {
+	mu    sync.Mutex
+	stats map[string]*ResolverStat
+
+	// anonymize turns an address into the Addr that is saved and logged
+	anonymize func(string) string
+}
+
+func newResolverTracker() *resolverTracker {
+	return &resolverTracker{
+		stats:     make(map[string]*ResolverStat),
+		anonymize: func(addr string) string { return addr },
+	}
+}
+
+// record counts a response forwarded to a resolver for a query that
+// advertised payload bytes (0 without EDNS0), and logs a warning when the
+// resolver crosses the alert threshold.
+func (t *resolverTracker) record(addr string, payload int, response []byte, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.stats[addr]
+	if !ok {
+		if len(t.stats) >= maxTrackedResolvers {
+			t.evictOldest()
+		}
+		s = &ResolverStat{Addr: t.anonymize(addr)}
+		t.stats[addr] = s
+	}
+	s.Responses++
+	s.Payload = payload
+	s.LastSeen = now
+
+	limit := payload
+	if limit < classicPayloadSize {
+		limit = classicPayloadSize
+	}
+	if len(response) > 2 && response[2]&0x02 != 0 {
+		s.Truncated++
+	} else if len(response) > limit {
+		s.Oversize++
+	}
+
+	if s.IsClamping() && now.Sub(s.lastAlert) >= alertInterval {
+		s.lastAlert = now
+		log.Printf("[warning] resolver %s clamps %.0f%% of tunnel responses (EDNS0 payload %s); clients using it need a lower MTU",
+			s.Addr, 100*s.ClampRatio(), PayloadString(payload))
+	}
+}
+
+func (t *resolverTracker) evictOldest() {
+	var oldest string
+	for addr, s := range t.stats {
+		if oldest == "" || s.LastSeen.Before(t.stats[oldest].LastSeen) {
+			oldest = addr
+		}
+	}
+	delete(t.stats, oldest)
+}
+
+// snapshot returns a copy of the stats, most clamped first.
+func (t *resolverTracker) snapshot() []ResolverStat {
+	t.mu.Lock()
+	stats := make([]ResolverStat, 0, len(t.stats))
+	for _, s := range t.stats {
+		stats = append(stats, *s)
+	}
+	t.mu.Unlock()
+	SortResolverStats(stats)
+	return stats
+}
+
+// SortResolverStats orders stats by clamped responses, then by responses.
+func SortResolverStats(stats []ResolverStat) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Clamped() != stats[j].Clamped() {
+			return stats[i].Clamped() > stats[j].Clamped()
+		}
+		if stats[i].Responses != stats[j].Responses {
+			return stats[i].Responses > stats[j].Responses
+		}
+		return stats[i].Addr < stats[j].Addr
+	})
+}
+
+// PayloadString formats an advertised payload size.
+func PayloadString(payload int) string {
+	if payload == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%d", payload)
+}
+
+// ednsPayloadSize returns the UDP payload size advertised by the OPT record
+// of a query, or 0 when it has none.
+func ednsPayloadSize(packet []byte) int {
+	if len(packet) < dnsHeaderSize {
+		return 0
+	}
+	qd := int(binary.BigEndian.Uint16(packet[4:6]))
+	rrs := int(binary.BigEndian.Uint16(packet[6:8])) + int(binary.BigEndian.Uint16(packet[8:10]))
+	ar := int(binary.BigEndian.Uint16(packet[10:12]))
+
+	off := dnsHeaderSize
+	var err error
+	for i := 0; i < qd; i++ {
+		if _, off, err = parseName(packet, off); err != nil {
+			return 0
+		}
+		off += 4
+	}
+	for i := 0; i < rrs+ar; i++ {
+		if _, off, err = parseName(packet, off); err != nil || off+10 > len(packet) {
+			return 0
+		}
+		typ := binary.BigEndian.Uint16(packet[off : off+2])
+		if i >= rrs && typ == typeOPT {
+			return int(binary.BigEndian.Uint16(packet[off+2 : off+4]))
+		}
+		off += 10 + int(binary.BigEndian.Uint16(packet[off+8:off+10]))
+	}
+	return 0
+}
+
+// SaveResolverStats writes stats to ResolverStatsFile.
+func SaveResolverStats(stats []ResolverStat) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ResolverStatsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ResolverStatsFile)
+}
+
+// LoadResolverStats reads the stats last written by the router. A missing
+// file yields none.
+func LoadResolverStats() ([]ResolverStat, error) {
+	data, err := os.ReadFile(ResolverStatsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resolver stats: %w", err)
+	}
+	var stats []ResolverStat
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ResolverStatsFile, err)
+	}
+	return stats, nil
+}
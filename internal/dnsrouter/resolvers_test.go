@@ -0,0 +1,115 @@
+package dnsrouter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withOPT appends an EDNS0 OPT record advertising payload to a query.
+func withOPT(query []byte, payload uint16) []byte {
+	q := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(q[10:12], 1)
+	q = append(q, 0) // root owner
+	q = binary.BigEndian.AppendUint16(q, typeOPT)
+	q = binary.BigEndian.AppendUint16(q, payload)
+	q = binary.BigEndian.AppendUint32(q, 0)
+	return binary.BigEndian.AppendUint16(q, 0)
+}
+
+func TestEDNSPayloadSize(t *testing.T) {
+	query := buildTestQuery("abc.t.example.com", TypeTXT)
+	if got := ednsPayloadSize(query); got != 0 {
+		t.Errorf("ednsPayloadSize() without OPT = %d, want 0", got)
+	}
+	if got := ednsPayloadSize(withOPT(query, 1232)); got != 1232 {
+		t.Errorf("ednsPayloadSize() = %d, want 1232", got)
+	}
+	if got := ednsPayloadSize(withOPT(query, 4096)[:len(query)+5]); got != 0 {
+		t.Errorf("ednsPayloadSize() of a cut packet = %d, want 0", got)
+	}
+}
+
+func TestResolverTracker(t *testing.T) {
+	tr := newResolverTracker()
+	now := time.Now()
+	truncated := []byte{0, 0, 0x82, 0}
+	small := make([]byte, 400)
+	large := make([]byte, 1000)
+
+	for i := 0; i < 90; i++ {
+		tr.record("192.0.2.1", 1232, large, now)
+	}
+	for i := 0; i < 5; i++ {
+		tr.record("192.0.2.1", 1232, truncated, now)
+	}
+	for i := 0; i < 20; i++ {
+		tr.record("198.51.100.1", 0, small, now)
+		tr.record("198.51.100.1", 0, large, now) // over 512 without EDNS0
+	}
+
+	stats := tr.snapshot()
+	if len(stats) != 2 || stats[0].Addr != "198.51.100.1" {
+		t.Fatalf("snapshot() = %+v, want the clamping resolver first", stats)
+	}
+	if s := stats[0]; s.Responses != 40 || s.Oversize != 20 || s.Payload != 0 || s.IsClamping() {
+		t.Errorf("no-EDNS resolver = %+v, want 20/40 oversize and below the sample minimum", s)
+	}
+	if s := stats[1]; s.Truncated != 5 || s.Oversize != 0 || s.IsClamping() {
+		t.Errorf("EDNS resolver = %+v, want 5 truncated and not clamping", s)
+	}
+
+	for i := 0; i < 60; i++ {
+		tr.record("198.51.100.1", 0, large, now)
+	}
+	if s := tr.snapshot()[0]; !s.IsClamping() || s.ClampRatio() != 0.8 {
+		t.Errorf("resolver = %+v, ratio %v, want clamping at 0.8", s, s.ClampRatio())
+	}
+}
+
+func TestResolverTracker_Evicts(t *testing.T) {
+	tr := newResolverTracker()
+	start := time.Now()
+	for i := 0; i <= maxTrackedResolvers; i++ {
+		tr.record(fmt.Sprintf("10.%d.%d.1", i/256, i%256), 0, nil, start.Add(time.Duration(i)*time.Second))
+	}
+	if len(tr.stats) != maxTrackedResolvers {
+		t.Errorf("tracked %d resolvers, want %d", len(tr.stats), maxTrackedResolvers)
+	}
+	if _, ok := tr.stats["10.0.0.1"]; ok {
+		t.Error("least recently seen resolver was not evicted")
+	}
+}
+
+func TestResolverTracker_Anonymizes(t *testing.T) {
+	tr := newResolverTracker()
+	tr.anonymize = func(addr string) string { return "anon-" + addr }
+	tr.record("192.0.2.1", 0, nil, time.Now())
+	tr.record("192.0.2.1", 0, nil, time.Now())
+	if stats := tr.snapshot(); len(stats) != 1 || stats[0].Addr != "anon-192.0.2.1" || stats[0].Responses != 2 {
+		t.Errorf("snapshot() = %+v, want one anonymized resolver", stats)
+	}
+}
+
+func TestResolverStats_SaveLoad(t *testing.T) {
+	orig := ResolverStatsFile
+	ResolverStatsFile = filepath.Join(t.TempDir(), "resolvers.json")
+	defer func() { ResolverStatsFile = orig }()
+
+	if got, err := LoadResolverStats(); err != nil || got != nil {
+		t.Fatalf("LoadResolverStats() without file = %v, %v", got, err)
+	}
+	want := []ResolverStat{{Addr: "192.0.2.1", Responses: 200, Oversize: 50, Payload: 512}}
+	if err := SaveResolverStats(want); err != nil {
+		t.Fatalf("SaveResolverStats() error = %v", err)
+	}
+	got, err := LoadResolverStats()
+	if err != nil {
+		t.Fatalf("LoadResolverStats() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Addr != "192.0.2.1" || !got[0].IsClamping() {
+		t.Errorf("LoadResolverStats() = %+v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package dnsrouter
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+)
+
+// routeTable maps domain suffixes to backends. It is never modified once
+// built, so any number of workers can look names up without locking.
+type routeTable struct {
+	backends map[string]tableRoute
+}
+
+// tableRoute is where the queries for a domain go.
+type tableRoute struct {
+	backend string
+	canary  string
+	percent uint32
+	reply   uint8 // rcode answered instead of forwarding, 0 to forward
+}
+
+// newRouteTable indexes routes by domain. When a domain is listed twice the
+// first route wins. A stateless table drops the canaries, whose share is
+// picked by client network, so that lookups depend on the name alone.
+func newRouteTable(routes []Route, stateless bool) *routeTable {
+	t := &routeTable{backends: make(map[string]tableRoute, len(routes))}
+	for _, route := range routes {
+		domain := strings.TrimSuffix(strings.ToLower(route.Domain), ".")
+		if _, ok := t.backends[domain]; !ok {
+			tr := tableRoute{backend: route.Backend, reply: route.Reply}
+			if route.Canary != "" && route.CanaryPercent > 0 && !stateless {
+				tr.canary = route.Canary
+				tr.percent = uint32(min(route.CanaryPercent, 100))
+			}
+			t.backends[domain] = tr
+		}
+	}
+	return t
+}
+
+// lookup returns the backend of the longest domain that name equals or is
+// under, or "" when none matches. name must be lowercase. A domain with a
+// canary sends the client to it when the client's network hashes below the
+// canary's percentage. A paused domain has no backend but the response
+// code to answer with.
+func (t *routeTable) lookup(name []byte, client netip.Addr) (string, uint8) {
+	for {
+		// string(name) in a map index does not allocate
+		if tr, ok := t.backends[string(name)]; ok {
+			if tr.reply != 0 {
+				return "", tr.reply
+			}
+			if tr.percent > 0 && clientBucket(client) < tr.percent {
+				return tr.canary, 0
+			}
+			return tr.backend, 0
+		}
+		dot := bytes.IndexByte(name, '.')
+		if dot < 0 {
+			return "", 0
+		}
+		name = name[dot+1:]
+	}
+}
+
+// clientBucket maps the network of a client to 0-99. Clients reach the
+// router through recursive resolvers, which often send the queries of one
+// client from several addresses in a /24 (IPv4) or /48 (IPv6), so the
+// bucket only depends on that prefix.
+func clientBucket(client netip.Addr) uint32 {
+	client = client.Unmap()
+	b := client.As16()
+	prefix := b[:6]
+	if client.Is4() {
+		prefix = b[12:15]
+	}
+	// FNV-1a
+	h := uint32(2166136261)
+	for _, c := range prefix {
+		h ^= uint32(c)
+		h *= 16777619
+	}
+	return h % 100
+}
+
+// appendQueryName appends the lowercased name of the first question of a
+// query to dst. Unlike ExtractQueryName it allocates nothing when dst has
+// room; names using compression are handed to ExtractQueryName.
+func appendQueryName(dst, packet []byte) ([]byte, error) {
+	if len(packet) < dnsHeaderSize+1 {
+		return dst, ErrPacketTooShort
+	}
+	if packet[4] == 0 && packet[5] == 0 {
+		return dst, ErrNoQuestionSection
+	}
+
+	start := len(dst)
+	off := dnsHeaderSize
+	for {
+		if off >= len(packet) {
+			return dst[:start], ErrPacketTooShort
+		}
+		length := int(packet[off])
+		if length == 0 {
+			return dst, nil
+		}
+		if length&0xC0 != 0 {
+			name, err := ExtractQueryName(packet)
+			return append(dst[:start], name...), err
+		}
+		off++
+		if off+length > len(packet) {
+			return dst[:start], ErrPacketTooShort
+		}
+		if len(dst) > start {
+			dst = append(dst, '.')
+		}
+		for _, c := range packet[off : off+length] {
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			dst = append(dst, c)
+		}
+		off += length
+	}
+}
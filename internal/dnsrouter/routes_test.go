@@ -0,0 +1,130 @@
+package dnsrouter
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+func TestRouteTable_Lookup(t *testing.T) {
+	table := newRouteTable([]Route{
+		{Domain: "t.example.com", Backend: "127.0.0.1:5310"},
+		{Domain: "Deep.T.Example.com.", Backend: "127.0.0.1:5311"},
+		{Domain: "other.org", Backend: "127.0.0.1:5312"},
+		{Domain: "other.org", Backend: "127.0.0.1:5399"},
+	}, false)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"t.example.com", "127.0.0.1:5310"},
+		{"abc.t.example.com", "127.0.0.1:5310"},
+		{"abc.deep.t.example.com", "127.0.0.1:5311"},
+		{"deep.t.example.com", "127.0.0.1:5311"},
+		{"x.other.org", "127.0.0.1:5312"},
+		{"example.com", ""},
+		{"nott.example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := table.lookup([]byte(tt.name), netip.Addr{}); got != tt.want {
+			t.Errorf("lookup(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRouteTable_Canary(t *testing.T) {
+	table := newRouteTable([]Route{
+		{Domain: "t.example.com", Backend: "127.0.0.1:5310", Canary: "127.0.0.1:5311", CanaryPercent: 30},
+		{Domain: "off.example.com", Backend: "127.0.0.1:5312", Canary: "127.0.0.1:5313"},
+	}, false)
+
+	canaries := 0
+	for i := 0; i < 1000; i++ {
+		client := netip.MustParseAddr(fmt.Sprintf("10.%d.%d.1", i/256, i%256))
+		got, _ := table.lookup([]byte("abc.t.example.com"), client)
+		if got == "127.0.0.1:5311" {
+			canaries++
+		}
+		// Resolvers send from several addresses of one network
+		peer := netip.MustParseAddr(fmt.Sprintf("10.%d.%d.200", i/256, i%256))
+		if other, _ := table.lookup([]byte("abc.t.example.com"), peer); other != got {
+			t.Fatalf("%s went to %s but %s to %s", client, got, peer, other)
+		}
+		if off, _ := table.lookup([]byte("abc.off.example.com"), client); off != "127.0.0.1:5312" {
+			t.Fatalf("lookup() with a 0%% canary = %s", off)
+		}
+	}
+	if canaries < 250 || canaries > 350 {
+		t.Errorf("%d of 1000 networks went to the 30%% canary", canaries)
+	}
+}
+
+func TestRouteTable_Paused(t *testing.T) {
+	table := newRouteTable([]Route{
+		{Domain: "t.example.com", Reply: RcodeRefused},
+		{Domain: "u.example.com", Backend: "127.0.0.1:5311"},
+	}, false)
+
+	if backend, reply := table.lookup([]byte("abc.t.example.com"), netip.Addr{}); backend != "" || reply != RcodeRefused {
+		t.Errorf("lookup() on a paused route = %q, %d, want REFUSED", backend, reply)
+	}
+	if backend, reply := table.lookup([]byte("abc.u.example.com"), netip.Addr{}); backend != "127.0.0.1:5311" || reply != 0 {
+		t.Errorf("lookup() = %q, %d, want the backend", backend, reply)
+	}
+}
+
+func TestRouteTable_Stateless(t *testing.T) {
+	table := newRouteTable([]Route{
+		{Domain: "t.example.com", Backend: "127.0.0.1:5310", Canary: "127.0.0.1:5311", CanaryPercent: 100},
+	}, true)
+
+	// Every router behind ECMP must pick the same backend for a name,
+	// whichever address the query came from
+	for i := 0; i < 256; i++ {
+		client := netip.MustParseAddr(fmt.Sprintf("10.0.%d.1", i))
+		if got, _ := table.lookup([]byte("abc.t.example.com"), client); got != "127.0.0.1:5310" {
+			t.Fatalf("lookup() from %s = %s, want the stable backend", client, got)
+		}
+	}
+}
+
+func TestAppendQueryName(t *testing.T) {
+	query := buildTestQuery("AbC.T.Example.COM", TypeTXT)
+	name, err := appendQueryName(make([]byte, 0, 64), query)
+	if err != nil || string(name) != "abc.t.example.com" {
+		t.Errorf("appendQueryName() = %q, %v", name, err)
+	}
+
+	want, _ := ExtractQueryName(query)
+	if string(name) != want {
+		t.Errorf("appendQueryName() = %q, ExtractQueryName() = %q", name, want)
+	}
+
+	if _, err := appendQueryName(nil, query[:len(query)-10]); err != ErrPacketTooShort {
+		t.Errorf("appendQueryName() of a cut packet error = %v, want %v", err, ErrPacketTooShort)
+	}
+	noQuestion := append([]byte(nil), query...)
+	noQuestion[5] = 0
+	if _, err := appendQueryName(nil, noQuestion); err != ErrNoQuestionSection {
+		t.Errorf("appendQueryName() without question error = %v, want %v", err, ErrNoQuestionSection)
+	}
+}
+
+func BenchmarkRouteLookup(b *testing.B) {
+	table := newRouteTable([]Route{
+		{Domain: "a.example.com", Backend: "127.0.0.1:5310"},
+		{Domain: "b.example.com", Backend: "127.0.0.1:5311"},
+		{Domain: "c.example.com", Backend: "127.0.0.1:5312"},
+	}, false)
+	query := buildTestQuery("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa.bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb.c.example.com", TypeTXT)
+	name := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		name, _ = appendQueryName(name[:0], query)
+		if backend, _ := table.lookup(name, netip.Addr{}); backend == "" {
+			b.Fatal("no route")
+		}
+	}
+}
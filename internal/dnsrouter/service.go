@@ -2,14 +2,17 @@ package dnsrouter
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/paths"
 	"github.com/net2share/dnstm/internal/service"
 	"github.com/net2share/dnstm/internal/system"
 )
 
-const (
-	ServiceName = "dnstm-dnsrouter"
-	BinaryName  = "dnstm-dnsrouter"
+var (
+	ServiceName = paths.SystemService("dnsrouter")
+	BinaryName  = paths.Service("dnsrouter")
 )
 
 // Service manages the DNS router as a systemd service.
@@ -27,22 +30,42 @@ func NewService() *Service {
 func getBinaryPath() string {
 	// Always use the installed path for systemd services
 	// This prevents issues when running from development locations
-	return "/usr/local/bin/dnstm"
+	return paths.Bin("dnstm")
 }
 
-// CreateService creates the systemd service for the DNS router.
-func (s *Service) CreateService() error {
-	cfg := &service.ServiceConfig{
+// serviceConfig returns the systemd unit configuration of the DNS router.
+func (s *Service) serviceConfig() *service.ServiceConfig {
+	return &service.ServiceConfig{
 		Name:             ServiceName,
 		Description:      "DNSTM DNS Router",
 		User:             system.DnstmUser,
 		Group:            system.DnstmUser,
-		ExecStart:        fmt.Sprintf("%s dnsrouter serve", s.binaryPath),
-		ReadOnlyPaths:    []string{"/etc/dnstm"},
+		ExecStart:        cmdline.Join(s.binaryPath, "dnsrouter", "serve"),
+		ReadOnlyPaths:    []string{paths.ConfigDir},
+		ReadWritePaths:   []string{StateDir},
 		BindToPrivileged: true,
+		// The listen sockets, one per worker, outlive restarts
+		FDStore: 256,
+	}
+}
+
+// CreateService creates the systemd service for the DNS router.
+func (s *Service) CreateService() error {
+	// The state directory must exist for ReadWritePaths
+	if err := os.MkdirAll(StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", StateDir, err)
+	}
+	if err := system.ChownToDnstm(StateDir); err != nil {
+		return fmt.Errorf("failed to set ownership of %s: %w", StateDir, err)
 	}
 
-	return service.CreateGenericService(cfg)
+	return service.CreateGenericService(s.serviceConfig())
+}
+
+// IsServiceCurrent checks if the installed service unit matches the one
+// CreateService writes.
+func (s *Service) IsServiceCurrent() bool {
+	return service.IsUnitCurrent(s.serviceConfig())
 }
 
 // Start starts the DNS router service.
@@ -60,6 +83,11 @@ func (s *Service) Restart() error {
 	return service.RestartService(ServiceName)
 }
 
+// Reload makes the running DNS router reread the ACME challenge records.
+func (s *Service) Reload() error {
+	return service.SignalService(ServiceName, "HUP")
+}
+
 // Enable enables the DNS router service to start on boot.
 func (s *Service) Enable() error {
 	return service.EnableService(ServiceName)
@@ -0,0 +1,613 @@
+package dnsrouter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNS record types served from a decoy zone.
+const (
+	TypeA     uint16 = 1
+	TypeNS    uint16 = 2
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypeMX    uint16 = 15
+	TypeTXT   uint16 = 16
+	TypeAAAA  uint16 = 28
+	TypeANY   uint16 = 255
+
+	classIN = 1
+
+	defaultZoneTTL = 3600
+)
+
+// Response codes the router answers paused routes with.
+const (
+	RcodeServFail uint8 = 2
+	RcodeRefused  uint8 = 5
+)
+
+var typeNames = map[string]uint16{
+	"A":     TypeA,
+	"NS":    TypeNS,
+	"CNAME": TypeCNAME,
+	"SOA":   TypeSOA,
+	"MX":    TypeMX,
+	"TXT":   TypeTXT,
+	"AAAA":  TypeAAAA,
+}
+
+// ErrNotInZone is returned for queries the decoy zone does not answer.
+var ErrNotInZone = errors.New("name not in decoy zone")
+
+// Record is a resource record of a decoy zone.
+type Record struct {
+	Name  string // Lowercase, without trailing dot
+	TTL   uint32
+	Type  uint16
+	RData []byte
+	// Target is the host named by NS, MX and CNAME records, for glue
+	Target string
+}
+
+// Zone is a set of records the DNS router answers authoritatively: a decoy
+// zone file, so queries for the tunnel domains that are not tunnel traffic
+// (the apex, www and so on) get the answers a normal authoritative server
+// would give, and static records from the config, such as MX or ACME
+// DNS-01 TXT records, served next to the tunnels.
+type Zone struct {
+	nodes  map[string][]Record
+	apexes []string // SOA owners, longest first
+}
+
+// NewZone returns an empty zone.
+func NewZone() *Zone {
+	return &Zone{nodes: make(map[string][]Record)}
+}
+
+// Add adds a record to the zone.
+func (z *Zone) Add(rec Record) {
+	z.nodes[rec.Name] = append(z.nodes[rec.Name], rec)
+	if rec.Type == TypeSOA {
+		z.apexes = append(z.apexes, rec.Name)
+		sort.Slice(z.apexes, func(i, j int) bool { return len(z.apexes[i]) > len(z.apexes[j]) })
+	}
+}
+
+// IsEmpty reports whether the zone has no records.
+func (z *Zone) IsEmpty() bool {
+	return len(z.nodes) == 0
+}
+
+// NewRecord builds a record from a fully qualified name, a type, and a value
+// in zone file syntax ("10 mail.example.com" for MX). Names in the value are
+// taken as fully qualified, and a TXT value is a single string that is split
+// into 255-byte chunks as needed.
+func NewRecord(name, typ, value string, ttl uint32) (Record, error) {
+	owner := canonicalName(strings.TrimSpace(name))
+	if owner == "" {
+		return Record{}, fmt.Errorf("record name is empty")
+	}
+	if ttl == 0 {
+		ttl = defaultZoneTTL
+	}
+
+	if strings.EqualFold(typ, "TXT") {
+		rec := Record{Name: owner, TTL: ttl, Type: TypeTXT}
+		for len(value) > 255 {
+			rec.RData = append(append(rec.RData, 255), value[:255]...)
+			value = value[255:]
+		}
+		rec.RData = append(append(rec.RData, byte(len(value))), value...)
+		return rec, nil
+	}
+
+	args := strings.Fields(value)
+	if len(args) > 0 {
+		// The last field of NS, CNAME and MX values is a host name
+		switch strings.ToUpper(typ) {
+		case "NS", "CNAME", "MX":
+			last := len(args) - 1
+			if !strings.HasSuffix(args[last], ".") {
+				args[last] += "."
+			}
+		}
+	}
+	return parseRecord(owner, append([]string{typ}, args...), ttl, "")
+}
+
+// LoadZone reads a zone file in RFC 1035 master file format.
+func LoadZone(path string) (*Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zone file: %w", err)
+	}
+	defer f.Close()
+	z, err := ParseZone(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return z, nil
+}
+
+// ParseZone parses a zone in master file format. $ORIGIN and $TTL are
+// supported, as are the A, AAAA, NS, CNAME, MX, TXT and SOA record types.
+// Every zone must have an SOA record.
+func ParseZone(r io.Reader) (*Zone, error) {
+	z := NewZone()
+	origin := ""
+	ttl := uint32(defaultZoneTTL)
+	owner := ""
+
+	lines, err := zoneLines(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		fields := splitZoneFields(line.text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "$ORIGIN":
+			if len(fields) != 2 || !strings.HasSuffix(fields[1], ".") {
+				return nil, fmt.Errorf("line %d: $ORIGIN needs an absolute name", line.num)
+			}
+			origin = canonicalName(fields[1])
+			continue
+		case "$TTL":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: $TTL needs a value", line.num)
+			}
+			v, err := strconv.ParseUint(fields[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid $TTL '%s'", line.num, fields[1])
+			}
+			ttl = uint32(v)
+			continue
+		}
+
+		// A line starting with whitespace continues the previous owner
+		if !line.continued {
+			owner, err = absoluteName(fields[0], origin)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.num, err)
+			}
+			fields = fields[1:]
+		}
+		if owner == "" {
+			return nil, fmt.Errorf("line %d: record without owner", line.num)
+		}
+
+		rec, err := parseRecord(owner, fields, ttl, origin)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.num, err)
+		}
+		z.Add(rec)
+	}
+
+	if len(z.apexes) == 0 {
+		return nil, fmt.Errorf("zone has no SOA record")
+	}
+	return z, nil
+}
+
+type zoneLine struct {
+	num       int
+	text      string
+	continued bool
+}
+
+// zoneLines strips comments and joins parenthesized records into one line.
+func zoneLines(r io.Reader) ([]zoneLine, error) {
+	var lines []zoneLine
+	var pending *zoneLine
+	depth := 0
+
+	scanner := bufio.NewScanner(r)
+	num := 0
+	for scanner.Scan() {
+		num++
+		text := stripComment(scanner.Text())
+		if pending == nil {
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			pending = &zoneLine{num: num, continued: text[0] == ' ' || text[0] == '\t'}
+		}
+		depth += strings.Count(text, "(") - strings.Count(text, ")")
+		text = strings.NewReplacer("(", " ", ")", " ").Replace(text)
+		pending.text += " " + text
+		if depth <= 0 {
+			lines = append(lines, *pending)
+			pending = nil
+			depth = 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("line %d: unclosed parenthesis", pending.num)
+	}
+	return lines, nil
+}
+
+// stripComment removes a ";" comment that is not inside a quoted string.
+func stripComment(s string) string {
+	quoted := false
+	for i, c := range s {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+// splitZoneFields splits a line on whitespace, keeping quoted strings whole.
+// Quoted fields keep their quotes so TXT parsing can tell them apart.
+func splitZoneFields(s string) []string {
+	var fields []string
+	var cur strings.Builder
+	quoted := false
+	for _, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			cur.WriteRune(c)
+		case !quoted && (c == ' ' || c == '\t'):
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+// parseRecord parses the "[ttl] [IN] type rdata" part of a record.
+func parseRecord(owner string, fields []string, ttl uint32, origin string) (Record, error) {
+	rec := Record{Name: owner, TTL: ttl}
+
+	// TTL and class may appear in either order
+	for len(fields) > 0 {
+		if strings.EqualFold(fields[0], "IN") {
+			fields = fields[1:]
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[0], 10, 32); err == nil {
+			rec.TTL = uint32(v)
+			fields = fields[1:]
+			continue
+		}
+		break
+	}
+	if len(fields) == 0 {
+		return rec, fmt.Errorf("missing record type")
+	}
+
+	typ, ok := typeNames[strings.ToUpper(fields[0])]
+	if !ok {
+		return rec, fmt.Errorf("unsupported record type '%s'", fields[0])
+	}
+	rec.Type = typ
+	args := fields[1:]
+
+	need := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s record needs %d fields, got %d", fields[0], n, len(args))
+		}
+		return nil
+	}
+
+	switch typ {
+	case TypeA, TypeAAAA:
+		if err := need(1); err != nil {
+			return rec, err
+		}
+		ip := net.ParseIP(args[0])
+		if typ == TypeA && (ip == nil || ip.To4() == nil) {
+			return rec, fmt.Errorf("invalid IPv4 address '%s'", args[0])
+		}
+		if typ == TypeAAAA && (ip == nil || ip.To4() != nil) {
+			return rec, fmt.Errorf("invalid IPv6 address '%s'", args[0])
+		}
+		if typ == TypeA {
+			rec.RData = ip.To4()
+		} else {
+			rec.RData = ip.To16()
+		}
+	case TypeNS, TypeCNAME:
+		if err := need(1); err != nil {
+			return rec, err
+		}
+		target, err := absoluteName(args[0], origin)
+		if err != nil {
+			return rec, err
+		}
+		rec.Target = target
+		rec.RData = encodeName(target)
+	case TypeMX:
+		if err := need(2); err != nil {
+			return rec, err
+		}
+		pref, err := strconv.ParseUint(args[0], 10, 16)
+		if err != nil {
+			return rec, fmt.Errorf("invalid MX preference '%s'", args[0])
+		}
+		target, err := absoluteName(args[1], origin)
+		if err != nil {
+			return rec, err
+		}
+		rec.Target = target
+		rec.RData = binary.BigEndian.AppendUint16(nil, uint16(pref))
+		rec.RData = append(rec.RData, encodeName(target)...)
+	case TypeTXT:
+		if len(args) == 0 {
+			return rec, fmt.Errorf("TXT record needs a value")
+		}
+		for _, a := range args {
+			s := strings.Trim(a, `"`)
+			if len(s) > 255 {
+				return rec, fmt.Errorf("TXT string longer than 255 bytes")
+			}
+			rec.RData = append(rec.RData, byte(len(s)))
+			rec.RData = append(rec.RData, s...)
+		}
+	case TypeSOA:
+		if err := need(7); err != nil {
+			return rec, err
+		}
+		for _, n := range args[:2] {
+			name, err := absoluteName(n, origin)
+			if err != nil {
+				return rec, err
+			}
+			rec.RData = append(rec.RData, encodeName(name)...)
+		}
+		for _, v := range args[2:] {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return rec, fmt.Errorf("invalid SOA value '%s'", v)
+			}
+			rec.RData = binary.BigEndian.AppendUint32(rec.RData, uint32(n))
+		}
+	}
+	return rec, nil
+}
+
+// absoluteName resolves a zone file name against the origin.
+func absoluteName(name, origin string) (string, error) {
+	switch {
+	case name == "@":
+		if origin == "" {
+			return "", fmt.Errorf("'@' used without $ORIGIN")
+		}
+		return origin, nil
+	case strings.HasSuffix(name, "."):
+		return canonicalName(name), nil
+	case origin == "":
+		return "", fmt.Errorf("relative name '%s' used without $ORIGIN", name)
+	default:
+		return canonicalName(name + "." + origin), nil
+	}
+}
+
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// encodeName encodes a name in uncompressed wire format.
+func encodeName(name string) []byte {
+	var b []byte
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0)
+}
+
+// Domains returns the apex of every zone with an SOA record.
+func (z *Zone) Domains() []string {
+	return append([]string(nil), z.apexes...)
+}
+
+// apexOf returns the zone apex a name falls under, or "".
+func (z *Zone) apexOf(name string) string {
+	for _, apex := range z.apexes {
+		if MatchDomainSuffix(name, apex) {
+			return apex
+		}
+	}
+	return ""
+}
+
+// Answer builds an authoritative response to a query for a name that has
+// records in the zone. Names without records, which include all tunnel
+// traffic, return ErrNotInZone so the query is forwarded as usual.
+func (z *Zone) Answer(query []byte) ([]byte, error) {
+	name, end, err := parseQuestion(query)
+	if err != nil {
+		return nil, err
+	}
+	records, ok := z.nodes[name]
+	if !ok {
+		return nil, ErrNotInZone
+	}
+	qtype := binary.BigEndian.Uint16(query[end-4 : end-2])
+	if binary.BigEndian.Uint16(query[end-2:end]) != classIN {
+		return nil, ErrNotInZone
+	}
+
+	var answers []Record
+	for _, rec := range records {
+		if rec.Type == qtype || qtype == TypeANY || rec.Type == TypeCNAME {
+			answers = append(answers, rec)
+		}
+	}
+
+	var authority, additional []Record
+	if len(answers) == 0 {
+		// NODATA: the SOA in the authority section, if the name is under
+		// one, sets the negative TTL
+		apex := z.apexOf(name)
+		for _, rec := range z.nodes[apex] {
+			if rec.Type == TypeSOA {
+				authority = append(authority, rec)
+			}
+		}
+	}
+	for _, rec := range answers {
+		if rec.Type != TypeNS && rec.Type != TypeMX {
+			continue
+		}
+		for _, glue := range z.nodes[rec.Target] {
+			if glue.Type == TypeA || glue.Type == TypeAAAA {
+				additional = append(additional, glue)
+			}
+		}
+	}
+
+	// The response has to fit the UDP payload the client accepts: 512 bytes,
+	// or the size its OPT record advertises, less our own OPT record
+	limit := classicPayloadSize
+	payload := ednsPayloadSize(query)
+	if payload > limit {
+		limit = payload
+	}
+	if payload > 0 {
+		limit -= optRecordSize
+	}
+
+	resp := make([]byte, 12, 512)
+	copy(resp[0:2], query[0:2])
+	resp[2] = 0x84 | query[2]&0x79 // QR, AA, opcode and RD from the query
+	resp[3] = 0x00                 // RCODE=NOERROR
+	binary.BigEndian.PutUint16(resp[4:6], 1)
+	resp = append(resp, query[dnsHeaderSize:end]...)
+
+	// Records that do not fit are left out. A cut answer or authority
+	// section sets TC so the client retries over TCP; glue is optional.
+	var counts [3]int
+	truncated := false
+	for i, section := range [][]Record{answers, authority, additional} {
+		for _, rec := range section {
+			next := appendRecord(resp, rec)
+			if len(next) > limit {
+				truncated = i < 2
+				break
+			}
+			resp = next
+			counts[i]++
+		}
+		if truncated {
+			resp[2] |= 0x02 // TC
+			break
+		}
+	}
+	if payload > 0 {
+		resp = appendOPT(resp)
+		counts[2]++
+	}
+	binary.BigEndian.PutUint16(resp[6:8], uint16(counts[0]))
+	binary.BigEndian.PutUint16(resp[8:10], uint16(counts[1]))
+	binary.BigEndian.PutUint16(resp[10:12], uint16(counts[2]))
+	return resp, nil
+}
+
+// optRecordSize is the size of the OPT record appendOPT adds.
+const optRecordSize = 11
+
+// appendOPT adds the OPT record of an EDNS0 response, advertising the
+// largest packet the router reads.
+func appendOPT(b []byte) []byte {
+	b = append(b, 0) // root name
+	b = binary.BigEndian.AppendUint16(b, typeOPT)
+	b = binary.BigEndian.AppendUint16(b, MaxPacketSize)
+	b = binary.BigEndian.AppendUint32(b, 0) // extended RCODE, version, flags
+	return binary.BigEndian.AppendUint16(b, 0)
+}
+
+// ErrorResponse builds a response to a query with the given response code
+// and no records, echoing its question.
+func ErrorResponse(query []byte, rcode uint8) ([]byte, error) {
+	_, end, err := parseQuestion(query)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]byte, 12, end)
+	copy(resp[0:2], query[0:2])
+	resp[2] = 0x80 | query[2]&0x79 // QR, opcode and RD from the query
+	resp[3] = rcode & 0x0f
+	binary.BigEndian.PutUint16(resp[4:6], 1)
+	return append(resp, query[dnsHeaderSize:end]...), nil
+}
+
+// parseQuestion returns the lowercase name of the first question and the
+// offset after its type and class.
+func parseQuestion(packet []byte) (string, int, error) {
+	name, err := ExtractQueryName(packet)
+	if err != nil {
+		return "", 0, err
+	}
+	_, end, err := parseName(packet, dnsHeaderSize)
+	if err != nil {
+		return "", 0, err
+	}
+	if end+4 > len(packet) {
+		return "", 0, ErrPacketTooShort
+	}
+	return strings.TrimSuffix(name, "."), end + 4, nil
+}
+
+func appendRecord(b []byte, rec Record) []byte {
+	b = append(b, encodeName(rec.Name)...)
+	b = binary.BigEndian.AppendUint16(b, rec.Type)
+	b = binary.BigEndian.AppendUint16(b, classIN)
+	b = binary.BigEndian.AppendUint32(b, rec.TTL)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rec.RData)))
+	return append(b, rec.RData...)
+}
+
+// StarterZone renders a zone file for the given domains that answers SOA,
+// NS and A queries at each apex with the server's address. The NS host
+// should be edited to match the delegation in the parent zone.
+func StarterZone(domains []string, ip string, now time.Time) string {
+	var b strings.Builder
+	serial := now.Format("20060102") + "01"
+	b.WriteString("; Decoy zone served by the dnstm DNS router for queries that are not\n")
+	b.WriteString("; tunnel traffic. Edit the NS host to match the delegation in the\n")
+	b.WriteString("; parent zone, then run: dnstm router restart\n")
+	b.WriteString("$TTL 3600\n")
+	for _, d := range domains {
+		d = canonicalName(d)
+		fmt.Fprintf(&b, "\n$ORIGIN %s.\n", d)
+		fmt.Fprintf(&b, "@\tIN\tSOA\tns1 hostmaster (\n\t\t%s ; serial\n\t\t7200 ; refresh\n\t\t3600 ; retry\n\t\t1209600 ; expire\n\t\t300 ) ; negative TTL\n", serial)
+		b.WriteString("@\tIN\tNS\tns1\n")
+		fmt.Fprintf(&b, "@\tIN\tA\t%s\n", ip)
+		fmt.Fprintf(&b, "ns1\tIN\tA\t%s\n", ip)
+	}
+	return b.String()
+}
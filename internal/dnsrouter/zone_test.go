@@ -0,0 +1,266 @@
+package dnsrouter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testZone = `; test zone
+$TTL 600
+$ORIGIN t.example.com.
+@	IN	SOA	ns1 hostmaster (
+		2025010101 ; serial
+		7200 3600 1209600 300 )
+	IN	NS	ns1
+	IN	A	192.0.2.1
+ns1	IN	A	192.0.2.1
+www	300	IN	CNAME	@
+@	IN	TXT	"v=spf1 -all" "second; string"
+`
+
+// buildTestQuery builds a query for name with the given type.
+func buildTestQuery(name string, qtype uint16) []byte {
+	q := []byte{0xab, 0xcd, 0x01, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}
+	q = append(q, encodeName(name)...)
+	q = binary.BigEndian.AppendUint16(q, qtype)
+	return binary.BigEndian.AppendUint16(q, classIN)
+}
+
+func TestParseZone(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(testZone))
+	if err != nil {
+		t.Fatalf("ParseZone() error = %v", err)
+	}
+	if got := z.Domains(); len(got) != 1 || got[0] != "t.example.com" {
+		t.Errorf("Domains() = %v", got)
+	}
+	apex := z.nodes["t.example.com"]
+	if len(apex) != 4 {
+		t.Fatalf("apex has %d records, want 4", len(apex))
+	}
+	if apex[0].TTL != 600 || apex[0].Type != TypeSOA {
+		t.Errorf("SOA = %+v", apex[0])
+	}
+	// Two names plus five 32-bit values
+	if len(apex[0].RData) != len(encodeName("ns1.t.example.com"))+len(encodeName("hostmaster.t.example.com"))+20 {
+		t.Errorf("SOA rdata length = %d", len(apex[0].RData))
+	}
+	if www := z.nodes["www.t.example.com"]; len(www) != 1 || www[0].TTL != 300 || www[0].Target != "t.example.com" {
+		t.Errorf("www = %+v", www)
+	}
+	txt := apex[3].RData
+	if txt[0] != 11 || string(txt[1:12]) != "v=spf1 -all" || string(txt[13:]) != "second; string" {
+		t.Errorf("TXT rdata = %q", txt)
+	}
+}
+
+func TestParseZone_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		zone    string
+		wantErr string
+	}{
+		{"no SOA", "$ORIGIN t.example.com.\n@ IN A 192.0.2.1\n", "no SOA"},
+		{"relative without origin", "www IN A 192.0.2.1\n", "without $ORIGIN"},
+		{"bad address", "$ORIGIN t.example.com.\n@ IN A ::1\n", "invalid IPv4"},
+		{"unsupported type", "$ORIGIN t.example.com.\n@ IN SRV 0 0 0 x\n", "unsupported record type"},
+		{"unclosed", "$ORIGIN t.example.com.\n@ IN SOA ns1 hm ( 1 2 3\n", "unclosed parenthesis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseZone(strings.NewReader(tt.zone))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseZone() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestZone_Answer(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(testZone))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		qname      string
+		qtype      uint16
+		answers    uint16
+		authority  uint16
+		additional uint16
+	}{
+		{"apex A", "t.example.com", TypeA, 1, 0, 0},
+		{"apex NS with glue", "T.Example.COM", TypeNS, 1, 0, 1},
+		{"apex SOA", "t.example.com", TypeSOA, 1, 0, 0},
+		{"NODATA", "t.example.com", TypeAAAA, 0, 1, 0},
+		{"CNAME for any type", "www.t.example.com", TypeA, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := buildTestQuery(strings.ToLower(tt.qname), tt.qtype)
+			resp, err := z.Answer(query)
+			if err != nil {
+				t.Fatalf("Answer() error = %v", err)
+			}
+			if resp[0] != 0xab || resp[1] != 0xcd {
+				t.Error("response ID does not match query")
+			}
+			if resp[2]&0x80 == 0 || resp[2]&0x04 == 0 || resp[2]&0x01 == 0 {
+				t.Errorf("flags = %08b, want QR, AA and RD", resp[2])
+			}
+			if resp[3]&0x0f != 0 {
+				t.Errorf("rcode = %d, want NOERROR", resp[3]&0x0f)
+			}
+			got := [3]uint16{binary.BigEndian.Uint16(resp[6:8]), binary.BigEndian.Uint16(resp[8:10]), binary.BigEndian.Uint16(resp[10:12])}
+			if got != [3]uint16{tt.answers, tt.authority, tt.additional} {
+				t.Errorf("counts = %v, want [%d %d %d]", got, tt.answers, tt.authority, tt.additional)
+			}
+			if name, err := ExtractQueryName(resp); err != nil || name != strings.ToLower(tt.qname) {
+				t.Errorf("question = %q, %v", name, err)
+			}
+		})
+	}
+
+	// Tunnel traffic and other zones pass through
+	for _, name := range []string{"aaaabbbbccccdddd.t.example.com", "example.org"} {
+		if _, err := z.Answer(buildTestQuery(name, TypeTXT)); !errors.Is(err, ErrNotInZone) {
+			t.Errorf("Answer(%s) error = %v, want ErrNotInZone", name, err)
+		}
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	query := buildTestQuery("abc.t.example.com", TypeTXT)
+	resp, err := ErrorResponse(query, RcodeServFail)
+	if err != nil {
+		t.Fatalf("ErrorResponse() error = %v", err)
+	}
+	if resp[0] != 0xab || resp[1] != 0xcd {
+		t.Error("response ID does not match query")
+	}
+	if resp[2]&0x80 == 0 || resp[2]&0x04 != 0 || resp[3]&0x0f != RcodeServFail {
+		t.Errorf("flags = %02x %02x, want a non-authoritative SERVFAIL", resp[2], resp[3])
+	}
+	if len(resp) != len(query) || binary.BigEndian.Uint16(resp[6:8]) != 0 {
+		t.Errorf("response has %d bytes, want the question alone (%d)", len(resp), len(query))
+	}
+
+	if _, err := ErrorResponse(query[:10], RcodeRefused); err == nil {
+		t.Error("ErrorResponse() accepted a truncated query")
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	mx, err := NewRecord("Example.com.", "mx", "10 mail.example.com", 0)
+	if err != nil {
+		t.Fatalf("NewRecord(MX) error = %v", err)
+	}
+	if mx.Name != "example.com" || mx.TTL != defaultZoneTTL || mx.Target != "mail.example.com" {
+		t.Errorf("MX = %+v", mx)
+	}
+
+	long := strings.Repeat("x", 300)
+	txt, err := NewRecord("_acme-challenge.t.example.com", "TXT", long, 60)
+	if err != nil {
+		t.Fatalf("NewRecord(TXT) error = %v", err)
+	}
+	if len(txt.RData) != 302 || txt.RData[0] != 255 || txt.RData[256] != 45 {
+		t.Errorf("TXT rdata not split into 255-byte strings: len %d", len(txt.RData))
+	}
+
+	if _, err := NewRecord("example.com", "A", "not-an-ip", 0); err == nil {
+		t.Error("NewRecord(A) accepted an invalid address")
+	}
+}
+
+func TestZone_AnswerStaticRecords(t *testing.T) {
+	z := NewZone()
+	for _, r := range [][2]string{{"A", "203.0.113.10"}, {"MX", "10 mail.example.com"}} {
+		rec, err := NewRecord("example.com", r[0], r[1], 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		z.Add(rec)
+	}
+
+	resp, err := z.Answer(buildTestQuery("example.com", TypeMX))
+	if err != nil {
+		t.Fatalf("Answer() error = %v", err)
+	}
+	if an := binary.BigEndian.Uint16(resp[6:8]); an != 1 {
+		t.Errorf("answers = %d, want 1", an)
+	}
+
+	// Without an SOA a missing type is an empty answer
+	resp, err = z.Answer(buildTestQuery("example.com", TypeAAAA))
+	if err != nil {
+		t.Fatalf("Answer() error = %v", err)
+	}
+	if an, ns := binary.BigEndian.Uint16(resp[6:8]), binary.BigEndian.Uint16(resp[8:10]); an != 0 || ns != 0 {
+		t.Errorf("counts = %d/%d, want 0/0", an, ns)
+	}
+}
+
+func TestZone_AnswerTruncates(t *testing.T) {
+	z := NewZone()
+	for i := 0; i < 20; i++ {
+		rec, err := NewRecord("example.com", "TXT", fmt.Sprintf("%q", strings.Repeat("x", 90)+fmt.Sprint(i)), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		z.Add(rec)
+	}
+
+	resp, err := z.Answer(buildTestQuery("example.com", TypeTXT))
+	if err != nil {
+		t.Fatalf("Answer() error = %v", err)
+	}
+	if len(resp) > classicPayloadSize || resp[2]&0x02 == 0 {
+		t.Errorf("without EDNS0: %d bytes, TC=%v; want at most 512 bytes with TC", len(resp), resp[2]&0x02 != 0)
+	}
+	if an := binary.BigEndian.Uint16(resp[6:8]); an == 0 || an >= 20 {
+		t.Errorf("without EDNS0: answers = %d, want some of 20", an)
+	}
+
+	// A query advertising 4096 bytes gets every record and an OPT record
+	query := buildTestQuery("example.com", TypeTXT)
+	binary.BigEndian.PutUint16(query[10:12], 1)
+	query = appendOPT(query)
+	resp, err = z.Answer(query)
+	if err != nil {
+		t.Fatalf("Answer() error = %v", err)
+	}
+	if resp[2]&0x02 != 0 {
+		t.Error("with EDNS0: TC set")
+	}
+	if an, ar := binary.BigEndian.Uint16(resp[6:8]), binary.BigEndian.Uint16(resp[10:12]); an != 20 || ar != 1 {
+		t.Errorf("with EDNS0: answers/additional = %d/%d, want 20/1", an, ar)
+	}
+	if got := ednsPayloadSize(resp); got != MaxPacketSize {
+		t.Errorf("advertised payload = %d, want %d", got, MaxPacketSize)
+	}
+}
+
+func TestStarterZone(t *testing.T) {
+	now := time.Date(2025, 3, 4, 5, 0, 0, 0, time.UTC)
+	zone := StarterZone([]string{"t.example.com", "t2.example.com."}, "192.0.2.1", now)
+
+	z, err := ParseZone(strings.NewReader(zone))
+	if err != nil {
+		t.Fatalf("starter zone does not parse: %v\n%s", err, zone)
+	}
+	if got := z.Domains(); len(got) != 2 {
+		t.Errorf("Domains() = %v, want 2", got)
+	}
+	if !strings.Contains(zone, "2025030401 ; serial") {
+		t.Errorf("serial not derived from time:\n%s", zone)
+	}
+	if _, err := z.Answer(buildTestQuery("t2.example.com", TypeA)); err != nil {
+		t.Errorf("Answer() error = %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package egress
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NFTablesTable is the nftables table holding dnstm egress rules.
+const NFTablesTable = "dnstm_egress"
+
+// NFTablesScript renders rules as an nftables script that replaces the
+// dnstm egress table. Rules only apply to new connections opened by the
+// given system user, so replies to clients of that process are unaffected.
+func NFTablesScript(user string, rules []Rule) string {
+	var b strings.Builder
+
+	// Create-then-delete makes the script idempotent whether or not the table exists
+	fmt.Fprintf(&b, "add table inet %s\n", NFTablesTable)
+	fmt.Fprintf(&b, "delete table inet %s\n", NFTablesTable)
+	fmt.Fprintf(&b, "table inet %s {\n", NFTablesTable)
+	b.WriteString("\tchain output {\n")
+	b.WriteString("\t\ttype filter hook output priority 0; policy accept;\n")
+
+	for _, r := range rules {
+		fmt.Fprintf(&b, "\t\t%s\n", nftRule(user, r))
+	}
+
+	b.WriteString("\t}\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// nftRule renders a single rule as an nftables statement.
+func nftRule(user string, r Rule) string {
+	parts := []string{fmt.Sprintf("meta skuid %q", user), "ct state new"}
+
+	if r.Network != nil {
+		family := "ip6"
+		if r.Network.IP.To4() != nil {
+			family = "ip"
+		}
+		parts = append(parts, fmt.Sprintf("%s daddr %s", family, r.Network))
+	}
+
+	if r.PortMin > 0 {
+		if r.PortMin == r.PortMax {
+			parts = append(parts, fmt.Sprintf("tcp dport %d", r.PortMin))
+		} else {
+			parts = append(parts, fmt.Sprintf("tcp dport %d-%d", r.PortMin, r.PortMax))
+		}
+	}
+
+	if r.Action == RuleAllow {
+		parts = append(parts, "accept")
+	} else {
+		parts = append(parts, "reject")
+	}
+
+	return strings.Join(parts, " ")
+}
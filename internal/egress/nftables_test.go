@@ -0,0 +1,46 @@
+package egress
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNFTablesScript(t *testing.T) {
+	rules, err := ParseRules([]string{
+		"allow 10.1.2.3 8080",
+		"deny 10.0.0.0/8",
+		"deny 0.0.0.0/0 25",
+		"deny fc00::/7 1-1024",
+	})
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	script := NFTablesScript("dnstm-socks", rules)
+
+	want := []string{
+		"delete table inet dnstm_egress",
+		"type filter hook output priority 0; policy accept;",
+		`meta skuid "dnstm-socks" ct state new ip daddr 10.1.2.3/32 tcp dport 8080 accept`,
+		`meta skuid "dnstm-socks" ct state new ip daddr 10.0.0.0/8 reject`,
+		`meta skuid "dnstm-socks" ct state new ip daddr 0.0.0.0/0 tcp dport 25 reject`,
+		`meta skuid "dnstm-socks" ct state new ip6 daddr fc00::/7 tcp dport 1-1024 reject`,
+	}
+	for _, w := range want {
+		if !strings.Contains(script, w) {
+			t.Errorf("script missing %q\n%s", w, script)
+		}
+	}
+
+	// Rule order must be preserved for first-match semantics
+	if strings.Index(script, "accept\n") > strings.Index(script, "10.0.0.0/8 reject") {
+		t.Error("allow rule should precede deny rule")
+	}
+}
+
+func TestNFTablesScript_NoRules(t *testing.T) {
+	script := NFTablesScript("dnstm-socks", nil)
+	if strings.Contains(script, "skuid") {
+		t.Errorf("empty rule set should not produce rules:\n%s", script)
+	}
+}
@@ -0,0 +1,153 @@
+// Package egress provides destination allow/deny rules for outbound traffic.
+package egress
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// RuleAction decides whether a matching destination is permitted.
+type RuleAction string
+
+const (
+	RuleAllow RuleAction = "allow"
+	RuleDeny  RuleAction = "deny"
+)
+
+// Rule matches destinations by network and port range.
+type Rule struct {
+	Action  RuleAction
+	Network *net.IPNet
+	PortMin int // 0 = any port
+	PortMax int
+}
+
+// ParseRule parses a rule of the form "<allow|deny> <cidr|ip> [port|port-port]".
+func ParseRule(s string) (Rule, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || len(fields) > 3 {
+		return Rule{}, fmt.Errorf("invalid rule %q: expected '<allow|deny> <cidr> [ports]'", s)
+	}
+
+	var r Rule
+	switch RuleAction(strings.ToLower(fields[0])) {
+	case RuleAllow:
+		r.Action = RuleAllow
+	case RuleDeny:
+		r.Action = RuleDeny
+	default:
+		return Rule{}, fmt.Errorf("invalid rule %q: action must be allow or deny", s)
+	}
+
+	network, err := parseNetwork(fields[1])
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid rule %q: %w", s, err)
+	}
+	r.Network = network
+
+	if len(fields) == 3 {
+		min, max, err := parsePortRange(fields[2])
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid rule %q: %w", s, err)
+		}
+		r.PortMin, r.PortMax = min, max
+	}
+
+	return r, nil
+}
+
+// ParseRules parses a list of rules.
+func ParseRules(specs []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+	for _, spec := range specs {
+		r, err := ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Matches reports whether the rule applies to ip:port.
+func (r Rule) Matches(ip net.IP, port int) bool {
+	if r.Network != nil && !r.Network.Contains(ip) {
+		return false
+	}
+	if r.PortMin > 0 && (port < r.PortMin || port > r.PortMax) {
+		return false
+	}
+	return true
+}
+
+// String returns the rule in the format accepted by ParseRule.
+func (r Rule) String() string {
+	s := fmt.Sprintf("%s %s", r.Action, r.Network)
+	if r.PortMin > 0 {
+		if r.PortMin == r.PortMax {
+			s += " " + strconv.Itoa(r.PortMin)
+		} else {
+			s += fmt.Sprintf(" %d-%d", r.PortMin, r.PortMax)
+		}
+	}
+	return s
+}
+
+// Allowed evaluates rules in order; the first matching rule wins.
+// Destinations that match no rule are allowed.
+func Allowed(rules []Rule, ip net.IP, port int) bool {
+	for _, r := range rules {
+		if r.Matches(ip, port) {
+			return r.Action == RuleAllow
+		}
+	}
+	return true
+}
+
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+		return network, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP %q", s)
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func parsePortRange(s string) (int, int, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	min, err := parsePort(lo)
+	if err != nil {
+		return 0, 0, err
+	}
+	max := min
+	if isRange {
+		if max, err = parsePort(hi); err != nil {
+			return 0, 0, err
+		}
+	}
+	if max < min {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
+	}
+	return min, max, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
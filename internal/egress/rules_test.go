@@ -0,0 +1,80 @@
+package egress
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{"deny 10.0.0.0/8", "deny 10.0.0.0/8", false},
+		{"allow 0.0.0.0/0 443", "allow 0.0.0.0/0 443", false},
+		{"DENY 192.168.1.1 25", "deny 192.168.1.1/32 25", false},
+		{"deny ::1 1-1024", "deny ::1/128 1-1024", false},
+		{"deny fc00::/7", "deny fc00::/7", false},
+		{"block 10.0.0.0/8", "", true},
+		{"deny", "", true},
+		{"deny not-an-ip", "", true},
+		{"deny 10.0.0.0/8 0", "", true},
+		{"deny 10.0.0.0/8 100-10", "", true},
+		{"deny 10.0.0.0/8 25 extra", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			r, err := ParseRule(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRule(%q) expected error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRule(%q) unexpected error: %v", tt.spec, err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("ParseRule(%q) = %q, want %q", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	rules, err := ParseRules([]string{
+		"allow 10.1.2.3 8080",
+		"deny 10.0.0.0/8",
+		"deny 0.0.0.0/0 25",
+	})
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		port int
+		want bool
+	}{
+		{"10.1.2.3", 8080, true},
+		{"10.1.2.3", 80, false},
+		{"10.9.9.9", 443, false},
+		{"93.184.216.34", 25, false},
+		{"93.184.216.34", 443, true},
+		{"2001:db8::1", 443, true},
+	}
+
+	for _, tt := range tests {
+		if got := Allowed(rules, net.ParseIP(tt.ip), tt.port); got != tt.want {
+			t.Errorf("Allowed(%s, %d) = %v, want %v", tt.ip, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestAllowed_NoRules(t *testing.T) {
+	if !Allowed(nil, net.ParseIP("127.0.0.1"), 22) {
+		t.Error("destinations should be allowed when no rules are configured")
+	}
+}
@@ -0,0 +1,531 @@
+// Package escrow exports and restores the keys and certificates of tunnels.
+//
+// An escrow archive holds only crypto material, not the configuration, so it
+// can be kept off-box for disaster recovery: restored on a replacement
+// server, tunnels keep their public keys and certificate fingerprints and
+// existing client configs keep working. Archives can be encrypted with a
+// passphrase (scrypt and XChaCha20-Poly1305).
+package escrow
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/net2share/dnstm/internal/certs"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+// FormatVersion is the archive layout version written by Write.
+const FormatVersion = 1
+
+const (
+	manifestFile = "manifest.json"
+	tunnelsDir   = "tunnels"
+
+	// maxFileSize bounds a single archive entry; crypto files are a few KB.
+	maxFileSize = 1 << 20
+)
+
+// Crypto file names inside a tunnel directory, as written by the certs and
+// keys packages.
+const (
+	certFile    = "cert.pem"
+	certKeyFile = "key.pem"
+	privKeyFile = "server.key"
+	pubKeyFile  = "server.pub"
+)
+
+// secretFiles are written readable by the owner only.
+var secretFiles = map[string]bool{certKeyFile: true, privKeyFile: true}
+
+// chownToDnstm hands a restored tunnel directory to the service user.
+var chownToDnstm = system.ChownDirToDnstm
+
+// encryptedMagic starts an encrypted archive. The byte after it is the
+// encryption version, which fixes the scrypt parameters.
+var encryptedMagic = []byte("DNSTMESC")
+
+const (
+	encryptionVersion = 1
+	saltSize          = 16
+	scryptN           = 1 << 15
+	scryptR           = 8
+	scryptP           = 1
+)
+
+// ErrPassphraseRequired is returned by Read for an encrypted archive when no
+// passphrase is given.
+var ErrPassphraseRequired = errors.New("the archive is encrypted; a passphrase is required")
+
+// ErrWrongPassphrase is returned by Read when an encrypted archive does not
+// open with the passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase, or the archive is damaged")
+
+// Manifest describes an archive.
+type Manifest struct {
+	Version int         `json:"version"`
+	Created time.Time   `json:"created"`
+	Origin  string      `json:"origin,omitempty"`
+	Tunnels []TunnelKey `json:"tunnels"`
+}
+
+// TunnelKey is the public identity of an archived tunnel: the certificate
+// fingerprint for Slipstream and Chisel, the public key for DNSTT and VayDNS.
+type TunnelKey struct {
+	Tag         string               `json:"tag"`
+	Domain      string               `json:"domain"`
+	Transport   config.TransportType `json:"transport"`
+	Fingerprint string               `json:"fingerprint,omitempty"`
+	PublicKey   string               `json:"public_key,omitempty"`
+}
+
+// Archive is the crypto material of a set of tunnels.
+type Archive struct {
+	Manifest Manifest
+	// Files maps "tag/name" to the content of a tunnel's crypto file.
+	Files map[string][]byte
+}
+
+// Build collects the crypto files of tunnels into an archive.
+func Build(tunnels []*config.TunnelConfig) (*Archive, error) {
+	origin, _ := os.Hostname()
+	a := &Archive{
+		Manifest: Manifest{
+			Version: FormatVersion,
+			Created: time.Now().UTC(),
+			Origin:  origin,
+		},
+		Files: make(map[string][]byte),
+	}
+	for _, t := range tunnels {
+		if err := a.collectTunnel(t); err != nil {
+			return nil, fmt.Errorf("tunnel %s: %w", t.Tag, err)
+		}
+	}
+	return a, nil
+}
+
+// collectTunnel reads the crypto files of t.
+func (a *Archive) collectTunnel(t *config.TunnelConfig) error {
+	key := TunnelKey{Tag: t.Tag, Domain: t.Domain, Transport: t.Transport}
+	switch t.Transport {
+	case config.TransportSlipstream, config.TransportChisel:
+		var cert, certKey string
+		if t.Slipstream != nil {
+			cert, certKey = t.Slipstream.Cert, t.Slipstream.Key
+		}
+		if t.Chisel != nil {
+			cert, certKey = t.Chisel.Cert, t.Chisel.Key
+		}
+		if cert == "" || certKey == "" {
+			return fmt.Errorf("no certificate configured")
+		}
+		if err := a.addFiles(t.Tag, map[string]string{certFile: cert, certKeyFile: certKey}); err != nil {
+			return err
+		}
+		fingerprint, err := certs.ReadCertificateFingerprint(cert)
+		if err != nil {
+			return err
+		}
+		key.Fingerprint = fingerprint
+	case config.TransportDNSTT, config.TransportVayDNS:
+		var privateKey string
+		if t.DNSTT != nil {
+			privateKey = t.DNSTT.PrivateKey
+		}
+		if t.VayDNS != nil {
+			privateKey = t.VayDNS.PrivateKey
+		}
+		if privateKey == "" {
+			return fmt.Errorf("no private key configured")
+		}
+		if err := a.addFiles(t.Tag, map[string]string{privKeyFile: privateKey}); err != nil {
+			return err
+		}
+		publicKey, err := publicKeyOf(a.Files[path.Join(t.Tag, privKeyFile)])
+		if err != nil {
+			return err
+		}
+		a.Files[path.Join(t.Tag, pubKeyFile)] = []byte(publicKey + "\n")
+		key.PublicKey = publicKey
+	default:
+		return fmt.Errorf("transport %s has no keys", t.Transport)
+	}
+	a.Manifest.Tunnels = append(a.Manifest.Tunnels, key)
+	return nil
+}
+
+func (a *Archive) addFiles(tag string, files map[string]string) error {
+	for name, src := range files {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", src, err)
+		}
+		a.Files[path.Join(tag, name)] = data
+	}
+	return nil
+}
+
+// Tunnel returns the archived identity of a tunnel, or nil.
+func (a *Archive) Tunnel(tag string) *TunnelKey {
+	for i := range a.Manifest.Tunnels {
+		if a.Manifest.Tunnels[i].Tag == tag {
+			return &a.Manifest.Tunnels[i]
+		}
+	}
+	return nil
+}
+
+// Restore writes the crypto files of a tunnel into dir/<tag>/, replacing
+// the ones there, and returns that directory.
+func (a *Archive) Restore(dir, tag string) (string, error) {
+	return a.RestoreAs(dir, tag, tag)
+}
+
+// RestoreAs writes the crypto files of tunnel tag into dir/<as>/, so tunnel
+// as presents the same identity, and returns that directory.
+func (a *Archive) RestoreAs(dir, tag, as string) (string, error) {
+	tunnelDir := filepath.Join(dir, as)
+	if err := os.MkdirAll(tunnelDir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create tunnel directory: %w", err)
+	}
+	for _, name := range []string{certFile, certKeyFile, privKeyFile, pubKeyFile} {
+		data, ok := a.Files[path.Join(tag, name)]
+		if !ok {
+			continue
+		}
+		mode := os.FileMode(0644)
+		if secretFiles[name] {
+			mode = 0600
+		}
+		if err := os.WriteFile(filepath.Join(tunnelDir, name), data, mode); err != nil {
+			return "", fmt.Errorf("failed to write %s for %s: %w", name, as, err)
+		}
+	}
+	if err := chownToDnstm(tunnelDir); err != nil {
+		return "", fmt.Errorf("failed to set ownership of %s: %w", tunnelDir, err)
+	}
+	return tunnelDir, nil
+}
+
+// SetCryptoPaths points the config of t at the crypto files Restore wrote
+// into dir.
+func SetCryptoPaths(t *config.TunnelConfig, dir string) {
+	switch t.Transport {
+	case config.TransportSlipstream:
+		if t.Slipstream == nil {
+			t.Slipstream = &config.SlipstreamConfig{}
+		}
+		t.Slipstream.Cert = filepath.Join(dir, certFile)
+		t.Slipstream.Key = filepath.Join(dir, certKeyFile)
+	case config.TransportChisel:
+		if t.Chisel == nil {
+			t.Chisel = &config.ChiselConfig{}
+		}
+		t.Chisel.Cert = filepath.Join(dir, certFile)
+		t.Chisel.Key = filepath.Join(dir, certKeyFile)
+	case config.TransportDNSTT:
+		if t.DNSTT == nil {
+			t.DNSTT = &config.DNSTTConfig{}
+		}
+		t.DNSTT.PrivateKey = filepath.Join(dir, privKeyFile)
+	case config.TransportVayDNS:
+		if t.VayDNS == nil {
+			t.VayDNS = &config.VayDNSConfig{}
+		}
+		t.VayDNS.PrivateKey = filepath.Join(dir, privKeyFile)
+	}
+}
+
+// Write stores the archive at dest, readable by root only. With a non-empty
+// passphrase the archive is encrypted.
+func (a *Archive) Write(dest, passphrase string) error {
+	data, err := a.marshal()
+	if err != nil {
+		return err
+	}
+	if passphrase != "" {
+		if data, err = encrypt(data, passphrase); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(dest, data, 0600); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// marshal returns the archive as a gzipped tarball.
+func (a *Archive) marshal() ([]byte, error) {
+	manifest, err := json.MarshalIndent(a.Manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	names := make([]string, 0, len(a.Files))
+	for name := range a.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: a.Manifest.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := write(manifestFile, manifest); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	for _, name := range names {
+		if err := write(path.Join(tunnelsDir, name), a.Files[name]); err != nil {
+			return nil, fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// IsEncrypted reports whether the archive at src is encrypted.
+func IsEncrypted(src string) (bool, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+	head := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(head, encryptedMagic), nil
+}
+
+// Read loads an archive written by Write, decrypting it with passphrase when
+// it is encrypted. Each tunnel's files are checked against the identity in
+// the manifest.
+func Read(src, passphrase string) (*Archive, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if bytes.HasPrefix(data, encryptedMagic) {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		if data, err = decrypt(data, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not a dnstm key archive: %w", err)
+	}
+	defer gz.Close()
+
+	a := &Archive{Files: make(map[string][]byte)}
+	var manifest []byte
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected archive entry %s", hdr.Name)
+		}
+		if hdr.Size > maxFileSize {
+			return nil, fmt.Errorf("archive entry %s is too large", hdr.Name)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Name == manifestFile {
+			manifest = content
+			continue
+		}
+		rel, ok := cryptoEntry(hdr.Name)
+		if !ok {
+			return nil, fmt.Errorf("unexpected archive entry %s", hdr.Name)
+		}
+		a.Files[rel] = content
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("not a dnstm key archive: %s missing", manifestFile)
+	}
+	if err := json.Unmarshal(manifest, &a.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if a.Manifest.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported archive version %d (this dnstm reads version %d)", a.Manifest.Version, FormatVersion)
+	}
+
+	for rel := range a.Files {
+		tag, _ := path.Split(rel)
+		if a.Tunnel(path.Clean(tag)) == nil {
+			return nil, fmt.Errorf("archive holds files for unknown tunnel %s", path.Clean(tag))
+		}
+	}
+	for _, k := range a.Manifest.Tunnels {
+		if err := a.verify(k); err != nil {
+			return nil, fmt.Errorf("tunnel %s: %w", k.Tag, err)
+		}
+	}
+	return a, nil
+}
+
+// verify checks that the files of a tunnel match its recorded identity.
+func (a *Archive) verify(k TunnelKey) error {
+	if k.Fingerprint != "" {
+		cert, key := a.Files[path.Join(k.Tag, certFile)], a.Files[path.Join(k.Tag, certKeyFile)]
+		if cert == nil || key == nil {
+			return fmt.Errorf("certificate missing")
+		}
+		if _, err := tls.X509KeyPair(cert, key); err != nil {
+			return fmt.Errorf("certificate and key do not match: %w", err)
+		}
+		fingerprint, err := certs.CertificateFingerprint(cert)
+		if err != nil {
+			return err
+		}
+		if fingerprint != k.Fingerprint {
+			return fmt.Errorf("certificate does not match fingerprint %s", certs.FormatFingerprint(k.Fingerprint))
+		}
+		return nil
+	}
+
+	privateKey, ok := a.Files[path.Join(k.Tag, privKeyFile)]
+	if !ok {
+		return fmt.Errorf("private key missing")
+	}
+	publicKey, err := publicKeyOf(privateKey)
+	if err != nil {
+		return err
+	}
+	if publicKey != k.PublicKey {
+		return fmt.Errorf("private key does not match public key %s", k.PublicKey)
+	}
+	return nil
+}
+
+// cryptoEntry returns "tag/name" for a tunnels/<tag>/<name> entry with a
+// known crypto file name.
+func cryptoEntry(name string) (string, bool) {
+	dir, file := path.Split(name)
+	parent, tag := path.Split(path.Clean(dir))
+	if path.Clean(parent) != tunnelsDir || tag == "" || tag == "." || tag == ".." {
+		return "", false
+	}
+	switch file {
+	case certFile, certKeyFile, privKeyFile, pubKeyFile:
+		return path.Join(tag, file), true
+	}
+	return "", false
+}
+
+// publicKeyOf derives the hex public key of a hex Curve25519 private key
+// file.
+func publicKeyOf(privateKeyFile []byte) (string, error) {
+	privateKey, err := hex.DecodeString(strings.TrimSpace(string(privateKeyFile)))
+	if err != nil || len(privateKey) != curve25519.ScalarSize {
+		return "", fmt.Errorf("invalid private key")
+	}
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	return hex.EncodeToString(publicKey), nil
+}
+
+// encrypt seals data with a key derived from passphrase. The output is the
+// magic, the encryption version, the salt, the nonce and the ciphertext.
+func encrypt(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, encryptedMagic...), encryptionVersion)
+	header = append(append(header, salt...), nonce...)
+	return aead.Seal(header, nonce, data, header), nil
+}
+
+// decrypt opens data sealed by encrypt.
+func decrypt(data []byte, passphrase string) ([]byte, error) {
+	headerSize := len(encryptedMagic) + 1 + saltSize + chacha20poly1305.NonceSizeX
+	if len(data) < headerSize {
+		return nil, ErrWrongPassphrase
+	}
+	if v := data[len(encryptedMagic)]; v != encryptionVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", v)
+	}
+	salt := data[len(encryptedMagic)+1 : len(encryptedMagic)+1+saltSize]
+	nonce := data[len(encryptedMagic)+1+saltSize : headerSize]
+
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, nonce, data[headerSize:], data[:headerSize])
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return chacha20poly1305.NewX(key)
+}
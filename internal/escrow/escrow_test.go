@@ -0,0 +1,134 @@
+package escrow
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/certs"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/keys"
+)
+
+func testTunnels(t *testing.T) []*config.TunnelConfig {
+	t.Helper()
+	dir := t.TempDir()
+
+	certInfo, err := certs.GenerateInDir(filepath.Join(dir, "slip1"), "s.example.com")
+	if err != nil {
+		t.Fatalf("GenerateInDir failed: %v", err)
+	}
+	keyInfo, err := keys.GenerateInDir(filepath.Join(dir, "dnstt1"))
+	if err != nil {
+		t.Fatalf("GenerateInDir failed: %v", err)
+	}
+
+	return []*config.TunnelConfig{
+		{
+			Tag:        "slip1",
+			Transport:  config.TransportSlipstream,
+			Domain:     "s.example.com",
+			Slipstream: &config.SlipstreamConfig{Cert: certInfo.CertPath, Key: certInfo.KeyPath},
+		},
+		{
+			Tag:       "dnstt1",
+			Transport: config.TransportDNSTT,
+			Domain:    "d.example.com",
+			DNSTT:     &config.DNSTTConfig{PrivateKey: keyInfo.PrivateKeyPath},
+		},
+	}
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	chownToDnstm = func(string) error { return nil }
+	tunnels := testTunnels(t)
+
+	a, err := Build(tunnels)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	for _, passphrase := range []string{"", "correct horse"} {
+		dest := filepath.Join(t.TempDir(), "keys")
+		if err := a.Write(dest, passphrase); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		encrypted, err := IsEncrypted(dest)
+		if err != nil {
+			t.Fatalf("IsEncrypted failed: %v", err)
+		}
+		if encrypted != (passphrase != "") {
+			t.Errorf("IsEncrypted = %v with passphrase %q", encrypted, passphrase)
+		}
+
+		got, err := Read(dest, passphrase)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if len(got.Manifest.Tunnels) != 2 {
+			t.Fatalf("expected 2 tunnels, got %d", len(got.Manifest.Tunnels))
+		}
+		if got.Tunnel("slip1").Fingerprint == "" || got.Tunnel("dnstt1").PublicKey == "" {
+			t.Errorf("identities missing: %+v", got.Manifest.Tunnels)
+		}
+
+		restoreDir := t.TempDir()
+		dir, err := got.Restore(restoreDir, "dnstt1")
+		if err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		restored := keys.GetFromDir(dir)
+		if restored == nil || restored.PublicKey != got.Tunnel("dnstt1").PublicKey {
+			t.Errorf("restored key = %+v, want public key %s", restored, got.Tunnel("dnstt1").PublicKey)
+		}
+		info, err := os.Stat(filepath.Join(dir, "server.key"))
+		if err != nil {
+			t.Fatalf("stat failed: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("server.key mode = %o, want 600", info.Mode().Perm())
+		}
+
+		tunnel := &config.TunnelConfig{Tag: "dnstt1", Transport: config.TransportDNSTT}
+		SetCryptoPaths(tunnel, dir)
+		if tunnel.DNSTT.PrivateKey != filepath.Join(dir, "server.key") {
+			t.Errorf("PrivateKey = %s", tunnel.DNSTT.PrivateKey)
+		}
+	}
+}
+
+func TestRead_Passphrase(t *testing.T) {
+	a, err := Build(testTunnels(t))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	dest := filepath.Join(t.TempDir(), "keys.enc")
+	if err := a.Write(dest, "secret"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if _, err := Read(dest, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Read without passphrase: got %v, want ErrPassphraseRequired", err)
+	}
+	if _, err := Read(dest, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Read with wrong passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestRead_RejectsMismatchedKey(t *testing.T) {
+	a, err := Build(testTunnels(t))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	a.Files[path.Join("dnstt1", privKeyFile)] = []byte("0000000000000000000000000000000000000000000000000000000000000001\n")
+
+	dest := filepath.Join(t.TempDir(), "keys.tar.gz")
+	if err := a.Write(dest, ""); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := Read(dest, ""); err == nil {
+		t.Error("expected an error for a key not matching its public key")
+	}
+}
@@ -0,0 +1,197 @@
+// Package fail2ban generates fail2ban jails for the services dnstm exposes.
+//
+// Only services that see their clients' real addresses get a jail. Tunnel
+// traffic reaches the backends from the tunnel servers on loopback, so
+// loopback is always ignored: banning it would cut off every tunnel user.
+package fail2ban
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// JailPath is the jail file dnstm manages.
+var JailPath = "/etc/fail2ban/jail.d/dnstm.conf"
+
+// SSHJail bans addresses that keep failing to log in to sshd, e.g. probing
+// the SSH tunnel users directly.
+const SSHJail = "dnstm-sshd"
+
+// Defaults for the generated jails.
+const (
+	DefaultMaxRetry = 5
+	DefaultFindTime = "10m"
+	DefaultBanTime  = "1h"
+)
+
+// Jail is a fail2ban jail using a filter shipped with fail2ban.
+type Jail struct {
+	Name     string
+	Filter   string
+	Ports    []int
+	MaxRetry int
+	FindTime string
+	BanTime  string
+	IgnoreIP []string
+}
+
+// Jails returns the jails for the services in cfg. ignore lists further
+// addresses that must never be banned, such as the server's own IPs.
+func Jails(cfg *config.Config, ignore []string) []Jail {
+	return []Jail{{
+		Name:     SSHJail,
+		Filter:   "sshd",
+		Ports:    sshPorts(cfg),
+		MaxRetry: DefaultMaxRetry,
+		FindTime: DefaultFindTime,
+		BanTime:  DefaultBanTime,
+		IgnoreIP: append([]string{"127.0.0.1/8", "::1"}, ignore...),
+	}}
+}
+
+// sshPorts returns the sshd ports of the SSH backends, 22 when there are none.
+func sshPorts(cfg *config.Config) []int {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, b := range cfg.Backends {
+		if b.Type != config.BackendSSH {
+			continue
+		}
+		_, p, err := net.SplitHostPort(b.Address)
+		if err != nil {
+			continue
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil || seen[port] {
+			continue
+		}
+		seen[port] = true
+		ports = append(ports, port)
+	}
+	if len(ports) == 0 {
+		return []int{22}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// Render returns the jail file contents.
+func Render(jails []Jail) string {
+	var b strings.Builder
+	b.WriteString("# Managed by dnstm; changes are overwritten by 'dnstm security fail2ban enable'.\n")
+	for _, j := range jails {
+		ports := make([]string, len(j.Ports))
+		for i, p := range j.Ports {
+			ports[i] = strconv.Itoa(p)
+		}
+		fmt.Fprintf(&b, "\n[%s]\n", j.Name)
+		fmt.Fprintf(&b, "enabled  = true\n")
+		fmt.Fprintf(&b, "filter   = %s\n", j.Filter)
+		fmt.Fprintf(&b, "backend  = systemd\n")
+		fmt.Fprintf(&b, "port     = %s\n", strings.Join(ports, ","))
+		fmt.Fprintf(&b, "maxretry = %d\n", j.MaxRetry)
+		fmt.Fprintf(&b, "findtime = %s\n", j.FindTime)
+		fmt.Fprintf(&b, "bantime  = %s\n", j.BanTime)
+		fmt.Fprintf(&b, "ignoreip = %s\n", strings.Join(j.IgnoreIP, " "))
+	}
+	return b.String()
+}
+
+// IsInstalled reports whether fail2ban is installed.
+func IsInstalled() bool {
+	_, err := exec.LookPath("fail2ban-client")
+	return err == nil
+}
+
+// IsEnabled reports whether the dnstm jail file is in place.
+func IsEnabled() bool {
+	_, err := os.Stat(JailPath)
+	return err == nil
+}
+
+// HasJail reports whether fail2ban runs a jail with the given name.
+func HasJail(name string) bool {
+	return exec.Command("fail2ban-client", "status", name).Run() == nil
+}
+
+// Apply writes the jail file and reloads fail2ban.
+func Apply(jails []Jail) error {
+	if err := os.WriteFile(JailPath, []byte(Render(jails)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", JailPath, err)
+	}
+	return reload()
+}
+
+// Remove deletes the jail file and reloads fail2ban, lifting its bans.
+func Remove() error {
+	if err := os.Remove(JailPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", JailPath, err)
+	}
+	return reload()
+}
+
+func reload() error {
+	if exec.Command("systemctl", "is-active", "--quiet", "fail2ban").Run() != nil {
+		if output, err := exec.Command("systemctl", "enable", "--now", "fail2ban").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to start fail2ban: %s: %w", strings.TrimSpace(string(output)), err)
+		}
+		return nil
+	}
+	if output, err := exec.Command("fail2ban-client", "reload").CombinedOutput(); err != nil {
+		return fmt.Errorf("fail2ban reload failed: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// JailStatus is the state of a running jail.
+type JailStatus struct {
+	Name            string
+	CurrentlyFailed int
+	TotalFailed     int
+	CurrentlyBanned int
+	TotalBanned     int
+	BannedIPs       []string
+}
+
+// GetJailStatus queries fail2ban for a jail's state.
+func GetJailStatus(name string) (*JailStatus, error) {
+	output, err := exec.Command("fail2ban-client", "status", name).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("jail %s: %s", name, strings.TrimSpace(string(output)))
+	}
+	return parseJailStatus(name, string(output)), nil
+}
+
+// parseJailStatus parses "fail2ban-client status <jail>" output.
+func parseJailStatus(name, output string) *JailStatus {
+	s := &JailStatus{Name: name}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimLeft(key, " |-`")
+		value = strings.TrimSpace(value)
+		n, _ := strconv.Atoi(value)
+		switch key {
+		case "Currently failed":
+			s.CurrentlyFailed = n
+		case "Total failed":
+			s.TotalFailed = n
+		case "Currently banned":
+			s.CurrentlyBanned = n
+		case "Total banned":
+			s.TotalBanned = n
+		case "Banned IP list":
+			s.BannedIPs = strings.Fields(value)
+		}
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package fail2ban
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func TestJails_SSHPorts(t *testing.T) {
+	cfg := &config.Config{Backends: []config.BackendConfig{
+		{Tag: "ssh", Type: config.BackendSSH, Address: "127.0.0.1:2222"},
+		{Tag: "ssh2", Type: config.BackendSSH, Address: "127.0.0.1:22"},
+		{Tag: "ssh3", Type: config.BackendSSH, Address: "127.0.0.1:2222"},
+		{Tag: "socks", Type: config.BackendSOCKS, Address: "127.0.0.1:1080"},
+	}}
+
+	jails := Jails(cfg, []string{"203.0.113.10"})
+	if len(jails) != 1 || jails[0].Name != SSHJail {
+		t.Fatalf("Jails() = %+v, want the sshd jail", jails)
+	}
+	if got := jails[0].Ports; len(got) != 2 || got[0] != 22 || got[1] != 2222 {
+		t.Errorf("Ports = %v, want [22 2222]", got)
+	}
+
+	if got := Jails(&config.Config{}, nil)[0].Ports; len(got) != 1 || got[0] != 22 {
+		t.Errorf("Ports without ssh backends = %v, want [22]", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	out := Render(Jails(&config.Config{}, []string{"203.0.113.10"}))
+	for _, want := range []string{
+		"[dnstm-sshd]",
+		"filter   = sshd",
+		"backend  = systemd",
+		"port     = 22",
+		"ignoreip = 127.0.0.1/8 ::1 203.0.113.10",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Render() missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseJailStatus(t *testing.T) {
+	output := "Status for the jail: dnstm-sshd\n" +
+		"|- Filter\n" +
+		"|  |- Currently failed:\t3\n" +
+		"|  |- Total failed:\t42\n" +
+		"|  `- Journal matches:\t_SYSTEMD_UNIT=sshd.service + _COMM=sshd\n" +
+		"`- Actions\n" +
+		"   |- Currently banned:\t2\n" +
+		"   |- Total banned:\t7\n" +
+		"   `- Banned IP list:\t198.51.100.7 192.0.2.9\n"
+
+	s := parseJailStatus("dnstm-sshd", output)
+	if s.CurrentlyFailed != 3 || s.TotalFailed != 42 || s.CurrentlyBanned != 2 || s.TotalBanned != 7 {
+		t.Errorf("parseJailStatus() = %+v", s)
+	}
+	if len(s.BannedIPs) != 2 || s.BannedIPs[0] != "198.51.100.7" {
+		t.Errorf("BannedIPs = %v", s.BannedIPs)
+	}
+}
@@ -0,0 +1,114 @@
+// Package fwguard keeps firewall changes made over SSH from locking the
+// operator out.
+//
+// Before dnstm changes the firewall, Begin saves the current rules and
+// installs a timer that runs "dnstm firewall rollback" once the confirm
+// timeout has passed, and again shortly after boot. "dnstm firewall confirm"
+// removes the timer and the saved rules, keeping the changes. Changes made
+// while a rollback is already pending are covered by the first one.
+package fwguard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// Dir holds the saved firewall rules and the pending record.
+var Dir = "/var/lib/dnstm/firewall"
+
+// TimerName is the timer and oneshot service running the rollback.
+var TimerName = paths.SystemService("firewall-rollback")
+
+// Pending describes firewall changes waiting for confirmation.
+type Pending struct {
+	Reason   string    `json:"reason"`
+	Since    time.Time `json:"since"`
+	Deadline time.Time `json:"deadline"`
+	SSHPort  int       `json:"ssh_port,omitempty"`
+}
+
+func pendingPath() string {
+	return filepath.Join(Dir, "pending.json")
+}
+
+func stateDir() string {
+	return filepath.Join(Dir, "state")
+}
+
+// Get returns the pending changes, or nil if nothing awaits confirmation.
+func Get() *Pending {
+	data, err := os.ReadFile(pendingPath())
+	if err != nil {
+		return nil
+	}
+	var p Pending
+	if err := json.Unmarshal(data, &p); err != nil {
+		return &Pending{}
+	}
+	return &p
+}
+
+// Begin saves the current firewall rules and schedules their restore after
+// timeout. If a rollback is already pending it is returned unchanged.
+func Begin(reason string, sshPort int, timeout time.Duration) (*Pending, error) {
+	if p := Get(); p != nil {
+		return p, nil
+	}
+
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", Dir, err)
+	}
+	if err := network.SaveFirewallState(stateDir()); err != nil {
+		os.RemoveAll(Dir)
+		return nil, fmt.Errorf("failed to save firewall rules: %w", err)
+	}
+
+	now := time.Now()
+	p := &Pending{Reason: reason, Since: now, Deadline: now.Add(timeout), SSHPort: sshPort}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(pendingPath(), data, 0600); err != nil {
+		os.RemoveAll(Dir)
+		return nil, err
+	}
+
+	if err := service.CreateTimer(&service.TimerConfig{
+		Name:        TimerName,
+		Description: "dnstm firewall rollback",
+		ExecStart:   cmdline.Join(paths.Bin("dnstm"), "firewall", "rollback", "--force"),
+		OnCalendar:  p.Deadline.Format("2006-01-02 15:04:05"),
+		OnBoot:      time.Minute,
+	}); err != nil {
+		os.RemoveAll(Dir)
+		return nil, fmt.Errorf("failed to schedule rollback: %w", err)
+	}
+	return p, nil
+}
+
+// Confirm keeps the current firewall rules and cancels the rollback.
+func Confirm() error {
+	if service.IsTimerInstalled(TimerName) {
+		if err := service.RemoveTimer(TimerName); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(Dir)
+}
+
+// Rollback restores the rules saved by Begin and cancels the timer.
+func Rollback() error {
+	if err := network.RestoreFirewallState(stateDir()); err != nil {
+		return err
+	}
+	return Confirm()
+}
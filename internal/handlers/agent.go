@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+func init() {
+	actions.SetAPIHandler(actions.ActionAPIAgents, HandleAPIAgents)
+	actions.SetAgentHandler(actions.ActionAgentEnroll, HandleAgentEnroll)
+	actions.SetAgentHandler(actions.ActionAgentStatus, HandleAgentStatus)
+	actions.SetAgentHandler(actions.ActionAgentLeave, HandleAgentLeave)
+	actions.SetAgentHandler(actions.ActionAgentToken, HandleAgentToken)
+	actions.SetAgentHandler(actions.ActionAgentList, HandleAgentList)
+	actions.SetAgentHandler(actions.ActionAgentRevoke, HandleAgentRevoke)
+	actions.SetAgentHandler(actions.ActionAgentExec, HandleAgentExec)
+}
+
+// agentOnline is how recently an agent must have polled to count as online.
+// Polls are held open for less than that.
+const agentOnline = time.Minute
+
+// controllerRegistry returns the registry of this controller. Files root
+// writes are handed to the dnstm user the API server runs as.
+func controllerRegistry() agent.Registry {
+	return agent.Registry{Dir: agent.ControllerDir, Chown: system.ChownToDnstm}
+}
+
+// requireController returns the config when this server accepts agents.
+func requireController(ctx *actions.Context) (*config.Config, error) {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.API.IsEnabled() || cfg.API.Agents == nil {
+		return nil, actions.NewActionError("this server does not accept agents", "Turn it into a controller with: dnstm api agents")
+	}
+	return cfg, nil
+}
+
+// HandleAPIAgents turns agent enrollment on or off.
+func HandleAPIAgents(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !cfg.API.IsEnabled() {
+		return actions.NewActionError("the API server is not enabled", "Enable it first with: dnstm api enable")
+	}
+
+	if ctx.GetBool("disable") {
+		cfg.API.Agents = nil
+	} else {
+		listen := strings.TrimSpace(ctx.GetString("listen"))
+		if listen == "" {
+			listen = config.DefaultAgentsListen
+		}
+		cfg.API.Agents = &config.AgentsConfig{Listen: listen}
+	}
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Example: --listen "+config.DefaultAgentsListen)
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.API.Agents = cfg.API.Agents
+		return c.Validate()
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := startAPIServer(cfg); err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if cfg.API.Agents == nil {
+		ctx.Output.Success("Agents disabled")
+		ctx.Output.Info("Enrolled agents are kept and reconnect once agents are enabled again.")
+		ctx.Output.Println()
+		return nil
+	}
+	ctx.Output.Success(fmt.Sprintf("Accepting agents on %s (TLS)", cfg.API.Agents.Listen))
+	ctx.Output.Info("Create an enrollment token with: dnstm agent token")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAgentEnroll enrolls this server with a controller and starts the
+// agent service.
+func HandleAgentEnroll(ctx *actions.Context) error {
+	if _, err := RequireConfig(ctx); err != nil {
+		return err
+	}
+	if state, err := agent.LoadState(); err == nil && !state.Revoked {
+		return actions.NewActionError(
+			fmt.Sprintf("this server is already enrolled with %s as '%s'", state.Controller, state.Name),
+			"Leave first with: dnstm agent leave")
+	}
+
+	name := strings.TrimSpace(ctx.GetString("name"))
+	if name == "" {
+		host, _ := os.Hostname()
+		name, _, _ = strings.Cut(strings.ToLower(host), ".")
+	}
+	if err := agent.ValidateName(name); err != nil {
+		return actions.NewActionError(err.Error(), "Pick a name with --name")
+	}
+
+	enrollCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	state, err := agent.Enroll(enrollCtx, ctx.GetString("controller"), ctx.GetString("token"), name)
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Tokens work once and expire; create a new one with 'dnstm agent token' on the controller")
+	}
+	if err := agent.InstallService(); err != nil {
+		return fmt.Errorf("failed to start agent service: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success(fmt.Sprintf("Enrolled with %s as '%s'", state.Controller, state.Name))
+	ctx.Output.Printf("The %s service keeps the control channel open.\n", agent.ServiceName)
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAgentStatus shows this server's enrollment.
+func HandleAgentStatus(ctx *actions.Context) error {
+	state, err := agent.LoadState()
+	if errors.Is(err, os.ErrNotExist) {
+		ctx.Output.Info("This server is not enrolled with a controller")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read agent state: %w", err)
+	}
+
+	service := "stopped"
+	switch {
+	case state.Revoked:
+		service = "revoked by the controller"
+	case agent.IsActive():
+		service = "running"
+	}
+	ctx.Output.Println()
+	ctx.Output.Printf("Controller: %s\n", state.Controller)
+	ctx.Output.Printf("Name:       %s\n", state.Name)
+	ctx.Output.Printf("Enrolled:   %s\n", state.EnrolledAt.Local().Format("2006-01-02 15:04"))
+	ctx.Output.Printf("Service:    %s (%s)\n", agent.ServiceName, service)
+	ctx.Output.Printf("CA SHA-256: %s\n", state.CAHash)
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAgentLeave stops the agent service and deletes the agent's key.
+func HandleAgentLeave(ctx *actions.Context) error {
+	if !agent.IsEnrolled() && !agent.IsInstalled() {
+		ctx.Output.Info("This server is not enrolled with a controller")
+		return nil
+	}
+	if err := agent.RemoveService(); err != nil {
+		return fmt.Errorf("failed to remove agent service: %w", err)
+	}
+	if err := agent.Leave(); err != nil {
+		return fmt.Errorf("failed to delete agent state: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success("Left the fleet")
+	ctx.Output.Info("Revoke this agent on the controller with: dnstm agent revoke <name>")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAgentToken creates an enrollment token.
+func HandleAgentToken(ctx *actions.Context) error {
+	cfg, err := requireController(ctx)
+	if err != nil {
+		return err
+	}
+
+	ttl := agent.DefaultTokenTTL
+	if s := strings.TrimSpace(ctx.GetString("ttl")); s != "" {
+		if ttl, err = config.ParseDays(s); err != nil || ttl <= 0 {
+			return actions.NewActionError(fmt.Sprintf("invalid --ttl '%s'", s), "Example: --ttl 1h or --ttl 7d")
+		}
+	}
+
+	ca, err := agent.LoadOrCreateCA(agent.ControllerDir)
+	if err != nil {
+		return fmt.Errorf("failed to load agent CA: %w", err)
+	}
+	token, tok, err := controllerRegistry().CreateToken(ca.Hash(), ttl, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+
+	host := "<server>"
+	if ip, err := network.GetExternalIP(); err == nil {
+		host = ip
+	}
+	controller := "https://" + net.JoinHostPort(host, strconv.Itoa(cfg.API.Agents.Port()))
+
+	ctx.Output.Println()
+	ctx.Output.Success("Enrollment token created")
+	ctx.Output.Printf("Expires: %s\n", tok.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	ctx.Output.Println()
+	ctx.Output.Println("Run on the server to enroll:")
+	ctx.Output.Printf("  dnstm agent enroll --controller %s --token %s\n", controller, token)
+	ctx.Output.Println()
+	ctx.Output.Info("The token enrolls one agent. Keep it secret until then.")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAgentList lists the enrolled agents.
+func HandleAgentList(ctx *actions.Context) error {
+	if _, err := requireController(ctx); err != nil {
+		return err
+	}
+	reg := controllerRegistry()
+	nodes, err := reg.Nodes()
+	if err != nil {
+		return fmt.Errorf("failed to read agents: %w", err)
+	}
+	if len(nodes) == 0 {
+		ctx.Output.Info("No agents enrolled")
+		ctx.Output.Println("  Create an enrollment token with: dnstm agent token")
+		return nil
+	}
+
+	now := time.Now()
+	ctx.Output.Printf("%-20s %-16s %-8s %-17s %s\n", "NAME", "ADDRESS", "STATUS", "LAST SEEN", "ENROLLED")
+	ctx.Output.Separator(80)
+	for _, n := range nodes {
+		status := "offline"
+		switch {
+		case reg.IsRevoked(n.Serial):
+			status = "revoked"
+		case now.Sub(n.LastSeen) < agentOnline:
+			status = "online"
+		}
+		ctx.Output.Printf("%-20s %-16s %-8s %-17s %s\n", n.Name, n.Address, status,
+			n.LastSeen.Local().Format("2006-01-02 15:04"), n.EnrolledAt.Local().Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// HandleAgentRevoke revokes an agent's certificate.
+func HandleAgentRevoke(ctx *actions.Context) error {
+	if _, err := requireController(ctx); err != nil {
+		return err
+	}
+	name := ctx.GetArg(0)
+	if err := controllerRegistry().Revoke(name, time.Now()); err != nil {
+		return actions.NewActionError(err.Error(), "List agents with: dnstm agent list")
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success(fmt.Sprintf("Agent '%s' revoked", name))
+	ctx.Output.Info("It stops its service when it next connects. The name can be enrolled again.")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAgentExec queues a dnstm command for an agent and prints its
+// result once the agent reports it.
+func HandleAgentExec(ctx *actions.Context) error {
+	if _, err := requireController(ctx); err != nil {
+		return err
+	}
+	name := ctx.GetArg(0)
+	args := ctx.Args[1:]
+	if len(args) == 0 {
+		return actions.NewActionError("no command given", "Example: dnstm agent exec "+name+" -- tunnel list")
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(ctx.GetString("timeout")))
+	if err != nil || timeout <= 0 {
+		return actions.NewActionError(fmt.Sprintf("invalid --timeout '%s'", ctx.GetString("timeout")), "Example: --timeout 5m")
+	}
+
+	reg := controllerRegistry()
+	node, err := reg.Node(name)
+	if err != nil {
+		return actions.NewActionError(fmt.Sprintf("agent '%s' is not enrolled", name), "List agents with: dnstm agent list")
+	}
+	if reg.IsRevoked(node.Serial) {
+		return actions.NewActionError(fmt.Sprintf("agent '%s' is revoked", name), "")
+	}
+	cmd, err := reg.QueueCommand(name, args, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to queue command: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		cmd, err = reg.Command(name, cmd.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read command: %w", err)
+		}
+		if cmd.Status == agent.CommandDone {
+			break
+		}
+		if time.Now().After(deadline) {
+			return actions.NewActionError(
+				fmt.Sprintf("agent '%s' did not report within %s (command %s is %s)", name, timeout, cmd.ID, cmd.Status),
+				"The agent runs it once it reconnects; check it with: dnstm agent list")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	ctx.Output.Print(cmd.Output)
+	if cmd.ExitCode != 0 {
+		return fmt.Errorf("command failed on '%s' with exit code %d", name, cmd.ExitCode)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/api"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+)
+
+func init() {
+	actions.SetAPIHandler(actions.ActionAPIEnable, HandleAPIEnable)
+	actions.SetAPIHandler(actions.ActionAPIDisable, HandleAPIDisable)
+	actions.SetAPIHandler(actions.ActionAPIStatus, HandleAPIStatus)
+	actions.SetAPIHandler(actions.ActionAPIStatusPage, HandleAPIStatusPage)
+}
+
+// HandleAPIEnable configures the API server address and (re)starts it.
+func HandleAPIEnable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	listen := strings.TrimSpace(ctx.GetString("listen"))
+	if listen == "" {
+		listen = config.DefaultAPIListen
+	}
+	cfg.API.Listen = listen
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Example: --listen "+config.DefaultAPIListen)
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.API.Listen = cfg.API.Listen
+		return c.Validate()
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := startAPIServer(cfg); err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success(fmt.Sprintf("API server listening on %s", listen))
+	if !cfg.API.StatusPage {
+		ctx.Output.Info("Turn on the public status page with: dnstm api status-page")
+	}
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAPIDisable stops and removes the API server.
+func HandleAPIDisable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !cfg.API.IsEnabled() && !api.IsInstalled() {
+		ctx.Output.Info("The API server is not enabled")
+		return nil
+	}
+
+	if err := api.Remove(); err != nil {
+		return fmt.Errorf("failed to remove API server: %w", err)
+	}
+	cfg.API = config.APIConfig{}
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.API = config.APIConfig{}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success("API server disabled")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAPIStatus shows whether the API server runs and what it serves.
+func HandleAPIStatus(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if !cfg.API.IsEnabled() {
+		ctx.Output.Info("The API server is not enabled")
+		ctx.Output.Println("  Enable with: dnstm api enable --listen " + config.DefaultAPIListen)
+		ctx.Output.Println()
+		return nil
+	}
+
+	state := "stopped"
+	if api.IsActive() {
+		state = "running"
+	}
+	ctx.Output.Printf("Service:     %s (%s)\n", api.ServiceName, state)
+	ctx.Output.Printf("Listen:      %s\n", cfg.API.Listen)
+	statusPage := "off"
+	if cfg.API.StatusPage {
+		statusPage = "/status, /status.json"
+	}
+	ctx.Output.Printf("Status page: %s\n", statusPage)
+	webhook := "off"
+	if cfg.API.Webhook != nil {
+		webhook = "/v1/provision (backend " + cfg.API.Webhook.GetBackend() + ")"
+	}
+	ctx.Output.Printf("Webhook:     %s\n", webhook)
+	agents := "off"
+	if a := cfg.API.Agents; a != nil {
+		agents = a.Listen + " (TLS)"
+	}
+	ctx.Output.Printf("Agents:      %s\n", agents)
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAPIStatusPage turns the public status page on or off.
+func HandleAPIStatusPage(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !cfg.API.IsEnabled() {
+		return actions.NewActionError("the API server is not enabled", "Enable it first with: dnstm api enable")
+	}
+
+	cfg.API.StatusPage = !ctx.GetBool("disable")
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.API.StatusPage = cfg.API.StatusPage
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := startAPIServer(cfg); err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if cfg.API.StatusPage {
+		ctx.Output.Success(fmt.Sprintf("Status page served at http://<server>:%d/status", cfg.API.Port()))
+		ctx.Output.Info("It shows whether each tunnel domain is up; nothing else is published.")
+	} else {
+		ctx.Output.Success("Status page disabled")
+	}
+	ctx.Output.Println()
+	return nil
+}
+
+// startAPIServer writes the API server unit, opens its ports, starts or
+// restarts it and installs or removes the provisioning timer.
+func startAPIServer(cfg *config.Config) error {
+	if err := api.CreateService(cfg); err != nil {
+		return fmt.Errorf("failed to create API server service: %w", err)
+	}
+	if err := network.AllowTCPPort(cfg.API.Port()); err != nil {
+		return fmt.Errorf("failed to open port %d: %w", cfg.API.Port(), err)
+	}
+	if a := cfg.API.Agents; a != nil {
+		if err := network.AllowTCPPort(a.Port()); err != nil {
+			return fmt.Errorf("failed to open port %d: %w", a.Port(), err)
+		}
+	}
+	if err := api.Start(); err != nil {
+		return fmt.Errorf("failed to start API server: %w", err)
+	}
+	if err := api.SyncProvisionTimer(cfg); err != nil {
+		return fmt.Errorf("failed to set up provisioning timer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/api"
+)
+
+func init() {
+	actions.SetAPIHandler(actions.ActionAPISpec, HandleAPISpec)
+}
+
+// HandleAPISpec prints the OpenAPI document of the API server.
+func HandleAPISpec(ctx *actions.Context) error {
+	data, err := api.SpecJSON()
+	if err != nil {
+		return fmt.Errorf("failed to build OpenAPI document: %w", err)
+	}
+
+	if outputFile := ctx.GetString("file"); outputFile != "" {
+		if err := os.WriteFile(outputFile, data, 0644); err != nil {
+			return fmt.Errorf("failed to write to file: %w", err)
+		}
+		ctx.Output.Success(fmt.Sprintf("OpenAPI document written to %s", outputFile))
+		return nil
+	}
+
+	fmt.Print(string(data))
+	return nil
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/api"
+	"github.com/net2share/dnstm/internal/clientcfg"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+func init() {
+	actions.SetAPIHandler(actions.ActionAPIWebhook, HandleAPIWebhook)
+	actions.SetAPIHandler(actions.ActionAPIJobs, HandleAPIJobs)
+	actions.SetAPIHandler(actions.ActionAPIProvision, HandleAPIProvision)
+}
+
+// HandleAPIWebhook turns the provisioning webhook on or off.
+func HandleAPIWebhook(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !cfg.API.IsEnabled() {
+		return actions.NewActionError("the API server is not enabled", "Enable it first with: dnstm api enable")
+	}
+
+	var secret string
+	if ctx.GetBool("disable") {
+		cfg.API.Webhook = nil
+	} else {
+		secret = strings.TrimSpace(ctx.GetString("secret"))
+		generated := secret == ""
+		if generated {
+			if cfg.API.Webhook != nil {
+				secret = cfg.API.Webhook.Secret
+			} else {
+				secret = generateWebhookSecret()
+			}
+		}
+		cfg.API.Webhook = &config.WebhookConfig{Secret: secret, Backend: strings.TrimSpace(ctx.GetString("backend"))}
+		if cfg.API.Webhook.Backend == config.DefaultWebhookBackend {
+			cfg.API.Webhook.Backend = ""
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Omit --secret to generate one, and pick an existing backend")
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.API.Webhook = cfg.API.Webhook
+		return c.Validate()
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := startAPIServer(cfg); err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if cfg.API.Webhook == nil {
+		ctx.Output.Success("Provisioning webhook disabled")
+		ctx.Output.Println()
+		return nil
+	}
+	ctx.Output.Success(fmt.Sprintf("Provisioning webhook accepting requests at http://<server>:%d/v1/provision", cfg.API.Port()))
+	ctx.Output.Printf("Secret:  %s\n", secret)
+	ctx.Output.Printf("Backend: %s (default)\n", cfg.API.Webhook.GetBackend())
+	ctx.Output.Info("Sign requests with HMAC-SHA256 of \"<timestamp>.<body>\"; see docs/CLI.md.")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAPIJobs lists the provisioning jobs.
+func HandleAPIJobs(ctx *actions.Context) error {
+	jobs, err := api.JobStore{Dir: api.JobsDir}.List()
+	if err != nil {
+		return fmt.Errorf("failed to read jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		ctx.Output.Info("No provisioning jobs")
+		return nil
+	}
+
+	ctx.Output.Printf("%-20s %-8s %-12s %-24s %-10s %s\n", "ID", "STATUS", "TAG", "DOMAIN", "CALLBACK", "RECEIVED")
+	ctx.Output.Separator(100)
+	for _, job := range jobs {
+		callback := "-"
+		switch {
+		case job.Request.CallbackURL == "":
+		case job.Delivered:
+			callback = "sent"
+		case job.Status == api.JobQueued:
+			callback = "pending"
+		case job.Attempts >= api.MaxCallbackAttempts:
+			callback = "failed"
+		default:
+			callback = fmt.Sprintf("retry %d", job.Attempts)
+		}
+		ctx.Output.Printf("%-20s %-8s %-12s %-24s %-10s %s\n", job.Request.ID, job.Status, job.Tag, job.Request.Domain,
+			callback, job.CreatedAt.Local().Format("2006-01-02 15:04"))
+		if job.Error != "" {
+			ctx.Output.Printf("  error: %s\n", job.Error)
+		}
+	}
+	return nil
+}
+
+// HandleAPIProvision creates the tunnels of queued jobs and posts the results
+// to their callback URLs, retrying callbacks that failed on earlier runs. It
+// is run by the provisioning timer.
+func HandleAPIProvision(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	webhook := cfg.API.Webhook
+	if webhook == nil {
+		ctx.Output.Info("The provisioning webhook is not enabled")
+		return nil
+	}
+
+	store := api.JobStore{Dir: api.JobsDir, Chown: system.ChownToDnstm}
+	jobs, err := store.List()
+	if err != nil {
+		return fmt.Errorf("failed to read jobs: %w", err)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	for _, job := range jobs {
+		if job.Status == api.JobQueued {
+			provisionJob(ctx, store, webhook, job)
+		}
+		if !job.NeedsCallback() {
+			continue
+		}
+		job.Attempts++
+		if err := api.SendCallback(ctx.Ctx, client, webhook.Secret, job); err != nil {
+			job.LastError = err.Error()
+			ctx.Output.Warning(fmt.Sprintf("%s: callback failed (attempt %d of %d): %v", job.Request.ID, job.Attempts, api.MaxCallbackAttempts, err))
+		} else {
+			job.Delivered, job.LastError = true, ""
+		}
+		if err := store.Save(job); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("%s: %v", job.Request.ID, err))
+		}
+	}
+	return nil
+}
+
+// provisionJob creates the tunnel of a queued job and records the outcome.
+// The tag is saved before the tunnel is created, so a run interrupted in
+// between picks up the tunnel instead of creating a second one.
+func provisionJob(ctx *actions.Context, store api.JobStore, webhook *config.WebhookConfig, job *api.Job) {
+	req := job.Request
+	err := func() error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		backendTag := req.Backend
+		if backendTag == "" {
+			backendTag = webhook.GetBackend()
+		}
+		backend := cfg.GetBackendByTag(backendTag)
+		if backend == nil {
+			return fmt.Errorf("backend '%s' does not exist", backendTag)
+		}
+		if backend.Type == config.BackendSSH {
+			return fmt.Errorf("SSH backends need per-user credentials and cannot be provisioned by webhook")
+		}
+
+		if job.Tag == "" {
+			tag := req.Tag
+			if tag == "" {
+				tag = router.GenerateUniqueTunnelTag(cfg.Tunnels)
+			}
+			job.Tag = router.NormalizeTag(tag)
+			job.UpdatedAt = time.Now().UTC()
+			if err := store.Save(job); err != nil {
+				return err
+			}
+		}
+
+		tunnelCfg := cfg.GetTunnelByTag(job.Tag)
+		if tunnelCfg == nil {
+			addCtx := &actions.Context{
+				Ctx:    ctx.Ctx,
+				Output: ctx.Output,
+				Values: map[string]interface{}{
+					"tag":         job.Tag,
+					"transport":   req.Transport,
+					"backend":     backendTag,
+					"domain":      req.Domain,
+					"labels":      "provisioned-by=webhook",
+					"description": "Provisioned for webhook request " + req.ID,
+				},
+			}
+			if err := HandleTunnelAdd(addCtx); err != nil {
+				return err
+			}
+			if cfg, err = config.Load(); err != nil {
+				return err
+			}
+			if tunnelCfg = cfg.GetTunnelByTag(job.Tag); tunnelCfg == nil {
+				return fmt.Errorf("tunnel '%s' was not created", job.Tag)
+			}
+		} else if tunnelCfg.Domain != req.Domain {
+			return fmt.Errorf("tag '%s' is already used by another tunnel", job.Tag)
+		}
+
+		client, err := clientcfg.Generate(tunnelCfg, cfg.GetBackendByTag(tunnelCfg.Backend), clientcfg.GenerateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to generate client config: %w", err)
+		}
+		url, err := clientcfg.Encode(client)
+		if err != nil {
+			return fmt.Errorf("failed to encode client config: %w", err)
+		}
+		job.Result = &api.ProvisionResult{
+			Tag:       tunnelCfg.Tag,
+			Domain:    tunnelCfg.Domain,
+			Transport: string(tunnelCfg.Transport),
+			URL:       url,
+			Client:    client,
+		}
+		return nil
+	}()
+
+	job.Status, job.Error = api.JobDone, ""
+	if err != nil {
+		job.Status, job.Error = api.JobFailed, err.Error()
+		ctx.Output.Error(fmt.Sprintf("%s: %v", req.ID, err))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("%s: tunnel '%s' provisioned for %s", req.ID, job.Tag, req.Domain))
+	}
+	job.UpdatedAt = time.Now().UTC()
+	if err := store.Save(job); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("%s: %v", req.ID, err))
+	}
+}
+
+// generateWebhookSecret returns a random secret for signing webhook requests.
+func generateWebhookSecret() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/schedule"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/updater"
+	"github.com/net2share/dnstm/internal/version"
+)
+
+// autoUpdateSettle is how long services get to come back after an upgrade
+// before the health check runs.
+const autoUpdateSettle = 10 * time.Second
+
+func init() {
+	actions.SetAutoUpdateHandler(actions.ActionAutoUpdateEnable, HandleAutoUpdateEnable)
+	actions.SetAutoUpdateHandler(actions.ActionAutoUpdateDisable, HandleAutoUpdateDisable)
+	actions.SetAutoUpdateHandler(actions.ActionAutoUpdateStatus, HandleAutoUpdateStatus)
+	actions.SetAutoUpdateHandler(actions.ActionAutoUpdateRun, HandleAutoUpdateRun)
+}
+
+// HandleAutoUpdateEnable installs the unattended upgrade timer.
+func HandleAutoUpdateEnable(ctx *actions.Context) error {
+	windowStr := ctx.GetString("window")
+	if windowStr == "" {
+		windowStr = updater.DefaultWindow
+	}
+	w, err := schedule.ParseWindow(windowStr)
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Example: --window 03:00-05:00")
+	}
+
+	if err := updater.EnableAutoUpdate(w); err != nil {
+		return fmt.Errorf("failed to enable unattended upgrades: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success(fmt.Sprintf("Unattended upgrades enabled (window %s, local time)", w))
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAutoUpdateDisable removes the unattended upgrade timer.
+func HandleAutoUpdateDisable(ctx *actions.Context) error {
+	if !service.IsTimerInstalled(updater.AutoUpdateName) {
+		ctx.Output.Info("Unattended upgrades are not enabled")
+		return nil
+	}
+
+	if err := updater.DisableAutoUpdate(); err != nil {
+		return fmt.Errorf("failed to disable unattended upgrades: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success("Unattended upgrades disabled")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAutoUpdateStatus shows the unattended upgrade timer state.
+func HandleAutoUpdateStatus(ctx *actions.Context) error {
+	ctx.Output.Println()
+
+	w, ok := updater.AutoUpdateWindow()
+	if !ok || !service.IsTimerInstalled(updater.AutoUpdateName) {
+		ctx.Output.Info("Unattended upgrades are not enabled")
+		ctx.Output.Println("  Enable with: dnstm auto-update enable --window 03:00-05:00")
+		ctx.Output.Println()
+		return nil
+	}
+
+	state := "inactive"
+	if service.IsTimerActive(updater.AutoUpdateName) {
+		state = "active"
+	}
+
+	ctx.Output.Printf("Timer:      %s\n", state)
+	ctx.Output.Printf("Window:     %s (local time)\n", w)
+	if next := service.GetUnitProperty(updater.AutoUpdateName+".timer", "NextElapseUSecRealtime"); next != "" {
+		ctx.Output.Printf("Next run:   %s\n", next)
+	}
+	if last := service.GetUnitProperty(updater.AutoUpdateName+".timer", "LastTriggerUSec"); last != "" && last != "n/a" {
+		result := service.GetUnitProperty(updater.AutoUpdateName+".service", "Result")
+		ctx.Output.Printf("Last run:   %s (%s)\n", last, result)
+	}
+	ctx.Output.Println()
+	ctx.Output.Info("Logs: journalctl -u " + updater.AutoUpdateName)
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleAutoUpdateRun performs an unattended upgrade. Running services are
+// health checked afterwards; if any of them failed, the previous binaries
+// are restored and the services restarted.
+func HandleAutoUpdateRun(ctx *actions.Context) error {
+	if windowStr := ctx.GetString("window"); windowStr != "" {
+		w, err := schedule.ParseWindow(windowStr)
+		if err != nil {
+			return err
+		}
+		if !w.Contains(time.Now()) {
+			ctx.Output.Info(fmt.Sprintf("Outside maintenance window %s, skipping", w))
+			return nil
+		}
+	}
+
+	opts := updater.UpdateOptions{Force: true}
+	report, err := updater.CheckForUpdates(version.Version, opts)
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+	for _, w := range report.Warnings {
+		ctx.Output.Warning(w)
+	}
+	if !report.HasUpdates() {
+		ctx.Output.Status("Everything is up to date")
+		return nil
+	}
+
+	displayUpdateReport(ctx, report)
+	ctx.Output.Println()
+
+	active := activeUpdateServices()
+
+	createAutoSnapshot(ctx, "before unattended update")
+
+	backup, err := updater.BackupFiles(updater.UpdatePaths(report, opts))
+	if err != nil {
+		return err
+	}
+	defer backup.Discard()
+
+	statusFn := func(msg string) { ctx.Output.Status(msg) }
+
+	updateErr := applyUnattendedUpdates(report, statusFn)
+
+	var failed []string
+	if updateErr == nil {
+		ctx.Output.Info("Checking services...")
+		failed = updater.FailedServices(active, autoUpdateSettle)
+		if len(failed) == 0 {
+			ctx.Output.Success("Update completed successfully")
+			return nil
+		}
+	}
+
+	// Roll back to the previous binaries
+	if updateErr != nil {
+		ctx.Output.Error(updateErr.Error())
+	} else {
+		ctx.Output.Error("Services failed after update: " + strings.Join(failed, ", "))
+	}
+	ctx.Output.Info("Rolling back...")
+	if err := backup.Restore(); err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+	for _, name := range active {
+		if err := service.RestartService(name); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if still := updater.FailedServices(active, autoUpdateSettle); len(still) > 0 {
+		return fmt.Errorf("rolled back, but services are still failing: %s", strings.Join(still, ", "))
+	}
+
+	if updateErr != nil {
+		return fmt.Errorf("update failed and was rolled back: %w", updateErr)
+	}
+	return fmt.Errorf("update rolled back: services failed health check: %s", strings.Join(failed, ", "))
+}
+
+// applyUnattendedUpdates installs all available updates. After a dnstm
+// self-update the services run by dnstm itself are restarted so the health
+// check covers the new binary.
+func applyUnattendedUpdates(report *updater.UpdateReport, statusFn updater.StatusFunc) error {
+	if report.DnstmUpdate != nil {
+		if err := updater.PerformSelfUpdate(report.DnstmUpdate.Latest, statusFn); err != nil {
+			return fmt.Errorf("self-update failed: %w", err)
+		}
+		for _, name := range dnstmRunServices() {
+			if err := service.RestartService(name); err != nil {
+				return fmt.Errorf("failed to restart %s: %w", name, err)
+			}
+		}
+	}
+
+	if len(report.BinaryUpdates) > 0 {
+		if err := updater.PerformBinaryUpdates(report.BinaryUpdates, statusFn); err != nil {
+			return fmt.Errorf("binary update failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// activeUpdateServices returns the running services an upgrade can affect.
+func activeUpdateServices() []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, services := range updater.GetAllActiveServices() {
+		for _, name := range services {
+			add(name)
+		}
+	}
+	for _, name := range dnstmRunServices() {
+		add(name)
+	}
+	return names
+}
+
+// dnstmRunServices returns the running services whose process is dnstm itself.
+func dnstmRunServices() []string {
+	var names []string
+	if dnsrouter.NewService().IsActive() {
+		names = append(names, dnsrouter.ServiceName)
+	}
+	if cfg, err := config.Load(); err == nil && cfg.Proxy.Engine == config.ProxyEngineBuiltin && proxy.IsMicrosocksRunning() {
+		names = append(names, proxy.MicrosocksServiceName)
+	}
+	return names
+}
@@ -25,6 +25,13 @@ func HandleBackendAdd(ctx *actions.Context) error {
 	if backendType == "" {
 		return fmt.Errorf("backend type is required")
 	}
+	policy, err := config.LoadPolicy()
+	if err != nil {
+		return err
+	}
+	if !policy.AllowsBackend(backendType) {
+		return policyError(fmt.Errorf("%w: %s backends are not allowed", config.ErrPolicy, config.GetBackendTypeDisplayName(backendType)))
+	}
 
 	tag := ctx.GetString("tag")
 	if tag == "" {
@@ -61,31 +68,50 @@ func HandleBackendAdd(ctx *actions.Context) error {
 		}
 		backend.Address = address
 
-	case config.BackendShadowsocks:
-		password := ctx.GetString("password")
-		if password == "" {
-			password = GeneratePassword()
+	case config.BackendPortForward:
+		address := ctx.GetString("address")
+		if address == "" {
+			return fmt.Errorf("address is required for portforward backend")
+		}
+		backend.Address = address
+		if clientPort := ctx.GetInt("client-port"); clientPort > 0 {
+			backend.PortForward = &config.PortForwardConfig{ClientPort: clientPort}
 		}
 
+	case config.BackendShadowsocks:
 		method := ctx.GetString("method")
 		if method == "" {
 			method = "aes-256-gcm"
 		}
 
+		password := ctx.GetString("password")
+		if password == "" {
+			generated, err := config.GenerateShadowsocksPassword(method, cfg.Secrets)
+			if err != nil {
+				return fmt.Errorf("failed to generate password: %w", err)
+			}
+			password = generated
+		} else if err := config.ValidateShadowsocksPassword(method, password); err != nil {
+			return actions.NewActionError(err.Error(), "Leave out --password to generate one")
+		}
+
 		backend.Shadowsocks = &config.ShadowsocksConfig{
 			Password: password,
 			Method:   method,
 		}
 
 	default:
-		return fmt.Errorf("unknown backend type: %s (use 'shadowsocks' or 'custom')", backendType)
+		return fmt.Errorf("unknown backend type: %s (use 'shadowsocks', 'custom' or 'portforward')", backendType)
 	}
 
-	// Add backend to config
-	cfg.Backends = append(cfg.Backends, backend)
-
-	// Save config
-	if err := cfg.Save(); err != nil {
+	// Add backend to config, unless another invocation took the tag meanwhile
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		if c.GetBackendByTag(tag) != nil {
+			return actions.BackendExistsError(tag)
+		}
+		c.Backends = append(c.Backends, backend)
+		return nil
+	}); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
@@ -111,6 +137,11 @@ func HandleBackendAdd(ctx *actions.Context) error {
 			section.Rows = append(section.Rows,
 				actions.InfoRow{Key: "Address", Value: backend.Address},
 			)
+		case config.BackendPortForward:
+			section.Rows = append(section.Rows,
+				actions.InfoRow{Key: "Address", Value: backend.Address},
+				actions.InfoRow{Key: "Client Port", Value: fmt.Sprintf("%d", backend.GetClientPort())},
+			)
 		}
 
 		infoCfg.Sections = append(infoCfg.Sections, section)
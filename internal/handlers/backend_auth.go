@@ -37,13 +37,14 @@ func HandleBackendAuth(ctx *actions.Context) error {
 
 	if disable {
 		backend.Socks = nil
-		if err := cfg.Save(); err != nil {
+		if err := saveBackend(cfg, backend); err != nil {
 			return fmt.Errorf("failed to save config: %w", err)
 		}
 
-		if err := proxy.ReconfigureMicrosocks(cfg.Proxy.Port, "", ""); err != nil {
-			return fmt.Errorf("failed to reconfigure microsocks: %w", err)
+		if err := proxy.ReconfigureSocks(cfg); err != nil {
+			return fmt.Errorf("failed to reconfigure SOCKS proxy: %w", err)
 		}
+		refreshClientBundles(ctx, cfg, cfg.GetTunnelsUsingBackend(tag))
 
 		ctx.Output.Success("SOCKS5 authentication disabled")
 		return nil
@@ -60,13 +61,14 @@ func HandleBackendAuth(ctx *actions.Context) error {
 		User:     user,
 		Password: password,
 	}
-	if err := cfg.Save(); err != nil {
+	if err := saveBackend(cfg, backend); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
-	if err := proxy.ReconfigureMicrosocks(cfg.Proxy.Port, user, password); err != nil {
-		return fmt.Errorf("failed to reconfigure microsocks: %w", err)
+	if err := proxy.ReconfigureSocks(cfg); err != nil {
+		return fmt.Errorf("failed to reconfigure SOCKS proxy: %w", err)
 	}
+	refreshClientBundles(ctx, cfg, cfg.GetTunnelsUsingBackend(tag))
 
 	ctx.Output.Success(fmt.Sprintf("SOCKS5 authentication enabled (user: %s)", user))
 	return nil
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/egress"
+	"github.com/net2share/dnstm/internal/proxy"
+)
+
+func init() {
+	actions.SetBackendHandler(actions.ActionBackendEgress, HandleBackendEgress)
+}
+
+// HandleBackendEgress sets or clears the destination rules of the SOCKS proxy.
+func HandleBackendEgress(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "backend")
+	if err != nil {
+		return err
+	}
+
+	backend := cfg.GetBackendByTag(tag)
+	if backend == nil {
+		return actions.BackendNotFoundError(tag)
+	}
+
+	if backend.Type != config.BackendSOCKS {
+		return fmt.Errorf("backend '%s' is not a SOCKS backend", tag)
+	}
+
+	var rules []string
+	if !ctx.GetBool("clear") {
+		for _, r := range strings.Split(ctx.GetString("rules"), ";") {
+			if r = strings.TrimSpace(r); r != "" {
+				rules = append(rules, r)
+			}
+		}
+		if len(rules) == 0 {
+			return actions.NewActionError(
+				"no egress rules given",
+				"Use --rules \"deny 10.0.0.0/8; deny 0.0.0.0/0 25\" or --clear",
+			)
+		}
+		if _, err := egress.ParseRules(rules); err != nil {
+			return fmt.Errorf("invalid egress rules: %w", err)
+		}
+	}
+
+	if len(rules) > 0 {
+		backend.Egress = &config.EgressConfig{Rules: rules}
+	} else {
+		backend.Egress = nil
+	}
+	if err := saveBackend(cfg, backend); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if err := proxy.ApplySocksEgress(cfg); err != nil {
+		return fmt.Errorf("failed to apply egress rules: %w", err)
+	}
+	// Rewriting the microsocks unit moves units from before its dedicated
+	// user onto it, so the rules match its connections
+	if cfg.Proxy.IsBuiltinEngine() {
+		if err := proxy.RestartMicrosocks(); err != nil {
+			return fmt.Errorf("failed to restart SOCKS proxy: %w", err)
+		}
+	} else if err := proxy.ReconfigureSocks(cfg); err != nil {
+		return fmt.Errorf("failed to restart SOCKS proxy: %w", err)
+	}
+
+	if len(rules) == 0 {
+		ctx.Output.Success("SOCKS egress rules cleared")
+		return nil
+	}
+
+	ctx.Output.Success(fmt.Sprintf("SOCKS egress rules updated (%d rules)", len(rules)))
+	return nil
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/sshd"
+)
+
+func init() {
+	actions.SetBackendHandler(actions.ActionBackendKeepalive, HandleBackendKeepalive)
+}
+
+// HandleBackendKeepalive sets or clears the keepalive settings of a SOCKS or
+// SSH backend and applies them to the proxy or sshd.
+func HandleBackendKeepalive(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "backend")
+	if err != nil {
+		return err
+	}
+
+	backend := cfg.GetBackendByTag(tag)
+	if backend == nil {
+		return actions.BackendNotFoundError(tag)
+	}
+
+	if backend.Type != config.BackendSOCKS && backend.Type != config.BackendSSH {
+		return fmt.Errorf("backend '%s' does not support keepalive settings", tag)
+	}
+	if backend.Type == config.BackendSOCKS && !cfg.Proxy.IsBuiltinEngine() {
+		return actions.NewActionError(
+			"microsocks has no keepalive options",
+			"Switch to the built-in SOCKS engine with 'dnstm install --socks-engine builtin --force'",
+		)
+	}
+
+	var keepalive *config.KeepaliveConfig
+	if !ctx.GetBool("clear") {
+		keepalive = &config.KeepaliveConfig{
+			Idle:        strings.TrimSpace(ctx.GetString("idle")),
+			Interval:    strings.TrimSpace(ctx.GetString("interval")),
+			Count:       ctx.GetInt("count"),
+			IdleTimeout: strings.TrimSpace(ctx.GetString("idle-timeout")),
+		}
+		if *keepalive == (config.KeepaliveConfig{}) {
+			return actions.NewActionError(
+				"no keepalive settings given",
+				"Use --idle, --interval, --count, --idle-timeout, or --clear",
+			)
+		}
+	}
+	backend.Keepalive = keepalive
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Keepalive: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveBackend(cfg, backend); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	if backend.Type == config.BackendSOCKS {
+		if err := proxy.ReconfigureSocks(cfg); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to reconfigure SOCKS proxy: %w", err))
+		}
+		ctx.Output.Status("SOCKS proxy restarted")
+	} else {
+		if err := sshd.Sync(cfg); errors.Is(err, sshd.ErrNoInclude) {
+			return failProgress(ctx, actions.NewActionError(
+				err.Error(),
+				fmt.Sprintf("Add 'Include /etc/ssh/sshd_config.d/*.conf' at the top of %s and run the command again", sshd.MainConfigPath),
+			))
+		} else if err != nil {
+			return failProgress(ctx, err)
+		}
+		ctx.Output.Status("sshd reloaded")
+	}
+
+	if keepalive == nil {
+		ctx.Output.Success(fmt.Sprintf("Keepalive settings cleared for '%s'", tag))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Backend '%s' now uses keepalive %s", tag, describeKeepalive(keepalive)))
+		if backend.Type == config.BackendSSH {
+			ctx.Output.Info("Existing SSH sessions keep their settings until they reconnect")
+		}
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
+
+// describeKeepalive formats keepalive settings for display.
+func describeKeepalive(k *config.KeepaliveConfig) string {
+	var parts []string
+	if k.Idle != "" {
+		parts = append(parts, "idle "+k.Idle)
+	}
+	if k.Interval != "" {
+		parts = append(parts, "interval "+k.Interval)
+	}
+	if k.Count > 0 {
+		parts = append(parts, fmt.Sprintf("%d probes", k.Count))
+	}
+	if k.IdleTimeout != "" {
+		parts = append(parts, "idle timeout "+k.IdleTimeout)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/proxy"
+)
+
+func init() {
+	actions.SetBackendHandler(actions.ActionBackendOutbound, HandleBackendOutbound)
+}
+
+// HandleBackendOutbound binds a backend's outgoing connections to an
+// interface or source IP and applies the change to its running services.
+func HandleBackendOutbound(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "backend")
+	if err != nil {
+		return err
+	}
+
+	backend := cfg.GetBackendByTag(tag)
+	if backend == nil {
+		return actions.BackendNotFoundError(tag)
+	}
+
+	if backend.Type != config.BackendSOCKS && backend.Type != config.BackendShadowsocks {
+		return fmt.Errorf("backend '%s' does not support outbound binding", tag)
+	}
+
+	var outbound *config.OutboundConfig
+	if !ctx.GetBool("clear") {
+		iface := strings.TrimSpace(ctx.GetString("interface"))
+		sourceIP := strings.TrimSpace(ctx.GetString("source-ip"))
+		if iface == "" && sourceIP == "" {
+			return actions.NewActionError(
+				"no interface or source IP given",
+				"Use --interface, --source-ip, or --clear",
+			)
+		}
+		outbound = &config.OutboundConfig{Interface: iface, SourceIP: sourceIP}
+	}
+	backend.Outbound = outbound
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Outbound Interface: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveBackend(cfg, backend); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	if backend.Type == config.BackendSOCKS {
+		if err := proxy.ReconfigureSocks(cfg); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to reconfigure SOCKS proxy: %w", err))
+		}
+		ctx.Output.Status("SOCKS proxy restarted")
+	} else if err := rebuildBackendTunnels(ctx, cfg, tag); err != nil {
+		return failProgress(ctx, err)
+	}
+
+	if outbound == nil {
+		ctx.Output.Success(fmt.Sprintf("Outbound binding cleared for '%s'", tag))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Backend '%s' now sends traffic via %s", tag, describeOutbound(outbound)))
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
+
+// describeOutbound formats an outbound binding for display.
+func describeOutbound(o *config.OutboundConfig) string {
+	switch {
+	case o.Interface != "" && o.SourceIP != "":
+		return fmt.Sprintf("%s (%s)", o.Interface, o.SourceIP)
+	case o.Interface != "":
+		return o.Interface
+	default:
+		return o.SourceIP
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+)
+
+func init() {
+	actions.SetBackendHandler(actions.ActionBackendPlugin, HandleBackendPlugin)
+}
+
+// HandleBackendPlugin adds, removes and lists the plugin listeners of a
+// Shadowsocks backend and rebuilds the tunnel using it.
+func HandleBackendPlugin(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "backend")
+	if err != nil {
+		return err
+	}
+
+	backend := cfg.GetBackendByTag(tag)
+	if backend == nil {
+		return actions.BackendNotFoundError(tag)
+	}
+	if backend.Type != config.BackendShadowsocks || backend.Shadowsocks == nil {
+		return actions.NewActionError(
+			fmt.Sprintf("backend '%s' is not a Shadowsocks backend", tag),
+			"Plugin listeners are only available for Shadowsocks backends",
+		)
+	}
+
+	plugins := slices.Clone(backend.Shadowsocks.Plugins)
+	name := strings.TrimSpace(ctx.GetString("plugin"))
+	remove := ctx.GetInt("remove")
+
+	var done string
+	switch {
+	case ctx.GetBool("clear"):
+		plugins = nil
+		done = fmt.Sprintf("Plugin listeners removed from '%s'", tag)
+
+	case remove != 0:
+		i := slices.IndexFunc(plugins, func(p config.ShadowsocksPlugin) bool { return p.Port == remove })
+		if i < 0 {
+			return actions.NewActionError(
+				fmt.Sprintf("backend '%s' has no plugin listener on port %d", tag, remove),
+				fmt.Sprintf("See the listeners with 'dnstm backend plugin -t %s'", tag),
+			).WithCode(actions.ExitNotFound)
+		}
+		plugins = slices.Delete(plugins, i, i+1)
+		done = fmt.Sprintf("Plugin listener on port %d removed from '%s'", remove, tag)
+
+	case name != "":
+		p := config.ShadowsocksPlugin{
+			Plugin:  name,
+			Options: strings.TrimSpace(ctx.GetString("opts")),
+			Port:    ctx.GetInt("port"),
+		}
+		if err := config.ValidateShadowsocksPlugin(p); err != nil {
+			return actions.UsageError(err.Error(), "Usage: dnstm backend plugin -t <tag> --plugin v2ray-plugin --port 8443 --opts \"server\"")
+		}
+		// A listener on the same port is replaced
+		if i := slices.IndexFunc(plugins, func(q config.ShadowsocksPlugin) bool { return q.Port == p.Port }); i >= 0 {
+			plugins[i] = p
+		} else {
+			plugins = append(plugins, p)
+		}
+		done = fmt.Sprintf("Backend '%s' now also listens on port %d with %s", tag, p.Port, p.Plugin)
+
+	default:
+		if len(plugins) == 0 {
+			ctx.Output.Info(fmt.Sprintf("Backend '%s' has no plugin listeners", tag))
+			return nil
+		}
+		ctx.Output.Printf("%-6s %-20s %s\n", "PORT", "PLUGIN", "OPTIONS")
+		for _, p := range plugins {
+			ctx.Output.Printf("%-6d %-20s %s\n", p.Port, p.Plugin, p.Options)
+		}
+		return nil
+	}
+
+	if len(plugins) == 0 {
+		plugins = nil
+	}
+	backend.Shadowsocks.Plugins = plugins
+
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Choose a free port, and use the backend for one tunnel only")
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Plugins: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveBackend(cfg, backend); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	for _, p := range plugins {
+		network.AllowTCPPort(p.Port)
+	}
+	if err := rebuildBackendTunnels(ctx, cfg, tag); err != nil {
+		return failProgress(ctx, err)
+	}
+
+	ctx.Output.Success(done)
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
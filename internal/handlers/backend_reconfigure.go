@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetBackendHandler(actions.ActionBackendReconfigure, HandleBackendReconfigure)
+}
+
+// HandleBackendReconfigure updates a backend's address or credentials and
+// rebuilds the services of every tunnel that references it.
+func HandleBackendReconfigure(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "backend")
+	if err != nil {
+		return err
+	}
+
+	backend := cfg.GetBackendByTag(tag)
+	if backend == nil {
+		return actions.BackendNotFoundError(tag)
+	}
+
+	changed := false
+
+	switch backend.Type {
+	case config.BackendSOCKS:
+		return actions.NewActionError(
+			fmt.Sprintf("backend '%s' is the built-in SOCKS proxy", tag),
+			fmt.Sprintf("Use 'dnstm backend auth -t %s' to change its credentials", tag),
+		)
+
+	case config.BackendSSH, config.BackendCustom, config.BackendPortForward:
+		address := ctx.GetString("address")
+		if address != "" && address != backend.Address {
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				return fmt.Errorf("invalid address '%s': %w", address, err)
+			}
+			backend.Address = address
+			changed = true
+		}
+		if backend.Type == config.BackendPortForward {
+			if clientPort := ctx.GetInt("client-port"); clientPort > 0 && clientPort != backend.GetClientPort() {
+				backend.PortForward = &config.PortForwardConfig{ClientPort: clientPort}
+				changed = true
+			}
+		}
+
+	case config.BackendShadowsocks:
+		if backend.Shadowsocks == nil {
+			backend.Shadowsocks = &config.ShadowsocksConfig{}
+		}
+		password := ctx.GetString("password")
+		if password != "" && password != backend.Shadowsocks.Password {
+			backend.Shadowsocks.Password = password
+			changed = true
+		}
+		if method := ctx.GetString("method"); method != "" && method != backend.Shadowsocks.Method {
+			backend.Shadowsocks.Method = method
+			changed = true
+			// 2022 methods need a key of their size; the old password rarely is one
+			if password == "" && config.ValidateShadowsocksPassword(method, backend.Shadowsocks.Password) != nil {
+				generated, err := config.GenerateShadowsocksPassword(method, cfg.Secrets)
+				if err != nil {
+					return err
+				}
+				backend.Shadowsocks.Password = generated
+				ctx.Output.Warning("Generated a new password for " + method + "; share the tunnels of this backend again")
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown backend type: %s", backend.Type)
+	}
+
+	if !changed {
+		ctx.Output.Info(fmt.Sprintf("No changes for backend '%s'", tag))
+		return nil
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Reconfigure Backend: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveBackend(cfg, backend); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	if err := rebuildBackendTunnels(ctx, cfg, tag); err != nil {
+		return failProgress(ctx, err)
+	}
+	refreshClientBundles(ctx, cfg, cfg.GetTunnelsUsingBackend(tag))
+
+	ctx.Output.Success(fmt.Sprintf("Backend '%s' reconfigured", tag))
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
+
+// rebuildBackendTunnels regenerates the services of every tunnel using a backend.
+// Failures for individual tunnels are reported as warnings.
+func rebuildBackendTunnels(ctx *actions.Context, cfg *config.Config, tag string) error {
+	tunnels := cfg.GetTunnelsUsingBackend(tag)
+	if len(tunnels) == 0 {
+		return nil
+	}
+
+	r, err := router.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create router: %w", err)
+	}
+
+	for _, t := range tunnels {
+		if err := r.RegenerateTunnel(t.Tag); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to rebuild tunnel '%s': %v", t.Tag, err))
+			continue
+		}
+		ctx.Output.Status(fmt.Sprintf("Rebuilt tunnel service: %s", t.Tag))
+	}
+
+	return nil
+}
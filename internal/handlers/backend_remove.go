@@ -44,22 +44,32 @@ func HandleBackendRemove(ctx *actions.Context) error {
 		return actions.BackendInUseError(tag, tunnelTags)
 	}
 
-	// Find and remove the backend
-	var newBackends []config.BackendConfig
-	for _, b := range cfg.Backends {
-		if b.Tag != tag {
-			newBackends = append(newBackends, b)
-		}
-	}
-	cfg.Backends = newBackends
-
 	beginProgress(ctx, fmt.Sprintf("Remove Backend: %s", tag))
 	if !ctx.IsInteractive {
 		ctx.Output.Println()
 	}
 
-	// Save config
-	if err := cfg.Save(); err != nil {
+	// Find and remove the backend, unless a tunnel started using it meanwhile
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		if c.GetBackendByTag(tag) == nil {
+			return actions.BackendNotFoundError(tag)
+		}
+		if using := c.GetTunnelsUsingBackend(tag); len(using) > 0 {
+			var tunnelTags []string
+			for _, t := range using {
+				tunnelTags = append(tunnelTags, t.Tag)
+			}
+			return actions.BackendInUseError(tag, tunnelTags)
+		}
+		var newBackends []config.BackendConfig
+		for _, b := range c.Backends {
+			if b.Tag != tag {
+				newBackends = append(newBackends, b)
+			}
+		}
+		c.Backends = newBackends
+		return nil
+	}); err != nil {
 		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
 	}
 
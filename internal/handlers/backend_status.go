@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/proxy"
 	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/usage"
 )
 
 func init() {
@@ -32,6 +35,7 @@ func HandleBackendStatus(ctx *actions.Context) error {
 
 	// Get tunnels using this backend
 	tunnelsUsing := cfg.GetTunnelsUsingBackend(tag)
+	router.PrefetchStates(cfg.Tunnels)
 
 	// Build info config
 	infoCfg := actions.InfoConfig{
@@ -68,6 +72,15 @@ func HandleBackendStatus(ctx *actions.Context) error {
 		infoCfg.Sections = append(infoCfg.Sections, authSection)
 	}
 
+	// Counters of the built-in SOCKS proxy
+	proxyRows := socksStatsRows(cfg, backend)
+	if len(proxyRows) > 0 {
+		infoCfg.Sections = append(infoCfg.Sections, actions.InfoSection{
+			Title: "Connections",
+			Rows:  proxyRows,
+		})
+	}
+
 	// Show shadowsocks config if applicable
 	if backend.Shadowsocks != nil {
 		ssSection := actions.InfoSection{
@@ -80,6 +93,40 @@ func HandleBackendStatus(ctx *actions.Context) error {
 		infoCfg.Sections = append(infoCfg.Sections, ssSection)
 	}
 
+	// Show outbound binding if configured
+	if backend.Outbound != nil {
+		infoCfg.Sections = append(infoCfg.Sections, actions.InfoSection{
+			Title: "Outbound",
+			Rows: []actions.InfoRow{
+				{Key: "Interface", Value: backend.Outbound.Interface},
+				{Key: "Source IP", Value: backend.Outbound.SourceIP},
+			},
+		})
+	}
+
+	if backend.Keepalive != nil {
+		infoCfg.Sections = append(infoCfg.Sections, actions.InfoSection{
+			Title: "Keepalive",
+			Rows: []actions.InfoRow{
+				{Key: "Settings", Value: describeKeepalive(backend.Keepalive)},
+			},
+		})
+	}
+
+	// Show client-side instructions for port forwards
+	if backend.Type == config.BackendPortForward {
+		pfSection := actions.InfoSection{
+			Title: "Port Forward",
+			Rows: []actions.InfoRow{
+				{Key: "Client Port", Value: fmt.Sprintf("%d", backend.GetClientPort())},
+			},
+		}
+		for _, line := range portForwardInstructions(backend, tunnelsUsing) {
+			pfSection.Rows = append(pfSection.Rows, actions.InfoRow{Value: line})
+		}
+		infoCfg.Sections = append(infoCfg.Sections, pfSection)
+	}
+
 	// Show tunnels using this backend
 	tunnelSection := actions.InfoSection{
 		Title: fmt.Sprintf("Tunnels Using This Backend (%d)", len(tunnelsUsing)),
@@ -125,6 +172,14 @@ func HandleBackendStatus(ctx *actions.Context) error {
 		}
 	}
 
+	if len(proxyRows) > 0 {
+		ctx.Output.Println()
+		ctx.Output.Println("Connections:")
+		for _, row := range proxyRows {
+			ctx.Output.Printf("  %-14s %s\n", row.Key+":", row.Value)
+		}
+	}
+
 	if backend.Shadowsocks != nil {
 		ctx.Output.Println()
 		ctx.Output.Println("Shadowsocks Configuration:")
@@ -132,6 +187,27 @@ func HandleBackendStatus(ctx *actions.Context) error {
 		ctx.Output.Printf("  Password: %s\n", backend.Shadowsocks.Password)
 	}
 
+	if backend.Outbound != nil {
+		ctx.Output.Println()
+		ctx.Output.Println("Outbound:")
+		ctx.Output.Printf("  Interface: %s\n", backend.Outbound.Interface)
+		ctx.Output.Printf("  Source IP: %s\n", backend.Outbound.SourceIP)
+	}
+
+	if backend.Keepalive != nil {
+		ctx.Output.Println()
+		ctx.Output.Printf("Keepalive: %s\n", describeKeepalive(backend.Keepalive))
+	}
+
+	if backend.Type == config.BackendPortForward {
+		ctx.Output.Println()
+		ctx.Output.Println("Port Forward:")
+		ctx.Output.Printf("  Client Port: %d\n", backend.GetClientPort())
+		for _, line := range portForwardInstructions(backend, tunnelsUsing) {
+			ctx.Output.Printf("  %s\n", line)
+		}
+	}
+
 	ctx.Output.Println()
 	if len(tunnelsUsing) == 0 {
 		ctx.Output.Println("No tunnels using this backend")
@@ -150,6 +226,41 @@ func HandleBackendStatus(ctx *actions.Context) error {
 	return nil
 }
 
+// socksStatsRows returns the counters the built-in SOCKS proxy saved since
+// it started, for the socks backend while it uses that engine.
+func socksStatsRows(cfg *config.Config, b *config.BackendConfig) []actions.InfoRow {
+	if b.Tag != "socks" || !cfg.Proxy.IsBuiltinEngine() {
+		return nil
+	}
+	st, err := proxy.LoadSocksStats()
+	if err != nil || st == nil || time.Since(st.Updated) >= socksStatsMaxAge {
+		return []actions.InfoRow{{Key: "Counters", Value: "not available (proxy not running)"}}
+	}
+	return []actions.InfoRow{
+		{Key: "Active", Value: fmt.Sprintf("%d", st.ActiveConns)},
+		{Key: "Total", Value: fmt.Sprintf("%d", st.TotalConns)},
+		{Key: "Rejected", Value: fmt.Sprintf("%d", st.RejectedConns)},
+		{Key: "Auth Failures", Value: fmt.Sprintf("%d", st.AuthFailures)},
+		{Key: "Denied", Value: fmt.Sprintf("%d", st.DeniedDests)},
+		{Key: "Dial Failures", Value: fmt.Sprintf("%d", st.DialFailures)},
+		{Key: "Traffic", Value: fmt.Sprintf("%s in, %s out", usage.FormatBytes(st.BytesIn), usage.FormatBytes(st.BytesOut))},
+		{Key: "Updated", Value: st.Updated.Format("2006-01-02 15:04:05")},
+	}
+}
+
+// portForwardInstructions returns client-side steps for reaching a published service.
+func portForwardInstructions(b *config.BackendConfig, tunnels []*config.TunnelConfig) []string {
+	if len(tunnels) == 0 {
+		return []string{"Add a tunnel with this backend to publish the service"}
+	}
+	var lines []string
+	for _, t := range tunnels {
+		lines = append(lines, fmt.Sprintf("Via %s: run 'dnstm tunnel share -t %s', import the URL on the client, then connect to 127.0.0.1:%d to reach %s",
+			t.Tag, t.Tag, b.GetClientPort(), b.Address))
+	}
+	return lines
+}
+
 func getBackendAddress(b *config.BackendConfig) string {
 	if b.Type == config.BackendShadowsocks {
 		return "[SIP003 plugin mode]"
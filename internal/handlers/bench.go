@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/bench"
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/clientcfg"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+// benchProbeTimeout bounds each latency probe; transfers get ten times as long.
+const benchProbeTimeout = 10 * time.Second
+
+func init() {
+	actions.SetBenchHandler(actions.ActionBench, HandleBench)
+}
+
+// HandleBench benchmarks a tunnel, or shows recorded results.
+func HandleBench(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.GetBool("history") {
+		return showBenchHistory(ctx, ctx.GetString("tag"))
+	}
+
+	tag := ctx.GetString("tag")
+	if tag == "" {
+		return actions.NewActionError("tunnel tag required", "Usage: dnstm bench -t <tag>")
+	}
+	tunnel := cfg.GetTunnelByTag(tag)
+	if tunnel == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+	if !tunnel.Transport.IsDNS() {
+		return actions.NewActionError(fmt.Sprintf("tunnel '%s' does not use DNS", tag), "Only DNS tunnels can be benchmarked")
+	}
+	backend := cfg.GetBackendByTag(tunnel.Backend)
+	if backend == nil {
+		return actions.BackendNotFoundError(tunnel.Backend)
+	}
+	if backend.Type != config.BackendSOCKS && backend.Type != config.BackendSSH {
+		return actions.NewActionError(
+			fmt.Sprintf("%s backends cannot be benchmarked", backend.Type),
+			"Benchmark a tunnel with a socks or ssh backend",
+		)
+	}
+	if !router.NewTunnel(tunnel).IsActive() {
+		return actions.NewActionError(fmt.Sprintf("tunnel '%s' is not running", tag), "Start it with: dnstm tunnel start -t "+tag)
+	}
+
+	// Without a resolver, query the tunnel server directly
+	resolver := fmt.Sprintf("127.0.0.1:%d", tunnel.Port)
+	if r := ctx.GetString("resolver"); r != "" {
+		parsed, err := network.ParseResolvers(r)
+		if err != nil || len(parsed) != 1 {
+			return actions.NewActionError(fmt.Sprintf("invalid resolver '%s'", r), "Example: --resolver 8.8.8.8")
+		}
+		resolver = parsed[0].Addr
+	}
+
+	opts := bench.Options{
+		Rounds:  ctx.GetInt("rounds"),
+		Size:    int64(ctx.GetInt("size")) << 10,
+		Timeout: benchProbeTimeout,
+	}
+	if opts.Rounds <= 0 {
+		opts.Rounds = 10
+	}
+	if opts.Size < 0 {
+		opts.Size = 0
+	}
+
+	cc, err := clientcfg.Generate(tunnel, backend, clientcfg.GenerateOptions{})
+	if err != nil {
+		return err
+	}
+
+	beginProgress(ctx, "Benchmark: "+tag)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	certPath := ""
+	if cc.Transport.Cert != "" {
+		dir, err := os.MkdirTemp("", "dnstm-bench-")
+		if err != nil {
+			return failProgress(ctx, err)
+		}
+		defer os.RemoveAll(dir)
+		certPath = filepath.Join(dir, "cert.pem")
+		if err := os.WriteFile(certPath, []byte(cc.Transport.Cert), 0600); err != nil {
+			return failProgress(ctx, err)
+		}
+	}
+
+	port, err := bench.FreePort()
+	if err != nil {
+		return failProgress(ctx, err)
+	}
+	binType, args, err := bench.ClientCommand(cc, resolver, port, certPath)
+	if err != nil {
+		return failProgress(ctx, err)
+	}
+	ctx.Output.Status(fmt.Sprintf("Installing %s...", binType))
+	clientPath, err := binary.NewDefaultManager().EnsureInstalled(binType)
+	if err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to install %s: %w", binType, err))
+	}
+
+	ctx.Output.Status(fmt.Sprintf("Starting %s through %s...", binType, resolver))
+	client, err := bench.StartClient(clientPath, args, port, 15*time.Second)
+	if err != nil {
+		return failProgress(ctx, err)
+	}
+	defer client.Stop()
+
+	result := &bench.Result{
+		Time:      time.Now().UTC(),
+		Tag:       tag,
+		Transport: string(tunnel.Transport),
+		MTU:       tunnel.GetMTU(),
+		Resolver:  resolver,
+		Note:      ctx.GetString("note"),
+	}
+	ctx.Output.Status(fmt.Sprintf("Timing %d connections...", opts.Rounds))
+	if opts.Size > 0 && backend.Type == config.BackendSOCKS {
+		ctx.Output.Status(fmt.Sprintf("Transferring %d KiB each way...", opts.Size>>10))
+	}
+	warnings, err := bench.Measure(client.Addr(), cc.Backend, opts, result)
+	if err != nil {
+		return failProgress(ctx, actions.NewActionError(err.Error(), "Check the tunnel with: dnstm tunnel status -t "+tag).WithCode(actions.ExitDNSCheckFailed))
+	}
+	for _, w := range warnings {
+		ctx.Output.Warning(w)
+	}
+
+	if err := bench.Append(result); err != nil {
+		ctx.Output.Warning("Result not recorded: " + err.Error())
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Printf("  Latency:  min %.0f ms, avg %.0f ms, max %.0f ms (%d/%d answered)\n",
+		result.RTTMin, result.RTTAvg, result.RTTMax, result.Probes-result.Lost, result.Probes)
+	if result.Bytes > 0 {
+		ctx.Output.Printf("  Download: %s\n", bench.FormatRate(result.DownRate))
+		ctx.Output.Printf("  Upload:   %s\n", bench.FormatRate(result.UpRate))
+	}
+	ctx.Output.Println()
+	ctx.Output.Success("Benchmark recorded. Compare runs with: dnstm bench --history")
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+	return nil
+}
+
+// showBenchHistory prints the recorded results of one or all tunnels.
+func showBenchHistory(ctx *actions.Context, tag string) error {
+	results, err := bench.History(tag)
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if len(results) == 0 {
+		ctx.Output.Info("No benchmark results recorded")
+		ctx.Output.Println("  Run one with: dnstm bench -t <tag>")
+		ctx.Output.Println()
+		return nil
+	}
+
+	ctx.Output.Printf("%-16s %-12s %-10s %5s %-21s %8s %6s %12s %12s  %s\n",
+		"TIME", "TAG", "TRANSPORT", "MTU", "RESOLVER", "RTT", "LOSS", "DOWN", "UP", "NOTE")
+	ctx.Output.Separator(120)
+	for _, r := range results {
+		mtu := "-"
+		if r.MTU > 0 {
+			mtu = strconv.Itoa(r.MTU)
+		}
+		ctx.Output.Printf("%-16s %-12s %-10s %5s %-21s %6.0fms %5d%% %12s %12s  %s\n",
+			r.Time.Local().Format("2006-01-02 15:04"), r.Tag, r.Transport, mtu, r.Resolver,
+			r.RTTAvg, 100*r.Lost/max(r.Probes, 1), bench.FormatRate(r.DownRate), bench.FormatRate(r.UpRate), r.Note)
+	}
+	ctx.Output.Println()
+	return nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/bootstrap"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/presets"
+	"github.com/net2share/dnstm/internal/version"
+)
+
+func init() {
+	actions.SetBootstrapHandler(actions.ActionBootstrapGenerate, HandleBootstrapGenerate)
+}
+
+// HandleBootstrapGenerate renders a provisioning script for a new server.
+func HandleBootstrapGenerate(ctx *actions.Context) error {
+	manifestPath := ctx.GetString("manifest")
+	presetName := ctx.GetString("preset")
+	domain := ctx.GetString("domain")
+
+	if manifestPath == "" && presetName == "" {
+		return actions.NewActionError("--manifest or --preset is required",
+			"Usage: dnstm bootstrap generate --manifest config.json | --preset NAME -d DOMAIN")
+	}
+	if manifestPath != "" && presetName != "" {
+		return actions.NewActionError("--manifest and --preset cannot be combined", "")
+	}
+
+	opts := bootstrap.Options{
+		Format:      ctx.GetString("format"),
+		Version:     ctx.GetString("version"),
+		Mode:        ctx.GetString("mode"),
+		SocksEngine: ctx.GetString("socks-engine"),
+	}
+	if opts.Version == "" && version.Version != "dev" {
+		opts.Version = version.Version
+	}
+
+	if manifestPath != "" {
+		data, err := os.ReadFile(manifestPath)
+		if err != nil {
+			return actions.NewActionError(
+				fmt.Sprintf("failed to read manifest: %v", err),
+				"Please provide a valid config.json file path",
+			)
+		}
+
+		cfg, err := config.LoadFromPath(manifestPath)
+		if err != nil {
+			return fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		cfg.EnsureBuiltinBackends()
+		if err := cfg.Validate(); err != nil {
+			return actions.NewActionError(
+				fmt.Sprintf("invalid manifest: %v", err),
+				"Check it with: dnstm config validate "+manifestPath,
+			)
+		}
+
+		opts.Manifest = data
+		for _, t := range cfg.Tunnels {
+			b := cfg.GetBackendByTag(t.Backend)
+			opts.Tunnels = append(opts.Tunnels, bootstrap.Tunnel{
+				Tag: t.Tag,
+				SSH: b != nil && b.Type == config.BackendSSH,
+			})
+		}
+	} else {
+		p, err := presets.Get(presetName)
+		if err != nil {
+			return actions.NewActionError(err.Error(), "")
+		}
+		if domain == "" {
+			return actions.NewActionError("--domain is required with --preset",
+				"Usage: dnstm bootstrap generate --preset "+presetName+" -d example.com")
+		}
+
+		opts.Preset = p.Name
+		opts.Domain = domain
+		for _, t := range p.Tunnels {
+			opts.Tunnels = append(opts.Tunnels, bootstrap.Tunnel{
+				Tag: t.Tag,
+				SSH: t.Backend == "ssh",
+			})
+		}
+	}
+
+	out, err := bootstrap.Generate(opts)
+	if err != nil {
+		return actions.NewActionError(err.Error(), "")
+	}
+
+	outputFile := ctx.GetString("output")
+	if outputFile == "" {
+		fmt.Print(out)
+		return nil
+	}
+
+	// The script may carry backend passwords from the manifest
+	if err := os.WriteFile(outputFile, []byte(out), 0600); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	ctx.Output.Success(fmt.Sprintf("Bootstrap %s written to %s", opts.Format, outputFile))
+	return nil
+}
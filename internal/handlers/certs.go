@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/certs"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/keys"
+)
+
+func init() {
+	actions.SetCertsHandler(actions.ActionCertsHistory, HandleCertsHistory)
+}
+
+// HandleCertsHistory shows the fingerprints and public keys a domain served,
+// or checks one a client reports against them.
+func HandleCertsHistory(ctx *actions.Context) error {
+	domain := ctx.GetArg(0)
+	if domain == "" {
+		return actions.UsageError("domain required", "Usage: dnstm certs history <domain>")
+	}
+
+	entries, err := certs.History(domain)
+	if err != nil {
+		return err
+	}
+
+	if check := ctx.GetString("check"); check != "" {
+		return checkKeyHistory(ctx, domain, entries, check)
+	}
+
+	if len(entries) == 0 {
+		ctx.Output.Info(fmt.Sprintf("No fingerprints or keys recorded for %s", domain))
+		return nil
+	}
+
+	current := currentHistoryEntries(entries)
+	ctx.Output.Println()
+	ctx.Output.Printf("%-7s %-20s %-20s %-15s %s\n", "KIND", "FIRST SEEN", "LAST SEEN", "TUNNEL", "VALUE")
+	ctx.Output.Separator(100)
+	for i, e := range entries {
+		value := formatKeyValue(e)
+		if current[i] {
+			value += " (current)"
+		}
+		ctx.Output.Printf("%-7s %-20s %-20s %-15s %s\n", e.Kind,
+			e.FirstSeen.Local().Format("2006-01-02 15:04"), e.LastSeen.Local().Format("2006-01-02 15:04"), e.Tunnel, value)
+	}
+	ctx.Output.Println()
+	return nil
+}
+
+// checkKeyHistory tells where a fingerprint or key a client reports comes
+// from.
+func checkKeyHistory(ctx *actions.Context, domain string, entries []certs.HistoryEntry, check string) error {
+	value := certs.NormalizeKeyValue(check)
+	current := currentHistoryEntries(entries)
+	for i, e := range entries {
+		if e.Value != value {
+			continue
+		}
+		if current[i] {
+			ctx.Output.Success(fmt.Sprintf("%s is the current %s of %s, served since %s",
+				formatKeyValue(e), e.Kind, domain, e.FirstSeen.Local().Format("2006-01-02 15:04")))
+			return nil
+		}
+		ctx.Output.Warning(fmt.Sprintf("%s was the %s of %s from %s to %s; it has been rotated since",
+			formatKeyValue(e), e.Kind, domain, e.FirstSeen.Local().Format("2006-01-02 15:04"), e.LastSeen.Local().Format("2006-01-02 15:04")))
+		ctx.Output.Info(fmt.Sprintf("The client has an old config; share the tunnel again with 'dnstm tunnel share -t %s'", e.Tunnel))
+		return nil
+	}
+
+	// A value of another domain points at a mixed-up client config
+	all, err := certs.LoadHistory()
+	if err != nil {
+		return err
+	}
+	for _, e := range all {
+		if e.Value == value {
+			ctx.Output.Warning(fmt.Sprintf("%s was issued for %s (tunnel '%s'), not %s", formatKeyValue(e), e.Domain, e.Tunnel, domain))
+			ctx.Output.Info("The client uses the config of another tunnel")
+			return nil
+		}
+	}
+
+	return actions.NewActionError(
+		fmt.Sprintf("%s was never issued for %s by this server", check, domain),
+		"Something else may be answering the client's queries; check the NS records and the client's resolver",
+	).WithCode(actions.ExitNotFound)
+}
+
+// currentHistoryEntries marks the latest entry of each kind, which is what
+// the domain serves now.
+func currentHistoryEntries(entries []certs.HistoryEntry) map[int]bool {
+	latest := make(map[string]int)
+	for i, e := range entries {
+		latest[e.Kind] = i
+	}
+	current := make(map[int]bool)
+	for _, i := range latest {
+		current[i] = true
+	}
+	return current
+}
+
+// formatKeyValue formats a history value for display.
+func formatKeyValue(e certs.HistoryEntry) string {
+	if e.Kind == certs.KindCert {
+		return certs.FormatFingerprint(e.Value)
+	}
+	return e.Value
+}
+
+// recordKeyHistory records the certificate fingerprint or public key the
+// clients of a tunnel are given.
+func recordKeyHistory(t *config.TunnelConfig) error {
+	dir := filepath.Join(config.TunnelsDir, t.Tag)
+
+	var kind, value string
+	var err error
+	switch t.Transport {
+	case config.TransportSlipstream, config.TransportChisel:
+		certPath := filepath.Join(dir, "cert.pem")
+		if t.Slipstream != nil && t.Slipstream.Cert != "" {
+			certPath = t.Slipstream.Cert
+		}
+		if t.Chisel != nil && t.Chisel.Cert != "" {
+			certPath = t.Chisel.Cert
+		}
+		kind = certs.KindCert
+		value, err = certs.ReadCertificateFingerprint(certPath)
+	case config.TransportDNSTT, config.TransportVayDNS:
+		kind = certs.KindPubKey
+		value, err = keys.ReadPublicKey(filepath.Join(dir, "server.pub"))
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the %s of '%s': %w", kind, t.Tag, err)
+	}
+	return certs.Record(t.Domain, t.Tag, kind, value, time.Now())
+}
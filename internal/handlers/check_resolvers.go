@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+)
+
+// defaultProbeTimeout is how long to wait for each resolver.
+const defaultProbeTimeout = 5 * time.Second
+
+func init() {
+	actions.SetCheckHandler(actions.ActionCheckResolvers, HandleCheckResolvers)
+}
+
+// HandleCheckResolvers probes every DNS tunnel domain through public resolvers.
+func HandleCheckResolvers(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tunnels []*config.TunnelConfig
+	if tag := ctx.GetString("tag"); tag != "" {
+		t := cfg.GetTunnelByTag(tag)
+		if t == nil {
+			return actions.TunnelNotFoundError(tag)
+		}
+		if !t.Transport.IsDNS() {
+			return actions.NewActionError(fmt.Sprintf("tunnel '%s' does not use DNS", tag), "Only DNS tunnels are reached through resolvers")
+		}
+		tunnels = append(tunnels, t)
+	} else {
+		for i := range cfg.Tunnels {
+			if cfg.Tunnels[i].Transport.IsDNS() {
+				tunnels = append(tunnels, &cfg.Tunnels[i])
+			}
+		}
+	}
+	if len(tunnels) == 0 {
+		return actions.NewActionError("no DNS tunnels to check", "Add one with: dnstm tunnel add")
+	}
+
+	resolvers := network.DefaultResolvers
+	if list := ctx.GetString("resolvers"); list != "" {
+		if resolvers, err = network.ParseResolvers(list); err != nil {
+			return actions.NewActionError(err.Error(), "Example: --resolvers 8.8.8.8,1.1.1.1,9.9.9.9:53")
+		}
+	}
+	timeout := defaultProbeTimeout
+	if n := ctx.GetInt("timeout"); n > 0 {
+		timeout = time.Duration(n) * time.Second
+	}
+
+	beginProgress(ctx, "Resolver Check")
+	ctx.Output.Println()
+	ctx.Output.Info(fmt.Sprintf("Probing %d domain(s) through %d resolvers...", len(tunnels), len(resolvers)))
+
+	// Probe everything at once; each probe is a single UDP exchange
+	results := make([][]network.ProbeResult, len(tunnels))
+	var wg sync.WaitGroup
+	for i, t := range tunnels {
+		results[i] = make([]network.ProbeResult, len(resolvers))
+		for j, r := range resolvers {
+			wg.Add(1)
+			go func(i, j int, r network.PublicResolver, domain string) {
+				defer wg.Done()
+				results[i][j] = network.ProbeResolver(r, domain, timeout)
+			}(i, j, r, t.Domain)
+		}
+	}
+	wg.Wait()
+
+	var unreachable []string
+	for i, t := range tunnels {
+		ctx.Output.Println()
+		ctx.Output.Printf("%s (%s)\n", t.Domain, t.Tag)
+		ctx.Output.Printf("%-14s %-22s %-14s %s\n", "RESOLVER", "ADDRESS", "STATUS", "RTT")
+		ctx.Output.Separator(60)
+
+		var fastest *network.ProbeResult
+		reached := 0
+		for j := range results[i] {
+			r := &results[i][j]
+			rtt := "-"
+			if r.Reachable() {
+				reached++
+				rtt = r.RTT.Round(time.Millisecond).String()
+				if fastest == nil || r.RTT < fastest.RTT {
+					fastest = r
+				}
+			}
+			ctx.Output.Printf("%-14s %-22s %-14s %s\n", r.Resolver.Name, r.Resolver.Addr, r.Status, rtt)
+		}
+
+		ctx.Output.Println()
+		if fastest == nil {
+			ctx.Output.Warning(fmt.Sprintf("No resolver reaches %s", t.Domain))
+			unreachable = append(unreachable, t.Domain)
+			continue
+		}
+		ctx.Output.Success(fmt.Sprintf("%d/%d resolvers reach %s; fastest: %s (%s)",
+			reached, len(resolvers), t.Domain, fastest.Resolver.Name, fastest.RTT.Round(time.Millisecond)))
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+	if len(unreachable) > 0 {
+		return actions.NewActionError(
+			fmt.Sprintf("not reachable through any resolver: %s", strings.Join(unreachable, ", ")),
+			"Check the NS delegation of the domain and that port 53 is open: dnstm router status",
+		).WithCode(actions.ExitDNSCheckFailed)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/prompt"
+	"github.com/net2share/go-corelib/tui"
+)
+
+func init() {
+	actions.SetConfigHandler(actions.ActionConfigEdit, HandleConfigEdit)
+}
+
+// HandleConfigEdit opens the configuration in an editor, validates the
+// result and deploys it, going back to the previous configuration when the
+// deploy fails.
+func HandleConfigEdit(ctx *actions.Context) error {
+	if err := CheckRequirements(ctx, true, false); err != nil {
+		return err
+	}
+	oldCfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if prompt.IsHeadless() {
+		return actions.NewActionError("config edit needs a terminal",
+			"Export the config with 'dnstm config export -o <file>', edit it, and deploy it with 'dnstm config load <file>'")
+	}
+
+	data, err := json.MarshalIndent(oldCfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	// The config holds secrets; CreateTemp makes the copy readable by root only
+	f, err := os.CreateTemp("", "dnstm-config-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	path := f.Name()
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	kept := "Your edits are kept in " + path
+
+	var newCfg *config.Config
+	var changes []config.Change
+	for {
+		if err := runEditor(path); err != nil {
+			return actions.NewActionError(err.Error(), "Set $EDITOR to an installed editor. "+kept)
+		}
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read edited config: %w", err)
+		}
+		if bytes.Equal(bytes.TrimSpace(edited), data) {
+			os.Remove(path)
+			ctx.Output.Info("No changes")
+			return nil
+		}
+
+		newCfg, err = checkEditedConfig(path, oldCfg)
+		if err == nil {
+			changes, err = config.Diff(oldCfg, newCfg)
+		}
+		if err == nil {
+			break
+		}
+		ctx.Output.Println()
+		ctx.Output.Error(err.Error())
+		again, perr := prompt.RunConfirm(tui.ConfirmConfig{
+			Title:       "Edit again?",
+			Description: "The edited configuration is invalid. Otherwise the current configuration is kept.",
+			Default:     true,
+		})
+		if perr != nil {
+			return perr
+		}
+		if !again {
+			ctx.Output.Info("The configuration is unchanged. " + kept)
+			return actions.ErrCancelled
+		}
+	}
+
+	if len(changes) == 0 {
+		os.Remove(path)
+		ctx.Output.Info("No changes")
+		return nil
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Info("Changes:")
+	for _, c := range changes {
+		mark := "~"
+		switch c.Kind {
+		case config.ChangeAdded:
+			mark = "+"
+		case config.ChangeRemoved:
+			mark = "-"
+		}
+		ctx.Output.Printf("  %s %s\n", mark, c)
+		if c.Section == "tunnel" && c.Kind == config.ChangeModified {
+			newCfg.GetTunnelByTag(c.Tag).MarkModified()
+		}
+	}
+	ctx.Output.Println()
+	ctx.Output.Info("Deploying recreates every tunnel service and restarts the router.")
+
+	confirm, err := prompt.RunConfirm(tui.ConfirmConfig{
+		Title: "Deploy these changes?",
+	})
+	if err != nil {
+		return err
+	}
+	if !confirm {
+		ctx.Output.Info("The configuration is unchanged. " + kept)
+		return actions.ErrCancelled
+	}
+
+	ctx.Output.Println()
+	createAutoSnapshot(ctx, "before config edit")
+
+	// Deploying removes the tunnel directories; the keys and certificates of
+	// the tunnels that stay are put back before their services are created
+	dirs, err := setTunnelDirsAside()
+	if err != nil {
+		return actions.NewActionError(fmt.Sprintf("failed to keep tunnel keys: %v", err), kept)
+	}
+	defer dirs.remove()
+
+	deployErr := deployConfig(ctx, newCfg, dirs.restore(newCfg))
+	if deployErr == nil {
+		os.Remove(path)
+		return nil
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Error(fmt.Sprintf("Deploy failed: %v", deployErr))
+	ctx.Output.Info("Deploying the previous configuration...")
+	rollbackErr := dirs.moveAside()
+	if rollbackErr == nil {
+		rollbackErr = deployConfig(ctx, oldCfg, dirs.restore(oldCfg))
+	}
+	if rollbackErr != nil {
+		return actions.NewActionError(
+			fmt.Sprintf("deploy failed: %v; restoring the previous configuration failed: %v", deployErr, rollbackErr),
+			"Restore it with 'dnstm snapshot rollback latest'. "+kept,
+		)
+	}
+	return actions.NewActionError(
+		fmt.Sprintf("deploy failed: %v", deployErr),
+		"The previous configuration was restored. "+kept,
+	)
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, or vi, and waits for it.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The variables may hold arguments, such as "code --wait"
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// checkEditedConfig parses and validates an edited config the way a load
+// would.
+func checkEditedConfig(path string, oldCfg *config.Config) (*config.Config, error) {
+	cfg, err := config.LoadFromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	cfg.EnsureBuiltinBackends()
+	cfg.InheritMeta(oldCfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	policy, err := config.LoadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Check(cfg); err != nil {
+		return nil, fmt.Errorf("policy error: %w", err)
+	}
+	return cfg, nil
+}
+
+// tunnelDirs holds the tunnel directories, with their keys and
+// certificates, moved next to TunnelsDir while a config is deployed.
+type tunnelDirs struct {
+	aside string
+}
+
+// setTunnelDirsAside moves every tunnel directory aside.
+func setTunnelDirsAside() (*tunnelDirs, error) {
+	aside, err := os.MkdirTemp(filepath.Dir(config.TunnelsDir), ".tunnels-")
+	if err != nil {
+		return nil, err
+	}
+	d := &tunnelDirs{aside: aside}
+	if err := d.moveAside(); err != nil {
+		d.remove()
+		return nil, err
+	}
+	return d, nil
+}
+
+// moveAside moves the directories in TunnelsDir aside.
+func (d *tunnelDirs) moveAside() error {
+	entries, err := os.ReadDir(config.TunnelsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		src, dst := filepath.Join(config.TunnelsDir, e.Name()), filepath.Join(d.aside, e.Name())
+		// A directory already aside holds the original keys
+		if _, err := os.Stat(dst); err == nil {
+			os.RemoveAll(src)
+			continue
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore returns the install step of deployConfig that moves back the
+// directories the tunnels of cfg use: their own and those their crypto
+// paths point into.
+func (d *tunnelDirs) restore(cfg *config.Config) func() error {
+	return func() error {
+		if err := os.MkdirAll(config.TunnelsDir, 0750); err != nil {
+			return fmt.Errorf("failed to create tunnels directory: %w", err)
+		}
+		names := make(map[string]bool)
+		for _, t := range cfg.Tunnels {
+			names[t.Tag] = true
+			for _, p := range tunnelCryptoPaths(&t) {
+				if dir := filepath.Dir(p); filepath.Dir(dir) == config.TunnelsDir {
+					names[filepath.Base(dir)] = true
+				}
+			}
+		}
+		for name := range names {
+			src := filepath.Join(d.aside, name)
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if err := os.Rename(src, filepath.Join(config.TunnelsDir, name)); err != nil {
+				return fmt.Errorf("failed to restore keys of %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// remove deletes the directories left aside, of tunnels no longer deployed.
+func (d *tunnelDirs) remove() {
+	os.RemoveAll(d.aside)
+}
+
+// tunnelCryptoPaths returns the key and certificate paths set on t.
+func tunnelCryptoPaths(t *config.TunnelConfig) []string {
+	var paths []string
+	if t.Slipstream != nil {
+		paths = append(paths, t.Slipstream.Cert, t.Slipstream.Key)
+	}
+	if t.Chisel != nil {
+		paths = append(paths, t.Chisel.Cert, t.Chisel.Key)
+	}
+	if t.DNSTT != nil {
+		paths = append(paths, t.DNSTT.PrivateKey)
+	}
+	if t.VayDNS != nil {
+		paths = append(paths, t.VayDNS.PrivateKey)
+	}
+	return paths
+}
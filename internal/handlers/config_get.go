@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+)
+
+func init() {
+	actions.SetConfigHandler(actions.ActionConfigGet, HandleConfigGet)
+}
+
+// HandleConfigGet prints one setting of the configuration.
+func HandleConfigGet(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := ctx.GetArg(0)
+	if path == "" {
+		return actions.UsageError("path required", "Usage: dnstm config get <path>")
+	}
+	raw, err := cfg.GetPath(path)
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Example: dnstm config get tunnels[tag=main].dnstt.mtu")
+	}
+
+	// Unset settings print nothing, text without quotes
+	if raw == nil {
+		return nil
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		fmt.Println(text)
+		return nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "", "  "); err != nil {
+		return fmt.Errorf("failed to format setting: %w", err)
+	}
+	fmt.Println(out.String())
+	return nil
+}
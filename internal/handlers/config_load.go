@@ -8,12 +8,17 @@ import (
 	"strings"
 
 	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/api"
 	"github.com/net2share/dnstm/internal/certs"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
 	"github.com/net2share/dnstm/internal/installer"
 	"github.com/net2share/dnstm/internal/keys"
+	"github.com/net2share/dnstm/internal/logship"
+	"github.com/net2share/dnstm/internal/metrics"
 	"github.com/net2share/dnstm/internal/proxy"
 	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/sshd"
 	"github.com/net2share/dnstm/internal/system"
 	"github.com/net2share/dnstm/internal/transport"
 )
@@ -82,13 +87,38 @@ func HandleConfigLoad(ctx *actions.Context) error {
 		}
 	}
 
+	return deployConfig(ctx, newCfg, nil)
+}
+
+// deployConfig replaces the running setup with newCfg: it validates the
+// config, removes the existing tunnels, creates the services and starts the
+// router. install, when set, runs after the old tunnel directories are
+// removed, to put crypto material in place before services are created.
+func deployConfig(ctx *actions.Context, newCfg *config.Config, install func() error) error {
 	// Add built-in backends before validation so users can reference them
 	newCfg.EnsureBuiltinBackends()
 
+	// Keep the description, notes and history of tunnels that stay
+	oldCfg, _ := config.Load()
+	newCfg.InheritMeta(oldCfg)
+
+	// Apply defaults before anything is torn down, so a full port range
+	// leaves the running setup alone
+	if err := newCfg.ApplyDefaults(); err != nil {
+		return portRangeError(err)
+	}
+
 	// Validate the configuration
 	if err := newCfg.Validate(); err != nil {
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
+	policy, err := config.LoadPolicy()
+	if err != nil {
+		return err
+	}
+	if err := policy.Check(newCfg); err != nil {
+		return policyError(err)
+	}
 
 	ctx.Output.Status("Configuration validated")
 
@@ -107,8 +137,11 @@ func HandleConfigLoad(ctx *actions.Context) error {
 	}
 	ctx.Output.Status("Cleanup complete")
 
-	// Apply defaults
-	newCfg.ApplyDefaults()
+	if install != nil {
+		if err := install(); err != nil {
+			return err
+		}
+	}
 
 	// Save to the system config location
 	if err := newCfg.Save(); err != nil {
@@ -117,18 +150,13 @@ func HandleConfigLoad(ctx *actions.Context) error {
 
 	ctx.Output.Status("Configuration saved to " + config.GetConfigPath())
 
-	// Reconfigure microsocks with port and auth from loaded config
-	if proxy.IsMicrosocksInstalled() {
+	// Reconfigure the SOCKS proxy with port and auth from loaded config
+	if proxy.IsSocksAvailable(newCfg.Proxy) {
 		port := newCfg.Proxy.Port
 		if port == 0 {
 			port = 1080
 		}
-		var socksUser, socksPass string
-		if socksBackend := newCfg.GetBackendByTag("socks"); socksBackend != nil && socksBackend.HasSocksAuth() {
-			socksUser = socksBackend.Socks.User
-			socksPass = socksBackend.Socks.Password
-		}
-		if err := proxy.ConfigureMicrosocksWithAuth(port, socksUser, socksPass); err != nil {
+		if err := proxy.ConfigureSocks(newCfg); err != nil {
 			ctx.Output.Warning(fmt.Sprintf("Failed to reconfigure microsocks: %v", err))
 		} else {
 			if err := proxy.RestartMicrosocks(); err != nil {
@@ -150,7 +178,36 @@ func HandleConfigLoad(ctx *actions.Context) error {
 			} else {
 				ctx.Output.Status(fmt.Sprintf("Service created for %s", tunnelCfg.Tag))
 			}
+			if err := router.NewTunnel(tunnelCfg).SyncScheduleTimer(); err != nil {
+				ctx.Output.Warning(fmt.Sprintf("Failed to install schedule timer for %s: %v", tunnelCfg.Tag, err))
+			}
+		}
+	}
+
+	if err := router.SyncExpiryTimer(newCfg); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to install expiry timer: %v", err))
+	}
+	if err := router.SyncLogScanTimer(newCfg); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to install log scan timer: %v", err))
+	}
+	if err := metrics.SyncTimer(newCfg); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to install metrics timer: %v", err))
+	}
+	if err := health.Sync(newCfg); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to install health service: %v", err))
+	}
+	if err := logship.Sync(newCfg); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to install log shipping service: %v", err))
+	}
+	if err := sshd.Sync(newCfg); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to apply SSH keepalive settings: %v", err))
+	}
+	if newCfg.API.IsEnabled() {
+		if err := startAPIServer(newCfg); err != nil {
+			ctx.Output.Warning(err.Error())
 		}
+	} else if err := api.Remove(); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to remove API server: %v", err))
 	}
 
 	// Save config again to persist any updated cert/key paths
@@ -177,6 +234,7 @@ func HandleConfigLoad(ctx *actions.Context) error {
 		return fmt.Errorf("failed to create router: %w", err)
 	}
 
+	guardFirewall(ctx, newCfg, "config load")
 	if err := r.Start(); err != nil {
 		return fmt.Errorf("failed to start router: %w", err)
 	}
@@ -184,6 +242,14 @@ func HandleConfigLoad(ctx *actions.Context) error {
 	ctx.Output.Success("Router started!")
 	ctx.Output.Println()
 
+	var tunnels []*config.TunnelConfig
+	for i := range newCfg.Tunnels {
+		tunnels = append(tunnels, &newCfg.Tunnels[i])
+	}
+	if refreshClientBundles(ctx, newCfg, tunnels) > 0 {
+		ctx.Output.Println()
+	}
+
 	// Show connection info for each tunnel
 	ctx.Output.Info("Connection Info:")
 	for _, tunnel := range newCfg.Tunnels {
@@ -198,6 +264,10 @@ func HandleConfigLoad(ctx *actions.Context) error {
 				ctx.Output.Printf("    Cert:        %s\n", certPath)
 				ctx.Output.Printf("    Key:         %s\n", keyPath)
 			}
+		} else if tunnel.Transport == config.TransportChisel && tunnel.Chisel != nil {
+			ctx.Output.Printf("    Listen:      %d/tcp\n", tunnel.Port)
+			ctx.Output.Printf("    Auth:        %s\n", tunnel.Chisel.Auth)
+			ctx.Output.Printf("    Cert:        %s\n", tunnel.Chisel.Cert)
 		} else if tunnel.Transport == config.TransportDNSTT || tunnel.Transport == config.TransportVayDNS {
 			pubKeyPath := filepath.Join(tunnelDir, "server.pub")
 			pubKey, err := keys.ReadPublicKey(pubKeyPath)
@@ -339,6 +409,41 @@ func ensureTunnelService(ctx *actions.Context, tunnelCfg *config.TunnelConfig, c
 			tunnelCfg.VayDNS.PrivateKey = keyInfo.PrivateKeyPath
 			ctx.Output.Status(fmt.Sprintf("Generated keys for %s", tunnelCfg.Domain))
 		}
+	} else if tunnelCfg.Transport == config.TransportChisel {
+		if tunnelCfg.Chisel == nil {
+			tunnelCfg.Chisel = &config.ChiselConfig{}
+		}
+		if tunnelCfg.Chisel.Auth == "" {
+			auth, err := transport.GenerateChiselAuth()
+			if err != nil {
+				return err
+			}
+			tunnelCfg.Chisel.Auth = auth
+		}
+
+		if tunnelCfg.Chisel.Cert != "" || tunnelCfg.Chisel.Key != "" {
+			if tunnelCfg.Chisel.Cert == "" || tunnelCfg.Chisel.Key == "" {
+				return fmt.Errorf("both cert and key paths must be provided for tunnel %s", tunnelCfg.Tag)
+			}
+			for _, path := range []string{tunnelCfg.Chisel.Cert, tunnelCfg.Chisel.Key} {
+				canRead, err := system.CanDnstmUserReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to check permissions of %s: %w", path, err)
+				}
+				if !canRead {
+					return fmt.Errorf("dnstm user cannot read file: %s", path)
+				}
+			}
+			ctx.Output.Status(fmt.Sprintf("Using provided certificate for %s", tunnelCfg.Domain))
+		} else {
+			certInfo, err := certs.GetOrCreateInDir(tunnelDir, tunnelCfg.Domain)
+			if err != nil {
+				return fmt.Errorf("failed to generate certificate: %w", err)
+			}
+			tunnelCfg.Chisel.Cert = certInfo.CertPath
+			tunnelCfg.Chisel.Key = certInfo.KeyPath
+			ctx.Output.Status(fmt.Sprintf("Generated certificate for %s", tunnelCfg.Domain))
+		}
 	}
 
 	// Get backend
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	actions.SetConfigHandler(actions.ActionConfigPolicy, HandleConfigPolicy)
+}
+
+// HandleConfigPolicy shows the admin policy and whether the current config
+// complies with it.
+func HandleConfigPolicy(ctx *actions.Context) error {
+	policy, err := config.LoadPolicy()
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Fix or remove "+config.PolicyFile)
+	}
+
+	ctx.Output.Println()
+	if policy.IsEmpty() {
+		ctx.Output.Info("No admin policy; every transport and backend may be deployed")
+		ctx.Output.Println("  Restrict them in " + config.PolicyFile)
+		ctx.Output.Println()
+		return nil
+	}
+
+	orAny := func(list string) string {
+		if list == "" {
+			return "any"
+		}
+		return list
+	}
+	var transports, backends []string
+	for _, t := range policy.Transports {
+		transports = append(transports, config.GetTransportTypeDisplayName(t))
+	}
+	for _, b := range policy.Backends {
+		backends = append(backends, config.GetBackendTypeDisplayName(b))
+	}
+	maxTunnels := "unlimited"
+	if policy.MaxTunnels > 0 {
+		maxTunnels = fmt.Sprint(policy.MaxTunnels)
+	}
+	ctx.Output.Printf("Policy:      %s\n", config.PolicyFile)
+	ctx.Output.Printf("Transports:  %s\n", orAny(strings.Join(transports, ", ")))
+	ctx.Output.Printf("Backends:    %s\n", orAny(strings.Join(backends, ", ")))
+	ctx.Output.Printf("Max tunnels: %s\n", maxTunnels)
+
+	if cfg, err := config.Load(); err == nil {
+		ctx.Output.Println()
+		if err := policy.Check(cfg); err != nil {
+			ctx.Output.Warning("The current config breaks the policy: " + err.Error())
+		} else {
+			ctx.Output.Status("The current config complies")
+		}
+	}
+	ctx.Output.Println()
+	return nil
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/api"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/logship"
+	"github.com/net2share/dnstm/internal/metrics"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/sshd"
+)
+
+func init() {
+	actions.SetConfigHandler(actions.ActionConfigSet, HandleConfigSet)
+}
+
+// HandleConfigSet changes one setting of the configuration and updates the
+// services using it.
+func HandleConfigSet(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	path, value := ctx.GetArg(0), ctx.GetArg(1)
+	if path == "" || !ctx.HasArg(1) {
+		return actions.UsageError("path and value required", "Usage: dnstm config set <path> <value>")
+	}
+	newCfg, err := cfg.SetPath(path, value)
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Example: dnstm config set tunnels[tag=main].dnstt.mtu 1400")
+	}
+
+	changes, err := config.Diff(cfg, newCfg)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		ctx.Output.Info(fmt.Sprintf("%s is unchanged", path))
+		return nil
+	}
+	for _, c := range changes {
+		switch {
+		case c.Kind != config.ChangeModified:
+			return actions.NewActionError(
+				fmt.Sprintf("config set cannot add or remove a %s (%s)", c.Section, c),
+				fmt.Sprintf("Use 'dnstm %s add' and 'dnstm %s remove'", c.Section, c.Section),
+			)
+		case c.Section == "route" && slices.Contains(c.Fields, "mode"):
+			return actions.NewActionError("config set cannot change the routing mode", "Use 'dnstm router mode'")
+		case c.Section == "route" && slices.Contains(c.Fields, "active"):
+			return actions.NewActionError("config set cannot change the active tunnel", "Use 'dnstm router switch'")
+		}
+	}
+
+	if err := newCfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "The config is unchanged")
+	}
+	policy, err := config.LoadPolicy()
+	if err != nil {
+		return err
+	}
+	if err := policy.Check(newCfg); err != nil {
+		return policyError(err)
+	}
+
+	markModified := func(c *config.Config) {
+		for _, ch := range changes {
+			if ch.Section == "tunnel" {
+				if t := c.GetTunnelByTag(ch.Tag); t != nil {
+					t.MarkModified()
+				}
+			}
+		}
+	}
+	markModified(newCfg)
+	// The value is set again on the stored config, so changes other
+	// invocations made meanwhile are kept
+	if err := config.Update(func(c *config.Config) error {
+		updated, err := c.SetPath(path, value)
+		if err != nil {
+			return err
+		}
+		markModified(updated)
+		if err := updated.Validate(); err != nil {
+			return err
+		}
+		*c = *updated
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	ctx.Output.Status("Configuration saved")
+
+	applyConfigChanges(ctx, newCfg, changes)
+	ctx.Output.Success(fmt.Sprintf("%s set", path))
+	return nil
+}
+
+// applyConfigChanges rebuilds and restarts what uses the changed tunnels,
+// backends and sections of cfg. Sections read only when a command runs,
+// such as limits or ports, need nothing. Failures are reported as warnings,
+// as the config is already saved.
+func applyConfigChanges(ctx *actions.Context, cfg *config.Config, changes []config.Change) {
+	var tunnels []string
+	sections := make(map[string]bool)
+	for _, c := range changes {
+		switch c.Section {
+		case "tunnel":
+			if !slices.Contains(tunnels, c.Tag) {
+				tunnels = append(tunnels, c.Tag)
+			}
+		case "backend":
+			for _, t := range cfg.GetTunnelsUsingBackend(c.Tag) {
+				if !slices.Contains(tunnels, t.Tag) {
+					tunnels = append(tunnels, t.Tag)
+				}
+			}
+		default:
+			sections[c.Section] = true
+		}
+	}
+
+	if len(tunnels) > 0 {
+		r, err := router.New(cfg)
+		if err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to create router: %v", err))
+		} else {
+			for _, tag := range tunnels {
+				if err := r.RegenerateTunnel(tag); err != nil {
+					ctx.Output.Warning(fmt.Sprintf("Failed to rebuild tunnel service %s: %v", tag, err))
+					continue
+				}
+				if err := router.NewTunnel(cfg.GetTunnelByTag(tag)).SyncScheduleTimer(); err != nil {
+					ctx.Output.Warning(fmt.Sprintf("Failed to install schedule timer for %s: %v", tag, err))
+				}
+				ctx.Output.Status(fmt.Sprintf("Rebuilt tunnel service: %s", tag))
+			}
+		}
+		if err := router.SyncExpiryTimer(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to install expiry timer: %v", err))
+		}
+		if err := sshd.Sync(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to apply SSH keepalive settings: %v", err))
+		}
+	}
+
+	if len(tunnels) > 0 || sections["log"] || sections["privacy"] {
+		if err := health.Sync(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to update health service: %v", err))
+		}
+	}
+	if sections["log"] || sections["privacy"] {
+		if err := router.SyncLogScanTimer(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to install log scan timer: %v", err))
+		}
+		if err := logship.Sync(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to update log shipping service: %v", err))
+		}
+	}
+	if sections["metrics"] {
+		if err := metrics.SyncTimer(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to install metrics timer: %v", err))
+		}
+	}
+	if sections["proxy"] && proxy.IsSocksAvailable(cfg.Proxy) {
+		if err := proxy.ConfigureSocks(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to reconfigure microsocks: %v", err))
+		} else if err := proxy.RestartMicrosocks(); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to restart microsocks: %v", err))
+		} else {
+			ctx.Output.Status("SOCKS proxy restarted")
+		}
+	}
+	// The loopback rules of the conntrack bypass cover the tunnel port range
+	if sections["firewall"] || (sections["ports"] && cfg.Firewall.NoTrack) {
+		if err := router.SyncNotrack(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to update conntrack bypass: %v", err))
+		}
+	}
+	if sections["api"] {
+		if cfg.API.IsEnabled() {
+			if err := startAPIServer(cfg); err != nil {
+				ctx.Output.Warning(err.Error())
+			}
+		} else if err := api.Remove(); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to remove API server: %v", err))
+		}
+	}
+
+	// The DNS router reads the domains and ports of the tunnels, the routes
+	// and the listen address
+	if len(tunnels) > 0 || sections["route"] || sections["listen"] || sections["privacy"] {
+		if err := restartDNSRouterIfActive(); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to restart DNS router: %v", err))
+		}
+	}
+}
@@ -17,7 +17,7 @@ func init() {
 func HandleConfigValidate(ctx *actions.Context) error {
 	filePath := ctx.GetArg(0)
 	if filePath == "" {
-		return actions.NewActionError("file path required", "Usage: dnstm config validate <file>")
+		return actions.UsageError("file path required", "Usage: dnstm config validate <file>")
 	}
 
 	// Check if file exists
@@ -25,7 +25,7 @@ func HandleConfigValidate(ctx *actions.Context) error {
 		return actions.NewActionError(
 			fmt.Sprintf("file not found: %s", filePath),
 			"Please provide a valid config.json file path",
-		)
+		).WithCode(actions.ExitNotFound)
 	}
 
 	ctx.Output.Println()
@@ -35,8 +35,7 @@ func HandleConfigValidate(ctx *actions.Context) error {
 	// Load the configuration from the file
 	cfg, err := config.LoadFromPath(filePath)
 	if err != nil {
-		ctx.Output.Error(fmt.Sprintf("Parse error: %s", err.Error()))
-		return nil
+		return fmt.Errorf("parse error: %w", err)
 	}
 
 	ctx.Output.Status("JSON syntax: OK")
@@ -46,12 +45,21 @@ func HandleConfigValidate(ctx *actions.Context) error {
 
 	// Validate the configuration
 	if err := cfg.Validate(); err != nil {
-		ctx.Output.Error(fmt.Sprintf("Validation error: %s", err.Error()))
-		return nil
+		return fmt.Errorf("validation error: %w", err)
 	}
 
 	ctx.Output.Status("Configuration: Valid")
 
+	// The policy of this server, which a load would be checked against
+	if policy, err := config.LoadPolicy(); err != nil {
+		ctx.Output.Warning(err.Error())
+	} else if !policy.IsEmpty() {
+		if err := policy.Check(cfg); err != nil {
+			return fmt.Errorf("policy error: %w", err)
+		}
+		ctx.Output.Status("Admin policy: OK")
+	}
+
 	ctx.Output.Println()
 	ctx.Output.Success("Configuration file is valid!")
 	ctx.Output.Println()
@@ -76,6 +84,7 @@ func HandleConfigValidate(ctx *actions.Context) error {
 	if len(cfg.Tunnels) > 0 {
 		ctx.Output.Println()
 		ctx.Output.Info("Tunnels:")
+		router.PrefetchStates(cfg.Tunnels)
 		for _, t := range cfg.Tunnels {
 			transportName := config.GetTransportTypeDisplayName(t.Transport)
 			status := "stopped"
@@ -2,12 +2,11 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"fmt"
 
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/system"
 	"github.com/net2share/dnstm/internal/transport"
 	"github.com/net2share/go-corelib/osdetect"
 )
@@ -114,16 +113,7 @@ func RequireBackends(ctx *actions.Context) error {
 
 // RequireRoot checks for root privileges.
 func RequireRoot() error {
-	return osdetect.RequireRoot()
-}
-
-// GeneratePassword generates a random base64-encoded password.
-func GeneratePassword() string {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		panic("crypto/rand failed: " + err.Error())
-	}
-	return base64.StdEncoding.EncodeToString(bytes)
+	return system.RequireRoot()
 }
 
 // GetDefaultSSHAddress returns the default SSH server address.
@@ -139,11 +129,69 @@ func RequireConfig(ctx *actions.Context) (*config.Config, error) {
 	return LoadConfig(ctx)
 }
 
+// updateConfig applies fn to the stored config while holding the config
+// lock, so concurrent dnstm invocations cannot lose each other's changes,
+// and then to cfg, the copy the handler goes on with. fn must change only
+// the config it is given; if it fails, nothing is saved.
+func updateConfig(cfg *config.Config, fn func(*config.Config) error) error {
+	if err := config.Update(fn); err != nil {
+		return err
+	}
+	return fn(cfg)
+}
+
+// saveTunnel stores the changes a handler made to t with updateConfig:
+// the stored tunnel is replaced, the rest of the config is kept as other
+// invocations left it, and the result must still validate.
+func saveTunnel(cfg *config.Config, t *config.TunnelConfig) error {
+	updated := *t
+	return updateConfig(cfg, func(c *config.Config) error {
+		stored := c.GetTunnelByTag(updated.Tag)
+		if stored == nil {
+			return actions.TunnelNotFoundError(updated.Tag)
+		}
+		*stored = updated
+		return c.Validate()
+	})
+}
+
+// setTunnelEnabled stores whether the tunnel tagged tag is enabled with
+// updateConfig. Starting and stopping change nothing else, so the rest of
+// the config is not validated again.
+func setTunnelEnabled(cfg *config.Config, tag string, enabled bool) error {
+	return updateConfig(cfg, func(c *config.Config) error {
+		t := c.GetTunnelByTag(tag)
+		if t == nil {
+			return actions.TunnelNotFoundError(tag)
+		}
+		t.Enabled = &enabled
+		return nil
+	})
+}
+
+// saveBackend is saveTunnel for a backend.
+func saveBackend(cfg *config.Config, b *config.BackendConfig) error {
+	updated := *b
+	return updateConfig(cfg, func(c *config.Config) error {
+		stored := c.GetBackendByTag(updated.Tag)
+		if stored == nil {
+			return actions.BackendNotFoundError(updated.Tag)
+		}
+		*stored = updated
+		return c.Validate()
+	})
+}
+
+// portRangeError reports that no port of the tunnel port range is free.
+func portRangeError(err error) error {
+	return actions.NewActionError(err.Error(), "Widen ports.start/ports.end in the config, or check 'dnstm ports list'")
+}
+
 // RequireTag gets a tag value from context, returning a standardized error if empty.
 func RequireTag(ctx *actions.Context, entity string) (string, error) {
 	tag := ctx.GetString("tag")
 	if tag == "" {
-		return "", actions.NewActionError(
+		return "", actions.UsageError(
 			fmt.Sprintf("%s tag required", entity),
 			fmt.Sprintf("Usage: dnstm %s <command> -t <tag>", entity),
 		)
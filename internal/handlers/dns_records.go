@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/delegation"
+	"github.com/net2share/dnstm/internal/network"
+)
+
+func init() {
+	actions.SetDNSHandler(actions.ActionDNSRecords, HandleDNSRecords)
+}
+
+// HandleDNSRecords prints the parent-zone records delegating a tunnel domain
+// to this server.
+func HandleDNSRecords(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+	if !tunnelCfg.Transport.IsDNS() {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' is not a DNS tunnel", tag),
+			"Clients connect to it directly; no delegation is needed",
+		)
+	}
+
+	ip := strings.TrimSpace(ctx.GetString("ip"))
+	if ip == "" {
+		ip = tunnelCfg.BindHost
+	}
+	if ip == "" {
+		if ip, err = network.GetExternalIP(); err != nil {
+			return actions.NewActionError("could not detect the external IP: "+err.Error(), "Pass it with --ip")
+		}
+	}
+	if net.ParseIP(ip) == nil {
+		return actions.NewActionError(fmt.Sprintf("invalid address '%s'", ip), "Pass an IPv4 or IPv6 address with --ip")
+	}
+
+	plan, err := delegation.NewPlan(tunnelCfg.Domain, strings.TrimSpace(ctx.GetString("ns-host")), ip, ctx.GetString("txt"))
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Use --ns-host to pick another name server host")
+	}
+	out, err := plan.Render(ctx.GetString("format"))
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Use --format bind, cloudflare or route53")
+	}
+
+	ctx.Output.Print(out)
+	return nil
+}
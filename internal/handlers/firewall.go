@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/fwguard"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/prompt"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetFirewallHandler(actions.ActionFirewallStatus, HandleFirewallStatus)
+	actions.SetFirewallHandler(actions.ActionFirewallConfirm, HandleFirewallConfirm)
+	actions.SetFirewallHandler(actions.ActionFirewallRollback, HandleFirewallRollback)
+	actions.SetFirewallHandler(actions.ActionFirewallNotrackEnable, HandleFirewallNotrackEnable)
+	actions.SetFirewallHandler(actions.ActionFirewallNotrackDisable, HandleFirewallNotrackDisable)
+}
+
+// guardFirewall runs before dnstm changes the firewall. When dnstm runs
+// over SSH it makes sure the session's port stays open and, when someone at
+// a terminal can confirm them, schedules a rollback of the changes unless
+// they are confirmed in time.
+func guardFirewall(ctx *actions.Context, cfg *config.Config, reason string) {
+	port := network.SSHSessionPort()
+	if port == 0 {
+		return
+	}
+
+	if !network.IsTCPPortAllowed(port) {
+		network.AllowTCPPort(port)
+		ctx.Output.Warning(fmt.Sprintf("SSH port %d was not open in the firewall; added a rule allowing it", port))
+	}
+
+	// Scripts (no terminal, --yes, --non-interactive) cannot confirm, and
+	// without a firewall there is nothing to roll back
+	timeout := cfg.Firewall.GetConfirmTimeout()
+	if timeout == 0 || prompt.IsHeadless() || network.DetectFirewall() == network.FirewallNone {
+		return
+	}
+	p, err := fwguard.Begin(reason, port, timeout)
+	if err != nil {
+		ctx.Output.Warning("Firewall rollback not scheduled: " + err.Error())
+		return
+	}
+	ctx.Output.Warning(fmt.Sprintf("Firewall changes are rolled back at %s unless you run 'dnstm firewall confirm' (from a new SSH session)",
+		p.Deadline.Format("15:04:05")))
+}
+
+// HandleFirewallStatus shows firewall changes awaiting confirmation and the
+// conntrack bypass.
+func HandleFirewallStatus(ctx *actions.Context) error {
+	showNotrackStatus(ctx)
+
+	p := fwguard.Get()
+	if p == nil {
+		ctx.Output.Info("No firewall changes await confirmation")
+		return nil
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Printf("Pending:     %s\n", p.Reason)
+	ctx.Output.Printf("Since:       %s\n", p.Since.Format(time.RFC3339))
+	ctx.Output.Printf("Rollback at: %s (in %s)\n", p.Deadline.Format(time.RFC3339), time.Until(p.Deadline).Round(time.Second))
+	if p.SSHPort != 0 {
+		allowed := "open"
+		if !network.IsTCPPortAllowed(p.SSHPort) {
+			allowed = "blocked"
+		}
+		ctx.Output.Printf("SSH port:    %d (%s)\n", p.SSHPort, allowed)
+	}
+	ctx.Output.Println()
+	ctx.Output.Info("Keep the changes with: dnstm firewall confirm")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleFirewallConfirm keeps the pending firewall changes.
+func HandleFirewallConfirm(ctx *actions.Context) error {
+	if fwguard.Get() == nil {
+		ctx.Output.Info("No firewall changes await confirmation")
+		return nil
+	}
+	if err := fwguard.Confirm(); err != nil {
+		return fmt.Errorf("failed to cancel rollback: %w", err)
+	}
+	ctx.Output.Success("Firewall changes confirmed")
+	return nil
+}
+
+// HandleFirewallRollback restores the firewall rules saved before the
+// pending changes. The rollback timer runs it as well.
+func HandleFirewallRollback(ctx *actions.Context) error {
+	p := fwguard.Get()
+	if p == nil {
+		ctx.Output.Info("No firewall changes await confirmation")
+		return nil
+	}
+	if err := fwguard.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back firewall: %w", err)
+	}
+	ctx.Output.Success(fmt.Sprintf("Firewall rules restored to before: %s", p.Reason))
+	return nil
+}
+
+// showNotrackStatus shows whether the conntrack bypass is enabled and its
+// rules are in place.
+func showNotrackStatus(ctx *actions.Context) {
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	rules := network.NotrackRules()
+	ctx.Output.Println()
+	switch {
+	case cfg.Firewall.NoTrack && len(rules) == 0:
+		ctx.Output.Printf("Conntrack bypass: enabled, but no rules are in place\n")
+		ctx.Output.Warning("Put them back with: dnstm firewall notrack enable")
+	case cfg.Firewall.NoTrack:
+		ctx.Output.Printf("Conntrack bypass: enabled (%s mode, %d rules)\n", cfg.Route.Mode, len(rules))
+	case len(rules) > 0:
+		ctx.Output.Printf("Conntrack bypass: disabled, but %d rules are left\n", len(rules))
+		ctx.Output.Warning("Remove them with: dnstm firewall notrack disable")
+	default:
+		ctx.Output.Printf("Conntrack bypass: disabled\n")
+	}
+	for _, rule := range rules {
+		ctx.Output.Printf("  %s\n", rule)
+	}
+	ctx.Output.Println()
+}
+
+// HandleFirewallNotrackEnable exempts tunnel DNS traffic from connection
+// tracking.
+func HandleFirewallNotrackEnable(ctx *actions.Context) error {
+	return setNotrack(ctx, true)
+}
+
+// HandleFirewallNotrackDisable tracks tunnel DNS traffic again.
+func HandleFirewallNotrackDisable(ctx *actions.Context) error {
+	return setNotrack(ctx, false)
+}
+
+func setNotrack(ctx *actions.Context, enabled bool) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Firewall.NoTrack = enabled
+	if err := router.SyncNotrack(cfg); err != nil {
+		if errors.Is(err, network.ErrNotrackUnsupported) {
+			return actions.NewActionError(err.Error(), "Install iptables, or leave the bypass disabled")
+		}
+		return fmt.Errorf("failed to update conntrack bypass: %w", err)
+	}
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Firewall.NoTrack = enabled
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if !enabled {
+		ctx.Output.Success("Conntrack bypass disabled")
+		return nil
+	}
+	ctx.Output.Println()
+	for _, rule := range network.NotrackRules() {
+		ctx.Output.Status(rule)
+	}
+	ctx.Output.Success("Conntrack bypass enabled")
+	ctx.Output.Info("Check it with: dnstm firewall status")
+	ctx.Output.Println()
+	return nil
+}
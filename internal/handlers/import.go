@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/certs"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/importer"
+	"github.com/net2share/dnstm/internal/keys"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+func init() {
+	actions.SetImportHandler(actions.ActionImport, HandleImport)
+}
+
+// HandleImport adds a tunnel server set up without dnstm as a dnstm tunnel.
+func HandleImport(ctx *actions.Context) error {
+	if err := CheckRequirements(ctx, true, true); err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	kind, err := importer.ParseKind(ctx.GetString("from"))
+	if err != nil {
+		return actions.UsageError(err.Error(), "Example: dnstm import --from dnstt /etc/systemd/system/dnstt.service")
+	}
+	srv, err := importer.Read(kind, ctx.GetArg(0))
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Pass the systemd unit running the server, or the ssserver config.json")
+	}
+	if strings.HasPrefix(srv.Unit, paths.ServicePrefix) {
+		return actions.NewActionError(
+			fmt.Sprintf("%s is managed by dnstm already", srv.Unit),
+			"Use 'dnstm tunnel list' to see the tunnels",
+		)
+	}
+
+	unit := strings.TrimSuffix(srv.Unit, ".service")
+	tag := ctx.GetString("tag")
+	if tag == "" {
+		tag = unit
+	}
+	if tag == "" {
+		tag = router.GenerateUniqueTunnelTag(cfg.Tunnels)
+	}
+	tag = router.NormalizeTag(tag)
+	if err := router.ValidateTag(tag); err != nil {
+		return actions.UsageError(fmt.Sprintf("invalid tag '%s': %v", tag, err), "Choose one with --tag")
+	}
+	if tag == config.RescueTag {
+		return actions.UsageError(fmt.Sprintf("tag '%s' is reserved for the rescue tunnel", tag), "Choose another with --tag")
+	}
+	if cfg.GetTunnelByTag(tag) != nil {
+		return actions.TunnelExistsError(tag)
+	}
+
+	tunnelCfg, newBackend, err := importedTunnel(cfg, tag, srv)
+	if err != nil {
+		return err
+	}
+	backend := tunnelCfg.Backend
+
+	disableOld := ctx.GetBool("disable-old")
+
+	ctx.Output.Println()
+	ctx.Output.Status(fmt.Sprintf("Found %s server for %s in %s", srv.Kind, srv.Domain, srv.Source))
+	ctx.Output.Println(ctx.Output.KV("  Tunnel", fmt.Sprintf("%s (%s)", tag, config.GetTransportTypeDisplayName(tunnelCfg.Transport))))
+	switch {
+	case newBackend == nil:
+		ctx.Output.Println(ctx.Output.KV("  Backend", backend))
+	case newBackend.Type == config.BackendShadowsocks:
+		ctx.Output.Println(ctx.Output.KV("  Backend", fmt.Sprintf("%s (new Shadowsocks backend)", backend)))
+	default:
+		ctx.Output.Println(ctx.Output.KV("  Backend", fmt.Sprintf("%s (new custom backend for %s)", backend, newBackend.Address)))
+	}
+	switch {
+	case srv.Unit == "":
+		ctx.Output.Println(ctx.Output.KV("  Old unit", "unknown"))
+	case disableOld:
+		ctx.Output.Println(ctx.Output.KV("  Old unit", srv.Unit+" (stopped and disabled)"))
+	default:
+		ctx.Output.Println(ctx.Output.KV("  Old unit", srv.Unit+" (left as is)"))
+	}
+
+	if ctx.GetBool("dry-run") {
+		ctx.Output.Println()
+		ctx.Output.Info("Dry run: nothing was changed")
+		ctx.Output.Println()
+		return nil
+	}
+
+	if newBackend != nil {
+		cfg.Backends = append(cfg.Backends, *newBackend)
+	}
+	if err := importCrypto(srv, filepath.Join(config.TunnelsDir, tag)); err != nil {
+		return err
+	}
+
+	// The old server usually holds the port the tunnel needs
+	stopped := false
+	if disableOld && srv.Unit == "" {
+		ctx.Output.Warning("No systemd unit is known for this server; stop it yourself")
+	} else if disableOld && service.IsServiceActive(unit) {
+		if err := service.StopService(unit); err != nil {
+			return fmt.Errorf("failed to stop %s: %w", srv.Unit, err)
+		}
+		stopped = true
+	}
+
+	if err := createTunnel(ctx, tunnelCfg, cfg); err != nil {
+		if stopped {
+			if startErr := service.StartService(unit); startErr != nil {
+				ctx.Output.Warning(fmt.Sprintf("Failed to start %s again: %v", srv.Unit, startErr))
+			}
+		}
+		return err
+	}
+
+	if !disableOld || srv.Unit == "" {
+		if srv.Unit != "" {
+			ctx.Output.Info(fmt.Sprintf("%s is still enabled; disable it with 'systemctl disable --now %s' once the tunnel works", srv.Unit, unit))
+		}
+		return nil
+	}
+	if t := cfg.GetTunnelByTag(tag); t == nil || !router.NewTunnel(t).IsActive() {
+		if stopped {
+			if err := service.StartService(unit); err != nil {
+				ctx.Output.Warning(fmt.Sprintf("Failed to start %s again: %v", srv.Unit, err))
+			}
+		}
+		ctx.Output.Warning(fmt.Sprintf("Tunnel '%s' is not running, so %s was left enabled", tag, srv.Unit))
+		return nil
+	}
+	if err := service.DisableService(unit); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to disable %s: %v", srv.Unit, err))
+	} else {
+		ctx.Output.Status(fmt.Sprintf("%s stopped and disabled", srv.Unit))
+	}
+	ctx.Output.Println()
+	return nil
+}
+
+// importedTunnel returns the config of the tunnel replacing srv, and the
+// backend to add for it, if any.
+func importedTunnel(cfg *config.Config, tag string, srv *importer.Server) (*config.TunnelConfig, *config.BackendConfig, error) {
+	port, err := cfg.AllocateNextPort()
+	if err != nil {
+		return nil, nil, portRangeError(err)
+	}
+	tunnelCfg := &config.TunnelConfig{
+		Tag:         tag,
+		Transport:   srv.Transport(),
+		Domain:      srv.Domain,
+		Port:        port,
+		Description: "Imported from " + srv.Source,
+	}
+	if srv.Kind == importer.KindDNSTT {
+		mtu := srv.MTU
+		if mtu == 0 {
+			mtu = 1232
+		}
+		tunnelCfg.DNSTT = &config.DNSTTConfig{MTU: mtu}
+	}
+	backend, newBackend := importBackend(cfg, tag, srv)
+	tunnelCfg.Backend = backend
+	return tunnelCfg, newBackend, nil
+}
+
+// importBackend returns the tag of the backend an imported server forwards
+// to, and the backend to add when there is none yet.
+func importBackend(cfg *config.Config, tag string, srv *importer.Server) (string, *config.BackendConfig) {
+	if ss := srv.Shadowsocks; ss != nil {
+		// A backend with plugins serves one tunnel only, so it is not shared
+		for _, b := range cfg.Backends {
+			if b.Type == config.BackendShadowsocks && b.Shadowsocks != nil && len(b.Shadowsocks.Plugins) == 0 &&
+				b.Shadowsocks.Method == ss.Method && b.Shadowsocks.Password == ss.Password {
+				return b.Tag, nil
+			}
+		}
+		backendTag := uniqueBackendTag(cfg, tag+"-ss")
+		return backendTag, &config.BackendConfig{Tag: backendTag, Type: config.BackendShadowsocks, Shadowsocks: ss}
+	}
+
+	if b := importer.MatchBackend(cfg.Backends, srv.Target); b != nil {
+		return b.Tag, nil
+	}
+	backendTag := uniqueBackendTag(cfg, tag+"-upstream")
+	return backendTag, &config.BackendConfig{Tag: backendTag, Type: config.BackendCustom, Address: srv.Target}
+}
+
+// uniqueBackendTag returns base, or base with a number if it is taken.
+func uniqueBackendTag(cfg *config.Config, base string) string {
+	tag := base
+	for i := 2; cfg.GetBackendByTag(tag) != nil; i++ {
+		tag = fmt.Sprintf("%s-%d", base, i)
+	}
+	return tag
+}
+
+// importCrypto copies the key or certificate of an imported server into
+// the tunnel directory, where creating the tunnel picks it up.
+func importCrypto(srv *importer.Server, tunnelDir string) error {
+	if err := os.MkdirAll(tunnelDir, 0750); err != nil {
+		return fmt.Errorf("failed to create tunnel directory: %w", err)
+	}
+	_ = system.ChownDirToDnstm(tunnelDir)
+
+	if srv.Kind == importer.KindDNSTT {
+		if _, err := keys.ImportInDir(tunnelDir, srv.PrivateKey); err != nil {
+			return fmt.Errorf("failed to import key: %w", err)
+		}
+		return nil
+	}
+
+	certPath := filepath.Join(tunnelDir, "cert.pem")
+	keyPath := filepath.Join(tunnelDir, "key.pem")
+	if err := copyOwned(srv.Cert, certPath, 0644); err != nil {
+		return fmt.Errorf("failed to import certificate: %w", err)
+	}
+	if err := copyOwned(srv.Key, keyPath, 0600); err != nil {
+		return fmt.Errorf("failed to import key: %w", err)
+	}
+	if _, err := certs.ReadCertificateFingerprint(certPath); err != nil {
+		return fmt.Errorf("failed to read certificate %s: %w", srv.Cert, err)
+	}
+	return nil
+}
+
+// copyOwned copies src to dst with mode and hands it to the dnstm user.
+// A file already in place is left alone.
+func copyOwned(src, dst string, mode os.FileMode) error {
+	if filepath.Clean(src) == filepath.Clean(dst) {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	_ = system.ChownToDnstm(dst)
+	return nil
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/certs"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/escrow"
+	"github.com/net2share/dnstm/internal/prompt"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/go-corelib/tui"
+)
+
+func init() {
+	actions.SetKeysHandler(actions.ActionKeysExport, HandleKeysExport)
+	actions.SetKeysHandler(actions.ActionKeysImport, HandleKeysImport)
+}
+
+// HandleKeysExport writes the keys and certificates of one or all tunnels to
+// an escrow archive.
+func HandleKeysExport(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tunnels []*config.TunnelConfig
+	if tag := ctx.GetString("tag"); tag != "" {
+		t := cfg.GetTunnelByTag(tag)
+		if t == nil {
+			return actions.TunnelNotFoundError(tag)
+		}
+		tunnels = append(tunnels, t)
+	} else {
+		for i := range cfg.Tunnels {
+			tunnels = append(tunnels, &cfg.Tunnels[i])
+		}
+	}
+	if len(tunnels) == 0 {
+		ctx.Output.Info("No tunnels configured")
+		return nil
+	}
+
+	passphrase := ctx.GetString("passphrase")
+	if passphrase == "" && ctx.GetBool("encrypt") {
+		if passphrase, err = askNewPassphrase(); err != nil {
+			return err
+		}
+		if passphrase == "" {
+			return nil
+		}
+	}
+
+	dest := ctx.GetString("file")
+	if dest == "" {
+		dest = "dnstm-keys.tar.gz"
+		if passphrase != "" {
+			dest = "dnstm-keys.enc"
+		}
+	}
+
+	a, err := escrow.Build(tunnels)
+	if err != nil {
+		return fmt.Errorf("failed to collect keys: %w", err)
+	}
+	if err := a.Write(dest, passphrase); err != nil {
+		return err
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Keys of %d tunnels written to %s", len(a.Manifest.Tunnels), dest))
+	for _, k := range a.Manifest.Tunnels {
+		ctx.Output.Status(fmt.Sprintf("%s (%s): %s", k.Tag, k.Domain, tunnelKeyIdentity(k)))
+	}
+	if passphrase == "" {
+		ctx.Output.Warning("The archive holds private keys in the clear. Store it encrypted, or export again with --encrypt.")
+	} else {
+		ctx.Output.Info("Keep the passphrase apart from the archive; the keys cannot be recovered without it.")
+	}
+	ctx.Output.Info(fmt.Sprintf("To restore on a replacement server: dnstm keys import %s", dest))
+	return nil
+}
+
+// HandleKeysImport restores the keys and certificates of an escrow archive.
+func HandleKeysImport(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	src := ctx.GetArg(0)
+	if src == "" {
+		return actions.UsageError("archive path required", "Usage: dnstm keys import <file>")
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return actions.NewActionError(
+			fmt.Sprintf("file not found: %s", src),
+			"Create an archive on the original server with 'dnstm keys export'",
+		).WithCode(actions.ExitNotFound)
+	}
+
+	passphrase := ctx.GetString("passphrase")
+	if encrypted, err := escrow.IsEncrypted(src); err != nil {
+		return err
+	} else if encrypted && passphrase == "" {
+		if passphrase, err = askPassphrase("Passphrase of the archive"); err != nil {
+			return err
+		}
+		if passphrase == "" {
+			return nil
+		}
+	}
+
+	a, err := escrow.Read(src, passphrase)
+	if errors.Is(err, escrow.ErrWrongPassphrase) {
+		return actions.NewActionError(err.Error(), "Check the passphrase the archive was exported with")
+	}
+	if err != nil {
+		return err
+	}
+
+	restore := a.Manifest.Tunnels
+	if tag := ctx.GetString("tag"); tag != "" {
+		k := a.Tunnel(tag)
+		if k == nil {
+			return actions.NewActionError(
+				fmt.Sprintf("the archive has no keys for tunnel '%s'", tag),
+				"Leave out --tag to restore every tunnel in the archive",
+			).WithCode(actions.ExitNotFound)
+		}
+		restore = []escrow.TunnelKey{*k}
+	}
+
+	m := a.Manifest
+	ctx.Output.Println()
+	ctx.Output.Info(fmt.Sprintf("Restoring %d tunnels from %s (exported %s)",
+		len(restore), m.Origin, m.Created.Local().Format("2006-01-02 15:04:05")))
+	ctx.Output.Println()
+
+	createAutoSnapshot(ctx, "before keys import")
+
+	var rebuild []string
+	for _, k := range restore {
+		t := cfg.GetTunnelByTag(k.Tag)
+		if t != nil && t.Transport != k.Transport {
+			ctx.Output.Warning(fmt.Sprintf("Skipped '%s': the archive holds %s keys, the tunnel uses %s", k.Tag, k.Transport, t.Transport))
+			continue
+		}
+
+		dir, err := a.Restore(config.TunnelsDir, k.Tag)
+		if err != nil {
+			return err
+		}
+
+		if t == nil {
+			ctx.Output.Status(fmt.Sprintf("Keys of '%s' staged in %s", k.Tag, dir))
+			ctx.Output.Info(fmt.Sprintf("Add the tunnel with 'dnstm tunnel add -t %s --transport %s --domain %s' to use them", k.Tag, k.Transport, k.Domain))
+			continue
+		}
+		if t.Domain != k.Domain {
+			ctx.Output.Warning(fmt.Sprintf("'%s' serves %s, the keys were exported for %s", k.Tag, t.Domain, k.Domain))
+		}
+		escrow.SetCryptoPaths(t, dir)
+		t.MarkModified()
+		rebuild = append(rebuild, k.Tag)
+		ctx.Output.Status(fmt.Sprintf("Keys of '%s' restored: %s", k.Tag, tunnelKeyIdentity(k)))
+	}
+
+	if len(rebuild) == 0 {
+		return nil
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		for _, tag := range rebuild {
+			stored := c.GetTunnelByTag(tag)
+			if stored == nil {
+				return actions.TunnelNotFoundError(tag)
+			}
+			*stored = *cfg.GetTunnelByTag(tag)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	ctx.Output.Status("Configuration saved")
+
+	r, err := router.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create router: %w", err)
+	}
+	var rebuilt []*config.TunnelConfig
+	for _, tag := range rebuild {
+		if err := r.RegenerateTunnel(tag); err != nil {
+			return fmt.Errorf("failed to rebuild tunnel service: %w", err)
+		}
+		ctx.Output.Status(fmt.Sprintf("Rebuilt tunnel service: %s", tag))
+		rebuilt = append(rebuilt, cfg.GetTunnelByTag(tag))
+	}
+	refreshClientBundles(ctx, cfg, rebuilt)
+
+	ctx.Output.Success(fmt.Sprintf("Restored the keys of %d tunnels", len(rebuild)))
+	return nil
+}
+
+// tunnelKeyIdentity formats what clients pin of an archived tunnel.
+func tunnelKeyIdentity(k escrow.TunnelKey) string {
+	if k.Fingerprint != "" {
+		return "fingerprint " + certs.FormatFingerprint(k.Fingerprint)
+	}
+	return "public key " + k.PublicKey
+}
+
+// askPassphrase asks for a passphrase. It returns "" if the user cancelled.
+func askPassphrase(title string) (string, error) {
+	passphrase, confirmed, err := prompt.RunInput(tui.InputConfig{
+		Title:    title,
+		Password: true,
+	})
+	if errors.Is(err, prompt.ErrNoTerminal) {
+		return "", actions.UsageError("passphrase required", "Give it with --passphrase")
+	}
+	if err != nil || !confirmed {
+		return "", err
+	}
+	return passphrase, nil
+}
+
+// askNewPassphrase asks for a passphrase twice. It returns "" if the user
+// cancelled.
+func askNewPassphrase() (string, error) {
+	passphrase, err := askPassphrase("Passphrase")
+	if err != nil || passphrase == "" {
+		return "", err
+	}
+	again, err := askPassphrase("Repeat passphrase")
+	if err != nil || again == "" {
+		return "", err
+	}
+	if again != passphrase {
+		return "", actions.UsageError("passphrases do not match", "Enter the same passphrase twice")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/prompt"
+	"github.com/net2share/dnstm/internal/system"
+	"github.com/net2share/go-corelib/tui"
+)
+
+// enforceLimit decides what happens when a change exceeds a limit of the
+// config (limitErr wraps config.ErrLimit): with --ignore-limits it goes on
+// with a warning, in the menu the user is asked once, otherwise it fails.
+func enforceLimit(ctx *actions.Context, limitErr error) error {
+	if limitErr == nil {
+		return nil
+	}
+	if ctx.GetBool("ignore-limits") {
+		ctx.Output.Warning(limitErr.Error() + "; ignored")
+		return nil
+	}
+	if ctx.IsInteractive {
+		confirm, err := prompt.RunConfirm(tui.ConfirmConfig{
+			Title:       "Exceed the limit?",
+			Description: limitErr.Error() + ".\nSmall servers may run out of memory or fail in obscure ways.",
+		})
+		if err != nil {
+			return err
+		}
+		if !confirm {
+			return actions.ErrCancelled
+		}
+		// Asked once per command
+		ctx.Set("ignore-limits", true)
+		return nil
+	}
+	return actions.NewActionError(limitErr.Error(), "Raise the limit in the config, or run again with --ignore-limits")
+}
+
+// checkMultiModeMemory checks that this server has the memory multi mode
+// needs. Where memory is unknown, nothing is checked.
+func checkMultiModeMemory(ctx *actions.Context, cfg *config.Config) error {
+	mem, err := system.TotalMemory()
+	if err != nil {
+		return nil
+	}
+	return enforceLimit(ctx, cfg.CheckMultiModeMemory(mem))
+}
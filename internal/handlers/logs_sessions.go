@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/logship"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/sessionlog"
+)
+
+func init() {
+	actions.SetLogsHandler(actions.ActionLogsSessions, HandleLogsSessions)
+}
+
+// HandleLogsSessions sets or removes the sink of the session events, giving
+// every tunnel that can have one a front to record its sessions.
+func HandleLogsSessions(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	disable := ctx.GetBool("disable")
+	var rebuild, skipped []string
+	if disable {
+		if cfg.Log.Sessions == nil && !hasSessionLog(cfg) {
+			ctx.Output.Info("Sessions are not recorded")
+			return nil
+		}
+		cfg.Log.Sessions = nil
+		for i := range cfg.Tunnels {
+			t := &cfg.Tunnels[i]
+			if t.SessionLog == nil {
+				continue
+			}
+			oldFront := t.FrontPort()
+			t.SessionLog = nil
+			t.MarkModified()
+			if t.FrontPort() != oldFront {
+				rebuild = append(rebuild, t.Tag)
+			}
+		}
+	} else {
+		sink := strings.TrimSpace(ctx.GetString("sink"))
+		if sink == "" && cfg.Log.Sessions != nil {
+			sink = cfg.Log.Sessions.Sink
+		}
+		if sink == "" {
+			return actions.UsageError("--sink is required", "Usage: dnstm logs sessions --sink file:///var/log/dnstm/sessions.jsonl")
+		}
+		cfg.Log.Sessions = &config.SessionLogConfig{Sink: sink}
+		if rebuild, skipped, err = addSessionLogFronts(cfg); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Example: --sink file:///var/log/dnstm/sessions.jsonl or --sink https://siem.example.com/ingest")
+	}
+
+	beginProgress(ctx, "Session Events")
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	// The file sink is created by the health service update; remote sinks
+	// are checked before anything changes
+	if s := cfg.Log.Sessions; s != nil && s.SinkType() != config.SessionSinkFile {
+		if err := sessionlog.SendTest(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Test event not delivered: %v", err))
+		} else {
+			ctx.Output.Status("Test event delivered")
+		}
+	}
+
+	// Sessions are recorded through fronts on the tunnels
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Log.Sessions = cfg.Log.Sessions
+		for _, t := range cfg.Tunnels {
+			if stored := c.GetTunnelByTag(t.Tag); stored != nil {
+				stored.SessionLog, stored.Meta = t.SessionLog, t.Meta
+			}
+		}
+		return c.Validate()
+	}); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	// Fronts must be up before tunnels forward to them, and tunnels must stop
+	// forwarding to them before they go away
+	if !disable {
+		if err := health.Sync(cfg); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to update health service: %w", err))
+		}
+		ctx.Output.Status("Health service updated")
+	}
+	if len(rebuild) > 0 {
+		r, err := router.New(cfg)
+		if err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to create router: %w", err))
+		}
+		for _, tag := range rebuild {
+			if err := r.RegenerateTunnel(tag); err != nil {
+				return failProgress(ctx, fmt.Errorf("failed to rebuild tunnel service %s: %w", tag, err))
+			}
+			ctx.Output.Status(fmt.Sprintf("Rebuilt tunnel service: %s", tag))
+		}
+	}
+	if disable {
+		if err := health.Sync(cfg); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to update health service: %w", err))
+		}
+		ctx.Output.Status("Health service updated")
+		ctx.Output.Success("Sessions are no longer recorded")
+	} else {
+		recorded := 0
+		for _, t := range cfg.Tunnels {
+			if t.SessionLog != nil {
+				recorded++
+			}
+		}
+		ctx.Output.Success(fmt.Sprintf("Sessions of %d tunnel(s) are recorded to %s", recorded, logship.RedactURL(cfg.Log.Sessions.Sink)))
+		if len(skipped) > 0 {
+			ctx.Output.Info("Not recorded, as their transport picks the destination: " + strings.Join(skipped, ", "))
+		}
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
+
+// hasSessionLog reports whether a tunnel still has a front for the session
+// log.
+func hasSessionLog(cfg *config.Config) bool {
+	for _, t := range cfg.Tunnels {
+		if t.SessionLog != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// addSessionLogFronts gives every tunnel that can have a front one for the
+// session log, reusing the front of its health endpoint or session limit.
+// It returns the tunnels whose service must be rebuilt to forward to a new
+// front, and those that cannot have one.
+func addSessionLogFronts(cfg *config.Config) (rebuild, skipped []string, err error) {
+	for i := range cfg.Tunnels {
+		t := &cfg.Tunnels[i]
+		backend := cfg.GetBackendByTag(t.Backend)
+		if backend == nil || !config.HasFront(t.Transport, backend.Type) {
+			skipped = append(skipped, t.Tag)
+			continue
+		}
+		if t.SessionLog != nil {
+			continue
+		}
+		port := t.FrontPort()
+		if port == 0 {
+			var err error
+			if port, err = cfg.AllocateNextPort(); err != nil {
+				return nil, nil, portRangeError(fmt.Errorf("front of '%s': %w", t.Tag, err))
+			}
+			rebuild = append(rebuild, t.Tag)
+		}
+		t.SessionLog = &config.TunnelLogConfig{Port: port}
+		t.MarkModified()
+	}
+	return rebuild, skipped, nil
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/logship"
+)
+
+func init() {
+	actions.SetLogsHandler(actions.ActionLogsShip, HandleLogsShip)
+}
+
+// HandleLogsShip sets or removes the remote log shipping target.
+func HandleLogsShip(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.GetBool("disable") {
+		if cfg.Log.Ship == nil && !logship.IsInstalled() {
+			ctx.Output.Info("Logs are not shipped")
+			return nil
+		}
+		cfg.Log.Ship = nil
+	} else {
+		ship := &config.LogShipConfig{}
+		if cfg.Log.Ship != nil {
+			*ship = *cfg.Log.Ship
+		}
+		if u := strings.TrimSpace(ctx.GetString("url")); u != "" {
+			ship.URL = u
+		}
+		if ship.URL == "" {
+			return actions.UsageError("--url is required", "Usage: dnstm logs ship --url udp://logs.example.com:514")
+		}
+		if l := strings.TrimSpace(ctx.GetString("labels")); l != "" {
+			labels, err := config.ParseLabels(l)
+			if err != nil {
+				return actions.UsageError(err.Error(), "Example: --labels server=eu-1,region=eu")
+			}
+			ship.Labels = labels
+		}
+		cfg.Log.Ship = ship
+	}
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Example: --url udp://logs.example.com:514 or --url https://loki.example.com")
+	}
+
+	beginProgress(ctx, "Log Shipping")
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if cfg.Log.Ship != nil {
+		// A target that cannot be reached is still saved; entries wait in
+		// the journal until it can
+		if err := logship.SendTest(cfg); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Test entry not delivered: %v", err))
+		} else {
+			ctx.Output.Status("Test entry delivered")
+		}
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Log.Ship = cfg.Log.Ship
+		return nil
+	}); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	if err := logship.Sync(cfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to update log shipping service: %w", err))
+	}
+
+	if cfg.Log.Ship == nil {
+		ctx.Output.Success("Log shipping disabled")
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Logs of all dnstm services are shipped to %s", logship.RedactURL(cfg.Log.Ship.URL)))
+		ctx.Output.Info("Follow the shipper with: journalctl -u " + logship.ServiceName)
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
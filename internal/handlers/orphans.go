@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/importer"
+	"github.com/net2share/dnstm/internal/installer"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+func init() {
+	actions.SetOrphansHandler(actions.ActionOrphansScan, HandleOrphansScan)
+	actions.SetOrphansHandler(actions.ActionOrphansAdopt, HandleOrphansAdopt)
+	actions.SetOrphansHandler(actions.ActionOrphansRemove, HandleOrphansRemove)
+}
+
+// HandleOrphansScan lists the services and directories of tunnels missing
+// from the config.
+func HandleOrphansScan(ctx *actions.Context) error {
+	if err := CheckRequirements(ctx, true, true); err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	orphans := installer.FindOrphans(cfg)
+	if len(orphans) == 0 {
+		ctx.Output.Success("No orphaned services or directories found")
+		return nil
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Printf("%-20s %-10s %-10s %-40s %s\n", "TAG", "SERVICE", "TIMER", "DIRECTORY", "FIX")
+	ctx.Output.Separator(100)
+	for _, o := range orphans {
+		svc := "-"
+		if o.Service != "" {
+			svc = "inactive"
+			if service.IsServiceActive(o.Service) {
+				svc = "active"
+			}
+		}
+		timer := "-"
+		if o.Timer != "" {
+			timer = "installed"
+		}
+		dir := "-"
+		if o.Dir != "" {
+			dir = o.Dir
+		}
+		fix := "remove"
+		if _, err := readOrphan(&o); err == nil {
+			fix = "adopt or remove"
+		}
+		ctx.Output.Printf("%-20s %-10s %-10s %-40s %s\n", o.Tag, svc, timer, dir, fix)
+	}
+	ctx.Output.Println()
+	ctx.Output.Info("Use 'dnstm orphans adopt' or 'dnstm orphans remove', optionally with -t <tag>")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleOrphansAdopt adds orphaned tunnel services to the config.
+func HandleOrphansAdopt(ctx *actions.Context) error {
+	if err := CheckRequirements(ctx, true, true); err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	tag := ctx.GetString("tag")
+	orphans, err := selectOrphans(cfg, tag)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	adopted := 0
+	for i := range orphans {
+		o := &orphans[i]
+		if o.Service == "" {
+			if tag != "" {
+				return actions.NewActionError(
+					fmt.Sprintf("orphan '%s' has no service to adopt", tag),
+					fmt.Sprintf("Use 'dnstm tunnel add --tag %s' to reuse its key or certificate", tag),
+				)
+			}
+			continue
+		}
+		if err := adoptOrphan(ctx, cfg, o); err != nil {
+			if tag != "" {
+				return err
+			}
+			ctx.Output.Warning(fmt.Sprintf("Failed to adopt '%s': %v", o.Tag, err))
+			failed = append(failed, o.Tag)
+			continue
+		}
+		adopted++
+	}
+
+	if len(failed) > 0 {
+		return actions.NewActionError(
+			fmt.Sprintf("failed to adopt %s", strings.Join(failed, ", ")),
+			"Remove them with 'dnstm orphans remove -t <tag>'",
+		)
+	}
+	if adopted == 0 {
+		ctx.Output.Info("No orphaned tunnel services to adopt")
+	}
+	return nil
+}
+
+// adoptOrphan reads an orphaned tunnel service and creates the tunnel it
+// ran again, with its key or certificate.
+func adoptOrphan(ctx *actions.Context, cfg *config.Config, o *installer.Orphan) error {
+	srv, err := readOrphan(o)
+	if err != nil {
+		return actions.NewActionError(
+			fmt.Sprintf("cannot adopt %s: %v", o.Service, err),
+			fmt.Sprintf("Use 'dnstm orphans remove -t %s' to remove it", o.Tag),
+		)
+	}
+	if err := router.ValidateTag(o.Tag); err != nil {
+		return actions.NewActionError(
+			fmt.Sprintf("cannot adopt %s: invalid tag '%s': %v", o.Service, o.Tag, err),
+			fmt.Sprintf("Use 'dnstm orphans remove -t %s' to remove it", o.Tag),
+		)
+	}
+
+	tunnelCfg, newBackend, err := importedTunnel(cfg, o.Tag, srv)
+	if err != nil {
+		return err
+	}
+	// It was a dnstm tunnel before, not an import
+	tunnelCfg.Description = ""
+	if newBackend != nil {
+		cfg.Backends = append(cfg.Backends, *newBackend)
+	}
+	if err := importCrypto(srv, filepath.Join(config.TunnelsDir, o.Tag)); err != nil {
+		return err
+	}
+
+	// The orphan holds the port and its unit is replaced
+	wasActive := service.IsServiceActive(o.Service)
+	if wasActive {
+		if err := service.StopService(o.Service); err != nil {
+			return fmt.Errorf("failed to stop %s: %w", o.Service, err)
+		}
+	}
+	if err := createTunnel(ctx, tunnelCfg, cfg); err != nil {
+		if wasActive {
+			if startErr := service.StartService(o.Service); startErr != nil {
+				ctx.Output.Warning(fmt.Sprintf("Failed to start %s again: %v", o.Service, startErr))
+			}
+		}
+		return err
+	}
+	// The schedule it applied was lost with the config
+	if o.Timer != "" {
+		if err := service.RemoveTimer(o.Timer); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to remove %s: %v", o.Timer, err))
+		}
+	}
+	return nil
+}
+
+// readOrphan reads the server an orphaned tunnel service runs.
+func readOrphan(o *installer.Orphan) (*importer.Server, error) {
+	if o.Service == "" {
+		return nil, fmt.Errorf("no service")
+	}
+	if !service.UsesSystemd() {
+		return nil, fmt.Errorf("only systemd units can be adopted")
+	}
+	return importer.Read("", service.GetServicePath(o.Service))
+}
+
+// HandleOrphansRemove stops and deletes orphaned services and directories.
+func HandleOrphansRemove(ctx *actions.Context) error {
+	if err := CheckRequirements(ctx, true, true); err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	orphans, err := selectOrphans(cfg, ctx.GetString("tag"))
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		ctx.Output.Info("No orphaned services or directories to remove")
+		return nil
+	}
+
+	var errs []string
+	for i := range orphans {
+		o := &orphans[i]
+		if err := installer.RemoveOrphan(o); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		ctx.Output.Status(fmt.Sprintf("Removed orphan '%s'", o.Tag))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// selectOrphans returns the orphans of cfg, or only the one tagged tag.
+func selectOrphans(cfg *config.Config, tag string) ([]installer.Orphan, error) {
+	orphans := installer.FindOrphans(cfg)
+	if tag == "" {
+		return orphans, nil
+	}
+	for _, o := range orphans {
+		if o.Tag == tag {
+			return []installer.Orphan{o}, nil
+		}
+	}
+	if cfg.GetTunnelByTag(tag) != nil {
+		return nil, actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' is in the config, so it is not an orphan", tag),
+			"Use 'dnstm tunnel remove' to remove it",
+		).WithCode(actions.ExitConflict)
+	}
+	return nil, actions.NewActionError(
+		fmt.Sprintf("no orphan tagged '%s' found", tag),
+		"Use 'dnstm orphans scan' to list orphans",
+	).WithCode(actions.ExitNotFound)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// quietOutput discards the messages of a handler.
+type quietOutput struct{ actions.OutputWriter }
+
+func (quietOutput) Info(string)    {}
+func (quietOutput) Success(string) {}
+func (quietOutput) Status(string)  {}
+func (quietOutput) Warning(string) {}
+
+func TestHandleOrphansRemove_Tag(t *testing.T) {
+	dir := t.TempDir()
+	for _, def := range binary.ServerBinaries() {
+		t.Setenv(def.EnvVar, os.Args[0])
+	}
+	configDir, tunnelsDir, prefix := config.ConfigDir, config.TunnelsDir, paths.ServicePrefix
+	t.Cleanup(func() { config.ConfigDir, config.TunnelsDir, paths.ServicePrefix = configDir, tunnelsDir, prefix })
+	config.ConfigDir = dir
+	config.TunnelsDir = filepath.Join(dir, "tunnels")
+	// Keeps services installed on the host out of the scan
+	paths.ServicePrefix = "dnstm-orphans-test-"
+
+	cfg := &config.Config{Route: config.RouteConfig{Mode: "multi"}}
+	if err := cfg.SaveToPath(filepath.Join(dir, config.ConfigFile)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"keep", "drop"} {
+		if err := os.MkdirAll(filepath.Join(config.TunnelsDir, tag), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := &actions.Context{
+		Ctx:    context.Background(),
+		Config: cfg,
+		Values: map[string]interface{}{"tag": "drop", "force": true},
+		Output: quietOutput{},
+	}
+	if err := HandleOrphansRemove(ctx); err != nil {
+		t.Fatalf("HandleOrphansRemove() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(config.TunnelsDir, "drop")); !os.IsNotExist(err) {
+		t.Error("orphan picked with -t was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(config.TunnelsDir, "keep")); err != nil {
+		t.Errorf("other orphan was removed too: %v", err)
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"strings"
 
 	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/prompt"
 	"github.com/net2share/go-corelib/tui"
 )
 
 // TUIOutput implements OutputWriter using the tui package.
 type TUIOutput struct {
 	progressView *tui.ProgressView
+
+	// Quiet drops info, success, status and step messages; warnings,
+	// errors and plain output are still printed.
+	Quiet bool
 }
 
 // NewTUIOutput creates a new TUI output writer.
@@ -55,6 +60,9 @@ func (t *TUIOutput) Info(msg string) {
 		t.progressView.AddInfo(msg)
 		return
 	}
+	if t.Quiet {
+		return
+	}
 	tui.PrintInfo(msg)
 }
 
@@ -64,6 +72,9 @@ func (t *TUIOutput) Success(msg string) {
 		t.progressView.AddSuccess(msg)
 		return
 	}
+	if t.Quiet {
+		return
+	}
 	tui.PrintSuccess(msg)
 }
 
@@ -91,6 +102,9 @@ func (t *TUIOutput) Status(msg string) {
 		t.progressView.AddStatus(msg)
 		return
 	}
+	if t.Quiet {
+		return
+	}
 	tui.PrintStatus(msg)
 }
 
@@ -100,6 +114,9 @@ func (t *TUIOutput) Step(current, total int, msg string) {
 		t.progressView.AddInfo(fmt.Sprintf("[%d/%d] %s", current, total, msg))
 		return
 	}
+	if t.Quiet {
+		return
+	}
 	tui.PrintStep(current, total, msg)
 }
 
@@ -217,11 +234,16 @@ func (t *TUIOutput) ShowInfo(cfg actions.InfoConfig) error {
 		}
 		tuiCfg.Sections = append(tuiCfg.Sections, tuiSection)
 	}
-	return tui.ShowInfo(tuiCfg)
+	return prompt.ShowInfo(tuiCfg)
 }
 
 // BeginProgress starts a progress view with the given title.
+// In plain mode the title is printed and output continues line by line.
 func (t *TUIOutput) BeginProgress(title string) {
+	if prompt.IsPlain() {
+		fmt.Printf("\n%s\n\n", title)
+		return
+	}
 	t.progressView = tui.NewProgressView(title)
 }
 
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/prompt"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/go-corelib/tui"
+)
+
+// resolvePort53Conflicts checks for other DNS servers on port 53 before dnstm
+// binds it in mode. Interactive sessions walk through each conflict and offer
+// to stop it; otherwise the conflicts are returned as an error instead of
+// letting the bind fail later, unless --force is given.
+func resolvePort53Conflicts(ctx *actions.Context, cfg *config.Config, mode string) error {
+	conflicts := network.DetectDNSConflicts(port53Host(cfg, mode))
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	if !ctx.IsInteractive && ctx.GetBool("force") {
+		for _, c := range conflicts {
+			ctx.Output.Warning(fmt.Sprintf("Continuing with %s on port 53; binding may fail", c))
+		}
+		return nil
+	}
+
+	if !ctx.IsInteractive {
+		var lines []string
+		var hints []string
+		for _, c := range conflicts {
+			lines = append(lines, "  - "+c.String())
+			if c.Unit != "" {
+				hints = append(hints, "systemctl disable --now "+c.Unit)
+			} else if c.PID != 0 {
+				hints = append(hints, fmt.Sprintf("kill %d", c.PID))
+			}
+		}
+		hint := "Stop the other DNS server first, or run again with --force to go on anyway"
+		if len(hints) > 0 {
+			hint += ":\n  " + strings.Join(hints, "\n  ")
+		}
+		return actions.NewActionError("port 53 is used by another DNS server:\n"+strings.Join(lines, "\n"), hint)
+	}
+
+	for _, c := range conflicts {
+		if err := resolveConflict(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// port53Host returns the address dnstm binds port 53 on in mode: the
+// external IP in single mode, the DNS router's listen address in multi mode.
+func port53Host(cfg *config.Config, mode string) string {
+	if mode == "multi" {
+		if host, _, err := net.SplitHostPort(cfg.Listen.Address); err == nil {
+			return host
+		}
+		return "0.0.0.0"
+	}
+	ip, err := network.GetExternalIP()
+	if err != nil {
+		return "0.0.0.0"
+	}
+	return ip
+}
+
+// resolveConflict asks how to handle one conflicting DNS server.
+func resolveConflict(ctx *actions.Context, c network.DNSConflict) error {
+	var options []tui.MenuOption
+	if c.Unit != "" {
+		options = append(options,
+			tui.MenuOption{Label: fmt.Sprintf("Stop and disable %s", c.Unit), Value: "disable"},
+			tui.MenuOption{Label: fmt.Sprintf("Stop %s until next boot", c.Unit), Value: "stop"},
+		)
+	} else if c.PID != 0 {
+		options = append(options, tui.MenuOption{Label: fmt.Sprintf("Terminate process %d", c.PID), Value: "kill"})
+	}
+	options = append(options,
+		tui.MenuOption{Label: "Continue anyway", Value: "ignore"},
+		tui.MenuOption{Label: "Cancel", Value: "cancel"},
+	)
+
+	description := c.String() + "\n\ndnstm needs port 53 to answer DNS queries for its tunnels."
+	if c.Name == "systemd-resolved" {
+		description += "\nDisabling systemd-resolved also removes the local resolver; point /etc/resolv.conf at another server."
+	}
+
+	choice, err := prompt.RunMenu(tui.MenuConfig{
+		Title:       "Port 53 Conflict",
+		Description: description,
+		Options:     options,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch choice {
+	case "disable":
+		if err := service.StopService(c.Unit); err != nil {
+			return err
+		}
+		if err := service.DisableService(c.Unit); err != nil {
+			ctx.Output.Warning(err.Error())
+		}
+		ctx.Output.Status(fmt.Sprintf("%s stopped and disabled", c.Unit))
+	case "stop":
+		if err := service.StopService(c.Unit); err != nil {
+			return err
+		}
+		ctx.Output.Status(fmt.Sprintf("%s stopped", c.Unit))
+	case "kill":
+		proc, err := os.FindProcess(c.PID)
+		if err == nil {
+			err = proc.Signal(syscall.SIGTERM)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to terminate process %d: %w", c.PID, err)
+		}
+		ctx.Output.Status(fmt.Sprintf("Process %d terminated", c.PID))
+	case "ignore":
+		ctx.Output.Warning(fmt.Sprintf("Continuing with %s on port 53; binding may fail", c.Name))
+	default:
+		return actions.ErrCancelled
+	}
+	return nil
+}
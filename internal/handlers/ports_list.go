@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetPortsHandler(actions.ActionPortsList, HandlePortsList)
+}
+
+// portAssignment is a port dnstm expects to own.
+type portAssignment struct {
+	port    int
+	owner   string
+	running bool // The owning service is up, so the port being bound is expected
+	ranged  bool // Subject to the tunnel allocation policy
+}
+
+// HandlePortsList lists port assignments and reports conflicts.
+func HandlePortsList(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	router.PrefetchStates(cfg.Tunnels)
+	var assignments []portAssignment
+	for i := range cfg.Tunnels {
+		t := &cfg.Tunnels[i]
+		assignments = append(assignments, portAssignment{
+			port:    t.Port,
+			owner:   "tunnel " + t.Tag,
+			running: router.NewTunnel(t).IsActive(),
+			ranged:  t.Transport.IsDNS(),
+		})
+		if port := t.FrontPort(); port != 0 {
+			assignments = append(assignments, portAssignment{
+				port:    port,
+				owner:   "front " + t.Tag,
+				running: health.IsActive(),
+				ranged:  true,
+			})
+		}
+	}
+	if cfg.GetBackendByTag("socks") != nil {
+		port := cfg.Proxy.Port
+		if port == 0 {
+			port = 1080
+		}
+		assignments = append(assignments, portAssignment{
+			port:    port,
+			owner:   "socks proxy",
+			running: proxy.IsMicrosocksRunning(),
+		})
+	}
+	sort.SliceStable(assignments, func(i, j int) bool { return assignments[i].port < assignments[j].port })
+
+	owners := make(map[int][]string)
+	for _, a := range assignments {
+		owners[a.port] = append(owners[a.port], a.owner)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Printf("Range:    %s\n", router.GetPortRange(cfg.Ports))
+	if len(cfg.Ports.Exclude) > 0 {
+		excluded := make([]string, len(cfg.Ports.Exclude))
+		for i, p := range cfg.Ports.Exclude {
+			excluded[i] = fmt.Sprintf("%d", p)
+		}
+		ctx.Output.Printf("Excluded: %s\n", strings.Join(excluded, ", "))
+	}
+	if next, err := cfg.AllocateNextPort(); err == nil {
+		ctx.Output.Printf("Next:     %d\n", next)
+	} else {
+		ctx.Output.Printf("Next:     none (range exhausted)\n")
+	}
+	ctx.Output.Println()
+
+	ctx.Output.Printf("%-8s %-24s %s\n", "PORT", "OWNER", "STATUS")
+	ctx.Output.Separator(70)
+
+	conflicts := 0
+	for _, a := range assignments {
+		status := portStatus(cfg, a, owners[a.port])
+		if strings.HasPrefix(status, "conflict") {
+			conflicts++
+		}
+		ctx.Output.Printf("%-8d %-24s %s\n", a.port, a.owner, status)
+	}
+	ctx.Output.Println()
+
+	if conflicts > 0 {
+		ctx.Output.Warning(fmt.Sprintf("%d port conflict(s) found", conflicts))
+		ctx.Output.Println()
+	}
+	return nil
+}
+
+// portStatus describes an assignment, starting with "conflict" when the port
+// cannot be used as configured.
+func portStatus(cfg *config.Config, a portAssignment, owners []string) string {
+	if len(owners) > 1 {
+		var others []string
+		for _, o := range owners {
+			if o != a.owner {
+				others = append(others, o)
+			}
+		}
+		return "conflict: also assigned to " + strings.Join(others, ", ")
+	}
+	if a.running {
+		return "in use (running)"
+	}
+	if !config.IsPortFree(a.port) {
+		return "conflict: held by another process"
+	}
+	if a.ranged {
+		if cfg.Ports.IsExcluded(a.port) {
+			return "excluded (free)"
+		}
+		if start, end := cfg.Ports.Range(); a.port < start || a.port > end {
+			return "outside range (free)"
+		}
+	}
+	return "free"
+}
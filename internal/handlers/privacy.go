@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/logship"
+)
+
+func init() {
+	actions.SetPrivacyHandler(actions.ActionPrivacyEnable, HandlePrivacyEnable)
+	actions.SetPrivacyHandler(actions.ActionPrivacyDisable, HandlePrivacyDisable)
+}
+
+// HandlePrivacyEnable turns on anonymizing client addresses.
+func HandlePrivacyEnable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	mode := strings.TrimSpace(ctx.GetString("ip"))
+	if mode == "" {
+		mode = config.PrivacyTruncate
+	}
+	if cfg.Privacy != nil && cfg.Privacy.IP == mode {
+		ctx.Output.Info(fmt.Sprintf("Client addresses are already anonymized (%s)", mode))
+		return nil
+	}
+
+	p := &config.PrivacyConfig{IP: mode}
+	if mode == config.PrivacyHash {
+		// A salt kept from an earlier hash setting keeps the hashes the same
+		if cfg.Privacy != nil && cfg.Privacy.Salt != "" {
+			p.Salt = cfg.Privacy.Salt
+		} else if p.Salt, err = config.GenerateSalt(); err != nil {
+			return fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+	cfg.Privacy = p
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Use --ip truncate or --ip hash")
+	}
+
+	if err := applyPrivacy(ctx, cfg); err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if mode == config.PrivacyHash {
+		ctx.Output.Success("Client addresses are replaced with a keyed hash in session events, resolver counters and shipped logs")
+	} else {
+		ctx.Output.Success("Client addresses are truncated to their /24 (IPv4) or /48 (IPv6) in session events, resolver counters and shipped logs")
+	}
+	ctx.Output.Info("The local journal keeps what the transports and sshd log; records written before are unchanged")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandlePrivacyDisable turns off anonymizing client addresses.
+func HandlePrivacyDisable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Privacy == nil {
+		ctx.Output.Info("Client addresses are not anonymized")
+		return nil
+	}
+	cfg.Privacy = nil
+
+	if err := applyPrivacy(ctx, cfg); err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success("Client addresses are recorded as seen")
+	ctx.Output.Println()
+	return nil
+}
+
+// applyPrivacy saves cfg and restarts the services that record client
+// addresses, so they pick up the privacy setting.
+func applyPrivacy(ctx *actions.Context, cfg *config.Config) error {
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Privacy = cfg.Privacy
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if err := restartDNSRouterIfActive(); err != nil {
+		ctx.Output.Warning("Failed to restart DNS router: " + err.Error())
+	}
+	if health.IsInstalled() {
+		if err := health.Sync(cfg); err != nil {
+			ctx.Output.Warning("Failed to restart health service: " + err.Error())
+		}
+	}
+	if err := logship.Sync(cfg); err != nil {
+		ctx.Output.Warning("Failed to restart log shipping service: " + err.Error())
+	}
+	return nil
+}
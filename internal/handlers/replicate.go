@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/replicate"
+	"github.com/net2share/dnstm/internal/version"
+)
+
+func init() {
+	actions.SetReplicateHandler(actions.ActionReplicateExport, HandleReplicateExport)
+	actions.SetReplicateHandler(actions.ActionReplicateImport, HandleReplicateImport)
+}
+
+// HandleReplicateExport writes a replica bundle of the current setup.
+func HandleReplicateExport(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	dest := ctx.GetString("file")
+	if dest == "" {
+		dest = "dnstm-replica.tar.gz"
+	}
+
+	b, err := replicate.Build(cfg, version.Version)
+	if err != nil {
+		return fmt.Errorf("failed to build replica bundle: %w", err)
+	}
+	if err := b.Write(dest); err != nil {
+		return err
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Replica bundle written to %s (%d tunnels)", dest, len(b.Manifest.Tunnels)))
+	if len(b.Manifest.Dropped) > 0 {
+		ctx.Output.Info("Left out (bound to this host):")
+		for _, d := range b.Manifest.Dropped {
+			ctx.Output.Status(d)
+		}
+	}
+	ctx.Output.Warning("The bundle contains private keys. Copy it over a secure channel and delete it afterwards.")
+	ctx.Output.Info(fmt.Sprintf("On each replica run: dnstm replicate import %s", dest))
+	return nil
+}
+
+// HandleReplicateImport replaces the current setup with a replica bundle.
+func HandleReplicateImport(ctx *actions.Context) error {
+	if err := CheckRequirements(ctx, true, false); err != nil {
+		return err
+	}
+
+	src := ctx.GetArg(0)
+	if src == "" {
+		return actions.NewActionError("bundle path required", "Usage: dnstm replicate import <file>")
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return actions.NewActionError(
+			fmt.Sprintf("file not found: %s", src),
+			"Create a bundle on the origin server with 'dnstm replicate export'",
+		)
+	}
+
+	b, err := replicate.Read(src)
+	if err != nil {
+		return err
+	}
+
+	m := b.Manifest
+	ctx.Output.Println()
+	ctx.Output.Info(fmt.Sprintf("Importing %d tunnels from %s (exported %s, dnstm %s)",
+		len(m.Tunnels), m.Origin, m.Created.Local().Format("2006-01-02 15:04:05"), m.DnstmVersion))
+	ctx.Output.Println()
+
+	createAutoSnapshot(ctx, "before replicate import")
+
+	if err := deployConfig(ctx, b.Config, func() error {
+		if err := b.Install(config.TunnelsDir); err != nil {
+			return fmt.Errorf("failed to install tunnel keys: %w", err)
+		}
+		ctx.Output.Status("Keys and certificates installed")
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	ctx.Output.Info("Add this server's IP to the address records of each tunnel domain's nameserver (e.g. a second A record for the NS host) to serve it alongside the origin.")
+	return nil
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/usage"
+)
+
+func init() {
+	actions.SetReportHandler(actions.ActionReportUsage, HandleReportUsage)
+	actions.SetReportHandler(actions.ActionReportEnable, HandleReportEnable)
+	actions.SetReportHandler(actions.ActionReportDisable, HandleReportDisable)
+	actions.SetReportHandler(actions.ActionReportCollect, HandleReportCollect)
+}
+
+// HandleReportUsage shows or exports the usage ledger of a month.
+func HandleReportUsage(ctx *actions.Context) error {
+	month := usage.Month(time.Now())
+	if m := ctx.GetString("month"); m != "" {
+		parsed, err := usage.ParseMonth(m)
+		if err != nil {
+			return actions.UsageError(err.Error(), "Example: --month 2025-01")
+		}
+		month = parsed
+	}
+
+	// Fold in traffic counted since the last timer run
+	if month == usage.Month(time.Now()) && network.IsUsageAccountingActive() {
+		if err := collectUsage(); err != nil {
+			ctx.Output.Warning(err.Error())
+		}
+	}
+
+	ledger, err := usage.Load(month)
+	if err != nil {
+		return err
+	}
+
+	format := ctx.GetString("format")
+	if format == "" {
+		format = usage.FormatTable
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case usage.FormatCSV:
+		err = usage.WriteCSV(&buf, ledger)
+	case usage.FormatJSON:
+		err = usage.WriteJSON(&buf, ledger)
+	case usage.FormatTable:
+		if path := ctx.GetString("output"); path != "" {
+			return actions.NewActionError("--output requires --format csv or json", "Example: --format csv --output usage.csv")
+		}
+		showUsageTable(ctx, ledger)
+		return nil
+	default:
+		return actions.NewActionError(fmt.Sprintf("unknown format '%s'", format), "Use table, csv or json")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to format report: %w", err)
+	}
+
+	if path := ctx.GetString("output"); path != "" {
+		if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		ctx.Output.Success(fmt.Sprintf("Usage report for %s written to %s", month, path))
+		return nil
+	}
+	ctx.Output.Print(buf.String())
+	return nil
+}
+
+// showUsageTable prints a ledger as a table.
+func showUsageTable(ctx *actions.Context, ledger *usage.Ledger) {
+	ctx.Output.Println()
+	ctx.Output.Printf("Usage for %s\n", ledger.Month)
+	if !ledger.Updated.IsZero() {
+		ctx.Output.Printf("Updated:  %s\n", ledger.Updated.Local().Format("2006-01-02 15:04"))
+	}
+	ctx.Output.Println()
+
+	if len(ledger.Entries) == 0 {
+		ctx.Output.Info("No traffic recorded")
+		if !network.IsUsageAccountingActive() {
+			ctx.Output.Println("  Enable accounting with: dnstm report enable")
+		}
+		ctx.Output.Println()
+		return
+	}
+
+	cfg, _ := config.Load()
+	state, _ := usage.LoadQuotaState()
+
+	ctx.Output.Printf("%-6s %-24s %12s %12s  %s\n", "KIND", "NAME", "TRAFFIC", "PACKETS", "QUOTA")
+	ctx.Output.Separator(80)
+	var total uint64
+	for _, e := range ledger.Entries {
+		ctx.Output.Printf("%-6s %-24s %12s %12d  %s\n", e.Kind, e.Name, usage.FormatBytes(e.Bytes), e.Packets, quotaStatus(cfg, state, ledger, e.Account()))
+		total += e.Bytes
+	}
+	ctx.Output.Separator(80)
+	ctx.Output.Printf("%-31s %12s\n", "Total", usage.FormatBytes(total))
+	ctx.Output.Println()
+}
+
+// quotaStatus describes the quota of an account for the usage table.
+func quotaStatus(cfg *config.Config, state *usage.QuotaState, ledger *usage.Ledger, account string) string {
+	if cfg == nil || state == nil {
+		return "-"
+	}
+	q := cfg.GetQuota(account)
+	if q == nil {
+		return "-"
+	}
+	status := fmt.Sprintf("%s of %s", usage.FormatBytes(state.Used(ledger, account)), q.Limit)
+	if state.Cutoffs[account] != nil && ledger.Month == usage.Month(time.Now()) {
+		status += " (cut off)"
+	}
+	return status
+}
+
+// HandleReportEnable installs the usage counters and the collector timer.
+func HandleReportEnable(ctx *actions.Context) error {
+	if err := reloadUsageAccounting(); err != nil {
+		return actions.NewActionError(err.Error(), "Install nftables and try again")
+	}
+
+	if err := service.CreateTimer(&service.TimerConfig{
+		Name:        usage.TimerName,
+		Description: "dnstm usage accounting",
+		ExecStart:   cmdline.Join(paths.Bin("dnstm"), "report", "collect"),
+		OnCalendar:  "*:0/5",
+	}); err != nil {
+		network.RemoveUsageAccounting()
+		return fmt.Errorf("failed to install collector timer: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success("Usage accounting enabled")
+	ctx.Output.Info("View with: dnstm report usage")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleReportDisable collects the counters a last time and removes them.
+func HandleReportDisable(ctx *actions.Context) error {
+	if !network.IsUsageAccountingActive() && !service.IsTimerInstalled(usage.TimerName) {
+		ctx.Output.Info("Usage accounting is not enabled")
+		return nil
+	}
+
+	if network.IsUsageAccountingActive() {
+		if err := collectUsage(); err != nil {
+			ctx.Output.Warning(err.Error())
+		}
+	}
+	if service.IsTimerInstalled(usage.TimerName) {
+		if err := service.RemoveTimer(usage.TimerName); err != nil {
+			return fmt.Errorf("failed to remove collector timer: %w", err)
+		}
+	}
+	if err := network.RemoveUsageAccounting(); err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success("Usage accounting disabled (ledgers kept in " + usage.Dir + ")")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleReportCollect adds the counters to the ledger, enforces quotas, and
+// reloads the counters so users created since the last run are counted too.
+func HandleReportCollect(ctx *actions.Context) error {
+	if network.IsUsageAccountingActive() {
+		if err := collectUsage(); err != nil {
+			return err
+		}
+	}
+	if cfg, err := config.Load(); err == nil {
+		if err := enforceQuotas(ctx, cfg); err != nil {
+			ctx.Output.Warning(err.Error())
+		}
+	}
+	// The table is gone after a reboot; reloading restores it
+	return reloadUsageAccounting()
+}
+
+// collectUsage reads and resets the counters and records them in the
+// current month's ledger.
+func collectUsage() error {
+	readings, err := network.ResetUsageCounters()
+	if err != nil {
+		return err
+	}
+	return usage.Add(readings, time.Now())
+}
+
+// reloadUsageAccounting installs counters for the current set of users.
+func reloadUsageAccounting() error {
+	f, err := os.Open("/etc/passwd")
+	if err != nil {
+		return fmt.Errorf("failed to read users: %w", err)
+	}
+	defer f.Close()
+
+	cfg, _ := config.Load()
+	return network.ApplyUsageAccounting(usage.Subjects(cfg, f))
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/system"
+	"github.com/net2share/dnstm/internal/usage"
+)
+
+func init() {
+	actions.SetReportHandler(actions.ActionReportQuota, HandleReportQuota)
+	actions.SetReportHandler(actions.ActionReportReset, HandleReportReset)
+}
+
+// reportAccount returns the account argument, validated.
+func reportAccount(ctx *actions.Context, example string) (string, error) {
+	account := ctx.GetArg(0)
+	if account == "" {
+		account = ctx.GetString("account")
+	}
+	if _, _, err := config.ParseQuotaAccount(account); err != nil {
+		return "", actions.NewActionError(err.Error(), "Example: "+example)
+	}
+	return account, nil
+}
+
+// HandleReportQuota sets or removes the monthly quota of an account.
+func HandleReportQuota(ctx *actions.Context) error {
+	account, err := reportAccount(ctx, "dnstm report quota ssh:alice --limit 50G")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.GetBool("disable") {
+		if cfg.GetQuota(account) == nil {
+			ctx.Output.Info(fmt.Sprintf("No quota set for '%s'", account))
+			return nil
+		}
+		if err := updateConfig(cfg, func(c *config.Config) error {
+			c.RemoveQuota(account)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		// Lifts a cutoff the quota caused
+		if err := enforceQuotas(ctx, cfg); err != nil {
+			ctx.Output.Warning(err.Error())
+		}
+		ctx.Output.Success(fmt.Sprintf("Quota for '%s' removed", account))
+		return nil
+	}
+
+	limit := ctx.GetString("limit")
+	if limit == "" {
+		return actions.NewActionError("limit is required", "Example: --limit 50G")
+	}
+	if _, err := config.ParseByteSize(limit); err != nil {
+		return actions.NewActionError(err.Error(), "Example: --limit 50G")
+	}
+
+	setQuota := func(c *config.Config) error {
+		if q := c.GetQuota(account); q != nil {
+			q.Limit = limit
+		} else {
+			c.Quotas = append(c.Quotas, config.QuotaConfig{Account: account, Limit: limit})
+		}
+		return c.Validate()
+	}
+	if err := setQuota(cfg); err != nil {
+		return actions.NewActionError(err.Error(), "Check the account name")
+	}
+	if err := config.Update(setQuota); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Quota for '%s' set to %s per month", account, limit))
+
+	if !network.IsUsageAccountingActive() {
+		ctx.Output.Warning("Usage accounting is not enabled, so the quota is not enforced yet")
+		ctx.Output.Println("  Enable with: dnstm report enable")
+		return nil
+	}
+	if err := collectUsage(); err != nil {
+		ctx.Output.Warning(err.Error())
+	}
+	return enforceQuotas(ctx, cfg)
+}
+
+// HandleReportReset lifts a cutoff and stops counting the traffic recorded
+// so far this month against the account's quota.
+func HandleReportReset(ctx *actions.Context) error {
+	account, err := reportAccount(ctx, "dnstm report reset ssh:alice")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if network.IsUsageAccountingActive() {
+		if err := collectUsage(); err != nil {
+			ctx.Output.Warning(err.Error())
+		}
+	}
+
+	state, err := usage.LoadQuotaState()
+	if err != nil {
+		return err
+	}
+	ledger, err := usage.Load(usage.Month(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	state.Resets[account] = usage.Reset{Month: ledger.Month, Bytes: ledger.Bytes(account)}
+	if c := state.Cutoffs[account]; c != nil {
+		if err := liftCutoff(ctx, cfg, account, c); err != nil {
+			return fmt.Errorf("failed to restore '%s': %w", account, err)
+		}
+		delete(state.Cutoffs, account)
+		ctx.Output.Status(fmt.Sprintf("'%s' restored", account))
+	}
+	if err := state.Save(); err != nil {
+		return err
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Quota for '%s' reset", account))
+	return nil
+}
+
+// enforceQuotas cuts off accounts over their quota this month and restores
+// those whose cutoff no longer applies.
+func enforceQuotas(ctx *actions.Context, cfg *config.Config) error {
+	state, err := usage.LoadQuotaState()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	ledger, err := usage.Load(usage.Month(now))
+	if err != nil {
+		return err
+	}
+
+	cut, lift := state.Plan(cfg.Quotas, ledger)
+	for _, account := range lift {
+		if err := liftCutoff(ctx, cfg, account, state.Cutoffs[account]); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to restore '%s': %v", account, err))
+			continue
+		}
+		delete(state.Cutoffs, account)
+		ctx.Output.Status(fmt.Sprintf("'%s' restored", account))
+	}
+	for _, account := range cut {
+		c, err := cutOffAccount(ctx, cfg, account)
+		if err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to cut off '%s': %v", account, err))
+			continue
+		}
+		c.Month = ledger.Month
+		c.Since = now
+		state.Cutoffs[account] = c
+		ctx.Output.Warning(fmt.Sprintf("'%s' exceeded its monthly quota and was cut off", account))
+	}
+
+	for account, r := range state.Resets {
+		if r.Month != ledger.Month {
+			delete(state.Resets, account)
+		}
+	}
+	return state.Save()
+}
+
+// socksBackendForUser returns the SOCKS backend authenticating user.
+func socksBackendForUser(cfg *config.Config, user string) *config.BackendConfig {
+	for i := range cfg.Backends {
+		b := &cfg.Backends[i]
+		if b.Type == config.BackendSOCKS && b.Socks != nil && b.Socks.User == user {
+			return b
+		}
+	}
+	return nil
+}
+
+// cutOffAccount blocks an account: SSH users are expired, SOCKS passwords
+// replaced, and tunnels stopped.
+func cutOffAccount(ctx *actions.Context, cfg *config.Config, account string) (*usage.Cutoff, error) {
+	kind, name, err := config.ParseQuotaAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &usage.Cutoff{}
+	switch kind {
+	case config.QuotaSSH:
+		if !system.UserExists(name) {
+			return nil, fmt.Errorf("user '%s' does not exist", name)
+		}
+		if err := system.ExpireUser(name); err != nil {
+			return nil, err
+		}
+
+	case config.QuotaSocks:
+		b := socksBackendForUser(cfg, name)
+		if b == nil {
+			return nil, fmt.Errorf("no SOCKS backend authenticates '%s'", name)
+		}
+		password, err := GeneratePassword(cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.Password = b.Socks.Password
+		b.Socks.Password = password
+		if err := saveBackend(cfg, b); err != nil {
+			return nil, fmt.Errorf("failed to save config: %w", err)
+		}
+		if err := proxy.ReconfigureSocks(cfg); err != nil {
+			return nil, fmt.Errorf("failed to reconfigure SOCKS proxy: %w", err)
+		}
+
+	case config.QuotaTunnel:
+		t := cfg.GetTunnelByTag(name)
+		if t == nil {
+			return nil, fmt.Errorf("tunnel '%s' does not exist", name)
+		}
+		c.Enabled = t.IsEnabled()
+		tunnel := router.NewTunnel(t)
+		if tunnel.IsActive() {
+			if err := tunnel.Stop(); err != nil {
+				return nil, fmt.Errorf("failed to stop tunnel: %w", err)
+			}
+			c.Stopped = true
+		}
+		if err := setTunnelEnabled(cfg, name, false); err != nil {
+			return nil, fmt.Errorf("failed to save config: %w", err)
+		}
+		if cfg.IsMultiMode() && t.Transport.IsDNS() {
+			if err := restartDNSRouterIfActive(); err != nil {
+				ctx.Output.Warning("Failed to update DNS router: " + err.Error())
+			}
+		}
+	}
+	return c, nil
+}
+
+// liftCutoff undoes cutOffAccount.
+func liftCutoff(ctx *actions.Context, cfg *config.Config, account string, c *usage.Cutoff) error {
+	kind, name, err := config.ParseQuotaAccount(account)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case config.QuotaSSH:
+		if system.UserExists(name) {
+			return system.UnexpireUser(name)
+		}
+
+	case config.QuotaSocks:
+		b := socksBackendForUser(cfg, name)
+		if b == nil || c.Password == "" {
+			return nil
+		}
+		b.Socks.Password = c.Password
+		if err := saveBackend(cfg, b); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		return proxy.ReconfigureSocks(cfg)
+
+	case config.QuotaTunnel:
+		t := cfg.GetTunnelByTag(name)
+		// Cutoffs recorded before Enabled existed only set Stopped
+		if t == nil || !(c.Enabled || c.Stopped) {
+			return nil
+		}
+		if err := setTunnelEnabled(cfg, name, true); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		// A tunnel that was not running stays stopped, and in single mode
+		// only the active tunnel runs
+		if !c.Stopped || (cfg.IsSingleMode() && t.Transport.IsDNS() && cfg.Route.Active != name) {
+			if cfg.IsMultiMode() && t.Transport.IsDNS() {
+				if err := restartDNSRouterIfActive(); err != nil {
+					ctx.Output.Warning("Failed to update DNS router: " + err.Error())
+				}
+			}
+			return nil
+		}
+		return enableAndStartTunnel(ctx, cfg, router.NewTunnel(t))
+	}
+	return nil
+}
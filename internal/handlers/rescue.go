@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/keys"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetRescueHandler(actions.ActionRescueEnable, HandleRescueEnable)
+	actions.SetRescueHandler(actions.ActionRescueDisable, HandleRescueDisable)
+	actions.SetRescueHandler(actions.ActionRescueStatus, HandleRescueStatus)
+}
+
+// HandleRescueEnable creates and starts the rescue tunnel.
+func HandleRescueEnable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if existing := cfg.GetRescueTunnel(); existing != nil {
+		return actions.NewActionError(
+			fmt.Sprintf("rescue tunnel already enabled on %s", existing.Domain),
+			"Check it with 'dnstm rescue status'",
+		)
+	}
+	if cfg.GetTunnelByTag(config.RescueTag) != nil {
+		return actions.NewActionError(
+			fmt.Sprintf("a tunnel tagged '%s' already exists", config.RescueTag),
+			"Remove it first; the tag is reserved for the rescue tunnel",
+		)
+	}
+
+	domain := strings.TrimSpace(ctx.GetString("domain"))
+	if domain == "" {
+		return actions.NewActionError("domain required", "Usage: dnstm rescue enable -d <domain>")
+	}
+	for _, t := range cfg.Tunnels {
+		if t.Transport.IsDNS() && t.Domain == domain {
+			return actions.NewActionError(
+				fmt.Sprintf("domain '%s' is already used by tunnel '%s'", domain, t.Tag),
+				"Use a domain reserved for rescue access",
+			)
+		}
+	}
+
+	cfg.EnsureBuiltinBackends()
+	backend := cfg.GetBackendByTag("ssh")
+	if backend == nil || backend.Type != config.BackendSSH {
+		return actions.NewActionError("no ssh backend configured", "Add one with 'dnstm backend add --type ssh'")
+	}
+
+	port, err := cfg.AllocateNextPort()
+	if err != nil {
+		return portRangeError(err)
+	}
+	tunnelCfg := config.TunnelConfig{
+		Tag:       config.RescueTag,
+		Transport: config.TransportDNSTT,
+		Backend:   backend.Tag,
+		Domain:    domain,
+		Port:      port,
+		BindHost:  strings.TrimSpace(ctx.GetString("bind-host")),
+		DNSTT:     &config.DNSTTConfig{MTU: 1232},
+		Watchdog:  &config.WatchdogConfig{Timeout: strings.TrimSpace(ctx.GetString("timeout"))},
+		Rescue:    true,
+	}
+	// In single mode the active tunnel holds port 53 on the external IP
+	if cfg.IsSingleMode() && tunnelCfg.BindHost == "" {
+		return actions.NewActionError(
+			"the rescue tunnel needs its own address in single mode",
+			"Pass --bind-host with a second public IP, or switch to multi mode with 'dnstm router mode multi'",
+		)
+	}
+	if tunnelCfg.BindHost != "" {
+		if err := checkBindHost(cfg, &tunnelCfg); err != nil {
+			return err
+		}
+	}
+
+	enabled := true
+	tunnelCfg.Enabled = &enabled
+	tunnelCfg.MarkCreated()
+	cfg.Tunnels = append(cfg.Tunnels, tunnelCfg)
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Use a watchdog timeout like 30s or 1m")
+	}
+	rescue := &cfg.Tunnels[len(cfg.Tunnels)-1]
+
+	beginProgress(ctx, "Enable Rescue Tunnel")
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := ensureTunnelService(ctx, rescue, cfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to create rescue tunnel: %w", err))
+	}
+	tunnel := router.NewTunnel(rescue)
+	if err := tunnel.SetPermissions(); err != nil {
+		ctx.Output.Warning("Permission warning: " + err.Error())
+	}
+	ctx.Output.Status("Service created")
+
+	// An enable racing this one created the same service; the last one wins
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		if stored := c.GetRescueTunnel(); stored != nil {
+			*stored = *rescue
+		} else {
+			c.Tunnels = append(c.Tunnels, *rescue)
+		}
+		return c.Validate()
+	}); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	if err := enableAndStartTunnel(ctx, cfg, tunnel); err != nil {
+		ctx.Output.Warning("Failed to start rescue tunnel: " + err.Error())
+	} else {
+		ctx.Output.Status("Rescue tunnel started")
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Rescue tunnel enabled on %s", domain))
+	if pubKey, err := keys.ReadPublicKey(filepath.Join(config.TunnelsDir, rescue.Tag, "server.pub")); err == nil {
+		ctx.Output.Println()
+		ctx.Output.Info("Public Key:")
+		ctx.Output.Println(pubKey)
+	}
+	ctx.Output.Println()
+	ctx.Output.Info(fmt.Sprintf("Keep a client config for operators only: dnstm tunnel share -t %s", rescue.Tag))
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+	return nil
+}
+
+// HandleRescueDisable stops and removes the rescue tunnel.
+func HandleRescueDisable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	rescue := cfg.GetRescueTunnel()
+	if rescue == nil {
+		return actions.NewActionError("rescue tunnel is not enabled", "Enable it with 'dnstm rescue enable -d <domain>'")
+	}
+	tag := rescue.Tag
+
+	beginProgress(ctx, "Disable Rescue Tunnel")
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	tunnel := router.NewTunnel(rescue)
+	if err := tunnel.RemoveService(); err != nil {
+		ctx.Output.Warning("Service removal warning: " + err.Error())
+	} else {
+		ctx.Output.Status("Service removed")
+	}
+	if err := tunnel.RemoveConfigDir(); err != nil {
+		ctx.Output.Warning("Config removal warning: " + err.Error())
+	} else {
+		ctx.Output.Status("Keys removed")
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		var tunnels []config.TunnelConfig
+		for _, t := range c.Tunnels {
+			if t.Tag != tag {
+				tunnels = append(tunnels, t)
+			}
+		}
+		c.Tunnels = tunnels
+		c.RemoveQuota(config.QuotaTunnel + ":" + tag)
+		return nil
+	}); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration updated")
+
+	if cfg.IsMultiMode() {
+		if err := restartDNSRouterIfActive(); err != nil {
+			ctx.Output.Warning("Failed to update DNS router: " + err.Error())
+		}
+	}
+
+	ctx.Output.Success("Rescue tunnel removed")
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+	return nil
+}
+
+// HandleRescueStatus shows the rescue tunnel like 'tunnel status'.
+func HandleRescueStatus(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	rescue := cfg.GetRescueTunnel()
+	if rescue == nil {
+		ctx.Output.Info("Rescue tunnel is not enabled. Enable it with: dnstm rescue enable -d <domain>")
+		return nil
+	}
+	if cfg.IsSingleMode() && rescue.BindHost == "" {
+		ctx.Output.Warning("The rescue tunnel has no bind_host and cannot run in single mode; re-enable it with --bind-host or switch to multi mode.")
+	}
+
+	ctx.Set("tag", rescue.Tag)
+	return HandleTunnelStatus(ctx)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/prompt"
+	"github.com/net2share/dnstm/internal/system"
+	"github.com/net2share/go-corelib/tui"
+)
+
+// currentLoad returns what this server has to spare, or nil where that is
+// unknown.
+func currentLoad() *config.SystemLoad {
+	avail, err := system.AvailableMemory()
+	if err != nil {
+		return nil
+	}
+	load, err := system.LoadAverage()
+	if err != nil {
+		return nil
+	}
+	return &config.SystemLoad{CPUs: runtime.NumCPU(), Load1: load, MemAvailable: avail}
+}
+
+// checkFootprint estimates what the new tunnel will use and warns when the
+// server is likely oversubscribed. In the menu the user may stop there.
+func checkFootprint(ctx *actions.Context, t *config.TunnelConfig) error {
+	f := config.EstimateFootprint(t.Transport)
+	estimate := fmt.Sprintf("%s tunnels use about %d MB of memory and %.0f%% of a CPU core under load",
+		config.GetTransportTypeDisplayName(t.Transport), f.Memory>>20, f.CPU*100)
+
+	load := currentLoad()
+	if load == nil {
+		return nil
+	}
+	reasons := f.Oversubscribed(*load)
+	if len(reasons) == 0 {
+		if !ctx.IsInteractive {
+			ctx.Output.Info(estimate)
+		}
+		return nil
+	}
+
+	if ctx.IsInteractive {
+		confirm, err := prompt.RunConfirm(tui.ConfirmConfig{
+			Title:       "Server looks oversubscribed",
+			Description: estimate + ".\n" + strings.Join(reasons, ".\n") + ".\n\nAdd the tunnel anyway?",
+			Default:     true,
+		})
+		if err != nil {
+			return err
+		}
+		if !confirm {
+			return actions.ErrCancelled
+		}
+		return nil
+	}
+	ctx.Output.Warning(estimate)
+	for _, reason := range reasons {
+		ctx.Output.Warning("Server likely oversubscribed: " + reason)
+	}
+	return nil
+}
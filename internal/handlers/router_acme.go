@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/network"
+)
+
+// acmeVerifyTimeout bounds how long set waits for the listener to serve a value.
+const acmeVerifyTimeout = 5 * time.Second
+
+func init() {
+	actions.SetRouterHandler(actions.ActionRouterACMETXT, HandleRouterACMETXT)
+}
+
+// HandleRouterACMETXT publishes, clears or lists ACME DNS-01 challenge records.
+func HandleRouterACMETXT(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get operation from input (interactive) or positional arg (CLI)
+	op := ctx.GetString("operation")
+	if op == "" && ctx.HasArg(0) {
+		op = ctx.GetArg(0)
+	}
+
+	switch op {
+	case "", "list":
+		return listACMEChallenges(ctx, cfg)
+	case "set", "clear":
+	default:
+		return actions.NewActionError(fmt.Sprintf("invalid operation '%s'", op), "Use 'set', 'clear' or 'list'")
+	}
+
+	domain := strings.TrimSpace(ctx.GetString("domain"))
+	value := strings.TrimSpace(ctx.GetString("value"))
+	if domain == "" {
+		return actions.NewActionError("domain is required", "Example: dnstm router acme-txt set --domain t.example.com --value <token>")
+	}
+	if op == "set" && value == "" {
+		return actions.NewActionError("value is required", "Example: dnstm router acme-txt set --domain t.example.com --value <token>")
+	}
+
+	now := time.Now()
+	challenges, err := dnsrouter.LoadACMEChallenges(now)
+	if err != nil {
+		return err
+	}
+	name := dnsrouter.ACMEChallengeName(domain)
+
+	var kept []dnsrouter.ACMEChallenge
+	removed := 0
+	for _, c := range challenges {
+		if c.Name == name && (op == "set" || value == "" || c.Value == value) {
+			removed++
+			if op == "clear" || c.Value == value {
+				continue
+			}
+		}
+		kept = append(kept, c)
+	}
+	if op == "set" {
+		kept = append(kept, dnsrouter.ACMEChallenge{Name: name, Value: value, Expires: now.Add(dnsrouter.ACMEExpiry)})
+	} else if removed == 0 {
+		ctx.Output.Info("No challenge values for " + name)
+		return nil
+	}
+
+	if err := dnsrouter.SaveACMEChallenges(kept); err != nil {
+		return err
+	}
+
+	if cfg.IsSingleMode() {
+		ctx.Output.Warning("Challenge records are only served by the DNS router. Switch with: dnstm router mode multi")
+	} else if !underDNSTunnel(cfg, name) {
+		ctx.Output.Warning(name + " is not under a DNS tunnel domain; it is only answered if delegated to this server")
+	}
+
+	svc := dnsrouter.NewService()
+	if !svc.IsActive() {
+		if op == "set" {
+			ctx.Output.Warning("DNS router is not running; the record is served once it starts")
+		}
+	} else if err := svc.Reload(); err != nil {
+		ctx.Output.Warning("Failed to reload DNS router: " + err.Error())
+	} else if op == "set" {
+		addr := net.JoinHostPort(loopbackFor(network.ResolveListenAddress(cfg.Listen.Address)))
+		if !waitForTXT(addr, name, value, acmeVerifyTimeout) {
+			ctx.Output.Warning("The DNS router did not serve the value within " + acmeVerifyTimeout.String())
+		}
+	}
+
+	if op == "set" {
+		ctx.Output.Success(fmt.Sprintf("Published %s TXT %q", name, value))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Cleared %d value(s) for %s", removed, name))
+	}
+	return nil
+}
+
+// listACMEChallenges prints the published challenge values.
+func listACMEChallenges(ctx *actions.Context, cfg *config.Config) error {
+	challenges, err := dnsrouter.LoadACMEChallenges(time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if len(challenges) == 0 {
+		ctx.Output.Info("No ACME challenge values published")
+		ctx.Output.Println("  Publish one with: dnstm router acme-txt set --domain <domain> --value <token>")
+		ctx.Output.Println()
+		return nil
+	}
+
+	ctx.Output.Printf("%-40s %-16s  %s\n", "NAME", "EXPIRES", "VALUE")
+	ctx.Output.Separator(80)
+	for _, c := range challenges {
+		ctx.Output.Printf("%-40s %-16s  %s\n", c.Name, c.Expires.Local().Format("2006-01-02 15:04"), c.Value)
+	}
+	ctx.Output.Println()
+	if cfg.IsSingleMode() {
+		ctx.Output.Warning("Challenge records are only served by the DNS router. Switch with: dnstm router mode multi")
+		ctx.Output.Println()
+	}
+	return nil
+}
+
+// underDNSTunnel reports whether name falls under the domain of a DNS tunnel.
+func underDNSTunnel(cfg *config.Config, name string) bool {
+	for _, t := range cfg.Tunnels {
+		if t.Transport.IsDNS() && dnsrouter.MatchDomainSuffix(name, t.Domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// loopbackFor returns the host and port to query a listener on, using the
+// loopback address when it listens on all addresses.
+func loopbackFor(listenAddr string) (string, string) {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "127.0.0.1", "53"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return host, port
+}
+
+// waitForTXT polls the listener until it serves value for name.
+func waitForTXT(addr, name, value string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		values, _ := dnsrouter.LookupTXT(addr, name, time.Second)
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/network"
+)
+
+func init() {
+	actions.SetRouterHandler(actions.ActionRouterDecoy, HandleRouterDecoy)
+}
+
+// HandleRouterDecoy enables or disables decoy answers from a zone file.
+func HandleRouterDecoy(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.GetBool("disable") {
+		if cfg.Route.Decoy == nil {
+			ctx.Output.Info("Decoy zone is not enabled")
+			return nil
+		}
+		cfg.Route.Decoy = nil
+		if err := updateConfig(cfg, func(c *config.Config) error {
+			c.Route.Decoy = cfg.Route.Decoy
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		if err := restartDNSRouterIfActive(); err != nil {
+			ctx.Output.Warning("Failed to restart DNS router: " + err.Error())
+		}
+		ctx.Output.Success("Decoy zone disabled")
+		return nil
+	}
+
+	path := strings.TrimSpace(ctx.GetString("zone"))
+	if path == "" {
+		path = config.DefaultDecoyZone
+	}
+	if !filepath.IsAbs(path) {
+		return actions.NewActionError("zone file must be an absolute path", "Example: --zone "+config.DefaultDecoyZone)
+	}
+
+	beginProgress(ctx, "Decoy Zone")
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := writeStarterZone(cfg, path); err != nil {
+			return failProgress(ctx, err)
+		}
+		ctx.Output.Status("Wrote starter zone to " + path)
+	}
+
+	zone, err := dnsrouter.LoadZone(path)
+	if err != nil {
+		return failProgress(ctx, actions.NewActionError(err.Error(), "Fix the zone file and run the command again"))
+	}
+	ctx.Output.Status("Zone loaded: " + strings.Join(zone.Domains(), ", "))
+
+	cfg.Route.Decoy = &config.DecoyConfig{ZoneFile: path}
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Route.Decoy = cfg.Route.Decoy
+		return nil
+	}); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	if err := restartDNSRouterIfActive(); err != nil {
+		ctx.Output.Warning("Failed to restart DNS router: " + err.Error())
+	}
+
+	ctx.Output.Success("Decoy zone enabled")
+	if cfg.IsSingleMode() {
+		ctx.Output.Warning("The zone is only served by the DNS router. Switch with: dnstm router mode multi")
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+	return nil
+}
+
+// writeStarterZone writes a zone answering SOA, NS and A queries for every
+// DNS tunnel domain with the server's external address.
+func writeStarterZone(cfg *config.Config, path string) error {
+	var domains []string
+	for _, t := range cfg.Tunnels {
+		if t.Transport.IsDNS() {
+			domains = append(domains, t.Domain)
+		}
+	}
+	if len(domains) == 0 {
+		return actions.NewActionError("no DNS tunnels to write a starter zone for", "Add a tunnel first or pass an existing zone with --zone")
+	}
+
+	ip, err := network.GetExternalIP()
+	if err != nil {
+		return fmt.Errorf("failed to detect external IP: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create zone directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(dnsrouter.StarterZone(domains, ip, time.Now())), 0644); err != nil {
+		return fmt.Errorf("failed to write zone file: %w", err)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 func init() {
 	actions.SetRouterHandler(actions.ActionRouterStart, HandleRouterStart)
+	actions.SetRouterHandler(actions.ActionRouterRestart, HandleRouterStart)
 	actions.SetRouterHandler(actions.ActionRouterStop, HandleRouterStop)
 }
 
@@ -27,6 +28,10 @@ func HandleRouterStart(ctx *actions.Context) error {
 	modeName := GetModeDisplayName(cfg.Route.Mode)
 	isRunning := r.IsRunning()
 
+	if err := resolvePort53Conflicts(ctx, cfg, cfg.Route.Mode); err != nil {
+		return err
+	}
+
 	if isRunning {
 		beginProgress(ctx, "Restart Router")
 	} else {
@@ -42,6 +47,7 @@ func HandleRouterStart(ctx *actions.Context) error {
 		ctx.Output.Info(fmt.Sprintf("Starting in %s mode...", modeName))
 	}
 
+	guardFirewall(ctx, cfg, "router start")
 	if err := r.Restart(); err != nil {
 		return failProgress(ctx, fmt.Errorf("failed to start: %w", err))
 	}
@@ -80,6 +80,12 @@ func switchMode(ctx *actions.Context, cfg *config.Config, newMode string) error
 		return nil
 	}
 
+	if newMode == "multi" {
+		if err := checkMultiModeMemory(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
 	r, err := router.New(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create router: %w", err)
@@ -87,12 +93,18 @@ func switchMode(ctx *actions.Context, cfg *config.Config, newMode string) error
 
 	oldModeName := GetModeDisplayName(cfg.Route.Mode)
 
+	if err := resolvePort53Conflicts(ctx, cfg, newMode); err != nil {
+		return err
+	}
+
 	beginProgress(ctx, fmt.Sprintf("Switch to %s", newModeName))
 	if !ctx.IsInteractive {
 		ctx.Output.Println()
 	}
 
 	ctx.Output.Info(fmt.Sprintf("Switching from %s to %s...", oldModeName, newModeName))
+	createAutoSnapshot(ctx, "before mode switch")
+	guardFirewall(ctx, cfg, "mode switch to "+newMode)
 
 	if err := r.SwitchMode(newMode); err != nil {
 		return failProgress(ctx, fmt.Errorf("failed to switch mode: %w", err))
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/installer"
+)
+
+func init() {
+	actions.SetRouterHandler(actions.ActionRouterReset, HandleRouterReset)
+}
+
+// HandleRouterReset removes all tunnels and resets routing, optionally
+// preserving certificates and keys.
+func HandleRouterReset(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := installer.ResetOptions{
+		KeepCerts: ctx.GetBool("keep-certs"),
+		KeepKeys:  ctx.GetBool("keep-keys"),
+	}
+	plan := installer.PlanRouterReset(cfg, opts)
+
+	ctx.Output.Println()
+	printResetPlan(ctx, plan)
+
+	if ctx.GetBool("dry-run") {
+		ctx.Output.Info("Dry run: nothing was changed")
+		ctx.Output.Println()
+		return nil
+	}
+
+	createAutoSnapshot(ctx, "before router reset")
+
+	if err := installer.PerformRouterReset(cfg, plan); err != nil {
+		return fmt.Errorf("failed to reset router: %w", err)
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Router reset (%d tunnels removed)", len(plan.Tunnels)))
+	if len(plan.KeepFiles) > 0 {
+		ctx.Output.Info("Kept key material is reused when a tunnel is re-added with the same tag")
+	}
+	ctx.Output.Println()
+	return nil
+}
+
+// printResetPlan lists what a router reset removes and keeps.
+func printResetPlan(ctx *actions.Context, plan *installer.ResetPlan) {
+	section := func(title string, items []string) {
+		ctx.Output.Printf("%s (%d):\n", title, len(items))
+		if len(items) == 0 {
+			ctx.Output.Println("  (none)")
+		}
+		for _, item := range items {
+			ctx.Output.Printf("  - %s\n", item)
+		}
+		ctx.Output.Println()
+	}
+
+	section("Tunnels to remove", plan.Tunnels)
+	section("Services to remove", plan.Services)
+	section("Files to delete", plan.RemoveFiles)
+	section("Firewall rules to delete", plan.FirewallRules)
+	if len(plan.KeepFiles) > 0 {
+		section("Files to keep", plan.KeepFiles)
+	}
+}
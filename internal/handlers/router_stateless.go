@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	actions.SetRouterHandler(actions.ActionRouterStateless, HandleRouterStateless)
+}
+
+// HandleRouterStateless enables or disables routing by query name alone.
+func HandleRouterStateless(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	enable := !ctx.GetBool("disable")
+	if cfg.Route.Stateless == enable {
+		if enable {
+			ctx.Output.Info("Stateless routing is already enabled")
+		} else {
+			ctx.Output.Info("Stateless routing is not enabled")
+		}
+		return nil
+	}
+
+	cfg.Route.Stateless = enable
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Pause canaries with: dnstm tunnel canary -t <tag> --percent 0")
+	}
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Route.Stateless = cfg.Route.Stateless
+		return c.Validate()
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := restartDNSRouterIfActive(); err != nil {
+		ctx.Output.Warning("Failed to restart DNS router: " + err.Error())
+	}
+
+	if !enable {
+		ctx.Output.Success("Stateless routing disabled")
+		return nil
+	}
+	ctx.Output.Success("Stateless routing enabled: backends are picked by query name alone")
+	if cfg.IsSingleMode() {
+		ctx.Output.Warning("Routing is done by the DNS router. Switch with: dnstm router mode multi")
+	}
+	return nil
+}
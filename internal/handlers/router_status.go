@@ -5,6 +5,7 @@ import (
 
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
 	"github.com/net2share/dnstm/internal/router"
 )
 
@@ -23,6 +24,7 @@ func HandleRouterStatus(ctx *actions.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to create router: %w", err)
 	}
+	router.PrefetchStates(cfg.Tunnels)
 
 	// Build info config for TUI
 	infoCfg := actions.InfoConfig{
@@ -92,6 +94,12 @@ func HandleRouterStatus(ctx *actions.Context) error {
 				{Key: "DNS Router", Value: fmt.Sprintf("%s (port 53)", routerStatus)},
 			},
 		}
+		if cfg.Route.Decoy != nil {
+			mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Decoy Zone", Value: cfg.Route.Decoy.ZoneFile})
+		}
+		if cfg.Route.Stateless {
+			mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Routing", Value: "stateless (by query name)"})
+		}
 		infoCfg.Sections = append(infoCfg.Sections, mainSection)
 
 		// Tunnels section
@@ -126,6 +134,14 @@ func HandleRouterStatus(ctx *actions.Context) error {
 			}
 		}
 		infoCfg.Sections = append(infoCfg.Sections, tunnelSection)
+
+		if clamping := clampingResolverLines(); len(clamping) > 0 {
+			resolverSection := actions.InfoSection{Title: "Clamping Resolvers"}
+			for _, line := range clamping {
+				resolverSection.Rows = append(resolverSection.Rows, actions.InfoRow{Value: line})
+			}
+			infoCfg.Sections = append(infoCfg.Sections, resolverSection)
+		}
 	}
 
 	// Display using TUI in interactive mode
@@ -177,6 +193,12 @@ func HandleRouterStatus(ctx *actions.Context) error {
 			routerStatus = actions.SymbolError + " Not installed"
 		}
 		lines = append(lines, fmt.Sprintf("DNS Router: %s (port 53)", routerStatus))
+		if cfg.Route.Decoy != nil {
+			lines = append(lines, fmt.Sprintf("Decoy zone: %s", cfg.Route.Decoy.ZoneFile))
+		}
+		if cfg.Route.Stateless {
+			lines = append(lines, "Routing: stateless (by query name)")
+		}
 		lines = append(lines, "")
 		lines = append(lines, "Tunnels:")
 
@@ -202,6 +224,14 @@ func HandleRouterStatus(ctx *actions.Context) error {
 				lines = append(lines, fmt.Sprintf("    %s %s %s 127.0.0.1:%d", actions.SymbolBranch, tunnel.Domain, actions.SymbolArrow, tunnel.Port))
 			}
 		}
+
+		if clamping := clampingResolverLines(); len(clamping) > 0 {
+			lines = append(lines, "")
+			lines = append(lines, "Clamping resolvers:")
+			for _, line := range clamping {
+				lines = append(lines, "  "+line)
+			}
+		}
 	}
 
 	ctx.Output.Box("Router Status", lines)
@@ -209,3 +239,28 @@ func HandleRouterStatus(ctx *actions.Context) error {
 
 	return nil
 }
+
+// maxClampingResolvers limits the resolvers listed in the status.
+const maxClampingResolvers = 5
+
+// clampingResolverLines describes the resolvers that truncate or are sent
+// responses larger than they accept, from the counters the DNS router saves.
+func clampingResolverLines() []string {
+	stats, err := dnsrouter.LoadResolverStats()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var lines []string
+	for _, s := range stats {
+		if !s.IsClamping() {
+			continue
+		}
+		if len(lines) == maxClampingResolvers {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%-39s %3.0f%% of %d responses, EDNS0 payload %s",
+			s.Addr, 100*s.ClampRatio(), s.Responses, dnsrouter.PayloadString(s.Payload)))
+	}
+	return lines
+}
@@ -64,12 +64,17 @@ func HandleRouterSwitch(ctx *actions.Context) error {
 		return fmt.Errorf("failed to create router: %w", err)
 	}
 
+	if err := resolvePort53Conflicts(ctx, cfg, "single"); err != nil {
+		return err
+	}
+
 	beginProgress(ctx, "Switch Active Tunnel")
 	if !ctx.IsInteractive {
 		ctx.Output.Println()
 	}
 
 	ctx.Output.Info(fmt.Sprintf("Switching to '%s'...", tunnelTag))
+	guardFirewall(ctx, cfg, "switch to "+tunnelTag)
 
 	if err := r.SwitchActiveTunnel(tunnelTag); err != nil {
 		return failProgress(ctx, fmt.Errorf("failed to switch tunnel: %w", err))
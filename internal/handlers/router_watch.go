@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// watchPollInterval is how often states are polled without D-Bus.
+const watchPollInterval = 2 * time.Second
+
+func init() {
+	actions.SetRouterHandler(actions.ActionRouterWatch, HandleRouterWatch)
+}
+
+// HandleRouterWatch prints service state changes until interrupted.
+func HandleRouterWatch(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	names := []string{dnsrouter.ServiceName, proxy.MicrosocksServiceName}
+	for _, t := range cfg.Tunnels {
+		names = append(names, router.GetServiceName(t.Tag))
+	}
+
+	polling := false
+	events, err := service.WatchServices(sigCtx, func(name string) bool {
+		return strings.HasPrefix(name, paths.ServicePrefix) || name == proxy.MicrosocksServiceName
+	})
+	if err != nil {
+		ctx.Output.Warning("Polling every 2s, no D-Bus: " + err.Error())
+		events = service.PollServices(sigCtx, names, watchPollInterval)
+		polling = true
+	}
+
+	ctx.Output.Info("Watching dnstm services, press Ctrl+C to stop")
+	for {
+		for ev := range events {
+			state := ev.ActiveState
+			if ev.SubState != "" {
+				state += " (" + ev.SubState + ")"
+			}
+			ctx.Output.Printf("%s  %-28s %s\n", ev.Time.Format("15:04:05"), ev.Service, state)
+		}
+		if sigCtx.Err() != nil || polling {
+			return nil
+		}
+		ctx.Output.Warning("Lost the D-Bus connection, polling every 2s")
+		events = service.PollServices(sigCtx, names, watchPollInterval)
+		polling = true
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	actions.SetSecretHandler(actions.ActionSecretGen, HandleSecretGen)
+}
+
+// HandleSecretGen prints a random secret.
+func HandleSecretGen(ctx *actions.Context) error {
+	var policy config.SecretsConfig
+	if config.ConfigExists() {
+		cfg, err := LoadConfig(ctx)
+		if err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Using the default secret policy: failed to load config: %v", err))
+		} else {
+			policy = cfg.Secrets
+		}
+	}
+
+	if format := ctx.GetString("format"); format != "" {
+		policy.Format = format
+		if format != config.SecretFormatChars {
+			policy.Charset = ""
+		}
+	}
+	if length := ctx.GetInt("length"); length != 0 {
+		policy.Length = length
+	}
+	if charset := ctx.GetString("charset"); charset != "" {
+		policy.Format = config.SecretFormatChars
+		policy.Charset = charset
+	}
+
+	if err := policy.Validate(); err != nil {
+		return actions.UsageError(fmt.Sprintf("invalid secret policy: %v", err), "Run 'dnstm secret gen --help' for the options")
+	}
+	secret, err := policy.Generate()
+	if err != nil {
+		return err
+	}
+	fmt.Println(secret)
+	return nil
+}
+
+// GeneratePassword generates a random password following the secret
+// policy of the config.
+func GeneratePassword(cfg *config.Config) (string, error) {
+	password, err := cfg.Secrets.Generate()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return password, nil
+}
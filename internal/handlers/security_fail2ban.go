@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/fail2ban"
+	"github.com/net2share/dnstm/internal/network"
+)
+
+func init() {
+	actions.SetSecurityHandler(actions.ActionSecurityFail2banEnable, HandleFail2banEnable)
+	actions.SetSecurityHandler(actions.ActionSecurityFail2banDisable, HandleFail2banDisable)
+	actions.SetSecurityHandler(actions.ActionSecurityFail2banStatus, HandleFail2banStatus)
+}
+
+// applyFail2banJails writes the dnstm jails for cfg and reloads fail2ban.
+func applyFail2banJails(cfg *config.Config) ([]fail2ban.Jail, error) {
+	if !fail2ban.IsInstalled() {
+		return nil, actions.NewActionError("fail2ban is not installed", "Install it with your package manager (e.g. 'apt install fail2ban') and try again")
+	}
+	// The server's own addresses must never be banned
+	ips, _ := network.ExternalIPs()
+	jails := fail2ban.Jails(cfg, ips)
+	if err := fail2ban.Apply(jails); err != nil {
+		return nil, err
+	}
+	return jails, nil
+}
+
+// HandleFail2banEnable generates the dnstm fail2ban jails.
+func HandleFail2banEnable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	jails, err := applyFail2banJails(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	for _, j := range jails {
+		ports := make([]string, len(j.Ports))
+		for i, p := range j.Ports {
+			ports[i] = fmt.Sprint(p)
+		}
+		ctx.Output.Status(fmt.Sprintf("%s: ports %s, ban for %s after %d failures in %s",
+			j.Name, strings.Join(ports, ","), j.BanTime, j.MaxRetry, j.FindTime))
+	}
+	ctx.Output.Success("fail2ban jails enabled")
+	ctx.Output.Info("Check bans with: dnstm security fail2ban status")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleFail2banDisable removes the dnstm fail2ban jails.
+func HandleFail2banDisable(ctx *actions.Context) error {
+	if !fail2ban.IsEnabled() {
+		ctx.Output.Info("dnstm fail2ban jails are not enabled")
+		return nil
+	}
+	if err := fail2ban.Remove(); err != nil {
+		return err
+	}
+	ctx.Output.Success("fail2ban jails removed")
+	return nil
+}
+
+// HandleFail2banStatus shows the state of the dnstm fail2ban jails.
+func HandleFail2banStatus(ctx *actions.Context) error {
+	if !fail2ban.IsEnabled() {
+		ctx.Output.Info("dnstm fail2ban jails are not enabled. Enable them with: dnstm security fail2ban enable")
+		return nil
+	}
+	if !fail2ban.IsInstalled() {
+		return actions.NewActionError("fail2ban is not installed", "Reinstall fail2ban, or remove the jails with 'dnstm security fail2ban disable'")
+	}
+
+	ctx.Output.Println()
+	status, err := fail2ban.GetJailStatus(fail2ban.SSHJail)
+	if err != nil {
+		ctx.Output.Warning(err.Error())
+		ctx.Output.Println()
+		return nil
+	}
+	banned := "-"
+	if len(status.BannedIPs) > 0 {
+		banned = strings.Join(status.BannedIPs, ", ")
+	}
+	ctx.Output.Printf("Jail:     %s\n", status.Name)
+	ctx.Output.Printf("Failures: %d now, %d total\n", status.CurrentlyFailed, status.TotalFailed)
+	ctx.Output.Printf("Bans:     %d now, %d total\n", status.CurrentlyBanned, status.TotalBanned)
+	ctx.Output.Printf("Banned:   %s\n", banned)
+	ctx.Output.Println()
+	return nil
+}
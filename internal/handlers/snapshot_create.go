@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/snapshot"
+	"github.com/net2share/dnstm/internal/version"
+)
+
+func init() {
+	actions.SetSnapshotHandler(actions.ActionSnapshotCreate, HandleSnapshotCreate)
+}
+
+// HandleSnapshotCreate captures the current system state.
+func HandleSnapshotCreate(ctx *actions.Context) error {
+	reason := ctx.GetString("note")
+	if reason == "" {
+		reason = "manual"
+	}
+
+	m, err := snapshot.Create(reason, version.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Snapshot %s created (%d services, %d paths)", m.ID, len(m.Units), len(m.Paths)))
+	return nil
+}
+
+// createAutoSnapshot snapshots the system before a risky operation.
+// Failures are reported as warnings so they never block the operation.
+func createAutoSnapshot(ctx *actions.Context, reason string) {
+	m, err := snapshot.CreateAuto(reason, version.Version)
+	if err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to create snapshot: %v", err))
+		return
+	}
+	ctx.Output.Status(fmt.Sprintf("Snapshot %s created (restore with 'dnstm snapshot rollback %s')", m.ID, m.ID))
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/snapshot"
+)
+
+func init() {
+	actions.SetSnapshotHandler(actions.ActionSnapshotList, HandleSnapshotList)
+	actions.SetSnapshotHandler(actions.ActionSnapshotRemove, HandleSnapshotRemove)
+}
+
+// HandleSnapshotList lists stored snapshots.
+func HandleSnapshotList(ctx *actions.Context) error {
+	manifests, err := snapshot.List()
+	if err != nil {
+		return err
+	}
+
+	if len(manifests) == 0 {
+		ctx.Output.Println("No snapshots found")
+		return nil
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Printf("%-20s %-20s %-10s %-10s %s\n", "ID", "CREATED", "SERVICES", "VERSION", "REASON")
+	ctx.Output.Separator(80)
+
+	for _, m := range manifests {
+		ctx.Output.Printf("%-20s %-20s %-10d %-10s %s\n",
+			m.ID, m.Created.Local().Format("2006-01-02 15:04:05"), len(m.Units), m.Version, m.Reason)
+	}
+	ctx.Output.Println()
+
+	return nil
+}
+
+// HandleSnapshotRemove deletes a stored snapshot.
+func HandleSnapshotRemove(ctx *actions.Context) error {
+	id := ctx.GetArg(0)
+	if id == "" {
+		return actions.NewActionError("snapshot id required", "Usage: dnstm snapshot remove <id>")
+	}
+
+	if err := snapshot.Remove(id); err != nil {
+		return err
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Snapshot %s removed", id))
+	return nil
+}
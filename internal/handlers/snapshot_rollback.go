@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/snapshot"
+)
+
+func init() {
+	actions.SetSnapshotHandler(actions.ActionSnapshotRollback, HandleSnapshotRollback)
+}
+
+// HandleSnapshotRollback restores the system to a stored snapshot.
+func HandleSnapshotRollback(ctx *actions.Context) error {
+	id := ctx.GetArg(0)
+	if id == "" {
+		return actions.NewActionError("snapshot id required", "Usage: dnstm snapshot rollback <id|latest>")
+	}
+
+	m, err := snapshot.Get(id)
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Run 'dnstm snapshot list' to see available snapshots")
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Rollback: %s", m.ID))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	ctx.Output.Info(fmt.Sprintf("Restoring snapshot from %s (%s)", m.Created.Local().Format("2006-01-02 15:04:05"), m.Reason))
+
+	if err := snapshot.Rollback(m); err != nil {
+		return failProgress(ctx, fmt.Errorf("rollback failed: %w", err))
+	}
+
+	ctx.Output.Status(fmt.Sprintf("Restored %d paths", len(m.Paths)))
+	ctx.Output.Status(fmt.Sprintf("Started %d of %d services", len(m.Active), len(m.Units)))
+	ctx.Output.Success(fmt.Sprintf("Snapshot %s restored", m.ID))
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/metrics"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/usage"
+)
+
+func init() {
+	actions.SetStatsHandler(actions.ActionStatsGraph, HandleStatsGraph)
+	actions.SetStatsHandler(actions.ActionStatsEnable, HandleStatsEnable)
+	actions.SetStatsHandler(actions.ActionStatsDisable, HandleStatsDisable)
+	actions.SetStatsHandler(actions.ActionStatsCollect, HandleStatsCollect)
+}
+
+// routerCountersMaxAge is how old the router's saved counters may be and
+// still be recorded; the router saves them every minute while it runs.
+const routerCountersMaxAge = 2 * time.Minute
+
+// socksStatsMaxAge is the same for the counters of the built-in SOCKS
+// proxy.
+const socksStatsMaxAge = 2 * time.Minute
+
+// HandleStatsGraph draws the recorded series as sparklines.
+func HandleStatsGraph(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	sinceFlag := strings.TrimSpace(ctx.GetString("since"))
+	if sinceFlag == "" {
+		sinceFlag = "24h"
+	}
+	since, err := config.ParseDays(sinceFlag)
+	if err != nil || since <= 0 {
+		return actions.UsageError(fmt.Sprintf("invalid time range '%s'", sinceFlag), "Example: --since 24h or --since 7d")
+	}
+	width := ctx.GetInt("width")
+	if width <= 0 {
+		width = 60
+	}
+
+	now := time.Now()
+	from := now.Add(-since)
+	samples, err := metrics.Load(from, now)
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		if cfg.Metrics == nil {
+			ctx.Output.Info("Metrics are not recorded")
+			ctx.Output.Println("  Enable them with: dnstm stats enable")
+		} else {
+			ctx.Output.Info(fmt.Sprintf("No samples in the last %s", sinceFlag))
+		}
+		return nil
+	}
+
+	names := filterSeries(metrics.Names(samples), ctx.GetString("tag"), ctx.GetString("series"))
+	if len(names) == 0 {
+		ctx.Output.Info("No recorded series match")
+		return nil
+	}
+	nameWidth := 0
+	for _, name := range names {
+		nameWidth = max(nameWidth, len(name))
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Printf("Last %s, %s per character\n\n", sinceFlag, (since / time.Duration(width)).Round(time.Second))
+	for _, name := range names {
+		points := metrics.Series(samples, name, from, now, width)
+		ctx.Output.Printf("%-*s  %s  %s\n", nameWidth, name, metrics.Sparkline(points), describeSeries(name, points))
+	}
+	ctx.Output.Println()
+	return nil
+}
+
+// filterSeries keeps the series of one tunnel and of the listed series or
+// kinds. Empty filters keep everything.
+func filterSeries(names []string, tag, series string) []string {
+	var wanted []string
+	for _, s := range strings.Split(series, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			wanted = append(wanted, s)
+		}
+	}
+
+	var kept []string
+	for _, name := range names {
+		kind, seriesTag, _ := strings.Cut(name, ".")
+		if tag != "" && seriesTag != tag {
+			continue
+		}
+		if len(wanted) > 0 {
+			found := false
+			for _, w := range wanted {
+				if w == name || w == kind {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		kept = append(kept, name)
+	}
+	return kept
+}
+
+// describeSeries summarizes the points of a series in its unit.
+func describeSeries(name string, points []float64) string {
+	_, avg, high, ok := metrics.Summary(points)
+	if !ok {
+		return "no data"
+	}
+	kind, _, _ := strings.Cut(name, ".")
+	switch kind {
+	case metrics.SeriesUp:
+		return fmt.Sprintf("up %.1f%%", 100*avg)
+	case metrics.SeriesQueries:
+		return fmt.Sprintf("avg %.1f q/s, max %.1f q/s", avg, high)
+	case metrics.SeriesBytes, metrics.SeriesRelayed:
+		return fmt.Sprintf("avg %s/s, max %s/s", usage.FormatBytes(uint64(avg)), usage.FormatBytes(uint64(high)))
+	case metrics.SeriesConns:
+		return fmt.Sprintf("avg %.1f, max %.0f connections", avg, high)
+	}
+	return fmt.Sprintf("avg %.2f, max %.2f", avg, high)
+}
+
+// HandleStatsEnable installs the metrics timer.
+func HandleStatsEnable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	m := cfg.Metrics
+	if m == nil {
+		m = &config.MetricsConfig{}
+	}
+	if retention := strings.TrimSpace(ctx.GetString("retention")); retention != "" {
+		m.Retention = retention
+	}
+	cfg.Metrics = m
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Example: --retention 30d")
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Metrics = cfg.Metrics
+		return c.Validate()
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := metrics.SyncTimer(cfg); err != nil {
+		return fmt.Errorf("failed to install metrics timer: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success(fmt.Sprintf("Metrics are recorded every minute and kept for %s", formatRetention(m.GetRetention())))
+	ctx.Output.Info("View with: dnstm stats graph")
+	ctx.Output.Println()
+	return nil
+}
+
+// formatRetention formats a retention in days when it is a whole number
+// of them.
+func formatRetention(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// HandleStatsDisable removes the metrics timer and keeps the samples.
+func HandleStatsDisable(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if cfg.Metrics == nil && !service.IsTimerInstalled(metrics.TimerName) {
+		ctx.Output.Info("Metrics are not recorded")
+		return nil
+	}
+
+	cfg.Metrics = nil
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Metrics = nil
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := metrics.SyncTimer(cfg); err != nil {
+		return fmt.Errorf("failed to remove metrics timer: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Success("Metrics recording disabled (samples kept in " + metrics.Dir + ")")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleStatsCollect records a sample and deletes samples past the
+// retention. It is run by the metrics timer.
+func HandleStatsCollect(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if cfg.Metrics == nil {
+		ctx.Output.Info("Metrics are not recorded")
+		ctx.Output.Println("  Enable them with: dnstm stats enable")
+		return nil
+	}
+
+	now := time.Now()
+	if err := metrics.Append(sampleMetrics(cfg, now)); err != nil {
+		return err
+	}
+	return metrics.Prune(cfg.Metrics.GetRetention(), now)
+}
+
+// sampleMetrics reads the current value of every series.
+func sampleMetrics(cfg *config.Config, now time.Time) metrics.Sample {
+	values := make(map[string]float64)
+	tunnels := cfg.GetEnabledTunnels()
+
+	router.PrefetchStates(cfg.Tunnels)
+	for _, t := range tunnels {
+		values[metrics.Name(metrics.SeriesUp, t.Tag)] = boolValue(router.NewTunnel(t).IsActive())
+	}
+
+	if cfg.IsMultiMode() {
+		values[metrics.Name(metrics.SeriesUp, "dnsrouter")] = boolValue(dnsrouter.NewService().IsActive())
+		if c, err := dnsrouter.LoadCounters(); err == nil && c != nil && now.Sub(c.Updated) < routerCountersMaxAge {
+			values[metrics.SeriesQueries] = float64(c.Queries)
+			for _, t := range tunnels {
+				if n, ok := c.Backends[fmt.Sprintf("127.0.0.1:%d", t.Port)]; ok {
+					values[metrics.Name(metrics.SeriesQueries, t.Tag)] = float64(n)
+				}
+			}
+		}
+	}
+
+	if cfg.Proxy.IsBuiltinEngine() {
+		if st, err := proxy.LoadSocksStats(); err == nil && st != nil && now.Sub(st.Updated) < socksStatsMaxAge {
+			values[metrics.Name(metrics.SeriesConns, "socks")] = float64(st.ActiveConns)
+			values[metrics.Name(metrics.SeriesRelayed, "socks")] = float64(st.BytesIn + st.BytesOut)
+		}
+	}
+
+	// Tunnel traffic is only known with usage accounting on
+	if service.IsTimerInstalled(usage.TimerName) {
+		if ledger, err := usage.Load(usage.Month(now)); err == nil {
+			for _, t := range tunnels {
+				values[metrics.Name(metrics.SeriesBytes, t.Tag)] = float64(ledger.Bytes(config.QuotaTunnel + ":" + t.Tag))
+			}
+		}
+	}
+
+	return metrics.Sample{Time: now, Values: values}
+}
+
+// boolValue returns 1 for true and 0 for false.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"strings"
 
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/binary"
 	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/fail2ban"
 	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/packaging"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/presets"
 	"github.com/net2share/dnstm/internal/proxy"
 	"github.com/net2share/dnstm/internal/router"
 	"github.com/net2share/dnstm/internal/system"
@@ -17,34 +23,64 @@ import (
 	"github.com/net2share/dnstm/internal/updater"
 )
 
-const installPath = "/usr/local/bin/dnstm"
+var installPath = binary.ExeName(paths.Bin("dnstm"))
 
 func init() {
 	actions.SetSystemHandler(actions.ActionInstall, HandleInstall)
 }
 
-// HandleInstall performs system installation.
+// HandleInstall performs system installation. It is safe to re-run: every
+// step checks what is already in place and only installs or repairs what is
+// missing, and the run ends with a summary of what changed.
 func HandleInstall(ctx *actions.Context) error {
 	force := ctx.GetBool("force")
+	installed := router.IsInitialized()
 
-	// Check if already installed
-	if router.IsInitialized() && !force {
-		// If binaries are missing, install just the missing ones
-		missing := transport.GetMissingBinaries()
-		if len(missing) > 0 {
-			return installMissingBinaries(ctx, missing)
-		}
-		return fmt.Errorf("dnstm is already installed. Use --force to reinstall")
+	modeStr := ctx.GetString("mode")
+	if modeStr != "" && modeStr != "single" && modeStr != "multi" {
+		return fmt.Errorf("invalid mode: %s (must be 'single' or 'multi')", modeStr)
 	}
 
-	modeStr := ctx.GetString("mode")
+	socksEngine := ctx.GetString("socks-engine")
+	if socksEngine != "" && socksEngine != config.ProxyEngineMicrosocks && socksEngine != config.ProxyEngineBuiltin {
+		return fmt.Errorf("invalid socks engine: %s (must be '%s' or '%s')", socksEngine, config.ProxyEngineMicrosocks, config.ProxyEngineBuiltin)
+	}
 
-	// Default to single mode if not specified
-	if modeStr == "" {
-		modeStr = "single"
+	var profile *config.Profile
+	if name := ctx.GetString("profile"); name != "" {
+		p, err := config.GetProfile(name)
+		if err != nil {
+			return actions.NewActionError(err.Error(), "")
+		}
+		profile = &p
 	}
-	if modeStr != "single" && modeStr != "multi" {
-		return fmt.Errorf("invalid mode: %s (must be 'single' or 'multi')", modeStr)
+
+	// Resolve the preset up front so a bad name fails before anything is installed
+	var preset *presets.Preset
+	baseDomain := ctx.GetString("domain")
+	if name := ctx.GetString("preset"); name != "" {
+		p, err := presets.Get(name)
+		if err != nil {
+			return actions.NewActionError(err.Error(), "Run 'dnstm install --help' to list presets")
+		}
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if baseDomain == "" {
+			return actions.NewActionError("--domain is required with --preset", "Usage: dnstm install --preset "+name+" --domain example.com")
+		}
+		preset = p
+		// The preset decides the operating mode
+		modeStr = p.Mode
+	} else if baseDomain != "" {
+		return actions.NewActionError("--domain is only used with --preset", "")
+	}
+
+	// A re-run keeps the existing mode and engine unless forced
+	if installed && !force {
+		if err := checkInstallSettings(modeStr, socksEngine); err != nil {
+			return err
+		}
 	}
 
 	if ctx.IsInteractive {
@@ -53,105 +89,184 @@ func HandleInstall(ctx *actions.Context) error {
 		ctx.Output.Println()
 	}
 
-	ctx.Output.Info("Installing dnstm components...")
+	if installed {
+		ctx.Output.Info("Checking dnstm components...")
+	} else {
+		ctx.Output.Info("Installing dnstm components...")
+	}
+	var changes installChanges
 
 	// Step 0: Ensure dnstm binary is installed at the standard path
-	if err := ensureDnstmInstalled(ctx); err != nil {
+	copied, err := ensureDnstmInstalled(ctx)
+	if err != nil {
 		return fmt.Errorf("failed to install dnstm binary: %w", err)
 	}
+	if copied {
+		changes.add("dnstm binary installed to " + installPath)
+	}
 
 	// Step 1: Create dnstm user
-	ctx.Output.Info("Creating dnstm user...")
-	if err := system.CreateDnstmUser(); err != nil {
-		return fmt.Errorf("failed to create dnstm user: %w", err)
+	if system.DnstmUserExists() {
+		ctx.Output.Status("dnstm user exists")
+	} else {
+		ctx.Output.Info("Creating dnstm user...")
+		if err := system.CreateDnstmUser(); err != nil {
+			return fmt.Errorf("failed to create dnstm user: %w", err)
+		}
+		ctx.Output.Status("dnstm user ready")
+		changes.add("dnstm user created")
 	}
-	ctx.Output.Status("dnstm user ready")
 
-	// Step 2: Initialize router
-	ctx.Output.Info("Initializing router...")
+	// Step 2: Initialize router (directories are created or fixed either way)
 	if err := router.Initialize(); err != nil {
 		return fmt.Errorf("failed to initialize router: %w", err)
 	}
-	ctx.Output.Status("Router initialized")
+	if installed {
+		ctx.Output.Status("Router configuration exists")
+	} else {
+		ctx.Output.Status("Router initialized")
+		changes.add("router configuration created")
+	}
 
 	// Step 3: Set operating mode and ensure built-in backends
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
-	cfg.Route.Mode = modeStr
+	cfgChanged := false
+	if !installed && modeStr == "" {
+		modeStr = "single"
+	}
+	if modeStr != "" && cfg.Route.Mode != modeStr {
+		cfg.Route.Mode = modeStr
+		changes.add(fmt.Sprintf("mode set to %s", GetModeDisplayName(modeStr)))
+		cfgChanged = true
+	}
+	if socksEngine != "" && cfg.Proxy.IsBuiltinEngine() != (socksEngine == config.ProxyEngineBuiltin) {
+		cfg.Proxy.Engine = socksEngine
+		changes.add("SOCKS engine set to " + socksEngine)
+		cfgChanged = true
+	}
+	// A first install without --profile is tuned to the server; a re-run
+	// keeps the profile it has
+	workers := cfg.Listen.Workers
+	mem, _ := system.TotalMemory()
+	if profile == nil && !installed && cfg.Profile == "" {
+		p := config.DetectProfile(mem, runtime.NumCPU())
+		profile = &p
+		ctx.Output.Status(fmt.Sprintf("Detected %d MB of memory and %d CPUs", mem>>20, runtime.NumCPU()))
+	}
+	if profile != nil && cfg.Profile != profile.Name {
+		cfg.ApplyProfile(*profile)
+		changes.add("profile set to " + profile.Name)
+		cfgChanged = true
+	}
+	backends := len(cfg.Backends)
 	cfg.EnsureBuiltinBackends()
-	if err := cfg.Save(); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	if len(cfg.Backends) != backends {
+		changes.add("built-in backends added")
+		cfgChanged = true
+	}
+	if cfgChanged {
+		if err := updateConfig(cfg, func(c *config.Config) error {
+			c.Route.Mode = cfg.Route.Mode
+			c.Proxy.Engine = cfg.Proxy.Engine
+			if profile != nil && c.Profile != profile.Name {
+				c.ApplyProfile(*profile)
+			}
+			c.EnsureBuiltinBackends()
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
 	}
-	ctx.Output.Status(fmt.Sprintf("Mode set to %s", GetModeDisplayName(cfg.Route.Mode)))
+	ctx.Output.Status(fmt.Sprintf("Mode: %s", GetModeDisplayName(cfg.Route.Mode)))
+	ctx.Output.Status("Profile: " + cfg.GetProfile().Name)
+	if installed && cfg.Listen.Workers != workers {
+		if err := restartDNSRouterIfActive(); err != nil {
+			ctx.Output.Warning("Failed to restart DNS router: " + err.Error())
+		}
+	}
+	adviseSwap(ctx, mem)
 
 	// Step 4: Create DNS router service
 	svc := dnsrouter.NewService()
-	if err := svc.CreateService(); err != nil {
+	if svc.IsServiceCurrent() {
+		ctx.Output.Status("DNS router service up to date")
+	} else if err := svc.CreateService(); err != nil {
 		ctx.Output.Warning("DNS router service: " + err.Error())
 	} else {
 		ctx.Output.Status("DNS router service created")
+		changes.add("DNS router service written")
 	}
 
 	// Step 5: Install binaries
 	ctx.Output.Println()
-	ctx.Output.Info("Installing transport binaries...")
+	ctx.Output.Info("Checking transport binaries...")
 
-	// Status callback routes output through the context
-	statusFn := func(msg string) { ctx.Output.Status(msg) }
-
-	if err := transport.EnsureDnsttInstalledWithStatus(statusFn); err != nil {
-		return fmt.Errorf("failed to install dnstt-server: %w", err)
+	missing := transport.GetMissingBinaries()
+	if len(missing) == 0 {
+		ctx.Output.Status("All transport binaries installed")
 	}
-
-	if err := transport.EnsureSlipstreamInstalledWithStatus(statusFn); err != nil {
-		return fmt.Errorf("failed to install slipstream-server: %w", err)
+	for _, name := range missing {
+		if err := installBinary(ctx, name); err != nil {
+			return err
+		}
+		changes.add(name + " installed")
 	}
-
-	if err := transport.EnsureShadowsocksInstalledWithStatus(statusFn); err != nil {
-		return fmt.Errorf("failed to install ssserver: %w", err)
+	if unsupported := transport.UnsupportedBinaries(); len(unsupported) > 0 {
+		ctx.Output.Warning(fmt.Sprintf("Not available on %s/%s: %s (transports needing them cannot be used)",
+			runtime.GOOS, runtime.GOARCH, strings.Join(unsupported, ", ")))
 	}
 
-	if err := transport.EnsureVayDNSInstalledWithStatus(statusFn); err != nil {
-		return fmt.Errorf("failed to install vaydns-server: %w", err)
-	}
-
-	if err := transport.EnsureSSHTunUserInstalledWithStatus(statusFn); err != nil {
-		ctx.Output.Warning("sshtun-user: " + err.Error())
+	// Without a microsocks build, the built-in SOCKS engine takes its place
+	if !proxy.IsSocksAvailable(cfg.Proxy) && !binary.IsSupported(binary.BinaryMicrosocks) {
+		if err := updateConfig(cfg, func(c *config.Config) error {
+			c.Proxy.Engine = config.ProxyEngineBuiltin
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		ctx.Output.Status("SOCKS engine set to builtin (no microsocks build for this platform)")
+		changes.add("SOCKS engine set to " + config.ProxyEngineBuiltin)
 	}
 
-	if !proxy.IsMicrosocksInstalled() {
+	if !proxy.IsSocksAvailable(cfg.Proxy) {
 		ctx.Output.Info("Installing microsocks...")
 		if err := proxy.InstallMicrosocks(nil); err != nil {
 			return fmt.Errorf("failed to install microsocks: %w", err)
 		}
+		changes.add("microsocks installed")
 	}
 	// Ensure microsocks service is configured and running
 	if !proxy.IsMicrosocksRunning() {
 		ctx.Output.Info("Configuring microsocks service...")
-		port, err := proxy.FindAvailablePort()
+		// Keep the existing port so backends and tunnels pointing at it stay valid
+		port := cfg.Proxy.Port
+		if port == 0 {
+			port, err = proxy.FindAvailablePort()
+		}
 		if err != nil {
 			ctx.Output.Warning("Could not find available port: " + err.Error())
 		} else {
-			cfg.Proxy.Port = port
-			cfg.UpdateSocksBackendPort(port)
-			if err := cfg.Save(); err != nil {
-				ctx.Output.Warning("Failed to save proxy port: " + err.Error())
-			}
-			// Preserve existing auth config on reinstall
-			var socksUser, socksPass string
-			if socksBackend := cfg.GetBackendByTag("socks"); socksBackend != nil && socksBackend.HasSocksAuth() {
-				socksUser = socksBackend.Socks.User
-				socksPass = socksBackend.Socks.Password
+			if port != cfg.Proxy.Port {
+				if err := updateConfig(cfg, func(c *config.Config) error {
+					c.Proxy.Port = port
+					c.UpdateSocksBackendPort(port)
+					return nil
+				}); err != nil {
+					ctx.Output.Warning("Failed to save proxy port: " + err.Error())
+				}
 			}
-			if err := proxy.ConfigureMicrosocksWithAuth(port, socksUser, socksPass); err != nil {
+			// Existing auth config is preserved on reinstall
+			if err := proxy.ConfigureSocks(cfg); err != nil {
 				ctx.Output.Warning("microsocks service config: " + err.Error())
 			} else {
 				if err := proxy.StartMicrosocks(); err != nil {
 					ctx.Output.Warning("microsocks service start: " + err.Error())
 				} else {
 					ctx.Output.Status(fmt.Sprintf("microsocks installed and running on port %d", port))
+					changes.add(fmt.Sprintf("SOCKS proxy started on port %d", port))
 				}
 			}
 		}
@@ -159,52 +274,220 @@ func HandleInstall(ctx *actions.Context) error {
 		ctx.Output.Status("microsocks already running")
 	}
 
-	// Step 6: Configure firewall
+	// Step 6: Configure firewall (rules that already exist are kept)
 	ctx.Output.Println()
 	ctx.Output.Info("Configuring firewall...")
+	guardFirewall(ctx, cfg, "install")
 	network.ClearNATOnly()
 	if err := network.AllowPort53(); err != nil {
 		ctx.Output.Warning("Firewall configuration: " + err.Error())
 	} else {
 		ctx.Output.Status("Firewall configured (port 53 UDP/TCP)")
 	}
+	if cfg.Firewall.NoTrack {
+		if err := router.SyncNotrack(cfg); err != nil {
+			ctx.Output.Warning("Conntrack bypass: " + err.Error())
+		} else {
+			ctx.Output.Status("Conntrack bypass rules in place")
+		}
+	}
+
+	// fail2ban jails are opt-in
+	if ctx.GetBool("fail2ban") {
+		hadJails := fail2ban.IsEnabled()
+		if _, err := applyFail2banJails(cfg); err != nil {
+			ctx.Output.Warning("fail2ban: " + err.Error())
+		} else {
+			ctx.Output.Status("fail2ban jails configured")
+			if !hadJails {
+				changes.add("fail2ban jails generated")
+			}
+		}
+	}
+
+	// Kernel tuning is opt-in
+	if ctx.GetBool("tune") {
+		if tuned, err := applyTuning(cfg); err != nil {
+			ctx.Output.Warning("Kernel tuning: " + err.Error())
+		} else {
+			ctx.Output.Status("Kernel settings tuned")
+			if len(tuned) > 0 {
+				changes.add(fmt.Sprintf("%d kernel settings tuned", len(tuned)))
+			}
+		}
+	}
 
-	// Step 7: Create version manifest
-	if err := createVersionManifest(ctx); err != nil {
-		ctx.Output.Warning("Failed to create version manifest: " + err.Error())
+	// Step 7: Record versions of newly installed binaries
+	recorded, err := updateVersionManifest()
+	if err != nil {
+		ctx.Output.Warning("Failed to update version manifest: " + err.Error())
+	} else if recorded > 0 {
+		changes.add(fmt.Sprintf("version manifest updated (%d binaries)", recorded))
+	}
+
+	ctx.Output.Println()
+	if len(changes) == 0 {
+		ctx.Output.Success("dnstm is already installed; nothing to change")
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Installation complete (%d changes)", len(changes)))
+		for _, c := range changes {
+			ctx.Output.Println("  - " + c)
+		}
 	}
 
-	ctx.Output.Success("Installation complete!")
+	if preset != nil {
+		return applyPreset(ctx, preset, baseDomain)
+	}
 
 	// Show next steps (different for CLI vs interactive)
 	if ctx.IsInteractive {
-		ctx.Output.Println()
-		ctx.Output.Info("Next: Select 'Backends' > 'Add' for custom backends (optional)")
-		ctx.Output.Info("Next: Select 'Tunnels' > 'Add' to create a tunnel")
+		if !installed {
+			ctx.Output.Println()
+			ctx.Output.Info("Next: Select 'Backends' > 'Add' for custom backends (optional)")
+			ctx.Output.Info("Next: Select 'Tunnels' > 'Add' to create a tunnel")
+		}
 		ctx.Output.EndProgress()
-	} else {
+	} else if !installed {
 		ctx.Output.Println()
 		ctx.Output.Info("Next steps:")
 		ctx.Output.Println("  1. Add backend (optional): dnstm backend add")
 		ctx.Output.Println("  2. Add tunnel: dnstm tunnel add")
+		if t := cfg.GetProfile().Transport; t != "" {
+			ctx.Output.Println("     " + config.GetTransportTypeDisplayName(t) + " is recommended for this server")
+		}
 		ctx.Output.Println()
 	}
 
 	return nil
 }
 
-// ensureDnstmInstalled copies the current binary to /usr/local/bin/dnstm if needed.
-// This ensures services always use the correct binary path.
-func ensureDnstmInstalled(ctx *actions.Context) error {
+// installChanges lists what an install run changed, for its summary.
+type installChanges []string
+
+func (c *installChanges) add(change string) {
+	*c = append(*c, change)
+}
+
+// adviseSwap warns when a server with less than 1 GB of memory has no swap,
+// where a burst of clients can get tunnel processes killed.
+func adviseSwap(ctx *actions.Context, mem uint64) {
+	if mem == 0 || mem >= 1<<30 {
+		return
+	}
+	if swap, err := system.TotalSwap(); err != nil || swap > 0 {
+		return
+	}
+	ctx.Output.Warning(fmt.Sprintf("This server has %d MB of memory and no swap; consider adding a swap file:", mem>>20))
+	ctx.Output.Println("  fallocate -l 1G /swapfile && chmod 600 /swapfile")
+	ctx.Output.Println("  mkswap /swapfile && swapon /swapfile")
+	ctx.Output.Println("  echo '/swapfile none swap sw 0 0' >> /etc/fstab")
+}
+
+// checkInstallSettings rejects a re-run whose --mode or --socks-engine
+// differs from the existing install, since switching either needs more than
+// a config change.
+func checkInstallSettings(modeStr, socksEngine string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if modeStr != "" && modeStr != cfg.Route.Mode {
+		return actions.NewActionError(
+			fmt.Sprintf("dnstm is already installed in %s mode", GetModeDisplayName(cfg.Route.Mode)),
+			fmt.Sprintf("Switch modes with: dnstm router mode %s", modeStr))
+	}
+	engine := config.ProxyEngineMicrosocks
+	if cfg.Proxy.IsBuiltinEngine() {
+		engine = config.ProxyEngineBuiltin
+	}
+	if socksEngine != "" && socksEngine != engine {
+		return actions.NewActionError(
+			fmt.Sprintf("dnstm is already installed with the %s SOCKS engine", engine),
+			"Use --force to change the SOCKS engine")
+	}
+	return nil
+}
+
+// applyPreset creates the backends and tunnels of an install preset.
+// Existing backends and tunnels with the same tags are kept as they are.
+func applyPreset(ctx *actions.Context, preset *presets.Preset, baseDomain string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Info(fmt.Sprintf("Applying preset '%s'...", preset.Name))
+
+	for _, spec := range preset.Backends {
+		if cfg.GetBackendByTag(spec.Tag) != nil {
+			ctx.Output.Status(fmt.Sprintf("Backend '%s' already exists, keeping it", spec.Tag))
+			continue
+		}
+		password, err := GeneratePassword(cfg)
+		if err != nil {
+			return err
+		}
+		backend := spec.BackendConfig(password)
+		if err := updateConfig(cfg, func(c *config.Config) error {
+			if c.GetBackendByTag(backend.Tag) == nil {
+				c.Backends = append(c.Backends, backend)
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		ctx.Output.Status(fmt.Sprintf("Backend '%s' added", spec.Tag))
+	}
+
+	for _, spec := range preset.Tunnels {
+		if cfg.GetTunnelByTag(spec.Tag) != nil {
+			ctx.Output.Status(fmt.Sprintf("Tunnel '%s' already exists, keeping it", spec.Tag))
+			continue
+		}
+		tunnelCfg := spec.TunnelConfig(baseDomain)
+		port, err := cfg.AllocateNextPort()
+		if err != nil {
+			return portRangeError(err)
+		}
+		tunnelCfg.Port = port
+		if tunnelCfg.DNSTT != nil {
+			tunnelCfg.DNSTT.MTU = cfg.DefaultMTU()
+		}
+		if tunnelCfg.VayDNS != nil {
+			tunnelCfg.VayDNS.MTU = cfg.DefaultMTU()
+		}
+		if err := createTunnel(ctx, tunnelCfg, cfg); err != nil {
+			return fmt.Errorf("failed to create tunnel '%s': %w", spec.Tag, err)
+		}
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Preset '%s' applied!", preset.Name))
+	ctx.Output.Println()
+	ctx.Output.Info("Point these domains at this server with NS records:")
+	for _, spec := range preset.Tunnels {
+		ctx.Output.Println("  " + spec.TunnelConfig(baseDomain).Domain)
+	}
+	ctx.Output.Println()
+	ctx.Output.Info("Client configs: dnstm tunnel share -t <tag>")
+	ctx.Output.Println()
+
+	return nil
+}
+
+// ensureDnstmInstalled copies the current binary to the install path if
+// needed and reports whether it did. This ensures services always use the
+// correct binary path.
+func ensureDnstmInstalled(ctx *actions.Context) (bool, error) {
 	currentExe, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to get current executable: %w", err)
+		return false, fmt.Errorf("failed to get current executable: %w", err)
 	}
 
 	// If already running from install path, nothing to do
 	if currentExe == installPath {
 		ctx.Output.Status("dnstm binary already at " + installPath)
-		return nil
+		return false, nil
 	}
 
 	// Check if install path exists and is the same file
@@ -213,16 +496,22 @@ func ensureDnstmInstalled(ctx *actions.Context) error {
 		srcInfo, err := os.Stat(currentExe)
 		if err == nil && os.SameFile(srcInfo, destInfo) {
 			ctx.Output.Status("dnstm binary already at " + installPath)
-			return nil
+			return false, nil
 		}
 	}
 
+	// A packaged binary is replaced only by the package manager
+	if pm := packaging.Manager(); pm != "" {
+		ctx.Output.Warning(fmt.Sprintf("%s is managed by the %s package; not replacing it", installPath, pm))
+		return false, nil
+	}
+
 	// Copy current binary to install path
 	ctx.Output.Info("Installing dnstm binary to " + installPath + "...")
 
 	src, err := os.Open(currentExe)
 	if err != nil {
-		return fmt.Errorf("failed to open source binary: %w", err)
+		return false, fmt.Errorf("failed to open source binary: %w", err)
 	}
 	defer src.Close()
 
@@ -230,99 +519,66 @@ func ensureDnstmInstalled(ctx *actions.Context) error {
 	tmpPath := installPath + ".tmp"
 	dst, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create destination: %w", err)
+		return false, fmt.Errorf("failed to create destination: %w", err)
 	}
 
 	if _, err := io.Copy(dst, src); err != nil {
 		dst.Close()
 		os.Remove(tmpPath)
-		return fmt.Errorf("failed to copy binary: %w", err)
+		return false, fmt.Errorf("failed to copy binary: %w", err)
 	}
 	dst.Close()
 
 	// Rename temp to final (atomic on same filesystem)
 	if err := os.Rename(tmpPath, installPath); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("failed to install binary: %w", err)
+		return false, fmt.Errorf("failed to install binary: %w", err)
 	}
 
 	ctx.Output.Status("dnstm binary installed to " + installPath)
-	return nil
+	return true, nil
 }
 
-// installMissingBinaries installs only the binaries that are missing.
-// This handles the upgrade case where a new dnstm version adds a new transport binary.
-func installMissingBinaries(ctx *actions.Context, missing []string) error {
-	if ctx.IsInteractive {
-		ctx.Output.BeginProgress("Install Missing Binaries")
-	}
-
-	ctx.Output.Info("Installing missing transport binaries...")
+// installBinary installs a missing managed binary. sshtun-user is optional,
+// so failing to install it only warns.
+func installBinary(ctx *actions.Context, name string) error {
 	statusFn := func(msg string) { ctx.Output.Status(msg) }
 
-	for _, name := range missing {
-		binType := binary.BinaryType(name)
-		switch binType {
-		case binary.BinaryDNSTTServer:
-			if err := transport.EnsureDnsttInstalledWithStatus(statusFn); err != nil {
-				return fmt.Errorf("failed to install %s: %w", name, err)
-			}
-		case binary.BinarySlipstreamServer:
-			if err := transport.EnsureSlipstreamInstalledWithStatus(statusFn); err != nil {
-				return fmt.Errorf("failed to install %s: %w", name, err)
-			}
-		case binary.BinarySSServer:
-			if err := transport.EnsureShadowsocksInstalledWithStatus(statusFn); err != nil {
-				return fmt.Errorf("failed to install %s: %w", name, err)
-			}
-		case binary.BinaryVayDNSServer:
-			if err := transport.EnsureVayDNSInstalledWithStatus(statusFn); err != nil {
-				return fmt.Errorf("failed to install %s: %w", name, err)
-			}
-		case binary.BinarySSHTunUser:
-			if err := transport.EnsureSSHTunUserInstalledWithStatus(statusFn); err != nil {
-				ctx.Output.Warning("sshtun-user: " + err.Error())
-			}
-		default:
-			ctx.Output.Warning(fmt.Sprintf("Unknown binary: %s", name))
+	binType := binary.BinaryType(name)
+	if binType == binary.BinarySSHTunUser {
+		if err := transport.EnsureSSHTunUserInstalledWithStatus(statusFn); err != nil {
+			ctx.Output.Warning("sshtun-user: " + err.Error())
 		}
+		return nil
 	}
+	// Transport and ssserver binaries; the error message names the binary
+	return transport.EnsureBinaryInstalledWithStatus(binType, statusFn)
+}
 
-	// Update version manifest with installed versions
+// updateVersionManifest records the pinned version of every installed
+// binary that has no version in the manifest yet, creating the manifest if
+// needed. Versions recorded by updates are kept. It returns how many
+// binaries were added.
+func updateVersionManifest() (int, error) {
 	manifest, err := updater.LoadManifest()
 	if err != nil {
 		manifest = updater.NewManifest()
 	}
-	for _, name := range missing {
-		def, ok := binary.GetDef(binary.BinaryType(name))
-		if ok && def.PinnedVersion != "" {
-			manifest.SetVersion(name, def.PinnedVersion)
-		}
-	}
-	if err := manifest.Save(); err != nil {
-		ctx.Output.Warning("Failed to update version manifest: " + err.Error())
-	}
-
-	ctx.Output.Success("Missing binaries installed!")
-
-	if ctx.IsInteractive {
-		ctx.Output.EndProgress()
-	}
-
-	return nil
-}
-
-// createVersionManifest creates the initial version manifest after installation.
-// Uses pinned versions from binary definitions as the source of truth.
-func createVersionManifest(ctx *actions.Context) error {
-	manifest := updater.NewManifest()
 
+	mgr := binary.NewDefaultManager()
+	added := 0
 	for _, def := range binary.ServerBinaries() {
-		if def.SkipUpdate || def.PinnedVersion == "" {
+		if def.SkipUpdate || def.PinnedVersion == "" || manifest.GetVersion(string(def.Type)) != "" {
+			continue
+		}
+		if _, err := mgr.GetPath(def.Type); err != nil {
 			continue
 		}
 		manifest.SetVersion(string(def.Type), def.PinnedVersion)
+		added++
 	}
-
-	return manifest.Save()
+	if added == 0 {
+		return 0, nil
+	}
+	return added, manifest.Save()
 }
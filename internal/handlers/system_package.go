@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/packaging"
+	"github.com/net2share/dnstm/internal/version"
+)
+
+func init() {
+	actions.SetSystemHandler(actions.ActionPackage, HandlePackage)
+}
+
+// HandlePackage builds a .deb or .rpm package of dnstm.
+func HandlePackage(ctx *actions.Context) error {
+	opts := packaging.Options{
+		Format:    ctx.GetString("format"),
+		Version:   ctx.GetString("version"),
+		Arch:      ctx.GetString("arch"),
+		Binary:    ctx.GetString("binary"),
+		OutputDir: ctx.GetString("output"),
+	}
+	if opts.Format == "" {
+		opts.Format = packaging.FormatDeb
+	}
+	if opts.Version == "" {
+		opts.Version = version.Version
+	}
+	if opts.Arch == "" {
+		opts.Arch = runtime.GOARCH
+	}
+	if opts.Binary == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to get current executable: %w", err)
+		}
+		opts.Binary = exe
+	}
+
+	ctx.Output.Info(fmt.Sprintf("Building %s package...", opts.Format))
+	path, err := packaging.Build(opts)
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Usage: dnstm package --format deb|rpm --version X.Y.Z [--arch ARCH] [--binary PATH]")
+	}
+	ctx.Output.Success("Package written to " + path)
+	return nil
+}
@@ -3,14 +3,59 @@ package handlers
 import (
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/installer"
+	"github.com/net2share/dnstm/internal/packaging"
 )
 
 func init() {
 	actions.SetSystemHandler(actions.ActionUninstall, HandleUninstall)
 }
 
-// HandleUninstall performs a full system uninstall.
+// HandleUninstall uninstalls dnstm, or the selected components of it.
 func HandleUninstall(ctx *actions.Context) error {
 	// Note: Confirmation is handled by the adapter before calling the handler
-	return installer.PerformFullUninstall(ctx.Output, ctx.IsInteractive)
+	scope := ctx.GetString("scope")
+	opts := installer.UninstallOptions{
+		KeepCrypto:     ctx.GetBool("keep-crypto") || ctx.GetString("crypto") == "keep",
+		KeepMicrosocks: ctx.GetBool("keep-microsocks") || scope == "keep-microsocks",
+		OnlyTunnels:    ctx.GetBool("only-tunnels") || scope == "tunnels",
+	}
+
+	// A packaged dnstm is removed by its package, whose script runs this
+	if pm := packaging.Manager(); pm != "" && !packaging.FromPackageScript() && !opts.OnlyTunnels && !ctx.GetBool("dry-run") {
+		return actions.NewActionError("dnstm is installed from a "+pm+" package",
+			"Remove it with: "+packaging.RemoveCommand(pm)+". Use --only-tunnels to remove just the tunnels")
+	}
+
+	plan, err := installer.PlanFullUninstall(opts)
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Run 'dnstm uninstall' without --keep-microsocks")
+	}
+
+	if ctx.GetBool("dry-run") {
+		ctx.Output.Println()
+		printUninstallPlan(ctx, plan)
+		ctx.Output.Info("Dry run: nothing was changed")
+		ctx.Output.Println()
+		return nil
+	}
+
+	return installer.PerformFullUninstall(ctx.Output, ctx.IsInteractive, plan)
+}
+
+// printUninstallPlan lists what an uninstall removes and keeps.
+func printUninstallPlan(ctx *actions.Context, plan *installer.UninstallPlan) {
+	printResetPlan(ctx, &plan.ResetPlan)
+	section := func(title string, items []string) {
+		ctx.Output.Printf("%s (%d):\n", title, len(items))
+		for _, item := range items {
+			ctx.Output.Printf("  - %s\n", item)
+		}
+		ctx.Output.Println()
+	}
+	if !plan.Options.OnlyTunnels {
+		section("Binaries to delete", plan.Binaries)
+		ctx.Output.Println("User to remove: dnstm")
+		ctx.Output.Println()
+	}
+	section("Kept", plan.Keep)
 }
@@ -6,6 +6,9 @@ import (
 	"strings"
 
 	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/packaging"
+	"github.com/net2share/dnstm/internal/prompt"
 	"github.com/net2share/dnstm/internal/updater"
 	"github.com/net2share/dnstm/internal/version"
 	"github.com/net2share/go-corelib/tui"
@@ -17,7 +20,7 @@ func init() {
 
 // HandleUpdate handles the update action.
 func HandleUpdate(ctx *actions.Context) error {
-	force := ctx.GetBool("force")
+	force := ctx.Confirmed("force")
 	selfOnly := ctx.GetBool("self")
 	binariesOnly := ctx.GetBool("binaries")
 	checkOnly := ctx.GetBool("check")
@@ -45,6 +48,11 @@ func HandleUpdate(ctx *actions.Context) error {
 		return fmt.Errorf("failed to check for updates: %w", err)
 	}
 
+	if report.PackageManager != "" && !binariesOnly {
+		ctx.Output.Info(fmt.Sprintf("dnstm is installed from a %s package; upgrade it with: %s",
+			report.PackageManager, packaging.UpgradeCommand(report.PackageManager)))
+	}
+
 	if !report.HasUpdates() {
 		if len(report.Warnings) > 0 {
 			for _, w := range report.Warnings {
@@ -75,7 +83,7 @@ func HandleUpdate(ctx *actions.Context) error {
 	if !force {
 		if ctx.IsInteractive {
 			ctx.Output.DismissProgress()
-			confirm, err := tui.RunConfirm(tui.ConfirmConfig{
+			confirm, err := prompt.RunConfirm(tui.ConfirmConfig{
 				Title:       "Install updates?",
 				Description: formatUpdateSummary(report),
 			})
@@ -98,6 +106,8 @@ func HandleUpdate(ctx *actions.Context) error {
 		ctx.Output.Println()
 	}
 
+	createAutoSnapshot(ctx, "before update")
+
 	statusFn := func(msg string) {
 		ctx.Output.Status(msg)
 	}
@@ -110,6 +120,15 @@ func HandleUpdate(ctx *actions.Context) error {
 			}
 			return fmt.Errorf("self-update failed: %w", err)
 		}
+
+		// The DNS router runs the dnstm binary; systemd hands its port 53
+		// sockets to the new version
+		if svc := dnsrouter.NewService(); svc.IsActive() {
+			statusFn("Restarting DNS router on the new version...")
+			if err := svc.Restart(); err != nil {
+				ctx.Output.Warning("Failed to restart DNS router: " + err.Error())
+			}
+		}
 	}
 
 	// Perform binary updates (if needed and not self-only)
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/templates"
+)
+
+func init() {
+	actions.SetTemplatesHandler(actions.ActionTemplatesList, HandleTemplatesList)
+	actions.SetTemplatesHandler(actions.ActionTemplatesShow, HandleTemplatesShow)
+	actions.SetTemplatesHandler(actions.ActionTemplatesExport, HandleTemplatesExport)
+}
+
+// HandleTemplatesList lists the templates and where each comes from.
+func HandleTemplatesList(ctx *actions.Context) error {
+	ctx.Output.Println()
+	ctx.Output.Printf("%-20s %-45s %s\n", "NAME", "SOURCE", "STATUS")
+	ctx.Output.Separator(80)
+	for _, name := range templates.Names() {
+		source := "built-in"
+		if templates.IsOverridden(name) {
+			source = templates.Path(name)
+		}
+		status := "ok"
+		if err := templates.Check(name); err != nil {
+			status = err.Error()
+		}
+		ctx.Output.Printf("%-20s %-45s %s\n", name, source, status)
+	}
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleTemplatesShow prints a template.
+func HandleTemplatesShow(ctx *actions.Context) error {
+	name := ctx.GetArg(0)
+	if err := checkTemplateName(name); err != nil {
+		return err
+	}
+
+	var text string
+	var err error
+	if ctx.GetBool("default") {
+		text, err = templates.Default(name)
+	} else {
+		text, err = templates.Source(name)
+	}
+	if err != nil {
+		return err
+	}
+	ctx.Output.Print(text)
+	return nil
+}
+
+// HandleTemplatesExport writes the built-in templates to the templates
+// directory.
+func HandleTemplatesExport(ctx *actions.Context) error {
+	names := templates.Names()
+	if name := ctx.GetArg(0); name != "" {
+		if err := checkTemplateName(name); err != nil {
+			return err
+		}
+		names = []string{name}
+	}
+
+	force := ctx.GetBool("force")
+	for _, name := range names {
+		written, err := templates.Export(name, force)
+		if err != nil {
+			return err
+		}
+		if written {
+			ctx.Output.Success(fmt.Sprintf("Wrote %s", templates.Path(name)))
+		} else {
+			ctx.Output.Info(fmt.Sprintf("Kept %s (use --force to replace it)", templates.Path(name)))
+		}
+	}
+	return nil
+}
+
+// checkTemplateName returns a not-found error for an unknown template.
+func checkTemplateName(name string) error {
+	if _, err := templates.Default(name); err != nil {
+		return actions.NewActionError(err.Error(), "Run 'dnstm templates list' to see the templates").
+			WithCode(actions.ExitNotFound)
+	}
+	return nil
+}
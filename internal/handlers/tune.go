@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/tune"
+)
+
+func init() {
+	actions.SetTuneHandler(actions.ActionTuneApply, HandleTuneApply)
+	actions.SetTuneHandler(actions.ActionTuneRevert, HandleTuneRevert)
+	actions.SetTuneHandler(actions.ActionTuneStatus, HandleTuneStatus)
+}
+
+// applyTuning applies the kernel settings recommended for the profile of cfg.
+func applyTuning(cfg *config.Config) ([]tune.Change, error) {
+	changes, err := tune.Apply(tune.Recommended(cfg.GetProfile().Name))
+	if errors.Is(err, tune.ErrUnsupported) {
+		return nil, actions.NewActionError(err.Error(), "")
+	}
+	return changes, err
+}
+
+// HandleTuneApply applies the recommended kernel settings.
+func HandleTuneApply(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	changes, err := applyTuning(cfg)
+	for _, c := range changes {
+		ctx.Output.Status(fmt.Sprintf("%s: %d -> %d", c.Key, c.Previous, c.Value))
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if len(changes) == 0 {
+		ctx.Output.Success("Kernel settings already meet the recommendations")
+	} else {
+		ctx.Output.Success(fmt.Sprintf("%d kernel settings tuned and persisted in %s", len(changes), tune.ConfPath))
+	}
+	ctx.Output.Info("Put back the previous values with: dnstm tune revert")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleTuneRevert puts back the kernel settings the tuning replaced.
+func HandleTuneRevert(ctx *actions.Context) error {
+	if !tune.IsApplied() {
+		ctx.Output.Info("Kernel tuning is not applied")
+		return nil
+	}
+	changes, err := tune.Revert(true)
+	for _, c := range changes {
+		ctx.Output.Status(fmt.Sprintf("%s: %d -> %d", c.Key, c.Previous, c.Value))
+	}
+	if err != nil {
+		return err
+	}
+	ctx.Output.Println()
+	ctx.Output.Success(fmt.Sprintf("Kernel tuning reverted (%d settings restored)", len(changes)))
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleTuneStatus compares the kernel settings with the recommendations.
+func HandleTuneStatus(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	statuses, err := tune.GetStatus(tune.Recommended(cfg.GetProfile().Name))
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	ctx.Output.Printf("%-34s %-10s %-12s %-10s %s\n", "SETTING", "CURRENT", "RECOMMENDED", "PREVIOUS", "STATUS")
+	for _, s := range statuses {
+		current, previous, status := "-", "-", "low"
+		if s.Available {
+			current = strconv.FormatInt(s.Current, 10)
+		}
+		if s.Previous != nil {
+			previous = strconv.FormatInt(*s.Previous, 10)
+		}
+		switch {
+		case !s.Available:
+			status = "not available"
+		case s.OK():
+			status = "ok"
+		}
+		ctx.Output.Printf("%-34s %-10s %-12d %-10s %s\n", s.Key, current, s.Value, previous, status)
+	}
+	ctx.Output.Println()
+	if tune.IsApplied() {
+		ctx.Output.Info(fmt.Sprintf("Tuning is persisted in %s (profile %s)", tune.ConfPath, cfg.GetProfile().Name))
+	} else {
+		ctx.Output.Info("Tuning is not applied. Apply it with: dnstm tune apply")
+	}
+	ctx.Output.Println()
+	return nil
+}
@@ -2,15 +2,21 @@ package handlers
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/certs"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/escrow"
+	"github.com/net2share/dnstm/internal/health"
 	"github.com/net2share/dnstm/internal/keys"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/prompt"
 	"github.com/net2share/dnstm/internal/router"
 	"github.com/net2share/dnstm/internal/system"
 	"github.com/net2share/dnstm/internal/transport"
@@ -32,21 +38,52 @@ func HandleTunnelAdd(ctx *actions.Context) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	policy, err := config.LoadPolicy()
+	if err != nil {
+		return err
+	}
+	// Fail before asking anything when no tunnel could be added
+	if err := policy.CheckRoom(cfg); err != nil {
+		return policyError(err)
+	}
+	if err := enforceLimit(ctx, cfg.CheckTunnelLimits(&config.TunnelConfig{})); err != nil {
+		return err
+	}
+
 	if ctx.IsInteractive {
-		return addTunnelInteractive(ctx, cfg)
+		return addTunnelInteractive(ctx, cfg, policy)
 	}
 	return addTunnelNonInteractive(ctx, cfg)
 }
 
-func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
-	// Select transport type
-	transportType, err := tui.RunMenu(tui.MenuConfig{
-		Title: "Transport Type",
-		Options: []tui.MenuOption{
-			{Label: "VayDNS", Value: string(config.TransportVayDNS)},
-			{Label: "DNSTT", Value: string(config.TransportDNSTT)},
-			{Label: "Slipstream", Value: string(config.TransportSlipstream)},
-		},
+// policyError returns an action error for a tunnel the admin policy
+// forbids.
+func policyError(err error) error {
+	return actions.NewActionError(err.Error(), "The admin policy is in "+config.PolicyFile)
+}
+
+func addTunnelInteractive(ctx *actions.Context, cfg *config.Config, policy *config.Policy) error {
+	// Select transport type, offering only what the policy allows
+	var transportOptions []tui.MenuOption
+	for _, opt := range []tui.MenuOption{
+		{Label: "VayDNS", Value: string(config.TransportVayDNS)},
+		{Label: "DNSTT", Value: string(config.TransportDNSTT)},
+		{Label: "Slipstream", Value: string(config.TransportSlipstream)},
+		{Label: "Chisel (HTTPS fallback)", Value: string(config.TransportChisel)},
+	} {
+		if config.TransportType(opt.Value) == cfg.GetProfile().Transport {
+			opt.Label += " (recommended for this server)"
+		}
+		if policy.AllowsTransport(config.TransportType(opt.Value)) {
+			transportOptions = append(transportOptions, opt)
+		}
+	}
+	if len(transportOptions) == 0 {
+		return policyError(fmt.Errorf("%w: no transport is allowed", config.ErrPolicy))
+	}
+	transportType, err := prompt.RunMenu(tui.MenuConfig{
+		Title:   "Transport Type",
+		Options: transportOptions,
 	})
 	if err != nil {
 		return err
@@ -56,7 +93,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 	}
 
 	// Select backend
-	backendOptions := buildBackendOptions(cfg, config.TransportType(transportType))
+	backendOptions := buildBackendOptions(cfg, config.TransportType(transportType), policy)
 	if len(backendOptions) == 0 {
 		return actions.NewActionError(
 			"no compatible backends available",
@@ -64,7 +101,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 		)
 	}
 
-	backendTag, err := tui.RunMenu(tui.MenuConfig{
+	backendTag, err := prompt.RunMenu(tui.MenuConfig{
 		Title:   "Backend",
 		Options: backendOptions,
 	})
@@ -87,7 +124,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 	suggestedTag := router.GenerateUniqueTunnelTag(cfg.Tunnels)
 	if tag == "" {
 		var confirmed bool
-		tag, confirmed, err = tui.RunInput(tui.InputConfig{
+		tag, confirmed, err = prompt.RunInput(tui.InputConfig{
 			Title: "Tunnel Tag",
 			Value: suggestedTag,
 		})
@@ -106,6 +143,12 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 	if err := router.ValidateTag(tag); err != nil {
 		return fmt.Errorf("invalid tag: %w", err)
 	}
+	if tag == config.RescueTag {
+		return actions.NewActionError(
+			fmt.Sprintf("tag '%s' is reserved for the rescue tunnel", tag),
+			"Use 'dnstm rescue enable' to create it",
+		)
+	}
 
 	if cfg.GetTunnelByTag(tag) != nil {
 		return actions.TunnelExistsError(tag)
@@ -115,7 +158,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 	var domain string
 	for {
 		var confirmed bool
-		domain, confirmed, err = tui.RunInput(tui.InputConfig{
+		domain, confirmed, err = prompt.RunInput(tui.InputConfig{
 			Title:       "Domain",
 			Description: "e.g., t1.example.com",
 		})
@@ -133,13 +176,13 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 	}
 
 	// Get MTU for DNSTT/VayDNS
-	mtu := 1232
+	mtu := cfg.DefaultMTU()
 	if config.TransportType(transportType) == config.TransportDNSTT || config.TransportType(transportType) == config.TransportVayDNS {
 		for {
-			mtuStr, confirmed, mtuErr := tui.RunInput(tui.InputConfig{
+			mtuStr, confirmed, mtuErr := prompt.RunInput(tui.InputConfig{
 				Title:       "MTU",
 				Description: "DNS packet MTU (512-1400)",
-				Value:       "1232",
+				Value:       strconv.Itoa(mtu),
 			})
 			if mtuErr != nil {
 				return mtuErr
@@ -148,7 +191,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 				return nil
 			}
 			if mtuStr == "" {
-				mtuStr = "1232"
+				mtuStr = strconv.Itoa(cfg.DefaultMTU())
 			}
 			parsed, parseErr := strconv.Atoi(mtuStr)
 			if parseErr != nil || parsed < 512 || parsed > 1400 {
@@ -164,7 +207,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 	var vaydnsClientIDSize, vaydnsQueueSize int
 	var vaydnsIdleTimeout, vaydnsKeepAlive, vaydnsRecordType string
 	if config.TransportType(transportType) == config.TransportVayDNS {
-		confirm, confirmErr := tui.RunConfirm(tui.ConfirmConfig{
+		confirm, confirmErr := prompt.RunConfirm(tui.ConfirmConfig{
 			Title:       "DNSTT-compatible wire format?",
 			Description: "Enable only if clients use dnstt-client and don't have vaydns-client. Uses 8-byte client IDs. May not work on some highly restricted UDP resolvers.",
 		})
@@ -176,7 +219,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 		// clientid_size: only if not dnstt-compat (compat forces 8-byte)
 		if !vaydnsDnsttCompat {
 			for {
-				cidStr, confirmed, cidErr := tui.RunInput(tui.InputConfig{
+				cidStr, confirmed, cidErr := prompt.RunInput(tui.InputConfig{
 					Title:       "Client ID Size",
 					Description: "Client ID size in bytes (1-8)",
 					Value:       "2",
@@ -206,7 +249,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 			defaultIdle = "2m"
 		}
 		for {
-			idleStr, confirmed, idleErr := tui.RunInput(tui.InputConfig{
+			idleStr, confirmed, idleErr := prompt.RunInput(tui.InputConfig{
 				Title:       "Idle Timeout",
 				Description: "Session idle timeout (e.g. 60s, 2m)",
 				Value:       defaultIdle,
@@ -234,7 +277,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 			defaultKeep = "10s"
 		}
 		for {
-			keepStr, confirmed, keepErr := tui.RunInput(tui.InputConfig{
+			keepStr, confirmed, keepErr := prompt.RunInput(tui.InputConfig{
 				Title:       "Keepalive Interval",
 				Description: "Keepalive ping interval; must be less than idle timeout",
 				Value:       defaultKeep,
@@ -264,7 +307,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 
 		// queue-size
 		for {
-			qsStr, confirmed, qsErr := tui.RunInput(tui.InputConfig{
+			qsStr, confirmed, qsErr := prompt.RunInput(tui.InputConfig{
 				Title:       "Queue Size",
 				Description: "Packet queue size for transport (32-65535)",
 				Value:       "512",
@@ -298,7 +341,7 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 				{Label: "A", Value: "a"},
 				{Label: "AAAA", Value: "aaaa"},
 			}
-			rtValue, rtErr := tui.RunMenu(tui.MenuConfig{
+			rtValue, rtErr := prompt.RunMenu(tui.MenuConfig{
 				Title:   "DNS Record Type",
 				Options: rtOptions,
 			})
@@ -336,9 +379,54 @@ func addTunnelInteractive(ctx *actions.Context, cfg *config.Config) error {
 		}
 	}
 
-	// Allocate port
-	port := cfg.AllocateNextPort()
-	tunnelCfg.Port = port
+	// Allocate port; chisel listens publicly on a port chosen here
+	if tunnelCfg.Transport == config.TransportChisel {
+		for {
+			portStr, confirmed, portErr := prompt.RunInput(tui.InputConfig{
+				Title:       "Listen Port",
+				Description: "TCP port clients connect to",
+				Value:       strconv.Itoa(config.DefaultChiselPort),
+			})
+			if portErr != nil {
+				return portErr
+			}
+			if !confirmed {
+				return nil
+			}
+			if portStr == "" {
+				portStr = strconv.Itoa(config.DefaultChiselPort)
+			}
+			parsed, parseErr := strconv.Atoi(portStr)
+			if parseErr != nil || parsed < 1 || parsed > 65535 {
+				ctx.Output.Error("Port must be a number between 1 and 65535")
+				continue
+			}
+			if err := checkListenPort(cfg, parsed); err != nil {
+				ctx.Output.Error(err.Error())
+				continue
+			}
+			tunnelCfg.Port = parsed
+			break
+		}
+	} else {
+		port, err := cfg.AllocateNextPort()
+		if err != nil {
+			return portRangeError(err)
+		}
+		tunnelCfg.Port = port
+	}
+
+	// A second public IP can serve this tunnel next to the active one
+	if cfg.IsSingleMode() && tunnelCfg.Transport.IsDNS() && cfg.Route.Active != "" {
+		bindHost, ok, err := promptBindHost()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		tunnelCfg.BindHost = bindHost
+	}
 
 	// Create the tunnel
 	return createTunnel(ctx, tunnelCfg, cfg)
@@ -351,6 +439,13 @@ func addTunnelNonInteractive(ctx *actions.Context, cfg *config.Config) error {
 	port := ctx.GetInt("port")
 	mtu := ctx.GetInt("mtu")
 
+	// A canary serves the domain of its stable tunnel
+	if stableTag := ctx.GetString("canary-of"); stableTag != "" && domain == "" {
+		if stable := cfg.GetTunnelByTag(stableTag); stable != nil {
+			domain = stable.Domain
+		}
+	}
+
 	if transportStr == "" || backendTag == "" || domain == "" {
 		return fmt.Errorf("--transport, --backend, and --domain flags are required\n\nUsage: dnstm tunnel add --transport TYPE -b BACKEND -d DOMAIN [-t TAG]")
 	}
@@ -358,8 +453,8 @@ func addTunnelNonInteractive(ctx *actions.Context, cfg *config.Config) error {
 	transportType := config.TransportType(transportStr)
 
 	// Validate transport type
-	if transportType != config.TransportSlipstream && transportType != config.TransportDNSTT && transportType != config.TransportVayDNS {
-		return fmt.Errorf("invalid transport type: %s (must be slipstream, dnstt, or vaydns)", transportType)
+	if !config.IsKnownTransport(transportType) {
+		return fmt.Errorf("invalid transport type: %s (must be slipstream, dnstt, vaydns, or chisel)", transportType)
 	}
 
 	// Validate backend exists and is compatible
@@ -369,7 +464,7 @@ func addTunnelNonInteractive(ctx *actions.Context, cfg *config.Config) error {
 	}
 
 	// Check transport-backend compatibility
-	if (transportType == config.TransportDNSTT || transportType == config.TransportVayDNS) && backend.Type == config.BackendShadowsocks {
+	if transportType != config.TransportSlipstream && backend.Type == config.BackendShadowsocks {
 		return actions.NewActionError(
 			"incompatible transport and backend",
 			fmt.Sprintf("%s transport does not support Shadowsocks backend", config.GetTransportTypeDisplayName(transportType)),
@@ -386,30 +481,44 @@ func addTunnelNonInteractive(ctx *actions.Context, cfg *config.Config) error {
 	if err := router.ValidateTag(tag); err != nil {
 		return fmt.Errorf("invalid tag: %w", err)
 	}
+	if tag == config.RescueTag {
+		return actions.NewActionError(
+			fmt.Sprintf("tag '%s' is reserved for the rescue tunnel", tag),
+			"Use 'dnstm rescue enable' to create it",
+		)
+	}
 
 	if cfg.GetTunnelByTag(tag) != nil {
 		return actions.TunnelExistsError(tag)
 	}
 
+	labels, err := config.ParseLabels(ctx.GetString("labels"))
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Labels look like region=eu,customer=acme")
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+
 	// Build config
 	tunnelCfg := &config.TunnelConfig{
 		Tag:       tag,
 		Transport: transportType,
 		Backend:   backendTag,
 		Domain:    domain,
+		BindHost:  ctx.GetString("bind-host"),
+		Labels:    labels,
 	}
+	tunnelCfg.Description = strings.TrimSpace(ctx.GetString("description"))
 
 	// Transport-specific configuration
+	if mtu == 0 {
+		mtu = cfg.DefaultMTU()
+	}
 	if transportType == config.TransportDNSTT {
-		if mtu == 0 {
-			mtu = 1232
-		}
 		tunnelCfg.DNSTT = &config.DNSTTConfig{MTU: mtu}
 	}
 	if transportType == config.TransportVayDNS {
-		if mtu == 0 {
-			mtu = 1232
-		}
 		dnsttCompat := ctx.GetBool("dnstt-compat")
 		cid := ctx.GetInt("clientid-size")
 
@@ -453,21 +562,155 @@ func addTunnelNonInteractive(ctx *actions.Context, cfg *config.Config) error {
 		tunnelCfg.VayDNS = v
 	}
 
-	// Allocate port
-	if port == 0 {
-		port = cfg.AllocateNextPort()
+	// Allocate port, or check that a requested one is usable
+	if transportType == config.TransportChisel {
+		if port == 0 {
+			port = config.DefaultChiselPort
+		}
+		if err := checkListenPort(cfg, port); err != nil {
+			return err
+		}
+	} else if port == 0 {
+		var err error
+		if port, err = cfg.AllocateNextPort(); err != nil {
+			return portRangeError(err)
+		}
+	} else {
+		if cfg.Ports.IsExcluded(port) {
+			return fmt.Errorf("port %d is excluded by ports.exclude", port)
+		}
+		if existing := cfg.GetTunnelByPort(port); existing != nil {
+			return fmt.Errorf("port %d is already used by tunnel '%s'", port, existing.Tag)
+		}
+		if !config.IsPortFree(port) {
+			return fmt.Errorf("port %d is already in use on this host", port)
+		}
 	}
 	tunnelCfg.Port = port
 
 	return createTunnel(ctx, tunnelCfg, cfg)
 }
 
+// checkListenPort checks that a non-DNS tunnel can listen on port.
+func checkListenPort(cfg *config.Config, port int) error {
+	if existing := cfg.GetTunnelByPort(port); existing != nil {
+		return fmt.Errorf("port %d is already used by tunnel '%s'", port, existing.Tag)
+	}
+	if !config.IsPortFree(port) {
+		return fmt.Errorf("port %d is already in use on this host", port)
+	}
+	return nil
+}
+
+// checkCanaryOf checks that a new tunnel can become the canary of the tunnel
+// stableTag.
+func checkCanaryOf(cfg *config.Config, tunnelCfg *config.TunnelConfig, stableTag string) error {
+	stable := cfg.GetTunnelByTag(stableTag)
+	if stable == nil {
+		return actions.TunnelNotFoundError(stableTag)
+	}
+	if !cfg.IsMultiMode() {
+		return actions.NewActionError(
+			"canaries are only routed in multi mode",
+			"Switch with 'dnstm router mode multi'",
+		)
+	}
+	if !stable.Transport.IsDNS() || !tunnelCfg.Transport.IsDNS() {
+		return fmt.Errorf("canaries are only supported between DNS tunnels")
+	}
+	if tunnelCfg.Transport != stable.Transport {
+		return actions.NewActionError(
+			fmt.Sprintf("a canary of '%s' must use %s, the transport its clients speak", stableTag, stable.Transport),
+			fmt.Sprintf("Use --transport %s", stable.Transport),
+		)
+	}
+	if tunnelCfg.Domain != stable.Domain {
+		return fmt.Errorf("a canary of '%s' must serve %s", stableTag, stable.Domain)
+	}
+	if stable.Canary != nil {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' already has canary '%s'", stableTag, stable.Canary.Tunnel),
+			fmt.Sprintf("Remove it with 'dnstm tunnel remove -t %s' first", stable.Canary.Tunnel),
+		)
+	}
+	if cfg.CanaryOf(stableTag) != nil {
+		return fmt.Errorf("tunnel '%s' is itself a canary", stableTag)
+	}
+	if tunnelCfg.BindHost != "" {
+		return fmt.Errorf("--bind-host cannot be used with --canary-of")
+	}
+	return nil
+}
+
+// checkBindHost checks that a DNS tunnel can serve port 53 on its own
+// address next to the active tunnel in single mode.
+func checkBindHost(cfg *config.Config, tunnelCfg *config.TunnelConfig) error {
+	if !tunnelCfg.Transport.IsDNS() {
+		return fmt.Errorf("--bind-host is only used by DNS transports")
+	}
+	if !cfg.IsSingleMode() {
+		return actions.NewActionError(
+			"--bind-host is only used in single mode",
+			"In multi mode the DNS router serves every tunnel on listen.address",
+		)
+	}
+	if cfg.Route.Active == "" {
+		return actions.NewActionError(
+			"no active tunnel to run alongside",
+			"Add the tunnel for the primary IP first, without --bind-host",
+		)
+	}
+	if net.ParseIP(tunnelCfg.BindHost) == nil {
+		return fmt.Errorf("invalid --bind-host '%s': not an IP address", tunnelCfg.BindHost)
+	}
+	if !network.IsLocalAddress(tunnelCfg.BindHost) {
+		return fmt.Errorf("address %s is not assigned to this host", tunnelCfg.BindHost)
+	}
+	if externalIP, err := network.GetExternalIP(); err == nil && externalIP == tunnelCfg.BindHost {
+		return fmt.Errorf("address %s is used by the active tunnel '%s'; pick another public IP", externalIP, cfg.Route.Active)
+	}
+	for _, t := range cfg.Tunnels {
+		if t.BindHost == tunnelCfg.BindHost {
+			return fmt.Errorf("address %s is already used by tunnel '%s'", t.BindHost, t.Tag)
+		}
+	}
+	return nil
+}
+
+// promptBindHost offers the host's other public IPs when adding a DNS
+// tunnel next to the active one. It returns "" to keep the tunnel behind the
+// active one, and ok=false when the user cancels.
+func promptBindHost() (string, bool, error) {
+	ips, err := network.ExternalIPs()
+	if err != nil || len(ips) < 2 {
+		return "", true, nil
+	}
+
+	// The first address is the one the active tunnel binds
+	options := []tui.MenuOption{{Label: "None (add as an inactive tunnel)", Value: "none"}}
+	for _, ip := range ips[1:] {
+		options = append(options, tui.MenuOption{Label: ip + ":53", Value: ip})
+	}
+	value, err := prompt.RunMenu(tui.MenuConfig{
+		Title:       "Bind Address",
+		Description: fmt.Sprintf("Serve this tunnel on another public IP next to the active tunnel on %s", ips[0]),
+		Options:     options,
+	})
+	if err != nil || value == "" {
+		return "", false, err
+	}
+	if value == "none" {
+		return "", true, nil
+	}
+	return value, true, nil
+}
+
 // promptModeSwitch prompts the user to switch from single to multi mode when adding a second tunnel.
 // Returns true if mode was switched, false if user declined.
 func promptModeSwitch(ctx *actions.Context, cfg *config.Config, newTunnel *config.TunnelConfig) (bool, error) {
 	existingTunnel := cfg.Tunnels[0].Tag
 
-	confirm, err := tui.RunConfirm(tui.ConfirmConfig{
+	confirm, err := prompt.RunConfirm(tui.ConfirmConfig{
 		Title: "Switch to multi mode?",
 		Description: fmt.Sprintf(
 			"You already have tunnel '%s'. Single mode only allows one active tunnel.\nMulti mode allows running multiple tunnels simultaneously with DNS-based routing.",
@@ -480,7 +723,7 @@ func promptModeSwitch(ctx *actions.Context, cfg *config.Config, newTunnel *confi
 	}
 
 	if !confirm {
-		_ = tui.ShowMessage(tui.AppMessage{Type: "info", Message: "Staying in single mode. New tunnel will be added but only one can be active."})
+		_ = prompt.ShowMessage(tui.AppMessage{Type: "info", Message: "Staying in single mode. New tunnel will be added but only one can be active."})
 		return false, nil
 	}
 
@@ -490,34 +733,70 @@ func promptModeSwitch(ctx *actions.Context, cfg *config.Config, newTunnel *confi
 			return false, fmt.Errorf("cannot switch to multi mode: new tunnel '%s' and existing tunnel '%s' share domain '%s'", newTunnel.Tag, t.Tag, newTunnel.Domain)
 		}
 	}
+	if err := checkMultiModeMemory(ctx, cfg); err != nil {
+		return false, err
+	}
 
 	r, err := router.New(cfg)
 	if err != nil {
 		return false, fmt.Errorf("failed to create router: %w", err)
 	}
 
+	guardFirewall(ctx, cfg, "mode switch to multi")
 	if err := r.SwitchMode("multi"); err != nil {
 		return false, fmt.Errorf("failed to switch mode: %w", err)
 	}
 
-	_ = tui.ShowMessage(tui.AppMessage{Type: "info", Message: "Switched to multi mode!"})
+	_ = prompt.ShowMessage(tui.AppMessage{Type: "info", Message: "Switched to multi mode!"})
 
 	return true, nil
 }
 
 func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *config.Config) error {
+	// The last check before anything is created, for every caller
+	policy, err := config.LoadPolicy()
+	if err != nil {
+		return err
+	}
+	if err := policy.CheckTunnel(cfg, tunnelCfg); err != nil {
+		return policyError(err)
+	}
+	if err := enforceLimit(ctx, cfg.CheckTunnelLimits(tunnelCfg)); err != nil {
+		return err
+	}
+
+	if tunnelCfg.Port == 0 {
+		return actions.NewActionError(
+			fmt.Sprintf("no free port left in range %s", router.GetPortRange(cfg.Ports)),
+			"Widen ports.start/ports.end in the config, or check 'dnstm ports list'",
+		)
+	}
+
+	stableTag := ctx.GetString("canary-of")
+	if stableTag != "" {
+		if err := checkCanaryOf(cfg, tunnelCfg, stableTag); err != nil {
+			return err
+		}
+	}
+
 	// Check for duplicate domain in multi mode
-	if cfg.IsMultiMode() {
+	if cfg.IsMultiMode() && tunnelCfg.Transport.IsDNS() {
 		for _, t := range cfg.Tunnels {
-			if t.Domain == tunnelCfg.Domain {
+			if t.Transport.IsDNS() && t.Domain == tunnelCfg.Domain && t.Tag != stableTag {
 				return fmt.Errorf("domain '%s' is already used by tunnel '%s' (duplicate domains not allowed in multi mode)", tunnelCfg.Domain, t.Tag)
 			}
 		}
 	}
 
+	if tunnelCfg.BindHost != "" {
+		if err := checkBindHost(cfg, tunnelCfg); err != nil {
+			return err
+		}
+	}
+
 	// Check if we need to switch to multi mode
-	// This happens when adding a second tunnel while in single mode
-	if cfg.IsSingleMode() && len(cfg.Tunnels) > 0 {
+	// This happens when adding a second DNS tunnel while in single mode
+	if cfg.IsSingleMode() && !tunnelCfg.RunsAlongsideActive() && cfg.Route.Active != "" {
 		if ctx.IsInteractive {
 			switchedMode, err := promptModeSwitch(ctx, cfg, tunnelCfg)
 			if err != nil {
@@ -533,13 +812,24 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 			}
 		} else {
 			// Non-interactive mode: just inform the user
-			existingTunnel := cfg.Tunnels[0].Tag
+			existingTunnel := cfg.Route.Active
 			ctx.Output.Info("Adding tunnel to single mode. Existing active tunnel: " + existingTunnel)
 			ctx.Output.Info("New tunnel will be added but not activated. Use 'dnstm router switch' to activate it.")
 			ctx.Output.Println()
 		}
 	}
 
+	// A tunnel that becomes active in single mode binds port 53 itself
+	if cfg.IsSingleMode() && tunnelCfg.Transport.IsDNS() && (cfg.Route.Active == "" || cfg.Route.Active == tunnelCfg.Tag) {
+		if err := resolvePort53Conflicts(ctx, cfg, "single"); err != nil {
+			return err
+		}
+	}
+
+	if err := checkFootprint(ctx, tunnelCfg); err != nil {
+		return err
+	}
+
 	// Start progress view in interactive mode
 	if ctx.IsInteractive {
 		ctx.Output.BeginProgress(fmt.Sprintf("Add Tunnel: %s", tunnelCfg.Tag))
@@ -573,9 +863,36 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 	// Step 3: Generate certificates/keys into tunnel directory
 	currentStep++
 	ctx.Output.Step(currentStep, totalSteps, "Generating cryptographic material...")
+	if stableTag != "" {
+		// Clients of the stable tunnel must be able to talk to the canary
+		archive, err := escrow.Build([]*config.TunnelConfig{cfg.GetTunnelByTag(stableTag)})
+		if err != nil {
+			return fmt.Errorf("failed to read the keys of '%s': %w", stableTag, err)
+		}
+		if _, err := archive.RestoreAs(config.TunnelsDir, stableTag, tunnelCfg.Tag); err != nil {
+			return err
+		}
+		ctx.Output.Status(fmt.Sprintf("Copied the keys of '%s'", stableTag))
+	}
 	var fingerprint string
 	var publicKey string
-	if tunnelCfg.Transport == config.TransportSlipstream {
+	if tunnelCfg.Transport == config.TransportChisel {
+		certInfo, err := certs.GetOrCreateInDir(tunnelDir, tunnelCfg.Domain)
+		if err != nil {
+			return fmt.Errorf("failed to generate certificate: %w", err)
+		}
+		auth, err := transport.GenerateChiselAuth()
+		if err != nil {
+			return err
+		}
+		fingerprint = certInfo.Fingerprint
+		tunnelCfg.Chisel = &config.ChiselConfig{
+			Auth: auth,
+			Cert: certInfo.CertPath,
+			Key:  certInfo.KeyPath,
+		}
+		ctx.Output.Status("TLS certificate and credentials ready")
+	} else if tunnelCfg.Transport == config.TransportSlipstream {
 		certInfo, err := certs.GetOrCreateInDir(tunnelDir, tunnelCfg.Domain)
 		if err != nil {
 			return fmt.Errorf("failed to generate certificate: %w", err)
@@ -625,6 +942,15 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 		return actions.BackendNotFoundError(tunnelCfg.Backend)
 	}
 
+	// Sessions of new tunnels are recorded too while the session log is on
+	if cfg.Log.Sessions != nil && config.HasFront(tunnelCfg.Transport, backend.Type) {
+		if port, err := cfg.AllocateFrontPort(tunnelCfg); err == nil {
+			tunnelCfg.SessionLog = &config.TunnelLogConfig{Port: port}
+		} else {
+			ctx.Output.Warning("No free port left for a session log front; sessions of this tunnel are not recorded")
+		}
+	}
+
 	if err := createTunnelService(tunnelCfg, backend, serviceMode); err != nil {
 		return fmt.Errorf("failed to create service: %w", err)
 	}
@@ -644,24 +970,49 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 	ctx.Output.Step(currentStep, totalSteps, "Saving configuration...")
 	enabled := true
 	tunnelCfg.Enabled = &enabled
-	cfg.Tunnels = append(cfg.Tunnels, *tunnelCfg)
+	tunnelCfg.MarkCreated()
 
-	// Handle mode-specific config
-	if cfg.IsSingleMode() {
-		if cfg.Route.Active == "" {
-			cfg.Route.Active = tunnelCfg.Tag
+	// Another invocation may have taken the tag or a port meanwhile
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		if c.GetTunnelByTag(tunnelCfg.Tag) != nil {
+			return actions.TunnelExistsError(tunnelCfg.Tag)
 		}
-	} else {
-		if cfg.Route.Default == "" {
-			cfg.Route.Default = tunnelCfg.Tag
+		c.Tunnels = append(c.Tunnels, *tunnelCfg)
+
+		// Handle mode-specific config; fallback transports are not routed
+		if !tunnelCfg.Transport.IsDNS() {
+			// Runs alongside the DNS tunnels in either mode
+		} else if c.IsSingleMode() {
+			if c.Route.Active == "" {
+				c.Route.Active = tunnelCfg.Tag
+			}
+		} else {
+			if c.Route.Default == "" {
+				c.Route.Default = tunnelCfg.Tag
+			}
 		}
-	}
 
-	if err := cfg.Save(); err != nil {
+		// The canary takes no clients until its share is raised
+		if stableTag != "" {
+			stable := c.GetTunnelByTag(stableTag)
+			if stable == nil {
+				return actions.TunnelNotFoundError(stableTag)
+			}
+			stable.Canary = &config.CanaryConfig{Tunnel: tunnelCfg.Tag}
+		}
+		return c.Validate()
+	}); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 	ctx.Output.Status("Configuration saved")
 
+	// The front must be up before the tunnel forwards to it
+	if tunnelCfg.SessionLog != nil {
+		if err := health.Sync(cfg); err != nil {
+			ctx.Output.Warning("Failed to update health service: " + err.Error())
+		}
+	}
+
 	// Start the tunnel (and regenerate DNS router in multi mode)
 	if err := enableAndStartTunnel(ctx, cfg, tunnel); err != nil {
 		ctx.Output.Warning("Failed to start tunnel: " + err.Error())
@@ -669,6 +1020,8 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 		ctx.Output.Status("Tunnel started")
 	}
 
+	refreshClientBundles(ctx, cfg, []*config.TunnelConfig{cfg.GetTunnelByTag(tunnelCfg.Tag)})
+
 	ctx.Output.Success(fmt.Sprintf("Tunnel '%s' created and started!", tunnelCfg.Tag))
 	ctx.Output.Println()
 
@@ -677,6 +1030,10 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 	ctx.Output.Status(fmt.Sprintf("Backend: %s", tunnelCfg.Backend))
 	ctx.Output.Status(fmt.Sprintf("Domain: %s", tunnelCfg.Domain))
 	ctx.Output.Status(fmt.Sprintf("Port: %d", tunnelCfg.Port))
+	if tunnelCfg.BindHost != "" {
+		ctx.Output.Status(fmt.Sprintf("Bind Address: %s:53", tunnelCfg.BindHost))
+		ctx.Output.Info(fmt.Sprintf("Point the NS record for %s at %s", tunnelCfg.Domain, tunnelCfg.BindHost))
+	}
 
 	if fingerprint != "" {
 		ctx.Output.Println()
@@ -689,6 +1046,13 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 		ctx.Output.Println(publicKey)
 	}
 
+	if c := tunnelCfg.Chisel; c != nil {
+		ctx.Output.Println()
+		ctx.Output.Status(fmt.Sprintf("Auth: %s", c.Auth))
+		ctx.Output.Status(fmt.Sprintf("Remote: %s", backend.TargetAddress()))
+		ctx.Output.Info("Clients connect with: chisel client --auth AUTH --tls-ca cert.pem https://DOMAIN:PORT LOCAL_PORT:REMOTE")
+	}
+
 	if tunnelCfg.Transport == config.TransportVayDNS && tunnelCfg.VayDNS != nil {
 		v := tunnelCfg.VayDNS
 		ctx.Output.Println()
@@ -706,6 +1070,14 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 		ctx.Output.Status(fmt.Sprintf("Record Type: %s", rt))
 	}
 
+	if stableTag != "" {
+		ctx.Output.Println()
+		ctx.Output.Info(fmt.Sprintf("Send clients to the canary with: dnstm tunnel canary -t %s --percent 10", stableTag))
+	} else if tunnelCfg.Transport.IsDNS() {
+		ctx.Output.Println()
+		ctx.Output.Info(fmt.Sprintf("DNS records to create: dnstm dns records -t %s [--format bind|cloudflare|route53]", tunnelCfg.Tag))
+	}
+
 	if ctx.IsInteractive {
 		ctx.Output.EndProgress()
 	} else {
@@ -716,12 +1088,15 @@ func createTunnel(ctx *actions.Context, tunnelCfg *config.TunnelConfig, cfg *con
 }
 
 // buildBackendOptions builds menu options for backend selection.
-func buildBackendOptions(cfg *config.Config, transportType config.TransportType) []tui.MenuOption {
+func buildBackendOptions(cfg *config.Config, transportType config.TransportType, policy *config.Policy) []tui.MenuOption {
 	var options []tui.MenuOption
 
 	for _, b := range cfg.Backends {
-		// Check compatibility: DNSTT and VayDNS don't support shadowsocks
-		if (transportType == config.TransportDNSTT || transportType == config.TransportVayDNS) && b.Type == config.BackendShadowsocks {
+		// Check compatibility: only Slipstream supports shadowsocks
+		if transportType != config.TransportSlipstream && b.Type == config.BackendShadowsocks {
+			continue
+		}
+		if !policy.AllowsBackend(b.Type) {
 			continue
 		}
 
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelCanary, HandleTunnelCanary)
+}
+
+// HandleTunnelCanary sets the share of a tunnel's clients the DNS router
+// sends to its canary.
+func HandleTunnelCanary(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+	if tunnelCfg.Canary == nil {
+		if stable := cfg.CanaryOf(tag); stable != nil {
+			return actions.NewActionError(
+				fmt.Sprintf("tunnel '%s' is the canary of '%s'", tag, stable.Tag),
+				fmt.Sprintf("Usage: dnstm tunnel canary -t %s --percent <n>", stable.Tag),
+			)
+		}
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' has no canary", tag),
+			fmt.Sprintf("Create one with: dnstm tunnel add ... --canary-of %s", tag),
+		)
+	}
+
+	percent := ctx.GetInt("percent")
+	if percent < 0 || percent > 100 {
+		return actions.UsageError("--percent must be between 0 and 100", fmt.Sprintf("Usage: dnstm tunnel canary -t %s --percent <n>", tag))
+	}
+	if percent == tunnelCfg.Canary.Percent {
+		ctx.Output.Info(fmt.Sprintf("Tunnel '%s' already sends %d%% of its clients to '%s'", tag, percent, tunnelCfg.Canary.Tunnel))
+		return nil
+	}
+	if percent > 0 && cfg.Route.Stateless {
+		return actions.NewActionError("canaries cannot be routed while the DNS router is stateless", "Disable stateless routing with: dnstm router stateless --disable")
+	}
+	tunnelCfg.Canary.Percent = percent
+	tunnelCfg.MarkModified()
+
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), fmt.Sprintf("Check the canary with 'dnstm tunnel status -t %s'", tunnelCfg.Canary.Tunnel))
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Canary Routing: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveTunnel(cfg, tunnelCfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	if cfg.IsMultiMode() {
+		if err := restartDNSRouterIfActive(); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to restart DNS router: %w", err))
+		}
+		ctx.Output.Status("DNS router updated")
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Tunnel '%s' sends %d%% of its clients to '%s'", tag, percent, tunnelCfg.Canary.Tunnel))
+	if !cfg.IsMultiMode() {
+		ctx.Output.Warning("Canary routing is done by the DNS router. Switch with: dnstm router mode multi")
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
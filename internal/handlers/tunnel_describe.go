@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelDescribe, HandleTunnelDescribe)
+}
+
+// HandleTunnelDescribe sets a tunnel's description and notes, or prints them
+// when none are given.
+func HandleTunnelDescribe(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	description := strings.TrimSpace(ctx.GetString("description"))
+	notes := strings.TrimSpace(strings.ReplaceAll(ctx.GetString("notes"), `\n`, "\n"))
+	clearAll := ctx.GetBool("clear")
+	if !clearAll && description == "" && notes == "" {
+		printTunnelNotes(ctx, tunnelCfg)
+		return nil
+	}
+
+	if err := config.Update(func(c *config.Config) error {
+		t := c.GetTunnelByTag(tag)
+		if t == nil {
+			return actions.TunnelNotFoundError(tag)
+		}
+		if clearAll {
+			t.Description, t.Notes = "", ""
+		}
+		if description != "" {
+			t.Description = description
+		}
+		if notes != "" {
+			t.Notes = notes
+		}
+		t.MarkModified()
+		if err := c.Validate(); err != nil {
+			return actions.NewActionError(err.Error(), "Keep the description to one line and put the rest in --notes")
+		}
+		*tunnelCfg = *t
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Description of '%s' saved", tag))
+	return nil
+}
+
+// printTunnelNotes prints a tunnel's description, notes and metadata.
+func printTunnelNotes(ctx *actions.Context, t *config.TunnelConfig) {
+	if t.Description == "" && t.Notes == "" && t.Meta == nil {
+		ctx.Output.Info(fmt.Sprintf("Tunnel '%s' has no description", t.Tag))
+		return
+	}
+	for _, row := range tunnelMetaRows(t) {
+		ctx.Output.Printf("%-12s %s\n", row.Key+":", row.Value)
+	}
+	if t.Notes != "" {
+		ctx.Output.Println()
+		ctx.Output.Println(t.Notes)
+	}
+}
+
+// tunnelMetaRows returns the description and metadata of a tunnel as info
+// rows; notes are shown separately as they span lines.
+func tunnelMetaRows(t *config.TunnelConfig) []actions.InfoRow {
+	var rows []actions.InfoRow
+	if t.Description != "" {
+		rows = append(rows, actions.InfoRow{Key: "Description", Value: t.Description})
+	}
+	if m := t.Meta; m != nil {
+		stamp := func(at, by string) string {
+			if by == "" {
+				return at
+			}
+			return at + " by " + by
+		}
+		const layout = "2006-01-02 15:04"
+		if !m.CreatedAt.IsZero() {
+			rows = append(rows, actions.InfoRow{Key: "Created", Value: stamp(m.CreatedAt.Local().Format(layout), m.CreatedBy)})
+		}
+		if !m.ModifiedAt.IsZero() {
+			rows = append(rows, actions.InfoRow{Key: "Modified", Value: stamp(m.ModifiedAt.Local().Format(layout), m.ModifiedBy)})
+		}
+	}
+	return rows
+}
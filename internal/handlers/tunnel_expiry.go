@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelExpiry, HandleTunnelExpiry)
+	actions.SetTunnelHandler(actions.ActionTunnelApplyExpiry, HandleTunnelApplyExpiry)
+}
+
+// HandleTunnelExpiry sets or clears when a tunnel expires, installs the
+// expiry timer, and applies the expiry now.
+func HandleTunnelExpiry(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	hint := "Use --at 2026-12-31, --at 30d or --disable; grace periods look like 7d or 72h"
+	at := strings.TrimSpace(ctx.GetString("at"))
+	if ctx.GetBool("disable") {
+		tunnelCfg.Expiry = nil
+	} else {
+		if at == "" {
+			return actions.NewActionError("expiry time required", hint)
+		}
+		// A duration counts from now
+		if d, err := config.ParseDays(at); err == nil {
+			at = time.Now().Add(d).Truncate(time.Second).Format(time.RFC3339)
+		}
+		remove := ctx.GetString("on-expiry") == "remove"
+		tunnelCfg.Expiry = &config.ExpiryConfig{At: at, Remove: remove}
+		if remove {
+			tunnelCfg.Expiry.Grace = strings.TrimSpace(ctx.GetString("grace"))
+		}
+	}
+	tunnelCfg.MarkModified()
+
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), hint)
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Expiry: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveTunnel(cfg, tunnelCfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	if err := router.SyncExpiryTimer(cfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to update expiry timer: %w", err))
+	}
+
+	if tunnelCfg.Expiry == nil {
+		ctx.Output.Success(fmt.Sprintf("Expiry removed for '%s'", tag))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' expires %s", tag, expirySummary(tunnelCfg.Expiry)))
+		if err := applyExpiry(ctx, cfg, time.Now()); err != nil {
+			ctx.Output.Warning(err.Error())
+		}
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
+
+// HandleTunnelApplyExpiry stops expired tunnels and removes those past their
+// grace period. It is run by the expiry timer.
+func HandleTunnelApplyExpiry(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	return applyExpiry(ctx, cfg, time.Now())
+}
+
+// applyExpiry stops and disables the tunnels that expired before now and
+// removes the ones set to be removed once their grace period has passed.
+// Stopping sets enabled to false so nothing starts the tunnel again until its
+// expiry is extended.
+func applyExpiry(ctx *actions.Context, cfg *config.Config, now time.Time) error {
+	var expired []string
+	for _, t := range cfg.Tunnels {
+		if t.Expiry.Expired(now) {
+			expired = append(expired, t.Tag)
+		}
+	}
+
+	var failed []string
+	for _, tag := range expired {
+		tunnelCfg := cfg.GetTunnelByTag(tag)
+		if tunnelCfg == nil {
+			continue
+		}
+		if tunnelCfg.Expiry.Removable(now) {
+			tagCtx := *ctx
+			tagCtx.Values = maps.Clone(ctx.Values)
+			tagCtx.Values["tag"] = tag
+			if err := HandleTunnelRemove(&tagCtx); err != nil {
+				ctx.Output.Error(fmt.Sprintf("%s: %v", tag, err))
+				failed = append(failed, tag)
+			}
+			continue
+		}
+		if !tunnelCfg.IsEnabled() {
+			continue
+		}
+
+		tunnel := router.NewTunnel(tunnelCfg)
+		if tunnel.IsActive() {
+			if err := tunnel.Stop(); err != nil {
+				ctx.Output.Error(fmt.Sprintf("%s: failed to stop: %v", tag, err))
+				failed = append(failed, tag)
+				continue
+			}
+		}
+		if err := setTunnelEnabled(cfg, tag, false); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		if cfg.IsMultiMode() && tunnel.Transport.IsDNS() {
+			if err := restartDNSRouterIfActive(); err != nil {
+				ctx.Output.Warning("Failed to update DNS router: " + err.Error())
+			}
+		}
+		ctx.Output.Status(fmt.Sprintf("Tunnel '%s' stopped (expired at %s)", tag, tunnelCfg.Expiry.At))
+	}
+
+	if err := router.SyncExpiryTimer(cfg); err != nil {
+		ctx.Output.Warning("Failed to update expiry timer: " + err.Error())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to expire %d of %d tunnels: %s", len(failed), len(expired), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// expirySummary describes an expiry in one line.
+func expirySummary(e *config.ExpiryConfig) string {
+	at, grace, err := e.Parse()
+	if err != nil {
+		return e.At
+	}
+	s := at.Local().Format("2006-01-02 15:04 MST")
+	if e.Remove {
+		if grace > 0 {
+			s += fmt.Sprintf(", removed %s later", e.Grace)
+		} else {
+			s += ", then removed"
+		}
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/clientcfg"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelExports, HandleTunnelExports)
+}
+
+// HandleTunnelExports regenerates the client bundles of one or all tunnels.
+func HandleTunnelExports(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tunnels []*config.TunnelConfig
+	if tag := ctx.GetString("tag"); tag != "" {
+		t := cfg.GetTunnelByTag(tag)
+		if t == nil {
+			return actions.TunnelNotFoundError(tag)
+		}
+		tunnels = append(tunnels, t)
+	} else {
+		for i := range cfg.Tunnels {
+			tunnels = append(tunnels, &cfg.Tunnels[i])
+		}
+	}
+	if len(tunnels) == 0 {
+		ctx.Output.Info("No tunnels configured")
+		return nil
+	}
+
+	if refreshClientBundles(ctx, cfg, tunnels) == 0 {
+		ctx.Output.Success(fmt.Sprintf("Client bundles in %s are up to date", clientcfg.ExportsDir))
+	}
+	return nil
+}
+
+// refreshClientBundles regenerates the client bundles of tunnels and tells
+// the operator which client configs changed and need redistributing. It
+// returns the number of changed configs; new ones are not counted.
+func refreshClientBundles(ctx *actions.Context, cfg *config.Config, tunnels []*config.TunnelConfig) int {
+	changed := 0
+	for _, t := range tunnels {
+		backend := cfg.GetBackendByTag(t.Backend)
+		if backend == nil {
+			continue
+		}
+		if err := recordKeyHistory(t); err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to record key history of '%s': %v", t.Tag, err))
+		}
+		changes, err := clientcfg.WriteBundle(t, backend)
+		if err != nil {
+			ctx.Output.Warning(fmt.Sprintf("Failed to update client bundle of '%s': %v", t.Tag, err))
+			continue
+		}
+		for _, c := range changes {
+			name := t.Tag
+			if c.Name != "client" {
+				name = fmt.Sprintf("%s (%s)", t.Tag, c.Name)
+			}
+			if c.New {
+				ctx.Output.Status(fmt.Sprintf("Client config of %s saved to %s", name, c.Path))
+				continue
+			}
+			changed++
+			ctx.Output.Warning(fmt.Sprintf("Client config of %s changed: %s", name, strings.Join(c.Fields, ", ")))
+			ctx.Output.Info(fmt.Sprintf("Redistribute %s to its users", c.Path))
+		}
+	}
+	return changed
+}
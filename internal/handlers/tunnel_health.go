@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelHealth, HandleTunnelHealth)
+}
+
+// HandleTunnelHealth routes the health endpoint through a tunnel, stops
+// routing it, or probes it locally.
+func HandleTunnelHealth(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+	backend := cfg.GetBackendByTag(tunnelCfg.Backend)
+	if backend == nil {
+		return actions.BackendNotFoundError(tunnelCfg.Backend)
+	}
+
+	if ctx.GetBool("probe") {
+		return probeTunnelHealth(ctx, tunnelCfg)
+	}
+
+	if ctx.GetBool("disable") {
+		if tunnelCfg.Health == nil {
+			ctx.Output.Info(fmt.Sprintf("Tunnel '%s' does not route the health endpoint", tag))
+			return nil
+		}
+		tunnelCfg.Health = nil
+	} else {
+		if tunnelCfg.Health != nil {
+			ctx.Output.Info(fmt.Sprintf("Tunnel '%s' already routes the health endpoint", tag))
+			return nil
+		}
+		tunnelCfg.Health = &config.HealthConfig{}
+		if config.HasFront(tunnelCfg.Transport, backend.Type) {
+			// A session limit may already have put a front before the backend
+			tunnelCfg.Health.Port = tunnelCfg.FrontPort()
+			if tunnelCfg.Health.Port == 0 {
+				port, err := cfg.AllocateNextPort()
+				if err != nil {
+					return portRangeError(err)
+				}
+				tunnelCfg.Health.Port = port
+			}
+		}
+	}
+	tunnelCfg.MarkModified()
+
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Check the port range with 'dnstm ports list'")
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Health endpoint: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveTunnel(cfg, tunnelCfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	// The fronts must be up before a tunnel forwards to them
+	if err := health.Sync(cfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to update health service: %w", err))
+	}
+	ctx.Output.Status("Health service updated")
+
+	r, err := router.New(cfg)
+	if err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to create router: %w", err))
+	}
+	if err := r.RegenerateTunnel(tag); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to rebuild tunnel service: %w", err))
+	}
+	ctx.Output.Status(fmt.Sprintf("Rebuilt tunnel service: %s", tag))
+
+	if tunnelCfg.Health == nil {
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' no longer routes the health endpoint", tag))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' routes the health endpoint", tag))
+		if tunnelCfg.Health.Port != 0 {
+			ctx.Output.Info(fmt.Sprintf("Clients probe by sending %q as the first bytes of a stream", health.ProbeRequest))
+		} else {
+			ctx.Output.Info(fmt.Sprintf("Clients probe by connecting to %s through the tunnel and sending %q", health.Addr, health.ProbeRequest))
+		}
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
+
+// probeTunnelHealth sends a probe to the endpoint a tunnel routes, as a
+// client's would arrive from the transport.
+func probeTunnelHealth(ctx *actions.Context, t *config.TunnelConfig) error {
+	if t.Health == nil {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' does not route the health endpoint", t.Tag),
+			fmt.Sprintf("Enable it with 'dnstm tunnel health -t %s'", t.Tag),
+		)
+	}
+	addr := health.FrontAddr(t)
+	if addr == "" {
+		addr = health.Addr
+	}
+	line, err := health.Probe(addr, 5*time.Second)
+	if err != nil {
+		return actions.NewActionError(
+			fmt.Sprintf("no answer from %s: %v", addr, err),
+			"Check the health service with 'systemctl status "+health.ServiceName+"'",
+		)
+	}
+	ctx.Output.Success(fmt.Sprintf("%s answered: %s", addr, line))
+	return nil
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelLabel, HandleTunnelLabel)
+}
+
+// HandleTunnelLabel sets and removes a tunnel's labels.
+func HandleTunnelLabel(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	set, err := config.ParseLabels(ctx.GetString("set"))
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Usage: dnstm tunnel label -t <tag> --set region=eu,customer=acme")
+	}
+	remove := ctx.GetString("remove")
+	if len(set) == 0 && strings.TrimSpace(remove) == "" {
+		if len(tunnelCfg.Labels) == 0 {
+			ctx.Output.Info(fmt.Sprintf("Tunnel '%s' has no labels", tag))
+		} else {
+			ctx.Output.Println(config.FormatLabels(tunnelCfg.Labels))
+		}
+		return nil
+	}
+
+	labels := maps.Clone(tunnelCfg.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for _, key := range strings.Split(remove, ",") {
+		delete(labels, strings.TrimSpace(key))
+	}
+	maps.Copy(labels, set)
+	if len(labels) == 0 {
+		labels = nil
+	}
+
+	if err := config.Update(func(c *config.Config) error {
+		t := c.GetTunnelByTag(tag)
+		if t == nil {
+			return actions.TunnelNotFoundError(tag)
+		}
+		t.Labels = labels
+		t.MarkModified()
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	tunnelCfg.Labels = labels
+
+	if len(labels) == 0 {
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' has no labels", tag))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' labels: %s", tag, config.FormatLabels(labels)))
+	}
+	return nil
+}
+
+// forSelectedTunnels runs a single-tunnel handler for each tunnel whose
+// labels match the --selector flag, as if its tag were given with -t.
+// The rescue tunnel is never selected. A failure on one tunnel does not
+// stop the others.
+func forSelectedTunnels(ctx *actions.Context, handler actions.Handler) error {
+	if ctx.GetString("tag") != "" {
+		return actions.NewActionError("--tag and --selector cannot be combined", "Give either one tunnel tag or a label selector")
+	}
+	sel, err := config.ParseSelector(ctx.GetString("selector"))
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Selectors look like region=eu,customer!=acme")
+	}
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tags []string
+	for _, t := range cfg.SelectTunnels(sel) {
+		if !t.Rescue {
+			tags = append(tags, t.Tag)
+		}
+	}
+	if len(tags) == 0 {
+		return actions.NewActionError(
+			fmt.Sprintf("no tunnels match '%s'", ctx.GetString("selector")),
+			"Check the labels with 'dnstm tunnel list'",
+		)
+	}
+
+	var failed []string
+	for _, tag := range tags {
+		tagCtx := *ctx
+		tagCtx.Values = maps.Clone(ctx.Values)
+		tagCtx.Values["tag"] = tag
+		tagCtx.Values["selector"] = ""
+		if err := handler(&tagCtx); err != nil {
+			ctx.Output.Error(fmt.Sprintf("%s: %v", tag, err))
+			failed = append(failed, tag)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed for %d of %d tunnels: %s", len(failed), len(tags), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/network"
 	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/usage"
 )
 
 func init() {
@@ -17,6 +20,10 @@ func init() {
 
 // HandleTunnelStart enables and starts a tunnel.
 func HandleTunnelStart(ctx *actions.Context) error {
+	if ctx.GetString("selector") != "" {
+		return forSelectedTunnels(ctx, HandleTunnelStart)
+	}
+
 	cfg, err := RequireConfig(ctx)
 	if err != nil {
 		return err
@@ -32,14 +39,46 @@ func HandleTunnelStart(ctx *actions.Context) error {
 		return actions.TunnelNotFoundError(tag)
 	}
 
-	// Single mode guard: must be the active tunnel
-	if cfg.IsSingleMode() && cfg.Route.Active != tag {
+	// Single mode guard: must be the active tunnel (fallbacks and tunnels
+	// with their own bind_host run alongside it)
+	if cfg.IsSingleMode() && !tunnelCfg.RunsAlongsideActive() && cfg.Route.Active != tag {
 		return fmt.Errorf("tunnel '%s' is not the active tunnel. Switch with: dnstm router switch -t %s", tag, tag)
 	}
 
 	tunnel := router.NewTunnel(tunnelCfg)
+	if tunnel.IsQuarantined() {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' is quarantined after a crash loop", tag),
+			fmt.Sprintf("Check 'dnstm tunnel logs -t %s', then run 'dnstm tunnel unquarantine -t %s'", tag, tag),
+		)
+	}
+	if tunnelCfg.IsPaused() {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' is paused", tag),
+			fmt.Sprintf("Resume it with 'dnstm tunnel resume -t %s'", tag),
+		)
+	}
+	if usage.IsCutOff(config.QuotaTunnel + ":" + tag) {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' is cut off for exceeding its monthly quota", tag),
+			fmt.Sprintf("Raise the quota or run 'dnstm report reset tunnel:%s'", tag),
+		)
+	}
+	if tunnelCfg.Expiry.Expired(time.Now()) {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' expired at %s", tag, tunnelCfg.Expiry.At),
+			fmt.Sprintf("Extend it with 'dnstm tunnel expiry -t %s --at <date>' or run 'dnstm tunnel expiry -t %s --disable'", tag, tag),
+		)
+	}
 	isRunning := tunnel.IsActive()
 
+	// Single mode: the active tunnel binds port 53 on the external IP itself
+	if cfg.IsSingleMode() && cfg.Route.Active == tag {
+		if err := resolvePort53Conflicts(ctx, cfg, "single"); err != nil {
+			return err
+		}
+	}
+
 	if isRunning {
 		beginProgress(ctx, fmt.Sprintf("Restart Tunnel: %s", tag))
 	} else {
@@ -47,9 +86,7 @@ func HandleTunnelStart(ctx *actions.Context) error {
 	}
 
 	// Enable in config
-	enabled := true
-	tunnelCfg.Enabled = &enabled
-	if err := cfg.Save(); err != nil {
+	if err := setTunnelEnabled(cfg, tag, true); err != nil {
 		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
 	}
 
@@ -57,18 +94,21 @@ func HandleTunnelStart(ctx *actions.Context) error {
 	if isRunning {
 		ctx.Output.Info("Restarting tunnel...")
 		if err := enableAndStartTunnel(ctx, cfg, tunnel); err != nil {
-			rollbackEnabled(tunnelCfg, cfg, false)
+			_ = setTunnelEnabled(cfg, tag, false)
 			return failProgress(ctx, fmt.Errorf("failed to restart tunnel: %w", err))
 		}
 		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' restarted", tag))
 	} else {
 		ctx.Output.Info("Starting tunnel...")
 		if err := enableAndStartTunnel(ctx, cfg, tunnel); err != nil {
-			rollbackEnabled(tunnelCfg, cfg, false)
+			_ = setTunnelEnabled(cfg, tag, false)
 			return failProgress(ctx, fmt.Errorf("failed to start tunnel: %w", err))
 		}
 		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' started", tag))
 	}
+	if !tunnelCfg.InSchedule(time.Now()) {
+		ctx.Output.Warning(fmt.Sprintf("Tunnel '%s' is outside its schedule and will be stopped when its schedule timer next runs", tag))
+	}
 
 	endProgress(ctx)
 	return nil
@@ -76,6 +116,10 @@ func HandleTunnelStart(ctx *actions.Context) error {
 
 // HandleTunnelStop stops and disables a tunnel.
 func HandleTunnelStop(ctx *actions.Context) error {
+	if ctx.GetString("selector") != "" {
+		return forSelectedTunnels(ctx, HandleTunnelStop)
+	}
+
 	cfg, err := RequireConfig(ctx)
 	if err != nil {
 		return err
@@ -93,8 +137,9 @@ func HandleTunnelStop(ctx *actions.Context) error {
 
 	tunnel := router.NewTunnel(tunnelCfg)
 
-	// Guard: if not running, just inform
-	if !tunnel.IsActive() {
+	// Guard: if not running, just inform. A paused tunnel is stopped
+	// already but still has its route to withdraw.
+	if !tunnel.IsActive() && !tunnelCfg.IsPaused() {
 		ctx.Output.Info(fmt.Sprintf("Tunnel '%s' is not running", tag))
 		return nil
 	}
@@ -108,9 +153,16 @@ func HandleTunnelStop(ctx *actions.Context) error {
 	}
 
 	// Disable in config
-	enabled := false
-	tunnelCfg.Enabled = &enabled
-	if err := cfg.Save(); err != nil {
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		t := c.GetTunnelByTag(tag)
+		if t == nil {
+			return actions.TunnelNotFoundError(tag)
+		}
+		enabled := false
+		t.Enabled = &enabled
+		t.Paused = ""
+		return nil
+	}); err != nil {
 		ctx.Output.Warning("Failed to save config: " + err.Error())
 	}
 
@@ -134,6 +186,10 @@ func HandleTunnelStop(ctx *actions.Context) error {
 
 // HandleTunnelRestart restarts a running tunnel.
 func HandleTunnelRestart(ctx *actions.Context) error {
+	if ctx.GetString("selector") != "" {
+		return forSelectedTunnels(ctx, HandleTunnelRestart)
+	}
+
 	if _, err := RequireConfig(ctx); err != nil {
 		return err
 	}
@@ -167,19 +223,30 @@ func HandleTunnelRestart(ctx *actions.Context) error {
 	return nil
 }
 
-// enableAndStartTunnel restarts the DNS router in multi mode,
-// and starts (or restarts) the tunnel. Start/Restart handle systemd enabling.
+// enableAndStartTunnel restarts the DNS router in multi mode, starts (or
+// restarts) the tunnel and waits until it listens. Start/Restart handle
+// systemd enabling.
 func enableAndStartTunnel(ctx *actions.Context, cfg *config.Config, tunnel *router.Tunnel) error {
-	if cfg.IsMultiMode() {
+	if cfg.IsMultiMode() && tunnel.Transport.IsDNS() {
 		if err := restartDNSRouterIfActive(); err != nil {
 			ctx.Output.Warning("Failed to update DNS router: " + err.Error())
 		}
 	}
+	for _, port := range cfg.PublicPorts(tunnel.Config) {
+		network.AllowTCPPort(port)
+	}
 
+	start := tunnel.Start
 	if tunnel.IsActive() {
-		return tunnel.Restart()
+		start = tunnel.Restart
 	}
-	return tunnel.Start()
+	if err := start(); err != nil {
+		return err
+	}
+	if err := tunnel.WaitReady(cfg.IsSingleMode() && cfg.Route.Active == tunnel.Tag, cfg.Services.GetReadyTimeout()); err != nil {
+		return fmt.Errorf("%w; check the logs with 'dnstm tunnel logs -t %s'", err, tunnel.Tag)
+	}
+	return nil
 }
 
 // restartDNSRouterIfActive restarts the DNS router service if it's running.
@@ -190,9 +257,3 @@ func restartDNSRouterIfActive() error {
 	}
 	return nil
 }
-
-// rollbackEnabled rolls back the Enabled config field and saves.
-func rollbackEnabled(tunnelCfg *config.TunnelConfig, cfg *config.Config, value bool) {
-	tunnelCfg.Enabled = &value
-	_ = cfg.Save()
-}
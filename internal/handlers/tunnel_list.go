@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/logscan"
 	"github.com/net2share/dnstm/internal/router"
 )
 
@@ -22,6 +25,23 @@ func HandleTunnelList(ctx *actions.Context) error {
 		return nil
 	}
 
+	sel, err := config.ParseSelector(ctx.GetString("selector"))
+	if err != nil {
+		return actions.NewActionError(err.Error(), "Selectors look like region=eu,customer!=acme")
+	}
+	showRescue := ctx.GetBool("all")
+	var tunnels []*config.TunnelConfig
+	for _, t := range cfg.SelectTunnels(sel) {
+		if t.Rescue && !showRescue {
+			continue
+		}
+		tunnels = append(tunnels, t)
+	}
+	if len(tunnels) == 0 {
+		ctx.Output.Println("No tunnels match " + ctx.GetString("selector"))
+		return nil
+	}
+
 	ctx.Output.Println()
 	modeName := GetModeDisplayName(cfg.Route.Mode)
 	ctx.Output.Printf("Mode: %s\n\n", modeName)
@@ -31,11 +51,24 @@ func HandleTunnelList(ctx *actions.Context) error {
 	ctx.Output.Separator(90)
 
 	// Print tunnels
-	for _, t := range cfg.Tunnels {
-		tunnel := router.NewTunnel(&t)
+	router.PrefetchStates(cfg.Tunnels)
+	quarantined, degraded := false, false
+	for _, t := range tunnels {
+		tunnel := router.NewTunnel(t)
 		status := "Stopped"
 		if tunnel.IsActive() {
 			status = "Running"
+			if logscan.IsDegraded(t.Tag) {
+				status = "Degraded"
+				degraded = true
+			}
+		} else if tunnel.IsQuarantined() {
+			status = "Quarantined"
+			quarantined = true
+		} else if t.IsPaused() {
+			status = "Paused"
+		} else if t.Expiry.Expired(time.Now()) {
+			status = "Expired"
 		}
 
 		// Add marker for active/default tunnel
@@ -44,16 +77,27 @@ func HandleTunnelList(ctx *actions.Context) error {
 			marker = " *"
 		} else if cfg.IsMultiMode() && cfg.Route.Default == t.Tag {
 			marker = " (default)"
+		} else if t.Rescue {
+			marker = " (rescue)"
 		}
 
 		transportName := config.GetTransportTypeDisplayName(t.Transport)
 		ctx.Output.Printf("%-16s %-12s %-16s %-8d %-20s %s%s\n",
 			t.Tag, transportName, t.Backend, t.Port, t.Domain, status, marker)
+		if len(t.Labels) > 0 {
+			ctx.Output.Printf("%-16s labels: %s\n", "", config.FormatLabels(t.Labels))
+		}
 	}
 
 	if cfg.IsSingleMode() {
 		ctx.Output.Println("\n* = active tunnel")
 	}
+	if degraded {
+		ctx.Output.Warning("Degraded tunnels log errors. See why with: dnstm tunnel status -t <tag>")
+	}
+	if quarantined {
+		ctx.Output.Warning("Quarantined tunnels crash-looped and are not restarted. Check their logs, then run: dnstm tunnel unquarantine -t <tag>")
+	}
 	ctx.Output.Println()
 
 	return nil
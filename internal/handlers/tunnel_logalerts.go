@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/logscan"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelLogAlerts, HandleTunnelLogAlerts)
+	actions.SetTunnelHandler(actions.ActionTunnelScanLogs, HandleTunnelScanLogs)
+}
+
+// maxScanWindow bounds how far back a scan reads, e.g. after the timer did
+// not run for a while.
+const maxScanWindow = time.Hour
+
+// HandleTunnelLogAlerts turns scanning tunnel logs on or off.
+func HandleTunnelLogAlerts(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.GetBool("disable") {
+		cfg.Log.Alerts = nil
+	} else {
+		alerts := cfg.Log.Alerts
+		if alerts == nil {
+			alerts = &config.LogAlertsConfig{}
+		}
+		if threshold := ctx.GetInt("threshold"); threshold > 0 {
+			alerts.Threshold = threshold
+		}
+		cfg.Log.Alerts = alerts
+	}
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Example: --threshold 10")
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		c.Log.Alerts = cfg.Log.Alerts
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := router.SyncLogScanTimer(cfg); err != nil {
+		return fmt.Errorf("failed to update log scan timer: %w", err)
+	}
+
+	ctx.Output.Println()
+	if cfg.Log.Alerts == nil {
+		ctx.Output.Success("Log alerts disabled")
+		ctx.Output.Println()
+		return nil
+	}
+	ctx.Output.Success(fmt.Sprintf("Tunnel logs are scanned every 5 minutes (threshold %d)", cfg.Log.Alerts.GetThreshold()))
+	ctx.Output.Info("Degraded tunnels are shown in 'dnstm tunnel list' and alerted in the journal and on terminals.")
+	ctx.Output.Println()
+	return nil
+}
+
+// HandleTunnelScanLogs scans what the running tunnels logged since the last
+// scan. It is run by the log scan timer.
+func HandleTunnelScanLogs(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+	alerts := cfg.Log.Alerts
+	if alerts == nil {
+		ctx.Output.Info("Log alerts are not enabled")
+		ctx.Output.Println("  Enable them with: dnstm tunnel log-alerts")
+		return nil
+	}
+
+	now := time.Now()
+	for _, t := range cfg.GetEnabledTunnels() {
+		tunnel := router.NewTunnel(t)
+		if !tunnel.IsActive() {
+			continue
+		}
+		state := logscan.Get(t.Tag)
+		if state == nil {
+			state = &logscan.State{Tag: t.Tag}
+		}
+		since := state.Checked
+		if since.IsZero() || now.Sub(since) > maxScanWindow {
+			since = now.Add(-maxScanWindow)
+		}
+
+		lines, err := service.GetServiceLogsSince(tunnel.ServiceName, since)
+		if err != nil {
+			return err
+		}
+		matches, err := logscan.Count(lines, alerts.GetPatterns(t.Transport))
+		if err != nil {
+			return err
+		}
+		if state.Apply(matches, alerts.GetThreshold(), now) {
+			if state.Degraded {
+				alertOperator(fmt.Sprintf("dnstm: tunnel '%s' is degraded: %s. Check 'journalctl -u %s'.",
+					t.Tag, describeMatches(matches), tunnel.ServiceName))
+			} else {
+				ctx.Output.Info(fmt.Sprintf("Tunnel '%s' recovered: no errors in its log since the last scan", t.Tag))
+			}
+		}
+		if err := logscan.Save(state); err != nil {
+			return err
+		}
+
+		status := "ok"
+		if state.Degraded {
+			status = "degraded"
+		}
+		if len(matches) == 0 {
+			ctx.Output.Printf("%-20s %s\n", t.Tag, status)
+		} else {
+			ctx.Output.Printf("%-20s %s (%s)\n", t.Tag, status, describeMatches(matches))
+		}
+	}
+	return nil
+}
+
+// describeMatches summarizes matched patterns, highest count first.
+func describeMatches(matches []logscan.Match) string {
+	parts := make([]string, len(matches))
+	for i, m := range matches {
+		parts[i] = fmt.Sprintf("%d lines matched %q", m.Count, m.Pattern)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// alertOperator logs msg at critical priority in the journal and sends it
+// to all terminals.
+func alertOperator(msg string) {
+	fmt.Fprintln(os.Stderr, "<2>"+msg)
+	exec.Command("wall", msg).Run()
+}
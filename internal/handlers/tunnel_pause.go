@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelPause, HandleTunnelPause)
+	actions.SetTunnelHandler(actions.ActionTunnelResume, HandleTunnelResume)
+}
+
+// HandleTunnelPause stops a tunnel while the DNS router keeps answering
+// its domain with an error reply.
+func HandleTunnelPause(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	if !cfg.IsMultiMode() {
+		return actions.NewActionError(
+			"pausing needs multi mode, where the DNS router answers for the tunnel",
+			fmt.Sprintf("Switch with 'dnstm router mode multi', or stop the tunnel with 'dnstm tunnel stop -t %s'", tag),
+		)
+	}
+	if !tunnelCfg.Transport.IsDNS() {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' is not reached through the DNS router", tag),
+			fmt.Sprintf("Stop it with 'dnstm tunnel stop -t %s'", tag),
+		)
+	}
+	if !tunnelCfg.IsEnabled() {
+		return fmt.Errorf("tunnel '%s' is stopped, it has no route to keep", tag)
+	}
+
+	reply := ctx.GetString("reply")
+	if reply == "" {
+		reply = config.PauseServfail
+	}
+	if tunnelCfg.Paused == reply {
+		ctx.Output.Info(fmt.Sprintf("Tunnel '%s' is already paused", tag))
+		return nil
+	}
+
+	previous := tunnelCfg.Paused
+	tunnelCfg.Paused = reply
+	if err := cfg.Validate(); err != nil {
+		tunnelCfg.Paused = previous
+		return err
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Pause Tunnel: %s", tag))
+
+	if err := saveTunnel(cfg, tunnelCfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+
+	// The route switches to the error reply before the backend goes away
+	if err := restartDNSRouterIfActive(); err != nil {
+		ctx.Output.Warning("Failed to update DNS router: " + err.Error())
+	}
+
+	tunnel := router.NewTunnel(tunnelCfg)
+	if tunnel.IsActive() {
+		ctx.Output.Info("Stopping tunnel...")
+		if err := tunnel.Stop(); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to stop tunnel: %w", err))
+		}
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Tunnel '%s' paused, %s answers with %s", tag, tunnelCfg.Domain, reply))
+	endProgress(ctx)
+	return nil
+}
+
+// HandleTunnelResume starts a paused tunnel and routes its domain to it
+// again.
+func HandleTunnelResume(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	if !tunnelCfg.IsPaused() {
+		ctx.Output.Info(fmt.Sprintf("Tunnel '%s' is not paused", tag))
+		return nil
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Resume Tunnel: %s", tag))
+
+	tunnelCfg.Paused = ""
+	if err := saveTunnel(cfg, tunnelCfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+
+	if tunnelCfg.IsEnabled() {
+		ctx.Output.Info("Starting tunnel...")
+		if err := enableAndStartTunnel(ctx, cfg, router.NewTunnel(tunnelCfg)); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to start tunnel: %w", err))
+		}
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Tunnel '%s' resumed", tag))
+	endProgress(ctx)
+	return nil
+}
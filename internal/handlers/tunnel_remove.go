@@ -4,7 +4,9 @@ import (
 	"fmt"
 
 	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/clientcfg"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
 	"github.com/net2share/dnstm/internal/router"
 )
 
@@ -28,6 +30,12 @@ func HandleTunnelRemove(ctx *actions.Context) error {
 	if tunnelCfg == nil {
 		return actions.TunnelNotFoundError(tag)
 	}
+	if tunnelCfg.Rescue {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' is the rescue tunnel", tag),
+			"Remove it with 'dnstm rescue disable'",
+		)
+	}
 
 	// Track if removing the active tunnel in single mode (for warning after removal)
 	wasActiveSingleMode := cfg.IsSingleMode() && cfg.Route.Active == tag
@@ -70,31 +78,62 @@ func HandleTunnelRemove(ctx *actions.Context) error {
 	ctx.Output.Step(currentStep, totalSteps, "Updating router configuration...")
 
 	// Remove tunnel from config
-	var newTunnels []config.TunnelConfig
-	for _, t := range cfg.Tunnels {
-		if t.Tag != tag {
-			newTunnels = append(newTunnels, t)
+	rerouted := false
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		// Removing a canary returns its clients to the stable tunnel; removing
+		// the stable tunnel leaves the domain to its canary
+		if t := c.GetTunnelByTag(tag); t != nil && t.Canary != nil {
+			rerouted = true
+		}
+		if stable := c.CanaryOf(tag); stable != nil {
+			stable.Canary = nil
+			rerouted = true
 		}
-	}
-	cfg.Tunnels = newTunnels
 
-	// Update Route.Default if needed (multi mode)
-	if cfg.Route.Default == tag {
-		cfg.Route.Default = ""
-		if len(cfg.Tunnels) > 0 {
-			cfg.Route.Default = cfg.Tunnels[0].Tag
+		var newTunnels []config.TunnelConfig
+		for _, t := range c.Tunnels {
+			if t.Tag != tag {
+				newTunnels = append(newTunnels, t)
+			}
+		}
+		c.Tunnels = newTunnels
+
+		// Update Route.Default if needed (multi mode)
+		if c.Route.Default == tag {
+			c.Route.Default = ""
+			for _, t := range c.Tunnels {
+				if t.Transport.IsDNS() && !t.Rescue {
+					c.Route.Default = t.Tag
+					break
+				}
+			}
 		}
-	}
 
-	// Clear Route.Active if removing the active tunnel (single mode)
-	if cfg.Route.Active == tag {
-		cfg.Route.Active = ""
-	}
+		// Clear Route.Active if removing the active tunnel (single mode)
+		if c.Route.Active == tag {
+			c.Route.Active = ""
+		}
 
-	if err := cfg.Save(); err != nil {
+		c.RemoveQuota(config.QuotaTunnel + ":" + tag)
+		return nil
+	}); err != nil {
 		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
 	}
 	ctx.Output.Status("Configuration updated")
+	if rerouted && cfg.IsMultiMode() {
+		if err := restartDNSRouterIfActive(); err != nil {
+			ctx.Output.Warning("Failed to update DNS router: " + err.Error())
+		}
+	}
+	if err := clientcfg.RemoveBundle(tag); err != nil {
+		ctx.Output.Warning(err.Error())
+	}
+	if err := router.SyncExpiryTimer(cfg); err != nil {
+		ctx.Output.Warning("Failed to update expiry timer: " + err.Error())
+	}
+	if err := health.Sync(cfg); err != nil {
+		ctx.Output.Warning("Failed to update health service: " + err.Error())
+	}
 
 	ctx.Output.Success(fmt.Sprintf("Tunnel '%s' removed!", tag))
 
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/usage"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelSchedule, HandleTunnelSchedule)
+	actions.SetTunnelHandler(actions.ActionTunnelApplySchedule, HandleTunnelApplySchedule)
+}
+
+// HandleTunnelSchedule sets or clears the active hours and blackout windows
+// of a tunnel, installs its schedule timer, and applies the schedule now.
+func HandleTunnelSchedule(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	active := splitWindows(ctx.GetString("active"))
+	blackout := splitWindows(ctx.GetString("blackout"))
+	if ctx.GetBool("disable") || (len(active) == 0 && len(blackout) == 0) {
+		tunnelCfg.Schedule = nil
+	} else {
+		tunnelCfg.Schedule = &config.ScheduleConfig{Active: active, Blackout: blackout}
+	}
+	tunnelCfg.MarkModified()
+
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Use windows like --active 18:00-02:00 --blackout 03:00-04:00, or --disable")
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Schedule: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveTunnel(cfg, tunnelCfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	tunnel := router.NewTunnel(tunnelCfg)
+	if err := tunnel.SyncScheduleTimer(); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to update schedule timer: %w", err))
+	}
+	if tunnelCfg.Schedule != nil {
+		ctx.Output.Status(fmt.Sprintf("Installed timer: %s", tunnel.ScheduleTimerName()))
+	}
+
+	if err := applySchedule(ctx, cfg, tunnel); err != nil {
+		ctx.Output.Warning(err.Error())
+	}
+
+	if tunnelCfg.Schedule == nil {
+		ctx.Output.Success(fmt.Sprintf("Schedule removed for '%s'", tag))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Schedule set for '%s' (%s)", tag, scheduleSummary(tunnelCfg.Schedule)))
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
+
+// HandleTunnelApplySchedule starts or stops a tunnel to match its schedule.
+func HandleTunnelApplySchedule(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	return applySchedule(ctx, cfg, router.NewTunnel(tunnelCfg))
+}
+
+// applySchedule starts a tunnel inside its schedule and stops it outside.
+// Tunnels that would not run anyway (disabled, paused, quarantined, cut off,
+// or not the active tunnel in single mode) are left alone. Stopping keeps the tunnel
+// enabled in the config so the next window starts it again.
+func applySchedule(ctx *actions.Context, cfg *config.Config, tunnel *router.Tunnel) error {
+	tunnelCfg := tunnel.Config
+	if !tunnelCfg.IsEnabled() || tunnelCfg.IsPaused() || tunnel.IsQuarantined() || usage.IsCutOff(config.QuotaTunnel+":"+tunnel.Tag) {
+		return nil
+	}
+	if cfg.IsSingleMode() && tunnel.Transport.IsDNS() && cfg.Route.Active != tunnel.Tag {
+		return nil
+	}
+
+	running := tunnel.IsActive()
+	if tunnelCfg.InSchedule(time.Now()) {
+		if running {
+			return nil
+		}
+		if err := enableAndStartTunnel(ctx, cfg, tunnel); err != nil {
+			return fmt.Errorf("failed to start tunnel '%s': %w", tunnel.Tag, err)
+		}
+		ctx.Output.Status(fmt.Sprintf("Tunnel '%s' started (inside its schedule)", tunnel.Tag))
+		return nil
+	}
+
+	if !running {
+		return nil
+	}
+	if err := tunnel.Stop(); err != nil {
+		return fmt.Errorf("failed to stop tunnel '%s': %w", tunnel.Tag, err)
+	}
+	ctx.Output.Status(fmt.Sprintf("Tunnel '%s' stopped (outside its schedule)", tunnel.Tag))
+	return nil
+}
+
+// splitWindows splits a comma-separated list of windows.
+func splitWindows(s string) []string {
+	var windows []string
+	for _, w := range strings.Split(s, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// scheduleSummary describes a schedule in one line.
+func scheduleSummary(s *config.ScheduleConfig) string {
+	var parts []string
+	if len(s.Active) > 0 {
+		parts = append(parts, "active "+strings.Join(s.Active, ", "))
+	}
+	if len(s.Blackout) > 0 {
+		parts = append(parts, "blackout "+strings.Join(s.Blackout, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// nextScheduleChange returns when the schedule timer of a tunnel fires next,
+// or "" if it is not installed.
+func nextScheduleChange(tunnel *router.Tunnel) string {
+	if !service.IsTimerActive(tunnel.ScheduleTimerName()) {
+		return ""
+	}
+	return service.GetUnitProperty(tunnel.ScheduleTimerName()+".timer", "NextElapseUSecRealtime")
+}
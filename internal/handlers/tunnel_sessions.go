@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelSessions, HandleTunnelSessions)
+}
+
+// HandleTunnelSessions sets or removes the session limit of a tunnel.
+func HandleTunnelSessions(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+	backend := cfg.GetBackendByTag(tunnelCfg.Backend)
+	if backend == nil {
+		return actions.BackendNotFoundError(tunnelCfg.Backend)
+	}
+
+	oldFront := tunnelCfg.FrontPort()
+	if ctx.GetBool("disable") {
+		if tunnelCfg.Sessions == nil {
+			ctx.Output.Info(fmt.Sprintf("Tunnel '%s' has no session limit", tag))
+			return nil
+		}
+		tunnelCfg.Sessions = nil
+	} else {
+		if !config.HasFront(tunnelCfg.Transport, backend.Type) {
+			return actions.NewActionError(
+				fmt.Sprintf("session limits are not supported with %s to %s", tunnelCfg.Transport, backend.Type),
+				"Limit a DNSTT, VayDNS or Slipstream tunnel instead",
+			)
+		}
+		limit := ctx.GetInt("max")
+		if limit <= 0 {
+			return actions.UsageError("--max is required", "Usage: dnstm tunnel sessions -t <tag> --max <n>")
+		}
+		sessions := &config.SessionsConfig{Max: limit, Port: oldFront}
+		if ctx.GetString("over-limit") == config.SessionsQueue {
+			sessions.OverLimit = config.SessionsQueue
+			sessions.QueueTimeout = strings.TrimSpace(ctx.GetString("queue-timeout"))
+		} else if over := ctx.GetString("over-limit"); over != "" && over != config.SessionsReject {
+			return actions.UsageError(fmt.Sprintf("unknown over-limit behavior '%s'", over), "Use reject or queue")
+		}
+		if sessions.Port == 0 {
+			port, err := cfg.AllocateNextPort()
+			if err != nil {
+				return portRangeError(err)
+			}
+			sessions.Port = port
+		}
+		tunnelCfg.Sessions = sessions
+	}
+	tunnelCfg.MarkModified()
+
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Check the port range with 'dnstm ports list'")
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Session Limit: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveTunnel(cfg, tunnelCfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	// The front must be up before the tunnel forwards to it
+	if err := health.Sync(cfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to update health service: %w", err))
+	}
+	ctx.Output.Status("Health service updated")
+
+	// Only adding or dropping the front changes where the tunnel forwards
+	if tunnelCfg.FrontPort() != oldFront {
+		r, err := router.New(cfg)
+		if err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to create router: %w", err))
+		}
+		if err := r.RegenerateTunnel(tag); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to rebuild tunnel service: %w", err))
+		}
+		ctx.Output.Status(fmt.Sprintf("Rebuilt tunnel service: %s", tag))
+	}
+
+	if tunnelCfg.Sessions == nil {
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' no longer limits sessions", tag))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' allows %s", tag, describeSessionLimit(tunnelCfg.Sessions)))
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
+
+// describeSessionLimit formats a session limit for display.
+func describeSessionLimit(s *config.SessionsConfig) string {
+	if s.Queues() {
+		return fmt.Sprintf("%d concurrent sessions, queueing others for %s", s.Max, s.QueueTimeoutDuration())
+	}
+	return fmt.Sprintf("%d concurrent sessions, rejecting others", s.Max)
+}
+
+// describeSessions formats a session limit with the counters the health
+// service last saved for the tunnel.
+func describeSessions(tag string, s *config.SessionsConfig) string {
+	desc := describeSessionLimit(s)
+	if st := health.SessionStatsFor(tag); st != nil {
+		desc += "; " + describeSessionStats(st)
+	}
+	return desc
+}
+
+// describeSessionStats formats the counters of a tunnel's front.
+func describeSessionStats(st *health.SessionStat) string {
+	parts := []string{
+		fmt.Sprintf("%d active", st.Active),
+		fmt.Sprintf("%d total", st.Total),
+		fmt.Sprintf("%d rejected", st.Rejected),
+	}
+	if st.Queued > 0 {
+		parts = append(parts, fmt.Sprintf("%d queued (%d waiting, %d timed out)", st.Queued, st.Waiting, st.TimedOut))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -16,7 +16,8 @@ func init() {
 	actions.SetTunnelHandler(actions.ActionTunnelShare, HandleTunnelShare)
 }
 
-// HandleTunnelShare generates a dnst:// URL for client configuration.
+// HandleTunnelShare generates a dnst:// URL, or an ss:// link with --ss, for
+// client configuration.
 func HandleTunnelShare(ctx *actions.Context) error {
 	cfg, err := RequireConfig(ctx)
 	if err != nil {
@@ -39,7 +40,8 @@ func HandleTunnelShare(ctx *actions.Context) error {
 	}
 
 	opts := clientcfg.GenerateOptions{
-		NoCert: ctx.GetBool("no-cert"),
+		NoCert:          ctx.GetBool("no-cert"),
+		ShadowsocksUser: ctx.GetString("ss-user"),
 	}
 
 	// Collect and validate SSH-specific inputs
@@ -102,20 +104,63 @@ func HandleTunnelShare(ctx *actions.Context) error {
 		return fmt.Errorf("failed to generate client config: %w", err)
 	}
 
-	url, err := clientcfg.Encode(clientCfg)
+	var url string
+	if ctx.GetBool("ss") {
+		if tunnelCfg.Transport != config.TransportSlipstream || backend.Type != config.BackendShadowsocks {
+			return actions.NewActionError(
+				fmt.Sprintf("tunnel '%s' cannot be shared as an ss:// link", tag),
+				"ss:// links are only available for Slipstream tunnels with a Shadowsocks backend",
+			)
+		}
+		if opts.ShadowsocksUser != "" {
+			clientCfg.Tag = tag + "-" + opts.ShadowsocksUser
+		}
+		url, err = clientcfg.ShadowsocksURL(clientCfg)
+	} else {
+		url, err = clientcfg.Encode(clientCfg)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to encode client config: %w", err)
 	}
 
+	// Stdout carries the URL for scripts, so a failure goes to stderr
+	if err := recordKeyHistory(tunnelCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record key history: %v\n", err)
+	}
+
+	if pngPath := ctx.GetString("qr-png"); pngPath != "" {
+		png, err := clientcfg.QRPNG(url)
+		if err != nil {
+			return actions.NewActionError(err.Error(), "Use --no-cert to shorten the URL")
+		}
+		if err := os.WriteFile(pngPath, png, 0600); err != nil {
+			return fmt.Errorf("failed to write QR code: %w", err)
+		}
+	}
+
 	if ctx.IsInteractive {
 		// Print directly to terminal (not TUI) so the URL is easily selectable
 		fmt.Println()
 		fmt.Printf("Share: %s\n\n", tag)
 		fmt.Println(url)
 		fmt.Println()
+		if ctx.GetBool("qr") {
+			qr, err := clientcfg.QRTerminal(url)
+			if err != nil {
+				return actions.NewActionError(err.Error(), "Skip the certificate to shorten the URL")
+			}
+			fmt.Print(qr)
+			fmt.Println()
+		}
 		fmt.Printf("Transport: %s\n", config.GetTransportTypeDisplayName(tunnelCfg.Transport))
 		fmt.Printf("Backend:   %s\n", config.GetBackendTypeDisplayName(backend.Type))
 		fmt.Printf("Domain:    %s\n", tunnelCfg.Domain)
+		if !tunnelCfg.Transport.IsDNS() {
+			fmt.Printf("Connect:   https://%s:%d\n", tunnelCfg.Domain, tunnelCfg.Port)
+		}
+		if backend.Type == config.BackendPortForward {
+			fmt.Printf("Forward:   127.0.0.1:%d -> %s\n", backend.GetClientPort(), backend.Address)
+		}
 		fmt.Println()
 		fmt.Print("Press Enter to continue...")
 		fmt.Scanln()
@@ -123,6 +168,13 @@ func HandleTunnelShare(ctx *actions.Context) error {
 	}
 
 	ctx.Output.Println(url)
+	if ctx.GetBool("qr") {
+		qr, err := clientcfg.QRTerminal(url)
+		if err != nil {
+			return actions.NewActionError(err.Error(), "Use --no-cert to shorten the URL")
+		}
+		ctx.Output.Print(qr)
+	}
 	return nil
 }
 
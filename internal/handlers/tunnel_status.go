@@ -3,11 +3,15 @@ package handlers
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/certs"
 	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/keys"
+	"github.com/net2share/dnstm/internal/logscan"
+	"github.com/net2share/dnstm/internal/quarantine"
 	"github.com/net2share/dnstm/internal/router"
 )
 
@@ -50,6 +54,86 @@ func HandleTunnelStatus(ctx *actions.Context) error {
 			{Key: "Status", Value: tunnel.StatusString()},
 		},
 	}
+	if tunnelCfg.BindHost != "" {
+		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{
+			Key: "Bind Address", Value: tunnelCfg.BindHost + ":53",
+		})
+	}
+	if len(tunnelCfg.Labels) > 0 {
+		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{
+			Key: "Labels", Value: config.FormatLabels(tunnelCfg.Labels),
+		})
+	}
+	mainSection.Rows = append(mainSection.Rows, tunnelMetaRows(tunnelCfg)...)
+	if tunnelCfg.Rescue {
+		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{
+			Key: "Role", Value: "rescue (emergency SSH access)",
+		})
+	}
+	if r := quarantine.Get(tag); r != nil {
+		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{
+			Key: "Quarantined", Value: r.Since.Local().Format("2006-01-02 15:04:05"),
+		})
+	}
+	var health string
+	if st := logscan.Get(tag); st != nil && st.Degraded && tunnel.IsActive() {
+		health = fmt.Sprintf("degraded since %s: %s", st.Since.Local().Format("2006-01-02 15:04"), describeMatches(st.Matches))
+		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Health", Value: health})
+	}
+	watchdogStatus := "Off"
+	if tunnelCfg.Watchdog != nil {
+		if d, err := tunnelCfg.Watchdog.TimeoutDuration(); err == nil {
+			watchdogStatus = d.String()
+		}
+	}
+	mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Watchdog", Value: watchdogStatus})
+	endpointStatus := "Off"
+	if h := tunnelCfg.Health; h != nil {
+		endpointStatus = "direct (clients connect to the endpoint)"
+		if h.Port != 0 {
+			endpointStatus = fmt.Sprintf("front on port %d", h.Port)
+		}
+	}
+	mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Health endpoint", Value: endpointStatus})
+	var sessionStatus string
+	if s := tunnelCfg.Sessions; s != nil {
+		sessionStatus = describeSessions(tag, s)
+		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Sessions", Value: sessionStatus})
+	}
+	var canaryStatus string
+	if c := tunnelCfg.Canary; c != nil {
+		canaryStatus = fmt.Sprintf("%d%% of clients to %s", c.Percent, c.Tunnel)
+	} else if cfg != nil {
+		if stable := cfg.CanaryOf(tag); stable != nil {
+			canaryStatus = fmt.Sprintf("receives %d%% of the clients of %s", stable.Canary.Percent, stable.Tag)
+		}
+	}
+	if canaryStatus != "" {
+		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Canary", Value: canaryStatus})
+	}
+	var scheduleRows []actions.InfoRow
+	if tunnelCfg.Schedule != nil {
+		state := "outside (stopped by schedule)"
+		if tunnelCfg.InSchedule(time.Now()) {
+			state = "inside"
+		}
+		scheduleRows = append(scheduleRows,
+			actions.InfoRow{Key: "Schedule", Value: scheduleSummary(tunnelCfg.Schedule)},
+			actions.InfoRow{Key: "Window", Value: state},
+		)
+		if next := nextScheduleChange(tunnel); next != "" {
+			scheduleRows = append(scheduleRows, actions.InfoRow{Key: "Next Check", Value: next})
+		}
+		mainSection.Rows = append(mainSection.Rows, scheduleRows...)
+	}
+	expires := ""
+	if tunnelCfg.Expiry != nil {
+		expires = expirySummary(tunnelCfg.Expiry)
+		if tunnelCfg.Expiry.Expired(time.Now()) {
+			expires += " (expired)"
+		}
+		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Expires", Value: expires})
+	}
 	if tunnelCfg.Transport == config.TransportDNSTT && tunnelCfg.DNSTT != nil {
 		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{
 			Key: "MTU", Value: fmt.Sprintf("%d", tunnelCfg.DNSTT.MTU),
@@ -73,15 +157,38 @@ func HandleTunnelStatus(ctx *actions.Context) error {
 		}
 		mainSection.Rows = append(mainSection.Rows, actions.InfoRow{Key: "Record Type", Value: rt})
 	}
+	if c := tunnelCfg.Chisel; tunnelCfg.Transport == config.TransportChisel && c != nil {
+		mainSection.Rows = append(mainSection.Rows,
+			actions.InfoRow{Key: "Listen", Value: fmt.Sprintf("0.0.0.0:%d/tcp", tunnelCfg.Port)},
+			actions.InfoRow{Key: "Auth", Value: c.Auth},
+		)
+	}
 	infoCfg.Sections = append(infoCfg.Sections, mainSection)
+	if stats := tunnel.StatsLines(); len(stats) > 0 {
+		statsSection := actions.InfoSection{Title: "Resources"}
+		for _, line := range stats {
+			statsSection.Rows = append(statsSection.Rows, actions.InfoRow{Key: line.Key, Value: line.Value})
+		}
+		infoCfg.Sections = append(infoCfg.Sections, statsSection)
+	}
+	if tunnelCfg.Notes != "" {
+		notesSection := actions.InfoSection{Title: "Notes"}
+		for _, line := range strings.Split(tunnelCfg.Notes, "\n") {
+			notesSection.Rows = append(notesSection.Rows, actions.InfoRow{Value: line})
+		}
+		infoCfg.Sections = append(infoCfg.Sections, notesSection)
+	}
 
 	// Show certificate/key info based on transport type
 	tunnelDir := filepath.Join(config.TunnelsDir, tunnelCfg.Tag)
-	if tunnelCfg.Transport == config.TransportSlipstream {
+	if tunnelCfg.Transport == config.TransportSlipstream || tunnelCfg.Transport == config.TransportChisel {
 		certPath := filepath.Join(tunnelDir, "cert.pem")
 		if tunnelCfg.Slipstream != nil && tunnelCfg.Slipstream.Cert != "" {
 			certPath = tunnelCfg.Slipstream.Cert
 		}
+		if tunnelCfg.Chisel != nil && tunnelCfg.Chisel.Cert != "" {
+			certPath = tunnelCfg.Chisel.Cert
+		}
 		fingerprint, err := certs.ReadCertificateFingerprint(certPath)
 		if err == nil {
 			certSection := actions.InfoSection{
@@ -146,12 +253,46 @@ func HandleTunnelStatus(ctx *actions.Context) error {
 	// CLI mode - print to console
 	ctx.Output.Println()
 	ctx.Output.Println(tunnel.GetFormattedInfo())
+	if len(tunnelCfg.Labels) > 0 {
+		ctx.Output.Printf("Labels: %s\n\n", config.FormatLabels(tunnelCfg.Labels))
+	}
+	if tunnelCfg.Description != "" || tunnelCfg.Notes != "" || tunnelCfg.Meta != nil {
+		printTunnelNotes(ctx, tunnelCfg)
+		ctx.Output.Println()
+	}
+	if health != "" {
+		ctx.Output.Printf("Health: %s\n\n", health)
+	}
+	if tunnelCfg.Watchdog != nil {
+		ctx.Output.Printf("Watchdog: %s\n\n", watchdogStatus)
+	}
+	if tunnelCfg.Health != nil {
+		ctx.Output.Printf("Health endpoint: %s\n\n", endpointStatus)
+	}
+	if sessionStatus != "" {
+		ctx.Output.Printf("Sessions: %s\n\n", sessionStatus)
+	}
+	if canaryStatus != "" {
+		ctx.Output.Printf("Canary: %s\n\n", canaryStatus)
+	}
+	if len(scheduleRows) > 0 {
+		for _, row := range scheduleRows {
+			ctx.Output.Printf("%-11s %s\n", row.Key+":", row.Value)
+		}
+		ctx.Output.Println()
+	}
+	if expires != "" {
+		ctx.Output.Printf("Expires: %s\n\n", expires)
+	}
 
-	if tunnelCfg.Transport == config.TransportSlipstream {
+	if tunnelCfg.Transport == config.TransportSlipstream || tunnelCfg.Transport == config.TransportChisel {
 		certPath := filepath.Join(tunnelDir, "cert.pem")
 		if tunnelCfg.Slipstream != nil && tunnelCfg.Slipstream.Cert != "" {
 			certPath = tunnelCfg.Slipstream.Cert
 		}
+		if tunnelCfg.Chisel != nil && tunnelCfg.Chisel.Cert != "" {
+			certPath = tunnelCfg.Chisel.Cert
+		}
 		fingerprint, err := certs.ReadCertificateFingerprint(certPath)
 		if err == nil {
 			ctx.Output.Println("Certificate Fingerprint:")
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/quarantine"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelUnquarantine, HandleTunnelUnquarantine)
+}
+
+// HandleTunnelUnquarantine clears a crash-loop quarantine and starts the tunnel again.
+func HandleTunnelUnquarantine(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	tunnel := router.NewTunnel(tunnelCfg)
+	if !tunnel.IsQuarantined() {
+		ctx.Output.Info(fmt.Sprintf("Tunnel '%s' is not quarantined", tag))
+		return nil
+	}
+
+	// Tunnels that are disabled or inactive in single mode stay stopped
+	startable := tunnelCfg.IsEnabled() && (!cfg.IsSingleMode() || cfg.Route.Active == tag)
+	if startable && cfg.IsSingleMode() {
+		if err := resolvePort53Conflicts(ctx, cfg, "single"); err != nil {
+			return err
+		}
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Unquarantine Tunnel: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := quarantine.Clear(tag); err != nil {
+		return failProgress(ctx, err)
+	}
+	ctx.Output.Status("Quarantine cleared")
+
+	if startable {
+		ctx.Output.Info("Starting tunnel...")
+		if err := enableAndStartTunnel(ctx, cfg, tunnel); err != nil {
+			return failProgress(ctx, fmt.Errorf("failed to start tunnel: %w", err))
+		}
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' unquarantined and started", tag))
+	} else {
+		ctx.Output.Success(fmt.Sprintf("Tunnel '%s' unquarantined", tag))
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/updater"
+)
+
+// upgradeReadyTimeout is how long a candidate or restarted tunnel may take
+// to bind its port.
+const upgradeReadyTimeout = 30 * time.Second
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelUpgrade, HandleTunnelUpgrade)
+}
+
+// HandleTunnelUpgrade tests a new version of a tunnel's transport binary as
+// a second instance on a temporary port, then installs it and restarts the
+// tunnel, restoring the previous binary if the tunnel fails the self-test.
+func HandleTunnelUpgrade(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	binType, ok := updater.TunnelBinary(tunnelCfg)
+	if !ok {
+		return fmt.Errorf("tunnel '%s' has no transport binary to upgrade", tag)
+	}
+	def, _ := binary.GetDef(binType)
+	if def.EnvVar != "" && os.Getenv(def.EnvVar) != "" {
+		return actions.NewActionError(
+			fmt.Sprintf("%s is taken from $%s", binType, def.EnvVar),
+			fmt.Sprintf("Replace %s yourself, or unset %s", os.Getenv(def.EnvVar), def.EnvVar),
+		)
+	}
+	installed, err := binary.NewDefaultManager().GetPath(binType)
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", binType, err)
+	}
+
+	manifest, err := updater.LoadManifest()
+	if err != nil {
+		manifest = updater.NewManifest()
+	}
+	current := manifest.GetVersion(string(binType))
+
+	// Unversioned binaries are always fetched from their latest release
+	version := strings.TrimSpace(ctx.GetString("version"))
+	switch {
+	case def.SkipUpdate:
+		if version != "" {
+			return actions.UsageError(fmt.Sprintf("%s has no versioned releases", binType), "Run the command without --version")
+		}
+		version = "latest"
+	case version == "":
+		version = def.PinnedVersion
+		if !ctx.GetBool("reinstall") && !updater.IsNewer(current, version) {
+			ctx.Output.Info(fmt.Sprintf("Tunnel '%s' already runs %s %s", tag, binType, current))
+			return nil
+		}
+	case version == current && !ctx.GetBool("reinstall"):
+		ctx.Output.Info(fmt.Sprintf("Tunnel '%s' already runs %s %s", tag, binType, current))
+		return nil
+	}
+
+	r, err := router.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create router: %w", err)
+	}
+	tunnel := r.GetTunnel(tag)
+	if tunnel == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Upgrade Tunnel: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	ctx.Output.Status(fmt.Sprintf("Downloading %s %s...", binType, version))
+	staged, err := updater.StageBinary(binType, version)
+	defer updater.DiscardStaged()
+	if err != nil {
+		return failProgress(ctx, err)
+	}
+
+	port, err := cfg.AllocateNextPort()
+	if err != nil {
+		return failProgress(ctx, portRangeError(fmt.Errorf("candidate: %w", err)))
+	}
+
+	cand, err := r.StartCandidate(tag, installed, staged, port)
+	if err != nil {
+		return failProgress(ctx, err)
+	}
+	defer cand.RemoveService()
+	ctx.Output.Status(fmt.Sprintf("Started candidate %s on 127.0.0.1:%d", cand.ServiceName, port))
+
+	err = cand.WaitReady(false, upgradeReadyTimeout)
+	if err == nil {
+		err = cand.SelfTest("127.0.0.1", port)
+	}
+	if err != nil {
+		return failProgress(ctx, actions.NewActionError(
+			fmt.Sprintf("%s %s failed the self-test: %v", binType, version, err),
+			fmt.Sprintf("Tunnel '%s' was left on its current version; see 'journalctl -u %s'", tag, cand.ServiceName),
+		))
+	}
+	ctx.Output.Status("Candidate passed the self-test")
+
+	backup, err := updater.BackupFiles([]string{installed, updater.GetManifestPath()})
+	if err != nil {
+		return failProgress(ctx, err)
+	}
+	defer backup.Discard()
+
+	if err := updater.InstallStaged(staged, installed); err != nil {
+		return failProgress(ctx, err)
+	}
+	manifest.SetVersion(string(binType), version)
+	if err := manifest.Save(); err != nil {
+		ctx.Output.Warning(fmt.Sprintf("Failed to update version manifest: %v", err))
+	}
+	ctx.Output.Status(fmt.Sprintf("Installed %s %s", binType, version))
+
+	if tunnel.IsActive() {
+		singleMode := cfg.IsSingleMode() && cfg.Route.Active == tag
+		err := tunnel.Restart()
+		if err == nil {
+			err = tunnel.WaitReady(singleMode, upgradeReadyTimeout)
+		}
+		if err == nil {
+			err = r.SelfTest(tag)
+		}
+		if err != nil {
+			if rerr := backup.Restore(); rerr != nil {
+				return failProgress(ctx, fmt.Errorf("tunnel failed after the upgrade (%v) and the previous binary could not be restored: %w", err, rerr))
+			}
+			tunnel.Restart()
+			return failProgress(ctx, actions.NewActionError(
+				fmt.Sprintf("tunnel '%s' failed the self-test after the upgrade: %v", tag, err),
+				"The previous binary was restored and the tunnel restarted",
+			))
+		}
+		ctx.Output.Status(fmt.Sprintf("Restarted tunnel: %s", tag))
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Tunnel '%s' upgraded to %s %s", tag, binType, version))
+	var others []string
+	for _, name := range updater.GetActiveServicesForBinary(binType) {
+		if name != tunnel.ServiceName {
+			others = append(others, name)
+		}
+	}
+	if len(others) > 0 {
+		ctx.Output.Info(fmt.Sprintf("Still on the previous version until restarted: %s", strings.Join(others, ", ")))
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/clientcfg"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelUsersList, HandleTunnelUsersList)
+	actions.SetTunnelHandler(actions.ActionTunnelUsersAdd, HandleTunnelUsersAdd)
+	actions.SetTunnelHandler(actions.ActionTunnelUsersRemove, HandleTunnelUsersRemove)
+	actions.SetTunnelHandler(actions.ActionTunnelUsersShare, HandleTunnelUsersShare)
+}
+
+// HandleTunnelUsersList lists the users of a tunnel.
+func HandleTunnelUsersList(ctx *actions.Context) error {
+	_, tunnelCfg, _, err := loadUsersTunnel(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(tunnelCfg.Slipstream.Users) == 0 {
+		ctx.Output.Info(fmt.Sprintf("Tunnel '%s' has no users", tunnelCfg.Tag))
+		return nil
+	}
+
+	ctx.Output.Println("NAME")
+	for _, u := range tunnelCfg.Slipstream.Users {
+		ctx.Output.Println(u.Name)
+	}
+	return nil
+}
+
+// HandleTunnelUsersAdd adds a user with a key of its own to a tunnel.
+func HandleTunnelUsersAdd(ctx *actions.Context) error {
+	cfg, tunnelCfg, backend, err := loadUsersTunnel(ctx)
+	if err != nil {
+		return err
+	}
+	tag := tunnelCfg.Tag
+
+	method := backend.Shadowsocks.Method
+	if !config.ShadowsocksSupportsUsers(method) {
+		return actions.NewActionError(
+			fmt.Sprintf("backend '%s' uses %s, which has no users", backend.Tag, method),
+			fmt.Sprintf("Switch it to Shadowsocks 2022: dnstm backend reconfigure -t %s --method %s", backend.Tag, config.ShadowsocksMethod2022AES256),
+		)
+	}
+
+	name := ctx.GetString("name")
+	if err := config.ValidateShadowsocksUserName(name); err != nil {
+		return actions.UsageError(err.Error(), "Usage: dnstm tunnel users add -t <tag> -n <name>")
+	}
+	if tunnelCfg.Slipstream.GetShadowsocksUser(name) != nil {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' already has a user '%s'", tag, name),
+			"Choose another name, or remove the user first",
+		).WithCode(actions.ExitConflict)
+	}
+
+	password := ctx.GetString("password")
+	if password != "" {
+		if err := config.ValidateShadowsocksPassword(method, password); err != nil {
+			return actions.NewActionError(err.Error(), "Leave out --password to generate one")
+		}
+	} else {
+		password, err = config.GenerateShadowsocksPassword(method, cfg.Secrets)
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+	}
+
+	users := append(slices.Clone(tunnelCfg.Slipstream.Users), config.ShadowsocksUser{Name: name, Password: password})
+	if err := saveTunnelUsers(ctx, cfg, tag, users); err != nil {
+		return err
+	}
+
+	ctx.Output.Success(fmt.Sprintf("User '%s' added to '%s'", name, tag))
+	ctx.Output.Info(fmt.Sprintf("Share it with: dnstm tunnel users share -t %s -n %s", tag, name))
+	return nil
+}
+
+// HandleTunnelUsersRemove removes a user from a tunnel.
+func HandleTunnelUsersRemove(ctx *actions.Context) error {
+	cfg, tunnelCfg, _, err := loadUsersTunnel(ctx)
+	if err != nil {
+		return err
+	}
+	tag := tunnelCfg.Tag
+
+	name := ctx.GetString("name")
+	if tunnelCfg.Slipstream.GetShadowsocksUser(name) == nil {
+		return actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' has no user '%s'", tag, name),
+			fmt.Sprintf("See the users with 'dnstm tunnel users list -t %s'", tag),
+		).WithCode(actions.ExitNotFound)
+	}
+
+	users := slices.DeleteFunc(slices.Clone(tunnelCfg.Slipstream.Users), func(u config.ShadowsocksUser) bool {
+		return u.Name == name
+	})
+	if len(users) == 0 {
+		users = nil
+	}
+	if err := saveTunnelUsers(ctx, cfg, tag, users); err != nil {
+		return err
+	}
+
+	ctx.Output.Success(fmt.Sprintf("User '%s' removed from '%s'", name, tag))
+	return nil
+}
+
+// HandleTunnelUsersShare prints the ss:// link of a user.
+func HandleTunnelUsersShare(ctx *actions.Context) error {
+	_, tunnelCfg, backend, err := loadUsersTunnel(ctx)
+	if err != nil {
+		return err
+	}
+	tag := tunnelCfg.Tag
+
+	name := ctx.GetString("name")
+	clientCfg, err := clientcfg.Generate(tunnelCfg, backend, clientcfg.GenerateOptions{
+		NoCert:          true,
+		ShadowsocksUser: name,
+	})
+	if err != nil {
+		return actions.NewActionError(err.Error(), fmt.Sprintf("See the users with 'dnstm tunnel users list -t %s'", tag))
+	}
+	clientCfg.Tag = tag + "-" + name
+
+	url, err := clientcfg.ShadowsocksURL(clientCfg)
+	if err != nil {
+		return fmt.Errorf("failed to encode link: %w", err)
+	}
+
+	ctx.Output.Println(url)
+	if ctx.GetBool("qr") {
+		qr, err := clientcfg.QRTerminal(url)
+		if err != nil {
+			return err
+		}
+		ctx.Output.Print(qr)
+	}
+	return nil
+}
+
+// loadUsersTunnel loads the tunnel of a users command, which must be a
+// Slipstream tunnel with a Shadowsocks backend.
+func loadUsersTunnel(ctx *actions.Context) (*config.Config, *config.TunnelConfig, *config.BackendConfig, error) {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return nil, nil, nil, actions.TunnelNotFoundError(tag)
+	}
+
+	backend := cfg.GetBackendByTag(tunnelCfg.Backend)
+	if backend == nil {
+		return nil, nil, nil, actions.BackendNotFoundError(tunnelCfg.Backend)
+	}
+
+	if tunnelCfg.Transport != config.TransportSlipstream || backend.Type != config.BackendShadowsocks ||
+		tunnelCfg.Slipstream == nil || backend.Shadowsocks == nil {
+		return nil, nil, nil, actions.NewActionError(
+			fmt.Sprintf("tunnel '%s' is not a Slipstream tunnel with a Shadowsocks backend", tag),
+			"Only Slipstream + Shadowsocks tunnels have users",
+		)
+	}
+	return cfg, tunnelCfg, backend, nil
+}
+
+// saveTunnelUsers saves the users of a tunnel and rebuilds its service.
+func saveTunnelUsers(ctx *actions.Context, cfg *config.Config, tag string, users []config.ShadowsocksUser) error {
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	tunnelCfg.Slipstream.Users = users
+	tunnelCfg.MarkModified()
+
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Check the tunnel's backend")
+	}
+
+	if err := config.Update(func(c *config.Config) error {
+		t := c.GetTunnelByTag(tag)
+		if t == nil || t.Slipstream == nil {
+			return actions.TunnelNotFoundError(tag)
+		}
+		t.Slipstream.Users = users
+		t.MarkModified()
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	ctx.Output.Status("Configuration saved")
+
+	r, err := router.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create router: %w", err)
+	}
+	if err := r.RegenerateTunnel(tag); err != nil {
+		return fmt.Errorf("failed to rebuild tunnel service: %w", err)
+	}
+	ctx.Output.Status(fmt.Sprintf("Rebuilt tunnel service: %s", tag))
+	refreshClientBundles(ctx, cfg, []*config.TunnelConfig{tunnelCfg})
+	return nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetTunnelHandler(actions.ActionTunnelWatchdog, HandleTunnelWatchdog)
+}
+
+// HandleTunnelWatchdog enables or disables the health watchdog for a tunnel
+// and regenerates its service.
+func HandleTunnelWatchdog(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := RequireTag(ctx, "tunnel")
+	if err != nil {
+		return err
+	}
+
+	tunnelCfg := cfg.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return actions.TunnelNotFoundError(tag)
+	}
+
+	timeout := strings.TrimSpace(ctx.GetString("timeout"))
+	if ctx.GetBool("disable") || timeout == "off" {
+		tunnelCfg.Watchdog = nil
+	} else {
+		tunnelCfg.Watchdog = &config.WatchdogConfig{Timeout: timeout}
+	}
+	tunnelCfg.MarkModified()
+
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Use a duration like 30s or 1m, or --disable")
+	}
+
+	beginProgress(ctx, fmt.Sprintf("Watchdog: %s", tag))
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	if err := saveTunnel(cfg, tunnelCfg); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to save config: %w", err))
+	}
+	ctx.Output.Status("Configuration saved")
+
+	r, err := router.New(cfg)
+	if err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to create router: %w", err))
+	}
+	if err := r.RegenerateTunnel(tag); err != nil {
+		return failProgress(ctx, fmt.Errorf("failed to rebuild tunnel service: %w", err))
+	}
+	ctx.Output.Status(fmt.Sprintf("Rebuilt tunnel service: %s", tag))
+
+	if tunnelCfg.Watchdog == nil {
+		ctx.Output.Success(fmt.Sprintf("Watchdog disabled for '%s'", tag))
+	} else {
+		d, _ := tunnelCfg.Watchdog.TimeoutDuration()
+		ctx.Output.Success(fmt.Sprintf("Watchdog enabled for '%s' (restart after %s without an answer)", tag, d))
+	}
+
+	endProgress(ctx)
+	if !ctx.IsInteractive {
+		ctx.Output.Println()
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+func init() {
+	actions.SetVerifyHandler(actions.ActionVerify, HandleVerify)
+}
+
+// HandleVerify reports generated files that drifted from the config, and
+// regenerates them with --fix.
+func HandleVerify(ctx *actions.Context) error {
+	if err := CheckRequirements(ctx, true, true); err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	drifts, err := router.Verify(cfg)
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		ctx.Output.Success("All generated files match the config")
+		return nil
+	}
+	printDrifts(ctx, drifts)
+
+	if !ctx.GetBool("fix") {
+		return actions.NewActionError(
+			fmt.Sprintf("found %d differences from the config", len(drifts)),
+			"Run 'dnstm verify --fix' to regenerate them",
+		)
+	}
+
+	unfixed, err := router.FixDrift(cfg, drifts)
+	if err != nil {
+		return err
+	}
+	// Check again: regenerating must leave only what cannot be fixed
+	remaining, err := router.Verify(cfg)
+	if err != nil {
+		return err
+	}
+	if len(remaining) > len(unfixed) {
+		printDrifts(ctx, remaining)
+	}
+	if len(remaining) > 0 {
+		return actions.NewActionError(
+			fmt.Sprintf("%d differences from the config remain", len(remaining)),
+			"Missing keys and certificates are not generated again; re-create the tunnel with 'dnstm tunnel remove' and 'dnstm tunnel add'",
+		)
+	}
+	ctx.Output.Success(fmt.Sprintf("Fixed %d differences from the config", len(drifts)))
+	return nil
+}
+
+// printDrifts prints a table of drifted files.
+func printDrifts(ctx *actions.Context, drifts []router.Drift) {
+	ctx.Output.Println()
+	ctx.Output.Printf("%-20s %-50s %s\n", "TUNNEL", "FILE", "PROBLEM")
+	ctx.Output.Separator(100)
+	for _, d := range drifts {
+		tunnel := d.Tunnel
+		if tunnel == "" {
+			tunnel = "(router)"
+		}
+		ctx.Output.Printf("%-20s %-50s %s\n", tunnel, d.Path, d.Detail)
+	}
+	ctx.Output.Println()
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func init() {
+	actions.SetZoneHandler(actions.ActionZoneList, HandleZoneList)
+	actions.SetZoneHandler(actions.ActionZoneAdd, HandleZoneAdd)
+	actions.SetZoneHandler(actions.ActionZoneRemove, HandleZoneRemove)
+}
+
+// HandleZoneList lists the static records.
+func HandleZoneList(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Println()
+	if len(cfg.Route.Records) == 0 {
+		ctx.Output.Info("No static records configured")
+		ctx.Output.Println("  Add one with: dnstm zone add --name <name> --type <type> --value <value>")
+		ctx.Output.Println()
+		return nil
+	}
+
+	ctx.Output.Printf("%-32s %-6s %6s  %s\n", "NAME", "TYPE", "TTL", "VALUE")
+	ctx.Output.Separator(80)
+	for _, r := range cfg.Route.Records {
+		ttl := r.TTL
+		if ttl == 0 {
+			ttl = 3600
+		}
+		ctx.Output.Printf("%-32s %-6s %6d  %s\n", r.Name, strings.ToUpper(r.Type), ttl, r.Value)
+	}
+	ctx.Output.Println()
+	if cfg.IsSingleMode() {
+		ctx.Output.Warning("Records are only served by the DNS router. Switch with: dnstm router mode multi")
+		ctx.Output.Println()
+	}
+	return nil
+}
+
+// HandleZoneAdd adds a static record and reloads the DNS router.
+func HandleZoneAdd(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	record := config.ZoneRecord{
+		Name:  strings.ToLower(strings.TrimSuffix(strings.TrimSpace(ctx.GetString("name")), ".")),
+		Type:  strings.ToUpper(strings.TrimSpace(ctx.GetString("type"))),
+		Value: strings.TrimSpace(ctx.GetString("value")),
+		TTL:   ctx.GetInt("ttl"),
+	}
+	for _, r := range cfg.Route.Records {
+		if r.Name == record.Name && strings.EqualFold(r.Type, record.Type) && r.Value == record.Value {
+			ctx.Output.Info(fmt.Sprintf("Record %s %s %s already exists", record.Name, record.Type, record.Value))
+			return nil
+		}
+	}
+
+	cfg.Route.Records = append(cfg.Route.Records, record)
+	if err := cfg.Validate(); err != nil {
+		return actions.NewActionError(err.Error(), "Example: dnstm zone add --name mail.example.com --type A --value 203.0.113.10")
+	}
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		for _, r := range c.Route.Records {
+			if r.Name == record.Name && strings.EqualFold(r.Type, record.Type) && r.Value == record.Value {
+				return nil
+			}
+		}
+		c.Route.Records = append(c.Route.Records, record)
+		return c.Validate()
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := restartDNSRouterIfActive(); err != nil {
+		ctx.Output.Warning("Failed to restart DNS router: " + err.Error())
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Added %s %s %s", record.Name, record.Type, record.Value))
+	if cfg.IsSingleMode() {
+		ctx.Output.Warning("Records are only served by the DNS router. Switch with: dnstm router mode multi")
+	}
+	return nil
+}
+
+// HandleZoneRemove removes the static records with a name and, if given, a type.
+func HandleZoneRemove(ctx *actions.Context) error {
+	cfg, err := RequireConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(ctx.GetString("name")), "."))
+	typ := strings.TrimSpace(ctx.GetString("type"))
+
+	matches := func(r config.ZoneRecord) bool {
+		return r.Name == name && (typ == "" || strings.EqualFold(r.Type, typ))
+	}
+	removed := 0
+	for _, r := range cfg.Route.Records {
+		if matches(r) {
+			removed++
+		}
+	}
+	if removed == 0 {
+		return actions.NewActionError(fmt.Sprintf("no static record named '%s'", name), "List records with: dnstm zone list")
+	}
+
+	if err := updateConfig(cfg, func(c *config.Config) error {
+		var kept []config.ZoneRecord
+		for _, r := range c.Route.Records {
+			if !matches(r) {
+				kept = append(kept, r)
+			}
+		}
+		c.Route.Records = kept
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := restartDNSRouterIfActive(); err != nil {
+		ctx.Output.Warning("Failed to restart DNS router: " + err.Error())
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Removed %d record(s) for %s", removed, name))
+	return nil
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"time"
+)
+
+// Kinds of session events.
+const (
+	EventSessionStart = "session_start"
+	EventSessionEnd   = "session_end"
+)
+
+// SessionEvent is the start or end of a session a front passed to its
+// backend. Duration and the byte counts are only set at the end.
+type SessionEvent struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	Session   string    `json:"session"` // the same in the start and end events
+	Host      string    `json:"host"`
+	Tunnel    string    `json:"tunnel"`
+	Transport string    `json:"transport"`
+	Backend   string    `json:"backend"`
+	Client    string    `json:"client"`           // the address the front was reached from
+	Duration  float64   `json:"duration_seconds"` // end only
+	BytesUp   int64     `json:"bytes_up"`         // client to backend, end only
+	BytesDown int64     `json:"bytes_down"`       // backend to client, end only
+}
+
+// EventSink takes the session events of the fronts. Record is called on
+// the path of every session, so it must not block.
+type EventSink interface {
+	Record(SessionEvent)
+}
+
+// session is a stream a front passes to its backend while an EventSink
+// records it.
+type session struct {
+	id     string
+	client string
+	start  time.Time
+}
+
+// startSession records the start of a session on c.
+func (f *front) startSession(c net.Conn) *session {
+	s := &session{id: newSessionID(), client: c.RemoteAddr().String(), start: time.Now()}
+	if host, _, err := net.SplitHostPort(s.client); err == nil {
+		s.client = host
+	}
+	f.events.Record(f.event(EventSessionStart, s, s.start))
+	return s
+}
+
+// endSession records the end of a session and what it carried.
+func (f *front) endSession(s *session, up, down int64) {
+	now := time.Now()
+	e := f.event(EventSessionEnd, s, now)
+	e.Duration = now.Sub(s.start).Seconds()
+	e.BytesUp = up
+	e.BytesDown = down
+	f.events.Record(e)
+}
+
+func (f *front) event(kind string, s *session, at time.Time) SessionEvent {
+	return SessionEvent{
+		Time:      at,
+		Event:     kind,
+		Session:   s.id,
+		Tunnel:    f.tag,
+		Transport: f.transport,
+		Backend:   f.backend,
+		Client:    s.client,
+	}
+}
+
+// newSessionID returns a random id tying the events of a session together.
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
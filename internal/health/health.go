@@ -0,0 +1,249 @@
+// Package health serves the built-in health endpoint tunnels can route.
+//
+// Client apps probe a tunnel end to end by opening a stream through it and
+// sending ProbeRequest; dnstm answers with a line starting with ProbeReply,
+// whether or not the tunnel's backend (SSH, SOCKS, ...) is up. Transports
+// that forward to a fixed target (DNSTT, VayDNS, Slipstream) reach the
+// endpoint through a front: a loopback listener per tunnel that answers
+// probes and passes every other stream to the backend. Transports where the
+// client picks the destination (Chisel, Shadowsocks) connect to Addr.
+//
+// Fronts also enforce the session limits of their tunnels and record their
+// sessions to log.sessions, so a tunnel with a limit or a session log gets a
+// front even when it does not route the endpoint.
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// Addr is the standalone health endpoint. Besides answering probes it
+// echoes what it receives.
+const Addr = "127.0.0.1:7"
+
+// ProbeRequest is what a client sends first to probe a tunnel.
+const ProbeRequest = "DNSTM-HEALTH\n"
+
+// ProbeReply starts the answer to a probe: "DNSTM-OK <tag> <unix time>\n".
+const ProbeReply = "DNSTM-OK"
+
+// peekTimeout is how long a front waits for the first bytes of a stream
+// before passing it to the backend. Only backends that speak first, like
+// SMTP, notice it.
+const peekTimeout = 300 * time.Millisecond
+
+// FrontAddr returns the loopback address of the front of a tunnel, or "" if
+// the tunnel has none.
+func FrontAddr(t *config.TunnelConfig) string {
+	port := t.FrontPort()
+	if port == 0 {
+		return ""
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+// front passes the streams of a tunnel to its backend.
+type front struct {
+	tag       string
+	transport string
+	backend   string
+	target    string
+	probes    bool      // answer probes
+	limit     *limiter  // nil = no session limit
+	events    EventSink // nil = sessions are not recorded
+}
+
+// Serve runs the standalone endpoint and the fronts of the tunnels in cfg
+// until one of the listeners fails. The fronts of tunnels with a session
+// log pass their session events to events, which may be nil.
+func Serve(cfg *config.Config, events EventSink) error {
+	errs := make(chan error, len(cfg.Tunnels)+1)
+
+	ln, err := net.Listen("tcp", Addr)
+	if err != nil {
+		return err
+	}
+	go func() { errs <- serve(ln, func(c net.Conn) { handleEndpoint(c) }) }()
+	log.Printf("health endpoint listening on %s", Addr)
+
+	var limiters []*limiter
+	for i := range cfg.Tunnels {
+		t := &cfg.Tunnels[i]
+		addr := FrontAddr(t)
+		if addr == "" {
+			continue
+		}
+		backend := cfg.GetBackendByTag(t.Backend)
+		if backend == nil || backend.TargetAddress() == "" {
+			log.Printf("tunnel %s: no backend address, front skipped", t.Tag)
+			continue
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("tunnel %s: %w", t.Tag, err)
+		}
+		f := &front{
+			tag:       t.Tag,
+			transport: string(t.Transport),
+			backend:   t.Backend,
+			target:    backend.TargetAddress(),
+			probes:    t.Health != nil,
+		}
+		if t.SessionLog != nil {
+			f.events = events
+		}
+		if t.Sessions != nil {
+			f.limit = newLimiter(t.Tag, t.Sessions)
+			limiters = append(limiters, f.limit)
+		}
+		go func() { errs <- serve(ln, func(c net.Conn) { handleFront(c, f) }) }()
+		log.Printf("tunnel %s: front on %s for %s", f.tag, addr, f.target)
+	}
+	if len(limiters) > 0 {
+		go writeSessionStats(limiters)
+	}
+
+	return <-errs
+}
+
+func serve(ln net.Listener, handle func(net.Conn)) error {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go handle(c)
+	}
+}
+
+// reply writes the answer to a probe.
+func reply(c net.Conn, tag string) {
+	fmt.Fprintf(c, "%s %s %d\n", ProbeReply, tag, time.Now().Unix())
+}
+
+// handleEndpoint answers a probe, or echoes the stream.
+func handleEndpoint(c net.Conn) {
+	defer c.Close()
+	head, probe := peek(c, 0)
+	if probe {
+		reply(c, "dnstm")
+		return
+	}
+	if _, err := c.Write(head); err != nil {
+		return
+	}
+	io.Copy(c, c)
+}
+
+// handleFront answers a probe, or passes the stream to the backend once it
+// holds a session slot.
+func handleFront(c net.Conn, f *front) {
+	defer c.Close()
+	var head []byte
+	if f.probes {
+		var probe bool
+		if head, probe = peek(c, peekTimeout); probe {
+			reply(c, f.tag)
+			return
+		}
+	}
+
+	if f.limit != nil {
+		if !f.limit.acquire() {
+			return
+		}
+		defer f.limit.release()
+	}
+
+	b, err := net.DialTimeout("tcp", f.target, 10*time.Second)
+	if err != nil {
+		return
+	}
+	defer b.Close()
+	if _, err := b.Write(head); err != nil {
+		return
+	}
+
+	var s *session
+	if f.events != nil {
+		s = f.startSession(c)
+	}
+
+	var wg sync.WaitGroup
+	var up int64
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		up, _ = io.Copy(b, c)
+		if tc, ok := b.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+	}()
+	down, _ := io.Copy(c, b)
+	if tc, ok := c.(*net.TCPConn); ok {
+		tc.CloseWrite()
+	}
+	wg.Wait()
+
+	if s != nil {
+		f.endSession(s, int64(len(head))+up, down)
+	}
+}
+
+// peek reads the start of a stream until it is known whether it is a probe.
+// A timeout of 0 waits as long as the client takes. The bytes read are
+// returned so they can be passed on.
+func peek(c net.Conn, timeout time.Duration) (head []byte, probe bool) {
+	if timeout > 0 {
+		c.SetReadDeadline(time.Now().Add(timeout))
+		defer c.SetReadDeadline(time.Time{})
+	}
+	buf := make([]byte, len(ProbeRequest))
+	n := 0
+	for n < len(buf) {
+		m, err := c.Read(buf[n:])
+		n += m
+		if !bytes.HasPrefix([]byte(ProbeRequest), buf[:n]) || err != nil {
+			return buf[:n], false
+		}
+	}
+	return buf[:n], true
+}
+
+// Probe sends a probe to addr and returns the reply line.
+func Probe(addr string, timeout time.Duration) (string, error) {
+	c, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(timeout))
+	if _, err := io.WriteString(c, ProbeRequest); err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(io.LimitReader(c, 256)).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	if !strings.HasPrefix(line, ProbeReply+" ") {
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+	return line, nil
+}
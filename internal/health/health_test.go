@@ -0,0 +1,191 @@
+package health
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// listen serves handle on a free loopback port and returns its address.
+func listen(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go serve(ln, handle)
+	return ln.Addr().String()
+}
+
+func TestFront(t *testing.T) {
+	// The backend speaks first, like SMTP, then echoes
+	backend := listen(t, func(c net.Conn) {
+		defer c.Close()
+		io.WriteString(c, "220 ready\n")
+		io.Copy(c, c)
+	})
+	f := &front{tag: "dnstt1", target: backend, probes: true}
+	front := listen(t, func(c net.Conn) { handleFront(c, f) })
+
+	line, err := Probe(front, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	if !strings.HasPrefix(line, ProbeReply+" dnstt1 ") {
+		t.Errorf("Probe() = %q", line)
+	}
+
+	// A client that speaks first reaches the backend with its bytes intact
+	c, err := net.Dial("tcp", front)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	io.WriteString(c, "DNS query\n")
+	got := make([]byte, len("220 ready\nDNS query\n"))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != "220 ready\nDNS query\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// A client that waits for the banner gets it after the peek timeout
+	c2, err := net.Dial("tcp", front)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	c2.SetDeadline(time.Now().Add(2 * time.Second))
+	banner := make([]byte, len("220 ready\n"))
+	if _, err := io.ReadFull(c2, banner); err != nil || string(banner) != "220 ready\n" {
+		t.Errorf("banner = %q, %v", banner, err)
+	}
+}
+
+// eventList collects session events.
+type eventList struct {
+	mu     sync.Mutex
+	events []SessionEvent
+}
+
+func (l *eventList) Record(e SessionEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func (l *eventList) get() []SessionEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]SessionEvent(nil), l.events...)
+}
+
+func TestFrontEvents(t *testing.T) {
+	backend := listen(t, func(c net.Conn) {
+		defer c.Close()
+		io.Copy(c, c)
+	})
+	events := &eventList{}
+	f := &front{tag: "dnstt1", transport: "dnstt", backend: "ssh", target: backend, probes: true, events: events}
+	front := listen(t, func(c net.Conn) { handleFront(c, f) })
+
+	// Probes are not sessions
+	if _, err := Probe(front, 2*time.Second); err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+
+	c, err := net.Dial("tcp", front)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	io.WriteString(c, "hello backend")
+	got := make([]byte, len("hello backend"))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	c.(*net.TCPConn).CloseWrite()
+	io.Copy(io.Discard, c)
+	c.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(events.get()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	recorded := events.get()
+	if len(recorded) != 2 {
+		t.Fatalf("got %d events, want start and end: %+v", len(recorded), recorded)
+	}
+	start, end := recorded[0], recorded[1]
+	if start.Event != EventSessionStart || end.Event != EventSessionEnd || start.Session != end.Session || start.Session == "" {
+		t.Errorf("events = %+v", recorded)
+	}
+	if end.Tunnel != "dnstt1" || end.Transport != "dnstt" || end.Backend != "ssh" || end.Client != "127.0.0.1" {
+		t.Errorf("end = %+v", end)
+	}
+	if end.BytesUp != 13 || end.BytesDown != 13 || end.Duration <= 0 {
+		t.Errorf("end counts = %d up, %d down, %fs", end.BytesUp, end.BytesDown, end.Duration)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	addr := listen(t, handleEndpoint)
+
+	if line, err := Probe(addr, 2*time.Second); err != nil || !strings.HasPrefix(line, ProbeReply+" ") {
+		t.Errorf("Probe() = %q, %v", line, err)
+	}
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	io.WriteString(c, "ping")
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(c, got); err != nil || string(got) != "ping" {
+		t.Errorf("echo = %q, %v", got, err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	reject := newLimiter("a", &config.SessionsConfig{Max: 1})
+	if !reject.acquire() {
+		t.Fatal("first session refused")
+	}
+	if reject.acquire() {
+		t.Error("session over the limit admitted")
+	}
+	reject.release()
+	if !reject.acquire() {
+		t.Error("session refused after release")
+	}
+	if st := reject.stat(time.Now()); st.Total != 2 || st.Rejected != 1 || st.Active != 1 {
+		t.Errorf("stat = %+v", st)
+	}
+
+	queue := newLimiter("b", &config.SessionsConfig{Max: 1, OverLimit: config.SessionsQueue, QueueTimeout: "50ms"})
+	queue.acquire()
+	if queue.acquire() {
+		t.Error("queued session admitted without a free slot")
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		queue.release()
+	}()
+	queue.timeout = 2 * time.Second
+	if !queue.acquire() {
+		t.Error("queued session not admitted after release")
+	}
+	if st := queue.stat(time.Now()); st.Queued != 2 || st.TimedOut != 1 || st.Waiting != 0 {
+		t.Errorf("stat = %+v", st)
+	}
+}
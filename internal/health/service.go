@@ -0,0 +1,110 @@
+package health
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+// ServiceName is the service running the health endpoint and the fronts.
+var ServiceName = paths.SystemService("health")
+
+// Sync runs the health service while a tunnel routes the endpoint or has a
+// front, and restarts it so it picks up changed fronts. Without such tunnels
+// the service is removed.
+func Sync(cfg *config.Config) error {
+	for _, t := range cfg.Tunnels {
+		if t.Health != nil || t.FrontPort() != 0 {
+			return installService(cfg)
+		}
+	}
+	return RemoveService()
+}
+
+func installService(cfg *config.Config) error {
+	// The state directory must exist for ReadWritePaths
+	if err := os.MkdirAll(StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", StateDir, err)
+	}
+	if err := system.ChownToDnstm(StateDir); err != nil {
+		return fmt.Errorf("failed to set ownership of %s: %w", StateDir, err)
+	}
+	writable := []string{StateDir}
+	if path := sessionLogFile(cfg); path != "" {
+		if err := prepareSessionLogFile(path); err != nil {
+			return err
+		}
+		writable = append(writable, path)
+	}
+
+	if err := service.CreateGenericService(&service.ServiceConfig{
+		Name:             ServiceName,
+		Description:      "dnstm health endpoint",
+		User:             system.DnstmUser,
+		Group:            system.DnstmUser,
+		ExecStart:        cmdline.Join(paths.Bin("dnstm"), "health", "serve"),
+		ReadOnlyPaths:    []string{paths.ConfigDir},
+		ReadWritePaths:   writable,
+		BindToPrivileged: true,
+	}); err != nil {
+		return err
+	}
+	if service.IsServiceActive(ServiceName) {
+		return service.RestartService(ServiceName)
+	}
+	if err := service.EnableService(ServiceName); err != nil {
+		return err
+	}
+	return service.StartService(ServiceName)
+}
+
+// IsInstalled reports whether the health service unit exists.
+func IsInstalled() bool {
+	return service.IsServiceInstalled(ServiceName)
+}
+
+// IsActive reports whether the health service is running.
+func IsActive() bool {
+	return service.IsServiceActive(ServiceName)
+}
+
+// RemoveService stops the health service and deletes its unit.
+func RemoveService() error {
+	if !IsInstalled() {
+		return nil
+	}
+	service.StopService(ServiceName)
+	service.DisableService(ServiceName)
+	return service.RemoveService(ServiceName)
+}
+
+// sessionLogFile returns the file session events are appended to, or "".
+func sessionLogFile(cfg *config.Config) string {
+	if cfg.Log.Sessions == nil {
+		return ""
+	}
+	return cfg.Log.Sessions.FilePath()
+}
+
+// prepareSessionLogFile creates the session log file for the service user,
+// which may not create files next to it.
+func prepareSessionLogFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	f.Close()
+	if err := system.ChownToDnstm(path); err != nil {
+		return fmt.Errorf("failed to set ownership of %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// StateDir holds what the health service records.
+const StateDir = "/var/lib/dnstm/health"
+
+// SessionStatsFile holds the latest session counters of the fronts.
+var SessionStatsFile = filepath.Join(StateDir, "sessions.json")
+
+// statsInterval is how often the session counters are saved.
+const statsInterval = 10 * time.Second
+
+// SessionStat holds the session counters of a tunnel's front since the
+// health service started.
+type SessionStat struct {
+	Tag      string    `json:"tag"`
+	Max      int       `json:"max"`
+	Active   int64     `json:"active"`
+	Waiting  int64     `json:"waiting"`
+	Total    int64     `json:"total"`
+	Queued   int64     `json:"queued"`
+	Rejected int64     `json:"rejected"`
+	TimedOut int64     `json:"timed_out"`
+	Updated  time.Time `json:"updated"`
+}
+
+// limiter caps the concurrent sessions of a tunnel.
+type limiter struct {
+	tag     string
+	slots   chan struct{}
+	queue   bool
+	timeout time.Duration
+
+	active   atomic.Int64
+	waiting  atomic.Int64
+	total    atomic.Int64
+	queued   atomic.Int64
+	rejected atomic.Int64
+	timedOut atomic.Int64
+}
+
+func newLimiter(tag string, s *config.SessionsConfig) *limiter {
+	return &limiter{
+		tag:     tag,
+		slots:   make(chan struct{}, s.Max),
+		queue:   s.Queues(),
+		timeout: s.QueueTimeoutDuration(),
+	}
+}
+
+// acquire takes a session slot. Over the limit it fails at once, or when
+// queueing, after waiting the queue timeout for a slot.
+func (l *limiter) acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		l.admit()
+		return true
+	default:
+	}
+	if !l.queue {
+		l.rejected.Add(1)
+		return false
+	}
+
+	l.queued.Add(1)
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		l.admit()
+		return true
+	case <-timer.C:
+		l.timedOut.Add(1)
+		return false
+	}
+}
+
+func (l *limiter) admit() {
+	l.active.Add(1)
+	l.total.Add(1)
+}
+
+// release frees a slot taken by acquire.
+func (l *limiter) release() {
+	l.active.Add(-1)
+	<-l.slots
+}
+
+func (l *limiter) stat(now time.Time) SessionStat {
+	return SessionStat{
+		Tag:      l.tag,
+		Max:      cap(l.slots),
+		Active:   l.active.Load(),
+		Waiting:  l.waiting.Load(),
+		Total:    l.total.Load(),
+		Queued:   l.queued.Load(),
+		Rejected: l.rejected.Load(),
+		TimedOut: l.timedOut.Load(),
+		Updated:  now,
+	}
+}
+
+// writeSessionStats saves the counters of limiters every statsInterval.
+func writeSessionStats(limiters []*limiter) {
+	failed := false
+	for range time.Tick(statsInterval) {
+		now := time.Now()
+		stats := make([]SessionStat, len(limiters))
+		for i, l := range limiters {
+			stats[i] = l.stat(now)
+		}
+		if err := SaveSessionStats(stats); err != nil && !failed {
+			log.Printf("[warning] session stats not saved: %v", err)
+			failed = true
+		}
+	}
+}
+
+// SaveSessionStats writes stats to SessionStatsFile.
+func SaveSessionStats(stats []SessionStat) error {
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Tag < stats[j].Tag })
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := SessionStatsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, SessionStatsFile)
+}
+
+// LoadSessionStats reads the counters last written by the health service. A
+// missing file yields none.
+func LoadSessionStats() ([]SessionStat, error) {
+	data, err := os.ReadFile(SessionStatsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session stats: %w", err)
+	}
+	var stats []SessionStat
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SessionStatsFile, err)
+	}
+	return stats, nil
+}
+
+// SessionStatsFor returns the last saved counters of a tunnel, or nil.
+func SessionStatsFor(tag string) *SessionStat {
+	stats, err := LoadSessionStats()
+	if err != nil {
+		return nil
+	}
+	for i := range stats {
+		if stats[i].Tag == tag {
+			return &stats[i]
+		}
+	}
+	return nil
+}
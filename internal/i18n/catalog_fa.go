@@ -0,0 +1,151 @@
+package i18n
+
+// catalogFa holds the Persian translations, keyed by the English text.
+var catalogFa = map[string]string{
+	// Menu labels
+	"ACME Challenges":    "چالش‌های ACME",
+	"API Server":         "سرور API",
+	"Add":                "افزودن",
+	"Agents":             "ایجنت‌ها",
+	"Authentication":     "احراز هویت",
+	"Auto-Update":        "به‌روزرسانی خودکار",
+	"Available Types":    "انواع موجود",
+	"Backends":           "بک‌اندها",
+	"Benchmark":          "محک‌زنی",
+	"Bootstrap":          "راه‌اندازی اولیه",
+	"Checks":             "بررسی‌ها",
+	"Config":             "پیکربندی",
+	"Confirm":            "تأیید",
+	"Create":             "ایجاد",
+	"Create Token":       "ایجاد توکن",
+	"DNS Records":        "رکوردهای DNS",
+	"Decoy Zone":         "زون پوششی",
+	"Describe":           "توضیحات",
+	"Disable":            "غیرفعال‌سازی",
+	"Egress Rules":       "قوانین خروجی",
+	"Enable":             "فعال‌سازی",
+	"Enroll":             "ثبت‌نام",
+	"Expiry":             "انقضا",
+	"Export":             "خروجی گرفتن",
+	"Firewall":           "فایروال",
+	"Fleet Agents":       "ایجنت‌های ناوگان",
+	"Generate":           "تولید",
+	"Import":             "وارد کردن",
+	"Install":            "نصب",
+	"Jobs":               "کارها",
+	"Labels":             "برچسب‌ها",
+	"Leave":              "خروج",
+	"List":               "فهرست",
+	"Load":               "بارگذاری",
+	"Log Alerts":         "هشدارهای لاگ",
+	"Logs":               "لاگ‌ها",
+	"Mode":               "حالت",
+	"Outbound Interface": "رابط خروجی",
+	"Policy":             "سیاست",
+	"Ports":              "پورت‌ها",
+	"Quota":              "سهمیه",
+	"Reconfigure":        "پیکربندی مجدد",
+	"Records":            "رکوردها",
+	"Remove":             "حذف",
+	"Replicate":          "تکثیر",
+	"Reports":            "گزارش‌ها",
+	"Rescue":             "دسترسی اضطراری",
+	"Reset":              "بازنشانی",
+	"Reset Quota":        "بازنشانی سهمیه",
+	"Resolvers":          "ریزالورها",
+	"Restart":            "راه‌اندازی مجدد",
+	"Revoke":             "ابطال",
+	"Rollback":           "بازگردانی",
+	"Router":             "روتر",
+	"SSH Users":          "کاربران SSH",
+	"Schedule":           "زمان‌بندی",
+	"Security":           "امنیت",
+	"Share":              "اشتراک‌گذاری",
+	"Snapshots":          "اسنپ‌شات‌ها",
+	"Start":              "شروع",
+	"Start/Restart":      "شروع/راه‌اندازی مجدد",
+	"Status":             "وضعیت",
+	"Status Page":        "صفحه وضعیت",
+	"Stop":               "توقف",
+	"Switch Active":      "تغییر تونل فعال",
+	"Tunnels":            "تونل‌ها",
+	"Uninstall":          "حذف نصب",
+	"Unquarantine":       "خروج از قرنطینه",
+	"Update":             "به‌روزرسانی",
+	"Usage":              "مصرف",
+	"Validate":           "اعتبارسنجی",
+	"Watch":              "پایش",
+	"Watchdog":           "واچ‌داگ",
+	"Webhook":            "وب‌هوک",
+
+	// Menus and prompts
+	"Back":                                   "بازگشت",
+	"Change credentials":                     "تغییر اطلاعات ورود",
+	"Exit":                                   "خروج",
+	"External Tools":                         "ابزارهای خارجی",
+	"Install (Required)":                     "نصب (الزامی)",
+	"Quarantined":                            "قرنطینه",
+	"Running":                                "در حال اجرا",
+	"Select Backend":                         "انتخاب بک‌اند",
+	"Select Tunnel":                          "انتخاب تونل",
+	"Skip":                                   "رد شدن",
+	"Stopped":                                "متوقف",
+	"(Recommended)":                          "(پیشنهادی)",
+	"%s is required":                         "%s الزامی است",
+	"Select %s":                              "انتخاب %s",
+	"Mode: %s":                               "حالت: %s",
+	"Switch Active: (none)":                  "تغییر تونل فعال: (هیچ)",
+	"Switch Active: %s":                      "تغییر تونل فعال: %s",
+	"Authentication: Disabled":               "احراز هویت: غیرفعال",
+	"Authentication: %s":                     "احراز هویت: %s",
+	"Egress Rules: None":                     "قوانین خروجی: هیچ",
+	"Egress Rules: %d":                       "قوانین خروجی: %d",
+	"Outbound: Default":                      "خروجی: پیش‌فرض",
+	"Outbound: %s":                           "خروجی: %s",
+	"Watchdog: Off":                          "واچ‌داگ: خاموش",
+	"Watchdog: %s":                           "واچ‌داگ: %s",
+	"Tunnels: %d | Running: %d":              "تونل‌ها: %d | در حال اجرا: %d",
+	"Tunnels: %d | Running: %d | Active: %s": "تونل‌ها: %d | در حال اجرا: %d | فعال: %s",
+	"Updates available: %s":                  "به‌روزرسانی موجود است: %s",
+	"⚠ dnstm not installed\nMissing: %v":     "⚠ dnstm نصب نشده است\nموارد ناموجود: %v",
+	"Tunnel '%s' not found":                  "تونل '%s' یافت نشد",
+	"Backend '%s' not found":                 "بک‌اند '%s' یافت نشد",
+	"Failed to load config: %v":              "بارگذاری پیکربندی ناموفق بود: %v",
+	"No tunnels configured. Add one first.":  "هیچ تونلی پیکربندی نشده است. ابتدا یکی اضافه کنید.",
+	"No backends configured. Add one first.": "هیچ بک‌اندی پیکربندی نشده است. ابتدا یکی اضافه کنید.",
+	"Choose 1-%d (0 to go back): ":           "انتخاب کنید 1-%d (0 برای بازگشت): ",
+	"Enter a number between 1 and %d.":       "عددی بین 1 و %d وارد کنید.",
+	"Answer y or n.":                         "y یا n را وارد کنید.",
+	"Error: ":                                "خطا: ",
+	"Warning: ":                              "هشدار: ",
+	"Press Enter to continue...":             "برای ادامه Enter را بزنید...",
+
+	// Confirmations
+	"Are you sure you want to uninstall dnstm?": "آیا از حذف نصب dnstm مطمئن هستید؟",
+	"Remove backend?":           "بک‌اند حذف شود؟",
+	"Remove snapshot?":          "اسنپ‌شات حذف شود؟",
+	"Remove the rescue tunnel?": "تونل اضطراری حذف شود؟",
+	"Remove tunnel?":            "تونل حذف شود؟",
+	"Replace this server's configuration with the bundle?": "پیکربندی این سرور با بسته جایگزین شود؟",
+	"Restore snapshot?":                    "اسنپ‌شات بازیابی شود؟",
+	"Restore the previous firewall rules?": "قوانین قبلی فایروال بازیابی شود؟",
+
+	// Errors and hints
+	"router not initialized":                                "روتر راه‌اندازی نشده است",
+	"no backends configured":                                "هیچ بک‌اندی پیکربندی نشده است",
+	"this command is only available in single-tunnel mode":  "این فرمان فقط در حالت تک‌تونلی در دسترس است",
+	"tunnel '%s' not found":                                 "تونل '%s' یافت نشد",
+	"tunnel '%s' already exists":                            "تونل '%s' از قبل وجود دارد",
+	"backend '%s' not found":                                "بک‌اند '%s' یافت نشد",
+	"backend '%s' already exists":                           "بک‌اند '%s' از قبل وجود دارد",
+	"backend '%s' is in use by tunnels: %v":                 "بک‌اند '%s' توسط این تونل‌ها استفاده می‌شود: %v",
+	"transport binaries not installed. Missing: %v":         "باینری‌های ترنسپورت نصب نشده‌اند. موارد ناموجود: %v",
+	"Run 'dnstm install' first":                             "ابتدا 'dnstm install' را اجرا کنید",
+	"Use 'dnstm tunnel list' to see available tunnels":      "برای دیدن تونل‌های موجود از 'dnstm tunnel list' استفاده کنید",
+	"Use 'dnstm backend list' to see available backends":    "برای دیدن بک‌اندهای موجود از 'dnstm backend list' استفاده کنید",
+	"Choose a different tag or remove the existing tunnel":  "برچسب دیگری انتخاب کنید یا تونل موجود را حذف کنید",
+	"Choose a different tag or remove the existing backend": "برچسب دیگری انتخاب کنید یا بک‌اند موجود را حذف کنید",
+	"Remove the tunnels first":                              "ابتدا تونل‌ها را حذف کنید",
+	"Use 'dnstm router mode single' to switch modes first":  "ابتدا با 'dnstm router mode single' حالت را تغییر دهید",
+	"Use 'dnstm backend add' to create one":                 "برای ایجاد یکی از 'dnstm backend add' استفاده کنید",
+}
@@ -0,0 +1,151 @@
+package i18n
+
+// catalogRu holds the Russian translations, keyed by the English text.
+var catalogRu = map[string]string{
+	// Menu labels
+	"ACME Challenges":    "Проверки ACME",
+	"API Server":         "API-сервер",
+	"Add":                "Добавить",
+	"Agents":             "Агенты",
+	"Authentication":     "Аутентификация",
+	"Auto-Update":        "Автообновление",
+	"Available Types":    "Доступные типы",
+	"Backends":           "Бэкенды",
+	"Benchmark":          "Замер скорости",
+	"Bootstrap":          "Начальная настройка",
+	"Checks":             "Проверки",
+	"Config":             "Конфигурация",
+	"Confirm":            "Подтвердить",
+	"Create":             "Создать",
+	"Create Token":       "Создать токен",
+	"DNS Records":        "DNS-записи",
+	"Decoy Zone":         "Зона-приманка",
+	"Describe":           "Описание",
+	"Disable":            "Отключить",
+	"Egress Rules":       "Правила исходящего трафика",
+	"Enable":             "Включить",
+	"Enroll":             "Подключить",
+	"Expiry":             "Срок действия",
+	"Export":             "Экспорт",
+	"Firewall":           "Файрвол",
+	"Fleet Agents":       "Агенты парка",
+	"Generate":           "Сгенерировать",
+	"Import":             "Импорт",
+	"Install":            "Установить",
+	"Jobs":               "Задания",
+	"Labels":             "Метки",
+	"Leave":              "Отключиться",
+	"List":               "Список",
+	"Load":               "Загрузить",
+	"Log Alerts":         "Оповещения журнала",
+	"Logs":               "Журналы",
+	"Mode":               "Режим",
+	"Outbound Interface": "Исходящий интерфейс",
+	"Policy":             "Политика",
+	"Ports":              "Порты",
+	"Quota":              "Квота",
+	"Reconfigure":        "Перенастроить",
+	"Records":            "Записи",
+	"Remove":             "Удалить",
+	"Replicate":          "Репликация",
+	"Reports":            "Отчёты",
+	"Rescue":             "Аварийный доступ",
+	"Reset":              "Сброс",
+	"Reset Quota":        "Сбросить квоту",
+	"Resolvers":          "Резолверы",
+	"Restart":            "Перезапустить",
+	"Revoke":             "Отозвать",
+	"Rollback":           "Откатить",
+	"Router":             "Маршрутизатор",
+	"SSH Users":          "Пользователи SSH",
+	"Schedule":           "Расписание",
+	"Security":           "Безопасность",
+	"Share":              "Поделиться",
+	"Snapshots":          "Снимки",
+	"Start":              "Запустить",
+	"Start/Restart":      "Запуск/перезапуск",
+	"Status":             "Состояние",
+	"Status Page":        "Страница статуса",
+	"Stop":               "Остановить",
+	"Switch Active":      "Сменить активный",
+	"Tunnels":            "Туннели",
+	"Uninstall":          "Удалить dnstm",
+	"Unquarantine":       "Снять карантин",
+	"Update":             "Обновить",
+	"Usage":              "Использование",
+	"Validate":           "Проверить",
+	"Watch":              "Наблюдение",
+	"Watchdog":           "Watchdog",
+	"Webhook":            "Вебхук",
+
+	// Menus and prompts
+	"Back":                                   "Назад",
+	"Change credentials":                     "Изменить учётные данные",
+	"Exit":                                   "Выход",
+	"External Tools":                         "Внешние инструменты",
+	"Install (Required)":                     "Установить (обязательно)",
+	"Quarantined":                            "На карантине",
+	"Running":                                "Запущен",
+	"Select Backend":                         "Выберите бэкенд",
+	"Select Tunnel":                          "Выберите туннель",
+	"Skip":                                   "Пропустить",
+	"Stopped":                                "Остановлен",
+	"(Recommended)":                          "(рекомендуется)",
+	"%s is required":                         "Требуется %s",
+	"Select %s":                              "Выберите %s",
+	"Mode: %s":                               "Режим: %s",
+	"Switch Active: (none)":                  "Сменить активный: (нет)",
+	"Switch Active: %s":                      "Сменить активный: %s",
+	"Authentication: Disabled":               "Аутентификация: отключена",
+	"Authentication: %s":                     "Аутентификация: %s",
+	"Egress Rules: None":                     "Правила исходящего трафика: нет",
+	"Egress Rules: %d":                       "Правила исходящего трафика: %d",
+	"Outbound: Default":                      "Исходящий: по умолчанию",
+	"Outbound: %s":                           "Исходящий: %s",
+	"Watchdog: Off":                          "Watchdog: выкл.",
+	"Watchdog: %s":                           "Watchdog: %s",
+	"Tunnels: %d | Running: %d":              "Туннели: %d | Запущено: %d",
+	"Tunnels: %d | Running: %d | Active: %s": "Туннели: %d | Запущено: %d | Активный: %s",
+	"Updates available: %s":                  "Доступны обновления: %s",
+	"⚠ dnstm not installed\nMissing: %v":     "⚠ dnstm не установлен\nОтсутствуют: %v",
+	"Tunnel '%s' not found":                  "Туннель '%s' не найден",
+	"Backend '%s' not found":                 "Бэкенд '%s' не найден",
+	"Failed to load config: %v":              "Не удалось загрузить конфигурацию: %v",
+	"No tunnels configured. Add one first.":  "Туннели не настроены. Сначала добавьте туннель.",
+	"No backends configured. Add one first.": "Бэкенды не настроены. Сначала добавьте бэкенд.",
+	"Choose 1-%d (0 to go back): ":           "Выберите 1-%d (0 — назад): ",
+	"Enter a number between 1 and %d.":       "Введите число от 1 до %d.",
+	"Answer y or n.":                         "Ответьте y или n.",
+	"Error: ":                                "Ошибка: ",
+	"Warning: ":                              "Предупреждение: ",
+	"Press Enter to continue...":             "Нажмите Enter, чтобы продолжить...",
+
+	// Confirmations
+	"Are you sure you want to uninstall dnstm?": "Вы уверены, что хотите удалить dnstm?",
+	"Remove backend?":           "Удалить бэкенд?",
+	"Remove snapshot?":          "Удалить снимок?",
+	"Remove the rescue tunnel?": "Удалить аварийный туннель?",
+	"Remove tunnel?":            "Удалить туннель?",
+	"Replace this server's configuration with the bundle?": "Заменить конфигурацию этого сервера содержимым пакета?",
+	"Restore snapshot?":                    "Восстановить снимок?",
+	"Restore the previous firewall rules?": "Восстановить прежние правила файрвола?",
+
+	// Errors and hints
+	"router not initialized":                                "маршрутизатор не инициализирован",
+	"no backends configured":                                "бэкенды не настроены",
+	"this command is only available in single-tunnel mode":  "эта команда доступна только в режиме одного туннеля",
+	"tunnel '%s' not found":                                 "туннель '%s' не найден",
+	"tunnel '%s' already exists":                            "туннель '%s' уже существует",
+	"backend '%s' not found":                                "бэкенд '%s' не найден",
+	"backend '%s' already exists":                           "бэкенд '%s' уже существует",
+	"backend '%s' is in use by tunnels: %v":                 "бэкенд '%s' используется туннелями: %v",
+	"transport binaries not installed. Missing: %v":         "бинарные файлы транспортов не установлены. Отсутствуют: %v",
+	"Run 'dnstm install' first":                             "Сначала выполните 'dnstm install'",
+	"Use 'dnstm tunnel list' to see available tunnels":      "Список туннелей: 'dnstm tunnel list'",
+	"Use 'dnstm backend list' to see available backends":    "Список бэкендов: 'dnstm backend list'",
+	"Choose a different tag or remove the existing tunnel":  "Выберите другой тег или удалите существующий туннель",
+	"Choose a different tag or remove the existing backend": "Выберите другой тег или удалите существующий бэкенд",
+	"Remove the tunnels first":                              "Сначала удалите туннели",
+	"Use 'dnstm router mode single' to switch modes first":  "Сначала переключите режим: 'dnstm router mode single'",
+	"Use 'dnstm backend add' to create one":                 "Создайте бэкенд: 'dnstm backend add'",
+}
@@ -0,0 +1,151 @@
+package i18n
+
+// catalogZh holds the Chinese translations, keyed by the English text.
+var catalogZh = map[string]string{
+	// Menu labels
+	"ACME Challenges":    "ACME 验证",
+	"API Server":         "API 服务器",
+	"Add":                "添加",
+	"Agents":             "代理",
+	"Authentication":     "认证",
+	"Auto-Update":        "自动更新",
+	"Available Types":    "可用类型",
+	"Backends":           "后端",
+	"Benchmark":          "性能测试",
+	"Bootstrap":          "初始化引导",
+	"Checks":             "检查",
+	"Config":             "配置",
+	"Confirm":            "确认",
+	"Create":             "创建",
+	"Create Token":       "创建令牌",
+	"DNS Records":        "DNS 记录",
+	"Decoy Zone":         "伪装区域",
+	"Describe":           "描述",
+	"Disable":            "禁用",
+	"Egress Rules":       "出站规则",
+	"Enable":             "启用",
+	"Enroll":             "注册",
+	"Expiry":             "到期",
+	"Export":             "导出",
+	"Firewall":           "防火墙",
+	"Fleet Agents":       "集群代理",
+	"Generate":           "生成",
+	"Import":             "导入",
+	"Install":            "安装",
+	"Jobs":               "任务",
+	"Labels":             "标签",
+	"Leave":              "退出",
+	"List":               "列表",
+	"Load":               "加载",
+	"Log Alerts":         "日志告警",
+	"Logs":               "日志",
+	"Mode":               "模式",
+	"Outbound Interface": "出站接口",
+	"Policy":             "策略",
+	"Ports":              "端口",
+	"Quota":              "配额",
+	"Reconfigure":        "重新配置",
+	"Records":            "记录",
+	"Remove":             "删除",
+	"Replicate":          "复制部署",
+	"Reports":            "报告",
+	"Rescue":             "应急访问",
+	"Reset":              "重置",
+	"Reset Quota":        "重置配额",
+	"Resolvers":          "解析器",
+	"Restart":            "重启",
+	"Revoke":             "吊销",
+	"Rollback":           "回滚",
+	"Router":             "路由器",
+	"SSH Users":          "SSH 用户",
+	"Schedule":           "计划",
+	"Security":           "安全",
+	"Share":              "分享",
+	"Snapshots":          "快照",
+	"Start":              "启动",
+	"Start/Restart":      "启动/重启",
+	"Status":             "状态",
+	"Status Page":        "状态页",
+	"Stop":               "停止",
+	"Switch Active":      "切换活动隧道",
+	"Tunnels":            "隧道",
+	"Uninstall":          "卸载",
+	"Unquarantine":       "解除隔离",
+	"Update":             "更新",
+	"Usage":              "用量",
+	"Validate":           "校验",
+	"Watch":              "监视",
+	"Watchdog":           "看门狗",
+	"Webhook":            "Webhook",
+
+	// Menus and prompts
+	"Back":                                   "返回",
+	"Change credentials":                     "更改凭据",
+	"Exit":                                   "退出",
+	"External Tools":                         "外部工具",
+	"Install (Required)":                     "安装（必需）",
+	"Quarantined":                            "已隔离",
+	"Running":                                "运行中",
+	"Select Backend":                         "选择后端",
+	"Select Tunnel":                          "选择隧道",
+	"Skip":                                   "跳过",
+	"Stopped":                                "已停止",
+	"(Recommended)":                          "（推荐）",
+	"%s is required":                         "%s 为必填项",
+	"Select %s":                              "选择 %s",
+	"Mode: %s":                               "模式：%s",
+	"Switch Active: (none)":                  "切换活动隧道：（无）",
+	"Switch Active: %s":                      "切换活动隧道：%s",
+	"Authentication: Disabled":               "认证：已禁用",
+	"Authentication: %s":                     "认证：%s",
+	"Egress Rules: None":                     "出站规则：无",
+	"Egress Rules: %d":                       "出站规则：%d",
+	"Outbound: Default":                      "出站：默认",
+	"Outbound: %s":                           "出站：%s",
+	"Watchdog: Off":                          "看门狗：关闭",
+	"Watchdog: %s":                           "看门狗：%s",
+	"Tunnels: %d | Running: %d":              "隧道：%d | 运行中：%d",
+	"Tunnels: %d | Running: %d | Active: %s": "隧道：%d | 运行中：%d | 活动：%s",
+	"Updates available: %s":                  "有可用更新：%s",
+	"⚠ dnstm not installed\nMissing: %v":     "⚠ dnstm 未安装\n缺少：%v",
+	"Tunnel '%s' not found":                  "未找到隧道 '%s'",
+	"Backend '%s' not found":                 "未找到后端 '%s'",
+	"Failed to load config: %v":              "加载配置失败：%v",
+	"No tunnels configured. Add one first.":  "尚未配置隧道。请先添加一个。",
+	"No backends configured. Add one first.": "尚未配置后端。请先添加一个。",
+	"Choose 1-%d (0 to go back): ":           "请选择 1-%d（0 返回）：",
+	"Enter a number between 1 and %d.":       "请输入 1 到 %d 之间的数字。",
+	"Answer y or n.":                         "请输入 y 或 n。",
+	"Error: ":                                "错误：",
+	"Warning: ":                              "警告：",
+	"Press Enter to continue...":             "按 Enter 继续...",
+
+	// Confirmations
+	"Are you sure you want to uninstall dnstm?": "确定要卸载 dnstm 吗？",
+	"Remove backend?":           "删除后端？",
+	"Remove snapshot?":          "删除快照？",
+	"Remove the rescue tunnel?": "删除应急隧道？",
+	"Remove tunnel?":            "删除隧道？",
+	"Replace this server's configuration with the bundle?": "用该包替换此服务器的配置？",
+	"Restore snapshot?":                    "恢复快照？",
+	"Restore the previous firewall rules?": "恢复之前的防火墙规则？",
+
+	// Errors and hints
+	"router not initialized":                                "路由器未初始化",
+	"no backends configured":                                "未配置后端",
+	"this command is only available in single-tunnel mode":  "此命令仅在单隧道模式下可用",
+	"tunnel '%s' not found":                                 "未找到隧道 '%s'",
+	"tunnel '%s' already exists":                            "隧道 '%s' 已存在",
+	"backend '%s' not found":                                "未找到后端 '%s'",
+	"backend '%s' already exists":                           "后端 '%s' 已存在",
+	"backend '%s' is in use by tunnels: %v":                 "后端 '%s' 正被以下隧道使用：%v",
+	"transport binaries not installed. Missing: %v":         "传输程序未安装。缺少：%v",
+	"Run 'dnstm install' first":                             "请先运行 'dnstm install'",
+	"Use 'dnstm tunnel list' to see available tunnels":      "使用 'dnstm tunnel list' 查看可用隧道",
+	"Use 'dnstm backend list' to see available backends":    "使用 'dnstm backend list' 查看可用后端",
+	"Choose a different tag or remove the existing tunnel":  "请选择其他标签或删除现有隧道",
+	"Choose a different tag or remove the existing backend": "请选择其他标签或删除现有后端",
+	"Remove the tunnels first":                              "请先删除这些隧道",
+	"Use 'dnstm router mode single' to switch modes first":  "请先使用 'dnstm router mode single' 切换模式",
+	"Use 'dnstm backend add' to create one":                 "使用 'dnstm backend add' 创建一个",
+}
@@ -0,0 +1,112 @@
+// Package i18n translates user-facing menu labels, prompts and error messages.
+//
+// Messages are looked up by their English text, so untranslated strings fall
+// back to English unchanged.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Supported languages.
+const (
+	English = "en"
+	Persian = "fa"
+	Russian = "ru"
+	Chinese = "zh"
+)
+
+// LangEnv overrides the language detected from the locale.
+const LangEnv = "DNSTM_LANG"
+
+// Languages lists the supported language codes.
+var Languages = []string{English, Persian, Russian, Chinese}
+
+var catalogs = map[string]map[string]string{
+	Persian: catalogFa,
+	Russian: catalogRu,
+	Chinese: catalogZh,
+}
+
+var current = English
+
+// SetLanguage selects the language used by T and Tf.
+func SetLanguage(lang string) error {
+	lang = Normalize(lang)
+	for _, l := range Languages {
+		if l == lang {
+			current = lang
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported language '%s' (expected %s)", lang, strings.Join(Languages, ", "))
+}
+
+// Language returns the current language code.
+func Language() string {
+	return current
+}
+
+// Normalize reduces a locale such as "fa_IR.UTF-8" to its language code.
+func Normalize(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "_-.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	switch locale {
+	case "", "c", "posix":
+		return English
+	}
+	return locale
+}
+
+// Detect returns the language from $DNSTM_LANG or the locale environment,
+// falling back to English for unsupported languages.
+func Detect() string {
+	for _, env := range []string{LangEnv, "LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		lang := Normalize(v)
+		if _, ok := catalogs[lang]; ok {
+			return lang
+		}
+		return English
+	}
+	return English
+}
+
+// FromArgs returns the value of a --lang flag in args, or "" if absent.
+// Arguments after "--" are not inspected.
+func FromArgs(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return ""
+		case arg == "--lang" && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "--lang="):
+			return strings.TrimPrefix(arg, "--lang=")
+		}
+	}
+	return ""
+}
+
+// T returns the translation of s in the current language.
+func T(s string) string {
+	if current == English || s == "" {
+		return s
+	}
+	if t, ok := catalogs[current][s]; ok {
+		return t
+	}
+	return s
+}
+
+// Tf translates format and then formats it with args.
+func Tf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
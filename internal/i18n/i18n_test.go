@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var verbRe = regexp.MustCompile(`%[a-z]`)
+
+func verbs(s string) string {
+	v := verbRe.FindAllString(s, -1)
+	sort.Strings(v)
+	return strings.Join(v, " ")
+}
+
+func TestCatalogs_FormatVerbs(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for key, msg := range catalog {
+			if verbs(key) != verbs(msg) {
+				t.Errorf("%s: %q has verbs [%s], translation %q has [%s]", lang, key, verbs(key), msg, verbs(msg))
+			}
+		}
+	}
+}
+
+func TestCatalogs_SameKeys(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for key := range catalogFa {
+			if _, ok := catalog[key]; !ok {
+				t.Errorf("%s: missing translation for %q", lang, key)
+			}
+		}
+		if len(catalog) != len(catalogFa) {
+			t.Errorf("%s: %d entries, fa has %d", lang, len(catalog), len(catalogFa))
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	defer SetLanguage(English)
+
+	if got := T("Tunnels"); got != "Tunnels" {
+		t.Errorf("T() in English = %q", got)
+	}
+	if err := SetLanguage("ru_RU.UTF-8"); err != nil {
+		t.Fatal(err)
+	}
+	if got := T("Tunnels"); got != "Туннели" {
+		t.Errorf("T(Tunnels) = %q", got)
+	}
+	if got := T("not in the catalog"); got != "not in the catalog" {
+		t.Errorf("untranslated string = %q, want English", got)
+	}
+	if got := Tf("Select %s", "tag"); got != "Выберите tag" {
+		t.Errorf("Tf() = %q", got)
+	}
+	if err := SetLanguage("de"); err == nil {
+		t.Error("SetLanguage() accepted an unsupported language")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct{ lang, lcAll, want string }{
+		{"fa_IR.UTF-8", "", Persian},
+		{"fa_IR.UTF-8", "zh_CN.UTF-8", Chinese},
+		{"de_DE.UTF-8", "", English},
+		{"C", "", English},
+		{"", "", English},
+	} {
+		t.Setenv(LangEnv, "")
+		t.Setenv("LC_MESSAGES", "")
+		t.Setenv("LC_ALL", tc.lcAll)
+		t.Setenv("LANG", tc.lang)
+		if got := Detect(); got != tc.want {
+			t.Errorf("Detect() with LANG=%q LC_ALL=%q = %q, want %q", tc.lang, tc.lcAll, got, tc.want)
+		}
+	}
+}
+
+func TestFromArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"tunnel", "list", "--lang", "fa"}, "fa"},
+		{[]string{"--lang=zh", "tunnel", "list"}, "zh"},
+		{[]string{"tunnel", "list"}, ""},
+		{[]string{"ssh-users", "--", "--lang", "ru"}, ""},
+	} {
+		if got := FromArgs(tc.args); got != tc.want {
+			t.Errorf("FromArgs(%v) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
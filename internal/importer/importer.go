@@ -0,0 +1,418 @@
+// Package importer reads DNS tunnel servers set up by hand or by other
+// tools, so dnstm can take them over with their keys and certificates.
+//
+// A server is read from the systemd unit that runs dnstt-server,
+// slipstream-server or ssserver, or from an ssserver JSON config that uses
+// slipstream-server as its plugin (Shadowsocks over Slipstream).
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// Kind is the kind of server a config belongs to.
+type Kind string
+
+const (
+	KindDNSTT      Kind = "dnstt"
+	KindSlipstream Kind = "slipstream"
+	KindSSServer   Kind = "ssserver"
+)
+
+// Kinds lists the kinds that can be imported.
+var Kinds = []Kind{KindDNSTT, KindSlipstream, KindSSServer}
+
+// UnitDirs are searched for a unit given by name.
+var UnitDirs = []string{"/etc/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"}
+
+// binaries maps server binaries to the kind they run.
+var binaries = map[string]Kind{
+	"dnstt-server":      KindDNSTT,
+	"slipstream-server": KindSlipstream,
+	"ssserver":          KindSSServer,
+}
+
+// Server is a tunnel server found in a foreign config.
+type Server struct {
+	Kind   Kind
+	Source string // file the server was read from
+	Unit   string // systemd unit running the server, if known
+	Domain string
+	Listen string // address the server listened on, e.g. ":5300"
+	Target string // upstream address; empty for Shadowsocks
+	MTU    int
+
+	PrivateKey  string                    // dnstt: hex private key
+	Cert        string                    // slipstream: certificate path
+	Key         string                    // slipstream: key path
+	Shadowsocks *config.ShadowsocksConfig // ssserver
+}
+
+// Transport returns the dnstm transport serving the same clients.
+func (s *Server) Transport() config.TransportType {
+	if s.Kind == KindDNSTT {
+		return config.TransportDNSTT
+	}
+	return config.TransportSlipstream
+}
+
+// ParseKind parses a --from value. An empty value detects the kind from
+// the config.
+func ParseKind(s string) (Kind, error) {
+	if s == "" {
+		return "", nil
+	}
+	for _, k := range Kinds {
+		if string(k) == s {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown source '%s' (must be dnstt, slipstream or ssserver)", s)
+}
+
+// Read reads the server configured at path: a systemd unit file or name,
+// or an ssserver JSON config. kind may be empty to detect it.
+func Read(kind Kind, path string) (*Server, error) {
+	file, err := resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		if kind != "" && kind != KindSSServer && kind != KindSlipstream {
+			return nil, fmt.Errorf("%s is a JSON config; only ssserver configs are JSON", file)
+		}
+		return readSSServer(file, data)
+	}
+
+	args, err := execStart(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	args = unwrapWatchdog(args)
+	found, ok := binaries[filepath.Base(args[0])]
+	if !ok {
+		return nil, fmt.Errorf("%s runs %s, not dnstt-server, slipstream-server or ssserver", file, args[0])
+	}
+	// ssserver with the slipstream plugin is also a slipstream server
+	if kind != "" && kind != found && !(kind == KindSlipstream && found == KindSSServer) {
+		return nil, fmt.Errorf("%s runs %s, not a %s server", file, filepath.Base(args[0]), kind)
+	}
+
+	var srv *Server
+	switch found {
+	case KindDNSTT:
+		srv, err = parseDNSTT(args[1:])
+	case KindSlipstream:
+		srv, err = parseSlipstream(args[1:])
+	case KindSSServer:
+		flags, _ := parseFlags(args[1:])
+		conf := flags["c"]
+		if conf == "" {
+			conf = flags["config"]
+		}
+		if conf == "" {
+			return nil, fmt.Errorf("%s: ssserver is not run with -c <config.json>", file)
+		}
+		if srv, err = Read(KindSSServer, conf); err == nil {
+			srv.Unit = filepath.Base(file)
+		}
+		return srv, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	srv.Source = file
+	srv.Unit = filepath.Base(file)
+	return srv, nil
+}
+
+// resolve returns the file path names: an existing file, or a unit name
+// looked up in UnitDirs.
+func resolve(path string) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if !strings.Contains(path, "/") {
+		name := path
+		if filepath.Ext(name) != ".service" {
+			name += ".service"
+		}
+		for _, dir := range UnitDirs {
+			file := filepath.Join(dir, name)
+			if _, err := os.Stat(file); err == nil {
+				return file, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%s: no such file or systemd unit", path)
+}
+
+// execStart returns the command line of the ExecStart= of a unit, split
+// into arguments.
+func execStart(unit string) ([]string, error) {
+	var line string
+	scanner := bufio.NewScanner(strings.NewReader(unit))
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		for strings.HasSuffix(text, "\\") && scanner.Scan() {
+			text = strings.TrimSuffix(text, "\\") + " " + strings.TrimSpace(scanner.Text())
+		}
+		if v, ok := strings.CutPrefix(text, "ExecStart="); ok {
+			line = v
+		}
+	}
+	// Prefixes such as "-" (ignore failure) or "+" (full privileges)
+	line = strings.TrimLeft(strings.TrimSpace(line), "-@:+!")
+	if line == "" {
+		return nil, fmt.Errorf("no ExecStart= found")
+	}
+	return splitArgs(line)
+}
+
+// unwrapWatchdog returns the server command of a dnstm tunnel unit run
+// under "dnstm watchdog ... -- <server>", or args unchanged.
+func unwrapWatchdog(args []string) []string {
+	if len(args) < 2 || filepath.Base(args[0]) != "dnstm" || args[1] != "watchdog" {
+		return args
+	}
+	for i, arg := range args {
+		if arg == "--" && i+1 < len(args) {
+			return args[i+1:]
+		}
+	}
+	return args
+}
+
+// splitArgs splits a command line into arguments, honoring single and
+// double quotes and backslash escapes.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// parseFlags splits arguments into flags and positional arguments. Flags
+// take the form -name value, --name value or --name=value; a flag followed
+// by another flag has an empty value.
+func parseFlags(args []string) (map[string]string, []string) {
+	flags := make(map[string]string)
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if k, v, ok := strings.Cut(name, "="); ok {
+			flags[k] = v
+			continue
+		}
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			flags[name] = args[i+1]
+			i++
+			continue
+		}
+		flags[name] = ""
+	}
+	return flags, positional
+}
+
+// parseDNSTT reads the arguments of dnstt-server:
+// [-udp ADDR] [-privkey HEX | -privkey-file FILE] [-mtu N] DOMAIN UPSTREAM
+func parseDNSTT(args []string) (*Server, error) {
+	flags, positional := parseFlags(args)
+	if len(positional) != 2 {
+		return nil, fmt.Errorf("dnstt-server needs a domain and an upstream address, got %q", positional)
+	}
+	srv := &Server{
+		Kind:   KindDNSTT,
+		Domain: positional[0],
+		Target: positional[1],
+		Listen: flags["udp"],
+	}
+	if mtu := flags["mtu"]; mtu != "" {
+		n, err := strconv.Atoi(mtu)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -mtu %q", mtu)
+		}
+		srv.MTU = n
+	}
+
+	switch {
+	case flags["privkey"] != "":
+		srv.PrivateKey = flags["privkey"]
+	case flags["privkey-file"] != "":
+		data, err := os.ReadFile(flags["privkey-file"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		srv.PrivateKey = strings.TrimSpace(string(data))
+	default:
+		return nil, fmt.Errorf("dnstt-server has no -privkey or -privkey-file")
+	}
+	return srv, nil
+}
+
+// parseSlipstream reads the arguments of slipstream-server.
+func parseSlipstream(args []string) (*Server, error) {
+	flags, _ := parseFlags(args)
+	srv := &Server{
+		Kind:   KindSlipstream,
+		Domain: flags["domain"],
+		Target: flags["target-address"],
+		Cert:   flags["cert"],
+		Key:    flags["key"],
+	}
+	if port := flags["dns-listen-port"]; port != "" {
+		srv.Listen = flags["dns-listen-host"] + ":" + port
+	}
+	if err := srv.checkSlipstream(); err != nil {
+		return nil, err
+	}
+	if srv.Target == "" {
+		return nil, fmt.Errorf("slipstream-server has no --target-address")
+	}
+	return srv, nil
+}
+
+// checkSlipstream checks the fields every slipstream server needs.
+func (s *Server) checkSlipstream() error {
+	if s.Domain == "" {
+		return fmt.Errorf("slipstream-server has no domain")
+	}
+	if s.Cert == "" || s.Key == "" {
+		return fmt.Errorf("slipstream-server needs both a certificate and a key")
+	}
+	return nil
+}
+
+// ssserverConfig is the part of an ssserver JSON config that is imported.
+type ssserverConfig struct {
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+	Plugin     string `json:"plugin"`
+	PluginOpts string `json:"plugin_opts"`
+}
+
+// readSSServer reads an ssserver JSON config using the slipstream plugin.
+func readSSServer(file string, data []byte) (*Server, error) {
+	var c ssserverConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if c.Password == "" {
+		return nil, fmt.Errorf("%s: no password", file)
+	}
+	if filepath.Base(c.Plugin) != "slipstream-server" {
+		return nil, fmt.Errorf("%s: ssserver does not use the slipstream-server plugin; only Shadowsocks over Slipstream can be imported", file)
+	}
+
+	opts := make(map[string]string)
+	for _, opt := range strings.Split(c.PluginOpts, ";") {
+		if k, v, ok := strings.Cut(opt, "="); ok {
+			opts[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	srv := &Server{
+		Kind:   KindSSServer,
+		Source: file,
+		Domain: opts["domain"],
+		Cert:   opts["cert"],
+		Key:    opts["key"],
+		Shadowsocks: &config.ShadowsocksConfig{
+			Method:   c.Method,
+			Password: c.Password,
+		},
+	}
+	if port := opts["dns-listen-port"]; port != "" {
+		srv.Listen = opts["dns-listen-host"] + ":" + port
+	} else if c.ServerPort > 0 {
+		srv.Listen = fmt.Sprintf("%s:%d", c.Server, c.ServerPort)
+	}
+	if err := srv.checkSlipstream(); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return srv, nil
+}
+
+// MatchBackend returns the backend forwarding to target, or nil.
+func MatchBackend(backends []config.BackendConfig, target string) *config.BackendConfig {
+	want := normalizeAddr(target)
+	for i := range backends {
+		b := &backends[i]
+		if b.Type == config.BackendShadowsocks {
+			continue
+		}
+		if addr := b.TargetAddress(); addr != "" && normalizeAddr(addr) == want {
+			return b
+		}
+	}
+	return nil
+}
+
+// normalizeAddr spells loopback addresses the same way.
+func normalizeAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	switch host {
+	case "", "localhost", "::1":
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
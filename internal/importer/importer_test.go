@@ -0,0 +1,185 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+const testKey = "2f1a6b0c9e8d7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f40"
+
+// writeFile writes content to name in dir and returns its path.
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRead_DNSTTUnit(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writeFile(t, dir, "server.key", testKey+"\n")
+	unit := writeFile(t, dir, "dnstt.service", `[Unit]
+Description=dnstt
+
+[Service]
+ExecStart=/usr/local/bin/dnstt-server -udp :5300 \
+    -privkey-file `+keyFile+` -mtu 1200 \
+    t.example.com 127.0.0.1:22
+Restart=always
+`)
+
+	srv, err := Read("", unit)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := &Server{
+		Kind:       KindDNSTT,
+		Source:     unit,
+		Unit:       "dnstt.service",
+		Domain:     "t.example.com",
+		Listen:     ":5300",
+		Target:     "127.0.0.1:22",
+		MTU:        1200,
+		PrivateKey: testKey,
+	}
+	if !reflect.DeepEqual(srv, want) {
+		t.Errorf("Read() = %+v\nwant %+v", srv, want)
+	}
+	if srv.Transport() != config.TransportDNSTT {
+		t.Errorf("Transport() = %s", srv.Transport())
+	}
+
+	if _, err := Read(KindSlipstream, unit); err == nil {
+		t.Error("Read() accepted a dnstt unit as slipstream")
+	}
+}
+
+func TestRead_Watchdog(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writeFile(t, dir, "server.key", testKey+"\n")
+	unit := writeFile(t, dir, "dnstm-tun.service", "[Service]\nExecStart=/usr/local/bin/dnstm watchdog --addr 127.0.0.1:5310 --domain t.example.com --timeout 30s -- "+
+		"/usr/local/bin/dnstt-server -udp 127.0.0.1:5310 -privkey-file "+keyFile+" -mtu 1232 t.example.com 127.0.0.1:22\n")
+
+	srv, err := Read("", unit)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if srv.Kind != KindDNSTT || srv.Listen != "127.0.0.1:5310" || srv.Domain != "t.example.com" || srv.PrivateKey != testKey {
+		t.Errorf("Read() = %+v", srv)
+	}
+}
+
+func TestRead_UnitByName(t *testing.T) {
+	dir := t.TempDir()
+	old := UnitDirs
+	UnitDirs = []string{dir}
+	defer func() { UnitDirs = old }()
+
+	writeFile(t, dir, "slip.service", `[Service]
+ExecStart=-/opt/slipstream-server --dns-listen-port=5300 --domain s.example.com --target-address "127.0.0.1:1080" --cert /etc/slip/cert.pem --key /etc/slip/key.pem
+`)
+
+	srv, err := Read(KindSlipstream, "slip")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if srv.Kind != KindSlipstream || srv.Domain != "s.example.com" || srv.Target != "127.0.0.1:1080" ||
+		srv.Cert != "/etc/slip/cert.pem" || srv.Key != "/etc/slip/key.pem" || srv.Listen != ":5300" || srv.Unit != "slip.service" {
+		t.Errorf("Read() = %+v", srv)
+	}
+
+	if _, err := Read("", "missing"); err == nil {
+		t.Error("Read() of a missing unit succeeded")
+	}
+}
+
+func TestRead_SSServer(t *testing.T) {
+	dir := t.TempDir()
+	conf := writeFile(t, dir, "config.json", `{
+    "server": "0.0.0.0",
+    "server_port": 5300,
+    "password": "secret",
+    "method": "chacha20-ietf-poly1305",
+    "plugin": "/usr/local/bin/slipstream-server",
+    "plugin_opts": "domain=s.example.com;cert=/etc/ss/cert.pem;key=/etc/ss/key.pem"
+}`)
+	unit := writeFile(t, dir, "ss.service", "[Service]\nExecStart=/usr/bin/ssserver -c "+conf+"\n")
+
+	srv, err := Read("", unit)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if srv.Kind != KindSSServer || srv.Source != conf || srv.Unit != "ss.service" || srv.Domain != "s.example.com" ||
+		srv.Listen != "0.0.0.0:5300" || srv.Transport() != config.TransportSlipstream {
+		t.Errorf("Read() = %+v", srv)
+	}
+	if srv.Shadowsocks == nil || srv.Shadowsocks.Password != "secret" || srv.Shadowsocks.Method != "chacha20-ietf-poly1305" {
+		t.Errorf("Shadowsocks = %+v", srv.Shadowsocks)
+	}
+
+	plain := writeFile(t, dir, "plain.json", `{"server": "0.0.0.0", "server_port": 8388, "password": "x", "method": "aes-256-gcm"}`)
+	if _, err := Read("", plain); err == nil || !strings.Contains(err.Error(), "plugin") {
+		t.Errorf("Read() of a config without plugin: error = %v", err)
+	}
+	if _, err := Read(KindDNSTT, conf); err == nil {
+		t.Error("Read() accepted a JSON config as dnstt")
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	dir := t.TempDir()
+	for name, unit := range map[string]string{
+		"other":   "[Service]\nExecStart=/usr/bin/nginx\n",
+		"noexec":  "[Service]\nType=simple\n",
+		"nokey":   "[Service]\nExecStart=dnstt-server -udp :5300 t.example.com 127.0.0.1:22\n",
+		"noargs":  "[Service]\nExecStart=dnstt-server -privkey " + testKey + "\n",
+		"quote":   "[Service]\nExecStart=slipstream-server --domain 's.example.com\n",
+		"nocert":  "[Service]\nExecStart=slipstream-server --domain s.example.com --target-address 127.0.0.1:22\n",
+		"ssnoarg": "[Service]\nExecStart=ssserver\n",
+	} {
+		if _, err := Read("", writeFile(t, dir, name+".service", unit)); err == nil {
+			t.Errorf("Read(%s) succeeded", name)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got, err := splitArgs(`a "b c" 'd "e"' f\ g`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b c", `d "e"`, "f g"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestMatchBackend(t *testing.T) {
+	backends := []config.BackendConfig{
+		{Tag: "socks", Type: config.BackendSOCKS},
+		{Tag: "ssh", Type: config.BackendSSH},
+		{Tag: "ss", Type: config.BackendShadowsocks},
+		{Tag: "web", Type: config.BackendCustom, Address: "10.0.0.5:8080"},
+	}
+	for target, want := range map[string]string{
+		"127.0.0.1:22":   "ssh",
+		"localhost:1080": "socks",
+		"10.0.0.5:8080":  "web",
+		"10.0.0.5:8081":  "",
+	} {
+		got := ""
+		if b := MatchBackend(backends, target); b != nil {
+			got = b.Tag
+		}
+		if got != want {
+			t.Errorf("MatchBackend(%s) = %q, want %q", target, got, want)
+		}
+	}
+}
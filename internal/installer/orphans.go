@@ -0,0 +1,103 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// Orphan is what a tunnel left behind under a tag no tunnel in the config
+// has: its service, schedule timer or directory. Orphans come from crashes
+// while adding or removing a tunnel, and from hand-edited configs.
+type Orphan struct {
+	Tag     string
+	Service string // tunnel service, if installed
+	Timer   string // schedule timer, if installed
+	Dir     string // tunnel directory, if present
+}
+
+// schedulePrefix starts the name of a tunnel's schedule timer after the
+// service prefix.
+const schedulePrefix = "schedule-"
+
+// FindOrphans returns the orphans of cfg, sorted by tag.
+func FindOrphans(cfg *config.Config) []Orphan {
+	return findOrphans(cfg, service.ListServices(paths.ServicePrefix), service.IsTimerInstalled, config.TunnelsDir)
+}
+
+// findOrphans matches the installed services and the tunnel directories
+// in tunnelsDir against the tunnels of cfg.
+func findOrphans(cfg *config.Config, services []string, isTimer func(string) bool, tunnelsDir string) []Orphan {
+	byTag := make(map[string]*Orphan)
+	orphan := func(tag string) *Orphan {
+		if o, ok := byTag[tag]; ok {
+			return o
+		}
+		o := &Orphan{Tag: tag}
+		byTag[tag] = o
+		return o
+	}
+
+	for _, name := range services {
+		if paths.IsSystemService(name) {
+			continue
+		}
+		tag := strings.TrimPrefix(name, paths.ServicePrefix)
+		if t, ok := strings.CutPrefix(tag, schedulePrefix); ok && isTimer(name) {
+			if cfg.GetTunnelByTag(t) == nil {
+				orphan(t).Timer = name
+			}
+			continue
+		}
+		if cfg.GetTunnelByTag(tag) == nil {
+			orphan(tag).Service = name
+		}
+	}
+
+	entries, _ := os.ReadDir(tunnelsDir)
+	for _, entry := range entries {
+		if entry.IsDir() && cfg.GetTunnelByTag(entry.Name()) == nil {
+			orphan(entry.Name()).Dir = filepath.Join(tunnelsDir, entry.Name())
+		}
+	}
+
+	orphans := make([]Orphan, 0, len(byTag))
+	for _, o := range byTag {
+		orphans = append(orphans, *o)
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Tag < orphans[j].Tag })
+	return orphans
+}
+
+// RemoveOrphan stops and deletes everything an orphan left behind.
+func RemoveOrphan(o *Orphan) error {
+	var errs []string
+	if o.Service != "" {
+		// Also clears the quarantine and log scan state of the tag
+		tunnel := router.NewTunnel(&config.TunnelConfig{Tag: o.Tag})
+		if err := tunnel.RemoveService(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", o.Service, err))
+		}
+	}
+	if o.Timer != "" && service.IsTimerInstalled(o.Timer) {
+		if err := service.RemoveTimer(o.Timer); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", o.Timer, err))
+		}
+	}
+	if o.Dir != "" {
+		if err := os.RemoveAll(o.Dir); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", o.Dir, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to remove orphan '%s': %s", o.Tag, strings.Join(errs, "; "))
+	}
+	return nil
+}
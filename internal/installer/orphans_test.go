@@ -0,0 +1,59 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/usage"
+)
+
+func TestFindOrphans(t *testing.T) {
+	dir := t.TempDir()
+	for _, tag := range []string{"live", "crashed", "leftover"} {
+		if err := os.Mkdir(filepath.Join(dir, tag), 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stray.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Tunnels: []config.TunnelConfig{{Tag: "live"}}}
+	services := []string{
+		dnsrouter.ServiceName,
+		health.ServiceName,
+		usage.TimerName,
+		paths.Service("live"),
+		paths.Service("schedule-live"),
+		paths.Service("crashed"),
+		paths.Service("schedule-gone"),
+		paths.Service("schedule-x"),
+	}
+	timers := map[string]bool{
+		usage.TimerName:                true,
+		paths.Service("schedule-live"): true,
+		paths.Service("schedule-gone"): true,
+	}
+
+	got := findOrphans(cfg, services, func(name string) bool { return timers[name] }, dir)
+	want := []Orphan{
+		{Tag: "crashed", Service: paths.Service("crashed"), Dir: filepath.Join(dir, "crashed")},
+		{Tag: "gone", Timer: paths.Service("schedule-gone")},
+		{Tag: "leftover", Dir: filepath.Join(dir, "leftover")},
+		// A service named like a schedule timer without a timer is a tunnel
+		{Tag: "schedule-x", Service: paths.Service("schedule-x")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findOrphans() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := findOrphans(cfg, nil, func(string) bool { return false }, filepath.Join(dir, "missing")); len(got) != 0 {
+		t.Errorf("findOrphans() without services or directories = %+v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// Key material file names inside a tunnel directory.
+var (
+	certFiles = []string{"cert.pem", "key.pem"}
+	keyFiles  = []string{"server.key", "server.pub"}
+)
+
+// ResetOptions selects what a router reset preserves.
+type ResetOptions struct {
+	// KeepCerts preserves Slipstream certificates (cert.pem, key.pem).
+	KeepCerts bool
+	// KeepKeys preserves DNSTT/VayDNS key pairs (server.key, server.pub).
+	KeepKeys bool
+}
+
+// ResetPlan lists everything a router reset removes or keeps.
+type ResetPlan struct {
+	Tunnels       []string
+	Services      []string
+	RemoveFiles   []string
+	KeepFiles     []string
+	FirewallRules []string
+}
+
+// PlanRouterReset computes the effect of a router reset without changing anything.
+func PlanRouterReset(cfg *config.Config, opts ResetOptions) *ResetPlan {
+	plan := planTunnelFiles(cfg, config.TunnelsDir, opts)
+
+	for _, t := range cfg.Tunnels {
+		name := router.GetServiceName(t.Tag)
+		if service.IsServiceInstalled(name) {
+			plan.Services = append(plan.Services, name)
+		}
+	}
+	if dnsrouter.NewService().IsActive() {
+		plan.Services = append(plan.Services, dnsrouter.ServiceName+" (stop only)")
+	}
+
+	plan.FirewallRules = network.DNSRedirectRules()
+
+	return plan
+}
+
+// planTunnelFiles lists the tunnel files under tunnelsDir to remove or keep.
+func planTunnelFiles(cfg *config.Config, tunnelsDir string, opts ResetOptions) *ResetPlan {
+	plan := &ResetPlan{}
+
+	keep := make(map[string]bool)
+	if opts.KeepCerts {
+		for _, f := range certFiles {
+			keep[f] = true
+		}
+	}
+	if opts.KeepKeys {
+		for _, f := range keyFiles {
+			keep[f] = true
+		}
+	}
+
+	for _, t := range cfg.Tunnels {
+		plan.Tunnels = append(plan.Tunnels, t.Tag)
+	}
+
+	entries, _ := os.ReadDir(tunnelsDir)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(tunnelsDir, entry.Name())
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			if keep[info.Name()] && filepath.Dir(path) == dir {
+				plan.KeepFiles = append(plan.KeepFiles, path)
+			} else {
+				plan.RemoveFiles = append(plan.RemoveFiles, path)
+			}
+			return nil
+		})
+	}
+
+	sort.Strings(plan.RemoveFiles)
+	sort.Strings(plan.KeepFiles)
+	return plan
+}
+
+// PerformRouterReset removes all tunnels and their services, stops the DNS
+// router, clears the port 53 NAT redirects, and resets routing to single mode.
+// Backends and proxy settings are kept. Files listed in plan.KeepFiles stay
+// on disk so re-adding a tunnel with the same tag reuses them.
+func PerformRouterReset(cfg *config.Config, plan *ResetPlan) error {
+	for _, t := range cfg.Tunnels {
+		router.NewTunnel(&t).RemoveService()
+	}
+
+	if svc := dnsrouter.NewService(); svc.IsActive() {
+		svc.Stop()
+	}
+
+	network.ClearNATOnly()
+
+	for _, path := range plan.RemoveFiles {
+		os.Remove(path)
+	}
+	removeEmptyTunnelDirs(config.TunnelsDir)
+
+	cfg.Tunnels = []config.TunnelConfig{}
+	cfg.Route = config.RouteConfig{Mode: "single"}
+	return config.Update(func(c *config.Config) error {
+		c.Tunnels = cfg.Tunnels
+		c.Route = cfg.Route
+		return nil
+	})
+}
+
+// removeEmptyTunnelDirs removes tunnel directories left without any files.
+func removeEmptyTunnelDirs(tunnelsDir string) {
+	entries, err := os.ReadDir(tunnelsDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(tunnelsDir, entry.Name())
+		hasFiles := false
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				hasFiles = true
+			}
+			return nil
+		})
+		if !hasFiles {
+			os.RemoveAll(dir)
+		}
+	}
+}
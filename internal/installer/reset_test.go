@@ -0,0 +1,112 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func TestPlanTunnelFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"slip/cert.pem",
+		"slip/key.pem",
+		"slip/config.json",
+		"dnstt/server.key",
+		"dnstt/server.pub",
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{Tunnels: []config.TunnelConfig{{Tag: "slip"}, {Tag: "dnstt"}}}
+	p := func(f string) string { return filepath.Join(dir, f) }
+
+	tests := []struct {
+		name       string
+		opts       ResetOptions
+		wantKeep   []string
+		wantRemove []string
+	}{
+		{
+			name:       "remove everything",
+			opts:       ResetOptions{},
+			wantKeep:   nil,
+			wantRemove: []string{p("dnstt/server.key"), p("dnstt/server.pub"), p("slip/cert.pem"), p("slip/config.json"), p("slip/key.pem")},
+		},
+		{
+			name:       "keep certs",
+			opts:       ResetOptions{KeepCerts: true},
+			wantKeep:   []string{p("slip/cert.pem"), p("slip/key.pem")},
+			wantRemove: []string{p("dnstt/server.key"), p("dnstt/server.pub"), p("slip/config.json")},
+		},
+		{
+			name:       "keep certs and keys",
+			opts:       ResetOptions{KeepCerts: true, KeepKeys: true},
+			wantKeep:   []string{p("dnstt/server.key"), p("dnstt/server.pub"), p("slip/cert.pem"), p("slip/key.pem")},
+			wantRemove: []string{p("slip/config.json")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := planTunnelFiles(cfg, dir, tt.opts)
+			if !reflect.DeepEqual(plan.KeepFiles, tt.wantKeep) {
+				t.Errorf("KeepFiles = %v, want %v", plan.KeepFiles, tt.wantKeep)
+			}
+			if !reflect.DeepEqual(plan.RemoveFiles, tt.wantRemove) {
+				t.Errorf("RemoveFiles = %v, want %v", plan.RemoveFiles, tt.wantRemove)
+			}
+			if !reflect.DeepEqual(plan.Tunnels, []string{"slip", "dnstt"}) {
+				t.Errorf("Tunnels = %v", plan.Tunnels)
+			}
+		})
+	}
+}
+
+func TestRemoveEmptyTunnelDirs(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "empty", "sub"), 0755)
+	os.MkdirAll(filepath.Join(dir, "kept"), 0755)
+	os.WriteFile(filepath.Join(dir, "kept", "cert.pem"), []byte("x"), 0600)
+
+	removeEmptyTunnelDirs(dir)
+
+	if _, err := os.Stat(filepath.Join(dir, "empty")); !os.IsNotExist(err) {
+		t.Error("empty tunnel directory should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kept", "cert.pem")); err != nil {
+		t.Error("directory with kept files should remain")
+	}
+}
+
+func TestRemoveConfigKeepingCrypto(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"config.json", "tunnels/slip/cert.pem", "tunnels/slip/config.json", "tunnels/dnstt/server.pub", "tunnels/empty/notes.txt"} {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0600)
+	}
+
+	if kept := removeConfigKeepingCrypto(dir); kept != 2 {
+		t.Errorf("kept = %d, want 2", kept)
+	}
+
+	for _, f := range []string{"tunnels/slip/cert.pem", "tunnels/dnstt/server.pub"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("%s should be kept", f)
+		}
+	}
+	for _, f := range []string{"config.json", "tunnels/slip/config.json", "tunnels/empty"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", f)
+		}
+	}
+}
@@ -1,17 +1,163 @@
 package installer
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/net2share/dnstm/internal/actions"
+	"github.com/net2share/dnstm/internal/agent"
+	"github.com/net2share/dnstm/internal/api"
+	"github.com/net2share/dnstm/internal/certs"
+	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/fail2ban"
+	"github.com/net2share/dnstm/internal/fwguard"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/logship"
+	"github.com/net2share/dnstm/internal/metrics"
 	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/paths"
 	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/quarantine"
+	"github.com/net2share/dnstm/internal/router"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/snapshot"
+	"github.com/net2share/dnstm/internal/sshd"
 	"github.com/net2share/dnstm/internal/system"
+	"github.com/net2share/dnstm/internal/tune"
+	"github.com/net2share/dnstm/internal/updater"
+	"github.com/net2share/dnstm/internal/usage"
+	"github.com/net2share/dnstm/internal/version"
 )
 
-// PerformFullUninstall removes all dnstm components from the system.
-func PerformFullUninstall(output actions.OutputWriter, isInteractive bool) error {
+// uninstallBinaries are the managed binaries a full uninstall deletes.
+var uninstallBinaries = []string{
+	paths.Bin("dnstt-server"),
+	paths.Bin("slipstream-server"),
+	paths.Bin("ssserver"),
+	paths.Bin("sshtun-user"),
+	paths.Bin("vaydns-server"),
+	paths.Bin("microsocks"),
+	paths.Bin("chisel"),
+}
+
+// UninstallOptions selects which components an uninstall removes.
+type UninstallOptions struct {
+	// KeepCrypto preserves tunnel certificates and keys.
+	KeepCrypto bool
+	// KeepMicrosocks leaves the microsocks service and binary running.
+	KeepMicrosocks bool
+	// OnlyTunnels removes the tunnels and their files, and nothing else.
+	OnlyTunnels bool
+}
+
+// UninstallPlan lists everything an uninstall removes or keeps.
+type UninstallPlan struct {
+	ResetPlan
+	Options  UninstallOptions
+	Binaries []string
+	Keep     []string // components left in place
+}
+
+// PlanFullUninstall computes the effect of an uninstall without changing
+// anything.
+func PlanFullUninstall(opts UninstallOptions) (*UninstallPlan, error) {
+	cfg, err := router.Load()
+	if err != nil {
+		cfg = &config.Config{}
+	}
+	if opts.KeepMicrosocks && !opts.OnlyTunnels && cfg.Proxy.IsBuiltinEngine() {
+		return nil, fmt.Errorf("--keep-microsocks needs the microsocks engine; the built-in SOCKS5 server is part of dnstm")
+	}
+
+	plan := &UninstallPlan{Options: opts}
+	if opts.OnlyTunnels {
+		plan.ResetPlan = *PlanRouterReset(cfg, ResetOptions{KeepCerts: opts.KeepCrypto, KeepKeys: opts.KeepCrypto})
+		plan.Keep = []string{
+			dnsrouter.ServiceName + " service, SOCKS5 proxy, backends and settings",
+			"dnstm user, binaries and firewall rules",
+		}
+		return plan, nil
+	}
+
+	for _, t := range cfg.Tunnels {
+		plan.Tunnels = append(plan.Tunnels, t.Tag)
+		if name := router.GetServiceName(t.Tag); service.IsServiceInstalled(name) {
+			plan.Services = append(plan.Services, name)
+		}
+	}
+	if service.IsServiceInstalled(dnsrouter.ServiceName) {
+		plan.Services = append(plan.Services, dnsrouter.ServiceName)
+	}
+	for _, timer := range []string{updater.AutoUpdateName, usage.TimerName, fwguard.TimerName, router.ExpiryTimerName, router.LogScanTimerName, metrics.TimerName} {
+		if service.IsTimerInstalled(timer) {
+			plan.Services = append(plan.Services, timer+".timer")
+		}
+	}
+	if service.IsServiceInstalled(quarantine.HookName) {
+		plan.Services = append(plan.Services, quarantine.HookName)
+	}
+	if api.IsInstalled() {
+		plan.Services = append(plan.Services, api.ServiceName)
+	}
+	if service.IsTimerInstalled(api.ProvisionTimerName) {
+		plan.Services = append(plan.Services, api.ProvisionTimerName+".timer")
+	}
+	if agent.IsInstalled() {
+		plan.Services = append(plan.Services, agent.ServiceName)
+	}
+	if health.IsInstalled() {
+		plan.Services = append(plan.Services, health.ServiceName)
+	}
+	if logship.IsInstalled() {
+		plan.Services = append(plan.Services, logship.ServiceName)
+	}
+	if opts.KeepMicrosocks {
+		plan.Keep = append(plan.Keep, proxy.MicrosocksServiceName+" service and binary")
+	} else if service.IsServiceInstalled(proxy.MicrosocksServiceName) {
+		plan.Services = append(plan.Services, proxy.MicrosocksServiceName)
+	}
+
+	if opts.KeepCrypto {
+		plan.RemoveFiles, plan.KeepFiles = planConfigKeepingCrypto(config.ConfigDir)
+	} else if _, err := os.Stat(config.ConfigDir); err == nil {
+		plan.RemoveFiles = []string{config.ConfigDir}
+	}
+	if fail2ban.IsEnabled() {
+		plan.RemoveFiles = append(plan.RemoveFiles, fail2ban.JailPath)
+	}
+	if sshd.IsInstalled() {
+		plan.RemoveFiles = append(plan.RemoveFiles, sshd.DropInPath)
+	}
+	if tune.IsApplied() {
+		plan.RemoveFiles = append(plan.RemoveFiles, tune.ConfPath)
+	}
+
+	for _, bin := range uninstallBinaries {
+		if opts.KeepMicrosocks && filepath.Base(bin) == "microsocks" {
+			continue
+		}
+		if _, err := os.Stat(bin); err == nil {
+			plan.Binaries = append(plan.Binaries, bin)
+		}
+	}
+
+	plan.FirewallRules = append(append(network.DNSRedirectRules(), network.NotrackRules()...),
+		"port 53 and tunnel port allow rules",
+		"SOCKS egress and usage accounting rules")
+	plan.Keep = append(plan.Keep, "dnstm binary ("+dnstmBinary+")")
+
+	return plan, nil
+}
+
+// dnstmBinary is the installed dnstm binary, kept for reinstallation.
+var dnstmBinary = paths.Bin("dnstm")
+
+// PerformFullUninstall removes the dnstm components listed in plan. Tunnel
+// certificates and keys in plan.KeepFiles are left in place so a reinstall
+// can reuse them without clients updating fingerprints or keys.
+func PerformFullUninstall(output actions.OutputWriter, isInteractive bool, plan *UninstallPlan) error {
 	// Start progress view in interactive mode
 	if isInteractive {
 		output.BeginProgress("Uninstall")
@@ -19,7 +165,37 @@ func PerformFullUninstall(output actions.OutputWriter, isInteractive bool) error
 		output.Println()
 	}
 
-	output.Info("Performing full uninstall...")
+	opts := plan.Options
+	if opts.OnlyTunnels {
+		output.Info("Removing all tunnels...")
+	} else {
+		output.Info("Performing full uninstall...")
+	}
+
+	// Snapshot first so the uninstall can be rolled back after reinstalling
+	if m, err := snapshot.CreateAuto("before uninstall", version.Version); err != nil {
+		output.Warning("Failed to create snapshot: " + err.Error())
+	} else {
+		output.Status("Snapshot " + m.ID + " created")
+	}
+
+	if opts.OnlyTunnels {
+		cfg, err := router.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if err := PerformRouterReset(cfg, &plan.ResetPlan); err != nil {
+			return fmt.Errorf("failed to remove tunnels: %w", err)
+		}
+		output.Success(fmt.Sprintf("%d tunnels removed", len(plan.Tunnels)))
+		output.Info("The DNS router, SOCKS5 proxy, backends and binaries were kept.")
+		if isInteractive {
+			output.EndProgress()
+		} else {
+			output.Println()
+		}
+		return nil
+	}
 
 	totalSteps := 7
 	currentStep := 0
@@ -37,20 +213,65 @@ func PerformFullUninstall(output actions.OutputWriter, isInteractive bool) error
 	svc := dnsrouter.NewService()
 	svc.Stop()
 	svc.Remove()
+	if service.IsTimerInstalled(updater.AutoUpdateName) {
+		service.RemoveTimer(updater.AutoUpdateName)
+	}
+	if service.IsTimerInstalled(usage.TimerName) {
+		service.RemoveTimer(usage.TimerName)
+	}
+	if service.IsTimerInstalled(router.ExpiryTimerName) {
+		service.RemoveTimer(router.ExpiryTimerName)
+	}
+	if service.IsTimerInstalled(router.LogScanTimerName) {
+		service.RemoveTimer(router.LogScanTimerName)
+	}
+	if service.IsTimerInstalled(metrics.TimerName) {
+		service.RemoveTimer(metrics.TimerName)
+	}
+	// A pending rollback would put back the rules removed below
+	fwguard.Confirm()
+	if fail2ban.IsEnabled() {
+		fail2ban.Remove()
+	}
+	sshd.Remove()
+	// Before the config directory, which holds the values to put back
+	tune.Revert(false)
+	quarantine.RemoveHookUnit()
+	api.Remove()
+	agent.RemoveService()
+	health.RemoveService()
+	logship.RemoveService()
 	output.Status("DNS router service removed")
 
 	// Step 3: Remove microsocks service
 	currentStep++
-	output.Step(currentStep, totalSteps, "Removing microsocks...")
-	proxy.StopMicrosocks()
-	proxy.UninstallMicrosocks()
-	output.Status("Microsocks removed")
+	if opts.KeepMicrosocks {
+		output.Step(currentStep, totalSteps, "Detaching microsocks from dnstm...")
+		// Rewrite the unit while the config is still there
+		if cfg, err := config.Load(); err != nil {
+			output.Warning("microsocks: " + err.Error())
+		} else if err := proxy.DetachMicrosocks(cfg); err != nil {
+			output.Warning("microsocks: " + err.Error())
+		} else {
+			output.Status("Microsocks kept")
+		}
+	} else {
+		output.Step(currentStep, totalSteps, "Removing microsocks...")
+		proxy.StopMicrosocks()
+		proxy.UninstallMicrosocks()
+		output.Status("Microsocks removed")
+	}
 
 	// Step 4: Remove /etc/dnstm entirely
 	currentStep++
 	output.Step(currentStep, totalSteps, "Removing configuration directory...")
-	os.RemoveAll("/etc/dnstm")
-	output.Status("Configuration removed")
+	if opts.KeepCrypto {
+		kept := removeConfigKeepingCrypto(config.ConfigDir)
+		output.Status(fmt.Sprintf("Configuration removed (kept %d certificate/key files)", kept))
+	} else {
+		os.RemoveAll(config.ConfigDir)
+		output.Status("Configuration removed")
+	}
 
 	// Step 5: Remove dnstm user
 	currentStep++
@@ -61,18 +282,8 @@ func PerformFullUninstall(output actions.OutputWriter, isInteractive bool) error
 	// Step 6: Remove transport binaries
 	currentStep++
 	output.Step(currentStep, totalSteps, "Removing transport binaries...")
-	binaries := []string{
-		"/usr/local/bin/dnstt-server",
-		"/usr/local/bin/slipstream-server",
-		"/usr/local/bin/ssserver",
-		"/usr/local/bin/sshtun-user",
-		"/usr/local/bin/vaydns-server",
-		"/usr/local/bin/microsocks",
-	}
-	for _, bin := range binaries {
-		if _, err := os.Stat(bin); err == nil {
-			os.Remove(bin)
-		}
+	for _, bin := range plan.Binaries {
+		os.Remove(bin)
 	}
 	output.Status("Binaries removed")
 
@@ -81,12 +292,20 @@ func PerformFullUninstall(output actions.OutputWriter, isInteractive bool) error
 	output.Step(currentStep, totalSteps, "Removing firewall rules...")
 	network.ClearNATOnly()
 	network.RemoveAllFirewallRules()
+	network.SyncNotrack(false, "")
+	network.RemoveEgressRules()
+	network.RemoveUsageAccounting()
 	output.Status("Firewall rules removed")
 
 	output.Success("Uninstallation complete!")
-	output.Info("All dnstm components have been removed.")
+	if opts.KeepMicrosocks {
+		output.Info("All dnstm components except microsocks have been removed.")
+	} else {
+		output.Info("All dnstm components have been removed.")
+	}
 	output.Info("Note: The dnstm binary is still available for reinstallation.")
-	output.Info("      To fully remove: rm /usr/local/bin/dnstm")
+	output.Info("      To restore: dnstm install, then dnstm snapshot rollback latest")
+	output.Info("      To fully remove: rm " + dnstmBinary)
 
 	if isInteractive {
 		output.EndProgress()
@@ -96,3 +315,36 @@ func PerformFullUninstall(output actions.OutputWriter, isInteractive bool) error
 
 	return nil
 }
+
+// planConfigKeepingCrypto lists the paths under configDir to delete so that
+// only tunnel certificates and keys remain, and the files kept.
+func planConfigKeepingCrypto(configDir string) (remove, keep []string) {
+	tunnelsDir := filepath.Join(configDir, "tunnels")
+	plan := planTunnelFiles(&config.Config{}, tunnelsDir, ResetOptions{KeepCerts: true, KeepKeys: true})
+	remove = plan.RemoveFiles
+
+	// The key history goes with the keys it records
+	history := filepath.Base(certs.HistoryFile)
+	entries, _ := os.ReadDir(configDir)
+	for _, entry := range entries {
+		switch entry.Name() {
+		case "tunnels":
+		case history:
+			plan.KeepFiles = append(plan.KeepFiles, filepath.Join(configDir, history))
+		default:
+			remove = append(remove, filepath.Join(configDir, entry.Name()))
+		}
+	}
+	return remove, plan.KeepFiles
+}
+
+// removeConfigKeepingCrypto removes the config directory except tunnel
+// certificates and keys, and returns the number of files kept.
+func removeConfigKeepingCrypto(configDir string) int {
+	remove, keep := planConfigKeepingCrypto(configDir)
+	for _, path := range remove {
+		os.RemoveAll(path)
+	}
+	removeEmptyTunnelDirs(filepath.Join(configDir, "tunnels"))
+	return len(keep)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/net2share/dnstm/internal/system"
 	"golang.org/x/crypto/curve25519"
@@ -14,10 +15,6 @@ import (
 // Generate creates a new Curve25519 key pair for dnstt.
 // Keys are stored as 64-character hex strings (32 bytes).
 func Generate(privateKeyPath, publicKeyPath string) (publicKey string, err error) {
-	if err := os.MkdirAll(filepath.Dir(privateKeyPath), 0750); err != nil {
-		return "", fmt.Errorf("failed to create key directory: %w", err)
-	}
-
 	// Generate 32 random bytes for private key
 	var privateKey [32]byte
 	if _, err := rand.Read(privateKey[:]); err != nil {
@@ -29,6 +26,27 @@ func Generate(privateKeyPath, publicKeyPath string) (publicKey string, err error
 	privateKey[31] &= 127
 	privateKey[31] |= 64
 
+	return writeKeyPair(privateKeyPath, publicKeyPath, privateKey)
+}
+
+// Import stores an existing private key, given as the 64-character hex
+// string dnstt-server reads, together with its public key.
+func Import(privateKeyHex, privateKeyPath, publicKeyPath string) (publicKey string, err error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(privateKeyHex))
+	if err != nil || len(raw) != 32 {
+		return "", fmt.Errorf("invalid private key: expected 64 hex characters")
+	}
+	var privateKey [32]byte
+	copy(privateKey[:], raw)
+	return writeKeyPair(privateKeyPath, publicKeyPath, privateKey)
+}
+
+// writeKeyPair writes privateKey and its public key as hex files.
+func writeKeyPair(privateKeyPath, publicKeyPath string, privateKey [32]byte) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(privateKeyPath), 0750); err != nil {
+		return "", fmt.Errorf("failed to create key directory: %w", err)
+	}
+
 	// Derive public key
 	var pubKey [32]byte
 	curve25519.ScalarBaseMult(&pubKey, &privateKey)
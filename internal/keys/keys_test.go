@@ -234,3 +234,33 @@ func TestGenerateInDir(t *testing.T) {
 		t.Errorf("public key path = %q, want %q", info.PublicKeyPath, filepath.Join(tmpDir, "server.pub"))
 	}
 }
+
+func TestImportInDir(t *testing.T) {
+	src := t.TempDir()
+	pubKey, err := Generate(filepath.Join(src, "server.key"), filepath.Join(src, "server.pub"))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	privKey, err := os.ReadFile(filepath.Join(src, "server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	info, err := ImportInDir(dir, string(privKey))
+	if err != nil {
+		t.Fatalf("ImportInDir failed: %v", err)
+	}
+	if info.PublicKey != pubKey {
+		t.Errorf("public key = %s, want %s", info.PublicKey, pubKey)
+	}
+	if got := GetFromDir(dir); got == nil || got.PublicKey != pubKey {
+		t.Errorf("GetFromDir() = %+v, want the imported key", got)
+	}
+
+	for _, bad := range []string{"", "abcd", strings.Repeat("zz", 32)} {
+		if _, err := ImportInDir(t.TempDir(), bad); err == nil {
+			t.Errorf("ImportInDir(%q) succeeded", bad)
+		}
+	}
+}
@@ -58,3 +58,21 @@ func GenerateInDir(dir string) (*KeyInfo, error) {
 		PublicKey:      pubKey,
 	}, nil
 }
+
+// ImportInDir stores an existing private key into dir/server.key and its
+// public key into dir/server.pub.
+func ImportInDir(dir, privateKeyHex string) (*KeyInfo, error) {
+	privPath := filepath.Join(dir, "server.key")
+	pubPath := filepath.Join(dir, "server.pub")
+
+	pubKey, err := Import(privateKeyHex, privPath, pubPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyInfo{
+		PrivateKeyPath: privPath,
+		PublicKeyPath:  pubPath,
+		PublicKey:      pubKey,
+	}, nil
+}
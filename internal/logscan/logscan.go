@@ -0,0 +1,118 @@
+// Package logscan scans the logs of tunnels for error patterns such as
+// bind failures, TLS errors or repeated connection resets.
+//
+// The log scan timer runs "dnstm tunnel scan-logs" every few minutes. Each
+// run reads what every running tunnel logged since the last run and counts
+// the lines matching the patterns of its transport (see
+// config.LogAlertsConfig). A tunnel with a pattern matched threshold times
+// is marked degraded here and the operator is alerted; the mark is lifted
+// by the first clean scan.
+package logscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Dir holds the scan state of each tunnel.
+var Dir = "/var/lib/dnstm/logscan"
+
+// Match counts the lines that matched one pattern.
+type Match struct {
+	Pattern string `json:"pattern"`
+	Count   int    `json:"count"`
+}
+
+// State is the result of the last scan of a tunnel.
+type State struct {
+	Tag      string    `json:"tag"`
+	Checked  time.Time `json:"checked"`            // end of the last scan
+	Degraded bool      `json:"degraded,omitempty"` // a pattern reached the threshold
+	Since    time.Time `json:"since"`              // when the health last changed
+	Matches  []Match   `json:"matches,omitempty"`  // of the last scan
+}
+
+// Count counts the lines matching each pattern. Patterns without matches
+// are left out; the rest are ordered by count, highest first.
+func Count(lines []string, patterns []string) ([]Match, error) {
+	var matches []Match
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		n := 0
+		for _, line := range lines {
+			if re.MatchString(line) {
+				n++
+			}
+		}
+		if n > 0 {
+			matches = append(matches, Match{Pattern: p, Count: n})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Count > matches[j].Count })
+	return matches, nil
+}
+
+// Apply records a scan ending at now in s and reports whether the tunnel's
+// health changed.
+func (s *State) Apply(matches []Match, threshold int, now time.Time) bool {
+	degraded := len(matches) > 0 && matches[0].Count >= threshold
+	changed := degraded != s.Degraded
+	if changed {
+		s.Since = now
+	}
+	s.Degraded = degraded
+	s.Checked = now
+	s.Matches = matches
+	return changed
+}
+
+func statePath(tag string) string {
+	return filepath.Join(Dir, tag+".json")
+}
+
+// Get returns the scan state of tag, or nil if it was never scanned.
+func Get(tag string) *State {
+	data, err := os.ReadFile(statePath(tag))
+	if err != nil {
+		return nil
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+// IsDegraded reports whether the last scan marked tag degraded.
+func IsDegraded(tag string) bool {
+	s := Get(tag)
+	return s != nil && s.Degraded
+}
+
+// Save stores the scan state of a tunnel.
+func Save(s *State) error {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create log scan directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath(s.Tag), data, 0644)
+}
+
+// Clear removes the scan state of tag.
+func Clear(tag string) error {
+	if err := os.Remove(statePath(tag)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear log scan state: %w", err)
+	}
+	return nil
+}
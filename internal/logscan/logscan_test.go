@@ -0,0 +1,66 @@
+package logscan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCount(t *testing.T) {
+	lines := []string{
+		"listen udp 127.0.0.1:5300: bind: address already in use",
+		"read: connection reset by peer",
+		"write: broken pipe",
+		"session opened",
+	}
+	matches, err := Count(lines, []string{`address already in use`, `connection reset|broken pipe`, `tls: `})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("got %+v, want 2 matches", matches)
+	}
+	if matches[0].Count != 2 || matches[1].Count != 1 {
+		t.Errorf("got %+v, want the highest count first", matches)
+	}
+
+	if _, err := Count(lines, []string{"("}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestStateApply(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s := &State{Tag: "a"}
+
+	if s.Apply([]Match{{Pattern: "x", Count: 2}}, 5, now) || s.Degraded {
+		t.Fatalf("below the threshold: got %+v", s)
+	}
+	if !s.Apply([]Match{{Pattern: "x", Count: 5}}, 5, now) || !s.Degraded || !s.Since.Equal(now) {
+		t.Fatalf("at the threshold: got %+v", s)
+	}
+	later := now.Add(5 * time.Minute)
+	if s.Apply([]Match{{Pattern: "x", Count: 9}}, 5, later) || !s.Since.Equal(now) {
+		t.Errorf("still degraded: got %+v", s)
+	}
+	if !s.Apply(nil, 5, later) || s.Degraded || !s.Checked.Equal(later) {
+		t.Errorf("clean scan: got %+v", s)
+	}
+}
+
+func TestSaveGet(t *testing.T) {
+	Dir = t.TempDir()
+	defer func() { Dir = "/var/lib/dnstm/logscan" }()
+
+	if Get("a") != nil || IsDegraded("a") {
+		t.Fatal("expected no state")
+	}
+	if err := Save(&State{Tag: "a", Degraded: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !IsDegraded("a") {
+		t.Error("expected a degraded tunnel")
+	}
+	if err := Clear("a"); err != nil || Get("a") != nil {
+		t.Errorf("state not cleared: %v", err)
+	}
+}
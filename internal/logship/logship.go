@@ -0,0 +1,225 @@
+// Package logship forwards the logs of dnstm's services to a remote
+// syslog server or Loki, so the logs of many servers can be kept in one
+// place.
+//
+// The log shipping service ("dnstm logship serve") follows the journal of
+// every dnstm unit, tunnels included, and sends each entry with fields
+// naming where it came from: the host, the service and, for tunnels, the
+// tag, transport, backend and the tunnel's labels. The journal cursor of
+// the last entry sent is kept under StateDir, so entries written while the
+// remote end was down or the service stopped are sent once it is back.
+package logship
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/router"
+)
+
+// StateDir holds the cursor of the last entry sent.
+const StateDir = "/var/lib/dnstm/logship"
+
+// CursorFile holds the journal cursor of the last entry sent.
+var CursorFile = filepath.Join(StateDir, "cursor")
+
+// Syslog severities of journal entries.
+const (
+	SeverityError   = 3
+	SeverityWarning = 4
+	SeverityInfo    = 6
+)
+
+// Entry is one journal entry of a dnstm service.
+type Entry struct {
+	Time     time.Time
+	Unit     string // e.g. dnstm-slip1.service
+	Severity int    // syslog severity, 0 (emergency) to 7 (debug)
+	Message  string
+	Cursor   string
+}
+
+// ParseJournal parses a line of "journalctl -o json" output. Entries
+// without a message are skipped.
+func ParseJournal(line []byte) (Entry, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return Entry{}, false
+	}
+	e := Entry{
+		Unit:     journalString(fields["_SYSTEMD_UNIT"]),
+		Severity: SeverityInfo,
+		Message:  journalString(fields["MESSAGE"]),
+		Cursor:   journalString(fields["__CURSOR"]),
+	}
+	if e.Message == "" {
+		return Entry{}, false
+	}
+	if p, err := strconv.Atoi(journalString(fields["PRIORITY"])); err == nil && p >= 0 && p <= 7 {
+		e.Severity = p
+	}
+	if us, err := strconv.ParseInt(journalString(fields["__REALTIME_TIMESTAMP"]), 10, 64); err == nil {
+		e.Time = time.UnixMicro(us)
+	} else {
+		e.Time = time.Now()
+	}
+	return e, true
+}
+
+// journalString returns a journal field as a string. The journal gives
+// fields that are not valid UTF-8 as an array of bytes.
+func journalString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var b []byte
+	var ints []int
+	if json.Unmarshal(raw, &ints) == nil {
+		for _, i := range ints {
+			b = append(b, byte(i))
+		}
+		return strings.ToValidUTF8(string(b), string(utf8.RuneError))
+	}
+	return ""
+}
+
+// SeverityName returns the name of a syslog severity.
+func SeverityName(severity int) string {
+	switch {
+	case severity <= SeverityError:
+		return "error"
+	case severity == SeverityWarning:
+		return "warning"
+	case severity == 7:
+		return "debug"
+	}
+	return "info"
+}
+
+// invalidLabelChars matches what may not appear in a Loki label name or a
+// syslog structured data parameter name.
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// LabelName turns a tunnel label key into a field name both targets accept.
+func LabelName(key string) string {
+	name := invalidLabelChars.ReplaceAllString(key, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+// Labeler gives the fields sent with the entries of each service.
+type Labeler struct {
+	host   string
+	extra  map[string]string
+	units  map[string]map[string]string
+	loaded time.Time
+}
+
+// labelerReload is how often the config is reread for services that were
+// added after it was loaded.
+const labelerReload = time.Minute
+
+// NewLabeler returns the fields of the services of cfg, with the labels of
+// log.ship added to all of them.
+func NewLabeler(cfg *config.Config, host string) *Labeler {
+	l := &Labeler{host: host}
+	l.load(cfg)
+	return l
+}
+
+func (l *Labeler) load(cfg *config.Config) {
+	l.loaded = time.Now()
+	l.extra = nil
+	if cfg.Log.Ship != nil {
+		l.extra = cfg.Log.Ship.Labels
+	}
+	l.units = make(map[string]map[string]string, len(cfg.Tunnels))
+	for _, t := range cfg.Tunnels {
+		fields := map[string]string{
+			"tunnel":    t.Tag,
+			"transport": string(t.Transport),
+			"backend":   t.Backend,
+		}
+		for key, value := range t.Labels {
+			if _, taken := fields[LabelName(key)]; !taken {
+				fields[LabelName(key)] = value
+			}
+		}
+		l.units[router.GetServiceName(t.Tag)+".service"] = fields
+	}
+}
+
+// Fields returns the fields of the entries of unit. A tunnel service added
+// since the config was loaded is picked up by rereading it, at most once
+// a minute.
+func (l *Labeler) Fields(unit string) map[string]string {
+	own, ok := l.units[unit]
+	if !ok && time.Since(l.loaded) > labelerReload {
+		if cfg, err := config.Load(); err == nil {
+			l.load(cfg)
+			own = l.units[unit]
+		} else {
+			l.loaded = time.Now()
+		}
+	}
+
+	fields := map[string]string{
+		"host":    l.host,
+		"service": strings.TrimSuffix(unit, ".service"),
+	}
+	for key, value := range own {
+		fields[key] = value
+	}
+	// Operator labels do not replace the fields identifying the entry
+	for key, value := range l.extra {
+		if _, taken := fields[LabelName(key)]; !taken {
+			fields[LabelName(key)] = value
+		}
+	}
+	return fields
+}
+
+// LoadCursor returns the cursor of the last entry sent, or "" if none was.
+func LoadCursor() string {
+	data, err := os.ReadFile(CursorFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// SaveCursor records the cursor of the last entry sent.
+func SaveCursor(cursor string) error {
+	tmp := CursorFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(cursor+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, CursorFile)
+}
+
+// journalArgs returns the journalctl arguments that follow the journal of
+// all dnstm units after cursor, or from now when there is none.
+func journalArgs(cursor string) []string {
+	args := []string{"--follow", "--output=json", "--no-pager", "--quiet", "--unit=" + paths.Service("*")}
+	if cursor != "" {
+		return append(args, "--after-cursor="+cursor)
+	}
+	return append(args, "--lines=0")
+}
@@ -0,0 +1,150 @@
+package logship
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func TestParseJournal(t *testing.T) {
+	line := `{"__CURSOR":"s=1;i=2","__REALTIME_TIMESTAMP":"1792152000000000","_SYSTEMD_UNIT":"dnstm-slip1.service","PRIORITY":"4","MESSAGE":"backend slow"}`
+	e, ok := ParseJournal([]byte(line))
+	if !ok {
+		t.Fatal("ParseJournal() skipped a valid entry")
+	}
+	if e.Unit != "dnstm-slip1.service" || e.Severity != SeverityWarning || e.Message != "backend slow" || e.Cursor != "s=1;i=2" {
+		t.Errorf("ParseJournal() = %+v", e)
+	}
+	if !e.Time.Equal(time.UnixMicro(1792152000000000)) {
+		t.Errorf("Time = %v", e.Time)
+	}
+
+	// Messages that are not valid UTF-8 come as an array of bytes
+	e, ok = ParseJournal([]byte(`{"MESSAGE":[104,105,255],"PRIORITY":"9"}`))
+	if !ok || e.Message != "hi�" || e.Severity != SeverityInfo {
+		t.Errorf("ParseJournal(bytes) = %+v, %v", e, ok)
+	}
+
+	for _, line := range []string{`{"PRIORITY":"6"}`, `not json`} {
+		if _, ok := ParseJournal([]byte(line)); ok {
+			t.Errorf("ParseJournal(%s) should skip the line", line)
+		}
+	}
+}
+
+func testLabeler() *Labeler {
+	cfg := &config.Config{
+		Log: config.LogConfig{Ship: &config.LogShipConfig{
+			URL:    "udp://logs.example.com",
+			Labels: map[string]string{"env": "prod", "tunnel": "ignored"},
+		}},
+		Tunnels: []config.TunnelConfig{{
+			Tag:       "slip1",
+			Transport: config.TransportSlipstream,
+			Backend:   "socks",
+			Labels:    map[string]string{"region.name": "eu"},
+		}},
+	}
+	return NewLabeler(cfg, "srv1")
+}
+
+func TestLabelerFields(t *testing.T) {
+	l := testLabeler()
+
+	got := l.Fields("dnstm-slip1.service")
+	want := map[string]string{
+		"host": "srv1", "service": "dnstm-slip1", "tunnel": "slip1",
+		"transport": "slipstream", "backend": "socks", "region_name": "eu", "env": "prod",
+	}
+	if len(got) != len(want) {
+		t.Errorf("Fields() = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("Fields()[%s] = %q, want %q", key, got[key], value)
+		}
+	}
+
+	got = l.Fields("dnstm-dnsrouter.service")
+	if got["service"] != "dnstm-dnsrouter" || got["tunnel"] != "ignored" {
+		t.Errorf("Fields(router) = %v", got)
+	}
+}
+
+func TestLabelName(t *testing.T) {
+	tests := map[string]string{
+		"region":                "region",
+		"a.b-c":                 "a_b_c",
+		"1st":                   "_1st",
+		"":                      "_",
+		strings.Repeat("x", 40): strings.Repeat("x", 32),
+	}
+	for in, want := range tests {
+		if got := LabelName(in); got != want {
+			t.Errorf("LabelName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatSyslog(t *testing.T) {
+	e := Entry{
+		Time:     time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Unit:     "dnstm-slip1.service",
+		Severity: SeverityError,
+		Message:  "backend down",
+	}
+	got := FormatSyslog(e, map[string]string{"host": "srv1", "service": "dnstm-slip1", "tunnel": `a"b]`})
+	want := `<27>1 2026-10-16T12:00:00Z srv1 dnstm-slip1 - - [dnstm@32473 service="dnstm-slip1" tunnel="a\"b\]"] backend down`
+	if got != want {
+		t.Errorf("FormatSyslog() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLokiPush(t *testing.T) {
+	l := testLabeler()
+	at := time.Unix(1792152000, 0)
+	entries := []Entry{
+		{Time: at, Unit: "dnstm-slip1.service", Severity: SeverityInfo, Message: "one"},
+		{Time: at, Unit: "dnstm-dnsrouter.service", Severity: SeverityInfo, Message: "two"},
+		{Time: at.Add(time.Second), Unit: "dnstm-slip1.service", Severity: SeverityInfo, Message: "three"},
+		{Time: at, Unit: "dnstm-slip1.service", Severity: SeverityError, Message: "four"},
+	}
+	body, err := LokiPush(entries, l)
+	if err != nil {
+		t.Fatalf("LokiPush() error = %v", err)
+	}
+
+	var push struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	if err := json.Unmarshal(body, &push); err != nil {
+		t.Fatalf("invalid push body: %v", err)
+	}
+	if len(push.Streams) != 3 {
+		t.Fatalf("got %d streams, want 3 (one per service and level)", len(push.Streams))
+	}
+	first := push.Streams[0]
+	if first.Stream["job"] != "dnstm" || first.Stream["level"] != "info" || first.Stream["tunnel"] != "slip1" {
+		t.Errorf("stream labels = %v", first.Stream)
+	}
+	if len(first.Values) != 2 || first.Values[1][0] != "1792152001000000000" || first.Values[1][1] != "three" {
+		t.Errorf("stream values = %v", first.Values)
+	}
+	if push.Streams[2].Stream["level"] != "error" {
+		t.Errorf("third stream = %v, want the error level", push.Streams[2].Stream)
+	}
+}
+
+func TestJournalArgs(t *testing.T) {
+	args := strings.Join(journalArgs(""), " ")
+	if !strings.Contains(args, "--unit=dnstm-*") || !strings.HasSuffix(args, "--lines=0") {
+		t.Errorf("journalArgs() = %s", args)
+	}
+	args = strings.Join(journalArgs("s=1"), " ")
+	if !strings.HasSuffix(args, "--after-cursor=s=1") {
+		t.Errorf("journalArgs(cursor) = %s", args)
+	}
+}
@@ -0,0 +1,94 @@
+package logship
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lokiPushPath is the push API of Loki, used when the URL has no path.
+const lokiPushPath = "/loki/api/v1/push"
+
+// lokiSender pushes entries to Loki, one stream per service and level.
+type lokiSender struct {
+	url    string
+	client *http.Client
+}
+
+func newLokiSender(u *url.URL) *lokiSender {
+	push := *u
+	if push.Path == "" || push.Path == "/" {
+		push.Path = lokiPushPath
+	}
+	return &lokiSender{url: push.String(), client: &http.Client{Timeout: dialTimeout}}
+}
+
+// lokiStream is a stream of a push request.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// LokiPush builds the body of a push request for entries.
+func LokiPush(entries []Entry, labeler *Labeler) ([]byte, error) {
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, e := range entries {
+		labels := labeler.Fields(e.Unit)
+		labels["job"] = "dnstm"
+		labels["level"] = SeverityName(e.Severity)
+		key := streamKey(labels)
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: labels}
+			streams[key] = s
+			order = append(order, key)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), e.Message})
+	}
+
+	body := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, key := range order {
+		body.Streams = append(body.Streams, streams[key])
+	}
+	return json.Marshal(body)
+}
+
+// streamKey identifies a label set.
+func streamKey(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+strconv.Quote(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Send pushes the entries in one request. Credentials in the URL are sent
+// as basic auth.
+func (s *lokiSender) Send(entries []Entry, labeler *Labeler) error {
+	body, err := LokiPush(entries, labeler)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to push to Loki: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Loki answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (s *lokiSender) Close() {}
@@ -0,0 +1,76 @@
+package logship
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+// ServiceName is the service shipping the logs.
+var ServiceName = paths.SystemService("logship")
+
+// journalGroup may read the whole system journal.
+const journalGroup = "systemd-journal"
+
+// Sync runs the log shipping service while log.ship is set, restarting it
+// so it picks up a changed target, and removes it otherwise.
+func Sync(cfg *config.Config) error {
+	if cfg.Log.Ship == nil {
+		return RemoveService()
+	}
+	if !service.UsesSystemd() {
+		return fmt.Errorf("log shipping reads the systemd journal, which this system does not use")
+	}
+
+	// The state directory must exist for ReadWritePaths
+	if err := os.MkdirAll(StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", StateDir, err)
+	}
+	if err := system.ChownToDnstm(StateDir); err != nil {
+		return fmt.Errorf("failed to set ownership of %s: %w", StateDir, err)
+	}
+
+	if err := service.CreateGenericService(&service.ServiceConfig{
+		Name:           ServiceName,
+		Description:    "dnstm log shipping",
+		User:           system.DnstmUser,
+		Group:          journalGroup,
+		ExecStart:      cmdline.Join(paths.Bin("dnstm"), "logship", "serve"),
+		ReadOnlyPaths:  []string{paths.ConfigDir},
+		ReadWritePaths: []string{StateDir},
+	}); err != nil {
+		return err
+	}
+	if service.IsServiceActive(ServiceName) {
+		return service.RestartService(ServiceName)
+	}
+	if err := service.EnableService(ServiceName); err != nil {
+		return err
+	}
+	return service.StartService(ServiceName)
+}
+
+// IsInstalled reports whether the log shipping service unit exists.
+func IsInstalled() bool {
+	return service.IsServiceInstalled(ServiceName)
+}
+
+// IsActive reports whether the log shipping service is running.
+func IsActive() bool {
+	return service.IsServiceActive(ServiceName)
+}
+
+// RemoveService stops the log shipping service and deletes its unit.
+func RemoveService() error {
+	if !IsInstalled() {
+		return nil
+	}
+	service.StopService(ServiceName)
+	service.DisableService(ServiceName)
+	return service.RemoveService(ServiceName)
+}
@@ -0,0 +1,174 @@
+package logship
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/privacy"
+)
+
+const (
+	// batchSize and batchWait bound how many entries are sent at once and
+	// how long the first of them waits for more.
+	batchSize = 200
+	batchWait = time.Second
+
+	// maxBackoff is the longest wait between attempts to send a batch.
+	maxBackoff = time.Minute
+)
+
+// Sender sends entries to a remote end.
+type Sender interface {
+	Send(entries []Entry, labeler *Labeler) error
+	Close()
+}
+
+// NewSender returns the sender for the target of ship.
+func NewSender(ship *config.LogShipConfig) (Sender, error) {
+	u, err := url.Parse(ship.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log.ship.url: %w", err)
+	}
+	switch ship.Target() {
+	case config.LogShipSyslog:
+		return newSyslogSender(u), nil
+	case config.LogShipLoki:
+		return newLokiSender(u), nil
+	}
+	return nil, fmt.Errorf("unknown log shipping target '%s'", u.Scheme)
+}
+
+// SendTest sends one entry saying log shipping was turned on, to check the
+// remote end can be reached.
+func SendTest(cfg *config.Config) error {
+	sender, err := NewSender(cfg.Log.Ship)
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+	host, _ := os.Hostname()
+	e := Entry{Time: time.Now(), Unit: "dnstm", Severity: SeverityInfo, Message: "dnstm log shipping enabled"}
+	return sender.Send([]Entry{e}, NewLabeler(cfg, host))
+}
+
+// Run follows the journal of the dnstm services and sends its entries
+// until ctx is done, with IP addresses anonymized when privacy is set. A batch the remote end does not take is retried with
+// growing delays while the journal is read no further, so nothing is lost.
+func Run(ctx context.Context, cfg *config.Config) error {
+	sender, err := NewSender(cfg.Log.Ship)
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+	host, _ := os.Hostname()
+	labeler := NewLabeler(cfg, host)
+	anon := privacy.New(cfg.Privacy)
+
+	cmd := exec.CommandContext(ctx, "journalctl", journalArgs(LoadCursor())...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to read the journal: %w", err)
+	}
+
+	entries := make(chan Entry, batchSize)
+	go func() {
+		defer close(entries)
+		sc := bufio.NewScanner(stdout)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			if e, ok := ParseJournal(sc.Bytes()); ok {
+				e.Message = anon.Text(e.Message)
+				entries <- e
+			}
+		}
+	}()
+
+	log.Printf("Shipping logs to %s", RedactURL(cfg.Log.Ship.URL))
+	for {
+		batch, open := nextBatch(entries)
+		if len(batch) > 0 {
+			if err := sendWithRetry(ctx, sender, batch, labeler); err != nil {
+				break
+			}
+			if err := SaveCursor(batch[len(batch)-1].Cursor); err != nil {
+				log.Printf("[warning] cursor not saved: %v", err)
+			}
+		}
+		if !open {
+			break
+		}
+	}
+
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("journalctl stopped: %w", err)
+	}
+	return nil
+}
+
+// nextBatch waits for an entry, then collects what follows it within
+// batchWait. It reports false once entries is closed.
+func nextBatch(entries <-chan Entry) ([]Entry, bool) {
+	e, ok := <-entries
+	if !ok {
+		return nil, false
+	}
+	batch := []Entry{e}
+	timer := time.NewTimer(batchWait)
+	defer timer.Stop()
+	for len(batch) < batchSize {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, e)
+		case <-timer.C:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// sendWithRetry sends batch until it is taken or ctx is done. Only the
+// first failure and the recovery are logged, as they are shipped too.
+func sendWithRetry(ctx context.Context, sender Sender, batch []Entry, labeler *Labeler) error {
+	backoff := time.Second
+	failed := false
+	for {
+		err := sender.Send(batch, labeler)
+		if err == nil {
+			if failed {
+				log.Printf("Log shipping recovered")
+			}
+			return nil
+		}
+		if !failed {
+			log.Printf("[warning] %v; retrying", err)
+			failed = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// RedactURL hides the password of a URL for display.
+func RedactURL(s string) string {
+	if u, err := url.Parse(s); err == nil {
+		return u.Redacted()
+	}
+	return s
+}
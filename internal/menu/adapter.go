@@ -7,6 +7,8 @@ import (
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/handlers"
+	"github.com/net2share/dnstm/internal/i18n"
+	"github.com/net2share/dnstm/internal/prompt"
 	"github.com/net2share/dnstm/internal/router"
 	"github.com/net2share/go-corelib/tui"
 )
@@ -50,19 +52,23 @@ func newActionContext() *actions.Context {
 
 // handleConfirmation shows a confirmation prompt if the action requires one.
 // titleSuffix is appended to the message (e.g. "'tunnel-tag'") when present.
-func handleConfirmation(action *actions.Action, titleSuffix string) error {
+// A dry run changes nothing and needs no confirmation.
+func handleConfirmation(ctx *actions.Context, action *actions.Action, titleSuffix string) error {
 	if action.Confirm == nil {
 		return nil
 	}
+	if action.Confirm.DryRunFlag != "" && ctx.GetBool(action.Confirm.DryRunFlag) {
+		return nil
+	}
 
-	title := action.Confirm.Message
+	title := i18n.T(action.Confirm.Message)
 	if titleSuffix != "" {
 		title = fmt.Sprintf("%s '%s'?", title, titleSuffix)
 	}
 
-	confirm, err := tui.RunConfirm(tui.ConfirmConfig{
+	confirm, err := prompt.RunConfirm(tui.ConfirmConfig{
 		Title:       title,
-		Description: action.Confirm.Description,
+		Description: i18n.T(action.Confirm.Description),
 		Default:     !action.Confirm.DefaultNo,
 	})
 	if err != nil {
@@ -99,9 +105,9 @@ func BuildMenuOptions(parentID string) []tui.MenuOption {
 			continue
 		}
 
-		label := action.MenuLabel
+		label := i18n.T(action.MenuLabel)
 		if label == "" {
-			label = action.Short
+			label = i18n.T(action.Short)
 		}
 
 		// Add arrow for submenus
@@ -145,9 +151,9 @@ func RunAction(actionID string) error {
 			ctx.Values[action.Args.Name] = selected
 		} else if action.Args.Required {
 			// Prompt for text input when no picker is available and arg is required
-			value, confirmed, err := tui.RunInput(tui.InputConfig{
+			value, confirmed, err := prompt.RunInput(tui.InputConfig{
 				Title:       action.Args.Name,
-				Description: action.Args.Description,
+				Description: i18n.T(action.Args.Description),
 			})
 			if err != nil {
 				return err
@@ -156,7 +162,7 @@ func RunAction(actionID string) error {
 				return errCancelled
 			}
 			if value == "" {
-				return fmt.Errorf("%s is required", action.Args.Name)
+				return fmt.Errorf(i18n.T("%s is required"), action.Args.Name)
 			}
 			ctx.Values[action.Args.Name] = value
 		}
@@ -168,7 +174,7 @@ func RunAction(actionID string) error {
 		return err
 	}
 
-	if err := handleConfirmation(action, ""); err != nil {
+	if err := handleConfirmation(ctx, action, ""); err != nil {
 		return err
 	}
 
@@ -194,7 +200,7 @@ func collectInputs(ctx *actions.Context, action *actions.Action) error {
 		case actions.InputTypeText, actions.InputTypePassword:
 			var val string
 			var confirmed bool
-			baseDescription := input.Description
+			baseDescription := i18n.T(input.Description)
 			defaultVal := input.Default
 			if input.DefaultFunc != nil {
 				defaultVal = input.DefaultFunc(ctx)
@@ -219,8 +225,8 @@ func collectInputs(ctx *actions.Context, action *actions.Action) error {
 					}
 				}
 
-				val, confirmed, err = tui.RunInput(tui.InputConfig{
-					Title:       input.Label,
+				val, confirmed, err = prompt.RunInput(tui.InputConfig{
+					Title:       i18n.T(input.Label),
 					Description: description,
 					Placeholder: input.Placeholder,
 					Value:       defaultVal,
@@ -237,7 +243,7 @@ func collectInputs(ctx *actions.Context, action *actions.Action) error {
 				}
 				// Check if required and still empty
 				if input.Required && val == "" {
-					validationErr = fmt.Errorf("%s is required", input.Label)
+					validationErr = fmt.Errorf(i18n.T("%s is required"), i18n.T(input.Label))
 					continue
 				}
 				// Run custom validation if defined
@@ -253,7 +259,7 @@ func collectInputs(ctx *actions.Context, action *actions.Action) error {
 		case actions.InputTypeNumber:
 			var val string
 			var confirmed bool
-			baseDescription := input.Description
+			baseDescription := i18n.T(input.Description)
 			defaultVal := input.Default
 			if input.DefaultFunc != nil {
 				defaultVal = input.DefaultFunc(ctx)
@@ -278,8 +284,8 @@ func collectInputs(ctx *actions.Context, action *actions.Action) error {
 					}
 				}
 
-				val, confirmed, err = tui.RunInput(tui.InputConfig{
-					Title:       input.Label,
+				val, confirmed, err = prompt.RunInput(tui.InputConfig{
+					Title:       i18n.T(input.Label),
 					Description: description,
 					Placeholder: defaultVal,
 					Value:       defaultVal,
@@ -296,7 +302,7 @@ func collectInputs(ctx *actions.Context, action *actions.Action) error {
 				}
 				// Check if required and still empty
 				if input.Required && val == "" {
-					validationErr = fmt.Errorf("%s is required", input.Label)
+					validationErr = fmt.Errorf(i18n.T("%s is required"), i18n.T(input.Label))
 					continue
 				}
 				// Run custom validation if defined
@@ -320,7 +326,7 @@ func collectInputs(ctx *actions.Context, action *actions.Action) error {
 			for _, opt := range options {
 				label := opt.Label
 				if opt.Recommended {
-					label += " (Recommended)"
+					label += " " + i18n.T("(Recommended)")
 				}
 				tuiOptions = append(tuiOptions, tui.MenuOption{
 					Label: label,
@@ -330,17 +336,17 @@ func collectInputs(ctx *actions.Context, action *actions.Action) error {
 			// Add back option if not required
 			if !input.Required {
 				tuiOptions = append(tuiOptions, tui.MenuOption{
-					Label: "Skip",
+					Label: i18n.T("Skip"),
 					Value: "",
 				})
 			}
 			// Get description (static or dynamic)
-			selectDescription := input.Description
+			selectDescription := i18n.T(input.Description)
 			if input.DescriptionFunc != nil {
 				selectDescription = input.DescriptionFunc(ctx)
 			}
-			val, err := tui.RunMenu(tui.MenuConfig{
-				Title:       input.Label,
+			val, err := prompt.RunMenu(tui.MenuConfig{
+				Title:       i18n.T(input.Label),
 				Description: selectDescription,
 				Options:     tuiOptions,
 			})
@@ -391,11 +397,11 @@ func runPickerForAction(ctx *actions.Context, action *actions.Action) (string, e
 			Value: opt.Value,
 		})
 	}
-	tuiOptions = append(tuiOptions, tui.MenuOption{Label: "Back", Value: ""})
+	tuiOptions = append(tuiOptions, tui.MenuOption{Label: i18n.T("Back"), Value: ""})
 
 	// Show picker
-	selected, err := tui.RunMenu(tui.MenuConfig{
-		Title:   fmt.Sprintf("Select %s", action.Args.Name),
+	selected, err := prompt.RunMenu(tui.MenuConfig{
+		Title:   i18n.Tf("Select %s", action.Args.Name),
 		Options: tuiOptions,
 	})
 	if err != nil {
@@ -432,37 +438,37 @@ func RunSubmenu(parentID string) error {
 			}
 
 			options = append(options, tui.MenuOption{
-				Label: fmt.Sprintf("Mode: %s", modeName),
+				Label: i18n.Tf("Mode: %s", modeName),
 				Value: actions.ActionRouterMode,
 			})
 
 			// Switch Active is only relevant in single mode
 			if isSingleMode {
-				activeLabel := "Switch Active: (none)"
+				activeLabel := i18n.T("Switch Active: (none)")
 				if cfg != nil && cfg.Route.Active != "" {
-					activeLabel = fmt.Sprintf("Switch Active: %s", cfg.Route.Active)
+					activeLabel = i18n.Tf("Switch Active: %s", cfg.Route.Active)
 				}
 				options = append(options, tui.MenuOption{Label: activeLabel, Value: actions.ActionRouterSwitch})
 			}
 
 			options = append(options,
-				tui.MenuOption{Label: "Status", Value: actions.ActionRouterStatus},
-				tui.MenuOption{Label: "Start/Restart", Value: actions.ActionRouterStart},
-				tui.MenuOption{Label: "Stop", Value: actions.ActionRouterStop},
-				tui.MenuOption{Label: "Logs", Value: actions.ActionRouterLogs},
+				tui.MenuOption{Label: i18n.T("Status"), Value: actions.ActionRouterStatus},
+				tui.MenuOption{Label: i18n.T("Start/Restart"), Value: actions.ActionRouterStart},
+				tui.MenuOption{Label: i18n.T("Stop"), Value: actions.ActionRouterStop},
+				tui.MenuOption{Label: i18n.T("Logs"), Value: actions.ActionRouterLogs},
 			)
 		} else {
 			options = BuildMenuOptions(parentID)
 		}
 
-		options = append(options, tui.MenuOption{Label: "Back", Value: "back"})
+		options = append(options, tui.MenuOption{Label: i18n.T("Back"), Value: "back"})
 
-		title := action.MenuLabel
+		title := i18n.T(action.MenuLabel)
 		if title == "" {
-			title = action.Short
+			title = i18n.T(action.Short)
 		}
 
-		choice, err := tui.RunMenu(tui.MenuConfig{
+		choice, err := prompt.RunMenu(tui.MenuConfig{
 			Title:   title,
 			Options: options,
 		})
@@ -474,7 +480,7 @@ func RunSubmenu(parentID string) error {
 		childAction := actions.Get(choice)
 		if childAction != nil && childAction.IsSubmenu {
 			if err := RunSubmenu(choice); err != errCancelled {
-				tui.WaitForEnter()
+				prompt.WaitForEnter()
 			}
 			continue
 		}
@@ -484,11 +490,11 @@ func RunSubmenu(parentID string) error {
 			if err == errCancelled {
 				continue
 			}
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
 		} else {
 			// Skip WaitForEnter for actions that use TUI info view
 			if !isInfoViewAction(choice) {
-				tui.WaitForEnter()
+				prompt.WaitForEnter()
 			}
 		}
 	}
@@ -11,6 +11,8 @@ import (
 
 	"github.com/net2share/dnstm/internal/actions"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/i18n"
+	"github.com/net2share/dnstm/internal/prompt"
 	"github.com/net2share/dnstm/internal/router"
 	"github.com/net2share/dnstm/internal/transport"
 	"github.com/net2share/dnstm/internal/updater"
@@ -50,7 +52,10 @@ func InitTUI() {
 	Version = version.Version
 	BuildTime = version.BuildTime
 	tui.SetAppInfo("dnstm", version.Version, version.BuildTime)
-	tui.BeginSession()
+	// Plain mode prompts inline, without the alternate screen
+	if !prompt.IsPlain() {
+		tui.BeginSession()
+	}
 }
 
 // HasInteractiveMenu returns true if the action has a registered interactive submenu.
@@ -169,7 +174,7 @@ func formatUpdateBanner(report *updater.UpdateReport) string {
 			bu.Binary, current, bu.LatestVersion))
 	}
 
-	return fmt.Sprintf("Updates available: %s", strings.Join(parts, ", "))
+	return i18n.Tf("Updates available: %s", strings.Join(parts, ", "))
 }
 
 // buildTunnelSummary builds a summary string for the main menu header.
@@ -181,6 +186,7 @@ func buildTunnelSummary() string {
 
 	total := len(cfg.Tunnels)
 	running := 0
+	router.PrefetchStates(cfg.Tunnels)
 	for _, t := range cfg.Tunnels {
 		tunnel := router.NewTunnel(&t)
 		if tunnel.IsActive() {
@@ -189,9 +195,9 @@ func buildTunnelSummary() string {
 	}
 
 	if cfg.IsSingleMode() && cfg.Route.Active != "" {
-		return fmt.Sprintf("Tunnels: %d | Running: %d | Active: %s", total, running, cfg.Route.Active)
+		return i18n.Tf("Tunnels: %d | Running: %d | Active: %s", total, running, cfg.Route.Active)
 	}
-	return fmt.Sprintf("Tunnels: %d | Running: %d", total, running)
+	return i18n.Tf("Tunnels: %d | Running: %d", total, running)
 }
 
 func runMainMenu() error {
@@ -206,10 +212,10 @@ func runMainMenu() error {
 		if !installed {
 			// Not installed - show install option first and limited menu
 			missing := transport.GetMissingBinaries()
-			description = fmt.Sprintf("⚠ dnstm not installed\nMissing: %v", missing)
+			description = i18n.Tf("⚠ dnstm not installed\nMissing: %v", missing)
 
-			options = append(options, tui.MenuOption{Label: "Install (Required)", Value: actions.ActionInstall})
-			options = append(options, tui.MenuOption{Label: "Exit", Value: "exit"})
+			options = append(options, tui.MenuOption{Label: i18n.T("Install (Required)"), Value: actions.ActionInstall})
+			options = append(options, tui.MenuOption{Label: i18n.T("Exit"), Value: "exit"})
 		} else {
 			// Build tunnel summary for header
 			header = buildTunnelSummary()
@@ -220,19 +226,19 @@ func runMainMenu() error {
 			}
 
 			// Fully installed - show all options
-			options = append(options, tui.MenuOption{Label: "Tunnels →", Value: actions.ActionTunnel})
-			options = append(options, tui.MenuOption{Label: "Backends →", Value: actions.ActionBackend})
-			options = append(options, tui.MenuOption{Label: "Router →", Value: actions.ActionRouter})
-			options = append(options, tui.MenuOption{Label: "Update", Value: actions.ActionUpdate})
-			options = append(options, tui.MenuOption{Label: "Uninstall", Value: actions.ActionUninstall})
+			options = append(options, tui.MenuOption{Label: i18n.T("Tunnels") + " →", Value: actions.ActionTunnel})
+			options = append(options, tui.MenuOption{Label: i18n.T("Backends") + " →", Value: actions.ActionBackend})
+			options = append(options, tui.MenuOption{Label: i18n.T("Router") + " →", Value: actions.ActionRouter})
+			options = append(options, tui.MenuOption{Label: i18n.T("Update"), Value: actions.ActionUpdate})
+			options = append(options, tui.MenuOption{Label: i18n.T("Uninstall"), Value: actions.ActionUninstall})
 			options = append(options, tui.MenuOption{Label: "", Separator: true})
-			options = append(options, tui.MenuOption{Label: "External Tools", Separator: true})
-			options = append(options, tui.MenuOption{Label: "SSH Users ↗", Value: actions.ActionSSHUsers})
+			options = append(options, tui.MenuOption{Label: i18n.T("External Tools"), Separator: true})
+			options = append(options, tui.MenuOption{Label: i18n.T("SSH Users") + " ↗", Value: actions.ActionSSHUsers})
 			options = append(options, tui.MenuOption{Label: "", Separator: true})
-			options = append(options, tui.MenuOption{Label: "Exit", Value: "exit"})
+			options = append(options, tui.MenuOption{Label: i18n.T("Exit"), Value: "exit"})
 		}
 
-		choice, err := tui.RunMenu(tui.MenuConfig{
+		choice, err := prompt.RunMenu(tui.MenuConfig{
 			Header:      header,
 			Title:       "DNSTM",
 			Description: description,
@@ -251,7 +257,7 @@ func runMainMenu() error {
 			continue
 		}
 		if err != nil {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
 		}
 	}
 }
@@ -306,13 +312,14 @@ func handleMainMenuChoice(choice string) error {
 func runTunnelMenu() error {
 	for {
 		options := []tui.MenuOption{
-			{Label: "Add", Value: actions.ActionTunnelAdd},
+			{Label: i18n.T("Add"), Value: actions.ActionTunnelAdd},
 		}
 
 		// Load tunnels and show inline list
 		cfg, _ := config.Load()
 		if cfg != nil && len(cfg.Tunnels) > 0 {
 			options = append(options, tui.MenuOption{Separator: true})
+			router.PrefetchStates(cfg.Tunnels)
 			for _, t := range cfg.Tunnels {
 				tunnel := router.NewTunnel(&t)
 				status := "○"
@@ -326,10 +333,10 @@ func runTunnelMenu() error {
 		}
 
 		options = append(options, tui.MenuOption{Separator: true})
-		options = append(options, tui.MenuOption{Label: "Back", Value: "back"})
+		options = append(options, tui.MenuOption{Label: i18n.T("Back"), Value: "back"})
 
-		choice, err := tui.RunMenu(tui.MenuConfig{
-			Title:   "Tunnels",
+		choice, err := prompt.RunMenu(tui.MenuConfig{
+			Title:   i18n.T("Tunnels"),
 			Options: options,
 		})
 		if err != nil || choice == "" || choice == "back" {
@@ -340,13 +347,13 @@ func runTunnelMenu() error {
 		case choice == actions.ActionTunnelAdd:
 			if err := RunAction(actions.ActionTunnelAdd); err != nil {
 				if err != errCancelled {
-					_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
+					_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
 				}
 			}
 		case strings.HasPrefix(choice, "tunnel:"):
 			tag := strings.TrimPrefix(choice, "tunnel:")
 			if err := runTunnelManageMenu(tag); err != errCancelled {
-				tui.WaitForEnter()
+				prompt.WaitForEnter()
 			}
 		}
 	}
@@ -357,16 +364,17 @@ func runTunnelListMenu() error {
 	for {
 		cfg, err := config.Load()
 		if err != nil {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: "Failed to load config: " + err.Error()})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: i18n.Tf("Failed to load config: %v", err)})
 			return nil
 		}
 
 		if len(cfg.Tunnels) == 0 {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "info", Message: "No tunnels configured. Add one first."})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "info", Message: i18n.T("No tunnels configured. Add one first.")})
 			return errCancelled
 		}
 
 		var options []tui.MenuOption
+		router.PrefetchStates(cfg.Tunnels)
 		for _, t := range cfg.Tunnels {
 			tunnel := router.NewTunnel(&t)
 			status := "○"
@@ -377,10 +385,10 @@ func runTunnelListMenu() error {
 			label := fmt.Sprintf("%s %s (%s → %s)", status, t.Tag, transportName, t.Backend)
 			options = append(options, tui.MenuOption{Label: label, Value: t.Tag})
 		}
-		options = append(options, tui.MenuOption{Label: "Back", Value: "back"})
+		options = append(options, tui.MenuOption{Label: i18n.T("Back"), Value: "back"})
 
-		selected, err := tui.RunMenu(tui.MenuConfig{
-			Title:   "Select Tunnel",
+		selected, err := prompt.RunMenu(tui.MenuConfig{
+			Title:   i18n.T("Select Tunnel"),
 			Options: options,
 		})
 		if err != nil || selected == "" || selected == "back" {
@@ -388,7 +396,7 @@ func runTunnelListMenu() error {
 		}
 
 		if err := runTunnelManageMenu(selected); err != errCancelled {
-			tui.WaitForEnter()
+			prompt.WaitForEnter()
 		}
 	}
 }
@@ -398,53 +406,69 @@ func runTunnelManageMenu(tag string) error {
 	for {
 		cfg, err := config.Load()
 		if err != nil {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: "Failed to load config: " + err.Error()})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: i18n.Tf("Failed to load config: %v", err)})
 			return nil
 		}
 
 		tunnelCfg := cfg.GetTunnelByTag(tag)
 		if tunnelCfg == nil {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: fmt.Sprintf("Tunnel '%s' not found", tag)})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: i18n.Tf("Tunnel '%s' not found", tag)})
 			return nil
 		}
 
 		tunnel := router.NewTunnel(tunnelCfg)
-		status := "Stopped"
+		status := i18n.T("Stopped")
 		if tunnel.IsActive() {
-			status = "Running"
+			status = i18n.T("Running")
 		}
 
 		isRunning := tunnel.IsActive()
+		isQuarantined := !isRunning && tunnel.IsQuarantined()
+		if isQuarantined {
+			status = i18n.T("Quarantined")
+		}
 
 		// Build context-aware options
 		options := []tui.MenuOption{
-			{Label: "Status", Value: "status"},
-			{Label: "Share", Value: "share"},
-			{Label: "Logs", Value: "logs"},
+			{Label: i18n.T("Status"), Value: "status"},
+			{Label: i18n.T("Share"), Value: "share"},
+			{Label: i18n.T("Logs"), Value: "logs"},
 		}
 
 		// Only show start/stop/restart for active tunnel (single mode) or any tunnel (multi mode)
 		canManage := cfg.IsMultiMode() || (cfg.IsSingleMode() && cfg.Route.Active == tag)
-		if canManage {
+		if isQuarantined {
+			options = append(options,
+				tui.MenuOption{Label: i18n.T("Unquarantine"), Value: "unquarantine"},
+			)
+		} else if canManage {
 			if isRunning {
 				options = append(options,
-					tui.MenuOption{Label: "Restart", Value: "restart"},
-					tui.MenuOption{Label: "Stop", Value: "stop"},
+					tui.MenuOption{Label: i18n.T("Restart"), Value: "restart"},
+					tui.MenuOption{Label: i18n.T("Stop"), Value: "stop"},
 				)
 			} else {
 				options = append(options,
-					tui.MenuOption{Label: "Start", Value: "start"},
+					tui.MenuOption{Label: i18n.T("Start"), Value: "start"},
 				)
 			}
 		}
 
+		watchdogLabel := i18n.T("Watchdog: Off")
+		if tunnelCfg.Watchdog != nil {
+			if d, err := tunnelCfg.Watchdog.TimeoutDuration(); err == nil {
+				watchdogLabel = i18n.Tf("Watchdog: %s", d)
+			}
+		}
+
 		options = append(options,
-			tui.MenuOption{Label: "Remove", Value: "remove"},
-			tui.MenuOption{Label: "Back", Value: "back"},
+			tui.MenuOption{Label: watchdogLabel, Value: "watchdog"},
+			tui.MenuOption{Label: i18n.T("Remove"), Value: "remove"},
+			tui.MenuOption{Label: i18n.T("Back"), Value: "back"},
 		)
 
 		transportName := config.GetTransportTypeDisplayName(tunnelCfg.Transport)
-		choice, err := tui.RunMenu(tui.MenuConfig{
+		choice, err := prompt.RunMenu(tui.MenuConfig{
 			Title:       fmt.Sprintf("%s (%s)", tag, status),
 			Description: fmt.Sprintf("%s → %s:%d", transportName, tunnelCfg.Domain, tunnelCfg.Port),
 			Options:     options,
@@ -459,7 +483,7 @@ func runTunnelManageMenu(tag string) error {
 			if err == errCancelled {
 				continue
 			}
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
 		} else {
 			// Check if tunnel was removed
 			if choice == "remove" {
@@ -467,7 +491,7 @@ func runTunnelManageMenu(tag string) error {
 			}
 			// Skip WaitForEnter for actions that use TUI info/progress view
 			if !isInfoViewAction(actionID) {
-				tui.WaitForEnter()
+				prompt.WaitForEnter()
 			}
 		}
 	}
@@ -478,7 +502,8 @@ func runTunnelAction(actionID, tunnelTag string) error {
 	// Special handling for actions that need the tunnel tag
 	switch actionID {
 	case actions.ActionTunnelStatus, actions.ActionTunnelShare, actions.ActionTunnelLogs,
-		actions.ActionTunnelStart, actions.ActionTunnelStop, actions.ActionTunnelRestart, actions.ActionTunnelRemove:
+		actions.ActionTunnelStart, actions.ActionTunnelStop, actions.ActionTunnelRestart, actions.ActionTunnelRemove,
+		actions.ActionTunnelWatchdog, actions.ActionTunnelUnquarantine:
 		return runActionWithArgs(actionID, []string{tunnelTag})
 	default:
 		return RunAction(actionID)
@@ -505,7 +530,7 @@ func runActionWithArgs(actionID string, args []string) error {
 		return err
 	}
 
-	if err := handleConfirmation(action, argValue); err != nil {
+	if err := handleConfirmation(ctx, action, argValue); err != nil {
 		return err
 	}
 
@@ -520,7 +545,7 @@ func runActionWithArgs(actionID string, args []string) error {
 func runBackendMenu() error {
 	for {
 		options := []tui.MenuOption{
-			{Label: "Add", Value: actions.ActionBackendAdd},
+			{Label: i18n.T("Add"), Value: actions.ActionBackendAdd},
 		}
 
 		// Load backends and show inline list
@@ -539,10 +564,10 @@ func runBackendMenu() error {
 		}
 
 		options = append(options, tui.MenuOption{Separator: true})
-		options = append(options, tui.MenuOption{Label: "Back", Value: "back"})
+		options = append(options, tui.MenuOption{Label: i18n.T("Back"), Value: "back"})
 
-		choice, err := tui.RunMenu(tui.MenuConfig{
-			Title:   "Backends",
+		choice, err := prompt.RunMenu(tui.MenuConfig{
+			Title:   i18n.T("Backends"),
 			Options: options,
 		})
 		if err != nil || choice == "" || choice == "back" {
@@ -553,15 +578,15 @@ func runBackendMenu() error {
 		case choice == actions.ActionBackendAdd:
 			if err := RunAction(actions.ActionBackendAdd); err != nil {
 				if err != errCancelled {
-					_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
+					_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
 				}
 			} else if !isInfoViewAction(actions.ActionBackendAdd) {
-				tui.WaitForEnter()
+				prompt.WaitForEnter()
 			}
 		case strings.HasPrefix(choice, "backend:"):
 			tag := strings.TrimPrefix(choice, "backend:")
 			if err := runBackendManageMenu(tag); err != errCancelled {
-				tui.WaitForEnter()
+				prompt.WaitForEnter()
 			}
 		}
 	}
@@ -572,12 +597,12 @@ func runBackendListMenu() error {
 	for {
 		cfg, err := config.Load()
 		if err != nil {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: "Failed to load config: " + err.Error()})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: i18n.Tf("Failed to load config: %v", err)})
 			return nil
 		}
 
 		if len(cfg.Backends) == 0 {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "info", Message: "No backends configured. Add one first."})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "info", Message: i18n.T("No backends configured. Add one first.")})
 			return errCancelled
 		}
 
@@ -591,10 +616,10 @@ func runBackendListMenu() error {
 			label := fmt.Sprintf("%s (%s)%s", b.Tag, typeName, status)
 			options = append(options, tui.MenuOption{Label: label, Value: b.Tag})
 		}
-		options = append(options, tui.MenuOption{Label: "Back", Value: "back"})
+		options = append(options, tui.MenuOption{Label: i18n.T("Back"), Value: "back"})
 
-		selected, err := tui.RunMenu(tui.MenuConfig{
-			Title:   "Select Backend",
+		selected, err := prompt.RunMenu(tui.MenuConfig{
+			Title:   i18n.T("Select Backend"),
 			Options: options,
 		})
 		if err != nil || selected == "" || selected == "back" {
@@ -602,7 +627,7 @@ func runBackendListMenu() error {
 		}
 
 		if err := runBackendManageMenu(selected); err != errCancelled {
-			tui.WaitForEnter()
+			prompt.WaitForEnter()
 		}
 	}
 }
@@ -612,39 +637,57 @@ func runBackendManageMenu(tag string) error {
 	for {
 		cfg, err := config.Load()
 		if err != nil {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: "Failed to load config: " + err.Error()})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: i18n.Tf("Failed to load config: %v", err)})
 			return nil
 		}
 
 		backend := cfg.GetBackendByTag(tag)
 		if backend == nil {
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: fmt.Sprintf("Backend '%s' not found", tag)})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: i18n.Tf("Backend '%s' not found", tag)})
 			return nil
 		}
 
 		typeName := config.GetBackendTypeDisplayName(backend.Type)
 
 		options := []tui.MenuOption{
-			{Label: "Status", Value: "status"},
+			{Label: i18n.T("Status"), Value: "status"},
 		}
 
 		// Show Authentication option for SOCKS backends
 		if backend.Type == config.BackendSOCKS {
-			authLabel := "Authentication: Disabled"
+			authLabel := i18n.T("Authentication: Disabled")
 			if backend.HasSocksAuth() {
-				authLabel = fmt.Sprintf("Authentication: %s", backend.Socks.User)
+				authLabel = i18n.Tf("Authentication: %s", backend.Socks.User)
 			}
 			options = append(options, tui.MenuOption{Label: authLabel, Value: "auth"})
+
+			egressLabel := i18n.T("Egress Rules: None")
+			if backend.Egress != nil && len(backend.Egress.Rules) > 0 {
+				egressLabel = i18n.Tf("Egress Rules: %d", len(backend.Egress.Rules))
+			}
+			options = append(options, tui.MenuOption{Label: egressLabel, Value: "egress"})
+		}
+
+		// Show Outbound option for backends that open outgoing connections
+		if backend.Type == config.BackendSOCKS || backend.Type == config.BackendShadowsocks {
+			outboundLabel := i18n.T("Outbound: Default")
+			if o := backend.Outbound; o != nil {
+				outboundLabel = i18n.Tf("Outbound: %s", o.Interface)
+				if o.Interface == "" {
+					outboundLabel = i18n.Tf("Outbound: %s", o.SourceIP)
+				}
+			}
+			options = append(options, tui.MenuOption{Label: outboundLabel, Value: "outbound"})
 		}
 
 		// Only show Remove for non-built-in backends
 		if !backend.IsBuiltIn() {
-			options = append(options, tui.MenuOption{Label: "Remove", Value: "remove"})
+			options = append(options, tui.MenuOption{Label: i18n.T("Remove"), Value: "remove"})
 		}
 
-		options = append(options, tui.MenuOption{Label: "Back", Value: "back"})
+		options = append(options, tui.MenuOption{Label: i18n.T("Back"), Value: "back"})
 
-		choice, err := tui.RunMenu(tui.MenuConfig{
+		choice, err := prompt.RunMenu(tui.MenuConfig{
 			Title:       fmt.Sprintf("%s (%s)", tag, typeName),
 			Description: getBackendDescription(backend),
 			Options:     options,
@@ -655,7 +698,7 @@ func runBackendManageMenu(tag string) error {
 
 		if choice == "auth" {
 			if err := runBackendAuthMenu(tag, backend); err != nil && err != errCancelled {
-				_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
+				_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
 			}
 			continue
 		}
@@ -666,7 +709,7 @@ func runBackendManageMenu(tag string) error {
 			if err == errCancelled {
 				continue
 			}
-			_ = tui.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
+			_ = prompt.ShowMessage(tui.AppMessage{Type: "error", Message: err.Error()})
 		} else {
 			// Check if backend was removed
 			if choice == "remove" {
@@ -674,7 +717,7 @@ func runBackendManageMenu(tag string) error {
 			}
 			// Skip WaitForEnter for actions that use TUI info/progress view
 			if !isInfoViewAction(actionID) {
-				tui.WaitForEnter()
+				prompt.WaitForEnter()
 			}
 		}
 	}
@@ -696,19 +739,19 @@ func runBackendAuthMenu(tag string, backend *config.BackendConfig) error {
 	var options []tui.MenuOption
 	if backend.HasSocksAuth() {
 		options = []tui.MenuOption{
-			{Label: "Change credentials", Value: "change"},
-			{Label: "Disable", Value: "disable"},
-			{Label: "Back", Value: "back"},
+			{Label: i18n.T("Change credentials"), Value: "change"},
+			{Label: i18n.T("Disable"), Value: "disable"},
+			{Label: i18n.T("Back"), Value: "back"},
 		}
 	} else {
 		options = []tui.MenuOption{
-			{Label: "Enable", Value: "enable"},
-			{Label: "Back", Value: "back"},
+			{Label: i18n.T("Enable"), Value: "enable"},
+			{Label: i18n.T("Back"), Value: "back"},
 		}
 	}
 
-	choice, err := tui.RunMenu(tui.MenuConfig{
-		Title:   "Authentication",
+	choice, err := prompt.RunMenu(tui.MenuConfig{
+		Title:   i18n.T("Authentication"),
 		Options: options,
 	})
 	if err != nil || choice == "" || choice == "back" {
@@ -735,7 +778,7 @@ func runBackendAuthMenu(tag string, backend *config.BackendConfig) error {
 // runBackendAction runs a backend action with the given tag as argument.
 func runBackendAction(actionID, backendTag string) error {
 	switch actionID {
-	case actions.ActionBackendStatus, actions.ActionBackendRemove, actions.ActionBackendAuth:
+	case actions.ActionBackendStatus, actions.ActionBackendRemove, actions.ActionBackendAuth, actions.ActionBackendEgress, actions.ActionBackendOutbound:
 		return runActionWithArgs(actionID, []string{backendTag})
 	default:
 		return RunAction(actionID)
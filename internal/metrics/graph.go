@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// maxGap is the longest interval between two samples a counter's increase
+// is attributed to; over longer gaps the collector was not running and
+// the rate is unknown.
+const maxGap = 10 * time.Minute
+
+// sparks are the levels of a sparkline, lowest first.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Series reduces one series to width points evenly spread between from and
+// to. A gauge is averaged over each point's interval; a counter becomes its
+// rate per second, with a drop in value read as a reset to zero. Points
+// without data are NaN.
+func Series(samples []Sample, name string, from, to time.Time, width int) []float64 {
+	sums := make([]float64, width)
+	weights := make([]float64, width)
+	step := to.Sub(from) / time.Duration(width)
+	if step <= 0 {
+		step = 1
+	}
+	bucket := func(t time.Time) int {
+		i := int(t.Sub(from) / step)
+		if i >= width {
+			i = width - 1
+		}
+		return i
+	}
+
+	counter := IsCounter(name)
+	var prev float64
+	var prevTime time.Time
+	for _, s := range samples {
+		v, ok := s.Values[name]
+		if !ok || s.Time.Before(from) || s.Time.After(to) {
+			continue
+		}
+		i := bucket(s.Time)
+		if !counter {
+			sums[i] += v
+			weights[i]++
+			continue
+		}
+		if dt := s.Time.Sub(prevTime); !prevTime.IsZero() && dt > 0 && dt <= maxGap {
+			delta := v - prev
+			if delta < 0 {
+				delta = v
+			}
+			sums[i] += delta
+			weights[i] += dt.Seconds()
+		}
+		prev, prevTime = v, s.Time
+	}
+
+	points := make([]float64, width)
+	for i := range points {
+		if weights[i] == 0 {
+			points[i] = math.NaN()
+		} else {
+			points[i] = sums[i] / weights[i]
+		}
+	}
+	return points
+}
+
+// Sparkline draws points scaled from zero to their maximum, one character
+// each. Points without data are left blank.
+func Sparkline(points []float64) string {
+	max := 0.0
+	for _, p := range points {
+		if !math.IsNaN(p) && p > max {
+			max = p
+		}
+	}
+	var b strings.Builder
+	for _, p := range points {
+		switch {
+		case math.IsNaN(p):
+			b.WriteRune(' ')
+		case max == 0:
+			b.WriteRune(sparks[0])
+		default:
+			level := int(p / max * float64(len(sparks)-1))
+			if level < 0 {
+				level = 0
+			}
+			b.WriteRune(sparks[level])
+		}
+	}
+	return b.String()
+}
+
+// Summary returns the lowest, average and highest of the points that have
+// data, and false if none has.
+func Summary(points []float64) (low, avg, high float64, ok bool) {
+	n := 0
+	for _, p := range points {
+		if math.IsNaN(p) {
+			continue
+		}
+		if n == 0 || p < low {
+			low = p
+		}
+		if n == 0 || p > high {
+			high = p
+		}
+		avg += p
+		n++
+	}
+	if n == 0 {
+		return 0, 0, 0, false
+	}
+	return low, avg / float64(n), high, true
+}
@@ -0,0 +1,193 @@
+// Package metrics records what dnstm can observe about its services in a
+// local time-series store, so their history can be shown without an
+// external monitoring system.
+//
+// The metrics timer runs "dnstm stats collect" every minute. Each run
+// appends one Sample, the current value of every series, to a file per
+// day under Dir; files older than the retention are deleted. Series are
+// named after what they measure:
+//
+//	up.<tag>        1 while the tunnel's service runs, 0 otherwise
+//	up.dnsrouter    the same for the DNS router (multi mode)
+//	queries         queries received by the DNS router
+//	queries.<tag>   queries the DNS router forwarded to the tunnel
+//	bytes.<tag>     traffic sent by the tunnel this month (usage accounting)
+//	conns.socks     connections open in the built-in SOCKS proxy
+//	relayed.socks   bytes the built-in SOCKS proxy relayed
+//
+// "queries", "bytes" and "relayed" series are counters; see IsCounter.
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// Dir holds one file of samples per day.
+var Dir = "/var/lib/dnstm/metrics"
+
+// TimerName is the name of the collector timer and service.
+var TimerName = paths.SystemService("metrics")
+
+// DayLayout is the time layout of the day a sample file covers.
+const DayLayout = "2006-01-02"
+
+// Series name prefixes.
+const (
+	SeriesUp      = "up"
+	SeriesQueries = "queries"
+	SeriesBytes   = "bytes"
+	SeriesConns   = "conns"
+	SeriesRelayed = "relayed"
+)
+
+// Sample is the value of each series at one time.
+type Sample struct {
+	Time   time.Time          `json:"t"`
+	Values map[string]float64 `json:"v"`
+}
+
+// Name returns the name of the series kind for one tunnel, or of the
+// kind's total when tag is empty.
+func Name(kind, tag string) string {
+	if tag == "" {
+		return kind
+	}
+	return kind + "." + tag
+}
+
+// IsCounter reports whether a series only grows, apart from resets, so
+// that its rate rather than its value is of interest.
+func IsCounter(name string) bool {
+	kind, _, _ := strings.Cut(name, ".")
+	return kind == SeriesQueries || kind == SeriesBytes || kind == SeriesRelayed
+}
+
+func dayPath(day time.Time) string {
+	return filepath.Join(Dir, day.Format(DayLayout)+".jsonl")
+}
+
+// Append adds s to the file of its day.
+func Append(s Sample) error {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dayPath(s.Time), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open metrics file: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return f.Close()
+}
+
+// Load returns the samples taken between from and to, oldest first. Lines
+// that cannot be parsed, such as one cut short by a crash, are skipped.
+func Load(from, to time.Time) ([]Sample, error) {
+	var samples []Sample
+	for _, day := range days() {
+		if day.AddDate(0, 0, 1).Before(from) || day.After(to) {
+			continue
+		}
+		f, err := os.Open(dayPath(day))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metrics: %w", err)
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			var s Sample
+			if json.Unmarshal(sc.Bytes(), &s) != nil {
+				continue
+			}
+			if !s.Time.Before(from) && !s.Time.After(to) {
+				samples = append(samples, s)
+			}
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dayPath(day), err)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	return samples, nil
+}
+
+// Prune deletes the files whose samples are all older than retention.
+func Prune(retention time.Duration, now time.Time) error {
+	cutoff := now.Add(-retention)
+	for _, day := range days() {
+		if day.AddDate(0, 0, 1).Before(cutoff) {
+			if err := os.Remove(dayPath(day)); err != nil {
+				return fmt.Errorf("failed to remove old metrics: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// days returns the days that have a sample file, oldest first. Days are in
+// local time, like the file names.
+func days() []time.Time {
+	files, _ := filepath.Glob(filepath.Join(Dir, "*.jsonl"))
+	var days []time.Time
+	for _, f := range files {
+		day, err := time.ParseInLocation(DayLayout, strings.TrimSuffix(filepath.Base(f), ".jsonl"), time.Local)
+		if err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// Names returns the series found in samples, sorted.
+func Names(samples []Sample) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, s := range samples {
+		for name := range s.Values {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SyncTimer installs the collector timer while metrics are on, and removes
+// it once they are off.
+func SyncTimer(cfg *config.Config) error {
+	if cfg.Metrics != nil {
+		return service.CreateTimer(&service.TimerConfig{
+			Name:        TimerName,
+			Description: "dnstm metrics",
+			ExecStart:   cmdline.Join(paths.Bin("dnstm"), "stats", "collect"),
+			OnCalendar:  "*:*:00",
+		})
+	}
+	if service.IsTimerInstalled(TimerName) {
+		return service.RemoveTimer(TimerName)
+	}
+	return nil
+}
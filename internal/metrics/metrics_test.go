@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func useTempDir(t *testing.T) {
+	t.Helper()
+	orig := Dir
+	Dir = t.TempDir()
+	t.Cleanup(func() { Dir = orig })
+}
+
+func TestAppendLoadPrune(t *testing.T) {
+	useTempDir(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+
+	for _, at := range []time.Time{now.AddDate(0, 0, -40), now.AddDate(0, 0, -1), now.Add(-time.Minute), now} {
+		if err := Append(Sample{Time: at, Values: map[string]float64{"up.slip1": 1}}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	// A line cut short is skipped
+	f, _ := os.OpenFile(dayPath(now), os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"t":"2026-10-16T12:0`)
+	f.Close()
+
+	got, err := Load(now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(got) != 2 || !got[1].Time.Equal(now) {
+		t.Fatalf("Load() = %v, want the 2 samples of the last hour", got)
+	}
+
+	if err := Prune(30*24*time.Hour, now); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(Dir, "*.jsonl"))
+	if len(files) != 2 {
+		t.Errorf("Prune() left %v, want the files of the last 2 days", files)
+	}
+}
+
+func TestSeries(t *testing.T) {
+	from := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	var samples []Sample
+	for i := 0; i < 4; i++ {
+		samples = append(samples, Sample{
+			Time: from.Add(time.Duration(i) * time.Minute),
+			// The counter resets between the third and fourth sample
+			Values: map[string]float64{"up.slip1": float64(i % 2), "queries": []float64{0, 60, 180, 30}[i]},
+		})
+	}
+	to := from.Add(4 * time.Minute)
+
+	up := Series(samples, "up.slip1", from, to, 2)
+	if up[0] != 0.5 || up[1] != 0.5 {
+		t.Errorf("gauge = %v, want [0.5 0.5]", up)
+	}
+
+	qps := Series(samples, "queries", from, to, 2)
+	if qps[0] != 1 || qps[1] != 1.25 {
+		t.Errorf("counter = %v, want [1 1.25]", qps)
+	}
+
+	empty := Series(samples, "bytes.slip1", from, to, 2)
+	if !math.IsNaN(empty[0]) || !math.IsNaN(empty[1]) {
+		t.Errorf("missing series = %v, want NaN", empty)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]float64{0, 1, 2, math.NaN(), 4}); got != "▁▂▄ █" {
+		t.Errorf("Sparkline() = %q", got)
+	}
+	if got := Sparkline([]float64{0, 0}); got != "▁▁" {
+		t.Errorf("Sparkline() of zeros = %q", got)
+	}
+	low, avg, high, ok := Summary([]float64{math.NaN(), 1, 3})
+	if !ok || low != 1 || avg != 2 || high != 3 {
+		t.Errorf("Summary() = %v, %v, %v, %v", low, avg, high, ok)
+	}
+}
@@ -0,0 +1,240 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// DNSServer is a DNS server that commonly occupies port 53.
+type DNSServer struct {
+	Name      string
+	Units     []string // systemd units it usually runs as
+	Processes []string // process names, matched against the truncated names ss shows
+}
+
+// KnownDNSServers lists the DNS servers recognised when scanning for port 53 conflicts.
+var KnownDNSServers = []DNSServer{
+	{Name: "BIND", Units: []string{"named", "bind9"}, Processes: []string{"named"}},
+	{Name: "Unbound", Units: []string{"unbound"}, Processes: []string{"unbound"}},
+	{Name: "Pi-hole", Units: []string{"pihole-FTL"}, Processes: []string{"pihole-FTL"}},
+	{Name: "dnsmasq", Units: []string{"dnsmasq"}, Processes: []string{"dnsmasq"}},
+	{Name: "AdGuard Home", Units: []string{"AdGuardHome"}, Processes: []string{"AdGuardHome"}},
+	{Name: "systemd-resolved", Units: []string{"systemd-resolved"}, Processes: []string{"systemd-resolved"}},
+}
+
+// DNSConflict is a process or service other than dnstm that holds port 53
+// on the address dnstm binds.
+type DNSConflict struct {
+	Name    string   // Display name, e.g. "Unbound", or the process name if unknown
+	Unit    string   // systemd unit without ".service", empty if not run by systemd
+	Process string   // Process name as shown by ss
+	PID     int      // 0 if ss could not tell the owner
+	Addrs   []string // Listening addresses, e.g. "udp 0.0.0.0:53"
+}
+
+// String returns a one-line description of the conflict.
+func (c DNSConflict) String() string {
+	s := c.Name
+	if c.Unit != "" {
+		s += fmt.Sprintf(" (%s.service)", c.Unit)
+	} else if c.PID != 0 {
+		s += fmt.Sprintf(" (pid %d)", c.PID)
+	}
+	if len(c.Addrs) > 0 {
+		s += " on " + strings.Join(c.Addrs, ", ")
+	}
+	return s
+}
+
+// ownProcesses are the processes dnstm itself binds port 53 with.
+var ownProcesses = []string{"dnstm", "dnstt-server", "slipstream-server", "vaydns-server", "ssserver"}
+
+// commLen is the length of the process name the kernel keeps, and ss shows:
+// slipstream-server appears as "slipstream-serv".
+const commLen = 15
+
+// sameProcess reports whether the process name shown by ss is name.
+func sameProcess(shown, name string) bool {
+	if len(name) > commLen {
+		name = name[:commLen]
+	}
+	return shown == name
+}
+
+// isOwnProcess reports whether a process shown by ss is one of dnstm's.
+func isOwnProcess(shown string) bool {
+	for _, p := range ownProcesses {
+		if sameProcess(shown, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// socketListener is a port 53 listener parsed from ss output.
+type socketListener struct {
+	proto   string
+	addr    string
+	process string
+	pid     int
+}
+
+var ssUsersRe = regexp.MustCompile(`\("([^"]+)",pid=(\d+)`)
+
+// parseSS parses `ss -Hlnp` output for listeners on port 53.
+func parseSS(proto, output string) []socketListener {
+	var listeners []socketListener
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		local := fields[3]
+		if !strings.HasSuffix(local, ":53") {
+			continue
+		}
+		// Drop the interface suffix of addresses like 127.0.0.53%lo:53
+		if i := strings.Index(local, "%"); i >= 0 {
+			local = local[:i] + ":53"
+		}
+		matches := ssUsersRe.FindAllStringSubmatch(line, -1)
+		if len(matches) == 0 {
+			listeners = append(listeners, socketListener{proto: proto, addr: local})
+			continue
+		}
+		for _, m := range matches {
+			pid, _ := strconv.Atoi(m[2])
+			listeners = append(listeners, socketListener{proto: proto, addr: local, process: m[1], pid: pid})
+		}
+	}
+	return listeners
+}
+
+// isResolvedStub reports whether addr is the systemd-resolved stub listener,
+// which only binds loopback and does not block dnstm.
+func isResolvedStub(addr string) bool {
+	return strings.HasPrefix(addr, "127.0.0.53:") || strings.HasPrefix(addr, "127.0.0.54:")
+}
+
+// isWildcard reports whether host is the any address, as ss prints it.
+func isWildcard(host string) bool {
+	if host == "*" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// blocksBind reports whether a listener on addr stops dnstm from binding
+// port 53 on bindHost: a listener on the any address blocks every bind, and
+// a bind on the any address is blocked by every listener.
+func blocksBind(addr, bindHost string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return true
+	}
+	if isWildcard(host) || isWildcard(bindHost) {
+		return true
+	}
+	ip, bindIP := net.ParseIP(host), net.ParseIP(bindHost)
+	if ip == nil || bindIP == nil {
+		return host == bindHost
+	}
+	return ip.Equal(bindIP)
+}
+
+// unitForPID returns the systemd service a process belongs to.
+func unitForPID(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, part := range strings.Split(line, "/") {
+			if strings.HasSuffix(part, ".service") {
+				return strings.TrimSuffix(part, ".service")
+			}
+		}
+	}
+	return ""
+}
+
+// knownServer returns the known DNS server matching a unit or process name.
+func knownServer(unit, process string) *DNSServer {
+	for i := range KnownDNSServers {
+		s := &KnownDNSServers[i]
+		for _, u := range s.Units {
+			if u == unit {
+				return s
+			}
+		}
+		for _, p := range s.Processes {
+			if sameProcess(process, p) {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// DetectDNSConflicts scans listening sockets for other DNS servers that would
+// prevent dnstm from binding port 53 on bindHost. Listeners on other
+// addresses, dnstm's own services and the systemd-resolved loopback stub are
+// not reported.
+func DetectDNSConflicts(bindHost string) []DNSConflict {
+	var listeners []socketListener
+	for _, p := range []struct{ proto, flag string }{{"udp", "-u"}, {"tcp", "-t"}} {
+		output, err := exec.Command("ss", "-Hlnp", p.flag, "sport = :53").Output()
+		if err != nil {
+			continue
+		}
+		listeners = append(listeners, parseSS(p.proto, string(output))...)
+	}
+	return collectConflicts(listeners, bindHost, unitForPID)
+}
+
+// collectConflicts groups the listeners that block bindHost by owner.
+func collectConflicts(listeners []socketListener, bindHost string, unitOf func(int) string) []DNSConflict {
+	var conflicts []DNSConflict
+	index := make(map[string]int)
+
+	for _, l := range listeners {
+		if isResolvedStub(l.addr) || isOwnProcess(l.process) || !blocksBind(l.addr, bindHost) {
+			continue
+		}
+		unit := ""
+		if l.pid != 0 {
+			unit = unitOf(l.pid)
+		}
+		if strings.HasPrefix(unit, paths.ServicePrefix) {
+			continue
+		}
+
+		key := unit
+		if key == "" {
+			key = fmt.Sprintf("pid:%d", l.pid)
+		}
+		i, ok := index[key]
+		if !ok {
+			name := l.process
+			if s := knownServer(unit, l.process); s != nil {
+				name = s.Name
+			}
+			if name == "" {
+				name = "unknown process"
+			}
+			conflicts = append(conflicts, DNSConflict{Name: name, Unit: unit, Process: l.process, PID: l.pid})
+			i = len(conflicts) - 1
+			index[key] = i
+		}
+		conflicts[i].Addrs = append(conflicts[i].Addrs, l.proto+" "+l.addr)
+	}
+	return conflicts
+}
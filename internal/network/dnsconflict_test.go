@@ -0,0 +1,82 @@
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+const ssUDPOutput = `UNCONN 0      0      127.0.0.53%lo:53        0.0.0.0:*    users:(("systemd-resolve",pid=512,fd=13))
+UNCONN 0      0            0.0.0.0:53        0.0.0.0:*    users:(("unbound",pid=900,fd=5))
+UNCONN 0      0        203.0.113.5:53        0.0.0.0:*    users:(("dnstt-server",pid=1200,fd=7))
+UNCONN 0      0          127.0.0.1:5310      0.0.0.0:*    users:(("slipstream-serv",pid=1300,fd=7))
+UNCONN 0      0               [::]:53           [::]:*    users:(("dnsmasq",pid=777,fd=4))
+`
+
+func TestParseSS(t *testing.T) {
+	listeners := parseSS("udp", ssUDPOutput)
+	if len(listeners) != 4 {
+		t.Fatalf("got %d listeners, want 4: %+v", len(listeners), listeners)
+	}
+	if listeners[0].addr != "127.0.0.53:53" || listeners[0].process != "systemd-resolve" || listeners[0].pid != 512 {
+		t.Errorf("listener[0] = %+v", listeners[0])
+	}
+	if listeners[3].addr != "[::]:53" || listeners[3].pid != 777 {
+		t.Errorf("listener[3] = %+v", listeners[3])
+	}
+}
+
+func TestCollectConflicts(t *testing.T) {
+	listeners := parseSS("udp", ssUDPOutput)
+	listeners = append(listeners,
+		socketListener{proto: "tcp", addr: "0.0.0.0:53", process: "unbound", pid: 900},
+		socketListener{proto: "udp", addr: "203.0.113.5:53", process: "slipstream-serv", pid: 1300},
+		socketListener{proto: "udp", addr: "10.0.0.5:53", process: "named", pid: 1400},
+	)
+
+	units := map[int]string{512: "systemd-resolved", 900: "unbound", 1200: "dnstm-t1", 1400: "named"}
+	unitOf := func(pid int) string { return units[pid] }
+
+	tests := []struct {
+		bindHost string
+		want     []string
+	}{
+		{"203.0.113.5", []string{
+			"Unbound (unbound.service) on udp 0.0.0.0:53, tcp 0.0.0.0:53",
+			"dnsmasq (pid 777) on udp [::]:53",
+		}},
+		{"0.0.0.0", []string{
+			"Unbound (unbound.service) on udp 0.0.0.0:53, tcp 0.0.0.0:53",
+			"dnsmasq (pid 777) on udp [::]:53",
+			"BIND (named.service) on udp 10.0.0.5:53",
+		}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, c := range collectConflicts(listeners, tt.bindHost, unitOf) {
+			got = append(got, c.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("bind %s, conflicts:\n%s\nwant:\n%s", tt.bindHost, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestBlocksBind(t *testing.T) {
+	tests := []struct {
+		addr, bindHost string
+		want           bool
+	}{
+		{"0.0.0.0:53", "203.0.113.5", true},
+		{"[::]:53", "203.0.113.5", true},
+		{"*:53", "203.0.113.5", true},
+		{"203.0.113.5:53", "203.0.113.5", true},
+		{"10.0.0.5:53", "203.0.113.5", false},
+		{"127.0.0.1:53", "203.0.113.5", false},
+		{"10.0.0.5:53", "0.0.0.0", true},
+	}
+	for _, tt := range tests {
+		if got := blocksBind(tt.addr, tt.bindHost); got != tt.want {
+			t.Errorf("blocksBind(%q, %q) = %v, want %v", tt.addr, tt.bindHost, got, tt.want)
+		}
+	}
+}
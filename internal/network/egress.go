@@ -0,0 +1,42 @@
+package network
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/egress"
+)
+
+// ApplyEgressRules installs nftables rules restricting outbound connections
+// opened by the given user. With no rules the dnstm egress table is removed.
+func ApplyEgressRules(user string, rules []egress.Rule) error {
+	if len(rules) == 0 {
+		return RemoveEgressRules()
+	}
+
+	if _, err := exec.LookPath("nft"); err != nil {
+		return fmt.Errorf("nft not found: egress rules require nftables")
+	}
+
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(egress.NFTablesScript(user, rules))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply egress rules: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// RemoveEgressRules deletes the dnstm egress table if it exists.
+func RemoveEgressRules() error {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return nil
+	}
+	if err := exec.Command("nft", "list", "table", "inet", egress.NFTablesTable).Run(); err != nil {
+		return nil
+	}
+	if output, err := exec.Command("nft", "delete", "table", "inet", egress.NFTablesTable).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove egress rules: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -23,9 +25,21 @@ const (
 	FirewallFirewalld
 	FirewallUFW
 	FirewallIptables
+	FirewallNetsh // Windows Firewall
+	FirewallPF    // pf on FreeBSD and OpenBSD
 )
 
 func DetectFirewall() FirewallType {
+	switch runtime.GOOS {
+	case "windows":
+		return FirewallNetsh
+	case "freebsd", "openbsd":
+		if _, err := exec.LookPath("pfctl"); err == nil {
+			return FirewallPF
+		}
+		return FirewallNone
+	}
+
 	if _, err := exec.LookPath("firewall-cmd"); err == nil {
 		cmd := exec.Command("systemctl", "is-active", "firewalld")
 		if err := cmd.Run(); err == nil {
@@ -59,6 +73,11 @@ func ConfigureFirewallForPort(port string) error {
 		return configureUFWForPort(port)
 	case FirewallIptables, FirewallNone:
 		return configureIptablesForPort(port)
+	case FirewallNetsh:
+		return errNetshRedirect
+	case FirewallPF:
+		removePFRules(isPFRedirect)
+		return addPFRules(pfDNSRule, pfRedirectRule(port))
 	}
 
 	return nil
@@ -287,6 +306,11 @@ func RemoveFirewallRulesForPort(port string) {
 		clearIptablesRulesForPort(port)
 		clearIp6tablesRulesForPort(port)
 		saveIptablesRules()
+	case FirewallNetsh:
+		removeNetshRule(netshDNSUDPRule)
+		removeNetshRule(netshDNSTCPRule)
+	case FirewallPF:
+		removePFRules(func(r string) bool { return r == pfDNSRule || r == pfRedirectRule(port) })
 	}
 }
 
@@ -310,6 +334,11 @@ func RemoveAllFirewallRules() {
 			clearIp6tablesRulesForPort(port)
 		}
 		saveIptablesRules()
+	case FirewallNetsh:
+		removeNetshRule(netshDNSUDPRule)
+		removeNetshRule(netshDNSTCPRule)
+	case FirewallPF:
+		removePFRules(func(r string) bool { return r == pfDNSRule || isPFRedirect(r) })
 	}
 }
 
@@ -454,6 +483,38 @@ func AllowPort53() error {
 		for _, args := range cmds {
 			exec.Command("iptables", args...).Run()
 		}
+	case FirewallNetsh:
+		if err := allowNetshPort(netshDNSUDPRule, "UDP", 53); err != nil {
+			return err
+		}
+		return allowNetshPort(netshDNSTCPRule, "TCP", 53)
+	case FirewallPF:
+		return addPFRules(pfDNSRule)
+	}
+
+	return nil
+}
+
+// AllowTCPPort ensures a TCP port is open in the firewall. It is used for
+// non-DNS fallback transports, which accept clients directly.
+func AllowTCPPort(port int) error {
+	p := strconv.Itoa(port)
+
+	switch DetectFirewall() {
+	case FirewallFirewalld:
+		exec.Command("firewall-cmd", "--permanent", "--add-port="+p+"/tcp").Run()
+		exec.Command("firewall-cmd", "--reload").Run()
+	case FirewallUFW:
+		exec.Command("ufw", "allow", p+"/tcp").Run()
+	case FirewallIptables, FirewallNone:
+		rule := []string{"INPUT", "-p", "tcp", "--dport", p, "-j", "ACCEPT"}
+		if exec.Command("iptables", append([]string{"-C"}, rule...)...).Run() != nil {
+			exec.Command("iptables", append([]string{"-A"}, rule...)...).Run()
+		}
+	case FirewallNetsh:
+		return allowNetshPort(netshTCPRule(port), "TCP", port)
+	case FirewallPF:
+		return addPFRules(pfTCPRule(port))
 	}
 
 	return nil
@@ -486,6 +547,8 @@ func ClearNATOnly() {
 			exec.Command("firewall-cmd", "--permanent", "--direct", "--remove-rule", "ipv4", "nat", "PREROUTING", "0", "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-ports", port).Run()
 		}
 		exec.Command("firewall-cmd", "--reload").Run()
+	case FirewallPF:
+		removePFRules(isPFRedirect)
 	}
 }
 
@@ -505,13 +568,78 @@ func ResolveListenAddress(addr string) string {
 // GetExternalIP returns the external (non-loopback, non-private) IP address.
 // Falls back to the first non-loopback IP if no external IP is found.
 func GetExternalIP() (string, error) {
-	ifaces, err := net.Interfaces()
+	ips, err := interfaceIPv4s()
 	if err != nil {
-		return "", fmt.Errorf("failed to get interfaces: %w", err)
+		return "", err
 	}
 
 	var fallbackIP string
 
+	for _, ip := range ips {
+		// Check if it's a private IP
+		if isPrivateIP(ip) {
+			// Use as fallback if we don't find an external IP
+			if fallbackIP == "" {
+				fallbackIP = ip.String()
+			}
+			continue
+		}
+
+		// Found an external IP
+		return ip.String(), nil
+	}
+
+	// If no external IP found, use the fallback (first non-loopback IP)
+	if fallbackIP != "" {
+		return fallbackIP, nil
+	}
+
+	return "", fmt.Errorf("no suitable IP address found")
+}
+
+// ExternalIPs returns every external IPv4 address assigned to the host,
+// in interface order. The first entry is the one GetExternalIP returns.
+func ExternalIPs() ([]string, error) {
+	ips, err := interfaceIPv4s()
+	if err != nil {
+		return nil, err
+	}
+
+	var external []string
+	for _, ip := range ips {
+		if !isPrivateIP(ip) {
+			external = append(external, ip.String())
+		}
+	}
+	return external, nil
+}
+
+// IsLocalAddress reports whether addr is assigned to an interface of this host.
+func IsLocalAddress(addr string) bool {
+	want := net.ParseIP(addr)
+	if want == nil {
+		return false
+	}
+	ips, err := interfaceIPv4s()
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if ip.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// interfaceIPv4s returns the IPv4 addresses of all up, non-loopback interfaces.
+func interfaceIPv4s() ([]net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interfaces: %w", err)
+	}
+
+	var ips []net.IP
 	for _, iface := range ifaces {
 		// Skip loopback and down interfaces
 		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
@@ -536,27 +664,10 @@ func GetExternalIP() (string, error) {
 			if ip == nil || ip.IsLoopback() || ip.To4() == nil {
 				continue
 			}
-
-			// Check if it's a private IP
-			if isPrivateIP(ip) {
-				// Use as fallback if we don't find an external IP
-				if fallbackIP == "" {
-					fallbackIP = ip.String()
-				}
-				continue
-			}
-
-			// Found an external IP
-			return ip.String(), nil
+			ips = append(ips, ip)
 		}
 	}
-
-	// If no external IP found, use the fallback (first non-loopback IP)
-	if fallbackIP != "" {
-		return fallbackIP, nil
-	}
-
-	return "", fmt.Errorf("no suitable IP address found")
+	return ips, nil
 }
 
 // isPrivateIP checks if an IP is in a private range.
@@ -624,12 +735,59 @@ func KillProcessOnPort(port int) error {
 	exec.Command("fuser", "-k", fmt.Sprintf("%d/udp", port)).Run()
 	exec.Command("fuser", "-k", fmt.Sprintf("%d/tcp", port)).Run()
 
-	// Wait for processes to terminate
-	time.Sleep(500 * time.Millisecond)
-
-	// Check if port is now available
-	if !IsUDPPortAvailable(port) {
+	// Wait for processes to terminate and release the port
+	if !WaitForPortAvailable(port, 2*time.Second) {
 		return fmt.Errorf("port %d still in use after killing processes", port)
 	}
 	return nil
 }
+
+// natCommands are the iptables binaries whose NAT tables hold DNS redirects.
+var natCommands = []string{"iptables", "ip6tables"}
+
+// DNSRedirectRules returns the NAT PREROUTING rules redirecting port 53,
+// each prefixed with the iptables binary that owns it
+// (e.g. "iptables -A PREROUTING -p udp ... --dport 53 -j DNAT ...").
+func DNSRedirectRules() []string {
+	var rules []string
+	for _, bin := range natCommands {
+		output, err := exec.Command(bin, "-t", "nat", "-S", "PREROUTING").Output()
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "-A PREROUTING") && strings.Contains(line, "--dport 53 ") {
+				rules = append(rules, bin+" "+line)
+			}
+		}
+	}
+	return rules
+}
+
+// RestoreDNSRedirectRules replaces the current port 53 NAT redirects with
+// rules previously returned by DNSRedirectRules.
+func RestoreDNSRedirectRules(rules []string) error {
+	for _, rule := range DNSRedirectRules() {
+		fields := strings.Fields(rule)
+		fields[1] = "-D"
+		exec.Command(fields[0], append([]string{"-t", "nat"}, fields[1:]...)...).Run()
+	}
+
+	if len(rules) > 0 {
+		enableRouteLocalnet()
+	}
+
+	for _, rule := range rules {
+		fields := strings.Fields(rule)
+		if len(fields) < 3 || (fields[0] != "iptables" && fields[0] != "ip6tables") || fields[1] != "-A" {
+			return fmt.Errorf("invalid NAT rule: %s", rule)
+		}
+		cmd := exec.Command(fields[0], append([]string{"-t", "nat"}, fields[1:]...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s command failed: %s: %w", fields[0], strings.TrimSpace(string(output)), err)
+		}
+	}
+
+	return saveIptablesRules()
+}
@@ -0,0 +1,51 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Windows Firewall rules added by dnstm, managed with netsh.
+const (
+	netshDNSUDPRule = "dnstm DNS (UDP)"
+	netshDNSTCPRule = "dnstm DNS (TCP)"
+)
+
+// netshPolicyFile holds the exported Windows Firewall policy in a saved state.
+const netshPolicyFile = "netsh.wfw"
+
+// errNetshRedirect is returned by ConfigureFirewallForPort on Windows, which
+// has no NAT redirect from port 53 to another port. Tunnels bind port 53
+// directly instead.
+var errNetshRedirect = errors.New("redirecting port 53 is not supported by Windows Firewall")
+
+// netshTCPRule names the rule opening a TCP port.
+func netshTCPRule(port int) string {
+	return "dnstm TCP " + strconv.Itoa(port)
+}
+
+// netshHasRule reports whether a firewall rule with the given name exists.
+func netshHasRule(name string) bool {
+	return exec.Command("netsh", "advfirewall", "firewall", "show", "rule", "name="+name).Run() == nil
+}
+
+// allowNetshPort adds an inbound allow rule for a port unless it exists.
+func allowNetshPort(name, protocol string, port int) error {
+	if netshHasRule(name) {
+		return nil
+	}
+	output, err := exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
+		"name="+name, "dir=in", "action=allow", "protocol="+protocol, "localport="+strconv.Itoa(port)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("netsh failed to add rule %q: %s: %w", name, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// removeNetshRule deletes a firewall rule by name, ignoring missing rules.
+func removeNetshRule(name string) {
+	exec.Command("netsh", "advfirewall", "firewall", "delete", "rule", "name="+name).Run()
+}
@@ -0,0 +1,148 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// pf rules added by dnstm live in their own anchor, loaded from a rules
+// file so they can be put back after a reboot.
+const pfAnchor = "dnstm"
+
+// pfRulesPath is the rules file loaded into the anchor.
+var pfRulesPath = filepath.Join(paths.ConfigDir, "pf.conf")
+
+// pfDNSRule opens port 53.
+const pfDNSRule = "pass in quick proto { udp tcp } to port 53"
+
+// errPFAnchor is returned when the main pf ruleset does not evaluate the
+// dnstm anchor, so its rules have no effect.
+var errPFAnchor = errors.New(`pf does not use the dnstm anchor; add these lines to /etc/pf.conf and run 'pfctl -f /etc/pf.conf':` +
+	"\n  " + strings.Join(pfConfLines(), "\n  "))
+
+// pfConfLines returns the /etc/pf.conf lines that hook in the dnstm anchor.
+// FreeBSD evaluates redirects from a separate rdr-anchor.
+func pfConfLines() []string {
+	lines := []string{`anchor "` + pfAnchor + `"`, `load anchor "` + pfAnchor + `" from "` + pfRulesPath + `"`}
+	if runtime.GOOS == "freebsd" {
+		lines = append([]string{`rdr-anchor "` + pfAnchor + `"`}, lines...)
+	}
+	return lines
+}
+
+// pfTCPRule opens a TCP port.
+func pfTCPRule(port int) string {
+	return fmt.Sprintf("pass in quick proto tcp to port %d", port)
+}
+
+// pfRedirectRule redirects port 53 to a local port.
+func pfRedirectRule(port string) string {
+	if runtime.GOOS == "openbsd" {
+		return "pass in quick proto { udp tcp } to port 53 rdr-to 127.0.0.1 port " + port
+	}
+	return "rdr pass proto { udp tcp } to port 53 -> 127.0.0.1 port " + port
+}
+
+// isPFRedirect reports whether a rule is a port 53 redirect.
+func isPFRedirect(rule string) bool {
+	return strings.HasPrefix(rule, "rdr ") || strings.Contains(rule, " rdr-to ")
+}
+
+// readPFRules returns the rules in the dnstm rules file.
+func readPFRules() []string {
+	data, err := os.ReadFile(pfRulesPath)
+	if err != nil {
+		return nil
+	}
+	var rules []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			rules = append(rules, line)
+		}
+	}
+	return rules
+}
+
+// writePFRules saves rules to the dnstm rules file and loads them into the
+// anchor. Redirects go first, as pf requires translation before filtering.
+func writePFRules(rules []string) error {
+	var rdr, filter []string
+	for _, r := range rules {
+		if isPFRedirect(r) {
+			rdr = append(rdr, r)
+		} else {
+			filter = append(filter, r)
+		}
+	}
+	content := "# Written by dnstm; changes are overwritten.\n" + strings.Join(append(rdr, filter...), "\n") + "\n"
+	if err := os.WriteFile(pfRulesPath, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", pfRulesPath, err)
+	}
+	return loadPFAnchor()
+}
+
+// loadPFAnchor loads the dnstm rules file into the anchor.
+func loadPFAnchor() error {
+	if output, err := exec.Command("pfctl", "-a", pfAnchor, "-f", pfRulesPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("pfctl failed: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// addPFRules adds rules that are not already present.
+func addPFRules(add ...string) error {
+	rules := readPFRules()
+	changed := false
+	for _, r := range add {
+		if !containsString(rules, r) {
+			rules = append(rules, r)
+			changed = true
+		}
+	}
+	if changed {
+		if err := writePFRules(rules); err != nil {
+			return err
+		}
+	}
+	if !pfUsesAnchor() {
+		return errPFAnchor
+	}
+	return nil
+}
+
+// removePFRules removes the rules matching drop.
+func removePFRules(drop func(rule string) bool) {
+	rules := readPFRules()
+	var kept []string
+	for _, r := range rules {
+		if !drop(r) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) != len(rules) {
+		writePFRules(kept)
+	}
+}
+
+// pfUsesAnchor reports whether the main ruleset evaluates the dnstm anchor.
+func pfUsesAnchor() bool {
+	output, err := exec.Command("pfctl", "-s", "rules").Output()
+	return err == nil && strings.Contains(string(output), `anchor "`+pfAnchor+`"`)
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPFRules(t *testing.T) {
+	if r := pfRedirectRule("5310"); !isPFRedirect(r) || !strings.HasSuffix(r, "127.0.0.1 port 5310") {
+		t.Errorf("pfRedirectRule() = %q", r)
+	}
+	for _, r := range []string{pfDNSRule, pfTCPRule(8443)} {
+		if isPFRedirect(r) {
+			t.Errorf("isPFRedirect(%q) = true", r)
+		}
+	}
+	if !strings.Contains(errPFAnchor.Error(), `load anchor "dnstm" from "/etc/dnstm/pf.conf"`) {
+		t.Errorf("errPFAnchor does not show the pf.conf lines: %v", errPFAnchor)
+	}
+}
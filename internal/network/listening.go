@@ -0,0 +1,73 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// procNetDir holds the kernel socket tables read by IsListening.
+var procNetDir = "/proc/net"
+
+// tcpListen is the state of a listening socket in /proc/net/tcp.
+const tcpListen = "0A"
+
+// IsListening reports whether a local socket is bound to port: any UDP
+// socket for "udp", a listening TCP socket for "tcp". It reads the kernel
+// socket tables rather than probing the port, so it never competes with a
+// starting service for the bind.
+func IsListening(proto string, port int) bool {
+	for _, table := range []string{proto, proto + "6"} {
+		if tableHasPort(filepath.Join(procNetDir, table), proto == "tcp", port) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableHasPort scans one /proc/net socket table for a local port.
+func tableHasPort(path string, listenOnly bool, port int) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		if listenOnly && fields[3] != tcpListen {
+			continue
+		}
+		colon := strings.LastIndexByte(fields[1], ':')
+		if colon < 0 {
+			continue
+		}
+		p, err := strconv.ParseUint(fields[1][colon+1:], 16, 16)
+		if err == nil && int(p) == port {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitListening waits until IsListening reports the port bound.
+func WaitListening(proto string, port int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for !IsListening(proto, port) {
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("nothing listening on %s port %d after %s", proto, port, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
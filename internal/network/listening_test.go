@@ -0,0 +1,54 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsListening(t *testing.T) {
+	dir := t.TempDir()
+	origDir := procNetDir
+	defer func() { procNetDir = origDir }()
+	procNetDir = dir
+
+	header := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	tables := map[string]string{
+		// 127.0.0.1:5310 unconnected
+		"udp": header + "   0: 0100007F:14BE 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 1 2\n",
+		// 0.0.0.0:8080 listening, 0.0.0.0:9000 established only
+		"tcp": header +
+			"   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1\n" +
+			"   1: 00000000:2328 0100007F:A000 01 00000000:00000000 00:00000000 00000000     0        0 1 1\n",
+		// [::]:53
+		"udp6": header + "   0: 00000000000000000000000000000000:0035 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 1 2\n",
+	}
+	for name, content := range tables {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		proto string
+		port  int
+		want  bool
+	}{
+		{"udp", 5310, true},
+		{"udp", 53, true},
+		{"udp", 5311, false},
+		{"tcp", 8080, true},
+		{"tcp", 9000, false},
+		{"tcp", 5310, false},
+	}
+	for _, tt := range tests {
+		if got := IsListening(tt.proto, tt.port); got != tt.want {
+			t.Errorf("IsListening(%s, %d) = %v, want %v", tt.proto, tt.port, got, tt.want)
+		}
+	}
+
+	if err := WaitListening("tcp", 9000, 150*time.Millisecond); err == nil {
+		t.Error("WaitListening() on an unbound port returned nil")
+	}
+}
@@ -0,0 +1,321 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SSHSessionPort returns the server port of the SSH session dnstm runs in,
+// or 0 when it does not run over SSH. sudo usually drops SSH_CONNECTION, so
+// the parent processes' environments are searched as well.
+func SSHSessionPort() int {
+	if port := parseSSHConnection(os.Getenv("SSH_CONNECTION")); port > 0 {
+		return port
+	}
+	pid := os.Getppid()
+	for i := 0; pid > 1 && i < 16; i++ {
+		if port := parseSSHConnection(procEnv(pid, "SSH_CONNECTION")); port > 0 {
+			return port
+		}
+		pid = parentPID(pid)
+	}
+	return 0
+}
+
+// parseSSHConnection returns the server port from an SSH_CONNECTION value
+// ("client_ip client_port server_ip server_port").
+func parseSSHConnection(value string) int {
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return 0
+	}
+	port, err := strconv.Atoi(fields[3])
+	if err != nil || port < 1 || port > 65535 {
+		return 0
+	}
+	return port
+}
+
+func procEnv(pid int, name string) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return ""
+	}
+	for _, kv := range strings.Split(string(data), "\x00") {
+		if v, ok := strings.CutPrefix(kv, name+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func parentPID(pid int) int {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "PPid:"); ok {
+			ppid, _ := strconv.Atoi(strings.TrimSpace(v))
+			return ppid
+		}
+	}
+	return 0
+}
+
+// IsTCPPortAllowed reports whether the firewall lets new connections in on
+// a TCP port. It errs on the side of false when the rules are unclear.
+func IsTCPPortAllowed(port int) bool {
+	p := strconv.Itoa(port)
+
+	switch DetectFirewall() {
+	case FirewallFirewalld:
+		if exec.Command("firewall-cmd", "--query-port="+p+"/tcp").Run() == nil {
+			return true
+		}
+		return port == 22 && exec.Command("firewall-cmd", "--query-service=ssh").Run() == nil
+	case FirewallUFW:
+		output, err := exec.Command("ufw", "status", "verbose").Output()
+		return err == nil && ufwAllowsTCP(string(output), port)
+	case FirewallIptables:
+		output, err := exec.Command("iptables", "-S", "INPUT").Output()
+		return err == nil && iptablesAllowsTCP(string(output), port)
+	case FirewallNetsh, FirewallPF:
+		// dnstm only adds allow rules to Windows Firewall and its pf
+		// anchor, so it cannot shut out a session that is already permitted
+		return true
+	}
+	return true
+}
+
+// ufwAllowsTCP checks "ufw status verbose" output for a rule or default
+// policy admitting the port.
+func ufwAllowsTCP(status string, port int) bool {
+	p := strconv.Itoa(port)
+	for _, line := range strings.Split(status, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Default:") {
+			if strings.Contains(line, "allow (incoming)") {
+				return true
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		action := fields[1]
+		if fields[1] == "(v6)" && len(fields) > 2 {
+			action = fields[2]
+		}
+		if action != "ALLOW" && action != "LIMIT" {
+			continue
+		}
+		to := fields[0]
+		if to == "OpenSSH" && port == 22 {
+			return true
+		}
+		proto := ""
+		if i := strings.Index(to, "/"); i >= 0 {
+			to, proto = to[:i], to[i+1:]
+		}
+		if proto != "" && proto != "tcp" {
+			continue
+		}
+		if to == p || portInRange(to, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// iptablesAllowsTCP checks "iptables -S INPUT" output. The port counts as
+// allowed when an ACCEPT rule names it, or when the policy is ACCEPT and no
+// rule drops traffic wholesale.
+func iptablesAllowsTCP(rules string, port int) bool {
+	p := strconv.Itoa(port)
+	policyAccept := false
+	blanketDrop := false
+	for _, line := range strings.Split(rules, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "-P INPUT ACCEPT" {
+			policyAccept = true
+			continue
+		}
+		if !strings.HasPrefix(line, "-A INPUT") {
+			continue
+		}
+		fields := strings.Fields(line)
+		dport := ""
+		target := ""
+		for i := 0; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "--dport":
+				dport = fields[i+1]
+			case "-j":
+				target = fields[i+1]
+			}
+		}
+		switch target {
+		case "ACCEPT":
+			if (dport == p || portInRange(dport, port)) && !strings.Contains(line, "-p udp") {
+				return true
+			}
+		case "DROP", "REJECT":
+			if dport == "" && !strings.Contains(line, " -s ") && !strings.Contains(line, " -i ") {
+				blanketDrop = true
+			}
+		}
+	}
+	return policyAccept && !blanketDrop
+}
+
+// portInRange reports whether port lies in a "start:end" range.
+func portInRange(r string, port int) bool {
+	lo, hi, ok := strings.Cut(r, ":")
+	if !ok {
+		return false
+	}
+	start, err1 := strconv.Atoi(lo)
+	end, err2 := strconv.Atoi(hi)
+	return err1 == nil && err2 == nil && port >= start && port <= end
+}
+
+// firewallState describes a saved firewall configuration.
+type firewallState struct {
+	Firewall FirewallType    `json:"firewall"`
+	Files    map[string]bool `json:"files"` // path -> whether it existed
+}
+
+// firewallStateFiles returns the files holding the persistent rules of a firewall.
+func firewallStateFiles(fw FirewallType) []string {
+	switch fw {
+	case FirewallUFW:
+		return []string{ufwBeforeRulesPath, ufwBefore6RulesPath, "/etc/ufw/user.rules", "/etc/ufw/user6.rules"}
+	case FirewallFirewalld:
+		zones, _ := filepath.Glob("/etc/firewalld/zones/*.xml")
+		return append([]string{"/etc/firewalld/direct.xml"}, zones...)
+	case FirewallPF:
+		return []string{pfRulesPath}
+	}
+	return nil
+}
+
+func stateCopyName(path string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "_")
+}
+
+// SaveFirewallState saves the current firewall rules to dir, so they can be
+// put back with RestoreFirewallState.
+func SaveFirewallState(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	state := firewallState{Firewall: DetectFirewall(), Files: make(map[string]bool)}
+	for _, path := range firewallStateFiles(state.Firewall) {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			state.Files[path] = false
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, stateCopyName(path)), data, 0600); err != nil {
+			return err
+		}
+		state.Files[path] = true
+	}
+
+	if state.Firewall == FirewallNetsh {
+		if output, err := exec.Command("netsh", "advfirewall", "export", filepath.Join(dir, netshPolicyFile)).CombinedOutput(); err != nil {
+			return fmt.Errorf("netsh export failed: %s: %w", strings.TrimSpace(string(output)), err)
+		}
+	}
+
+	// The live tables cover rules dnstm adds without a persistent file
+	for _, bin := range natCommands {
+		output, err := exec.Command(bin + "-save").Output()
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, bin+".rules"), output, 0600); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "state.json"), data, 0600)
+}
+
+// RestoreFirewallState puts back the firewall rules saved by SaveFirewallState.
+func RestoreFirewallState(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		return fmt.Errorf("no saved firewall state: %w", err)
+	}
+	var state firewallState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid saved firewall state: %w", err)
+	}
+
+	for path, existed := range state.Files {
+		if !existed {
+			os.Remove(path)
+			continue
+		}
+		saved, err := os.ReadFile(filepath.Join(dir, stateCopyName(path)))
+		if err != nil {
+			return fmt.Errorf("failed to read saved copy of %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, saved, 0640); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+	}
+
+	switch state.Firewall {
+	case FirewallUFW:
+		if output, err := exec.Command("ufw", "reload").CombinedOutput(); err != nil {
+			return fmt.Errorf("ufw reload failed: %s: %w", strings.TrimSpace(string(output)), err)
+		}
+	case FirewallFirewalld:
+		if output, err := exec.Command("firewall-cmd", "--reload").CombinedOutput(); err != nil {
+			return fmt.Errorf("firewalld reload failed: %s: %w", strings.TrimSpace(string(output)), err)
+		}
+	case FirewallIptables, FirewallNone:
+		for _, bin := range natCommands {
+			rules, err := os.Open(filepath.Join(dir, bin+".rules"))
+			if err != nil {
+				continue
+			}
+			cmd := exec.Command(bin + "-restore")
+			cmd.Stdin = rules
+			output, err := cmd.CombinedOutput()
+			rules.Close()
+			if err != nil {
+				return fmt.Errorf("%s-restore failed: %s: %w", bin, strings.TrimSpace(string(output)), err)
+			}
+		}
+		return saveIptablesRules()
+	case FirewallPF:
+		if !state.Files[pfRulesPath] {
+			exec.Command("pfctl", "-a", pfAnchor, "-F", "all").Run()
+			return nil
+		}
+		return loadPFAnchor()
+	case FirewallNetsh:
+		if output, err := exec.Command("netsh", "advfirewall", "import", filepath.Join(dir, netshPolicyFile)).CombinedOutput(); err != nil {
+			return fmt.Errorf("netsh import failed: %s: %w", strings.TrimSpace(string(output)), err)
+		}
+	}
+	return nil
+}
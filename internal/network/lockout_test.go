@@ -0,0 +1,68 @@
+package network
+
+import "testing"
+
+func TestParseSSHConnection(t *testing.T) {
+	tests := map[string]int{
+		"198.51.100.7 51234 203.0.113.10 22":   22,
+		"198.51.100.7 51234 203.0.113.10 2222": 2222,
+		"":                                     0,
+		"198.51.100.7 51234 203.0.113.10":      0,
+		"198.51.100.7 51234 203.0.113.10 ssh":  0,
+	}
+	for value, want := range tests {
+		if got := parseSSHConnection(value); got != want {
+			t.Errorf("parseSSHConnection(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestUFWAllowsTCP(t *testing.T) {
+	status := `Status: active
+Logging: on (low)
+Default: deny (incoming), allow (outgoing), disabled (routed)
+New profiles: skip
+
+To                         Action      From
+--                         ------      ----
+53/udp                     ALLOW IN    Anywhere
+2222/tcp                   LIMIT IN    Anywhere
+8000:8100/tcp              ALLOW IN    Anywhere
+9000/udp                   ALLOW IN    Anywhere
+7000                       DENY IN     Anywhere
+OpenSSH                    ALLOW IN    Anywhere
+`
+	tests := map[int]bool{22: true, 2222: true, 8050: true, 53: false, 9000: false, 7000: false, 443: false}
+	for port, want := range tests {
+		if got := ufwAllowsTCP(status, port); got != want {
+			t.Errorf("ufwAllowsTCP(%d) = %v, want %v", port, got, want)
+		}
+	}
+
+	if !ufwAllowsTCP("Default: allow (incoming), allow (outgoing)\n", 443) {
+		t.Error("ufwAllowsTCP() ignored an allow incoming default")
+	}
+}
+
+func TestIptablesAllowsTCP(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		port  int
+		want  bool
+	}{
+		{name: "open policy", rules: "-P INPUT ACCEPT\n", port: 22, want: true},
+		{name: "drop policy", rules: "-P INPUT DROP\n-A INPUT -p udp -m udp --dport 53 -j ACCEPT\n", port: 22, want: false},
+		{name: "explicit accept", rules: "-P INPUT DROP\n-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT\n", port: 22, want: true},
+		{name: "accept range", rules: "-P INPUT DROP\n-A INPUT -p tcp -m tcp --dport 2000:3000 -j ACCEPT\n", port: 2222, want: true},
+		{name: "blanket reject", rules: "-P INPUT ACCEPT\n-A INPUT -j REJECT --reject-with icmp-host-prohibited\n", port: 22, want: false},
+		{name: "source scoped drop", rules: "-P INPUT ACCEPT\n-A INPUT -s 192.0.2.0/24 -j DROP\n", port: 22, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := iptablesAllowsTCP(tt.rules, tt.port); got != tt.want {
+				t.Errorf("iptablesAllowsTCP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
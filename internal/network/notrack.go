@@ -0,0 +1,118 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// notrackComment tags the raw-table rules dnstm manages, so they can be
+// found and replaced without touching the rules of others.
+const notrackComment = "dnstm-notrack"
+
+// ErrNotrackUnsupported is returned when the conntrack bypass is enabled on
+// a system without iptables.
+var ErrNotrackUnsupported = errors.New("conntrack bypass needs iptables")
+
+// notrackRule is a raw-table rule, run by bin in chain.
+type notrackRule struct {
+	bin   string
+	chain string
+	args  []string
+}
+
+// notrackRules returns the rules exempting DNS traffic from connection
+// tracking: queries arriving on port 53 and the answers leaving it, which
+// the transports in single mode and the DNS router in multi mode handle
+// without NAT. loopback, a port range such as "5310:5409", adds the
+// traffic between the DNS router and the tunnels on loopback. Traffic of
+// the server's own resolver goes to port 53 elsewhere and stays tracked.
+func notrackRules(loopback string) []notrackRule {
+	var rules []notrackRule
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		rules = append(rules,
+			notrackRule{bin, "PREROUTING", []string{"!", "-i", "lo", "-p", "udp", "--dport", "53"}},
+			notrackRule{bin, "OUTPUT", []string{"!", "-o", "lo", "-p", "udp", "--sport", "53"}},
+		)
+	}
+	if loopback != "" {
+		for _, dir := range []string{"--dport", "--sport"} {
+			rules = append(rules,
+				notrackRule{"iptables", "PREROUTING", []string{"-i", "lo", "-p", "udp", dir, loopback}},
+				notrackRule{"iptables", "OUTPUT", []string{"-o", "lo", "-p", "udp", dir, loopback}},
+			)
+		}
+	}
+	for i := range rules {
+		rules[i].args = append(rules[i].args, "-m", "comment", "--comment", notrackComment, "-j", "NOTRACK")
+	}
+	return rules
+}
+
+// NotrackRules returns the conntrack bypass rules in place, each prefixed
+// with the iptables binary that owns it.
+func NotrackRules() []string {
+	var rules []string
+	for _, bin := range natCommands {
+		for _, chain := range []string{"PREROUTING", "OUTPUT"} {
+			output, err := exec.Command(bin, "-t", "raw", "-S", chain).Output()
+			if err != nil {
+				continue
+			}
+			for _, rule := range parseNotrackRules(string(output)) {
+				rules = append(rules, bin+" "+rule)
+			}
+		}
+	}
+	return rules
+}
+
+// parseNotrackRules returns the dnstm rules in "iptables -S" output.
+func parseNotrackRules(output string) []string {
+	var rules []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-A ") && strings.Contains(line, "--comment "+notrackComment+" ") {
+			rules = append(rules, line)
+		}
+	}
+	return rules
+}
+
+// SyncNotrack replaces the conntrack bypass rules: with enabled, those of
+// notrackRules for loopback are put in place, otherwise they are removed.
+// The rules are saved with the other iptables rules.
+func SyncNotrack(enabled bool, loopback string) error {
+	if enabled {
+		if _, err := exec.LookPath("iptables"); err != nil {
+			return ErrNotrackUnsupported
+		}
+	}
+	existing := NotrackRules()
+	for _, rule := range existing {
+		fields := strings.Fields(rule)
+		fields[1] = "-D"
+		exec.Command(fields[0], append([]string{"-t", "raw"}, fields[1:]...)...).Run()
+	}
+	if !enabled {
+		if len(existing) > 0 {
+			return saveIptablesRules()
+		}
+		return nil
+	}
+
+	for _, r := range notrackRules(loopback) {
+		args := append([]string{"-t", "raw", "-A", r.chain}, r.args...)
+		output, err := exec.Command(r.bin, args...).CombinedOutput()
+		if err == nil {
+			continue
+		}
+		// Hosts without IPv6 have no ip6tables raw table
+		if r.bin == "ip6tables" {
+			continue
+		}
+		return fmt.Errorf("%s command failed: %s: %w", r.bin, strings.TrimSpace(string(output)), err)
+	}
+	return saveIptablesRules()
+}
@@ -0,0 +1,53 @@
+package network
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNotrackRules(t *testing.T) {
+	single := notrackRules("")
+	if len(single) != 4 {
+		t.Fatalf("notrackRules() = %d rules, want 4 (port 53 both ways, IPv4 and IPv6)", len(single))
+	}
+	for _, r := range single {
+		args := strings.Join(r.args, " ")
+		if !strings.Contains(args, "! -i lo") && !strings.Contains(args, "! -o lo") {
+			t.Errorf("%s %s %s: port 53 rule must skip loopback", r.bin, r.chain, args)
+		}
+		if !strings.HasSuffix(args, "--comment "+notrackComment+" -j NOTRACK") {
+			t.Errorf("%s %s %s: missing comment or target", r.bin, r.chain, args)
+		}
+	}
+	// Queries come in on port 53 and answers go out from it, so the server's
+	// own lookups to remote port 53 stay tracked
+	if got := single[1].args[:6]; !reflect.DeepEqual(got, []string{"!", "-o", "lo", "-p", "udp", "--sport"}) {
+		t.Errorf("OUTPUT rule = %v, want answers from port 53", got)
+	}
+
+	multi := notrackRules("5310:5409")
+	if len(multi) != 8 {
+		t.Fatalf("notrackRules(range) = %d rules, want 8", len(multi))
+	}
+	for _, r := range multi[4:] {
+		if r.bin != "iptables" || !strings.Contains(strings.Join(r.args, " "), " 5310:5409 ") {
+			t.Errorf("loopback rule %s %s %v", r.bin, r.chain, r.args)
+		}
+	}
+}
+
+func TestParseNotrackRules(t *testing.T) {
+	output := `-P PREROUTING ACCEPT
+-A PREROUTING ! -i lo -p udp -m udp --dport 53 -m comment --comment dnstm-notrack -j NOTRACK
+-A PREROUTING -p udp -m udp --dport 123 -j NOTRACK
+-A PREROUTING -i lo -p udp -m udp --dport 5310:5409 -m comment --comment dnstm-notrack -j NOTRACK
+`
+	want := []string{
+		"-A PREROUTING ! -i lo -p udp -m udp --dport 53 -m comment --comment dnstm-notrack -j NOTRACK",
+		"-A PREROUTING -i lo -p udp -m udp --dport 5310:5409 -m comment --comment dnstm-notrack -j NOTRACK",
+	}
+	if got := parseNotrackRules(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNotrackRules() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,241 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// PublicResolver is a recursive resolver clients may send tunnel queries through.
+type PublicResolver struct {
+	Name string
+	Addr string // host:port
+}
+
+// DefaultResolvers lists well-known public resolvers and resolvers run by
+// ISPs in the regions dnstm is most used in.
+var DefaultResolvers = []PublicResolver{
+	{Name: "Google", Addr: "8.8.8.8:53"},
+	{Name: "Cloudflare", Addr: "1.1.1.1:53"},
+	{Name: "Quad9", Addr: "9.9.9.9:53"},
+	{Name: "OpenDNS", Addr: "208.67.222.222:53"},
+	{Name: "AdGuard", Addr: "94.140.14.14:53"},
+	{Name: "Yandex", Addr: "77.88.8.8:53"},
+	{Name: "Shecan", Addr: "178.22.122.100:53"},
+	{Name: "Electro", Addr: "78.157.42.100:53"},
+	{Name: "Begzar", Addr: "185.55.226.26:53"},
+}
+
+// ParseResolvers parses a comma-separated list of resolver addresses, each
+// an IP with an optional port.
+func ParseResolvers(list string) ([]PublicResolver, error) {
+	var resolvers []PublicResolver
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		addr := s
+		if net.ParseIP(s) != nil {
+			addr = net.JoinHostPort(s, "53")
+		} else if host, _, err := net.SplitHostPort(s); err != nil || net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid resolver '%s' (expected an IP address with an optional port)", s)
+		}
+		resolvers = append(resolvers, PublicResolver{Name: s, Addr: addr})
+	}
+	if len(resolvers) == 0 {
+		return nil, fmt.Errorf("no resolvers given")
+	}
+	return resolvers, nil
+}
+
+// ProbeStatus is the outcome of a query for a tunnel domain through a resolver.
+type ProbeStatus string
+
+// Probe outcomes.
+const (
+	ProbeOK           ProbeStatus = "ok"            // the query reached the tunnel server
+	ProbeNotDelegated ProbeStatus = "not-delegated" // the parent zone answered NXDOMAIN
+	ProbeServFail     ProbeStatus = "servfail"      // the resolver gave up, e.g. on a blocked server
+	ProbeRefused      ProbeStatus = "refused"       // the resolver does not serve this client
+	ProbeTimeout      ProbeStatus = "timeout"
+	ProbeError        ProbeStatus = "error"
+)
+
+// ProbeResult is the result of probing one domain through one resolver.
+type ProbeResult struct {
+	Resolver PublicResolver
+	Domain   string
+	Status   ProbeStatus
+	RTT      time.Duration
+	Err      error
+}
+
+// Reachable reports whether the resolver forwards queries to the tunnel.
+func (r ProbeResult) Reachable() bool {
+	return r.Status == ProbeOK
+}
+
+// DNS response codes and types used by the probe.
+const (
+	rcodeNXDomain = 3
+	rcodeServFail = 2
+	rcodeRefused  = 5
+	probeTypeTXT  = 16
+	probeTypeSOA  = 6
+)
+
+// ProbeResolver sends a TXT query for a random name under domain through
+// the resolver. A random name defeats resolver caches, so an answer means
+// the resolver asked the server the domain is delegated to.
+func ProbeResolver(resolver PublicResolver, domain string, timeout time.Duration) ProbeResult {
+	result := ProbeResult{Resolver: resolver, Domain: domain}
+	fail := func(status ProbeStatus, err error) ProbeResult {
+		result.Status = status
+		result.Err = err
+		return result
+	}
+
+	label := make([]byte, 6)
+	rand.Read(label)
+	query := []byte{label[0], label[1], 0x01, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}
+	query = appendName(query, "dnstm-"+hex.EncodeToString(label)+"."+strings.TrimSuffix(domain, "."))
+	query = binary.BigEndian.AppendUint16(query, probeTypeTXT)
+	query = binary.BigEndian.AppendUint16(query, 1)
+
+	conn, err := net.DialTimeout("udp", resolver.Addr, timeout)
+	if err != nil {
+		return fail(ProbeError, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	start := time.Now()
+	if _, err := conn.Write(query); err != nil {
+		return fail(ProbeError, err)
+	}
+	resp := make([]byte, 4096)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return fail(ProbeTimeout, err)
+			}
+			return fail(ProbeError, err)
+		}
+		// Ignore stray packets that do not answer this query
+		if n >= 12 && resp[0] == query[0] && resp[1] == query[1] && resp[2]&0x80 != 0 {
+			resp = resp[:n]
+			break
+		}
+	}
+	result.RTT = time.Since(start)
+
+	switch resp[3] & 0x0f {
+	case 0:
+		result.Status = ProbeOK
+	case rcodeNXDomain:
+		// An NXDOMAIN with the SOA of a zone above the domain comes from the
+		// parent, which means the domain is not delegated
+		if soa, ok := authoritySOA(resp); ok && !isSubdomain(soa, domain) {
+			return fail(ProbeNotDelegated, fmt.Errorf("NXDOMAIN from %s", soa))
+		}
+		result.Status = ProbeOK
+	case rcodeServFail:
+		return fail(ProbeServFail, errors.New("SERVFAIL"))
+	case rcodeRefused:
+		return fail(ProbeRefused, errors.New("REFUSED"))
+	default:
+		return fail(ProbeError, fmt.Errorf("rcode %d", resp[3]&0x0f))
+	}
+	return result
+}
+
+// appendName appends name in uncompressed wire format.
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(name, ".") {
+		if label != "" {
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0)
+}
+
+// skipName returns the offset after the name at off.
+func skipName(b []byte, off int) (int, bool) {
+	for off < len(b) {
+		l := int(b[off])
+		switch {
+		case l == 0:
+			return off + 1, true
+		case l&0xc0 == 0xc0:
+			return off + 2, off+2 <= len(b)
+		default:
+			off += 1 + l
+		}
+	}
+	return 0, false
+}
+
+// readName returns the name at off, following compression pointers.
+func readName(b []byte, off int) string {
+	var labels []string
+	for hops := 0; off < len(b) && hops < 32; {
+		l := int(b[off])
+		switch {
+		case l == 0:
+			return strings.Join(labels, ".")
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(b) {
+				return ""
+			}
+			off = int(b[off]&0x3f)<<8 | int(b[off+1])
+			hops++
+		default:
+			if off+1+l > len(b) {
+				return ""
+			}
+			labels = append(labels, string(b[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+	return ""
+}
+
+// authoritySOA returns the owner of the SOA record in the authority section.
+func authoritySOA(resp []byte) (string, bool) {
+	off, ok := skipName(resp, 12)
+	if !ok {
+		return "", false
+	}
+	off += 4
+	answers := int(binary.BigEndian.Uint16(resp[6:8]))
+	authority := int(binary.BigEndian.Uint16(resp[8:10]))
+	for i := 0; i < answers+authority; i++ {
+		owner := off
+		if off, ok = skipName(resp, off); !ok || off+10 > len(resp) {
+			return "", false
+		}
+		typ := binary.BigEndian.Uint16(resp[off : off+2])
+		rdlen := int(binary.BigEndian.Uint16(resp[off+8 : off+10]))
+		if i >= answers && typ == probeTypeSOA {
+			return strings.ToLower(readName(resp, owner)), true
+		}
+		off += 10 + rdlen
+	}
+	return "", false
+}
+
+// isSubdomain reports whether name is domain or a name under it.
+func isSubdomain(name, domain string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
@@ -0,0 +1,107 @@
+package network
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseResolvers(t *testing.T) {
+	got, err := ParseResolvers("8.8.8.8, 1.1.1.1:5353,2001:4860:4860::8888")
+	if err != nil {
+		t.Fatalf("ParseResolvers() error = %v", err)
+	}
+	want := []string{"8.8.8.8:53", "1.1.1.1:5353", "[2001:4860:4860::8888]:53"}
+	if len(got) != len(want) {
+		t.Fatalf("ParseResolvers() = %v", got)
+	}
+	for i, r := range got {
+		if r.Addr != want[i] {
+			t.Errorf("resolver %d = %s, want %s", i, r.Addr, want[i])
+		}
+	}
+
+	for _, bad := range []string{"", "dns.google", "8.8.8.8:x:y"} {
+		if _, err := ParseResolvers(bad); err == nil {
+			t.Errorf("ParseResolvers(%q) accepted", bad)
+		}
+	}
+}
+
+// fakeResolver answers every query with rcode and, if soa is set, an SOA
+// record owned by soa in the authority section.
+func fakeResolver(t *testing.T, rcode byte, soa string) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			resp := append([]byte(nil), buf[:n]...)
+			resp[2] |= 0x80
+			resp[3] = 0x80 | rcode
+			if soa != "" {
+				binary.BigEndian.PutUint16(resp[8:10], 1)
+				resp = appendName(resp, soa)
+				resp = binary.BigEndian.AppendUint16(resp, probeTypeSOA)
+				resp = binary.BigEndian.AppendUint16(resp, 1)
+				resp = binary.BigEndian.AppendUint32(resp, 300)
+				rdata := appendName(appendName(nil, "ns1."+soa), "hostmaster."+soa)
+				rdata = append(rdata, make([]byte, 20)...)
+				resp = binary.BigEndian.AppendUint16(resp, uint16(len(rdata)))
+				resp = append(resp, rdata...)
+			}
+			conn.WriteToUDP(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestProbeResolver(t *testing.T) {
+	tests := []struct {
+		name  string
+		rcode byte
+		soa   string
+		want  ProbeStatus
+	}{
+		{"answered", 0, "", ProbeOK},
+		{"NXDOMAIN from the tunnel", rcodeNXDomain, "t.example.com", ProbeOK},
+		{"NXDOMAIN from the parent", rcodeNXDomain, "example.com", ProbeNotDelegated},
+		{"SERVFAIL", rcodeServFail, "", ProbeServFail},
+		{"REFUSED", rcodeRefused, "", ProbeRefused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := PublicResolver{Name: "fake", Addr: fakeResolver(t, tt.rcode, tt.soa)}
+			got := ProbeResolver(r, "T.Example.com.", time.Second)
+			if got.Status != tt.want {
+				t.Errorf("Status = %s (%v), want %s", got.Status, got.Err, tt.want)
+			}
+			if got.Reachable() != (tt.want == ProbeOK) {
+				t.Errorf("Reachable() = %v", got.Reachable())
+			}
+		})
+	}
+}
+
+func TestProbeResolver_Timeout(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	got := ProbeResolver(PublicResolver{Addr: conn.LocalAddr().String()}, "t.example.com", 100*time.Millisecond)
+	if got.Status != ProbeTimeout {
+		t.Errorf("Status = %s (%v), want timeout", got.Status, got.Err)
+	}
+}
@@ -0,0 +1,53 @@
+package network
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/usage"
+)
+
+// ApplyUsageAccounting replaces the usage table with counters for subjects.
+// Counter values are lost, so they must be collected first.
+func ApplyUsageAccounting(subjects []usage.Subject) error {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return fmt.Errorf("nft not found: usage accounting requires nftables")
+	}
+
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(usage.NFTablesScript(subjects))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply usage accounting: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// IsUsageAccountingActive reports whether the usage table exists.
+func IsUsageAccountingActive() bool {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return false
+	}
+	return exec.Command("nft", "list", "table", "inet", usage.NFTablesTable).Run() == nil
+}
+
+// ResetUsageCounters reads the usage counters and sets them to zero in one
+// step, so no traffic is counted twice or lost between reading and resetting.
+func ResetUsageCounters() ([]usage.Entry, error) {
+	output, err := exec.Command("nft", "-j", "reset", "counters", "table", "inet", usage.NFTablesTable).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage counters: %w", err)
+	}
+	return usage.ParseCounters(output)
+}
+
+// RemoveUsageAccounting deletes the usage table if it exists.
+func RemoveUsageAccounting() error {
+	if !IsUsageAccountingActive() {
+		return nil
+	}
+	if output, err := exec.Command("nft", "delete", "table", "inet", usage.NFTablesTable).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove usage accounting: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
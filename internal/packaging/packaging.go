@@ -0,0 +1,302 @@
+// Package packaging builds .deb and .rpm packages of dnstm and detects
+// installs managed by a package manager.
+//
+// The packages install the dnstm binary to /usr/local/bin, where services
+// expect it. Their maintainer scripts refresh the services of a configured
+// install on upgrade and run "dnstm uninstall" when the package is removed.
+package packaging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Package formats.
+const (
+	FormatDeb = "deb"
+	FormatRPM = "rpm"
+)
+
+// BinaryPath is where the packages install dnstm.
+const BinaryPath = "/usr/local/bin/dnstm"
+
+// ScriptEnv is set when dnstm runs from a maintainer script, so install and
+// uninstall do not defer to the package manager that is running them.
+const ScriptEnv = "DNSTM_PACKAGE_SCRIPT"
+
+// configPath marks a configured install.
+const configPath = "/etc/dnstm/config.json"
+
+// Options configures a package build.
+type Options struct {
+	Format string
+	// Version is the package version; a leading "v" is dropped.
+	Version string
+	// Arch is a Go architecture name (amd64, arm64, arm, 386).
+	Arch string
+	// Binary is the dnstm binary to package.
+	Binary string
+	// OutputDir receives the package file.
+	OutputDir string
+}
+
+// debArches and rpmArches map Go architectures to package architectures.
+var (
+	debArches = map[string]string{"amd64": "amd64", "arm64": "arm64", "arm": "armhf", "386": "i386"}
+	rpmArches = map[string]string{"amd64": "x86_64", "arm64": "aarch64", "arm": "armv7hl", "386": "i686"}
+)
+
+// Build writes the package described by opts and returns its path. It needs
+// dpkg-deb for .deb packages and rpmbuild for .rpm packages.
+func Build(opts Options) (string, error) {
+	opts.Version = strings.TrimPrefix(opts.Version, "v")
+	if opts.Version == "" || opts.Version == "dev" {
+		return "", fmt.Errorf("a release version is required")
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = "."
+	}
+
+	work, err := os.MkdirTemp("", "dnstm-package-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(work)
+
+	switch opts.Format {
+	case FormatDeb:
+		return buildDeb(opts, work)
+	case FormatRPM:
+		return buildRPM(opts, work)
+	}
+	return "", fmt.Errorf("invalid format '%s' (must be '%s' or '%s')", opts.Format, FormatDeb, FormatRPM)
+}
+
+// buildDeb lays out the package tree in work and runs dpkg-deb on it.
+func buildDeb(opts Options, work string) (string, error) {
+	arch, ok := debArches[opts.Arch]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture '%s'", opts.Arch)
+	}
+	if err := copyBinary(opts.Binary, filepath.Join(work, BinaryPath)); err != nil {
+		return "", err
+	}
+	debian := filepath.Join(work, "DEBIAN")
+	if err := os.MkdirAll(debian, 0755); err != nil {
+		return "", err
+	}
+	files := map[string]string{
+		"postinst": postinst(FormatDeb),
+		"prerm":    prerm(FormatDeb),
+		"postrm":   postrm(FormatDeb),
+	}
+	for name, script := range files {
+		if err := os.WriteFile(filepath.Join(debian, name), []byte(script), 0755); err != nil {
+			return "", err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(debian, "control"), []byte(debControl(opts.Version, arch)), 0644); err != nil {
+		return "", err
+	}
+
+	out := filepath.Join(opts.OutputDir, fmt.Sprintf("dnstm_%s_%s.deb", opts.Version, arch))
+	if output, err := exec.Command("dpkg-deb", "--root-owner-group", "--build", work, out).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("dpkg-deb failed: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return out, nil
+}
+
+// buildRPM writes a spec file in work and runs rpmbuild with work as its
+// top directory.
+func buildRPM(opts Options, work string) (string, error) {
+	arch, ok := rpmArches[opts.Arch]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture '%s'", opts.Arch)
+	}
+	// RPM versions cannot contain dashes; "~" sorts pre-releases first
+	version := strings.ReplaceAll(opts.Version, "-", "~")
+	if err := copyBinary(opts.Binary, filepath.Join(work, "SOURCES", "dnstm")); err != nil {
+		return "", err
+	}
+	spec := filepath.Join(work, "dnstm.spec")
+	if err := os.WriteFile(spec, []byte(rpmSpec(version)), 0644); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("rpmbuild", "-bb", "--define", "_topdir "+work, "--target", arch, spec)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("rpmbuild failed: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	name := fmt.Sprintf("dnstm-%s-1.%s.rpm", version, arch)
+	out := filepath.Join(opts.OutputDir, name)
+	if err := copyFile(filepath.Join(work, "RPMS", arch, name), out, 0644); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// debControl renders the control file of the .deb package.
+func debControl(version, arch string) string {
+	return fmt.Sprintf(`Package: dnstm
+Version: %s
+Architecture: %s
+Maintainer: net2share
+Section: net
+Priority: optional
+Homepage: https://github.com/net2share/dnstm
+Description: DNS Tunnel Manager
+ Installs and manages DNS tunnel servers (dnstt, slipstream, vaydns)
+ behind a DNS router, with systemd services and firewall rules.
+`, version, arch)
+}
+
+// rpmSpec renders the spec file of the .rpm package. The binary is static,
+// so debug packages, stripping and dependency scanning are turned off.
+func rpmSpec(version string) string {
+	return fmt.Sprintf(`%%global debug_package %%{nil}
+%%global __os_install_post %%{nil}
+
+Name:       dnstm
+Version:    %s
+Release:    1
+Summary:    DNS Tunnel Manager
+License:    See https://github.com/net2share/dnstm
+URL:        https://github.com/net2share/dnstm
+AutoReqProv: no
+
+%%description
+Installs and manages DNS tunnel servers (dnstt, slipstream, vaydns)
+behind a DNS router, with systemd services and firewall rules.
+
+%%install
+install -D -m 0755 %%{_sourcedir}/dnstm %%{buildroot}%s
+
+%%files
+%s
+
+%%post
+%s
+%%preun
+%s
+%%postun
+%s`, version, BinaryPath, BinaryPath, scriptBody(postinst(FormatRPM)), scriptBody(prerm(FormatRPM)), scriptBody(postrm(FormatRPM)))
+}
+
+// scriptBody drops the interpreter line of a maintainer script for use as
+// an rpm scriptlet.
+func scriptBody(script string) string {
+	return strings.TrimPrefix(script, "#!/bin/sh\n")
+}
+
+// postinst refreshes the services of a configured install after the binary
+// is replaced. A new install only prints how to set dnstm up. deb passes
+// "configure"; rpm passes 1 on install and 2 on upgrade.
+func postinst(format string) string {
+	check := `[ "$1" = "configure" ]`
+	if format == FormatRPM {
+		check = `[ "$1" -ge 1 ]`
+	}
+	return fmt.Sprintf(`#!/bin/sh
+set -e
+if %s; then
+	if [ -f %s ]; then
+		%s=1 %s install || true
+	else
+		echo "Run 'dnstm install' to set up dnstm."
+	fi
+fi
+`, check, configPath, ScriptEnv, BinaryPath)
+}
+
+// prerm removes the services, configuration and firewall rules when the
+// package is removed, keeping tunnel keys for a reinstall. Upgrades leave
+// everything in place. deb passes "remove"; rpm passes 0 on removal.
+func prerm(format string) string {
+	check := `[ "$1" = "remove" ]`
+	if format == FormatRPM {
+		check = `[ "$1" -eq 0 ]`
+	}
+	return fmt.Sprintf(`#!/bin/sh
+set -e
+if %s && [ -f %s ]; then
+	%s=1 %s uninstall --force --keep-crypto || true
+fi
+`, check, configPath, ScriptEnv, BinaryPath)
+}
+
+// postrm deletes the kept tunnel keys when a .deb package is purged. rpm has
+// no purge, so its scriptlet does nothing.
+func postrm(format string) string {
+	if format == FormatRPM {
+		return "#!/bin/sh\nexit 0\n"
+	}
+	return `#!/bin/sh
+set -e
+if [ "$1" = "purge" ]; then
+	rm -rf /etc/dnstm
+fi
+`
+}
+
+// Manager returns the format of the package that installed dnstm, or ""
+// when it was not installed from a package.
+func Manager() string {
+	if exec.Command("dpkg-query", "-S", BinaryPath).Run() == nil {
+		return FormatDeb
+	}
+	if exec.Command("rpm", "-qf", BinaryPath).Run() == nil {
+		return FormatRPM
+	}
+	return ""
+}
+
+// FromPackageScript reports whether dnstm runs from a maintainer script.
+func FromPackageScript() bool {
+	return os.Getenv(ScriptEnv) != ""
+}
+
+// UpgradeCommand returns the command upgrading a packaged dnstm.
+func UpgradeCommand(format string) string {
+	if format == FormatRPM {
+		return "dnf upgrade dnstm (or rpm -U with a newer package)"
+	}
+	return "apt install --only-upgrade dnstm (or dpkg -i with a newer package)"
+}
+
+// RemoveCommand returns the command removing a packaged dnstm.
+func RemoveCommand(format string) string {
+	if format == FormatRPM {
+		return "dnf remove dnstm"
+	}
+	return "apt remove dnstm (apt purge dnstm also deletes /etc/dnstm)"
+}
+
+// copyBinary copies the dnstm binary into the package tree.
+func copyBinary(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return copyFile(src, dst, 0755)
+}
+
+// copyFile copies src to dst with the given mode.
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,96 @@
+package packaging
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuild_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"dev version", Options{Format: FormatDeb, Version: "dev", Arch: "amd64"}, "release version"},
+		{"bad format", Options{Format: "apk", Version: "1.0.0", Arch: "amd64"}, "invalid format"},
+		{"bad deb arch", Options{Format: FormatDeb, Version: "1.0.0", Arch: "mips"}, "unsupported architecture"},
+		{"bad rpm arch", Options{Format: FormatRPM, Version: "1.0.0", Arch: "mips"}, "unsupported architecture"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Build(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Build() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScripts(t *testing.T) {
+	if s := postinst(FormatDeb); !strings.Contains(s, `if [ "$1" = "configure" ]; then`) ||
+		!strings.Contains(s, "DNSTM_PACKAGE_SCRIPT=1 /usr/local/bin/dnstm install") {
+		t.Errorf("deb postinst:\n%s", s)
+	}
+	if s := postinst(FormatRPM); !strings.Contains(s, `if [ "$1" -ge 1 ]; then`) {
+		t.Errorf("rpm postinst:\n%s", s)
+	}
+	if s := prerm(FormatDeb); !strings.Contains(s, `if [ "$1" = "remove" ] && [ -f /etc/dnstm/config.json ]; then`) ||
+		!strings.Contains(s, "dnstm uninstall --force --keep-crypto") {
+		t.Errorf("deb prerm:\n%s", s)
+	}
+	if s := prerm(FormatRPM); !strings.Contains(s, `if [ "$1" -eq 0 ]`) {
+		t.Errorf("rpm prerm:\n%s", s)
+	}
+	if s := postrm(FormatDeb); !strings.Contains(s, "rm -rf /etc/dnstm") {
+		t.Errorf("deb postrm:\n%s", s)
+	}
+	if s := postrm(FormatRPM); strings.Contains(s, "rm -rf") {
+		t.Errorf("rpm postrm should not delete anything:\n%s", s)
+	}
+}
+
+func TestRPMSpec(t *testing.T) {
+	spec := rpmSpec("1.2.0~rc1")
+	for _, want := range []string{
+		"Version:    1.2.0~rc1\n",
+		"install -D -m 0755 %{_sourcedir}/dnstm %{buildroot}/usr/local/bin/dnstm\n",
+		"%files\n/usr/local/bin/dnstm\n",
+		"%preun\nset -e\n",
+	} {
+		if !strings.Contains(spec, want) {
+			t.Errorf("spec missing %q:\n%s", want, spec)
+		}
+	}
+	if strings.Contains(spec, "#!/bin/sh") {
+		t.Errorf("scriptlets should not have an interpreter line:\n%s", spec)
+	}
+}
+
+func TestBuild_Deb(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb not installed")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "dnstm")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := Build(Options{Format: FormatDeb, Version: "v1.2.0", Arch: "arm", Binary: bin, OutputDir: dir})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if want := filepath.Join(dir, "dnstm_1.2.0_armhf.deb"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	output, err := exec.Command("dpkg-deb", "--contents", path).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "./usr/local/bin/dnstm") {
+		t.Errorf("package contents:\n%s", output)
+	}
+}
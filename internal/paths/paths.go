@@ -0,0 +1,97 @@
+// Package paths holds the filesystem locations, config store and service
+// name prefix that dnstm uses.
+//
+// Each one can be overridden by an environment variable, read once at
+// startup, so dnstm can be tested in CI containers and run on systems with
+// a non-standard layout such as NixOS or immutable distributions. Services
+// and timers created by dnstm inherit the overrides (see Overrides).
+package paths
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Environment variables overriding the defaults.
+const (
+	ConfigDirEnv     = "DNSTM_CONFIG_DIR"
+	TunnelsDirEnv    = "DNSTM_TUNNELS_DIR"
+	BinDirEnv        = "DNSTM_BIN_DIR"
+	ServicePrefixEnv = "DNSTM_SERVICE_PREFIX"
+	StoreEnv         = "DNSTM_STORE"
+)
+
+var (
+	// ConfigDir holds config.json and the other dnstm settings.
+	ConfigDir = fromEnv(ConfigDirEnv, "/etc/dnstm")
+	// TunnelsDir holds a directory per tunnel with its certificate and keys.
+	TunnelsDir = fromEnv(TunnelsDirEnv, filepath.Join(ConfigDir, "tunnels"))
+	// BinDir holds the dnstm binary and the transport binaries it installs.
+	BinDir = fromEnv(BinDirEnv, "/usr/local/bin")
+	// ServicePrefix starts the name of every dnstm service and timer.
+	ServicePrefix = fromEnv(ServicePrefixEnv, "dnstm-")
+	// Store is the URL of a shared config store, e.g. etcd://host:2379/dnstm;
+	// empty keeps config.json in ConfigDir (see config.OpenStore).
+	Store = os.Getenv(StoreEnv)
+)
+
+// fromEnv returns the value of an environment variable, or def when it is
+// unset or empty. Directories are made absolute, as services do not run in
+// the caller's working directory.
+func fromEnv(name, def string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	if name != ServicePrefixEnv {
+		if abs, err := filepath.Abs(value); err == nil {
+			value = abs
+		}
+	}
+	return value
+}
+
+// Bin returns the path of a binary in BinDir.
+func Bin(name string) string {
+	return filepath.Join(BinDir, name)
+}
+
+// Service returns the full name of a dnstm service, e.g. "dnstm-" + name.
+func Service(name string) string {
+	return ServicePrefix + name
+}
+
+// systemServices holds the services and timers registered by SystemService.
+var systemServices = make(map[string]bool)
+
+// SystemService is Service for a service or timer of dnstm itself, which
+// belongs to no tunnel. The packages owning such units name them with it, so
+// that orphan detection skips them and no tunnel tag can take their names.
+func SystemService(name string) string {
+	full := Service(name)
+	systemServices[full] = true
+	return full
+}
+
+// IsSystemService reports whether full is a name returned by SystemService.
+func IsSystemService(full string) bool {
+	return systemServices[full]
+}
+
+// Overrides returns the overrides that are set, as NAME=value pairs, so
+// services started by dnstm see the same layout.
+func Overrides() []string {
+	var env []string
+	for _, o := range []struct{ name, value string }{
+		{ConfigDirEnv, ConfigDir},
+		{TunnelsDirEnv, TunnelsDir},
+		{BinDirEnv, BinDir},
+		{ServicePrefixEnv, ServicePrefix},
+		{StoreEnv, Store},
+	} {
+		if os.Getenv(o.name) != "" {
+			env = append(env, o.name+"="+o.value)
+		}
+	}
+	return env
+}
@@ -0,0 +1,49 @@
+package paths
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(ConfigDirEnv, "")
+	if got := fromEnv(ConfigDirEnv, "/etc/dnstm"); got != "/etc/dnstm" {
+		t.Errorf("unset: got %q", got)
+	}
+
+	t.Setenv(ConfigDirEnv, "conf")
+	want, _ := filepath.Abs("conf")
+	if got := fromEnv(ConfigDirEnv, "/etc/dnstm"); got != want {
+		t.Errorf("relative dir: got %q, want %q", got, want)
+	}
+
+	t.Setenv(ServicePrefixEnv, "ci-")
+	if got := fromEnv(ServicePrefixEnv, "dnstm-"); got != "ci-" {
+		t.Errorf("prefix: got %q, want ci-", got)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	for _, name := range []string{ConfigDirEnv, TunnelsDirEnv, BinDirEnv, ServicePrefixEnv, StoreEnv} {
+		t.Setenv(name, "")
+	}
+	if got := Overrides(); got != nil {
+		t.Errorf("no overrides: got %q", got)
+	}
+
+	// Overrides reports the values read at startup, not the raw variable
+	t.Setenv(BinDirEnv, "bin")
+	if got, want := Overrides(), []string{BinDirEnv + "=" + BinDir}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	if got, want := Bin("ssserver"), filepath.Join(BinDir, "ssserver"); got != want {
+		t.Errorf("Bin() = %q, want %q", got, want)
+	}
+	if got, want := Service("usage"), ServicePrefix+"usage"; got != want {
+		t.Errorf("Service() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+// Package presets provides the embedded catalog of install presets.
+//
+// A preset describes an operating mode plus a set of backends and tunnels
+// that "dnstm install --preset" creates in one run. Tunnel domains are
+// derived from a base domain given at install time.
+package presets
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+//go:embed presets.json
+var catalogJSON []byte
+
+// Preset is a named install profile.
+type Preset struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Mode        string        `json:"mode"`
+	Backends    []BackendSpec `json:"backends,omitempty"`
+	Tunnels     []TunnelSpec  `json:"tunnels"`
+}
+
+// BackendSpec describes a backend created by a preset.
+type BackendSpec struct {
+	Tag    string             `json:"tag"`
+	Type   config.BackendType `json:"type"`
+	Method string             `json:"method,omitempty"`
+}
+
+// TunnelSpec describes a tunnel created by a preset.
+type TunnelSpec struct {
+	Tag       string               `json:"tag"`
+	Transport config.TransportType `json:"transport"`
+	Backend   string               `json:"backend"`
+	Subdomain string               `json:"subdomain"`
+}
+
+// builtinBackends are created by install and may be referenced without being declared.
+var builtinBackends = map[string]config.BackendType{
+	"socks": config.BackendSOCKS,
+	"ssh":   config.BackendSSH,
+}
+
+// List returns all presets in catalog order.
+func List() ([]Preset, error) {
+	var presets []Preset
+	if err := json.Unmarshal(catalogJSON, &presets); err != nil {
+		return nil, fmt.Errorf("failed to parse preset catalog: %w", err)
+	}
+	return presets, nil
+}
+
+// Get returns the preset with the given name.
+func Get(name string) (*Preset, error) {
+	presets, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := range presets {
+		if presets[i].Name == name {
+			return &presets[i], nil
+		}
+		names = append(names, presets[i].Name)
+	}
+	return nil, fmt.Errorf("unknown preset '%s' (available: %s)", name, strings.Join(names, ", "))
+}
+
+// Validate checks that the preset is internally consistent.
+func (p *Preset) Validate() error {
+	if p.Mode != "single" && p.Mode != "multi" {
+		return fmt.Errorf("preset '%s': invalid mode '%s'", p.Name, p.Mode)
+	}
+	if len(p.Tunnels) == 0 {
+		return fmt.Errorf("preset '%s': no tunnels", p.Name)
+	}
+	if p.Mode == "single" && len(p.Tunnels) > 1 {
+		return fmt.Errorf("preset '%s': single mode allows one tunnel", p.Name)
+	}
+
+	backends := make(map[string]config.BackendType, len(builtinBackends)+len(p.Backends))
+	for tag, typ := range builtinBackends {
+		backends[tag] = typ
+	}
+	for _, b := range p.Backends {
+		if _, exists := backends[b.Tag]; exists {
+			return fmt.Errorf("preset '%s': duplicate backend '%s'", p.Name, b.Tag)
+		}
+		if b.Type != config.BackendShadowsocks {
+			return fmt.Errorf("preset '%s': backend '%s' has unsupported type '%s'", p.Name, b.Tag, b.Type)
+		}
+		backends[b.Tag] = b.Type
+	}
+
+	tags := make(map[string]bool)
+	subdomains := make(map[string]bool)
+	for _, t := range p.Tunnels {
+		if tags[t.Tag] {
+			return fmt.Errorf("preset '%s': duplicate tunnel '%s'", p.Name, t.Tag)
+		}
+		tags[t.Tag] = true
+		if subdomains[t.Subdomain] {
+			return fmt.Errorf("preset '%s': duplicate subdomain '%s'", p.Name, t.Subdomain)
+		}
+		subdomains[t.Subdomain] = true
+
+		typ, ok := backends[t.Backend]
+		if !ok {
+			return fmt.Errorf("preset '%s': tunnel '%s' references unknown backend '%s'", p.Name, t.Tag, t.Backend)
+		}
+		switch t.Transport {
+		case config.TransportSlipstream:
+		case config.TransportDNSTT, config.TransportVayDNS:
+			if typ == config.BackendShadowsocks {
+				return fmt.Errorf("preset '%s': tunnel '%s': %s does not support Shadowsocks", p.Name, t.Tag, t.Transport)
+			}
+		default:
+			return fmt.Errorf("preset '%s': tunnel '%s' has invalid transport '%s'", p.Name, t.Tag, t.Transport)
+		}
+	}
+	return nil
+}
+
+// BackendConfig returns the backend configuration for a spec.
+func (b BackendSpec) BackendConfig(password string) config.BackendConfig {
+	backend := config.BackendConfig{
+		Tag:  b.Tag,
+		Type: b.Type,
+	}
+	if b.Type == config.BackendShadowsocks {
+		backend.Shadowsocks = &config.ShadowsocksConfig{
+			Password: password,
+			Method:   b.Method,
+		}
+	}
+	return backend
+}
+
+// TunnelConfig returns the tunnel configuration for a spec under the given
+// base domain. The port is left for the caller to allocate.
+func (t TunnelSpec) TunnelConfig(baseDomain string) *config.TunnelConfig {
+	tunnel := &config.TunnelConfig{
+		Tag:       t.Tag,
+		Transport: t.Transport,
+		Backend:   t.Backend,
+		Domain:    t.Subdomain + "." + strings.TrimSuffix(baseDomain, "."),
+	}
+	switch t.Transport {
+	case config.TransportDNSTT:
+		tunnel.DNSTT = &config.DNSTTConfig{MTU: 1232}
+	case config.TransportVayDNS:
+		tunnel.VayDNS = &config.VayDNSConfig{MTU: 1232}
+	}
+	return tunnel
+}
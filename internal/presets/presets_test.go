@@ -0,0 +1,134 @@
+package presets
+
+import (
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func TestCatalog_Valid(t *testing.T) {
+	presets, err := List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(presets) == 0 {
+		t.Fatal("catalog is empty")
+	}
+
+	names := make(map[string]bool)
+	for _, p := range presets {
+		if names[p.Name] {
+			t.Errorf("duplicate preset name %q", p.Name)
+		}
+		names[p.Name] = true
+		if err := p.Validate(); err != nil {
+			t.Errorf("Validate() error: %v", err)
+		}
+	}
+}
+
+func TestCatalog_ProducesValidConfig(t *testing.T) {
+	presets, err := List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+
+	for _, p := range presets {
+		t.Run(p.Name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.EnsureBuiltinBackends()
+			cfg.Route.Mode = p.Mode
+
+			for _, b := range p.Backends {
+				cfg.Backends = append(cfg.Backends, b.BackendConfig("secret"))
+			}
+			for _, spec := range p.Tunnels {
+				tunnel := spec.TunnelConfig("example.com")
+				port, err := cfg.AllocateNextPort()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tunnel.Port = port
+				cfg.Tunnels = append(cfg.Tunnels, *tunnel)
+			}
+			if p.Mode == "single" {
+				cfg.Route.Active = cfg.Tunnels[0].Tag
+			} else {
+				cfg.Route.Default = cfg.Tunnels[0].Tag
+			}
+
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("Validate() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	p, err := Get("ssh-basic")
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if p.Name != "ssh-basic" {
+		t.Errorf("Name = %q, want ssh-basic", p.Name)
+	}
+
+	if _, err := Get("nope"); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset Preset
+	}{
+		{
+			name: "single mode with two tunnels",
+			preset: Preset{Name: "x", Mode: "single", Tunnels: []TunnelSpec{
+				{Tag: "a", Transport: config.TransportDNSTT, Backend: "ssh", Subdomain: "a"},
+				{Tag: "b", Transport: config.TransportDNSTT, Backend: "ssh", Subdomain: "b"},
+			}},
+		},
+		{
+			name: "unknown backend",
+			preset: Preset{Name: "x", Mode: "single", Tunnels: []TunnelSpec{
+				{Tag: "a", Transport: config.TransportDNSTT, Backend: "nope", Subdomain: "a"},
+			}},
+		},
+		{
+			name: "dnstt with shadowsocks",
+			preset: Preset{Name: "x", Mode: "single",
+				Backends: []BackendSpec{{Tag: "ss", Type: config.BackendShadowsocks}},
+				Tunnels: []TunnelSpec{
+					{Tag: "a", Transport: config.TransportDNSTT, Backend: "ss", Subdomain: "a"},
+				}},
+		},
+		{
+			name: "duplicate subdomain",
+			preset: Preset{Name: "x", Mode: "multi", Tunnels: []TunnelSpec{
+				{Tag: "a", Transport: config.TransportDNSTT, Backend: "ssh", Subdomain: "t"},
+				{Tag: "b", Transport: config.TransportSlipstream, Backend: "socks", Subdomain: "t"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.preset.Validate(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestTunnelConfig_Domain(t *testing.T) {
+	spec := TunnelSpec{Tag: "a", Transport: config.TransportVayDNS, Backend: "ssh", Subdomain: "v"}
+	tunnel := spec.TunnelConfig("example.com.")
+	if tunnel.Domain != "v.example.com" {
+		t.Errorf("Domain = %q, want v.example.com", tunnel.Domain)
+	}
+	if tunnel.VayDNS == nil || tunnel.VayDNS.MTU != 1232 {
+		t.Errorf("VayDNS = %+v, want MTU 1232", tunnel.VayDNS)
+	}
+}
@@ -0,0 +1,95 @@
+// Package privacy anonymizes the client IP addresses dnstm records, for
+// operators who must not keep identifying data about tunnel users.
+//
+// Truncation keeps the network of an address (the /24 of IPv4, the /48 of
+// IPv6), which is enough to tell resolvers and providers apart. Hashing
+// replaces it with a keyed hash, so the records of one address can still be
+// told apart from others without revealing it; without the salt the hash
+// cannot be reversed by hashing every address.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"regexp"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// Prefix lengths kept by truncation.
+const (
+	truncateBits4 = 24
+	truncateBits6 = 48
+)
+
+// hashPrefix starts a hashed address, so it is not taken for a hostname.
+const hashPrefix = "anon-"
+
+// Anonymizer hides IP addresses. A nil Anonymizer leaves them as they are.
+type Anonymizer struct {
+	mode string
+	key  []byte
+}
+
+// New returns the anonymizer of p, or nil when p is nil.
+func New(p *config.PrivacyConfig) *Anonymizer {
+	if p == nil {
+		return nil
+	}
+	key, _ := hex.DecodeString(p.Salt)
+	return &Anonymizer{mode: p.IP, key: key}
+}
+
+// IP anonymizes an address, with or without a port. Anything else is
+// returned as is.
+func (a *Anonymizer) IP(s string) string {
+	if a == nil {
+		return s
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return a.addr(addr)
+	}
+	if host, port, err := net.SplitHostPort(s); err == nil {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			return net.JoinHostPort(a.addr(addr), port)
+		}
+	}
+	return s
+}
+
+func (a *Anonymizer) addr(addr netip.Addr) string {
+	addr = addr.Unmap().WithZone("")
+	if a.mode == config.PrivacyHash {
+		mac := hmac.New(sha256.New, a.key)
+		b := addr.As16()
+		mac.Write(b[:])
+		return hashPrefix + hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	bits := truncateBits6
+	if addr.Is4() {
+		bits = truncateBits4
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix.Addr().String()
+}
+
+// candidates matches what may be an IPv4 or IPv6 address in text; Text
+// only replaces the ones that parse.
+var candidates = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}(?:(?:\d{1,3}\.){3}\d{1,3})?`)
+
+// Text anonymizes every IP address in s, such as a log message.
+func (a *Anonymizer) Text(s string) string {
+	if a == nil {
+		return s
+	}
+	return candidates.ReplaceAllStringFunc(s, func(m string) string {
+		addr, err := netip.ParseAddr(m)
+		if err != nil {
+			return m
+		}
+		return a.addr(addr)
+	})
+}
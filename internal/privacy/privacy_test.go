@@ -0,0 +1,62 @@
+package privacy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+const testSalt = "000102030405060708090a0b0c0d0e0f"
+
+func TestTruncate(t *testing.T) {
+	a := New(&config.PrivacyConfig{IP: config.PrivacyTruncate})
+	tests := map[string]string{
+		"203.0.113.77":             "203.0.113.0",
+		"203.0.113.77:5353":        "203.0.113.0:5353",
+		"::ffff:203.0.113.77":      "203.0.113.0",
+		"2001:db8:abcd:12::1":      "2001:db8:abcd::",
+		"[2001:db8:abcd:12::1]:53": "[2001:db8:abcd::]:53",
+		"resolver.example.com":     "resolver.example.com",
+		"":                         "",
+	}
+	for in, want := range tests {
+		if got := a.IP(in); got != want {
+			t.Errorf("IP(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	a := New(&config.PrivacyConfig{IP: config.PrivacyHash, Salt: testSalt})
+	h1, h2 := a.IP("203.0.113.77"), a.IP("203.0.113.78")
+	if !strings.HasPrefix(h1, hashPrefix) || len(h1) != len(hashPrefix)+16 {
+		t.Errorf("IP() = %q", h1)
+	}
+	if h1 == h2 {
+		t.Error("different addresses hash the same")
+	}
+	if a.IP("::ffff:203.0.113.77") != h1 {
+		t.Error("a mapped IPv4 address hashes differently")
+	}
+	other := New(&config.PrivacyConfig{IP: config.PrivacyHash, Salt: strings.Repeat("ff", 16)})
+	if other.IP("203.0.113.77") == h1 {
+		t.Error("the salt does not change the hash")
+	}
+}
+
+func TestText(t *testing.T) {
+	a := New(&config.PrivacyConfig{IP: config.PrivacyTruncate})
+	in := "12:00:01 query from 198.51.100.23:4021 via [2001:db8:1:2::9]:53, version 1.2.3, mac aa:bb:cc:dd:ee:ff"
+	want := "12:00:01 query from 198.51.100.0:4021 via [2001:db8:1::]:53, version 1.2.3, mac aa:bb:cc:dd:ee:ff"
+	if got := a.Text(in); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNil(t *testing.T) {
+	a := New(nil)
+	if a.IP("203.0.113.77") != "203.0.113.77" || a.Text("from 203.0.113.77") != "from 203.0.113.77" {
+		t.Error("a nil anonymizer changed an address")
+	}
+}
@@ -0,0 +1,272 @@
+// Package prompt runs the interactive dialogs used by the menus and handlers.
+//
+// By default dialogs are the full-screen tui widgets. In plain mode they
+// become numbered, line-based prompts without ANSI colors or cursor control,
+// for screen readers, serial consoles and terminals the TUI cannot drive.
+//
+// Without a terminal (cron, pipes, "ssh host dnstm ...") or with --yes or
+// --non-interactive, dnstm runs headless: nothing waits for Enter, and
+// dialogs that need an answer fail with ErrNoTerminal instead of blocking on
+// stdin. Without a terminal, output is plain as well.
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/net2share/dnstm/internal/i18n"
+	"github.com/net2share/go-corelib/tui"
+)
+
+// PlainEnv enables plain mode when set to a non-empty value other than "0".
+const PlainEnv = "DNSTM_PLAIN"
+
+// ErrNoTerminal is returned by dialogs that need an answer when running
+// headless.
+var ErrNoTerminal = errors.New("prompts are disabled (no terminal, --yes or --non-interactive)")
+
+var (
+	plain    bool
+	headless bool
+	input              = bufio.NewReader(os.Stdin)
+	output   io.Writer = os.Stdout
+)
+
+// SetPlain switches plain mode on or off. Turning it on also disables colors.
+func SetPlain(on bool) {
+	plain = on
+	if on {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// IsPlain reports whether plain mode is active.
+func IsPlain() bool {
+	return plain
+}
+
+// SetHeadless switches headless mode on or off.
+func SetHeadless(on bool) {
+	headless = on
+}
+
+// IsHeadless reports whether dialogs are disabled.
+func IsHeadless() bool {
+	return headless
+}
+
+// IsTerminal reports whether both stdin and stdout are terminals.
+func IsTerminal() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// DetectPlain reports whether the environment asks for plain mode:
+// $DNSTM_PLAIN is set, or the terminal is "dumb".
+func DetectPlain() bool {
+	if v := os.Getenv(PlainEnv); v != "" && v != "0" {
+		return true
+	}
+	return os.Getenv("TERM") == "dumb"
+}
+
+// RunMenu shows a menu and returns the selected value, or "" if the user
+// backs out.
+func RunMenu(cfg tui.MenuConfig) (string, error) {
+	if headless {
+		return "", ErrNoTerminal
+	}
+	if !plain {
+		return tui.RunMenu(cfg)
+	}
+
+	fmt.Fprintln(output)
+	printHeading(cfg.Title, cfg.Description)
+
+	var values []string
+	for _, opt := range cfg.Options {
+		switch {
+		case opt.Separator && opt.Label == "":
+			fmt.Fprintln(output)
+		case opt.Separator:
+			fmt.Fprintf(output, "  %s:\n", opt.Label)
+		default:
+			values = append(values, opt.Value)
+			fmt.Fprintf(output, "  %d) %s\n", len(values), opt.Label)
+		}
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	for {
+		line, ok := readLine(i18n.Tf("Choose 1-%d (0 to go back): ", len(values)))
+		if !ok || line == "0" || strings.EqualFold(line, "q") {
+			return "", nil
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(values) {
+			return values[n-1], nil
+		}
+		fmt.Fprintln(output, i18n.Tf("Enter a number between 1 and %d.", len(values)))
+	}
+}
+
+// RunInput asks for a line of text. It returns the value and false if the
+// user cancelled.
+func RunInput(cfg tui.InputConfig) (string, bool, error) {
+	if headless {
+		return "", false, ErrNoTerminal
+	}
+	if !plain {
+		return tui.RunInput(cfg)
+	}
+
+	fmt.Fprintln(output)
+	printHeading("", cfg.Description)
+
+	label := cfg.Title
+	switch {
+	case cfg.Value != "" && !cfg.Password:
+		label += " [" + cfg.Value + "]"
+	case cfg.Placeholder != "":
+		label += " (" + cfg.Placeholder + ")"
+	}
+	// Input is echoed; plain mode has no raw terminal to hide it with
+	line, ok := readLine(label + ": ")
+	if !ok {
+		return "", false, nil
+	}
+	if line == "" {
+		line = cfg.Value
+	}
+	return line, true, nil
+}
+
+// RunConfirm asks a yes/no question.
+func RunConfirm(cfg tui.ConfirmConfig) (bool, error) {
+	if headless {
+		return false, ErrNoTerminal
+	}
+	if !plain {
+		return tui.RunConfirm(cfg)
+	}
+
+	fmt.Fprintln(output)
+	printHeading("", cfg.Description)
+
+	choices := "y/N"
+	if cfg.Default {
+		choices = "Y/n"
+	}
+	for {
+		line, ok := readLine(fmt.Sprintf("%s [%s]: ", cfg.Title, choices))
+		if !ok {
+			return false, nil
+		}
+		switch strings.ToLower(line) {
+		case "":
+			return cfg.Default, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(output, i18n.T("Answer y or n."))
+	}
+}
+
+// ShowMessage shows a message and waits for the user to acknowledge it.
+func ShowMessage(msg tui.AppMessage) error {
+	if !plain && !headless {
+		return tui.ShowMessage(msg)
+	}
+
+	prefix := ""
+	switch msg.Type {
+	case "error":
+		prefix = i18n.T("Error: ")
+	case "warning":
+		prefix = i18n.T("Warning: ")
+	}
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, prefix+msg.Message)
+	WaitForEnter()
+	return nil
+}
+
+// ShowInfo shows titled sections of key/value rows.
+func ShowInfo(cfg tui.InfoConfig) error {
+	if !plain && !headless {
+		return tui.ShowInfo(cfg)
+	}
+
+	fmt.Fprintln(output)
+	printHeading(cfg.Title, cfg.Description)
+	for _, section := range cfg.Sections {
+		if section.Title != "" {
+			fmt.Fprintf(output, "%s:\n", section.Title)
+		}
+		for _, row := range section.Rows {
+			switch {
+			case len(row.Columns) > 0:
+				fmt.Fprintf(output, "  %s\n", strings.Join(row.Columns, "  "))
+			case row.Key == "":
+				fmt.Fprintf(output, "  %s\n", row.Value)
+			default:
+				fmt.Fprintf(output, "  %s: %s\n", row.Key, row.Value)
+			}
+		}
+		fmt.Fprintln(output)
+	}
+	WaitForEnter()
+	return nil
+}
+
+// WaitForEnter waits for the user to press Enter. It returns at once when
+// running headless.
+func WaitForEnter() {
+	if headless {
+		return
+	}
+	if !plain {
+		tui.WaitForEnter()
+		return
+	}
+	readLine("\n" + i18n.T("Press Enter to continue..."))
+}
+
+// printHeading prints a dialog title and description, skipping empty ones.
+func printHeading(title, description string) {
+	if title != "" {
+		fmt.Fprintln(output, title)
+	}
+	if description != "" {
+		fmt.Fprintln(output, description)
+	}
+	if title != "" || description != "" {
+		fmt.Fprintln(output)
+	}
+}
+
+// readLine prints label and reads one trimmed line. ok is false at end of
+// input, which callers treat as cancel.
+func readLine(label string) (string, bool) {
+	fmt.Fprint(output, label)
+	line, err := input.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(output)
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
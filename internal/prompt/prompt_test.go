@@ -0,0 +1,116 @@
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/net2share/go-corelib/tui"
+)
+
+// withInput runs fn in plain mode reading the given input, and returns what
+// was printed.
+func withInput(t *testing.T, in string, fn func()) string {
+	t.Helper()
+	oldIn, oldOut, oldPlain := input, output, plain
+	defer func() { input, output, plain = oldIn, oldOut, oldPlain }()
+
+	var out strings.Builder
+	input, output, plain = bufio.NewReader(strings.NewReader(in)), &out, true
+	fn()
+	return out.String()
+}
+
+func TestRunMenu_Plain(t *testing.T) {
+	cfg := tui.MenuConfig{
+		Title: "Tunnels",
+		Options: []tui.MenuOption{
+			{Label: "Add", Value: "add"},
+			{Label: "", Separator: true},
+			{Label: "Configured", Separator: true},
+			{Label: "main", Value: "tunnel:main"},
+		},
+	}
+
+	var got string
+	out := withInput(t, "9\nx\n2\n", func() { got, _ = RunMenu(cfg) })
+	if got != "tunnel:main" {
+		t.Errorf("RunMenu() = %q, want tunnel:main", got)
+	}
+	for _, want := range []string{"  1) Add\n", "  Configured:\n", "  2) main\n", "Enter a number between 1 and 2."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "\033[") {
+		t.Errorf("output contains escape sequences:\n%q", out)
+	}
+
+	for _, in := range []string{"0\n", ""} {
+		withInput(t, in, func() { got, _ = RunMenu(cfg) })
+		if got != "" {
+			t.Errorf("RunMenu() with input %q = %q, want cancel", in, got)
+		}
+	}
+}
+
+func TestRunInput_Plain(t *testing.T) {
+	var val string
+	var ok bool
+	withInput(t, "\n", func() { val, ok, _ = RunInput(tui.InputConfig{Title: "MTU", Value: "1232"}) })
+	if !ok || val != "1232" {
+		t.Errorf("RunInput() = %q, %v; want the default", val, ok)
+	}
+	withInput(t, " t.example.com \n", func() { val, ok, _ = RunInput(tui.InputConfig{Title: "Domain"}) })
+	if !ok || val != "t.example.com" {
+		t.Errorf("RunInput() = %q, %v", val, ok)
+	}
+	withInput(t, "", func() { _, ok, _ = RunInput(tui.InputConfig{Title: "Domain"}) })
+	if ok {
+		t.Error("RunInput() at end of input was not cancelled")
+	}
+}
+
+func TestRunConfirm_Plain(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		def  bool
+		want bool
+	}{
+		{"\n", true, true},
+		{"\n", false, false},
+		{"maybe\nyes\n", false, true},
+		{"N\n", true, false},
+		{"", true, false},
+	} {
+		var got bool
+		withInput(t, tc.in, func() { got, _ = RunConfirm(tui.ConfirmConfig{Title: "Remove tunnel?", Default: tc.def}) })
+		if got != tc.want {
+			t.Errorf("RunConfirm(%q, default %v) = %v, want %v", tc.in, tc.def, got, tc.want)
+		}
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	defer func() { headless = false }()
+
+	out := withInput(t, "y\n", func() {
+		headless = true
+		if _, err := RunConfirm(tui.ConfirmConfig{Title: "Remove tunnel?", Default: true}); !errors.Is(err, ErrNoTerminal) {
+			t.Errorf("RunConfirm() error = %v, want ErrNoTerminal", err)
+		}
+		if _, err := RunMenu(tui.MenuConfig{Options: []tui.MenuOption{{Label: "Add", Value: "add"}}}); !errors.Is(err, ErrNoTerminal) {
+			t.Errorf("RunMenu() error = %v, want ErrNoTerminal", err)
+		}
+		if err := ShowMessage(tui.AppMessage{Message: "Tunnel started"}); err != nil {
+			t.Errorf("ShowMessage() error = %v", err)
+		}
+		if line, _ := input.ReadString('\n'); line != "y\n" {
+			t.Errorf("input was consumed, left %q", line)
+		}
+	})
+	if !strings.Contains(out, "Tunnel started") || strings.Contains(out, "Press Enter") {
+		t.Errorf("ShowMessage() waited for Enter:\n%s", out)
+	}
+}
@@ -0,0 +1,8 @@
+package gosocks
+
+import "syscall"
+
+// bindToDevice restricts a socket to a network interface.
+func bindToDevice(fd uintptr, iface string) error {
+	return syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package gosocks
+
+import "errors"
+
+// bindToDevice is only supported on Linux; elsewhere bind to a source
+// address instead.
+func bindToDevice(fd uintptr, iface string) error {
+	return errors.New("binding to an interface requires Linux (SO_BINDTODEVICE); use a source address instead")
+}
@@ -0,0 +1,623 @@
+// Package gosocks provides a lightweight SOCKS5 server used as a built-in
+// alternative to microsocks.
+package gosocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/net2share/dnstm/internal/egress"
+)
+
+// SOCKS5 protocol constants (RFC 1928, RFC 1929).
+const (
+	socksVersion = 0x05
+
+	methodNoAuth       = 0x00
+	methodUserPass     = 0x02
+	methodNoAcceptable = 0xFF
+
+	userPassVersion = 0x01
+
+	cmdConnect = 0x01
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+
+	repSucceeded          = 0x00
+	repGeneralFailure     = 0x01
+	repNotAllowed         = 0x02
+	repNetworkUnreachable = 0x03
+	repHostUnreachable    = 0x04
+	repConnRefused        = 0x05
+	repCmdNotSupported    = 0x07
+	repAddrNotSupported   = 0x08
+)
+
+const (
+	defaultDialTimeout      = 10 * time.Second
+	defaultHandshakeTimeout = 30 * time.Second
+)
+
+// Config configures the SOCKS5 server.
+type Config struct {
+	ListenAddr string
+
+	// User and Password enable username/password authentication when both are set.
+	User     string
+	Password string
+
+	// MaxConnsPerIP limits concurrent connections from a single client IP (0 = unlimited).
+	MaxConnsPerIP int
+
+	// Rules are evaluated in order against each destination; the first match wins.
+	// Destinations that match no rule are allowed.
+	Rules []egress.Rule
+
+	// BindAddr sets the source address of outgoing connections. Destinations
+	// of the other address family are skipped.
+	BindAddr net.IP
+
+	// BindInterface binds outgoing sockets to a network device (SO_BINDTODEVICE).
+	BindInterface string
+
+	DialTimeout time.Duration
+
+	// KeepAlive tunes TCP keepalive on client and outgoing connections when
+	// Enable is set; otherwise Go's defaults apply.
+	KeepAlive net.KeepAliveConfig
+
+	// IdleTimeout closes a connection after no data moved in either direction
+	// for this long (0 = never).
+	IdleTimeout time.Duration
+}
+
+// Stats contains server counters.
+type Stats struct {
+	ActiveConns   int64  `json:"active_conns"`
+	TotalConns    int64  `json:"total_conns"`
+	RejectedConns int64  `json:"rejected_conns"`
+	AuthFailures  int64  `json:"auth_failures"`
+	DeniedDests   int64  `json:"denied_dests"`
+	DialFailures  int64  `json:"dial_failures"`
+	BytesIn       uint64 `json:"bytes_in"`
+	BytesOut      uint64 `json:"bytes_out"`
+}
+
+// Server is a SOCKS5 server supporting the CONNECT command.
+type Server struct {
+	config   Config
+	listener net.Listener
+	dialer   *net.Dialer
+	resolver *net.Resolver
+
+	mu    sync.Mutex
+	perIP map[string]int
+	// conns holds the client and target side of every open connection,
+	// so Close can end relays whose upstream is idle.
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+
+	activeConns   atomic.Int64
+	totalConns    atomic.Int64
+	rejectedConns atomic.Int64
+	authFailures  atomic.Int64
+	deniedDests   atomic.Int64
+	dialFailures  atomic.Int64
+	bytesIn       atomic.Uint64
+	bytesOut      atomic.Uint64
+}
+
+// New creates a new SOCKS5 server.
+func New(cfg Config) *Server {
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	return &Server{
+		config:   cfg,
+		dialer:   newDialer(cfg),
+		resolver: net.DefaultResolver,
+		perIP:    make(map[string]int),
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the configured address and serves connections.
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.ListenAddr, err)
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until the server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	log.Printf("SOCKS5 server listening on %s", l.Addr())
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(50 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		if tc, ok := conn.(*net.TCPConn); ok && s.config.KeepAlive.Enable {
+			tc.SetKeepAliveConfig(s.config.KeepAlive)
+		}
+
+		if !s.acquire(conn) {
+			s.rejectedConns.Add(1)
+			conn.Close()
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.release(conn)
+			s.handleConn(conn)
+		}()
+	}
+}
+
+// Addr returns the listener address, or nil if not serving.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close stops the listener and closes all active connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Stats returns a snapshot of the server counters.
+func (s *Server) Stats() Stats {
+	return Stats{
+		ActiveConns:   s.activeConns.Load(),
+		TotalConns:    s.totalConns.Load(),
+		RejectedConns: s.rejectedConns.Load(),
+		AuthFailures:  s.authFailures.Load(),
+		DeniedDests:   s.deniedDests.Load(),
+		DialFailures:  s.dialFailures.Load(),
+		BytesIn:       s.bytesIn.Load(),
+		BytesOut:      s.bytesOut.Load(),
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// acquire registers a connection, enforcing the per-IP limit.
+func (s *Server) acquire(conn net.Conn) bool {
+	ip := remoteIP(conn)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.config.MaxConnsPerIP > 0 && s.perIP[ip] >= s.config.MaxConnsPerIP {
+		return false
+	}
+	s.perIP[ip]++
+	s.conns[conn] = struct{}{}
+	s.activeConns.Add(1)
+	s.totalConns.Add(1)
+	return true
+}
+
+// release unregisters a connection.
+func (s *Server) release(conn net.Conn) {
+	conn.Close()
+	ip := remoteIP(conn)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.perIP[ip] <= 1 {
+		delete(s.perIP, ip)
+	} else {
+		s.perIP[ip]--
+	}
+	delete(s.conns, conn)
+	s.activeConns.Add(-1)
+}
+
+// track registers the target side of a connection so Close reaches it.
+func (s *Server) track(target net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[target] = struct{}{}
+	return true
+}
+
+// untrack closes and unregisters the target side of a connection.
+func (s *Server) untrack(target net.Conn) {
+	target.Close()
+	s.mu.Lock()
+	delete(s.conns, target)
+	s.mu.Unlock()
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// handleConn runs the SOCKS5 handshake and relays data.
+func (s *Server) handleConn(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(defaultHandshakeTimeout))
+
+	if err := s.negotiate(conn); err != nil {
+		return
+	}
+
+	host, port, err := readRequest(conn)
+	if err != nil {
+		var re *replyError
+		if errors.As(err, &re) {
+			writeReply(conn, re.code)
+		}
+		return
+	}
+
+	target, code := s.connect(host, port)
+	if target == nil {
+		writeReply(conn, code)
+		return
+	}
+	if !s.track(target) {
+		target.Close()
+		return
+	}
+	defer s.untrack(target)
+
+	if err := writeReply(conn, repSucceeded); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	s.relay(conn, target)
+}
+
+// negotiate performs method selection and optional username/password auth.
+func (s *Server) negotiate(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socksVersion {
+		return fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	requireAuth := s.config.User != "" && s.config.Password != ""
+	want := byte(methodNoAuth)
+	if requireAuth {
+		want = methodUserPass
+	}
+
+	offered := false
+	for _, m := range methods {
+		if m == want {
+			offered = true
+			break
+		}
+	}
+	if !offered {
+		conn.Write([]byte{socksVersion, methodNoAcceptable})
+		if requireAuth {
+			s.authFailures.Add(1)
+		}
+		return fmt.Errorf("no acceptable auth method")
+	}
+	if _, err := conn.Write([]byte{socksVersion, want}); err != nil {
+		return err
+	}
+
+	if !requireAuth {
+		return nil
+	}
+	return s.authenticate(conn)
+}
+
+// authenticate handles RFC 1929 username/password authentication.
+func (s *Server) authenticate(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != userPassVersion {
+		return fmt.Errorf("unsupported auth version %d", header[0])
+	}
+	user := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, user); err != nil {
+		return err
+	}
+	plen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, plen); err != nil {
+		return err
+	}
+	pass := make([]byte, plen[0])
+	if _, err := io.ReadFull(conn, pass); err != nil {
+		return err
+	}
+
+	if string(user) != s.config.User || string(pass) != s.config.Password {
+		s.authFailures.Add(1)
+		conn.Write([]byte{userPassVersion, 0x01})
+		return fmt.Errorf("authentication failed")
+	}
+	_, err := conn.Write([]byte{userPassVersion, 0x00})
+	return err
+}
+
+// replyError carries the SOCKS reply code for a failed request.
+type replyError struct {
+	code byte
+	msg  string
+}
+
+func (e *replyError) Error() string { return e.msg }
+
+// readRequest parses a SOCKS5 request and returns the destination.
+func readRequest(r io.Reader) (string, int, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", 0, err
+	}
+	if header[0] != socksVersion {
+		return "", 0, fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	if header[1] != cmdConnect {
+		return "", 0, &replyError{code: repCmdNotSupported, msg: "command not supported"}
+	}
+
+	var host string
+	switch header[3] {
+	case atypIPv4:
+		addr := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(r, addr); err != nil {
+			return "", 0, err
+		}
+		host = net.IP(addr).String()
+	case atypIPv6:
+		addr := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(r, addr); err != nil {
+			return "", 0, err
+		}
+		host = net.IP(addr).String()
+	case atypDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(r, l); err != nil {
+			return "", 0, err
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(r, name); err != nil {
+			return "", 0, err
+		}
+		host = string(name)
+	default:
+		return "", 0, &replyError{code: repAddrNotSupported, msg: "address type not supported"}
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(r, portBytes); err != nil {
+		return "", 0, err
+	}
+	port := int(portBytes[0])<<8 | int(portBytes[1])
+
+	return host, port, nil
+}
+
+// connect resolves the destination, applies rules, and dials it.
+// Returns a nil conn and the reply code on failure.
+func (s *Server) connect(host string, port int) (net.Conn, byte) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.DialTimeout)
+		addrs, err := s.resolver.LookupIPAddr(ctx, host)
+		cancel()
+		if err != nil || len(addrs) == 0 {
+			s.dialFailures.Add(1)
+			return nil, repHostUnreachable
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+
+	// Every resolved address must be permitted so DNS cannot be used to
+	// smuggle a denied destination through an allowed name.
+	for _, ip := range ips {
+		if !egress.Allowed(s.config.Rules, ip, port) {
+			s.deniedDests.Add(1)
+			return nil, repNotAllowed
+		}
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		if s.config.BindAddr != nil && (ip.To4() == nil) != (s.config.BindAddr.To4() == nil) {
+			continue
+		}
+		conn, err := s.dialer.Dial("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+		if err == nil {
+			return conn, repSucceeded
+		}
+		lastErr = err
+	}
+
+	s.dialFailures.Add(1)
+	if lastErr == nil {
+		return nil, repHostUnreachable
+	}
+	return nil, dialErrorCode(lastErr)
+}
+
+// newDialer creates the dialer for outgoing connections.
+func newDialer(cfg Config) *net.Dialer {
+	d := &net.Dialer{Timeout: cfg.DialTimeout, KeepAliveConfig: cfg.KeepAlive}
+	if cfg.BindAddr != nil {
+		d.LocalAddr = &net.TCPAddr{IP: cfg.BindAddr}
+	}
+	if cfg.BindInterface != "" {
+		iface := cfg.BindInterface
+		d.Control = func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) {
+				sockErr = bindToDevice(fd, iface)
+			}); err != nil {
+				return err
+			}
+			if sockErr != nil {
+				return fmt.Errorf("failed to bind to interface %s: %w", iface, sockErr)
+			}
+			return nil
+		}
+	}
+	return d
+}
+
+// dialErrorCode maps a dial error to a SOCKS5 reply code.
+func dialErrorCode(err error) byte {
+	var ne net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return repConnRefused
+	case errors.Is(err, syscall.ENETUNREACH):
+		return repNetworkUnreachable
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return repHostUnreachable
+	case errors.As(err, &ne) && ne.Timeout():
+		return repHostUnreachable
+	default:
+		return repGeneralFailure
+	}
+}
+
+// writeReply sends a SOCKS5 reply with an unspecified bind address.
+func writeReply(w io.Writer, code byte) error {
+	_, err := w.Write([]byte{socksVersion, code, 0x00, atypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// relay copies data in both directions until either side closes, or until
+// both have been idle for the idle timeout.
+func (s *Server) relay(client, target net.Conn) {
+	done := make(chan struct{}, 2)
+
+	var fromClient, fromTarget io.Reader = client, target
+	if timeout := s.config.IdleTimeout; timeout > 0 {
+		touch := func() {
+			deadline := time.Now().Add(timeout)
+			client.SetDeadline(deadline)
+			target.SetDeadline(deadline)
+		}
+		touch()
+		fromClient = &idleReader{Reader: client, touch: touch}
+		fromTarget = &idleReader{Reader: target, touch: touch}
+	}
+
+	go func() {
+		n, _ := io.Copy(target, fromClient)
+		s.bytesIn.Add(uint64(n))
+		closeWrite(target)
+		done <- struct{}{}
+	}()
+	go func() {
+		n, _ := io.Copy(client, fromTarget)
+		s.bytesOut.Add(uint64(n))
+		closeWrite(client)
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+}
+
+// idleReader pushes the idle deadline of a relay back whenever data arrives.
+type idleReader struct {
+	io.Reader
+	touch func()
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.touch()
+	}
+	return n, err
+}
+
+func closeWrite(c net.Conn) {
+	if tc, ok := c.(*net.TCPConn); ok {
+		tc.CloseWrite()
+		return
+	}
+	c.Close()
+}
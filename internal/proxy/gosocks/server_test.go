@@ -0,0 +1,267 @@
+package gosocks
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/egress"
+)
+
+// startEcho starts a TCP echo server and returns its address.
+func startEcho(t *testing.T) *net.TCPAddr {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr)
+}
+
+// startServer starts a SOCKS5 server on a random port.
+func startServer(t *testing.T, cfg Config) *Server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := New(cfg)
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	deadline := time.Now().Add(time.Second)
+	for s.Addr() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return s
+}
+
+// dialSocks performs a SOCKS5 handshake and CONNECT, returning the reply code.
+func dialSocks(t *testing.T, proxy net.Addr, user, pass string, dest *net.TCPAddr) (net.Conn, byte) {
+	t.Helper()
+	c, err := net.Dial("tcp", proxy.String())
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	method := byte(methodNoAuth)
+	if user != "" {
+		method = methodUserPass
+	}
+	c.Write([]byte{socksVersion, 1, method})
+	resp := make([]byte, 2)
+	if _, err := io.ReadFull(c, resp); err != nil {
+		c.Close()
+		return nil, 0xFF
+	}
+	if resp[1] != method {
+		c.Close()
+		return nil, resp[1]
+	}
+
+	if user != "" {
+		req := []byte{userPassVersion, byte(len(user))}
+		req = append(req, user...)
+		req = append(req, byte(len(pass)))
+		req = append(req, pass...)
+		c.Write(req)
+		if _, err := io.ReadFull(c, resp); err != nil || resp[1] != 0x00 {
+			c.Close()
+			return nil, 0xFF
+		}
+	}
+
+	req := []byte{socksVersion, cmdConnect, 0x00, atypIPv4}
+	req = append(req, dest.IP.To4()...)
+	req = append(req, byte(dest.Port>>8), byte(dest.Port))
+	c.Write(req)
+
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(c, reply); err != nil {
+		c.Close()
+		return nil, 0xFF
+	}
+	if reply[1] != repSucceeded {
+		c.Close()
+		return nil, reply[1]
+	}
+	return c, repSucceeded
+}
+
+func TestServer_Connect(t *testing.T) {
+	echo := startEcho(t)
+	s := startServer(t, Config{})
+
+	c, code := dialSocks(t, s.Addr(), "", "", echo)
+	if code != repSucceeded {
+		t.Fatalf("CONNECT reply = %d, want success", code)
+	}
+	defer c.Close()
+
+	msg := []byte("hello through socks")
+	c.Write(msg)
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatalf("failed to read echo: %v", err)
+	}
+	if !bytes.Equal(buf, msg) {
+		t.Errorf("echo = %q, want %q", buf, msg)
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	echo := startEcho(t)
+	s := startServer(t, Config{User: "alice", Password: "secret"})
+
+	if c, code := dialSocks(t, s.Addr(), "alice", "secret", echo); code != repSucceeded {
+		t.Errorf("valid credentials: reply = %d, want success", code)
+	} else {
+		c.Close()
+	}
+
+	if _, code := dialSocks(t, s.Addr(), "alice", "wrong", echo); code == repSucceeded {
+		t.Error("invalid password should be rejected")
+	}
+
+	if _, code := dialSocks(t, s.Addr(), "", "", echo); code != methodNoAcceptable {
+		t.Errorf("no-auth method: reply = %d, want %d", code, methodNoAcceptable)
+	}
+
+	if got := s.Stats().AuthFailures; got != 2 {
+		t.Errorf("AuthFailures = %d, want 2", got)
+	}
+}
+
+func TestServer_DenyRule(t *testing.T) {
+	echo := startEcho(t)
+	rules, err := egress.ParseRules([]string{"deny 127.0.0.0/8 " + strconv.Itoa(echo.Port)})
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	s := startServer(t, Config{Rules: rules})
+
+	if _, code := dialSocks(t, s.Addr(), "", "", echo); code != repNotAllowed {
+		t.Errorf("denied destination: reply = %d, want %d", code, repNotAllowed)
+	}
+	if got := s.Stats().DeniedDests; got != 1 {
+		t.Errorf("DeniedDests = %d, want 1", got)
+	}
+}
+
+func TestServer_BindAddr(t *testing.T) {
+	echo := startEcho(t)
+	s := startServer(t, Config{BindAddr: net.ParseIP("127.0.0.1")})
+
+	if conn, code := dialSocks(t, s.Addr(), "", "", echo); code != repSucceeded {
+		t.Errorf("matching family: reply = %d, want %d", code, repSucceeded)
+	} else {
+		conn.Close()
+	}
+
+	if _, code := s.connect("::1", echo.Port); code != repHostUnreachable {
+		t.Errorf("other family: reply = %d, want %d", code, repHostUnreachable)
+	}
+}
+
+func TestServer_MaxConnsPerIP(t *testing.T) {
+	echo := startEcho(t)
+	s := startServer(t, Config{MaxConnsPerIP: 1})
+
+	first, code := dialSocks(t, s.Addr(), "", "", echo)
+	if code != repSucceeded {
+		t.Fatalf("first connection: reply = %d, want success", code)
+	}
+
+	if _, code := dialSocks(t, s.Addr(), "", "", echo); code == repSucceeded {
+		t.Error("second concurrent connection should be rejected")
+	}
+
+	first.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Stats().ActiveConns > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c, code := dialSocks(t, s.Addr(), "", "", echo)
+	if code != repSucceeded {
+		t.Fatalf("connection after release: reply = %d, want success", code)
+	}
+	c.Close()
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	echo := startEcho(t)
+	s := startServer(t, Config{IdleTimeout: 200 * time.Millisecond})
+
+	c, code := dialSocks(t, s.Addr(), "", "", echo)
+	if code != repSucceeded {
+		t.Fatalf("CONNECT reply = %d, want success", code)
+	}
+	defer c.Close()
+
+	// Traffic keeps the connection open past the timeout
+	buf := make([]byte, 4)
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		c.Write([]byte("ping"))
+		if _, err := io.ReadFull(c, buf); err != nil {
+			t.Fatalf("active connection closed after %d round trips: %v", i, err)
+		}
+	}
+
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.Read(buf); err != io.EOF {
+		t.Errorf("idle connection: read error = %v, want EOF", err)
+	}
+}
+
+func TestServer_CloseIdleUpstream(t *testing.T) {
+	// The upstream accepts and then neither reads nor closes
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	s := startServer(t, Config{})
+	c, code := dialSocks(t, s.Addr(), "", "", l.Addr().(*net.TCPAddr))
+	if code != repSucceeded {
+		t.Fatalf("CONNECT reply = %d, want success", code)
+	}
+	defer c.Close()
+
+	closed := make(chan error, 1)
+	go func() { closed <- s.Close() }()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() blocked on a relay with an idle upstream")
+	}
+}
@@ -4,11 +4,10 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
-	"os/exec"
-	"strings"
 
 	"github.com/net2share/dnstm/internal/binary"
 	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/system"
 )
 
 const (
@@ -30,26 +29,48 @@ func ConfigureMicrosocks(port int) error {
 
 // ConfigureMicrosocksWithAuth creates the systemd service for microsocks with optional authentication.
 func ConfigureMicrosocksWithAuth(port int, user, password string) error {
+	return configureMicrosocks(port, user, password, "")
+}
+
+// configureMicrosocks creates the microsocks service. A non-empty bindAddr
+// sets the source address of outgoing connections.
+func configureMicrosocks(port int, user, password, bindAddr string) error {
+	if err := system.CreateSystemUser(system.SocksUser); err != nil {
+		return err
+	}
+	cfg, err := microsocksService(port, user, password, bindAddr)
+	if err != nil {
+		return err
+	}
+	return service.CreateGenericService(cfg)
+}
+
+// microsocksService returns the microsocks unit configuration.
+func microsocksService(port int, user, password, bindAddr string) (*service.ServiceConfig, error) {
 	mgr := binary.NewDefaultManager()
 	binaryPath, err := mgr.GetPath(binary.BinaryMicrosocks)
 	if err != nil {
-		return fmt.Errorf("microsocks binary not found: %w", err)
+		return nil, fmt.Errorf("microsocks binary not found: %w", err)
 	}
 
 	execStart := fmt.Sprintf("%s -i %s -p %d -q", binaryPath, MicrosocksBindAddr, port)
 	if user != "" && password != "" {
 		execStart = fmt.Sprintf("%s -i %s -p %d -q -u %s -P %s", binaryPath, MicrosocksBindAddr, port, user, password)
 	}
+	if bindAddr != "" {
+		execStart += " -b " + bindAddr
+	}
 
-	return service.CreateGenericService(&service.ServiceConfig{
+	return &service.ServiceConfig{
 		Name:             MicrosocksServiceName,
 		Description:      "Microsocks SOCKS5 Proxy",
-		User:             "nobody",
-		Group:            getNobodyGroup(),
+		User:             system.SocksUser,
+		Group:            system.SocksUser,
 		ExecStart:        execStart,
+		ExecStartPre:     []string{egressApplyCommand},
 		ReadOnlyPaths:    []string{binaryPath},
 		BindToPrivileged: false,
-	})
+	}, nil
 }
 
 // ReconfigureMicrosocks reconfigures and restarts microsocks with the given auth settings.
@@ -113,18 +134,6 @@ func IsMicrosocksRunning() bool {
 	return service.IsServiceActive(MicrosocksServiceName)
 }
 
-// getNobodyGroup returns the appropriate "nobody" group for the current system.
-// Debian/Ubuntu use "nogroup", RHEL/Fedora use "nobody".
-func getNobodyGroup() string {
-	// Check if nogroup exists (Debian/Ubuntu)
-	out, err := exec.Command("getent", "group", "nogroup").Output()
-	if err == nil && strings.HasPrefix(string(out), "nogroup:") {
-		return "nogroup"
-	}
-	// Fall back to nobody (RHEL/Fedora)
-	return "nobody"
-}
-
 // UninstallMicrosocks removes the microsocks binary and service.
 func UninstallMicrosocks() error {
 	if service.IsServiceActive(MicrosocksServiceName) {
@@ -134,6 +143,7 @@ func UninstallMicrosocks() error {
 		service.DisableService(MicrosocksServiceName)
 	}
 	service.RemoveService(MicrosocksServiceName)
+	system.RemoveSystemUser(system.SocksUser)
 	// Note: We don't remove the binary as it's managed by the binary manager
 	return nil
 }
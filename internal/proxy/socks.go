@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/egress"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+// dnstmBinaryPath is the installed dnstm binary used by the built-in engine.
+var dnstmBinaryPath = paths.Bin("dnstm")
+
+// egressApplyCommand syncs the nftables egress rules before the proxy starts.
+// The "+" prefix runs it with full privileges while the proxy itself does not.
+var egressApplyCommand = "+" + dnstmBinaryPath + " socks apply-egress"
+
+// ConfigureSocks creates the SOCKS proxy service for the configured engine,
+// taking credentials and outbound binding from the socks backend.
+// Both engines share the microsocks unit so lifecycle helpers work for either.
+func ConfigureSocks(cfg *config.Config) error {
+	if cfg.Proxy.IsBuiltinEngine() {
+		return ConfigureBuiltinSocks()
+	}
+	return configureMicrosocks(microsocksArgs(cfg))
+}
+
+// microsocksArgs returns the port, credentials and outbound address of the
+// socks backend.
+func microsocksArgs(cfg *config.Config) (port int, user, password, bindAddr string) {
+	port = cfg.Proxy.Port
+	if port == 0 {
+		port = 1080
+	}
+	if b := cfg.GetBackendByTag("socks"); b != nil {
+		if b.HasSocksAuth() {
+			user, password = b.Socks.User, b.Socks.Password
+		}
+		if b.Outbound != nil {
+			bindAddr = b.Outbound.SourceIP
+		}
+	}
+	return port, user, password, bindAddr
+}
+
+// DetachMicrosocks rewrites the microsocks unit without the pre-start hook
+// that runs dnstm, so microsocks keeps working once dnstm is uninstalled.
+// Egress rules are no longer applied after that.
+func DetachMicrosocks(cfg *config.Config) error {
+	if cfg.Proxy.IsBuiltinEngine() {
+		return fmt.Errorf("the built-in SOCKS5 server is part of dnstm and cannot be kept")
+	}
+	svc, err := microsocksService(microsocksArgs(cfg))
+	if err != nil {
+		return err
+	}
+	svc.ExecStartPre = nil
+	return service.CreateGenericService(svc)
+}
+
+// ReconfigureSocks reconfigures and restarts the SOCKS proxy service.
+func ReconfigureSocks(cfg *config.Config) error {
+	if err := ConfigureSocks(cfg); err != nil {
+		return err
+	}
+	return RestartMicrosocks()
+}
+
+// ConfigureBuiltinSocks creates the systemd service for the built-in SOCKS5 server.
+// The server reads its port, credentials, and limits from the dnstm config.
+func ConfigureBuiltinSocks() error {
+	// The state directory must exist for ReadWritePaths
+	if err := os.MkdirAll(SocksStateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", SocksStateDir, err)
+	}
+	if err := system.ChownToDnstm(SocksStateDir); err != nil {
+		return fmt.Errorf("failed to set ownership of %s: %w", SocksStateDir, err)
+	}
+
+	return service.CreateGenericService(&service.ServiceConfig{
+		Name:             MicrosocksServiceName,
+		Description:      "DNSTM SOCKS5 Proxy (built-in)",
+		User:             system.DnstmUser,
+		Group:            system.DnstmUser,
+		ExecStart:        dnstmBinaryPath + " socks serve",
+		ExecStartPre:     []string{egressApplyCommand},
+		ReadOnlyPaths:    []string{config.ConfigDir},
+		ReadWritePaths:   []string{SocksStateDir},
+		BindToPrivileged: false,
+	})
+}
+
+// ApplySocksEgress enforces the egress rules of the SOCKS backend for the
+// configured engine. The built-in server checks rules itself, so nftables
+// rules are only installed for microsocks and removed otherwise. They match
+// the connections of system.SocksUser, which only microsocks runs as.
+func ApplySocksEgress(cfg *config.Config) error {
+	if cfg.Proxy.IsBuiltinEngine() {
+		return network.RemoveEgressRules()
+	}
+
+	var rules []egress.Rule
+	if b := cfg.GetBackendByTag("socks"); b != nil {
+		var err error
+		if rules, err = b.EgressRules(); err != nil {
+			return err
+		}
+	}
+	// Units written before microsocks had its own user still run it as
+	// nobody; nft needs the user to exist either way
+	if len(rules) > 0 {
+		if err := system.CreateSystemUser(system.SocksUser); err != nil {
+			return err
+		}
+	}
+	return network.ApplyEgressRules(system.SocksUser, rules)
+}
+
+// IsSocksAvailable checks if the configured SOCKS engine can be run.
+func IsSocksAvailable(proxyCfg config.ProxyConfig) bool {
+	if proxyCfg.IsBuiltinEngine() {
+		return true
+	}
+	return IsMicrosocksInstalled()
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/net2share/dnstm/internal/proxy/gosocks"
+)
+
+// SocksStateDir holds the state of the built-in SOCKS server.
+const SocksStateDir = "/var/lib/dnstm/socks"
+
+// SocksStatsFile holds the latest counters of the built-in SOCKS server.
+var SocksStatsFile = filepath.Join(SocksStateDir, "stats.json")
+
+// SocksStats are the counters of a running built-in SOCKS server. They
+// start at zero each time the server starts.
+type SocksStats struct {
+	gosocks.Stats
+	Updated time.Time `json:"updated"`
+}
+
+// SaveSocksStats writes s to SocksStatsFile.
+func SaveSocksStats(s *SocksStats) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := SocksStatsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, SocksStatsFile)
+}
+
+// LoadSocksStats reads the counters last written by the built-in SOCKS
+// server. A missing file yields nil.
+func LoadSocksStats() (*SocksStats, error) {
+	data, err := os.ReadFile(SocksStatsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SOCKS stats: %w", err)
+	}
+	var s SocksStats
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SocksStatsFile, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,158 @@
+// Package quarantine tracks tunnels that systemd stopped restarting after a
+// crash loop.
+//
+// Tunnel units carry a start rate limit (StartLimitBurst within
+// StartLimitIntervalSec). When a tunnel keeps crashing and hits that limit,
+// systemd puts the unit in the failed state and activates the OnFailure hook
+// unit, which runs "dnstm quarantine-hook". The hook records the quarantine
+// here, disables the unit so it stays down across reboots, and alerts the
+// operator. The quarantine is lifted with "dnstm tunnel unquarantine".
+package quarantine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// Dir holds one record per quarantined tunnel.
+var Dir = "/var/lib/dnstm/quarantine"
+
+var (
+	// HookName is the template unit activated by OnFailure= of tunnel units.
+	HookName = paths.SystemService("quarantine@")
+
+	// OnFailure is the OnFailure= value for tunnel units; %n expands to the
+	// failing unit's full name.
+	OnFailure = HookName + "%n.service"
+)
+
+const (
+	// ResultStartLimitHit is the unit Result systemd reports once it gave up
+	// restarting a service.
+	ResultStartLimitHit = "start-limit-hit"
+)
+
+// Record describes a quarantined tunnel.
+type Record struct {
+	Tag      string    `json:"tag"`
+	Since    time.Time `json:"since"`
+	Restarts string    `json:"restarts,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+func recordPath(tag string) string {
+	return filepath.Join(Dir, tag+".json")
+}
+
+// Mark records tag as quarantined.
+func Mark(r *Record) error {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(recordPath(r.Tag), data, 0600)
+}
+
+// Get returns the quarantine record for tag, or nil if it is not quarantined.
+func Get(tag string) *Record {
+	data, err := os.ReadFile(recordPath(tag))
+	if err != nil {
+		return nil
+	}
+	var r Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		// A corrupt record still means the tunnel was quarantined
+		return &Record{Tag: tag}
+	}
+	return &r
+}
+
+// IsQuarantined reports whether tag is quarantined.
+func IsQuarantined(tag string) bool {
+	_, err := os.Stat(recordPath(tag))
+	return err == nil
+}
+
+// Clear removes the quarantine record for tag.
+func Clear(tag string) error {
+	if err := os.Remove(recordPath(tag)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear quarantine: %w", err)
+	}
+	return nil
+}
+
+// TagFromUnit returns the tunnel tag for a unit name such as "dnstm-foo.service".
+func TagFromUnit(unit string) (string, bool) {
+	name := strings.TrimSuffix(unit, ".service")
+	if !strings.HasPrefix(name, paths.ServicePrefix) || name == unit {
+		return "", false
+	}
+	return strings.TrimPrefix(name, paths.ServicePrefix), true
+}
+
+// HandleFailure is run by the hook unit when a tunnel unit enters the failed
+// state. Only failures caused by the start rate limit are quarantined; it
+// returns nil for any other failure.
+func HandleFailure(unit string) (*Record, error) {
+	tag, ok := TagFromUnit(unit)
+	if !ok {
+		return nil, fmt.Errorf("not a tunnel unit: %s", unit)
+	}
+	if service.GetUnitProperty(unit, "Result") != ResultStartLimitHit {
+		return nil, nil
+	}
+
+	r := &Record{
+		Tag:      tag,
+		Since:    time.Now().UTC(),
+		Restarts: service.GetUnitProperty(unit, "NRestarts"),
+		Reason:   "crash loop: start rate limit hit",
+	}
+	if err := Mark(r); err != nil {
+		return nil, err
+	}
+	// Keep the tunnel down across reboots until it is unquarantined
+	service.DisableService(strings.TrimSuffix(unit, ".service"))
+	return r, nil
+}
+
+// EnsureHookUnit installs the OnFailure template unit if it is missing.
+func EnsureHookUnit(execStart string) error {
+	if !service.UsesSystemd() {
+		// Without systemd the service host restarts tunnels without a limit
+		return nil
+	}
+	path := service.GetServicePath(HookName)
+	unit := fmt.Sprintf(`[Unit]
+Description=dnstm crash-loop quarantine for %%i
+
+[Service]
+Type=oneshot
+ExecStart=%s quarantine-hook %%i
+`, cmdline.Quote(execStart))
+
+	if data, err := os.ReadFile(path); err == nil && string(data) == unit {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to write quarantine hook unit: %w", err)
+	}
+	return service.DaemonReload()
+}
+
+// RemoveHookUnit removes the OnFailure template unit and all records.
+func RemoveHookUnit() error {
+	os.RemoveAll(Dir)
+	return service.RemoveService(HookName)
+}
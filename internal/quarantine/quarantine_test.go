@@ -0,0 +1,54 @@
+package quarantine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTagFromUnit(t *testing.T) {
+	tests := []struct {
+		unit string
+		tag  string
+		ok   bool
+	}{
+		{"dnstm-ssh-tunnel.service", "ssh-tunnel", true},
+		{"dnstm-a", "", false},
+		{"sshd.service", "", false},
+	}
+	for _, tt := range tests {
+		tag, ok := TagFromUnit(tt.unit)
+		if tag != tt.tag || ok != tt.ok {
+			t.Errorf("TagFromUnit(%q) = %q, %v; want %q, %v", tt.unit, tag, ok, tt.tag, tt.ok)
+		}
+	}
+}
+
+func TestMarkGetClear(t *testing.T) {
+	Dir = t.TempDir()
+
+	if IsQuarantined("t1") || Get("t1") != nil {
+		t.Fatal("t1 should not be quarantined")
+	}
+
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := Mark(&Record{Tag: "t1", Since: since, Restarts: "10"}); err != nil {
+		t.Fatalf("Mark() error: %v", err)
+	}
+	if !IsQuarantined("t1") {
+		t.Fatal("t1 should be quarantined")
+	}
+	r := Get("t1")
+	if r == nil || !r.Since.Equal(since) || r.Restarts != "10" {
+		t.Errorf("Get() = %+v", r)
+	}
+
+	if err := Clear("t1"); err != nil {
+		t.Fatalf("Clear() error: %v", err)
+	}
+	if IsQuarantined("t1") {
+		t.Error("t1 should no longer be quarantined")
+	}
+	if err := Clear("t1"); err != nil {
+		t.Errorf("Clear() on missing record: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package remote runs dnstm commands on other servers over SSH.
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// HostEnv names the environment variable that selects a default remote host.
+const HostEnv = "DNSTM_HOST"
+
+// remoteBinary is the dnstm binary invoked on the remote server.
+const remoteBinary = "dnstm"
+
+// ExtractHost removes a --host/-H flag from args and returns its value along
+// with the remaining arguments. Arguments after "--" are left untouched.
+// If no flag is present, the host falls back to $DNSTM_HOST. A host starting
+// with "-" is rejected, as ssh would take it for an option.
+func ExtractHost(args []string) (string, []string, error) {
+	host := os.Getenv(HostEnv)
+	rest := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			rest = append(rest, args[i:]...)
+			return host, rest, checkHost(host)
+		case arg == "--host" || arg == "-H":
+			if i+1 >= len(args) || args[i+1] == "" {
+				return "", nil, fmt.Errorf("flag %s requires a value", arg)
+			}
+			host = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--host="):
+			host = strings.TrimPrefix(arg, "--host=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if err := checkHost(host); err != nil {
+		return "", nil, err
+	}
+	return host, rest, nil
+}
+
+// checkHost rejects hosts ssh would parse as options, such as
+// "-oProxyCommand=...".
+func checkHost(host string) error {
+	if strings.HasPrefix(host, "-") {
+		return fmt.Errorf("invalid host %q: must not start with '-'", host)
+	}
+	return nil
+}
+
+// Command builds the ssh invocation that runs dnstm with args on host.
+// A TTY is requested when stdin is a terminal so the interactive menu and
+// progress output work remotely. "--" ends the ssh options before the host,
+// so neither it nor the command is read as one.
+func Command(host string, args []string, tty bool) *exec.Cmd {
+	sshArgs := []string{}
+	if tty {
+		sshArgs = append(sshArgs, "-t")
+	}
+	sshArgs = append(sshArgs, "--", host, RemoteCommandLine(args))
+	return exec.Command("ssh", sshArgs...)
+}
+
+// RemoteCommandLine renders the shell command executed on the remote server.
+func RemoteCommandLine(args []string) string {
+	parts := []string{remoteBinary}
+	for _, a := range args {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Run executes dnstm on host over SSH, streaming output to the local
+// terminal, and returns the remote exit code.
+func Run(host string, args []string) (int, error) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		return 1, fmt.Errorf("ssh not found: remote management requires an OpenSSH client")
+	}
+
+	cmd := Command(host, args, isTerminal(os.Stdin))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		// 127 is the shell's "command not found"; 255 is an ssh connection failure
+		switch code {
+		case 127:
+			return code, fmt.Errorf("dnstm is not installed on %s (copy the binary to /usr/local/bin/dnstm first)", host)
+		case 255:
+			return code, fmt.Errorf("ssh connection to %s failed", host)
+		}
+		return code, nil
+	}
+	return 1, fmt.Errorf("failed to run ssh: %w", err)
+}
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,81 @@
+package remote
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractHost(t *testing.T) {
+	t.Setenv(HostEnv, "")
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantHost string
+		wantRest []string
+		wantErr  bool
+	}{
+		{name: "no host", args: []string{"tunnel", "list"}, wantHost: "", wantRest: []string{"tunnel", "list"}},
+		{name: "long flag", args: []string{"--host", "root@a", "tunnel", "list"}, wantHost: "root@a", wantRest: []string{"tunnel", "list"}},
+		{name: "short flag after command", args: []string{"tunnel", "list", "-H", "root@a"}, wantHost: "root@a", wantRest: []string{"tunnel", "list"}},
+		{name: "equals form", args: []string{"--host=root@a", "router", "status"}, wantHost: "root@a", wantRest: []string{"router", "status"}},
+		{name: "after double dash", args: []string{"tunnel", "--", "--host", "x"}, wantHost: "", wantRest: []string{"tunnel", "--", "--host", "x"}},
+		{name: "missing value", args: []string{"--host"}, wantErr: true},
+		{name: "option as host", args: []string{"--host", "-oProxyCommand=sh", "tunnel", "list"}, wantErr: true},
+		{name: "option as host, equals form", args: []string{"-H", "x", "--host=-oProxyCommand=sh"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, rest, err := ExtractHost(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("ExtractHost() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExtractHost() unexpected error: %v", err)
+			}
+			if host != tt.wantHost {
+				t.Errorf("host = %q, want %q", host, tt.wantHost)
+			}
+			if !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("rest = %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestExtractHost_Env(t *testing.T) {
+	t.Setenv(HostEnv, "root@env")
+
+	host, _, err := ExtractHost([]string{"tunnel", "list"})
+	if err != nil {
+		t.Fatalf("ExtractHost() unexpected error: %v", err)
+	}
+	if host != "root@env" {
+		t.Errorf("host = %q, want %q", host, "root@env")
+	}
+
+	host, _, _ = ExtractHost([]string{"--host", "root@flag"})
+	if host != "root@flag" {
+		t.Errorf("flag should override env: host = %q", host)
+	}
+}
+
+func TestRemoteCommandLine(t *testing.T) {
+	got := RemoteCommandLine([]string{"backend", "egress", "-t", "socks", "--rules", "deny 10.0.0.0/8; deny 0.0.0.0/0 25", "--password", "it's", ""})
+	want := `dnstm backend egress -t socks --rules 'deny 10.0.0.0/8; deny 0.0.0.0/0 25' --password 'it'\''s' ''`
+	if got != want {
+		t.Errorf("RemoteCommandLine() = %s, want %s", got, want)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	cmd := Command("root@server2", []string{"tunnel", "list"}, true)
+	want := []string{"ssh", "-t", "--", "root@server2", "dnstm tunnel list"}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("Command() args = %q, want %q", cmd.Args, want)
+	}
+}
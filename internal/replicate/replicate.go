@@ -0,0 +1,396 @@
+// Package replicate packages a server's tunnels for identical replicas.
+//
+// A bundle holds the configuration together with each tunnel's keys and
+// certificates, so further servers can serve the same domains with the same
+// crypto material (for DNS round robin or anycast) and every client config
+// keeps working against any of them. Settings tied to the origin host, such
+// as the addresses it binds, are dropped on export.
+package replicate
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/system"
+)
+
+// FormatVersion is the bundle layout version written by Write.
+const FormatVersion = 1
+
+const (
+	manifestFile = "manifest.json"
+	configFile   = "config.json"
+	tunnelsDir   = "tunnels"
+
+	// maxFileSize bounds a single bundle entry; crypto files are a few KB.
+	maxFileSize = 1 << 20
+)
+
+// Crypto file names inside a tunnel directory, as written by the certs and
+// keys packages.
+const (
+	certFile    = "cert.pem"
+	certKeyFile = "key.pem"
+	privKeyFile = "server.key"
+	pubKeyFile  = "server.pub"
+)
+
+// secretFiles are written readable by the owner only.
+var secretFiles = map[string]bool{certKeyFile: true, privKeyFile: true}
+
+// chownToDnstm hands an installed tunnel directory to the service user.
+var chownToDnstm = system.ChownDirToDnstm
+
+// Manifest describes a bundle.
+type Manifest struct {
+	Version      int       `json:"version"`
+	Created      time.Time `json:"created"`
+	Origin       string    `json:"origin,omitempty"`
+	DnstmVersion string    `json:"dnstm_version,omitempty"`
+	Tunnels      []string  `json:"tunnels"`
+	Dropped      []string  `json:"dropped,omitempty"`
+}
+
+// Bundle is a configuration with the crypto material of its tunnels.
+type Bundle struct {
+	Manifest Manifest
+	Config   *config.Config
+	// Files maps "tag/name" to the content of a tunnel's crypto file.
+	Files map[string][]byte
+}
+
+// Build collects cfg and the crypto files it references into a bundle.
+// Crypto paths are cleared in the bundled config and set again by Install;
+// host-bound settings are dropped and listed in Manifest.Dropped.
+func Build(cfg *config.Config, dnstmVersion string) (*Bundle, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	var copied config.Config
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+
+	origin, _ := os.Hostname()
+	b := &Bundle{
+		Manifest: Manifest{
+			Version:      FormatVersion,
+			Created:      time.Now().UTC(),
+			Origin:       origin,
+			DnstmVersion: dnstmVersion,
+		},
+		Config: &copied,
+		Files:  make(map[string][]byte),
+	}
+	b.dropHostSettings()
+
+	for i := range b.Config.Tunnels {
+		t := &b.Config.Tunnels[i]
+		if err := b.collectTunnel(t); err != nil {
+			return nil, fmt.Errorf("tunnel %s: %w", t.Tag, err)
+		}
+		b.Manifest.Tunnels = append(b.Manifest.Tunnels, t.Tag)
+	}
+	return b, nil
+}
+
+// dropHostSettings clears settings that name addresses or interfaces of the
+// origin host.
+func (b *Bundle) dropHostSettings() {
+	cfg := b.Config
+	if host, _, err := net.SplitHostPort(cfg.Listen.Address); err == nil {
+		if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+			b.Manifest.Dropped = append(b.Manifest.Dropped, "listen.address "+cfg.Listen.Address)
+			cfg.Listen.Address = ""
+		}
+	}
+	for i := range cfg.Tunnels {
+		t := &cfg.Tunnels[i]
+		if t.BindHost != "" {
+			b.Manifest.Dropped = append(b.Manifest.Dropped, fmt.Sprintf("tunnel %s: bind_host %s", t.Tag, t.BindHost))
+			t.BindHost = ""
+		}
+	}
+	for i := range cfg.Backends {
+		be := &cfg.Backends[i]
+		if be.Outbound != nil {
+			b.Manifest.Dropped = append(b.Manifest.Dropped, fmt.Sprintf("backend %s: outbound", be.Tag))
+			be.Outbound = nil
+		}
+	}
+}
+
+// collectTunnel reads the crypto files of t and clears their paths.
+func (b *Bundle) collectTunnel(t *config.TunnelConfig) error {
+	switch t.Transport {
+	case config.TransportSlipstream:
+		if t.Slipstream == nil || t.Slipstream.Cert == "" {
+			return fmt.Errorf("no certificate configured")
+		}
+		if err := b.addFiles(t.Tag, map[string]string{certFile: t.Slipstream.Cert, certKeyFile: t.Slipstream.Key}); err != nil {
+			return err
+		}
+		t.Slipstream.Cert, t.Slipstream.Key = "", ""
+	case config.TransportChisel:
+		if t.Chisel == nil || t.Chisel.Cert == "" {
+			return fmt.Errorf("no certificate configured")
+		}
+		if err := b.addFiles(t.Tag, map[string]string{certFile: t.Chisel.Cert, certKeyFile: t.Chisel.Key}); err != nil {
+			return err
+		}
+		t.Chisel.Cert, t.Chisel.Key = "", ""
+	case config.TransportDNSTT:
+		if t.DNSTT == nil || t.DNSTT.PrivateKey == "" {
+			return fmt.Errorf("no private key configured")
+		}
+		if err := b.addKeyPair(t.Tag, t.DNSTT.PrivateKey); err != nil {
+			return err
+		}
+		t.DNSTT.PrivateKey = ""
+	case config.TransportVayDNS:
+		if t.VayDNS == nil || t.VayDNS.PrivateKey == "" {
+			return fmt.Errorf("no private key configured")
+		}
+		if err := b.addKeyPair(t.Tag, t.VayDNS.PrivateKey); err != nil {
+			return err
+		}
+		t.VayDNS.PrivateKey = ""
+	}
+	return nil
+}
+
+// addKeyPair adds a Curve25519 private key and the public key next to it.
+func (b *Bundle) addKeyPair(tag, privateKey string) error {
+	return b.addFiles(tag, map[string]string{
+		privKeyFile: privateKey,
+		pubKeyFile:  filepath.Join(filepath.Dir(privateKey), pubKeyFile),
+	})
+}
+
+func (b *Bundle) addFiles(tag string, files map[string]string) error {
+	for name, src := range files {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", src, err)
+		}
+		b.Files[path.Join(tag, name)] = data
+	}
+	return nil
+}
+
+// Install writes the crypto files into dir/<tag>/ and points the bundled
+// config at them.
+func (b *Bundle) Install(dir string) error {
+	for i := range b.Config.Tunnels {
+		t := &b.Config.Tunnels[i]
+		tunnelDir := filepath.Join(dir, t.Tag)
+		if err := os.MkdirAll(tunnelDir, 0750); err != nil {
+			return fmt.Errorf("failed to create tunnel directory: %w", err)
+		}
+
+		written := false
+		for _, name := range []string{certFile, certKeyFile, privKeyFile, pubKeyFile} {
+			data, ok := b.Files[path.Join(t.Tag, name)]
+			if !ok {
+				continue
+			}
+			mode := os.FileMode(0644)
+			if secretFiles[name] {
+				mode = 0600
+			}
+			if err := os.WriteFile(filepath.Join(tunnelDir, name), data, mode); err != nil {
+				return fmt.Errorf("failed to write %s for %s: %w", name, t.Tag, err)
+			}
+			written = true
+		}
+		if !written {
+			continue
+		}
+		if err := chownToDnstm(tunnelDir); err != nil {
+			return fmt.Errorf("failed to set ownership of %s: %w", tunnelDir, err)
+		}
+
+		switch t.Transport {
+		case config.TransportSlipstream:
+			if t.Slipstream == nil {
+				t.Slipstream = &config.SlipstreamConfig{}
+			}
+			t.Slipstream.Cert = filepath.Join(tunnelDir, certFile)
+			t.Slipstream.Key = filepath.Join(tunnelDir, certKeyFile)
+		case config.TransportChisel:
+			if t.Chisel == nil {
+				t.Chisel = &config.ChiselConfig{}
+			}
+			t.Chisel.Cert = filepath.Join(tunnelDir, certFile)
+			t.Chisel.Key = filepath.Join(tunnelDir, certKeyFile)
+		case config.TransportDNSTT:
+			if t.DNSTT == nil {
+				t.DNSTT = &config.DNSTTConfig{}
+			}
+			t.DNSTT.PrivateKey = filepath.Join(tunnelDir, privKeyFile)
+		case config.TransportVayDNS:
+			if t.VayDNS == nil {
+				t.VayDNS = &config.VayDNSConfig{}
+			}
+			t.VayDNS.PrivateKey = filepath.Join(tunnelDir, privKeyFile)
+		}
+	}
+	return nil
+}
+
+// Write stores the bundle as a gzipped tarball readable by root only.
+func (b *Bundle) Write(dest string) error {
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	cfg, err := json.MarshalIndent(b.Config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	names := make([]string, 0, len(b.Files))
+	for name := range b.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := map[string][]byte{manifestFile: manifest, configFile: cfg}
+	order := []string{manifestFile, configFile}
+	for _, name := range names {
+		entries[path.Join(tunnelsDir, name)] = b.Files[name]
+		order = append(order, path.Join(tunnelsDir, name))
+	}
+
+	for _, name := range order {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(entries[name])),
+			ModTime: b.Manifest.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if _, err := tw.Write(entries[name]); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return f.Sync()
+}
+
+// Read loads a bundle written by Write. Entries other than the manifest, the
+// config and known crypto files of bundled tunnels are rejected.
+func Read(src string) (*Bundle, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	b := &Bundle{Files: make(map[string][]byte)}
+	var manifest, cfg []byte
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected bundle entry %s", hdr.Name)
+		}
+		if hdr.Size > maxFileSize {
+			return nil, fmt.Errorf("bundle entry %s is too large", hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+
+		switch {
+		case hdr.Name == manifestFile:
+			manifest = data
+		case hdr.Name == configFile:
+			cfg = data
+		default:
+			rel, ok := cryptoEntry(hdr.Name)
+			if !ok {
+				return nil, fmt.Errorf("unexpected bundle entry %s", hdr.Name)
+			}
+			b.Files[rel] = data
+		}
+	}
+
+	if manifest == nil || cfg == nil {
+		return nil, fmt.Errorf("not a dnstm replica bundle: %s or %s missing", manifestFile, configFile)
+	}
+	if err := json.Unmarshal(manifest, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if b.Manifest.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d (this dnstm reads version %d)", b.Manifest.Version, FormatVersion)
+	}
+	b.Config = &config.Config{}
+	if err := json.Unmarshal(cfg, b.Config); err != nil {
+		return nil, fmt.Errorf("failed to parse bundled config: %w", err)
+	}
+
+	for rel := range b.Files {
+		tag, _ := path.Split(rel)
+		if b.Config.GetTunnelByTag(path.Clean(tag)) == nil {
+			return nil, fmt.Errorf("bundle holds files for unknown tunnel %s", path.Clean(tag))
+		}
+	}
+	return b, nil
+}
+
+// cryptoEntry returns "tag/name" for a tunnels/<tag>/<name> entry with a
+// known crypto file name.
+func cryptoEntry(name string) (string, bool) {
+	dir, file := path.Split(name)
+	parent, tag := path.Split(path.Clean(dir))
+	if path.Clean(parent) != tunnelsDir || tag == "" || tag == "." || tag == ".." {
+		return "", false
+	}
+	switch file {
+	case certFile, certKeyFile, privKeyFile, pubKeyFile:
+		return path.Join(tag, file), true
+	}
+	return "", false
+}
@@ -0,0 +1,127 @@
+package replicate
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBundle_RoundTrip(t *testing.T) {
+	origChown := chownToDnstm
+	defer func() { chownToDnstm = origChown }()
+	chownToDnstm = func(string) error { return nil }
+
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a/server.key"), "priv-a")
+	writeFile(t, filepath.Join(src, "a/server.pub"), "pub-a")
+	writeFile(t, filepath.Join(src, "b/cert.pem"), "cert-b")
+	writeFile(t, filepath.Join(src, "b/key.pem"), "key-b")
+
+	cfg := &config.Config{
+		Listen: config.ListenConfig{Address: "203.0.113.10:53"},
+		Backends: []config.BackendConfig{
+			{Tag: "socks", Type: config.BackendSOCKS, Address: "127.0.0.1:1080", Outbound: &config.OutboundConfig{SourceIP: "203.0.113.10"}},
+		},
+		Tunnels: []config.TunnelConfig{
+			{Tag: "a", Transport: config.TransportDNSTT, Backend: "socks", Domain: "a.example.com", Port: 5310,
+				DNSTT: &config.DNSTTConfig{MTU: 1232, PrivateKey: filepath.Join(src, "a/server.key")}},
+			{Tag: "b", Transport: config.TransportSlipstream, Backend: "socks", Domain: "b.example.com", Port: 5311, BindHost: "203.0.113.20",
+				Slipstream: &config.SlipstreamConfig{Cert: filepath.Join(src, "b/cert.pem"), Key: filepath.Join(src, "b/key.pem")}},
+		},
+		Route: config.RouteConfig{Mode: "single", Active: "a"},
+	}
+
+	b, err := Build(cfg, "v1.0.0")
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	if len(b.Manifest.Dropped) != 3 {
+		t.Errorf("Dropped = %v, want listen.address, bind_host and outbound", b.Manifest.Dropped)
+	}
+	if cfg.Tunnels[1].BindHost == "" || cfg.Listen.Address == "" {
+		t.Error("Build() modified the source config")
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "replica.tar.gz")
+	if err := b.Write(bundlePath); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	got, err := Read(bundlePath)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got.Config.Listen.Address != "" || got.Config.Tunnels[1].BindHost != "" || got.Config.Backends[0].Outbound != nil {
+		t.Error("host-bound settings were bundled")
+	}
+	if got.Config.Tunnels[0].DNSTT.PrivateKey != "" {
+		t.Errorf("bundled config keeps origin key path %q", got.Config.Tunnels[0].DNSTT.PrivateKey)
+	}
+
+	dst := t.TempDir()
+	if err := got.Install(dst); err != nil {
+		t.Fatalf("Install() failed: %v", err)
+	}
+	if want := filepath.Join(dst, "a/server.key"); got.Config.Tunnels[0].DNSTT.PrivateKey != want {
+		t.Errorf("PrivateKey = %q, want %q", got.Config.Tunnels[0].DNSTT.PrivateKey, want)
+	}
+	if want := filepath.Join(dst, "b/cert.pem"); got.Config.Tunnels[1].Slipstream.Cert != want {
+		t.Errorf("Cert = %q, want %q", got.Config.Tunnels[1].Slipstream.Cert, want)
+	}
+	for path, want := range map[string]string{
+		"a/server.key": "priv-a",
+		"a/server.pub": "pub-a",
+		"b/cert.pem":   "cert-b",
+		"b/key.pem":    "key-b",
+	} {
+		data, err := os.ReadFile(filepath.Join(dst, path))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q (%v), want %q", path, data, err, want)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dst, "a/server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key mode = %o, want 600", info.Mode().Perm())
+	}
+}
+
+func TestRead_RejectsForeignEntries(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "tunnels/a/../../x", "tunnels/a/other.txt", "tunnels/server.key"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bad.tar.gz")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gz := gzip.NewWriter(f)
+			tw := tar.NewWriter(gz)
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: 1})
+			tw.Write([]byte("x"))
+			tw.Close()
+			gz.Close()
+			f.Close()
+
+			if _, err := Read(path); err == nil || !strings.Contains(err.Error(), "unexpected bundle entry") {
+				t.Errorf("Read() = %v, want unexpected entry error", err)
+			}
+		})
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"github.com/net2share/dnstm/internal/config"
 )
 
-// Re-export constants from config package
-const (
+// Re-export paths from config package
+var (
 	ConfigDir  = config.ConfigDir
-	ConfigFile = config.ConfigFile
 	TunnelsDir = config.TunnelsDir
 )
 
+const ConfigFile = config.ConfigFile
+
 // Mode defines the operating mode of dnstm.
 type Mode string
 
@@ -0,0 +1,32 @@
+package router
+
+import (
+	"time"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// ExpiryTimerName is the timer that runs "dnstm tunnel apply-expiry".
+var ExpiryTimerName = paths.SystemService("expiry")
+
+// SyncExpiryTimer installs the expiry timer while any tunnel has an expiry,
+// and removes it once none has.
+func SyncExpiryTimer(cfg *Config) error {
+	for _, t := range cfg.Tunnels {
+		if t.Expiry != nil {
+			return service.CreateTimer(&service.TimerConfig{
+				Name:        ExpiryTimerName,
+				Description: "dnstm tunnel expiry",
+				ExecStart:   cmdline.Join(paths.Bin("dnstm"), "tunnel", "apply-expiry"),
+				OnCalendar:  "*:0/5",
+				OnBoot:      time.Minute,
+			})
+		}
+	}
+	if service.IsTimerInstalled(ExpiryTimerName) {
+		return service.RemoveTimer(ExpiryTimerName)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"time"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// LogScanTimerName is the timer that runs "dnstm tunnel scan-logs".
+var LogScanTimerName = paths.SystemService("logscan")
+
+// SyncLogScanTimer installs the log scan timer while log alerts are on, and
+// removes it once they are off.
+func SyncLogScanTimer(cfg *Config) error {
+	if cfg.Log.Alerts != nil {
+		return service.CreateTimer(&service.TimerConfig{
+			Name:        LogScanTimerName,
+			Description: "dnstm tunnel log alerts",
+			ExecStart:   cmdline.Join(paths.Bin("dnstm"), "tunnel", "scan-logs"),
+			OnCalendar:  "*:0/5",
+			OnBoot:      5 * time.Minute,
+		})
+	}
+	if service.IsTimerInstalled(LogScanTimerName) {
+		return service.RemoveTimer(LogScanTimerName)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/network"
 	"github.com/net2share/dnstm/internal/transport"
 )
@@ -80,7 +81,7 @@ func (r *Router) rollback(snapshot *ModeSnapshot, reason string) error {
 	}
 
 	// Save config
-	if err := r.config.Save(); err != nil {
+	if err := r.saveRouting(nil); err != nil {
 		log.Printf("[warning] rollback: failed to save config: %v", err)
 	}
 
@@ -89,6 +90,13 @@ func (r *Router) rollback(snapshot *ModeSnapshot, reason string) error {
 
 // switchToSingleMode transitions from multi to single mode.
 func (r *Router) switchToSingleMode() error {
+	// Only the DNS router can answer for a paused tunnel
+	for _, t := range r.config.Tunnels {
+		if t.IsPaused() {
+			return fmt.Errorf("tunnel %s is paused; resume it with 'dnstm tunnel resume -t %s' first", t.Tag, t.Tag)
+		}
+	}
+
 	snapshot, _ := r.captureSnapshot()
 
 	// 1. Stop dnsrouter if running
@@ -98,9 +106,9 @@ func (r *Router) switchToSingleMode() error {
 		}
 	}
 
-	// 2. Stop all tunnels
+	// 2. Stop all DNS tunnels (fallback transports keep running)
 	for tag, tunnel := range r.tunnels {
-		if tunnel.IsActive() {
+		if tunnel.Transport.IsDNS() && tunnel.IsActive() {
 			if err := tunnel.Stop(); err != nil {
 				return fmt.Errorf("failed to stop tunnel %s: %w", tag, err)
 			}
@@ -110,9 +118,9 @@ func (r *Router) switchToSingleMode() error {
 	// 3. Determine active tunnel
 	active := r.config.Route.Active
 	if active == "" && len(r.config.Tunnels) > 0 {
-		// Pick first enabled tunnel
+		// Pick first enabled DNS tunnel
 		for _, t := range r.config.Tunnels {
-			if t.IsEnabled() {
+			if t.IsEnabled() && t.Transport.IsDNS() {
 				active = t.Tag
 				break
 			}
@@ -126,6 +134,9 @@ func (r *Router) switchToSingleMode() error {
 	enabledFalse := false
 	for i := range r.config.Tunnels {
 		t := &r.config.Tunnels[i]
+		if !t.Transport.IsDNS() {
+			continue
+		}
 		if t.Tag == active {
 			t.Enabled = &enabledTrue
 		} else {
@@ -148,6 +159,7 @@ func (r *Router) switchToSingleMode() error {
 
 	// 7. Update config mode
 	r.config.Route.Mode = "single"
+	r.syncNotrack()
 
 	// 8. Regenerate active tunnel's service with single-mode binding (EXTERNAL_IP:53)
 	if active != "" {
@@ -169,16 +181,19 @@ func (r *Router) switchToSingleMode() error {
 	}
 
 	// 9. Save config
-	if err := r.config.Save(); err != nil {
+	if err := r.saveRouting(nil); err != nil {
 		return r.rollback(snapshot, fmt.Sprintf("failed to save config: %v", err))
 	}
 
 	// 10. Start active tunnel if any
 	if active != "" {
-		if tunnel, ok := r.tunnels[active]; ok {
+		if tunnel, ok := r.tunnels[active]; ok && !tunnel.IsQuarantined() {
 			if err := tunnel.Start(); err != nil {
 				return r.rollback(snapshot, fmt.Sprintf("failed to start %s: %v", active, err))
 			}
+			if err := tunnel.WaitReady(true, r.config.Services.GetReadyTimeout()); err != nil {
+				return r.rollback(snapshot, fmt.Sprintf("%s is not ready: %v", active, err))
+			}
 		}
 	}
 
@@ -190,6 +205,12 @@ func (r *Router) switchToMultiMode() error {
 	// Validate: each tunnel must have a unique domain in multi-mode
 	domains := make(map[string]string)
 	for _, t := range r.config.Tunnels {
+		if !t.Transport.IsDNS() {
+			continue
+		}
+		if t.BindHost != "" {
+			return fmt.Errorf("cannot switch to multi-mode: tunnel '%s' binds its own address %s; remove it or clear its bind_host first", t.Tag, t.BindHost)
+		}
 		if existing, ok := domains[t.Domain]; ok {
 			return fmt.Errorf("cannot switch to multi-mode: tunnels '%s' and '%s' share the same domain '%s'", existing, t.Tag, t.Domain)
 		}
@@ -224,6 +245,7 @@ func (r *Router) switchToMultiMode() error {
 
 	// 4. Update config mode and enable all tunnels
 	r.config.Route.Mode = "multi"
+	r.syncNotrack()
 	enabledTrue := true
 	for i := range r.config.Tunnels {
 		r.config.Tunnels[i].Enabled = &enabledTrue
@@ -236,7 +258,7 @@ func (r *Router) switchToMultiMode() error {
 			r.config.Route.Default = r.config.Route.Active
 		} else {
 			for _, t := range r.config.Tunnels {
-				if t.IsEnabled() {
+				if t.IsEnabled() && t.Transport.IsDNS() {
 					r.config.Route.Default = t.Tag
 					break
 				}
@@ -262,7 +284,7 @@ func (r *Router) switchToMultiMode() error {
 	}
 
 	// 7. Save config
-	if err := r.config.Save(); err != nil {
+	if err := r.saveRouting(nil); err != nil {
 		return r.rollback(snapshot, fmt.Sprintf("failed to save config: %v", err))
 	}
 
@@ -275,16 +297,30 @@ func (r *Router) switchToMultiMode() error {
 
 	// 9. Start all tunnels FIRST (before dnsrouter)
 	//     Start() also enables the systemd service
+	var started []*Tunnel
 	for tag, tunnel := range r.tunnels {
+		if tunnel.IsQuarantined() {
+			log.Printf("[warning] tunnel %s is quarantined, not starting", tag)
+			continue
+		}
 		if err := tunnel.Start(); err != nil {
 			return r.rollback(snapshot, fmt.Sprintf("failed to start tunnel %s: %v", tag, err))
 		}
+		started = append(started, tunnel)
+	}
+	for _, tunnel := range started {
+		if err := tunnel.WaitReady(false, r.config.Services.GetReadyTimeout()); err != nil {
+			return r.rollback(snapshot, fmt.Sprintf("tunnel %s is not ready: %v", tunnel.Tag, err))
+		}
 	}
 
 	// 10. Start DNS router AFTER tunnels are ready
 	if err := r.dnsrouter.Start(); err != nil {
 		return r.rollback(snapshot, fmt.Sprintf("failed to start DNS router: %v", err))
 	}
+	if err := r.waitDNSRouterReady(); err != nil {
+		return r.rollback(snapshot, fmt.Sprintf("DNS router is not ready: %v", err))
+	}
 
 	return nil
 }
@@ -306,6 +342,16 @@ func (r *Router) SwitchActiveTunnel(tag string) error {
 		return fmt.Errorf("tunnel '%s' not found", tag)
 	}
 
+	if !newTunnelCfg.Transport.IsDNS() {
+		return fmt.Errorf("tunnel '%s' is a %s fallback and runs alongside the active tunnel; it cannot be made active", tag, config.GetTransportTypeDisplayName(newTunnelCfg.Transport))
+	}
+	if newTunnelCfg.Rescue {
+		return fmt.Errorf("tunnel '%s' is the rescue tunnel and cannot be made active", tag)
+	}
+	if newTunnelCfg.BindHost != "" {
+		return fmt.Errorf("tunnel '%s' binds its own address %s and runs alongside the active tunnel; it cannot be made active", tag, newTunnelCfg.BindHost)
+	}
+
 	currentActive := r.config.Route.Active
 
 	// Nothing to do if already active
@@ -367,7 +413,7 @@ func (r *Router) SwitchActiveTunnel(tag string) error {
 
 	// 5. Update config
 	r.config.Route.Active = tag
-	if err := r.config.Save(); err != nil {
+	if err := r.saveRouting(nil); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
@@ -375,6 +421,9 @@ func (r *Router) SwitchActiveTunnel(tag string) error {
 	if err := newTunnel.Start(); err != nil {
 		return fmt.Errorf("failed to start tunnel %s: %w", tag, err)
 	}
+	if err := newTunnel.WaitReady(true, r.config.Services.GetReadyTimeout()); err != nil {
+		return fmt.Errorf("tunnel %s is not ready: %w", tag, err)
+	}
 
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
 )
 
 var adjectives = []string{
@@ -74,6 +75,9 @@ func ValidateTag(tag string) error {
 			return fmt.Errorf("tag '%s' is reserved", tag)
 		}
 	}
+	if paths.IsSystemService(paths.Service(tag)) {
+		return fmt.Errorf("tag '%s' is reserved for the %s service", tag, paths.Service(tag))
+	}
 
 	return nil
 }
@@ -120,7 +124,7 @@ func SuggestSimilarTags(baseTag string, cfg *config.Config, count int) []string
 
 // GetServiceName returns the systemd service name for a tunnel.
 func GetServiceName(tag string) string {
-	return "dnstm-" + tag
+	return paths.Service(tag)
 }
 
 // GenerateUniqueTunnelTag generates a unique tag that doesn't conflict with existing tunnels.
@@ -8,8 +8,8 @@ import (
 
 // IsPortAvailable checks if a port is available for use.
 func IsPortAvailable(port int, cfg *config.Config) bool {
-	// Check if port is in the valid range
-	if port < config.DefaultPortStart || port > config.DefaultPortEnd {
+	// Check if port is in the valid range and not excluded
+	if ValidatePort(port, cfg.Ports) != nil {
 		return false
 	}
 
@@ -24,8 +24,8 @@ func IsPortAvailable(port int, cfg *config.Config) bool {
 	return config.IsPortFree(port)
 }
 
-// ValidatePort checks if a port is valid for use.
-func ValidatePort(port int) error {
+// ValidatePort checks if a port is valid for use under the given port policy.
+func ValidatePort(port int, ports config.PortsConfig) error {
 	if port < 1024 {
 		return fmt.Errorf("port %d is a privileged port (< 1024)", port)
 	}
@@ -34,14 +34,19 @@ func ValidatePort(port int) error {
 		return fmt.Errorf("port %d is out of range (> 65535)", port)
 	}
 
-	if port < config.DefaultPortStart || port > config.DefaultPortEnd {
-		return fmt.Errorf("port %d is outside the router range (%d-%d)", port, config.DefaultPortStart, config.DefaultPortEnd)
+	if start, end := ports.Range(); port < start || port > end {
+		return fmt.Errorf("port %d is outside the router range (%d-%d)", port, start, end)
+	}
+
+	if ports.IsExcluded(port) {
+		return fmt.Errorf("port %d is excluded by ports.exclude", port)
 	}
 
 	return nil
 }
 
 // GetPortRange returns the port range as a string.
-func GetPortRange() string {
-	return fmt.Sprintf("%d-%d", config.DefaultPortStart, config.DefaultPortEnd)
+func GetPortRange(ports config.PortsConfig) string {
+	start, end := ports.Range()
+	return fmt.Sprintf("%d-%d", start, end)
 }
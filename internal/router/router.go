@@ -2,15 +2,21 @@ package router
 
 import (
 	"fmt"
+	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/net2share/dnstm/internal/certs"
 	"github.com/net2share/dnstm/internal/config"
 	"github.com/net2share/dnstm/internal/dnsrouter"
 	"github.com/net2share/dnstm/internal/keys"
 	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/service"
 	"github.com/net2share/dnstm/internal/system"
+	"github.com/net2share/dnstm/internal/transport"
 )
 
 // Router orchestrates multiple tunnels and the DNS router.
@@ -45,15 +51,41 @@ func New(cfg *config.Config) (*Router, error) {
 // In single mode: starts the active tunnel (binds directly to EXTERNAL_IP:53).
 // In multi mode: starts the DNS router and all enabled tunnels.
 func (r *Router) Start() error {
+	return r.start(false)
+}
+
+// start starts the router; with restart, a running DNS router is restarted
+// instead of left as it is.
+func (r *Router) start(restart bool) error {
 	// Ensure dnstm user exists
 	if err := system.CreateDnstmUser(); err != nil {
 		return fmt.Errorf("failed to create dnstm user: %w", err)
 	}
 
+	r.repairRescue()
+
 	if r.config.IsSingleMode() {
 		return r.startSingleMode()
 	}
-	return r.startMultiMode()
+	return r.startMultiMode(restart)
+}
+
+// repairRescue recreates the rescue tunnel's unit when it went missing, so
+// emergency access survives a cleanup of the systemd directory.
+func (r *Router) repairRescue() {
+	rescue := r.config.GetRescueTunnel()
+	if rescue == nil {
+		return
+	}
+	tunnel := r.tunnels[rescue.Tag]
+	if tunnel == nil || service.IsServiceInstalled(tunnel.ServiceName) {
+		return
+	}
+	if err := r.RegenerateTunnel(rescue.Tag); err != nil {
+		log.Printf("[warning] failed to recreate rescue tunnel service: %v", err)
+		return
+	}
+	log.Printf("[info] recreated missing rescue tunnel service %s", tunnel.ServiceName)
 }
 
 // startSingleMode starts the active tunnel which binds directly to EXTERNAL_IP:53.
@@ -72,17 +104,134 @@ func (r *Router) startSingleMode() error {
 	network.ClearNATOnly()
 	// Ensure firewall allows port 53
 	network.AllowPort53()
+	r.syncNotrack()
+
+	if tunnel.IsQuarantined() {
+		return fmt.Errorf("active tunnel '%s' is quarantined; run 'dnstm tunnel unquarantine -t %s'", active, active)
+	}
+	if err := r.checkBindHosts(); err != nil {
+		return err
+	}
+	if !tunnel.Config.InSchedule(time.Now()) {
+		log.Printf("[info] active tunnel %s is outside its schedule, not starting", active)
+		return r.startAlongside()
+	}
 
 	// Start the tunnel
+	r.allowPublicPorts(tunnel)
 	if err := tunnel.Start(); err != nil {
 		return fmt.Errorf("failed to start tunnel %s: %w", active, err)
 	}
+	if err := tunnel.WaitReady(true, r.config.Services.GetReadyTimeout()); err != nil {
+		return fmt.Errorf("tunnel %s is not ready: %w", active, err)
+	}
+
+	return r.startAlongside()
+}
+
+// allowPublicPorts opens the firewall for the TCP ports a tunnel listens
+// on besides DNS.
+func (r *Router) allowPublicPorts(tunnel *Tunnel) {
+	for _, port := range r.config.PublicPorts(tunnel.Config) {
+		network.AllowTCPPort(port)
+	}
+}
+
+// SyncNotrack puts the conntrack bypass rules for the mode of cfg in place,
+// or removes them when it is disabled. In multi mode the rules also cover
+// the tunnel port range on loopback, where the DNS router reaches the
+// tunnels.
+func SyncNotrack(cfg *config.Config) error {
+	loopback := ""
+	if cfg.IsMultiMode() {
+		start, end := cfg.Ports.Range()
+		loopback = fmt.Sprintf("%d:%d", start, end)
+	}
+	return network.SyncNotrack(cfg.Firewall.NoTrack, loopback)
+}
+
+// syncNotrack is SyncNotrack for a starting router, which goes on without
+// the bypass rather than fail.
+func (r *Router) syncNotrack() {
+	if err := SyncNotrack(r.config); err != nil {
+		log.Printf("[warning] conntrack bypass: %v", err)
+	}
+}
+
+// saveRouting stores the routing state of r.config, the route and whether
+// each tunnel is enabled, with config.Update. fn, if not nil, makes the
+// other changes to the stored config first; the rest of it is kept as
+// other invocations left it.
+func (r *Router) saveRouting(fn func(*config.Config)) error {
+	route := r.config.Route
+	enabled := make(map[string]*bool, len(r.config.Tunnels))
+	for _, t := range r.config.Tunnels {
+		enabled[t.Tag] = t.Enabled
+	}
+	return config.Update(func(c *config.Config) error {
+		if fn != nil {
+			fn(c)
+		}
+		c.Route.Mode = route.Mode
+		c.Route.Active = route.Active
+		c.Route.Default = route.Default
+		for i := range c.Tunnels {
+			if e, ok := enabled[c.Tunnels[i].Tag]; ok {
+				c.Tunnels[i].Enabled = e
+			}
+		}
+		return nil
+	})
+}
 
+// checkBindHosts rejects a DNS tunnel pinned to the address the active
+// tunnel binds, since both would need port 53 on it.
+func (r *Router) checkBindHosts() error {
+	active := r.tunnels[r.config.Route.Active]
+	if active == nil {
+		return nil
+	}
+	externalIP, err := network.GetExternalIP()
+	if err != nil {
+		return nil
+	}
+	for tag, tunnel := range r.tunnels {
+		if tag != active.Tag && tunnel.Config.BindHost == externalIP {
+			return fmt.Errorf("tunnel '%s' binds %s:53, which the active tunnel '%s' uses; set another bind_host", tag, externalIP, active.Tag)
+		}
+	}
+	return nil
+}
+
+// startAlongside starts the enabled tunnels that run next to the active
+// tunnel in single mode: non-DNS tunnels and DNS tunnels with a bind_host.
+func (r *Router) startAlongside() error {
+	for tag, tunnel := range r.tunnels {
+		if tag == r.config.Route.Active || !tunnel.Config.RunsAlongsideActive() || !tunnel.Config.IsEnabled() {
+			continue
+		}
+		if tunnel.IsQuarantined() {
+			log.Printf("[warning] tunnel %s is quarantined, not starting", tag)
+			continue
+		}
+		if !tunnel.Config.InSchedule(time.Now()) {
+			log.Printf("[info] tunnel %s is outside its schedule, not starting", tag)
+			continue
+		}
+		r.allowPublicPorts(tunnel)
+		if err := tunnel.Start(); err != nil {
+			return fmt.Errorf("failed to start tunnel %s: %w", tag, err)
+		}
+		if err := tunnel.WaitReady(true, r.config.Services.GetReadyTimeout()); err != nil {
+			return fmt.Errorf("tunnel %s is not ready: %w", tag, err)
+		}
+	}
 	return nil
 }
 
-// startMultiMode starts the DNS router and all enabled tunnels.
-func (r *Router) startMultiMode() error {
+// startMultiMode starts the DNS router and all enabled tunnels. With
+// restart, a running DNS router is restarted too.
+func (r *Router) startMultiMode(restart bool) error {
 	// Create DNS router service if needed
 	if !r.dnsrouter.IsServiceInstalled() {
 		if err := r.dnsrouter.CreateService(); err != nil {
@@ -94,24 +243,73 @@ func (r *Router) startMultiMode() error {
 	network.ClearNATOnly()
 	// Ensure firewall allows port 53
 	network.AllowPort53()
+	r.syncNotrack()
 
 	// Start all enabled tunnels FIRST (before dnsrouter)
+	var started []*Tunnel
 	for tag, tunnel := range r.tunnels {
 		if tunnel.Config.IsEnabled() {
+			if tunnel.IsQuarantined() {
+				log.Printf("[warning] tunnel %s is quarantined, not starting", tag)
+				continue
+			}
+			if tunnel.Config.IsPaused() {
+				log.Printf("[info] tunnel %s is paused, not starting", tag)
+				continue
+			}
+			if !tunnel.Config.InSchedule(time.Now()) {
+				log.Printf("[info] tunnel %s is outside its schedule, not starting", tag)
+				continue
+			}
+			r.allowPublicPorts(tunnel)
 			if err := tunnel.Start(); err != nil {
 				return fmt.Errorf("failed to start tunnel %s: %w", tag, err)
 			}
+			started = append(started, tunnel)
+		}
+	}
+
+	// The tunnels start concurrently; wait for all of them to listen
+	timeout := r.config.Services.GetReadyTimeout()
+	for _, tunnel := range started {
+		if err := tunnel.WaitReady(false, timeout); err != nil {
+			return fmt.Errorf("tunnel %s is not ready: %w", tunnel.Tag, err)
 		}
 	}
 
 	// Start DNS router AFTER tunnels are ready
-	if err := r.dnsrouter.Start(); err != nil {
+	start := r.dnsrouter.Start
+	if restart {
+		start = r.dnsrouter.Restart
+	}
+	if err := start(); err != nil {
 		return fmt.Errorf("failed to start DNS router: %w", err)
 	}
+	if err := r.waitDNSRouterReady(); err != nil {
+		return fmt.Errorf("DNS router is not ready: %w", err)
+	}
 
 	return nil
 }
 
+// waitDNSRouterReady waits until the DNS router service is active and bound
+// to the listen port.
+func (r *Router) waitDNSRouterReady() error {
+	timeout := r.config.Services.GetReadyTimeout()
+	start := time.Now()
+	if err := service.WaitActive(dnsrouter.ServiceName, timeout); err != nil {
+		return err
+	}
+
+	port := 53
+	if _, p, err := net.SplitHostPort(r.config.Listen.Address); err == nil {
+		if n, err := strconv.Atoi(p); err == nil {
+			port = n
+		}
+	}
+	return service.MarkFailed(network.WaitListening("udp", port, timeout-time.Since(start)))
+}
+
 // Stop stops the router based on the current mode.
 func (r *Router) Stop() error {
 	if r.config.IsSingleMode() {
@@ -120,7 +318,7 @@ func (r *Router) Stop() error {
 	return r.stopMultiMode()
 }
 
-// stopSingleMode stops the active tunnel.
+// stopSingleMode stops the active tunnel and the tunnels running next to it.
 func (r *Router) stopSingleMode() error {
 	var lastErr error
 
@@ -133,19 +331,20 @@ func (r *Router) stopSingleMode() error {
 		}
 	}
 
+	for tag, tunnel := range r.tunnels {
+		if tag != active && tunnel.Config.RunsAlongsideActive() {
+			if err := tunnel.Stop(); err != nil {
+				lastErr = fmt.Errorf("failed to stop tunnel %s: %w", tag, err)
+			}
+		}
+	}
+
 	return lastErr
 }
 
 // stopMultiMode stops all tunnels and the DNS router.
 func (r *Router) stopMultiMode() error {
-	var lastErr error
-
-	// Stop all tunnels
-	for tag, tunnel := range r.tunnels {
-		if err := tunnel.Stop(); err != nil {
-			lastErr = fmt.Errorf("failed to stop tunnel %s: %w", tag, err)
-		}
-	}
+	lastErr := r.stopTunnels()
 
 	// Stop DNS router
 	if err := r.dnsrouter.Stop(); err != nil {
@@ -155,6 +354,17 @@ func (r *Router) stopMultiMode() error {
 	return lastErr
 }
 
+// stopTunnels stops all tunnels.
+func (r *Router) stopTunnels() error {
+	var lastErr error
+	for tag, tunnel := range r.tunnels {
+		if err := tunnel.Stop(); err != nil {
+			lastErr = fmt.Errorf("failed to stop tunnel %s: %w", tag, err)
+		}
+	}
+	return lastErr
+}
+
 // IsRunning returns true if any router services are currently active.
 func (r *Router) IsRunning() bool {
 	if r.config.IsSingleMode() {
@@ -180,6 +390,14 @@ func (r *Router) IsRunning() bool {
 
 // Restart restarts all services based on current mode.
 func (r *Router) Restart() error {
+	// A running DNS router is restarted rather than stopped, so systemd
+	// keeps its port 53 sockets for the new process
+	if r.config.IsMultiMode() && r.dnsrouter.IsActive() {
+		if err := r.stopTunnels(); err != nil {
+			return err
+		}
+		return r.start(true)
+	}
 	if err := r.Stop(); err != nil {
 		return err
 	}
@@ -196,8 +414,15 @@ func (r *Router) AddTunnel(cfg *config.TunnelConfig) error {
 		return fmt.Errorf("tunnel %s already exists", cfg.Tag)
 	}
 
+	if cfg.Port == 0 && cfg.Transport == config.TransportChisel {
+		cfg.Port = config.DefaultChiselPort
+	}
 	if cfg.Port == 0 {
-		cfg.Port = r.config.AllocateNextPort()
+		port, err := r.config.AllocateNextPort()
+		if err != nil {
+			return err
+		}
+		cfg.Port = port
 	}
 
 	// Generate or reuse certificate/keys
@@ -213,7 +438,9 @@ func (r *Router) AddTunnel(cfg *config.TunnelConfig) error {
 	r.tunnels[cfg.Tag] = tunnel
 
 	// In single mode: auto-set as active if first tunnel
-	if r.config.IsSingleMode() {
+	if !cfg.Transport.IsDNS() {
+		// Fallback transports are not routed
+	} else if r.config.IsSingleMode() {
 		if r.config.Route.Active == "" {
 			r.config.Route.Active = cfg.Tag
 		}
@@ -231,7 +458,11 @@ func (r *Router) AddTunnel(cfg *config.TunnelConfig) error {
 	}
 
 	// Save config
-	if err := r.config.Save(); err != nil {
+	if err := r.saveRouting(func(c *config.Config) {
+		if c.GetTunnelByTag(cfg.Tag) == nil {
+			c.Tunnels = append(c.Tunnels, *cfg)
+		}
+	}); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
@@ -275,9 +506,12 @@ func (r *Router) RemoveTunnel(tag string) error {
 		// Update default route if needed
 		if r.config.Route.Default == tag {
 			r.config.Route.Default = ""
-			// Set to first available tunnel
-			if len(r.config.Tunnels) > 0 {
-				r.config.Route.Default = r.config.Tunnels[0].Tag
+			// Set to first available DNS tunnel
+			for _, t := range r.config.Tunnels {
+				if t.Transport.IsDNS() {
+					r.config.Route.Default = t.Tag
+					break
+				}
 			}
 		}
 
@@ -290,13 +524,61 @@ func (r *Router) RemoveTunnel(tag string) error {
 	}
 
 	// Save config
-	if err := r.config.Save(); err != nil {
+	if err := r.saveRouting(func(c *config.Config) {
+		var kept []config.TunnelConfig
+		for _, t := range c.Tunnels {
+			if t.Tag != tag {
+				kept = append(kept, t)
+			}
+		}
+		c.Tunnels = kept
+	}); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
 	return nil
 }
 
+// RegenerateTunnel rebuilds a tunnel's service from the current config using
+// the binding for the current mode, restarting it if it was running.
+func (r *Router) RegenerateTunnel(tag string) error {
+	tunnel, exists := r.tunnels[tag]
+	if !exists {
+		return fmt.Errorf("tunnel %s not found", tag)
+	}
+
+	tunnelCfg := r.config.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return fmt.Errorf("tunnel '%s' does not exist", tag)
+	}
+	backend := r.config.GetBackendByTag(tunnelCfg.Backend)
+	if backend == nil {
+		return fmt.Errorf("backend '%s' not found for tunnel '%s'", tunnelCfg.Backend, tag)
+	}
+
+	mode := ServiceModeMulti
+	if r.config.IsSingleMode() && r.config.Route.Active == tag {
+		mode = ServiceModeSingle
+	}
+	opts, err := NewServiceGenerator().GetBindOptions(tunnelCfg, mode)
+	if err != nil {
+		return fmt.Errorf("failed to get bind options: %w", err)
+	}
+
+	wasActive := tunnel.IsActive()
+	if err := transport.NewBuilder().RegenerateTunnelService(tunnelCfg, backend, opts); err != nil {
+		return err
+	}
+
+	if wasActive {
+		if err := tunnel.Start(); err != nil {
+			return fmt.Errorf("failed to start tunnel %s: %w", tag, err)
+		}
+	}
+
+	return nil
+}
+
 // GetTunnel returns a tunnel by tag.
 func (r *Router) GetTunnel(tag string) *Tunnel {
 	return r.tunnels[tag]
@@ -357,6 +639,24 @@ func (r *Router) ensureCryptoMaterial(cfg *config.TunnelConfig) error {
 		}
 		cfg.Slipstream.Cert = certInfo.CertPath
 		cfg.Slipstream.Key = certInfo.KeyPath
+	} else if cfg.Transport == config.TransportChisel {
+		certInfo, err := certs.GetOrCreateInDir(tunnelDir, cfg.Domain)
+		if err != nil {
+			return fmt.Errorf("failed to get certificate: %w", err)
+		}
+
+		if cfg.Chisel == nil {
+			cfg.Chisel = &config.ChiselConfig{}
+		}
+		if cfg.Chisel.Auth == "" {
+			auth, err := transport.GenerateChiselAuth()
+			if err != nil {
+				return err
+			}
+			cfg.Chisel.Auth = auth
+		}
+		cfg.Chisel.Cert = certInfo.CertPath
+		cfg.Chisel.Key = certInfo.KeyPath
 	} else if cfg.Transport == config.TransportDNSTT {
 		keyInfo, err := keys.GetOrCreateInDir(tunnelDir)
 		if err != nil {
@@ -382,7 +682,7 @@ func (r *Router) SetDefaultRoute(tag string) error {
 
 	r.config.Route.Default = tag
 
-	if err := r.config.Save(); err != nil {
+	if err := r.saveRouting(nil); err != nil {
 		return err
 	}
 
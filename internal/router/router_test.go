@@ -26,7 +26,7 @@ func TestValidatePort(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(fmt.Sprintf("port_%d", tt.port), func(t *testing.T) {
-			err := ValidatePort(tt.port)
+			err := ValidatePort(tt.port, config.PortsConfig{})
 			if tt.wantErr {
 				if err == nil {
 					t.Errorf("ValidatePort(%d) expected error", tt.port)
@@ -43,7 +43,7 @@ func TestValidatePort(t *testing.T) {
 }
 
 func TestGetPortRange(t *testing.T) {
-	pr := GetPortRange()
+	pr := GetPortRange(config.PortsConfig{})
 	expected := "5310-5399"
 	if pr != expected {
 		t.Errorf("GetPortRange() = %q, want %q", pr, expected)
@@ -74,6 +74,19 @@ func TestIsPortAvailable(t *testing.T) {
 	}
 }
 
+func TestValidatePort_Policy(t *testing.T) {
+	ports := config.PortsConfig{Start: 6000, End: 6010, Exclude: []int{6005}}
+	if err := ValidatePort(6001, ports); err != nil {
+		t.Errorf("ValidatePort(6001) unexpected error: %v", err)
+	}
+	if err := ValidatePort(5310, ports); err == nil || !strings.Contains(err.Error(), "outside the router range (6000-6010)") {
+		t.Errorf("ValidatePort(5310) error = %v", err)
+	}
+	if err := ValidatePort(6005, ports); err == nil || !strings.Contains(err.Error(), "excluded") {
+		t.Errorf("ValidatePort(6005) error = %v", err)
+	}
+}
+
 func TestIsPortAvailableUsedPort(t *testing.T) {
 	cfg := &config.Config{
 		Tunnels: []config.TunnelConfig{
@@ -133,6 +146,8 @@ func TestValidateTag(t *testing.T) {
 		{name: "default", wantErr: true, errText: "reserved"},
 		{name: "all", wantErr: true, errText: "reserved"},
 		{name: "none", wantErr: true, errText: "reserved"},
+		{name: "expiry", wantErr: true, errText: "reserved"},
+		{name: "logscan", wantErr: true, errText: "reserved"},
 	}
 
 	for _, tt := range tests {
@@ -309,3 +324,25 @@ func TestServiceGenerator_GetBindOptions_Multi(t *testing.T) {
 		t.Errorf("BindPort = %d, want 5320", opts.BindPort)
 	}
 }
+
+func TestServiceGenerator_GetBindOptions_BindHost(t *testing.T) {
+	sg := NewServiceGenerator()
+
+	cfg := &config.TunnelConfig{
+		Tag:       "second-ip",
+		Transport: config.TransportDNSTT,
+		Port:      5321,
+		Domain:    "t2.example.com",
+		BindHost:  "203.0.113.20",
+	}
+
+	for _, mode := range []ServiceMode{ServiceModeSingle, ServiceModeMulti} {
+		opts, err := sg.GetBindOptions(cfg, mode)
+		if err != nil {
+			t.Fatalf("GetBindOptions(%s) failed: %v", mode, err)
+		}
+		if opts.BindHost != "203.0.113.20" || opts.BindPort != 53 {
+			t.Errorf("GetBindOptions(%s) = %s:%d, want 203.0.113.20:53", mode, opts.BindHost, opts.BindPort)
+		}
+	}
+}
@@ -27,7 +27,23 @@ func NewServiceGenerator() *ServiceGenerator {
 // GetBindOptions returns the appropriate BuildOptions for the given mode.
 // For single mode: binds to EXTERNAL_IP:53
 // For multi mode: binds to 127.0.0.1:cfg.Port
+// Non-DNS transports always bind 0.0.0.0:cfg.Port, and DNS tunnels with a
+// bind_host always bind BIND_HOST:53.
 func (sg *ServiceGenerator) GetBindOptions(cfg *config.TunnelConfig, mode ServiceMode) (*transport.BuildOptions, error) {
+	if !cfg.Transport.IsDNS() {
+		return &transport.BuildOptions{
+			BindHost: "0.0.0.0",
+			BindPort: cfg.Port,
+		}, nil
+	}
+
+	if cfg.BindHost != "" {
+		return &transport.BuildOptions{
+			BindHost: cfg.BindHost,
+			BindPort: 53,
+		}, nil
+	}
+
 	if mode == ServiceModeSingle {
 		externalIP, err := network.GetExternalIP()
 		if err != nil {
@@ -6,10 +6,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 
+	"github.com/net2share/dnstm/internal/cmdline"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/logscan"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/quarantine"
 	"github.com/net2share/dnstm/internal/service"
 	"github.com/net2share/dnstm/internal/system"
+	"github.com/net2share/dnstm/internal/usage"
 )
 
 // Tunnel represents a running DNS tunnel.
@@ -38,6 +45,8 @@ func NewTunnel(cfg *config.TunnelConfig) *Tunnel {
 
 // Start enables and starts the tunnel service.
 func (t *Tunnel) Start() error {
+	// Manual starts should not count towards the crash-loop limit
+	service.ResetFailed(t.ServiceName)
 	if err := service.EnableService(t.ServiceName); err != nil {
 		log.Printf("[warning] failed to enable service %s: %v", t.ServiceName, err)
 	}
@@ -57,12 +66,33 @@ func (t *Tunnel) Stop() error {
 
 // Restart enables and restarts the tunnel service.
 func (t *Tunnel) Restart() error {
+	service.ResetFailed(t.ServiceName)
 	if err := service.EnableService(t.ServiceName); err != nil {
 		log.Printf("[warning] failed to enable service %s: %v", t.ServiceName, err)
 	}
 	return service.RestartService(t.ServiceName)
 }
 
+// WaitReady waits until the tunnel service is active and its socket is bound,
+// UDP for DNS transports and TCP otherwise. In single mode the active DNS
+// tunnel listens on port 53 instead of its own port, as does a DNS tunnel
+// with a bind_host in any mode.
+func (t *Tunnel) WaitReady(singleMode bool, timeout time.Duration) error {
+	start := time.Now()
+	if err := service.WaitActive(t.ServiceName, timeout); err != nil {
+		return err
+	}
+
+	proto, port := "tcp", t.Port
+	if t.Transport.IsDNS() {
+		proto = "udp"
+		if singleMode || t.Config.BindHost != "" {
+			port = 53
+		}
+	}
+	return service.MarkFailed(network.WaitListening(proto, port, timeout-time.Since(start)))
+}
+
 // GetLogs returns recent logs from the tunnel.
 func (t *Tunnel) GetLogs(lines int) (string, error) {
 	return service.GetServiceLogs(t.ServiceName, lines)
@@ -78,6 +108,11 @@ func (t *Tunnel) IsActive() bool {
 	return service.IsServiceActive(t.ServiceName)
 }
 
+// IsQuarantined checks if the tunnel was quarantined after a crash loop.
+func (t *Tunnel) IsQuarantined() bool {
+	return quarantine.IsQuarantined(t.Tag)
+}
+
 // IsServiceEnabled checks if the tunnel service is enabled to start on boot.
 func (t *Tunnel) IsServiceEnabled() bool {
 	return service.IsServiceEnabled(t.ServiceName)
@@ -88,8 +123,46 @@ func (t *Tunnel) IsInstalled() bool {
 	return service.IsServiceInstalled(t.ServiceName)
 }
 
+// ScheduleTimerName returns the name of the timer that applies the tunnel's schedule.
+func (t *Tunnel) ScheduleTimerName() string {
+	return paths.Service("schedule-" + t.Tag)
+}
+
+// SyncScheduleTimer installs a timer that runs "dnstm tunnel apply-schedule"
+// at every window boundary and shortly after boot, or removes it when the
+// tunnel has no schedule.
+func (t *Tunnel) SyncScheduleTimer() error {
+	name := t.ScheduleTimerName()
+	sched, err := t.Config.Schedule.Parse()
+	if err != nil {
+		return err
+	}
+	calendars := sched.OnCalendars()
+	if len(calendars) == 0 {
+		if service.IsTimerInstalled(name) {
+			return service.RemoveTimer(name)
+		}
+		return nil
+	}
+	return service.CreateTimer(&service.TimerConfig{
+		Name:        name,
+		Description: fmt.Sprintf("dnstm schedule for tunnel %s", t.Tag),
+		ExecStart:   cmdline.Join(paths.Bin("dnstm"), "tunnel", "apply-schedule", "-t", t.Tag),
+		OnCalendar:  calendars[0],
+		Calendars:   calendars[1:],
+		// The service is enabled at boot regardless of the schedule
+		OnBoot: time.Minute,
+	})
+}
+
 // RemoveService removes the systemd service for this tunnel.
 func (t *Tunnel) RemoveService() error {
+	quarantine.Clear(t.Tag)
+	logscan.Clear(t.Tag)
+	service.ForgetPeaks(t.ServiceName)
+	if service.IsTimerInstalled(t.ScheduleTimerName()) {
+		service.RemoveTimer(t.ScheduleTimerName())
+	}
 	service.StopService(t.ServiceName)
 	service.DisableService(t.ServiceName)
 	return service.RemoveService(t.ServiceName)
@@ -97,7 +170,7 @@ func (t *Tunnel) RemoveService() error {
 
 // SetPermissions sets the correct permissions for the tunnel files.
 func (t *Tunnel) SetPermissions() error {
-	configDir := filepath.Join(ConfigDir, "tunnels", t.Tag)
+	configDir := filepath.Join(TunnelsDir, t.Tag)
 
 	// Set ownership of tunnel config directory
 	if err := exec.Command("chown", "-R", system.DnstmUser+":"+system.DnstmUser, configDir).Run(); err != nil {
@@ -112,7 +185,7 @@ func (t *Tunnel) SetPermissions() error {
 
 // GetConfigDir returns the tunnel-specific config directory.
 func (t *Tunnel) GetConfigDir() string {
-	return filepath.Join(ConfigDir, "tunnels", t.Tag)
+	return filepath.Join(TunnelsDir, t.Tag)
 }
 
 // RemoveConfigDir removes the tunnel-specific config directory.
@@ -124,8 +197,17 @@ func (t *Tunnel) RemoveConfigDir() error {
 // StatusString returns a human-readable status string.
 func (t *Tunnel) StatusString() string {
 	if t.IsActive() {
+		if logscan.IsDegraded(t.Tag) {
+			return "Running (degraded)"
+		}
 		return "Running"
 	}
+	if t.IsQuarantined() {
+		return "Quarantined"
+	}
+	if t.Config != nil && t.Config.IsPaused() {
+		return "Paused"
+	}
 	if t.IsInstalled() {
 		return "Stopped"
 	}
@@ -150,9 +232,18 @@ Status:    %s
 		t.ServiceName,
 		t.StatusString(),
 	)
+	if t.Config != nil && t.Config.BindHost != "" {
+		info += fmt.Sprintf("Bind:      %s:53\n", t.Config.BindHost)
+	}
+	if t.Config != nil && t.Config.Rescue {
+		info += "Role:      rescue (emergency SSH access)\n"
+	}
 	if t.Transport == config.TransportDNSTT && t.Config != nil && t.Config.DNSTT != nil {
 		info += fmt.Sprintf("MTU:       %d\n", t.Config.DNSTT.MTU)
 	}
+	if t.Transport == config.TransportChisel && t.Config != nil && t.Config.Chisel != nil {
+		info += fmt.Sprintf("Auth:      %s\n", t.Config.Chisel.Auth)
+	}
 	if t.Transport == config.TransportVayDNS && t.Config != nil && t.Config.VayDNS != nil {
 		v := t.Config.VayDNS
 		info += fmt.Sprintf("MTU:       %d\n", v.MTU)
@@ -169,6 +260,48 @@ Status:    %s
 		}
 		info += fmt.Sprintf("Record:    %s\n", rt)
 	}
+	for _, line := range t.StatsLines() {
+		info += fmt.Sprintf("%-10s %s\n", line.Key+":", line.Value)
+	}
 	return info
 }
 
+// StatLine is a labeled resource figure of a running tunnel.
+type StatLine struct {
+	Key, Value string
+}
+
+// StatsLines returns the live resource use of a running tunnel with the
+// peaks recorded for it, or nil when the tunnel is stopped or its service
+// has no cgroup accounting. Reading them records new peaks.
+func (t *Tunnel) StatsLines() []StatLine {
+	if !t.IsActive() {
+		return nil
+	}
+	s, err := service.GetStats(t.ServiceName)
+	if err != nil {
+		return nil
+	}
+	peaks := service.RecordPeaks(t.ServiceName, s)
+	lines := []StatLine{
+		{"Memory", fmt.Sprintf("%s (peak %s)", usage.FormatBytes(s.Memory), usage.FormatBytes(peaks.Memory))},
+		{"CPU", fmt.Sprintf("%.1f%% (peak %.1f%%)", s.CPUPercent, peaks.CPUPercent)},
+		{"Tasks", fmt.Sprintf("%d (peak %d)", s.Tasks, peaks.Tasks)},
+	}
+	if up := s.Uptime(time.Now()); up > 0 {
+		lines = append(lines, StatLine{"Uptime", up.String()})
+	}
+	lines = append(lines, StatLine{"Peaks", "since " + peaks.Since.Local().Format("2006-01-02 15:04")})
+	return lines
+}
+
+
+// PrefetchStates looks up whether each tunnel is running with a single
+// systemctl call, for listings that then call IsActive on every tunnel.
+func PrefetchStates(tunnels []config.TunnelConfig) {
+	names := make([]string, len(tunnels))
+	for i, t := range tunnels {
+		names[i] = GetServiceName(t.Tag)
+	}
+	service.PrefetchActive(names...)
+}
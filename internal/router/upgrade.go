@@ -0,0 +1,119 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/transport"
+	"github.com/net2share/dnstm/internal/watchdog"
+)
+
+// selfTestTimeout bounds each self-test of a tunnel.
+const selfTestTimeout = 5 * time.Second
+
+// CandidateTag returns the tag the new version of a tunnel runs under while
+// an upgrade tests it.
+func CandidateTag(tag string) string {
+	return "upgrade-" + tag
+}
+
+// ReplaceBinary returns execStart with every argument equal to from replaced
+// by to, and whether any was. Watchdog-wrapped tunnels name the transport
+// binary after the dnstm one.
+func ReplaceBinary(execStart, from, to string) (string, bool) {
+	args := cmdline.Split(execStart)
+	found := false
+	for i, a := range args {
+		if a == from {
+			args[i] = to
+			found = true
+		}
+	}
+	return cmdline.Join(args...), found
+}
+
+// StartCandidate starts a copy of a tunnel that runs bin in place of the
+// installed transport binary, bound to port on loopback. The candidate uses
+// the tunnel's keys and backend but never takes its traffic; remove it with
+// RemoveService.
+func (r *Router) StartCandidate(tag, installed, bin string, port int) (*Tunnel, error) {
+	tunnelCfg := r.config.GetTunnelByTag(tag)
+	if tunnelCfg == nil {
+		return nil, fmt.Errorf("tunnel '%s' does not exist", tag)
+	}
+	backend := r.config.GetBackendByTag(tunnelCfg.Backend)
+	if backend == nil {
+		return nil, fmt.Errorf("backend '%s' not found for tunnel '%s'", tunnelCfg.Backend, tag)
+	}
+
+	candCfg := *tunnelCfg
+	candCfg.Port = port
+	candCfg.BindHost = ""
+	result, err := transport.NewBuilder().BuildTunnelService(&candCfg, backend, &transport.BuildOptions{
+		BindHost: "127.0.0.1",
+		BindPort: port,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build candidate service: %w", err)
+	}
+	execStart, ok := ReplaceBinary(result.ExecStart, installed, bin)
+	if !ok {
+		return nil, fmt.Errorf("tunnel %s does not run %s", tag, installed)
+	}
+	result.ExecStart = execStart
+	// A failing candidate is removed, not quarantined
+	result.RestartLimit = 0
+
+	cand := NewTunnel(&candCfg)
+	cand.Tag = CandidateTag(tag)
+	cand.ServiceName = paths.Service(cand.Tag)
+	if err := service.CreateGenericService(result.ServiceConfig(cand.ServiceName)); err != nil {
+		return nil, fmt.Errorf("failed to create candidate service: %w", err)
+	}
+	if err := service.StartService(cand.ServiceName); err != nil {
+		cand.RemoveService()
+		return nil, fmt.Errorf("failed to start candidate service: %w", err)
+	}
+	return cand, nil
+}
+
+// SelfTest checks that a tunnel answers on host:port: a DNS transport must
+// answer a query under its domain, others must accept a connection.
+func (t *Tunnel) SelfTest(host string, port int) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	if t.Transport.IsDNS() {
+		return watchdog.Probe(addr, t.Domain, selfTestTimeout)
+	}
+	c, err := net.DialTimeout("tcp", addr, selfTestTimeout)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// SelfTest runs the self-test against the address a tunnel is bound to in
+// the current mode.
+func (r *Router) SelfTest(tag string) error {
+	tunnel, exists := r.tunnels[tag]
+	if !exists {
+		return fmt.Errorf("tunnel %s not found", tag)
+	}
+	mode := ServiceModeMulti
+	if r.config.IsSingleMode() && r.config.Route.Active == tag {
+		mode = ServiceModeSingle
+	}
+	opts, err := NewServiceGenerator().GetBindOptions(tunnel.Config, mode)
+	if err != nil {
+		return fmt.Errorf("failed to get bind options: %w", err)
+	}
+	host := opts.BindHost
+	if host == "0.0.0.0" {
+		host = "127.0.0.1"
+	}
+	return tunnel.SelfTest(host, opts.BindPort)
+}
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"net"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func TestReplaceBinary(t *testing.T) {
+	tests := []struct {
+		name      string
+		execStart string
+		want      string
+		found     bool
+	}{
+		{
+			name:      "direct",
+			execStart: "/usr/local/bin/vaydns-server -udp 127.0.0.1:5310 -domain t.example.com",
+			want:      "/usr/local/bin/.dnstm-staging/vaydns-server -udp 127.0.0.1:5310 -domain t.example.com",
+			found:     true,
+		},
+		{
+			name:      "watchdog",
+			execStart: "/usr/local/bin/dnstm watchdog --addr 127.0.0.1:5310 -- /usr/local/bin/vaydns-server -udp 127.0.0.1:5310",
+			want:      "/usr/local/bin/dnstm watchdog --addr 127.0.0.1:5310 -- /usr/local/bin/.dnstm-staging/vaydns-server -udp 127.0.0.1:5310",
+			found:     true,
+		},
+		{
+			name:      "other binary",
+			execStart: "/usr/local/bin/slipstream-server --dns-listen-port 5310",
+			want:      "/usr/local/bin/slipstream-server --dns-listen-port 5310",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := ReplaceBinary(tt.execStart, "/usr/local/bin/vaydns-server", "/usr/local/bin/.dnstm-staging/vaydns-server")
+			if got != tt.want || found != tt.found {
+				t.Errorf("ReplaceBinary() = %q, %v; want %q, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestTunnelSelfTest_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	tunnel := NewTunnel(&config.TunnelConfig{Tag: "chisel1", Transport: config.TransportChisel, Port: port})
+	if err := tunnel.SelfTest("127.0.0.1", port); err != nil {
+		t.Errorf("SelfTest() with a listener: %v", err)
+	}
+
+	ln.Close()
+	if err := tunnel.SelfTest("127.0.0.1", port); err == nil {
+		t.Error("SelfTest() without a listener succeeded")
+	}
+}
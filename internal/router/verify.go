@@ -0,0 +1,233 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/dnsrouter"
+	"github.com/net2share/dnstm/internal/service"
+	"github.com/net2share/dnstm/internal/system"
+	"github.com/net2share/dnstm/internal/transport"
+)
+
+// DriftKind is how a file differs from what the config produces.
+type DriftKind string
+
+const (
+	DriftMissing  DriftKind = "missing"
+	DriftModified DriftKind = "modified"
+	DriftOwner    DriftKind = "owner"
+	DriftMode     DriftKind = "mode"
+)
+
+// Drift is a file of the DNS router or a tunnel that does not match the
+// config: a unit or transport config that was edited or deleted, or a
+// tunnel file with the wrong owner or mode.
+type Drift struct {
+	Tunnel string // tunnel tag; empty for the DNS router
+	Path   string
+	Kind   DriftKind
+	Detail string
+
+	generated bool        // the file is generated from the config
+	mode      os.FileMode // wanted mode, for DriftMode
+}
+
+// Fixable reports whether FixDrift can repair the drift. A missing key or
+// certificate cannot be generated again without breaking clients.
+func (d *Drift) Fixable() bool {
+	return d.generated || d.Kind != DriftMissing
+}
+
+// ownedByDnstm reports whether the dnstm user owns a file; tests replace it.
+var ownedByDnstm = system.IsOwnedByDnstm
+
+// Verify compares the DNS router unit, and the units, transport configs,
+// keys and certificates of all tunnels with what cfg produces.
+func Verify(cfg *config.Config) ([]Drift, error) {
+	var drifts []Drift
+
+	svc := dnsrouter.NewService()
+	routerUnit := service.GetServicePath(dnsrouter.ServiceName)
+	if !svc.IsServiceInstalled() {
+		drifts = append(drifts, Drift{Path: routerUnit, Kind: DriftMissing, Detail: "missing", generated: true})
+	} else if !svc.IsServiceCurrent() {
+		drifts = append(drifts, Drift{Path: routerUnit, Kind: DriftModified, Detail: "differs from the config", generated: true})
+	}
+
+	for i := range cfg.Tunnels {
+		t := &cfg.Tunnels[i]
+		result, err := buildTunnel(cfg, t)
+		if err != nil {
+			return nil, fmt.Errorf("tunnel '%s': %w", t.Tag, err)
+		}
+		drifts = append(drifts, verifyTunnel(t, result)...)
+	}
+	return drifts, nil
+}
+
+// buildTunnel builds the service of a tunnel as it would be created now,
+// without writing anything.
+func buildTunnel(cfg *config.Config, t *config.TunnelConfig) (*transport.TunnelBuildResult, error) {
+	backend := cfg.GetBackendByTag(t.Backend)
+	if backend == nil {
+		return nil, fmt.Errorf("backend '%s' not found", t.Backend)
+	}
+	mode := ServiceModeMulti
+	if cfg.IsSingleMode() && cfg.Route.Active == t.Tag {
+		mode = ServiceModeSingle
+	}
+	opts, err := NewServiceGenerator().GetBindOptions(t, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bind options: %w", err)
+	}
+	return transport.NewBuilder().BuildTunnelService(t, backend, opts)
+}
+
+// verifyTunnel compares the installed files of a tunnel with result.
+func verifyTunnel(t *config.TunnelConfig, result *transport.TunnelBuildResult) []Drift {
+	var drifts []Drift
+	name := NewTunnel(t).ServiceName
+	unit := service.GetServicePath(name)
+	if !service.IsServiceInstalled(name) {
+		drifts = append(drifts, Drift{Tunnel: t.Tag, Path: unit, Kind: DriftMissing, Detail: "missing", generated: true})
+	} else if !service.IsUnitCurrent(result.ServiceConfig(name)) {
+		drifts = append(drifts, Drift{Tunnel: t.Tag, Path: unit, Kind: DriftModified, Detail: "differs from the config", generated: true})
+	}
+
+	for _, f := range result.Files {
+		drifts = append(drifts, checkFile(t.Tag, f.Path, f.Data, f.Mode, true)...)
+	}
+
+	// Keys and certificates are kept, not generated: only their owner
+	// and the mode of private keys are checked
+	drifts = append(drifts, checkFile(t.Tag, filepath.Join(TunnelsDir, t.Tag), nil, 0, false)...)
+	for _, path := range secretFiles(t) {
+		drifts = append(drifts, checkFile(t.Tag, path, nil, 0600, false)...)
+	}
+	for _, path := range certFiles(t) {
+		drifts = append(drifts, checkFile(t.Tag, path, nil, 0, false)...)
+	}
+	return drifts
+}
+
+// secretFiles returns the private key files of a tunnel.
+func secretFiles(t *config.TunnelConfig) []string {
+	var files []string
+	if t.DNSTT != nil && t.DNSTT.PrivateKey != "" {
+		files = append(files, t.DNSTT.PrivateKey)
+	}
+	if t.VayDNS != nil && t.VayDNS.PrivateKey != "" {
+		files = append(files, t.VayDNS.PrivateKey)
+	}
+	if t.Slipstream != nil && t.Slipstream.Key != "" {
+		files = append(files, t.Slipstream.Key)
+	}
+	if t.Chisel != nil && t.Chisel.Key != "" {
+		files = append(files, t.Chisel.Key)
+	}
+	return files
+}
+
+// certFiles returns the certificate files of a tunnel.
+func certFiles(t *config.TunnelConfig) []string {
+	var files []string
+	if t.Slipstream != nil && t.Slipstream.Cert != "" {
+		files = append(files, t.Slipstream.Cert)
+	}
+	if t.Chisel != nil && t.Chisel.Cert != "" {
+		files = append(files, t.Chisel.Cert)
+	}
+	return files
+}
+
+// checkFile compares a file with want, when not nil, and mode, when not
+// zero, and checks that the dnstm user owns it.
+func checkFile(tag, path string, want []byte, mode os.FileMode, generated bool) []Drift {
+	info, err := os.Stat(path)
+	if err != nil {
+		return []Drift{{Tunnel: tag, Path: path, Kind: DriftMissing, Detail: "missing", generated: generated}}
+	}
+
+	var drifts []Drift
+	if want != nil {
+		if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, want) {
+			drifts = append(drifts, Drift{Tunnel: tag, Path: path, Kind: DriftModified, Detail: "differs from the config", generated: generated})
+		}
+	}
+	if owned, err := ownedByDnstm(info); err == nil && !owned {
+		drifts = append(drifts, Drift{Tunnel: tag, Path: path, Kind: DriftOwner, Detail: "not owned by " + system.DnstmUser, generated: generated})
+	}
+	if mode != 0 && info.Mode().Perm() != mode {
+		drifts = append(drifts, Drift{
+			Tunnel:    tag,
+			Path:      path,
+			Kind:      DriftMode,
+			Detail:    fmt.Sprintf("mode %04o, want %04o", info.Mode().Perm(), mode),
+			generated: generated,
+			mode:      mode,
+		})
+	}
+	return drifts
+}
+
+// FixDrift regenerates the DNS router and tunnel services whose files
+// drifted, restarting the running ones, and repairs the owner and mode of
+// keys and certificates. It returns the drifts it cannot fix.
+func FixDrift(cfg *config.Config, drifts []Drift) ([]Drift, error) {
+	var unfixed []Drift
+	regenerate := make(map[string]bool)
+	var tags []string
+	routerDrift := false
+
+	for _, d := range drifts {
+		switch {
+		case !d.Fixable():
+			unfixed = append(unfixed, d)
+		case d.Tunnel == "":
+			routerDrift = true
+		case d.generated:
+			if !regenerate[d.Tunnel] {
+				regenerate[d.Tunnel] = true
+				tags = append(tags, d.Tunnel)
+			}
+		case d.Kind == DriftOwner:
+			if err := system.ChownToDnstm(d.Path); err != nil {
+				return unfixed, fmt.Errorf("failed to change owner of %s: %w", d.Path, err)
+			}
+		case d.Kind == DriftMode:
+			if err := os.Chmod(d.Path, d.mode); err != nil {
+				return unfixed, fmt.Errorf("failed to change mode of %s: %w", d.Path, err)
+			}
+		}
+	}
+
+	if routerDrift {
+		svc := dnsrouter.NewService()
+		if err := svc.CreateService(); err != nil {
+			return unfixed, fmt.Errorf("failed to create DNS router service: %w", err)
+		}
+		if svc.IsActive() {
+			if err := svc.Restart(); err != nil {
+				return unfixed, fmt.Errorf("failed to restart DNS router: %w", err)
+			}
+		}
+	}
+
+	if len(tags) == 0 {
+		return unfixed, nil
+	}
+	r, err := New(cfg)
+	if err != nil {
+		return unfixed, err
+	}
+	for _, tag := range tags {
+		if err := r.RegenerateTunnel(tag); err != nil {
+			return unfixed, fmt.Errorf("failed to regenerate tunnel '%s': %w", tag, err)
+		}
+	}
+	return unfixed, nil
+}
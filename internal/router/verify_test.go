@@ -0,0 +1,76 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func TestCheckFile(t *testing.T) {
+	owned := true
+	old := ownedByDnstm
+	ownedByDnstm = func(os.FileInfo) (bool, error) { return owned, nil }
+	defer func() { ownedByDnstm = old }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := checkFile("slip", path, []byte("{}"), 0600, true); len(got) != 0 {
+		t.Errorf("checkFile() of a current file = %+v", got)
+	}
+
+	owned = false
+	got := checkFile("slip", path, []byte(`{"edited":true}`), 0644, true)
+	var kinds []DriftKind
+	for _, d := range got {
+		kinds = append(kinds, d.Kind)
+		if d.Tunnel != "slip" || d.Path != path || !d.Fixable() {
+			t.Errorf("drift = %+v", d)
+		}
+	}
+	if want := []DriftKind{DriftModified, DriftOwner, DriftMode}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("checkFile() kinds = %v, want %v", kinds, want)
+	}
+	if got[2].Detail != "mode 0600, want 0644" {
+		t.Errorf("mode detail = %q", got[2].Detail)
+	}
+
+	missing := checkFile("slip", filepath.Join(dir, "key.pem"), nil, 0600, false)
+	if len(missing) != 1 || missing[0].Kind != DriftMissing || missing[0].Fixable() {
+		t.Errorf("checkFile() of a missing key = %+v", missing)
+	}
+	if got := checkFile("slip", filepath.Join(dir, "gone.json"), []byte("{}"), 0644, true); len(got) != 1 || !got[0].Fixable() {
+		t.Errorf("checkFile() of a missing generated file = %+v", got)
+	}
+}
+
+func TestFixDrift_KeyMode(t *testing.T) {
+	old := ownedByDnstm
+	ownedByDnstm = func(os.FileInfo) (bool, error) { return true, nil }
+	defer func() { ownedByDnstm = old }()
+
+	dir := t.TempDir()
+	key := filepath.Join(dir, "server.key")
+	if err := os.WriteFile(key, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	drifts := checkFile("dnstt", key, nil, 0600, false)
+	drifts = append(drifts, checkFile("dnstt", filepath.Join(dir, "missing.key"), nil, 0600, false)...)
+
+	unfixed, err := FixDrift(&config.Config{}, drifts)
+	if err != nil {
+		t.Fatalf("FixDrift() error = %v", err)
+	}
+	if len(unfixed) != 1 || unfixed[0].Kind != DriftMissing {
+		t.Errorf("FixDrift() unfixed = %+v", unfixed)
+	}
+	if info, err := os.Stat(key); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key mode after fix = %v, %v", info.Mode().Perm(), err)
+	}
+}
@@ -0,0 +1,48 @@
+package schedule
+
+import (
+	"sort"
+	"time"
+)
+
+// Schedule limits when a tunnel runs. It runs inside any Active window, or
+// at all times when there are none, except during Blackout windows.
+type Schedule struct {
+	Active   []Window
+	Blackout []Window
+}
+
+// Allows reports whether the tunnel should be running at t.
+func (s Schedule) Allows(t time.Time) bool {
+	for _, w := range s.Blackout {
+		if w.Contains(t) {
+			return false
+		}
+	}
+	if len(s.Active) == 0 {
+		return true
+	}
+	for _, w := range s.Active {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnCalendars returns a systemd calendar expression for every window start
+// and end, the only moments at which Allows can change.
+func (s Schedule) OnCalendars() []string {
+	seen := make(map[string]bool)
+	var calendars []string
+	for _, w := range append(append([]Window{}, s.Active...), s.Blackout...) {
+		for _, c := range []string{w.OnCalendar(), w.EndCalendar()} {
+			if !seen[c] {
+				seen[c] = true
+				calendars = append(calendars, c)
+			}
+		}
+	}
+	sort.Strings(calendars)
+	return calendars
+}
@@ -0,0 +1,89 @@
+// Package schedule parses daily time windows and decides whether a
+// scheduled tunnel should be running.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time window in local time. It may wrap past midnight.
+type Window struct {
+	Start int // Minutes after midnight
+	End   int // Minutes after midnight
+}
+
+// ParseWindow parses a window in "HH:MM-HH:MM" form.
+func ParseWindow(s string) (Window, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return Window{}, fmt.Errorf("invalid window '%s' (expected HH:MM-HH:MM)", s)
+	}
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window '%s': %w", s, err)
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window '%s': %w", s, err)
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("invalid window '%s': start and end are equal", s)
+	}
+	return Window{Start: start, End: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a HH:MM time", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// String returns the window in "HH:MM-HH:MM" form.
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// Length returns the duration of the window.
+func (w Window) Length() time.Duration {
+	minutes := w.End - w.Start
+	if minutes < 0 {
+		minutes += 24 * 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Contains reports whether t falls inside the window.
+func (w Window) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return m >= w.Start && m < w.End
+	}
+	return m >= w.Start || m < w.End
+}
+
+// OnCalendar returns the systemd calendar expression for the window start.
+func (w Window) OnCalendar() string {
+	return fmt.Sprintf("*-*-* %02d:%02d:00", w.Start/60, w.Start%60)
+}
+
+// EndCalendar returns the systemd calendar expression for the window end.
+func (w Window) EndCalendar() string {
+	return fmt.Sprintf("*-*-* %02d:%02d:00", w.End/60, w.End%60)
+}
+
+// ParseWindows parses a list of windows in "HH:MM-HH:MM" form.
+func ParseWindows(specs []string) ([]Window, error) {
+	windows := make([]Window, 0, len(specs))
+	for _, s := range specs {
+		w, err := ParseWindow(s)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
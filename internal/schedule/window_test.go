@@ -0,0 +1,110 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		length  time.Duration
+		wantErr bool
+	}{
+		{"03:00-05:00", "03:00-05:00", 2 * time.Hour, false},
+		{"3:30-4:00", "03:30-04:00", 30 * time.Minute, false},
+		{"23:00-01:00", "23:00-01:00", 2 * time.Hour, false},
+		{"03:00", "", 0, true},
+		{"03:00-03:00", "", 0, true},
+		{"25:00-01:00", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			w, err := ParseWindow(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindow(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if w.String() != tt.want {
+				t.Errorf("String() = %q, want %q", w.String(), tt.want)
+			}
+			if w.Length() != tt.length {
+				t.Errorf("Length() = %v, want %v", w.Length(), tt.length)
+			}
+		})
+	}
+}
+
+func TestWindow_Contains(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 1, 1, h, m, 0, 0, time.Local) }
+
+	day, _ := ParseWindow("03:00-05:00")
+	if !day.Contains(at(3, 0)) || !day.Contains(at(4, 59)) {
+		t.Error("expected times inside 03:00-05:00")
+	}
+	if day.Contains(at(5, 0)) || day.Contains(at(2, 59)) {
+		t.Error("expected times outside 03:00-05:00")
+	}
+
+	wrap, _ := ParseWindow("23:00-01:00")
+	if !wrap.Contains(at(23, 30)) || !wrap.Contains(at(0, 30)) {
+		t.Error("expected times inside 23:00-01:00")
+	}
+	if wrap.Contains(at(1, 0)) || wrap.Contains(at(12, 0)) {
+		t.Error("expected times outside 23:00-01:00")
+	}
+
+	if got := day.OnCalendar(); got != "*-*-* 03:00:00" {
+		t.Errorf("OnCalendar() = %q", got)
+	}
+}
+
+func TestSchedule_Allows(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 1, 1, h, m, 0, 0, time.Local) }
+
+	evening, _ := ParseWindows([]string{"18:00-02:00"})
+	blackout, _ := ParseWindows([]string{"20:00-20:30"})
+
+	tests := []struct {
+		name  string
+		sched Schedule
+		at    time.Time
+		want  bool
+	}{
+		{"no windows", Schedule{}, at(12, 0), true},
+		{"inside active", Schedule{Active: evening}, at(23, 0), true},
+		{"after midnight", Schedule{Active: evening}, at(1, 59), true},
+		{"outside active", Schedule{Active: evening}, at(12, 0), false},
+		{"blackout only", Schedule{Blackout: blackout}, at(20, 15), false},
+		{"blackout wins over active", Schedule{Active: evening, Blackout: blackout}, at(20, 15), false},
+		{"active after blackout", Schedule{Active: evening, Blackout: blackout}, at(20, 30), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sched.Allows(tt.at); got != tt.want {
+				t.Errorf("Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_OnCalendars(t *testing.T) {
+	active, _ := ParseWindows([]string{"18:00-02:00"})
+	blackout, _ := ParseWindows([]string{"02:00-03:00"})
+
+	got := Schedule{Active: active, Blackout: blackout}.OnCalendars()
+	want := []string{"*-*-* 02:00:00", "*-*-* 03:00:00", "*-*-* 18:00:00"}
+	if len(got) != len(want) {
+		t.Fatalf("OnCalendars() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("OnCalendars()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activeCacheTTL bounds how long states fetched by PrefetchActive are used.
+const activeCacheTTL = 2 * time.Second
+
+type cachedState struct {
+	active bool
+	at     time.Time
+}
+
+var activeCache = struct {
+	mu     sync.Mutex
+	states map[string]cachedState
+}{states: make(map[string]cachedState)}
+
+// queryActiveStates runs one `systemctl is-active` for all names, which
+// prints one state per unit in order.
+var queryActiveStates = func(names []string) ([]string, error) {
+	output, err := exec.Command("systemctl", append([]string{"is-active"}, names...)...).Output()
+	// The exit status is non-zero when any unit is inactive
+	states := strings.Fields(string(output))
+	if len(states) != len(names) {
+		return nil, err
+	}
+	return states, nil
+}
+
+// PrefetchActive looks up whether each service is active with a single
+// systemctl call, so the IsServiceActive calls that follow within a
+// couple of seconds do not each run systemctl. Listings and menus call it
+// before showing the state of many services.
+func PrefetchActive(names ...string) {
+	if len(names) == 0 {
+		return
+	}
+	states, err := queryActiveStates(names)
+	if err != nil || states == nil {
+		return
+	}
+
+	now := time.Now()
+	activeCache.mu.Lock()
+	defer activeCache.mu.Unlock()
+	for i, name := range names {
+		activeCache.states[name] = cachedState{active: states[i] == "active", at: now}
+	}
+}
+
+// cachedActive returns the prefetched state of a service, if still fresh.
+func cachedActive(name string) (active, ok bool) {
+	activeCache.mu.Lock()
+	defer activeCache.mu.Unlock()
+	s, ok := activeCache.states[name]
+	if !ok || time.Since(s.at) > activeCacheTTL {
+		return false, false
+	}
+	return s.active, true
+}
+
+// forgetActive drops the prefetched state of a service after an action
+// that may change it.
+func forgetActive(name string) {
+	activeCache.mu.Lock()
+	delete(activeCache.states, name)
+	activeCache.mu.Unlock()
+}
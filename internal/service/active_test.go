@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrefetchActive(t *testing.T) {
+	origQuery := queryActiveStates
+	defer func() { queryActiveStates = origQuery }()
+
+	calls := 0
+	queryActiveStates = func(names []string) ([]string, error) {
+		calls++
+		states := make([]string, len(names))
+		for i, name := range names {
+			states[i] = "inactive"
+			if name == "dnstm-a" {
+				states[i] = "active"
+			}
+		}
+		return states, nil
+	}
+
+	PrefetchActive("dnstm-a", "dnstm-b")
+	defer forgetActive("dnstm-a")
+	defer forgetActive("dnstm-b")
+	if calls != 1 {
+		t.Fatalf("PrefetchActive() ran systemctl %d times, want 1", calls)
+	}
+	if active, ok := cachedActive("dnstm-a"); !ok || !active {
+		t.Errorf("cachedActive(dnstm-a) = %v, %v, want true, true", active, ok)
+	}
+	if !IsServiceActive("dnstm-a") || IsServiceActive("dnstm-b") {
+		t.Error("IsServiceActive() did not use the prefetched states")
+	}
+
+	forgetActive("dnstm-a")
+	if _, ok := cachedActive("dnstm-a"); ok {
+		t.Error("state still cached after forgetActive()")
+	}
+
+	activeCache.mu.Lock()
+	activeCache.states["dnstm-b"] = cachedState{at: time.Now().Add(-2 * activeCacheTTL)}
+	activeCache.mu.Unlock()
+	if _, ok := cachedActive("dnstm-b"); ok {
+		t.Error("expired state still used")
+	}
+}
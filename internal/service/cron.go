@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Without systemd, timers become entries in root's crontab, each tagged
+// with a trailing "# dnstm:<name>" comment. The same calendar expressions as
+// on Windows are translated; seconds are dropped. The random delay uses awk,
+// which the BSDs and BusyBox both ship.
+
+// cronMarker returns the comment that tags the crontab entries of a timer.
+func cronMarker(name string) string {
+	return "# dnstm:" + name
+}
+
+// cronEntries translates the schedule of a timer into crontab lines.
+func cronEntries(cfg *TimerConfig) ([]string, error) {
+	command := strings.TrimSpace(cfg.ExecStart)
+	if command == "" {
+		return nil, fmt.Errorf("timer %s has no command", cfg.Name)
+	}
+	if env := environment(nil); len(env) > 0 {
+		command = strings.Join(env, " ") + " " + command
+	}
+	if cfg.RandomDelay > 0 {
+		command = fmt.Sprintf("sleep $(awk 'BEGIN{srand(); print int(rand()*%d)}'); %s", int(cfg.RandomDelay.Seconds())+1, command)
+	}
+	marker := cronMarker(cfg.Name)
+
+	var lines []string
+	calendars := cfg.Calendars
+	if cfg.OnCalendar != "" {
+		calendars = append([]string{cfg.OnCalendar}, calendars...)
+	}
+	for _, c := range calendars {
+		spec, guard, err := cronSpec(c)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s", spec, cronEscape(guard+command), marker))
+	}
+	if cfg.OnBoot > 0 {
+		lines = append(lines, fmt.Sprintf("@reboot sleep %d; %s %s", int(cfg.OnBoot.Seconds()), cronEscape(command), marker))
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("timer %s has no schedule", cfg.Name)
+	}
+	return lines, nil
+}
+
+// cronSpec translates one systemd calendar expression into the five time
+// fields of a crontab line. Cron has no year field, so one-off times also
+// return a guard that checks the year before the command runs.
+func cronSpec(calendar string) (spec, guard string, err error) {
+	switch {
+	case dailyCalendar.MatchString(calendar):
+		m := dailyCalendar.FindStringSubmatch(calendar)
+		return fmt.Sprintf("%s %s * * *", trimZero(m[2]), trimZero(m[1])), "", nil
+	case absoluteCalendar.MatchString(calendar):
+		t, err := time.Parse("2006-01-02 15:04:05", calendar)
+		if err != nil {
+			t, err = time.Parse("2006-01-02 15:04", calendar)
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("invalid calendar time %q: %w", calendar, err)
+		}
+		return fmt.Sprintf("%d %d %d %d *", t.Minute(), t.Hour(), t.Day(), int(t.Month())),
+			fmt.Sprintf(`[ "$(date +%%Y)" = "%d" ] || exit 0; `, t.Year()), nil
+	case stepCalendar.MatchString(calendar):
+		minutes, _ := strconv.Atoi(stepCalendar.FindStringSubmatch(calendar)[1])
+		if minutes >= 1 && minutes < 60 {
+			return fmt.Sprintf("*/%d * * * *", minutes), "", nil
+		}
+	}
+	return "", "", fmt.Errorf("calendar expression %q is not supported by cron", calendar)
+}
+
+// trimZero drops the leading zero of a two-digit time field.
+func trimZero(s string) string {
+	n, _ := strconv.Atoi(s)
+	return strconv.Itoa(n)
+}
+
+// cronEscape escapes the characters cron treats specially in a command.
+func cronEscape(command string) string {
+	return strings.ReplaceAll(command, "%", `\%`)
+}
+
+// replaceCronEntries returns crontab with the entries of a timer replaced
+// by lines. Empty lines removes the timer.
+func replaceCronEntries(crontab, name string, lines []string) string {
+	marker := cronMarker(name)
+	var out []string
+	for _, line := range strings.Split(strings.TrimRight(crontab, "\n"), "\n") {
+		if line == "" && len(out) == 0 {
+			continue
+		}
+		if strings.HasSuffix(line, " "+marker) {
+			continue
+		}
+		out = append(out, line)
+	}
+	out = append(out, lines...)
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronEntries(t *testing.T) {
+	tests := []struct {
+		cfg  TimerConfig
+		want []string
+	}{
+		{
+			TimerConfig{Name: "dnstm-autoupdate", ExecStart: "/usr/local/bin/dnstm auto-update run", OnCalendar: "*-*-* 03:05:00"},
+			[]string{"5 3 * * * /usr/local/bin/dnstm auto-update run # dnstm:dnstm-autoupdate"},
+		},
+		{
+			TimerConfig{Name: "fw", ExecStart: "dnstm firewall rollback", OnCalendar: "2026-05-01 12:34:56"},
+			[]string{`34 12 1 5 * [ "$(date +\%Y)" = "2026" ] || exit 0; dnstm firewall rollback # dnstm:fw`},
+		},
+		{
+			TimerConfig{Name: "r", ExecStart: "dnstm report collect", OnCalendar: "*:0/5", OnBoot: 2 * time.Minute},
+			[]string{"*/5 * * * * dnstm report collect # dnstm:r", "@reboot sleep 120; dnstm report collect # dnstm:r"},
+		},
+		{
+			TimerConfig{Name: "s", ExecStart: "x", Calendars: []string{"*-*-* 01:00", "*-*-* 13:00"}, RandomDelay: time.Hour},
+			[]string{"0 1 * * * sleep $(awk 'BEGIN{srand(); print int(rand()*3601)}'); x # dnstm:s", "0 13 * * * sleep $(awk 'BEGIN{srand(); print int(rand()*3601)}'); x # dnstm:s"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := cronEntries(&tt.cfg)
+		if err != nil {
+			t.Errorf("%s: %v", tt.cfg.Name, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.cfg.Name, got, tt.want)
+		}
+	}
+
+	for _, calendar := range []string{"Mon *-*-* 03:00:00", "*:0/90", "weekly"} {
+		if _, err := cronEntries(&TimerConfig{Name: "t", ExecStart: "x", OnCalendar: calendar}); err == nil {
+			t.Errorf("cronEntries(%q) succeeded, want unsupported", calendar)
+		}
+	}
+}
+
+func TestReplaceCronEntries(t *testing.T) {
+	crontab := "MAILTO=root\n0 1 * * * old # dnstm:a\n0 2 * * * other # dnstm:ab\n"
+
+	got := replaceCronEntries(crontab, "a", []string{"0 3 * * * new # dnstm:a"})
+	want := "MAILTO=root\n0 2 * * * other # dnstm:ab\n0 3 * * * new # dnstm:a\n"
+	if got != want {
+		t.Errorf("replace:\ngot  %q\nwant %q", got, want)
+	}
+
+	got = replaceCronEntries("0 1 * * * old # dnstm:a\n", "a", nil)
+	if got != "" {
+		t.Errorf("remove last entry = %q, want empty", got)
+	}
+}
@@ -0,0 +1,467 @@
+package service
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemBusSocket is the system bus used when DBUS_SYSTEM_BUS_ADDRESS is unset.
+const systemBusSocket = "/run/dbus/system_bus_socket"
+
+// D-Bus message types
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+	dbusSignal       = 4
+)
+
+// dbusConn is a minimal D-Bus client: enough to call methods with string
+// arguments and to decode the signals systemd sends. Its only user is
+// WatchServices; anything beyond that belongs in a real D-Bus library.
+type dbusConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	serial uint32
+}
+
+// dbusMessage is a decoded D-Bus message.
+type dbusMessage struct {
+	Type        byte
+	Serial      uint32
+	ReplySerial uint32
+	Path        string
+	Interface   string
+	Member      string
+	ErrorName   string
+	Signature   string
+	Body        []any
+}
+
+// dialSystemBus connects and authenticates to the system bus.
+func dialSystemBus() (*dbusConn, error) {
+	path := systemBusSocket
+	if addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); strings.HasPrefix(addr, "unix:path=") {
+		path, _, _ = strings.Cut(strings.TrimPrefix(addr, "unix:path="), ",")
+	}
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the system bus: %w", err)
+	}
+
+	c := &dbusConn{conn: conn, r: bufio.NewReader(conn)}
+	if err := c.auth(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// auth authenticates as the current user with the EXTERNAL mechanism.
+func (c *dbusConn) auth() error {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	defer c.conn.SetDeadline(time.Time{})
+
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := fmt.Fprintf(c.conn, "\x00AUTH EXTERNAL %s\r\n", uid); err != nil {
+		return err
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("D-Bus authentication failed: %w", err)
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("D-Bus authentication rejected: %s", strings.TrimSpace(line))
+	}
+	_, err = io.WriteString(c.conn, "BEGIN\r\n")
+	return err
+}
+
+// Close closes the connection.
+func (c *dbusConn) Close() error {
+	return c.conn.Close()
+}
+
+// call calls a method with string arguments and waits for its reply,
+// discarding any other message that arrives first.
+func (c *dbusConn) call(dest, path, iface, member string, args ...string) error {
+	serial, err := c.send(dest, path, iface, member, args...)
+	if err != nil {
+		return err
+	}
+	for {
+		m, err := c.read()
+		if err != nil {
+			return err
+		}
+		if m.ReplySerial != serial {
+			continue
+		}
+		if m.Type == dbusError {
+			detail := ""
+			if len(m.Body) > 0 {
+				detail, _ = m.Body[0].(string)
+			}
+			return fmt.Errorf("%s.%s failed: %s: %s", iface, member, m.ErrorName, detail)
+		}
+		return nil
+	}
+}
+
+// send writes a method call and returns its serial.
+func (c *dbusConn) send(dest, path, iface, member string, args ...string) (uint32, error) {
+	c.serial++
+
+	var body dbusEncoder
+	for _, arg := range args {
+		body.string(arg)
+	}
+
+	var e dbusEncoder
+	e.buf = append(e.buf, 'l', dbusMethodCall, 0, 1)
+	e.uint32(uint32(len(body.buf)))
+	e.uint32(c.serial)
+
+	// Header fields, a(yv)
+	lenAt := len(e.buf)
+	e.uint32(0)
+	start := len(e.buf)
+	e.field(1, "o", path)
+	if iface != "" {
+		e.field(2, "s", iface)
+	}
+	e.field(3, "s", member)
+	if dest != "" {
+		e.field(6, "s", dest)
+	}
+	if len(args) > 0 {
+		e.field(8, "g", strings.Repeat("s", len(args)))
+	}
+	binary.LittleEndian.PutUint32(e.buf[lenAt:], uint32(len(e.buf)-start))
+	e.align(8)
+
+	_, err := c.conn.Write(append(e.buf, body.buf...))
+	return c.serial, err
+}
+
+// read reads and decodes the next message.
+func (c *dbusConn) read() (*dbusMessage, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(c.r, head); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if head[0] == 'B' {
+		order = binary.BigEndian
+	}
+	bodyLen := order.Uint32(head[4:8])
+	fieldsLen := order.Uint32(head[12:16])
+	if bodyLen > 1<<27 || fieldsLen > 1<<26 {
+		return nil, errors.New("D-Bus message too large")
+	}
+	headerLen := 16 + int(fieldsLen)
+	padded := (headerLen + 7) &^ 7
+
+	packet := make([]byte, padded+int(bodyLen))
+	copy(packet, head)
+	if _, err := io.ReadFull(c.r, packet[16:]); err != nil {
+		return nil, err
+	}
+	m, err := parseDBusMessage(packet, order)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDBusUndecodable, err)
+	}
+	return m, nil
+}
+
+// parseDBusMessage decodes a complete message.
+func parseDBusMessage(packet []byte, order binary.ByteOrder) (*dbusMessage, error) {
+	d := &dbusDecoder{buf: packet, off: 12, order: order}
+	fields, err := d.value("a(yv)")
+	if err != nil {
+		return nil, fmt.Errorf("invalid D-Bus header: %w", err)
+	}
+
+	m := &dbusMessage{Type: packet[1], Serial: order.Uint32(packet[8:12])}
+	for _, f := range fields.([]any) {
+		field := f.([]any)
+		switch field[0].(byte) {
+		case 1:
+			m.Path, _ = field[1].(string)
+		case 2:
+			m.Interface, _ = field[1].(string)
+		case 3:
+			m.Member, _ = field[1].(string)
+		case 4:
+			m.ErrorName, _ = field[1].(string)
+		case 5:
+			m.ReplySerial, _ = field[1].(uint32)
+		case 8:
+			m.Signature, _ = field[1].(string)
+		}
+	}
+
+	d.off = (d.off + 7) &^ 7
+	if m.Signature != "" {
+		if m.Body, err = d.values(m.Signature); err != nil {
+			return nil, fmt.Errorf("invalid D-Bus body: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// dbusEncoder writes little-endian D-Bus data.
+type dbusEncoder struct {
+	buf []byte
+}
+
+func (e *dbusEncoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *dbusEncoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *dbusEncoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(append(e.buf, s...), 0)
+}
+
+func (e *dbusEncoder) signature(s string) {
+	e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+}
+
+// field writes a header field holding a string, object path or signature.
+func (e *dbusEncoder) field(code byte, sig, value string) {
+	e.align(8)
+	e.buf = append(e.buf, code)
+	e.signature(sig)
+	if sig == "g" {
+		e.signature(value)
+	} else {
+		e.string(value)
+	}
+}
+
+// dbusDecoder reads D-Bus data. Arrays decode to []any, structs and dict
+// entries to []any of their members, and variants to their value.
+type dbusDecoder struct {
+	buf   []byte
+	off   int
+	order binary.ByteOrder
+}
+
+var errDBusShort = errors.New("D-Bus data too short")
+
+// errDBusUndecodable wraps the error of a message that was read whole but
+// could not be decoded; the connection is still usable.
+var errDBusUndecodable = errors.New("undecodable D-Bus message")
+
+func (d *dbusDecoder) align(n int) error {
+	off := (d.off + n - 1) / n * n
+	if off > len(d.buf) {
+		return errDBusShort
+	}
+	d.off = off
+	return nil
+}
+
+func (d *dbusDecoder) next(n int) ([]byte, error) {
+	if n < 0 || d.off+n > len(d.buf) {
+		return nil, errDBusShort
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// values decodes a sequence of complete types.
+func (d *dbusDecoder) values(sig string) ([]any, error) {
+	var out []any
+	for sig != "" {
+		t, err := firstType(sig)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(t)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+		sig = sig[len(t):]
+	}
+	return out, nil
+}
+
+// value decodes one complete type.
+func (d *dbusDecoder) value(t string) (any, error) {
+	if err := d.align(typeAlignment(t[0])); err != nil {
+		return nil, err
+	}
+	switch t[0] {
+	case 'y':
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'n', 'q':
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		if t[0] == 'n' {
+			return int16(d.order.Uint16(b)), nil
+		}
+		return d.order.Uint16(b), nil
+	case 'b', 'i', 'u', 'h':
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		v := d.order.Uint32(b)
+		switch t[0] {
+		case 'b':
+			return v != 0, nil
+		case 'i':
+			return int32(v), nil
+		}
+		return v, nil
+	case 'x', 't', 'd':
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		v := d.order.Uint64(b)
+		switch t[0] {
+		case 'x':
+			return int64(v), nil
+		case 'd':
+			return math.Float64frombits(v), nil
+		}
+		return v, nil
+	case 's', 'o':
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.next(int(d.order.Uint32(b)) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return string(s[:len(s)-1]), nil
+	case 'g':
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.next(int(b[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return string(s[:len(s)-1]), nil
+	case 'v':
+		sig, err := d.value("g")
+		if err != nil {
+			return nil, err
+		}
+		if t, err := firstType(sig.(string)); err != nil || t != sig {
+			return nil, fmt.Errorf("invalid variant signature %q", sig)
+		}
+		return d.value(sig.(string))
+	case 'a':
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		n := int(d.order.Uint32(b))
+		elem := t[1:]
+		if err := d.align(typeAlignment(elem[0])); err != nil {
+			return nil, err
+		}
+		end := d.off + n
+		if end > len(d.buf) {
+			return nil, errDBusShort
+		}
+		items := []any{}
+		for d.off < end {
+			v, err := d.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case '(', '{':
+		return d.values(t[1 : len(t)-1])
+	}
+	return nil, fmt.Errorf("unsupported D-Bus type %q", t)
+}
+
+// firstType returns the first complete type of a signature.
+func firstType(sig string) (string, error) {
+	if sig == "" {
+		return "", errors.New("empty D-Bus signature")
+	}
+	switch sig[0] {
+	case 'a':
+		elem, err := firstType(sig[1:])
+		if err != nil {
+			return "", err
+		}
+		return "a" + elem, nil
+	case '(', '{':
+		closer := byte(')')
+		if sig[0] == '{' {
+			closer = '}'
+		}
+		depth := 0
+		for i := 0; i < len(sig); i++ {
+			switch sig[i] {
+			case '(', '{':
+				depth++
+			case ')', '}':
+				depth--
+				if depth == 0 {
+					if sig[i] != closer {
+						return "", fmt.Errorf("invalid D-Bus signature %q", sig)
+					}
+					return sig[:i+1], nil
+				}
+			}
+		}
+		return "", fmt.Errorf("invalid D-Bus signature %q", sig)
+	}
+	return sig[:1], nil
+}
+
+// typeAlignment returns the alignment of a type code.
+func typeAlignment(code byte) int {
+	switch code {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 4
+}
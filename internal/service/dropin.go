@@ -0,0 +1,85 @@
+//go:build !windows
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dropInHeader starts every drop-in dnstm writes, so one written by hand,
+// e.g. with "systemctl edit", is never removed.
+const dropInHeader = "# Written by dnstm from the \"unit\" settings of the tunnel in the config.\n# Edit those instead: this file is rewritten with the unit.\n"
+
+// GetDropInPath returns the drop-in file the overrides of a service are
+// written to.
+func GetDropInPath(serviceName string) string {
+	return fmt.Sprintf("/etc/systemd/system/%s.service.d/override.conf", serviceName)
+}
+
+// generateDropIn renders the drop-in for overrides.
+func generateDropIn(o *Overrides) string {
+	var b strings.Builder
+	b.WriteString(dropInHeader)
+	if len(o.After) > 0 {
+		fmt.Fprintf(&b, "\n[Unit]\nAfter=%s\n", strings.Join(o.After, " "))
+	}
+	if len(o.Environment) > 0 || len(o.ExecStartPre) > 0 {
+		b.WriteString("\n[Service]\n")
+		for _, e := range o.Environment {
+			fmt.Fprintf(&b, "Environment=%q\n", e)
+		}
+		for _, c := range o.ExecStartPre {
+			fmt.Fprintf(&b, "ExecStartPre=%s\n", c)
+		}
+	}
+	return b.String()
+}
+
+// writeDropIn writes the overrides of cfg to its drop-in, or removes the
+// drop-in dnstm wrote when there are none.
+func writeDropIn(cfg *ServiceConfig) error {
+	path := GetDropInPath(cfg.Name)
+	if cfg.Overrides.IsEmpty() {
+		return removeDropIn(path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create drop-in directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(generateDropIn(cfg.Overrides)), 0644); err != nil {
+		return fmt.Errorf("failed to write drop-in: %w", err)
+	}
+	return nil
+}
+
+// removeDropIn removes a drop-in written by dnstm, and its directory when
+// nothing else is left in it.
+func removeDropIn(path string) error {
+	if !isOwnDropIn(path) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove drop-in: %w", err)
+	}
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// isOwnDropIn reports whether the file at path is a drop-in dnstm wrote.
+func isOwnDropIn(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.HasPrefix(string(data), dropInHeader)
+}
+
+// isDropInCurrent reports whether the drop-in of a service is the one
+// writeDropIn would write for cfg.
+func isDropInCurrent(cfg *ServiceConfig) bool {
+	path := GetDropInPath(cfg.Name)
+	if cfg.Overrides.IsEmpty() {
+		return !isOwnDropIn(path)
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && string(data) == generateDropIn(cfg.Overrides)
+}
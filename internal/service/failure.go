@@ -0,0 +1,21 @@
+package service
+
+import "errors"
+
+// ErrFailed is wrapped by errors of services that could not be started,
+// stopped or reached readiness.
+var ErrFailed = errors.New("service failed")
+
+// failedError marks err as a service failure without changing its message.
+type failedError struct{ err error }
+
+func (e failedError) Error() string   { return e.err.Error() }
+func (e failedError) Unwrap() []error { return []error{e.err, ErrFailed} }
+
+// MarkFailed wraps err so it matches ErrFailed.
+func MarkFailed(err error) error {
+	if err == nil {
+		return nil
+	}
+	return failedError{err}
+}
@@ -0,0 +1,71 @@
+//go:build !windows
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// RunHost supervises the service serviceName until the init script stops it
+// with SIGTERM. SIGHUP, SIGUSR1 and SIGUSR2 are passed on to ExecStart.
+func RunHost(serviceName string) error {
+	cfg, err := loadServiceConfig(serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to load service %s: %w", serviceName, err)
+	}
+	log, err := openLog(serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer log.Close()
+
+	h := &host{cfg: cfg}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.supervise(log, stop)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	for sig := range signals {
+		if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+			break
+		}
+		h.signal(sig)
+	}
+	close(stop)
+	h.kill()
+	<-done
+	return nil
+}
+
+// setCredential makes cmd run as username and group. An empty user keeps
+// root.
+func setCredential(cmd *exec.Cmd, username, group string) error {
+	if username == "" || username == "root" {
+		return nil
+	}
+	u, err := user.Lookup(username)
+	if err != nil {
+		return fmt.Errorf("unknown service user %s: %w", username, err)
+	}
+	uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+	gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return fmt.Errorf("unknown service group %s: %w", group, err)
+		}
+		gid, _ = strconv.ParseUint(g.Gid, 10, 32)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}}
+	return nil
+}
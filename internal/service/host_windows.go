@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"os/exec"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// RunHost runs as the Windows service serviceName, supervising its
+// ExecStart command until the service manager stops it.
+func RunHost(serviceName string) error {
+	cfg, err := loadServiceConfig(serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to load service %s: %w", serviceName, err)
+	}
+	return svc.Run(serviceName, &host{cfg: cfg})
+}
+
+// Execute implements svc.Handler.
+func (h *host) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	log, err := openLog(h.cfg.Name)
+	if err != nil {
+		return true, 1
+	}
+	defer log.Close()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.supervise(log, stop)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			close(stop)
+			h.kill()
+			<-done
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// setCredential is a no-op on Windows, where services run as LocalSystem.
+func setCredential(cmd *exec.Cmd, user, group string) error {
+	return nil
+}
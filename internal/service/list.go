@@ -0,0 +1,25 @@
+package service
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ListServices returns the names of the installed services starting with
+// prefix, sorted. On systemd the services of timers are included.
+func ListServices(prefix string) []string {
+	pattern := configPath(prefix + "*")
+	if UsesSystemd() {
+		pattern = GetServicePath(prefix + "*")
+	}
+	matches, _ := filepath.Glob(pattern)
+
+	var names []string
+	for _, path := range matches {
+		base := filepath.Base(path)
+		names = append(names, strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package service
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// SetServicePermissions sets permissions for service files.
+func SetServicePermissions(user, group string, privateKeyFile, publicKeyFile, configDir string) error {
+	ownership := user + ":" + group
+
+	if privateKeyFile != "" {
+		if err := exec.Command("chown", ownership, privateKeyFile).Run(); err != nil {
+			return fmt.Errorf("failed to chown private key: %w", err)
+		}
+		if err := exec.Command("chmod", "600", privateKeyFile).Run(); err != nil {
+			return fmt.Errorf("failed to chmod private key: %w", err)
+		}
+	}
+	if publicKeyFile != "" {
+		if err := exec.Command("chown", ownership, publicKeyFile).Run(); err != nil {
+			return fmt.Errorf("failed to chown public key: %w", err)
+		}
+		if err := exec.Command("chmod", "644", publicKeyFile).Run(); err != nil {
+			return fmt.Errorf("failed to chmod public key: %w", err)
+		}
+	}
+
+	if err := exec.Command("chown", "-R", ownership, configDir).Run(); err != nil {
+		return fmt.Errorf("failed to chown config directory: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// rcDir holds the rc.d scripts of locally installed services.
+const rcDir = "/usr/local/etc/rc.d"
+
+// rcInit reports whether services are init scripts, as they always are here.
+const rcInit = true
+
+// rcStateDir holds the saved configuration of each service.
+const rcStateDir = "/var/db/dnstm/services"
+
+// crontabPath is root's crontab, which holds the timers.
+const crontabPath = "/var/cron/tabs/root"
+
+// rcCommand returns the command running an action on an rc.d service. The
+// "one" prefixes work whether or not the service is enabled.
+func rcCommand(action, name string) []string {
+	switch action {
+	case "start", "stop", "restart", "status":
+		return []string{"service", name, "one" + action}
+	}
+	return []string{"service", name, action}
+}
+
+// generateRCScript renders the rc.d script of a service. daemon(8) puts
+// the host in the background and records its pid.
+func generateRCScript(cfg *ServiceConfig) string {
+	name := rcName(cfg.Name)
+	require := []string{"NETWORKING"}
+	// REQUIRE only orders the scripts, so Wants maps to it as well
+	for _, r := range slices.Concat(cfg.Requires, cfg.Wants) {
+		require = append(require, rcName(strings.TrimSuffix(r, ".service")))
+	}
+	return fmt.Sprintf(`#!/bin/sh
+#
+# PROVIDE: %[1]s
+# REQUIRE: %[2]s
+# KEYWORD: shutdown
+#
+# Written by dnstm; changes are overwritten.
+
+. /etc/rc.subr
+
+name="%[1]s"
+rcvar="%[1]s_enable"
+desc="%[3]s"
+pidfile="/var/run/${name}.pid"
+command="/usr/sbin/daemon"
+command_args="-f -P ${pidfile} %[4]s %[5]s %[6]s"
+
+load_rc_config $name
+: ${%[1]s_enable:="NO"}
+
+run_rc_command "$1"
+`, name, strings.Join(require, " "), cfg.Description, hostBinary, HostCommand, cfg.Name)
+}
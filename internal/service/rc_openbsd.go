@@ -0,0 +1,50 @@
+package service
+
+import "fmt"
+
+// rcDir holds the rc.d scripts of all services.
+const rcDir = "/etc/rc.d"
+
+// rcInit reports whether services are init scripts, as they always are here.
+const rcInit = true
+
+// rcStateDir holds the saved configuration of each service.
+const rcStateDir = "/var/db/dnstm/services"
+
+// crontabPath is root's crontab, which holds the timers.
+const crontabPath = "/var/cron/tabs/root"
+
+// rcCommand returns the command running an action on an rc.d service.
+// Starting is forced so disabled services can be started too.
+func rcCommand(action, name string) []string {
+	switch action {
+	case "start", "restart":
+		return []string{"rcctl", "-f", action, name}
+	case "status":
+		return []string{"rcctl", "check", name}
+	case "enabled":
+		return []string{"rcctl", "get", name, "status"}
+	}
+	return []string{"rcctl", action, name}
+}
+
+// generateRCScript renders the rc.d script of a service. rc_bg puts the
+// host in the background. OpenBSD has no start ordering between scripts,
+// so Requires and Wants are not expressed.
+func generateRCScript(cfg *ServiceConfig) string {
+	return fmt.Sprintf(`#!/bin/ksh
+#
+# %s
+# Written by dnstm; changes are overwritten.
+
+daemon="%s"
+daemon_flags="%s %s"
+
+. /etc/rc.d/rc.subr
+
+rc_bg=YES
+rc_reload=NO
+
+rc_cmd $1
+`, cfg.Description, hostBinary, HostCommand, cfg.Name)
+}
@@ -0,0 +1,79 @@
+//go:build !windows && !freebsd && !openbsd
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// rcDir holds the OpenRC init scripts.
+const rcDir = "/etc/init.d"
+
+// rcStateDir holds the saved configuration of each service.
+const rcStateDir = "/var/lib/dnstm/services"
+
+// crontabPath is root's crontab on BusyBox crond, which holds the timers.
+const crontabPath = "/etc/crontabs/root"
+
+// rcInit reports whether services are OpenRC init scripts rather than
+// systemd units. Alpine and other systems without systemd use OpenRC.
+var rcInit = detectOpenRC()
+
+// detectOpenRC reports whether OpenRC manages services: openrc-run is
+// installed and systemctl is not.
+func detectOpenRC() bool {
+	if _, err := os.Stat("/sbin/openrc-run"); err != nil {
+		return false
+	}
+	_, err := exec.LookPath("systemctl")
+	return err != nil
+}
+
+// rcCommand returns the command running an action on an OpenRC service.
+// Services are enabled in the default runlevel.
+func rcCommand(action, name string) []string {
+	switch action {
+	case "enable":
+		return []string{"rc-update", "add", name, "default"}
+	case "disable":
+		return []string{"rc-update", "del", name, "default"}
+	case "enabled":
+		return []string{"test", "-e", "/etc/runlevels/default/" + name}
+	}
+	return []string{"rc-service", name, action}
+}
+
+// generateRCScript renders the OpenRC script of a service. openrc-run puts
+// the host in the background and records its pid.
+func generateRCScript(cfg *ServiceConfig) string {
+	need := []string{"net"}
+	for _, r := range cfg.Requires {
+		need = append(need, rcName(strings.TrimSuffix(r, ".service")))
+	}
+	var want string
+	if len(cfg.Wants) > 0 {
+		var names []string
+		for _, w := range cfg.Wants {
+			names = append(names, rcName(strings.TrimSuffix(w, ".service")))
+		}
+		want = "\n\twant " + strings.Join(names, " ")
+	}
+	return fmt.Sprintf(`#!/sbin/openrc-run
+#
+# Written by dnstm; changes are overwritten.
+
+description="%s"
+command="%s"
+command_args="%s %s"
+command_background=true
+pidfile="/run/${RC_SVCNAME}.pid"
+
+depend() {
+	need %s%s
+	after firewall
+}
+`, cfg.Description, hostBinary, HostCommand, cfg.Name, strings.Join(need, " "), want)
+}
@@ -0,0 +1,49 @@
+//go:build !windows && !freebsd && !openbsd
+
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRCScript_OpenRC(t *testing.T) {
+	script := generateRCScript(&ServiceConfig{
+		Name:        "dnstm-main",
+		Description: "dnstm tunnel",
+		Requires:    []string{"dnstm-dnsrouter.service"},
+		Wants:       []string{"microsocks.service"},
+	})
+
+	for _, want := range []string{
+		"#!/sbin/openrc-run\n",
+		`description="dnstm tunnel"`,
+		`command="/usr/local/bin/dnstm"`,
+		`command_args="service-host dnstm-main"`,
+		"command_background=true\n",
+		"\tneed net dnstm_dnsrouter\n\twant microsocks\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestRCCommand_OpenRC(t *testing.T) {
+	tests := []struct {
+		action string
+		want   []string
+	}{
+		{"start", []string{"rc-service", "dnstm_main", "start"}},
+		{"status", []string{"rc-service", "dnstm_main", "status"}},
+		{"enable", []string{"rc-update", "add", "dnstm_main", "default"}},
+		{"disable", []string{"rc-update", "del", "dnstm_main", "default"}},
+		{"enabled", []string{"test", "-e", "/etc/runlevels/default/dnstm_main"}},
+	}
+	for _, tt := range tests {
+		if got := rcCommand(tt.action, "dnstm_main"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rcCommand(%q) = %v, want %v", tt.action, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// readyPollInterval is how often WaitActive asks systemd for the state.
+var readyPollInterval = 100 * time.Millisecond
+
+// WaitActive waits until a service reports active. It returns early with an
+// error when the service fails, and when it is still not active after
+// timeout.
+func WaitActive(serviceName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		state := "unknown"
+		if states, _ := queryActiveStates([]string{serviceName}); len(states) == 1 {
+			state = states[0]
+		}
+		switch state {
+		case "active":
+			return nil
+		case "failed":
+			return MarkFailed(fmt.Errorf("%s failed to start", serviceName))
+		}
+		if !time.Now().Before(deadline) {
+			return MarkFailed(fmt.Errorf("%s is still %s after %s", serviceName, state, timeout))
+		}
+		time.Sleep(readyPollInterval)
+	}
+}
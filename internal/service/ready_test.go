@@ -0,0 +1,44 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitActive(t *testing.T) {
+	origQuery, origInterval := queryActiveStates, readyPollInterval
+	defer func() { queryActiveStates, readyPollInterval = origQuery, origInterval }()
+	readyPollInterval = time.Millisecond
+
+	tests := []struct {
+		name    string
+		states  []string
+		wantErr string
+	}{
+		{"active after activating", []string{"activating", "activating", "active"}, ""},
+		{"fails early", []string{"activating", "failed", "active"}, "failed to start"},
+		{"times out", []string{"activating"}, "still activating"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			queryActiveStates = func(names []string) ([]string, error) {
+				state := tt.states[min(calls, len(tt.states)-1)]
+				calls++
+				return []string{state}, nil
+			}
+
+			err := WaitActive("dnstm-a", 50*time.Millisecond)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("WaitActive() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("WaitActive() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
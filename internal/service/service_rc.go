@@ -0,0 +1,186 @@
+//go:build !windows
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// On FreeBSD, OpenBSD and OpenRC systems such as Alpine each dnstm service
+// is an init script that starts "dnstm service-host <name>" in the
+// background. The host reads the service configuration saved in serviceDir
+// and runs ExecStart as the service user, restarting it when it exits, as
+// Restart=always does under systemd. The exported functions in systemd.go
+// call the rc functions here when rcInit is set.
+
+// hostBinary is the dnstm binary the init scripts run.
+var hostBinary = paths.Bin("dnstm")
+
+func init() {
+	if rcInit {
+		queryActiveStates = queryRCStates
+	}
+}
+
+// serviceDir holds the saved configuration of each service.
+func serviceDir() string {
+	return rcStateDir
+}
+
+// getLogPath returns the file a service's output is written to.
+func getLogPath(serviceName string) string {
+	return filepath.Join("/var/log/dnstm", serviceName+".log")
+}
+
+// rcName returns the rc.d name of a service. rc.d names are shell variable
+// names, so dashes become underscores.
+func rcName(serviceName string) string {
+	return strings.ReplaceAll(serviceName, "-", "_")
+}
+
+// rcServicePath returns the init script path for a service name.
+func rcServicePath(serviceName string) string {
+	return filepath.Join(rcDir, rcName(serviceName))
+}
+
+// runRC runs an init script action on a service and returns a formatted
+// error on failure.
+func runRC(action, serviceName string) error {
+	defer forgetActive(serviceName)
+	argv := rcCommand(action, rcName(serviceName))
+	if output, err := exec.Command(argv[0], argv[1:]...).CombinedOutput(); err != nil {
+		return MarkFailed(fmt.Errorf("failed to %s service: %s: %w", action, strings.TrimSpace(string(output)), err))
+	}
+	return nil
+}
+
+// rcCreateService saves the configuration and writes the init script.
+func rcCreateService(cfg *ServiceConfig) error {
+	if err := saveServiceConfig(cfg); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(getLogPath(cfg.Name)), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := os.WriteFile(rcServicePath(cfg.Name), []byte(generateRCScript(cfg)), 0755); err != nil {
+		return fmt.Errorf("failed to write service file: %w", err)
+	}
+	return nil
+}
+
+// rcIsUnitCurrent reports whether the installed script and saved configuration
+// of a service are the ones rcCreateService would write for cfg.
+func rcIsUnitCurrent(cfg *ServiceConfig) bool {
+	data, err := os.ReadFile(rcServicePath(cfg.Name))
+	return err == nil && string(data) == generateRCScript(cfg) && isConfigCurrent(cfg)
+}
+
+// rcEnableService makes a service start at boot.
+func rcEnableService(serviceName string) error {
+	return runRC("enable", serviceName)
+}
+
+// rcDisableService stops a service from starting at boot.
+func rcDisableService(serviceName string) error {
+	return runRC("disable", serviceName)
+}
+
+// rcStartService starts a service, whether or not it is enabled.
+func rcStartService(serviceName string) error {
+	return runRC("start", serviceName)
+}
+
+// rcStopService stops a service.
+func rcStopService(serviceName string) error {
+	return runRC("stop", serviceName)
+}
+
+// rcRestartService restarts a service.
+func rcRestartService(serviceName string) error {
+	return runRC("restart", serviceName)
+}
+
+// rcSignalService sends a signal, such as "HUP", to the host of a service,
+// which passes it on to ExecStart.
+func rcSignalService(serviceName, signal string) error {
+	cmd := exec.Command("pkill", "-"+signal, "-f", HostCommand+" "+serviceName+"$")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to signal service: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// rcIsServiceActive checks if a service is running, using the state fetched by
+// PrefetchActive when it is recent.
+func rcIsServiceActive(serviceName string) bool {
+	if active, ok := cachedActive(serviceName); ok {
+		return active
+	}
+	argv := rcCommand("status", rcName(serviceName))
+	return exec.Command(argv[0], argv[1:]...).Run() == nil
+}
+
+// queryRCStates returns "active" or "inactive" for each service, in the
+// form `systemctl is-active` prints. Init scripts have no batch query.
+func queryRCStates(names []string) ([]string, error) {
+	states := make([]string, len(names))
+	for i, name := range names {
+		argv := rcCommand("status", rcName(name))
+		states[i] = "inactive"
+		if exec.Command(argv[0], argv[1:]...).Run() == nil {
+			states[i] = "active"
+		}
+	}
+	return states, nil
+}
+
+// rcIsServiceEnabled checks if a service starts at boot.
+func rcIsServiceEnabled(serviceName string) bool {
+	argv := rcCommand("enabled", rcName(serviceName))
+	return exec.Command(argv[0], argv[1:]...).Run() == nil
+}
+
+// rcIsServiceInstalled checks if a service's init script exists.
+func rcIsServiceInstalled(serviceName string) bool {
+	_, err := os.Stat(rcServicePath(serviceName))
+	return err == nil
+}
+
+// rcServiceStatus returns a short status report for a service.
+func rcServiceStatus(serviceName string) (string, error) {
+	state := "stopped"
+	if rcIsServiceActive(serviceName) {
+		state = "running"
+	}
+	boot := "disabled"
+	if rcIsServiceEnabled(serviceName) {
+		boot = "enabled"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", serviceName, rcServicePath(serviceName))
+	fmt.Fprintf(&b, "  State: %s\n", state)
+	fmt.Fprintf(&b, "  Boot:  %s\n", boot)
+	fmt.Fprintf(&b, "  Log:   %s\n", getLogPath(serviceName))
+	return b.String(), nil
+}
+
+// rcRemoveService stops, disables and deletes a service's init script and
+// saved configuration. The log is kept.
+func rcRemoveService(serviceName string) error {
+	if rcIsServiceInstalled(serviceName) {
+		rcStopService(serviceName)
+		rcDisableService(serviceName)
+	}
+	for _, path := range []string{rcServicePath(serviceName), configPath(serviceName)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove service file: %w", err)
+		}
+	}
+	return nil
+}
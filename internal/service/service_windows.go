@@ -0,0 +1,350 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// On Windows each dnstm service is a Windows service whose executable is
+// dnstm itself ("dnstm service-host <name>"). The host reads the service
+// configuration saved next to its log and runs ExecStart as a child
+// process, restarting it when it exits, as Restart=always does under systemd.
+
+// UsesSystemd reports whether services are systemd units.
+func UsesSystemd() bool {
+	return false
+}
+
+// stopTimeout bounds how long StopService waits for a service to stop.
+const stopTimeout = 30 * time.Second
+
+func init() {
+	queryActiveStates = queryServiceStates
+}
+
+// serviceDir holds the saved configuration and log of each service.
+func serviceDir() string {
+	base := os.Getenv("ProgramData")
+	if base == "" {
+		base = `C:\ProgramData`
+	}
+	return filepath.Join(base, "dnstm", "services")
+}
+
+// GetServicePath returns the saved configuration file of a service.
+func GetServicePath(serviceName string) string {
+	return configPath(serviceName)
+}
+
+// getLogPath returns the file a service's output is written to.
+func getLogPath(serviceName string) string {
+	return filepath.Join(serviceDir(), serviceName+".log")
+}
+
+// openService connects to the service manager and opens a service.
+// The caller closes both.
+func openService(serviceName string) (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		m.Disconnect()
+		return nil, nil, fmt.Errorf("failed to open service %s: %w", serviceName, err)
+	}
+	return m, s, nil
+}
+
+// withService runs fn on an opened service.
+func withService(serviceName string, fn func(s *mgr.Service) error) error {
+	defer forgetActive(serviceName)
+	m, s, err := openService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+	return fn(s)
+}
+
+// CreateGenericService saves the configuration and registers the Windows
+// service, updating it if it already exists. New services start manually
+// until enabled.
+func CreateGenericService(cfg *ServiceConfig) error {
+	if err := saveServiceConfig(cfg); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate dnstm: %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(cfg.Name); err == nil {
+		defer s.Close()
+		c, err := s.Config()
+		if err != nil {
+			return fmt.Errorf("failed to read service %s: %w", cfg.Name, err)
+		}
+		c.DisplayName = cfg.Description
+		c.BinaryPathName = windows.EscapeArg(exe) + " " + HostCommand + " " + windows.EscapeArg(cfg.Name)
+		c.Dependencies = cfg.Requires
+		if err := s.UpdateConfig(c); err != nil {
+			return fmt.Errorf("failed to update service %s: %w", cfg.Name, err)
+		}
+		return nil
+	}
+
+	// The service manager has no weak dependencies, so Wants is not expressed
+	s, err := m.CreateService(cfg.Name, exe, mgr.Config{
+		DisplayName:  cfg.Description,
+		StartType:    mgr.StartManual,
+		Dependencies: cfg.Requires,
+	}, HostCommand, cfg.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create service %s: %w", cfg.Name, err)
+	}
+	return s.Close()
+}
+
+// IsUnitCurrent reports whether the saved configuration of a service
+// matches cfg.
+func IsUnitCurrent(cfg *ServiceConfig) bool {
+	return isConfigCurrent(cfg)
+}
+
+// setStartType switches a service between automatic and manual start.
+func setStartType(serviceName string, startType uint32) error {
+	return withService(serviceName, func(s *mgr.Service) error {
+		c, err := s.Config()
+		if err != nil {
+			return err
+		}
+		c.StartType = startType
+		return s.UpdateConfig(c)
+	})
+}
+
+// EnableService makes a service start at boot.
+func EnableService(serviceName string) error {
+	return setStartType(serviceName, mgr.StartAutomatic)
+}
+
+// DisableService stops a service from starting at boot.
+func DisableService(serviceName string) error {
+	return setStartType(serviceName, mgr.StartManual)
+}
+
+// StartService starts a service.
+func StartService(serviceName string) error {
+	return withService(serviceName, func(s *mgr.Service) error {
+		if status, err := s.Query(); err == nil && status.State == svc.Running {
+			return nil
+		}
+		if err := s.Start(); err != nil {
+			return MarkFailed(fmt.Errorf("failed to start service: %w", err))
+		}
+		return nil
+	})
+}
+
+// StopService stops a service and waits for it to exit.
+func StopService(serviceName string) error {
+	return withService(serviceName, func(s *mgr.Service) error {
+		status, err := s.Query()
+		if err != nil {
+			return fmt.Errorf("failed to query service: %w", err)
+		}
+		if status.State == svc.Stopped {
+			return nil
+		}
+		if status, err = s.Control(svc.Stop); err != nil {
+			return MarkFailed(fmt.Errorf("failed to stop service: %w", err))
+		}
+		deadline := time.Now().Add(stopTimeout)
+		for status.State != svc.Stopped {
+			if time.Now().After(deadline) {
+				return MarkFailed(fmt.Errorf("failed to stop service: still running after %s", stopTimeout))
+			}
+			time.Sleep(300 * time.Millisecond)
+			if status, err = s.Query(); err != nil {
+				return fmt.Errorf("failed to query service: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// RestartService stops and starts a service.
+func RestartService(serviceName string) error {
+	if err := StopService(serviceName); err != nil {
+		return err
+	}
+	return StartService(serviceName)
+}
+
+// ResetFailed is a no-op on Windows, which has no failed state to clear.
+func ResetFailed(serviceName string) error {
+	return nil
+}
+
+// SignalService is not supported on Windows.
+func SignalService(serviceName, signal string) error {
+	return fmt.Errorf("cannot send %s to %s: signals are not supported on Windows", signal, serviceName)
+}
+
+// IsServiceActive checks if a service is running, using the state fetched by
+// PrefetchActive when it is recent.
+func IsServiceActive(serviceName string) bool {
+	if active, ok := cachedActive(serviceName); ok {
+		return active
+	}
+	states, err := queryServiceStates([]string{serviceName})
+	return err == nil && states[0] == "active"
+}
+
+// queryServiceStates returns "active" or "inactive" for each service, in the
+// form `systemctl is-active` prints.
+func queryServiceStates(names []string) ([]string, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Disconnect()
+
+	states := make([]string, len(names))
+	for i, name := range names {
+		states[i] = "inactive"
+		s, err := m.OpenService(name)
+		if err != nil {
+			continue
+		}
+		if status, err := s.Query(); err == nil && status.State == svc.Running {
+			states[i] = "active"
+		}
+		s.Close()
+	}
+	return states, nil
+}
+
+// IsServiceEnabled checks if a service starts at boot.
+func IsServiceEnabled(serviceName string) bool {
+	enabled := false
+	withService(serviceName, func(s *mgr.Service) error {
+		c, err := s.Config()
+		enabled = err == nil && c.StartType == mgr.StartAutomatic
+		return err
+	})
+	return enabled
+}
+
+// IsServiceInstalled checks if a service is registered.
+func IsServiceInstalled(serviceName string) bool {
+	m, s, err := openService(serviceName)
+	if err != nil {
+		return false
+	}
+	s.Close()
+	m.Disconnect()
+	return true
+}
+
+// GetServiceLogs returns the last lines of a service's log file.
+func GetServiceLogs(serviceName string, lines int) (string, error) {
+	return tailServiceLog(serviceName, lines)
+}
+
+// GetServiceLogsSince returns the log lines a service wrote after since.
+// Windows service logs carry no timestamps to read from.
+func GetServiceLogsSince(serviceName string, since time.Time) ([]string, error) {
+	return nil, fmt.Errorf("reading logs by time needs the systemd journal")
+}
+
+// GetStats returns the resource use of a service. Windows services have
+// no cgroup to read it from.
+func GetStats(serviceName string) (*Stats, error) {
+	return nil, errNoStats
+}
+
+// GetServiceStatus returns a short status report for a service.
+func GetServiceStatus(serviceName string) (string, error) {
+	var b strings.Builder
+	err := withService(serviceName, func(s *mgr.Service) error {
+		c, err := s.Config()
+		if err != nil {
+			return err
+		}
+		status, err := s.Query()
+		if err != nil {
+			return err
+		}
+		startType := "manual"
+		if c.StartType == mgr.StartAutomatic {
+			startType = "automatic"
+		}
+		fmt.Fprintf(&b, "%s - %s\n", serviceName, c.DisplayName)
+		fmt.Fprintf(&b, "  State:      %s\n", stateName(status.State))
+		fmt.Fprintf(&b, "  Start type: %s\n", startType)
+		if status.ProcessId != 0 {
+			fmt.Fprintf(&b, "  Host PID:   %d\n", status.ProcessId)
+		}
+		fmt.Fprintf(&b, "  Log:        %s\n", getLogPath(serviceName))
+		return nil
+	})
+	return b.String(), err
+}
+
+// stateName describes a service state.
+func stateName(state svc.State) string {
+	switch state {
+	case svc.Stopped:
+		return "stopped"
+	case svc.StartPending:
+		return "starting"
+	case svc.StopPending:
+		return "stopping"
+	case svc.Running:
+		return "running"
+	}
+	return fmt.Sprintf("state %d", state)
+}
+
+// RemoveService stops and deletes a service and its saved configuration.
+// The log is kept.
+func RemoveService(serviceName string) error {
+	if IsServiceInstalled(serviceName) {
+		StopService(serviceName)
+		if err := withService(serviceName, func(s *mgr.Service) error { return s.Delete() }); err != nil {
+			return fmt.Errorf("failed to remove service: %w", err)
+		}
+	}
+	if err := os.Remove(GetServicePath(serviceName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove service file: %w", err)
+	}
+	return nil
+}
+
+// SetServicePermissions is a no-op on Windows: services run as LocalSystem
+// and files keep the ACLs inherited from their directory.
+func SetServicePermissions(user, group string, privateKeyFile, publicKeyFile, configDir string) error {
+	return nil
+}
+
+// DaemonReload is a no-op on Windows; service changes apply immediately.
+func DaemonReload() error {
+	return nil
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PeaksDir holds the highest resource use seen per service, kept across
+// restarts so a slowly leaking process shows up.
+var PeaksDir = "/var/lib/dnstm/peaks"
+
+// errNoStats is returned where services have no cgroup accounting.
+var errNoStats = errors.New("resource usage is only known for systemd units")
+
+// Stats is the live resource use of a running service, read from its
+// cgroup.
+type Stats struct {
+	Memory     uint64    // bytes in use
+	MemoryPeak uint64    // highest since the service started, 0 if unknown
+	CPUPercent float64   // of one core, over a short sample
+	Tasks      uint64    // processes and threads
+	Since      time.Time // when the service became active
+}
+
+// Uptime returns how long the service has been running at now.
+func (s *Stats) Uptime(now time.Time) time.Duration {
+	if s.Since.IsZero() {
+		return 0
+	}
+	return now.Sub(s.Since).Truncate(time.Second)
+}
+
+// Peaks is the highest resource use recorded for a service.
+type Peaks struct {
+	Memory     uint64    `json:"memory"`
+	CPUPercent float64   `json:"cpu_percent"`
+	Tasks      uint64    `json:"tasks"`
+	Since      time.Time `json:"since"` // first recording
+}
+
+// Update raises the peaks to s and reports whether any changed.
+func (p *Peaks) Update(s *Stats, now time.Time) bool {
+	changed := false
+	if p.Since.IsZero() {
+		p.Since = now
+		changed = true
+	}
+	if mem := max(s.Memory, s.MemoryPeak); mem > p.Memory {
+		p.Memory = mem
+		changed = true
+	}
+	if s.CPUPercent > p.CPUPercent {
+		p.CPUPercent = s.CPUPercent
+		changed = true
+	}
+	if s.Tasks > p.Tasks {
+		p.Tasks = s.Tasks
+		changed = true
+	}
+	return changed
+}
+
+func peaksPath(serviceName string) string {
+	return filepath.Join(PeaksDir, serviceName+".json")
+}
+
+// LoadPeaks returns the peaks recorded for a service, empty when none are.
+func LoadPeaks(serviceName string) *Peaks {
+	var p Peaks
+	data, err := os.ReadFile(peaksPath(serviceName))
+	if err == nil {
+		json.Unmarshal(data, &p)
+	}
+	return &p
+}
+
+// RecordPeaks merges s into the recorded peaks of a service and returns
+// them. Saving is best effort: without write access the peaks are still
+// returned.
+func RecordPeaks(serviceName string, s *Stats) *Peaks {
+	p := LoadPeaks(serviceName)
+	if !p.Update(s, time.Now()) {
+		return p
+	}
+	if err := os.MkdirAll(PeaksDir, 0755); err != nil {
+		return p
+	}
+	if data, err := json.MarshalIndent(p, "", "  "); err == nil {
+		os.WriteFile(peaksPath(serviceName), data, 0644)
+	}
+	return p
+}
+
+// ForgetPeaks deletes the recorded peaks of a removed service.
+func ForgetPeaks(serviceName string) {
+	os.Remove(peaksPath(serviceName))
+}
+
+// parseProperties parses "systemctl show" output of Key=Value lines.
+func parseProperties(output string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// parseCounter parses a systemd resource counter, which is "[not set]" or
+// the maximum uint64 when the unit is stopped or accounting is off.
+func parseCounter(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || n == math.MaxUint64 {
+		return 0, fmt.Errorf("counter not available")
+	}
+	return n, nil
+}
+
+// parseStats builds Stats from two samples of a unit's properties taken
+// elapsed apart. uptime is the system's monotonic clock at now, which
+// ActiveEnterTimestampMonotonic is relative to.
+func parseStats(first, second map[string]string, elapsed, uptime time.Duration, now time.Time) (*Stats, error) {
+	mem, err := parseCounter(second["MemoryCurrent"])
+	if err != nil {
+		return nil, fmt.Errorf("memory accounting is not available")
+	}
+	s := &Stats{Memory: mem}
+	s.MemoryPeak, _ = parseCounter(second["MemoryPeak"])
+	s.Tasks, _ = parseCounter(second["TasksCurrent"])
+
+	cpu1, err1 := parseCounter(first["CPUUsageNSec"])
+	cpu2, err2 := parseCounter(second["CPUUsageNSec"])
+	if err1 == nil && err2 == nil && cpu2 >= cpu1 && elapsed > 0 {
+		s.CPUPercent = float64(cpu2-cpu1) / float64(elapsed.Nanoseconds()) * 100
+	}
+
+	if usec, err := strconv.ParseUint(second["ActiveEnterTimestampMonotonic"], 10, 64); err == nil && usec > 0 {
+		if active := time.Duration(usec) * time.Microsecond; active <= uptime {
+			s.Since = now.Add(active - uptime)
+		}
+	}
+	return s, nil
+}
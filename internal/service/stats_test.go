@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCounter(t *testing.T) {
+	if n, err := parseCounter("12582912\n"); err != nil || n != 12582912 {
+		t.Errorf("got %d, %v", n, err)
+	}
+	for _, value := range []string{"[not set]", "18446744073709551615", ""} {
+		if _, err := parseCounter(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	first := parseProperties("CPUUsageNSec=1000000000\n")
+	second := parseProperties(`MemoryCurrent=20971520
+MemoryPeak=31457280
+CPUUsageNSec=1050000000
+TasksCurrent=7
+ActiveEnterTimestampMonotonic=3600000000
+`)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s, err := parseStats(first, second, 250*time.Millisecond, 2*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Memory != 20<<20 || s.MemoryPeak != 30<<20 || s.Tasks != 7 {
+		t.Errorf("got %+v", s)
+	}
+	if s.CPUPercent < 19.9 || s.CPUPercent > 20.1 {
+		t.Errorf("cpu: got %.2f%%, want 20%%", s.CPUPercent)
+	}
+	if up := s.Uptime(now); up != time.Hour {
+		t.Errorf("uptime: got %s, want 1h", up)
+	}
+
+	if _, err := parseStats(first, parseProperties("MemoryCurrent=[not set]\n"), time.Second, time.Hour, now); err == nil {
+		t.Error("expected an error without memory accounting")
+	}
+}
+
+func TestPeaks(t *testing.T) {
+	PeaksDir = t.TempDir()
+	defer func() { PeaksDir = "/var/lib/dnstm/peaks" }()
+
+	p := RecordPeaks("dnstm-a", &Stats{Memory: 10 << 20, MemoryPeak: 12 << 20, CPUPercent: 5, Tasks: 4})
+	if p.Memory != 12<<20 || p.CPUPercent != 5 || p.Tasks != 4 || p.Since.IsZero() {
+		t.Fatalf("got %+v", p)
+	}
+	since := p.Since
+
+	// Peaks survive a restart with lower use
+	p = RecordPeaks("dnstm-a", &Stats{Memory: 8 << 20, CPUPercent: 9, Tasks: 2})
+	if p.Memory != 12<<20 || p.CPUPercent != 9 || p.Tasks != 4 || !p.Since.Equal(since) {
+		t.Errorf("got %+v", p)
+	}
+
+	ForgetPeaks("dnstm-a")
+	if p := LoadPeaks("dnstm-a"); p.Memory != 0 {
+		t.Errorf("peaks not forgotten: %+v", p)
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+)
+
+// Without systemd, services run "dnstm service-host <name>",
+// which supervises the service's commands the way systemd would: pre-start
+// commands first, then ExecStart, restarted whenever it exits.
+
+// HostCommand is the hidden dnstm command services run.
+const HostCommand = "service-host"
+
+// restartDelay matches RestartSec in the systemd units.
+const restartDelay = 5 * time.Second
+
+// maxLogSize is the size at which a service log is started afresh.
+const maxLogSize = 10 << 20
+
+// host supervises one service's process.
+type host struct {
+	cfg *ServiceConfig
+
+	mu      sync.Mutex
+	proc    *os.Process
+	stopped bool
+}
+
+// supervise runs the service's commands, starting them again after
+// restartDelay whenever ExecStart exits, until stop is closed.
+func (h *host) supervise(log io.Writer, stop <-chan struct{}) {
+	for {
+		h.runOnce(log)
+		select {
+		case <-stop:
+			return
+		case <-time.After(restartDelay):
+		}
+	}
+}
+
+// runOnce runs the pre-start commands and then ExecStart until it exits.
+func (h *host) runOnce(log io.Writer) {
+	for _, pre := range h.cfg.ExecStartPre {
+		// "+" runs as root instead of the service user; "-" ignores failure
+		privileged := strings.HasPrefix(pre, "+")
+		pre = strings.TrimLeft(pre, "+")
+		optional := strings.HasPrefix(pre, "-")
+		if err := h.run(strings.TrimPrefix(pre, "-"), !privileged, log); err != nil && !optional {
+			fmt.Fprintf(log, "dnstm: pre-start command failed: %v\n", err)
+			return
+		}
+	}
+	if err := h.run(h.cfg.ExecStart, true, log); err != nil {
+		fmt.Fprintf(log, "dnstm: %s exited: %v\n", h.cfg.Name, err)
+	}
+}
+
+// run starts a command line with output going to log and waits for it.
+// asUser runs it as the service's user.
+func (h *host) run(command string, asUser bool, log io.Writer) error {
+	argv := cmdline.Split(command)
+	if len(argv) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), h.cfg.Environment...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if asUser {
+		if err := setCredential(cmd, h.cfg.User, h.cfg.Group); err != nil {
+			return err
+		}
+	}
+	// Starting under the lock keeps kill from missing a process
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		return fmt.Errorf("service is stopping")
+	}
+	if err := cmd.Start(); err != nil {
+		h.mu.Unlock()
+		return err
+	}
+	h.proc = cmd.Process
+	h.mu.Unlock()
+
+	err := cmd.Wait()
+
+	h.mu.Lock()
+	h.proc = nil
+	h.mu.Unlock()
+	return err
+}
+
+// kill terminates the running command, if any, and keeps new ones from
+// starting.
+func (h *host) kill() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	if h.proc != nil {
+		h.proc.Kill()
+	}
+}
+
+// signal sends sig to the running command, if any.
+func (h *host) signal(sig os.Signal) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.proc != nil {
+		h.proc.Signal(sig)
+	}
+}
+
+// openLog opens a service's log for appending, starting a new one when it
+// has grown past maxLogSize.
+func openLog(serviceName string) (*os.File, error) {
+	path := getLogPath(serviceName)
+	if info, err := os.Stat(path); err == nil && info.Size() > maxLogSize {
+		os.Rename(path, path+".1")
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+// configPath returns the file a service's configuration is saved in for the
+// host to read.
+func configPath(serviceName string) string {
+	return filepath.Join(serviceDir(), serviceName+".json")
+}
+
+// savedConfig returns cfg as it is saved for the host, with the path
+// overrides dnstm runs with added to its environment and its overrides
+// merged in.
+func savedConfig(cfg *ServiceConfig) *ServiceConfig {
+	saved := *cfg
+	env := cfg.Environment
+	if o := cfg.Overrides; o != nil {
+		env = append(append([]string{}, env...), o.Environment...)
+		saved.ExecStartPre = append(append([]string{}, cfg.ExecStartPre...), o.ExecStartPre...)
+		saved.After = append(append([]string{}, cfg.After...), o.After...)
+		saved.Overrides = nil
+	}
+	saved.Environment = environment(env)
+	return &saved
+}
+
+// saveServiceConfig writes the configuration the host reads.
+func saveServiceConfig(cfg *ServiceConfig) error {
+	if err := os.MkdirAll(serviceDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create service directory: %w", err)
+	}
+	data, err := json.MarshalIndent(savedConfig(cfg), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(configPath(cfg.Name), data, 0644); err != nil {
+		return fmt.Errorf("failed to write service file: %w", err)
+	}
+	return nil
+}
+
+// isConfigCurrent reports whether the saved configuration matches cfg.
+func isConfigCurrent(cfg *ServiceConfig) bool {
+	saved, err := loadServiceConfig(cfg.Name)
+	if err != nil {
+		return false
+	}
+	a, _ := json.Marshal(saved)
+	b, _ := json.Marshal(savedConfig(cfg))
+	return string(a) == string(b)
+}
+
+// loadServiceConfig reads the configuration saved by CreateGenericService.
+func loadServiceConfig(serviceName string) (*ServiceConfig, error) {
+	data, err := os.ReadFile(configPath(serviceName))
+	if err != nil {
+		return nil, err
+	}
+	var cfg ServiceConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid service file: %w", err)
+	}
+	return &cfg, nil
+}
+
+// tailServiceLog returns the last lines of a service's log file.
+func tailServiceLog(serviceName string, lines int) (string, error) {
+	data, err := os.ReadFile(getLogPath(serviceName))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get logs: %w", err)
+	}
+	all := strings.Split(strings.TrimRight(string(data), "\r\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n") + "\n", nil
+}
@@ -1,3 +1,5 @@
+//go:build !windows
+
 package service
 
 import (
@@ -5,196 +7,164 @@ import (
 	"os"
 	"os/exec"
 	"strings"
-)
-
-// ServiceConfig contains configuration for a systemd service.
-type ServiceConfig struct {
-	Name             string   // Service name (e.g., "dnstt-server", "slipstream-server")
-	Description      string
-	User             string
-	Group            string
-	ExecStart        string
-	ReadOnlyPaths    []string // Paths that should be read-only
-	ReadWritePaths   []string // Paths that should be read-write
-	BindToPrivileged bool     // Whether service needs CAP_NET_BIND_SERVICE
-}
-
-// RealSystemdManager implements SystemdManager using actual systemd commands.
-type RealSystemdManager struct{}
-
-// NewRealSystemdManager creates a new RealSystemdManager.
-func NewRealSystemdManager() *RealSystemdManager {
-	return &RealSystemdManager{}
-}
-
-// CreateService implements SystemdManager.
-func (m *RealSystemdManager) CreateService(name string, cfg ServiceConfig) error {
-	cfg.Name = name
-	return CreateGenericService(&cfg)
-}
-
-// RemoveService implements SystemdManager.
-func (m *RealSystemdManager) RemoveService(name string) error {
-	return RemoveService(name)
-}
-
-// StartService implements SystemdManager.
-func (m *RealSystemdManager) StartService(name string) error {
-	return StartService(name)
-}
-
-// StopService implements SystemdManager.
-func (m *RealSystemdManager) StopService(name string) error {
-	return StopService(name)
-}
-
-// RestartService implements SystemdManager.
-func (m *RealSystemdManager) RestartService(name string) error {
-	return RestartService(name)
-}
-
-// EnableService implements SystemdManager.
-func (m *RealSystemdManager) EnableService(name string) error {
-	return EnableService(name)
-}
-
-// DisableService implements SystemdManager.
-func (m *RealSystemdManager) DisableService(name string) error {
-	return DisableService(name)
-}
-
-// IsServiceActive implements SystemdManager.
-func (m *RealSystemdManager) IsServiceActive(name string) bool {
-	return IsServiceActive(name)
-}
-
-// IsServiceEnabled implements SystemdManager.
-func (m *RealSystemdManager) IsServiceEnabled(name string) bool {
-	return IsServiceEnabled(name)
-}
+	"time"
 
-// IsServiceInstalled implements SystemdManager.
-func (m *RealSystemdManager) IsServiceInstalled(name string) bool {
-	return IsServiceInstalled(name)
-}
-
-// GetServiceStatus implements SystemdManager.
-func (m *RealSystemdManager) GetServiceStatus(name string) (string, error) {
-	return GetServiceStatus(name)
-}
-
-// GetServiceLogs implements SystemdManager.
-func (m *RealSystemdManager) GetServiceLogs(name string, lines int) (string, error) {
-	return GetServiceLogs(name, lines)
-}
+	"github.com/net2share/dnstm/internal/templates"
+	"golang.org/x/sys/unix"
+)
 
-// DaemonReload implements SystemdManager.
-func (m *RealSystemdManager) DaemonReload() error {
-	return DaemonReload()
+// UsesSystemd reports whether services are systemd units. Otherwise they
+// are init scripts (see service_rc.go).
+func UsesSystemd() bool {
+	return !rcInit
 }
 
-// Ensure RealSystemdManager implements SystemdManager.
-var _ SystemdManager = (*RealSystemdManager)(nil)
-
 // GetServicePath returns the systemd service file path for a service name.
 func GetServicePath(serviceName string) string {
+	if rcInit {
+		return rcServicePath(serviceName)
+	}
 	return fmt.Sprintf("/etc/systemd/system/%s.service", serviceName)
 }
 
 // runSystemctl executes a systemctl command and returns a formatted error on failure.
 func runSystemctl(action, serviceName string) error {
+	defer forgetActive(serviceName)
 	cmd := exec.Command("systemctl", action, serviceName)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to %s service: %s: %w", action, strings.TrimSpace(string(output)), err)
+		return MarkFailed(fmt.Errorf("failed to %s service: %s: %w", action, strings.TrimSpace(string(output)), err))
 	}
 	return nil
 }
 
 // CreateGenericService creates a systemd service with the given configuration.
 func CreateGenericService(cfg *ServiceConfig) error {
+	if rcInit {
+		return rcCreateService(cfg)
+	}
 	servicePath := GetServicePath(cfg.Name)
 
-	// Build paths directives
-	var pathsSection string
-	for _, p := range cfg.ReadOnlyPaths {
-		pathsSection += fmt.Sprintf("ReadOnlyPaths=%s\n", p)
+	unit, err := generateUnit(cfg)
+	if err != nil {
+		return err
 	}
-	for _, p := range cfg.ReadWritePaths {
-		pathsSection += fmt.Sprintf("ReadWritePaths=%s\n", p)
+	if err := os.WriteFile(servicePath, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to write service file: %w", err)
 	}
-
-	// Build capabilities section
-	var capsSection string
-	if cfg.BindToPrivileged {
-		capsSection = "AmbientCapabilities=CAP_NET_BIND_SERVICE\nCapabilityBoundingSet=CAP_NET_BIND_SERVICE\n"
+	if err := writeDropIn(cfg); err != nil {
+		return err
 	}
 
-	serviceContent := fmt.Sprintf(`[Unit]
-Description=%s
-After=network-online.target
-Wants=network-online.target
-
-[Service]
-Type=simple
-User=%s
-Group=%s
-ExecStart=%s
-Restart=always
-RestartSec=5
-StandardOutput=journal
-StandardError=journal
-
-# Security hardening
-NoNewPrivileges=yes
-ProtectSystem=strict
-ProtectHome=yes
-PrivateTmp=yes
-%s%sProtectKernelTunables=yes
-ProtectKernelModules=yes
-ProtectControlGroups=yes
-RestrictRealtime=yes
-RestrictSUIDSGID=yes
-MemoryDenyWriteExecute=yes
-LockPersonality=yes
-
-[Install]
-WantedBy=multi-user.target
-`, cfg.Description, cfg.User, cfg.Group, cfg.ExecStart, pathsSection, capsSection)
+	return DaemonReload()
+}
 
-	if err := os.WriteFile(servicePath, []byte(serviceContent), 0644); err != nil {
-		return fmt.Errorf("failed to write service file: %w", err)
+// IsUnitCurrent reports whether the installed unit file and drop-in of a
+// service are the ones CreateGenericService would write for cfg.
+func IsUnitCurrent(cfg *ServiceConfig) bool {
+	if rcInit {
+		return rcIsUnitCurrent(cfg)
 	}
+	data, err := os.ReadFile(GetServicePath(cfg.Name))
+	if err != nil {
+		return false
+	}
+	unit, err := generateUnit(cfg)
+	return err == nil && string(data) == unit && isDropInCurrent(cfg)
+}
 
-	return DaemonReload()
+// generateUnit renders the unit file content for a service configuration
+// from the unit template.
+func generateUnit(cfg *ServiceConfig) (string, error) {
+	return templates.Render(templates.Unit, &struct {
+		*ServiceConfig
+		After       string
+		Environment []string
+		ExecStart   string
+	}{
+		ServiceConfig: cfg,
+		After:         strings.Join(append([]string{"network-online.target"}, cfg.After...), " "),
+		// Including the path overrides dnstm runs with
+		Environment: environment(cfg.Environment),
+		ExecStart:   unitCommand(cfg.ExecStart),
+	})
+}
+
+// unitCommand escapes a command line for the ExecStart= of a unit. systemd
+// expands "%" specifiers and "$" variables there, while the service host
+// runs command lines as they are.
+func unitCommand(line string) string {
+	return strings.NewReplacer("%", "%%", "$", "$$").Replace(line)
 }
 
 // EnableService enables a systemd service.
 func EnableService(serviceName string) error {
+	if rcInit {
+		return rcEnableService(serviceName)
+	}
 	return runSystemctl("enable", serviceName)
 }
 
 // DisableService disables a systemd service.
 func DisableService(serviceName string) error {
+	if rcInit {
+		return rcDisableService(serviceName)
+	}
 	return runSystemctl("disable", serviceName)
 }
 
 // StartService starts a systemd service.
 func StartService(serviceName string) error {
+	if rcInit {
+		return rcStartService(serviceName)
+	}
 	return runSystemctl("start", serviceName)
 }
 
 // StopService stops a systemd service.
 func StopService(serviceName string) error {
+	if rcInit {
+		return rcStopService(serviceName)
+	}
 	return runSystemctl("stop", serviceName)
 }
 
 // RestartService restarts a systemd service.
 func RestartService(serviceName string) error {
+	if rcInit {
+		return rcRestartService(serviceName)
+	}
 	return runSystemctl("restart", serviceName)
 }
 
-// IsServiceActive checks if a service is active.
+// ResetFailed clears the failed state and start rate limit counter of a service.
+func ResetFailed(serviceName string) error {
+	if rcInit {
+		return nil
+	}
+	return runSystemctl("reset-failed", serviceName)
+}
+
+// SignalService sends a signal, such as "HUP", to the main process of a service.
+func SignalService(serviceName, signal string) error {
+	if rcInit {
+		return rcSignalService(serviceName, signal)
+	}
+	cmd := exec.Command("systemctl", "kill", "--kill-whom=main", "-s", signal, serviceName)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to signal service: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// IsServiceActive checks if a service is active, using the state fetched by
+// PrefetchActive when it is recent.
 func IsServiceActive(serviceName string) bool {
+	if rcInit {
+		return rcIsServiceActive(serviceName)
+	}
+	if active, ok := cachedActive(serviceName); ok {
+		return active
+	}
 	cmd := exec.Command("systemctl", "is-active", serviceName)
 	output, _ := cmd.Output()
 	return strings.TrimSpace(string(output)) == "active"
@@ -202,6 +172,9 @@ func IsServiceActive(serviceName string) bool {
 
 // IsServiceEnabled checks if a service is enabled.
 func IsServiceEnabled(serviceName string) bool {
+	if rcInit {
+		return rcIsServiceEnabled(serviceName)
+	}
 	cmd := exec.Command("systemctl", "is-enabled", serviceName)
 	output, _ := cmd.Output()
 	return strings.TrimSpace(string(output)) == "enabled"
@@ -215,6 +188,9 @@ func IsServiceInstalled(serviceName string) bool {
 
 // GetServiceStatus returns the systemctl status output for a service.
 func GetServiceStatus(serviceName string) (string, error) {
+	if rcInit {
+		return rcServiceStatus(serviceName)
+	}
 	cmd := exec.Command("systemctl", "status", serviceName, "--no-pager", "-l")
 	output, err := cmd.CombinedOutput()
 	return string(output), err
@@ -222,6 +198,9 @@ func GetServiceStatus(serviceName string) (string, error) {
 
 // GetServiceLogs returns recent logs for a service.
 func GetServiceLogs(serviceName string, lines int) (string, error) {
+	if rcInit {
+		return tailServiceLog(serviceName, lines)
+	}
 	cmd := exec.Command("journalctl", "-u", serviceName, "-n", fmt.Sprintf("%d", lines), "--no-pager")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -230,44 +209,85 @@ func GetServiceLogs(serviceName string, lines int) (string, error) {
 	return string(output), nil
 }
 
-// RemoveService removes a systemd service unit file and reloads daemon.
-func RemoveService(serviceName string) error {
-	servicePath := GetServicePath(serviceName)
-	if err := os.Remove(servicePath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove service file: %w", err)
+// GetServiceLogsSince returns the log lines a service wrote after since,
+// without journal metadata. Only the journal keeps timestamps to read from.
+func GetServiceLogsSince(serviceName string, since time.Time) ([]string, error) {
+	if rcInit {
+		return nil, fmt.Errorf("reading logs by time needs the systemd journal")
 	}
-	return DaemonReload()
+	cmd := exec.Command("journalctl", "-u", serviceName, "--since", fmt.Sprintf("@%d", since.Unix()), "-o", "cat", "--no-pager", "-q")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %w", err)
+	}
+	text := strings.TrimRight(string(output), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
 }
 
-// SetServicePermissions sets permissions for service files.
-func SetServicePermissions(user, group string, privateKeyFile, publicKeyFile, configDir string) error {
-	ownership := user + ":" + group
+// statsProperties are the unit properties GetStats reads.
+var statsProperties = []string{"MemoryCurrent", "MemoryPeak", "CPUUsageNSec", "TasksCurrent", "ActiveEnterTimestampMonotonic"}
 
-	if privateKeyFile != "" {
-		if err := exec.Command("chown", ownership, privateKeyFile).Run(); err != nil {
-			return fmt.Errorf("failed to chown private key: %w", err)
-		}
-		if err := exec.Command("chmod", "600", privateKeyFile).Run(); err != nil {
-			return fmt.Errorf("failed to chmod private key: %w", err)
-		}
-	}
-	if publicKeyFile != "" {
-		if err := exec.Command("chown", ownership, publicKeyFile).Run(); err != nil {
-			return fmt.Errorf("failed to chown public key: %w", err)
-		}
-		if err := exec.Command("chmod", "644", publicKeyFile).Run(); err != nil {
-			return fmt.Errorf("failed to chmod public key: %w", err)
-		}
+// cpuSample is how long GetStats measures CPU use.
+const cpuSample = 250 * time.Millisecond
+
+// GetStats returns the live resource use of a running service from its
+// cgroup. It takes a short CPU sample.
+func GetStats(serviceName string) (*Stats, error) {
+	if rcInit {
+		return nil, errNoStats
+	}
+	first, err := showProperties(serviceName)
+	if err != nil {
+		return nil, err
 	}
+	start := time.Now()
+	time.Sleep(cpuSample)
+	second, err := showProperties(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return nil, err
+	}
+	return parseStats(first, second, now.Sub(start), time.Duration(ts.Nano()), now)
+}
 
-	if err := exec.Command("chown", "-R", ownership, configDir).Run(); err != nil {
-		return fmt.Errorf("failed to chown config directory: %w", err)
+func showProperties(serviceName string) (map[string]string, error) {
+	args := []string{"show", serviceName}
+	for _, p := range statsProperties {
+		args = append(args, "-p", p)
+	}
+	output, err := exec.Command("systemctl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", serviceName, err)
 	}
+	return parseProperties(string(output)), nil
+}
 
-	return nil
+// RemoveService removes a systemd service unit file and reloads daemon.
+func RemoveService(serviceName string) error {
+	if rcInit {
+		return rcRemoveService(serviceName)
+	}
+	servicePath := GetServicePath(serviceName)
+	if err := os.Remove(servicePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove service file: %w", err)
+	}
+	if err := removeDropIn(GetDropInPath(serviceName)); err != nil {
+		return err
+	}
+	return DaemonReload()
 }
 
 // DaemonReload reloads systemd daemon.
 func DaemonReload() error {
+	if rcInit {
+		return nil
+	}
 	return exec.Command("systemctl", "daemon-reload").Run()
 }
@@ -1,6 +1,10 @@
+//go:build !windows && !freebsd && !openbsd
+
 package service
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -346,3 +350,215 @@ func TestMockSystemdManager_Concurrency(t *testing.T) {
 		t.Errorf("expected 10 services, got %d", len(services))
 	}
 }
+
+func TestGenerateUnit_ExecStartPre(t *testing.T) {
+	unit := mustGenerateUnit(t, &ServiceConfig{
+		Description:  "Test Service",
+		User:         "dnstm",
+		Group:        "dnstm",
+		ExecStart:    "/usr/bin/test",
+		ExecStartPre: []string{"+/usr/bin/prepare"},
+	})
+
+	if !strings.Contains(unit, "ExecStartPre=+/usr/bin/prepare\nExecStart=/usr/bin/test\n") {
+		t.Errorf("unit missing ExecStartPre before ExecStart:\n%s", unit)
+	}
+
+	unit = mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test"})
+	if strings.Contains(unit, "ExecStartPre=") {
+		t.Errorf("unit should not contain ExecStartPre:\n%s", unit)
+	}
+}
+
+func TestGenerateUnit_Watchdog(t *testing.T) {
+	unit := mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test", WatchdogSec: 30})
+	if !strings.Contains(unit, "Type=notify\nNotifyAccess=main\nWatchdogSec=30\n") {
+		t.Errorf("unit missing watchdog settings:\n%s", unit)
+	}
+	if strings.Contains(unit, "Type=simple") {
+		t.Errorf("watchdog unit should not be Type=simple:\n%s", unit)
+	}
+
+	unit = mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test"})
+	if !strings.Contains(unit, "Type=simple\n") || strings.Contains(unit, "WatchdogSec") {
+		t.Errorf("unit should be Type=simple without watchdog:\n%s", unit)
+	}
+}
+
+func TestGenerateUnit_FDStore(t *testing.T) {
+	unit := mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test", FDStore: 256})
+	if !strings.Contains(unit, "Type=simple\nNotifyAccess=main\nFileDescriptorStoreMax=256\n") {
+		t.Errorf("unit missing file descriptor store settings:\n%s", unit)
+	}
+
+	// The watchdog already grants the main process access
+	unit = mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test", WatchdogSec: 30, FDStore: 8})
+	if strings.Count(unit, "NotifyAccess=") != 1 || !strings.Contains(unit, "WatchdogSec=30\nFileDescriptorStoreMax=8\n") {
+		t.Errorf("unit with watchdog and file descriptor store:\n%s", unit)
+	}
+
+	unit = mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test"})
+	if strings.Contains(unit, "FileDescriptorStoreMax") || strings.Contains(unit, "NotifyAccess") {
+		t.Errorf("unit should not store file descriptors by default:\n%s", unit)
+	}
+}
+
+func TestGenerateUnit_StartLimit(t *testing.T) {
+	unit := mustGenerateUnit(t, &ServiceConfig{
+		ExecStart:     "/usr/bin/test",
+		RestartLimit:  10,
+		RestartWindow: 600,
+		OnFailure:     "dnstm-quarantine@%n.service",
+	})
+	if !strings.Contains(unit, "StartLimitIntervalSec=600\nStartLimitBurst=10\nOnFailure=dnstm-quarantine@%n.service\n\n[Service]") {
+		t.Errorf("unit missing start limit settings in [Unit]:\n%s", unit)
+	}
+
+	unit = mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test"})
+	if strings.Contains(unit, "StartLimit") || strings.Contains(unit, "OnFailure") {
+		t.Errorf("unit should not set start limits by default:\n%s", unit)
+	}
+
+	unit = mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test", RestartLimit: -1})
+	if !strings.Contains(unit, "StartLimitIntervalSec=0\n") || strings.Contains(unit, "StartLimitBurst") {
+		t.Errorf("unit should disable the start limit:\n%s", unit)
+	}
+}
+
+func TestGenerateUnit_Dependencies(t *testing.T) {
+	unit := mustGenerateUnit(t, &ServiceConfig{
+		ExecStart: "/usr/bin/test",
+		Requires:  []string{"dnstm-dnsrouter.service"},
+		Wants:     []string{"microsocks.service"},
+		After:     []string{"microsocks.service"},
+	})
+	for _, want := range []string{
+		"After=network-online.target microsocks.service\n",
+		"Wants=network-online.target microsocks.service\n",
+		"Requires=dnstm-dnsrouter.service\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+
+	unit = mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test"})
+	if !strings.Contains(unit, "After=network-online.target\n") || !strings.Contains(unit, "Wants=network-online.target\n") || strings.Contains(unit, "Requires=") {
+		t.Errorf("unit should have no extra dependencies:\n%s", unit)
+	}
+}
+
+func TestGenerateTimerUnits(t *testing.T) {
+	svc, timer := generateTimerUnits(&TimerConfig{
+		Name:        "dnstm-test",
+		Description: "Test job",
+		ExecStart:   "/usr/local/bin/dnstm test",
+		OnCalendar:  "*-*-* 03:00:00",
+		RandomDelay: time.Hour,
+	})
+
+	for _, want := range []string{"Type=oneshot\n", "ExecStart=/usr/local/bin/dnstm test\n"} {
+		if !strings.Contains(svc, want) {
+			t.Errorf("service unit missing %q:\n%s", want, svc)
+		}
+	}
+	for _, want := range []string{"OnCalendar=*-*-* 03:00:00\n", "RandomizedDelaySec=3600\n", "WantedBy=timers.target\n"} {
+		if !strings.Contains(timer, want) {
+			t.Errorf("timer unit missing %q:\n%s", want, timer)
+		}
+	}
+
+	_, timer = generateTimerUnits(&TimerConfig{
+		Name:       "dnstm-test",
+		ExecStart:  "/usr/local/bin/dnstm test",
+		OnCalendar: "*-*-* 18:00:00",
+		Calendars:  []string{"*-*-* 02:00:00"},
+		OnBoot:     time.Minute,
+	})
+	for _, want := range []string{"OnCalendar=*-*-* 18:00:00\n", "OnCalendar=*-*-* 02:00:00\n", "OnBootSec=60\n"} {
+		if !strings.Contains(timer, want) {
+			t.Errorf("timer unit missing %q:\n%s", want, timer)
+		}
+	}
+	if strings.Contains(timer, "RandomizedDelaySec") {
+		t.Errorf("timer unit should have no delay:\n%s", timer)
+	}
+}
+
+func TestGenerateUnit_Environment(t *testing.T) {
+	unit := mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test", Environment: []string{"A=b c"}})
+	if !strings.Contains(unit, "Environment=\"A=b c\"\nExecStart=/usr/bin/test\n") {
+		t.Errorf("unit missing Environment:\n%s", unit)
+	}
+
+	unit = mustGenerateUnit(t, &ServiceConfig{ExecStart: "/usr/bin/test"})
+	if strings.Contains(unit, "Environment=") {
+		t.Errorf("unit should not set Environment:\n%s", unit)
+	}
+}
+
+func TestGenerateDropIn(t *testing.T) {
+	dropIn := generateDropIn(&Overrides{
+		Environment:  []string{"HTTPS_PROXY=http://10.0.0.1:3128"},
+		ExecStartPre: []string{"/usr/local/bin/wait-for-vpn"},
+		After:        []string{"wg-quick@wg0.service", "mnt-keys.mount"},
+	})
+	want := dropInHeader + "\n[Unit]\nAfter=wg-quick@wg0.service mnt-keys.mount\n" +
+		"\n[Service]\nEnvironment=\"HTTPS_PROXY=http://10.0.0.1:3128\"\nExecStartPre=/usr/local/bin/wait-for-vpn\n"
+	if dropIn != want {
+		t.Errorf("generateDropIn() =\n%s\nwant\n%s", dropIn, want)
+	}
+
+	dropIn = generateDropIn(&Overrides{After: []string{"x.service"}})
+	if strings.Contains(dropIn, "[Service]") {
+		t.Errorf("drop-in should have no [Service] section:\n%s", dropIn)
+	}
+}
+
+func TestRemoveDropIn(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dnstm-test.service.d")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "override.conf")
+
+	// A drop-in written by hand is left alone
+	if err := os.WriteFile(path, []byte("[Service]\nNice=5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeDropIn(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("hand-written drop-in was removed: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(generateDropIn(&Overrides{After: []string{"x.service"}})), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeDropIn(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("drop-in directory left behind: %v", err)
+	}
+}
+
+func mustGenerateUnit(t *testing.T, cfg *ServiceConfig) string {
+	t.Helper()
+	unit, err := generateUnit(cfg)
+	if err != nil {
+		t.Fatalf("generateUnit() error = %v", err)
+	}
+	return unit
+}
+
+func TestGenerateUnit_EscapesExecStart(t *testing.T) {
+	unit := mustGenerateUnit(t, &ServiceConfig{ExecStart: `/usr/bin/test --pass "50%$off"`, ReadOnlyPaths: []string{"/etc/my keys"}})
+	if !strings.Contains(unit, "ExecStart=/usr/bin/test --pass \"50%%$$off\"\n") {
+		t.Errorf("ExecStart not escaped:\n%s", unit)
+	}
+	if !strings.Contains(unit, "ReadOnlyPaths=\"/etc/my keys\"\n") {
+		t.Errorf("ReadOnlyPaths not quoted:\n%s", unit)
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+)
+
+// Timers become Windows Task Scheduler tasks. Only the calendar expressions
+// dnstm itself generates are translated: daily times ("*-*-* 03:00:00"),
+// absolute times ("2026-01-02 15:04:05") and minute steps ("*:0/5").
+
+var (
+	dailyCalendar    = regexp.MustCompile(`^\*-\*-\* (\d{2}):(\d{2})(?::(\d{2}))?$`)
+	absoluteCalendar = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}(:\d{2})?$`)
+	stepCalendar     = regexp.MustCompile(`^\*:0/(\d+)$`)
+)
+
+// taskTrigger is one trigger of a scheduled task.
+type taskTrigger struct {
+	XMLName       xml.Name
+	StartBoundary string            `xml:"StartBoundary,omitempty"`
+	Delay         string            `xml:"Delay,omitempty"`
+	RandomDelay   string            `xml:"RandomDelay,omitempty"`
+	Repetition    *taskRepetition   `xml:"Repetition,omitempty"`
+	ScheduleByDay *taskScheduleDays `xml:"ScheduleByDay,omitempty"`
+}
+
+type taskRepetition struct {
+	Interval string `xml:"Interval"`
+}
+
+type taskScheduleDays struct {
+	DaysInterval int `xml:"DaysInterval"`
+}
+
+// taskTriggers translates the schedule of a timer into task triggers.
+func taskTriggers(cfg *TimerConfig, now time.Time) ([]taskTrigger, error) {
+	var triggers []taskTrigger
+	calendars := cfg.Calendars
+	if cfg.OnCalendar != "" {
+		calendars = append([]string{cfg.OnCalendar}, calendars...)
+	}
+	for _, c := range calendars {
+		t, err := calendarTrigger(c, now)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.RandomDelay > 0 {
+			t.RandomDelay = taskDuration(cfg.RandomDelay)
+		}
+		triggers = append(triggers, t)
+	}
+	if cfg.OnBoot > 0 {
+		triggers = append(triggers, taskTrigger{XMLName: xml.Name{Local: "BootTrigger"}, Delay: taskDuration(cfg.OnBoot)})
+	}
+	if len(triggers) == 0 {
+		return nil, fmt.Errorf("timer %s has no schedule", cfg.Name)
+	}
+	return triggers, nil
+}
+
+// calendarTrigger translates one systemd calendar expression.
+func calendarTrigger(calendar string, now time.Time) (taskTrigger, error) {
+	today := now.Format("2006-01-02")
+	switch {
+	case dailyCalendar.MatchString(calendar):
+		m := dailyCalendar.FindStringSubmatch(calendar)
+		sec := m[3]
+		if sec == "" {
+			sec = "00"
+		}
+		return taskTrigger{
+			XMLName:       xml.Name{Local: "CalendarTrigger"},
+			StartBoundary: fmt.Sprintf("%sT%s:%s:%s", today, m[1], m[2], sec),
+			ScheduleByDay: &taskScheduleDays{DaysInterval: 1},
+		}, nil
+	case absoluteCalendar.MatchString(calendar):
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", calendar, now.Location())
+		if err != nil {
+			t, err = time.ParseInLocation("2006-01-02 15:04", calendar, now.Location())
+		}
+		if err != nil {
+			return taskTrigger{}, fmt.Errorf("invalid calendar time %q: %w", calendar, err)
+		}
+		return taskTrigger{XMLName: xml.Name{Local: "TimeTrigger"}, StartBoundary: t.Format("2006-01-02T15:04:05")}, nil
+	case stepCalendar.MatchString(calendar):
+		minutes, _ := strconv.Atoi(stepCalendar.FindStringSubmatch(calendar)[1])
+		if minutes < 1 {
+			break
+		}
+		return taskTrigger{
+			XMLName:       xml.Name{Local: "TimeTrigger"},
+			StartBoundary: today + "T00:00:00",
+			Repetition:    &taskRepetition{Interval: taskDuration(time.Duration(minutes) * time.Minute)},
+		}, nil
+	}
+	return taskTrigger{}, fmt.Errorf("calendar expression %q is not supported on Windows", calendar)
+}
+
+// taskDuration formats d as an XML duration, e.g. PT1H30M.
+func taskDuration(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	out := "PT"
+	if h := s / 3600; h > 0 {
+		out += fmt.Sprintf("%dH", h)
+	}
+	if m := s / 60 % 60; m > 0 {
+		out += fmt.Sprintf("%dM", m)
+	}
+	if sec := s % 60; sec > 0 || out == "PT" {
+		out += fmt.Sprintf("%dS", sec)
+	}
+	return out
+}
+
+// renderTask renders the Task Scheduler definition of a timer. The task
+// runs ExecStart as SYSTEM.
+func renderTask(cfg *TimerConfig, now time.Time) (string, error) {
+	triggers, err := taskTriggers(cfg, now)
+	if err != nil {
+		return "", err
+	}
+	argv := cmdline.Split(cfg.ExecStart)
+	if len(argv) == 0 {
+		return "", fmt.Errorf("timer %s has no command", cfg.Name)
+	}
+	quoted := make([]string, len(argv)-1)
+	for i, a := range argv[1:] {
+		quoted[i] = quoteWindowsArg(a)
+	}
+
+	type exec struct {
+		Command   string `xml:"Command"`
+		Arguments string `xml:"Arguments,omitempty"`
+	}
+	task := struct {
+		XMLName     xml.Name      `xml:"Task"`
+		Version     string        `xml:"version,attr"`
+		Xmlns       string        `xml:"xmlns,attr"`
+		Description string        `xml:"RegistrationInfo>Description"`
+		Triggers    []taskTrigger `xml:"Triggers>Trigger"`
+		UserID      string        `xml:"Principals>Principal>UserId"`
+		RunLevel    string        `xml:"Principals>Principal>RunLevel"`
+		Settings    struct {
+			MultipleInstancesPolicy    string
+			DisallowStartIfOnBatteries bool
+			StopIfGoingOnBatteries     bool
+			StartWhenAvailable         bool
+			ExecutionTimeLimit         string
+		}
+		Exec exec `xml:"Actions>Exec"`
+	}{
+		Version:     "1.2",
+		Xmlns:       "http://schemas.microsoft.com/windows/2004/02/mit/task",
+		Description: cfg.Description,
+		Triggers:    triggers,
+		UserID:      "S-1-5-18", // LocalSystem
+		RunLevel:    "HighestAvailable",
+		Exec:        exec{Command: argv[0], Arguments: strings.Join(quoted, " ")},
+	}
+	task.Settings.MultipleInstancesPolicy = "IgnoreNew"
+	task.Settings.StartWhenAvailable = true
+	task.Settings.ExecutionTimeLimit = "PT1H"
+
+	data, err := xml.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// schtasks only accepts UTF-16 files; the caller encodes it
+	return `<?xml version="1.0" encoding="UTF-16"?>` + "\n" + string(data) + "\n", nil
+}
+
+// quoteWindowsArg quotes an argument for a Windows command line.
+func quoteWindowsArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarTrigger(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		calendar string
+		want     string
+	}{
+		{"*-*-* 03:00:00", "<CalendarTrigger><StartBoundary>2026-03-04T03:00:00</StartBoundary><ScheduleByDay><DaysInterval>1</DaysInterval></ScheduleByDay></CalendarTrigger>"},
+		{"*-*-* 22:30", "<StartBoundary>2026-03-04T22:30:00</StartBoundary>"},
+		{"2026-05-01 12:34:56", "<TimeTrigger><StartBoundary>2026-05-01T12:34:56</StartBoundary></TimeTrigger>"},
+		{"*:0/5", "<Repetition><Interval>PT5M</Interval></Repetition>"},
+	}
+	for _, tt := range tests {
+		def, err := renderTask(&TimerConfig{Name: "t", ExecStart: "/usr/local/bin/dnstm x", OnCalendar: tt.calendar}, now)
+		if err != nil {
+			t.Errorf("%q: %v", tt.calendar, err)
+			continue
+		}
+		if !strings.Contains(strings.Join(strings.Fields(def), ""), strings.ReplaceAll(tt.want, " ", "")) {
+			t.Errorf("%q: task missing %s:\n%s", tt.calendar, tt.want, def)
+		}
+	}
+
+	for _, calendar := range []string{"Mon *-*-* 03:00:00", "hourly", "*:0/0"} {
+		if _, err := calendarTrigger(calendar, now); err == nil {
+			t.Errorf("calendarTrigger(%q) succeeded, want unsupported", calendar)
+		}
+	}
+}
+
+func TestRenderTask(t *testing.T) {
+	def, err := renderTask(&TimerConfig{
+		Name:        "dnstm-autoupdate",
+		Description: "dnstm update",
+		ExecStart:   `'C:\Program Files\dnstm\dnstm.exe' update --force`,
+		Calendars:   []string{"*-*-* 04:00:00"},
+		OnBoot:      90 * time.Second,
+		RandomDelay: time.Hour,
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-16"?>`,
+		"<Triggers>",
+		"<RandomDelay>PT1H</RandomDelay>",
+		"<BootTrigger>",
+		"<Delay>PT1M30S</Delay>",
+		`<Command>C:\Program Files\dnstm\dnstm.exe</Command>`,
+		"<Arguments>update --force</Arguments>",
+		"<UserId>S-1-5-18</UserId>",
+	} {
+		if !strings.Contains(def, want) {
+			t.Errorf("task missing %s:\n%s", want, def)
+		}
+	}
+
+	if _, err := renderTask(&TimerConfig{Name: "t", ExecStart: "x"}, time.Now()); err == nil {
+		t.Error("renderTask() without a schedule succeeded")
+	}
+}
+
+func TestTaskDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                         "PT0S",
+		30 * time.Second:          "PT30S",
+		5 * time.Minute:           "PT5M",
+		90 * time.Minute:          "PT1H30M",
+		2*time.Hour + time.Second: "PT2H1S",
+	} {
+		if got := taskDuration(d); got != want {
+			t.Errorf("taskDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+//go:build !windows
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GetTimerPath returns the systemd timer file path for a timer name, or the
+// crontab holding its entries.
+func GetTimerPath(name string) string {
+	if rcInit {
+		return crontabPath
+	}
+	return fmt.Sprintf("/etc/systemd/system/%s.timer", name)
+}
+
+// CreateTimer writes a oneshot service and its timer, then enables and starts the timer.
+func CreateTimer(cfg *TimerConfig) error {
+	if rcInit {
+		return cronCreateTimer(cfg)
+	}
+	svc, timer := generateTimerUnits(cfg)
+
+	if err := os.WriteFile(GetServicePath(cfg.Name), []byte(svc), 0644); err != nil {
+		return fmt.Errorf("failed to write service file: %w", err)
+	}
+	if err := os.WriteFile(GetTimerPath(cfg.Name), []byte(timer), 0644); err != nil {
+		return fmt.Errorf("failed to write timer file: %w", err)
+	}
+	if err := DaemonReload(); err != nil {
+		return err
+	}
+	if err := runSystemctl("enable", cfg.Name+".timer"); err != nil {
+		return err
+	}
+	return runSystemctl("restart", cfg.Name+".timer")
+}
+
+// RemoveTimer stops and removes a timer and its service.
+func RemoveTimer(name string) error {
+	if rcInit {
+		return cronRemoveTimer(name)
+	}
+	runSystemctl("disable", name+".timer")
+	runSystemctl("stop", name+".timer")
+
+	for _, path := range []string{GetTimerPath(name), GetServicePath(name)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return DaemonReload()
+}
+
+// IsTimerInstalled checks if a timer unit file exists.
+func IsTimerInstalled(name string) bool {
+	if rcInit {
+		return cronIsTimerInstalled(name)
+	}
+	_, err := os.Stat(GetTimerPath(name))
+	return err == nil
+}
+
+// IsTimerActive checks if a timer is active. Cron entries are always active.
+func IsTimerActive(name string) bool {
+	if rcInit {
+		return cronIsTimerInstalled(name)
+	}
+	return IsServiceActive(name + ".timer")
+}
+
+// GetUnitProperty returns a systemd unit property, or "" if it cannot be read
+// or services are not systemd units.
+// The unit name must include its suffix, e.g. "dnstm-autoupdate.timer".
+func GetUnitProperty(unit, property string) string {
+	if rcInit {
+		return ""
+	}
+	output, err := exec.Command("systemctl", "show", unit, "-p", property, "--value").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// generateTimerUnits renders the service and timer unit file contents.
+func generateTimerUnits(cfg *TimerConfig) (string, string) {
+	var envSection string
+	for _, e := range environment(nil) {
+		envSection += fmt.Sprintf("Environment=%q\n", e)
+	}
+
+	svc := fmt.Sprintf(`[Unit]
+Description=%s
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+%sExecStart=%s
+StandardOutput=journal
+StandardError=journal
+`, cfg.Description, envSection, unitCommand(cfg.ExecStart))
+
+	var triggers strings.Builder
+	fmt.Fprintf(&triggers, "OnCalendar=%s\n", cfg.OnCalendar)
+	for _, c := range cfg.Calendars {
+		fmt.Fprintf(&triggers, "OnCalendar=%s\n", c)
+	}
+	if cfg.OnBoot > 0 {
+		fmt.Fprintf(&triggers, "OnBootSec=%d\n", int(cfg.OnBoot.Seconds()))
+	}
+	if cfg.RandomDelay > 0 {
+		fmt.Fprintf(&triggers, "RandomizedDelaySec=%d\n", int(cfg.RandomDelay.Seconds()))
+	}
+
+	timer := fmt.Sprintf(`[Unit]
+Description=%s (timer)
+
+[Timer]
+%sPersistent=false
+
+[Install]
+WantedBy=timers.target
+`, cfg.Description, triggers.String())
+
+	return svc, timer
+}
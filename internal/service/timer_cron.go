@@ -0,0 +1,63 @@
+//go:build !windows
+
+package service
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// cronCreateTimer adds the crontab entries of a timer, replacing any existing
+// ones.
+func cronCreateTimer(cfg *TimerConfig) error {
+	lines, err := cronEntries(cfg)
+	if err != nil {
+		return err
+	}
+	crontab, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	return writeCrontab(replaceCronEntries(crontab, cfg.Name, lines))
+}
+
+// cronRemoveTimer removes the crontab entries of a timer.
+func cronRemoveTimer(name string) error {
+	crontab, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(crontab, cronMarker(name)) {
+		return nil
+	}
+	return writeCrontab(replaceCronEntries(crontab, name, nil))
+}
+
+// cronIsTimerInstalled checks if a timer has crontab entries.
+func cronIsTimerInstalled(name string) bool {
+	crontab, err := readCrontab()
+	return err == nil && strings.Contains(crontab, " "+cronMarker(name)+"\n")
+}
+
+// readCrontab returns root's crontab, or "" if it has none.
+func readCrontab() (string, error) {
+	output, err := exec.Command("crontab", "-l").Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read crontab: %w", err)
+	}
+	return string(output), nil
+}
+
+// writeCrontab replaces root's crontab.
+func writeCrontab(crontab string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(crontab)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to write crontab: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Timers are Task Scheduler tasks in the \dnstm folder. The rendered task
+// definition is kept next to the service files.
+
+// taskName returns the Task Scheduler path of a timer.
+func taskName(name string) string {
+	return `\dnstm\` + name
+}
+
+// GetTimerPath returns the saved task definition of a timer.
+func GetTimerPath(name string) string {
+	return strings.TrimSuffix(GetServicePath(name), ".json") + ".task.xml"
+}
+
+// CreateTimer registers the scheduled task for a timer, replacing any
+// existing one.
+func CreateTimer(cfg *TimerConfig) error {
+	def, err := renderTask(cfg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(serviceDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create service directory: %w", err)
+	}
+	path := GetTimerPath(cfg.Name)
+	if err := os.WriteFile(path, encodeUTF16(def), 0644); err != nil {
+		return fmt.Errorf("failed to write task file: %w", err)
+	}
+	return runSchtasks("/Create", "/F", "/TN", taskName(cfg.Name), "/XML", path)
+}
+
+// RemoveTimer deletes a timer's scheduled task.
+func RemoveTimer(name string) error {
+	if IsTimerInstalled(name) {
+		if err := runSchtasks("/Delete", "/F", "/TN", taskName(name)); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(GetTimerPath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", GetTimerPath(name), err)
+	}
+	return nil
+}
+
+// IsTimerInstalled checks if a timer's scheduled task exists.
+func IsTimerInstalled(name string) bool {
+	return exec.Command("schtasks", "/Query", "/TN", taskName(name)).Run() == nil
+}
+
+// IsTimerActive checks if a timer's scheduled task exists and is enabled.
+func IsTimerActive(name string) bool {
+	output, err := exec.Command("schtasks", "/Query", "/TN", taskName(name), "/XML").Output()
+	if err != nil {
+		return false
+	}
+	return !strings.Contains(decodeUTF16(output), "<Enabled>false</Enabled>")
+}
+
+// GetUnitProperty returns "" on Windows, which has no unit properties.
+func GetUnitProperty(unit, property string) string {
+	return ""
+}
+
+// runSchtasks runs schtasks, including its output in any error.
+func runSchtasks(args ...string) error {
+	output, err := exec.Command("schtasks", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("schtasks %s failed: %s", args[0], strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// encodeUTF16 encodes s as little-endian UTF-16 with a byte order mark.
+func encodeUTF16(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2, 2+2*len(units))
+	b[0], b[1] = 0xff, 0xfe
+	for _, u := range units {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+// decodeUTF16 decodes schtasks XML output, which may be UTF-16 or plain.
+func decodeUTF16(b []byte) string {
+	if len(b) < 2 || b[0] != 0xff || b[1] != 0xfe {
+		return string(b)
+	}
+	units := make([]uint16, 0, len(b)/2)
+	for i := 2; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])|uint16(b[i+1])<<8)
+	}
+	return string(utf16.Decode(units))
+}
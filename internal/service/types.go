@@ -0,0 +1,62 @@
+package service
+
+import (
+	"time"
+
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// ServiceConfig contains configuration for a systemd service.
+type ServiceConfig struct {
+	Name             string // Service name (e.g., "dnstt-server", "slipstream-server")
+	Description      string
+	User             string
+	Group            string
+	ExecStart        string
+	ExecStartPre     []string // Commands run before ExecStart (prefix with "+" to run as root)
+	ReadOnlyPaths    []string // Paths that should be read-only
+	ReadWritePaths   []string // Paths that should be read-write
+	BindToPrivileged bool     // Whether service needs CAP_NET_BIND_SERVICE
+	WatchdogSec      int      // systemd watchdog timeout; requires ExecStart to send sd_notify pings
+	FDStore          int      // File descriptors systemd keeps across restarts; requires ExecStart to store them
+	RestartLimit     int      // Starts allowed within RestartWindow before systemd gives up; negative never gives up
+	RestartWindow    int      // Start rate limit interval in seconds
+	OnFailure        string   // Unit activated when the service enters the failed state
+	Requires         []string // Units started with this one; stopping them stops this one
+	Wants            []string // Units started with this one that stop and restart on their own
+	After            []string // Units this one is ordered after, besides network-online.target
+	Environment      []string // NAME=value pairs set for all commands
+	Overrides        *Overrides
+}
+
+// Overrides are settings added on top of a generated service. On systemd
+// they are written to a drop-in next to the unit; the service host merges
+// them into the service.
+type Overrides struct {
+	Environment  []string // NAME=value pairs
+	ExecStartPre []string
+	After        []string
+}
+
+// IsEmpty reports whether there is nothing to override.
+func (o *Overrides) IsEmpty() bool {
+	return o == nil || len(o.Environment)+len(o.ExecStartPre)+len(o.After) == 0
+}
+
+// environment returns the variables set for a service's commands: the path
+// overrides dnstm itself runs with, then the service's own.
+func environment(own []string) []string {
+	return append(paths.Overrides(), own...)
+}
+
+// TimerConfig contains configuration for a systemd timer and the oneshot
+// service it triggers. Both units share the same name.
+type TimerConfig struct {
+	Name        string
+	Description string
+	ExecStart   string
+	OnCalendar  string        // systemd calendar expression, e.g. "*-*-* 03:00:00"
+	Calendars   []string      // Further calendar expressions; the timer fires on each
+	OnBoot      time.Duration // Also fire this long after boot when set
+	RandomDelay time.Duration // Spreads the start over this period after OnCalendar
+}
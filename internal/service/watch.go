@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unitPathPrefix is the object path prefix of systemd units.
+const unitPathPrefix = "/org/freedesktop/systemd1/unit/"
+
+// UnitEvent is a change in the state of a service.
+type UnitEvent struct {
+	Service     string // service name without ".service"
+	ActiveState string // active, activating, deactivating, inactive or failed
+	SubState    string // e.g. running, dead or auto-restart; empty when polled
+	Time        time.Time
+}
+
+// WatchServices subscribes to systemd on the system bus and sends the state
+// changes of the services match accepts, until ctx is done or the
+// connection drops. It fails when the bus cannot be reached; callers can
+// then use PollServices instead.
+func WatchServices(ctx context.Context, match func(name string) bool) (<-chan UnitEvent, error) {
+	c, err := dialSystemBus()
+	if err != nil {
+		return nil, err
+	}
+	rule := "type='signal',sender='org.freedesktop.systemd1',interface='org.freedesktop.DBus.Properties'," +
+		"member='PropertiesChanged',path_namespace='/org/freedesktop/systemd1/unit'"
+	if err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", rule); err != nil {
+		c.Close()
+		return nil, err
+	}
+	// systemd only sends unit signals while some client is subscribed
+	if err := c.call("org.freedesktop.systemd1", "/org/freedesktop/systemd1", "org.freedesktop.systemd1.Manager", "Subscribe"); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	events := make(chan UnitEvent, 16)
+	go func() {
+		<-ctx.Done()
+		c.Close()
+	}()
+	go func() {
+		defer close(events)
+		for {
+			m, err := c.read()
+			if errors.Is(err, errDBusUndecodable) {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			ev, ok := unitEvent(m)
+			if !ok || !match(ev.Service) {
+				continue
+			}
+			forgetActive(ev.Service)
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// unitEvent extracts a service state change from a PropertiesChanged signal.
+func unitEvent(m *dbusMessage) (UnitEvent, bool) {
+	if m.Type != dbusSignal || m.Member != "PropertiesChanged" || len(m.Body) < 2 {
+		return UnitEvent{}, false
+	}
+	if iface, _ := m.Body[0].(string); iface != "org.freedesktop.systemd1.Unit" {
+		return UnitEvent{}, false
+	}
+	unit := unescapeUnitPath(strings.TrimPrefix(m.Path, unitPathPrefix))
+	name, ok := strings.CutSuffix(unit, ".service")
+	if !ok {
+		return UnitEvent{}, false
+	}
+
+	ev := UnitEvent{Service: name, Time: time.Now()}
+	changed, _ := m.Body[1].([]any)
+	for _, entry := range changed {
+		kv, _ := entry.([]any)
+		if len(kv) != 2 {
+			continue
+		}
+		value, _ := kv[1].(string)
+		switch kv[0] {
+		case "ActiveState":
+			ev.ActiveState = value
+		case "SubState":
+			ev.SubState = value
+		}
+	}
+	return ev, ev.ActiveState != ""
+}
+
+// unescapeUnitPath decodes the _xx escapes systemd uses in unit object paths.
+func unescapeUnitPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// PollServices checks the active state of the services every interval with
+// one systemctl call and sends the changes, until ctx is done. The first
+// check reports every service.
+func PollServices(ctx context.Context, names []string, interval time.Duration) <-chan UnitEvent {
+	events := make(chan UnitEvent, 16)
+	go func() {
+		defer close(events)
+		last := make(map[string]string)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if states, err := queryActiveStates(names); err == nil && states != nil {
+				for i, name := range names {
+					if last[name] == states[i] {
+						continue
+					}
+					last[name] = states[i]
+					select {
+					case events <- UnitEvent{Service: name, ActiveState: states[i], Time: time.Now()}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
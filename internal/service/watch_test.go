@@ -0,0 +1,195 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMessage encodes a message as a bus would send it.
+func testMessage(typ byte, serial, replySerial uint32, path, iface, member, sig string, body []byte) []byte {
+	var e dbusEncoder
+	e.buf = append(e.buf, 'l', typ, 0, 1)
+	e.uint32(uint32(len(body)))
+	e.uint32(serial)
+	lenAt := len(e.buf)
+	e.uint32(0)
+	start := len(e.buf)
+	if path != "" {
+		e.field(1, "o", path)
+	}
+	if iface != "" {
+		e.field(2, "s", iface)
+	}
+	if member != "" {
+		e.field(3, "s", member)
+	}
+	if replySerial != 0 {
+		e.align(8)
+		e.buf = append(e.buf, 5)
+		e.signature("u")
+		e.uint32(replySerial)
+	}
+	if sig != "" {
+		e.field(8, "g", sig)
+	}
+	binary.LittleEndian.PutUint32(e.buf[lenAt:], uint32(len(e.buf)-start))
+	e.align(8)
+	return append(e.buf, body...)
+}
+
+// propertiesChanged encodes the body of a PropertiesChanged signal.
+func propertiesChanged(iface string, props map[string]string) []byte {
+	var e dbusEncoder
+	e.string(iface)
+	e.align(4)
+	lenAt := len(e.buf)
+	e.uint32(0)
+	e.align(8)
+	start := len(e.buf)
+	for k, v := range props {
+		e.align(8)
+		e.string(k)
+		e.signature("s")
+		e.string(v)
+	}
+	binary.LittleEndian.PutUint32(e.buf[lenAt:], uint32(len(e.buf)-start))
+	e.uint32(0) // no invalidated properties
+	return e.buf
+}
+
+func TestParseDBusMessage_PropertiesChanged(t *testing.T) {
+	body := propertiesChanged("org.freedesktop.systemd1.Unit", map[string]string{"ActiveState": "failed", "SubState": "failed"})
+	packet := testMessage(dbusSignal, 7, 0, unitPathPrefix+"dnstm_2dswift_2dfox_2eservice",
+		"org.freedesktop.DBus.Properties", "PropertiesChanged", "sa{sv}as", body)
+
+	m, err := parseDBusMessage(packet, binary.LittleEndian)
+	if err != nil {
+		t.Fatalf("parseDBusMessage() error = %v", err)
+	}
+	if m.Serial != 7 || m.Member != "PropertiesChanged" || len(m.Body) != 3 {
+		t.Fatalf("parseDBusMessage() = %+v", m)
+	}
+	ev, ok := unitEvent(m)
+	if !ok || ev.Service != "dnstm-swift-fox" || ev.ActiveState != "failed" || ev.SubState != "failed" {
+		t.Errorf("unitEvent() = %+v, %v", ev, ok)
+	}
+
+	if _, err := parseDBusMessage(packet[:len(packet)-6], binary.LittleEndian); err == nil {
+		t.Error("parseDBusMessage() of a cut message succeeded")
+	}
+}
+
+func TestUnitEvent_Ignores(t *testing.T) {
+	for _, tt := range []struct {
+		path, iface string
+	}{
+		{unitPathPrefix + "dnstm_2dx_2esocket", "org.freedesktop.systemd1.Unit"},
+		{unitPathPrefix + "dnstm_2dx_2eservice", "org.freedesktop.systemd1.Service"},
+	} {
+		body := propertiesChanged(tt.iface, map[string]string{"ActiveState": "active"})
+		m, err := parseDBusMessage(testMessage(dbusSignal, 1, 0, tt.path, "org.freedesktop.DBus.Properties",
+			"PropertiesChanged", "sa{sv}as", body), binary.LittleEndian)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev, ok := unitEvent(m); ok {
+			t.Errorf("unitEvent(%s, %s) = %+v, want ignored", tt.path, tt.iface, ev)
+		}
+	}
+}
+
+func TestFirstType(t *testing.T) {
+	tests := map[string]string{
+		"sa{sv}as": "s",
+		"a{sv}as":  "a{sv}",
+		"(yv)u":    "(yv)",
+		"aa(s(u))": "aa(s(u))",
+	}
+	for sig, want := range tests {
+		if got, err := firstType(sig); err != nil || got != want {
+			t.Errorf("firstType(%q) = %q, %v, want %q", sig, got, err, want)
+		}
+	}
+	for _, sig := range []string{"", "a", "(ss", "(s}"} {
+		if _, err := firstType(sig); err == nil {
+			t.Errorf("firstType(%q) succeeded", sig)
+		}
+	}
+}
+
+// fakeBus accepts one client, answers its method calls and then sends
+// the given signals.
+func fakeBus(t *testing.T, signals ...[]byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bus")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", "unix:path="+path+",guid=0")
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if line, err := r.ReadString('\n'); err != nil || !strings.Contains(line, "AUTH EXTERNAL") {
+			return
+		}
+		conn.Write([]byte("OK 0123456789abcdef\r\n"))
+		if _, err := r.ReadString('\n'); err != nil {
+			return
+		}
+
+		bus := &dbusConn{conn: conn, r: r}
+		for {
+			m, err := bus.read()
+			if err != nil {
+				return
+			}
+			conn.Write(testMessage(dbusMethodReturn, m.Serial+100, m.Serial, "", "", "", "", nil))
+			if m.Member == "Subscribe" {
+				break
+			}
+		}
+		for _, s := range signals {
+			conn.Write(s)
+		}
+		io.Copy(io.Discard, r)
+	}()
+}
+
+func TestWatchServices(t *testing.T) {
+	signal := func(unit, state string) []byte {
+		body := propertiesChanged("org.freedesktop.systemd1.Unit", map[string]string{"ActiveState": state})
+		return testMessage(dbusSignal, 1, 0, unitPathPrefix+unit, "org.freedesktop.DBus.Properties", "PropertiesChanged", "sa{sv}as", body)
+	}
+	// A signal whose body is cut short is skipped, not the end of the watch
+	broken := testMessage(dbusSignal, 1, 0, unitPathPrefix+"dnstm_2dt0_2eservice", "org.freedesktop.DBus.Properties", "PropertiesChanged", "s", nil)
+	fakeBus(t, signal("ssh_2eservice", "inactive"), broken, signal("dnstm_2dt1_2eservice", "failed"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := WatchServices(ctx, func(name string) bool { return strings.HasPrefix(name, "dnstm-") })
+	if err != nil {
+		t.Fatalf("WatchServices() error = %v", err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Service != "dnstm-t1" || ev.ActiveState != "failed" {
+			t.Errorf("event = %+v, want dnstm-t1 failed", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+	}
+}
@@ -0,0 +1,227 @@
+// Package sessionlog writes the session events of the tunnel fronts to the
+// sink of log.sessions, for operators that must keep connection records.
+//
+// Every event is a JSON line: a file sink gets one line per event, a
+// webhook a POST of newline-separated lines per batch, and a syslog server
+// one message per event. Events are queued in memory and written in the
+// background, so a slow sink never holds up a session; while the sink
+// fails the batch is retried and events that no longer fit the queue are
+// dropped and counted in the service log.
+package sessionlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/privacy"
+)
+
+const (
+	// queueSize is how many events wait for the sink before new ones are
+	// dropped.
+	queueSize = 4096
+
+	// batchSize and batchWait bound how many events are written at once and
+	// how long the first of them waits for more.
+	batchSize = 100
+	batchWait = time.Second
+
+	// maxBackoff is the longest wait between attempts to write a batch.
+	maxBackoff = time.Minute
+
+	// closeTimeout bounds how long Close waits for queued events.
+	closeTimeout = 5 * time.Second
+)
+
+// EventTest is the kind of the event SendTest writes.
+const EventTest = "sink_test"
+
+// writer writes JSON lines to a sink.
+type writer interface {
+	Write(lines [][]byte) error
+	Close()
+}
+
+// newWriter returns the writer for the sink of log.sessions.
+func newWriter(cfg *config.Config) (writer, error) {
+	s := cfg.Log.Sessions
+	u, err := url.Parse(s.Sink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log.sessions.sink: %w", err)
+	}
+	switch s.SinkType() {
+	case config.SessionSinkFile:
+		return &fileWriter{path: u.Path}, nil
+	case config.SessionSinkWebhook:
+		return newWebhookWriter(u), nil
+	case config.SessionSinkSyslog:
+		return newSyslogWriter(cfg)
+	}
+	return nil, fmt.Errorf("unknown session log sink '%s'", u.Scheme)
+}
+
+// Recorder queues session events and writes them to the sink.
+type Recorder struct {
+	host    string
+	anon    *privacy.Anonymizer
+	w       writer
+	queue   chan health.SessionEvent
+	dropped atomic.Int64
+	stop    chan struct{}
+	done    chan struct{}
+	close   sync.Once
+}
+
+// Open starts writing session events to the sink of log.sessions.
+func Open(cfg *config.Config) (*Recorder, error) {
+	w, err := newWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	r := &Recorder{
+		host:  host,
+		anon:  privacy.New(cfg.Privacy),
+		w:     w,
+		queue: make(chan health.SessionEvent, queueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+// Record queues an event, or drops it when the queue is full. The client
+// address is anonymized when privacy is set.
+func (r *Recorder) Record(e health.SessionEvent) {
+	e.Host = r.host
+	e.Client = r.anon.IP(e.Client)
+	select {
+	case r.queue <- e:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Close writes the queued events, waiting at most closeTimeout, and closes
+// the sink. Events recorded after Close are dropped.
+func (r *Recorder) Close() {
+	r.close.Do(func() {
+		close(r.stop)
+		select {
+		case <-r.done:
+			r.w.Close()
+		case <-time.After(closeTimeout):
+			log.Printf("[warning] session events still queued at exit were not written")
+		}
+	})
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	for {
+		batch, open := nextBatch(r.queue, r.stop)
+		if len(batch) > 0 {
+			r.write(batch)
+		}
+		if !open {
+			return
+		}
+	}
+}
+
+// write writes batch until the sink takes it. Only the first failure and
+// the recovery are logged.
+func (r *Recorder) write(batch []health.SessionEvent) {
+	lines := make([][]byte, 0, len(batch))
+	for _, e := range batch {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	backoff := time.Second
+	failed := false
+	for {
+		err := r.w.Write(lines)
+		if err == nil {
+			break
+		}
+		if !failed {
+			log.Printf("[warning] session events not written: %v; retrying", err)
+			failed = true
+		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxBackoff)
+	}
+	if failed {
+		log.Printf("Session events written again")
+	}
+	if n := r.dropped.Swap(0); n > 0 {
+		log.Printf("[warning] %d session events dropped while the sink was behind", n)
+	}
+}
+
+// nextBatch waits for an event, then collects what follows it within
+// batchWait. Once stop is closed it returns what is left in the queue and
+// reports false.
+func nextBatch(queue <-chan health.SessionEvent, stop <-chan struct{}) ([]health.SessionEvent, bool) {
+	var batch []health.SessionEvent
+	select {
+	case e := <-queue:
+		batch = append(batch, e)
+	case <-stop:
+		return drain(queue, batch), false
+	}
+	timer := time.NewTimer(batchWait)
+	defer timer.Stop()
+	for len(batch) < batchSize {
+		select {
+		case e := <-queue:
+			batch = append(batch, e)
+		case <-timer.C:
+			return batch, true
+		case <-stop:
+			return drain(queue, batch), false
+		}
+	}
+	return batch, true
+}
+
+// drain appends the events waiting in queue to batch.
+func drain(queue <-chan health.SessionEvent, batch []health.SessionEvent) []health.SessionEvent {
+	for {
+		select {
+		case e := <-queue:
+			batch = append(batch, e)
+		default:
+			return batch
+		}
+	}
+}
+
+// SendTest writes one event saying session logging was turned on, to check
+// the sink can be reached.
+func SendTest(cfg *config.Config) error {
+	w, err := newWriter(cfg)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	host, _ := os.Hostname()
+	line, err := json.Marshal(health.SessionEvent{Time: time.Now(), Event: EventTest, Host: host})
+	if err != nil {
+		return err
+	}
+	return w.Write([][]byte{line})
+}
@@ -0,0 +1,119 @@
+package sessionlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+)
+
+func sinkConfig(sink string) *config.Config {
+	cfg := config.Default()
+	cfg.Log.Sessions = &config.SessionLogConfig{Sink: sink}
+	return cfg
+}
+
+func TestRecorderFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.jsonl")
+	r, err := Open(sinkConfig("file://" + path))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	r.Record(health.SessionEvent{Event: health.EventSessionStart, Session: "a1", Tunnel: "slip1"})
+	r.Record(health.SessionEvent{Event: health.EventSessionEnd, Session: "a1", Tunnel: "slip1", BytesUp: 10, BytesDown: 20})
+	// Close writes what is still queued
+	r.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []health.SessionEvent
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e health.SessionEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", sc.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	host, _ := os.Hostname()
+	if events[1].Event != health.EventSessionEnd || events[1].BytesDown != 20 || events[1].Host != host {
+		t.Errorf("events[1] = %+v", events[1])
+	}
+
+	// Events recorded after Close are dropped, not a panic
+	r.Record(health.SessionEvent{Event: health.EventSessionStart})
+}
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		auth, _, _ = req.BasicAuth()
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	sink := strings.Replace(srv.URL, "http://", "http://siem:token@", 1) + "/ingest"
+	if err := SendTest(sinkConfig(sink)); err != nil {
+		t.Fatalf("SendTest() error = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], `"event":"sink_test"`) || !strings.HasSuffix(bodies[0], "\n") {
+		t.Errorf("bodies = %q", bodies)
+	}
+	if auth != "siem" {
+		t.Errorf("basic auth user = %q, want siem", auth)
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	err := SendTest(sinkConfig(srv.URL))
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("SendTest() error = %v, want the webhook's answer", err)
+	}
+}
+
+func TestNextBatch(t *testing.T) {
+	queue := make(chan health.SessionEvent, 10)
+	stop := make(chan struct{})
+	queue <- health.SessionEvent{Session: "a"}
+	queue <- health.SessionEvent{Session: "b"}
+
+	start := time.Now()
+	batch, open := nextBatch(queue, stop)
+	if len(batch) != 2 || !open || time.Since(start) < batchWait {
+		t.Errorf("nextBatch() = %d events, %v", len(batch), open)
+	}
+
+	queue <- health.SessionEvent{Session: "c"}
+	close(stop)
+	if batch, open := nextBatch(queue, stop); len(batch) != 1 || open {
+		t.Errorf("nextBatch() after stop = %d events, %v", len(batch), open)
+	}
+}
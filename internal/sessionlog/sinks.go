@@ -0,0 +1,107 @@
+package sessionlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/logship"
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// sinkTimeout bounds one write to a remote sink.
+const sinkTimeout = 10 * time.Second
+
+// fileWriter appends lines to a file. The file is opened for every batch,
+// so it can be rotated by moving it away.
+type fileWriter struct {
+	path string
+}
+
+func (w *fileWriter) Write(lines [][]byte) error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (w *fileWriter) Close() {}
+
+// webhookWriter POSTs each batch as newline-separated JSON. Credentials in
+// the URL are sent as basic auth.
+type webhookWriter struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookWriter(u *url.URL) *webhookWriter {
+	return &webhookWriter{url: u.String(), client: &http.Client{Timeout: sinkTimeout}}
+}
+
+func (w *webhookWriter) Write(lines [][]byte) error {
+	body := append(bytes.Join(lines, []byte("\n")), '\n')
+	resp, err := w.client.Post(w.url, "application/x-ndjson", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", logship.RedactURL(w.url), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (w *webhookWriter) Close() {}
+
+// syslogUnit names the events in syslog messages.
+var syslogUnit = paths.Service("sessions") + ".service"
+
+// syslogWriter sends each line as the message of a syslog entry, with the
+// same framing and fields as log shipping.
+type syslogWriter struct {
+	sender  logship.Sender
+	labeler *logship.Labeler
+}
+
+func newSyslogWriter(cfg *config.Config) (*syslogWriter, error) {
+	sender, err := logship.NewSender(&config.LogShipConfig{URL: cfg.Log.Sessions.Sink})
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	return &syslogWriter{sender: sender, labeler: logship.NewLabeler(cfg, host)}, nil
+}
+
+func (w *syslogWriter) Write(lines [][]byte) error {
+	entries := make([]logship.Entry, len(lines))
+	for i, line := range lines {
+		entries[i] = logship.Entry{
+			Time:     time.Now(),
+			Unit:     syslogUnit,
+			Severity: logship.SeverityInfo,
+			Message:  string(line),
+		}
+	}
+	return w.sender.Send(entries, w.labeler)
+}
+
+func (w *syslogWriter) Close() {
+	w.sender.Close()
+}
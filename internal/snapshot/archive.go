@@ -0,0 +1,201 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeArchive writes the given absolute paths, resolved under root, to a
+// gzipped tarball at dest. Directories are included recursively; entries are
+// stored relative to root.
+func writeArchive(dest, root string, paths []string) error {
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	for _, p := range paths {
+		src := filepath.Join(root, p)
+		err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				return nil
+			}
+
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(rel)
+			if info.IsDir() {
+				hdr.Name += "/"
+			}
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			in, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			_, err = io.Copy(tw, in)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", p, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return f.Sync()
+}
+
+// extractArchive restores a tarball written by writeArchive under root.
+// Only entries inside one of the allowed paths are extracted, so a tampered
+// archive cannot write elsewhere on the system.
+func extractArchive(src, root string, allowed []string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		name := "/" + strings.TrimSuffix(hdr.Name, "/")
+		if filepath.Clean(name) != name || !isAllowed(name, allowed) {
+			return fmt.Errorf("archive entry %s is outside the snapshot paths", hdr.Name)
+		}
+		target := filepath.Join(root, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+			out.Close()
+		default:
+			continue
+		}
+
+		// Restore mode and ownership; chown only succeeds as root
+		os.Chmod(target, os.FileMode(hdr.Mode).Perm())
+		if os.Geteuid() == 0 {
+			os.Lchown(target, hdr.Uid, hdr.Gid)
+		}
+	}
+}
+
+// stageArchive extracts an archive written by writeArchive into a new
+// directory inside parent, so a corrupt or truncated archive fails before
+// anything it restores is touched. The caller removes the directory.
+func stageArchive(src, parent string, allowed []string) (string, error) {
+	staging, err := os.MkdirTemp(parent, ".dnstm-rollback-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := extractArchive(src, staging, allowed); err != nil {
+		os.RemoveAll(staging)
+		return "", err
+	}
+	return staging, nil
+}
+
+// swapIn moves the paths extracted into staging to the same paths under
+// root. dir is replaced as a whole, so what it held before is gone even
+// when the archive has no copy of it; if its copy cannot be moved in, the
+// old one is put back.
+func swapIn(staging, root string, paths []string, dir string) error {
+	target := filepath.Join(root, dir)
+	old := filepath.Join(staging, ".old")
+	if err := os.Rename(target, old); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", dir, err)
+	}
+	if staged := filepath.Join(staging, dir); exists(staged) {
+		if err := os.Rename(staged, target); err != nil {
+			os.Rename(old, target)
+			return fmt.Errorf("failed to replace %s: %w", dir, err)
+		}
+	}
+
+	for _, p := range paths {
+		staged := filepath.Join(staging, p)
+		if p == dir || !exists(staged) {
+			continue
+		}
+		dest := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", p, err)
+		}
+		if err := os.Rename(staged, dest); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// isAllowed reports whether name equals or is inside one of the allowed paths.
+func isAllowed(name string, allowed []string) bool {
+	for _, a := range allowed {
+		if name == a || strings.HasPrefix(name, strings.TrimSuffix(a, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "etc/dnstm/config.json"), `{"tunnels":[]}`, 0644)
+	writeFile(t, filepath.Join(src, "etc/dnstm/tunnels/a/server.key"), "secret", 0600)
+	writeFile(t, filepath.Join(src, "etc/systemd/system/dnstm-a.service"), "[Unit]", 0644)
+	writeFile(t, filepath.Join(src, "etc/other"), "not captured", 0644)
+
+	paths := []string{"/etc/dnstm", "/etc/systemd/system/dnstm-a.service"}
+	archive := filepath.Join(t.TempDir(), archiveFile)
+	if err := writeArchive(archive, src, paths); err != nil {
+		t.Fatalf("writeArchive failed: %v", err)
+	}
+
+	dst := t.TempDir()
+	if err := extractArchive(archive, dst, paths); err != nil {
+		t.Fatalf("extractArchive failed: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"etc/dnstm/config.json":              `{"tunnels":[]}`,
+		"etc/dnstm/tunnels/a/server.key":     "secret",
+		"etc/systemd/system/dnstm-a.service": "[Unit]",
+	} {
+		got, err := os.ReadFile(filepath.Join(dst, path))
+		if err != nil {
+			t.Errorf("%s not restored: %v", path, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "etc/dnstm/tunnels/a/server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key mode = %o, want 600", info.Mode().Perm())
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "etc/other")); !os.IsNotExist(err) {
+		t.Error("uncaptured file should not be restored")
+	}
+}
+
+func TestExtractArchive_RejectsOutsidePaths(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), archiveFile)
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"etc/dnstm/../passwd"} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+		tw.Write([]byte("x"))
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	dst := t.TempDir()
+	if err := extractArchive(archive, dst, []string{"/etc/dnstm"}); err == nil {
+		t.Error("extractArchive should reject entries outside the snapshot paths")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "etc/passwd")); !os.IsNotExist(err) {
+		t.Error("entry outside the snapshot paths was written")
+	}
+}
+
+func TestIsAllowed(t *testing.T) {
+	allowed := []string{"/etc/dnstm", "/etc/systemd/system/dnstm-a.service"}
+
+	tests := map[string]bool{
+		"/etc/dnstm":                          true,
+		"/etc/dnstm/config.json":              true,
+		"/etc/dnstm-other/config.json":        false,
+		"/etc/systemd/system/dnstm-a.service": true,
+		"/etc/systemd/system/dnstm-b.service": false,
+	}
+	for name, want := range tests {
+		if got := isAllowed(name, allowed); got != want {
+			t.Errorf("isAllowed(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestStageAndSwapIn(t *testing.T) {
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "etc/dnstm/config.json"), "restored", 0644)
+	writeFile(t, filepath.Join(src, "etc/systemd/system/dnstm-a.service"), "[Unit]", 0644)
+	paths := []string{"/etc/dnstm", "/etc/systemd/system/dnstm-a.service"}
+	archive := filepath.Join(t.TempDir(), archiveFile)
+	if err := writeArchive(archive, src, paths); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "etc/dnstm/config.json"), "current", 0644)
+	writeFile(t, filepath.Join(root, "etc/dnstm/tunnels/b/server.key"), "added later", 0600)
+
+	// A truncated archive fails before anything is replaced
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(t.TempDir(), archiveFile)
+	writeFile(t, truncated, string(data[:len(data)/2]), 0600)
+	if _, err := stageArchive(truncated, filepath.Join(root, "etc"), paths); err == nil {
+		t.Fatal("stageArchive accepted a truncated archive")
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "etc/dnstm/config.json")); string(got) != "current" {
+		t.Errorf("config after a failed stage = %q, want it untouched", got)
+	}
+
+	staging, err := stageArchive(archive, filepath.Join(root, "etc"), paths)
+	if err != nil {
+		t.Fatalf("stageArchive failed: %v", err)
+	}
+	defer os.RemoveAll(staging)
+	if err := swapIn(staging, root, paths, "/etc/dnstm"); err != nil {
+		t.Fatalf("swapIn failed: %v", err)
+	}
+
+	if got, _ := os.ReadFile(filepath.Join(root, "etc/dnstm/config.json")); string(got) != "restored" {
+		t.Errorf("config = %q, want restored", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "etc/systemd/system/dnstm-a.service")); string(got) != "[Unit]" {
+		t.Errorf("unit = %q, want restored", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "etc/dnstm/tunnels/b")); !os.IsNotExist(err) {
+		t.Error("files the snapshot predates were kept in the config directory")
+	}
+}
@@ -0,0 +1,317 @@
+// Package snapshot captures and restores dnstm system state.
+//
+// A snapshot holds the configuration directory (config, certificates, keys,
+// tunnel configs), the systemd units dnstm manages, UFW NAT rule files, and
+// the DNS NAT redirect rules, together with which services were running.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/network"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// Dir is where snapshots are stored. It lives outside the config directory
+// so snapshots survive an uninstall.
+const Dir = "/var/lib/dnstm/snapshots"
+
+const (
+	manifestFile = "manifest.json"
+	archiveFile  = "files.tar.gz"
+	systemdDir   = "/etc/systemd/system"
+)
+
+// MaxAuto is how many automatic snapshots are kept; older ones are removed
+// when a new one is taken. Snapshots taken with dnstm snapshot create are
+// kept until removed.
+const MaxAuto = 10
+
+// extraPaths are captured in addition to the config directory and units when present.
+var extraPaths = []string{
+	"/etc/ufw/before.rules",
+	"/etc/ufw/before6.rules",
+}
+
+// Manifest describes a snapshot.
+type Manifest struct {
+	ID       string    `json:"id"`
+	Created  time.Time `json:"created"`
+	Reason   string    `json:"reason,omitempty"`
+	Auto     bool      `json:"auto,omitempty"` // taken before an operation, pruned past MaxAuto
+	Version  string    `json:"version,omitempty"`
+	Units    []string  `json:"units"`
+	Active   []string  `json:"active,omitempty"`
+	Paths    []string  `json:"paths"`
+	NATRules []string  `json:"nat_rules,omitempty"`
+}
+
+// Create captures the current system state. The reason is recorded in the
+// manifest, e.g. "manual".
+func Create(reason, version string) (*Manifest, error) {
+	return create(reason, version, false)
+}
+
+// CreateAuto is Create for the snapshot taken before an operation, e.g.
+// "before uninstall". Automatic snapshots past the newest MaxAuto are
+// removed; failing to remove them does not fail the snapshot.
+func CreateAuto(reason, version string) (*Manifest, error) {
+	m, err := create(reason, version, true)
+	if err != nil {
+		return nil, err
+	}
+	pruneAuto(Dir, MaxAuto)
+	return m, nil
+}
+
+func create(reason, version string, auto bool) (*Manifest, error) {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	now := time.Now()
+	m := &Manifest{
+		ID:      newID(now),
+		Created: now.UTC(),
+		Reason:  reason,
+		Auto:    auto,
+		Version: version,
+		Units:   managedUnits(),
+	}
+
+	for _, unit := range m.Units {
+		if service.IsServiceActive(unit) {
+			m.Active = append(m.Active, unit)
+		}
+	}
+
+	if _, err := os.Stat(config.ConfigDir); err == nil {
+		m.Paths = append(m.Paths, config.ConfigDir)
+	}
+	for _, unit := range m.Units {
+		m.Paths = append(m.Paths, service.GetServicePath(unit))
+	}
+	for _, p := range extraPaths {
+		if _, err := os.Stat(p); err == nil {
+			m.Paths = append(m.Paths, p)
+		}
+	}
+
+	m.NATRules = network.DNSRedirectRules()
+
+	dir := filepath.Join(Dir, m.ID)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	if err := writeArchive(filepath.Join(dir, archiveFile), "/", m.Paths); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := writeManifest(dir, m); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// List returns all snapshots, oldest first.
+func List() ([]*Manifest, error) {
+	return list(Dir)
+}
+
+func list(dir string) ([]*Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	var manifests []*Manifest
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		m, err := readManifest(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Created.Before(manifests[j].Created)
+	})
+	return manifests, nil
+}
+
+// Get returns the manifest of a snapshot. "latest" selects the newest snapshot.
+func Get(id string) (*Manifest, error) {
+	if id == "latest" {
+		manifests, err := List()
+		if err != nil {
+			return nil, err
+		}
+		if len(manifests) == 0 {
+			return nil, fmt.Errorf("no snapshots found")
+		}
+		return manifests[len(manifests)-1], nil
+	}
+
+	if strings.ContainsAny(id, "/\\") || id == "" || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid snapshot id '%s'", id)
+	}
+	m, err := readManifest(filepath.Join(Dir, id))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot '%s' not found", id)
+	}
+	return m, nil
+}
+
+// Remove deletes a snapshot.
+func Remove(id string) error {
+	m, err := Get(id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(Dir, m.ID))
+}
+
+// pruneAuto removes the automatic snapshots in dir past the newest keep.
+func pruneAuto(dir string, keep int) {
+	manifests, err := list(dir)
+	if err != nil {
+		return
+	}
+	var auto []*Manifest
+	for _, m := range manifests {
+		if m.Auto {
+			auto = append(auto, m)
+		}
+	}
+	for len(auto) > keep {
+		os.RemoveAll(filepath.Join(dir, auto[0].ID))
+		auto = auto[1:]
+	}
+}
+
+// Rollback restores the system to the state captured in a snapshot.
+// Services that are not part of the snapshot are stopped and removed, the
+// config directory is replaced, and services that were running are restarted.
+// The archive is extracted before anything is stopped, so a corrupt one
+// leaves the system as it was.
+func Rollback(m *Manifest) error {
+	dir := filepath.Join(Dir, m.ID)
+
+	staging, err := stageArchive(filepath.Join(dir, archiveFile), filepath.Dir(config.ConfigDir), m.Paths)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	// Stop everything currently managed, including units the snapshot predates
+	current := managedUnits()
+	for _, unit := range current {
+		service.StopService(unit)
+	}
+
+	keep := make(map[string]bool, len(m.Units))
+	for _, unit := range m.Units {
+		keep[unit] = true
+	}
+	for _, unit := range current {
+		if !keep[unit] {
+			service.DisableService(unit)
+			os.Remove(service.GetServicePath(unit))
+		}
+	}
+
+	if err := swapIn(staging, "/", m.Paths, config.ConfigDir); err != nil {
+		return err
+	}
+
+	if err := service.DaemonReload(); err != nil {
+		return err
+	}
+
+	if err := network.RestoreDNSRedirectRules(m.NATRules); err != nil {
+		return fmt.Errorf("failed to restore NAT rules: %w", err)
+	}
+
+	var errs []string
+	for _, unit := range m.Active {
+		if err := service.EnableService(unit); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := service.StartService(unit); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", unit, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to start services: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// managedUnits returns the names of installed systemd units managed by dnstm.
+func managedUnits() []string {
+	var units []string
+	matches, _ := filepath.Glob(filepath.Join(systemdDir, paths.ServicePrefix+"*.service"))
+	for _, path := range matches {
+		units = append(units, strings.TrimSuffix(filepath.Base(path), ".service"))
+	}
+	if _, err := os.Stat(service.GetServicePath(proxy.MicrosocksServiceName)); err == nil {
+		units = append(units, proxy.MicrosocksServiceName)
+	}
+	sort.Strings(units)
+	return units
+}
+
+// newID returns a snapshot ID based on the creation time.
+func newID(t time.Time) string {
+	base := t.UTC().Format("20060102-150405")
+	id := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(Dir, id)); os.IsNotExist(err) {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,37 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneAuto(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, auto := range []bool{true, false, true, true, true} {
+		m := &Manifest{ID: string(rune('a' + i)), Created: base.Add(time.Duration(i) * time.Hour), Auto: auto}
+		if err := os.Mkdir(filepath.Join(dir, m.ID), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeManifest(filepath.Join(dir, m.ID), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruneAuto(dir, 2)
+
+	manifests, err := list(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids string
+	for _, m := range manifests {
+		ids += m.ID
+	}
+	// The manual snapshot stays however old it is
+	if ids != "bde" {
+		t.Errorf("snapshots after pruning = %q, want %q", ids, "bde")
+	}
+}
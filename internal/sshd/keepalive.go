@@ -0,0 +1,131 @@
+// Package sshd manages the sshd settings dnstm needs for its SSH backends.
+//
+// dnstm does not own sshd_config. Settings go to a drop-in that applies only
+// to connections arriving on a backend's address, which are the ones coming
+// through the tunnels.
+package sshd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// DropInPath is the sshd drop-in dnstm manages.
+var DropInPath = "/etc/ssh/sshd_config.d/dnstm-keepalive.conf"
+
+// MainConfigPath is the sshd configuration that has to include DropInPath.
+var MainConfigPath = "/etc/ssh/sshd_config"
+
+// ErrNoInclude is returned when sshd does not read the drop-in directory.
+var ErrNoInclude = errors.New("sshd_config does not include /etc/ssh/sshd_config.d")
+
+var includeRegex = regexp.MustCompile(`(?mi)^\s*Include\s+.*sshd_config\.d/`)
+
+// Render returns the drop-in for the keepalive settings of the SSH backends
+// in cfg, or "" if none sets any.
+func Render(cfg *config.Config) string {
+	var b strings.Builder
+	for _, backend := range cfg.Backends {
+		k := backend.Keepalive
+		if backend.Type != config.BackendSSH || k == nil || (k.Idle == "" && k.Count == 0) {
+			continue
+		}
+		host, port, err := net.SplitHostPort(backend.Address)
+		if err != nil {
+			continue
+		}
+
+		criteria := "LocalPort " + port
+		if net.ParseIP(host) != nil {
+			criteria = "LocalAddress " + host + " " + criteria
+		}
+		fmt.Fprintf(&b, "\n# backend %s\n", backend.Tag)
+		fmt.Fprintf(&b, "Match %s\n", criteria)
+		if d := k.IdleDuration(); d > 0 {
+			fmt.Fprintf(&b, "\tClientAliveInterval %d\n", int(d.Seconds()))
+		}
+		if k.Count > 0 {
+			fmt.Fprintf(&b, "\tClientAliveCountMax %d\n", k.Count)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	// Included files are read in place, so reset the match for what follows
+	return "# Managed by dnstm; changes are overwritten by 'dnstm backend keepalive'.\n" +
+		b.String() + "\nMatch all\n"
+}
+
+// IsInstalled reports whether the drop-in is in place.
+func IsInstalled() bool {
+	_, err := os.Stat(DropInPath)
+	return err == nil
+}
+
+// IncludesDropIns reports whether sshd reads the drop-in directory.
+func IncludesDropIns() bool {
+	data, err := os.ReadFile(MainConfigPath)
+	return err == nil && includeRegex.Match(data)
+}
+
+// Sync writes the keepalive drop-in for cfg, or removes it when no SSH
+// backend has keepalive settings, and reloads sshd. A drop-in sshd rejects
+// is removed again.
+func Sync(cfg *config.Config) error {
+	content := Render(cfg)
+	if content == "" {
+		return Remove()
+	}
+	if !IncludesDropIns() {
+		return ErrNoInclude
+	}
+
+	if data, err := os.ReadFile(DropInPath); err == nil && string(data) == content {
+		return nil
+	}
+	if err := os.WriteFile(DropInPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", DropInPath, err)
+	}
+	if output, err := exec.Command(sshdBinary(), "-t").CombinedOutput(); err != nil {
+		os.Remove(DropInPath)
+		return fmt.Errorf("sshd rejected the keepalive settings: %s", strings.TrimSpace(string(output)))
+	}
+	return reload()
+}
+
+// Remove deletes the drop-in and reloads sshd.
+func Remove() error {
+	if !IsInstalled() {
+		return nil
+	}
+	if err := os.Remove(DropInPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", DropInPath, err)
+	}
+	return reload()
+}
+
+func sshdBinary() string {
+	if path, err := exec.LookPath("sshd"); err == nil {
+		return path
+	}
+	return "/usr/sbin/sshd"
+}
+
+// reload reloads sshd, whose unit is ssh on Debian and sshd elsewhere.
+func reload() error {
+	var output []byte
+	var err error
+	for _, unit := range []string{"ssh", "sshd"} {
+		if output, err = exec.Command("systemctl", "reload", unit).CombinedOutput(); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to reload sshd: %s: %w", strings.TrimSpace(string(output)), err)
+}
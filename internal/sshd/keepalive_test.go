@@ -0,0 +1,57 @@
+package sshd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+func TestRender(t *testing.T) {
+	cfg := &config.Config{
+		Backends: []config.BackendConfig{
+			{Tag: "ssh", Type: config.BackendSSH, Address: "127.0.0.1:22", Keepalive: &config.KeepaliveConfig{Idle: "30s", Count: 4}},
+			{Tag: "ssh-alt", Type: config.BackendSSH, Address: "localhost:2222", Keepalive: &config.KeepaliveConfig{Count: 2}},
+			{Tag: "ssh-plain", Type: config.BackendSSH, Address: "127.0.0.1:2022"},
+			{Tag: "socks", Type: config.BackendSOCKS, Address: "127.0.0.1:1080", Keepalive: &config.KeepaliveConfig{Idle: "60s"}},
+		},
+	}
+
+	want := `# Managed by dnstm; changes are overwritten by 'dnstm backend keepalive'.
+
+# backend ssh
+Match LocalAddress 127.0.0.1 LocalPort 22
+	ClientAliveInterval 30
+	ClientAliveCountMax 4
+
+# backend ssh-alt
+Match LocalPort 2222
+	ClientAliveCountMax 2
+
+Match all
+`
+	if got := Render(cfg); got != want {
+		t.Errorf("Render() =\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := Render(&config.Config{Backends: cfg.Backends[2:]}); got != "" {
+		t.Errorf("Render() without SSH keepalive = %q, want empty", got)
+	}
+}
+
+func TestIncludesDropIns(t *testing.T) {
+	MainConfigPath = filepath.Join(t.TempDir(), "sshd_config")
+
+	if IncludesDropIns() {
+		t.Error("missing sshd_config reported as including drop-ins")
+	}
+	os.WriteFile(MainConfigPath, []byte("Port 22\n#Include /etc/ssh/sshd_config.d/*.conf\n"), 0644)
+	if IncludesDropIns() {
+		t.Error("commented Include reported as including drop-ins")
+	}
+	os.WriteFile(MainConfigPath, []byte("Include /etc/ssh/sshd_config.d/*.conf\nPort 22\n"), 0644)
+	if !IncludesDropIns() {
+		t.Error("Include not detected")
+	}
+}
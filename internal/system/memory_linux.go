@@ -0,0 +1,68 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TotalMemory returns the memory of the host in bytes.
+func TotalMemory() (uint64, error) {
+	return readMeminfo("MemTotal")
+}
+
+// AvailableMemory returns the memory that can be used without swapping,
+// in bytes.
+func AvailableMemory() (uint64, error) {
+	return readMeminfo("MemAvailable")
+}
+
+// TotalSwap returns the swap space of the host in bytes.
+func TotalSwap() (uint64, error) {
+	return readMeminfo("SwapTotal")
+}
+
+// LoadAverage returns the one minute load average.
+func LoadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	// 0.42 0.31 0.25 1/123 4567
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty /proc/loadavg")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+func readMeminfo(key string) (uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMeminfo(f, key)
+}
+
+func parseMeminfo(r io.Reader, key string) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// MemTotal:        2030408 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == key+":" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			return kb << 10, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s missing from /proc/meminfo", key)
+}
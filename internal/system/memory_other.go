@@ -0,0 +1,29 @@
+//go:build !linux
+
+package system
+
+import "errors"
+
+var errNotLinux = errors.New("system load is only known on Linux")
+
+// TotalMemory returns the memory of the host in bytes. It is only known on
+// Linux; callers skip memory checks elsewhere.
+func TotalMemory() (uint64, error) {
+	return 0, errNotLinux
+}
+
+// AvailableMemory returns the memory that can be used without swapping,
+// in bytes.
+func AvailableMemory() (uint64, error) {
+	return 0, errNotLinux
+}
+
+// TotalSwap returns the swap space of the host in bytes.
+func TotalSwap() (uint64, error) {
+	return 0, errNotLinux
+}
+
+// LoadAverage returns the one minute load average.
+func LoadAverage() (float64, error) {
+	return 0, errNotLinux
+}
@@ -0,0 +1,9 @@
+package system
+
+import "os"
+
+// fileOwner is not available on Windows, where files have ACLs rather than
+// a uid and gid.
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
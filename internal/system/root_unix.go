@@ -0,0 +1,10 @@
+//go:build !windows
+
+package system
+
+import "github.com/net2share/go-corelib/osdetect"
+
+// RequireRoot returns an error unless dnstm runs as root.
+func RequireRoot() error {
+	return osdetect.RequireRoot()
+}
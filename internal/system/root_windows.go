@@ -0,0 +1,16 @@
+package system
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// RequireRoot returns an error unless dnstm runs elevated, which managing
+// services and firewall rules needs on Windows.
+func RequireRoot() error {
+	if !windows.GetCurrentProcessToken().IsElevated() {
+		return errors.New("this command must be run as Administrator")
+	}
+	return nil
+}
@@ -6,13 +6,16 @@ import (
 	"os/exec"
 	"os/user"
 	"strconv"
-	"syscall"
 )
 
 const (
 	// DnstmUser is the shared system user for all dnstm services.
 	DnstmUser = "dnstm"
 
+	// SocksUser runs microsocks alone, so the egress rules match the
+	// connections of the proxy and nothing else.
+	SocksUser = "dnstm-socks"
+
 )
 
 // CreateSystemUser creates a system user with no home directory and nologin shell.
@@ -21,12 +24,7 @@ func CreateSystemUser(username string) error {
 		return nil
 	}
 
-	cmd := exec.Command("useradd",
-		"--system",
-		"--no-create-home",
-		"--shell", "/usr/sbin/nologin",
-		username,
-	)
+	cmd := useraddCommand(username)
 
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to create user: %s: %w", string(output), err)
@@ -47,7 +45,26 @@ func RemoveSystemUser(username string) {
 		return
 	}
 
-	exec.Command("userdel", username).Run()
+	userdelCommand(username).Run()
+}
+
+// ExpireUser expires a user account so it can no longer log in, with a
+// password or a key, and ends its running sessions.
+func ExpireUser(username string) error {
+	if output, err := expireCommand(username, true).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to expire user: %s: %w", string(output), err)
+	}
+	// pkill exits 1 when the user has no processes
+	exec.Command("pkill", "-KILL", "-u", username).Run()
+	return nil
+}
+
+// UnexpireUser removes the expiry date set by ExpireUser.
+func UnexpireUser(username string) error {
+	if output, err := expireCommand(username, false).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to unexpire user: %s: %w", string(output), err)
+	}
+	return nil
 }
 
 // CreateDnstmUser creates the shared dnstm system user.
@@ -136,7 +153,7 @@ func CanDnstmUserReadFile(path string) (bool, error) {
 	}
 
 	// Get file owner info
-	stat, ok := info.Sys().(*syscall.Stat_t)
+	ownerUID, ownerGID, ok := fileOwner(info)
 	if !ok {
 		return false, fmt.Errorf("failed to get file stat")
 	}
@@ -146,15 +163,30 @@ func CanDnstmUserReadFile(path string) (bool, error) {
 	mode := info.Mode()
 
 	// Check if dnstm user owns the file
-	if int(stat.Uid) == uid {
+	if ownerUID == uid {
 		return mode&0400 != 0, nil // Owner read permission
 	}
 
 	// Check if dnstm group owns the file
-	if int(stat.Gid) == gid {
+	if ownerGID == gid {
 		return mode&0040 != 0, nil // Group read permission
 	}
 
 	// Check world read permission
 	return mode&0004 != 0, nil
 }
+
+// IsOwnedByDnstm reports whether the dnstm user owns a file. It is true
+// where files have no owner uid, as on Windows.
+func IsOwnedByDnstm(info os.FileInfo) (bool, error) {
+	ownerUID, _, ok := fileOwner(info)
+	if !ok {
+		return true, nil
+	}
+	u, err := user.Lookup(DnstmUser)
+	if err != nil {
+		return false, fmt.Errorf("user %s not found: %w", DnstmUser, err)
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	return ownerUID == uid, nil
+}
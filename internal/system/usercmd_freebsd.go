@@ -0,0 +1,26 @@
+package system
+
+import "os/exec"
+
+// useraddCommand returns the command creating a system user.
+func useraddCommand(username string) *exec.Cmd {
+	return exec.Command("pw", "useradd", "-n", username,
+		"-c", "dnstm service user",
+		"-d", "/nonexistent",
+		"-s", "/usr/sbin/nologin",
+	)
+}
+
+// userdelCommand returns the command removing a user.
+func userdelCommand(username string) *exec.Cmd {
+	return exec.Command("pw", "userdel", "-n", username)
+}
+
+// expireCommand returns the command setting or clearing a user's expiry.
+// pw takes a date in the past to expire an account and 0 to clear it.
+func expireCommand(username string, expire bool) *exec.Cmd {
+	if expire {
+		return exec.Command("pw", "usermod", "-n", username, "-e", "02-Jan-1970")
+	}
+	return exec.Command("pw", "usermod", "-n", username, "-e", "0")
+}
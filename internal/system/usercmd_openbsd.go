@@ -0,0 +1,27 @@
+package system
+
+import "os/exec"
+
+// useraddCommand returns the command creating a system user.
+func useraddCommand(username string) *exec.Cmd {
+	return exec.Command("useradd",
+		"-c", "dnstm service user",
+		"-d", "/nonexistent",
+		"-s", "/sbin/nologin",
+		username,
+	)
+}
+
+// userdelCommand returns the command removing a user.
+func userdelCommand(username string) *exec.Cmd {
+	return exec.Command("userdel", username)
+}
+
+// expireCommand returns the command setting or clearing a user's expiry.
+// usermod takes a date in the past to expire an account and 0 to clear it.
+func expireCommand(username string, expire bool) *exec.Cmd {
+	if expire {
+		return exec.Command("usermod", "-e", "1", username)
+	}
+	return exec.Command("usermod", "-e", "0", username)
+}
@@ -0,0 +1,50 @@
+//go:build !freebsd && !openbsd
+
+package system
+
+import "os/exec"
+
+// Alpine ships BusyBox adduser, deluser and passwd instead of the shadow
+// tools; they are used when useradd is not installed.
+
+// hasShadowTools reports whether useradd and friends are installed.
+func hasShadowTools() bool {
+	_, err := exec.LookPath("useradd")
+	return err == nil
+}
+
+// useraddCommand returns the command creating a system user.
+func useraddCommand(username string) *exec.Cmd {
+	if !hasShadowTools() {
+		return exec.Command("adduser", "-S", "-D", "-H", "-s", "/sbin/nologin", username)
+	}
+	return exec.Command("useradd",
+		"--system",
+		"--no-create-home",
+		"--shell", "/usr/sbin/nologin",
+		username,
+	)
+}
+
+// userdelCommand returns the command removing a user.
+func userdelCommand(username string) *exec.Cmd {
+	if !hasShadowTools() {
+		return exec.Command("deluser", username)
+	}
+	return exec.Command("userdel", username)
+}
+
+// expireCommand returns the command setting or clearing a user's expiry.
+// BusyBox has no account expiry, so the password is locked instead.
+func expireCommand(username string, expire bool) *exec.Cmd {
+	if !hasShadowTools() {
+		if expire {
+			return exec.Command("passwd", "-l", username)
+		}
+		return exec.Command("passwd", "-u", username)
+	}
+	if expire {
+		return exec.Command("chage", "-E", "0", username)
+	}
+	return exec.Command("chage", "-E", "-1", username)
+}
@@ -0,0 +1,169 @@
+// Package templates renders the service units and server command lines
+// dnstm generates from text/template templates.
+//
+// The defaults are embedded in the binary. A file of the same name in Dir
+// replaces a default, so units can be customized beyond what the config
+// offers; "dnstm templates export" writes the defaults there to start from.
+// Templates quote the arguments of command lines with the quote function.
+package templates
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+//go:embed *.tmpl
+var defaults embed.FS
+
+// Dir holds templates that replace the defaults of the same name.
+var Dir = filepath.Join(paths.ConfigDir, "templates")
+
+// Template names.
+const (
+	Unit       = "unit.service.tmpl" // systemd unit of every dnstm service
+	DNSTT      = "dnstt.tmpl"        // dnstt-server command line
+	Slipstream = "slipstream.tmpl"   // slipstream-server command line
+	SSServer   = "ssserver.tmpl"     // ssserver command line of Slipstream with Shadowsocks
+	VayDNS     = "vaydns.tmpl"       // vaydns-server command line
+	Chisel     = "chisel.tmpl"       // chisel server command line
+	Watchdog   = "watchdog.tmpl"     // "dnstm watchdog" wrapped around a server command line
+)
+
+// funcs are the functions templates can call besides the builtins.
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"quote": cmdline.Quote,
+}
+
+// Names returns the names of all templates, sorted.
+func Names() []string {
+	entries, _ := defaults.ReadDir(".")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Default returns the embedded default of a template.
+func Default(name string) (string, error) {
+	data, err := defaults.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("unknown template '%s'", name)
+	}
+	return string(data), nil
+}
+
+// Path returns the file in Dir that replaces a default.
+func Path(name string) string {
+	return filepath.Join(Dir, name)
+}
+
+// IsOverridden reports whether a file in Dir replaces the default.
+func IsOverridden(name string) bool {
+	_, err := os.Stat(Path(name))
+	return err == nil
+}
+
+// Source returns the text of a template in effect: the file in Dir, or
+// else the default.
+func Source(name string) (string, error) {
+	def, err := Default(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(Path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return def, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+	return string(data), nil
+}
+
+// Check parses the template in effect, so a broken file in Dir shows up
+// before a service is generated from it.
+func Check(name string) error {
+	text, err := Source(name)
+	if err != nil {
+		return err
+	}
+	_, err = parse(name, text)
+	return err
+}
+
+// Export writes the default of a template to Dir, to customize from. An
+// existing file is only replaced with force; it reports whether it wrote.
+func Export(name string, force bool) (bool, error) {
+	def, err := Default(name)
+	if err != nil {
+		return false, err
+	}
+	if !force && IsOverridden(name) {
+		return false, nil
+	}
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", Dir, err)
+	}
+	if err := os.WriteFile(Path(name), []byte(def), 0644); err != nil {
+		return false, fmt.Errorf("failed to write template: %w", err)
+	}
+	return true, nil
+}
+
+// Render renders a template with data.
+func Render(name string, data any) (string, error) {
+	text, err := Source(name)
+	if err != nil {
+		return "", err
+	}
+	return execute(name, text, data)
+}
+
+// RenderCommand renders a command line template. Templates put arguments
+// on lines of their own for readability; the lines are joined with spaces.
+func RenderCommand(name string, data any) (string, error) {
+	out, err := Render(name, data)
+	if err != nil {
+		return "", err
+	}
+	var args []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			args = append(args, line)
+		}
+	}
+	return strings.Join(args, " "), nil
+}
+
+func parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func execute(name, text string, data any) (string, error) {
+	tmpl, err := parse(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("template %s: %w", name, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,93 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultsParse(t *testing.T) {
+	names := Names()
+	if len(names) != 7 {
+		t.Errorf("Names() = %v", names)
+	}
+	for _, name := range names {
+		text, err := Default(name)
+		if err != nil {
+			t.Fatalf("Default(%s) error = %v", name, err)
+		}
+		if _, err := parse(name, text); err != nil {
+			t.Errorf("parse(%s) error = %v", name, err)
+		}
+	}
+}
+
+func TestRenderCommand(t *testing.T) {
+	old := Dir
+	Dir = t.TempDir()
+	defer func() { Dir = old }()
+
+	data := struct {
+		Binary, Config string
+		Tunnel         any
+	}{Binary: "/usr/local/bin/ssserver", Config: "/etc/dnstm/tunnels/ss/config.json"}
+	got, err := RenderCommand(SSServer, data)
+	if err != nil {
+		t.Fatalf("RenderCommand() error = %v", err)
+	}
+	if want := "/usr/local/bin/ssserver -c /etc/dnstm/tunnels/ss/config.json"; got != want {
+		t.Errorf("RenderCommand() = %q, want %q", got, want)
+	}
+
+	// A file in Dir replaces the default
+	if err := os.WriteFile(Path(SSServer), []byte("{{.Binary}}\n  -v\n\n  -c {{.Config}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = RenderCommand(SSServer, data)
+	if err != nil {
+		t.Fatalf("RenderCommand() error = %v", err)
+	}
+	if want := "/usr/local/bin/ssserver -v -c /etc/dnstm/tunnels/ss/config.json"; got != want {
+		t.Errorf("RenderCommand() with override = %q, want %q", got, want)
+	}
+
+	if err := os.WriteFile(Path(SSServer), []byte("{{.Binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(SSServer); err == nil || !strings.Contains(err.Error(), SSServer) {
+		t.Errorf("Check() of a broken override = %v", err)
+	}
+	if _, err := RenderCommand(SSServer, struct{ Binary string }{}); err == nil {
+		t.Error("RenderCommand() of a broken override succeeded")
+	}
+}
+
+func TestExport(t *testing.T) {
+	old := Dir
+	Dir = filepath.Join(t.TempDir(), "templates")
+	defer func() { Dir = old }()
+
+	if written, err := Export(Unit, false); err != nil || !written {
+		t.Fatalf("Export() = %v, %v", written, err)
+	}
+	if err := os.WriteFile(Path(Unit), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if written, err := Export(Unit, false); err != nil || written {
+		t.Errorf("Export() over an edited file = %v, %v", written, err)
+	}
+	if text, _ := Source(Unit); text != "edited" {
+		t.Errorf("Source() = %q", text)
+	}
+	if written, err := Export(Unit, true); err != nil || !written {
+		t.Errorf("Export(force) = %v, %v", written, err)
+	}
+	def, _ := Default(Unit)
+	if text, _ := Source(Unit); text != def {
+		t.Error("Export(force) did not restore the default")
+	}
+	if _, err := Export("nope.tmpl", false); err == nil {
+		t.Error("Export() of an unknown template succeeded")
+	}
+}
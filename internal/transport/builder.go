@@ -1,21 +1,29 @@
 package transport
 
 import (
-	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/net2share/dnstm/internal/binary"
 	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/proxy"
+	"github.com/net2share/dnstm/internal/quarantine"
 	"github.com/net2share/dnstm/internal/service"
 	"github.com/net2share/dnstm/internal/system"
+	"github.com/net2share/dnstm/internal/templates"
 )
 
-const (
-	ConfigDir = "/etc/dnstm"
+var (
+	ConfigDir = paths.ConfigDir
+
+	// DnstmBinaryPath is the installed dnstm binary, used for wrapper commands.
+	DnstmBinaryPath = paths.Bin("dnstm")
 )
 
 // Binary path getters using the binary manager.
@@ -67,6 +75,20 @@ type BuildOptions struct {
 	BindPort int    // 53 for single mode, cfg.Port for multi mode
 }
 
+// execData is what the command line templates of the transports are
+// rendered with (see the templates package).
+type execData struct {
+	Binary   string
+	Tunnel   *config.TunnelConfig
+	BindHost string
+	BindPort int
+	Target   string // backend address
+	MTU      int
+	Cert     string
+	Key      string
+	Config   string // transport config file
+}
+
 // Builder builds command lines for transport instances.
 type Builder struct{}
 
@@ -77,15 +99,68 @@ func NewBuilder() *Builder {
 
 // TunnelBuildResult contains the result of building a tunnel service.
 type TunnelBuildResult struct {
-	ExecStart    string
-	ConfigDir    string
-	ReadPaths    []string
-	WritePaths   []string
-	BindToPort53 bool
+	ExecStart      string
+	ConfigDir      string
+	ReadPaths      []string
+	WritePaths     []string
+	BindPrivileged bool // Binds a port below 1024
+	WatchdogSec    int
+	RestartLimit   int // Crash-loop limit: restarts allowed within RestartWindow; negative for none
+	RestartWindow  int // Crash-loop window in seconds
+	Wants          []string
+	After          []string
+	Files          []ConfigFile // written to ConfigDir by CreateService
+	Overrides      *service.Overrides
+}
+
+// ConfigFile is a file a transport reads its configuration from.
+type ConfigFile struct {
+	Path string
+	Data []byte
+	Mode os.FileMode
 }
 
-// CreateService creates a systemd service for the tunnel.
+// CreateService writes the config files and creates a systemd service for
+// the tunnel.
 func (r *TunnelBuildResult) CreateService(serviceName string) error {
+	if err := r.WriteFiles(); err != nil {
+		return err
+	}
+	cfg := r.ServiceConfig(serviceName)
+	if r.RestartLimit > 0 {
+		if err := quarantine.EnsureHookUnit(DnstmBinaryPath); err != nil {
+			return err
+		}
+	}
+	return service.CreateGenericService(cfg)
+}
+
+// WriteFiles creates the tunnel config directory and writes the config
+// files into it, owned by the dnstm user.
+func (r *TunnelBuildResult) WriteFiles() error {
+	if err := os.MkdirAll(r.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := system.ChownDirToDnstm(r.ConfigDir); err != nil {
+		return fmt.Errorf("failed to set config directory ownership: %w", err)
+	}
+	for _, f := range r.Files {
+		if err := os.WriteFile(f.Path, f.Data, f.Mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Path, err)
+		}
+		// WriteFile keeps the mode of an existing file
+		if err := os.Chmod(f.Path, f.Mode); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", f.Path, err)
+		}
+		if err := system.ChownToDnstm(f.Path); err != nil {
+			return fmt.Errorf("failed to set ownership of %s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// ServiceConfig returns the service configuration of the tunnel.
+func (r *TunnelBuildResult) ServiceConfig(serviceName string) *service.ServiceConfig {
 	cfg := &service.ServiceConfig{
 		Name:             serviceName,
 		Description:      fmt.Sprintf("dnstm tunnel: %s", serviceName),
@@ -94,12 +169,22 @@ func (r *TunnelBuildResult) CreateService(serviceName string) error {
 		ExecStart:        r.ExecStart,
 		ReadOnlyPaths:    r.ReadPaths,
 		ReadWritePaths:   r.WritePaths,
-		BindToPrivileged: r.BindToPort53,
-	}
-	return service.CreateGenericService(cfg)
+		BindToPrivileged: r.BindPrivileged,
+		WatchdogSec:      r.WatchdogSec,
+		Wants:            r.Wants,
+		After:            r.After,
+		Overrides:        r.Overrides,
+	}
+	cfg.RestartLimit = r.RestartLimit
+	if r.RestartLimit > 0 {
+		cfg.RestartWindow = r.RestartWindow
+		cfg.OnFailure = quarantine.OnFailure
+	}
+	return cfg
 }
 
 // BuildTunnelService builds the service configuration for a tunnel with the new config types.
+// It writes nothing; CreateService does.
 // This bridges between the new config types and the existing builder logic.
 func (b *Builder) BuildTunnelService(tunnel *config.TunnelConfig, backend *config.BackendConfig, opts *BuildOptions) (*TunnelBuildResult, error) {
 	if opts == nil {
@@ -110,214 +195,114 @@ func (b *Builder) BuildTunnelService(tunnel *config.TunnelConfig, backend *confi
 	}
 
 	result := &TunnelBuildResult{
-		BindToPort53: opts.BindPort == 53,
+		BindPrivileged: opts.BindPort < 1024,
 	}
 
-	// Create tunnel config directory
-	configDir := filepath.Join(ConfigDir, "tunnels", tunnel.Tag)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
-	}
-	if err := system.ChownDirToDnstm(configDir); err != nil {
-		return nil, fmt.Errorf("failed to set config directory ownership: %w", err)
-	}
-	result.ConfigDir = configDir
-
-	// Get target address from backend
-	targetAddr := backend.Address
-	if targetAddr == "" {
-		// Default addresses based on backend type
-		switch backend.Type {
-		case config.BackendSOCKS:
-			targetAddr = "127.0.0.1:1080"
-		case config.BackendSSH:
-			targetAddr = "127.0.0.1:22"
-		}
-	}
+	// Config files are only written by CreateService, so the result can
+	// also be compared with what is installed
+	result.ConfigDir = filepath.Join(paths.TunnelsDir, tunnel.Tag)
 
-	switch tunnel.Transport {
-	case config.TransportSlipstream:
-		return b.buildSlipstreamTunnel(tunnel, backend, targetAddr, opts, result)
-	case config.TransportDNSTT:
-		return b.buildDNSTTTunnel(tunnel, backend, targetAddr, opts, result)
-	case config.TransportVayDNS:
-		return b.buildVayDNSTunnel(tunnel, backend, targetAddr, opts, result)
-	default:
-		return nil, fmt.Errorf("unknown transport type: %s", tunnel.Transport)
+	// Get target address from backend; with a front the transport forwards
+	// to it, which passes non-probe streams on within the session limit
+	targetAddr := backend.TargetAddress()
+	if front := health.FrontAddr(tunnel); front != "" {
+		targetAddr = front
 	}
-}
 
-// buildSlipstreamTunnel builds a Slipstream-based tunnel service.
-func (b *Builder) buildSlipstreamTunnel(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) (*TunnelBuildResult, error) {
-	// Read cert/key paths from tunnel config (already set before builder is called)
-	if tunnel.Slipstream == nil || tunnel.Slipstream.Cert == "" || tunnel.Slipstream.Key == "" {
-		return nil, fmt.Errorf("slipstream cert/key paths not set for tunnel %s", tunnel.Tag)
+	provider := GetProvider(tunnel.Transport)
+	if provider == nil {
+		return nil, fmt.Errorf("unknown transport type: %s", tunnel.Transport)
 	}
-
-	certPath := tunnel.Slipstream.Cert
-	keyPath := tunnel.Slipstream.Key
-
-	result.ReadPaths = append(result.ReadPaths, certPath, keyPath)
-
-	// Slipstream + Shadowsocks uses ssserver with slipstream as plugin (SIP003)
-	if backend.Type == config.BackendShadowsocks {
-		return b.buildSlipstreamShadowsocksTunnel(tunnel, backend, certPath, keyPath, opts, result)
+	if err := provider.Build(tunnel, backend, targetAddr, opts, result); err != nil {
+		return nil, err
 	}
 
-	// Slipstream standalone mode (SOCKS, SSH, or custom target)
-	args := []string{
-		"--dns-listen-host", opts.BindHost,
-		"--domain", tunnel.Domain,
-		"--dns-listen-port", fmt.Sprintf("%d", opts.BindPort),
-		"--target-address", targetAddr,
-		"--cert", certPath,
-		"--key", keyPath,
+	if tunnel.Watchdog != nil {
+		if err := wrapWatchdog(tunnel, opts, result); err != nil {
+			return nil, err
+		}
 	}
 
-	result.ExecStart = fmt.Sprintf("%s %s", SlipstreamBinaryPath(), strings.Join(args, " "))
-	return result, nil
-}
-
-// buildSlipstreamShadowsocksTunnel builds a Slipstream+Shadowsocks tunnel using SIP003 plugin mode.
-func (b *Builder) buildSlipstreamShadowsocksTunnel(tunnel *config.TunnelConfig, backend *config.BackendConfig, certPath, keyPath string, opts *BuildOptions, result *TunnelBuildResult) (*TunnelBuildResult, error) {
-	if backend.Shadowsocks == nil {
-		return nil, fmt.Errorf("shadowsocks backend missing configuration")
+	result.Wants, result.After = backendUnits(backend)
+	if tunnel.Health != nil || tunnel.Sessions != nil {
+		unit := health.ServiceName + ".service"
+		result.Wants = append(result.Wants, unit)
+		result.After = append(result.After, unit)
 	}
-
-	method := backend.Shadowsocks.Method
-	if method == "" {
-		method = "aes-256-gcm"
+	if u := tunnel.Unit; u != nil {
+		result.Overrides = &service.Overrides{
+			Environment:  u.Environment,
+			ExecStartPre: u.ExecStartPre,
+			After:        u.After,
+		}
 	}
 
-	// Build plugin options
-	pluginOpts := fmt.Sprintf("domain=%s;dns-listen-host=%s;dns-listen-port=%d;cert=%s;key=%s",
-		tunnel.Domain, opts.BindHost, opts.BindPort, certPath, keyPath)
-
-	// Write Shadowsocks config file
-	ssConfig := map[string]interface{}{
-		"server":      opts.BindHost,
-		"server_port": opts.BindPort,
-		"password":    backend.Shadowsocks.Password,
-		"method":      method,
-		"mode":        "tcp_only",
-		"plugin":      SlipstreamBinaryPath(),
-		"plugin_opts": pluginOpts,
-		"plugin_mode": "tcp_only",
+	// The rescue tunnel is never quarantined: systemd keeps restarting it
+	if tunnel.Rescue {
+		result.RestartLimit = -1
+		return result, nil
 	}
 
-	configPath := filepath.Join(result.ConfigDir, "config.json")
-	data, err := json.MarshalIndent(ssConfig, "", "    ")
+	restarts, window, err := tunnel.CrashLoop.Limits()
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write config: %w", err)
-	}
-	if err := system.ChownToDnstm(configPath); err != nil {
-		return nil, fmt.Errorf("failed to set config file ownership: %w", err)
+		return nil, err
 	}
-
-	result.ExecStart = fmt.Sprintf("%s -c %s", SSServerBinaryPath(), configPath)
-	result.ReadPaths = append(result.ReadPaths, configPath)
+	result.RestartLimit = restarts
+	result.RestartWindow = int(window.Seconds())
 
 	return result, nil
 }
 
-// buildDNSTTTunnel builds a DNSTT-based tunnel service.
-func (b *Builder) buildDNSTTTunnel(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) (*TunnelBuildResult, error) {
-	// DNSTT doesn't support Shadowsocks
-	if backend.Type == config.BackendShadowsocks {
-		return nil, fmt.Errorf("DNSTT transport does not support Shadowsocks backend")
-	}
-
-	// Read key path from tunnel config (already set before builder is called)
-	if tunnel.DNSTT == nil || tunnel.DNSTT.PrivateKey == "" {
-		return nil, fmt.Errorf("dnstt private key path not set for tunnel %s", tunnel.Tag)
-	}
-
-	privKeyPath := tunnel.DNSTT.PrivateKey
-	result.ReadPaths = append(result.ReadPaths, privKeyPath)
-
-	mtu := "1232"
-	if tunnel.DNSTT.MTU > 0 {
-		mtu = fmt.Sprintf("%d", tunnel.DNSTT.MTU)
-	}
-
-	// Build dnstt-server command
-	args := []string{
-		"-udp", fmt.Sprintf("%s:%d", opts.BindHost, opts.BindPort),
-		"-privkey-file", privKeyPath,
-		"-mtu", mtu,
-		tunnel.Domain,
-		targetAddr,
-	}
-
-	result.ExecStart = fmt.Sprintf("%s %s", DNSTTBinaryPath(), strings.Join(args, " "))
-	return result, nil
+// backendUnits returns the systemd units a tunnel depends on for its backend.
+// The SOCKS proxy is managed by dnstm, so tunnels want it: it is started with
+// them, but restarting it for new credentials or egress rules does not
+// restart every tunnel, and a tunnel outlives a stopped proxy. The SSH daemon
+// is only ordered before the tunnel since its unit name differs between
+// distributions.
+func backendUnits(backend *config.BackendConfig) (wants, after []string) {
+	switch backend.Type {
+	case config.BackendSOCKS:
+		unit := proxy.MicrosocksServiceName + ".service"
+		return []string{unit}, []string{unit}
+	case config.BackendSSH:
+		return nil, []string{"ssh.service", "sshd.service"}
+	}
+	return nil, nil
 }
 
-// buildVayDNSTunnel builds a VayDNS-based tunnel service.
-func (b *Builder) buildVayDNSTunnel(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) (*TunnelBuildResult, error) {
-	if backend.Type == config.BackendShadowsocks {
-		return nil, fmt.Errorf("VayDNS transport does not support Shadowsocks backend")
-	}
-
-	if tunnel.VayDNS == nil || tunnel.VayDNS.PrivateKey == "" {
-		return nil, fmt.Errorf("vaydns private key path not set for tunnel %s", tunnel.Tag)
-	}
-
-	privKeyPath := tunnel.VayDNS.PrivateKey
-	result.ReadPaths = append(result.ReadPaths, privKeyPath)
-
-	mtu := "1232"
-	if tunnel.VayDNS.MTU > 0 {
-		mtu = fmt.Sprintf("%d", tunnel.VayDNS.MTU)
-	}
-
-	args := []string{
-		"-udp", fmt.Sprintf("%s:%d", opts.BindHost, opts.BindPort),
-		"-privkey-file", privKeyPath,
-		"-mtu", mtu,
-		"-domain", tunnel.Domain,
-		"-upstream", targetAddr,
-		"-idle-timeout", tunnel.VayDNS.ResolvedVayDNSIdleTimeout(),
-		"-keepalive", tunnel.VayDNS.ResolvedVayDNSKeepAlive(),
-	}
-
-	if tunnel.VayDNS.Fallback != "" {
-		args = append(args, "-fallback", tunnel.VayDNS.Fallback)
-	}
-	if tunnel.VayDNS.DnsttCompat {
-		args = append(args, "-dnstt-compat")
-	}
-	if n := tunnel.VayDNS.VayDNSClientIDSizeForFlag(); n > 0 {
-		args = append(args, "-clientid-size", strconv.Itoa(n))
-	}
-	if tunnel.VayDNS.QueueSize > 0 && tunnel.VayDNS.QueueSize != 512 {
-		args = append(args, "-queue-size", strconv.Itoa(tunnel.VayDNS.QueueSize))
-	}
-	if tunnel.VayDNS.KCPWindowSize > 0 {
-		args = append(args, "-kcp-window-size", strconv.Itoa(tunnel.VayDNS.KCPWindowSize))
-	}
-	if tunnel.VayDNS.QueueOverflow != "" && tunnel.VayDNS.QueueOverflow != "drop" {
-		args = append(args, "-queue-overflow", tunnel.VayDNS.QueueOverflow)
-	}
-	if tunnel.VayDNS.LogLevel != "" && tunnel.VayDNS.LogLevel != "info" {
-		args = append(args, "-log-level", tunnel.VayDNS.LogLevel)
-	}
-	if tunnel.VayDNS.RecordType != "" && tunnel.VayDNS.RecordType != "txt" {
-		args = append(args, "-record-type", tunnel.VayDNS.RecordType)
+// wrapWatchdog runs the transport under "dnstm watchdog", which pings the
+// systemd watchdog while the transport answers DNS queries on its bind address.
+func wrapWatchdog(tunnel *config.TunnelConfig, opts *BuildOptions, result *TunnelBuildResult) error {
+	timeout, err := tunnel.Watchdog.TimeoutDuration()
+	if err != nil {
+		return err
+	}
+
+	execStart, err := templates.RenderCommand(templates.Watchdog, struct {
+		Binary  string
+		Tunnel  *config.TunnelConfig
+		Addr    string
+		Timeout time.Duration
+		Command string
+	}{
+		Binary:  DnstmBinaryPath,
+		Tunnel:  tunnel,
+		Addr:    net.JoinHostPort(opts.BindHost, strconv.Itoa(opts.BindPort)),
+		Timeout: timeout,
+		Command: result.ExecStart,
+	})
+	if err != nil {
+		return err
 	}
-
-	result.ExecStart = fmt.Sprintf("%s %s", VayDNSBinaryPath(), strings.Join(args, " "))
-	return result, nil
+	result.ExecStart = execStart
+	result.WatchdogSec = int(timeout.Seconds())
+	return nil
 }
 
 // RegenerateTunnelService regenerates a tunnel's systemd service with new bind options.
 // This is used when switching active tunnels in single mode.
 func (b *Builder) RegenerateTunnelService(tunnel *config.TunnelConfig, backend *config.BackendConfig, opts *BuildOptions) error {
-	serviceName := fmt.Sprintf("dnstm-%s", tunnel.Tag)
+	serviceName := paths.Service(tunnel.Tag)
 
 	// Stop the service if it's running
 	if service.IsServiceActive(serviceName) {
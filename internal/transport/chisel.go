@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+	"github.com/net2share/dnstm/internal/templates"
+)
+
+func init() {
+	Register(&chiselProvider{Binaries: Binaries{binary.BinaryChiselServer}})
+}
+
+// chiselProvider runs chisel tunnels: a websocket over TLS fallback that
+// clients can use when DNS tunneling is throttled.
+type chiselProvider struct {
+	Binaries
+}
+
+func (p *chiselProvider) Type() config.TransportType { return config.TransportChisel }
+
+func (p *chiselProvider) DisplayName() string {
+	return config.GetTransportTypeDisplayName(config.TransportChisel)
+}
+
+// ChiselBinaryPath returns the path to chisel.
+func ChiselBinaryPath() string {
+	path, _ := getBinManager().GetPath(binary.BinaryChiselServer)
+	return path
+}
+
+// GenerateChiselAuth returns random "user:pass" credentials for a chisel tunnel.
+func GenerateChiselAuth() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate chisel credentials: %w", err)
+	}
+	return "dnstm:" + hex.EncodeToString(b), nil
+}
+
+// Build builds a chisel server. Clients authenticate with the tunnel's
+// credentials and may only forward to the backend address, and to the
+// health endpoint when the tunnel routes it.
+func (p *chiselProvider) Build(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) error {
+	if backend.Type == config.BackendShadowsocks {
+		return fmt.Errorf("Chisel transport does not support Shadowsocks backend")
+	}
+
+	c := tunnel.Chisel
+	if c == nil || c.Auth == "" || c.Cert == "" || c.Key == "" {
+		return fmt.Errorf("chisel auth or cert/key paths not set for tunnel %s", tunnel.Tag)
+	}
+	if !strings.Contains(c.Auth, ":") {
+		return fmt.Errorf("chisel auth must be user:pass for tunnel %s", tunnel.Tag)
+	}
+
+	// The auth file maps each user to the remotes it may open
+	remotes := []string{"^" + regexp.QuoteMeta(targetAddr) + "$"}
+	if tunnel.Health != nil {
+		remotes = append(remotes, "^"+regexp.QuoteMeta(health.Addr)+"$")
+	}
+	users := map[string][]string{c.Auth: remotes}
+	data, err := json.MarshalIndent(users, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal auth file: %w", err)
+	}
+	authPath := filepath.Join(result.ConfigDir, "users.json")
+	result.Files = append(result.Files, ConfigFile{Path: authPath, Data: data, Mode: 0600})
+
+	execStart, err := templates.RenderCommand(templates.Chisel, &execData{
+		Binary:   ChiselBinaryPath(),
+		Tunnel:   tunnel,
+		BindHost: opts.BindHost,
+		BindPort: opts.BindPort,
+		Cert:     c.Cert,
+		Key:      c.Key,
+		Config:   authPath,
+	})
+	if err != nil {
+		return err
+	}
+
+	result.ReadPaths = append(result.ReadPaths, authPath, c.Cert, c.Key)
+	result.ExecStart = execStart
+	return nil
+}
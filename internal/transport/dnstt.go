@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/templates"
+)
+
+func init() {
+	Register(&dnsttProvider{Binaries: Binaries{binary.BinaryDNSTTServer}})
+}
+
+// dnsttProvider runs DNSTT tunnels.
+type dnsttProvider struct {
+	Binaries
+}
+
+func (p *dnsttProvider) Type() config.TransportType { return config.TransportDNSTT }
+
+func (p *dnsttProvider) DisplayName() string {
+	return config.GetTransportTypeDisplayName(config.TransportDNSTT)
+}
+
+// Build builds a DNSTT-based tunnel service.
+func (p *dnsttProvider) Build(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) error {
+	// DNSTT doesn't support Shadowsocks
+	if backend.Type == config.BackendShadowsocks {
+		return fmt.Errorf("DNSTT transport does not support Shadowsocks backend")
+	}
+
+	// Read key path from tunnel config (already set before builder is called)
+	if tunnel.DNSTT == nil || tunnel.DNSTT.PrivateKey == "" {
+		return fmt.Errorf("dnstt private key path not set for tunnel %s", tunnel.Tag)
+	}
+
+	result.ReadPaths = append(result.ReadPaths, tunnel.DNSTT.PrivateKey)
+
+	mtu := 1232
+	if tunnel.DNSTT.MTU > 0 {
+		mtu = tunnel.DNSTT.MTU
+	}
+
+	execStart, err := templates.RenderCommand(templates.DNSTT, &execData{
+		Binary:   DNSTTBinaryPath(),
+		Tunnel:   tunnel,
+		BindHost: opts.BindHost,
+		BindPort: opts.BindPort,
+		Target:   targetAddr,
+		MTU:      mtu,
+	})
+	if err != nil {
+		return err
+	}
+	result.ExecStart = execStart
+	return nil
+}
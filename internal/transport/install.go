@@ -14,16 +14,11 @@ type StatusFunc func(message string)
 // EnsureTransportBinariesInstalled checks and installs required binaries for a transport type.
 // This function accepts the new config.TransportType.
 func EnsureTransportBinariesInstalled(transport config.TransportType) error {
-	switch transport {
-	case config.TransportSlipstream:
-		return EnsureSlipstreamInstalled()
-	case config.TransportDNSTT:
-		return EnsureDnsttInstalled()
-	case config.TransportVayDNS:
-		return EnsureVayDNSInstalled()
-	default:
+	p := GetProvider(transport)
+	if p == nil {
 		return nil
 	}
+	return p.Install(nil)
 }
 
 // EnsureBackendBinariesInstalled checks and installs required binaries for a backend type.
@@ -93,6 +88,11 @@ func IsSSHTunUserInstalled() bool {
 	return err == nil
 }
 
+// EnsureBinaryInstalledWithStatus installs any managed binary with status callback.
+func EnsureBinaryInstalledWithStatus(binType binary.BinaryType, statusFn StatusFunc) error {
+	return ensureBinaryInstalled(binType, string(binType), statusFn)
+}
+
 // ensureBinaryInstalled uses the binary manager to ensure a binary is available.
 func ensureBinaryInstalled(binType binary.BinaryType, displayName string, statusFn StatusFunc) error {
 	mgr := binary.NewDefaultManager()
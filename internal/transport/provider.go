@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// Provider implements one transport type. Each provider lives in its own
+// file and registers itself from init(), so adding a transport does not
+// touch the builder, installer or status code.
+type Provider interface {
+	// Type returns the transport type used in tunnel configs.
+	Type() config.TransportType
+
+	// DisplayName returns the human-readable transport name.
+	DisplayName() string
+
+	// Install ensures the transport's server binaries are available.
+	Install(statusFn StatusFunc) error
+
+	// MissingBinaries returns the transport's binaries that are not installed.
+	MissingBinaries() []string
+
+	// Build fills in result with the command line and paths for a tunnel.
+	// targetAddr is the backend address the transport forwards to.
+	Build(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) error
+}
+
+// Binaries implements Install and MissingBinaries for providers that only
+// need a set of binaries from the binary manager. Embed it in a provider.
+type Binaries []binary.BinaryType
+
+// Install installs each binary that is not present.
+func (bs Binaries) Install(statusFn StatusFunc) error {
+	for _, b := range bs {
+		if err := EnsureBinaryInstalledWithStatus(b, statusFn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MissingBinaries returns the binaries the binary manager cannot find.
+func (bs Binaries) MissingBinaries() []string {
+	mgr := binary.NewDefaultManager()
+	var missing []string
+	for _, b := range bs {
+		// Binaries without a build for this platform cannot be installed
+		if _, err := mgr.GetPath(b); err != nil && !binary.IsUnsupported(err) {
+			missing = append(missing, string(b))
+		}
+	}
+	return missing
+}
+
+var providers []Provider
+
+// Register adds a transport provider and makes its type valid in tunnel
+// configs. It panics if the type is already registered.
+func Register(p Provider) {
+	if GetProvider(p.Type()) != nil {
+		panic(fmt.Sprintf("transport: provider %q registered twice", p.Type()))
+	}
+	providers = append(providers, p)
+	config.RegisterTransportType(p.Type(), p.DisplayName())
+}
+
+// GetProvider returns the provider for a transport type, or nil if none is registered.
+func GetProvider(t config.TransportType) Provider {
+	for _, p := range providers {
+		if p.Type() == t {
+			return p
+		}
+	}
+	return nil
+}
+
+// Providers returns all registered providers in registration order.
+func Providers() []Provider {
+	return providers
+}
@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/health"
+)
+
+type fakeProvider struct {
+	Binaries
+}
+
+func (p *fakeProvider) Type() config.TransportType { return "fake" }
+
+func (p *fakeProvider) DisplayName() string { return "Fake" }
+
+func (p *fakeProvider) Build(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) error {
+	result.ExecStart = "/usr/bin/fake-server " + targetAddr
+	return nil
+}
+
+func TestProviders_BuiltinsRegistered(t *testing.T) {
+	for _, tt := range []config.TransportType{config.TransportSlipstream, config.TransportDNSTT, config.TransportVayDNS} {
+		p := GetProvider(tt)
+		if p == nil {
+			t.Fatalf("no provider registered for %s", tt)
+		}
+		if p.DisplayName() != config.GetTransportTypeDisplayName(tt) {
+			t.Errorf("%s display name = %q", tt, p.DisplayName())
+		}
+	}
+	if GetProvider("unknown") != nil {
+		t.Error("expected nil provider for unknown transport")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register(&fakeProvider{})
+
+	if GetProvider("fake") == nil {
+		t.Fatal("fake provider not registered")
+	}
+	if !config.IsKnownTransport("fake") {
+		t.Error("registered transport should be valid in config")
+	}
+	if got := config.GetTransportTypeDisplayName("fake"); got != "Fake" {
+		t.Errorf("display name = %q, want Fake", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	Register(&fakeProvider{})
+}
+
+func TestDNSTTProvider_RejectsShadowsocks(t *testing.T) {
+	tunnel := &config.TunnelConfig{Tag: "t", Transport: config.TransportDNSTT, DNSTT: &config.DNSTTConfig{PrivateKey: "/k"}}
+	backend := &config.BackendConfig{Type: config.BackendShadowsocks}
+	err := GetProvider(config.TransportDNSTT).Build(tunnel, backend, "", &BuildOptions{BindHost: "127.0.0.1", BindPort: 5310}, &TunnelBuildResult{})
+	if err == nil {
+		t.Error("expected error for shadowsocks backend")
+	}
+}
+
+func TestDNSTTProvider_QuotesArguments(t *testing.T) {
+	tunnel := &config.TunnelConfig{Tag: "t", Transport: config.TransportDNSTT, Domain: "t.example.com",
+		DNSTT: &config.DNSTTConfig{PrivateKey: "/etc/my keys/server.key"}}
+	backend := &config.BackendConfig{Type: config.BackendSOCKS}
+	result := &TunnelBuildResult{}
+	err := GetProvider(config.TransportDNSTT).Build(tunnel, backend, "127.0.0.1:1080", &BuildOptions{BindHost: "127.0.0.1", BindPort: 5310}, result)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	args := cmdline.Split(result.ExecStart)
+	want := []string{"-udp", "127.0.0.1:5310", "-privkey-file", "/etc/my keys/server.key", "-mtu", "1232", "t.example.com", "127.0.0.1:1080"}
+	if len(args) == 0 || strings.Join(args[1:], "|") != strings.Join(want, "|") {
+		t.Errorf("ExecStart %q splits into %q", result.ExecStart, args)
+	}
+}
+
+func TestPluginOptValue(t *testing.T) {
+	if got := pluginOptValue(`/etc/a;b=c\d/key.pem`); got != `/etc/a\;b\=c\\d/key.pem` {
+		t.Errorf("pluginOptValue() = %q", got)
+	}
+}
+
+func TestSlipstreamProvider_ShadowsocksPlugins(t *testing.T) {
+	tunnel := &config.TunnelConfig{Tag: "t", Transport: config.TransportSlipstream, Domain: "t.example.com",
+		Slipstream: &config.SlipstreamConfig{Cert: "/c.pem", Key: "/k.pem"}}
+	backend := &config.BackendConfig{Type: config.BackendShadowsocks, Shadowsocks: &config.ShadowsocksConfig{
+		Password: "secret",
+		Plugins:  []config.ShadowsocksPlugin{{Plugin: "v2ray-plugin", Options: "server", Port: 443}},
+	}}
+	build := func() map[string]any {
+		t.Helper()
+		result := &TunnelBuildResult{ConfigDir: t.TempDir()}
+		err := GetProvider(config.TransportSlipstream).Build(tunnel, backend, "", &BuildOptions{BindHost: "127.0.0.1", BindPort: 5310}, result)
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		if len(result.Files) != 1 {
+			t.Fatalf("got %d files, want config.json", len(result.Files))
+		}
+		var ss map[string]any
+		if err := json.Unmarshal(result.Files[0].Data, &ss); err != nil {
+			t.Fatal(err)
+		}
+		if len(backend.Shadowsocks.Plugins) > 0 && !result.BindPrivileged {
+			t.Error("port 443 needs BindPrivileged")
+		}
+		return ss
+	}
+
+	servers, _ := build()["servers"].([]any)
+	if len(servers) != 2 {
+		t.Fatalf("got %d servers, want slipstream and v2ray-plugin", len(servers))
+	}
+	extra := servers[1].(map[string]any)
+	if extra["plugin"] != "v2ray-plugin" || extra["plugin_opts"] != "server" || extra["server_port"] != float64(443) {
+		t.Errorf("plugin server = %v", extra)
+	}
+
+	// Without plugins the config keeps its single-server form
+	backend.Shadowsocks.Plugins = nil
+	if ss := build(); ss["servers"] != nil || ss["server_port"] != float64(5310) {
+		t.Errorf("config without plugins = %v", ss)
+	}
+}
+
+func TestBuildTunnelService_HealthFront(t *testing.T) {
+	tunnel := &config.TunnelConfig{Tag: "t", Transport: config.TransportDNSTT, Domain: "t.example.com", Port: 5310,
+		DNSTT: &config.DNSTTConfig{PrivateKey: "/k"}, Health: &config.HealthConfig{Port: 5311}}
+	backend := &config.BackendConfig{Type: config.BackendSSH, Address: "127.0.0.1:22"}
+
+	result, err := NewBuilder().BuildTunnelService(tunnel, backend, nil)
+	if err != nil {
+		t.Fatalf("BuildTunnelService() error = %v", err)
+	}
+	args := cmdline.Split(result.ExecStart)
+	if len(args) == 0 || args[len(args)-1] != "127.0.0.1:5311" {
+		t.Errorf("ExecStart %q does not forward to the front", result.ExecStart)
+	}
+	if !slices.Contains(result.Wants, health.ServiceName+".service") {
+		t.Errorf("Wants = %v, want the health service", result.Wants)
+	}
+}
+
+func TestBuildTunnelService_SessionLimit(t *testing.T) {
+	tunnel := &config.TunnelConfig{Tag: "t", Transport: config.TransportVayDNS, Domain: "t.example.com", Port: 5310,
+		VayDNS: &config.VayDNSConfig{PrivateKey: "/k"}, Sessions: &config.SessionsConfig{Max: 10, Port: 5312}}
+	backend := &config.BackendConfig{Type: config.BackendSOCKS, Address: "127.0.0.1:1080"}
+
+	result, err := NewBuilder().BuildTunnelService(tunnel, backend, nil)
+	if err != nil {
+		t.Fatalf("BuildTunnelService() error = %v", err)
+	}
+	if !strings.Contains(result.ExecStart, "127.0.0.1:5312") {
+		t.Errorf("ExecStart %q does not forward to the front", result.ExecStart)
+	}
+	if !slices.Contains(result.Wants, health.ServiceName+".service") {
+		t.Errorf("Wants = %v, want the health service", result.Wants)
+	}
+}
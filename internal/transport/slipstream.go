@@ -0,0 +1,162 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/templates"
+)
+
+func init() {
+	Register(&slipstreamProvider{Binaries: Binaries{binary.BinarySlipstreamServer}})
+}
+
+// slipstreamProvider runs Slipstream tunnels.
+type slipstreamProvider struct {
+	Binaries
+}
+
+func (p *slipstreamProvider) Type() config.TransportType { return config.TransportSlipstream }
+
+func (p *slipstreamProvider) DisplayName() string {
+	return config.GetTransportTypeDisplayName(config.TransportSlipstream)
+}
+
+// Build builds a Slipstream-based tunnel service.
+func (p *slipstreamProvider) Build(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) error {
+	// Read cert/key paths from tunnel config (already set before builder is called)
+	if tunnel.Slipstream == nil || tunnel.Slipstream.Cert == "" || tunnel.Slipstream.Key == "" {
+		return fmt.Errorf("slipstream cert/key paths not set for tunnel %s", tunnel.Tag)
+	}
+
+	certPath := tunnel.Slipstream.Cert
+	keyPath := tunnel.Slipstream.Key
+
+	result.ReadPaths = append(result.ReadPaths, certPath, keyPath)
+
+	// Slipstream + Shadowsocks uses ssserver with slipstream as plugin (SIP003)
+	if backend.Type == config.BackendShadowsocks {
+		return p.buildShadowsocks(tunnel, backend, certPath, keyPath, opts, result)
+	}
+
+	// Slipstream standalone mode (SOCKS, SSH, or custom target)
+	execStart, err := templates.RenderCommand(templates.Slipstream, &execData{
+		Binary:   SlipstreamBinaryPath(),
+		Tunnel:   tunnel,
+		BindHost: opts.BindHost,
+		BindPort: opts.BindPort,
+		Target:   targetAddr,
+		Cert:     certPath,
+		Key:      keyPath,
+	})
+	if err != nil {
+		return err
+	}
+	result.ExecStart = execStart
+	return nil
+}
+
+// buildShadowsocks builds a Slipstream+Shadowsocks tunnel using SIP003 plugin mode.
+func (p *slipstreamProvider) buildShadowsocks(tunnel *config.TunnelConfig, backend *config.BackendConfig, certPath, keyPath string, opts *BuildOptions, result *TunnelBuildResult) error {
+	if backend.Shadowsocks == nil {
+		return fmt.Errorf("shadowsocks backend missing configuration")
+	}
+
+	method := backend.Shadowsocks.Method
+	if method == "" {
+		method = "aes-256-gcm"
+	}
+
+	// Build plugin options
+	pluginOpts := fmt.Sprintf("domain=%s;dns-listen-host=%s;dns-listen-port=%d;cert=%s;key=%s",
+		pluginOptValue(tunnel.Domain), pluginOptValue(opts.BindHost), opts.BindPort,
+		pluginOptValue(certPath), pluginOptValue(keyPath))
+
+	// Write Shadowsocks config file
+	server := map[string]interface{}{
+		"server":      opts.BindHost,
+		"server_port": opts.BindPort,
+		"password":    backend.Shadowsocks.Password,
+		"method":      method,
+		"mode":        "tcp_only",
+		"plugin":      SlipstreamBinaryPath(),
+		"plugin_opts": pluginOpts,
+		"plugin_mode": "tcp_only",
+	}
+	var users []map[string]string
+	if tunnel.Slipstream != nil && len(tunnel.Slipstream.Users) > 0 {
+		// Multi-user: the password is the server's identity key and each
+		// user connects with it and a key of their own
+		users = make([]map[string]string, len(tunnel.Slipstream.Users))
+		for i, u := range tunnel.Slipstream.Users {
+			users[i] = map[string]string{"name": u.Name, "password": u.Password}
+		}
+		server["users"] = users
+	}
+	ssConfig := server
+	if plugins := backend.Shadowsocks.Plugins; len(plugins) > 0 {
+		// Each plugin listener is another server of the same process,
+		// sharing the keys and users of the slipstream one
+		servers := []map[string]interface{}{server}
+		for _, pl := range plugins {
+			extra := map[string]interface{}{
+				"server":      "0.0.0.0",
+				"server_port": pl.Port,
+				"password":    backend.Shadowsocks.Password,
+				"method":      method,
+				"mode":        "tcp_only",
+				"plugin":      pl.Plugin,
+			}
+			if pl.Options != "" {
+				extra["plugin_opts"] = pl.Options
+			}
+			if users != nil {
+				extra["users"] = users
+			}
+			if pl.Port < 1024 {
+				result.BindPrivileged = true
+			}
+			servers = append(servers, extra)
+		}
+		ssConfig = map[string]interface{}{"servers": servers}
+	}
+	if o := backend.Outbound; o != nil {
+		if o.SourceIP != "" {
+			ssConfig["outbound_bind_addr"] = o.SourceIP
+		}
+		if o.Interface != "" {
+			ssConfig["outbound_bind_interface"] = o.Interface
+		}
+	}
+
+	configPath := filepath.Join(result.ConfigDir, "config.json")
+	data, err := json.MarshalIndent(ssConfig, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	result.Files = append(result.Files, ConfigFile{Path: configPath, Data: data, Mode: 0644})
+
+	execStart, err := templates.RenderCommand(templates.SSServer, &execData{
+		Binary: SSServerBinaryPath(),
+		Tunnel: tunnel,
+		Config: configPath,
+	})
+	if err != nil {
+		return err
+	}
+	result.ExecStart = execStart
+	result.ReadPaths = append(result.ReadPaths, configPath)
+
+	return nil
+}
+
+// pluginOptValue escapes a value of SIP003 plugin options, where ';'
+// separates options and '=' a name from its value.
+func pluginOptValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, "=", `\=`).Replace(v)
+}
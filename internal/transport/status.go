@@ -6,40 +6,29 @@ import (
 
 // IsInstalled checks if all required transport binaries are installed.
 func IsInstalled() bool {
-	mgr := binary.NewDefaultManager()
-	binaries := []binary.BinaryType{
-		binary.BinaryDNSTTServer,
-		binary.BinarySlipstreamServer,
-		binary.BinarySSServer,
-		binary.BinaryVayDNSServer,
-	}
-
-	for _, bin := range binaries {
-		if _, err := mgr.GetPath(bin); err != nil {
-			return false
-		}
-	}
-
-	return true
+	return len(GetMissingBinaries()) == 0
 }
 
 // GetMissingBinaries returns a list of missing transport binaries.
 func GetMissingBinaries() []string {
-	mgr := binary.NewDefaultManager()
 	var missing []string
-
-	binaries := []binary.BinaryType{
-		binary.BinaryDNSTTServer,
-		binary.BinarySlipstreamServer,
-		binary.BinarySSServer,
-		binary.BinaryVayDNSServer,
+	for _, p := range Providers() {
+		missing = append(missing, p.MissingBinaries()...)
 	}
 
-	for _, bin := range binaries {
-		if _, err := mgr.GetPath(bin); err != nil {
-			missing = append(missing, string(bin))
+	// ssserver is needed by the Shadowsocks backend rather than a transport
+	return append(missing, Binaries{binary.BinarySSServer}.MissingBinaries()...)
+}
+
+// UnsupportedBinaries returns the server binaries that have no build for
+// this platform and no path override, so nothing needing them can be used.
+func UnsupportedBinaries() []string {
+	mgr := binary.NewDefaultManager()
+	var names []string
+	for _, def := range binary.ServerBinaries() {
+		if _, err := mgr.GetPath(def.Type); binary.IsUnsupported(err) {
+			names = append(names, string(def.Type))
 		}
 	}
-
-	return missing
+	return names
 }
@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"fmt"
+
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/templates"
+)
+
+func init() {
+	Register(&vaydnsProvider{Binaries: Binaries{binary.BinaryVayDNSServer}})
+}
+
+// vaydnsProvider runs VayDNS tunnels.
+type vaydnsProvider struct {
+	Binaries
+}
+
+func (p *vaydnsProvider) Type() config.TransportType { return config.TransportVayDNS }
+
+func (p *vaydnsProvider) DisplayName() string {
+	return config.GetTransportTypeDisplayName(config.TransportVayDNS)
+}
+
+// Build builds a VayDNS-based tunnel service.
+func (p *vaydnsProvider) Build(tunnel *config.TunnelConfig, backend *config.BackendConfig, targetAddr string, opts *BuildOptions, result *TunnelBuildResult) error {
+	if backend.Type == config.BackendShadowsocks {
+		return fmt.Errorf("VayDNS transport does not support Shadowsocks backend")
+	}
+
+	if tunnel.VayDNS == nil || tunnel.VayDNS.PrivateKey == "" {
+		return fmt.Errorf("vaydns private key path not set for tunnel %s", tunnel.Tag)
+	}
+
+	result.ReadPaths = append(result.ReadPaths, tunnel.VayDNS.PrivateKey)
+
+	mtu := 1232
+	if tunnel.VayDNS.MTU > 0 {
+		mtu = tunnel.VayDNS.MTU
+	}
+
+	execStart, err := templates.RenderCommand(templates.VayDNS, &execData{
+		Binary:   VayDNSBinaryPath(),
+		Tunnel:   tunnel,
+		BindHost: opts.BindHost,
+		BindPort: opts.BindPort,
+		Target:   targetAddr,
+		MTU:      mtu,
+	})
+	if err != nil {
+		return err
+	}
+	result.ExecStart = execStart
+	return nil
+}
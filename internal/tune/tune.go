@@ -0,0 +1,314 @@
+// Package tune applies the kernel settings recommended for DNS tunneling
+// servers.
+//
+// Settings are written to /proc/sys and persisted in a sysctl.d file. The
+// values they replace are recorded, so that revert and uninstall put the
+// kernel back the way it was.
+package tune
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// ProcSysDir is where the kernel exposes its sysctls.
+var ProcSysDir = "/proc/sys"
+
+// ConfPath is the sysctl.d file dnstm manages, read at boot.
+var ConfPath = "/etc/sysctl.d/99-dnstm.conf"
+
+// StatePath records the values the applied settings replaced.
+var StatePath = filepath.Join(paths.ConfigDir, "tune.json")
+
+// ErrUnsupported is returned by Apply on systems without Linux sysctls.
+var ErrUnsupported = errors.New("kernel tuning is only supported on Linux")
+
+// Setting is a recommended sysctl value.
+type Setting struct {
+	Key         string
+	Value       int64
+	Description string
+	// Optional settings belong to kernel modules that may not be loaded,
+	// such as conntrack.
+	Optional bool
+	// Required settings are needed by dnstm itself and are kept when the
+	// tuning is reverted on a running install.
+	Required bool
+}
+
+// Recommended returns the settings for a server with the given profile.
+// Buffer sizes and the conntrack table are smaller on tiny servers, where
+// they would otherwise claim a large part of the memory under load.
+func Recommended(profile string) []Setting {
+	buffer, conntrack := int64(16<<20), int64(262144)
+	if profile == config.ProfileTiny {
+		buffer, conntrack = 4<<20, 65536
+	}
+	return []Setting{
+		{Key: "net.core.rmem_max", Value: buffer, Description: "largest UDP receive buffer"},
+		{Key: "net.core.wmem_max", Value: buffer, Description: "largest UDP send buffer"},
+		{Key: "net.core.rmem_default", Value: 1 << 20, Description: "default socket receive buffer"},
+		{Key: "net.core.wmem_default", Value: 1 << 20, Description: "default socket send buffer"},
+		{Key: "net.core.netdev_max_backlog", Value: 5000, Description: "packets queued per CPU before dropping"},
+		{Key: "net.core.somaxconn", Value: 4096, Description: "pending TCP connections (Chisel, DNS over TCP)"},
+		{Key: "net.netfilter.nf_conntrack_max", Value: conntrack, Description: "tracked connections", Optional: true},
+		{Key: "net.ipv4.conf.all.route_localnet", Value: 1, Description: "port 53 redirects to loopback, as older installs use", Required: true},
+	}
+}
+
+// State is what Apply recorded.
+type State struct {
+	Applied time.Time `json:"applied"`
+	// Values holds the applied settings, Previous the values they replaced.
+	// Settings that were already at or above the recommendation are in
+	// neither.
+	Values   map[string]int64 `json:"values"`
+	Previous map[string]int64 `json:"previous"`
+}
+
+// Read returns the current value of a sysctl.
+func Read(key string) (int64, error) {
+	data, err := os.ReadFile(procPath(key))
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value of %s: %w", key, err)
+	}
+	return v, nil
+}
+
+func write(key string, value int64) error {
+	if err := os.WriteFile(procPath(key), []byte(strconv.FormatInt(value, 10)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+func procPath(key string) string {
+	return filepath.Join(ProcSysDir, filepath.FromSlash(strings.ReplaceAll(key, ".", "/")))
+}
+
+// LoadState returns the recorded state, or nil when the tuning is not
+// applied.
+func LoadState() (*State, error) {
+	data, err := os.ReadFile(StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", StatePath, err)
+	}
+	return &s, nil
+}
+
+func saveState(s *State) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(StatePath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", StatePath, err)
+	}
+	return nil
+}
+
+// IsApplied reports whether the tuning is applied.
+func IsApplied() bool {
+	_, err := os.Stat(ConfPath)
+	return err == nil
+}
+
+// Change is a setting Apply changed.
+type Change struct {
+	Key      string
+	Previous int64
+	Value    int64
+}
+
+// Apply raises the settings that are below their recommended value and
+// persists them. Values that are already higher are left alone, and
+// optional settings the kernel does not have are skipped. Applying again
+// keeps the values recorded by the first run.
+func Apply(settings []Setting) ([]Change, error) {
+	if runtime.GOOS != "linux" {
+		return nil, ErrUnsupported
+	}
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = &State{Values: make(map[string]int64), Previous: make(map[string]int64)}
+	}
+
+	var changes []Change
+	for _, s := range settings {
+		current, err := Read(s.Key)
+		if errors.Is(err, os.ErrNotExist) && s.Optional {
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+		if current >= s.Value {
+			continue
+		}
+		if err := write(s.Key, s.Value); err != nil {
+			return changes, err
+		}
+		if _, ok := state.Previous[s.Key]; !ok {
+			state.Previous[s.Key] = current
+		}
+		state.Values[s.Key] = s.Value
+		changes = append(changes, Change{Key: s.Key, Previous: current, Value: s.Value})
+	}
+
+	state.Applied = time.Now().UTC()
+	if err := writeConf(state.Values, settings); err != nil {
+		return changes, err
+	}
+	return changes, saveState(state)
+}
+
+// writeConf persists values in ConfPath. Optional settings are prefixed
+// with "-", so a missing module does not fail the boot-time load.
+func writeConf(values map[string]int64, settings []Setting) error {
+	optional := make(map[string]bool)
+	for _, s := range settings {
+		optional[s.Key] = s.Optional
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# Managed by dnstm; changes are overwritten by 'dnstm tune apply'\n")
+	b.WriteString("# and removed by 'dnstm tune revert'.\n")
+	for _, k := range keys {
+		prefix := ""
+		if optional[k] {
+			prefix = "-"
+		}
+		fmt.Fprintf(&b, "%s%s = %d\n", prefix, k, values[k])
+	}
+	if err := os.WriteFile(ConfPath, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ConfPath, err)
+	}
+	return nil
+}
+
+// Revert removes the persisted settings and puts back the values they
+// replaced, unless something else changed them since. With keepRequired,
+// the settings dnstm needs stay in effect until the next boot, and their
+// previous values stay recorded for a later revert, as on uninstall.
+func Revert(keepRequired bool) ([]Change, error) {
+	state, err := LoadState()
+	if err != nil || state == nil {
+		return nil, err
+	}
+	required := make(map[string]bool)
+	for _, s := range Recommended("") {
+		required[s.Key] = s.Required
+	}
+
+	keys := make([]string, 0, len(state.Previous))
+	for k := range state.Previous {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kept := &State{Applied: state.Applied, Values: make(map[string]int64), Previous: make(map[string]int64)}
+	var changes []Change
+	var errs []error
+	for _, k := range keys {
+		if keepRequired && required[k] {
+			kept.Values[k], kept.Previous[k] = state.Values[k], state.Previous[k]
+			continue
+		}
+		current, err := Read(k)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if current != state.Values[k] {
+			continue
+		}
+		if err := write(k, state.Previous[k]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changes = append(changes, Change{Key: k, Previous: current, Value: state.Previous[k]})
+	}
+	if len(errs) > 0 {
+		return changes, errors.Join(errs...)
+	}
+
+	if err := os.Remove(ConfPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return changes, fmt.Errorf("failed to remove %s: %w", ConfPath, err)
+	}
+	if len(kept.Previous) > 0 {
+		return changes, saveState(kept)
+	}
+	if err := os.Remove(StatePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return changes, fmt.Errorf("failed to remove %s: %w", StatePath, err)
+	}
+	return changes, nil
+}
+
+// Status is the state of one recommended setting.
+type Status struct {
+	Setting
+	Current   int64
+	Available bool
+	// Previous is the value the tuning replaced, if it changed the setting.
+	Previous *int64
+}
+
+// OK reports whether the setting is at or above its recommended value.
+func (s Status) OK() bool {
+	return s.Available && s.Current >= s.Value
+}
+
+// GetStatus compares the current values with the recommended settings.
+func GetStatus(settings []Setting) ([]Status, error) {
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(settings))
+	for _, s := range settings {
+		st := Status{Setting: s}
+		if v, err := Read(s.Key); err == nil {
+			st.Current, st.Available = v, true
+		}
+		if state != nil {
+			if prev, ok := state.Previous[s.Key]; ok {
+				st.Previous = &prev
+			}
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,158 @@
+package tune
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeProc points the package at a temporary /proc/sys holding values.
+func fakeProc(t *testing.T, values map[string]string) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("kernel tuning is Linux only")
+	}
+	dir := t.TempDir()
+	oldProc, oldConf, oldState := ProcSysDir, ConfPath, StatePath
+	ProcSysDir = filepath.Join(dir, "proc")
+	ConfPath = filepath.Join(dir, "99-dnstm.conf")
+	StatePath = filepath.Join(dir, "tune.json")
+	t.Cleanup(func() { ProcSysDir, ConfPath, StatePath = oldProc, oldConf, oldState })
+
+	for key, v := range values {
+		path := procPath(key)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(v+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readValue(t *testing.T, key string) int64 {
+	t.Helper()
+	v, err := Read(key)
+	if err != nil {
+		t.Fatalf("Read(%s) error = %v", key, err)
+	}
+	return v
+}
+
+func TestApplyAndRevert(t *testing.T) {
+	fakeProc(t, map[string]string{
+		"net.core.rmem_max":                "212992",
+		"net.core.somaxconn":               "8192",
+		"net.ipv4.conf.all.route_localnet": "0",
+	})
+	settings := []Setting{
+		{Key: "net.core.rmem_max", Value: 4 << 20},
+		{Key: "net.core.somaxconn", Value: 4096},
+		{Key: "net.netfilter.nf_conntrack_max", Value: 65536, Optional: true},
+		{Key: "net.ipv4.conf.all.route_localnet", Value: 1, Required: true},
+	}
+
+	changes, err := Apply(settings)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("Apply() changed %v, want rmem_max and route_localnet", changes)
+	}
+	if got := readValue(t, "net.core.rmem_max"); got != 4<<20 {
+		t.Errorf("rmem_max = %d, want %d", got, 4<<20)
+	}
+	if got := readValue(t, "net.core.somaxconn"); got != 8192 {
+		t.Errorf("somaxconn = %d, a higher value must be kept", got)
+	}
+	conf, err := os.ReadFile(ConfPath)
+	if err != nil {
+		t.Fatalf("sysctl.d file not written: %v", err)
+	}
+	if !strings.Contains(string(conf), "net.core.rmem_max = 4194304\n") || strings.Contains(string(conf), "somaxconn") {
+		t.Errorf("sysctl.d file =\n%s", conf)
+	}
+	if !IsApplied() {
+		t.Error("IsApplied() = false after Apply()")
+	}
+
+	// A revert on a running install keeps route_localnet
+	if _, err := Revert(true); err != nil {
+		t.Fatalf("Revert(true) error = %v", err)
+	}
+	if got := readValue(t, "net.core.rmem_max"); got != 212992 {
+		t.Errorf("rmem_max after revert = %d, want 212992", got)
+	}
+	if got := readValue(t, "net.ipv4.conf.all.route_localnet"); got != 1 {
+		t.Errorf("route_localnet after revert = %d, want 1", got)
+	}
+	if IsApplied() {
+		t.Error("IsApplied() = true after Revert()")
+	}
+
+	// Uninstall puts it back too
+	if _, err := Revert(false); err != nil {
+		t.Fatalf("Revert(false) error = %v", err)
+	}
+	if got := readValue(t, "net.ipv4.conf.all.route_localnet"); got != 0 {
+		t.Errorf("route_localnet after uninstall = %d, want 0", got)
+	}
+	if _, err := os.Stat(StatePath); !os.IsNotExist(err) {
+		t.Errorf("state file left after a full revert: %v", err)
+	}
+}
+
+func TestRevert_KeepsValuesChangedSince(t *testing.T) {
+	fakeProc(t, map[string]string{"net.core.wmem_max": "212992"})
+	if _, err := Apply([]Setting{{Key: "net.core.wmem_max", Value: 4 << 20}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if err := write("net.core.wmem_max", 32<<20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Revert(false); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+	if got := readValue(t, "net.core.wmem_max"); got != 32<<20 {
+		t.Errorf("wmem_max = %d, a value changed by the admin must be kept", got)
+	}
+}
+
+func TestApply_KeepsFirstPrevious(t *testing.T) {
+	fakeProc(t, map[string]string{"net.core.rmem_max": "212992"})
+	if _, err := Apply([]Setting{{Key: "net.core.rmem_max", Value: 4 << 20}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if _, err := Apply([]Setting{{Key: "net.core.rmem_max", Value: 16 << 20}}); err != nil {
+		t.Fatalf("second Apply() error = %v", err)
+	}
+	state, err := LoadState()
+	if err != nil || state == nil {
+		t.Fatalf("LoadState() = %v, %v", state, err)
+	}
+	if got := state.Previous["net.core.rmem_max"]; got != 212992 {
+		t.Errorf("recorded previous = %d, want the value before the first apply", got)
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	fakeProc(t, map[string]string{"net.core.somaxconn": "4096"})
+	statuses, err := GetStatus(Recommended(""))
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	for _, s := range statuses {
+		switch s.Key {
+		case "net.core.somaxconn":
+			if !s.OK() {
+				t.Errorf("somaxconn at the recommendation not OK: %+v", s)
+			}
+		default:
+			if s.Available || s.OK() {
+				t.Errorf("%s reported available", s.Key)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package updater
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/net2share/dnstm/internal/cmdline"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/schedule"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// AutoUpdateName is the name of the unattended upgrade timer and service.
+var AutoUpdateName = paths.SystemService("autoupdate")
+
+// DefaultWindow is the maintenance window used when none is given.
+const DefaultWindow = "03:00-05:00"
+
+// autoUpdateCommand returns the command the timer runs.
+func autoUpdateCommand(w schedule.Window) string {
+	return cmdline.Join(paths.Bin("dnstm"), "auto-update", "run", "--window", w.String())
+}
+
+// EnableAutoUpdate installs and starts the unattended upgrade timer.
+// The timer fires at a random point in the first half of the window so
+// the upgrade and its health checks finish before the window closes.
+func EnableAutoUpdate(w schedule.Window) error {
+	return service.CreateTimer(&service.TimerConfig{
+		Name:        AutoUpdateName,
+		Description: "dnstm unattended upgrades",
+		ExecStart:   autoUpdateCommand(w),
+		OnCalendar:  w.OnCalendar(),
+		RandomDelay: w.Length() / 2,
+	})
+}
+
+// DisableAutoUpdate stops and removes the unattended upgrade timer.
+func DisableAutoUpdate() error {
+	return service.RemoveTimer(AutoUpdateName)
+}
+
+// AutoUpdateWindow returns the window of the installed timer, or false if
+// unattended upgrades are not enabled.
+func AutoUpdateWindow() (schedule.Window, bool) {
+	f, err := os.Open(service.GetServicePath(AutoUpdateName))
+	if err != nil {
+		return schedule.Window{}, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "ExecStart=") {
+			continue
+		}
+		if idx := strings.Index(line, "--window "); idx >= 0 {
+			if w, err := schedule.ParseWindow(strings.Fields(line[idx+len("--window "):])[0]); err == nil {
+				return w, true
+			}
+		}
+	}
+	return schedule.Window{}, false
+}
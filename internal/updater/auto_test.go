@@ -0,0 +1,37 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackup_Restore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bin")
+	if err := os.WriteFile(path, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := BackupFiles([]string{path, filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatalf("BackupFiles() error: %v", err)
+	}
+	defer b.Discard()
+
+	if err := os.WriteFile(path, []byte("new"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Restore(); err != nil {
+		t.Fatalf("Restore() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "old" {
+		t.Errorf("content = %q, want old", data)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+}
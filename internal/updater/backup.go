@@ -0,0 +1,118 @@
+package updater
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/paths"
+	"github.com/net2share/dnstm/internal/service"
+)
+
+// DnstmPath is the installed dnstm binary.
+var DnstmPath = paths.Bin("dnstm")
+
+// Backup holds copies of files replaced by an update.
+type Backup struct {
+	dir   string
+	files map[string]string // original path -> backup path
+}
+
+// UpdatePaths returns the files an update would replace, including the
+// version manifest.
+func UpdatePaths(report *UpdateReport, opts UpdateOptions) []string {
+	paths := []string{GetManifestPath()}
+	if report.DnstmUpdate != nil && !opts.BinariesOnly {
+		paths = append(paths, DnstmPath)
+	}
+	if !opts.SelfOnly {
+		mgr := binary.NewDefaultManager()
+		for _, u := range report.BinaryUpdates {
+			if path, err := mgr.GetPath(u.Binary); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// BackupFiles copies the existing files among paths into a temporary directory.
+func BackupFiles(paths []string) (*Backup, error) {
+	dir, err := os.MkdirTemp("", "dnstm-update-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	b := &Backup{dir: dir, files: make(map[string]string)}
+	for i, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		dest := filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(path)))
+		if err := copyFile(path, dest); err != nil {
+			b.Discard()
+			return nil, fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+		b.files[path] = dest
+	}
+	return b, nil
+}
+
+// Restore puts the backed up files back in place.
+func (b *Backup) Restore() error {
+	for path, src := range b.files {
+		// Write next to the target and rename, so a running binary is replaced atomically
+		tmp := path + ".rollback"
+		if err := copyFile(src, tmp); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Discard removes the backup copies.
+func (b *Backup) Discard() {
+	os.RemoveAll(b.dir)
+}
+
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// FailedServices waits for services to settle and returns those that are not active.
+func FailedServices(names []string, settle time.Duration) []string {
+	time.Sleep(settle)
+
+	var failed []string
+	for _, name := range names {
+		if !service.IsServiceActive(name) {
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
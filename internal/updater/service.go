@@ -24,7 +24,7 @@ func GetActiveServicesForBinary(binType binary.BinaryType) []string {
 			services = append(services, proxy.MicrosocksServiceName)
 		}
 
-	case binary.BinarySlipstreamServer, binary.BinarySSServer, binary.BinaryDNSTTServer, binary.BinaryVayDNSServer:
+	case binary.BinarySlipstreamServer, binary.BinarySSServer, binary.BinaryDNSTTServer, binary.BinaryVayDNSServer, binary.BinaryChiselServer:
 		// Check tunnel services
 		cfg, err := config.Load()
 		if err != nil || cfg == nil {
@@ -70,6 +70,9 @@ func tunnelUsesBinary(tunnelCfg *config.TunnelConfig, binType binary.BinaryType)
 
 	case binary.BinaryVayDNSServer:
 		return tunnelCfg.Transport == config.TransportVayDNS
+
+	case binary.BinaryChiselServer:
+		return tunnelCfg.Transport == config.TransportChisel
 	}
 
 	return false
@@ -109,6 +112,7 @@ func GetAllActiveServices() map[binary.BinaryType][]string {
 		// Note: dnstt-server is skipped for updates, but we still track its services
 		binary.BinaryDNSTTServer,
 		binary.BinaryVayDNSServer,
+		binary.BinaryChiselServer,
 	}
 
 	for _, binType := range binaries {
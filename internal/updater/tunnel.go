@@ -0,0 +1,61 @@
+package updater
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// StagingDir holds binaries downloaded by a tunnel upgrade until they pass
+// the self-test. It is next to the installed binaries, so installing one is
+// a rename.
+var StagingDir = paths.Bin(".dnstm-staging")
+
+// transportBinaries are the binaries that run tunnel transports.
+var transportBinaries = []binary.BinaryType{
+	binary.BinarySlipstreamServer,
+	binary.BinaryDNSTTServer,
+	binary.BinaryVayDNSServer,
+	binary.BinaryChiselServer,
+}
+
+// TunnelBinary returns the binary that runs the transport of a tunnel.
+func TunnelBinary(tunnelCfg *config.TunnelConfig) (binary.BinaryType, bool) {
+	for _, b := range transportBinaries {
+		if tunnelUsesBinary(tunnelCfg, b) {
+			return b, true
+		}
+	}
+	return "", false
+}
+
+// StageBinary downloads a version of a binary into StagingDir and returns
+// its path.
+func StageBinary(binType binary.BinaryType, version string) (string, error) {
+	mgr := binary.NewManager(StagingDir)
+	if err := mgr.EnsureDir(); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", StagingDir, err)
+	}
+	if err := mgr.DownloadVersion(binType, version); err != nil {
+		return "", fmt.Errorf("failed to download %s %s: %w", binType, version, err)
+	}
+	return filepath.Join(StagingDir, binary.ExeName(string(binType))), nil
+}
+
+// InstallStaged moves a staged binary over the installed one. Running
+// processes keep the binary they were started with.
+func InstallStaged(staged, installed string) error {
+	if err := os.Rename(staged, installed); err != nil {
+		return fmt.Errorf("failed to install %s: %w", filepath.Base(installed), err)
+	}
+	return nil
+}
+
+// DiscardStaged removes StagingDir and what is left in it.
+func DiscardStaged() {
+	os.RemoveAll(StagingDir)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/net2share/dnstm/internal/binary"
+	"github.com/net2share/dnstm/internal/packaging"
 	"github.com/net2share/go-corelib/binman"
 )
 
@@ -20,6 +21,9 @@ type UpdateReport struct {
 	DnstmUpdate   *VersionInfo
 	BinaryUpdates []BinaryUpdate
 	Warnings      []string
+	// PackageManager is the format of the package dnstm was installed
+	// from; dnstm itself is then upgraded by the package manager.
+	PackageManager string
 }
 
 // VersionInfo contains version details.
@@ -64,10 +68,10 @@ func GetDnstmLatestVersion() (string, error) {
 
 // CheckForUpdates checks for available updates without applying them.
 func CheckForUpdates(currentDnstmVersion string, opts UpdateOptions) (*UpdateReport, error) {
-	report := &UpdateReport{}
+	report := &UpdateReport{PackageManager: packaging.Manager()}
 
 	// Check dnstm updates
-	if !opts.BinariesOnly {
+	if !opts.BinariesOnly && report.PackageManager == "" {
 		latestDnstm, err := GetDnstmLatestVersion()
 		if err != nil {
 			report.Warnings = append(report.Warnings,
@@ -103,6 +107,7 @@ func checkBinaryUpdates(manifest *VersionManifest) []BinaryUpdate {
 		binary.BinaryMicrosocks,
 		binary.BinarySSHTunUser,
 		binary.BinaryVayDNSServer,
+		binary.BinaryChiselServer,
 	}
 
 	for _, binType := range binariesToCheck {
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/net2share/go-corelib/binman"
+
+	"github.com/net2share/dnstm/internal/paths"
 )
 
 const (
@@ -22,7 +24,7 @@ type VersionManifest struct {
 
 // GetManifestPath returns the path to the version manifest file.
 func GetManifestPath() string {
-	return filepath.Join("/etc/dnstm", VersionManifestFile)
+	return filepath.Join(paths.ConfigDir, VersionManifestFile)
 }
 
 // NewManifest creates a new empty version manifest.
@@ -0,0 +1,57 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Export formats.
+const (
+	FormatTable = "table"
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+)
+
+// WriteCSV writes the ledger as CSV with a header row.
+func WriteCSV(w io.Writer, l *Ledger) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"month", "kind", "name", "bytes", "packets"})
+	for _, e := range l.Entries {
+		cw.Write([]string{
+			l.Month,
+			string(e.Kind),
+			e.Name,
+			strconv.FormatUint(e.Bytes, 10),
+			strconv.FormatUint(e.Packets, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the ledger as indented JSON.
+func WriteJSON(w io.Writer, l *Ledger) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// FormatBytes renders a byte count with a binary unit suffix.
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,81 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// NFTablesTable is the nftables table holding the usage counters.
+const NFTablesTable = "dnstm_usage"
+
+// NFTablesScript renders an nftables script that replaces the usage table
+// with one counter per subject. Only the output hook is used: an SSH user's
+// session sends both the forwarded traffic and the replies to its client,
+// loopback traffic to and from the SOCKS proxy passes it in both
+// directions, and a tunnel's answers to its clients leave from its port.
+func NFTablesScript(subjects []Subject) string {
+	var b strings.Builder
+
+	// Create-then-delete makes the script idempotent whether or not the table exists
+	fmt.Fprintf(&b, "add table inet %s\n", NFTablesTable)
+	fmt.Fprintf(&b, "delete table inet %s\n", NFTablesTable)
+	fmt.Fprintf(&b, "table inet %s {\n", NFTablesTable)
+
+	seen := make(map[string]bool)
+	for _, s := range subjects {
+		if !seen[s.CounterName()] {
+			seen[s.CounterName()] = true
+			fmt.Fprintf(&b, "\tcounter %s {\n\t}\n", s.CounterName())
+		}
+	}
+
+	b.WriteString("\tchain output {\n")
+	b.WriteString("\t\ttype filter hook output priority 0; policy accept;\n")
+	for _, s := range subjects {
+		switch s.Kind {
+		case KindSSH:
+			fmt.Fprintf(&b, "\t\tmeta skuid %d counter name %s\n", s.UID, s.CounterName())
+		case KindSocks:
+			fmt.Fprintf(&b, "\t\ttcp dport %d counter name %s\n", s.Port, s.CounterName())
+			fmt.Fprintf(&b, "\t\ttcp sport %d counter name %s\n", s.Port, s.CounterName())
+		case KindTunnel:
+			fmt.Fprintf(&b, "\t\tudp sport %d counter name %s\n", s.Port, s.CounterName())
+			fmt.Fprintf(&b, "\t\ttcp sport %d counter name %s\n", s.Port, s.CounterName())
+		}
+	}
+	b.WriteString("\t}\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ParseCounters extracts the counter values from "nft -j" output.
+func ParseCounters(data []byte) ([]Entry, error) {
+	var out struct {
+		Nftables []struct {
+			Counter *struct {
+				Table   string `json:"table"`
+				Name    string `json:"name"`
+				Packets uint64 `json:"packets"`
+				Bytes   uint64 `json:"bytes"`
+			} `json:"counter"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse nft output: %w", err)
+	}
+
+	var entries []Entry
+	for _, item := range out.Nftables {
+		c := item.Counter
+		if c == nil || c.Table != NFTablesTable {
+			continue
+		}
+		kind, name, ok := strings.Cut(c.Name, ".")
+		if !ok {
+			continue
+		}
+		entries = append(entries, Entry{Kind: Kind(kind), Name: name, Bytes: c.Bytes, Packets: c.Packets})
+	}
+	return entries, nil
+}
@@ -0,0 +1,131 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+// Cutoff records an account that was cut off for exceeding its quota.
+type Cutoff struct {
+	Month string    `json:"month"` // Month whose quota was exceeded
+	Since time.Time `json:"since"`
+
+	// Password is the SOCKS password replaced during the cutoff, restored
+	// when it is lifted.
+	Password string `json:"password,omitempty"`
+
+	// Enabled is set when the tunnel was enabled before the cutoff disabled
+	// it; it is enabled again when the cutoff is lifted.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Stopped is set when a running tunnel was stopped; it is started again
+	// when the cutoff is lifted.
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// Reset records a manual quota reset. Traffic recorded before it does not
+// count against the quota for the rest of the month.
+type Reset struct {
+	Month string `json:"month"`
+	Bytes uint64 `json:"bytes"`
+}
+
+// QuotaState is the enforcement state of the quotas, keyed by account.
+type QuotaState struct {
+	Cutoffs map[string]*Cutoff `json:"cutoffs,omitempty"`
+	Resets  map[string]Reset   `json:"resets,omitempty"`
+}
+
+func quotaStatePath() string {
+	return filepath.Join(Dir, "quota.json")
+}
+
+// LoadQuotaState returns the quota enforcement state.
+func LoadQuotaState() (*QuotaState, error) {
+	state := &QuotaState{}
+	data, err := os.ReadFile(quotaStatePath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read quota state: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("failed to parse quota state: %w", err)
+		}
+	}
+	if state.Cutoffs == nil {
+		state.Cutoffs = make(map[string]*Cutoff)
+	}
+	if state.Resets == nil {
+		state.Resets = make(map[string]Reset)
+	}
+	return state, nil
+}
+
+// Save writes the quota enforcement state.
+func (s *QuotaState) Save() error {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := quotaStatePath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write quota state: %w", err)
+	}
+	return os.Rename(tmp, quotaStatePath())
+}
+
+// IsCutOff reports whether an account is currently cut off.
+func IsCutOff(account string) bool {
+	state, err := LoadQuotaState()
+	return err == nil && state.Cutoffs[account] != nil
+}
+
+// Used returns the traffic of an account that counts against its quota.
+func (s *QuotaState) Used(ledger *Ledger, account string) uint64 {
+	used := ledger.Bytes(account)
+	if r, ok := s.Resets[account]; ok && r.Month == ledger.Month && r.Bytes <= used {
+		used -= r.Bytes
+	}
+	return used
+}
+
+// Plan compares the quotas with this month's ledger. It returns the accounts
+// to cut off, and the cutoffs to lift: those from an earlier month, since a
+// new period started, and those whose quota was removed or raised above the
+// traffic used.
+func (s *QuotaState) Plan(quotas []config.QuotaConfig, ledger *Ledger) (cut, lift []string) {
+	limits := make(map[string]uint64, len(quotas))
+	for _, q := range quotas {
+		if limit, err := config.ParseByteSize(q.Limit); err == nil {
+			limits[q.Account] = limit
+		}
+	}
+
+	for account, c := range s.Cutoffs {
+		limit, ok := limits[account]
+		if c.Month != ledger.Month || !ok || s.Used(ledger, account) < limit {
+			lift = append(lift, account)
+		}
+	}
+	for _, q := range quotas {
+		limit, ok := limits[q.Account]
+		if !ok || s.Cutoffs[q.Account] != nil {
+			continue
+		}
+		if s.Used(ledger, q.Account) >= limit {
+			cut = append(cut, q.Account)
+		}
+	}
+
+	sort.Strings(lift)
+	return cut, lift
+}
@@ -0,0 +1,236 @@
+// Package usage keeps per-user traffic accounting for SSH tunnel users and
+// SOCKS proxy credentials, and per-tunnel totals.
+//
+// Traffic is counted by named nftables counters in the dnstm_usage table:
+// SSH users by the uid of their sshd session (meta skuid), SOCKS credentials
+// by traffic through the proxy port, and tunnels by what they send from
+// their port. The collector ("dnstm report collect", run by the dnstm-usage
+// timer) reads and resets the counters and adds them to a ledger per
+// calendar month under Dir, so totals survive reboots and rule reloads.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+	"github.com/net2share/dnstm/internal/paths"
+)
+
+// Dir holds one ledger file per month.
+var Dir = "/var/lib/dnstm/usage"
+
+// TimerName is the name of the collector timer and service.
+var TimerName = paths.SystemService("usage")
+
+const (
+	// MonthLayout is the time layout of a ledger month.
+	MonthLayout = "2006-01"
+
+	// Regular users created by useradd fall in this uid range.
+	minUserUID = 1000
+	maxUserUID = 60000
+)
+
+// Kind is the kind of account traffic is attributed to.
+type Kind string
+
+// Account kinds.
+const (
+	KindSSH    Kind = config.QuotaSSH
+	KindSocks  Kind = config.QuotaSocks
+	KindTunnel Kind = config.QuotaTunnel
+)
+
+// validName matches names that are safe to use in nftables counter names.
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// Subject is an account whose traffic is counted.
+type Subject struct {
+	Kind Kind
+	Name string
+	UID  int // SSH users
+	Port int // SOCKS credentials and tunnels
+}
+
+// CounterName returns the nftables counter name for the subject.
+func (s Subject) CounterName() string {
+	return string(s.Kind) + "." + s.Name
+}
+
+// Entry is the traffic attributed to one account.
+type Entry struct {
+	Kind    Kind   `json:"kind"`
+	Name    string `json:"name"`
+	Bytes   uint64 `json:"bytes"`
+	Packets uint64 `json:"packets"`
+}
+
+// Ledger is the accumulated traffic of one calendar month.
+type Ledger struct {
+	Month   string    `json:"month"`
+	Updated time.Time `json:"updated,omitempty"`
+	Entries []Entry   `json:"entries"`
+}
+
+// ParseMonth validates a month in YYYY-MM form.
+func ParseMonth(s string) (string, error) {
+	t, err := time.Parse(MonthLayout, s)
+	if err != nil {
+		return "", fmt.Errorf("invalid month '%s' (expected YYYY-MM)", s)
+	}
+	return t.Format(MonthLayout), nil
+}
+
+// Month returns the ledger month containing t.
+func Month(t time.Time) string {
+	return t.Format(MonthLayout)
+}
+
+func ledgerPath(month string) string {
+	return filepath.Join(Dir, month+".json")
+}
+
+// Load returns the ledger for month. A month without traffic yields an
+// empty ledger.
+func Load(month string) (*Ledger, error) {
+	data, err := os.ReadFile(ledgerPath(month))
+	if os.IsNotExist(err) {
+		return &Ledger{Month: month}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	var l Ledger
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("failed to parse usage ledger %s: %w", ledgerPath(month), err)
+	}
+	return &l, nil
+}
+
+// Months returns the months that have a ledger, oldest first.
+func Months() []string {
+	files, _ := filepath.Glob(filepath.Join(Dir, "*.json"))
+	var months []string
+	for _, f := range files {
+		m := strings.TrimSuffix(filepath.Base(f), ".json")
+		if _, err := ParseMonth(m); err == nil {
+			months = append(months, m)
+		}
+	}
+	sort.Strings(months)
+	return months
+}
+
+// Account returns the quota account name of the entry, e.g. "ssh:alice".
+func (e Entry) Account() string {
+	return string(e.Kind) + ":" + e.Name
+}
+
+// Bytes returns the traffic recorded for a quota account.
+func (l *Ledger) Bytes(account string) uint64 {
+	for _, e := range l.Entries {
+		if e.Account() == account {
+			return e.Bytes
+		}
+	}
+	return 0
+}
+
+// Add merges counter readings into the ledger of the month containing now.
+func Add(readings []Entry, now time.Time) error {
+	l, err := Load(Month(now))
+	if err != nil {
+		return err
+	}
+	l.merge(readings)
+	l.Updated = now
+
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ledgerPath(l.Month) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %w", err)
+	}
+	return os.Rename(tmp, ledgerPath(l.Month))
+}
+
+// merge adds readings to the ledger entries, keeping them sorted.
+func (l *Ledger) merge(readings []Entry) {
+	index := make(map[string]int, len(l.Entries))
+	for i, e := range l.Entries {
+		index[string(e.Kind)+"."+e.Name] = i
+	}
+	for _, r := range readings {
+		if r.Bytes == 0 && r.Packets == 0 {
+			continue
+		}
+		key := string(r.Kind) + "." + r.Name
+		if i, ok := index[key]; ok {
+			l.Entries[i].Bytes += r.Bytes
+			l.Entries[i].Packets += r.Packets
+			continue
+		}
+		index[key] = len(l.Entries)
+		l.Entries = append(l.Entries, r)
+	}
+	sort.Slice(l.Entries, func(i, j int) bool {
+		if l.Entries[i].Kind != l.Entries[j].Kind {
+			return l.Entries[i].Kind < l.Entries[j].Kind
+		}
+		return l.Entries[i].Name < l.Entries[j].Name
+	})
+}
+
+// Subjects returns the accounts to count: regular system users, which
+// includes SSH tunnel users, read from passwd, the credentials of
+// authenticated SOCKS backends, and all tunnels.
+func Subjects(cfg *config.Config, passwd io.Reader) []Subject {
+	var subjects []Subject
+
+	scanner := bufio.NewScanner(passwd)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || !validName.MatchString(fields[0]) {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil || uid < minUserUID || uid > maxUserUID {
+			continue
+		}
+		subjects = append(subjects, Subject{Kind: KindSSH, Name: fields[0], UID: uid})
+	}
+
+	if cfg != nil {
+		port := cfg.Proxy.Port
+		if port == 0 {
+			port = 1080
+		}
+		for _, b := range cfg.Backends {
+			if b.Type != config.BackendSOCKS || b.Socks == nil || !validName.MatchString(b.Socks.User) {
+				continue
+			}
+			subjects = append(subjects, Subject{Kind: KindSocks, Name: b.Socks.User, Port: port})
+		}
+		for _, t := range cfg.Tunnels {
+			if t.Port != 0 && validName.MatchString(t.Tag) {
+				subjects = append(subjects, Subject{Kind: KindTunnel, Name: t.Tag, Port: t.Port})
+			}
+		}
+	}
+	return subjects
+}
@@ -0,0 +1,222 @@
+package usage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/net2share/dnstm/internal/config"
+)
+
+const testPasswd = `root:x:0:0:root:/root:/bin/bash
+dnstm:x:998:998::/nonexistent:/usr/sbin/nologin
+alice:x:1000:1000::/home/alice:/usr/sbin/nologin
+bob:x:1001:1001::/home/bob:/bin/bash
+nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin
+`
+
+func TestSubjects(t *testing.T) {
+	cfg := &config.Config{
+		Backends: []config.BackendConfig{
+			{Tag: "socks", Type: config.BackendSOCKS, Socks: &config.SocksConfig{User: "reseller1", Password: "x"}},
+			{Tag: "ssh", Type: config.BackendSSH},
+		},
+	}
+
+	got := Subjects(cfg, strings.NewReader(testPasswd))
+	want := []Subject{
+		{Kind: KindSSH, Name: "alice", UID: 1000},
+		{Kind: KindSSH, Name: "bob", UID: 1001},
+		{Kind: KindSocks, Name: "reseller1", Port: 1080},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d subjects, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("subject %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNFTablesScript(t *testing.T) {
+	script := NFTablesScript([]Subject{
+		{Kind: KindSSH, Name: "alice", UID: 1000},
+		{Kind: KindSocks, Name: "reseller1", Port: 1080},
+	})
+
+	for _, want := range []string{
+		"delete table inet dnstm_usage",
+		"counter ssh.alice {",
+		"counter socks.reseller1 {",
+		"meta skuid 1000 counter name ssh.alice",
+		"tcp dport 1080 counter name socks.reseller1",
+		"tcp sport 1080 counter name socks.reseller1",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestParseCounters(t *testing.T) {
+	data := []byte(`{"nftables": [
+		{"metainfo": {"version": "1.0.6"}},
+		{"counter": {"family": "inet", "name": "ssh.alice", "table": "dnstm_usage", "handle": 1, "packets": 10, "bytes": 4096}},
+		{"counter": {"family": "inet", "name": "other", "table": "something_else", "handle": 2, "packets": 1, "bytes": 1}}
+	]}`)
+
+	entries, err := ParseCounters(data)
+	if err != nil {
+		t.Fatalf("ParseCounters: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if entries[0] != (Entry{Kind: KindSSH, Name: "alice", Bytes: 4096, Packets: 10}) {
+		t.Errorf("got %+v", entries[0])
+	}
+}
+
+func TestAdd_AccumulatesPerMonth(t *testing.T) {
+	Dir = t.TempDir()
+
+	jan := time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 1, 1, 0, 0, 0, time.UTC)
+
+	if err := Add([]Entry{{Kind: KindSSH, Name: "bob", Bytes: 100, Packets: 1}}, jan); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add([]Entry{
+		{Kind: KindSSH, Name: "bob", Bytes: 50, Packets: 1},
+		{Kind: KindSSH, Name: "alice", Bytes: 10, Packets: 1},
+		{Kind: KindSocks, Name: "idle"},
+	}, jan); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add([]Entry{{Kind: KindSSH, Name: "bob", Bytes: 7, Packets: 1}}, feb); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Load("2025-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2 (idle accounts are not recorded): %+v", len(l.Entries), l.Entries)
+	}
+	if l.Entries[0].Name != "alice" || l.Entries[1].Name != "bob" || l.Entries[1].Bytes != 150 {
+		t.Errorf("unexpected January entries: %+v", l.Entries)
+	}
+
+	if months := Months(); len(months) != 2 || months[0] != "2025-01" || months[1] != "2025-02" {
+		t.Errorf("Months() = %v", months)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, &Ledger{
+		Month:   "2025-01",
+		Entries: []Entry{{Kind: KindSSH, Name: "alice", Bytes: 2048, Packets: 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "month,kind,name,bytes,packets\n2025-01,ssh,alice,2048,3\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestParseMonth(t *testing.T) {
+	if m, err := ParseMonth("2025-01"); err != nil || m != "2025-01" {
+		t.Errorf("ParseMonth(2025-01) = %q, %v", m, err)
+	}
+	if _, err := ParseMonth("2025-13"); err == nil {
+		t.Error("expected error for invalid month")
+	}
+}
+
+func TestQuotaState_Plan(t *testing.T) {
+	ledger := &Ledger{
+		Month: "2025-01",
+		Entries: []Entry{
+			{Kind: KindSSH, Name: "alice", Bytes: 2 << 30},
+			{Kind: KindSSH, Name: "bob", Bytes: 100},
+			{Kind: KindTunnel, Name: "main", Bytes: 5 << 30},
+		},
+	}
+	quotas := []config.QuotaConfig{
+		{Account: "ssh:alice", Limit: "1G"},
+		{Account: "ssh:bob", Limit: "1G"},
+		{Account: "tunnel:main", Limit: "10G"},
+	}
+
+	state := &QuotaState{
+		Cutoffs: map[string]*Cutoff{
+			"ssh:carol":   {Month: "2025-01"}, // quota removed
+			"tunnel:main": {Month: "2025-01"}, // quota raised
+		},
+		Resets: map[string]Reset{},
+	}
+
+	cut, lift := state.Plan(quotas, ledger)
+	if len(cut) != 1 || cut[0] != "ssh:alice" {
+		t.Errorf("cut = %v, want [ssh:alice]", cut)
+	}
+	if len(lift) != 2 || lift[0] != "ssh:carol" || lift[1] != "tunnel:main" {
+		t.Errorf("lift = %v, want [ssh:carol tunnel:main]", lift)
+	}
+}
+
+func TestQuotaState_NewMonthAndReset(t *testing.T) {
+	quotas := []config.QuotaConfig{{Account: "ssh:alice", Limit: "1G"}}
+	ledger := &Ledger{Month: "2025-02", Entries: []Entry{{Kind: KindSSH, Name: "alice", Bytes: 3 << 30}}}
+
+	state := &QuotaState{
+		Cutoffs: map[string]*Cutoff{"ssh:alice": {Month: "2025-01"}},
+		Resets:  map[string]Reset{},
+	}
+	if _, lift := state.Plan(quotas, ledger); len(lift) != 1 {
+		t.Errorf("cutoff from an earlier month should be lifted, got %v", lift)
+	}
+
+	// A manual reset discounts the traffic recorded before it
+	state.Cutoffs = map[string]*Cutoff{}
+	state.Resets["ssh:alice"] = Reset{Month: "2025-02", Bytes: 3 << 30}
+	if cut, _ := state.Plan(quotas, ledger); len(cut) != 0 {
+		t.Errorf("reset account should not be cut off, got %v", cut)
+	}
+	if used := state.Used(ledger, "ssh:alice"); used != 0 {
+		t.Errorf("Used = %d, want 0", used)
+	}
+}
+
+func TestQuotaState_SaveLoad(t *testing.T) {
+	Dir = t.TempDir()
+
+	state, err := LoadQuotaState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Cutoffs["socks:reseller1"] = &Cutoff{Month: "2025-01", Password: "original"}
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsCutOff("socks:reseller1") {
+		t.Error("expected socks:reseller1 to be cut off")
+	}
+	loaded, err := LoadQuotaState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Cutoffs["socks:reseller1"].Password != "original" {
+		t.Errorf("password not preserved: %+v", loaded.Cutoffs["socks:reseller1"])
+	}
+	if months := Months(); len(months) != 0 {
+		t.Errorf("quota state listed as a month: %v", months)
+	}
+}
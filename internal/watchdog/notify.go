@@ -0,0 +1,41 @@
+package watchdog
+
+import (
+	"net"
+	"os"
+)
+
+// Notifier sends sd_notify state updates.
+type Notifier interface {
+	Notify(state string) error
+}
+
+// socketNotifier writes to the socket named by $NOTIFY_SOCKET.
+type socketNotifier struct {
+	addr string
+}
+
+// SystemdNotifier returns a notifier for $NOTIFY_SOCKET. When the variable is
+// unset (not running under systemd) notifications are dropped.
+func SystemdNotifier() Notifier {
+	return &socketNotifier{addr: os.Getenv("NOTIFY_SOCKET")}
+}
+
+// Notify sends a state string such as "READY=1" or "WATCHDOG=1".
+func (n *socketNotifier) Notify(state string) error {
+	if n.addr == "" {
+		return nil
+	}
+	addr := n.addr
+	// Abstract socket namespace
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
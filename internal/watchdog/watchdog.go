@@ -0,0 +1,181 @@
+// Package watchdog wraps a tunnel process and feeds the systemd watchdog
+// while the process keeps answering DNS queries.
+//
+// The wrapper starts the transport as a child process and periodically sends
+// it a DNS query for a random name under the tunnel domain. Any well-formed
+// response counts as healthy. READY=1 is sent after the first answer and
+// WATCHDOG=1 after every answer, so systemd restarts the unit when the
+// transport hangs while its process is still alive.
+package watchdog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Config configures the wrapper.
+type Config struct {
+	Addr     string        // Address the transport listens on (host:port)
+	Domain   string        // Tunnel domain used for probe queries
+	Timeout  time.Duration // systemd WatchdogSec
+	Command  []string      // Transport command line
+	Notifier Notifier
+}
+
+// Run starts the command and probes it until it exits. It returns the
+// command's exit code.
+func Run(cfg Config) (int, error) {
+	if len(cfg.Command) == 0 {
+		return 1, errors.New("no command given")
+	}
+	if cfg.Notifier == nil {
+		cfg.Notifier = SystemdNotifier()
+	}
+
+	cmd := exec.Command(cfg.Command[0], cfg.Command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return 1, fmt.Errorf("failed to start %s: %w", cfg.Command[0], err)
+	}
+
+	// Forward termination signals to the transport
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	probing := make(chan struct{})
+	go func() {
+		probeLoop(ctx, cfg)
+		close(probing)
+	}()
+
+	for {
+		select {
+		case sig := <-sigCh:
+			cmd.Process.Signal(sig)
+		case err := <-done:
+			cancel()
+			<-probing
+			cfg.Notifier.Notify("STOPPING=1")
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return exitErr.ExitCode(), nil
+			}
+			if err != nil {
+				return 1, err
+			}
+			return 0, nil
+		}
+	}
+}
+
+// probeLoop probes the transport at a third of the watchdog timeout, so a
+// single lost query does not trigger a restart.
+func probeLoop(ctx context.Context, cfg Config) {
+	interval := cfg.Timeout / 3
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	probeTimeout := interval
+	if probeTimeout > 5*time.Second {
+		probeTimeout = 5 * time.Second
+	}
+
+	ready := false
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		if err := Probe(cfg.Addr, cfg.Domain, probeTimeout); err == nil {
+			if !ready {
+				cfg.Notifier.Notify("READY=1")
+				ready = true
+				ticker.Reset(interval)
+			}
+			cfg.Notifier.Notify("WATCHDOG=1")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Probe sends a DNS query for a random name under domain to addr and waits
+// for a response with the matching ID.
+func Probe(addr, domain string, timeout time.Duration) error {
+	query, id, err := buildQuery(domain)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(query); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return err
+		}
+		// Header must be a response (QR bit) to our query ID
+		if n >= 12 && binary.BigEndian.Uint16(buf[0:2]) == id && buf[2]&0x80 != 0 {
+			return nil
+		}
+	}
+}
+
+// buildQuery builds a TXT query for a random label under domain.
+func buildQuery(domain string) ([]byte, uint16, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(random[0:2])
+
+	name := fmt.Sprintf("wd%x.%s", random[2:], strings.TrimSuffix(domain, "."))
+
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:2], id)
+	msg[2] = 0x01 // RD
+	msg[5] = 0x01 // QDCOUNT=1
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, 0, fmt.Errorf("invalid domain '%s'", domain)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = append(msg, 0x00, 0x10) // QTYPE=TXT
+	msg = append(msg, 0x00, 0x01) // QCLASS=IN
+
+	return msg, id, nil
+}
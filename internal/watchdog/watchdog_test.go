@@ -0,0 +1,132 @@
+package watchdog
+
+import (
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startResponder answers every query on a UDP socket by echoing it back with
+// the QR bit set.
+func startResponder(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			buf[2] |= 0x80
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestProbe(t *testing.T) {
+	addr := startResponder(t)
+	if err := Probe(addr, "t.example.com", time.Second); err != nil {
+		t.Fatalf("Probe() error: %v", err)
+	}
+}
+
+func TestProbe_NoAnswer(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := Probe(conn.LocalAddr().String(), "t.example.com", 200*time.Millisecond); err == nil {
+		t.Fatal("expected timeout")
+	}
+}
+
+func TestBuildQuery(t *testing.T) {
+	msg, id, err := buildQuery("t.example.com.")
+	if err != nil {
+		t.Fatalf("buildQuery() error: %v", err)
+	}
+	if binary.BigEndian.Uint16(msg[0:2]) != id {
+		t.Error("ID mismatch")
+	}
+	if msg[5] != 1 {
+		t.Errorf("QDCOUNT = %d, want 1", msg[5])
+	}
+	if !strings.HasSuffix(string(msg), "\x01t\x07example\x03com\x00\x00\x10\x00\x01") {
+		t.Errorf("unexpected question: %q", msg[12:])
+	}
+}
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	states []string
+}
+
+func (r *recordingNotifier) Notify(state string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, state)
+	return nil
+}
+
+func TestRun_NotifiesAndReturnsExitCode(t *testing.T) {
+	addr := startResponder(t)
+	n := &recordingNotifier{}
+
+	code, err := Run(Config{
+		Addr:     addr,
+		Domain:   "t.example.com",
+		Timeout:  3 * time.Second,
+		Command:  []string{"sh", "-c", "sleep 0.5; exit 3"},
+		Notifier: n,
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	got := strings.Join(n.states, ",")
+	if !strings.HasPrefix(got, "READY=1,WATCHDOG=1") || !strings.HasSuffix(got, "STOPPING=1") {
+		t.Errorf("states = %s", got)
+	}
+}
+
+func TestSystemdNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	if err := SystemdNotifier().Notify("READY=1"); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "READY=1" {
+		t.Errorf("got %q", buf[:n])
+	}
+}